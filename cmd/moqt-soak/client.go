@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// runCycle connects the configured publishers and subscribers to addr, keeps
+// them running until the churn interval elapses and then closes every
+// session. It returns the number of frames read by the subscribers.
+func runCycle(ctx context.Context, cfg config, addr string, cycle int) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.churn)
+	defer cancel()
+
+	dialer := &moqt.Dialer{
+		TLSConfig: &tls.Config{InsecureSkipVerify: true}, // the soak server uses a self-signed certificate
+	}
+
+	var (
+		frames atomic.Uint64
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+	)
+	fail := func(err error) {
+		// Dials and subscriptions cut short by the end of the cycle are expected.
+		if ctx.Err() != nil {
+			return
+		}
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	for i := range cfg.publishers {
+		wg.Go(func() {
+			mux := moqt.NewTrackMux(0)
+			path := moqt.BroadcastPath(fmt.Sprintf("%s%d-%d", publisherPrefix, cycle, i))
+			mux.PublishFunc(ctx, path, func(tw *moqt.TrackWriter) {
+				publishGroups(tw, cfg.publish)
+			})
+
+			sess, err := dialer.Dial(ctx, addr, mux)
+			if err != nil {
+				fail(fmt.Errorf("publisher %d: %w", i, err))
+				return
+			}
+			defer sess.CloseWithError(moqt.NoError, "churn")
+
+			<-ctx.Done()
+		})
+	}
+
+	for i := range cfg.subscribers {
+		wg.Go(func() {
+			sess, err := dialer.Dial(ctx, addr, moqt.NewTrackMux(0))
+			if err != nil {
+				fail(fmt.Errorf("subscriber %d: %w", i, err))
+				return
+			}
+			defer sess.CloseWithError(moqt.NoError, "churn")
+
+			if err := consume(ctx, sess, serverBroadcast, &frames); err != nil {
+				fail(fmt.Errorf("subscriber %d: %w", i, err))
+			}
+		})
+	}
+
+	wg.Wait()
+
+	return frames.Load(), errors.Join(errs...)
+}
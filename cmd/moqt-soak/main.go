@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// config holds the command line options of the soak test.
+type config struct {
+	mode   string
+	addr   string
+	listen string
+	cert   string
+	key    string
+
+	duration    time.Duration
+	churn       time.Duration
+	publishers  int
+	subscribers int
+	publish     publishOptions
+
+	settle time.Duration
+	sample time.Duration
+	limits limits
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.mode, "mode", "all", "all: run the server and clients in-process; server: run only the server; client: run only the clients against -addr")
+	flag.StringVar(&cfg.addr, "addr", "", "server URL for client mode (moqt://host:port for native QUIC, https://host:port for WebTransport)")
+	flag.StringVar(&cfg.listen, "listen", ":4433", "listen address for server mode")
+	flag.StringVar(&cfg.cert, "cert", "", "certificate file for server mode; a self-signed certificate is generated if empty")
+	flag.StringVar(&cfg.key, "key", "", "private key file for server mode")
+	flag.DurationVar(&cfg.duration, "duration", time.Hour, "total run time")
+	flag.DurationVar(&cfg.churn, "churn", 30*time.Second, "how long sessions live before they are closed and reconnected")
+	flag.IntVar(&cfg.publishers, "publishers", 4, "publisher sessions per cycle")
+	flag.IntVar(&cfg.subscribers, "subscribers", 16, "subscriber sessions per cycle")
+	flag.DurationVar(&cfg.publish.groupInterval, "group-interval", 100*time.Millisecond, "interval between groups written by each publisher")
+	flag.IntVar(&cfg.publish.frames, "frames", 5, "frames per group")
+	flag.IntVar(&cfg.publish.frameSize, "frame-size", 1024, "frame payload size in bytes")
+	flag.DurationVar(&cfg.settle, "settle", 10*time.Second, "how long resources may take to return to baseline after a cycle")
+	flag.DurationVar(&cfg.sample, "sample", 5*time.Second, "resource sampling interval in server mode")
+	flag.IntVar(&cfg.limits.goroutines, "goroutine-slack", 10, "goroutines allowed above baseline")
+	flag.Uint64Var(&cfg.limits.heapInuse, "heap-slack", 32<<20, "heap bytes in use allowed above baseline")
+	flag.IntVar(&cfg.limits.fds, "fd-slack", 8, "file descriptors allowed above baseline")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		slog.Error("soak test failed", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("soak test passed")
+}
+
+// run runs the soak test until cfg.duration elapses or ctx is canceled.
+func run(ctx context.Context, cfg config) error {
+	switch cfg.mode {
+	case "all":
+		addr, err := freeUDPAddr()
+		if err != nil {
+			return err
+		}
+		tlsConfig, err := selfSignedConfig()
+		if err != nil {
+			return err
+		}
+
+		srv := newSoakServer(addr, tlsConfig, cfg.publish)
+		errCh := make(chan error, 1)
+		go func() { errCh <- srv.ListenAndServe() }()
+
+		err = runClients(ctx, cfg, "moqt://"+addr)
+		srv.Close()
+		return errors.Join(err, <-errCh)
+	case "server":
+		return runServer(ctx, cfg)
+	case "client":
+		if cfg.addr == "" {
+			return errors.New("-addr is required in client mode")
+		}
+		return runClients(ctx, cfg, cfg.addr)
+	default:
+		return fmt.Errorf("unknown mode %q", cfg.mode)
+	}
+}
+
+// runClients churns sessions against addr and checks after every cycle that
+// the process resources return to the baseline taken after a warm-up cycle.
+func runClients(ctx context.Context, cfg config, addr string) error {
+	runCtx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	// The first cycle initializes lazily allocated state such as buffer pools
+	// and is not part of the measurement.
+	slog.Info("warming up", "addr", addr)
+	if _, err := runCycle(runCtx, cfg, addr, 0); err != nil {
+		return fmt.Errorf("warm-up cycle: %w", err)
+	}
+	base := baseline(ctx, cfg.settle)
+	slog.Info("baseline", "resources", base)
+
+	for cycle := 1; runCtx.Err() == nil; cycle++ {
+		frames, err := runCycle(runCtx, cfg, addr, cycle)
+		if err != nil {
+			return fmt.Errorf("cycle %d: %w", cycle, err)
+		}
+		if frames == 0 && runCtx.Err() == nil {
+			slog.Warn("no frames received", "cycle", cycle)
+		}
+
+		snap, leaks := waitSettled(ctx, base, cfg.limits, cfg.settle)
+		slog.Info("cycle complete", "cycle", cycle, "frames", frames, "resources", snap)
+		if len(leaks) > 0 {
+			return leakError(fmt.Sprintf("cycle %d", cycle), leaks)
+		}
+	}
+	return nil
+}
+
+// runServer serves clients and checks whenever all sessions have gone that
+// the process resources return to the baseline taken at startup.
+func runServer(ctx context.Context, cfg config) error {
+	runCtx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	var tlsConfig *tls.Config
+	if cfg.cert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.cert, cfg.key)
+		if err != nil {
+			return err
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	} else {
+		var err error
+		if tlsConfig, err = selfSignedConfig(); err != nil {
+			return err
+		}
+	}
+
+	srv := newSoakServer(cfg.listen, tlsConfig, cfg.publish)
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+	defer srv.Close()
+
+	base := baseline(ctx, cfg.settle)
+	slog.Info("listening", "addr", cfg.listen, "baseline", base)
+
+	ticker := time.NewTicker(cfg.sample)
+	defer ticker.Stop()
+
+	var checked uint64
+	for {
+		select {
+		case <-runCtx.Done():
+			return nil
+		case err := <-errCh:
+			return err
+		case <-ticker.C:
+		}
+
+		served := srv.served.Load()
+		if srv.active.Load() > 0 || served == checked {
+			slog.Info("sample", "sessions", srv.active.Load(), "frames", srv.frames.Load(), "resources", takeSnapshot())
+			continue
+		}
+		checked = served
+
+		snap, leaks := waitSettled(ctx, base, cfg.limits, cfg.settle)
+		slog.Info("idle", "served", served, "frames", srv.frames.Load(), "resources", snap)
+		// Sessions that arrived while settling make the sample meaningless.
+		if len(leaks) > 0 && srv.active.Load() == 0 {
+			return leakError(fmt.Sprintf("%d sessions", served), leaks)
+		}
+	}
+}
+
+// baseline samples resources until the goroutine count is stable between two
+// samples or timeout elapses.
+func baseline(ctx context.Context, timeout time.Duration) snapshot {
+	deadline := time.Now().Add(timeout)
+	prev := takeSnapshot()
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		time.Sleep(250 * time.Millisecond)
+		snap := takeSnapshot()
+		if snap.goroutines == prev.goroutines {
+			return snap
+		}
+		prev = snap
+	}
+	return prev
+}
+
+// leakError dumps the goroutine stacks to help locate the leak and returns
+// an error describing it.
+func leakError(after string, leaks []string) error {
+	_ = pprof.Lookup("goroutine").WriteTo(os.Stderr, 1)
+	return fmt.Errorf("resources did not return to baseline after %s: %s", after, strings.Join(leaks, "; "))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// snapshot holds the process resources tracked by the soak test.
+type snapshot struct {
+	goroutines int
+	heapInuse  uint64
+	fds        int // -1 when the platform does not expose open descriptors
+}
+
+func (s snapshot) String() string {
+	return fmt.Sprintf("goroutines=%d heap_inuse=%dKiB fds=%d", s.goroutines, s.heapInuse>>10, s.fds)
+}
+
+// limits is how far a snapshot may exceed the baseline before it counts as a leak.
+type limits struct {
+	goroutines int
+	heapInuse  uint64
+	fds        int
+}
+
+// takeSnapshot collects garbage and samples the current process resources.
+func takeSnapshot() snapshot {
+	// Two cycles so that objects only held by sync.Pool victim caches are freed.
+	runtime.GC()
+	runtime.GC()
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	return snapshot{
+		goroutines: runtime.NumGoroutine(),
+		heapInuse:  ms.HeapInuse,
+		fds:        countFDs(),
+	}
+}
+
+// countFDs returns the number of open file descriptors, or -1 if unknown.
+func countFDs() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		entries, err := os.ReadDir(dir)
+		if err == nil {
+			return len(entries)
+		}
+	}
+	return -1
+}
+
+// leaks reports every resource of s that exceeds base by more than lim.
+func (s snapshot) leaks(base snapshot, lim limits) []string {
+	var leaks []string
+	if s.goroutines > base.goroutines+lim.goroutines {
+		leaks = append(leaks, fmt.Sprintf("goroutines: %d, baseline %d (+%d allowed)",
+			s.goroutines, base.goroutines, lim.goroutines))
+	}
+	if s.heapInuse > base.heapInuse+lim.heapInuse {
+		leaks = append(leaks, fmt.Sprintf("heap in use: %dKiB, baseline %dKiB (+%dKiB allowed)",
+			s.heapInuse>>10, base.heapInuse>>10, lim.heapInuse>>10))
+	}
+	if s.fds >= 0 && base.fds >= 0 && s.fds > base.fds+lim.fds {
+		leaks = append(leaks, fmt.Sprintf("file descriptors: %d, baseline %d (+%d allowed)",
+			s.fds, base.fds, lim.fds))
+	}
+	return leaks
+}
+
+// waitSettled samples resources until they are back within lim of base or
+// timeout elapses. It returns the last snapshot and its leaks, if any.
+func waitSettled(ctx context.Context, base snapshot, lim limits, timeout time.Duration) (snapshot, []string) {
+	deadline := time.Now().Add(timeout)
+	for {
+		snap := takeSnapshot()
+		leaks := snap.leaks(base, lim)
+		if len(leaks) == 0 || time.Now().After(deadline) || ctx.Err() != nil {
+			return snap, leaks
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
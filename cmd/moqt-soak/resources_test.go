@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSnapshotLeaks(t *testing.T) {
+	base := snapshot{goroutines: 10, heapInuse: 1 << 20, fds: 5}
+	lim := limits{goroutines: 2, heapInuse: 1 << 20, fds: 1}
+
+	tests := map[string]struct {
+		snap  snapshot
+		leaks int
+	}{
+		"at baseline": {
+			snap: base,
+		},
+		"within limits": {
+			snap: snapshot{goroutines: 12, heapInuse: 2 << 20, fds: 6},
+		},
+		"goroutine leak": {
+			snap:  snapshot{goroutines: 13, heapInuse: 1 << 20, fds: 5},
+			leaks: 1,
+		},
+		"all resources leak": {
+			snap:  snapshot{goroutines: 20, heapInuse: 4 << 20, fds: 9},
+			leaks: 3,
+		},
+		"unknown fds": {
+			snap: snapshot{goroutines: 10, heapInuse: 1 << 20, fds: -1},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.snap.leaks(base, lim); len(got) != tt.leaks {
+				t.Fatalf("leaks = %v; want %d", got, tt.leaks)
+			}
+		})
+	}
+}
+
+func TestWaitSettled(t *testing.T) {
+	base := takeSnapshot()
+	lim := limits{goroutines: 0, heapInuse: 64 << 20, fds: 4}
+
+	done := make(chan struct{})
+	go func() {
+		<-done
+	}()
+
+	// The extra goroutine is reported until it exits.
+	_, leaks := waitSettled(context.Background(), base, lim, 0)
+	if len(leaks) == 0 {
+		t.Fatal("expected a goroutine leak while the goroutine is running")
+	}
+
+	close(done)
+	if _, leaks := waitSettled(context.Background(), base, lim, 5*time.Second); len(leaks) != 0 {
+		t.Fatalf("unexpected leaks after the goroutine exited: %v", leaks)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+const (
+	// serverBroadcast is published by the server and read by subscriber clients.
+	serverBroadcast moqt.BroadcastPath = "/soak/server"
+	// publisherPrefix is the prefix under which publisher clients announce.
+	publisherPrefix = "/soak/pub/"
+	// soakTrack is the only track name used by the soak test.
+	soakTrack moqt.TrackName = "data"
+)
+
+// soakServer publishes serverBroadcast and consumes every broadcast announced
+// by its clients.
+type soakServer struct {
+	server *moqt.Server
+	cancel context.CancelFunc
+
+	active atomic.Int64  // sessions being served
+	served atomic.Uint64 // sessions served since start
+	frames atomic.Uint64 // frames read from publisher clients
+}
+
+func newSoakServer(addr string, tlsConfig *tls.Config, pub publishOptions) *soakServer {
+	s := &soakServer{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	mux := moqt.NewTrackMux(0)
+	mux.PublishFunc(ctx, serverBroadcast, func(tw *moqt.TrackWriter) {
+		publishGroups(tw, pub)
+	})
+
+	handler := moqt.HandleFunc(s.serve)
+
+	httpMux := http.NewServeMux()
+	httpMux.Handle("/", &moqt.WebTransportHandler{
+		CheckOrigin: func(r *http.Request) bool { return true },
+		TrackMux:    mux,
+		Handler:     handler,
+	})
+
+	s.server = &moqt.Server{
+		Addr:               addr,
+		TLSConfig:          tlsConfig,
+		TrackMux:           mux,
+		Handler:            handler,
+		WebTransportServer: moqt.NewWebTransportServer(httpMux),
+	}
+	return s
+}
+
+// ListenAndServe serves until Close is called.
+func (s *soakServer) ListenAndServe() error {
+	err := s.server.ListenAndServe()
+	if err == moqt.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close stops the server broadcast and closes all sessions.
+func (s *soakServer) Close() error {
+	s.cancel()
+	return s.server.Close()
+}
+
+func (s *soakServer) serve(sess *moqt.Session) {
+	s.active.Add(1)
+	defer s.active.Add(-1)
+	s.served.Add(1)
+
+	ctx := sess.Context()
+	defer func() { <-ctx.Done() }()
+
+	anns, err := sess.AcceptAnnounce(publisherPrefix)
+	if err != nil {
+		slog.Warn("accepting announcements failed", "error", err)
+		return
+	}
+	defer anns.Close()
+
+	var wg sync.WaitGroup
+	for ann := range anns.Announcements(ctx) {
+		wg.Go(func() {
+			_ = consume(ctx, sess, ann.BroadcastPath(), &s.frames)
+		})
+	}
+	wg.Wait()
+}
+
+// publishOptions describes the traffic written by every publisher.
+type publishOptions struct {
+	groupInterval time.Duration
+	frames        int
+	frameSize     int
+}
+
+// publishGroups writes groups to tw until the subscription ends.
+func publishGroups(tw *moqt.TrackWriter, opts publishOptions) {
+	ticker := time.NewTicker(opts.groupInterval)
+	defer ticker.Stop()
+
+	payload := make([]byte, opts.frameSize)
+	frame := moqt.NewFrame(opts.frameSize)
+
+	for {
+		select {
+		case <-tw.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		group, err := tw.OpenGroup()
+		if err != nil {
+			return
+		}
+		for range opts.frames {
+			frame.Reset()
+			_, _ = frame.Write(payload)
+			if err := group.WriteFrame(frame); err != nil {
+				break
+			}
+		}
+		_ = group.Close()
+	}
+}
+
+// consume subscribes to path and counts the frames read until the
+// subscription or ctx ends.
+func consume(ctx context.Context, sess *moqt.Session, path moqt.BroadcastPath, frames *atomic.Uint64) error {
+	track, err := sess.Subscribe(ctx, path, soakTrack, nil)
+	if err != nil {
+		return err
+	}
+	defer track.Close()
+
+	frame := moqt.NewFrame(0)
+	for {
+		group, err := track.AcceptGroup(ctx)
+		if err != nil {
+			return err
+		}
+		for range group.Frames(frame) {
+			frames.Add(1)
+		}
+	}
+}
+
+// selfSignedConfig returns a TLS config with a certificate for the loopback
+// addresses, generated in memory so the soak test needs no certificate files.
+func selfSignedConfig() (*tls.Config, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "moqt-soak"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(7 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, nil
+}
+
+// freeUDPAddr returns a loopback address with a UDP port that is currently free.
+func freeUDPAddr() (string, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String(), nil
+}
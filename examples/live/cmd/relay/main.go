@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/examples/live/relaynode"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/moqt/relay"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:4470", "address to listen on")
+	flag.Parse()
+
+	node := relaynode.New(*addr, &tls.Config{
+		NextProtos:   []string{moqt.NextProtoMOQ},
+		Certificates: []tls.Certificate{generateCert()},
+	}, &relay.Config{
+		CacheGroups: 4,
+		CacheTTL:    5 * time.Second,
+		Logger:      slog.Default(),
+	}, slog.Default())
+
+	slog.Info("relaying", "addr", *addr)
+	if err := node.ListenAndServe(); err != nil {
+		slog.Error("failed to listen and serve", "error", err)
+	}
+}
+
+func generateCert() tls.Certificate {
+	// Find project root by looking for go.mod file
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		panic(err)
+	}
+
+	// Load certificates from the examples/cert directory (project root)
+	certPath := filepath.Join(projectRoot, "examples", "cert", "localhost.pem")
+	keyPath := filepath.Join(projectRoot, "examples", "cert", "localhost-key.pem")
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		panic(err)
+	}
+	return cert
+}
+
+// findProjectRoot searches for the project root by looking for go.mod file
+func findProjectRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// Reached filesystem root
+			break
+		}
+		dir = parent
+	}
+
+	return "", os.ErrNotExist
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/qumo-dev/gomoqt/examples/live/studio"
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+func main() {
+	url := flag.String("relay", "moqt://localhost:4470", "URL of the relay")
+	path := flag.String("path", "/live/studio", "broadcast path")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := studio.New(nil)
+	if err != nil {
+		slog.Error("failed to create studio", "error", err)
+		return
+	}
+
+	mux := moqt.NewTrackMux(0)
+	s.Publish(ctx, mux, moqt.BroadcastPath(*path))
+
+	dialer := moqt.Dialer{
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true, // TODO: Not recommended for production
+		},
+		Logger: slog.Default(),
+	}
+	sess, err := dialer.Dial(ctx, *url, mux)
+	if err != nil {
+		slog.Error("failed to dial", "error", err)
+		return
+	}
+	defer sess.CloseWithError(moqt.NoError, "")
+
+	slog.Info("publishing", "path", *path, "renditions", len(s.Catalog().Tracks))
+	select {
+	case <-ctx.Done():
+	case <-sess.Context().Done():
+	}
+}
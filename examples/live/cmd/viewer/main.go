@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/examples/live/viewer"
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+func main() {
+	url := flag.String("relay", "moqt://localhost:4470", "URL of the relay")
+	path := flag.String("path", "/live/studio", "broadcast path")
+	bandwidth := flag.Int64("bandwidth", 0, "bandwidth in bits per second; probed if zero")
+	record := flag.String("record", "", "file to record the played frames to")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	dialer := moqt.Dialer{
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true, // TODO: Not recommended for production
+		},
+		Logger: slog.Default(),
+	}
+	sess, err := dialer.Dial(ctx, *url, nil)
+	if err != nil {
+		slog.Error("failed to dial", "error", err)
+		return
+	}
+	defer sess.CloseWithError(moqt.NoError, "")
+
+	config := &viewer.Config{Bandwidth: *bandwidth}
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			slog.Error("failed to create recording", "error", err)
+			return
+		}
+		defer f.Close()
+		config.Record = f
+	}
+
+	v := viewer.New(sess, moqt.BroadcastPath(*path), config)
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				slog.Info("playing", "rendition", v.Rendition(), "frames", v.Frames())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if err := v.Run(ctx); err != nil {
+		slog.Error("failed to play", "error", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Package live_test runs the studio, relay node and viewer of the live
+// example together over QUIC, so that behavioral changes of the public API
+// they use fail the build.
+package live_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/examples/live/relaynode"
+	"github.com/qumo-dev/gomoqt/examples/live/studio"
+	"github.com/qumo-dev/gomoqt/examples/live/viewer"
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/moqt/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLive(t *testing.T) {
+	const path moqt.BroadcastPath = "/live/studio"
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	node, addr := startRelayNode(t)
+
+	s, err := studio.New(&studio.Config{GroupInterval: 20 * time.Millisecond})
+	require.NoError(t, err)
+	studioMux := moqt.NewTrackMux(0)
+	s.Publish(ctx, studioMux, path)
+	moqttest.Dial(t, addr, studioMux)
+
+	// The relay re-announces the broadcast with the metadata of its
+	// renditions.
+	var ann *moqt.Announcement
+	require.Eventually(t, func() bool {
+		ann, _ = node.Mux.TrackHandler(path)
+		return ann != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	md, ok := ann.TrackMetadata("video-720p")
+	require.True(t, ok)
+	assert.Equal(t, uint64(3_000_000), md.Bitrate)
+	assert.Equal(t, uint64(720), md.Height)
+
+	var record lockedBuffer
+	v := viewer.New(moqttest.Dial(t, addr, nil), path, &viewer.Config{
+		ProbeDuration: 200 * time.Millisecond,
+		Record:        &record,
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- v.Run(ctx) }()
+
+	// A local connection has room for the best rendition.
+	require.Eventually(t, func() bool {
+		return v.Rendition() == "video-1080p" && v.Frames() > 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// Less bandwidth switches to the rendition that fits.
+	v.SetBandwidth(1_000_000)
+	require.Eventually(t, func() bool {
+		return strings.Contains(record.String(), "video-360p ")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "video-360p", v.Rendition())
+
+	// The recording holds the frames of both renditions, in order.
+	rec := record.String()
+	assert.Less(t, strings.Index(rec, "video-1080p "), strings.Index(rec, "video-360p "))
+	assert.NotContains(t, rec, "video-720p ")
+
+	cancel()
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("viewer did not stop")
+	}
+}
+
+func TestLive_ViewerWithoutBroadcast(t *testing.T) {
+	_, addr := startRelayNode(t)
+
+	v := viewer.New(moqttest.Dial(t, addr, nil), "/live/none", &viewer.Config{Bandwidth: 1_000_000})
+	err := v.Run(t.Context())
+
+	var subErr *moqt.SubscribeError
+	require.ErrorAs(t, err, &subErr)
+	assert.Equal(t, moqt.SubscribeErrorCodeNotFound, subErr.SubscribeErrorCode())
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func startRelayNode(t *testing.T) (*relaynode.Node, string) {
+	t.Helper()
+
+	n := relaynode.New("", nil, &relay.Config{
+		CacheGroups: 4,
+		CacheTTL:    time.Minute,
+	}, nil)
+	t.Cleanup(func() {
+		_ = n.Close()
+	})
+	return n, moqttest.Serve(t, n.Server)
+}
@@ -0,0 +1,66 @@
+// Package relaynode is the relay deployment of the live example: a MOQ
+// server that relays the broadcasts of the publishers connecting to it to
+// the viewers connecting to it, and serves bandwidth probes so that viewers
+// can choose their initial rendition.
+package relaynode
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/moqt/relay"
+)
+
+// Node is a relay server.
+type Node struct {
+	// Mux holds the relayed broadcasts and the bandwidth probe.
+	Mux *moqt.TrackMux
+	// Relay serves subscriptions to the relayed broadcasts.
+	Relay *relay.Relay
+	// Server serves the sessions of the node. Its ListenFunc can be set
+	// before ListenAndServe.
+	Server *moqt.Server
+
+	cancel context.CancelFunc
+}
+
+// New returns a Node listening on addr over QUIC. config configures the
+// relay and may be nil.
+func New(addr string, tlsConfig *tls.Config, config *relay.Config, logger *slog.Logger) *Node {
+	mux := moqt.NewTrackMux(moqt.NewHopID())
+	n := &Node{
+		Mux:   mux,
+		Relay: relay.New(mux, config),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	mux.Publish(ctx, moqt.BandwidthProbePath, moqt.BandwidthProbeHandler)
+
+	n.Server = &moqt.Server{
+		Addr:                addr,
+		TLSConfig:           tlsConfig,
+		TrackMux:            mux,
+		DisableWebTransport: true,
+		FetchHandler:        n.Relay,
+		Logger:              logger,
+		Handler: moqt.HandleFunc(func(sess *moqt.Session) {
+			// Relay whatever the peer publishes; viewers publish nothing.
+			_ = n.Relay.Serve(sess.Context(), sess, "/")
+		}),
+	}
+	return n
+}
+
+// ListenAndServe serves sessions until the node is closed.
+func (n *Node) ListenAndServe() error {
+	return n.Server.ListenAndServe()
+}
+
+// Close closes the server and its sessions.
+func (n *Node) Close() error {
+	n.cancel()
+	return n.Server.Close()
+}
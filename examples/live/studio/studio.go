@@ -0,0 +1,149 @@
+// Package studio is the publisher of the live example: a broadcast of
+// several video renditions of a synthetic source, described by an MSF
+// catalog.
+//
+// Each rendition writes a group every GroupInterval with FramesPerGroup
+// frames whose payloads name the rendition, group and frame, such as
+// "video-720p 12 3", so that viewers can tell which rendition they receive.
+package studio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf"
+)
+
+// Rendition is a video rendition of the broadcast.
+type Rendition struct {
+	Name    string
+	Width   int64
+	Height  int64
+	Bitrate int64
+}
+
+// DefaultRenditions are the renditions published if Config.Renditions is
+// empty.
+var DefaultRenditions = []Rendition{
+	{Name: "video-1080p", Width: 1920, Height: 1080, Bitrate: 6_000_000},
+	{Name: "video-720p", Width: 1280, Height: 720, Bitrate: 3_000_000},
+	{Name: "video-360p", Width: 640, Height: 360, Bitrate: 800_000},
+}
+
+// Config contains configuration options for a Studio.
+type Config struct {
+	// Renditions are the renditions of the broadcast.
+	// If empty, defaults to DefaultRenditions.
+	Renditions []Rendition
+
+	// GroupInterval is the time between two groups of a rendition.
+	// If zero, defaults to 100ms.
+	GroupInterval time.Duration
+
+	// FramesPerGroup is the number of frames of each group.
+	// If zero, defaults to 3.
+	FramesPerGroup int
+}
+
+// Studio publishes a broadcast with a catalog track and one track per
+// rendition.
+type Studio struct {
+	broadcast      *msf.Broadcast
+	groupInterval  time.Duration
+	framesPerGroup int
+}
+
+// New returns a Studio publishing the renditions of config, which may be
+// nil.
+func New(config *Config) (*Studio, error) {
+	s := &Studio{
+		groupInterval:  100 * time.Millisecond,
+		framesPerGroup: 3,
+	}
+	renditions := DefaultRenditions
+	if config != nil {
+		if len(config.Renditions) > 0 {
+			renditions = config.Renditions
+		}
+		if config.GroupInterval > 0 {
+			s.groupInterval = config.GroupInterval
+		}
+		if config.FramesPerGroup > 0 {
+			s.framesPerGroup = config.FramesPerGroup
+		}
+	}
+
+	broadcast, err := msf.NewBroadcast(msf.Catalog{Version: 1})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range renditions {
+		if r.Name == "" {
+			return nil, errors.New("studio: rendition name is required")
+		}
+		track := msf.Track{
+			Name:      r.Name,
+			Packaging: msf.PackagingLOC,
+			Role:      msf.RoleVideo,
+			IsLive:    new(true),
+			Codec:     "avc1.64001f",
+			Width:     new(r.Width),
+			Height:    new(r.Height),
+			Bitrate:   new(r.Bitrate),
+		}
+		if err := broadcast.RegisterTrack(track, moqt.TrackHandlerFunc(s.serveRendition)); err != nil {
+			return nil, fmt.Errorf("studio: %w", err)
+		}
+	}
+	s.broadcast = broadcast
+	return s, nil
+}
+
+// Catalog returns the catalog of the broadcast.
+func (s *Studio) Catalog() msf.Catalog {
+	return s.broadcast.Catalog()
+}
+
+// Publish announces the broadcast at path on mux until ctx is canceled. The
+// announcement declares the metadata of every rendition.
+func (s *Studio) Publish(ctx context.Context, mux *moqt.TrackMux, path moqt.BroadcastPath) {
+	ann, _ := moqt.NewAnnouncement(ctx, path)
+	s.broadcast.DeclareTracks(ann)
+	mux.Announce(ann, s.broadcast)
+}
+
+// serveRendition writes groups to a subscriber of a rendition until the
+// subscription ends.
+func (s *Studio) serveRendition(tw *moqt.TrackWriter) {
+	ticker := time.NewTicker(s.groupInterval)
+	defer ticker.Stop()
+
+	frame := moqt.NewFrame(64)
+	for {
+		gw, err := tw.OpenGroup()
+		if err != nil {
+			return
+		}
+		for i := range s.framesPerGroup {
+			frame.Reset()
+			_, _ = fmt.Fprintf(frame, "%s %d %d", tw.TrackName, gw.GroupSequence(), i)
+			if err = gw.WriteFrame(frame); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			gw.CancelWrite(moqt.InternalGroupErrorCode)
+			return
+		}
+		_ = gw.Close()
+
+		select {
+		case <-ticker.C:
+		case <-tw.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package viewer is the subscriber of the live example. A Viewer reads the
+// MSF catalog of a broadcast, plays the best rendition the bandwidth allows,
+// switches renditions when the bandwidth changes, and records the frames it
+// plays.
+package viewer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf"
+)
+
+// Config contains configuration options for a Viewer.
+type Config struct {
+	// Query constrains the renditions to choose from, except for the
+	// bitrate, which is limited by the bandwidth.
+	// If zero, any video rendition is chosen.
+	Query msf.TrackQuery
+
+	// Bandwidth is the initial bandwidth estimate, in bits per second.
+	// If zero, the bandwidth is probed with moqt.Session.ProbeBandwidth.
+	Bandwidth int64
+
+	// ProbeDuration is how long the bandwidth is probed.
+	// If zero, defaults to 1s.
+	ProbeDuration time.Duration
+
+	// Record, if set, receives the payload of every played frame followed
+	// by a newline.
+	Record io.Writer
+}
+
+// Viewer plays a broadcast from a session.
+type Viewer struct {
+	sess   *moqt.Session
+	path   moqt.BroadcastPath
+	config Config
+
+	mu        sync.Mutex
+	catalog   msf.Catalog
+	bandwidth int64
+	rendition string
+	// next is the rendition to switch to, and skip ends the playback of
+	// the current one.
+	next   string
+	skip   context.CancelFunc
+	frames uint64
+}
+
+// New returns a Viewer of the broadcast at path of sess. config may be nil.
+func New(sess *moqt.Session, path moqt.BroadcastPath, config *Config) *Viewer {
+	v := &Viewer{sess: sess, path: path}
+	if config != nil {
+		v.config = *config
+	}
+	if v.config.Query == (msf.TrackQuery{}) {
+		v.config.Query.Role = msf.RoleVideo
+	}
+	if v.config.ProbeDuration <= 0 {
+		v.config.ProbeDuration = time.Second
+	}
+	return v
+}
+
+// Run plays the broadcast until ctx is canceled, returning nil, or until a
+// subscription fails.
+func (v *Viewer) Run(ctx context.Context) error {
+	catalog, err := v.readCatalog(ctx)
+	if err != nil {
+		return err
+	}
+
+	bandwidth := v.config.Bandwidth
+	if bandwidth == 0 {
+		// Start with the lowest rendition if the peer cannot be probed.
+		bitrate, err := v.sess.ProbeBandwidth(ctx, v.config.ProbeDuration)
+		if err == nil {
+			bandwidth = int64(bitrate)
+		}
+	}
+
+	v.mu.Lock()
+	v.catalog = catalog
+	v.bandwidth = bandwidth
+	v.next = v.selectLocked()
+	v.mu.Unlock()
+	if v.next == "" {
+		return fmt.Errorf("viewer: no rendition of %s matches the query", v.path)
+	}
+
+	for {
+		err := v.play(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SetBandwidth updates the bandwidth estimate, switching renditions if a
+// different one fits better.
+func (v *Viewer) SetBandwidth(bitrate int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.bandwidth = bitrate
+	next := v.selectLocked()
+	if next == "" || next == v.next {
+		return
+	}
+	v.next = next
+	if v.skip != nil {
+		v.skip()
+	}
+}
+
+// Rendition returns the name of the rendition being played.
+func (v *Viewer) Rendition() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.rendition
+}
+
+// Frames returns the number of frames played.
+func (v *Viewer) Frames() uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.frames
+}
+
+// readCatalog reads the first catalog of the broadcast.
+func (v *Viewer) readCatalog(ctx context.Context) (msf.Catalog, error) {
+	w, err := msf.WatchCatalog(ctx, v.sess, v.path)
+	if err != nil {
+		return msf.Catalog{}, err
+	}
+	defer w.Close()
+
+	update, err := w.Next(ctx)
+	if err != nil {
+		return msf.Catalog{}, err
+	}
+	return update.Catalog, nil
+}
+
+// selectLocked returns the best rendition within the bandwidth, or the one
+// with the lowest bitrate if none fits.
+func (v *Viewer) selectLocked() string {
+	q := v.config.Query
+	q.MaxBitrate = v.bandwidth
+	if track, ok := v.catalog.SelectTrack(q); ok {
+		return track.Name
+	}
+
+	// Raise the limit to each bitrate of the catalog in turn.
+	var bitrates []int64
+	for _, track := range v.catalog.Tracks {
+		if track.Bitrate != nil {
+			bitrates = append(bitrates, *track.Bitrate)
+		}
+	}
+	slices.Sort(bitrates)
+	for _, bitrate := range bitrates {
+		q.MaxBitrate = bitrate
+		if track, ok := v.catalog.SelectTrack(q); ok {
+			return track.Name
+		}
+	}
+	return ""
+}
+
+// play subscribes to the next rendition and plays it until the viewer
+// switches to another one, returning nil, or until the track ends.
+func (v *Viewer) play(ctx context.Context) error {
+	v.mu.Lock()
+	name := v.next
+	playCtx, skip := context.WithCancel(ctx)
+	v.skip = skip
+	v.mu.Unlock()
+	defer skip()
+
+	tr, err := v.sess.Subscribe(ctx, v.path, moqt.TrackName(name), nil)
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+
+	v.mu.Lock()
+	v.rendition = name
+	v.mu.Unlock()
+
+	frame := moqt.NewFrame(0)
+	for {
+		gr, err := tr.AcceptGroup(playCtx)
+		if err != nil {
+			if playCtx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for {
+			err := gr.ReadFrame(frame)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				gr.CancelRead(moqt.InternalGroupErrorCode)
+				break
+			}
+			if err := v.record(frame.Body()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// record counts a played frame and writes it to Config.Record.
+func (v *Viewer) record(payload []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.frames++
+	if v.config.Record == nil {
+		return nil
+	}
+	if _, err := v.config.Record.Write(payload); err != nil {
+		return err
+	}
+	_, err := v.config.Record.Write([]byte{'\n'})
+	return err
+}
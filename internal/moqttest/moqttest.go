@@ -0,0 +1,123 @@
+// Package moqttest runs moqt servers and sessions on the loopback interface
+// for tests.
+package moqttest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Serve starts server on a new UDP socket of 127.0.0.1 and returns its
+// address. The socket is bound before Serve returns, so the server can be
+// dialed right away. If server has no TLSConfig, it gets a self-signed one
+// from testutil.TLSConfig. The server is closed when the test ends.
+func Serve(tb testing.TB, server *moqt.Server) string {
+	tb.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+
+	if server.TLSConfig == nil {
+		server.TLSConfig = testutil.TLSConfig(tb)
+	}
+	server.Addr = addr
+	server.ListenFunc = func(_ string, tlsConfig *tls.Config, quicConfig *quic.Config) (moqt.QUICListener, error) {
+		tr := &quic.Transport{Conn: conn}
+		ln, err := tr.ListenEarly(tlsConfig, quicConfig)
+		if err != nil {
+			return nil, err
+		}
+		tb.Cleanup(func() {
+			_ = tr.Close()
+		})
+		return &listener{ln}, nil
+	}
+	go func() {
+		_ = server.ListenAndServe()
+	}()
+	tb.Cleanup(func() {
+		_ = server.Close()
+		_ = conn.Close()
+	})
+	return addr
+}
+
+// ServeMux serves mux over native QUIC with a server that keeps sessions
+// open until they end, and returns its address.
+func ServeMux(tb testing.TB, mux *moqt.TrackMux) string {
+	tb.Helper()
+
+	return Serve(tb, &moqt.Server{
+		TrackMux:            mux,
+		DisableWebTransport: true,
+		Handler: moqt.HandleFunc(func(sess *moqt.Session) {
+			<-sess.Context().Done()
+		}),
+	})
+}
+
+// Dial connects to the server at addr, serving mux, with a Dialer that
+// skips certificate verification. The session is closed when the test ends.
+func Dial(tb testing.TB, addr string, mux *moqt.TrackMux) *moqt.Session {
+	tb.Helper()
+
+	return DialWith(tb, &moqt.Dialer{}, addr, mux)
+}
+
+// DialWith is like Dial, with dialer. If dialer has no TLSConfig, it skips
+// certificate verification.
+func DialWith(tb testing.TB, dialer *moqt.Dialer, addr string, mux *moqt.TrackMux) *moqt.Session {
+	tb.Helper()
+
+	if dialer.TLSConfig == nil {
+		dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	ctx, cancel := context.WithTimeout(tb.Context(), 5*time.Second)
+	defer cancel()
+	sess, err := dialer.Dial(ctx, "moqt://"+addr, mux)
+	if err != nil {
+		tb.Fatalf("moqttest: cannot dial %s: %v", addr, err)
+	}
+	tb.Cleanup(func() {
+		_ = sess.CloseWithError(moqt.NoError, "")
+	})
+	return sess
+}
+
+// DialMux serves mux with ServeMux and returns a session dialed to it.
+func DialMux(tb testing.TB, mux *moqt.TrackMux) *moqt.Session {
+	tb.Helper()
+
+	return Dial(tb, ServeMux(tb, mux), nil)
+}
+
+// listener is a QUIC listener of quic-go accepting moqt connections.
+type listener struct {
+	ln *quic.EarlyListener
+}
+
+func (l *listener) Accept(ctx context.Context) (moqt.StreamConn, error) {
+	conn, err := l.ln.Accept(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return moqt.WrapQUICConn(conn), nil
+}
+
+func (l *listener) Close() error {
+	return l.ln.Close()
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
@@ -0,0 +1,69 @@
+// Package testutil provides fixtures shared by the tests of the module.
+//
+// It depends on the standard library only, so that the internal packages
+// imported by moqt can use it in their tests. Fixtures serving moqt are in
+// package moqttest.
+package testutil
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// TLSConfig returns a server configuration with a self-signed certificate
+// for 127.0.0.1, negotiating nextProtos. Clients skip its verification.
+func TLSConfig(tb testing.TB, nextProtos ...string) *tls.Config {
+	tb.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gomoqt-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		NextProtos:   nextProtos,
+	}
+}
+
+// Box returns an ISO BMFF box of the given type holding the concatenated
+// parts.
+func Box(typ string, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	b = append(b, typ...)
+	return append(b, body...)
+}
+
+// FullBox returns a full box body prefix with version 0 and flags, followed
+// by the given uint32 fields.
+func FullBox(flags uint32, fields ...uint32) []byte {
+	b := binary.BigEndian.AppendUint32(nil, flags&0xffffff)
+	for _, f := range fields {
+		b = binary.BigEndian.AppendUint32(b, f)
+	}
+	return b
+}
@@ -187,6 +187,12 @@ func (t Test) All() error {
 	return sh.RunV("go", "test", "./...")
 }
 
+// Invariants runs all tests with the internal invariant checks enabled
+func (t Test) Invariants() error {
+	fmt.Println("Running tests with invariant checks...")
+	return sh.RunV("go", "test", "-tags", "moqtdebug", "./...")
+}
+
 // Coverage runs tests with coverage reporting
 func (t Test) Coverage() error {
 	fmt.Println("Running tests with coverage...")
@@ -313,8 +319,9 @@ func Help() {
 	fmt.Println("  mage setup:deno  - Setup Deno environment")
 	fmt.Println("")
 	fmt.Println("Testing:")
-	fmt.Println("  mage test:all      - Run all tests")
-	fmt.Println("  mage test:coverage - Run tests with coverage")
+	fmt.Println("  mage test:all        - Run all tests")
+	fmt.Println("  mage test:invariants - Run tests with invariant checks")
+	fmt.Println("  mage test:coverage   - Run tests with coverage")
 	fmt.Println("")
 	fmt.Println("Interop:")
 	fmt.Println("  mage interop:ts                       - Dockerized interop using TypeScript client (preferred)")
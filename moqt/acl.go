@@ -0,0 +1,96 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ACL decides which broadcasts a peer may announce and which tracks it may
+// subscribe to. A nil error allows the request. An *ACLError denies it with
+// its error code, ErrUnauthorized or an error wrapping it denies it as
+// unauthorized, and any other error denies it with an internal error.
+//
+// CheckAnnounce is called for each broadcast announced to a session that
+// accepts announcements with AcceptAnnounce, before the announcement is
+// delivered. A denied announcement closes the announce stream with the error
+// code, BannedPrefixErrorCode if unauthorized, so that the publisher learns
+// it is not allowed to publish. CheckSubscribe is called on receipt of a
+// SUBSCRIBE, after the Authorizer, if any; a denied subscription is rejected
+// with the error code, SubscribeErrorCodeUnauthorized if unauthorized.
+//
+// Both are called with the session context, from which the principal of the
+// session can be taken with PrincipalFromContext. Implementations must be
+// safe for concurrent use.
+type ACL interface {
+	CheckAnnounce(ctx context.Context, r *AnnounceAuthRequest) error
+	CheckSubscribe(ctx context.Context, r *SubscribeAuthRequest) error
+}
+
+// AnnounceAuthRequest describes a broadcast announced by a peer to authorize.
+type AnnounceAuthRequest struct {
+	BroadcastPath BroadcastPath
+
+	// SessionToken is the token the session was authorized with, or "".
+	SessionToken string
+
+	RemoteAddr net.Addr
+}
+
+// ACLError is an error of an ACL that denies a request with a specific error
+// code: an AnnounceErrorCode for announcements and a SubscribeErrorCode for
+// subscriptions. It wraps ErrUnauthorized.
+type ACLError struct {
+	Code   uint32
+	Reason string
+}
+
+func (err *ACLError) Error() string {
+	if err.Reason == "" {
+		return fmt.Sprintf("moqt: denied by ACL (code %d)", err.Code)
+	}
+	return "moqt: denied by ACL: " + err.Reason
+}
+
+func (err *ACLError) Unwrap() error {
+	return ErrUnauthorized
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries principal, the identity of
+// a session that ACLs grant permissions to, such as the subject of its token.
+// Authorizers call it from AuthorizeSession, or Server.SessionContext for
+// other ways of identifying sessions.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, such as a
+// session context, or "" if there is none.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// aclAnnounceErrorCode returns the announce error code that denies an
+// announcement for err.
+func aclAnnounceErrorCode(err error) AnnounceErrorCode {
+	if aclErr, ok := errors.AsType[*ACLError](err); ok {
+		return AnnounceErrorCode(aclErr.Code)
+	}
+	if errors.Is(err, ErrUnauthorized) {
+		return BannedPrefixErrorCode
+	}
+	return AnnounceErrorCodeInternal
+}
+
+// aclSubscribeErrorCode returns the subscribe error code that denies a
+// subscription for err.
+func aclSubscribeErrorCode(err error) SubscribeErrorCode {
+	if aclErr, ok := errors.AsType[*ACLError](err); ok {
+		return SubscribeErrorCode(aclErr.Code)
+	}
+	return authSubscribeErrorCode(err)
+}
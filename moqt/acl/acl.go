@@ -0,0 +1,318 @@
+package acl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Actions of a Rule.
+const (
+	Announce  = "announce"
+	Subscribe = "subscribe"
+)
+
+// List is a moqt.ACL made of rules. The first rule matching a request
+// decides it; requests matching no rule are denied as unauthorized.
+//
+// A List must not be modified after first use.
+type List struct {
+	Rules []Rule `json:"rules"`
+
+	// Principal returns the principal of a session given its context.
+	// If nil, moqt.PrincipalFromContext is used.
+	Principal func(ctx context.Context) string `json:"-"`
+}
+
+var _ moqt.ACL = (*List)(nil)
+
+// Rule allows or denies actions on the broadcasts of some paths to some
+// principals. Empty lists match everything.
+//
+// Principals are path.Match patterns in which "*" matches any sequence of
+// characters, including "/", since principals such as SPIFFE IDs or
+// "org/user" are not paths. Tracks are path.Match patterns. Paths are
+// path.Match patterns matched segment by segment, so "*" matches a single
+// segment; a final "**" segment matches one or more segments, so that
+// "/live/**" matches every broadcast under "/live/".
+type Rule struct {
+	// Principals are patterns of the principals the rule applies to. The
+	// principal of unidentified sessions is "", which only "*" matches.
+	Principals []string `json:"principals,omitempty"`
+
+	// Actions are the actions the rule applies to: Announce, Subscribe or
+	// both.
+	Actions []string `json:"actions,omitempty"`
+
+	// Paths are patterns of the broadcast paths the rule applies to.
+	Paths []string `json:"paths,omitempty"`
+
+	// Tracks are patterns of the track names the rule applies to. Since
+	// announcements have no track name, a rule with Tracks never matches
+	// them.
+	Tracks []string `json:"tracks,omitempty"`
+
+	// Deny makes the rule deny the requests it matches instead of allowing
+	// them.
+	Deny bool `json:"deny,omitempty"`
+
+	// Code is the error code of the requests denied by the rule: an
+	// AnnounceErrorCode for announcements and a SubscribeErrorCode for
+	// subscriptions. If zero, they are denied as unauthorized.
+	Code uint32 `json:"code,omitempty"`
+}
+
+// Load reads a List from the JSON file name.
+func Load(name string) (*List, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	list, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return list, nil
+}
+
+// Parse parses a List from JSON. It returns an error for unknown fields,
+// actions and malformed patterns.
+func Parse(data []byte) (*List, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var list List
+	if err := dec.Decode(&list); err != nil {
+		return nil, fmt.Errorf("acl: %w", err)
+	}
+	for i, rule := range list.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("acl: rule %d: %w", i, err)
+		}
+	}
+	return &list, nil
+}
+
+// CheckAnnounce implements moqt.ACL.
+func (l *List) CheckAnnounce(ctx context.Context, r *moqt.AnnounceAuthRequest) error {
+	return l.check(ctx, Announce, r.BroadcastPath, "", false)
+}
+
+// CheckSubscribe implements moqt.ACL.
+func (l *List) CheckSubscribe(ctx context.Context, r *moqt.SubscribeAuthRequest) error {
+	return l.check(ctx, Subscribe, r.BroadcastPath, r.TrackName, true)
+}
+
+func (l *List) check(ctx context.Context, action string, broadcast moqt.BroadcastPath, track moqt.TrackName, hasTrack bool) error {
+	principal := l.principal(ctx)
+	for i, rule := range l.Rules {
+		if !rule.matches(principal, action, string(broadcast), string(track), hasTrack) {
+			continue
+		}
+		if !rule.Deny {
+			return nil
+		}
+		reason := fmt.Sprintf("%s of %s denied to %q by rule %d", action, broadcast, principal, i)
+		if rule.Code != 0 {
+			return &moqt.ACLError{Code: rule.Code, Reason: reason}
+		}
+		return fmt.Errorf("%w: %s", moqt.ErrUnauthorized, reason)
+	}
+	return fmt.Errorf("%w: %s of %s not granted to %q", moqt.ErrUnauthorized, action, broadcast, principal)
+}
+
+func (l *List) principal(ctx context.Context) string {
+	if l.Principal != nil {
+		return l.Principal(ctx)
+	}
+	return moqt.PrincipalFromContext(ctx)
+}
+
+func (rule *Rule) matches(principal, action, broadcast, track string, hasTrack bool) bool {
+	if len(rule.Actions) > 0 && !slices.Contains(rule.Actions, action) {
+		return false
+	}
+	if len(rule.Principals) > 0 && !slices.ContainsFunc(rule.Principals, func(p string) bool {
+		return matchPrincipal(p, principal)
+	}) {
+		return false
+	}
+	if len(rule.Paths) > 0 && !slices.ContainsFunc(rule.Paths, func(p string) bool {
+		return matchPath(p, broadcast)
+	}) {
+		return false
+	}
+	if len(rule.Tracks) > 0 {
+		if !hasTrack {
+			return false
+		}
+		if !slices.ContainsFunc(rule.Tracks, func(p string) bool {
+			ok, _ := path.Match(p, track)
+			return ok
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule *Rule) validate() error {
+	for _, action := range rule.Actions {
+		if action != Announce && action != Subscribe {
+			return fmt.Errorf("unknown action %q", action)
+		}
+	}
+	for _, p := range rule.Principals {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("principal pattern %q: %w", p, err)
+		}
+	}
+	for _, p := range rule.Paths {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("path pattern %q does not start with /", p)
+		}
+		segments := strings.Split(p, "/")
+		for i, seg := range segments {
+			if seg == "**" && i != len(segments)-1 {
+				return fmt.Errorf("path pattern %q: ** is only allowed as the last segment", p)
+			}
+			if _, err := path.Match(seg, ""); err != nil {
+				return fmt.Errorf("path pattern %q: %w", p, err)
+			}
+		}
+	}
+	for _, p := range rule.Tracks {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("track pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// matchPath matches the broadcast path p against pattern, segment by
+// segment. A final "**" segment matches one or more segments.
+func matchPath(pattern, p string) bool {
+	patterns := strings.Split(pattern, "/")
+	segments := strings.Split(p, "/")
+	for i, seg := range patterns {
+		if seg == "**" && i == len(patterns)-1 {
+			return len(segments) > i
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if ok, _ := path.Match(seg, segments[i]); !ok {
+			return false
+		}
+	}
+	return len(segments) == len(patterns)
+}
+
+// principalToken is a part of a principal pattern: a star, or a path.Match
+// pattern of a single character.
+type principalToken struct {
+	star    bool
+	pattern string
+}
+
+// matchPrincipal matches principal against pattern like path.Match, except
+// that "*" and "?" also match "/".
+func matchPrincipal(pattern, principal string) bool {
+	tokens, ok := principalTokens(pattern)
+	if !ok {
+		return false
+	}
+	name := []rune(principal)
+
+	// t and n are the positions in tokens and name. After a star, the
+	// match is retried from star+1 with the star taking one more rune.
+	t, n := 0, 0
+	star, mark := -1, 0
+	for n < len(name) {
+		switch {
+		case t < len(tokens) && tokens[t].star:
+			star, mark = t, n
+			t++
+		case t < len(tokens) && tokens[t].matches(name[n]):
+			t++
+			n++
+		case star >= 0:
+			mark++
+			t, n = star+1, mark
+		default:
+			return false
+		}
+	}
+	for t < len(tokens) && tokens[t].star {
+		t++
+	}
+	return t == len(tokens)
+}
+
+// matches reports whether the single character token matches r.
+func (tok principalToken) matches(r rune) bool {
+	if tok.pattern == "?" {
+		return true
+	}
+	ok, _ := path.Match(tok.pattern, string(r))
+	return ok
+}
+
+// principalTokens splits pattern into tokens, or reports false if it is
+// malformed.
+func principalTokens(pattern string) ([]principalToken, bool) {
+	var tokens []principalToken
+	for i := 0; i < len(pattern); {
+		switch pattern[i] {
+		case '*':
+			tokens = append(tokens, principalToken{star: true})
+			i++
+			continue
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, false
+			}
+			_, size := utf8.DecodeRuneInString(pattern[i+1:])
+			tokens = append(tokens, principalToken{pattern: pattern[i : i+1+size]})
+			i += 1 + size
+			continue
+		case '[':
+			end := classEnd(pattern, i)
+			if end < 0 {
+				return nil, false
+			}
+			tokens = append(tokens, principalToken{pattern: pattern[i:end]})
+			i = end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(pattern[i:])
+		tokens = append(tokens, principalToken{pattern: pattern[i : i+size]})
+		i += size
+	}
+	return tokens, true
+}
+
+// classEnd returns the index after the character class starting at
+// pattern[start], or -1 if it is not closed.
+func classEnd(pattern string, start int) int {
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		i++
+	}
+	for first := i; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == '\\':
+			i++
+		case pattern[i] == ']' && i > first:
+			return i + 1
+		}
+	}
+	return -1
+}
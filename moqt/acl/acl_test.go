@@ -0,0 +1,186 @@
+package acl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `{
+  "rules": [
+    {"principals": ["encoder-*"], "actions": ["announce"], "paths": ["/live/**"]},
+    {"principals": ["*"], "actions": ["subscribe"], "paths": ["/live/private/**"], "deny": true, "code": 4097},
+    {"principals": ["guest"], "actions": ["subscribe"], "paths": ["/live/*"], "tracks": ["audio"]},
+    {"principals": ["?*"], "actions": ["subscribe"], "paths": ["/live/**"]}
+  ]
+}`
+
+func TestList(t *testing.T) {
+	list, err := Parse([]byte(testRules))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		principal string
+		action    string
+		path      moqt.BroadcastPath
+		track     moqt.TrackName
+		wantErr   bool
+		wantCode  uint32
+	}{
+		"encoder announces": {
+			principal: "encoder-1", action: Announce, path: "/live/room1/cam",
+		},
+		"viewer cannot announce": {
+			principal: "alice", action: Announce, path: "/live/room1",
+			wantErr: true,
+		},
+		"encoder announces outside its paths": {
+			principal: "encoder-1", action: Announce, path: "/vod/room1",
+			wantErr: true,
+		},
+		"viewer subscribes": {
+			principal: "alice", action: Subscribe, path: "/live/room1/cam", track: "video",
+		},
+		"private path denied with code": {
+			principal: "alice", action: Subscribe, path: "/live/private/room", track: "video",
+			wantErr: true, wantCode: 4097,
+		},
+		"guest subscribes to audio": {
+			principal: "guest", action: Subscribe, path: "/live/room1", track: "audio",
+		},
+		"SPIFFE ID subscribes": {
+			principal: "spiffe://example.org/ns/live/viewer", action: Subscribe, path: "/live/room1/cam", track: "video",
+		},
+		"principal with slash denied by star": {
+			principal: "org/user", action: Subscribe, path: "/live/private/room", track: "video",
+			wantErr: true, wantCode: 4097,
+		},
+		"anonymous session denied": {
+			principal: "", action: Subscribe, path: "/live/room1", track: "video",
+			wantErr: true,
+		},
+		"single segment pattern": {
+			principal: "encoder-1", action: Announce, path: "/live",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := moqt.WithPrincipal(t.Context(), tt.principal)
+			var err error
+			if tt.action == Announce {
+				err = list.CheckAnnounce(ctx, &moqt.AnnounceAuthRequest{BroadcastPath: tt.path})
+			} else {
+				err = list.CheckSubscribe(ctx, &moqt.SubscribeAuthRequest{BroadcastPath: tt.path, TrackName: tt.track})
+			}
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, moqt.ErrUnauthorized)
+			if tt.wantCode != 0 {
+				aclErr, ok := err.(*moqt.ACLError)
+				require.True(t, ok)
+				assert.Equal(t, tt.wantCode, aclErr.Code)
+			}
+		})
+	}
+}
+
+func TestList_Principal(t *testing.T) {
+	list := &List{
+		Rules:     []Rule{{Principals: []string{"admin"}}},
+		Principal: func(ctx context.Context) string { return "admin" },
+	}
+	assert.NoError(t, list.CheckAnnounce(t.Context(), &moqt.AnnounceAuthRequest{BroadcastPath: "/any"}))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"malformed JSON":    `{"rules": [`,
+		"unknown field":     `{"rules": [{"path": "/live/**"}]}`,
+		"unknown action":    `{"rules": [{"actions": ["publish"]}]}`,
+		"relative path":     `{"rules": [{"paths": ["live/**"]}]}`,
+		"inner double star": `{"rules": [{"paths": ["/**/cam"]}]}`,
+		"bad path pattern":  `{"rules": [{"paths": ["/live/["]}]}`,
+		"bad principal":     `{"rules": [{"principals": ["["]}]}`,
+		"bad track pattern": `{"rules": [{"tracks": ["["]}]}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "acl.json")
+	require.NoError(t, os.WriteFile(name, []byte(testRules), 0o600))
+
+	list, err := Load(name)
+	require.NoError(t, err)
+	assert.Len(t, list.Rules, 4)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestMatchPrincipal(t *testing.T) {
+	tests := map[string]struct {
+		pattern   string
+		principal string
+		want      bool
+	}{
+		"exact":                 {"alice", "alice", true},
+		"star":                  {"*", "org/user", true},
+		"star matches empty":    {"*", "", true},
+		"prefix":                {"encoder-*", "encoder-1", true},
+		"SPIFFE trust domain":   {"spiffe://example.org/*", "spiffe://example.org/ns/live/sa/encoder", true},
+		"other trust domain":    {"spiffe://example.org/*", "spiffe://evil.org/ns/live", false},
+		"inner star":            {"org/*/admin", "org/team/sub/admin", true},
+		"inner star mismatch":   {"org/*/admin", "org/team/user", false},
+		"question mark slash":   {"org?user", "org/user", true},
+		"question mark one":     {"org?user", "org//user", false},
+		"class":                 {"org/[a-c]*", "org/bob", true},
+		"class mismatch":        {"org/[a-c]*", "org/dave", false},
+		"escaped star":          {`org/\*`, "org/*", true},
+		"escaped star literal":  {`org/\*`, "org/user", false},
+		"star backtracks":       {"*/user", "org/team/user", true},
+		"trailing characters":   {"org/*", "team/org/user", false},
+		"malformed":             {"org/[", "org/[", false},
+		"unicode":               {"ユーザー/*", "ユーザー/太郎", true},
+		"unicode question mark": {"?/x", "太/x", true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchPrincipal(tt.pattern, tt.principal))
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := map[string]struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		"exact":                 {"/live/cam", "/live/cam", true},
+		"star segment":          {"/live/*", "/live/cam", true},
+		"star one segment":      {"/live/*", "/live/room/cam", false},
+		"double star":           {"/live/**", "/live/room/cam", true},
+		"double star needs one": {"/live/**", "/live", false},
+		"different":             {"/live/*", "/vod/cam", false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchPath(tt.pattern, tt.path))
+		})
+	}
+}
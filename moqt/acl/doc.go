@@ -0,0 +1,25 @@
+// Package acl provides a moqt.ACL that grants principals the broadcasts they
+// may announce and the tracks they may subscribe to with glob patterns,
+// loaded from a JSON file:
+//
+//	{
+//	  "rules": [
+//	    {"principals": ["encoder-*"], "actions": ["announce"], "paths": ["/live/**"]},
+//	    {"principals": ["*"], "actions": ["subscribe"], "paths": ["/live/private/**"], "deny": true},
+//	    {"principals": ["*"], "actions": ["subscribe"], "paths": ["/live/**"]}
+//	  ]
+//	}
+//
+// The first rule matching a request decides it, and requests matching no
+// rule are denied:
+//
+//	list, err := acl.Load("acl.json")
+//	if err != nil {
+//		return err
+//	}
+//	server := &moqt.Server{Addr: ":4433", TLSConfig: tlsConfig, Authorizer: auth, ACL: list}
+//
+// The principal of a session is taken from its context with
+// moqt.PrincipalFromContext, as set by the Authorizer of the server, such as
+// the subject of a token validated by jwtauth.
+package acl
@@ -0,0 +1,216 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeACL records the requests it receives and answers them with err.
+type fakeACL struct {
+	err error
+
+	mu         sync.Mutex
+	announces  []AnnounceAuthRequest
+	subscribes []SubscribeAuthRequest
+}
+
+func (a *fakeACL) CheckAnnounce(ctx context.Context, r *AnnounceAuthRequest) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.announces = append(a.announces, *r)
+	return a.err
+}
+
+func (a *fakeACL) CheckSubscribe(ctx context.Context, r *SubscribeAuthRequest) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.subscribes = append(a.subscribes, *r)
+	return a.err
+}
+
+func TestACLErrorCodes(t *testing.T) {
+	tests := map[string]struct {
+		err           error
+		wantAnnounce  AnnounceErrorCode
+		wantSubscribe SubscribeErrorCode
+	}{
+		"ACL error": {
+			err:           &ACLError{Code: 0x1001},
+			wantAnnounce:  AnnounceErrorCode(0x1001),
+			wantSubscribe: SubscribeErrorCode(0x1001),
+		},
+		"unauthorized": {
+			err:           ErrUnauthorized,
+			wantAnnounce:  BannedPrefixErrorCode,
+			wantSubscribe: SubscribeErrorCodeUnauthorized,
+		},
+		"other error": {
+			err:           errors.New("backend unavailable"),
+			wantAnnounce:  AnnounceErrorCodeInternal,
+			wantSubscribe: SubscribeErrorCodeInternal,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.wantAnnounce, aclAnnounceErrorCode(tt.err))
+			assert.Equal(t, tt.wantSubscribe, aclSubscribeErrorCode(tt.err))
+		})
+	}
+}
+
+func TestACLError(t *testing.T) {
+	err := &ACLError{Code: 0x1001, Reason: "quota exceeded"}
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, "moqt: denied by ACL: quota exceeded", err.Error())
+	assert.Equal(t, "moqt: denied by ACL (code 4097)", (&ACLError{Code: 0x1001}).Error())
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	assert.Empty(t, PrincipalFromContext(t.Context()))
+	assert.Equal(t, "alice", PrincipalFromContext(WithPrincipal(t.Context(), "alice")))
+}
+
+func TestSession_CheckSubscribe(t *testing.T) {
+	tests := map[string]struct {
+		err        error
+		wantServed bool
+		wantCode   transport.StreamErrorCode
+	}{
+		"allowed": {
+			wantServed: true,
+		},
+		"denied": {
+			err:      ErrUnauthorized,
+			wantCode: transport.StreamErrorCode(SubscribeErrorCodeUnauthorized),
+		},
+		"denied with code": {
+			err:      &ACLError{Code: uint32(SubscribeErrorCodeNotFound)},
+			wantCode: transport.StreamErrorCode(SubscribeErrorCodeNotFound),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			acl := &fakeACL{err: tt.err}
+			session, _ := newTestSessionWithConn(t)
+			session.acl = acl
+
+			served := false
+			session.mux.PublishFunc(t.Context(), "/test/path", func(tw *TrackWriter) {
+				served = true
+			})
+
+			var buf bytes.Buffer
+			require.NoError(t, message.StreamTypeSubscribe.Encode(&buf))
+			require.NoError(t, message.SubscribeMessage{
+				SubscribeID:   1,
+				BroadcastPath: "/test/path",
+				TrackName:     "video",
+			}.Encode(&buf))
+
+			var canceled []transport.StreamErrorCode
+			stream := &FakeQUICStream{
+				ReadFunc: func(p []byte) (int, error) {
+					if buf.Len() == 0 {
+						return 0, io.EOF
+					}
+					return buf.Read(p)
+				},
+				CancelWriteFunc: func(code transport.StreamErrorCode) {
+					canceled = append(canceled, code)
+				},
+			}
+
+			session.processBiStream(stream, func() {})
+
+			require.Len(t, acl.subscribes, 1)
+			assert.Equal(t, "/test/path", string(acl.subscribes[0].BroadcastPath))
+			assert.Equal(t, "video", string(acl.subscribes[0].TrackName))
+			assert.Equal(t, tt.wantServed, served)
+			if !tt.wantServed {
+				assert.Equal(t, []transport.StreamErrorCode{tt.wantCode}, canceled)
+			}
+		})
+	}
+}
+
+func TestAnnouncementReader_Check(t *testing.T) {
+	tests := map[string]struct {
+		denied   BroadcastPath
+		wantCode transport.StreamErrorCode
+		wantAnns []BroadcastPath
+	}{
+		"all allowed": {
+			wantAnns: []BroadcastPath{"/test/a", "/test/b"},
+		},
+		"one denied": {
+			denied:   "/test/b",
+			wantCode: transport.StreamErrorCode(BannedPrefixErrorCode),
+			wantAnns: []BroadcastPath{"/test/a"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			for _, suffix := range []string{"a", "b"} {
+				require.NoError(t, message.AnnounceMessage{BroadcastPathSuffix: suffix, AnnounceStatus: message.ACTIVE}.Encode(&buf))
+			}
+
+			var mu sync.Mutex
+			var canceled []transport.StreamErrorCode
+			stream := &FakeQUICStream{
+				ReadFunc: func(p []byte) (int, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					if buf.Len() == 0 {
+						return 0, io.EOF
+					}
+					return buf.Read(p)
+				},
+				CancelReadFunc: func(code transport.StreamErrorCode) {
+					mu.Lock()
+					defer mu.Unlock()
+					canceled = append(canceled, code)
+				},
+			}
+
+			check := func(path BroadcastPath) error {
+				if path == tt.denied {
+					return ErrUnauthorized
+				}
+				return nil
+			}
+			ar := newCheckedAnnouncementReader(stream, "/test/", nil, nil, message.VersionLite04Ext, check, nil)
+
+			var got []BroadcastPath
+			for range tt.wantAnns {
+				ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+				ann, err := ar.ReceiveAnnouncement(ctx)
+				cancel()
+				require.NoError(t, err)
+				got = append(got, ann.BroadcastPath())
+			}
+			assert.Equal(t, tt.wantAnns, got)
+
+			if tt.wantCode != 0 {
+				assert.Eventually(t, func() bool {
+					mu.Lock()
+					defer mu.Unlock()
+					return len(canceled) == 1 && canceled[0] == tt.wantCode
+				}, time.Second, time.Millisecond)
+			}
+		})
+	}
+}
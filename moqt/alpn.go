@@ -2,7 +2,8 @@ package moqt
 
 // NextProtoMOQ is the default ALPN token for MOQ over QUIC.
 //
-// moq-lite-04 negotiates via ALPN token "moq-lite-04" for native QUIC.
+// moq-lite-04 negotiates via ALPN token "moq-lite-04" for native QUIC. Other
+// versions are negotiated with their own tokens; see Config.SupportedVersions.
 const NextProtoMOQ = "moq-lite-04"
 
 // NextProtoH3 is the ALPN token used to indicate HTTP/3 (used for WebTransport).
@@ -23,7 +23,7 @@ func TestNewAnnouncementReader(t *testing.T) {
 	mockStream := &FakeQUICStream{}
 	prefix := "/test/prefix/"
 
-	ras := newAnnouncementReader(mockStream, prefix, []string{"suffix1", "suffix2"})
+	ras := newAnnouncementReader(mockStream, prefix, []string{"suffix1", "suffix2"}, nil, message.VersionLite04Ext)
 
 	require.NotNil(t, ras)
 	assert.Equal(t, prefix, ras.prefix)
@@ -58,7 +58,7 @@ func TestAnnouncementReader_ReceiveAnnouncement(t *testing.T) {
 				data := append([]byte(nil), buf.Bytes()...)
 				reader := bytes.NewReader(data)
 				mockStream.ReadFunc = reader.Read
-				ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"})
+				ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"}, nil, message.VersionLite04Ext)
 				return ras
 			}(),
 			ctx:     context.Background(),
@@ -69,7 +69,7 @@ func TestAnnouncementReader_ReceiveAnnouncement(t *testing.T) {
 			receiveAnnounceStream: func() *AnnouncementReader {
 				mockStream := &FakeQUICStream{}
 				// Don't provide initial suffixes so that ReceiveAnnouncement will wait
-				return newAnnouncementReader(mockStream, "/test/", []string{})
+				return newAnnouncementReader(mockStream, "/test/", []string{}, nil, message.VersionLite04Ext)
 			}(),
 			ctx: func() context.Context { ctx, cancel := context.WithCancel(context.Background()); cancel(); return ctx }(), wantErr: true,
 			wantErrType: context.Canceled,
@@ -79,7 +79,7 @@ func TestAnnouncementReader_ReceiveAnnouncement(t *testing.T) {
 			receiveAnnounceStream: func() *AnnouncementReader {
 				mockStream := &FakeQUICStream{}
 				// Don't provide initial suffixes so that ReceiveAnnouncement will wait
-				ras := newAnnouncementReader(mockStream, "/test/", []string{})
+				ras := newAnnouncementReader(mockStream, "/test/", []string{}, nil, message.VersionLite04Ext)
 				// Allow goroutine to start (very short)
 				time.Sleep(1 * time.Millisecond)
 				_ = ras.Close()
@@ -147,14 +147,14 @@ func TestAnnouncementReader_Close(t *testing.T) {
 	}{"normal_close": {
 		setupFunc: func() *AnnouncementReader {
 			mockStream := &FakeQUICStream{}
-			return newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"})
+			return newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"}, nil, message.VersionLite04Ext)
 		},
 		wantErr: false,
 	},
 		"already_closed": {
 			setupFunc: func() *AnnouncementReader {
 				mockStream := &FakeQUICStream{}
-				ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"})
+				ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"}, nil, message.VersionLite04Ext)
 				_ = ras.Close() // Close once
 				return ras
 			},
@@ -186,7 +186,7 @@ func TestAnnouncementReader_CloseWithError(t *testing.T) {
 		ReadFunc: func(p []byte) (int, error) { return 0, io.EOF },
 	}
 
-	ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"}, nil, message.VersionLite04Ext)
 
 	// Allow goroutine to start and call Read (very short)
 	time.Sleep(1 * time.Millisecond)
@@ -204,7 +204,7 @@ func TestAnnouncementReader_CloseWithError_MultipleClose(t *testing.T) {
 		ReadFunc: func(p []byte) (int, error) { return 0, io.EOF },
 	}
 
-	ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"}, nil, message.VersionLite04Ext)
 
 	// Allow goroutine to start and call Read (very short)
 	time.Sleep(1 * time.Millisecond)
@@ -220,7 +220,7 @@ func TestAnnouncementReader_CloseWithError_MultipleClose(t *testing.T) {
 
 func TestAnnouncementReader_AnnouncementTracking(t *testing.T) {
 	mockStream := &FakeQUICStream{}
-	ras := newAnnouncementReader(mockStream, "/test/", []string{}) // No initial announcements
+	ras := newAnnouncementReader(mockStream, "/test/", []string{}, nil, message.VersionLite04Ext) // No initial announcements
 
 	// Wait for the goroutine to start and process EOF (deterministic)
 	{
@@ -272,7 +272,7 @@ func TestAnnouncementReader_ConcurrentAccess(t *testing.T) {
 		ReadFunc: reader.Read,
 	}
 
-	ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"}, nil, message.VersionLite04Ext)
 
 	// Wait for message processing to begin (deterministic)
 	{
@@ -355,7 +355,7 @@ func TestAnnouncementReader_PrefixHandling(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			mockStream := &FakeQUICStream{}
-			ras := newAnnouncementReader(mockStream, tt.prefix, []string{tt.suffix})
+			ras := newAnnouncementReader(mockStream, tt.prefix, []string{tt.suffix}, nil, message.VersionLite04Ext)
 
 			// Allow goroutine to start and call Read (very short)
 			time.Sleep(1 * time.Millisecond)
@@ -387,7 +387,7 @@ func TestAnnouncementReader_InvalidMessage(t *testing.T) {
 		ReadFunc: buf.Read,
 	}
 
-	ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"}, nil, message.VersionLite04Ext)
 
 	// Give time for processing invalid data (short)
 	time.Sleep(5 * time.Millisecond)
@@ -425,7 +425,7 @@ func TestAnnouncementReader_ActiveThenEnded(t *testing.T) {
 		},
 	}
 
-	ras := newAnnouncementReader(mockStream, "/test/", []string{})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{}, nil, message.VersionLite04Ext)
 
 	// Wait until messages are observed by the reader instead of sleeping.
 	{
@@ -483,7 +483,7 @@ func TestAnnouncementReader_MultipleActiveStreams(t *testing.T) {
 		},
 	}
 
-	ras := newAnnouncementReader(mockStream, "/test/", []string{})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{}, nil, message.VersionLite04Ext)
 
 	// Wait until messages are observed by the reader instead of sleeping.
 	{
@@ -552,7 +552,7 @@ func TestAnnouncementReader_DuplicateActiveError(t *testing.T) {
 		},
 	}
 
-	ras := newAnnouncementReader(mockStream, "/test/", []string{})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{}, nil, message.VersionLite04Ext)
 
 	// Wait for the reader's context to be cancelled due to error.
 	{
@@ -594,7 +594,7 @@ func TestAnnouncementReader_EndNonExistentStreamError(t *testing.T) {
 		},
 	}
 
-	ras := newAnnouncementReader(mockStream, "/test/", []string{})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{}, nil, message.VersionLite04Ext)
 
 	// Wait for the reader's context to be cancelled due to error.
 	{
@@ -634,7 +634,7 @@ func TestAnnouncementReader_NotifyChannel(t *testing.T) {
 	}
 
 	// Don't provide initial suffixes so we only get the stream message
-	ras := newAnnouncementReader(mockStream, "/test/", []string{})
+	ras := newAnnouncementReader(mockStream, "/test/", []string{}, nil, message.VersionLite04Ext)
 
 	// Wait until the reader has processed the message
 	{
@@ -715,7 +715,7 @@ func TestAnnouncementReader_BoundaryValues(t *testing.T) {
 			if tt.expectPanic {
 				assert.Panics(t, func() {
 					mockStream := &FakeQUICStream{}
-					newAnnouncementReader(mockStream, tt.prefix, []string{})
+					newAnnouncementReader(mockStream, tt.prefix, []string{}, nil, message.VersionLite04Ext)
 				})
 				return
 			}
@@ -738,7 +738,7 @@ func TestAnnouncementReader_BoundaryValues(t *testing.T) {
 			}
 
 			// Don't provide initial suffixes so we only get the stream message
-			ras := newAnnouncementReader(mockStream, tt.prefix, []string{})
+			ras := newAnnouncementReader(mockStream, tt.prefix, []string{}, nil, message.VersionLite04Ext)
 
 			// Wait until the reader has processed the message
 			{
@@ -802,7 +802,7 @@ func TestAnnouncementReader_StreamErrors(t *testing.T) {
 				},
 			}
 
-			ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"})
+			ras := newAnnouncementReader(mockStream, "/test/", []string{"valid_announcement"}, nil, message.VersionLite04Ext)
 
 			// In quic-go, Read errors are receive-side events and do NOT cancel
 			// the stream's Context (which is send-side). The reader goroutine
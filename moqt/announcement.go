@@ -3,6 +3,7 @@ package moqt
 import (
 	"context"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -39,6 +40,30 @@ func NewAnnouncement(ctx context.Context, path BroadcastPath) (*Announcement, En
 	return ann, endFunc
 }
 
+// NewAnnouncementWithID is like NewAnnouncement, but the announcement carries
+// the global identifier id of the broadcast to every hop. See BroadcastID.
+// The identifier does not reach peers of sessions without extensions, which
+// see a zero BroadcastID; see Session.Extensions.
+func NewAnnouncementWithID(ctx context.Context, path BroadcastPath, id BroadcastID) (*Announcement, EndAnnouncementFunc) {
+	ann, end := NewAnnouncement(ctx, path)
+	ann.id = id
+	return ann, end
+}
+
+// Alias returns an announcement of the broadcast of a under another path,
+// such as a path rewritten by a relay. It carries the BroadcastID, hop IDs
+// and track metadata of a, and ends when a ends or the returned
+// EndAnnouncementFunc is called.
+func (a *Announcement) Alias(path BroadcastPath) (*Announcement, EndAnnouncementFunc) {
+	alias, end := NewAnnouncementWithID(context.Background(), path, a.id)
+	alias.hopIDs = slices.Clone(a.hopIDs)
+	alias.copyTrackMetadata(a)
+
+	stop := a.AfterFunc(end)
+	alias.AfterFunc(func() { stop() })
+	return alias, end
+}
+
 // Announcement represents the lifecycle of a broadcast.
 //
 // The key behaviors are:
@@ -62,6 +87,10 @@ type Announcement struct {
 	once   sync.Once
 
 	hopIDs []uint64
+
+	id BroadcastID
+
+	tracks map[TrackName]TrackMetadata
 }
 
 // String returns a string representation of the announcement for debugging.
@@ -95,6 +124,18 @@ func (a *Announcement) HopIDs() []uint64 {
 	return a.hopIDs
 }
 
+// BroadcastID returns the global identifier of the broadcast, or the zero
+// BroadcastID if the origin did not assign one.
+func (a *Announcement) BroadcastID() BroadcastID {
+	return a.id
+}
+
+// TrackID returns the global identifier of the track name of the announced
+// broadcast. See NewTrackID.
+func (a *Announcement) TrackID(name TrackName) TrackID {
+	return NewTrackID(a.id, a.path, name)
+}
+
 // Done returns a channel that is closed once when the announcement ends.
 // Consumers may use this channel to wait until the announcement stops.
 func (a *Announcement) Done() <-chan struct{} {
@@ -112,7 +153,9 @@ func (a *Announcement) AfterFunc(f func()) (stop func() bool) {
 	a.mu.Lock()
 	if !a.active.Load() {
 		a.mu.Unlock()
-		// Announcement already ended — call in a goroutine to avoid deadlock
+		// Announcement already ended — call in a goroutine to avoid deadlock,
+		// as context.AfterFunc does. The goroutine is f's own and ends with
+		// it.
 		go f()
 		return func() bool { return false }
 	}
@@ -201,6 +244,7 @@ func (a *Announcement) end() {
 					break
 				}
 
+				// The workers are waited for below.
 				go func(handlers []func()) {
 					for _, h := range handlers {
 						h()
@@ -0,0 +1,81 @@
+package moqt
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// AnnouncementFilter reports whether an announcement should be delivered.
+//
+// Filters are applied by AnnouncementReader.All on the subscriber side and
+// by Config.AnnouncementFilter on the publisher side, where rejected
+// announcements are never sent to the peer.
+type AnnouncementFilter func(ann *Announcement) bool
+
+// MatchSuffix returns a filter that accepts announcements whose broadcast
+// path ends with segments matching one of patterns. Each pattern is matched
+// with path.Match against as many trailing path segments as it has, so
+// "*/video" accepts "/live/room1/video" and "/vod/room2/video".
+// A leading "/" anchors the pattern to the whole path instead.
+func MatchSuffix(patterns ...string) AnnouncementFilter {
+	return func(ann *Announcement) bool {
+		p := string(ann.BroadcastPath())
+		for _, pattern := range patterns {
+			if matchPathSuffix(pattern, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchPathSuffix matches pattern against the trailing segments of p.
+func matchPathSuffix(pattern, p string) bool {
+	if strings.HasPrefix(pattern, "/") {
+		ok, _ := path.Match(pattern, p)
+		return ok
+	}
+
+	n := strings.Count(pattern, "/") + 1
+	i := len(p)
+	for range n {
+		i = strings.LastIndexByte(p[:i], '/')
+		if i < 0 {
+			return false
+		}
+	}
+	ok, _ := path.Match(pattern, p[i+1:])
+	return ok
+}
+
+// MaxHops returns a filter that accepts announcements that have traversed at
+// most n relays.
+func MaxHops(n int) AnnouncementFilter {
+	return func(ann *Announcement) bool {
+		return len(ann.HopIDs()) <= n
+	}
+}
+
+// ExcludeHops returns a filter that rejects announcements that have
+// traversed any of the given relays.
+func ExcludeHops(ids ...uint64) AnnouncementFilter {
+	return func(ann *Announcement) bool {
+		for _, id := range ann.HopIDs() {
+			if slices.Contains(ids, id) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// matchAll reports whether ann is accepted by every filter.
+func matchAll(filters []AnnouncementFilter, ann *Announcement) bool {
+	for _, filter := range filters {
+		if filter != nil && !filter(ann) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchSuffix(t *testing.T) {
+	tests := map[string]struct {
+		patterns []string
+		path     BroadcastPath
+		want     bool
+	}{
+		"single segment": {
+			patterns: []string{"video"},
+			path:     "/live/room1/video",
+			want:     true,
+		},
+		"wildcard segments": {
+			patterns: []string{"*/video"},
+			path:     "/live/room1/video",
+			want:     true,
+		},
+		"wildcard mismatch": {
+			patterns: []string{"*/video"},
+			path:     "/live/room1/audio",
+			want:     false,
+		},
+		"pattern longer than path": {
+			patterns: []string{"*/*/*/video"},
+			path:     "/live/video",
+			want:     false,
+		},
+		"anchored match": {
+			patterns: []string{"/live/*"},
+			path:     "/live/room1",
+			want:     true,
+		},
+		"anchored mismatch": {
+			patterns: []string{"/live/*"},
+			path:     "/live/room1/video",
+			want:     false,
+		},
+		"any pattern": {
+			patterns: []string{"audio", "video"},
+			path:     "/live/room1/video",
+			want:     true,
+		},
+		"no patterns": {
+			path: "/live/room1/video",
+			want: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ann, _ := NewAnnouncement(t.Context(), tt.path)
+			assert.Equal(t, tt.want, MatchSuffix(tt.patterns...)(ann))
+		})
+	}
+}
+
+func TestHopFilters(t *testing.T) {
+	ann, _ := NewAnnouncement(t.Context(), "/live/a")
+	ann.hopIDs = []uint64{1, 2}
+
+	assert.True(t, MaxHops(2)(ann))
+	assert.False(t, MaxHops(1)(ann))
+	assert.True(t, ExcludeHops(3)(ann))
+	assert.False(t, ExcludeHops(3, 2)(ann))
+}
+
+func TestMatchAll(t *testing.T) {
+	ann, _ := NewAnnouncement(t.Context(), "/live/a")
+	accept := func(*Announcement) bool { return true }
+	reject := func(*Announcement) bool { return false }
+
+	assert.True(t, matchAll(nil, ann))
+	assert.True(t, matchAll([]AnnouncementFilter{nil, accept}, ann))
+	assert.False(t, matchAll([]AnnouncementFilter{accept, reject}, ann))
+}
+
+func TestAnnouncementReader_All(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	for _, s := range []string{"room1/audio", "room1/video", "room2/video"} {
+		msg := message.AnnounceMessage{BroadcastPathSuffix: s, AnnounceStatus: message.ACTIVE}
+		require.NoError(t, msg.Encode(buf))
+	}
+
+	mockStream := &FakeQUICStream{
+		ReadFunc: func(p []byte) (int, error) {
+			if buf.Len() > 0 {
+				return buf.Read(p)
+			}
+			select {}
+		},
+	}
+	ras := newAnnouncementReader(mockStream, "/live/", []string{}, nil, message.VersionLite04Ext)
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	var got []BroadcastPath
+	for ann := range ras.All(ctx, MatchSuffix("video")) {
+		got = append(got, ann.BroadcastPath())
+		if len(got) == 2 {
+			break
+		}
+	}
+
+	assert.Equal(t, []BroadcastPath{"/live/room1/video", "/live/room2/video"}, got)
+}
+
+func TestAnnouncementWriter_Filter(t *testing.T) {
+	var buf bytes.Buffer
+	aw := newTestAnnouncementWriter(t, func(m *FakeQUICStream) {
+		m.WriteFunc = buf.Write
+	})
+	aw.filter = MatchSuffix("video")
+
+	audio, _ := NewAnnouncement(t.Context(), "/test/audio")
+	video, _ := NewAnnouncement(t.Context(), "/test/video")
+
+	require.NoError(t, aw.init(map[*Announcement]struct{}{audio: {}}))
+	require.NoError(t, aw.SendAnnouncement(video))
+
+	assert.Len(t, aw.actives, 1)
+
+	var msg message.AnnounceMessage
+	require.NoError(t, msg.Decode(&buf))
+	assert.Equal(t, "video", msg.BroadcastPathSuffix)
+	assert.Zero(t, buf.Len(), "filtered announcements should not be written")
+}
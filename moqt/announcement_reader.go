@@ -9,7 +9,15 @@ import (
 	"github.com/qumo-dev/gomoqt/transport"
 )
 
-func newAnnouncementReader(stream transport.Stream, prefix prefix, initSuffixes []suffix) *AnnouncementReader {
+func newAnnouncementReader(stream transport.Stream, prefix prefix, initSuffixes []suffix, qlog *qlogWriter, version message.Version) *AnnouncementReader {
+	return newCheckedAnnouncementReader(stream, prefix, initSuffixes, qlog, version, nil, nil)
+}
+
+// newCheckedAnnouncementReader is like newAnnouncementReader, but closes the
+// stream with the error code of check, if set, when it denies a broadcast
+// announced by the peer, and reads the announcements by a task of tasks; see
+// goTask.
+func newCheckedAnnouncementReader(stream transport.Stream, prefix prefix, initSuffixes []suffix, qlog *qlogWriter, version message.Version, check func(BroadcastPath) error, tasks *taskGroup) *AnnouncementReader {
 	if !isValidPrefix(prefix) {
 		panic("invalid prefix for AnnouncementReader")
 	}
@@ -29,9 +37,8 @@ func newAnnouncementReader(stream transport.Stream, prefix prefix, initSuffixes
 		ar.pendings = append(ar.pendings, ann)
 	}
 
-	// Receive announcements in a separate goroutine
-	go func() {
-		var am message.AnnounceMessage
+	goTask(tasks, "announcement reader", func() {
+		am := message.AnnounceMessage{Version: version}
 		var err error
 
 		for {
@@ -39,12 +46,19 @@ func newAnnouncementReader(stream transport.Stream, prefix prefix, initSuffixes
 			if err != nil {
 				return
 			}
+			qlog.announce(false, am)
 
 			// Use an inner scope to ensure Lock/Unlock are paired even across continue/return
 			switch am.AnnounceStatus {
 			case message.ACTIVE:
 				{
 					suffix := am.BroadcastPathSuffix
+					if check != nil {
+						if err := check(BroadcastPath(ar.prefix + suffix)); err != nil {
+							ar.CloseWithError(aclAnnounceErrorCode(err))
+							return
+						}
+					}
 					var shouldClose bool
 					// Mutate maps under lock
 					func() {
@@ -56,6 +70,10 @@ func newAnnouncementReader(stream transport.Stream, prefix prefix, initSuffixes
 						if !ok || !old.IsActive() {
 							ann, _ := NewAnnouncement(ar.ctx, BroadcastPath(ar.prefix+suffix))
 							ann.hopIDs = am.HopIDs
+							if len(am.BroadcastID) == message.BroadcastIDLen {
+								ann.id = BroadcastID(am.BroadcastID)
+							}
+							ann.setTrackDescriptors(am.Tracks)
 							ar.actives[suffix] = ann
 							ar.pendings = append(ar.pendings, ann)
 							select {
@@ -99,7 +117,9 @@ func newAnnouncementReader(stream transport.Stream, prefix prefix, initSuffixes
 				return
 			}
 		}
-	}()
+	}, func() {
+		ar.CloseWithError(AnnounceErrorCodeInternal)
+	})
 
 	return ar
 }
@@ -175,6 +195,22 @@ func (ras *AnnouncementReader) Announcements(ctx context.Context) iter.Seq[*Anno
 
 }
 
+// All returns an iterator like Announcements that yields only the
+// announcements accepted by every filter. Rejected announcements are
+// dropped from the reader without being yielded.
+func (ras *AnnouncementReader) All(ctx context.Context, filters ...AnnouncementFilter) iter.Seq[*Announcement] {
+	return func(yield func(*Announcement) bool) {
+		for ann := range ras.Announcements(ctx) {
+			if !matchAll(filters, ann) {
+				continue
+			}
+			if !yield(ann) {
+				return
+			}
+		}
+	}
+}
+
 // Close closes the AnnouncementReader and releases resources. It is safe to call multiple times.
 func (ras *AnnouncementReader) Close() error {
 	ras.announcementsMu.Lock()
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewAnnouncement(t *testing.T) {
@@ -357,3 +358,29 @@ func TestAnnouncement_ConcurrentContextDone(t *testing.T) {
 		})
 	}
 }
+
+func TestAnnouncement_Alias(t *testing.T) {
+	id := NewBroadcastID()
+	ann, end := NewAnnouncementWithID(context.Background(), "/cam", id)
+	ann.hopIDs = []uint64{7}
+	ann.SetTrackMetadata("video", TrackMetadata{Bitrate: 1000})
+
+	alias, _ := ann.Alias("/tenant-a/cam")
+	assert.Equal(t, BroadcastPath("/tenant-a/cam"), alias.BroadcastPath())
+	assert.Equal(t, id, alias.BroadcastID())
+	assert.Equal(t, []uint64{7}, alias.HopIDs())
+	md, ok := alias.TrackMetadata("video")
+	require.True(t, ok)
+	assert.Equal(t, uint64(1000), md.Bitrate)
+	assert.True(t, alias.IsActive())
+
+	end()
+	assert.False(t, alias.IsActive(), "the alias must end with the announcement")
+
+	// Ending the alias leaves the announcement active.
+	ann, _ = NewAnnouncement(context.Background(), "/cam")
+	alias, endAlias := ann.Alias("/other")
+	endAlias()
+	assert.False(t, alias.IsActive())
+	assert.True(t, ann.IsActive())
+}
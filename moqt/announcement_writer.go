@@ -40,6 +40,9 @@ type AnnouncementWriter struct {
 	logger     *slog.Logger
 	localHopID uint64 // this node's Hop ID to append when forwarding
 	excludeHop uint64 // skip announcements whose HopIDs contain this value
+	filter     AnnouncementFilter
+	qlog       *qlogWriter
+	version    message.Version // encoding of the ANNOUNCE messages
 
 	mu      sync.RWMutex
 	actives map[suffix]*activeAnnouncement
@@ -59,14 +62,27 @@ func (aw *AnnouncementWriter) logError(msg string, err error, args ...any) {
 }
 
 // shouldExclude returns true if the announcement should be skipped because
-// its HopIDs contain the excludeHop value.
+// it is rejected by the filter or its HopIDs contain the excludeHop value.
 func (aw *AnnouncementWriter) shouldExclude(ann *Announcement) bool {
+	if aw.filter != nil && !aw.filter(ann) {
+		return true
+	}
 	if aw.excludeHop == 0 {
 		return false
 	}
 	return slices.Contains(ann.HopIDs(), aw.excludeHop)
 }
 
+// writeAnnounce encodes msg on the announce stream and records it in qlog.
+func (aw *AnnouncementWriter) writeAnnounce(msg message.AnnounceMessage) error {
+	msg.Version = aw.version
+	if err := msg.Encode(aw.stream); err != nil {
+		return err
+	}
+	aw.qlog.announce(true, msg)
+	return nil
+}
+
 // buildHopIDs returns the HopIDs to encode in the outgoing ANNOUNCE message.
 // If localHopID is non-zero, it is appended to the announcement's existing HopIDs.
 func (aw *AnnouncementWriter) buildHopIDs(ann *Announcement) []uint64 {
@@ -80,6 +96,15 @@ func (aw *AnnouncementWriter) buildHopIDs(ann *Announcement) []uint64 {
 	return result
 }
 
+// broadcastIDBytes returns the wire form of id, which is empty for the zero
+// BroadcastID.
+func broadcastIDBytes(id BroadcastID) []byte {
+	if id.IsZero() {
+		return nil
+	}
+	return id[:]
+}
+
 // init snapshots the currently active announcements, sends an ACTIVE AnnounceMessage
 // for each active track suffix on the announce stream, and sets up end handlers.
 func (aw *AnnouncementWriter) init(announcements map[*Announcement]struct{}) error {
@@ -117,11 +142,13 @@ func (aw *AnnouncementWriter) init(announcements map[*Announcement]struct{}) err
 		aw.mu.Unlock()
 
 		for sfx, active := range actives {
-			err = message.AnnounceMessage{
+			err = aw.writeAnnounce(message.AnnounceMessage{
 				AnnounceStatus:      message.ACTIVE,
 				BroadcastPathSuffix: sfx,
 				HopIDs:              aw.buildHopIDs(active.announcement),
-			}.Encode(aw.stream)
+				BroadcastID:         broadcastIDBytes(active.announcement.id),
+				Tracks:              active.announcement.trackDescriptors(),
+			})
 			if err != nil {
 				if strErr, ok := errors.AsType[*transport.StreamError](err); ok {
 					err = &AnnounceError{StreamError: strErr}
@@ -156,10 +183,10 @@ func (aw *AnnouncementWriter) registerEndHandler(sfx suffix, ann *Announcement)
 		current, exists := aw.actives[sfx]
 		if exists && current.announcement == ann {
 			delete(aw.actives, sfx)
-			err := message.AnnounceMessage{
+			err := aw.writeAnnounce(message.AnnounceMessage{
 				AnnounceStatus:      message.ENDED,
 				BroadcastPathSuffix: sfx,
-			}.Encode(aw.stream)
+			})
 			if err != nil {
 				aw.logError("failed to encode ANNOUNCE end message", err)
 			}
@@ -173,10 +200,10 @@ func (aw *AnnouncementWriter) registerEndHandler(sfx suffix, ann *Announcement)
 		aw.mu.Lock()
 		defer aw.mu.Unlock()
 		delete(aw.actives, sfx)
-		if err := (message.AnnounceMessage{
+		if err := aw.writeAnnounce(message.AnnounceMessage{
 			AnnounceStatus:      message.ENDED,
 			BroadcastPathSuffix: sfx,
-		}).Encode(aw.stream); err != nil {
+		}); err != nil {
 			aw.logError("failed to encode ANNOUNCE end message", err)
 		}
 	}
@@ -237,12 +264,22 @@ func (aw *AnnouncementWriter) SendAnnouncement(announcement *Announcement) error
 	aw.mu.Lock()
 	defer aw.mu.Unlock()
 
+	if invariantsEnabled {
+		if prev, ok := aw.actives[suffix]; ok {
+			invariantViolated("ACTIVE announcement sent twice without ENDED",
+				"prefix", aw.prefix, "suffix", suffix,
+				"previous_active", prev.announcement.IsActive())
+		}
+	}
+
 	// Encode and send ACTIVE announcement
-	err := message.AnnounceMessage{
+	err := aw.writeAnnounce(message.AnnounceMessage{
 		AnnounceStatus:      message.ACTIVE,
 		BroadcastPathSuffix: suffix,
 		HopIDs:              aw.buildHopIDs(announcement),
-	}.Encode(aw.stream)
+		BroadcastID:         broadcastIDBytes(announcement.id),
+		Tracks:              announcement.trackDescriptors(),
+	})
 	if err != nil {
 		if strErr, ok := errors.AsType[*transport.StreamError](err); ok {
 			return &AnnounceError{
@@ -370,6 +370,94 @@ func TestAnnouncementWriter_SendAnnouncement_EncodesHops(t *testing.T) {
 
 }
 
+func TestAnnouncementWriter_SendAnnouncement_BroadcastID(t *testing.T) {
+	var buf bytes.Buffer
+
+	aw := newTestAnnouncementWriter(t, func(m *FakeQUICStream) {
+		m.WriteFunc = buf.Write
+	})
+	aw.version = message.VersionLite04Ext
+	id := NewBroadcastID()
+	ann, _ := NewAnnouncementWithID(context.Background(), BroadcastPath("/test/stream1"), id)
+
+	require.NoError(t, aw.init(map[*Announcement]struct{}{}))
+	require.NoError(t, aw.SendAnnouncement(ann))
+
+	// The ID survives the round trip to the reader.
+	ras := newAnnouncementReader(&FakeQUICStream{
+		ReadFunc: func(p []byte) (int, error) {
+			if buf.Len() > 0 {
+				return buf.Read(p)
+			}
+			select {}
+		},
+	}, "/test/", []string{}, nil, message.VersionLite04Ext)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	received, err := ras.ReceiveAnnouncement(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, id, received.BroadcastID())
+	assert.Equal(t, ann.TrackID("video"), received.TrackID("video"))
+}
+
+func TestAnnouncementWriter_SendAnnouncement_TrackMetadata(t *testing.T) {
+	var buf bytes.Buffer
+
+	aw := newTestAnnouncementWriter(t, func(m *FakeQUICStream) {
+		m.WriteFunc = buf.Write
+	})
+	aw.version = message.VersionLite04Ext
+	ann, _ := NewAnnouncement(context.Background(), BroadcastPath("/test/stream1"))
+	hd := TrackMetadata{Bitrate: 4_000_000, Width: 1920, Height: 1080, KeyframeInterval: 2 * time.Second}
+	ann.SetTrackMetadata("video/1080p", hd)
+	ann.SetTrackMetadata("audio", TrackMetadata{Bitrate: 128_000})
+
+	require.NoError(t, aw.init(map[*Announcement]struct{}{}))
+	require.NoError(t, aw.SendAnnouncement(ann))
+
+	// The metadata is known to the subscriber before it subscribes.
+	ras := newAnnouncementReader(&FakeQUICStream{
+		ReadFunc: func(p []byte) (int, error) {
+			if buf.Len() > 0 {
+				return buf.Read(p)
+			}
+			select {}
+		},
+	}, "/test/", []string{}, nil, message.VersionLite04Ext)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	received, err := ras.ReceiveAnnouncement(ctx)
+	require.NoError(t, err)
+	assert.True(t, received.BroadcastID().IsZero())
+	assert.Equal(t, []TrackName{"audio", "video/1080p"}, received.TrackNames())
+	md, ok := received.TrackMetadata("video/1080p")
+	require.True(t, ok)
+	assert.Equal(t, hd, md)
+	_, ok = received.TrackMetadata("video/720p")
+	assert.False(t, ok)
+}
+
+func TestAnnouncementWriter_SendAnnouncement_Lite04(t *testing.T) {
+	var buf bytes.Buffer
+
+	aw := newTestAnnouncementWriter(t, func(m *FakeQUICStream) {
+		m.WriteFunc = buf.Write
+	})
+	ann, _ := NewAnnouncementWithID(context.Background(), BroadcastPath("/test/stream1"), NewBroadcastID())
+	ann.SetTrackMetadata("video", TrackMetadata{Bitrate: 128_000})
+
+	require.NoError(t, aw.init(map[*Announcement]struct{}{}))
+	require.NoError(t, aw.SendAnnouncement(ann))
+
+	// Neither the ID nor the metadata is sent to a moq-lite-04 peer.
+	var decoded message.AnnounceMessage
+	require.NoError(t, decoded.Decode(&buf))
+	assert.Equal(t, "stream1", decoded.BroadcastPathSuffix)
+	assert.Zero(t, buf.Len())
+}
+
 func TestAnnouncementWriter_SendAnnouncement_WriteError(t *testing.T) {
 	tests := map[string]struct {
 		writeError   error
@@ -0,0 +1,171 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ErrUnauthorized is returned by an Authorizer to deny a session or a
+// subscription. Sessions are rejected with HTTP 401 over WebTransport and
+// closed with UnauthorizedSessionErrorCode over native QUIC; subscriptions
+// are rejected with SubscribeErrorCodeUnauthorized. Any other error denies
+// with the corresponding internal error code.
+var ErrUnauthorized = errors.New("moqt: unauthorized")
+
+// AuthTokenQueryParam is the query parameter of a WebTransport request that
+// carries the session authorization token when the client cannot set an
+// Authorization header, as in browsers.
+const AuthTokenQueryParam = "token"
+
+// SessionAuthRequest describes a session to authorize.
+type SessionAuthRequest struct {
+	// Token is the authorization token presented by the client, or "".
+	// WebTransport clients send it as a bearer token in the Authorization
+	// header or in the AuthTokenQueryParam query parameter. Native QUIC has
+	// no setup exchange to carry it, so it is always "" there.
+	Token string
+
+	// Transport is "webtransport" or "quic".
+	Transport string
+
+	RemoteAddr net.Addr
+
+	// TLS is the TLS state of the connection, including client certificates.
+	TLS *tls.ConnectionState
+
+	// Request is the WebTransport CONNECT request, or nil for native QUIC.
+	Request *http.Request
+
+	// ResponseHeader is the header of the response to Request, which an
+	// Authorizer may add headers to, such as ResumeTokenHeader. It is nil
+	// for native QUIC.
+	ResponseHeader http.Header
+}
+
+// SubscribeAuthRequest describes an incoming subscription to authorize.
+type SubscribeAuthRequest struct {
+	BroadcastPath BroadcastPath
+	TrackName     TrackName
+
+	// Token is the authorization token carried in the SUBSCRIBE message,
+	// or "".
+	Token string
+
+	// SessionToken is the token the session was authorized with, or "".
+	SessionToken string
+
+	RemoteAddr net.Addr
+}
+
+// Authorizer decides whether a peer may establish a session and subscribe to
+// tracks. A nil error allows the request; ErrUnauthorized, or an error
+// wrapping it, denies it as unauthorized.
+//
+// AuthorizeSession is called before the Handler of an accepted session and,
+// for WebTransport, before the upgrade, with the request or connection
+// context. An allowed session gets the returned context's values, such as the
+// claims of a validated token, in addition to those of Session.Context; return
+// ctx itself to add none. AuthorizeSubscribe is called on receipt of a
+// SUBSCRIBE, before it reaches the TrackMux, with the session context.
+// Implementations must be safe for concurrent use.
+type Authorizer interface {
+	AuthorizeSession(ctx context.Context, r *SessionAuthRequest) (context.Context, error)
+	AuthorizeSubscribe(ctx context.Context, r *SubscribeAuthRequest) error
+}
+
+// AllowAuthorizer is an Authorizer that allows every session and
+// subscription. Embed it to implement only one of the methods.
+type AllowAuthorizer struct{}
+
+func (AllowAuthorizer) AuthorizeSession(ctx context.Context, _ *SessionAuthRequest) (context.Context, error) {
+	return ctx, nil
+}
+
+func (AllowAuthorizer) AuthorizeSubscribe(context.Context, *SubscribeAuthRequest) error {
+	return nil
+}
+
+// DenyAuthorizer is an Authorizer that denies every session and subscription
+// with ErrUnauthorized.
+type DenyAuthorizer struct{}
+
+func (DenyAuthorizer) AuthorizeSession(ctx context.Context, _ *SessionAuthRequest) (context.Context, error) {
+	return ctx, ErrUnauthorized
+}
+
+func (DenyAuthorizer) AuthorizeSubscribe(context.Context, *SubscribeAuthRequest) error {
+	return ErrUnauthorized
+}
+
+var (
+	_ Authorizer = AllowAuthorizer{}
+	_ Authorizer = DenyAuthorizer{}
+)
+
+// authContext is the context of an authorized session: it is canceled with
+// the connection, and carries the values of the context returned by
+// AuthorizeSession or Server.SessionContext before those of the connection
+// context.
+type authContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c authContext) Value(key any) any {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// requestAuthToken returns the session authorization token of a WebTransport
+// request: the bearer token of the Authorization header or, failing that,
+// the AuthTokenQueryParam query parameter.
+func requestAuthToken(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get(AuthTokenQueryParam)
+}
+
+// requestRemoteAddr returns the peer address of a WebTransport request, or
+// nil if it cannot be parsed.
+func requestRemoteAddr(r *http.Request) net.Addr {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return net.UDPAddrFromAddrPort(addrPort)
+}
+
+// authSessionErrorCode returns the session error code that denies a session
+// for err.
+func authSessionErrorCode(err error) SessionErrorCode {
+	if errors.Is(err, ErrUnauthorized) {
+		return UnauthorizedSessionErrorCode
+	}
+	return InternalSessionErrorCode
+}
+
+// authHTTPStatus returns the HTTP status that denies a WebTransport request
+// for err.
+func authHTTPStatus(err error) int {
+	if errors.Is(err, ErrUnauthorized) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// authSubscribeErrorCode returns the subscribe error code that denies a
+// subscription for err.
+func authSubscribeErrorCode(err error) SubscribeErrorCode {
+	if errors.Is(err, ErrUnauthorized) {
+		return SubscribeErrorCodeUnauthorized
+	}
+	return SubscribeErrorCodeInternal
+}
@@ -0,0 +1,500 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type authKey struct{}
+
+// fakeAuthorizer records the requests it receives and answers them with err.
+type fakeAuthorizer struct {
+	err error
+
+	mu         sync.Mutex
+	sessions   []SessionAuthRequest
+	subscribes []SubscribeAuthRequest
+}
+
+func (a *fakeAuthorizer) AuthorizeSession(ctx context.Context, r *SessionAuthRequest) (context.Context, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessions = append(a.sessions, *r)
+	return context.WithValue(ctx, authKey{}, r.Token), a.err
+}
+
+func (a *fakeAuthorizer) AuthorizeSubscribe(ctx context.Context, r *SubscribeAuthRequest) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.subscribes = append(a.subscribes, *r)
+	return a.err
+}
+
+func TestRequestAuthToken(t *testing.T) {
+	tests := map[string]struct {
+		url    string
+		header string
+		want   string
+	}{
+		"bearer header": {
+			url:    "https://example.com/moq",
+			header: "Bearer abc",
+			want:   "abc",
+		},
+		"case insensitive scheme": {
+			url:    "https://example.com/moq",
+			header: "bearer abc",
+			want:   "abc",
+		},
+		"query parameter": {
+			url:  "https://example.com/moq?token=xyz",
+			want: "xyz",
+		},
+		"header wins over query": {
+			url:    "https://example.com/moq?token=xyz",
+			header: "Bearer abc",
+			want:   "abc",
+		},
+		"other scheme": {
+			url:    "https://example.com/moq",
+			header: "Basic dXNlcjpwYXNz",
+		},
+		"none": {
+			url: "https://example.com/moq",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodConnect, tt.url, nil)
+			require.NoError(t, err)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			assert.Equal(t, tt.want, requestAuthToken(r))
+		})
+	}
+}
+
+func TestAuthErrorCodes(t *testing.T) {
+	tests := map[string]struct {
+		err           error
+		wantSession   SessionErrorCode
+		wantSubscribe SubscribeErrorCode
+		wantStatus    int
+	}{
+		"unauthorized": {
+			err:           ErrUnauthorized,
+			wantSession:   UnauthorizedSessionErrorCode,
+			wantSubscribe: SubscribeErrorCodeUnauthorized,
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"wrapped unauthorized": {
+			err:           errors.Join(errors.New("expired"), ErrUnauthorized),
+			wantSession:   UnauthorizedSessionErrorCode,
+			wantSubscribe: SubscribeErrorCodeUnauthorized,
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"other error": {
+			err:           errors.New("backend unavailable"),
+			wantSession:   InternalSessionErrorCode,
+			wantSubscribe: SubscribeErrorCodeInternal,
+			wantStatus:    http.StatusInternalServerError,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.wantSession, authSessionErrorCode(tt.err))
+			assert.Equal(t, tt.wantSubscribe, authSubscribeErrorCode(tt.err))
+			assert.Equal(t, tt.wantStatus, authHTTPStatus(tt.err))
+		})
+	}
+}
+
+func TestServer_Authorizer_NativeQUIC(t *testing.T) {
+	tests := map[string]struct {
+		authorizer  Authorizer
+		wantHandled bool
+		wantCode    transport.ConnErrorCode
+	}{
+		"allowed": {
+			authorizer:  AllowAuthorizer{},
+			wantHandled: true,
+		},
+		"denied": {
+			authorizer: DenyAuthorizer{},
+			wantCode:   transport.ConnErrorCode(UnauthorizedSessionErrorCode),
+		},
+		"failed": {
+			authorizer: &fakeAuthorizer{err: errors.New("backend unavailable")},
+			wantCode:   transport.ConnErrorCode(InternalSessionErrorCode),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var closedWith transport.ConnErrorCode
+			conn := newTestNativeQUICConn(t, func(conn *FakeStreamConn) {
+				conn.CloseWithErrorFunc = func(code transport.ConnErrorCode, reason string) error {
+					closedWith = code
+					return nil
+				}
+			})
+			metrics := &fakeServerMetrics{}
+			handled := false
+			s := &Server{
+				Authorizer: tt.authorizer,
+				Metrics:    metrics,
+				Handler: HandleFunc(func(sess *Session) {
+					handled = true
+					assert.Equal(t, tt.authorizer, sess.authorizer)
+					_ = sess.CloseWithError(NoError, "")
+				}),
+			}
+
+			_ = s.ServeQUICConn(conn)
+
+			assert.Equal(t, tt.wantHandled, handled)
+			if !tt.wantHandled {
+				assert.Equal(t, tt.wantCode, closedWith)
+				assert.Equal(t, []string{"quic"}, metrics.setupFailed)
+			}
+		})
+	}
+}
+
+func TestWebTransportHandler_Authorizer(t *testing.T) {
+	tests := map[string]struct {
+		err          error
+		wantUpgraded bool
+		wantStatus   int
+	}{
+		"allowed": {
+			wantUpgraded: true,
+		},
+		"denied": {
+			err:        ErrUnauthorized,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			authorizer := &fakeAuthorizer{err: tt.err}
+			upgraded := false
+			var gotToken string
+			u := &WebTransportHandler{
+				TrackMux:   NewTrackMux(0),
+				Authorizer: authorizer,
+				UpgradeFunc: func(w http.ResponseWriter, r *http.Request) (WebTransportSession, error) {
+					upgraded = true
+					return &FakeWebTransportSession{}, nil
+				},
+				Handler: HandleFunc(func(sess *Session) {
+					gotToken = sess.authToken
+					assert.Equal(t, "abc", sess.Context().Value(authKey{}))
+				}),
+			}
+
+			r, err := http.NewRequest(http.MethodConnect, "https://example.com/moq", nil)
+			require.NoError(t, err)
+			r.Header.Set("Authorization", "Bearer abc")
+			r.RemoteAddr = "192.0.2.1:5000"
+			r.TLS = &tls.ConnectionState{}
+			var status int
+			w := &FakeHTTPResponseWriter{WriteHeaderFunc: func(code int) { status = code }}
+
+			u.ServeHTTP(w, r)
+
+			require.Len(t, authorizer.sessions, 1)
+			got := authorizer.sessions[0]
+			assert.Equal(t, "abc", got.Token)
+			assert.Equal(t, "webtransport", got.Transport)
+			assert.Equal(t, "192.0.2.1:5000", got.RemoteAddr.String())
+			assert.Same(t, r, got.Request)
+
+			assert.Equal(t, tt.wantUpgraded, upgraded)
+			assert.Equal(t, tt.wantStatus, status)
+			if tt.wantUpgraded {
+				assert.Equal(t, "abc", gotToken)
+			}
+		})
+	}
+}
+
+func TestWebTransportHandler_Authorizer_SkipsPlainHTTP(t *testing.T) {
+	authorizer := &fakeAuthorizer{err: ErrUnauthorized}
+	fallback := false
+	u := &WebTransportHandler{
+		Authorizer: authorizer,
+		UpgradeFunc: func(w http.ResponseWriter, r *http.Request) (WebTransportSession, error) {
+			return nil, errors.New("not a WebTransport request")
+		},
+		FallbackHandler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			fallback = true
+		}),
+	}
+
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	require.NoError(t, err)
+	u.ServeHTTP(&FakeHTTPResponseWriter{}, r)
+
+	assert.True(t, fallback)
+	assert.Empty(t, authorizer.sessions)
+}
+
+type tenantKey struct{}
+
+func TestServer_SessionContext_NativeQUIC(t *testing.T) {
+	tests := map[string]struct {
+		authorizer Authorizer
+		wantToken  any
+	}{
+		"without authorizer": {},
+		"with authorizer": {
+			authorizer: &fakeAuthorizer{},
+			wantToken:  "",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got *SessionAuthRequest
+			var tenant, token any
+			s := &Server{
+				Authorizer: tt.authorizer,
+				SessionContext: func(ctx context.Context, r *SessionAuthRequest) context.Context {
+					got = r
+					return context.WithValue(ctx, tenantKey{}, "acme")
+				},
+				Handler: HandleFunc(func(sess *Session) {
+					tenant = sess.Value(tenantKey{})
+					token = sess.Context().Value(authKey{})
+					_ = sess.CloseWithError(NoError, "")
+				}),
+			}
+
+			_ = s.ServeQUICConn(newTestNativeQUICConn(t))
+
+			require.NotNil(t, got)
+			assert.Equal(t, "quic", got.Transport)
+			assert.Equal(t, "acme", tenant)
+			assert.Equal(t, tt.wantToken, token)
+		})
+	}
+}
+
+func TestServer_SessionContext_NotCalledOnDenial(t *testing.T) {
+	called := false
+	s := &Server{
+		Authorizer: DenyAuthorizer{},
+		SessionContext: func(ctx context.Context, r *SessionAuthRequest) context.Context {
+			called = true
+			return ctx
+		},
+		Handler: HandleFunc(func(sess *Session) {}),
+	}
+
+	_ = s.ServeQUICConn(newTestNativeQUICConn(t))
+
+	assert.False(t, called)
+}
+
+func TestWebTransportHandler_SessionContext(t *testing.T) {
+	var tenant, token any
+	server := &Server{
+		SessionContext: func(ctx context.Context, r *SessionAuthRequest) context.Context {
+			assert.Equal(t, "webtransport", r.Transport)
+			assert.Equal(t, "abc", r.Token)
+			return context.WithValue(ctx, tenantKey{}, "acme")
+		},
+	}
+	u := &WebTransportHandler{
+		TrackMux:   NewTrackMux(0),
+		Authorizer: &fakeAuthorizer{},
+		UpgradeFunc: func(w http.ResponseWriter, r *http.Request) (WebTransportSession, error) {
+			return &FakeWebTransportSession{}, nil
+		},
+		Handler: HandleFunc(func(sess *Session) {
+			tenant = sess.Value(tenantKey{})
+			token = sess.Value(authKey{})
+		}),
+	}
+
+	r, err := http.NewRequest(http.MethodConnect, "https://example.com/moq", nil)
+	require.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer abc")
+	r = r.WithContext(context.WithValue(r.Context(), serverHandlerContextKey, server))
+
+	u.ServeHTTP(&FakeHTTPResponseWriter{}, r)
+
+	assert.Equal(t, "acme", tenant)
+	assert.Equal(t, "abc", token)
+}
+
+func TestServer_SessionContext_Nil(t *testing.T) {
+	s := &Server{
+		SessionContext: func(ctx context.Context, r *SessionAuthRequest) context.Context {
+			return nil
+		},
+	}
+
+	assert.PanicsWithValue(t, "SessionContext returned nil", func() {
+		s.sessionContext(context.Background(), nil, &SessionAuthRequest{})
+	})
+}
+
+func TestSession_AuthorizeSubscribe(t *testing.T) {
+	tests := map[string]struct {
+		err        error
+		wantServed bool
+		wantCode   transport.StreamErrorCode
+	}{
+		"allowed": {
+			wantServed: true,
+		},
+		"denied": {
+			err:      ErrUnauthorized,
+			wantCode: transport.StreamErrorCode(SubscribeErrorCodeUnauthorized),
+		},
+		"failed": {
+			err:      errors.New("backend unavailable"),
+			wantCode: transport.StreamErrorCode(SubscribeErrorCodeInternal),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			authorizer := &fakeAuthorizer{err: tt.err}
+			session, _ := newTestSessionWithConn(t, extendedConn)
+			session.authorizer = authorizer
+			session.authToken = "session-token"
+
+			served := false
+			session.mux.PublishFunc(t.Context(), "/test/path", func(tw *TrackWriter) {
+				served = true
+			})
+
+			var buf bytes.Buffer
+			require.NoError(t, message.StreamTypeSubscribe.Encode(&buf))
+			require.NoError(t, message.SubscribeMessage{
+				SubscribeID:   1,
+				BroadcastPath: "/test/path",
+				TrackName:     "video",
+				AuthToken:     "subscribe-token",
+				Version:       message.VersionLite04Ext,
+			}.Encode(&buf))
+
+			var canceled []transport.StreamErrorCode
+			stream := &FakeQUICStream{
+				ReadFunc: func(p []byte) (int, error) {
+					if buf.Len() == 0 {
+						return 0, io.EOF
+					}
+					return buf.Read(p)
+				},
+				CancelWriteFunc: func(code transport.StreamErrorCode) {
+					canceled = append(canceled, code)
+				},
+			}
+
+			session.processBiStream(stream, func() {})
+
+			require.Len(t, authorizer.subscribes, 1)
+			assert.Equal(t, SubscribeAuthRequest{
+				BroadcastPath: "/test/path",
+				TrackName:     "video",
+				Token:         "subscribe-token",
+				SessionToken:  "session-token",
+				RemoteAddr:    session.conn.RemoteAddr(),
+			}, authorizer.subscribes[0])
+			assert.Equal(t, tt.wantServed, served)
+			if !tt.wantServed {
+				assert.Equal(t, []transport.StreamErrorCode{tt.wantCode}, canceled)
+			}
+		})
+	}
+}
+
+func TestSession_Subscribe_AuthToken(t *testing.T) {
+	var written bytes.Buffer
+	session, _ := newTestSessionWithConn(t, extendedConn, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) {
+			return &FakeQUICStream{WriteFunc: written.Write}, nil
+		}
+	})
+
+	_, _ = session.Subscribe(t.Context(), "/test/path", "video", &SubscribeConfig{AuthToken: "abc"})
+
+	var st message.StreamType
+	require.NoError(t, st.Decode(&written))
+	sm := message.SubscribeMessage{Version: message.VersionLite04Ext}
+	require.NoError(t, sm.Decode(&written))
+	assert.Equal(t, "abc", sm.AuthToken)
+}
+
+func TestDialer_AuthToken(t *testing.T) {
+	tests := map[string]struct {
+		token string
+		want  http.Header
+	}{
+		"with token": {
+			token: "abc",
+			want:  http.Header{"Authorization": {"Bearer abc"}},
+		},
+		"without token": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got http.Header
+			dialer := &Dialer{
+				AuthToken: tt.token,
+				DialWebTransportFunc: func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
+					got = header
+					return nil, nil, errors.New("refused")
+				},
+			}
+
+			_, err := dialer.DialWebTransport(t.Context(), "example.com:443", "/moq", nil)
+			require.Error(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_AuthToken_UsesWebTransport(t *testing.T) {
+	quicDialed := false
+	var webTransportHost string
+	client := &Client{
+		Dialer: &Dialer{
+			AuthToken: "abc",
+			DialQUICFunc: func(context.Context, string, *tls.Config, *quic.Config) (StreamConn, error) {
+				quicDialed = true
+				return nil, errors.New("refused")
+			},
+			DialWebTransportFunc: func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
+				webTransportHost = addr
+				return nil, nil, errors.New("refused")
+			},
+		},
+	}
+
+	_, err := client.Dial(t.Context(), "https://example.com:443")
+	require.Error(t, err)
+	assert.False(t, quicDialed)
+	assert.Equal(t, "https://example.com:443/", webTransportHost)
+}
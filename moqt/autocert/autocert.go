@@ -0,0 +1,230 @@
+package autocert
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// handshakeTimeout bounds the TLS handshakes of the tls-alpn-01 listener.
+const handshakeTimeout = 10 * time.Second
+
+// Cache stores the ACME account key and the certificates across restarts.
+// Without a cache, certificates are requested again on every start, which
+// quickly hits the rate limits of public CAs.
+type Cache = autocert.Cache
+
+// DirCache is a Cache storing the data as files in a directory.
+type DirCache = autocert.DirCache
+
+// ErrCacheMiss is returned by a Cache for missing entries.
+var ErrCacheMiss = autocert.ErrCacheMiss
+
+// Manager obtains the certificates of the hosts of a moqt.Server from an
+// ACME CA, such as Let's Encrypt, when clients first connect, and renews
+// them before they expire.
+//
+// ACME CAs validate hosts over TCP, which a QUIC server does not listen on:
+// the challenges are answered by companion listeners run with Serve, on port
+// 80 for http-01 (HTTPAddr) or TCP port 443 for tls-alpn-01 (TLSALPNAddr).
+//
+// Using a Manager accepts the terms of service of the CA.
+type Manager struct {
+	// Hosts are the host names certificates are obtained for. Connections
+	// for other names are refused. It is required.
+	Hosts []string
+
+	// Email is the contact address of the ACME account, for notices about
+	// the certificates. Optional.
+	Email string
+
+	// Cache stores the account key and certificates. Optional but strongly
+	// recommended.
+	Cache Cache
+
+	// DirectoryURL is the ACME directory of the CA. If empty, Let's Encrypt
+	// is used.
+	DirectoryURL string
+
+	// RenewBefore is how long before expiry certificates are renewed. If
+	// zero, they are renewed 30 days before.
+	RenewBefore time.Duration
+
+	// HTTPAddr is the address of the listener answering http-01 challenges,
+	// such as ":80". Other requests are redirected to HTTPS. If empty, the
+	// http-01 challenge is not used by Serve.
+	HTTPAddr string
+
+	// TLSALPNAddr is the TCP address of the listener answering tls-alpn-01
+	// challenges, such as ":443". It only accepts ACME handshakes. If empty,
+	// the tls-alpn-01 challenge is not used by Serve.
+	TLSALPNAddr string
+
+	// Logger logs the failures of the companion listeners. Optional.
+	Logger *slog.Logger
+
+	initOnce sync.Once
+	manager  *autocert.Manager
+}
+
+func (m *Manager) init() {
+	m.initOnce.Do(func() {
+		m.manager = &autocert.Manager{
+			Prompt:      autocert.AcceptTOS,
+			Cache:       m.Cache,
+			HostPolicy:  autocert.HostWhitelist(m.Hosts...),
+			RenewBefore: m.RenewBefore,
+			Email:       m.Email,
+		}
+		if m.DirectoryURL != "" {
+			m.manager.Client = &acme.Client{DirectoryURL: m.DirectoryURL}
+		}
+		if m.HTTPAddr != "" {
+			// Enables the http-01 challenge.
+			m.manager.HTTPHandler(nil)
+		}
+	})
+}
+
+// GetCertificate returns the certificate of the server name of hello,
+// obtaining or renewing it if necessary. It implements the GetCertificate
+// callback of tls.Config.
+//
+// An ECDSA certificate is used for clients that support it, and an RSA one
+// otherwise. QUIC handshakes are TLS 1.3, whose cipher suites do not name the
+// certificate type, so only their signature algorithms are considered.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.init()
+	if slices.Contains(hello.SupportedVersions, tls.VersionTLS13) {
+		// autocert looks for an ECDSA cipher suite of TLS 1.2 besides the
+		// signature algorithms.
+		tls13 := *hello
+		tls13.CipherSuites = append(slices.Clip(hello.CipherSuites), tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+		hello = &tls13
+	}
+	return m.manager.GetCertificate(hello)
+}
+
+// TLSConfig returns a TLS configuration for moqt.Server.TLSConfig, with the
+// certificates of the Manager. The server sets its ALPN tokens.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: m.GetCertificate}
+}
+
+// HTTPHandler returns a handler answering http-01 challenges, for an
+// existing HTTP server on port 80 instead of HTTPAddr. Other requests go to
+// fallback; if nil, GET and HEAD requests are redirected to HTTPS.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	m.init()
+	return m.manager.HTTPHandler(fallback)
+}
+
+// Serve runs the companion listeners of HTTPAddr and TLSALPNAddr until ctx
+// is canceled, and then returns nil. It returns an error if no listener is
+// configured, or if one fails.
+func (m *Manager) Serve(ctx context.Context) error {
+	m.init()
+	if m.HTTPAddr == "" && m.TLSALPNAddr == "" {
+		return errors.New("autocert: neither HTTPAddr nor TLSALPNAddr is set")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+		cancel()
+	}
+
+	if m.HTTPAddr != "" {
+		ln, err := net.Listen("tcp", m.HTTPAddr)
+		if err != nil {
+			return fmt.Errorf("autocert: failed to listen for http-01 at %s: %w", m.HTTPAddr, err)
+		}
+		server := &http.Server{
+			Handler:           m.manager.HTTPHandler(nil),
+			ReadHeaderTimeout: handshakeTimeout,
+		}
+		wg.Go(func() {
+			if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+				fail(fmt.Errorf("autocert: http-01 listener: %w", err))
+			}
+		})
+		wg.Go(func() {
+			<-ctx.Done()
+			_ = server.Close()
+		})
+	}
+
+	if m.TLSALPNAddr != "" {
+		ln, err := net.Listen("tcp", m.TLSALPNAddr)
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return fmt.Errorf("autocert: failed to listen for tls-alpn-01 at %s: %w", m.TLSALPNAddr, err)
+		}
+		wg.Go(func() {
+			if err := m.serveTLSALPN(ctx, ln); err != nil {
+				fail(fmt.Errorf("autocert: tls-alpn-01 listener: %w", err))
+			}
+		})
+		wg.Go(func() {
+			<-ctx.Done()
+			_ = ln.Close()
+		})
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// serveTLSALPN completes the ACME handshakes of the connections accepted on
+// ln until ctx is canceled.
+func (m *Manager) serveTLSALPN(ctx context.Context, ln net.Listener) error {
+	config := &tls.Config{
+		NextProtos: []string{acme.ALPNProto},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if !slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+				return nil, errors.New("autocert: not an ACME challenge")
+			}
+			return m.manager.GetCertificate(hello)
+		},
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			tlsConn := tls.Server(conn, config)
+			hctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+			defer cancel()
+			if err := tlsConn.HandshakeContext(hctx); err != nil {
+				if logger := m.Logger; logger != nil {
+					logger.Debug("tls-alpn-01 handshake failed", "remote", conn.RemoteAddr(), "error", err)
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,253 @@
+package autocert
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreachableDirectory is an ACME directory that cannot be reached, so that
+// tests never contact a real CA.
+const unreachableDirectory = "http://127.0.0.1:1/directory"
+
+// memCache is a Cache in memory.
+type memCache struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (c *memCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.data[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return data, nil
+}
+
+func (c *memCache) Put(ctx context.Context, key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data == nil {
+		c.data = make(map[string][]byte)
+	}
+	c.data[key] = data
+	return nil
+}
+
+func (c *memCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+	return nil
+}
+
+// newCachedCert returns a cache holding a certificate for host, valid long
+// enough not to be renewed, as if it had been obtained before.
+func newCachedCert(t *testing.T, host string) *memCache {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		DNSNames:     []string{host},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	var data bytes.Buffer
+	require.NoError(t, pem.Encode(&data, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	require.NoError(t, pem.Encode(&data, &pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	cache := &memCache{}
+	require.NoError(t, cache.Put(t.Context(), host, data.Bytes()))
+	return cache
+}
+
+func TestManager_GetCertificate(t *testing.T) {
+	ecdsaHello := tls.ClientHelloInfo{
+		SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}
+	tls13Hello := tls.ClientHelloInfo{
+		SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256, tls.PSSWithSHA256},
+		SupportedVersions: []uint16{tls.VersionTLS13},
+		CipherSuites:      []uint16{tls.TLS_AES_128_GCM_SHA256},
+	}
+
+	tests := map[string]struct {
+		hello      tls.ClientHelloInfo
+		serverName string
+		wantErr    bool
+	}{
+		"cached host": {
+			hello:      ecdsaHello,
+			serverName: "relay.example.com",
+		},
+		"TLS 1.3": {
+			hello:      tls13Hello,
+			serverName: "relay.example.com",
+		},
+		"other host": {
+			hello:      ecdsaHello,
+			serverName: "other.example.com",
+			wantErr:    true,
+		},
+		"no server name": {
+			hello:   ecdsaHello,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &Manager{
+				Hosts:        []string{"relay.example.com"},
+				Cache:        newCachedCert(t, "relay.example.com"),
+				DirectoryURL: unreachableDirectory,
+			}
+			hello := tt.hello
+			hello.ServerName = tt.serverName
+			cert, err := m.TLSConfig().GetCertificate(&hello)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "relay.example.com", cert.Leaf.Subject.CommonName)
+		})
+	}
+}
+
+func TestManager_HTTPHandler(t *testing.T) {
+	m := &Manager{
+		Hosts:        []string{"relay.example.com"},
+		Cache:        &memCache{},
+		DirectoryURL: unreachableDirectory,
+	}
+	handler := m.HTTPHandler(nil)
+
+	tests := map[string]struct {
+		host, path string
+		want       int
+	}{
+		"unknown token": {
+			host: "relay.example.com",
+			path: "/.well-known/acme-challenge/token",
+			want: http.StatusNotFound,
+		},
+		"other host": {
+			host: "other.example.com",
+			path: "/.well-known/acme-challenge/token",
+			want: http.StatusForbidden,
+		},
+		"redirect": {
+			host: "relay.example.com",
+			path: "/index.html",
+			want: http.StatusFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+tt.path, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestManager_Serve(t *testing.T) {
+	t.Run("no listener", func(t *testing.T) {
+		assert.Error(t, (&Manager{}).Serve(t.Context()))
+	})
+
+	t.Run("companion listeners", func(t *testing.T) {
+		freeAddr := func() string {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer ln.Close()
+			return ln.Addr().String()
+		}
+		m := &Manager{
+			Hosts:        []string{"relay.example.com"},
+			Cache:        newCachedCert(t, "relay.example.com"),
+			DirectoryURL: unreachableDirectory,
+			HTTPAddr:     freeAddr(),
+			TLSALPNAddr:  freeAddr(),
+		}
+		ctx, cancel := context.WithCancel(t.Context())
+		errCh := make(chan error, 1)
+		go func() { errCh <- m.Serve(ctx) }()
+
+		// The http-01 listener answers challenges.
+		var resp *http.Response
+		require.Eventually(t, func() bool {
+			var err error
+			resp, err = http.Get("http://" + m.HTTPAddr + "/.well-known/acme-challenge/token")
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, "the host is not allowed")
+
+		// The tls-alpn-01 listener only accepts ACME handshakes.
+		_, err := tls.Dial("tcp", m.TLSALPNAddr, &tls.Config{
+			ServerName:         "relay.example.com",
+			NextProtos:         []string{"h2"},
+			InsecureSkipVerify: true,
+		})
+		assert.Error(t, err)
+
+		cancel()
+		select {
+		case err := <-errCh:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Serve did not return")
+		}
+	})
+}
+
+func TestManager_Server(t *testing.T) {
+	m := &Manager{
+		Hosts:        []string{"relay.example.com"},
+		Cache:        newCachedCert(t, "relay.example.com"),
+		DirectoryURL: unreachableDirectory,
+	}
+
+	addr := moqttest.Serve(t, &moqt.Server{
+		TLSConfig: m.TLSConfig(),
+		Handler: moqt.HandleFunc(func(sess *moqt.Session) {
+			<-sess.Context().Done()
+		}),
+	})
+	dialer := &moqt.Dialer{TLSConfig: &tls.Config{ServerName: "relay.example.com", InsecureSkipVerify: true}}
+	sess := moqttest.DialWith(t, dialer, addr, nil)
+
+	assert.Equal(t, "relay.example.com", sess.ConnectionState().TLS.PeerCertificates[0].Subject.CommonName)
+}
@@ -0,0 +1,22 @@
+// Package autocert obtains and renews the TLS certificates of a moqt.Server
+// with ACME, such as from Let's Encrypt, so that public MOQ relays run
+// without manual certificate management.
+//
+// ACME CAs validate host names over TCP, while MOQ servers listen on UDP: a
+// Manager answers the challenges on companion listeners, for http-01 on port
+// 80 or tls-alpn-01 on TCP port 443, next to the QUIC listener.
+//
+//	m := &autocert.Manager{
+//		Hosts:    []string{"relay.example.com"},
+//		Email:    "ops@example.com",
+//		Cache:    autocert.DirCache("/var/lib/moq/certs"),
+//		HTTPAddr: ":80",
+//	}
+//	go m.Serve(ctx)
+//
+//	server := &moqt.Server{
+//		Addr:      ":443",
+//		TLSConfig: m.TLSConfig(),
+//	}
+//	err := server.ListenAndServe()
+package autocert
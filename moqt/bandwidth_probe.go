@@ -0,0 +1,140 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// BandwidthProbePath and BandwidthProbeTrackName identify the track that
+// Session.ProbeBandwidth subscribes to. A peer serves it by publishing
+// BandwidthProbeHandler:
+//
+//	mux.Publish(ctx, moqt.BandwidthProbePath, moqt.BandwidthProbeHandler)
+const (
+	BandwidthProbePath      BroadcastPath = "/.probe"
+	BandwidthProbeTrackName TrackName     = "bandwidth"
+)
+
+// MaxBandwidthProbeDuration is the longest a bandwidth probe runs. The
+// publisher stops sending after it, whatever the subscriber does.
+const MaxBandwidthProbeDuration = 10 * time.Second
+
+// bandwidthProbeFrameSize and bandwidthProbeGroupFrames are the size of the
+// filler frames of a bandwidth probe and the number of frames per group.
+const (
+	bandwidthProbeFrameSize   = 16 * 1024
+	bandwidthProbeGroupFrames = 64
+)
+
+// ErrNoProbeData is returned by Session.ProbeBandwidth if no data was
+// received during the probe.
+var ErrNoProbeData = errors.New("moqt: no data received from bandwidth probe")
+
+// BandwidthProbeHandler is a TrackHandler that sends filler frames as fast as
+// the connection allows, for at most MaxBandwidthProbeDuration, to every
+// subscriber of BandwidthProbeTrackName. Subscriptions to other tracks are
+// rejected as not found.
+var BandwidthProbeHandler TrackHandler = TrackHandlerFunc(serveBandwidthProbe)
+
+func serveBandwidthProbe(tw *TrackWriter) {
+	if tw.TrackName != BandwidthProbeTrackName {
+		NotFound(tw)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(tw.Context(), MaxBandwidthProbeDuration)
+	defer cancel()
+	// Unblock a pending write when the probe times out.
+	stop := context.AfterFunc(ctx, func() { _ = tw.Close() })
+	defer stop()
+
+	frame := NewFrame(bandwidthProbeFrameSize)
+	_, _ = frame.Write(make([]byte, bandwidthProbeFrameSize))
+
+	for ctx.Err() == nil {
+		gw, err := tw.OpenGroup()
+		if err != nil {
+			break
+		}
+		for range bandwidthProbeGroupFrames {
+			if err = gw.WriteFrame(frame); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			gw.CancelWrite(InternalGroupErrorCode)
+			break
+		}
+		_ = gw.Close()
+	}
+	_ = tw.Close()
+}
+
+// ProbeBandwidth estimates the bandwidth available from the peer, in bits per
+// second, before selecting renditions. It subscribes at the highest priority
+// to the bandwidth probe track of the peer (see BandwidthProbeHandler) for
+// duration, capped to MaxBandwidthProbeDuration, and divides the data
+// received by the time it took to arrive.
+//
+// The estimate is also reported as SessionStats.EstimatedBitrate. Since the
+// probe competes with the other tracks of the session, it is most accurate
+// before they start. If the peer does not serve the probe track, the error
+// of the subscription is returned; if nothing arrived, ErrNoProbeData.
+func (s *Session) ProbeBandwidth(ctx context.Context, duration time.Duration) (uint64, error) {
+	if duration <= 0 {
+		return 0, fmt.Errorf("moqt: invalid probe duration %s", duration)
+	}
+	duration = min(duration, MaxBandwidthProbeDuration)
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	tr, err := s.Subscribe(ctx, BandwidthProbePath, BandwidthProbeTrackName, &SubscribeConfig{
+		Priority: math.MaxUint8,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tr.Close()
+
+	var (
+		received    uint64
+		first, last time.Time
+	)
+	frame := NewFrame(bandwidthProbeFrameSize)
+	for {
+		gr, err := tr.AcceptGroup(ctx)
+		if err != nil {
+			break
+		}
+		_ = gr.SetReadDeadline(deadline)
+		if first.IsZero() {
+			first = time.Now()
+		}
+
+		for {
+			if err = gr.ReadFrame(frame); err != nil {
+				break
+			}
+			received += uint64(len(frame.Body()))
+			last = time.Now()
+		}
+		if ctx.Err() != nil {
+			gr.CancelRead(InternalGroupErrorCode)
+			break
+		}
+	}
+
+	elapsed := last.Sub(first)
+	if received == 0 || elapsed <= 0 {
+		return 0, ErrNoProbeData
+	}
+
+	bitrate := uint64(float64(received*8) / elapsed.Seconds())
+	s.bitrateTracker.estimatedBitrate.Store(bitrate)
+	return bitrate, nil
+}
@@ -0,0 +1,118 @@
+package moqt
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthProbeHandler(t *testing.T) {
+	tests := map[string]struct {
+		trackName  TrackName
+		wantOpened bool
+	}{
+		"probe track": {trackName: BandwidthProbeTrackName, wantOpened: true},
+		"other track": {trackName: "video", wantOpened: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				written int
+				opened  int
+			)
+			substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+			tw := newTrackWriter(BandwidthProbePath, tt.trackName, substr, func() (transport.SendStream, error) {
+				mu.Lock()
+				opened++
+				mu.Unlock()
+				return &FakeQUICSendStream{WriteFunc: func(p []byte) (int, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					// Fail once enough data was sent, ending the probe.
+					if written >= 4*bandwidthProbeGroupFrames*bandwidthProbeFrameSize {
+						return 0, errors.New("connection lost")
+					}
+					written += len(p)
+					return len(p), nil
+				}}, nil
+			}, func() {})
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				BandwidthProbeHandler.ServeTrack(tw)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("handler did not return")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !tt.wantOpened {
+				assert.Zero(t, opened)
+				return
+			}
+			assert.GreaterOrEqual(t, opened, 4, "the probe must be sent in groups")
+			assert.GreaterOrEqual(t, written, 4*bandwidthProbeGroupFrames*bandwidthProbeFrameSize)
+		})
+	}
+}
+
+func TestSession_ProbeBandwidth(t *testing.T) {
+	session, _, _ := newBundleTestSession(t, 0)
+
+	var group bytes.Buffer
+	gw := newGroupWriter(&FakeQUICSendStream{WriteFunc: group.Write}, 0, nil)
+	frame := NewFrame(bandwidthProbeFrameSize)
+	_, _ = frame.Write(make([]byte, bandwidthProbeFrameSize))
+	for range 8 {
+		require.NoError(t, gw.WriteFrame(frame))
+	}
+
+	// Deliver one group to the probe subscription once it is made.
+	go func() {
+		for {
+			session.trackReaderMapLocker.RLock()
+			var tr *TrackReader
+			for _, r := range session.trackReaders {
+				tr = r
+			}
+			session.trackReaderMapLocker.RUnlock()
+			if tr != nil {
+				tr.enqueueGroup(0, &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(group.Bytes()).Read})
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	bitrate, err := session.ProbeBandwidth(t.Context(), 100*time.Millisecond)
+	require.NoError(t, err)
+	assert.NotZero(t, bitrate)
+	assert.Equal(t, bitrate, session.Stats().EstimatedBitrate)
+
+	session.trackReaderMapLocker.RLock()
+	defer session.trackReaderMapLocker.RUnlock()
+	assert.Empty(t, session.trackReaders, "the probe subscription must be closed")
+}
+
+func TestSession_ProbeBandwidth_NoData(t *testing.T) {
+	session, _, _ := newBundleTestSession(t, 0)
+
+	_, err := session.ProbeBandwidth(t.Context(), 20*time.Millisecond)
+	assert.ErrorIs(t, err, ErrNoProbeData)
+
+	_, err = session.ProbeBandwidth(t.Context(), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,259 @@
+package moqt
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultBufferLatency is the default BufferConfig.Latency.
+const defaultBufferLatency = 100 * time.Millisecond
+
+// BufferConfig configures a BufferedTrackReader.
+type BufferConfig struct {
+	// Latency is how long a group is held, from its arrival, for the groups
+	// before it. Groups still missing then are skipped, and dropped as late
+	// if they arrive afterwards. If zero, defaults to 100ms.
+	Latency time.Duration
+
+	// MaxGroups, if positive, bounds the groups held: once more are
+	// buffered, the first one is returned without waiting for the groups
+	// before it.
+	MaxGroups int
+}
+
+func (c *BufferConfig) latency() time.Duration {
+	if c != nil && c.Latency > 0 {
+		return c.Latency
+	}
+	return defaultBufferLatency
+}
+
+func (c *BufferConfig) maxGroups() int {
+	if c != nil {
+		return c.MaxGroups
+	}
+	return 0
+}
+
+// BufferStats describes the state of a BufferedTrackReader.
+type BufferStats struct {
+	// LiveEdge is the highest group sequence received, the estimate of the
+	// group the publisher is writing.
+	LiveEdge GroupSequence
+
+	// Next is the sequence of the group AcceptGroup returns next if it
+	// arrives in time, or MinGroupSequence before the first group.
+	Next GroupSequence
+
+	// Buffered is the number of groups held.
+	Buffered int
+
+	// Skipped is the number of group sequences skipped because they were
+	// missing, and Late the number of groups dropped because they arrived
+	// after being skipped.
+	Skipped uint64
+	Late    uint64
+}
+
+// BufferedTrackReader is a jitter buffer for a TrackReader. Groups are sent
+// on streams of their own and may arrive out of order; a BufferedTrackReader
+// holds them for up to a target latency and returns them in ascending order
+// of sequence. A missing group is skipped once the group after it has
+// waited for the latency, and dropped as late if it arrives afterwards,
+// which counts it in the DropStats of the TrackReader as DropReasonStale.
+// The subgroups of a group are returned in order of SubgroupID.
+//
+// All methods are safe for concurrent use.
+type BufferedTrackReader struct {
+	reader    *TrackReader
+	latency   time.Duration
+	maxGroups int
+
+	mu       sync.Mutex
+	buffered []bufferedGroup // by sequence and subgroup
+	changed  chan struct{}
+
+	// last is the sequence of the group returned last, once started.
+	last    GroupSequence
+	started bool
+
+	liveEdge GroupSequence
+	skipped  uint64
+	late     uint64
+
+	// err is the error of the TrackReader once it ended.
+	err error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type bufferedGroup struct {
+	group   *GroupReader
+	arrived time.Time
+}
+
+// NewBufferedTrackReader returns a BufferedTrackReader accepting the groups
+// of tr. The BufferedTrackReader owns tr: its groups must not be accepted
+// from tr directly, and Close closes it. A nil config uses the defaults.
+func NewBufferedTrackReader(tr *TrackReader, config *BufferConfig) *BufferedTrackReader {
+	ctx, cancel := context.WithCancel(tr.Context())
+	b := &BufferedTrackReader{
+		reader:    tr,
+		latency:   config.latency(),
+		maxGroups: config.maxGroups(),
+		changed:   make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	goTask(tr.tasks, "buffered track filler", b.fill, cancel)
+	return b
+}
+
+// TrackReader returns the TrackReader whose groups are buffered.
+func (b *BufferedTrackReader) TrackReader() *TrackReader {
+	return b.reader
+}
+
+// LiveEdge returns the highest group sequence received.
+func (b *BufferedTrackReader) LiveEdge() GroupSequence {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.liveEdge
+}
+
+// Stats returns the state of the buffer.
+func (b *BufferedTrackReader) Stats() BufferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BufferStats{
+		LiveEdge: b.liveEdge,
+		Buffered: len(b.buffered),
+		Skipped:  b.skipped,
+		Late:     b.late,
+	}
+	if b.started {
+		stats.Next = b.last + 1
+	}
+	return stats
+}
+
+// AcceptGroup returns the next group in order of sequence. It blocks until
+// the next group arrives, or until the first group held has waited for the
+// latency, or ctx is done. Once the TrackReader ends, the groups held are
+// returned without waiting, then the error of the TrackReader.
+func (b *BufferedTrackReader) AcceptGroup(ctx context.Context) (*GroupReader, error) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		b.mu.Lock()
+		var wait <-chan time.Time
+		if len(b.buffered) > 0 {
+			head := b.buffered[0]
+			seq := head.group.GroupSequence()
+			delay := time.Until(head.arrived.Add(b.latency))
+			inOrder := b.started && seq <= b.last+1
+			if inOrder || delay <= 0 || b.err != nil || (b.maxGroups > 0 && len(b.buffered) > b.maxGroups) {
+				b.buffered = b.buffered[1:]
+				if b.started && seq > b.last+1 {
+					b.skipped += uint64(seq - b.last - 1)
+				}
+				b.last, b.started = seq, true
+				b.mu.Unlock()
+				return head.group, nil
+			}
+			if timer == nil {
+				timer = time.NewTimer(delay)
+			} else {
+				timer.Reset(delay)
+			}
+			wait = timer.C
+		} else if b.err != nil {
+			err := b.err
+			b.mu.Unlock()
+			return nil, err
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		case <-wait:
+		}
+	}
+}
+
+// Close cancels the groups held and closes the TrackReader.
+func (b *BufferedTrackReader) Close() error {
+	b.cancel()
+	<-b.done
+
+	b.mu.Lock()
+	buffered := b.buffered
+	b.buffered = nil
+	b.mu.Unlock()
+
+	for _, g := range buffered {
+		g.group.CancelRead(SubscribeCanceledErrorCode)
+	}
+	return b.reader.Close()
+}
+
+// fill accepts the groups of the TrackReader into the buffer until it ends
+// or the BufferedTrackReader is closed.
+func (b *BufferedTrackReader) fill() {
+	defer close(b.done)
+
+	for {
+		group, err := b.reader.AcceptGroup(b.ctx)
+		if err != nil {
+			if b.ctx.Err() != nil && b.reader.Context().Err() == nil {
+				err = ErrClosedTrack
+			}
+			b.mu.Lock()
+			b.err = err
+			b.notifyLocked()
+			b.mu.Unlock()
+			return
+		}
+
+		seq := group.GroupSequence()
+		b.mu.Lock()
+		b.liveEdge = max(b.liveEdge, seq)
+		if b.started && seq < b.last {
+			b.late++
+			b.mu.Unlock()
+			group.CancelRead(ExpiredGroupErrorCode)
+			b.reader.drops.record(DropEvent{Reason: DropReasonStale, StartGroup: seq, EndGroup: seq})
+			continue
+		}
+		i, _ := slices.BinarySearchFunc(b.buffered, group, func(g bufferedGroup, target *GroupReader) int {
+			return cmp.Or(
+				cmp.Compare(g.group.GroupSequence(), target.GroupSequence()),
+				cmp.Compare(g.group.subgroup, target.subgroup),
+			)
+		})
+		b.buffered = slices.Insert(b.buffered, i, bufferedGroup{group: group, arrived: time.Now()})
+		b.notifyLocked()
+		b.mu.Unlock()
+	}
+}
+
+// notifyLocked wakes up the AcceptGroup calls waiting while b.mu is held.
+func (b *BufferedTrackReader) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,97 @@
+package moqt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedTrackReader_Reorders(t *testing.T) {
+	tests := map[string]struct {
+		arrivals []GroupSequence
+		want     []GroupSequence
+	}{
+		"in order":     {arrivals: []GroupSequence{1, 2, 3}, want: []GroupSequence{1, 2, 3}},
+		"out of order": {arrivals: []GroupSequence{3, 1, 2}, want: []GroupSequence{1, 2, 3}},
+		"with gap":     {arrivals: []GroupSequence{4, 1}, want: []GroupSequence{1, 4}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader, _ := newTestTrackReader(t)
+			b := NewBufferedTrackReader(reader, &BufferConfig{Latency: 50 * time.Millisecond})
+			defer b.Close()
+
+			for _, seq := range tt.arrivals {
+				reader.enqueueGroup(seq, &FakeQUICReceiveStream{})
+			}
+			var got []GroupSequence
+			for range tt.want {
+				gr, err := b.AcceptGroup(t.Context())
+				require.NoError(t, err)
+				got = append(got, gr.GroupSequence())
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want[len(tt.want)-1], b.LiveEdge())
+		})
+	}
+}
+
+func TestBufferedTrackReader_DropsLateGroups(t *testing.T) {
+	reader, _ := newTestTrackReader(t)
+	b := NewBufferedTrackReader(reader, &BufferConfig{Latency: 20 * time.Millisecond})
+	defer b.Close()
+
+	reader.enqueueGroup(1, &FakeQUICReceiveStream{})
+	gr, err := b.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(1), gr.GroupSequence())
+
+	// Group 3 waits for group 2 for the latency, then skips it.
+	start := time.Now()
+	reader.enqueueGroup(3, &FakeQUICReceiveStream{})
+	gr, err = b.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(3), gr.GroupSequence())
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	reader.enqueueGroup(2, &FakeQUICReceiveStream{})
+	require.Eventually(t, func() bool { return b.Stats().Late == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, BufferStats{LiveEdge: 3, Next: 4, Skipped: 1, Late: 1}, b.Stats())
+	assert.Equal(t, uint64(1), reader.DropStats().Groups[DropReasonStale])
+
+	// A following group arrives in order and is returned at once.
+	reader.enqueueGroup(4, &FakeQUICReceiveStream{})
+	start = time.Now()
+	gr, err = b.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(4), gr.GroupSequence())
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestBufferedTrackReader_MaxGroups(t *testing.T) {
+	reader, _ := newTestTrackReader(t)
+	b := NewBufferedTrackReader(reader, &BufferConfig{Latency: time.Hour, MaxGroups: 1})
+	defer b.Close()
+
+	reader.enqueueGroup(5, &FakeQUICReceiveStream{})
+	reader.enqueueGroup(7, &FakeQUICReceiveStream{})
+	gr, err := b.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(5), gr.GroupSequence())
+}
+
+func TestBufferedTrackReader_Close(t *testing.T) {
+	reader, _ := newTestTrackReader(t)
+	b := NewBufferedTrackReader(reader, &BufferConfig{Latency: time.Hour})
+
+	reader.enqueueGroup(1, &FakeQUICReceiveStream{})
+	require.Eventually(t, func() bool { return b.Stats().Buffered == 1 }, time.Second, time.Millisecond)
+
+	require.NoError(t, b.Close())
+	_, err := b.AcceptGroup(t.Context())
+	assert.ErrorIs(t, err, ErrClosedTrack)
+	assert.Error(t, reader.Context().Err(), "Close must close the track reader")
+}
@@ -0,0 +1,145 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCertReloadInterval is the interval at which ListenAndServeTLS checks
+// its certificate files for changes.
+const defaultCertReloadInterval = 10 * time.Second
+
+// CertReloader serves a certificate loaded from PEM files and reloads it when
+// the files change, so that certificates can be rotated without restarting
+// the server. Use its GetCertificate as the GetCertificate of a tls.Config:
+// new connections get the current certificate, while established sessions
+// continue with the one they were set up with.
+//
+//	reloader, err := moqt.NewCertReloader("cert.pem", "key.pem")
+//	if err != nil {
+//	    return err
+//	}
+//	go reloader.Watch(ctx, time.Minute)
+//
+//	server := &moqt.Server{
+//	    TLSConfig: &tls.Config{GetCertificate: reloader.GetCertificate},
+//	}
+type CertReloader struct {
+	// OnReload, if set, is called after each reload triggered by Watch with
+	// its error. After a failed reload, the previous certificate is kept.
+	// It must be set before Watch is called.
+	OnReload func(err error)
+
+	certFile, keyFile string
+
+	cert atomic.Pointer[tls.Certificate]
+
+	mu sync.Mutex
+	// stamp identifies the versions of the files last loaded.
+	stamp certStamp
+}
+
+// certStamp is the modification times and sizes of a certificate and its key.
+type certStamp struct {
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+}
+
+// NewCertReloader returns a CertReloader serving the certificate of certFile
+// and keyFile. It returns an error if they cannot be loaded.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. It implements the
+// GetCertificate callback of tls.Config.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Reload loads the certificate files again. If they cannot be loaded, the
+// current certificate is kept and an error is returned.
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+	return r.load(stamp)
+}
+
+// Watch checks the certificate files for changes every interval, and
+// reloads them when their modification time or size changes, until ctx is
+// canceled. A change that cannot be loaded, such as a certificate written
+// before its key, is retried on the next change.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		r.mu.Lock()
+		stamp, err := r.statFiles()
+		if err == nil && stamp == r.stamp {
+			r.mu.Unlock()
+			continue
+		}
+		if err == nil {
+			err = r.load(stamp)
+			// A change is only tried once.
+			r.stamp = stamp
+		}
+		r.mu.Unlock()
+
+		if r.OnReload != nil {
+			r.OnReload(err)
+		}
+	}
+}
+
+// load loads the certificate files, whose versions are stamp. r.mu must be
+// held.
+func (r *CertReloader) load(stamp certStamp) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load X509 key pair (cert=%s, key=%s): %w", r.certFile, r.keyFile, err)
+	}
+	r.cert.Store(&cert)
+	r.stamp = stamp
+	return nil
+}
+
+// statFiles returns the current versions of the certificate files. r.mu must
+// be held.
+func (r *CertReloader) statFiles() (certStamp, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return certStamp{}, fmt.Errorf("failed to load X509 key pair (cert=%s, key=%s): %w", r.certFile, r.keyFile, err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return certStamp{}, fmt.Errorf("failed to load X509 key pair (cert=%s, key=%s): %w", r.certFile, r.keyFile, err)
+	}
+	return certStamp{
+		certMod:  certInfo.ModTime(),
+		keyMod:   keyInfo.ModTime(),
+		certSize: certInfo.Size(),
+		keySize:  keyInfo.Size(),
+	}, nil
+}
@@ -0,0 +1,199 @@
+package moqt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a self-signed certificate for name and its key to
+// certFile and keyFile, with a modification time of mod.
+func writeTestCert(t *testing.T, certFile, keyFile, name string, mod time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, mod, mod))
+	require.NoError(t, os.Chtimes(keyFile, mod, mod))
+}
+
+// certName returns the common name of the certificate served by r.
+func certName(t *testing.T, r *CertReloader) string {
+	t.Helper()
+
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestNewCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "a.example.com", time.Now())
+	garbage := filepath.Join(dir, "garbage.pem")
+	require.NoError(t, os.WriteFile(garbage, []byte("garbage"), 0o600))
+
+	tests := map[string]struct {
+		certFile, keyFile string
+		wantErr           bool
+	}{
+		"valid": {
+			certFile: certFile,
+			keyFile:  keyFile,
+		},
+		"missing key": {
+			certFile: certFile,
+			keyFile:  filepath.Join(dir, "missing.pem"),
+			wantErr:  true,
+		},
+		"invalid certificate": {
+			certFile: garbage,
+			keyFile:  keyFile,
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewCertReloader(tt.certFile, tt.keyFile)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "failed to load X509 key pair")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "a.example.com", certName(t, r))
+		})
+	}
+}
+
+func TestCertReloader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	mod := time.Now().Add(-time.Minute)
+	writeTestCert(t, certFile, keyFile, "a.example.com", mod)
+
+	r, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	r.OnReload = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	reloads := func() []error {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]error(nil), errs...)
+	}
+	go r.Watch(t.Context(), 5*time.Millisecond)
+
+	// Unchanged files are not reloaded.
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, reloads())
+
+	writeTestCert(t, certFile, keyFile, "b.example.com", mod.Add(time.Second))
+	require.Eventually(t, func() bool {
+		return certName(t, r) == "b.example.com"
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []error{nil}, reloads())
+
+	// A broken certificate keeps the previous one.
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	require.NoError(t, os.Chtimes(certFile, mod.Add(2*time.Second), mod.Add(2*time.Second)))
+	require.Eventually(t, func() bool {
+		return len(reloads()) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Error(t, reloads()[1])
+	assert.Equal(t, "b.example.com", certName(t, r))
+
+	// It is not retried until the files change again.
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(t, reloads(), 2)
+}
+
+func TestServer_CertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "a.example.com", time.Now())
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	addr, listen := listenLoopback(t)
+	server := &Server{
+		Addr:       addr,
+		TLSConfig:  &tls.Config{GetCertificate: reloader.GetCertificate},
+		ListenFunc: listen,
+		Handler: HandleFunc(func(sess *Session) {
+			<-sess.Context().Done()
+		}),
+	}
+	go func() {
+		_ = server.ListenAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	dial := func() *Session {
+		dialer := &Dialer{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+		var sess *Session
+		require.Eventually(t, func() bool {
+			ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+			defer cancel()
+			sess, err = dialer.Dial(ctx, "moqt://"+addr, nil)
+			return err == nil
+		}, 5*time.Second, 20*time.Millisecond)
+		t.Cleanup(func() {
+			_ = sess.CloseWithError(NoError, "")
+		})
+		return sess
+	}
+	peerName := func(sess *Session) string {
+		return sess.ConnectionState().TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	before := dial()
+	assert.Equal(t, "a.example.com", peerName(before))
+
+	writeTestCert(t, certFile, keyFile, "b.example.com", time.Now().Add(time.Second))
+	require.NoError(t, reloader.Reload())
+
+	// New connections get the new certificate; established sessions go on.
+	after := dial()
+	assert.Equal(t, "b.example.com", peerName(after))
+	assert.NoError(t, before.Context().Err())
+}
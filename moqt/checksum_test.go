@@ -0,0 +1,206 @@
+package moqt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checksumBlockSize is the length of an extension header block holding only
+// a checksum: the count, type and length of the header, and its value.
+const checksumBlockSize = 3 + checksumSize
+
+// writeChecksumGroup writes payloads through a GroupWriter with the given
+// checksum mode and returns the encoded group stream.
+func writeChecksumGroup(t *testing.T, mode ChecksumMode, payloads ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	stream := &FakeQUICSendStream{WriteFunc: buf.Write}
+	group := newGroupWriter(stream, GroupSequence(1), nil)
+	group.checksum = &groupChecksum{mode: mode}
+
+	for _, payload := range payloads {
+		frame := NewFrame(len(payload))
+		_, _ = frame.Write([]byte(payload))
+		require.NoError(t, group.WriteFrame(frame))
+	}
+	return buf.Bytes()
+}
+
+func newChecksumGroupReader(data []byte, mode ChecksumMode, policy ChecksumPolicy) (*GroupReader, *FakeQUICReceiveStream) {
+	stream := &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}
+	group := newGroupReader(GroupSequence(1), stream, nil)
+	group.checksum = &groupChecksum{mode: mode, policy: policy}
+	return group, stream
+}
+
+func readAllFrames(group *GroupReader) ([]string, []error) {
+	var (
+		payloads []string
+		errs     []error
+	)
+	frame := NewFrame(0)
+	for {
+		err := group.ReadFrame(frame)
+		if err == io.EOF {
+			return payloads, errs
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if err == ErrChecksumMismatch {
+				continue
+			}
+			return payloads, errs
+		}
+		payloads = append(payloads, string(frame.Body()))
+	}
+}
+
+func TestChecksum_RoundTrip(t *testing.T) {
+	for _, mode := range []ChecksumMode{ChecksumFrame, ChecksumGroup} {
+		t.Run(mode.String(), func(t *testing.T) {
+			data := writeChecksumGroup(t, mode, "first", "", "third")
+			group, _ := newChecksumGroupReader(data, mode, ChecksumPolicyError)
+
+			payloads, errs := readAllFrames(group)
+			assert.Empty(t, errs)
+			assert.Equal(t, []string{"first", "", "third"}, payloads)
+		})
+	}
+}
+
+func TestChecksum_ReaderWithoutMode(t *testing.T) {
+	data := writeChecksumGroup(t, ChecksumFrame, "abc")
+
+	// The checksum is an extension header like any other.
+	group := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil)
+	group.SetExtensionHeaders(true)
+	frame := NewFrame(0)
+	require.NoError(t, group.ReadFrame(frame))
+	assert.Equal(t, "abc", string(frame.Body()))
+	value, ok := ChecksumFrameHeader.Get(frame.Extensions())
+	assert.True(t, ok)
+	assert.Len(t, value, checksumSize)
+
+	// Without extension headers, the block is part of the payload.
+	group = newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil)
+	require.NoError(t, group.ReadFrame(frame))
+	assert.Equal(t, checksumBlockSize+3, frame.Len())
+}
+
+func TestChecksum_Mismatch(t *testing.T) {
+	tests := map[string]struct {
+		mode     ChecksumMode
+		policy   ChecksumPolicy
+		payloads []string
+		errs     int
+		canceled bool
+	}{
+		"frame error":  {mode: ChecksumFrame, policy: ChecksumPolicyError, payloads: []string{"one", "three"}, errs: 1},
+		"frame skip":   {mode: ChecksumFrame, policy: ChecksumPolicySkip, payloads: []string{"one", "three"}},
+		"group error":  {mode: ChecksumGroup, policy: ChecksumPolicyError, payloads: []string{"one", "three"}, errs: 1},
+		"group skip":   {mode: ChecksumGroup, policy: ChecksumPolicySkip, payloads: []string{"one", "three"}},
+		"frame cancel": {mode: ChecksumFrame, policy: ChecksumPolicyCancel, payloads: []string{"one"}, errs: 1, canceled: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := writeChecksumGroup(t, tt.mode, "one", "two", "three")
+			// Corrupt the payload of the second frame: the first frame
+			// precedes it, followed by its length prefix and checksum block.
+			data[1+checksumBlockSize+3+1+checksumBlockSize] ^= 0xff
+
+			group, stream := newChecksumGroupReader(data, tt.mode, tt.policy)
+			var canceled bool
+			stream.CancelReadFunc = func(code transport.StreamErrorCode) {
+				canceled = true
+				assert.Equal(t, transport.StreamErrorCode(InternalGroupErrorCode), code)
+			}
+			if tt.canceled {
+				// Stop reading after the cancellation.
+				frame := NewFrame(0)
+				require.NoError(t, group.ReadFrame(frame))
+				assert.Equal(t, "one", string(frame.Body()))
+				assert.ErrorIs(t, group.ReadFrame(frame), ErrChecksumMismatch)
+				assert.True(t, canceled)
+				return
+			}
+
+			payloads, errs := readAllFrames(group)
+			assert.Equal(t, tt.payloads, payloads)
+			assert.Len(t, errs, tt.errs)
+			assert.False(t, canceled)
+		})
+	}
+}
+
+func TestChecksum_GroupDetectsMissingFrame(t *testing.T) {
+	whole := writeChecksumGroup(t, ChecksumGroup, "one", "two")
+	// Drop the first frame (1-byte length, checksum block, 3-byte payload).
+	data := whole[1+checksumBlockSize+3:]
+
+	group, _ := newChecksumGroupReader(data, ChecksumGroup, ChecksumPolicyError)
+	err := group.ReadFrame(NewFrame(0))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	// The same cut is invisible to per-frame checksums.
+	whole = writeChecksumGroup(t, ChecksumFrame, "one", "two")
+	group, _ = newChecksumGroupReader(whole[1+checksumBlockSize+3:], ChecksumFrame, ChecksumPolicyError)
+	assert.NoError(t, group.ReadFrame(NewFrame(0)))
+}
+
+func TestChecksum_MissingHeader(t *testing.T) {
+	// An empty extension header block, then the payload.
+	group, _ := newChecksumGroupReader([]byte{0x02, 0x00, 0xaa}, ChecksumFrame, ChecksumPolicyError)
+
+	assert.ErrorIs(t, group.ReadFrame(NewFrame(0)), ErrChecksumMismatch)
+}
+
+func TestChecksumMode_String(t *testing.T) {
+	assert.Equal(t, "none", ChecksumNone.String())
+	assert.Equal(t, "frame", ChecksumFrame.String())
+	assert.Equal(t, "group", ChecksumGroup.String())
+	assert.Equal(t, "unknown", ChecksumMode(9).String())
+}
+
+func TestTrackWriter_SetChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+	}, func() {})
+	defer writer.Close()
+
+	writer.SetChecksum(ChecksumGroup)
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+	require.NotNil(t, group.checksum)
+	assert.Equal(t, ChecksumGroup, group.checksum.mode)
+
+	// The group header announces the extension header block holding the
+	// checksum.
+	r := bytes.NewReader(buf.Bytes())
+	var st message.StreamType
+	require.NoError(t, st.Decode(r))
+	gm := message.GroupMessage{Version: message.VersionLite04Ext}
+	require.NoError(t, gm.Decode(r))
+	assert.Equal(t, message.GroupFlagExtensionHeaders, gm.Flags)
+}
+
+func TestTrackReader_SetChecksum(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+	receiver.SetChecksum(ChecksumFrame, ChecksumPolicySkip)
+
+	receiver.enqueueGroup(GroupSequence(1), &FakeQUICReceiveStream{})
+	group, err := receiver.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	require.NotNil(t, group.checksum)
+	assert.Equal(t, ChecksumFrame, group.checksum.mode)
+	assert.Equal(t, ChecksumPolicySkip, group.checksum.policy)
+}
@@ -0,0 +1,728 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientTransport selects how a Client reaches an https URL.
+// URLs with the moqt scheme always use native QUIC.
+type ClientTransport uint8
+
+const (
+	// TransportAuto offers both the MOQ versions and h3 in a single QUIC
+	// handshake and lets the server choose: the session runs over native
+	// QUIC if it selects a MOQ version, and falls back to WebTransport on
+	// the same connection if it selects h3. A DialQUICFunc must then return
+	// connections from WrapQUICConn. URLs with a path other than "/", and
+	// Dialers with an AuthToken, always use WebTransport, because native
+	// QUIC connections carry neither. So do proxied sessions, since only
+	// WebTransport sessions go through a proxy.
+	TransportAuto ClientTransport = iota
+	// TransportQUIC uses native QUIC only.
+	TransportQUIC
+	// TransportWebTransport uses WebTransport only.
+	TransportWebTransport
+)
+
+const (
+	defaultReconnectInitialBackoff = 500 * time.Millisecond
+	defaultReconnectMaxBackoff     = 30 * time.Second
+	defaultReconnectMultiplier     = 2.0
+)
+
+// ReconnectPolicy configures how a Client re-establishes a lost session.
+// Zero fields select the defaults.
+type ReconnectPolicy struct {
+	// InitialBackoff is the delay before the first reconnection attempt.
+	// Defaults to 500ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. Defaults to 30s.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the delay grows after each failed
+	// attempt. Defaults to 2.
+	Multiplier float64
+
+	// MaxAttempts is the number of attempts after which the Client gives up.
+	// Zero means unlimited.
+	MaxAttempts int
+}
+
+// backoff returns the delay before the given attempt, starting at 1.
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = defaultReconnectInitialBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultReconnectMaxBackoff
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultReconnectMultiplier
+	}
+
+	delay := float64(initial)
+	for range attempt - 1 {
+		delay *= multiplier
+		if delay >= float64(maxBackoff) {
+			return maxBackoff
+		}
+	}
+	return min(time.Duration(delay), maxBackoff)
+}
+
+// Client is a MOQ client that keeps a session to one server.
+// It selects native QUIC or WebTransport from the URL and, with a
+// ReconnectPolicy, re-establishes the session after it is lost and
+// resubscribes the tracks obtained through Client.Subscribe. When the server
+// sends GOAWAY, the Client migrates to the new session URI and resubscribes
+// the tracks there before closing the old session.
+//
+// A Client is used for a single Dial; its methods are safe for concurrent use.
+type Client struct {
+	// Dialer establishes the connections. If nil, a zero Dialer is used.
+	Dialer *Dialer
+
+	// TrackMux serves announcements and subscriptions from the server.
+	// If nil, DefaultMux is used.
+	TrackMux *TrackMux
+
+	// Transport selects the transport for https URLs.
+	Transport ClientTransport
+
+	// Endpoints, if set, races connections to several endpoints of the
+	// server, listed or resolved from the host of the URL, and keeps the
+	// first session established. If nil, the host of the URL is dialed,
+	// unless Resolver is set.
+	Endpoints *EndpointPolicy
+
+	// Resolver, if set, discovers the endpoints of the server, which are
+	// raced as with an EndpointPolicy without Addrs, such as from DNS
+	// records with an HTTPSResolver or SRVResolver.
+	Resolver Resolver
+
+	// Reconnect enables automatic reconnection. If nil, the Client does not
+	// reconnect and its tracks end with the session.
+	Reconnect *ReconnectPolicy
+
+	// OnDisconnect, if set, is called with the cause when the session is lost
+	// and a reconnection is about to be attempted.
+	OnDisconnect func(err error)
+
+	// OnReconnect, if set, is called with the new session once it has been
+	// established and the active tracks have been resubscribed, after a
+	// reconnection as well as after a GOAWAY migration.
+	OnReconnect func(sess *Session)
+
+	// OnGoaway, if set, is called with the new session URI when the server
+	// sends GOAWAY, before the Client migrates. Returning false vetoes the
+	// migration: the Client keeps the current session until the server
+	// closes it and then follows the ReconnectPolicy, if any.
+	// An empty or relative URI is resolved against the current URL.
+	OnGoaway func(newSessionURI string) bool
+
+	mu     sync.Mutex
+	url    *url.URL
+	sess   *Session
+	tracks map[*ClientTrack]struct{}
+
+	migrating atomic.Bool
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	// sessionCache holds the TLS sessions resumed with 0-RTT across
+	// reconnections if the Dialer enables it without a cache of its own.
+	sessionCache tls.ClientSessionCache
+
+	// tasks owns the supervision loop.
+	tasks taskGroup
+}
+
+// Dial connects to the server at urlStr and returns the session.
+// With a ReconnectPolicy, the Client keeps reconnecting to the same URL,
+// or to the URL it last migrated to, until Close is called or the policy
+// gives up.
+func (c *Client) Dial(ctx context.Context, urlStr string) (*Session, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.url != nil {
+		c.mu.Unlock()
+		return nil, errors.New("moqt: client already dialed")
+	}
+	c.url = u
+	c.tracks = make(map[*ClientTrack]struct{})
+	c.ctx, c.cancel = context.WithCancelCause(context.Background())
+	c.tasks.fail = c.fail
+	c.mu.Unlock()
+
+	sess, goaway, err := c.dial(ctx, u)
+	if err != nil {
+		c.mu.Lock()
+		c.url = nil
+		c.mu.Unlock()
+		c.cancel(err)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.sess = sess
+	c.mu.Unlock()
+
+	c.tasks.Go("client supervisor", func() {
+		c.supervise(sess, goaway)
+	}, nil)
+
+	return sess, nil
+}
+
+// dial establishes a session to u with the configured transport. The
+// returned channel receives the new session URI if the server sends GOAWAY.
+func (c *Client) dial(ctx context.Context, u *url.URL) (*Session, <-chan string, error) {
+	d := &Dialer{}
+	if c.Dialer != nil {
+		*d = *c.Dialer
+	}
+	if d.Enable0RTT && (d.TLSConfig == nil || d.TLSConfig.ClientSessionCache == nil) {
+		// Share the tickets across reconnections in a cache of the Client,
+		// leaving the TLS config of the Dialer untouched.
+		if d.TLSConfig == nil {
+			d.TLSConfig = &tls.Config{}
+		} else {
+			d.TLSConfig = d.TLSConfig.Clone()
+		}
+		c.mu.Lock()
+		if c.sessionCache == nil {
+			c.sessionCache = tls.NewLRUClientSessionCache(0)
+		}
+		d.TLSConfig.ClientSessionCache = c.sessionCache
+		c.mu.Unlock()
+	}
+	// Resume the previous session, so that a standby that took over from
+	// its server accepts it without authorizing it again.
+	if prev := c.Session(); prev != nil && d.ResumeToken == "" {
+		d.ResumeToken = prev.ResumeToken()
+	}
+
+	goaway := make(chan string, 1)
+	onGoaway := d.OnGoaway
+	d.OnGoaway = func(newSessionURI string) {
+		if onGoaway != nil {
+			onGoaway(newSessionURI)
+		}
+		select {
+		case goaway <- newSessionURI:
+		default:
+		}
+	}
+
+	sess, err := c.dialTransport(ctx, d, u)
+	return sess, goaway, err
+}
+
+// dialTransport establishes a session to u with d over the configured
+// transport, racing the endpoints of the server if any.
+func (c *Client) dialTransport(ctx context.Context, d *Dialer, u *url.URL) (*Session, error) {
+	if c.Endpoints == nil && c.Resolver == nil {
+		return c.dialEndpoint(ctx, d, u)
+	}
+	if u.Scheme != "moqt" && u.Scheme != "https" {
+		return nil, ErrInvalidScheme
+	}
+	policy := c.Endpoints
+	if policy == nil {
+		policy = &EndpointPolicy{}
+	}
+	endpoints, err := policy.endpoints(ctx, u, c.Resolver)
+	if err != nil {
+		return nil, err
+	}
+	return raceDial(ctx, endpoints, policy.attemptDelay(), func(ctx context.Context, endpoint string) (*Session, error) {
+		ed := *d
+		ed.endpoint = endpoint
+		return c.dialEndpoint(ctx, &ed, u)
+	})
+}
+
+// dialEndpoint establishes a session to u with d over the configured
+// transport.
+func (c *Client) dialEndpoint(ctx context.Context, d *Dialer, u *url.URL) (*Session, error) {
+	switch u.Scheme {
+	case "moqt":
+		return d.DialQUIC(ctx, u.Host, c.TrackMux)
+	case "https":
+	default:
+		return nil, ErrInvalidScheme
+	}
+
+	switch c.Transport {
+	case TransportQUIC:
+		return d.DialQUIC(ctx, u.Host, c.TrackMux)
+	case TransportAuto:
+		// Native QUIC carries neither a path nor session tokens.
+		if (u.Path != "" && u.Path != "/") || d.AuthToken != "" || d.ResumeToken != "" {
+			break
+		}
+		// Nor is it proxied.
+		if proxy, _ := d.proxyURL("https://" + u.Host + u.Path); proxy != nil {
+			break
+		}
+		return d.dialAuto(ctx, u.Host, u.Path, c.TrackMux)
+	}
+	return d.DialWebTransport(ctx, u.Host, u.Path, c.TrackMux)
+}
+
+// Session returns the current session, or nil before Dial succeeds.
+// After a reconnection it returns the new session.
+func (c *Client) Session() *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sess
+}
+
+// Context returns a context that is canceled when the Client is closed or
+// gives up reconnecting. It is nil before Dial is called.
+func (c *Client) Context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx
+}
+
+// Subscribe subscribes to a track on the current session. Unlike
+// Session.Subscribe, the returned ClientTrack survives reconnections: the
+// Client resubscribes it on every new session, starting after the last
+// group it has accepted.
+func (c *Client) Subscribe(ctx context.Context, path BroadcastPath, name TrackName, config *SubscribeConfig) (*ClientTrack, error) {
+	sess := c.Session()
+	if sess == nil {
+		return nil, errors.New("moqt: client is not connected")
+	}
+
+	reader, err := sess.Subscribe(ctx, path, name, config)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ClientTrack{
+		BroadcastPath: path,
+		TrackName:     name,
+		client:        c,
+		sess:          sess,
+		reader:        reader,
+		changed:       make(chan struct{}),
+	}
+	if config != nil {
+		t.config = *config
+	}
+	t.ctx, t.cancel = context.WithCancelCause(context.Background())
+
+	c.mu.Lock()
+	if err := c.ctx.Err(); err != nil {
+		c.mu.Unlock()
+		_ = reader.Close()
+		return nil, context.Cause(c.ctx)
+	}
+	c.tracks[t] = struct{}{}
+	current := c.sess
+	c.mu.Unlock()
+
+	// The session may have been replaced while subscribing.
+	if current != sess {
+		t.resubscribe(c.ctx, current)
+	}
+
+	return t, nil
+}
+
+// Close closes the session, stops reconnecting and ends all tracks.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.cancel == nil {
+		c.mu.Unlock()
+		return nil
+	}
+	c.cancel(ErrClosedSession)
+	sess := c.sess
+	c.mu.Unlock()
+
+	var err error
+	if sess != nil {
+		err = sess.CloseWithError(NoError, "")
+	}
+	c.tasks.Wait()
+	c.endTracks(ErrClosedSession)
+	return err
+}
+
+// fail stops the Client after one of its tasks failed: it stops
+// reconnecting, closes the session and ends all tracks with err.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	c.cancel(err)
+	sess := c.sess
+	c.mu.Unlock()
+
+	if sess != nil {
+		_ = sess.CloseWithError(InternalSessionErrorCode, "internal error")
+	}
+	c.endTracks(err)
+}
+
+// supervise follows GOAWAY migrations of sess, and reconnects after it ends
+// until the Client is closed or the policy gives up.
+func (c *Client) supervise(sess *Session, goaway <-chan string) {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case uri := <-goaway:
+			goaway = nil
+			if next, nextGoaway, err := c.migrate(sess, uri); err == nil && next != nil {
+				sess, goaway = next, nextGoaway
+			}
+			continue
+		case <-sess.Context().Done():
+		}
+
+		if c.Reconnect == nil {
+			c.endTracks(Cause(sess.Context()))
+			return
+		}
+
+		if c.OnDisconnect != nil {
+			c.OnDisconnect(Cause(sess.Context()))
+		}
+
+		next, nextGoaway, err := c.reconnect()
+		if err != nil {
+			c.cancel(err)
+			c.endTracks(context.Cause(c.ctx))
+			return
+		}
+		sess, goaway = next, nextGoaway
+	}
+}
+
+// reconnect dials the server with backoff and resubscribes the active tracks.
+func (c *Client) reconnect() (*Session, <-chan string, error) {
+	policy := c.Reconnect
+
+	var lastErr error
+	for attempt := 1; policy.MaxAttempts == 0 || attempt <= policy.MaxAttempts; attempt++ {
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return nil, nil, context.Cause(c.ctx)
+		case <-timer.C:
+		}
+
+		u := c.currentURL()
+		sess, goaway, err := c.dial(c.ctx, u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if err := c.adopt(sess, u); err != nil {
+			return nil, nil, err
+		}
+		return sess, goaway, nil
+	}
+
+	return nil, nil, fmt.Errorf("moqt: reconnection failed after %d attempts: %w", policy.MaxAttempts, lastErr)
+}
+
+// migrate moves the Client from sess to the session URI sent with GOAWAY.
+// The active tracks are resubscribed on the new session before sess is
+// closed, so that they continue from the last accepted group. migrate
+// returns a nil session if the application vetoed the migration.
+func (c *Client) migrate(sess *Session, newSessionURI string) (*Session, <-chan string, error) {
+	if c.OnGoaway != nil && !c.OnGoaway(newSessionURI) {
+		return nil, nil, nil
+	}
+
+	u, err := c.currentURL().Parse(newSessionURI)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Tracks whose session ends while migrating wait for the new session.
+	c.migrating.Store(true)
+	defer c.migrating.Store(false)
+
+	next, goaway, err := c.dial(c.ctx, u)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.adopt(next, u); err != nil {
+		return nil, nil, err
+	}
+
+	_ = sess.CloseWithError(NoError, "")
+	return next, goaway, nil
+}
+
+// adopt makes sess, established to u, the current session and resubscribes
+// the active tracks on it. If the Client has been closed meanwhile, sess is
+// closed and the cause is returned.
+func (c *Client) adopt(sess *Session, u *url.URL) error {
+	c.mu.Lock()
+	if c.ctx.Err() != nil {
+		c.mu.Unlock()
+		_ = sess.CloseWithError(NoError, "")
+		return context.Cause(c.ctx)
+	}
+	c.sess = sess
+	c.url = u
+	tracks := make([]*ClientTrack, 0, len(c.tracks))
+	for t := range c.tracks {
+		tracks = append(tracks, t)
+	}
+	c.mu.Unlock()
+
+	for _, t := range tracks {
+		t.resubscribe(c.ctx, sess)
+	}
+
+	if c.OnReconnect != nil {
+		c.OnReconnect(sess)
+	}
+	return nil
+}
+
+// currentURL returns the URL of the current session.
+func (c *Client) currentURL() *url.URL {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.url
+}
+
+// endTracks ends every track of the Client with cause.
+func (c *Client) endTracks(cause error) {
+	c.mu.Lock()
+	tracks := make([]*ClientTrack, 0, len(c.tracks))
+	for t := range c.tracks {
+		tracks = append(tracks, t)
+	}
+	c.mu.Unlock()
+
+	for _, t := range tracks {
+		t.end(cause)
+	}
+}
+
+// removeTrack deregisters t so that it is not resubscribed.
+func (c *Client) removeTrack(t *ClientTrack) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tracks, t)
+}
+
+// recovering reports whether sess has ended and the Client is reconnecting
+// or migrating to a new session.
+func (c *Client) recovering(sess *Session) bool {
+	return (c.Reconnect != nil || c.migrating.Load()) && sess.Context().Err() != nil && c.ctx.Err() == nil
+}
+
+// ClientTrack is a subscription made through a Client. It is resubscribed
+// on every new session after a reconnection, starting after the last group
+// accepted, so that AcceptGroup continues across network interruptions.
+//
+// All methods are safe for concurrent use.
+type ClientTrack struct {
+	// BroadcastPath is the path of the broadcast the track belongs to.
+	BroadcastPath BroadcastPath
+
+	// TrackName is the name of the track within the broadcast.
+	TrackName TrackName
+
+	client *Client
+
+	mu      sync.Mutex
+	sess    *Session
+	reader  *TrackReader
+	config  SubscribeConfig
+	latest  GroupSequence
+	changed chan struct{} // closed when reader is replaced
+
+	// Groups of reader up to skipThrough were already accepted from a
+	// replaced subscription, which kept delivering while reader was set up.
+	skipThrough GroupSequence
+	skipping    bool
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// Reader returns the TrackReader of the current subscription.
+func (t *ClientTrack) Reader() *TrackReader {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reader
+}
+
+// Context returns a context that is canceled when the track ends for good:
+// when it is closed, when the publisher ends it, or when the Client stops
+// reconnecting.
+func (t *ClientTrack) Context() context.Context {
+	return t.ctx
+}
+
+// AcceptGroup blocks until the next group is available. While the Client
+// is reconnecting or migrating, it waits for the track to be resubscribed on
+// the new session instead of failing. Groups already accepted from the
+// previous subscription are not returned again.
+func (t *ClientTrack) AcceptGroup(ctx context.Context) (*GroupReader, error) {
+	for {
+		t.mu.Lock()
+		sess, reader, changed := t.sess, t.reader, t.changed
+		t.mu.Unlock()
+
+		// Stop waiting when the session is lost even if the transport has
+		// not yet ended the subscription stream, or when the track has moved
+		// to a new subscription.
+		acceptCtx, cancel := context.WithCancel(ctx)
+		stop := context.AfterFunc(sess.Context(), cancel)
+		group, err := reader.acceptGroup(acceptCtx, changed)
+		stop()
+		cancel()
+		if err == nil {
+			if t.accepted(reader, group.GroupSequence()) {
+				return group, nil
+			}
+			group.CancelRead(SubscribeCanceledErrorCode)
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if t.ctx.Err() != nil {
+			return nil, context.Cause(t.ctx)
+		}
+		select {
+		case <-changed:
+			continue
+		default:
+		}
+		if !t.client.recovering(sess) {
+			t.end(err)
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.ctx.Done():
+			return nil, context.Cause(t.ctx)
+		case <-changed:
+		}
+	}
+}
+
+// accepted records that the group seq was accepted from reader and reports
+// whether it should be returned, that is, whether it is not a duplicate of a
+// group accepted from the replaced subscription.
+func (t *ClientTrack) accepted(reader *TrackReader, seq GroupSequence) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if reader != t.reader {
+		// The current subscription may deliver this group again.
+		t.skipThrough = max(t.skipThrough, seq)
+		t.skipping = true
+	} else if t.skipping && seq <= t.skipThrough {
+		return false
+	}
+	t.latest = max(t.latest, seq)
+	return true
+}
+
+// Update changes the subscription configuration. The new configuration is
+// also used when the track is resubscribed after a reconnection.
+func (t *ClientTrack) Update(config *SubscribeConfig) error {
+	if config == nil {
+		return errors.New("subscribe config cannot be nil")
+	}
+
+	t.mu.Lock()
+	t.config = *config
+	reader := t.reader
+	t.mu.Unlock()
+
+	return reader.Update(config)
+}
+
+// Close ends the subscription and stops resubscribing it.
+func (t *ClientTrack) Close() error {
+	return t.end(ErrClosedTrack)
+}
+
+// end ends the track with cause once and closes the current reader.
+func (t *ClientTrack) end(cause error) error {
+	t.mu.Lock()
+	if t.ctx.Err() != nil {
+		t.mu.Unlock()
+		return nil
+	}
+	t.cancel(cause)
+	reader := t.reader
+	t.mu.Unlock()
+
+	t.client.removeTrack(t)
+	return reader.Close()
+}
+
+// resubscribe subscribes the track on sess, starting after the last
+// accepted group. If the subscription fails, the track ends.
+func (t *ClientTrack) resubscribe(ctx context.Context, sess *Session) {
+	t.mu.Lock()
+	if t.ctx.Err() != nil || t.sess == sess {
+		t.mu.Unlock()
+		return
+	}
+	config := t.config
+	if t.latest != MinGroupSequence {
+		config.StartGroup = t.latest + 1
+	}
+	t.mu.Unlock()
+
+	if config.EndGroup != MinGroupSequence && config.StartGroup > config.EndGroup {
+		t.end(ErrClosedTrack)
+		return
+	}
+
+	reader, err := sess.Subscribe(ctx, t.BroadcastPath, t.TrackName, &config)
+	if err != nil {
+		t.end(err)
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ctx.Err() != nil {
+		_ = reader.Close()
+		return
+	}
+	t.sess = sess
+	t.reader = reader
+	// Groups accepted since config was taken are also sent by reader.
+	t.skipThrough = t.latest
+	t.skipping = t.latest >= config.StartGroup && t.latest != MinGroupSequence
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
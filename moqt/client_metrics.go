@@ -0,0 +1,46 @@
+package moqt
+
+import "time"
+
+// ClientMetrics receives client-side telemetry from a Dialer and the sessions
+// it establishes, so that player telemetry pipelines can consume the same
+// events from every gomoqt-based application.
+//
+// Methods are called synchronously on the dial and delivery paths and must
+// return quickly. Implementations must be safe for concurrent use.
+// Embed NopClientMetrics to implement only the methods of interest.
+type ClientMetrics interface {
+	// DialStarted is called when a dial attempt begins.
+	// transport is "webtransport" or "quic".
+	DialStarted(transport, addr string)
+
+	// DialFinished is called when a dial attempt ends: once the MOQ session
+	// is set up, or with the error that failed the dial, the version
+	// negotiation or the session setup. A session dialed in 0-RTT is set up
+	// when its handshake completes, after Dial returned. latency is the time
+	// from the start of the attempt until then.
+	DialFinished(transport, addr string, latency time.Duration, err error)
+
+	// FrameReceived is called for every frame read from a subscribed group.
+	// size is the frame payload length in bytes.
+	FrameReceived(path BroadcastPath, name TrackName, size int)
+
+	// GroupGap is called when the publisher reports that a range of groups
+	// of a subscribed track will not be delivered.
+	GroupGap(path BroadcastPath, name TrackName, drop SubscribeDrop)
+
+	// Rebuffer is called when the application reports a playback stall
+	// through TrackReader.ReportRebuffer.
+	Rebuffer(path BroadcastPath, name TrackName, duration time.Duration)
+}
+
+// NopClientMetrics is a ClientMetrics that discards all events.
+type NopClientMetrics struct{}
+
+func (NopClientMetrics) DialStarted(string, string)                        {}
+func (NopClientMetrics) DialFinished(string, string, time.Duration, error) {}
+func (NopClientMetrics) FrameReceived(BroadcastPath, TrackName, int)       {}
+func (NopClientMetrics) GroupGap(BroadcastPath, TrackName, SubscribeDrop)  {}
+func (NopClientMetrics) Rebuffer(BroadcastPath, TrackName, time.Duration)  {}
+
+var _ ClientMetrics = NopClientMetrics{}
@@ -0,0 +1,202 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClientMetrics struct {
+	NopClientMetrics
+
+	mu        sync.Mutex
+	started   []string
+	finished  []error
+	frames    []int
+	gaps      []SubscribeDrop
+	rebuffers []time.Duration
+	gapCh     chan struct{}
+}
+
+func (m *fakeClientMetrics) DialStarted(transport, addr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = append(m.started, transport+" "+addr)
+}
+
+func (m *fakeClientMetrics) DialFinished(transport, addr string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finished = append(m.finished, err)
+}
+
+func (m *fakeClientMetrics) FrameReceived(path BroadcastPath, name TrackName, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.frames = append(m.frames, size)
+}
+
+func (m *fakeClientMetrics) GroupGap(path BroadcastPath, name TrackName, drop SubscribeDrop) {
+	m.mu.Lock()
+	m.gaps = append(m.gaps, drop)
+	m.mu.Unlock()
+	if m.gapCh != nil {
+		close(m.gapCh)
+	}
+}
+
+func (m *fakeClientMetrics) Rebuffer(path BroadcastPath, name TrackName, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rebuffers = append(m.rebuffers, duration)
+}
+
+func TestDialer_Metrics_DialQUIC(t *testing.T) {
+	metrics := &fakeClientMetrics{}
+	dialer := &Dialer{
+		Metrics: metrics,
+		DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+			return &FakeStreamConn{}, nil
+		},
+	}
+
+	sess, err := dialer.DialQUIC(context.Background(), "example.com:9000", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = sess.CloseWithError(NoError, "")
+	})
+
+	assert.Equal(t, []string{"quic example.com:9000"}, metrics.started)
+	assert.Equal(t, []error{nil}, metrics.finished)
+	assert.Equal(t, ClientMetrics(metrics), sess.metrics)
+}
+
+func TestDialer_Metrics_DialQUIC_EarlyData(t *testing.T) {
+	tests := map[string]struct {
+		// complete completes the handshake if true, and closes the
+		// connection otherwise.
+		complete bool
+		wantErr  bool
+	}{
+		"handshake completes": {complete: true},
+		"connection closed":   {wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn := &fakeEarlyDataConn{FakeStreamConn: &FakeStreamConn{}, handshake: make(chan struct{})}
+			metrics := &fakeClientMetrics{}
+			dialer := &Dialer{
+				Metrics: metrics,
+				DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+					return conn, nil
+				},
+			}
+
+			sess, err := dialer.DialQUIC(context.Background(), "example.com:9000", nil)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+
+			metrics.mu.Lock()
+			assert.Empty(t, metrics.finished, "the dial should not finish before the session is set up")
+			metrics.mu.Unlock()
+
+			if tt.complete {
+				close(conn.handshake)
+			} else {
+				_ = conn.CloseWithError(transport.ConnErrorCode(ProtocolViolationErrorCode), "")
+			}
+
+			require.Eventually(t, func() bool {
+				metrics.mu.Lock()
+				defer metrics.mu.Unlock()
+				return len(metrics.finished) == 1
+			}, time.Second, time.Millisecond)
+			if tt.wantErr {
+				_, ok := errors.AsType[*SessionError](metrics.finished[0])
+				assert.True(t, ok, "got %v", metrics.finished[0])
+			} else {
+				assert.NoError(t, metrics.finished[0])
+			}
+		})
+	}
+}
+
+func TestDialer_Metrics_DialWebTransportError(t *testing.T) {
+	dialErr := errors.New("dial failed")
+	metrics := &fakeClientMetrics{}
+	dialer := &Dialer{
+		Metrics: metrics,
+		DialWebTransportFunc: func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
+			return nil, nil, dialErr
+		},
+	}
+
+	_, err := dialer.DialWebTransport(context.Background(), "example.com:8443", "/session", nil)
+	require.ErrorIs(t, err, dialErr)
+
+	assert.Equal(t, []string{"webtransport example.com:8443"}, metrics.started)
+	assert.Equal(t, []error{dialErr}, metrics.finished)
+}
+
+func TestSession_Metrics_SubscribedTrack(t *testing.T) {
+	var resp bytes.Buffer
+	_, _ = resp.Write([]byte{byte(message.MessageTypeSubscribeOk)})
+	require.NoError(t, message.SubscribeOkMessage{}.Encode(&resp))
+	_, _ = resp.Write([]byte{byte(message.MessageTypeSubscribeDrop)})
+	require.NoError(t, message.SubscribeDropMessage{StartGroup: 3, EndGroup: 5, ErrorCode: 1}.Encode(&resp))
+
+	stream := &FakeQUICStream{
+		ReadFunc:  resp.Read,
+		WriteFunc: func(p []byte) (int, error) { return len(p), nil },
+	}
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) { return stream, nil }
+	})
+	metrics := &fakeClientMetrics{gapCh: make(chan struct{})}
+	session.metrics = metrics
+
+	track, err := session.Subscribe(context.Background(), "/test", "video", nil)
+	require.NoError(t, err)
+
+	select {
+	case <-metrics.gapCh:
+	case <-time.After(time.Second):
+		t.Fatal("GroupGap was not reported")
+	}
+	assert.Equal(t, []SubscribeDrop{{StartGroup: 2, EndGroup: 4, ErrorCode: 1}}, metrics.gaps)
+	assert.Equal(t, uint64(3), track.DropStats().Groups[DropReasonPolicy])
+
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("hello"))
+	var data bytes.Buffer
+	require.NoError(t, frame.encode(&data))
+	track.enqueueGroup(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: data.Read})
+
+	group, err := track.AcceptGroup(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, group.ReadFrame(NewFrame(0)))
+	assert.Equal(t, []int{5}, metrics.frames)
+
+	track.ReportRebuffer(250 * time.Millisecond)
+	assert.Equal(t, []time.Duration{250 * time.Millisecond}, metrics.rebuffers)
+}
+
+func TestTrackReader_ReportRebuffer_NoMetrics(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+
+	assert.NotPanics(t, func() {
+		receiver.ReportRebuffer(time.Second)
+	})
+}
@@ -0,0 +1,721 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClientServer hands out fake connections that accept every SUBSCRIBE
+// and records the SUBSCRIBE messages it receives.
+type fakeClientServer struct {
+	mu         sync.Mutex
+	conns      []*FakeStreamConn
+	addrs      []string
+	writes     []*bytes.Buffer
+	failDials  int
+	dialErr    error
+	quicDials  int
+	wtDials    int
+	subscribed chan struct{}
+}
+
+func newFakeClientServer() *fakeClientServer {
+	return &fakeClientServer{subscribed: make(chan struct{}, 16)}
+}
+
+func (f *fakeClientServer) dialQUIC(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.quicDials++
+	f.addrs = append(f.addrs, addr)
+	if f.dialErr != nil {
+		return nil, f.dialErr
+	}
+	if f.failDials > 0 {
+		f.failDials--
+		return nil, errors.New("unreachable")
+	}
+
+	conn := &FakeStreamConn{}
+	conn.OpenStreamFunc = f.openSubscribeStream
+	f.conns = append(f.conns, conn)
+	return conn, nil
+}
+
+func (f *fakeClientServer) dialWebTransport(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.wtDials++
+	return nil, &FakeWebTransportSession{}, nil
+}
+
+func (f *fakeClientServer) openSubscribeStream() (transport.Stream, error) {
+	var resp bytes.Buffer
+	_, _ = resp.Write([]byte{byte(message.MessageTypeSubscribeOk)})
+	_ = message.SubscribeOkMessage{}.Encode(&resp)
+
+	written := new(bytes.Buffer)
+	f.mu.Lock()
+	f.writes = append(f.writes, written)
+	f.mu.Unlock()
+
+	return &FakeQUICStream{
+		ReadFunc: resp.Read,
+		WriteFunc: func(p []byte) (int, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			return written.Write(p)
+		},
+	}, nil
+}
+
+// subscribe returns the i-th SUBSCRIBE message received.
+func (f *fakeClientServer) subscribe(t *testing.T, i int) message.SubscribeMessage {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	require.Greater(t, len(f.writes), i)
+
+	r := bytes.NewReader(f.writes[i].Bytes())
+	var streamType message.StreamType
+	require.NoError(t, streamType.Decode(r))
+	var msg message.SubscribeMessage
+	require.NoError(t, msg.Decode(r))
+	return msg
+}
+
+func (f *fakeClientServer) conn(i int) *FakeStreamConn {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns[i]
+}
+
+func (f *fakeClientServer) dialer() *Dialer {
+	return &Dialer{DialQUICFunc: f.dialQUIC, DialWebTransportFunc: f.dialWebTransport}
+}
+
+func TestReconnectPolicy_Backoff(t *testing.T) {
+	tests := map[string]struct {
+		policy ReconnectPolicy
+		want   []time.Duration
+	}{
+		"defaults": {
+			want: []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		"capped": {
+			policy: ReconnectPolicy{InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, Multiplier: 3},
+			want:   []time.Duration{time.Second, 3 * time.Second, 3 * time.Second},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for i, want := range tt.want {
+				assert.Equal(t, want, tt.policy.backoff(i+1), "attempt %d", i+1)
+			}
+		})
+	}
+}
+
+func TestClient_Dial_Transport(t *testing.T) {
+	tests := map[string]struct {
+		url       string
+		transport ClientTransport
+		dialErr   error
+		quic      int
+		wt        int
+		wantErr   error
+	}{
+		"moqt scheme": {
+			url:  "moqt://example.com:9000",
+			quic: 1,
+		},
+		"auto prefers native quic": {
+			url:  "https://example.com:9000",
+			quic: 1,
+		},
+		"auto with path uses webtransport": {
+			url: "https://example.com:9000/live",
+			wt:  1,
+		},
+		"auto does not redial on handshake rejection": {
+			url:     "https://example.com:9000/",
+			dialErr: &quic.TransportError{ErrorCode: quic.TransportErrorCode(0x100 + 120)},
+			quic:    1,
+			wantErr: &quic.TransportError{ErrorCode: quic.TransportErrorCode(0x100 + 120)},
+		},
+		"auto does not fall back on other errors": {
+			url:     "https://example.com:9000",
+			dialErr: errors.New("timeout"),
+			quic:    1,
+			wantErr: errors.New("timeout"),
+		},
+		"webtransport only": {
+			url:       "https://example.com:9000",
+			transport: TransportWebTransport,
+			wt:        1,
+		},
+		"quic only": {
+			url:       "https://example.com:9000/live",
+			transport: TransportQUIC,
+			quic:      1,
+		},
+		"invalid scheme": {
+			url:     "http://example.com",
+			wantErr: ErrInvalidScheme,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newFakeClientServer()
+			server.dialErr = tt.dialErr
+			client := &Client{Dialer: server.dialer(), Transport: tt.transport}
+			defer client.Close()
+
+			sess, err := client.Dial(t.Context(), tt.url)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Same(t, sess, client.Session())
+			}
+			assert.Equal(t, tt.quic, server.quicDials)
+			assert.Equal(t, tt.wt, server.wtDials)
+		})
+	}
+}
+
+func TestClient_Dial_Auto_SingleHandshake(t *testing.T) {
+	tests := map[string]struct {
+		disableNativeQUIC bool
+		wantProtocol      string
+	}{
+		"server selects a version": {
+			wantProtocol: VersionLite04Ext,
+		},
+		"server selects h3": {
+			disableNativeQUIC: true,
+			wantProtocol:      NextProtoH3,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			addr, listen := listenLoopback(t)
+
+			handler := HandleFunc(func(sess *Session) {
+				<-sess.Context().Done()
+			})
+			server := &Server{
+				Addr:               addr,
+				TLSConfig:          testutil.TLSConfig(t),
+				DisableNativeQUIC:  tt.disableNativeQUIC,
+				Handler:            handler,
+				WebTransportServer: NewWebTransportServer(&WebTransportHandler{Handler: handler}),
+				ListenFunc:         listen,
+			}
+			go func() {
+				_ = server.ListenAndServe()
+			}()
+			t.Cleanup(func() {
+				_ = server.Close()
+			})
+
+			var mu sync.Mutex
+			var offered [][]string
+			dialer := &Dialer{
+				TLSConfig: &tls.Config{ServerName: "localhost", InsecureSkipVerify: true},
+				DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+					mu.Lock()
+					offered = append(offered, tlsConfig.NextProtos)
+					mu.Unlock()
+					conn, err := quic.DialAddrEarly(ctx, addr, tlsConfig, quicConfig)
+					if err != nil {
+						return nil, err
+					}
+					return WrapQUICConn(conn), nil
+				},
+				DialWebTransportFunc: func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
+					return nil, nil, errors.New("unexpected WebTransport dial")
+				},
+			}
+
+			var sess *Session
+			require.Eventually(t, func() bool {
+				mu.Lock()
+				offered = nil
+				mu.Unlock()
+				client := &Client{Dialer: dialer}
+				ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+				defer cancel()
+				var err error
+				sess, err = client.Dial(ctx, "https://"+addr)
+				if err != nil {
+					client.Close()
+					return false
+				}
+				t.Cleanup(func() {
+					client.Close()
+				})
+				return true
+			}, 5*time.Second, 20*time.Millisecond)
+
+			assert.Equal(t, tt.wantProtocol, sess.ConnectionState().TLS.NegotiatedProtocol)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, [][]string{{VersionLite04Ext, NextProtoMOQ, NextProtoH3}}, offered)
+		})
+	}
+}
+
+func TestClient_Dial_Auto_H3WithoutQUICConn(t *testing.T) {
+	var closed bool
+	dialer := &Dialer{
+		DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+			return &FakeStreamConn{
+				TLSFunc: func() *tls.ConnectionState {
+					return &tls.ConnectionState{NegotiatedProtocol: NextProtoH3}
+				},
+				CloseWithErrorFunc: func(code transport.ConnErrorCode, msg string) error {
+					closed = true
+					return nil
+				},
+			}, nil
+		},
+	}
+	client := &Client{Dialer: dialer}
+	defer client.Close()
+
+	_, err := client.Dial(t.Context(), "https://example.com:9000")
+	assert.ErrorContains(t, err, "WrapQUICConn")
+	assert.True(t, closed)
+}
+
+func TestClient_Dial_Twice(t *testing.T) {
+	server := newFakeClientServer()
+	client := &Client{Dialer: server.dialer()}
+	defer client.Close()
+
+	_, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	_, err = client.Dial(t.Context(), "moqt://example.com:9000")
+	assert.Error(t, err)
+}
+
+func TestClient_Subscribe_NotConnected(t *testing.T) {
+	client := &Client{}
+	_, err := client.Subscribe(t.Context(), "/live", "video", nil)
+	assert.Error(t, err)
+	assert.NoError(t, client.Close())
+}
+
+func TestClient_Reconnect_Resubscribes(t *testing.T) {
+	server := newFakeClientServer()
+	server.failDials = 0
+
+	reconnected := make(chan *Session, 1)
+	client := &Client{
+		Dialer:      server.dialer(),
+		Reconnect:   &ReconnectPolicy{InitialBackoff: time.Millisecond},
+		OnReconnect: func(sess *Session) { reconnected <- sess },
+	}
+	defer client.Close()
+
+	first, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+
+	track, err := client.Subscribe(t.Context(), "/live", "video", &SubscribeConfig{Priority: 3})
+	require.NoError(t, err)
+
+	track.Reader().enqueueGroup(GroupSequence(7), &FakeQUICReceiveStream{})
+	group, err := track.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(7), group.GroupSequence())
+
+	server.mu.Lock()
+	server.failDials = 2
+	server.mu.Unlock()
+
+	// Lose the connection.
+	_ = server.conn(0).CloseWithError(0, "network blip")
+
+	var second *Session
+	select {
+	case second = <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("client did not reconnect")
+	}
+	assert.NotSame(t, first, second)
+	assert.Same(t, second, client.Session())
+	assert.Equal(t, 4, server.quicDials)
+
+	msg := server.subscribe(t, 1)
+	assert.Equal(t, "/live", msg.BroadcastPath)
+	assert.Equal(t, "video", msg.TrackName)
+	assert.Equal(t, uint8(3), msg.SubscriberPriority)
+	assert.Equal(t, groupSequenceToWire(GroupSequence(8)), msg.StartGroup, "resubscription should start after the last accepted group")
+
+	track.Reader().enqueueGroup(GroupSequence(8), &FakeQUICReceiveStream{})
+	group, err = track.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(8), group.GroupSequence())
+}
+
+func TestClient_Enable0RTT_SharesCacheWithoutChangingDialer(t *testing.T) {
+	server := newFakeClientServer()
+	var caches []tls.ClientSessionCache
+	tlsConfig := &tls.Config{ServerName: "example.com"}
+	dialer := &Dialer{
+		TLSConfig:  tlsConfig,
+		Enable0RTT: true,
+		DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+			caches = append(caches, tlsConfig.ClientSessionCache)
+			return server.dialQUIC(ctx, addr, tlsConfig, quicConfig)
+		},
+	}
+	reconnected := make(chan *Session, 1)
+	client := &Client{
+		Dialer:      dialer,
+		Reconnect:   &ReconnectPolicy{InitialBackoff: time.Millisecond},
+		OnReconnect: func(sess *Session) { reconnected <- sess },
+	}
+	defer client.Close()
+
+	_, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	_ = server.conn(0).CloseWithError(0, "network blip")
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("client did not reconnect")
+	}
+
+	require.Len(t, caches, 2)
+	require.NotNil(t, caches[0])
+	assert.Same(t, caches[0], caches[1], "tickets must be shared across reconnections")
+	assert.Nil(t, tlsConfig.ClientSessionCache, "the TLS config of the Dialer should be unchanged")
+	assert.Nil(t, dialer.sessionCache, "the Dialer should be unchanged")
+}
+
+func TestClient_Reconnect_WaitingAcceptGroupResumes(t *testing.T) {
+	server := newFakeClientServer()
+	client := &Client{
+		Dialer:    server.dialer(),
+		Reconnect: &ReconnectPolicy{InitialBackoff: time.Millisecond},
+	}
+	defer client.Close()
+
+	_, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	track, err := client.Subscribe(t.Context(), "/live", "video", nil)
+	require.NoError(t, err)
+	first := track.Reader()
+
+	type result struct {
+		group *GroupReader
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		group, err := track.AcceptGroup(t.Context())
+		done <- result{group, err}
+	}()
+
+	_ = server.conn(0).CloseWithError(0, "network blip")
+
+	require.Eventually(t, func() bool { return track.Reader() != first }, time.Second, time.Millisecond)
+	track.Reader().enqueueGroup(GroupSequence(1), &FakeQUICReceiveStream{})
+
+	select {
+	case res := <-done:
+		require.NoError(t, res.err)
+		assert.Equal(t, GroupSequence(1), res.group.GroupSequence())
+	case <-time.After(time.Second):
+		t.Fatal("AcceptGroup did not resume after reconnection")
+	}
+}
+
+func TestClientTrack_AcceptGroup_StartsNoTask(t *testing.T) {
+	server := newFakeClientServer()
+	client := &Client{Dialer: server.dialer()}
+	defer client.Close()
+
+	_, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	track, err := client.Subscribe(t.Context(), "/live", "video", nil)
+	require.NoError(t, err)
+	running := client.tasks.Running()
+
+	for range 3 {
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		done := make(chan error, 1)
+		go func() {
+			_, err := track.AcceptGroup(ctx)
+			done <- err
+		}()
+
+		time.Sleep(5 * time.Millisecond)
+		assert.Equal(t, running, client.tasks.Running(), "a waiting AcceptGroup should start no task")
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		case <-time.After(time.Second):
+			t.Fatal("AcceptGroup did not return after its context ended")
+		}
+		cancel()
+	}
+	assert.Equal(t, running, client.tasks.Running())
+}
+
+func TestClient_Reconnect_GivesUp(t *testing.T) {
+	server := newFakeClientServer()
+	var disconnected error
+	client := &Client{
+		Dialer:       server.dialer(),
+		Reconnect:    &ReconnectPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 2},
+		OnDisconnect: func(err error) { disconnected = err },
+	}
+	defer client.Close()
+
+	_, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	track, err := client.Subscribe(t.Context(), "/live", "video", nil)
+	require.NoError(t, err)
+
+	server.mu.Lock()
+	server.failDials = 5
+	server.mu.Unlock()
+	_ = server.conn(0).CloseWithError(0, "network blip")
+
+	select {
+	case <-client.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("client did not give up")
+	}
+	assert.ErrorContains(t, context.Cause(client.Context()), "after 2 attempts")
+	assert.Error(t, disconnected)
+
+	_, err = track.AcceptGroup(t.Context())
+	assert.ErrorContains(t, err, "after 2 attempts")
+}
+
+func TestClient_NoReconnect_TrackEndsWithSession(t *testing.T) {
+	server := newFakeClientServer()
+	client := &Client{Dialer: server.dialer()}
+	defer client.Close()
+
+	_, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	track, err := client.Subscribe(t.Context(), "/live", "video", nil)
+	require.NoError(t, err)
+
+	_ = server.conn(0).CloseWithError(0, "gone")
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	_, err = track.AcceptGroup(ctx)
+	assert.Error(t, err)
+	assert.NoError(t, ctx.Err(), "AcceptGroup should fail instead of waiting")
+	assert.Error(t, track.Context().Err())
+}
+
+func TestClient_Close(t *testing.T) {
+	server := newFakeClientServer()
+	client := &Client{
+		Dialer:    server.dialer(),
+		Reconnect: &ReconnectPolicy{InitialBackoff: time.Millisecond},
+	}
+
+	sess, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	track, err := client.Subscribe(t.Context(), "/live", "video", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Close())
+
+	assert.Error(t, sess.Context().Err())
+	assert.ErrorIs(t, context.Cause(track.Context()), ErrClosedSession)
+	assert.Equal(t, 1, server.quicDials, "closing should not trigger a reconnection")
+}
+
+func TestClientTrack_Close(t *testing.T) {
+	server := newFakeClientServer()
+	client := &Client{Dialer: server.dialer()}
+	defer client.Close()
+
+	_, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	track, err := client.Subscribe(t.Context(), "/live", "video", nil)
+	require.NoError(t, err)
+
+	_ = track.Close()
+	assert.ErrorIs(t, context.Cause(track.Context()), ErrClosedTrack)
+
+	client.mu.Lock()
+	assert.Empty(t, client.tracks)
+	client.mu.Unlock()
+}
+
+func TestClient_Goaway_Migrates(t *testing.T) {
+	tests := map[string]struct {
+		uri      string
+		wantAddr string
+	}{
+		"absolute URI": {
+			uri:      "moqt://next.example.com:9000",
+			wantAddr: "next.example.com:9000",
+		},
+		"empty URI": {
+			uri:      "",
+			wantAddr: "example.com:9000",
+		},
+		"network-path reference": {
+			uri:      "//other.example.com:9001",
+			wantAddr: "other.example.com:9001",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newFakeClientServer()
+			migrated := make(chan *Session, 1)
+			var observed string
+			client := &Client{
+				Dialer: server.dialer(),
+				OnGoaway: func(uri string) bool {
+					observed = uri
+					return true
+				},
+				OnReconnect: func(sess *Session) { migrated <- sess },
+			}
+			defer client.Close()
+
+			first, err := client.Dial(t.Context(), "moqt://example.com:9000")
+			require.NoError(t, err)
+			track, err := client.Subscribe(t.Context(), "/live", "video", nil)
+			require.NoError(t, err)
+
+			track.Reader().enqueueGroup(GroupSequence(7), &FakeQUICReceiveStream{})
+			_, err = track.AcceptGroup(t.Context())
+			require.NoError(t, err)
+
+			first.onGoaway(tt.uri)
+
+			var second *Session
+			select {
+			case second = <-migrated:
+			case <-time.After(time.Second):
+				t.Fatal("client did not migrate")
+			}
+			assert.Equal(t, tt.uri, observed)
+			assert.Same(t, second, client.Session())
+			assert.Equal(t, tt.wantAddr, server.addrs[1])
+			assert.Equal(t, tt.wantAddr, client.currentURL().Host, "later reconnections should use the new URI")
+			assert.Error(t, first.Context().Err(), "the old session should be closed after migrating")
+
+			msg := server.subscribe(t, 1)
+			assert.Equal(t, groupSequenceToWire(GroupSequence(8)), msg.StartGroup, "resubscription should start after the last accepted group")
+
+			track.Reader().enqueueGroup(GroupSequence(8), &FakeQUICReceiveStream{})
+			group, err := track.AcceptGroup(t.Context())
+			require.NoError(t, err)
+			assert.Equal(t, GroupSequence(8), group.GroupSequence())
+			assert.NoError(t, track.Context().Err())
+		})
+	}
+}
+
+func TestClient_Goaway_Veto(t *testing.T) {
+	server := newFakeClientServer()
+	vetoed := make(chan string, 1)
+	client := &Client{
+		Dialer: server.dialer(),
+		OnGoaway: func(uri string) bool {
+			vetoed <- uri
+			return false
+		},
+	}
+	defer client.Close()
+
+	sess, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+
+	sess.onGoaway("moqt://next.example.com:9000")
+
+	select {
+	case uri := <-vetoed:
+		assert.Equal(t, "moqt://next.example.com:9000", uri)
+	case <-time.After(time.Second):
+		t.Fatal("OnGoaway was not called")
+	}
+
+	assert.Never(t, func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return server.quicDials > 1
+	}, 50*time.Millisecond, 5*time.Millisecond, "a vetoed migration should not dial")
+	assert.Same(t, sess, client.Session())
+	assert.NoError(t, sess.Context().Err())
+}
+
+func TestClient_Goaway_WaitingAcceptGroupMoves(t *testing.T) {
+	server := newFakeClientServer()
+	client := &Client{Dialer: server.dialer()}
+	defer client.Close()
+
+	sess, err := client.Dial(t.Context(), "moqt://example.com:9000")
+	require.NoError(t, err)
+	track, err := client.Subscribe(t.Context(), "/live", "video", nil)
+	require.NoError(t, err)
+	first := track.Reader()
+
+	type result struct {
+		group *GroupReader
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		group, err := track.AcceptGroup(t.Context())
+		done <- result{group, err}
+	}()
+
+	sess.onGoaway("moqt://next.example.com:9000")
+
+	require.Eventually(t, func() bool { return track.Reader() != first }, time.Second, time.Millisecond)
+	track.Reader().enqueueGroup(GroupSequence(1), &FakeQUICReceiveStream{})
+
+	select {
+	case res := <-done:
+		require.NoError(t, res.err)
+		assert.Equal(t, GroupSequence(1), res.group.GroupSequence())
+	case <-time.After(time.Second):
+		t.Fatal("AcceptGroup did not move to the new session")
+	}
+}
+
+func TestClientTrack_Accepted_SkipsDuplicates(t *testing.T) {
+	old := &TrackReader{}
+	current := &TrackReader{}
+	track := &ClientTrack{reader: current}
+
+	// A group accepted from the replaced subscription is returned once.
+	assert.True(t, track.accepted(old, 9))
+	assert.False(t, track.accepted(current, 8))
+	assert.False(t, track.accepted(current, 9))
+	assert.True(t, track.accepted(current, 10))
+	assert.Equal(t, GroupSequence(10), track.latest)
+}
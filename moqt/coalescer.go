@@ -0,0 +1,100 @@
+package moqt
+
+import (
+	"io"
+	"time"
+)
+
+// defaultCoalesceSize is the size at which coalesced frames are written when
+// no size is set.
+const defaultCoalesceSize = 8 << 10
+
+// coalescer buffers the encoded frames of a group so that small frames
+// written in quick succession reach the stream with a single write. The
+// buffer is written once it holds size bytes, or delay after the first frame
+// it holds. It is guarded by the mu of its GroupWriter.
+type coalescer struct {
+	w     io.Writer
+	delay time.Duration
+	size  int
+
+	// flushLater is called by the timer to write the buffer.
+	flushLater func()
+
+	buf []byte
+
+	// timer calls flushLater once armed.
+	timer *time.Timer
+	armed bool
+
+	// err is the error of a write by the timer, returned by the next write.
+	err error
+}
+
+func newCoalescer(w io.Writer, delay time.Duration, size int, flushLater func()) *coalescer {
+	if size <= 0 {
+		size = defaultCoalesceSize
+	}
+	return &coalescer{w: w, delay: delay, size: size, flushLater: flushLater}
+}
+
+// Write appends p to the buffer. Data that fills the buffer by itself is
+// written through instead of copied.
+func (c *coalescer) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if len(c.buf) == 0 && len(p) >= c.size {
+		n, err := c.w.Write(p)
+		c.err = err
+		return n, err
+	}
+	c.buf = append(c.buf, p...)
+	return len(p), nil
+}
+
+// written writes the buffer if it is full, or arms the timer if it holds
+// data. It is called after each frame.
+func (c *coalescer) written() error {
+	if len(c.buf) >= c.size {
+		return c.flush()
+	}
+	if len(c.buf) == 0 || c.armed {
+		return nil
+	}
+	c.armed = true
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.delay, c.flushLater)
+	} else {
+		c.timer.Reset(c.delay)
+	}
+	return nil
+}
+
+// flush writes the buffer.
+func (c *coalescer) flush() error {
+	c.stop()
+	if c.err != nil {
+		return c.err
+	}
+	if len(c.buf) == 0 {
+		return nil
+	}
+	_, err := c.w.Write(c.buf)
+	c.buf = c.buf[:0]
+	c.err = err
+	return err
+}
+
+// discard drops the buffer, when the group is canceled.
+func (c *coalescer) discard() {
+	c.stop()
+	c.buf = c.buf[:0]
+}
+
+func (c *coalescer) stop() {
+	if c.armed {
+		c.timer.Stop()
+		c.armed = false
+	}
+}
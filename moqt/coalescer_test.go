@@ -0,0 +1,191 @@
+package moqt
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSendStream returns a send stream recording the sizes of its
+// writes and the data written.
+func recordingSendStream() (*FakeQUICSendStream, func() []int, *bytes.Buffer) {
+	var (
+		mu     sync.Mutex
+		writes []int
+		data   bytes.Buffer
+	)
+	stream := &FakeQUICSendStream{
+		WriteFunc: func(p []byte) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			writes = append(writes, len(p))
+			return data.Write(p)
+		},
+	}
+	return stream, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), writes...)
+	}, &data
+}
+
+func TestGroupWriter_WriteCoalescing(t *testing.T) {
+	tests := map[string]struct {
+		delay      time.Duration
+		size       int
+		frames     []int
+		wait       time.Duration
+		wantWrites []int
+	}{
+		"buffered until delay": {
+			delay:      2 * time.Millisecond,
+			size:       8 << 10,
+			frames:     []int{100, 100, 100},
+			wait:       2 * time.Millisecond,
+			wantWrites: []int{306},
+		},
+		"not before delay": {
+			delay:  2 * time.Millisecond,
+			size:   8 << 10,
+			frames: []int{100, 100},
+			wait:   time.Millisecond,
+		},
+		"written when full": {
+			delay:      time.Second,
+			size:       250,
+			frames:     []int{100, 100, 100},
+			wantWrites: []int{306},
+		},
+		"large frame written through": {
+			delay:      time.Second,
+			size:       250,
+			frames:     []int{1000},
+			wantWrites: []int{1002},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			synctest.Test(t, func(t *testing.T) {
+				stream, writes, _ := recordingSendStream()
+				group := newGroupWriter(stream, GroupSequence(1), nil)
+				group.coalescer = newCoalescer(stream, tt.delay, tt.size, group.flushLater)
+
+				for _, size := range tt.frames {
+					frame := NewFrame(size)
+					_, _ = frame.Write(make([]byte, size))
+					require.NoError(t, group.WriteFrame(frame))
+				}
+				time.Sleep(tt.wait)
+				synctest.Wait()
+
+				assert.Equal(t, tt.wantWrites, writes())
+			})
+		})
+	}
+}
+
+func TestGroupWriter_WriteCoalescing_Close(t *testing.T) {
+	stream, writes, data := recordingSendStream()
+	group := newGroupWriter(stream, GroupSequence(1), nil)
+	group.coalescer = newCoalescer(stream, time.Hour, 0, group.flushLater)
+
+	for _, payload := range []string{"a", "bc", "def"} {
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte(payload))
+		require.NoError(t, group.WriteFrame(frame))
+	}
+	assert.Empty(t, writes(), "frames must be buffered")
+
+	require.NoError(t, group.Close())
+	assert.Len(t, writes(), 1, "the buffered frames must be written at once")
+
+	frame := NewFrame(0)
+	for _, want := range []string{"a", "bc", "def"} {
+		require.NoError(t, frame.decode(data))
+		assert.Equal(t, []byte(want), frame.Body())
+	}
+}
+
+func TestGroupWriter_WriteCoalescing_Flush(t *testing.T) {
+	stream, writes, _ := recordingSendStream()
+	group := newGroupWriter(stream, GroupSequence(1), nil)
+	group.coalescer = newCoalescer(stream, time.Hour, 0, group.flushLater)
+
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("payload"))
+	require.NoError(t, group.WriteFrame(frame))
+	require.NoError(t, group.Flush())
+	assert.Equal(t, []int{8}, writes())
+
+	// Flushing an empty buffer writes nothing.
+	require.NoError(t, group.Flush())
+	assert.Equal(t, []int{8}, writes())
+}
+
+func TestGroupWriter_WriteCoalescing_Cancel(t *testing.T) {
+	stream, writes, _ := recordingSendStream()
+	group := newGroupWriter(stream, GroupSequence(1), nil)
+	group.coalescer = newCoalescer(stream, time.Hour, 0, group.flushLater)
+
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("payload"))
+	require.NoError(t, group.WriteFrame(frame))
+
+	group.CancelWrite(PublishAbortedErrorCode)
+	assert.Empty(t, writes(), "buffered frames of a canceled group must be dropped")
+}
+
+func TestGroupWriter_WriteCoalescing_DelayedError(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		writeErr := errors.New("write error")
+		stream := &FakeQUICSendStream{
+			WriteFunc: func(p []byte) (int, error) { return 0, writeErr },
+		}
+		group := newGroupWriter(stream, GroupSequence(1), nil)
+		group.coalescer = newCoalescer(stream, time.Millisecond, 0, group.flushLater)
+
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte("payload"))
+		require.NoError(t, group.WriteFrame(frame))
+
+		time.Sleep(time.Millisecond)
+		synctest.Wait()
+
+		// The error of the delayed write is returned by the next write.
+		assert.ErrorIs(t, group.WriteFrame(frame), writeErr)
+	})
+}
+
+func TestTrackWriter_SetWriteCoalescing(t *testing.T) {
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	openUniStreamFunc := func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, func() {})
+	defer writer.Close()
+
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+	assert.Nil(t, group.coalescer, "coalescing must be disabled by default")
+
+	writer.SetWriteCoalescing(2*time.Millisecond, 0)
+	group, err = writer.OpenGroup()
+	require.NoError(t, err)
+	require.NotNil(t, group.coalescer)
+	assert.Equal(t, 2*time.Millisecond, group.coalescer.delay)
+	assert.Equal(t, defaultCoalesceSize, group.coalescer.size)
+
+	writer.SetWriteCoalescing(0, 0)
+	group, err = writer.OpenGroup()
+	require.NoError(t, err)
+	assert.Nil(t, group.coalescer)
+}
@@ -1,6 +1,9 @@
 package moqt
 
 import (
+	"fmt"
+	"maps"
+	"slices"
 	"time"
 )
 
@@ -22,6 +25,97 @@ type Config struct {
 	// an early probe send before ProbeMaxAge elapses.
 	// If zero, defaults to 0.10 (10%).
 	ProbeMaxDelta float64
+
+	// AnnouncementFilter, if set, limits the announcements sent to the peer
+	// in response to its announce interest to those the filter accepts.
+	AnnouncementFilter AnnouncementFilter
+
+	// MaxQueuedGroups is the maximum number of received groups a subscription
+	// holds until AcceptGroup takes them. When a busy track exceeds it, the
+	// oldest queued group is dropped as stale.
+	// If zero, defaults to 32. Servers and Dialers fail if it is negative.
+	MaxQueuedGroups int
+
+	// QLogDirFunc, if set, is called for each new session and returns the
+	// directory in which a qlog trace of the session's MOQT events is written.
+	// Each session writes its own file, so the directory can be shared with
+	// the transport qlog of quic-go. If it returns "", no trace is written.
+	QLogDirFunc func() string
+
+	// PriorityPolicy maps the groups the session sends to send priorities,
+	// so that under congestion the groups that matter most are sent first;
+	// see DefaultPriorityPolicy. If nil, groups are not scheduled, and
+	// frames are written as soon as they are ready.
+	PriorityPolicy PriorityPolicy
+
+	// WriteFrameInterceptor, if set, runs on every frame the session writes,
+	// after the interceptor of its track; see FrameInterceptor.
+	WriteFrameInterceptor FrameInterceptor
+
+	// ReadFrameInterceptor, if set, runs on every frame the session reads,
+	// before the interceptor of its track; see FrameInterceptor.
+	ReadFrameInterceptor FrameInterceptor
+
+	// KeepAliveInterval is the period at which the session sends a PING to
+	// the peer, which echoes it back. If zero, it defaults to a third of
+	// IdleTimeout, and no PING is sent if IdleTimeout is zero as well.
+	// PINGs are only sent on sessions that negotiated VersionLite04Ext, as
+	// peers of other versions do not know the Ping stream; see
+	// Session.Extensions. Set the KeepAlivePeriod and MaxIdleTimeout of
+	// the quic.Config for those.
+	KeepAliveInterval time.Duration
+
+	// IdleTimeout, if positive, is the time after which a session whose
+	// peer has not answered a PING nor sent one is closed with
+	// IdleTimeoutErrorCode, so that dead sessions are released before the
+	// idle timeout of the QUIC connection. Handlers observe it as
+	// ErrSessionIdle through Cause(sess.Context()).
+	// If zero, sessions are only closed by the QUIC idle timeout.
+	IdleTimeout time.Duration
+
+	// MaxSubscriptionBitrate, if positive, caps the rate at which the
+	// session sends the frames of each subscription, in bits per second.
+	// Frames beyond it are delayed, as with TrackWriter.SetPacingRate.
+	// If zero, subscriptions are not capped.
+	MaxSubscriptionBitrate uint64
+
+	// ControlMessageRate, if positive, is the number of control messages per
+	// second the peer may send on average: SUBSCRIBE, SUBSCRIBE_UPDATE,
+	// ANNOUNCE, announce interests, FETCH and track status requests. A
+	// session whose peer sends more, beyond ControlMessageBurst, is closed
+	// with ProtocolViolationErrorCode.
+	// If zero, control messages are not limited.
+	ControlMessageRate float64
+
+	// ControlMessageBurst is the number of control messages the peer may send
+	// at once, before ControlMessageRate applies.
+	// If zero, defaults to ControlMessageRate rounded up.
+	ControlMessageBurst int
+
+	// SupportedVersions lists the versions of MOQ the endpoint speaks, such
+	// as VersionLite04 and VersionLite03, in order of preference. A client
+	// offers them as ALPN tokens over native QUIC and as subprotocols over
+	// WebTransport, and a server selects one of those the client offers.
+	// The version of a session is reported by Session.ConnectionState.
+	// Unknown versions are ignored. If empty, defaults to VersionLite04Ext
+	// and VersionLite04, so that endpoints of this package use the
+	// extensions of VersionLite04Ext with each other and moq-lite-04 with
+	// other peers.
+	SupportedVersions []string
+
+	// Parameters registers the types of the extension parameters of
+	// subscriptions. Subscriptions carrying a malformed value of a
+	// registered type are rejected: outgoing ones by Session.Subscribe and
+	// incoming ones with SubscribeErrorCodeInvalidParameter. Parameters of
+	// other types are passed through.
+	Parameters ParameterRegistry
+
+	// ErrorCodes maps the application-defined error codes that sessions,
+	// tracks and groups are closed with onto wire codes, and the wire codes
+	// received back in Session.ApplicationError.
+	// If nil, application codes start at DefaultApplicationErrorBase.
+	// Its Base is validated when a Server or Dialer starts.
+	ErrorCodes *ErrorCodeMap
 }
 
 // setupTimeout returns the configured setup timeout or a default value.
@@ -56,6 +150,135 @@ func (c *Config) probeMaxDelta() float64 {
 	return 0.10
 }
 
+// announcementFilter returns the configured announcement filter, or nil.
+func (c *Config) announcementFilter() AnnouncementFilter {
+	if c != nil {
+		return c.AnnouncementFilter
+	}
+	return nil
+}
+
+// validate reports whether the fields of c are in range. Servers and Dialers
+// check it before they set up sessions.
+func (c *Config) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxQueuedGroups < 0 {
+		return fmt.Errorf("moqt: invalid MaxQueuedGroups %d", c.MaxQueuedGroups)
+	}
+	if c.ErrorCodes != nil {
+		// Validated once here, so that mapping a code is a plain addition.
+		if err := c.ErrorCodes.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultMaxQueuedGroups is the queued group limit of a subscription if
+// Config.MaxQueuedGroups is zero.
+const defaultMaxQueuedGroups = 32
+
+// maxQueuedGroups returns the configured queued group limit, or
+// defaultMaxQueuedGroups if it is not positive.
+func (c *Config) maxQueuedGroups() int {
+	if c != nil && c.MaxQueuedGroups > 0 {
+		return c.MaxQueuedGroups
+	}
+	return defaultMaxQueuedGroups
+}
+
+// priorityPolicy returns the configured priority policy, or nil if groups
+// are not scheduled.
+func (c *Config) priorityPolicy() PriorityPolicy {
+	if c == nil {
+		return nil
+	}
+	return c.PriorityPolicy
+}
+
+// writeFrameInterceptor returns the configured write interceptor, or nil.
+func (c *Config) writeFrameInterceptor() FrameInterceptor {
+	if c != nil {
+		return c.WriteFrameInterceptor
+	}
+	return nil
+}
+
+// readFrameInterceptor returns the configured read interceptor, or nil.
+func (c *Config) readFrameInterceptor() FrameInterceptor {
+	if c != nil {
+		return c.ReadFrameInterceptor
+	}
+	return nil
+}
+
+// keepAliveInterval returns the configured keep-alive interval, a third of
+// the idle timeout, or zero if keep-alive is disabled.
+func (c *Config) keepAliveInterval() time.Duration {
+	if c == nil {
+		return 0
+	}
+	if c.KeepAliveInterval > 0 {
+		return c.KeepAliveInterval
+	}
+	if c.IdleTimeout > 0 {
+		return c.IdleTimeout / 3
+	}
+	return 0
+}
+
+// idleTimeout returns the configured idle timeout, or zero if disabled.
+func (c *Config) idleTimeout() time.Duration {
+	if c != nil && c.IdleTimeout > 0 {
+		return c.IdleTimeout
+	}
+	return 0
+}
+
+// maxSubscriptionBitrate returns the bitrate cap of each subscription, or
+// zero if disabled.
+func (c *Config) maxSubscriptionBitrate() uint64 {
+	if c != nil {
+		return c.MaxSubscriptionBitrate
+	}
+	return 0
+}
+
+// controlLimiter returns a limiter of the control messages of the peer, or
+// nil if they are not limited.
+func (c *Config) controlLimiter() *controlLimiter {
+	if c == nil {
+		return nil
+	}
+	return newControlLimiter(c.ControlMessageRate, c.ControlMessageBurst)
+}
+
+// parameterRegistry returns the configured parameter registry, or nil.
+func (c *Config) parameterRegistry() ParameterRegistry {
+	if c != nil {
+		return c.Parameters
+	}
+	return nil
+}
+
+// errorCodes returns the configured error code map, or nil.
+func (c *Config) errorCodes() *ErrorCodeMap {
+	if c != nil {
+		return c.ErrorCodes
+	}
+	return nil
+}
+
+// qlogDir returns the qlog directory for a new session, or "" if qlog is disabled.
+func (c *Config) qlogDir() string {
+	if c != nil && c.QLogDirFunc != nil {
+		return c.QLogDirFunc()
+	}
+	return ""
+}
+
 // Clone creates a copy of the Config.
 func (c *Config) Clone() *Config {
 	if c == nil {
@@ -66,5 +289,24 @@ func (c *Config) Clone() *Config {
 		ProbeInterval: c.ProbeInterval,
 		ProbeMaxAge:   c.ProbeMaxAge,
 		ProbeMaxDelta: c.ProbeMaxDelta,
+
+		AnnouncementFilter: c.AnnouncementFilter,
+		MaxQueuedGroups:    c.MaxQueuedGroups,
+		QLogDirFunc:        c.QLogDirFunc,
+		PriorityPolicy:     c.PriorityPolicy,
+
+		WriteFrameInterceptor: c.WriteFrameInterceptor,
+		ReadFrameInterceptor:  c.ReadFrameInterceptor,
+
+		KeepAliveInterval: c.KeepAliveInterval,
+		IdleTimeout:       c.IdleTimeout,
+
+		MaxSubscriptionBitrate: c.MaxSubscriptionBitrate,
+		ControlMessageRate:     c.ControlMessageRate,
+		ControlMessageBurst:    c.ControlMessageBurst,
+
+		SupportedVersions: slices.Clone(c.SupportedVersions),
+		Parameters:        maps.Clone(c.Parameters),
+		ErrorCodes:        c.ErrorCodes,
 	}
 }
@@ -89,3 +89,71 @@ func TestConfig_setupTimeout_Boundary(t *testing.T) {
 		assert.Equal(t, 5*time.Minute, timeout, "should accept large timeout")
 	})
 }
+
+func TestConfig_maxQueuedGroups(t *testing.T) {
+	tests := map[string]struct {
+		config *Config
+		want   int
+	}{
+		"nil config uses the default": {
+			want: defaultMaxQueuedGroups,
+		},
+		"zero uses the default": {
+			config: &Config{},
+			want:   defaultMaxQueuedGroups,
+		},
+		"positive returns configured value": {
+			config: &Config{MaxQueuedGroups: 16},
+			want:   16,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.config.maxQueuedGroups())
+			assert.Equal(t, tt.config.maxQueuedGroups(), tt.config.Clone().maxQueuedGroups(), "Clone should keep MaxQueuedGroups")
+		})
+	}
+}
+
+func TestConfig_validate(t *testing.T) {
+	tests := map[string]struct {
+		config  *Config
+		wantErr string
+	}{
+		"nil config": {},
+		"zero values": {
+			config: &Config{},
+		},
+		"negative MaxQueuedGroups": {
+			config:  &Config{MaxQueuedGroups: -1},
+			wantErr: "MaxQueuedGroups",
+		},
+		"error code base overlaps reserved codes": {
+			config:  &Config{ErrorCodes: &ErrorCodeMap{Base: 0x3}},
+			wantErr: "overlaps reserved",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.config.validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestConfig_PriorityPolicy(t *testing.T) {
+	custom := PriorityPolicyFunc(func(GroupSendInfo) int64 { return 1 })
+
+	assert.Nil(t, (*Config)(nil).priorityPolicy(), "nil config schedules nothing")
+	assert.Nil(t, (&Config{}).priorityPolicy(), "unset schedules nothing")
+
+	config := &Config{PriorityPolicy: custom}
+	assert.Equal(t, int64(1), config.priorityPolicy().SendPriority(GroupSendInfo{Priority: 1}))
+	assert.Equal(t, int64(1), config.Clone().priorityPolicy().SendPriority(GroupSendInfo{Priority: 1}))
+}
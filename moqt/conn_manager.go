@@ -1,25 +1,35 @@
 package moqt
 
 import (
+	"context"
 	"fmt"
 	"sync"
 )
 
 type connManager struct {
-	closed      bool
-	mu          sync.Mutex
-	connections map[StreamConn]struct{}
+	closed bool
+	mu     sync.Mutex
+	// connections maps the tracked connections to their sessions, or nil
+	// for connections tracked without one.
+	connections map[StreamConn]*Session
 
 	doneChan chan struct{}
 }
 
 func newConnManager() *connManager {
 	return &connManager{
-		connections: make(map[StreamConn]struct{}),
+		connections: make(map[StreamConn]*Session),
 	}
 }
 
 func (s *connManager) addConn(conn StreamConn) {
+	s.addSession(conn, nil)
+}
+
+// addSession tracks conn with its session sess, until conn is removed or
+// closed. Connections closed by the peer never reach Session.CloseWithError,
+// so they are released once their context is done.
+func (s *connManager) addSession(conn StreamConn, sess *Session) {
 	if conn == nil {
 		return
 	}
@@ -31,7 +41,12 @@ func (s *connManager) addConn(conn StreamConn) {
 	if len(s.connections) == 0 {
 		s.doneChan = make(chan struct{})
 	}
-	s.connections[conn] = struct{}{}
+	if _, ok := s.connections[conn]; !ok {
+		context.AfterFunc(conn.Context(), func() {
+			s.removeConn(conn)
+		})
+	}
+	s.connections[conn] = sess
 }
 
 func (s *connManager) removeConn(conn StreamConn) {
@@ -56,6 +71,30 @@ func (s *connManager) countSessions() int {
 	return len(s.connections)
 }
 
+// conns returns a snapshot of the tracked connections.
+func (s *connManager) conns() []StreamConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns := make([]StreamConn, 0, len(s.connections))
+	for conn := range s.connections {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// sessions returns a snapshot of the sessions of the tracked connections.
+func (s *connManager) sessions() []*Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := make([]*Session, 0, len(s.connections))
+	for _, sess := range s.connections {
+		if sess != nil {
+			sessions = append(sessions, sess)
+		}
+	}
+	return sessions
+}
+
 func (s *connManager) Done() <-chan struct{} {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1,6 +1,7 @@
 package moqt
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -77,6 +78,24 @@ func TestConnManager_RemoveSession(t *testing.T) {
 	assert.Equal(t, 0, manager.countSessions())
 }
 
+func TestConnManager_RemovesClosedConn(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	conn := &FakeStreamConn{ParentCtx: ctx}
+	manager := newConnManager()
+	manager.addConn(conn)
+	done := manager.Done()
+
+	// The peer closes the connection without it being removed.
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("connection should be removed once it is closed by the peer")
+	}
+	assert.Equal(t, 0, manager.countSessions())
+}
+
 func TestConnManager_Done(t *testing.T) {
 	manager := newConnManager()
 	first := manager.Done()
@@ -156,3 +175,19 @@ func TestConnManager_Close(t *testing.T) {
 		assert.Zero(t, manager.countSessions())
 	})
 }
+
+func TestConnManager_Conns(t *testing.T) {
+	manager := newConnManager()
+	first := &FakeStreamConn{}
+	second := &FakeStreamConn{}
+	manager.addConn(first)
+	manager.addConn(second)
+
+	conns := manager.conns()
+	assert.ElementsMatch(t, []StreamConn{first, second}, conns)
+
+	// The snapshot is not affected by later changes.
+	manager.removeConn(first)
+	assert.Len(t, conns, 2)
+	assert.Equal(t, []StreamConn{second}, manager.conns())
+}
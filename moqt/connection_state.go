@@ -9,4 +9,9 @@ type ConnectionState struct {
 
 	// TLS holds the TLS state when available.
 	TLS *tls.ConnectionState
+
+	// Used0RTT reports whether the session was resumed with 0-RTT and the
+	// server accepted its early data. It is false until the handshake
+	// completes.
+	Used0RTT bool
 }
@@ -0,0 +1,84 @@
+package moqt
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+)
+
+// errControlRateExceeded is the reason of a session closed because its peer
+// exceeded Config.ControlMessageRate.
+var errControlRateExceeded = errors.New("moqt: control message rate exceeded")
+
+// Kinds of control messages reported to ServerMetrics.ControlMessageReceived.
+const (
+	ControlMessageSubscribe        = "subscribe"
+	ControlMessageSubscribeUpdate  = "subscribe_update"
+	ControlMessageAnnounce         = "announce"
+	ControlMessageAnnounceInterest = "announce_interest"
+	ControlMessageFetch            = "fetch"
+	ControlMessageTrackStatus      = "track_status"
+)
+
+// controlMessageKind returns the kind of control message that opens a stream
+// of type t, or "" if its streams are not counted.
+func controlMessageKind(t message.StreamType) string {
+	switch t {
+	case message.StreamTypeAnnounce:
+		return ControlMessageAnnounceInterest
+	case message.StreamTypeSubscribe:
+		return ControlMessageSubscribe
+	case message.StreamTypeFetch:
+		return ControlMessageFetch
+	case message.StreamTypeTrackStatus:
+		return ControlMessageTrackStatus
+	default:
+		return ""
+	}
+}
+
+// controlLimiter is a token bucket counting the control messages of a peer.
+type controlLimiter struct {
+	mu sync.Mutex
+
+	rate   float64 // messages per second
+	burst  float64 // bucket capacity in messages
+	tokens float64
+	last   time.Time
+}
+
+// newControlLimiter returns a limiter admitting rate messages per second and
+// bursts of burst messages, or nil if rate is not positive. A burst below one
+// message defaults to the rate rounded up.
+func newControlLimiter(rate float64, burst int) *controlLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = max(int(math.Ceil(rate)), 1)
+	}
+	return &controlLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow takes a message from the bucket and reports whether it was not empty.
+func (l *controlLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		elapsed := now.Sub(l.last).Seconds()
+		if elapsed > 0 {
+			l.tokens = min(l.burst, l.tokens+elapsed*l.rate)
+		}
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,132 @@
+package moqt
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlLimiter(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	tests := map[string]struct {
+		rate  float64
+		burst int
+		at    []time.Duration
+		want  []bool
+	}{
+		"burst": {
+			rate: 1, burst: 3,
+			at:   []time.Duration{0, 0, 0, 0},
+			want: []bool{true, true, true, false},
+		},
+		"refill": {
+			rate: 10, burst: 1,
+			at:   []time.Duration{0, 0, 100 * time.Millisecond},
+			want: []bool{true, false, true},
+		},
+		"default burst": {
+			rate: 2.5,
+			at:   []time.Duration{0, 0, 0, 0},
+			want: []bool{true, true, true, false},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := newControlLimiter(tt.rate, tt.burst)
+			var got []bool
+			for _, d := range tt.at {
+				got = append(got, l.allow(start.Add(d)))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewControlLimiter_Disabled(t *testing.T) {
+	assert.Nil(t, newControlLimiter(0, 10))
+	assert.Nil(t, (*Config)(nil).controlLimiter())
+	assert.NotNil(t, (&Config{ControlMessageRate: 1}).controlLimiter())
+}
+
+// controlMetrics records the control message events of a session.
+type controlMetrics struct {
+	NopServerMetrics
+
+	mu       sync.Mutex
+	kinds    []string
+	exceeded []string
+}
+
+func (m *controlMetrics) ControlMessageReceived(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kinds = append(m.kinds, kind)
+}
+
+func (m *controlMetrics) ControlRateExceeded(transport string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exceeded = append(m.exceeded, transport)
+}
+
+func TestSession_ControlMessageRate(t *testing.T) {
+	closed := make(chan transport.ConnErrorCode, 1)
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.CloseWithErrorFunc = func(code transport.ConnErrorCode, reason string) error {
+			closed <- code
+			return nil
+		}
+	})
+	metrics := &controlMetrics{}
+	session.serverMetrics = metrics
+	session.transport = "quic"
+	session.controlLimit = newControlLimiter(0.001, 2)
+
+	subscribe := func(id SubscribeID) {
+		var buf bytes.Buffer
+		require.NoError(t, message.StreamTypeSubscribe.Encode(&buf))
+		require.NoError(t, message.SubscribeMessage{
+			SubscribeID:   uint64(id),
+			BroadcastPath: "/test/path",
+			TrackName:     "video",
+		}.Encode(&buf))
+		session.processBiStream(&FakeQUICStream{
+			ReadFunc: func(p []byte) (int, error) {
+				if buf.Len() == 0 {
+					return 0, io.EOF
+				}
+				return buf.Read(p)
+			},
+		}, func() {})
+	}
+
+	subscribe(1)
+	subscribe(2)
+	select {
+	case <-closed:
+		t.Fatal("session closed within the burst")
+	default:
+	}
+
+	subscribe(3)
+	select {
+	case code := <-closed:
+		assert.Equal(t, transport.ConnErrorCode(ProtocolViolationErrorCode), code)
+	case <-time.After(time.Second):
+		t.Fatal("session not closed")
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	assert.Equal(t, []string{ControlMessageSubscribe, ControlMessageSubscribe, ControlMessageSubscribe}, metrics.kinds)
+	assert.Equal(t, []string{"quic"}, metrics.exceeded)
+}
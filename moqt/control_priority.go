@@ -0,0 +1,50 @@
+package moqt
+
+import "sync"
+
+// controlPriority gives control streams priority over data streams within a
+// session. Each accepted control stream holds it until its opening message is
+// read, and no new data stream is accepted while one does, so that a busy
+// track delays a control message by at most the streams already accepted.
+type controlPriority struct {
+	mu      sync.Mutex
+	pending int
+
+	// idle is closed while no control stream is pending.
+	idle chan struct{}
+}
+
+func newControlPriority() *controlPriority {
+	idle := make(chan struct{})
+	close(idle)
+	return &controlPriority{idle: idle}
+}
+
+// hold marks a control stream as pending and returns the function releasing
+// it. The function may be called more than once.
+func (p *controlPriority) hold() func() {
+	p.mu.Lock()
+	if p.pending == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.pending++
+	p.mu.Unlock()
+
+	return sync.OnceFunc(func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.pending--
+		if p.pending == 0 {
+			close(p.idle)
+		}
+	})
+}
+
+// idleCh returns a channel that is closed once no control stream is pending.
+func (p *controlPriority) idleCh() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.idle
+}
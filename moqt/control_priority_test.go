@@ -0,0 +1,34 @@
+package moqt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlPriority(t *testing.T) {
+	p := newControlPriority()
+	assert.True(t, isClosed(p.idleCh()), "a new priority should be idle")
+
+	release1 := p.hold()
+	release2 := p.hold()
+	idle := p.idleCh()
+	assert.False(t, isClosed(idle))
+
+	release1()
+	release1()
+	assert.False(t, isClosed(idle), "a second release should not count")
+
+	release2()
+	assert.True(t, isClosed(idle))
+	assert.True(t, isClosed(p.idleCh()))
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,196 @@
+package moqt
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+// A datagram carries a group of one frame: a GROUP message followed by the
+// frame, as on a group stream without the stream type. Datagrams may be lost
+// or reordered, so they suit groups that are useless when late, such as
+// audio packets or position updates.
+
+// WriteDatagram sends frame as a new group of its own, with the next group
+// sequence, in a single datagram, and returns the sequence. The frame is
+// written on a group stream instead when the connection has no datagram
+// support or the encoded group does not fit into a datagram, so WriteDatagram
+// never fails because of the size of frame.
+//
+// Unlike groups written on streams, a group sent as a datagram is not
+// retransmitted if lost.
+func (w *TrackWriter) WriteDatagram(frame *Frame) (GroupSequence, error) {
+	if frame == nil {
+		return 0, errors.New("moqt: nil frame")
+	}
+	seq := GroupSequence(w.groupSequence.Add(1))
+
+	if intercept := w.frameInterceptor(); intercept != nil {
+		out, err := intercept(frame)
+		if err != nil {
+			return seq, err
+		}
+		if out == nil {
+			w.drops.recordFrame(DropReasonPolicy, seq)
+			return seq, nil
+		}
+		frame = out
+	}
+	if g := w.retainGroup(seq); g != nil {
+		g.frames = []*Frame{frame.Clone()}
+		g.buf.keep(g)
+	}
+
+	sent, err := w.sendDatagram(seq, frame)
+	if sent || err != nil {
+		return seq, err
+	}
+
+	group, err := w.openGroup(seq, 0, time.Time{})
+	if err != nil {
+		return seq, err
+	}
+	// The frame has already passed the interceptors and been kept.
+	group.interceptor = nil
+	group.retained = nil
+	if err := group.WriteFrame(frame); err != nil {
+		group.CancelWrite(InternalGroupErrorCode)
+		return seq, err
+	}
+	return seq, group.Close()
+}
+
+// sendDatagram sends the group seq holding frame as a datagram. It reports
+// false without an error if the group must be sent on a stream instead.
+func (w *TrackWriter) sendDatagram(seq GroupSequence, frame *Frame) (bool, error) {
+	data, err := w.encodeDatagram(seq, frame)
+	if data == nil || err != nil {
+		return false, err
+	}
+
+	// Skip datagrams already known to be too large.
+	if limit := w.maxDatagramSize.Load(); limit > 0 && int64(len(data)) > limit {
+		return false, nil
+	}
+
+	// The datagram is paced without w.mu, so that Close does not wait for
+	// it.
+	if err := pace(w.Context(), len(data), w.pacers()...); err != nil {
+		return false, err
+	}
+
+	if err := w.sendDatagramFunc(data); err != nil {
+		if tooLarge, ok := errors.AsType[*transport.DatagramTooLargeError](err); ok {
+			w.maxDatagramSize.Store(tooLarge.MaxDatagramPayloadSize)
+		}
+		// Datagrams may also be unsupported by the peer; streams always work.
+		return false, nil
+	}
+
+	subscribeID := w.subscribeStream.subscribeID
+	w.qlog.groupOpened(true, subscribeID, seq)
+	w.qlog.object(true, subscribeID, seq, 0, frame.Len())
+	if w.metrics != nil {
+		w.metrics.FrameSent(w.BroadcastPath, w.TrackName, frame.Len())
+	}
+	return true, nil
+}
+
+// encodeDatagram returns the datagram of the group seq holding frame, once
+// the first SUBSCRIBE_OK has been sent. It returns nil without an error if
+// the connection has no datagram support.
+func (w *TrackWriter) encodeDatagram(seq GroupSequence, frame *Frame) ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.sendDatagramFunc == nil {
+		return nil, nil
+	}
+	if w.Context().Err() != nil {
+		return nil, Cause(w.Context())
+	}
+	err := w.subscribeStream.ensureInfo(PublishInfo{
+		StartGroup: seq,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	version := w.subscribeStream.version
+	ext := w.extensionHeaders.Load() && version.Extended()
+	var checksum *groupChecksum
+	if mode := ChecksumMode(w.checksumMode.Load()); mode != ChecksumNone && version.Extended() {
+		checksum = &groupChecksum{mode: mode}
+	}
+	gm := message.GroupMessage{
+		SubscribeID:   uint64(w.subscribeStream.subscribeID),
+		GroupSequence: uint64(seq),
+		Version:       version,
+	}
+	var schema SchemaID
+	if version.Extended() {
+		schema = SchemaID(w.schemaID.Load())
+	}
+	if ext || checksum != nil || schema != 0 {
+		gm.Flags |= message.GroupFlagExtensionHeaders
+	}
+	_ = gm.Encode(&buf)
+	var prefix []byte
+	if ext || checksum != nil || schema != 0 {
+		prefix = appendExtensionHeaders(nil, frame, ext, schema, checksum)
+	}
+	_ = encodeFrameWith(&buf, frame, prefix)
+	return buf.Bytes(), nil
+}
+
+// handleDatagrams delivers the groups received in datagrams to the track
+// readers of their subscriptions until the connection is closed. Datagrams
+// that cannot be decoded or belong to no subscription are dropped.
+func (sess *Session) handleDatagrams(conn transport.DatagramConn) {
+	for {
+		b, err := conn.ReceiveDatagram(sess.ctx)
+		if err != nil {
+			return
+		}
+
+		r := bytes.NewReader(b)
+		gm := message.GroupMessage{Version: sess.wireVersion}
+		if err := gm.Decode(r); err != nil {
+			sess.logError("failed to decode datagram", err)
+			continue
+		}
+		sess.qlog.groupOpened(false, SubscribeID(gm.SubscribeID), GroupSequence(gm.GroupSequence))
+
+		track, ok := sess.findTrackReader(SubscribeID(gm.SubscribeID))
+		if !ok {
+			continue
+		}
+		track.enqueue(receivedGroup(gm, &datagramStream{Reader: r}))
+	}
+}
+
+// datagramStream is a transport.ReceiveStream reading a group held in
+// memory, such as the group of a received datagram or a retransmitted group,
+// so that it is read with a GroupReader like a group stream.
+type datagramStream struct {
+	*bytes.Reader
+}
+
+var _ transport.ReceiveStream = (*datagramStream)(nil)
+
+func (s *datagramStream) CancelRead(transport.StreamErrorCode) {}
+
+func (s *datagramStream) SetReadDeadline(time.Time) error { return nil }
+
+// datagramSender returns the SendDatagram method of conn, or nil if conn has
+// no datagram support.
+func datagramSender(conn transport.StreamConn) func([]byte) error {
+	if dc, ok := conn.(transport.DatagramConn); ok {
+		return dc.SendDatagram
+	}
+	return nil
+}
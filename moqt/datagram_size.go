@@ -0,0 +1,112 @@
+package moqt
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+// datagramSizePollInterval is how often a session checks the maximum
+// datagram size while change callbacks are registered.
+const datagramSizePollInterval = time.Second
+
+// MaxDatagramSize returns the largest datagram payload the connection can
+// send now, or 0 if it has no datagram support or does not report the size.
+// It follows the path MTU discovered by QUIC, so it typically grows shortly
+// after the session is established, and may shrink when the path changes.
+//
+// A group sent with TrackWriter.WriteDatagram carries, in addition to the
+// frame payload, a GROUP message and a frame header, together at most 25
+// bytes, and a 4-byte checksum if the track has checksums enabled.
+func (sess *Session) MaxDatagramSize() int64 {
+	if dc, ok := sess.conn.(transport.DatagramSizeConn); ok {
+		return dc.MaxDatagramSize()
+	}
+	return 0
+}
+
+// OnMaxDatagramSizeChange registers f to be called with the new size
+// whenever MaxDatagramSize changes, until the session ends or the returned
+// stop function is called. The size is checked every second, and f is called
+// from a goroutine of the session, so it must not block.
+func (sess *Session) OnMaxDatagramSizeChange(f func(size int64)) (stop func()) {
+	return sess.datagramSize.register(f)
+}
+
+// datagramSizeWatcher calls the registered callbacks when the maximum
+// datagram size of a connection changes.
+type datagramSizeWatcher struct {
+	mu    sync.Mutex
+	funcs map[uint64]func(int64)
+	next  uint64
+	size  int64
+}
+
+func (w *datagramSizeWatcher) register(f func(int64)) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.funcs == nil {
+		w.funcs = make(map[uint64]func(int64))
+	}
+	id := w.next
+	w.next++
+	w.funcs[id] = f
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.funcs, id)
+	}
+}
+
+// update records size and calls the callbacks if it changed. The size is
+// only queried while callbacks are registered.
+func (w *datagramSizeWatcher) update(size func() int64) {
+	w.mu.Lock()
+	if len(w.funcs) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	w.mu.Unlock()
+
+	current := size()
+
+	w.mu.Lock()
+	if current == w.size {
+		w.mu.Unlock()
+		return
+	}
+	w.size = current
+	funcs := make([]func(int64), 0, len(w.funcs))
+	for _, f := range w.funcs {
+		funcs = append(funcs, f)
+	}
+	w.mu.Unlock()
+
+	for _, f := range funcs {
+		f(current)
+	}
+}
+
+// watchDatagramSize reports changes of the maximum datagram size of conn to
+// the registered callbacks until the session ends.
+func (sess *Session) watchDatagramSize(conn transport.DatagramSizeConn) {
+	// Changes are reported relative to the size at the start.
+	sess.datagramSize.mu.Lock()
+	sess.datagramSize.size = conn.MaxDatagramSize()
+	sess.datagramSize.mu.Unlock()
+
+	ticker := time.NewTicker(datagramSizePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sess.datagramSize.update(conn.MaxDatagramSize)
+		case <-sess.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package moqt
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDatagramSizeConn is a fakeDatagramConn reporting size as its maximum
+// datagram size.
+type fakeDatagramSizeConn struct {
+	*fakeDatagramConn
+	size atomic.Int64
+}
+
+func (c *fakeDatagramSizeConn) MaxDatagramSize() int64 {
+	return c.size.Load()
+}
+
+func TestDatagramSizeWatcher_Update(t *testing.T) {
+	tests := map[string]struct {
+		sizes []int64
+		want  []int64
+	}{
+		"unchanged": {
+			sizes: []int64{1200, 1200},
+			want:  nil,
+		},
+		"grows": {
+			sizes: []int64{1200, 1400, 1400},
+			want:  []int64{1400},
+		},
+		"grows and shrinks": {
+			sizes: []int64{1200, 1400, 1200},
+			want:  []int64{1400, 1200},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := datagramSizeWatcher{size: tt.sizes[0]}
+			var got []int64
+			stop := w.register(func(size int64) { got = append(got, size) })
+			defer stop()
+
+			for _, size := range tt.sizes[1:] {
+				w.update(func() int64 { return size })
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDatagramSizeWatcher_Stop(t *testing.T) {
+	var w datagramSizeWatcher
+	var queried bool
+	stop := w.register(func(int64) { t.Fatal("callback called after stop") })
+	stop()
+
+	w.update(func() int64 {
+		queried = true
+		return 1200
+	})
+	assert.False(t, queried, "the size is not queried without callbacks")
+}
+
+func TestSession_MaxDatagramSize(t *testing.T) {
+	t.Run("no datagram support", func(t *testing.T) {
+		sess := newTestSession(&FakeStreamConn{})
+		t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+		assert.Zero(t, sess.MaxDatagramSize())
+	})
+
+	t.Run("reports changes", func(t *testing.T) {
+		conn := &fakeDatagramSizeConn{
+			fakeDatagramConn: &fakeDatagramConn{FakeStreamConn: &FakeStreamConn{}, datagrams: make(chan []byte, 1)},
+		}
+		conn.size.Store(1200)
+		sess := newTestSession(conn)
+		t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+		assert.Equal(t, int64(1200), sess.MaxDatagramSize())
+
+		changed := make(chan int64, 1)
+		stop := sess.OnMaxDatagramSizeChange(func(size int64) { changed <- size })
+		defer stop()
+
+		// Wait until the watcher has recorded the initial size.
+		require.Eventually(t, func() bool {
+			sess.datagramSize.mu.Lock()
+			defer sess.datagramSize.mu.Unlock()
+			return sess.datagramSize.size == 1200
+		}, time.Second, time.Millisecond)
+		conn.size.Store(1400)
+
+		select {
+		case size := <-changed:
+			assert.Equal(t, int64(1400), size)
+		case <-time.After(3 * datagramSizePollInterval):
+			t.Fatal("size change was not reported")
+		}
+		assert.Equal(t, int64(1400), sess.MaxDatagramSize())
+	})
+}
@@ -0,0 +1,211 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDatagramConn is a FakeStreamConn that receives the datagrams sent on
+// its datagrams channel.
+type fakeDatagramConn struct {
+	*FakeStreamConn
+	datagrams chan []byte
+}
+
+func (c *fakeDatagramConn) SendDatagram(b []byte) error {
+	c.datagrams <- bytes.Clone(b)
+	return nil
+}
+
+func (c *fakeDatagramConn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-c.datagrams:
+		return b, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newDatagramTrackWriter returns a TrackWriter of subscription 1 sending
+// datagrams with send and recording the group streams it opens.
+func newDatagramTrackWriter(send func([]byte) error) (*TrackWriter, *[]*bytes.Buffer) {
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	var streams []*bytes.Buffer
+	tw := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		var buf bytes.Buffer
+		streams = append(streams, &buf)
+		return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+	}, func() {})
+	tw.sendDatagramFunc = send
+	return tw, &streams
+}
+
+func TestTrackWriter_WriteDatagram(t *testing.T) {
+	tests := map[string]struct {
+		sendErr       error
+		noSupport     bool
+		wantDatagrams int
+		wantStreams   int
+		wantMaxSize   int64
+	}{
+		"sent as datagram": {
+			wantDatagrams: 1,
+		},
+		"no datagram support": {
+			noSupport:   true,
+			wantStreams: 1,
+		},
+		"too large": {
+			sendErr:       &transport.DatagramTooLargeError{MaxDatagramPayloadSize: 4},
+			wantDatagrams: 1,
+			wantStreams:   1,
+			wantMaxSize:   4,
+		},
+		"send error": {
+			sendErr:       errors.New("datagram support disabled"),
+			wantDatagrams: 1,
+			wantStreams:   1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var datagrams [][]byte
+			send := func(b []byte) error {
+				datagrams = append(datagrams, bytes.Clone(b))
+				return tt.sendErr
+			}
+			if tt.noSupport {
+				send = nil
+			}
+			tw, streams := newDatagramTrackWriter(send)
+
+			frame := NewFrame(0)
+			_, _ = frame.Write([]byte("hello"))
+			seq, err := tw.WriteDatagram(frame)
+			require.NoError(t, err)
+			assert.Equal(t, GroupSequence(1), seq)
+			assert.Len(t, datagrams, tt.wantDatagrams)
+			assert.Len(t, *streams, tt.wantStreams)
+			assert.Equal(t, tt.wantMaxSize, tw.maxDatagramSize.Load())
+
+			// Both carry the GROUP message and the frame.
+			var data []byte
+			if tt.wantStreams > 0 {
+				data = (*streams)[0].Bytes()
+				var st message.StreamType
+				require.NoError(t, st.Decode(bytes.NewReader(data[:1])))
+				assert.Equal(t, message.StreamTypeGroup, st)
+				data = data[1:]
+			} else {
+				data = datagrams[0]
+			}
+			r := bytes.NewReader(data)
+			var gm message.GroupMessage
+			require.NoError(t, gm.Decode(r))
+			assert.Equal(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 1}, gm)
+			got := NewFrame(0)
+			require.NoError(t, got.decode(r))
+			assert.Equal(t, []byte("hello"), got.Body())
+		})
+	}
+}
+
+func TestTrackWriter_WriteDatagram_KnownTooLarge(t *testing.T) {
+	var sent int
+	tw, streams := newDatagramTrackWriter(func(b []byte) error {
+		sent++
+		return &transport.DatagramTooLargeError{MaxDatagramPayloadSize: 8}
+	})
+
+	large := NewFrame(0)
+	_, _ = large.Write(bytes.Repeat([]byte{1}, 100))
+	for range 3 {
+		_, err := tw.WriteDatagram(large)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, sent, "oversized datagrams must not be retried")
+	assert.Len(t, *streams, 3)
+}
+
+func TestTrackWriter_WriteDatagram_Closed(t *testing.T) {
+	tw, _ := newDatagramTrackWriter(func([]byte) error { return nil })
+	tw.CloseWithError(SubscribeErrorCodeInternal)
+
+	_, err := tw.WriteDatagram(NewFrame(0))
+	assert.Error(t, err)
+
+	_, err = tw.WriteDatagram(nil)
+	assert.Error(t, err)
+}
+
+func TestTrackWriter_WriteDatagram_ClosePaced(t *testing.T) {
+	tw, _ := newDatagramTrackWriter(func([]byte) error { return nil })
+	tw.SetPacingRate(8, 1) // 1 byte/s
+
+	// The datagram waits for pacing, which closing the track must not wait
+	// for.
+	errCh := make(chan error, 1)
+	go func() {
+		frame := NewFrame(0)
+		_, _ = frame.Write(make([]byte, 100))
+		_, err := tw.WriteDatagram(frame)
+		errCh <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		_ = tw.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("closing the track waited for the paced datagram")
+	}
+	assert.Error(t, <-errCh)
+}
+
+func TestSession_HandleDatagrams(t *testing.T) {
+	conn := &fakeDatagramConn{FakeStreamConn: &FakeStreamConn{}, datagrams: make(chan []byte, 8)}
+	sess := newTestSession(conn)
+	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+
+	substr := newSendSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{})
+	tr := newTrackReader("/broadcastpath", "trackname", substr, func() {})
+	sess.addTrackReader(SubscribeID(1), tr)
+
+	// A malformed datagram and one of an unknown subscription are dropped.
+	conn.datagrams <- []byte{0x40}
+	other, _ := newDatagramTrackWriter(conn.SendDatagram)
+	other.subscribeStream.subscribeID = 2
+	_, err := other.WriteDatagram(NewFrame(0))
+	require.NoError(t, err)
+
+	tw, _ := newDatagramTrackWriter(conn.SendDatagram)
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("hello"))
+	seq, err := tw.WriteDatagram(frame)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	gr, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, seq, gr.GroupSequence())
+
+	got := NewFrame(0)
+	require.NoError(t, gr.ReadFrame(got))
+	assert.Equal(t, []byte("hello"), got.Body())
+	assert.ErrorIs(t, gr.ReadFrame(got), io.EOF)
+}
@@ -0,0 +1,305 @@
+package moqt
+
+import (
+	"cmp"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxRecentErrors is the number of errors a session keeps for DebugDump.
+const maxRecentErrors = 16
+
+// errorHistory keeps the most recent errors logged by a session.
+// The zero value is ready to use.
+type errorHistory struct {
+	mu      sync.Mutex
+	entries [maxRecentErrors]errorDump
+	next    int
+	count   int
+}
+
+func (h *errorHistory) record(msg string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = errorDump{
+		Time:    time.Now(),
+		Message: msg,
+		Error:   redactAddrs(err.Error()),
+	}
+	h.next = (h.next + 1) % maxRecentErrors
+	h.count = min(h.count+1, maxRecentErrors)
+}
+
+// recent returns the recorded errors, oldest first.
+func (h *errorHistory) recent() []errorDump {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	errs := make([]errorDump, 0, h.count)
+	for i := range h.count {
+		errs = append(errs, h.entries[(h.next-h.count+i+maxRecentErrors)%maxRecentErrors])
+	}
+	return errs
+}
+
+// addrPattern matches IPv4 addresses and bracketed IPv6 addresses.
+var addrPattern = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b|\[[0-9A-Fa-f:.%]+\]`)
+
+// redactAddrs replaces IP addresses in s so that dumps can be shared publicly.
+func redactAddrs(s string) string {
+	return addrPattern.ReplaceAllString(s, "<redacted>")
+}
+
+// DebugDump writes a JSON snapshot of the session state to w, intended to be
+// attached to bug reports. It includes the negotiated protocol parameters,
+// the effective configuration, the open subscriptions and publications with
+// their group positions, queue depths and drop counters, and the most recent
+// errors logged by the session.
+//
+// The dump is sanitized: it contains no addresses, certificates or track
+// data, and IP addresses in error messages are redacted.
+func (s *Session) DebugDump(w io.Writer) error {
+	dump := sessionDump{
+		Time:        time.Now(),
+		Version:     s.version,
+		Terminating: s.terminating(),
+		Closed:      s.isClosed.Load(),
+		Config: configDump{
+			SetupTimeout:       s.config.setupTimeout().String(),
+			ProbeInterval:      s.config.probeInterval().String(),
+			ProbeMaxAge:        s.config.probeMaxAge().String(),
+			ProbeMaxDelta:      s.config.probeMaxDelta(),
+			MaxQueuedGroups:    s.config.maxQueuedGroups(),
+			AnnouncementFilter: s.config.announcementFilter() != nil,
+			QLog:               s.qlog != nil,
+			KeepAliveInterval:  s.config.keepAliveInterval().String(),
+			IdleTimeout:        s.config.idleTimeout().String(),
+		},
+		Goroutines: goroutinesDump{
+			Session: s.tasks.Running(),
+			Streams: s.streamTasks.Running(),
+		},
+		LastSubscribeID: s.subscribeIDCounter.Load(),
+		Subscriptions:   []trackReaderDump{},
+		Publications:    []trackWriterDump{},
+		RecentErrors:    s.recentErrors.recent(),
+	}
+
+	if state := s.conn.TLS(); state != nil {
+		dump.TLS = &tlsDump{
+			Version:     tls.VersionName(state.Version),
+			CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+			ALPN:        state.NegotiatedProtocol,
+		}
+	}
+
+	stats := s.Stats()
+	dump.Stats = statsDump{
+		EstimatedBitrate: stats.EstimatedBitrate,
+		RTT:              stats.RTT.String(),
+		BytesSent:        stats.BytesSent,
+		BytesReceived:    stats.BytesReceived,
+	}
+
+	s.trackReaderMapLocker.RLock()
+	readers := make(map[SubscribeID]*TrackReader, len(s.trackReaders))
+	for id, r := range s.trackReaders {
+		readers[id] = r
+	}
+	s.trackReaderMapLocker.RUnlock()
+
+	s.trackWriterMapLocker.RLock()
+	writers := make(map[SubscribeID]*TrackWriter, len(s.trackWriters))
+	for id, w := range s.trackWriters {
+		writers[id] = w
+	}
+	s.trackWriterMapLocker.RUnlock()
+
+	for id, r := range readers {
+		dump.Subscriptions = append(dump.Subscriptions, r.debugDump(id))
+	}
+	for id, w := range writers {
+		dump.Publications = append(dump.Publications, w.debugDump(id))
+	}
+	slices.SortFunc(dump.Subscriptions, func(a, b trackReaderDump) int { return cmp.Compare(a.SubscribeID, b.SubscribeID) })
+	slices.SortFunc(dump.Publications, func(a, b trackWriterDump) int { return cmp.Compare(a.SubscribeID, b.SubscribeID) })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
+
+func (r *TrackReader) debugDump(id SubscribeID) trackReaderDump {
+	r.trackMu.Lock()
+	queued := len(r.queueing)
+	latest := r.latestGroup
+	r.trackMu.Unlock()
+
+	r.groupManager.mu.Lock()
+	active := len(r.groupManager.activeGroups)
+	r.groupManager.mu.Unlock()
+
+	return trackReaderDump{
+		SubscribeID:   id,
+		BroadcastPath: r.BroadcastPath,
+		TrackName:     r.TrackName,
+		Config:        newSubscribeConfigDump(r.TrackConfig()),
+		LatestGroup:   latest,
+		QueuedGroups:  queued,
+		ActiveGroups:  active,
+		Drops:         newDropStatsDump(r.DropStats()),
+	}
+}
+
+func (w *TrackWriter) debugDump(id SubscribeID) trackWriterDump {
+	w.mu.RLock()
+	active := 0
+	if w.groupManager != nil {
+		active = w.groupManager.countGroups()
+	}
+	w.mu.RUnlock()
+
+	dump := trackWriterDump{
+		SubscribeID:   id,
+		BroadcastPath: w.BroadcastPath,
+		TrackName:     w.TrackName,
+		Config:        newSubscribeConfigDump(w.TrackConfig()),
+		LatestGroup:   GroupSequence(w.groupSequence.Load()),
+		ActiveGroups:  active,
+		Drops:         newDropStatsDump(w.DropStats()),
+	}
+	if stats, ok := w.Audience(); ok {
+		dump.Audience = &audienceDump{
+			Subscribers:   stats.Subscribers,
+			DroppedGroups: stats.DroppedGroups,
+			DroppedFrames: stats.DroppedFrames,
+		}
+	}
+	return dump
+}
+
+type sessionDump struct {
+	Time            time.Time         `json:"time"`
+	Version         string            `json:"version"`
+	TLS             *tlsDump          `json:"tls,omitempty"`
+	Terminating     bool              `json:"terminating"`
+	Closed          bool              `json:"closed"`
+	Config          configDump        `json:"config"`
+	Stats           statsDump         `json:"stats"`
+	Goroutines      goroutinesDump    `json:"goroutines"`
+	LastSubscribeID uint64            `json:"last_subscribe_id"`
+	Subscriptions   []trackReaderDump `json:"subscriptions"`
+	Publications    []trackWriterDump `json:"publications"`
+	RecentErrors    []errorDump       `json:"recent_errors"`
+}
+
+// goroutinesDump counts the running goroutines of the session by owner.
+type goroutinesDump struct {
+	Session int64 `json:"session"`
+	Streams int64 `json:"streams"`
+}
+
+type tlsDump struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+	ALPN        string `json:"alpn"`
+}
+
+type configDump struct {
+	SetupTimeout       string  `json:"setup_timeout"`
+	ProbeInterval      string  `json:"probe_interval"`
+	ProbeMaxAge        string  `json:"probe_max_age"`
+	ProbeMaxDelta      float64 `json:"probe_max_delta"`
+	MaxQueuedGroups    int     `json:"max_queued_groups"`
+	AnnouncementFilter bool    `json:"announcement_filter"`
+	QLog               bool    `json:"qlog"`
+	KeepAliveInterval  string  `json:"keep_alive_interval"`
+	IdleTimeout        string  `json:"idle_timeout"`
+}
+
+type statsDump struct {
+	EstimatedBitrate uint64 `json:"estimated_bitrate"`
+	RTT              string `json:"rtt"`
+	BytesSent        uint64 `json:"bytes_sent"`
+	BytesReceived    uint64 `json:"bytes_received"`
+}
+
+type subscribeConfigDump struct {
+	Priority   TrackPriority `json:"priority"`
+	Ordered    bool          `json:"ordered"`
+	MaxLatency uint64        `json:"max_latency_ms"`
+	StartGroup GroupSequence `json:"start_group"`
+	EndGroup   GroupSequence `json:"end_group"`
+}
+
+func newSubscribeConfigDump(config *SubscribeConfig) subscribeConfigDump {
+	if config == nil {
+		return subscribeConfigDump{}
+	}
+	return subscribeConfigDump{
+		Priority:   config.Priority,
+		Ordered:    config.Ordered,
+		MaxLatency: config.MaxLatency,
+		StartGroup: config.StartGroup,
+		EndGroup:   config.EndGroup,
+	}
+}
+
+type dropStatsDump struct {
+	Groups map[string]uint64 `json:"groups"`
+	Frames map[string]uint64 `json:"frames"`
+}
+
+func newDropStatsDump(stats DropStats) dropStatsDump {
+	dump := dropStatsDump{
+		Groups: make(map[string]uint64, len(stats.Groups)),
+		Frames: make(map[string]uint64, len(stats.Frames)),
+	}
+	for reason, n := range stats.Groups {
+		dump.Groups[reason.String()] = n
+	}
+	for reason, n := range stats.Frames {
+		dump.Frames[reason.String()] = n
+	}
+	return dump
+}
+
+type trackReaderDump struct {
+	SubscribeID   SubscribeID         `json:"subscribe_id"`
+	BroadcastPath BroadcastPath       `json:"broadcast_path"`
+	TrackName     TrackName           `json:"track_name"`
+	Config        subscribeConfigDump `json:"config"`
+	LatestGroup   GroupSequence       `json:"latest_group"`
+	QueuedGroups  int                 `json:"queued_groups"`
+	ActiveGroups  int                 `json:"active_groups"`
+	Drops         dropStatsDump       `json:"drops"`
+}
+
+type trackWriterDump struct {
+	SubscribeID   SubscribeID         `json:"subscribe_id"`
+	BroadcastPath BroadcastPath       `json:"broadcast_path"`
+	TrackName     TrackName           `json:"track_name"`
+	Config        subscribeConfigDump `json:"config"`
+	LatestGroup   GroupSequence       `json:"latest_group"`
+	ActiveGroups  int                 `json:"active_groups"`
+	Drops         dropStatsDump       `json:"drops"`
+	Audience      *audienceDump       `json:"audience,omitempty"`
+}
+
+type audienceDump struct {
+	Subscribers   uint64 `json:"subscribers"`
+	DroppedGroups uint64 `json:"dropped_groups"`
+	DroppedFrames uint64 `json:"dropped_frames"`
+}
+
+type errorDump struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Error   string    `json:"error"`
+}
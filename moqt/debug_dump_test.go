@@ -0,0 +1,135 @@
+package moqt
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_DebugDump(t *testing.T) {
+	conn := &FakeStreamConn{
+		TLSFunc: func() *tls.ConnectionState {
+			return &tls.ConnectionState{
+				Version:            tls.VersionTLS13,
+				CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+				NegotiatedProtocol: NextProtoMOQ,
+				ServerName:         "secret.example.com",
+			}
+		},
+	}
+	session := newSession(conn, NewTrackMux(0), nil, &Config{MaxQueuedGroups: 32}, nil, nil, nil, nil)
+	defer session.CloseWithError(NoError, "")
+
+	reader, _ := newTestTrackReader(t)
+	reader.enqueueGroup(3, &FakeQUICReceiveStream{})
+	reader.enqueueGroup(5, &FakeQUICReceiveStream{})
+	session.addTrackReader(2, reader)
+
+	substr := newReceiveSubscribeStream(SubscribeID(9), &FakeQUICStream{}, &SubscribeConfig{Priority: 4, Ordered: true}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/live/a", "audio", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	writer.SkipGroups(7)
+	writer.setAudience(TrackStats{Subscribers: 11})
+	writer.RecordDrop(DropEvent{Reason: DropReasonOverBudget, StartGroup: 1, EndGroup: 1, Frame: true})
+	session.addTrackWriter(9, writer)
+
+	session.logError("failed to dial", errors.New("dial udp 192.0.2.1:4433: timeout"))
+
+	var buf bytes.Buffer
+	require.NoError(t, session.DebugDump(&buf))
+	assert.NotContains(t, buf.String(), "secret.example.com", "server name should not be dumped")
+	assert.NotContains(t, buf.String(), "192.0.2.1", "addresses should be redacted")
+
+	var dump sessionDump
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &dump))
+
+	assert.Equal(t, VersionLite04, dump.Version)
+	require.NotNil(t, dump.TLS)
+	assert.Equal(t, tlsDump{Version: "TLS 1.3", CipherSuite: "TLS_AES_128_GCM_SHA256", ALPN: NextProtoMOQ}, *dump.TLS)
+	assert.Equal(t, 32, dump.Config.MaxQueuedGroups)
+	assert.Equal(t, "5s", dump.Config.SetupTimeout)
+	assert.Positive(t, dump.Goroutines.Session, "accept loops should be counted")
+
+	require.Len(t, dump.Subscriptions, 1)
+	sub := dump.Subscriptions[0]
+	assert.Equal(t, SubscribeID(2), sub.SubscribeID)
+	assert.Equal(t, GroupSequence(5), sub.LatestGroup)
+	assert.Equal(t, 2, sub.QueuedGroups)
+
+	require.Len(t, dump.Publications, 1)
+	pub := dump.Publications[0]
+	assert.Equal(t, BroadcastPath("/live/a"), pub.BroadcastPath)
+	assert.Equal(t, TrackName("audio"), pub.TrackName)
+	assert.Equal(t, subscribeConfigDump{Priority: 4, Ordered: true}, pub.Config)
+	assert.Equal(t, GroupSequence(7), pub.LatestGroup)
+	assert.Equal(t, map[string]uint64{"over_budget": 1}, pub.Drops.Frames)
+	require.NotNil(t, pub.Audience)
+	assert.Equal(t, uint64(11), pub.Audience.Subscribers)
+
+	require.Len(t, dump.RecentErrors, 1)
+	assert.Equal(t, "failed to dial", dump.RecentErrors[0].Message)
+	assert.Equal(t, "dial udp <redacted>:4433: timeout", dump.RecentErrors[0].Error)
+}
+
+func TestErrorHistory(t *testing.T) {
+	tests := map[string]struct {
+		recorded  int
+		wantFirst string
+		wantLen   int
+	}{
+		"empty": {},
+		"under capacity": {
+			recorded:  3,
+			wantFirst: "error 0",
+			wantLen:   3,
+		},
+		"wraps around": {
+			recorded:  maxRecentErrors + 5,
+			wantFirst: "error 5",
+			wantLen:   maxRecentErrors,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var h errorHistory
+			for i := range tt.recorded {
+				h.record("msg", fmt.Errorf("error %d", i))
+			}
+
+			errs := h.recent()
+			require.Len(t, errs, tt.wantLen)
+			if tt.wantLen > 0 {
+				assert.Equal(t, tt.wantFirst, errs[0].Error)
+				assert.Equal(t, fmt.Sprintf("error %d", tt.recorded-1), errs[len(errs)-1].Error, "the latest error should be last")
+			}
+		})
+	}
+}
+
+func TestRedactAddrs(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"ipv4":           {input: "dial 10.0.0.1:443 failed", want: "dial <redacted>:443 failed"},
+		"ipv6":           {input: "dial [2001:db8::1]:443 failed", want: "dial <redacted>:443 failed"},
+		"no address":     {input: "stream reset by peer", want: "stream reset by peer"},
+		"version string": {input: "moq-lite-04", want: "moq-lite-04"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactAddrs(tt.input))
+		})
+	}
+}
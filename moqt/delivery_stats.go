@@ -0,0 +1,28 @@
+package moqt
+
+import "sync/atomic"
+
+// DeliveryStats holds the data delivered on a subscription.
+type DeliveryStats struct {
+	// Groups is the number of groups accepted by AcceptGroup.
+	Groups uint64
+
+	// Frames and Bytes are the number of frames read from accepted groups
+	// and the size of their payloads.
+	Frames uint64
+	Bytes  uint64
+
+	// QueuedGroups is the number of groups received and not yet accepted.
+	QueuedGroups int
+
+	// LatestGroup is the highest group sequence received.
+	LatestGroup GroupSequence
+}
+
+// deliveryCounter counts the data delivered on a subscription. The zero
+// value is ready to use.
+type deliveryCounter struct {
+	groups atomic.Uint64
+	frames atomic.Uint64
+	bytes  atomic.Uint64
+}
@@ -3,14 +3,18 @@ package moqt
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/quic-go/quic-go"
 	"github.com/qumo-dev/gomoqt/moqt/internal/quicgo"
 	"github.com/qumo-dev/gomoqt/moqt/internal/webtransportgo"
+	"github.com/qumo-dev/gomoqt/transport"
 )
 
 // Dialer is a MOQ client that can establish sessions with MOQ servers.
@@ -37,6 +41,22 @@ type Dialer struct {
 	// If nil, the default dialer is used.
 	DialWebTransportFunc func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error)
 
+	// Proxy returns the proxy of a WebTransport session request, as
+	// http.Transport.Proxy does. WebTransport runs over UDP, which HTTP
+	// CONNECT cannot tunnel, so the proxy, an http or https URL, must
+	// support CONNECT-UDP over HTTP/1.1 (RFC 9298). The path of the URL, if
+	// any, is its URI template, with the default
+	// "/.well-known/masque/udp/{target_host}/{target_port}/". Credentials of
+	// the URL are sent with Basic authentication. If it returns a nil URL,
+	// sessions are dialed directly. If nil, the proxy is taken from the
+	// HTTPS_PROXY and NO_PROXY environment variables, as with
+	// http.ProxyFromEnvironment. Ignored with DialWebTransportFunc.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// DialProxyFunc opens the TCP connection to the proxy, over which TLS is
+	// established for https proxies. If nil, a net.Dialer is used.
+	DialProxyFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
 	// FetchHandler handles incoming fetch requests on WebTransport sessions.
 	// If nil, fetch requests on WebTransport sessions are not handled.
 	FetchHandler FetchHandler
@@ -47,6 +67,45 @@ type Dialer struct {
 
 	// Logger is used for logging connection and session events. If nil, logging is disabled.
 	Logger *slog.Logger
+
+	// AuthToken is sent as a bearer token in the Authorization header of
+	// WebTransport session requests, for the server's Authorizer. Native QUIC
+	// has no setup exchange to carry it, so it is not sent by DialQUIC.
+	AuthToken string
+
+	// ResumeToken is sent in the ResumeTokenHeader of WebTransport session
+	// requests to resume a session of which it is the Session.ResumeToken,
+	// on the same server or on a standby that took over from it (see
+	// Handoff). Client sets it when reconnecting. Native QUIC cannot carry
+	// it.
+	ResumeToken string
+
+	// Enable0RTT resumes the TLS sessions of native QUIC connections from
+	// the tickets of previous connections to the same server, cached in
+	// TLSConfig.ClientSessionCache or, if nil, in a cache of the Dialer, so
+	// that a Dialer reconnecting to a server that allows 0-RTT sends its
+	// first SUBSCRIBEs without waiting for the handshake. Only SUBSCRIBE,
+	// which is idempotent, is sent before the handshake completes, since
+	// early data may be replayed; other requests wait. WebTransport sessions
+	// are not affected. The server must allow 0-RTT with
+	// quic.Config.Allow0RTT.
+	Enable0RTT bool
+
+	// sessionCache is the cache of the TLS sessions of Enable0RTT when
+	// TLSConfig has none.
+	sessionCache tls.ClientSessionCache
+
+	// endpoint, if set, is the address dialed in place of the host of the
+	// server; see EndpointPolicy.
+	endpoint string
+
+	// Metrics receives client-side telemetry for dials and for the sessions
+	// they establish. If nil, no metrics are reported.
+	Metrics ClientMetrics
+
+	// Tracer receives the spans of session setup, subscribe handshakes and
+	// group delivery of dialed sessions. If nil, nothing is traced.
+	Tracer Tracer
 }
 
 // Dial establishes a new session to the specified URL using either WebTransport (https scheme) or QUIC (moqt scheme).
@@ -73,24 +132,47 @@ func (d *Dialer) Dial(ctx context.Context, urlStr string, mux *TrackMux) (*Sessi
 // It performs the WebTransport handshake and initializes a MOQ session.
 // `host` should be host:port and `path` is the path used for session setup.
 func (d *Dialer) DialWebTransport(ctx context.Context, host, path string, mux *TrackMux) (*Session, error) {
-	var baseLogger *slog.Logger
-	if d.Logger != nil {
-		baseLogger = d.Logger
-	} else {
-		baseLogger = slog.New(slog.DiscardHandler)
+	if err := d.Config.validate(); err != nil {
+		return nil, err
 	}
 
-	dialCtx, cancelDial := context.WithTimeout(ctx, d.Config.setupTimeout())
+	traceCtx, endSetup := startSessionSpan(ctx, d.Tracer, "webtransport", host, false)
+	dialCtx, cancelDial := context.WithTimeout(traceCtx, d.Config.setupTimeout())
 	defer cancelDial()
 
+	finish := d.dialStarted("webtransport", host, endSetup)
+
 	var dialer func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error)
 	if d.DialWebTransportFunc != nil {
 		dialer = d.DialWebTransportFunc
 	} else {
 		dialer = func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
-			return webtransportgo.Dial(ctx, addr, header, tlsConfig, []string{NextProtoMOQ})
+			dialPacket, err := d.proxyPacketDialer(addr)
+			if err != nil {
+				return nil, nil, err
+			}
+			if d.endpoint != "" {
+				dialPacket = endpointPacketDialer(d.endpoint, dialPacket)
+			}
+			return webtransportgo.Dial(ctx, addr, header, tlsConfig, d.Config.supportedVersions(), dialPacket)
 		}
 	}
+	target, header := d.webTransportRequest(host, path)
+	rsp, conn, err := dialer(dialCtx, target, header, d.TLSConfig)
+	if err == nil {
+		err = d.checkVersion(conn)
+	}
+	if err != nil {
+		finish(err)
+		return nil, err
+	}
+
+	return d.newWebTransportSession(rsp, conn, mux, traceCtx, finish), nil
+}
+
+// webTransportRequest returns the URL and the header of the WebTransport
+// session request to path on host.
+func (d *Dialer) webTransportRequest(host, path string) (string, http.Header) {
 	target := host
 	if !strings.Contains(target, "://") {
 		if path == "" {
@@ -99,29 +181,130 @@ func (d *Dialer) DialWebTransport(ctx context.Context, host, path string, mux *T
 		target = "https://" + host + path
 	}
 
-	_, conn, err := dialer(dialCtx, target, nil, d.TLSConfig)
-	if err != nil {
-		return nil, err
+	var header http.Header
+	if d.AuthToken != "" {
+		header = http.Header{"Authorization": {"Bearer " + d.AuthToken}}
+	}
+	if d.ResumeToken != "" {
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set(ResumeTokenHeader, d.ResumeToken)
 	}
+	return target, header
+}
 
-	connLogger := baseLogger.With(
-		"transport", "webtransport",
-		"local_address", conn.LocalAddr(),
-		"remote_address", conn.RemoteAddr(),
-	)
-	connLogger.Info("connection established")
+// newWebTransportSession creates a client session on an established
+// WebTransport session, which the server accepted with rsp.
+func (d *Dialer) newWebTransportSession(rsp *http.Response, conn WebTransportSession, mux *TrackMux, traceCtx context.Context, finish func(error)) *Session {
+	if d.Logger != nil {
+		d.Logger.Info("connection established",
+			"transport", "webtransport",
+			"local_address", conn.LocalAddr(),
+			"remote_address", conn.RemoteAddr(),
+		)
+	}
 
-	return newSession(conn, mux, nil, d.Config, d.FetchHandler, d.OnGoaway, d.Logger), nil
+	sess := d.newSession(conn, mux, traceCtx, finish)
+	if rsp != nil {
+		sess.resumeToken = rsp.Header.Get(ResumeTokenHeader)
+	}
+	return sess
 }
 
 // DialQUIC establishes a new session over native QUIC by dialing the provided
 // address and negotiating the transport protocol. This uses the QUIC dial
 // function configured on the Dialer (DialQUICFunc) if present.
 func (d *Dialer) DialQUIC(ctx context.Context, addr string, mux *TrackMux) (*Session, error) {
+	if err := d.Config.validate(); err != nil {
+		return nil, err
+	}
+
+	traceCtx, endSetup := startSessionSpan(ctx, d.Tracer, "quic", addr, false)
 	dialTimeout := d.Config.setupTimeout()
-	dialCtx, cancelDial := context.WithTimeout(ctx, dialTimeout)
+	dialCtx, cancelDial := context.WithTimeout(traceCtx, dialTimeout)
+	defer cancelDial()
+
+	finish := d.dialStarted("quic", addr, endSetup)
+
+	conn, err := d.dialQUICConn(dialCtx, addr, d.Config.supportedVersions(), d.QUICConfig)
+	if err == nil {
+		err = d.checkVersion(conn)
+	}
+	if err != nil {
+		finish(err)
+		return nil, err
+	}
+
+	return d.newSession(conn, mux, traceCtx, finish), nil
+}
+
+// dialAuto establishes a session to host with a single QUIC handshake that
+// offers NextProtoH3 along with the supported versions. The session runs
+// over native QUIC if the server selects a version, and over WebTransport
+// at path on the same connection if it selects NextProtoH3, which requires
+// the connections of a DialQUICFunc to come from WrapQUICConn.
+func (d *Dialer) dialAuto(ctx context.Context, host, path string, mux *TrackMux) (*Session, error) {
+	if err := d.Config.validate(); err != nil {
+		return nil, err
+	}
+
+	traceCtx, endSetup := startSessionSpan(ctx, d.Tracer, "quic", host, false)
+	dialCtx, cancelDial := context.WithTimeout(traceCtx, d.Config.setupTimeout())
 	defer cancelDial()
 
+	finish := d.dialStarted("quic", host, endSetup)
+
+	protos := append(d.Config.supportedVersions(), NextProtoH3)
+	conn, err := d.dialQUICConn(dialCtx, host, protos, webTransportQUICConfig(d.QUICConfig))
+	if err != nil {
+		finish(err)
+		return nil, err
+	}
+	if state := conn.TLS(); state == nil || state.NegotiatedProtocol != NextProtoH3 {
+		if err := d.checkVersion(conn); err != nil {
+			finish(err)
+			return nil, err
+		}
+		return d.newSession(conn, mux, traceCtx, finish), nil
+	}
+
+	qconn, ok := conn.(interface{ QUICConn() *quic.Conn })
+	if !ok || qconn.QUICConn() == nil {
+		conn.CloseWithError(transport.ConnErrorCode(InternalSessionErrorCode), "")
+		err := errors.New("moqt: WebTransport requires a connection from WrapQUICConn")
+		finish(err)
+		return nil, err
+	}
+	target, header := d.webTransportRequest(host, path)
+	rsp, wt, err := webtransportgo.DialConn(dialCtx, qconn.QUICConn(), target, header, d.Config.supportedVersions())
+	if err == nil {
+		err = d.checkVersion(wt)
+	}
+	if err != nil {
+		finish(err)
+		return nil, err
+	}
+
+	return d.newWebTransportSession(rsp, wt, mux, traceCtx, finish), nil
+}
+
+// webTransportQUICConfig returns config with the datagrams and the stream
+// resets with partial delivery that WebTransport requires enabled.
+func webTransportQUICConfig(config *quic.Config) *quic.Config {
+	if config == nil {
+		config = &quic.Config{}
+	} else {
+		config = config.Clone()
+	}
+	config.EnableDatagrams = true
+	config.EnableStreamResetPartialDelivery = true
+	return config
+}
+
+// dialQUICConn performs the QUIC handshake with addr, offering protos as the
+// ALPN protocols unless TLSConfig sets NextProtos.
+func (d *Dialer) dialQUICConn(ctx context.Context, addr string, protos []string, quicConfig *quic.Config) (StreamConn, error) {
 	tlsConfig := d.TLSConfig
 	if tlsConfig == nil {
 		tlsConfig = &tls.Config{}
@@ -129,7 +312,17 @@ func (d *Dialer) DialQUIC(ctx context.Context, addr string, mux *TrackMux) (*Ses
 		tlsConfig = tlsConfig.Clone()
 	}
 	if len(tlsConfig.NextProtos) == 0 {
-		tlsConfig.NextProtos = []string{NextProtoMOQ}
+		tlsConfig.NextProtos = protos
+	}
+	if d.Enable0RTT && tlsConfig.ClientSessionCache == nil {
+		tlsConfig.ClientSessionCache = d.clientSessionCache()
+	}
+	dialAddr := addr
+	if d.endpoint != "" {
+		dialAddr = d.endpoint
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = serverName(addr)
+		}
 	}
 
 	var dialFunc func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error)
@@ -138,10 +331,66 @@ func (d *Dialer) DialQUIC(ctx context.Context, addr string, mux *TrackMux) (*Ses
 	} else {
 		dialFunc = quicgo.DialAddrEarly
 	}
-	conn, err := dialFunc(dialCtx, addr, tlsConfig, d.QUICConfig)
-	if err != nil {
-		return nil, err
+	return dialFunc(ctx, dialAddr, tlsConfig, quicConfig)
+}
+
+// newSession creates a client session on an established connection. finish
+// is called once the session is set up, which for a connection still in
+// 0-RTT is when its handshake completes, or with the error that closed the
+// connection before.
+func (d *Dialer) newSession(conn StreamConn, mux *TrackMux, traceCtx context.Context, finish func(error)) *Session {
+	opts := &sessionOptions{
+		tracer:   d.Tracer,
+		traceCtx: traceCtx,
+	}
+	if early, ok := conn.(transport.EarlyDataConn); ok {
+		opts.early = early
 	}
+	sess := newSession(conn, mux, nil, d.Config, d.FetchHandler, d.OnGoaway, d.Logger, opts)
+	sess.metrics = d.Metrics
+	sess.startQLog(qlogClient)
 
-	return newSession(conn, mux, nil, d.Config, d.FetchHandler, d.OnGoaway, d.Logger), nil
+	if opts.early == nil {
+		finish(nil)
+		return sess
+	}
+	select {
+	case <-opts.early.HandshakeComplete():
+		finish(nil)
+	default:
+		sess.tasks.Go("session setup", func() {
+			select {
+			case <-opts.early.HandshakeComplete():
+				finish(nil)
+			case <-sess.Context().Done():
+				finish(Cause(sess.Context()))
+			}
+		}, nil)
+	}
+	return sess
+}
+
+// checkVersion closes a dialed connection on which the server selected no
+// supported version, with UnsupportedVersionErrorCode.
+func (d *Dialer) checkVersion(conn StreamConn) error {
+	if d.Config.supportsVersion(negotiatedVersion(conn)) {
+		return nil
+	}
+	conn.CloseWithError(transport.ConnErrorCode(UnsupportedVersionErrorCode), UnsupportedVersionErrorCode.String())
+	return ErrUnsupportedVersion
+}
+
+// dialStarted reports the start of a dial attempt and returns a function that
+// reports its outcome, the setup of the session or the error that ended the
+// attempt, and ends the session setup span with endSetup.
+func (d *Dialer) dialStarted(transport, addr string, endSetup func(error)) func(err error) {
+	if d.Metrics == nil {
+		return endSetup
+	}
+	d.Metrics.DialStarted(transport, addr)
+	start := time.Now()
+	return func(err error) {
+		d.Metrics.DialFinished(transport, addr, time.Since(start), err)
+		endSetup(err)
+	}
 }
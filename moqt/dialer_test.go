@@ -60,7 +60,7 @@ func TestDialer_Dial_MOQTRoutesToDialQUIC(t *testing.T) {
 			assert.WithinDuration(t, time.Now().Add(50*time.Millisecond), deadline, 250*time.Millisecond)
 			assert.Equal(t, "example.com:9000", addr)
 			require.NotNil(t, tlsConfig)
-			assert.Equal(t, []string{NextProtoMOQ}, tlsConfig.NextProtos)
+			assert.Equal(t, []string{VersionLite04Ext, NextProtoMOQ}, tlsConfig.NextProtos)
 			assert.Nil(t, quicConfig)
 
 			conn := &FakeStreamConn{}
@@ -124,7 +124,7 @@ func TestDialer_DialQUIC_DefaultTLSConfig(t *testing.T) {
 			recordedDeadline = deadline
 			assert.Equal(t, "example.com:9000", addr)
 			assert.Nil(t, quicConfig)
-			assert.Equal(t, []string{NextProtoMOQ}, tlsConfig.NextProtos)
+			assert.Equal(t, []string{VersionLite04Ext, NextProtoMOQ}, tlsConfig.NextProtos)
 
 			conn := &FakeStreamConn{}
 			return conn, nil
@@ -142,6 +142,28 @@ func TestDialer_DialQUIC_DefaultTLSConfig(t *testing.T) {
 	})
 }
 
+func TestDialer_InvalidConfig(t *testing.T) {
+	dialed := false
+	dialer := &Dialer{
+		Config: &Config{MaxQueuedGroups: -1},
+		DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+			dialed = true
+			return &FakeStreamConn{}, nil
+		},
+		DialWebTransportFunc: func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
+			dialed = true
+			return nil, nil, errors.New("dial failed")
+		},
+	}
+
+	for _, url := range []string{"moqt://example.com:9000", "https://example.com:9000/path"} {
+		sess, err := dialer.Dial(t.Context(), url, nil)
+		assert.ErrorContains(t, err, "MaxQueuedGroups", url)
+		assert.Nil(t, sess)
+	}
+	assert.False(t, dialed, "nothing should be dialed")
+}
+
 func TestDialer_DialWebTransport_CustomDialError(t *testing.T) {
 	dialErr := errors.New("dial failed")
 	dialer := &Dialer{
@@ -156,3 +178,26 @@ func TestDialer_DialWebTransport_CustomDialError(t *testing.T) {
 	assert.ErrorIs(t, err, dialErr)
 	assert.Nil(t, sess)
 }
+
+func TestDialer_DialWebTransport_ResumeToken(t *testing.T) {
+	var sent string
+	dialer := &Dialer{
+		ResumeToken: "previous",
+		DialWebTransportFunc: func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
+			sent = header.Get(ResumeTokenHeader)
+			conn := &FakeWebTransportSession{}
+			conn.AcceptStreamFunc = func(context.Context) (transport.Stream, error) { return nil, context.Canceled }
+			conn.AcceptUniStreamFunc = func(context.Context) (transport.ReceiveStream, error) { return nil, context.Canceled }
+			conn.LocalAddrFunc = func() net.Addr { return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8443} }
+			conn.RemoteAddrFunc = func() net.Addr { return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 443} }
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{ResumeTokenHeader: {"issued"}}}, conn, nil
+		},
+	}
+
+	sess, err := dialer.DialWebTransport(context.Background(), "example.com:8443", "/moq", nil)
+	require.NoError(t, err)
+	defer func() { _ = sess.CloseWithError(NoError, "") }()
+
+	assert.Equal(t, "previous", sent)
+	assert.Equal(t, "issued", sess.ResumeToken())
+}
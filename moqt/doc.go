@@ -63,6 +63,15 @@
 //		log.Fatal(err)
 //	}
 //
+// # Concurrency
+//
+// Session, TrackWriter, TrackReader, GroupWriter, and GroupReader are safe for
+// concurrent use by multiple goroutines; each exported method documents how
+// concurrent calls interact. Frame is a plain buffer and is not safe for
+// concurrent mutation, but GroupWriter.WriteFrame only reads it, so a single
+// Frame may be fanned out to several groups at once. GroupWriter.SendFrame
+// instead takes ownership of its Frame.
+//
 // # Transport customization
 //
 // Custom transport hooks can be supplied through Dialer.DialQUICFunc,
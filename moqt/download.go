@@ -0,0 +1,189 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DownloadRequest describes a finite track that Session.Download fetches to a
+// file, one FETCH per group.
+type DownloadRequest struct {
+	BroadcastPath BroadcastPath
+	TrackName     TrackName
+	Priority      TrackPriority
+
+	// FirstGroup and LastGroup are the inclusive range of groups making up
+	// the track. The range is agreed out of band, for example through the
+	// catalog.
+	FirstGroup GroupSequence
+	LastGroup  GroupSequence
+
+	// Checksum is the ChecksumMode the publisher writes the fetched groups
+	// with. A group failing verification is fetched again.
+	Checksum ChecksumMode
+
+	// Retries is the number of times a group is fetched again after a failed
+	// transfer before Download gives up. Zero means no retry.
+	Retries int
+}
+
+// downloadPartSuffix and downloadProgressSuffix name the files that hold the
+// data received so far and the resume point of an unfinished download.
+const (
+	downloadPartSuffix     = ".part"
+	downloadProgressSuffix = ".progress"
+)
+
+// downloadProgress is the resume point of an unfinished download: the next
+// group to fetch and the length of the data of the groups before it.
+type downloadProgress struct {
+	path   BroadcastPath
+	name   TrackName
+	next   GroupSequence
+	offset int64
+}
+
+// Download fetches the groups of a finite track in order and writes the
+// concatenated frame payloads to the file name.
+//
+// Data is written to name+".part" and, after each complete group, the resume
+// point is recorded in name+".progress". If Download is interrupted, by ctx,
+// a closed session or exhausted retries, calling it again with the same
+// request and name, on this or a new session, resumes after the last complete
+// group. A progress file of another track is discarded and the download starts
+// over. When the last group has been received, the part file is renamed to
+// name and the progress file is removed.
+func (s *Session) Download(ctx context.Context, req *DownloadRequest, name string) error {
+	if req == nil {
+		return errors.New("moqt: nil download request")
+	}
+	if req.LastGroup < req.FirstGroup {
+		return fmt.Errorf("moqt: invalid download range %s-%s", req.FirstGroup, req.LastGroup)
+	}
+
+	part, err := os.OpenFile(name+downloadPartSuffix, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	progress := readDownloadProgress(name + downloadProgressSuffix)
+	if progress == nil || progress.path != req.BroadcastPath || progress.name != req.TrackName ||
+		progress.next < req.FirstGroup || progress.next > req.LastGroup+1 {
+		progress = &downloadProgress{path: req.BroadcastPath, name: req.TrackName, next: req.FirstGroup}
+	}
+
+	for seq := progress.next; seq <= req.LastGroup; seq++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := s.downloadGroup(ctx, req, seq, part, progress.offset)
+		if err != nil {
+			return fmt.Errorf("moqt: failed to download group %s: %w", seq, err)
+		}
+		if err := part.Sync(); err != nil {
+			return err
+		}
+
+		progress.next = seq + 1
+		progress.offset += n
+		if err := writeDownloadProgress(name+downloadProgressSuffix, progress); err != nil {
+			return err
+		}
+	}
+
+	if err := part.Truncate(progress.offset); err != nil {
+		return err
+	}
+	if err := part.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(name+downloadPartSuffix, name); err != nil {
+		return err
+	}
+	return os.Remove(name + downloadProgressSuffix)
+}
+
+// downloadGroup fetches group seq and writes its payloads to part at offset,
+// retrying failed transfers. It returns the number of bytes written.
+func (s *Session) downloadGroup(ctx context.Context, req *DownloadRequest, seq GroupSequence, part *os.File, offset int64) (int64, error) {
+	var err error
+	for attempt := 0; attempt <= req.Retries; attempt++ {
+		var n int64
+		n, err = s.fetchGroupTo(ctx, req, seq, io.NewOffsetWriter(part, offset))
+		if err == nil {
+			return n, nil
+		}
+		if ctx.Err() != nil || s.terminating() {
+			break
+		}
+	}
+	return 0, err
+}
+
+// fetchGroupTo fetches group seq and writes its payloads to w.
+func (s *Session) fetchGroupTo(ctx context.Context, req *DownloadRequest, seq GroupSequence, w io.Writer) (int64, error) {
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	group, err := s.Fetch((&FetchRequest{
+		BroadcastPath: req.BroadcastPath,
+		TrackName:     req.TrackName,
+		Priority:      req.Priority,
+		GroupSequence: seq,
+	}).WithContext(groupCtx))
+	if err != nil {
+		return 0, err
+	}
+	group.SetChecksum(req.Checksum, ChecksumPolicyCancel)
+
+	var n int64
+	frame := NewFrame(0)
+	for {
+		err := group.ReadFrame(frame)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		m, err := w.Write(frame.Body())
+		n += int64(m)
+		if err != nil {
+			group.CancelRead(InternalGroupErrorCode)
+			return 0, err
+		}
+	}
+}
+
+// readDownloadProgress reads a progress file, returning nil if it is missing
+// or malformed.
+func readDownloadProgress(name string) *downloadProgress {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	var (
+		p           downloadProgress
+		path, track string
+	)
+	if _, err := fmt.Sscanf(string(data), "%q %q %d %d\n", &path, &track, &p.next, &p.offset); err != nil {
+		return nil
+	}
+	p.path, p.name = BroadcastPath(path), TrackName(track)
+	return &p
+}
+
+// writeDownloadProgress replaces the progress file atomically.
+func writeDownloadProgress(name string, p *downloadProgress) error {
+	tmp := name + ".tmp"
+	data := fmt.Sprintf("%q %q %d %d\n", p.path, p.name, uint64(p.next), p.offset)
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
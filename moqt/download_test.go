@@ -0,0 +1,253 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fetchPeer serves FETCH streams of a FakeStreamConn from groups, keyed by
+// group sequence, and records the fetched sequences.
+type fetchPeer struct {
+	groups   map[GroupSequence][]string
+	checksum ChecksumMode
+	// fail reports whether an attempt to fetch seq is reset or, if corrupt
+	// is set, delivered with a corrupted checksum.
+	fail    func(seq GroupSequence, attempt int) bool
+	corrupt bool
+
+	mu      sync.Mutex
+	fetched []GroupSequence
+}
+
+func (p *fetchPeer) openStream() (transport.Stream, error) {
+	var request bytes.Buffer
+	var response *bytes.Reader
+	stream := &FakeQUICStream{WriteFunc: request.Write}
+	stream.ReadFunc = func(b []byte) (int, error) {
+		if response == nil {
+			data, err := p.respond(&request)
+			if err != nil {
+				return 0, err
+			}
+			response = bytes.NewReader(data)
+		}
+		return response.Read(b)
+	}
+	return stream, nil
+}
+
+func (p *fetchPeer) respond(request *bytes.Buffer) ([]byte, error) {
+	var st message.StreamType
+	if err := st.Decode(request); err != nil {
+		return nil, err
+	}
+	var fm message.FetchMessage
+	if err := fm.Decode(request); err != nil {
+		return nil, err
+	}
+	seq := GroupSequence(fm.GroupSequence)
+
+	p.mu.Lock()
+	attempt := 0
+	for _, fetched := range p.fetched {
+		if fetched == seq {
+			attempt++
+		}
+	}
+	p.fetched = append(p.fetched, seq)
+	p.mu.Unlock()
+
+	failed := p.fail != nil && p.fail(seq, attempt)
+	if failed && !p.corrupt {
+		return nil, &transport.StreamError{ErrorCode: transport.StreamErrorCode(FetchErrorCodeInternal), Remote: true}
+	}
+
+	var buf bytes.Buffer
+	group := newGroupWriter(&FakeQUICSendStream{WriteFunc: buf.Write}, seq, nil)
+	group.SetChecksum(p.checksum)
+	for _, payload := range p.groups[seq] {
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte(payload))
+		if err := group.WriteFrame(frame); err != nil {
+			return nil, err
+		}
+	}
+	data := buf.Bytes()
+	if failed {
+		data[len(data)-1] ^= 0xff
+	}
+	return data, nil
+}
+
+func (p *fetchPeer) fetchedGroups() []GroupSequence {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]GroupSequence(nil), p.fetched...)
+}
+
+func newDownloadSession(t *testing.T, peer *fetchPeer) *Session {
+	t.Helper()
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = peer.openStream
+	})
+	return session
+}
+
+var downloadGroups = map[GroupSequence][]string{
+	1: {"hello, ", "world"},
+	2: {"!"},
+	3: {" bye"},
+}
+
+func TestSession_Download(t *testing.T) {
+	tests := map[string]struct {
+		peer        *fetchPeer
+		retries     int
+		wantErr     bool
+		wantFetched []GroupSequence
+	}{
+		"complete": {
+			peer:        &fetchPeer{groups: downloadGroups},
+			wantFetched: []GroupSequence{1, 2, 3},
+		},
+		"with checksums": {
+			peer:        &fetchPeer{groups: downloadGroups, checksum: ChecksumGroup},
+			wantFetched: []GroupSequence{1, 2, 3},
+		},
+		"reset retried": {
+			peer: &fetchPeer{
+				groups: downloadGroups,
+				fail:   func(seq GroupSequence, attempt int) bool { return seq == 2 && attempt == 0 },
+			},
+			retries:     1,
+			wantFetched: []GroupSequence{1, 2, 2, 3},
+		},
+		"checksum mismatch retried": {
+			peer: &fetchPeer{
+				groups:   downloadGroups,
+				checksum: ChecksumFrame,
+				corrupt:  true,
+				fail:     func(seq GroupSequence, attempt int) bool { return seq == 1 && attempt == 0 },
+			},
+			retries:     1,
+			wantFetched: []GroupSequence{1, 1, 2, 3},
+		},
+		"retries exhausted": {
+			peer: &fetchPeer{
+				groups: downloadGroups,
+				fail:   func(seq GroupSequence, attempt int) bool { return seq == 2 },
+			},
+			retries:     2,
+			wantErr:     true,
+			wantFetched: []GroupSequence{1, 2, 2, 2},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			session := newDownloadSession(t, tt.peer)
+			file := filepath.Join(t.TempDir(), "track.bin")
+
+			err := session.Download(t.Context(), &DownloadRequest{
+				BroadcastPath: "/files/a",
+				TrackName:     "data",
+				FirstGroup:    1,
+				LastGroup:     3,
+				Checksum:      tt.peer.checksum,
+				Retries:       tt.retries,
+			}, file)
+			assert.Equal(t, tt.wantFetched, tt.peer.fetchedGroups())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.NoFileExists(t, file)
+				assert.FileExists(t, file+downloadProgressSuffix)
+				return
+			}
+			require.NoError(t, err)
+			data, err := os.ReadFile(file)
+			require.NoError(t, err)
+			assert.Equal(t, "hello, world! bye", string(data))
+			assert.NoFileExists(t, file+downloadPartSuffix)
+			assert.NoFileExists(t, file+downloadProgressSuffix)
+		})
+	}
+}
+
+func TestSession_Download_Resume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "track.bin")
+	req := &DownloadRequest{
+		BroadcastPath: "/files/a",
+		TrackName:     "data",
+		FirstGroup:    1,
+		LastGroup:     3,
+	}
+
+	interrupted := &fetchPeer{
+		groups: downloadGroups,
+		fail:   func(seq GroupSequence, attempt int) bool { return seq == 3 },
+	}
+	require.Error(t, newDownloadSession(t, interrupted).Download(t.Context(), req, file))
+
+	resumed := &fetchPeer{groups: downloadGroups}
+	require.NoError(t, newDownloadSession(t, resumed).Download(t.Context(), req, file))
+	assert.Equal(t, []GroupSequence{3}, resumed.fetchedGroups())
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world! bye", string(data))
+}
+
+func TestSession_Download_OtherTrackProgress(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "track.bin")
+	require.NoError(t, os.WriteFile(file+downloadPartSuffix, []byte("stale data"), 0o644))
+	require.NoError(t, writeDownloadProgress(file+downloadProgressSuffix, &downloadProgress{
+		path: "/files/b", name: "data", next: 3, offset: 10,
+	}))
+
+	peer := &fetchPeer{groups: downloadGroups}
+	err := newDownloadSession(t, peer).Download(t.Context(), &DownloadRequest{
+		BroadcastPath: "/files/a",
+		TrackName:     "data",
+		FirstGroup:    1,
+		LastGroup:     3,
+	}, file)
+	require.NoError(t, err)
+	assert.Equal(t, []GroupSequence{1, 2, 3}, peer.fetchedGroups())
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world! bye", string(data))
+}
+
+func TestSession_Download_Canceled(t *testing.T) {
+	peer := &fetchPeer{groups: downloadGroups}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	err := newDownloadSession(t, peer).Download(ctx, &DownloadRequest{
+		FirstGroup: 1,
+		LastGroup:  3,
+		Retries:    5,
+	}, filepath.Join(t.TempDir(), "track.bin"))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, peer.fetchedGroups())
+}
+
+func TestSession_Download_InvalidRange(t *testing.T) {
+	session := newDownloadSession(t, &fetchPeer{})
+	file := filepath.Join(t.TempDir(), "track.bin")
+
+	assert.Error(t, session.Download(t.Context(), nil, file))
+	assert.Error(t, session.Download(t.Context(), &DownloadRequest{FirstGroup: 2, LastGroup: 1}, file))
+	assert.NoFileExists(t, file+downloadPartSuffix)
+}
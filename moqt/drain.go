@@ -0,0 +1,172 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+// Drain takes the server out of service without interrupting the
+// subscriptions it serves, such as to remove a relay from a load balancer:
+//
+//   - new connections are refused, and new subscriptions on existing
+//     sessions are rejected with SubscribeErrorCodeGoingAway;
+//   - every session is sent a GOAWAY with newURI, where clients may
+//     reconnect;
+//   - a session is closed with NoError once it has no subscription left in
+//     either direction, or when its peer closes it.
+//
+// Drain returns once every session has closed. If ctx ends first, the
+// remaining sessions are closed with GoAwayTimeoutErrorCode and ctx's error
+// is returned. Sessions set up while Drain starts are drained as well. The server stays draining afterwards: its listeners keep
+// refusing connections until Close or Shutdown is called.
+//
+// Drain returns ErrServerClosed if the server is shutting down, and
+// ErrServerDraining if it is already draining.
+func (s *Server) Drain(ctx context.Context, newURI string) error {
+	if s.shuttingDown() {
+		return ErrServerClosed
+	}
+	if !s.drain.CompareAndSwap(nil, &drainRequest{ctx: ctx, newURI: newURI}) {
+		return ErrServerDraining
+	}
+	s.init()
+
+	// Sessions registered from now on that are not in the snapshot below
+	// are drained by drainLate.
+
+	sessions := make(map[StreamConn]*Session)
+	for _, sess := range s.connManager.sessions() {
+		sessions[sess.conn] = sess
+	}
+
+	var wg sync.WaitGroup
+	for _, conn := range s.connManager.conns() {
+		sess := sessions[conn]
+		wg.Go(func() {
+			if err := s.drainConn(ctx, conn, sess, newURI); err != nil {
+				if logger := s.Logger; logger != nil {
+					logger.Error("error sending GOAWAY to connection during drain", "error", err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	// Wait for the sessions to close, including those drained by drainLate.
+	select {
+	case <-s.connManager.Done():
+	case <-ctx.Done():
+		for _, conn := range s.connManager.conns() {
+			conn.CloseWithError(transport.ConnErrorCode(GoAwayTimeoutErrorCode), GoAwayTimeoutErrorCode.String())
+		}
+	}
+
+	return ctx.Err()
+}
+
+// drainRequest is the call to Drain a server is draining for.
+type drainRequest struct {
+	ctx    context.Context
+	newURI string
+}
+
+// drainLate drains sess if the server is draining. A connection may pass
+// the draining checks before Drain is called and set up its session after
+// Drain took its snapshot of the sessions.
+func (s *Server) drainLate(sess *Session) {
+	d := s.drain.Load()
+	if d == nil || sess.goAwaySent.Load() {
+		return
+	}
+	s.tasks.Go("late session drainer", func() {
+		if err := s.drainConn(d.ctx, sess.conn, sess, d.newURI); err != nil {
+			if logger := s.Logger; logger != nil {
+				logger.Error("error sending GOAWAY to connection during drain", "error", err)
+			}
+		}
+	}, nil)
+}
+
+// drainConn sends a GOAWAY with newURI on conn, then waits until the
+// subscriptions of its session, if known, have ended, and closes it. If ctx
+// ends first, conn is closed with GoAwayTimeoutErrorCode.
+func (s *Server) drainConn(ctx context.Context, conn StreamConn, sess *Session, newURI string) error {
+	var (
+		drained <-chan struct{}
+		err     error
+	)
+	if sess != nil {
+		drained = sess.startDrain()
+		err = sess.GoAway(newURI)
+		if errors.Is(err, ErrClosedSession) {
+			return nil
+		}
+	} else {
+		err = sendGoAway(conn, newURI)
+	}
+	if err != nil {
+		conn.CloseWithError(transport.ConnErrorCode(GoAwayTimeoutErrorCode), GoAwayTimeoutErrorCode.String())
+		return err
+	}
+
+	select {
+	case <-conn.Context().Done():
+	case <-drained:
+		conn.CloseWithError(transport.ConnErrorCode(NoError), "drained")
+	case <-ctx.Done():
+		conn.CloseWithError(transport.ConnErrorCode(GoAwayTimeoutErrorCode), GoAwayTimeoutErrorCode.String())
+	}
+	return nil
+}
+
+// draining reports whether Drain was called.
+func (s *Server) draining() bool {
+	return s.drain.Load() != nil
+}
+
+// startDrain makes the session reject new subscriptions and returns a
+// channel that is closed once it has no subscription left. It may be called
+// more than once.
+func (sess *Session) startDrain() <-chan struct{} {
+	sess.drainMu.Lock()
+	if sess.drained == nil {
+		sess.drained = make(chan struct{})
+	}
+	drained := sess.drained
+	sess.drainMu.Unlock()
+
+	sess.isDraining.Store(true)
+	sess.checkDrained()
+	return drained
+}
+
+// checkDrained closes the drained channel of a draining session that has no
+// subscription left.
+func (sess *Session) checkDrained() {
+	if !sess.isDraining.Load() {
+		return
+	}
+
+	sess.trackReaderMapLocker.RLock()
+	readers := len(sess.trackReaders)
+	sess.trackReaderMapLocker.RUnlock()
+
+	sess.trackWriterMapLocker.RLock()
+	writers := len(sess.trackWriters)
+	sess.trackWriterMapLocker.RUnlock()
+
+	if readers > 0 || writers > 0 {
+		return
+	}
+
+	sess.drainMu.Lock()
+	defer sess.drainMu.Unlock()
+	select {
+	case <-sess.drained:
+	default:
+		close(sess.drained)
+	}
+}
@@ -0,0 +1,292 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goAwayRecorder records the GOAWAY messages sent on the streams it opens.
+type goAwayRecorder struct {
+	mu   sync.Mutex
+	uris []string
+}
+
+func (r *goAwayRecorder) openStream() (transport.Stream, error) {
+	var buf bytes.Buffer
+	stream := &FakeQUICStream{
+		WriteFunc: buf.Write,
+	}
+	stream.CloseFunc = func() error {
+		var st message.StreamType
+		var gm message.GoawayMessage
+		if st.Decode(&buf) == nil && st == message.StreamTypeGoaway && gm.Decode(&buf) == nil {
+			r.mu.Lock()
+			r.uris = append(r.uris, gm.NewSessionURI)
+			r.mu.Unlock()
+		}
+		return nil
+	}
+	return stream, nil
+}
+
+func (r *goAwayRecorder) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.uris...)
+}
+
+// newDrainTestServer returns a server serving a native QUIC session on conn
+// until it is closed, and the session.
+func newDrainTestServer(t *testing.T, conn *FakeStreamConn) (*Server, *Session) {
+	t.Helper()
+
+	s := &Server{
+		Handler: HandleFunc(func(sess *Session) {
+			<-sess.Context().Done()
+		}),
+	}
+	s.init()
+	t.Cleanup(func() { _ = s.Close() })
+
+	go func() {
+		_ = s.ServeQUICConn(conn)
+	}()
+	require.Eventually(t, func() bool {
+		return len(s.Sessions()) == 1
+	}, time.Second, time.Millisecond)
+	return s, s.Sessions()[0].Session
+}
+
+func closeCode(t *testing.T, conn StreamConn) SessionErrorCode {
+	t.Helper()
+	appErr, ok := errors.AsType[*transport.ApplicationError](context.Cause(conn.Context()))
+	require.True(t, ok, "connection should be closed with an application error")
+	return SessionErrorCode(appErr.ErrorCode)
+}
+
+func TestServer_Drain(t *testing.T) {
+	tests := map[string]struct {
+		// endSubscription ends the subscription of the session during Drain.
+		endSubscription bool
+		wantErr         error
+		wantCode        SessionErrorCode
+	}{
+		"subscriptions finish": {
+			endSubscription: true,
+			wantCode:        NoError,
+		},
+		"deadline exceeded": {
+			endSubscription: false,
+			wantErr:         context.DeadlineExceeded,
+			wantCode:        GoAwayTimeoutErrorCode,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var goAways goAwayRecorder
+			conn := newTestNativeQUICConn(t, func(conn *FakeStreamConn) {
+				conn.OpenStreamFunc = goAways.openStream
+			})
+			s, sess := newDrainTestServer(t, conn)
+			sess.addTrackWriter(1, &TrackWriter{})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- s.Drain(ctx, "https://backup.example.com/moq")
+			}()
+
+			require.Eventually(t, func() bool {
+				return len(goAways.sent()) == 1
+			}, time.Second, time.Millisecond)
+			assert.Equal(t, []string{"https://backup.example.com/moq"}, goAways.sent())
+			assert.NoError(t, conn.Context().Err(), "the session should wait for its subscriptions")
+
+			if tt.endSubscription {
+				sess.removeTrackWriter(1)
+			}
+			select {
+			case err := <-errCh:
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				} else {
+					assert.NoError(t, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Drain did not return")
+			}
+			assert.Equal(t, tt.wantCode, closeCode(t, conn))
+			assert.Empty(t, s.Sessions())
+		})
+	}
+}
+
+func TestServer_Drain_Late(t *testing.T) {
+	var goAways goAwayRecorder
+	conn := newTestNativeQUICConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = goAways.openStream
+	})
+	s, sess := newDrainTestServer(t, conn)
+	sess.addTrackWriter(1, &TrackWriter{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Drain(ctx, "https://backup.example.com/moq")
+	}()
+	require.Eventually(t, func() bool {
+		return len(goAways.sent()) == 1
+	}, time.Second, time.Millisecond)
+
+	// A connection that passed the draining check before Drain sets up its
+	// session after the snapshot.
+	var lateGoAways goAwayRecorder
+	late := newTestNativeQUICConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = lateGoAways.openStream
+	})
+	go func() {
+		_ = s.handleNativeQUIC(late)
+	}()
+	require.Eventually(t, func() bool {
+		return len(lateGoAways.sent()) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"https://backup.example.com/moq"}, lateGoAways.sent())
+	require.Eventually(t, func() bool {
+		return late.Context().Err() != nil
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, NoError, closeCode(t, late))
+
+	// A connection still tracked when ctx ends is closed.
+	leftover := newTestNativeQUICConn(t)
+	s.connManager.addConn(leftover)
+	sess.removeTrackWriter(1)
+
+	select {
+	case err := <-errCh:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not return")
+	}
+	assert.Equal(t, GoAwayTimeoutErrorCode, closeCode(t, leftover))
+}
+
+func TestServer_Drain_RefusesConnections(t *testing.T) {
+	s := &Server{
+		Handler: HandleFunc(func(sess *Session) {
+			t.Error("handler should not be called")
+		}),
+	}
+	require.NoError(t, s.Drain(context.Background(), ""))
+
+	conn := newTestNativeQUICConn(t)
+	assert.ErrorIs(t, s.ServeQUICConn(conn), ErrServerDraining)
+	assert.Equal(t, NoError, closeCode(t, conn))
+
+	// WebTransport upgrades through another HTTP/3 server are refused too.
+	u := &WebTransportHandler{
+		UpgradeFunc: func(w http.ResponseWriter, r *http.Request) (WebTransportSession, error) {
+			t.Error("upgrade should not be attempted")
+			return nil, errors.New("unexpected upgrade")
+		},
+		Handler: s.Handler,
+	}
+	r, _ := http.NewRequest(http.MethodConnect, "https://example.com/moq", nil)
+	r.TLS = &tls.ConnectionState{}
+	r = r.WithContext(s.connContext(context.Background(), &FakeStreamConn{}))
+	var status int
+	w := &FakeHTTPResponseWriter{
+		WriteHeaderFunc: func(statusCode int) { status = statusCode },
+	}
+	u.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+}
+
+func TestServer_Drain_States(t *testing.T) {
+	s := &Server{}
+	require.NoError(t, s.Drain(context.Background(), ""))
+	assert.ErrorIs(t, s.Drain(context.Background(), ""), ErrServerDraining)
+
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, s.Drain(context.Background(), ""), ErrServerClosed)
+}
+
+func TestSession_Draining_RejectsSubscriptions(t *testing.T) {
+	mux := NewTrackMux(0)
+	mux.PublishFunc(context.Background(), "/test/path", func(tw *TrackWriter) {
+		t.Error("track handler should not be called")
+	})
+	sess := newSession(&FakeStreamConn{}, mux, nil, nil, nil, nil, nil, nil)
+	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+
+	drained := sess.startDrain()
+	select {
+	case <-drained:
+	default:
+		t.Fatal("a session without subscriptions should be drained")
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, message.StreamTypeSubscribe.Encode(&buf))
+	require.NoError(t, message.SubscribeMessage{
+		SubscribeID:   1,
+		BroadcastPath: "/test/path",
+		TrackName:     "video",
+	}.Encode(&buf))
+
+	var code transport.StreamErrorCode
+	stream := &FakeQUICStream{
+		ReadFunc: buf.Read,
+		CancelWriteFunc: func(c transport.StreamErrorCode) {
+			code = c
+		},
+	}
+	sess.processBiStream(stream, func() {})
+	assert.Equal(t, transport.StreamErrorCode(SubscribeErrorCodeGoingAway), code)
+}
+
+func TestSession_GoAway(t *testing.T) {
+	var goAways goAwayRecorder
+	conn := &FakeStreamConn{OpenStreamFunc: goAways.openStream}
+	sess := newTestSession(conn)
+
+	require.NoError(t, sess.GoAway("https://relay-2.example.com/moq"))
+	assert.Equal(t, []string{"https://relay-2.example.com/moq"}, goAways.sent())
+	assert.True(t, sess.isDraining.Load(), "new subscriptions should be rejected")
+	assert.NoError(t, conn.Context().Err(), "the session should stay open")
+
+	// Only one GOAWAY is sent.
+	require.NoError(t, sess.GoAway("https://relay-3.example.com/moq"))
+	assert.Len(t, goAways.sent(), 1)
+
+	require.NoError(t, sess.CloseWithError(NoError, ""))
+	assert.ErrorIs(t, sess.GoAway(""), ErrClosedSession)
+}
+
+func TestSession_GoAway_OpenStreamFails(t *testing.T) {
+	conn := &FakeStreamConn{
+		OpenStreamFunc: func() (transport.Stream, error) {
+			return nil, &transport.ApplicationError{ErrorCode: transport.ApplicationErrorCode(InternalSessionErrorCode), Remote: true}
+		},
+	}
+	sess := newTestSession(conn)
+	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+
+	err := sess.GoAway("")
+	sessErr, ok := errors.AsType[*SessionError](err)
+	require.True(t, ok)
+	assert.Equal(t, InternalSessionErrorCode, sessErr.SessionErrorCode())
+}
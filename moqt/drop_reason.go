@@ -0,0 +1,152 @@
+package moqt
+
+import (
+	"sync/atomic"
+)
+
+// DropReason classifies why a frame or group was not delivered, so that
+// quality regressions can be attributed to the policy that caused them.
+type DropReason uint8
+
+const (
+	// DropReasonUnknown is used when no more specific reason applies.
+	DropReasonUnknown DropReason = iota
+	// DropReasonStale marks data that expired before it could be delivered,
+	// such as groups canceled with ExpiredGroupErrorCode or OutOfRangeErrorCode
+	// and ranges dropped with SubscribeErrorCodeTimeout.
+	DropReasonStale
+	// DropReasonReset marks groups whose stream was reset before completion.
+	DropReasonReset
+	// DropReasonOverBudget marks data skipped to stay within a bitrate or
+	// latency budget. Applications report it through RecordDrop.
+	DropReasonOverBudget
+	// DropReasonPolicy marks data discarded by a configured policy, such as a
+	// ChecksumPolicy, a SUBSCRIBE_DROP sent by the publisher or a
+	// FrameInterceptor.
+	DropReasonPolicy
+
+	dropReasonCount
+)
+
+// String returns a text for the drop reason.
+func (r DropReason) String() string {
+	switch r {
+	case DropReasonUnknown:
+		return "unknown"
+	case DropReasonStale:
+		return "stale"
+	case DropReasonReset:
+		return "reset"
+	case DropReasonOverBudget:
+		return "over_budget"
+	case DropReasonPolicy:
+		return "policy"
+	default:
+		return "invalid"
+	}
+}
+
+// groupDropReason returns the reason for a group canceled with code.
+func groupDropReason(code GroupErrorCode) DropReason {
+	switch code {
+	case ExpiredGroupErrorCode, OutOfRangeErrorCode:
+		return DropReasonStale
+	default:
+		return DropReasonReset
+	}
+}
+
+// subscribeDropReason returns the reason for a SUBSCRIBE_DROP with code.
+func subscribeDropReason(code SubscribeErrorCode) DropReason {
+	if code == SubscribeErrorCodeTimeout {
+		return DropReasonStale
+	}
+	return DropReasonPolicy
+}
+
+// DropEvent describes frames or groups of a subscription that were not
+// delivered.
+type DropEvent struct {
+	// Reason is why the data was dropped.
+	Reason DropReason
+
+	// StartGroup and EndGroup are the inclusive range of affected groups.
+	// For a frame drop both are the group the frame belongs to.
+	StartGroup GroupSequence
+	EndGroup   GroupSequence
+
+	// Frame reports whether a single frame was dropped rather than whole groups.
+	Frame bool
+}
+
+// DropStats holds the number of dropped groups and frames of a subscription
+// by reason. Reasons without drops are absent from the maps.
+type DropStats struct {
+	Groups map[DropReason]uint64
+	Frames map[DropReason]uint64
+}
+
+// dropRecorder counts the drops of one subscription and forwards them to an
+// optional trace hook. The zero value is ready to use.
+type dropRecorder struct {
+	groups [dropReasonCount]atomic.Uint64
+	frames [dropReasonCount]atomic.Uint64
+
+	trace atomic.Pointer[func(DropEvent)]
+}
+
+// record counts ev and passes it to the trace hook.
+func (d *dropRecorder) record(ev DropEvent) {
+	if d == nil {
+		return
+	}
+	if ev.Reason >= dropReasonCount {
+		ev.Reason = DropReasonUnknown
+	}
+
+	if ev.Frame {
+		d.frames[ev.Reason].Add(1)
+	} else if ev.EndGroup >= ev.StartGroup {
+		d.groups[ev.Reason].Add(uint64(ev.EndGroup-ev.StartGroup) + 1)
+	}
+
+	if trace := d.trace.Load(); trace != nil {
+		(*trace)(ev)
+	}
+}
+
+// recordGroup records a drop of the single group seq.
+func (d *dropRecorder) recordGroup(reason DropReason, seq GroupSequence) {
+	d.record(DropEvent{Reason: reason, StartGroup: seq, EndGroup: seq})
+}
+
+// recordFrame records a drop of one frame of group seq.
+func (d *dropRecorder) recordFrame(reason DropReason, seq GroupSequence) {
+	d.record(DropEvent{Reason: reason, StartGroup: seq, EndGroup: seq, Frame: true})
+}
+
+// setTrace installs f as the trace hook; nil removes it.
+func (d *dropRecorder) setTrace(f func(DropEvent)) {
+	if f == nil {
+		d.trace.Store(nil)
+		return
+	}
+	d.trace.Store(&f)
+}
+
+// stats returns a snapshot of the counters.
+func (d *dropRecorder) stats() DropStats {
+	stats := DropStats{
+		Groups: make(map[DropReason]uint64),
+		Frames: make(map[DropReason]uint64),
+	}
+	for reason := range dropReasonCount {
+		if n := d.groups[reason].Load(); n > 0 {
+			stats.Groups[reason] = n
+		}
+		if n := d.frames[reason].Load(); n > 0 {
+			stats.Frames[reason] = n
+		}
+	}
+	return stats
+}
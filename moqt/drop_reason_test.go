@@ -0,0 +1,183 @@
+package moqt
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropReason_String(t *testing.T) {
+	tests := map[DropReason]string{
+		DropReasonUnknown:    "unknown",
+		DropReasonStale:      "stale",
+		DropReasonReset:      "reset",
+		DropReasonOverBudget: "over_budget",
+		DropReasonPolicy:     "policy",
+		DropReason(99):       "invalid",
+	}
+	for reason, want := range tests {
+		assert.Equal(t, want, reason.String())
+	}
+}
+
+func TestGroupDropReason(t *testing.T) {
+	assert.Equal(t, DropReasonStale, groupDropReason(ExpiredGroupErrorCode))
+	assert.Equal(t, DropReasonStale, groupDropReason(OutOfRangeErrorCode))
+	assert.Equal(t, DropReasonReset, groupDropReason(PublishAbortedErrorCode))
+	assert.Equal(t, DropReasonReset, groupDropReason(InternalGroupErrorCode))
+}
+
+func TestSubscribeDropReason(t *testing.T) {
+	assert.Equal(t, DropReasonStale, subscribeDropReason(SubscribeErrorCodeTimeout))
+	assert.Equal(t, DropReasonPolicy, subscribeDropReason(SubscribeErrorCodeInternal))
+}
+
+func TestDropRecorder(t *testing.T) {
+	var d dropRecorder
+	var events []DropEvent
+	d.setTrace(func(ev DropEvent) { events = append(events, ev) })
+
+	d.record(DropEvent{Reason: DropReasonPolicy, StartGroup: 2, EndGroup: 4})
+	d.recordGroup(DropReasonReset, 7)
+	d.recordFrame(DropReasonOverBudget, 8)
+	d.recordFrame(DropReason(99), 8)
+
+	stats := d.stats()
+	assert.Equal(t, map[DropReason]uint64{DropReasonPolicy: 3, DropReasonReset: 1}, stats.Groups)
+	assert.Equal(t, map[DropReason]uint64{DropReasonOverBudget: 1, DropReasonUnknown: 1}, stats.Frames)
+	require.Len(t, events, 4)
+	assert.Equal(t, DropEvent{Reason: DropReasonReset, StartGroup: 7, EndGroup: 7}, events[1])
+
+	d.setTrace(nil)
+	d.recordGroup(DropReasonReset, 9)
+	assert.Len(t, events, 4)
+}
+
+func TestDropRecorder_Nil(t *testing.T) {
+	var d *dropRecorder
+	assert.NotPanics(t, func() { d.recordGroup(DropReasonReset, 1) })
+}
+
+func TestDropRecorder_Concurrent(t *testing.T) {
+	var d dropRecorder
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				d.recordFrame(DropReasonStale, 1)
+			}
+		})
+	}
+	wg.Wait()
+	assert.Equal(t, uint64(800), d.stats().Frames[DropReasonStale])
+}
+
+func TestTrackWriter_DropStats(t *testing.T) {
+	var buf bytes.Buffer
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+	}, func() {})
+	defer writer.Close()
+
+	var events []DropEvent
+	writer.SetDropTrace(func(ev DropEvent) { events = append(events, ev) })
+
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+	group.CancelWrite(ExpiredGroupErrorCode)
+	group.CancelWrite(ExpiredGroupErrorCode)
+
+	require.NoError(t, writer.DropNextGroups(2, SubscribeErrorCodeTimeout))
+	writer.RecordDrop(DropEvent{Reason: DropReasonOverBudget, StartGroup: 4, EndGroup: 4, Frame: true})
+
+	stats := writer.DropStats()
+	assert.Equal(t, map[DropReason]uint64{DropReasonStale: 3}, stats.Groups)
+	assert.Equal(t, map[DropReason]uint64{DropReasonOverBudget: 1}, stats.Frames)
+	assert.Len(t, events, 3)
+}
+
+func TestGroupReader_DropStats(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		groups map[DropReason]uint64
+	}{
+		"remote reset": {
+			err:    &transport.StreamError{ErrorCode: transport.StreamErrorCode(PublishAbortedErrorCode), Remote: true},
+			groups: map[DropReason]uint64{DropReasonReset: 1},
+		},
+		"remote expired": {
+			err:    &transport.StreamError{ErrorCode: transport.StreamErrorCode(ExpiredGroupErrorCode), Remote: true},
+			groups: map[DropReason]uint64{DropReasonStale: 1},
+		},
+		"local cancel": {
+			err:    &transport.StreamError{ErrorCode: transport.StreamErrorCode(SubscribeCanceledErrorCode)},
+			groups: map[DropReason]uint64{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			receiver, _ := newTestTrackReader(t)
+			receiver.enqueueGroup(GroupSequence(1), &FakeQUICReceiveStream{
+				ReadFunc: func([]byte) (int, error) { return 0, tt.err },
+			})
+			group, err := receiver.AcceptGroup(t.Context())
+			require.NoError(t, err)
+
+			frame := NewFrame(0)
+			assert.Error(t, group.ReadFrame(frame))
+			assert.Error(t, group.ReadFrame(frame))
+
+			assert.Equal(t, tt.groups, receiver.DropStats().Groups)
+		})
+	}
+}
+
+func TestGroupReader_DropStats_ChecksumPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy ChecksumPolicy
+		frames map[DropReason]uint64
+		groups map[DropReason]uint64
+	}{
+		"skip": {
+			policy: ChecksumPolicySkip,
+			frames: map[DropReason]uint64{DropReasonPolicy: 1},
+			groups: map[DropReason]uint64{},
+		},
+		"cancel": {
+			policy: ChecksumPolicyCancel,
+			frames: map[DropReason]uint64{},
+			groups: map[DropReason]uint64{DropReasonPolicy: 1},
+		},
+		"error": {
+			policy: ChecksumPolicyError,
+			frames: map[DropReason]uint64{},
+			groups: map[DropReason]uint64{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := writeChecksumGroup(t, ChecksumFrame, "one", "two")
+			data[1+checksumBlockSize+3+1+checksumBlockSize] ^= 0xff
+
+			group, _ := newChecksumGroupReader(data, ChecksumFrame, tt.policy)
+			var drops dropRecorder
+			group.drops = &drops
+
+			frame := NewFrame(0)
+			require.NoError(t, group.ReadFrame(frame))
+			_ = group.ReadFrame(frame)
+
+			stats := drops.stats()
+			assert.Equal(t, tt.frames, stats.Frames)
+			assert.Equal(t, tt.groups, stats.Groups)
+		})
+	}
+}
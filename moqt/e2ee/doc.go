@@ -0,0 +1,32 @@
+// Package e2ee encrypts the frames of moqt tracks end to end, in the manner
+// of SFrame (RFC 9605), so that broadcasts can traverse relays that are not
+// trusted with their media.
+//
+// Every frame payload is sealed with an AEAD key derived from a base key as
+// SFrame derives it. The key ID and the counter that SFrame carries in its
+// header are carried instead in the object extension headers KeyIDHeader
+// and CounterHeader, which relays forward unmodified; the SFrame header
+// they encode is authenticated with the payload.
+//
+// A Keyring holds the keys of a broadcast by key ID. Publishers encrypt
+// with its current key and rotate keys by adding a new one and making it
+// current; subscribers decrypt with the key named by each frame, so that
+// frames of the old and the new key can be read during a rotation.
+//
+//	keys := e2ee.NewKeyring(e2ee.AES128GCMSHA256)
+//	_ = keys.Rotate(1, baseKey)
+//
+//	// In the track handler of the publisher:
+//	if err := e2ee.EncryptTrack(tw, keys); err != nil {
+//		// The session cannot carry extension headers.
+//	}
+//
+//	// On the subscriber:
+//	tr, err := sess.Subscribe(ctx, "/live/cam", "video", nil)
+//	...
+//	e2ee.DecryptTrack(tr, keys)
+//
+// Keys are distributed out of band, for example with MLS. A base key must
+// not be used by more than one publisher, as the counters of each would
+// repeat nonces.
+package e2ee
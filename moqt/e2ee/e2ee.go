@@ -0,0 +1,98 @@
+package e2ee
+
+import (
+	"fmt"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+var (
+	// KeyIDHeader is the extension header carrying the key ID of an
+	// encrypted frame.
+	KeyIDHeader = moqt.VarintParameter(0x3a, "sframe-key-id")
+
+	// CounterHeader is the extension header carrying the counter of an
+	// encrypted frame, from which its nonce is derived.
+	CounterHeader = moqt.VarintParameter(0x3c, "sframe-counter")
+)
+
+// Encrypter returns a FrameInterceptor encrypting frames with the current
+// key of keys. The encrypted frame is a new frame holding the ciphertext
+// and the extension headers of the frame with KeyIDHeader and
+// CounterHeader added.
+func Encrypter(keys *Keyring) moqt.FrameInterceptor {
+	return func(frame *moqt.Frame) (*moqt.Frame, error) {
+		id, k, ctr, err := keys.next()
+		if err != nil {
+			return nil, err
+		}
+
+		ext := frame.Extensions().Clone()
+		KeyIDHeader.Set(&ext, uint64(id))
+		CounterHeader.Set(&ext, ctr)
+
+		aad := appendHeader(nil, id, ctr)
+		out := moqt.NewFrame(frame.Len() + k.aead.Overhead())
+		out.AppendBytes(func(b []byte) []byte {
+			return k.aead.Seal(b, k.nonce(ctr), frame.Body(), aad)
+		})
+		out.SetExtensions(ext)
+		return out, nil
+	}
+}
+
+// Decrypter returns a FrameInterceptor decrypting frames in place with the
+// key of keys they name. The key ID and counter headers are kept. A frame
+// that is not encrypted returns ErrNotEncrypted, one of an unknown key
+// ErrUnknownKey and one failing authentication ErrDecrypt; later frames
+// can still be read.
+func Decrypter(keys *Keyring) moqt.FrameInterceptor {
+	return func(frame *moqt.Frame) (*moqt.Frame, error) {
+		id, ok := KeyIDHeader.Get(frame.Extensions())
+		if !ok {
+			return nil, ErrNotEncrypted
+		}
+		ctr, ok := CounterHeader.Get(frame.Extensions())
+		if !ok {
+			return nil, ErrNotEncrypted
+		}
+		k, ok := keys.lookup(KeyID(id))
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownKey, id)
+		}
+
+		body := frame.Body()
+		plaintext, err := k.aead.Open(body[:0], k.nonce(ctr), body, appendHeader(nil, KeyID(id), ctr))
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		frame.Reset()
+		_, _ = frame.Write(plaintext)
+		return frame, nil
+	}
+}
+
+// EncryptTrack makes the groups of tw opened after the call carry
+// extension headers and encrypt every frame with keys; see Encrypter. It
+// replaces the frame interceptor of the track. It returns an error, leaving
+// the track unencrypted, if the session cannot carry extension headers; see
+// moqt.Session.Extensions. Fetch handlers configure each group with
+// GroupWriter.SetExtensionHeaders and GroupWriter.SetFrameInterceptor
+// instead.
+func EncryptTrack(tw *moqt.TrackWriter, keys *Keyring) error {
+	if err := tw.SetExtensionHeaders(true); err != nil {
+		return err
+	}
+	tw.SetFrameInterceptor(Encrypter(keys))
+	return nil
+}
+
+// DecryptTrack makes the groups of tr accepted after the call decrypt every
+// frame with keys; see Decrypter. Their extension headers are announced by
+// the publisher in the header of every group. It replaces the frame
+// interceptor of the track. Groups returned by Session.Fetch are configured
+// with GroupReader.SetExtensionHeaders and GroupReader.SetFrameInterceptor
+// instead.
+func DecryptTrack(tr *moqt.TrackReader, keys *Keyring) {
+	tr.SetFrameInterceptor(Decrypter(keys))
+}
@@ -0,0 +1,263 @@
+package e2ee
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/moqt/relay"
+	"github.com/qumo-dev/gomoqt/moqt/relay/relaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKeyring(t *testing.T, suite CipherSuite, id KeyID, baseKey string) *Keyring {
+	t.Helper()
+	keys := NewKeyring(suite)
+	require.NoError(t, keys.Rotate(id, []byte(baseKey)))
+	return keys
+}
+
+func textFrame(payload string) *moqt.Frame {
+	frame := moqt.NewFrame(len(payload))
+	_, _ = frame.Write([]byte(payload))
+	return frame
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	tests := map[string]struct {
+		suite CipherSuite
+		id    KeyID
+	}{
+		"aes 128":      {suite: AES128GCMSHA256, id: 1},
+		"aes 256":      {suite: AES256GCMSHA512, id: 1},
+		"large key id": {suite: AES128GCMSHA256, id: 1 << 40},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			keys := newKeyring(t, tt.suite, tt.id, "base key")
+			encrypt, decrypt := Encrypter(keys), Decrypter(keys)
+
+			frame := textFrame("payload")
+			frame.SetExtensions(moqt.ExtensionHeaders{0x02: {0x01}})
+			var counters []uint64
+			for range 3 {
+				out, err := encrypt(frame)
+				require.NoError(t, err)
+				assert.Equal(t, "payload", string(frame.Body()), "the written frame must not be modified")
+				assert.NotContains(t, string(out.Body()), "payload")
+				assert.Equal(t, frame.Len()+16, out.Len())
+
+				id, ok := KeyIDHeader.Get(out.Extensions())
+				require.True(t, ok)
+				assert.Equal(t, uint64(tt.id), id)
+				ctr, ok := CounterHeader.Get(out.Extensions())
+				require.True(t, ok)
+				counters = append(counters, ctr)
+
+				got, err := decrypt(out)
+				require.NoError(t, err)
+				assert.Equal(t, "payload", string(got.Body()))
+				assert.Equal(t, []byte{0x01}, got.Extensions()[0x02])
+			}
+			assert.Equal(t, []uint64{0, 1, 2}, counters)
+		})
+	}
+}
+
+func TestDecrypt_Errors(t *testing.T) {
+	keys := newKeyring(t, AES128GCMSHA256, 1, "base key")
+
+	tests := map[string]struct {
+		tamper  func(frame *moqt.Frame)
+		keys    *Keyring
+		wantErr error
+	}{
+		"modified payload": {
+			tamper:  func(frame *moqt.Frame) { frame.Body()[0] ^= 0xff },
+			wantErr: ErrDecrypt,
+		},
+		"modified counter": {
+			tamper: func(frame *moqt.Frame) {
+				ext := frame.Extensions()
+				CounterHeader.Set(&ext, 7)
+			},
+			wantErr: ErrDecrypt,
+		},
+		"other base key": {
+			keys:    newKeyring(t, AES128GCMSHA256, 1, "other key"),
+			wantErr: ErrDecrypt,
+		},
+		"unknown key": {
+			keys:    newKeyring(t, AES128GCMSHA256, 2, "base key"),
+			wantErr: ErrUnknownKey,
+		},
+		"not encrypted": {
+			tamper:  func(frame *moqt.Frame) { frame.SetExtensions(nil) },
+			wantErr: ErrNotEncrypted,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := Encrypter(keys)(textFrame("payload"))
+			require.NoError(t, err)
+			if tt.tamper != nil {
+				tt.tamper(out)
+			}
+			readKeys := keys
+			if tt.keys != nil {
+				readKeys = tt.keys
+			}
+			_, err = Decrypter(readKeys)(out)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestKeyring_Rotate(t *testing.T) {
+	sender := newKeyring(t, AES128GCMSHA256, 1, "first")
+	receiver := newKeyring(t, AES128GCMSHA256, 1, "first")
+	encrypt, decrypt := Encrypter(sender), Decrypter(receiver)
+
+	old, err := encrypt(textFrame("old"))
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Add(2, []byte("second")))
+	require.NoError(t, sender.Rotate(2, []byte("second")))
+	id, ok := sender.Current()
+	require.True(t, ok)
+	assert.Equal(t, KeyID(2), id)
+
+	rotated, err := encrypt(textFrame("new"))
+	require.NoError(t, err)
+	ctr, _ := CounterHeader.Get(rotated.Extensions())
+	assert.Zero(t, ctr, "the counter of a new key starts at zero")
+
+	// Frames of both keys are read during the rotation.
+	got, err := decrypt(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(got.Body()))
+	got, err = decrypt(old.Clone())
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(got.Body()))
+
+	receiver.Remove(1)
+	_, err = decrypt(old)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	sender.Remove(2)
+	_, ok = sender.Current()
+	assert.False(t, ok)
+	_, err = encrypt(textFrame("none"))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.ErrorIs(t, sender.SetCurrent(3), ErrUnknownKey)
+}
+
+func TestKeyring_CounterNeverRepeats(t *testing.T) {
+	keys := newKeyring(t, AES128GCMSHA256, 1, "first")
+	encrypt := Encrypter(keys)
+
+	seen := make(map[[2]uint64]bool)
+	encryptOnce := func() {
+		t.Helper()
+		frame, err := encrypt(textFrame("payload"))
+		require.NoError(t, err)
+		id, _ := KeyIDHeader.Get(frame.Extensions())
+		ctr, _ := CounterHeader.Get(frame.Extensions())
+		nonce := [2]uint64{id, ctr}
+		assert.False(t, seen[nonce], "key %d reused counter %d", id, ctr)
+		seen[nonce] = true
+	}
+
+	encryptOnce()
+	require.NoError(t, keys.Rotate(2, []byte("second")))
+	encryptOnce()
+	// Key 1 becomes current again.
+	require.NoError(t, keys.SetCurrent(1))
+	encryptOnce()
+	// Key 1 is added again with the same base key, deriving the same key.
+	require.NoError(t, keys.Add(1, []byte("first")))
+	encryptOnce()
+	require.NoError(t, keys.Rotate(1, []byte("first")))
+	encryptOnce()
+	// Key 1 is removed and added again.
+	keys.Remove(1)
+	require.NoError(t, keys.Rotate(1, []byte("first")))
+	encryptOnce()
+
+	assert.Len(t, seen, 6)
+}
+
+func TestNewKeyring_UnsupportedSuite(t *testing.T) {
+	keys := NewKeyring(CipherSuite(0x0001))
+	assert.Error(t, keys.Add(1, []byte("base key")))
+	assert.Equal(t, "CipherSuite(0x0001)", keys.Suite().String())
+}
+
+func TestAppendHeader(t *testing.T) {
+	tests := map[string]struct {
+		kid  KeyID
+		ctr  uint64
+		want []byte
+	}{
+		"short":           {kid: 0, ctr: 0, want: []byte{0x00}},
+		"short maximum":   {kid: 7, ctr: 7, want: []byte{0x77}},
+		"long key id":     {kid: 8, ctr: 0, want: []byte{0x80, 0x08}},
+		"long counter":    {kid: 0, ctr: 8, want: []byte{0x08, 0x08}},
+		"multi-byte both": {kid: 0x100, ctr: 0x10000, want: []byte{0x9a, 0x01, 0x00, 0x01, 0x00, 0x00}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, appendHeader(nil, tt.kid, tt.ctr))
+		})
+	}
+}
+
+func TestEncryptTrack_AcrossRelay(t *testing.T) {
+	keys := newKeyring(t, AES256GCMSHA512, 1, "broadcast key")
+
+	chain := relaytest.NewChain(t, &relay.Config{CacheGroups: 4, CacheTTL: time.Minute}, relaytest.Link{})
+	chain.Publisher.PublishFunc(t.Context(), "/live/cam", func(tw *moqt.TrackWriter) {
+		if !assert.NoError(t, EncryptTrack(tw, keys)) {
+			return
+		}
+		for {
+			gw, err := tw.OpenGroup()
+			if err != nil {
+				return
+			}
+			err = gw.WriteFrame(textFrame("secret"))
+			_ = gw.Close()
+			if err != nil {
+				return
+			}
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-tw.Context().Done():
+				return
+			}
+		}
+	})
+
+	readFrame := func(tr *moqt.TrackReader) *moqt.Frame {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		gr, err := tr.AcceptGroup(ctx)
+		require.NoError(t, err)
+		frame := moqt.NewFrame(0)
+		require.NoError(t, gr.ReadFrame(frame))
+		return frame
+	}
+
+	tr := chain.Subscribe(relaytest.Link{}, "/live/cam", "video", nil)
+	DecryptTrack(tr, keys)
+	assert.Equal(t, "secret", string(readFrame(tr).Body()))
+
+	// A subscriber without the keys only sees ciphertext.
+	raw := chain.Subscribe(relaytest.Link{}, "/live/cam", "video", nil)
+	assert.NotContains(t, string(readFrame(raw).Body()), "secret")
+}
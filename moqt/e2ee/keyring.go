@@ -0,0 +1,146 @@
+package e2ee
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// KeyID identifies a key of a Keyring.
+type KeyID uint64
+
+var (
+	// ErrUnknownKey is returned for a frame encrypted with a key the
+	// Keyring does not hold, or when no key is current.
+	ErrUnknownKey = errors.New("e2ee: unknown key")
+
+	// ErrDecrypt is returned for a frame that fails authentication.
+	ErrDecrypt = errors.New("e2ee: decryption failed")
+
+	// ErrNotEncrypted is returned for a frame without the key ID and
+	// counter headers.
+	ErrNotEncrypted = errors.New("e2ee: frame not encrypted")
+)
+
+// Keyring holds the keys of a broadcast by key ID, all of one cipher
+// suite. Frames are encrypted with the current key and decrypted with the
+// key they name.
+//
+// All methods are safe for concurrent use.
+type Keyring struct {
+	suite CipherSuite
+
+	mu         sync.RWMutex
+	keys       map[KeyID]*key
+	current    KeyID
+	hasCurrent bool
+
+	// counters holds the next counter of each key ID that encrypted a
+	// frame. It only grows, and is kept when a key is replaced, removed or
+	// made current again, since the derivation of a key from its ID and
+	// base key is deterministic and a repeated counter repeats the nonce.
+	counters map[KeyID]uint64
+}
+
+// NewKeyring returns an empty Keyring of the given cipher suite.
+func NewKeyring(suite CipherSuite) *Keyring {
+	return &Keyring{
+		suite:    suite,
+		keys:     make(map[KeyID]*key),
+		counters: make(map[KeyID]uint64),
+	}
+}
+
+// Suite returns the cipher suite of the keyring.
+func (k *Keyring) Suite() CipherSuite {
+	return k.suite
+}
+
+// Add derives the key of id from baseKey and adds it, replacing the key of
+// the same ID. The current key ID is unchanged. The counter of id goes on
+// from the frames it already encrypted, so that no nonce is reused.
+func (k *Keyring) Add(id KeyID, baseKey []byte) error {
+	derived, err := deriveKey(k.suite, id, baseKey)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys[id] = derived
+	return nil
+}
+
+// Remove removes the key of id, such as an old key once a rotation is
+// complete. If it is the current key, frames cannot be encrypted until
+// another key is made current.
+func (k *Keyring) Remove(id KeyID) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.keys, id)
+	if k.hasCurrent && k.current == id {
+		k.hasCurrent = false
+	}
+}
+
+// SetCurrent makes frames encrypted after the call use the key of id,
+// which must have been added. A key made current again goes on counting
+// from the frames it already encrypted.
+func (k *Keyring) SetCurrent(id KeyID) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownKey, id)
+	}
+	k.current, k.hasCurrent = id, true
+	return nil
+}
+
+// Rotate adds the key of id derived from baseKey and makes it current.
+// Subscribers must hold the new key before the first frame it encrypts
+// reaches them.
+func (k *Keyring) Rotate(id KeyID, baseKey []byte) error {
+	if err := k.Add(id, baseKey); err != nil {
+		return err
+	}
+	return k.SetCurrent(id)
+}
+
+// Current returns the ID of the current key, reporting false if there is
+// none.
+func (k *Keyring) Current() (KeyID, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current, k.hasCurrent
+}
+
+// next returns the current key with its ID and the next counter.
+func (k *Keyring) next() (KeyID, *key, uint64, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !k.hasCurrent {
+		return 0, nil, 0, ErrUnknownKey
+	}
+	id := k.current
+	ctr := k.counters[id]
+	if ctr == math.MaxUint64 {
+		return 0, nil, 0, fmt.Errorf("e2ee: counter of key %d exhausted", id)
+	}
+	k.counters[id] = ctr + 1
+	return id, k.keys[id], ctr, nil
+}
+
+// lookup returns the key of id.
+func (k *Keyring) lookup(id KeyID) (*key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	derived, ok := k.keys[id]
+	return derived, ok
+}
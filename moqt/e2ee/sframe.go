@@ -0,0 +1,140 @@
+package e2ee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// CipherSuite is an SFrame cipher suite, identified by its value in the
+// registry of RFC 9605.
+type CipherSuite uint16
+
+const (
+	// AES128GCMSHA256 is AES_128_GCM_SHA256_128.
+	AES128GCMSHA256 CipherSuite = 0x0004
+
+	// AES256GCMSHA512 is AES_256_GCM_SHA512_128.
+	AES256GCMSHA512 CipherSuite = 0x0005
+)
+
+func (s CipherSuite) String() string {
+	switch s {
+	case AES128GCMSHA256:
+		return "AES_128_GCM_SHA256_128"
+	case AES256GCMSHA512:
+		return "AES_256_GCM_SHA512_128"
+	default:
+		return fmt.Sprintf("CipherSuite(%#04x)", uint16(s))
+	}
+}
+
+// params returns the hash and the key size of the suite.
+func (s CipherSuite) params() (func() hash.Hash, int, error) {
+	switch s {
+	case AES128GCMSHA256:
+		return sha256.New, 16, nil
+	case AES256GCMSHA512:
+		return sha512.New, 32, nil
+	default:
+		return nil, 0, fmt.Errorf("e2ee: unsupported cipher suite %v", s)
+	}
+}
+
+// nonceSize is the nonce size of the AES-GCM suites.
+const nonceSize = 12
+
+// key is an SFrame key and salt derived from a base key.
+type key struct {
+	aead cipher.AEAD
+	salt []byte
+}
+
+// deriveKey derives the key and salt of key ID kid from baseKey as in
+// Section 4.4.2 of RFC 9605.
+func deriveKey(suite CipherSuite, kid KeyID, baseKey []byte) (*key, error) {
+	h, keySize, err := suite.params()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := hkdf.Extract(h, baseKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	var context [10]byte
+	binary.BigEndian.PutUint64(context[:8], uint64(kid))
+	binary.BigEndian.PutUint16(context[8:], uint16(suite))
+
+	k, err := hkdf.Expand(h, secret, "SFrame 1.0 Secret key "+string(context[:]), keySize)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := hkdf.Expand(h, secret, "SFrame 1.0 Secret salt "+string(context[:]), nonceSize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &key{aead: aead, salt: salt}, nil
+}
+
+// nonce returns the nonce of counter ctr: the salt XORed with the counter,
+// encoded big-endian.
+func (k *key) nonce(ctr uint64) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce[nonceSize-8:], ctr)
+	for i := range nonce {
+		nonce[i] ^= k.salt[i]
+	}
+	return nonce
+}
+
+// appendHeader appends the SFrame header of kid and ctr to b, as in
+// Section 4.3 of RFC 9605. It is the additional data of the frame.
+func appendHeader(b []byte, kid KeyID, ctr uint64) []byte {
+	config := len(b)
+	b = append(b, 0)
+	var flags byte
+	if kid < 8 {
+		flags = byte(kid) << 4
+	} else {
+		n := minBytes(uint64(kid))
+		flags = 0x80 | byte(n-1)<<4
+		b = binary.BigEndian.AppendUint64(b, uint64(kid))
+		b = append(b[:len(b)-8], b[len(b)-n:]...)
+	}
+	if ctr < 8 {
+		flags |= byte(ctr)
+	} else {
+		n := minBytes(ctr)
+		flags |= 0x08 | byte(n-1)
+		b = binary.BigEndian.AppendUint64(b, ctr)
+		b = append(b[:len(b)-8], b[len(b)-n:]...)
+	}
+	b[config] = flags
+	return b
+}
+
+// minBytes returns the number of bytes of the minimal big-endian encoding
+// of v, at least one.
+func minBytes(v uint64) int {
+	n := 1
+	for v > 0xff {
+		v >>= 8
+		n++
+	}
+	return n
+}
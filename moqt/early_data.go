@@ -0,0 +1,84 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+// sessionCacheMu guards the lazily created session caches of Dialers.
+var sessionCacheMu sync.Mutex
+
+// clientSessionCache returns the cache of the TLS sessions resumed by the
+// Dialer with 0-RTT, creating it on first use.
+func (d *Dialer) clientSessionCache() tls.ClientSessionCache {
+	sessionCacheMu.Lock()
+	defer sessionCacheMu.Unlock()
+
+	if d.sessionCache == nil {
+		d.sessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	return d.sessionCache
+}
+
+// Requests sent before the handshake of a session dialed with 0-RTT
+// completes can be replayed by an attacker, and are lost if the server
+// rejects 0-RTT. Only SUBSCRIBE, which is idempotent, is sent then, and sent
+// again if it was lost; every other stream waits for the handshake, as does
+// a SUBSCRIBE carrying a token, whose replay would reuse the authorization.
+
+// inEarlyData reports whether the session is still in 0-RTT.
+func (sess *Session) inEarlyData() bool {
+	if sess.early == nil {
+		return false
+	}
+	select {
+	case <-sess.early.HandshakeComplete():
+		return false
+	default:
+		return true
+	}
+}
+
+// awaitHandshake waits until the handshake of a session dialed with 0-RTT
+// completes, so that the requests it sends are safe from replay.
+func (sess *Session) awaitHandshake(ctx context.Context) error {
+	if sess.early == nil {
+		return nil
+	}
+	select {
+	case <-sess.early.HandshakeComplete():
+		return nil
+	case <-ctx.Done():
+		return Cause(ctx)
+	case <-sess.ctx.Done():
+		return ErrClosedSession
+	}
+}
+
+// earlyDataRejected waits for the handshake and reports whether the server
+// rejected the data the session sent in 0-RTT.
+func (sess *Session) earlyDataRejected(ctx context.Context) bool {
+	if sess.early == nil || sess.awaitHandshake(ctx) != nil {
+		return false
+	}
+	return !sess.early.Used0RTT()
+}
+
+// openStream opens a bidirectional stream once it is safe from replay.
+func (sess *Session) openStream() (transport.Stream, error) {
+	if err := sess.awaitHandshake(sess.ctx); err != nil {
+		return nil, err
+	}
+	return sess.conn.OpenStream()
+}
+
+// openUniStream opens a unidirectional stream once it is safe from replay.
+func (sess *Session) openUniStream() (transport.SendStream, error) {
+	if err := sess.awaitHandshake(sess.ctx); err != nil {
+		return nil, err
+	}
+	return sess.conn.OpenUniStream()
+}
@@ -0,0 +1,247 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEarlyDataConn is a FakeStreamConn dialed with 0-RTT, whose handshake
+// completes when handshake is closed.
+type fakeEarlyDataConn struct {
+	*FakeStreamConn
+	handshake chan struct{}
+	used0RTT  bool
+}
+
+func (c *fakeEarlyDataConn) HandshakeComplete() <-chan struct{} { return c.handshake }
+func (c *fakeEarlyDataConn) Used0RTT() bool                     { return c.used0RTT }
+
+func newEarlySession(t *testing.T, conn *fakeEarlyDataConn) *Session {
+	t.Helper()
+	sess := newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil, &sessionOptions{early: conn})
+	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+	return sess
+}
+
+func TestSession_OpenStream_AwaitsHandshake(t *testing.T) {
+	var opened atomic.Int32
+	conn := &fakeEarlyDataConn{
+		FakeStreamConn: &FakeStreamConn{
+			OpenStreamFunc: func() (transport.Stream, error) {
+				opened.Add(1)
+				return &FakeQUICStream{}, nil
+			},
+		},
+		handshake: make(chan struct{}),
+	}
+	sess := newEarlySession(t, conn)
+	assert.True(t, sess.inEarlyData())
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := sess.openStream()
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("stream opened in 0-RTT")
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.Zero(t, opened.Load())
+
+	close(conn.handshake)
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(1), opened.Load())
+	assert.False(t, sess.inEarlyData())
+}
+
+func TestSession_OpenStream_ClosedInEarlyData(t *testing.T) {
+	conn := &fakeEarlyDataConn{
+		FakeStreamConn: &FakeStreamConn{},
+		handshake:      make(chan struct{}),
+	}
+	sess := newEarlySession(t, conn)
+
+	require.NoError(t, sess.CloseWithError(NoError, ""))
+	_, err := sess.openUniStream()
+	assert.Error(t, err)
+}
+
+func TestSession_Subscribe_EarlyData(t *testing.T) {
+	tests := map[string]struct {
+		used0RTT    bool
+		wantErr     bool
+		wantStreams int32
+	}{
+		"accepted": {
+			used0RTT:    true,
+			wantErr:     true,
+			wantStreams: 1,
+		},
+		"rejected": {
+			used0RTT:    false,
+			wantStreams: 2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			_, _ = buf.Write([]byte{byte(message.MessageTypeSubscribeOk)})
+			require.NoError(t, message.SubscribeOkMessage{}.Encode(&buf))
+
+			conn := &fakeEarlyDataConn{
+				FakeStreamConn: &FakeStreamConn{},
+				handshake:      make(chan struct{}),
+				used0RTT:       tt.used0RTT,
+			}
+			var opened atomic.Int32
+			conn.OpenStreamFunc = func() (transport.Stream, error) {
+				stream := &FakeQUICStream{
+					WriteFunc: func(p []byte) (int, error) { return len(p), nil },
+				}
+				if opened.Add(1) == 1 {
+					// The first stream is lost with the data sent in 0-RTT.
+					stream.ReadFunc = func([]byte) (int, error) {
+						close(conn.handshake)
+						return 0, errors.New("0-RTT rejected")
+					}
+				} else {
+					stream.ReadFunc = bytes.NewReader(buf.Bytes()).Read
+				}
+				return stream, nil
+			}
+			sess := newEarlySession(t, conn)
+
+			reader, err := sess.Subscribe(t.Context(), "/test", "video", nil)
+			sess.trackReaderMapLocker.RLock()
+			registered := len(sess.trackReaders)
+			sess.trackReaderMapLocker.RUnlock()
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, reader)
+				assert.Zero(t, registered, "a failed subscription should not stay registered")
+			} else {
+				require.NoError(t, err)
+				assert.NotNil(t, reader)
+				assert.Equal(t, 1, registered, "the lost subscription should not stay registered")
+			}
+			assert.Equal(t, tt.wantStreams, opened.Load())
+		})
+	}
+}
+
+func TestSession_Subscribe_EarlyData_AuthToken(t *testing.T) {
+	var buf bytes.Buffer
+	_, _ = buf.Write([]byte{byte(message.MessageTypeSubscribeOk)})
+	require.NoError(t, message.SubscribeOkMessage{}.Encode(&buf))
+
+	conn := &fakeEarlyDataConn{
+		FakeStreamConn: &FakeStreamConn{},
+		handshake:      make(chan struct{}),
+		used0RTT:       true,
+	}
+	extendedConn(conn.FakeStreamConn)
+	var opened atomic.Int32
+	conn.OpenStreamFunc = func() (transport.Stream, error) {
+		opened.Add(1)
+		return &FakeQUICStream{
+			ReadFunc:  bytes.NewReader(buf.Bytes()).Read,
+			WriteFunc: func(p []byte) (int, error) { return len(p), nil },
+		}, nil
+	}
+	sess := newEarlySession(t, conn)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := sess.Subscribe(t.Context(), "/test", "video", &SubscribeConfig{AuthToken: "secret"})
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("subscription with a token sent in 0-RTT")
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.Zero(t, opened.Load(), "a token must not be sent in early data")
+
+	close(conn.handshake)
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(1), opened.Load())
+}
+
+func TestSession_ConnectionState_Used0RTT(t *testing.T) {
+	handshake := make(chan struct{})
+	close(handshake)
+	conn := &fakeEarlyDataConn{FakeStreamConn: &FakeStreamConn{}, handshake: handshake, used0RTT: true}
+	sess := newEarlySession(t, conn)
+	assert.True(t, sess.ConnectionState().Used0RTT)
+
+	plain, _ := newTestSessionWithConn(t)
+	assert.False(t, plain.ConnectionState().Used0RTT)
+}
+
+func TestDialer_Enable0RTT(t *testing.T) {
+	configured := tls.NewLRUClientSessionCache(1)
+
+	tests := map[string]struct {
+		enable    bool
+		tlsConfig *tls.Config
+		wantCache func(t *testing.T, caches []tls.ClientSessionCache)
+	}{
+		"disabled": {
+			wantCache: func(t *testing.T, caches []tls.ClientSessionCache) {
+				assert.Nil(t, caches[0])
+				assert.Nil(t, caches[1])
+			},
+		},
+		"dialer cache": {
+			enable: true,
+			wantCache: func(t *testing.T, caches []tls.ClientSessionCache) {
+				require.NotNil(t, caches[0])
+				assert.Same(t, caches[0], caches[1], "tickets must be shared across dials")
+			},
+		},
+		"configured cache": {
+			enable:    true,
+			tlsConfig: &tls.Config{ClientSessionCache: configured},
+			wantCache: func(t *testing.T, caches []tls.ClientSessionCache) {
+				assert.Same(t, configured, caches[0])
+				assert.Same(t, configured, caches[1])
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var caches []tls.ClientSessionCache
+			errRefused := errors.New("refused")
+			d := &Dialer{
+				TLSConfig:  tt.tlsConfig,
+				Enable0RTT: tt.enable,
+				DialQUICFunc: func(_ context.Context, _ string, tlsConfig *tls.Config, _ *quic.Config) (StreamConn, error) {
+					caches = append(caches, tlsConfig.ClientSessionCache)
+					return nil, errRefused
+				},
+			}
+
+			for range 2 {
+				_, err := d.DialQUIC(t.Context(), "example.com:443", nil)
+				require.ErrorIs(t, err, errRefused)
+			}
+			tt.wantCache(t, caches)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/webtransportgo"
+)
+
+const defaultAttemptDelay = 250 * time.Millisecond
+
+// EndpointPolicy configures how a Client races connections to several
+// endpoints of a server, as Happy Eyeballs (RFC 8305) does for the addresses
+// of both IP families: an attempt starts every AttemptDelay, or as soon as
+// the previous one fails, and the first session established is kept while
+// the others are abandoned.
+//
+// The URL still names the server in TLS and in WebTransport requests. A
+// Dialer.DialQUICFunc receives the endpoint as its address, whereas a
+// Dialer.DialWebTransportFunc receives the URL of the server, so that
+// endpoints only apply to the default WebTransport dialer.
+type EndpointPolicy struct {
+	// Addrs are the endpoints of the server, as host:port. If empty, the
+	// endpoints returned by Client.Resolver are raced, or the addresses the
+	// host of the URL resolves to, alternating between IPv6 and IPv4.
+	Addrs []string
+
+	// AttemptDelay is the delay before the next endpoint is tried while
+	// earlier attempts are pending. Defaults to 250ms.
+	AttemptDelay time.Duration
+}
+
+func (p *EndpointPolicy) attemptDelay() time.Duration {
+	if p.AttemptDelay <= 0 {
+		return defaultAttemptDelay
+	}
+	return p.AttemptDelay
+}
+
+// endpoints returns the endpoints to race for u, in order.
+func (p *EndpointPolicy) endpoints(ctx context.Context, u *url.URL, resolver Resolver) ([]string, error) {
+	if len(p.Addrs) > 0 {
+		return p.Addrs, nil
+	}
+	if resolver == nil {
+		resolver = IPResolver{}
+	}
+	return resolver.Resolve(ctx, u)
+}
+
+// interleaveFamilies orders addrs by alternating between their IP families,
+// starting with the family of the first address, and otherwise keeping the
+// order of the resolver.
+func interleaveFamilies(addrs []net.IPAddr) []net.IPAddr {
+	if len(addrs) == 0 {
+		return addrs
+	}
+	first := addrs[0].IP.To4() == nil
+	var primary, secondary []net.IPAddr
+	for _, addr := range addrs {
+		if (addr.IP.To4() == nil) == first {
+			primary = append(primary, addr)
+		} else {
+			secondary = append(secondary, addr)
+		}
+	}
+
+	ordered := make([]net.IPAddr, 0, len(addrs))
+	for i := 0; i < len(primary) || i < len(secondary); i++ {
+		if i < len(primary) {
+			ordered = append(ordered, primary[i])
+		}
+		if i < len(secondary) {
+			ordered = append(ordered, secondary[i])
+		}
+	}
+	return ordered
+}
+
+// raceDial dials endpoints with dial, starting an attempt every delay or as
+// soon as the latest one fails, and returns the first session established.
+// Sessions established by the other attempts are closed.
+func raceDial(ctx context.Context, endpoints []string, delay time.Duration, dial func(ctx context.Context, endpoint string) (*Session, error)) (*Session, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("moqt: no endpoints to dial")
+	}
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		sess *Session
+		err  error
+	}
+	results := make(chan result, len(endpoints))
+	next, pending := 0, 0
+	start := func() {
+		endpoint := endpoints[next]
+		next++
+		pending++
+		// The dials are owned by raceDial, and end with raceCtx once it
+		// returns; see supervisor.go.
+		go func() {
+			sess, err := dial(raceCtx, endpoint)
+			results <- result{sess: sess, err: err}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var errs []error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// Close the sessions of the dials still pending. This ends
+				// once they have returned, which they do as raceCtx is
+				// canceled on return.
+				go func(pending int) {
+					for range pending {
+						if r := <-results; r.err == nil {
+							_ = r.sess.CloseWithError(NoError, "")
+						}
+					}
+				}(pending)
+				return r.sess, nil
+			}
+			errs = append(errs, r.err)
+			if next < len(endpoints) {
+				start()
+				timer.Reset(delay)
+			}
+		case <-timer.C:
+			if next < len(endpoints) {
+				start()
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// endpointPacketDialer returns the function sending the QUIC packets of a
+// WebTransport session to endpoint, through dial if it is not nil.
+func endpointPacketDialer(endpoint string, dial webtransportgo.DialPacketFunc) webtransportgo.DialPacketFunc {
+	return func(ctx context.Context, _ string) (net.PacketConn, net.Addr, error) {
+		if dial != nil {
+			return dial(ctx, endpoint)
+		}
+		raddr, err := net.ResolveUDPAddr("udp", endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		pconn, err := net.ListenUDP("udp", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pconn, raddr, nil
+	}
+}
+
+// serverName returns the host of addr, a host:port.
+func serverName(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,240 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterleaveFamilies(t *testing.T) {
+	v4a := net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	v4b := net.IPAddr{IP: net.ParseIP("192.0.2.2")}
+	v6a := net.IPAddr{IP: net.ParseIP("2001:db8::1")}
+	v6b := net.IPAddr{IP: net.ParseIP("2001:db8::2")}
+	v6c := net.IPAddr{IP: net.ParseIP("2001:db8::3")}
+
+	tests := map[string]struct {
+		addrs []net.IPAddr
+		want  []net.IPAddr
+	}{
+		"empty":        {},
+		"ipv6 first":   {addrs: []net.IPAddr{v6a, v6b, v6c, v4a, v4b}, want: []net.IPAddr{v6a, v4a, v6b, v4b, v6c}},
+		"ipv4 first":   {addrs: []net.IPAddr{v4a, v6a, v4b}, want: []net.IPAddr{v4a, v6a, v4b}},
+		"single stack": {addrs: []net.IPAddr{v4a, v4b}, want: []net.IPAddr{v4a, v4b}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, interleaveFamilies(tt.addrs))
+		})
+	}
+}
+
+func TestEndpointPolicy_Endpoints(t *testing.T) {
+	resolver := ResolverFunc(func(context.Context, *url.URL) ([]string, error) {
+		return []string{"192.0.2.9:4433"}, nil
+	})
+
+	tests := map[string]struct {
+		policy   EndpointPolicy
+		resolver Resolver
+		url      string
+		want     []string
+	}{
+		"listed": {
+			policy:   EndpointPolicy{Addrs: []string{"192.0.2.1:4433", "[2001:db8::1]:4433"}},
+			resolver: resolver,
+			url:      "https://relay.example.com",
+			want:     []string{"192.0.2.1:4433", "[2001:db8::1]:4433"},
+		},
+		"resolved": {
+			resolver: resolver,
+			url:      "https://relay.example.com",
+			want:     []string{"192.0.2.9:4433"},
+		},
+		"default resolver": {
+			url:  "moqt://192.0.2.1:9000",
+			want: []string{"192.0.2.1:9000"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			require.NoError(t, err)
+			got, err := tt.policy.endpoints(t.Context(), u, tt.resolver)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRaceDial(t *testing.T) {
+	errUnreachable := errors.New("unreachable")
+
+	tests := map[string]struct {
+		// outcomes maps endpoints to the duration of their dial; missing
+		// endpoints hang until canceled.
+		outcomes map[string]time.Duration
+		fail     map[string]bool
+		delay    time.Duration
+		want     string
+		wantErr  bool
+		maxTime  time.Duration
+	}{
+		"first succeeds": {
+			outcomes: map[string]time.Duration{"a": 0, "b": 0},
+			delay:    time.Hour,
+			want:     "a",
+		},
+		"hung endpoint is overtaken": {
+			outcomes: map[string]time.Duration{"b": 0},
+			delay:    10 * time.Millisecond,
+			want:     "b",
+		},
+		"failure starts the next attempt": {
+			outcomes: map[string]time.Duration{"a": 0, "b": 0},
+			fail:     map[string]bool{"a": true},
+			delay:    time.Hour,
+			want:     "b",
+			maxTime:  time.Second,
+		},
+		"faster later attempt wins": {
+			outcomes: map[string]time.Duration{"a": 200 * time.Millisecond, "b": 0},
+			delay:    10 * time.Millisecond,
+			want:     "b",
+		},
+		"all fail": {
+			outcomes: map[string]time.Duration{"a": 0, "b": 0},
+			fail:     map[string]bool{"a": true, "b": true},
+			delay:    time.Hour,
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			conns := map[string]*FakeStreamConn{}
+			dial := func(ctx context.Context, endpoint string) (*Session, error) {
+				wait, ok := tt.outcomes[endpoint]
+				if !ok {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				time.Sleep(wait)
+				if tt.fail[endpoint] {
+					return nil, errUnreachable
+				}
+				conn := &FakeStreamConn{}
+				mu.Lock()
+				conns[endpoint] = conn
+				mu.Unlock()
+				return newTestSession(conn), nil
+			}
+
+			start := time.Now()
+			sess, err := raceDial(t.Context(), []string{"a", "b"}, tt.delay, dial)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errUnreachable)
+				return
+			}
+			require.NoError(t, err)
+			defer sess.CloseWithError(NoError, "")
+			if tt.maxTime > 0 {
+				assert.Less(t, time.Since(start), tt.maxTime)
+			}
+
+			mu.Lock()
+			winner := conns[tt.want]
+			mu.Unlock()
+			assert.Same(t, winner, sess.conn)
+
+			// Sessions established by the other attempts are closed.
+			time.Sleep(300 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			for endpoint, conn := range conns {
+				if endpoint != tt.want {
+					assert.Error(t, conn.Context().Err(), endpoint)
+				}
+			}
+		})
+	}
+}
+
+func TestRaceDial_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := raceDial(ctx, []string{"a"}, time.Hour, func(ctx context.Context, _ string) (*Session, error) {
+		<-ctx.Done()
+		return nil, errors.New("dial canceled")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestEndpointPacketDialer(t *testing.T) {
+	pconn, raddr, err := endpointPacketDialer("127.0.0.1:4433", nil)(t.Context(), "relay.example.com:4433")
+	require.NoError(t, err)
+	defer pconn.Close()
+	assert.Equal(t, "127.0.0.1:4433", raddr.String())
+
+	var dialed string
+	errBlocked := errors.New("blocked")
+	_, _, err = endpointPacketDialer("192.0.2.1:4433", func(_ context.Context, addr string) (net.PacketConn, net.Addr, error) {
+		dialed = addr
+		return nil, nil, errBlocked
+	})(t.Context(), "relay.example.com:4433")
+	assert.ErrorIs(t, err, errBlocked)
+	assert.Equal(t, "192.0.2.1:4433", dialed)
+}
+
+func TestClient_Dial_Endpoints(t *testing.T) {
+	server := newFakeClientServer()
+	server.failDials = 1
+
+	var mu sync.Mutex
+	var serverNames []string
+	dialer := server.dialer()
+	dialer.DialQUICFunc = func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+		mu.Lock()
+		serverNames = append(serverNames, tlsConfig.ServerName)
+		mu.Unlock()
+		return server.dialQUIC(ctx, addr, tlsConfig, quicConfig)
+	}
+
+	client := &Client{
+		Dialer: dialer,
+		Endpoints: &EndpointPolicy{
+			Addrs:        []string{"192.0.2.1:4433", "192.0.2.2:4433"},
+			AttemptDelay: time.Hour,
+		},
+	}
+	defer client.Close()
+
+	sess, err := client.Dial(t.Context(), "moqt://relay.example.com:4433")
+	require.NoError(t, err)
+	assert.Same(t, server.conn(0), sess.conn)
+
+	server.mu.Lock()
+	assert.Equal(t, []string{"192.0.2.1:4433", "192.0.2.2:4433"}, server.addrs)
+	server.mu.Unlock()
+	mu.Lock()
+	assert.Equal(t, []string{"relay.example.com", "relay.example.com"}, serverNames)
+	mu.Unlock()
+}
+
+func TestClient_Dial_Endpoints_InvalidScheme(t *testing.T) {
+	client := &Client{Endpoints: &EndpointPolicy{}}
+	_, err := client.Dial(t.Context(), "http://relay.invalid")
+	assert.ErrorIs(t, err, ErrInvalidScheme)
+}
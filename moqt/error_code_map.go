@@ -0,0 +1,265 @@
+package moqt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+)
+
+// ErrorSpace identifies the family a MOQ error code belongs to.
+// Codes in different spaces are independent: the same numeric value may mean
+// different things on a subscribe stream and on a group stream.
+type ErrorSpace uint8
+
+const (
+	SessionErrorSpace ErrorSpace = iota + 1
+	AnnounceErrorSpace
+	SubscribeErrorSpace
+	FetchErrorSpace
+	ProbeErrorSpace
+	GroupErrorSpace
+)
+
+// String returns a text for the error space.
+// It returns an empty string if the space is unknown.
+func (space ErrorSpace) String() string {
+	switch space {
+	case SessionErrorSpace:
+		return "session"
+	case AnnounceErrorSpace:
+		return "announce"
+	case SubscribeErrorSpace:
+		return "subscribe"
+	case FetchErrorSpace:
+		return "fetch"
+	case ProbeErrorSpace:
+		return "probe"
+	case GroupErrorSpace:
+		return "group"
+	default:
+		return ""
+	}
+}
+
+// DefaultApplicationErrorBase is the first wire code available to
+// application-defined errors when ErrorCodeMap.Base is zero. Wire codes below
+// the base are reserved for the protocol.
+const DefaultApplicationErrorBase uint32 = 0x1000
+
+// IsReservedErrorCode reports whether code is defined by the MOQ protocol in
+// the given space.
+func IsReservedErrorCode(space ErrorSpace, code uint32) bool {
+	return protocolErrorText(space, code) != ""
+}
+
+// protocolErrorText returns the text of a protocol-defined code, or an empty
+// string if the code is not defined in the space.
+func protocolErrorText(space ErrorSpace, code uint32) string {
+	switch space {
+	case SessionErrorSpace:
+		return SessionErrorCode(code).String()
+	case AnnounceErrorSpace:
+		return AnnounceErrorCode(code).String()
+	case SubscribeErrorSpace:
+		return SubscribeErrorCode(code).String()
+	case FetchErrorSpace:
+		return FetchErrorCode(code).String()
+	case ProbeErrorSpace:
+		return ProbeErrorCode(code).String()
+	case GroupErrorSpace:
+		return GroupErrorCode(code).String()
+	default:
+		return ""
+	}
+}
+
+// ErrorCodeMap translates between application-defined error codes and the
+// wire codes carried by QUIC stream resets and connection closes.
+//
+// Application code n maps to wire code Base+n, so custom errors can never
+// collide with protocol-defined codes, which all lie below Base. Codes may
+// optionally be registered with a descriptive text that is returned by Text.
+//
+// The zero value is ready to use. An ErrorCodeMap is safe for concurrent use.
+type ErrorCodeMap struct {
+	// Base is the wire code of application error code 0.
+	// If zero, DefaultApplicationErrorBase is used.
+	// Base must be greater than every protocol-defined code.
+	Base uint32
+
+	mu         sync.RWMutex
+	registered map[ErrorSpace]map[uint32]string
+}
+
+func (m *ErrorCodeMap) base() uint32 {
+	if m != nil && m.Base != 0 {
+		return m.Base
+	}
+	return DefaultApplicationErrorBase
+}
+
+// Validate reports an error if Base overlaps protocol-defined codes.
+func (m *ErrorCodeMap) Validate() error {
+	base := m.base()
+	for space := SessionErrorSpace; space <= GroupErrorSpace; space++ {
+		for _, code := range reservedErrorCodes(space) {
+			if code >= base {
+				return fmt.Errorf("moqt: application error base %#x overlaps reserved %s error code %#x", base, space, code)
+			}
+		}
+	}
+	return nil
+}
+
+// Register records text as the description of application code in space.
+// It fails if the code would overflow the wire range or is already registered.
+func (m *ErrorCodeMap) Register(space ErrorSpace, code uint32, text string) error {
+	if space.String() == "" {
+		return fmt.Errorf("moqt: unknown error space %d", space)
+	}
+	if _, err := m.WireCode(space, code); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.registered == nil {
+		m.registered = make(map[ErrorSpace]map[uint32]string)
+	}
+	codes := m.registered[space]
+	if codes == nil {
+		codes = make(map[uint32]string)
+		m.registered[space] = codes
+	}
+	if existing, ok := codes[code]; ok {
+		return fmt.Errorf("moqt: %s error code %d already registered as %q", space, code, existing)
+	}
+	codes[code] = text
+	return nil
+}
+
+// WireCode returns the wire code for application code in space. It fails
+// only if the code overflows the wire range; Base is not checked against
+// the protocol-defined codes, which Validate does, as a Server or Dialer
+// does for Config.ErrorCodes when it starts.
+func (m *ErrorCodeMap) WireCode(space ErrorSpace, code uint32) (uint32, error) {
+	base := m.base()
+	if code > math.MaxUint32-base {
+		return 0, fmt.Errorf("moqt: %s application error code %d overflows the wire range", space, code)
+	}
+	return base + code, nil
+}
+
+// ApplicationCode returns the application code for a wire code received in
+// space. It returns false if the wire code lies in the protocol range.
+func (m *ErrorCodeMap) ApplicationCode(space ErrorSpace, wire uint32) (uint32, bool) {
+	base := m.base()
+	if wire < base {
+		return 0, false
+	}
+	return wire - base, true
+}
+
+// Text returns a description of a wire code received in space. Protocol
+// codes use their built-in text; application codes use the registered text.
+// It returns an empty string if the code is unknown.
+func (m *ErrorCodeMap) Text(space ErrorSpace, wire uint32) string {
+	if text := protocolErrorText(space, wire); text != "" {
+		return text
+	}
+
+	code, ok := m.ApplicationCode(space, wire)
+	if !ok || m == nil {
+		return ""
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.registered[space][code]
+}
+
+// ApplicationErrorCode is an application-defined error code received from
+// the peer, as reported by ErrorCodeMap.ApplicationError.
+type ApplicationErrorCode struct {
+	// Space is the error space the code was received in.
+	Space ErrorSpace
+
+	// Code is the application code, with the base of the map removed.
+	Code uint32
+
+	// Text is the registered description of the code, if any.
+	Text string
+}
+
+// ApplicationError returns the application code carried by a MOQ error in
+// err's chain, such as a *SessionError or a *GroupError. It returns false if
+// err carries no MOQ error or the code is protocol-defined.
+func (m *ErrorCodeMap) ApplicationError(err error) (ApplicationErrorCode, bool) {
+	space, wire, ok := errorCodeOf(err)
+	if !ok {
+		return ApplicationErrorCode{}, false
+	}
+	code, ok := m.ApplicationCode(space, wire)
+	if !ok {
+		return ApplicationErrorCode{}, false
+	}
+	return ApplicationErrorCode{Space: space, Code: code, Text: m.Text(space, wire)}, true
+}
+
+// errorCodeOf returns the space and wire code of the MOQ error in err's chain.
+func errorCodeOf(err error) (ErrorSpace, uint32, bool) {
+	if e, ok := errors.AsType[*SessionError](err); ok {
+		return SessionErrorSpace, uint32(e.SessionErrorCode()), true
+	}
+	if e, ok := errors.AsType[*AnnounceError](err); ok {
+		return AnnounceErrorSpace, uint32(e.AnnounceErrorCode()), true
+	}
+	if e, ok := errors.AsType[*SubscribeError](err); ok {
+		return SubscribeErrorSpace, uint32(e.SubscribeErrorCode()), true
+	}
+	if e, ok := errors.AsType[*FetchError](err); ok {
+		return FetchErrorSpace, uint32(e.FetchErrorCode()), true
+	}
+	if e, ok := errors.AsType[*ProbeError](err); ok {
+		return ProbeErrorSpace, uint32(e.ProbeErrorCode()), true
+	}
+	if e, ok := errors.AsType[*GroupError](err); ok {
+		return GroupErrorSpace, uint32(e.GroupErrorCode()), true
+	}
+	return 0, 0, false
+}
+
+// reservedErrorCodes lists the protocol-defined codes of a space, in
+// increasing order. They are the codes the String methods have a text for.
+func reservedErrorCodes(space ErrorSpace) []uint32 {
+	switch space {
+	case SessionErrorSpace:
+		return errorCodesOf(sessionErrorCodeTexts)
+	case AnnounceErrorSpace:
+		return errorCodesOf(announceErrorCodeTexts)
+	case SubscribeErrorSpace:
+		return errorCodesOf(subscribeErrorCodeTexts)
+	case FetchErrorSpace:
+		return errorCodesOf(fetchErrorCodeTexts)
+	case ProbeErrorSpace:
+		return errorCodesOf(probeErrorCodeTexts)
+	case GroupErrorSpace:
+		return errorCodesOf(groupErrorCodeTexts)
+	default:
+		return nil
+	}
+}
+
+// errorCodesOf returns the codes of texts in increasing order.
+func errorCodesOf[C ~uint32](texts map[C]string) []uint32 {
+	codes := make([]uint32, 0, len(texts))
+	for code := range texts {
+		codes = append(codes, uint32(code))
+	}
+	slices.Sort(codes)
+	return codes
+}
@@ -0,0 +1,264 @@
+package moqt
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorSpace_String(t *testing.T) {
+	tests := map[string]struct {
+		space    ErrorSpace
+		expected string
+	}{
+		"session":   {space: SessionErrorSpace, expected: "session"},
+		"announce":  {space: AnnounceErrorSpace, expected: "announce"},
+		"subscribe": {space: SubscribeErrorSpace, expected: "subscribe"},
+		"fetch":     {space: FetchErrorSpace, expected: "fetch"},
+		"probe":     {space: ProbeErrorSpace, expected: "probe"},
+		"group":     {space: GroupErrorSpace, expected: "group"},
+		"unknown":   {space: ErrorSpace(0), expected: ""},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.space.String())
+		})
+	}
+}
+
+func TestIsReservedErrorCode(t *testing.T) {
+	tests := map[string]struct {
+		space    ErrorSpace
+		code     uint32
+		expected bool
+	}{
+		"subscribe not found":     {space: SubscribeErrorSpace, code: uint32(SubscribeErrorCodeNotFound), expected: true},
+		"group expired":           {space: GroupErrorSpace, code: uint32(ExpiredGroupErrorCode), expected: true},
+		"session goaway timeout":  {space: SessionErrorSpace, code: uint32(GoAwayTimeoutErrorCode), expected: true},
+		"unassigned session code": {space: SessionErrorSpace, code: 0x4, expected: false},
+		"application range":       {space: SubscribeErrorSpace, code: DefaultApplicationErrorBase, expected: false},
+		"unknown space":           {space: ErrorSpace(0), code: 0, expected: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsReservedErrorCode(tt.space, tt.code))
+		})
+	}
+}
+
+func TestReservedErrorCodes_MatchProtocolText(t *testing.T) {
+	for space := SessionErrorSpace; space <= GroupErrorSpace; space++ {
+		codes := reservedErrorCodes(space)
+		require.NotEmpty(t, codes, "space %s should list reserved codes", space)
+		for _, code := range codes {
+			assert.True(t, IsReservedErrorCode(space, code), "%s code %#x should be reserved", space, code)
+		}
+	}
+}
+
+// TestReservedErrorCodes_CoverDeclaredCodes fails when an error code
+// constant is declared without a text, which would leave it out of the
+// reserved codes.
+func TestReservedErrorCodes_CoverDeclaredCodes(t *testing.T) {
+	spaces := map[string]ErrorSpace{
+		"SessionErrorCode":   SessionErrorSpace,
+		"AnnounceErrorCode":  AnnounceErrorSpace,
+		"SubscribeErrorCode": SubscribeErrorSpace,
+		"FetchErrorCode":     FetchErrorSpace,
+		"ProbeErrorCode":     ProbeErrorSpace,
+		"GroupErrorCode":     GroupErrorSpace,
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	require.NoError(t, err)
+
+	var declared int
+	for _, file := range pkgs["moqt"].Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.ValueSpec)
+			if !ok || spec.Type == nil || len(spec.Values) != len(spec.Names) {
+				return true
+			}
+			typ, ok := spec.Type.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			space, ok := spaces[typ.Name]
+			if !ok {
+				return true
+			}
+			for i, name := range spec.Names {
+				lit, ok := spec.Values[i].(*ast.BasicLit)
+				require.True(t, ok, "%s must be a literal", name.Name)
+				code, err := strconv.ParseUint(lit.Value, 0, 32)
+				require.NoError(t, err)
+				declared++
+				assert.True(t, slices.Contains(reservedErrorCodes(space), uint32(code)),
+					"%s (%s code %#x) has no text and is not reserved", name.Name, space, code)
+			}
+			return true
+		})
+	}
+	assert.NotZero(t, declared)
+}
+
+func TestErrorCodeMap_Validate(t *testing.T) {
+	assert.NoError(t, (&ErrorCodeMap{}).Validate())
+	assert.NoError(t, (*ErrorCodeMap)(nil).Validate())
+	assert.Error(t, (&ErrorCodeMap{Base: 0x3}).Validate())
+}
+
+func TestErrorCodeMap_WireCode(t *testing.T) {
+	tests := map[string]struct {
+		base      uint32
+		code      uint32
+		expected  uint32
+		expectErr bool
+	}{
+		"default base": {code: 7, expected: DefaultApplicationErrorBase + 7},
+		"custom base":  {base: 0x100, code: 1, expected: 0x101},
+		"overflow":     {code: math.MaxUint32, expectErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &ErrorCodeMap{Base: tt.base}
+			wire, err := m.WireCode(SubscribeErrorSpace, tt.code)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, wire)
+			assert.False(t, IsReservedErrorCode(SubscribeErrorSpace, wire))
+
+			code, ok := m.ApplicationCode(SubscribeErrorSpace, wire)
+			assert.True(t, ok)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func TestErrorCodeMap_ApplicationCode_ProtocolRange(t *testing.T) {
+	var m ErrorCodeMap
+
+	_, ok := m.ApplicationCode(GroupErrorSpace, uint32(ExpiredGroupErrorCode))
+	assert.False(t, ok, "protocol codes must not map to application codes")
+}
+
+func TestErrorCodeMap_Register(t *testing.T) {
+	var m ErrorCodeMap
+
+	require.NoError(t, m.Register(SubscribeErrorSpace, 1, "geo blocked"))
+	// The same code in a different space is independent.
+	require.NoError(t, m.Register(GroupErrorSpace, 1, "encoder restart"))
+
+	err := m.Register(SubscribeErrorSpace, 1, "duplicate")
+	assert.Error(t, err, "registering the same code twice should fail")
+
+	err = m.Register(ErrorSpace(0), 2, "unknown space")
+	assert.Error(t, err)
+
+	wire, err := m.WireCode(SubscribeErrorSpace, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "geo blocked", m.Text(SubscribeErrorSpace, wire))
+	assert.Equal(t, "encoder restart", m.Text(GroupErrorSpace, wire))
+}
+
+func TestErrorCodeMap_Text(t *testing.T) {
+	var m ErrorCodeMap
+	require.NoError(t, m.Register(SubscribeErrorSpace, 0, "custom"))
+
+	assert.Equal(t, SubscribeErrorCodeNotFound.String(), m.Text(SubscribeErrorSpace, uint32(SubscribeErrorCodeNotFound)))
+	assert.Equal(t, "custom", m.Text(SubscribeErrorSpace, DefaultApplicationErrorBase))
+	assert.Equal(t, "", m.Text(SubscribeErrorSpace, DefaultApplicationErrorBase+1))
+	assert.Equal(t, "", m.Text(SubscribeErrorSpace, 0x40))
+}
+
+func TestErrorCodeMap_NilReceiver(t *testing.T) {
+	var m *ErrorCodeMap
+
+	wire, err := m.WireCode(FetchErrorSpace, 2)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultApplicationErrorBase+2, wire)
+	assert.Equal(t, "", m.Text(FetchErrorSpace, wire))
+}
+
+func TestErrorCodeMap_ConcurrentRegister(t *testing.T) {
+	var m ErrorCodeMap
+
+	var wg sync.WaitGroup
+	for i := range 32 {
+		wg.Go(func() {
+			assert.NoError(t, m.Register(GroupErrorSpace, uint32(i), "code"))
+			wire, _ := m.WireCode(GroupErrorSpace, uint32(i))
+			_ = m.Text(GroupErrorSpace, wire)
+		})
+	}
+	wg.Wait()
+}
+
+func TestErrorCodeMap_ApplicationError(t *testing.T) {
+	m := &ErrorCodeMap{Base: 0x2000}
+	require.NoError(t, m.Register(GroupErrorSpace, 3, "encoder restart"))
+
+	stream := func(code uint32) *transport.StreamError {
+		return &transport.StreamError{ErrorCode: transport.StreamErrorCode(code), Remote: true}
+	}
+
+	tests := map[string]struct {
+		err    error
+		want   ApplicationErrorCode
+		wantOK bool
+	}{
+		"session": {
+			err:    &SessionError{ApplicationError: &transport.ApplicationError{ErrorCode: 0x2001, Remote: true}},
+			want:   ApplicationErrorCode{Space: SessionErrorSpace, Code: 1},
+			wantOK: true,
+		},
+		"subscribe": {
+			err:    &SubscribeError{StreamError: stream(0x2002)},
+			want:   ApplicationErrorCode{Space: SubscribeErrorSpace, Code: 2},
+			wantOK: true,
+		},
+		"group with text": {
+			err:    &GroupError{StreamError: stream(0x2003)},
+			want:   ApplicationErrorCode{Space: GroupErrorSpace, Code: 3, Text: "encoder restart"},
+			wantOK: true,
+		},
+		"wrapped": {
+			err:    fmt.Errorf("read: %w", &AnnounceError{StreamError: stream(0x2004)}),
+			want:   ApplicationErrorCode{Space: AnnounceErrorSpace, Code: 4},
+			wantOK: true,
+		},
+		"protocol code": {
+			err: &SubscribeError{StreamError: stream(uint32(SubscribeErrorCodeNotFound))},
+		},
+		"not a moq error": {
+			err: io.EOF,
+		},
+		"nil": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := m.ApplicationError(tt.err)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
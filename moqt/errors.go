@@ -17,6 +17,48 @@ var (
 
 	// ErrServerClosed is returned when the server has been closed.
 	ErrServerClosed = errors.New("moqt: server closed")
+
+	// ErrServerDraining is returned for connections refused by a server
+	// that is draining; see Server.Drain.
+	ErrServerDraining = errors.New("moqt: server draining")
+
+	// ErrClosedTrack is returned when attempting to use a closed TrackReader.
+	ErrClosedTrack = errors.New("moqt: closed track")
+
+	// ErrChecksumMismatch is returned by GroupReader.ReadFrame when a frame
+	// fails checksum verification.
+	ErrChecksumMismatch = errors.New("moqt: frame checksum mismatch")
+
+	// ErrSchemaMismatch is matched by the SchemaError returned by
+	// GroupReader.ReadFrame when a frame has a schema the reader does not
+	// accept.
+	ErrSchemaMismatch = errors.New("moqt: frame schema mismatch")
+
+	// ErrInvalidExtensionHeaders is returned by GroupReader.ReadFrame for a
+	// frame whose extension headers cannot be decoded.
+	ErrInvalidExtensionHeaders = errors.New("moqt: invalid extension headers")
+
+	// ErrGroupOutOfRange is returned by TrackWriter.OpenGroup and OpenGroupAt
+	// for a group past the EndGroup of the subscription.
+	ErrGroupOutOfRange = errors.New("moqt: group out of subscribed range")
+
+	// ErrGroupTruncated is matched by the error returned by GroupReader.Err
+	// for a group that ended before the publisher closed its stream.
+	ErrGroupTruncated = errors.New("moqt: group truncated")
+
+	// ErrGroupNotFound is returned by a GroupStore that does not hold the
+	// requested group.
+	ErrGroupNotFound = errors.New("moqt: group not found")
+
+	// ErrSessionIdle is matched by the SessionError of a session closed with
+	// IdleTimeoutErrorCode, by either end, because its peer went silent
+	// beyond Config.IdleTimeout.
+	ErrSessionIdle = errors.New("moqt: session idle")
+
+	// ErrUnsupportedVersion is returned by a Dialer when the server selects
+	// no version of Config.SupportedVersions, such as a WebTransport server
+	// predating version negotiation while VersionLite04 is not supported.
+	ErrUnsupportedVersion = errors.New("moqt: unsupported version")
 )
 
 /*
@@ -40,25 +82,20 @@ const (
 	AnnounceErrorCodeInvalidPrefix AnnounceErrorCode = 0x5
 )
 
+// announceErrorCodeTexts holds the texts of the protocol-defined announce error codes.
+var announceErrorCodeTexts = map[AnnounceErrorCode]string{
+	AnnounceErrorCodeInternal:      "moqt: internal error",
+	AnnounceErrorCodeDuplicated:    "moqt: duplicated broadcast path",
+	AnnounceErrorCodeInvalidStatus: "moqt: invalid announce status",
+	UninterestedErrorCode:          "moqt: uninterested",
+	BannedPrefixErrorCode:          "moqt: banned prefix",
+	AnnounceErrorCodeInvalidPrefix: "moqt: invalid prefix",
+}
+
 // String returns a text for the announce error code.
 // It returns an empty string if the code is unknown.
 func (code AnnounceErrorCode) String() string {
-	switch code {
-	case AnnounceErrorCodeInternal:
-		return "moqt: internal error"
-	case AnnounceErrorCodeDuplicated:
-		return "moqt: duplicated broadcast path"
-	case AnnounceErrorCodeInvalidStatus:
-		return "moqt: invalid announce status"
-	case UninterestedErrorCode:
-		return "moqt: uninterested"
-	case BannedPrefixErrorCode:
-		return "moqt: banned prefix"
-	case AnnounceErrorCodeInvalidPrefix:
-		return "moqt: invalid prefix"
-	default:
-		return ""
-	}
+	return announceErrorCodeTexts[code]
 }
 
 // AnnounceError wraps a QUIC stream error with announcement-specific error codes.
@@ -95,27 +132,30 @@ const (
 
 	// Subscriber-side timeout.
 	SubscribeErrorCodeTimeout SubscribeErrorCode = 0x05
+
+	// The publisher is draining and accepts no new subscriptions.
+	SubscribeErrorCodeGoingAway SubscribeErrorCode = 0x06
+
+	// A registered parameter of the subscription has a malformed value.
+	SubscribeErrorCodeInvalidParameter SubscribeErrorCode = 0x07
 )
 
+// subscribeErrorCodeTexts holds the texts of the protocol-defined subscribe error codes.
+var subscribeErrorCodeTexts = map[SubscribeErrorCode]string{
+	SubscribeErrorCodeInternal:         "moqt: internal error",
+	SubscribeErrorCodeInvalidRange:     "moqt: invalid range",
+	SubscribeErrorCodeDuplicateID:      "moqt: duplicated id",
+	SubscribeErrorCodeNotFound:         "moqt: track does not exist",
+	SubscribeErrorCodeUnauthorized:     "moqt: unauthorized",
+	SubscribeErrorCodeTimeout:          "moqt: timeout",
+	SubscribeErrorCodeGoingAway:        "moqt: going away",
+	SubscribeErrorCodeInvalidParameter: "moqt: invalid parameter",
+}
+
 // String returns a text for the subscribe error code.
 // It returns an empty string if the code is unknown.
 func (code SubscribeErrorCode) String() string {
-	switch code {
-	case SubscribeErrorCodeInternal:
-		return "moqt: internal error"
-	case SubscribeErrorCodeInvalidRange:
-		return "moqt: invalid range"
-	case SubscribeErrorCodeDuplicateID:
-		return "moqt: duplicated id"
-	case SubscribeErrorCodeNotFound:
-		return "moqt: track does not exist"
-	case SubscribeErrorCodeUnauthorized:
-		return "moqt: unauthorized"
-	case SubscribeErrorCodeTimeout:
-		return "moqt: timeout"
-	default:
-		return ""
-	}
+	return subscribeErrorCodeTexts[code]
 }
 
 // SubscribeError wraps a QUIC stream error with subscription-specific error codes.
@@ -140,17 +180,16 @@ const (
 	FetchErrorCodeTimeout  FetchErrorCode = 0x01
 )
 
+// fetchErrorCodeTexts holds the texts of the protocol-defined fetch error codes.
+var fetchErrorCodeTexts = map[FetchErrorCode]string{
+	FetchErrorCodeInternal: "moqt: internal error",
+	FetchErrorCodeTimeout:  "moqt: timeout",
+}
+
 // String returns a text for the fetch error code.
 // It returns an empty string if the code is unknown.
 func (code FetchErrorCode) String() string {
-	switch code {
-	case FetchErrorCodeInternal:
-		return "moqt: internal error"
-	case FetchErrorCodeTimeout:
-		return "moqt: timeout"
-	default:
-		return ""
-	}
+	return fetchErrorCodeTexts[code]
 }
 
 type FetchError struct{ *transport.StreamError }
@@ -175,19 +214,17 @@ const (
 	ProbeErrorCodeNotSupported ProbeErrorCode = 0x02
 )
 
+// probeErrorCodeTexts holds the texts of the protocol-defined probe error codes.
+var probeErrorCodeTexts = map[ProbeErrorCode]string{
+	ProbeErrorCodeInternal:     "moqt: internal error",
+	ProbeErrorCodeTimeout:      "moqt: timeout",
+	ProbeErrorCodeNotSupported: "moqt: not supported",
+}
+
 // String returns a text for the probe error code.
 // It returns an empty string if the code is unknown.
 func (code ProbeErrorCode) String() string {
-	switch code {
-	case ProbeErrorCodeInternal:
-		return "moqt: internal error"
-	case ProbeErrorCodeTimeout:
-		return "moqt: timeout"
-	case ProbeErrorCodeNotSupported:
-		return "moqt: not supported"
-	default:
-		return ""
-	}
+	return probeErrorCodeTexts[code]
 }
 
 type ProbeError struct{ *transport.StreamError }
@@ -221,36 +258,30 @@ const (
 	ParameterLengthMismatchErrorCode SessionErrorCode = 0x5
 	TooManySubscribeErrorCode        SessionErrorCode = 0x6
 	GoAwayTimeoutErrorCode           SessionErrorCode = 0x10
+	IdleTimeoutErrorCode             SessionErrorCode = 0x11
 	UnsupportedVersionErrorCode      SessionErrorCode = 0x12
 
 	SetupFailedErrorCode SessionErrorCode = 0x13
 )
 
+// sessionErrorCodeTexts holds the texts of the protocol-defined session error codes.
+var sessionErrorCodeTexts = map[SessionErrorCode]string{
+	NoError:                          "moqt: no error",
+	InternalSessionErrorCode:         "moqt: internal error",
+	UnauthorizedSessionErrorCode:     "moqt: unauthorized",
+	ProtocolViolationErrorCode:       "moqt: protocol violation",
+	ParameterLengthMismatchErrorCode: "moqt: parameter length mismatch",
+	TooManySubscribeErrorCode:        "moqt: too many subscribes",
+	GoAwayTimeoutErrorCode:           "moqt: goaway timeout",
+	IdleTimeoutErrorCode:             "moqt: idle timeout",
+	UnsupportedVersionErrorCode:      "moqt: unsupported version",
+	SetupFailedErrorCode:             "moqt: setup failed",
+}
+
 // String returns a text for the session error code.
 // It returns an empty string if the code is unknown.
 func (code SessionErrorCode) String() string {
-	switch code {
-	case NoError:
-		return "moqt: no error"
-	case InternalSessionErrorCode:
-		return "moqt: internal error"
-	case UnauthorizedSessionErrorCode:
-		return "moqt: unauthorized"
-	case ProtocolViolationErrorCode:
-		return "moqt: protocol violation"
-	case ParameterLengthMismatchErrorCode:
-		return "moqt: parameter length mismatch"
-	case TooManySubscribeErrorCode:
-		return "moqt: too many subscribes"
-	case GoAwayTimeoutErrorCode:
-		return "moqt: goaway timeout"
-	case UnsupportedVersionErrorCode:
-		return "moqt: unsupported version"
-	case SetupFailedErrorCode:
-		return "moqt: setup failed"
-	default:
-		return ""
-	}
+	return sessionErrorCodeTexts[code]
 }
 
 // SessionError wraps a QUIC application error with session-specific error codes.
@@ -274,6 +305,13 @@ func (err SessionError) SessionErrorCode() SessionErrorCode {
 	return SessionErrorCode(err.ErrorCode)
 }
 
+// Is reports whether target is ErrSessionIdle and the session was closed
+// with IdleTimeoutErrorCode.
+func (err SessionError) Is(target error) bool {
+	return target == ErrSessionIdle && err.ApplicationError != nil &&
+		err.SessionErrorCode() == IdleTimeoutErrorCode
+}
+
 /*
  * Group Error
  */
@@ -292,27 +330,21 @@ const (
 	InvalidSubscribeIDErrorCode GroupErrorCode = 0x07
 )
 
+// groupErrorCodeTexts holds the texts of the protocol-defined group error codes.
+var groupErrorCodeTexts = map[GroupErrorCode]string{
+	InternalGroupErrorCode:      "moqt: internal error",
+	OutOfRangeErrorCode:         "moqt: out of range",
+	ExpiredGroupErrorCode:       "moqt: group expires",
+	SubscribeCanceledErrorCode:  "moqt: subscribe canceled",
+	PublishAbortedErrorCode:     "moqt: publish aborted",
+	ClosedSessionGroupErrorCode: "moqt: session closed",
+	InvalidSubscribeIDErrorCode: "moqt: invalid subscribe id",
+}
+
 // String returns a text for the group error code.
 // It returns an empty string if the code is unknown.
 func (code GroupErrorCode) String() string {
-	switch code {
-	case InternalGroupErrorCode:
-		return "moqt: internal error"
-	case OutOfRangeErrorCode:
-		return "moqt: out of range"
-	case ExpiredGroupErrorCode:
-		return "moqt: group expires"
-	case SubscribeCanceledErrorCode:
-		return "moqt: subscribe canceled"
-	case PublishAbortedErrorCode:
-		return "moqt: publish aborted"
-	case ClosedSessionGroupErrorCode:
-		return "moqt: session closed"
-	case InvalidSubscribeIDErrorCode:
-		return "moqt: invalid subscribe id"
-	default:
-		return ""
-	}
+	return groupErrorCodeTexts[code]
 }
 
 // GroupError wraps a QUIC stream error with group-specific error codes.
@@ -329,3 +361,28 @@ func (err GroupError) Error() string {
 func (err GroupError) GroupErrorCode() GroupErrorCode {
 	return GroupErrorCode(err.ErrorCode)
 }
+
+// GroupTruncatedError is returned by GroupReader.Err for a group that ended
+// before the publisher closed its stream. It matches ErrGroupTruncated with
+// errors.Is.
+type GroupTruncatedError struct {
+	// Frames is the number of frames read before the group ended.
+	Frames int64
+
+	// Err is why the group ended, such as a *GroupError for a reset stream.
+	Err error
+}
+
+func (e *GroupTruncatedError) Error() string {
+	return fmt.Sprintf("moqt: group truncated after %d frames: %v", e.Frames, e.Err)
+}
+
+// Is reports whether target is ErrGroupTruncated.
+func (e *GroupTruncatedError) Is(target error) bool {
+	return target == ErrGroupTruncated
+}
+
+// Unwrap returns why the group ended.
+func (e *GroupTruncatedError) Unwrap() error {
+	return e.Err
+}
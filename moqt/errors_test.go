@@ -113,6 +113,14 @@ func TestSubscribeErrorCode_String(t *testing.T) {
 			code:   SubscribeErrorCodeTimeout,
 			expect: "moqt: timeout",
 		},
+		"subscribe going away error code": {
+			code:   SubscribeErrorCodeGoingAway,
+			expect: "moqt: going away",
+		},
+		"subscribe invalid parameter error code": {
+			code:   SubscribeErrorCodeInvalidParameter,
+			expect: "moqt: invalid parameter",
+		},
 		"unknown code": {
 			code:   SubscribeErrorCode(0xFF), // Some arbitrary value not defined
 			expect: "",
@@ -166,6 +174,10 @@ func TestSessionErrorCode_String(t *testing.T) {
 			code:   GoAwayTimeoutErrorCode,
 			expect: "moqt: goaway timeout",
 		},
+		"idle timeout error code": {
+			code:   IdleTimeoutErrorCode,
+			expect: "moqt: idle timeout",
+		},
 		"unsupported version error code": {
 			code:   UnsupportedVersionErrorCode,
 			expect: "moqt: unsupported version",
@@ -688,6 +700,8 @@ func TestErrorTextConsistency(t *testing.T) {
 			SubscribeErrorCodeNotFound,
 			SubscribeErrorCodeUnauthorized,
 			SubscribeErrorCodeTimeout,
+			SubscribeErrorCodeGoingAway,
+			SubscribeErrorCodeInvalidParameter,
 		}
 
 		for _, code := range codes {
@@ -711,6 +725,7 @@ func TestErrorTextConsistency(t *testing.T) {
 			ParameterLengthMismatchErrorCode,
 			TooManySubscribeErrorCode,
 			GoAwayTimeoutErrorCode,
+			IdleTimeoutErrorCode,
 			UnsupportedVersionErrorCode,
 			SetupFailedErrorCode,
 		}
@@ -788,6 +803,8 @@ func TestErrorText_NonEmpty(t *testing.T) {
 			SubscribeErrorCodeNotFound,
 			SubscribeErrorCodeUnauthorized,
 			SubscribeErrorCodeTimeout,
+			SubscribeErrorCodeGoingAway,
+			SubscribeErrorCodeInvalidParameter,
 		}
 
 		for _, code := range codes {
@@ -805,6 +822,7 @@ func TestErrorText_NonEmpty(t *testing.T) {
 			ParameterLengthMismatchErrorCode,
 			TooManySubscribeErrorCode,
 			GoAwayTimeoutErrorCode,
+			IdleTimeoutErrorCode,
 			UnsupportedVersionErrorCode,
 			SetupFailedErrorCode,
 		}
@@ -0,0 +1,91 @@
+package moqt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+)
+
+// ExtensionHeaders are the extension headers of an object, keyed by header
+// type and held in their encoded form. They carry per-object metadata, such
+// as capture timestamps or encryption key IDs, end to end. Values of a known
+// type are accessed with a Parameter, as those of Parameters are.
+//
+// MOQ Lite frames have no header fields besides their length, so extension
+// headers are carried as a block prefixed to the frame payload on the wire:
+// a varint count followed by the headers, each a varint type and a
+// length-prefixed value; see ChecksumMode for the checksum and SchemaID for
+// the schema ID, which are headers too. The header of every group
+// announces whether its frames carry the block, so subscribers and relays
+// read it without configuration. Fetched groups have no header, so the fetch handler and
+// the fetching subscriber set it on the group with
+// GroupWriter.SetExtensionHeaders and GroupReader.SetExtensionHeaders.
+type ExtensionHeaders = Parameters
+
+// errExtensionHeadersUnsupported is returned when extension headers are
+// enabled on a track of a session whose version cannot announce them.
+var errExtensionHeadersUnsupported = errors.New("moqt: extension headers require VersionLite04Ext")
+
+// appendExtensionHeaders appends the extension header block of frame to b,
+// giving the prefix of the frame payload on the wire of a group with
+// extension headers. The block holds the extension headers of frame if ext
+// is set, the schema header of schema if it is not zero, and the checksum
+// header of the payload if checksum is not nil.
+func appendExtensionHeaders(b []byte, frame *Frame, ext bool, schema SchemaID, checksum *groupChecksum) []byte {
+	var headers ExtensionHeaders
+	if ext {
+		headers = frame.ext
+	}
+	if schema != 0 {
+		headers = maps.Clone(headers)
+		SchemaHeader.Set(&headers, uint64(schema))
+	}
+	if checksum != nil {
+		headers = checksum.headers(headers, frame.Body())
+	}
+	return message.ExtensionHeaders(headers).Append(b)
+}
+
+// encodeFrameWith writes frame with prefix, its extension header block, if
+// any.
+func encodeFrameWith(w io.Writer, frame *Frame, prefix []byte) error {
+	if len(prefix) > 0 {
+		return encodeFramePrefixed(w, prefix, frame)
+	}
+	return frame.encode(w)
+}
+
+// encodeFramePrefixed writes frame with prefix inserted before its payload.
+func encodeFramePrefixed(w io.Writer, prefix []byte, frame *Frame) error {
+	body := frame.Body()
+
+	var header [8]byte
+	h, _ := message.WriteMessageLength(header[:0], uint64(len(prefix)+len(body)))
+	if _, err := w.Write(h); err != nil {
+		return err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// readExtensionHeaders strips the extension headers from a decoded frame
+// into frame.ext, and takes its schema ID from them. Malformed headers return ErrInvalidExtensionHeaders and
+// leave the payload as received.
+func readExtensionHeaders(frame *Frame) error {
+	ext, n, err := message.ReadExtensionHeaders(frame.body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExtensionHeaders, err)
+	}
+	copy(frame.body, frame.body[n:])
+	frame.body = frame.body[:len(frame.body)-n]
+	frame.writeHeader()
+	frame.ext = ExtensionHeaders(ext)
+	readSchema(frame)
+	return nil
+}
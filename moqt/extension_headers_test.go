@@ -0,0 +1,211 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var captureTime = VarintParameter(0x02, "capture-time")
+
+// writeExtensionGroup writes frames with headers through a GroupWriter with
+// extension headers enabled and the given schema ID and checksum mode, and
+// returns the encoded group stream.
+func writeExtensionGroup(t *testing.T, id SchemaID, mode ChecksumMode, headers []ExtensionHeaders, payloads ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	group := newGroupWriter(&FakeQUICSendStream{WriteFunc: buf.Write}, GroupSequence(1), nil)
+	group.SetExtensionHeaders(true)
+	group.SetSchema(id)
+	group.SetChecksum(mode)
+
+	for i, payload := range payloads {
+		frame := NewFrame(len(payload))
+		_, _ = frame.Write([]byte(payload))
+		frame.SetExtensions(headers[i])
+		require.NoError(t, group.WriteFrame(frame))
+	}
+	return buf.Bytes()
+}
+
+func TestExtensionHeaders_ReadFrame(t *testing.T) {
+	var stamped ExtensionHeaders
+	captureTime.Set(&stamped, 1234567)
+	stamped[0x0b] = []byte("key-1")
+
+	tests := map[string]struct {
+		id      SchemaID
+		mode    ChecksumMode
+		headers ExtensionHeaders
+	}{
+		"headers":             {headers: stamped},
+		"no headers":          {},
+		"with schema":         {id: 3, headers: stamped},
+		"with frame checksum": {id: 3, mode: ChecksumFrame, headers: stamped},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := writeExtensionGroup(t, tt.id, tt.mode, []ExtensionHeaders{tt.headers, nil}, "data", "next")
+			group := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil)
+			group.SetExtensionHeaders(true)
+			group.SetChecksum(tt.mode, ChecksumPolicyError)
+			if tt.id != 0 {
+				group.SetSchemaLookup(NewSchemaRegistry(Schema{ID: tt.id}).Lookup)
+			}
+
+			frame := NewFrame(0)
+			require.NoError(t, group.ReadFrame(frame))
+			assert.Equal(t, "data", string(frame.Body()))
+			assert.Equal(t, tt.id, frame.SchemaID())
+			assert.Equal(t, tt.headers, withoutAdded(frame.Extensions()))
+			if tt.headers != nil {
+				v, ok := captureTime.Get(frame.Extensions())
+				assert.True(t, ok)
+				assert.Equal(t, uint64(1234567), v)
+			}
+
+			// Headers do not leak into the next frame.
+			require.NoError(t, group.ReadFrame(frame))
+			assert.Equal(t, "next", string(frame.Body()))
+			assert.Nil(t, withoutAdded(frame.Extensions()))
+		})
+	}
+}
+
+// withoutAdded returns headers without the checksum and schema headers,
+// which stay among the headers of a frame read, or nil if none remain.
+func withoutAdded(headers ExtensionHeaders) ExtensionHeaders {
+	headers = headers.Clone()
+	delete(headers, ChecksumFrameHeader.Key())
+	delete(headers, ChecksumGroupHeader.Key())
+	delete(headers, SchemaHeader.Key())
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+func TestExtensionHeaders_ReaderWithoutHeadersSeesBlock(t *testing.T) {
+	data := writeExtensionGroup(t, 0, ChecksumNone, []ExtensionHeaders{{0x02: {0x05}}}, "abc")
+	group := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil)
+
+	frame := NewFrame(0)
+	require.NoError(t, group.ReadFrame(frame))
+	assert.Equal(t, "\x01\x02\x01\x05abc", string(frame.Body()))
+	assert.Nil(t, frame.Extensions())
+}
+
+func TestExtensionHeaders_Malformed(t *testing.T) {
+	var buf bytes.Buffer
+	group := newGroupWriter(&FakeQUICSendStream{WriteFunc: buf.Write}, GroupSequence(1), nil)
+	for _, payload := range []string{"\x02\x01\x00\x01\x00", "\x00ok"} {
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte(payload))
+		require.NoError(t, group.WriteFrame(frame))
+	}
+
+	reader := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(buf.Bytes()).Read}, nil)
+	reader.SetExtensionHeaders(true)
+
+	frame := NewFrame(0)
+	assert.ErrorIs(t, reader.ReadFrame(frame), ErrInvalidExtensionHeaders)
+
+	// A malformed frame does not prevent reading the next one.
+	require.NoError(t, reader.ReadFrame(frame))
+	assert.Equal(t, "ok", string(frame.Body()))
+}
+
+func TestFrame_Extensions(t *testing.T) {
+	frame := NewFrame(0)
+	frame.SetExtensions(ExtensionHeaders{0x02: {0x01}})
+
+	clone := frame.Clone()
+	assert.Equal(t, frame.Extensions(), clone.Extensions())
+	clone.Extensions()[0x02][0] = 0x02
+	assert.Equal(t, []byte{0x01}, frame.Extensions()[0x02], "clone must not share values")
+
+	frame.Reset()
+	assert.NotNil(t, frame.Extensions())
+
+	frame.SetExtensions(nil)
+	assert.Nil(t, frame.Extensions())
+}
+
+func TestTrackWriter_SetExtensionHeaders(t *testing.T) {
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	defer writer.Close()
+
+	require.NoError(t, writer.SetExtensionHeaders(true))
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+	assert.True(t, group.extensionHeaders)
+
+	// The setting was announced in the group header and cannot change.
+	group.SetExtensionHeaders(false)
+	assert.True(t, group.extensionHeaders)
+
+	require.NoError(t, writer.SetExtensionHeaders(false))
+	group, err = writer.OpenGroup()
+	require.NoError(t, err)
+	assert.False(t, group.extensionHeaders)
+}
+
+func TestTrackWriter_ExtensionsUnsupported(t *testing.T) {
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	defer writer.Close()
+
+	// A moq-lite-04 group header cannot announce them.
+	assert.ErrorIs(t, writer.SetExtensionHeaders(true), errExtensionHeadersUnsupported)
+	assert.ErrorIs(t, writer.SetChecksum(ChecksumFrame), errChecksumUnsupported)
+	assert.NoError(t, writer.SetExtensionHeaders(false))
+	assert.NoError(t, writer.SetChecksum(ChecksumNone))
+
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+	assert.False(t, group.extensionHeaders)
+	assert.Nil(t, group.checksum)
+
+	// openGroup refuses the settings as well.
+	writer.extensionHeaders.Store(true)
+	_, err = writer.OpenGroup()
+	assert.ErrorIs(t, err, errExtensionHeadersUnsupported)
+}
+
+func TestTrackReader_ExtensionHeadersAnnounced(t *testing.T) {
+	sess, _ := newTestSessionWithConn(t, extendedConn)
+	substr := newSendSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{})
+	tr := newTrackReader("/broadcastpath", "trackname", substr, func() {})
+	sess.addTrackReader(SubscribeID(1), tr)
+
+	for _, flags := range []uint64{message.GroupFlagExtensionHeaders, 0} {
+		var buf bytes.Buffer
+		require.NoError(t, message.StreamTypeGroup.Encode(&buf))
+		require.NoError(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 7, Flags: flags, Version: message.VersionLite04Ext}.Encode(&buf))
+		sess.processUniStream(&FakeQUICReceiveStream{ReadFunc: buf.Read})
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	gr, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	assert.True(t, gr.HasExtensionHeaders())
+
+	gr, err = tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	assert.False(t, gr.HasExtensionHeaders())
+}
@@ -0,0 +1,105 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// fetchWindow is the number of FETCHes FetchGroups keeps in flight.
+const fetchWindow = 4
+
+// FetchGroups fetches the groups req.GroupSequence through last of a track
+// and yields their readers in order. Each group is fetched with a FETCH of
+// its own, and up to four are in flight at a time, so that the transfer of a
+// group overlaps with reading the previous one. It suits backfilling a
+// player buffer on join while a subscription delivers the live edge.
+//
+// A group the publisher does not hold still yields a reader, whose ReadFrame
+// fails with a *GroupError, typically with OutOfRangeErrorCode, so the
+// caller can skip it. If a FETCH cannot be sent, the error is yielded with a
+// nil reader and the iteration ends. Readers not yet yielded when the
+// iteration stops or ctx is canceled are canceled.
+func (s *Session) FetchGroups(ctx context.Context, req *FetchRequest, last GroupSequence) iter.Seq2[*GroupReader, error] {
+	return func(yield func(*GroupReader, error) bool) {
+		if req == nil {
+			yield(nil, errors.New("moqt: nil fetch request"))
+			return
+		}
+		if last < req.GroupSequence {
+			yield(nil, fmt.Errorf("moqt: invalid fetch range %s-%s", req.GroupSequence, last))
+			return
+		}
+
+		var pending []*GroupReader
+		defer func() {
+			for _, group := range pending {
+				group.CancelRead(ExpiredGroupErrorCode)
+			}
+		}()
+
+		next := req.GroupSequence
+		for len(pending) > 0 || next <= last {
+			for len(pending) < fetchWindow && next <= last {
+				r := req.WithContext(ctx)
+				r.GroupSequence = next
+				group, err := s.Fetch(r)
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				pending = append(pending, group)
+				next++
+			}
+
+			group := pending[0]
+			pending = pending[1:]
+			if !yield(group, nil) {
+				return
+			}
+		}
+	}
+}
+
+// GroupStore holds the frames of past groups of tracks, such as a recording
+// or an application cache, for StoreFetchHandler.
+type GroupStore interface {
+	// Group returns the frames of group seq of a track. It returns an error
+	// wrapping ErrGroupNotFound if the store does not hold the group.
+	Group(ctx context.Context, path BroadcastPath, name TrackName, seq GroupSequence) ([]*Frame, error)
+}
+
+// GroupStoreFunc is an adapter to use ordinary functions as a GroupStore.
+type GroupStoreFunc func(ctx context.Context, path BroadcastPath, name TrackName, seq GroupSequence) ([]*Frame, error)
+
+// Group calls f(ctx, path, name, seq).
+func (f GroupStoreFunc) Group(ctx context.Context, path BroadcastPath, name TrackName, seq GroupSequence) ([]*Frame, error) {
+	return f(ctx, path, name, seq)
+}
+
+// StoreFetchHandler returns a FetchHandler that serves FETCH requests from
+// store. Groups the store does not hold are canceled with
+// OutOfRangeErrorCode, and groups it fails to provide with
+// InternalGroupErrorCode.
+func StoreFetchHandler(store GroupStore) FetchHandler {
+	return FetchHandlerFunc(func(w *GroupWriter, r *FetchRequest) {
+		frames, err := store.Group(r.Context(), r.BroadcastPath, r.TrackName, r.GroupSequence)
+		if err != nil {
+			if errors.Is(err, ErrGroupNotFound) {
+				w.CancelWrite(OutOfRangeErrorCode)
+			} else {
+				w.CancelWrite(InternalGroupErrorCode)
+			}
+			return
+		}
+
+		for _, frame := range frames {
+			if err := w.WriteFrame(frame); err != nil {
+				w.CancelWrite(InternalGroupErrorCode)
+				return
+			}
+		}
+		_ = w.Close()
+	})
+}
@@ -0,0 +1,141 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_FetchGroups_InvalidRequest(t *testing.T) {
+	tests := map[string]struct {
+		req  *FetchRequest
+		last GroupSequence
+	}{
+		"nil request": {
+			req:  nil,
+			last: 1,
+		},
+		"last before first": {
+			req:  &FetchRequest{BroadcastPath: "/test", TrackName: "video", GroupSequence: 5},
+			last: 4,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sess := newTestSession(&FakeStreamConn{})
+			defer func() { _ = sess.CloseWithError(NoError, "") }()
+
+			var errs []error
+			for group, err := range sess.FetchGroups(context.Background(), tt.req, tt.last) {
+				assert.Nil(t, group)
+				errs = append(errs, err)
+			}
+			require.Len(t, errs, 1)
+			assert.Error(t, errs[0])
+		})
+	}
+}
+
+func TestStoreFetchHandler(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		wantCode transport.StreamErrorCode
+		wantData bool
+	}{
+		"group found": {
+			wantData: true,
+		},
+		"group not found": {
+			err:      fmt.Errorf("recording: %w", ErrGroupNotFound),
+			wantCode: transport.StreamErrorCode(OutOfRangeErrorCode),
+		},
+		"store failure": {
+			err:      errors.New("disk failure"),
+			wantCode: transport.StreamErrorCode(InternalGroupErrorCode),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotSeq GroupSequence
+			store := GroupStoreFunc(func(ctx context.Context, path BroadcastPath, name TrackName, seq GroupSequence) ([]*Frame, error) {
+				gotSeq = seq
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				frame := NewFrame(4)
+				frame.Write([]byte("data"))
+				return []*Frame{frame}, nil
+			})
+
+			var written bytes.Buffer
+			var canceled *transport.StreamErrorCode
+			stream := &FakeQUICStream{
+				WriteFunc: written.Write,
+				CancelWriteFunc: func(code transport.StreamErrorCode) {
+					canceled = &code
+				},
+			}
+			w := newGroupWriter(stream, 7, nil)
+			r := &FetchRequest{BroadcastPath: "/test", TrackName: "video", GroupSequence: 7}
+
+			StoreFetchHandler(store).ServeFetch(w, r)
+
+			assert.Equal(t, GroupSequence(7), gotSeq)
+			if tt.wantData {
+				assert.Nil(t, canceled)
+				assert.Contains(t, written.String(), "data")
+			} else {
+				require.NotNil(t, canceled)
+				assert.Equal(t, tt.wantCode, *canceled)
+			}
+		})
+	}
+}
+
+func TestSession_ProcessBiStream_FetchClosedGroupNotReset(t *testing.T) {
+	sess := newTestSession(&FakeStreamConn{})
+	defer func() { _ = sess.CloseWithError(NoError, "") }()
+
+	sess.fetchHandler = FetchHandlerFunc(func(w *GroupWriter, r *FetchRequest) {
+		_ = w.Close()
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, message.StreamTypeFetch.Encode(&buf))
+	require.NoError(t, message.FetchMessage{
+		BroadcastPath: "/test",
+		TrackName:     "video",
+		GroupSequence: 1,
+	}.Encode(&buf))
+
+	data := buf.Bytes()
+	var canceled atomic.Bool
+	stream := &FakeQUICStream{
+		ReadFunc: func(p []byte) (int, error) {
+			if len(data) == 0 {
+				return 0, io.EOF
+			}
+			n := copy(p, data)
+			data = data[n:]
+			return n, nil
+		},
+		CancelWriteFunc: func(transport.StreamErrorCode) {
+			canceled.Store(true)
+		},
+	}
+
+	sess.processBiStream(stream, func() {})
+
+	assert.False(t, canceled.Load(), "a group closed by the handler must not be reset")
+}
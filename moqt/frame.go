@@ -2,16 +2,39 @@ package moqt
 
 import (
 	"io"
+	"sync"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 )
 
+// maxPooledFrameSize is the largest payload capacity of the frames kept by
+// Release, so that a burst of large frames does not pin their memory.
+const maxPooledFrameSize = 1 << 20
+
+var framePool = sync.Pool{
+	New: func() any {
+		return &Frame{}
+	},
+}
+
 // Frame represents a MOQ frame.
 // It provides methods to build, read, and encode MOQ payloads.
+//
+// A Frame is not safe for concurrent mutation. Writing a Frame with
+// GroupWriter.WriteFrame only reads it, so the same Frame may be written to
+// several groups concurrently as long as no goroutine modifies it meanwhile.
 type Frame struct {
-	buf    []byte
-	header [8]byte
-	body   []byte
+	// buf holds an 8-byte header area followed by the payload. The varint
+	// length prefix is kept right-aligned in the header area by every
+	// mutation so that encoding never writes to the frame.
+	buf  []byte
+	body []byte
+
+	// schema is the schema ID the frame was read with.
+	schema SchemaID
+
+	// ext holds the extension headers of the frame.
+	ext ExtensionHeaders
 }
 
 // NewFrame creates a new Frame with the specified payload capacity.
@@ -22,10 +45,57 @@ func NewFrame(cap int) *Frame {
 	return f
 }
 
+// AcquireFrame returns an empty Frame with at least the specified payload
+// capacity, reusing the buffer of a released frame when one is available.
+// Release the frame, or hand it to GroupWriter.SendFrame, once done with it.
+func AcquireFrame(cap int) *Frame {
+	f := framePool.Get().(*Frame)
+	if f.buf == nil || f.Cap() < cap {
+		f.body = nil
+		f.init(cap)
+	}
+	return f
+}
+
+// Release returns the frame to the pool used by AcquireFrame. The frame and
+// its Body must not be used after the call. Releasing frames is optional:
+// frames that are not released are garbage collected.
+func (f *Frame) Release() {
+	if f.Cap() > maxPooledFrameSize {
+		return
+	}
+	f.schema = 0
+	f.ext = nil
+	f.Reset()
+	framePool.Put(f)
+}
+
 // Reset clears the frame payload while preserving the buffer capacity.
 // This allows the frame to be reused without reallocation.
 func (f *Frame) Reset() {
 	f.body = f.body[:0]
+	f.writeHeader()
+}
+
+// SchemaID returns the schema ID the frame was read with by a GroupReader,
+// or zero.
+func (f *Frame) SchemaID() SchemaID {
+	return f.schema
+}
+
+// Extensions returns the extension headers of the frame: those set with
+// SetExtensions, or those it was read with by a GroupReader with extension
+// headers enabled. The map belongs to the frame.
+func (f *Frame) Extensions() ExtensionHeaders {
+	return f.ext
+}
+
+// SetExtensions sets the extension headers written with the frame to a
+// track with extension headers enabled; see TrackWriter.SetExtensionHeaders.
+// The frame keeps h without copying it. Nil removes the headers. Reset
+// keeps them, so that they apply to a rebuilt payload.
+func (f *Frame) SetExtensions(h ExtensionHeaders) {
+	f.ext = h
 }
 
 // Body returns the frame payload bytes.
@@ -42,6 +112,18 @@ func (f *Frame) init(cap int) {
 		copy(body, f.body)
 	}
 	f.body = body
+	f.writeHeader()
+}
+
+// writeHeader stores the varint length of the current payload right-aligned
+// in the header area of buf.
+func (f *Frame) writeHeader() {
+	if len(f.buf) < 8 {
+		return
+	}
+	var header [8]byte
+	h, _ := message.WriteMessageLength(header[:0], uint64(len(f.body)))
+	copy(f.buf[8-len(h):8], h)
 }
 
 // append appends bytes to the frame payload and grows the buffer when needed.
@@ -54,6 +136,58 @@ func (f *Frame) append(b []byte) {
 	}
 
 	f.body = append(f.body, b...)
+	f.writeHeader()
+}
+
+// Grow grows the payload buffer, if needed, so that n more bytes can be
+// appended without another allocation.
+func (f *Frame) Grow(n int) {
+	if n > cap(f.body)-len(f.body) {
+		f.init(max(len(f.body)+n, 2*cap(f.body)))
+	}
+}
+
+// AppendBytes appends to the payload with appendFunc, which is passed the
+// payload and returns it extended, like the append-style methods of
+// encoders. Appends within the capacity of the frame are written directly
+// into the buffer the frame is sent from; call Grow first to make room. If
+// appendFunc reallocates the payload, the result is copied into the frame.
+func (f *Frame) AppendBytes(appendFunc func(b []byte) []byte) {
+	b := appendFunc(f.body)
+	if sameBuffer(b, f.body) {
+		f.body = b
+		f.writeHeader()
+		return
+	}
+	f.body = f.body[:0]
+	f.append(b)
+}
+
+// sameBuffer reports whether a and b share their underlying array.
+func sameBuffer(a, b []byte) bool {
+	return cap(a) > 0 && cap(a) == cap(b) && &a[:cap(a)][0] == &b[:cap(b)][0]
+}
+
+// ReadFrom appends the data read from r until EOF to the payload, reading
+// directly into the buffer the frame is sent from, and returns the number of
+// bytes read. It implements io.ReaderFrom.
+func (f *Frame) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	defer f.writeHeader()
+	for {
+		if len(f.body) == cap(f.body) {
+			f.Grow(max(512, cap(f.body)))
+		}
+		m, err := r.Read(f.body[len(f.body):cap(f.body)])
+		f.body = f.body[:len(f.body)+m]
+		n += int64(m)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
 }
 
 // Len returns the current length of the payload in bytes.
@@ -67,12 +201,10 @@ func (f *Frame) Cap() int {
 }
 
 // encode writes the frame in MOQ format: varint length followed by payload.
-// The length is encoded into the header buffer to minimize allocations.
+// The length header is maintained in buf by writeHeader, so encode issues a
+// single write without touching the frame.
 func (f *Frame) encode(w io.Writer) error {
-	l := uint64(len(f.body))
-	header, _ := message.WriteMessageLength(f.header[:0], l)
-	start := 8 - len(header)
-	copy(f.buf[start:], header)
+	start := 8 - message.VarintLen(uint64(len(f.body)))
 	end := 8 + len(f.body)
 	_, err := w.Write(f.buf[start:end])
 	return err
@@ -81,6 +213,8 @@ func (f *Frame) encode(w io.Writer) error {
 // decode reads a MOQ frame from the reader, updating the payload.
 // The payload buffer is reused or reallocated as needed.
 func (f *Frame) decode(src io.Reader) error {
+	f.schema = 0
+	f.ext = nil
 	num, err := message.ReadMessageLength(src)
 	if err != nil {
 		return err
@@ -88,16 +222,18 @@ func (f *Frame) decode(src io.Reader) error {
 
 	// If payload length is zero, reset the slice to zero length
 	if num == 0 {
-		f.body = f.body[:0]
+		f.Reset()
 		return nil
 	}
 
-	// Ensure the payload slice has enough capacity
+	// Ensure the payload slice has enough capacity. Growing goes through
+	// init so the payload stays backed by buf and can be re-encoded.
 	if cap(f.body) < int(num) {
-		f.body = make([]byte, num)
-	} else {
-		f.body = f.body[:num]
+		f.body = f.body[:0]
+		f.init(int(num))
 	}
+	f.body = f.body[:num]
+	f.writeHeader()
 
 	_, err = io.ReadFull(src, f.body)
 
@@ -109,6 +245,8 @@ func (f *Frame) decode(src io.Reader) error {
 func (f *Frame) Clone() *Frame {
 	clone := NewFrame(f.Cap())
 	clone.append(f.Body())
+	clone.schema = f.schema
+	clone.ext = f.ext.Clone()
 	return clone
 }
 
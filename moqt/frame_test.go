@@ -2,8 +2,10 @@ package moqt
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"testing"
+	"testing/iotest"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 	"github.com/stretchr/testify/assert"
@@ -303,3 +305,178 @@ func TestFrame_Encode(t *testing.T) {
 		})
 	}
 }
+
+func TestFrame_DecodeThenEncode(t *testing.T) {
+	// A decoded frame must re-encode exactly, including when decode had to
+	// grow the payload buffer and when the new payload is shorter.
+	tests := map[string]struct {
+		initialCap int
+		payloads   [][]byte
+	}{
+		"fits in capacity":  {initialCap: 64, payloads: [][]byte{[]byte("hello")}},
+		"grows buffer":      {initialCap: 1, payloads: [][]byte{make([]byte, 300)}},
+		"grow then shrink":  {initialCap: 1, payloads: [][]byte{make([]byte, 300), []byte("x")}},
+		"empty after large": {initialCap: 1, payloads: [][]byte{make([]byte, 100), {}}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			frame := NewFrame(tt.initialCap)
+			for _, payload := range tt.payloads {
+				var wire bytes.Buffer
+				src := NewFrame(len(payload))
+				_, _ = src.Write(payload)
+				require.NoError(t, src.encode(&wire))
+				expected := bytes.Clone(wire.Bytes())
+
+				require.NoError(t, frame.decode(&wire))
+
+				var out bytes.Buffer
+				require.NoError(t, frame.encode(&out))
+				assert.Equal(t, expected, out.Bytes())
+			}
+		})
+	}
+}
+
+func TestFrame_EncodeDoesNotMutate(t *testing.T) {
+	frame := NewFrame(16)
+	_, _ = frame.Write([]byte("immutable"))
+	before := bytes.Clone(frame.buf)
+
+	var buf bytes.Buffer
+	require.NoError(t, frame.encode(&buf))
+
+	assert.Equal(t, before, frame.buf, "encode must only read the frame")
+}
+
+func TestFrame_Grow(t *testing.T) {
+	frame := NewFrame(4)
+	_, _ = frame.Write([]byte("abc"))
+
+	frame.Grow(10)
+	assert.GreaterOrEqual(t, frame.Cap()-frame.Len(), 10)
+	assert.Equal(t, []byte("abc"), frame.Body())
+
+	capBefore := frame.Cap()
+	frame.Grow(1)
+	assert.Equal(t, capBefore, frame.Cap(), "Grow must not reallocate when there is room")
+}
+
+func TestFrame_AppendBytes(t *testing.T) {
+	tests := map[string]struct {
+		capacity int
+		initial  []byte
+		appended []byte
+		inPlace  bool
+	}{
+		"within capacity": {
+			capacity: 16,
+			initial:  []byte("abc"),
+			appended: []byte("defg"),
+			inPlace:  true,
+		},
+		"reallocated": {
+			capacity: 4,
+			initial:  []byte("abc"),
+			appended: []byte("defghijk"),
+		},
+		"empty frame": {
+			capacity: 0,
+			appended: []byte("abc"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			frame := NewFrame(tt.capacity)
+			_, _ = frame.Write(tt.initial)
+			buf := frame.buf
+
+			frame.AppendBytes(func(b []byte) []byte {
+				return append(b, tt.appended...)
+			})
+
+			want := append(append([]byte{}, tt.initial...), tt.appended...)
+			assert.Equal(t, want, frame.Body())
+			if tt.inPlace {
+				assert.Same(t, &buf[0], &frame.buf[0], "appending within capacity must write into the frame buffer")
+			}
+
+			var encoded bytes.Buffer
+			require.NoError(t, frame.encode(&encoded))
+			decoded := NewFrame(0)
+			require.NoError(t, decoded.decode(&encoded))
+			assert.Equal(t, want, decoded.Body())
+		})
+	}
+}
+
+func TestFrame_ReadFrom(t *testing.T) {
+	tests := map[string]struct {
+		capacity int
+		initial  []byte
+		data     []byte
+	}{
+		"fits": {
+			capacity: 64,
+			data:     []byte("payload"),
+		},
+		"grows": {
+			capacity: 2,
+			initial:  []byte("ab"),
+			data:     bytes.Repeat([]byte("x"), 2000),
+		},
+		"empty reader": {
+			capacity: 8,
+			initial:  []byte("ab"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			frame := NewFrame(tt.capacity)
+			_, _ = frame.Write(tt.initial)
+
+			n, err := frame.ReadFrom(bytes.NewReader(tt.data))
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.data)), n)
+
+			want := append(append([]byte{}, tt.initial...), tt.data...)
+			assert.Equal(t, want, frame.Body())
+
+			var encoded bytes.Buffer
+			require.NoError(t, frame.encode(&encoded))
+			decoded := NewFrame(0)
+			require.NoError(t, decoded.decode(&encoded))
+			assert.Equal(t, want, decoded.Body())
+		})
+	}
+}
+
+func TestFrame_ReadFrom_Error(t *testing.T) {
+	frame := NewFrame(8)
+	readErr := errors.New("read error")
+
+	n, err := frame.ReadFrom(io.MultiReader(bytes.NewReader([]byte("abc")), iotest.ErrReader(readErr)))
+	assert.ErrorIs(t, err, readErr)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, []byte("abc"), frame.Body())
+}
+
+func TestAcquireFrame(t *testing.T) {
+	frame := AcquireFrame(32)
+	assert.GreaterOrEqual(t, frame.Cap(), 32)
+	assert.Zero(t, frame.Len())
+
+	_, _ = frame.Write([]byte("data"))
+	frame.schema = 7
+	frame.Release()
+
+	// A frame from the pool is empty, whichever frame is reused.
+	frame = AcquireFrame(64)
+	assert.GreaterOrEqual(t, frame.Cap(), 64)
+	assert.Zero(t, frame.Len())
+	assert.Zero(t, frame.SchemaID())
+	frame.Release()
+}
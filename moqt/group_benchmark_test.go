@@ -6,6 +6,7 @@ import (
 	"io"
 	"sync"
 	"testing"
+	"time"
 )
 
 func newBenchmarkReceiveStream(reader io.Reader) *FakeQUICReceiveStream {
@@ -299,3 +300,37 @@ func BenchmarkFrame_ReuseVsAllocate(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkGroupWriter_WriteFrame_Coalescing benchmarks writing small frames,
+// such as audio frames, with and without write coalescing, and reports the
+// stream writes per frame.
+func BenchmarkGroupWriter_WriteFrame_Coalescing(b *testing.B) {
+	for _, coalesce := range []bool{false, true} {
+		b.Run(fmt.Sprintf("coalesce-%t", coalesce), func(b *testing.B) {
+			writes := 0
+			stream := &FakeQUICSendStream{WriteFunc: func(p []byte) (int, error) {
+				writes++
+				return len(p), nil
+			}}
+			groupWriter := newGroupWriter(stream, GroupSequence(1), nil)
+			if coalesce {
+				groupWriter.coalescer = newCoalescer(stream, time.Hour, defaultCoalesceSize, groupWriter.flushLater)
+			}
+
+			frame := NewFrame(160)
+			_, _ = frame.Write(make([]byte, 160))
+
+			b.SetBytes(160)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for b.Loop() {
+				if err := groupWriter.WriteFrame(frame); err != nil {
+					b.Fatalf("WriteFrame failed: %v", err)
+				}
+			}
+			_ = groupWriter.Flush()
+			b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+		})
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"errors"
 	"io"
 	"iter"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/qumo-dev/gomoqt/transport"
@@ -25,29 +29,185 @@ func newGroupReader(sequence GroupSequence, stream transport.ReceiveStream, grou
 
 // GroupReader receives group data for a subscribed track.
 // Each GroupReader corresponds to a GroupSequence and provides methods to read frames.
+//
+// All methods are safe for concurrent use. Concurrent ReadFrame calls are
+// serialized, each receiving a distinct frame in stream order.
 type GroupReader struct {
 	sequence GroupSequence
+	subgroup SubgroupID
 
-	stream     transport.ReceiveStream
-	frameCount int64
+	// timestamp is the time declared by the publisher, if any.
+	timestamp time.Time
+
+	stream transport.ReceiveStream
+
+	// in counts the bytes of the frame being read, so that a read that
+	// timed out before the frame began can be retried.
+	in countingReader
+
+	mu         sync.Mutex
+	frameCount atomic.Int64
+
+	// end is set once the group ended, with a nil error if it ended
+	// normally.
+	end atomic.Pointer[groupEnd]
+
+	// checksum, when set, verifies the checksum header of every frame.
+	checksum *groupChecksum
+
+	// schemaLookup, when set, strips and checks the schema ID of every frame.
+	schemaLookup SchemaLookupFunc
+
+	// extensionHeaders, when set, strips the extension headers of every
+	// frame into the frame.
+	extensionHeaders bool
+
+	// headerAnnounced is set if the group header announced whether frames
+	// carry extension headers. Otherwise, as for fetched groups, they do if
+	// they carry checksums.
+	headerAnnounced bool
+
+	// interceptor, when set, runs on every frame read.
+	interceptor FrameInterceptor
+
+	// errorCodes maps application error codes onto wire codes.
+	errorCodes *ErrorCodeMap
+
+	// onResetFunc, if set, is called when the publisher resets the group.
+	onResetFunc func()
+
+	// onFrameFunc, if set, is called with the payload size of every frame read.
+	onFrameFunc func(size int)
+
+	// drops, when set, records frames and groups that were not delivered.
+	drops   *dropRecorder
+	dropped bool
+
+	// qlog, when set, records the frames and the end of the group.
+	qlog        *qlogWriter
+	subscribeID SubscribeID
+
+	// endSpanFunc, if set, ends the tracing span of the group.
+	endSpanFunc func(err error)
 
 	groupManager *groupReaderManager
 }
 
+// groupEnd is how a group ended.
+type groupEnd struct {
+	err error
+}
+
+// finish records how the group ended, unless it already ended. err is nil
+// if the group ended normally.
+func (s *GroupReader) finish(err error) {
+	end := &groupEnd{}
+	if err != nil {
+		end.err = &GroupTruncatedError{Frames: s.frameCount.Load(), Err: err}
+	}
+	s.end.CompareAndSwap(nil, end)
+}
+
+// Complete reports whether the group ended normally: the publisher closed
+// its stream and every frame of it was read.
+func (s *GroupReader) Complete() bool {
+	end := s.end.Load()
+	return end != nil && end.err == nil
+}
+
+// Err returns a *GroupTruncatedError, which matches ErrGroupTruncated, if
+// the group ended before the publisher closed its stream: the publisher
+// reset it, such as when moving on to a newer group, the stream failed, or
+// the group was canceled by CancelRead. It returns nil while the group is
+// being read and once it ended normally. Decoders may use it to decide
+// whether to render or skip a partial group.
+func (s *GroupReader) Err() error {
+	if end := s.end.Load(); end != nil {
+		return end.err
+	}
+	return nil
+}
+
+// endSpan ends the tracing span of the group, if any.
+func (s *GroupReader) endSpan(err error) {
+	if s.endSpanFunc != nil {
+		s.endSpanFunc(err)
+	}
+}
+
 // GroupSequence returns the GroupSequence this reader belongs to.
 func (s *GroupReader) GroupSequence() GroupSequence {
 	return s.sequence
 }
 
 // ReadFrame decodes the next Frame from the group stream into the provided frame buffer.
-// If io.EOF is returned, the group stream has been closed.
+// If io.EOF is returned, the group stream has been closed. Once the group
+// has been truncated, ReadFrame returns Err.
+// If the frames of the group carry extension headers, a frame whose headers
+// cannot be decoded returns ErrInvalidExtensionHeaders. If the track reader
+// has a checksum mode, a frame failing verification, or without a checksum,
+// is handled according to its ChecksumPolicy. If it has a SchemaLookupFunc, a frame of an
+// unknown or invalid schema returns a SchemaError. Frames then
+// pass the FrameInterceptor of the group, and those it drops are skipped.
 func (s *GroupReader) ReadFrame(frame *Frame) error {
 	if frame == nil {
 		panic("nil frame")
 	}
-	err := frame.decode(s.stream)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The stream of a truncated group is not read again, as it may have
+	// stopped within a frame.
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	for {
+		err := s.readFrame(frame)
+		if err == nil {
+			dropped, err := interceptRead(s.interceptor, frame)
+			if dropped {
+				s.drops.recordFrame(DropReasonPolicy, s.sequence)
+				continue
+			}
+			return err
+		}
+		if !errors.Is(err, ErrChecksumMismatch) {
+			return err
+		}
+
+		switch s.checksum.policy {
+		case ChecksumPolicySkip:
+			s.drops.recordFrame(DropReasonPolicy, s.sequence)
+			continue
+		case ChecksumPolicyCancel:
+			s.stream.CancelRead(transport.StreamErrorCode(InternalGroupErrorCode))
+			s.finish(localGroupError(InternalGroupErrorCode))
+			s.endSpan(localGroupError(InternalGroupErrorCode))
+			if s.groupManager != nil {
+				s.groupManager.removeGroup(s)
+			}
+			s.recordGroupDrop(DropReasonPolicy)
+		}
+		return err
+	}
+}
+
+// readFrame decodes and verifies one frame while s.mu is held.
+func (s *GroupReader) readFrame(frame *Frame) error {
+	s.in = countingReader{r: s.stream}
+	err := frame.decode(&s.in)
 	if err != nil {
+		if s.in.n == 0 && isTimeout(err) {
+			// The read deadline expired between frames, so the group may
+			// still be read once the deadline is extended.
+			return err
+		}
 		if errors.Is(err, io.EOF) {
+			s.finish(nil)
+			s.qlog.groupClosed(false, s.subscribeID, s.sequence, nil)
+			s.endSpan(nil)
 			return err
 		}
 
@@ -55,28 +215,163 @@ func (s *GroupReader) ReadFrame(frame *Frame) error {
 			grpErr := &GroupError{
 				StreamError: strErr,
 			}
+			s.finish(grpErr)
+
+			if strErr.Remote {
+				if s.qlog != nil {
+					errorCode := uint64(strErr.ErrorCode)
+					s.qlog.groupClosed(false, s.subscribeID, s.sequence, &errorCode)
+				}
+				s.recordGroupDrop(groupDropReason(GroupErrorCode(strErr.ErrorCode)))
+				s.endSpan(grpErr)
+				if s.onResetFunc != nil {
+					s.onResetFunc()
+				}
+			}
 
 			return grpErr
 		}
 
+		// The rest of the frame is lost, so the stream cannot be read
+		// further.
+		s.stream.CancelRead(transport.StreamErrorCode(InternalGroupErrorCode))
+		s.finish(err)
+		s.endSpan(err)
+		if s.groupManager != nil {
+			s.groupManager.removeGroup(s)
+		}
 		return err
 	}
 
-	s.frameCount++
+	if s.hasExtensionHeaders() {
+		if err := readExtensionHeaders(frame); err != nil {
+			return err
+		}
+	}
+	if s.checksum != nil && !s.checksum.verify(frame) {
+		return ErrChecksumMismatch
+	}
+	if s.schemaLookup != nil {
+		if err := checkSchema(frame, s.schemaLookup); err != nil {
+			return err
+		}
+	}
+
+	n := s.frameCount.Add(1)
+	s.qlog.object(false, s.subscribeID, s.sequence, uint64(n-1), len(frame.Body()))
+	if s.onFrameFunc != nil {
+		s.onFrameFunc(len(frame.Body()))
+	}
 
 	return nil
 }
 
+// recordGroupDrop records the group as dropped once while s.mu is held.
+func (s *GroupReader) recordGroupDrop(reason DropReason) {
+	if s.dropped {
+		return
+	}
+	s.dropped = true
+	s.drops.recordGroup(reason, s.sequence)
+}
+
 // CancelRead cancels the group using the provided GroupErrorCode.
 func (s *GroupReader) CancelRead(code GroupErrorCode) {
 	s.stream.CancelRead(transport.StreamErrorCode(code))
+	s.finish(localGroupError(code))
+	s.endSpan(localGroupError(code))
 
 	if s.groupManager != nil {
 		s.groupManager.removeGroup(s)
 	}
 }
 
+// CancelReadWithApplicationError cancels the group with an
+// application-defined error code, mapped onto a wire code by
+// Config.ErrorCodes. It fails only if the code cannot be mapped.
+func (s *GroupReader) CancelReadWithApplicationError(code uint32) error {
+	wire, err := s.errorCodes.WireCode(GroupErrorSpace, code)
+	if err != nil {
+		return err
+	}
+	s.CancelRead(GroupErrorCode(wire))
+	return nil
+}
+
+// SetChecksum makes frames read after the call verify a checksum of the given
+// mode, handling mismatches according to policy. Groups accepted from a
+// TrackReader take the mode of the track, so this is mainly for groups
+// returned by Session.Fetch, whose frames then carry an extension header
+// block holding the checksum; call it before reading the first frame.
+func (s *GroupReader) SetChecksum(mode ChecksumMode, policy ChecksumPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mode == ChecksumNone {
+		s.checksum = nil
+		return
+	}
+	s.checksum = &groupChecksum{mode: mode, policy: policy}
+}
+
+// SetSchemaLookup makes frames read after the call carry a schema ID, which
+// is checked with lookup; see SchemaID. A nil lookup leaves schema IDs
+// unchecked. Groups accepted from a TrackReader take the lookup of the
+// track, so this is mainly for groups returned by Session.Fetch, whose
+// frames then carry an extension header block holding the schema ID; call
+// it before reading the first frame.
+func (s *GroupReader) SetSchemaLookup(lookup SchemaLookupFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schemaLookup = lookup
+}
+
+// SetExtensionHeaders sets whether frames read after the call carry
+// extension headers, which are stripped from the payload into the frame;
+// see ExtensionHeaders. It is for groups returned by Session.Fetch, which
+// have no header to announce the setting; call it before reading the first
+// frame. Groups accepted from a TrackReader take the setting announced in
+// their header.
+func (s *GroupReader) SetExtensionHeaders(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.extensionHeaders = enabled
+}
+
+// HasExtensionHeaders reports whether the frames of the group carry
+// extension headers, as announced in its header or set with
+// SetExtensionHeaders.
+func (s *GroupReader) HasExtensionHeaders() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.hasExtensionHeaders()
+}
+
+// hasExtensionHeaders reports whether frames carry an extension header
+// block while s.mu is held.
+func (s *GroupReader) hasExtensionHeaders() bool {
+	return s.extensionHeaders || (!s.headerAnnounced && (s.checksum != nil || s.schemaLookup != nil))
+}
+
+// SetFrameInterceptor makes frames read after the call pass intercept; see
+// FrameInterceptor. Nil removes it. Groups accepted from a TrackReader take
+// the interceptors of the session and the track, so this is mainly for
+// groups returned by Session.Fetch.
+func (s *GroupReader) SetFrameInterceptor(intercept FrameInterceptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.interceptor = intercept
+}
+
 // SetReadDeadline sets the read deadline for read operations.
+// A ReadFrame that times out before the next frame began returns the
+// timeout and may be retried once the deadline is extended. One that times
+// out within a frame cancels the group, as the frame is lost, and later
+// calls return Err.
 func (s *GroupReader) SetReadDeadline(t time.Time) error {
 	return s.stream.SetReadDeadline(t)
 }
@@ -100,3 +395,24 @@ func (s *GroupReader) Frames(buf *Frame) iter.Seq[*Frame] {
 		}
 	}
 }
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// isTimeout reports whether err is an expired deadline.
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	netErr, ok := errors.AsType[net.Error](err)
+	return ok && netErr.Timeout()
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"os"
 	"testing"
 	"time"
 
@@ -45,7 +46,7 @@ func TestNewReceiveGroupStream(t *testing.T) {
 			assert.NotNil(t, rgs)
 			assert.Equal(t, tt.sequence, rgs.sequence)
 			assert.Equal(t, mockStream, rgs.stream)
-			assert.Equal(t, int64(0), rgs.frameCount)
+			assert.Equal(t, int64(0), rgs.frameCount.Load())
 		})
 	}
 }
@@ -363,3 +364,148 @@ func TestGroupReader_Frames(t *testing.T) {
 		assert.Equal(t, 0, frameCount)
 	})
 }
+
+func TestGroupReader_Err(t *testing.T) {
+	var group bytes.Buffer
+	gw := newGroupWriter(&FakeQUICSendStream{WriteFunc: group.Write}, 1, nil)
+	for range 2 {
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte("frame"))
+		require.NoError(t, gw.WriteFrame(frame))
+	}
+	data := group.Bytes()
+	reset := &transport.StreamError{ErrorCode: transport.StreamErrorCode(ExpiredGroupErrorCode), Remote: true}
+
+	tests := map[string]struct {
+		// err is returned by the stream once its data is read.
+		err        error
+		cancel     bool
+		wantFrames int64
+		wantCode   GroupErrorCode
+		complete   bool
+	}{
+		"complete": {
+			err:      io.EOF,
+			complete: true,
+		},
+		"reset by publisher": {
+			err:        reset,
+			wantFrames: 2,
+			wantCode:   ExpiredGroupErrorCode,
+		},
+		"canceled": {
+			err:        io.EOF,
+			cancel:     true,
+			wantFrames: 2,
+			wantCode:   SubscribeCanceledErrorCode,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := bytes.NewReader(data)
+			gr := newGroupReader(1, &FakeQUICReceiveStream{ReadFunc: func(p []byte) (int, error) {
+				if r.Len() == 0 {
+					return 0, tt.err
+				}
+				return r.Read(p)
+			}}, nil)
+
+			frame := NewFrame(0)
+			for range 2 {
+				require.NoError(t, gr.ReadFrame(frame))
+			}
+			assert.NoError(t, gr.Err(), "the group is still being read")
+			assert.False(t, gr.Complete())
+
+			if tt.cancel {
+				gr.CancelRead(SubscribeCanceledErrorCode)
+			} else {
+				assert.Error(t, gr.ReadFrame(frame))
+			}
+			// Canceling an ended group does not change how it ended.
+			gr.CancelRead(InternalGroupErrorCode)
+
+			assert.Equal(t, tt.complete, gr.Complete())
+			if tt.complete {
+				assert.NoError(t, gr.Err())
+				return
+			}
+			err := gr.Err()
+			require.ErrorIs(t, err, ErrGroupTruncated)
+			truncated, ok := errors.AsType[*GroupTruncatedError](err)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantFrames, truncated.Frames)
+			grpErr, ok := errors.AsType[*GroupError](err)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantCode, grpErr.GroupErrorCode())
+		})
+	}
+}
+
+func TestGroupReader_ReadFrame_Timeout(t *testing.T) {
+	var group bytes.Buffer
+	gw := newGroupWriter(&FakeQUICSendStream{WriteFunc: group.Write}, 1, nil)
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("frame"))
+	require.NoError(t, gw.WriteFrame(frame))
+	data := group.Bytes()
+
+	tests := map[string]struct {
+		// before is the number of bytes read before the deadline expires.
+		before    int
+		truncated bool
+	}{
+		"between frames": {before: 0},
+		"within a frame": {before: 2, truncated: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := bytes.NewReader(data)
+			timedOut := false
+			var canceled *GroupErrorCode
+			groupManager := newGroupReaderManager()
+			gr := newGroupReader(1, &FakeQUICReceiveStream{
+				ReadFunc: func(p []byte) (int, error) {
+					if !timedOut && int(r.Size())-r.Len() == tt.before {
+						timedOut = true
+						return 0, os.ErrDeadlineExceeded
+					}
+					if r.Len() == 0 {
+						return 0, io.EOF
+					}
+					return r.Read(p[:1])
+				},
+				CancelReadFunc: func(code transport.StreamErrorCode) {
+					canceled = new(GroupErrorCode(code))
+				},
+			}, groupManager)
+
+			frame := NewFrame(0)
+			require.ErrorIs(t, gr.ReadFrame(frame), os.ErrDeadlineExceeded)
+			if tt.truncated {
+				assert.ErrorIs(t, gr.Err(), ErrGroupTruncated)
+				require.NotNil(t, canceled, "the stream must be canceled")
+				assert.Equal(t, InternalGroupErrorCode, *canceled)
+				assert.Empty(t, groupManager.close())
+
+				// A retry returns the truncation without decoding the
+				// rest of the lost frame as a new one.
+				unread := r.Len()
+				err := gr.ReadFrame(frame)
+				assert.ErrorIs(t, err, ErrGroupTruncated)
+				assert.Equal(t, gr.Err(), err)
+				assert.Equal(t, unread, r.Len(), "the stream must not be read again")
+				return
+			}
+			assert.Nil(t, canceled)
+			assert.NoError(t, gr.Err(), "a timeout between frames does not end the group")
+
+			require.NoError(t, gr.ReadFrame(frame))
+			assert.Equal(t, "frame", string(frame.Body()))
+			assert.ErrorIs(t, gr.ReadFrame(frame), io.EOF)
+			assert.True(t, gr.Complete())
+		})
+	}
+}
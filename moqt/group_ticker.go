@@ -0,0 +1,130 @@
+package moqt
+
+import (
+	"sync"
+	"time"
+)
+
+// GroupTicker rotates the groups of a constant-rate track, such as video with
+// a fixed GOP length or audio packed into fixed-length groups, so that the
+// publisher only writes frames.
+//
+// Groups are rotated by the media timestamps of the frames rather than by a
+// wall-clock timer. Group boundaries are anchored to the timestamp of the
+// first frame of a run and advance by exactly the group duration, so jitter
+// in individual timestamps does not accumulate into drift. A frame up to half
+// a frame interval early is treated as due, which absorbs rounding in
+// encoder timestamps.
+//
+// A timestamp that goes backwards, or that jumps ahead by more than the group
+// duration, for example after an encoder hiccup or restart, starts a new run
+// with a new group at that frame.
+//
+// All methods are safe for concurrent use.
+type GroupTicker struct {
+	tw            *TrackWriter
+	frameInterval time.Duration
+	groupDuration time.Duration
+
+	mu sync.Mutex
+	// started reports whether a run of frames is in progress.
+	started bool
+	// group is the current group, or nil if it failed and the rest of its
+	// frames are discarded.
+	group *GroupWriter
+	// start is the media time at which the current group slot starts.
+	start time.Duration
+	// last is the timestamp of the last frame.
+	last time.Duration
+}
+
+// NewGroupTicker returns a GroupTicker that writes frames produced at
+// frameRate frames per second to tw and starts a new group every
+// groupDuration of media time.
+// It panics if frameRate or groupDuration is not positive.
+func NewGroupTicker(tw *TrackWriter, frameRate float64, groupDuration time.Duration) *GroupTicker {
+	if frameRate <= 0 {
+		panic("[GroupTicker] non-positive frame rate")
+	}
+	if groupDuration <= 0 {
+		panic("[GroupTicker] non-positive group duration")
+	}
+	return &GroupTicker{
+		tw:            tw,
+		frameInterval: time.Duration(float64(time.Second) / frameRate),
+		groupDuration: groupDuration,
+	}
+}
+
+// WriteFrame writes frame with the given media timestamp, closing the current
+// group and opening a new one when a group boundary is reached.
+// Timestamps are relative to any fixed origin; publishers without media
+// timestamps may pass the elapsed wall-clock time.
+//
+// If writing to the current group fails, WriteFrame returns the error and
+// the remaining frames of that group are discarded, since they cannot be
+// decoded without its beginning. An error opening a new group, such as when
+// the track is closed, is returned as is.
+func (t *GroupTicker) WriteFrame(frame *Frame, timestamp time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if start, rotate := t.slot(timestamp); rotate {
+		if t.group != nil {
+			_ = t.group.Close()
+			t.group = nil
+		}
+
+		gw, err := t.tw.OpenGroup()
+		if err != nil {
+			t.started = false
+			return err
+		}
+		t.group, t.start, t.started = gw, start, true
+	}
+	t.last = timestamp
+
+	if t.group == nil {
+		return nil
+	}
+	if err := t.group.WriteFrame(frame); err != nil {
+		t.group.CancelWrite(InternalGroupErrorCode)
+		t.group = nil
+		return err
+	}
+	return nil
+}
+
+// slot reports whether a frame at timestamp starts a new group, and the
+// start of the frame's group slot.
+func (t *GroupTicker) slot(timestamp time.Duration) (time.Duration, bool) {
+	if !t.started {
+		return timestamp, true
+	}
+
+	delta := timestamp - t.last
+	if delta < 0 || delta > t.groupDuration {
+		// Discontinuity: start a new run at this frame.
+		return timestamp, true
+	}
+
+	due := timestamp + t.frameInterval/2 - t.start
+	if due < t.groupDuration {
+		return t.start, false
+	}
+	return t.start + due/t.groupDuration*t.groupDuration, true
+}
+
+// Close closes the current group. A later frame starts a new run.
+func (t *GroupTicker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.started = false
+	if t.group == nil {
+		return nil
+	}
+	err := t.group.Close()
+	t.group = nil
+	return err
+}
@@ -0,0 +1,159 @@
+package moqt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGroupTickerTestWriter(t *testing.T, openUniStreamFunc func() (transport.SendStream, error)) *TrackWriter {
+	t.Helper()
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, func() {})
+	t.Cleanup(func() { _ = writer.Close() })
+	return writer
+}
+
+func TestNewGroupTicker_Panics(t *testing.T) {
+	writer := newGroupTickerTestWriter(t, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	})
+
+	assert.Panics(t, func() { NewGroupTicker(writer, 0, time.Second) })
+	assert.Panics(t, func() { NewGroupTicker(writer, 30, 0) })
+}
+
+func TestGroupTicker_WriteFrame(t *testing.T) {
+	const ms = time.Millisecond
+
+	tests := map[string]struct {
+		timestamps []time.Duration
+		wantGroups []GroupSequence
+	}{
+		"one group per duration": {
+			timestamps: []time.Duration{0, 500 * ms, 999 * ms, 1000 * ms, 1999 * ms, 2000 * ms},
+			wantGroups: []GroupSequence{1, 1, 2, 2, 3, 3},
+		},
+		"early frame within half an interval": {
+			timestamps: []time.Duration{0, 500 * ms, 990 * ms, 1500 * ms},
+			wantGroups: []GroupSequence{1, 1, 2, 2},
+		},
+		"boundaries do not drift with early frames": {
+			// The second group starts at 1000ms even though its first frame
+			// was at 990ms, so 1980ms is still too early for the third group.
+			timestamps: []time.Duration{0, 990 * ms, 1500 * ms, 1980 * ms, 1990 * ms},
+			wantGroups: []GroupSequence{1, 2, 2, 2, 3},
+		},
+		"short hiccup keeps boundaries": {
+			timestamps: []time.Duration{0, 400 * ms, 1300 * ms, 1900 * ms, 1990 * ms},
+			wantGroups: []GroupSequence{1, 1, 2, 2, 3},
+		},
+		"long gap starts a new run": {
+			timestamps: []time.Duration{0, 500 * ms, 3000 * ms, 3500 * ms, 3990 * ms},
+			wantGroups: []GroupSequence{1, 1, 2, 2, 3},
+		},
+		"timestamp reset starts a new run": {
+			timestamps: []time.Duration{0, 500 * ms, 100 * ms, 600 * ms, 1090 * ms},
+			wantGroups: []GroupSequence{1, 1, 2, 2, 3},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			writer := newGroupTickerTestWriter(t, func() (transport.SendStream, error) {
+				return &FakeQUICSendStream{}, nil
+			})
+			ticker := NewGroupTicker(writer, 50, time.Second)
+
+			groups := make([]GroupSequence, 0, len(tt.timestamps))
+			for _, ts := range tt.timestamps {
+				require.NoError(t, ticker.WriteFrame(NewFrame(0), ts))
+				groups = append(groups, ticker.group.GroupSequence())
+			}
+			assert.Equal(t, tt.wantGroups, groups)
+		})
+	}
+}
+
+func TestGroupTicker_WriteFrame_ClosesPreviousGroup(t *testing.T) {
+	var streams []*FakeQUICSendStream
+	writer := newGroupTickerTestWriter(t, func() (transport.SendStream, error) {
+		stream := &FakeQUICSendStream{}
+		streams = append(streams, stream)
+		return stream, nil
+	})
+	ticker := NewGroupTicker(writer, 30, time.Second)
+
+	require.NoError(t, ticker.WriteFrame(NewFrame(0), 0))
+	require.NoError(t, ticker.WriteFrame(NewFrame(0), time.Second))
+	require.Len(t, streams, 2)
+	assert.True(t, streams[0].closed)
+	assert.False(t, streams[1].closed)
+
+	require.NoError(t, ticker.Close())
+	assert.True(t, streams[1].closed)
+}
+
+func TestGroupTicker_WriteFrame_FailedGroup(t *testing.T) {
+	writeErr := errors.New("write failed")
+	opened := 0
+	writer := newGroupTickerTestWriter(t, func() (transport.SendStream, error) {
+		opened++
+		if opened == 1 {
+			// Accept the group header, then fail the frame.
+			return &FakeQUICSendStream{WriteFunc: func(p []byte) (int, error) {
+				if bytes.Contains(p, []byte("frame")) {
+					return 0, writeErr
+				}
+				return len(p), nil
+			}}, nil
+		}
+		return &FakeQUICSendStream{}, nil
+	})
+	ticker := NewGroupTicker(writer, 30, time.Second)
+
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("frame"))
+
+	err := ticker.WriteFrame(frame, 0)
+	assert.ErrorIs(t, err, writeErr)
+
+	// The rest of the failed group is discarded.
+	require.NoError(t, ticker.WriteFrame(frame, 500*time.Millisecond))
+	assert.Equal(t, 1, opened)
+
+	// The next group is written normally.
+	require.NoError(t, ticker.WriteFrame(frame, time.Second))
+	assert.Equal(t, 2, opened)
+	assert.Equal(t, GroupSequence(2), ticker.group.GroupSequence())
+}
+
+func TestGroupTicker_WriteFrame_TrackClosed(t *testing.T) {
+	writer := newGroupTickerTestWriter(t, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	})
+	ticker := NewGroupTicker(writer, 30, time.Second)
+	require.NoError(t, writer.Close())
+
+	assert.Error(t, ticker.WriteFrame(NewFrame(0), 0))
+}
+
+func TestGroupTicker_Close_StartsNewRun(t *testing.T) {
+	writer := newGroupTickerTestWriter(t, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	})
+	ticker := NewGroupTicker(writer, 30, time.Second)
+
+	require.NoError(t, ticker.WriteFrame(NewFrame(0), 0))
+	require.NoError(t, ticker.Close())
+	require.NoError(t, ticker.Close())
+
+	require.NoError(t, ticker.WriteFrame(NewFrame(0), 100*time.Millisecond))
+	assert.Equal(t, GroupSequence(2), ticker.group.GroupSequence())
+}
@@ -0,0 +1,61 @@
+package moqt
+
+import "time"
+
+// OpenGroupWithTime opens a new group as OpenGroup does, declaring t as the
+// time of the group, such as the capture time of its first frame. The time
+// is sent in the header of the group, and of its subgroups, so that
+// subscribers and relays can map times to groups; see
+// SubscribeConfig.StartTime. A zero t declares no time. The time is an
+// extension of the group header, dropped on sessions that did not negotiate
+// it; see Session.Extensions.
+func (w *TrackWriter) OpenGroupWithTime(t time.Time) (*GroupWriter, error) {
+	return w.openGroup(w.nextSequence(), 0, t)
+}
+
+// OpenGroupAtTime opens a new group with sequence seq as OpenGroupAt does,
+// declaring t as the time of the group as OpenGroupWithTime does.
+func (w *TrackWriter) OpenGroupAtTime(seq GroupSequence, t time.Time) (*GroupWriter, error) {
+	w.advanceSequence(seq)
+	return w.openGroup(seq, 0, t)
+}
+
+// Timestamp returns the time declared for the group, or the zero time if
+// it was opened without one.
+func (sgs *GroupWriter) Timestamp() time.Time {
+	return sgs.timestamp
+}
+
+// Timestamp returns the time the publisher declared for the group, or the
+// zero time if it declared none.
+func (s *GroupReader) Timestamp() time.Time {
+	return s.timestamp
+}
+
+// timeToWire converts t into microseconds since the Unix epoch, with the
+// zero time, and times before the epoch, as 0 to omit the field.
+func timeToWire(t time.Time) uint64 {
+	if t.IsZero() || t.UnixMicro() <= 0 {
+		return 0
+	}
+	return uint64(t.UnixMicro())
+}
+
+// timeFromWire converts microseconds since the Unix epoch into a time, with
+// 0 as the zero time.
+func timeFromWire(v uint64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(int64(v))
+}
+
+// durationToWire converts d into microseconds, with negative durations as 0.
+func durationToWire(d time.Duration) uint64 {
+	return uint64(max(d.Microseconds(), 0))
+}
+
+// durationFromWire converts microseconds into a duration.
+func durationFromWire(v uint64) time.Duration {
+	return time.Duration(min(v, uint64(1<<63-1)/uint64(time.Microsecond))) * time.Microsecond
+}
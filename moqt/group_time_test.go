@@ -0,0 +1,100 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackWriter_OpenGroupWithTime(t *testing.T) {
+	tw, streams := newDatagramTrackWriter(nil)
+	ts := time.UnixMicro(1_700_000_000_000_000)
+
+	gw, err := tw.OpenGroupWithTime(ts)
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(1), gw.GroupSequence())
+	assert.True(t, ts.Equal(gw.Timestamp()))
+
+	// Subgroups carry the time of their group.
+	sub, err := gw.Subgroup(1)
+	require.NoError(t, err)
+	assert.True(t, ts.Equal(sub.Timestamp()))
+
+	gw2, err := tw.OpenGroupAtTime(5, ts.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(5), gw2.GroupSequence())
+
+	gw3, err := tw.OpenGroup()
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(7), gw3.GroupSequence())
+	assert.True(t, gw3.Timestamp().IsZero())
+
+	ext := message.VersionLite04Ext
+	want := []message.GroupMessage{
+		{SubscribeID: 1, GroupSequence: 1, Timestamp: 1_700_000_000_000_000, Version: ext},
+		{SubscribeID: 1, GroupSequence: 1, SubgroupID: 1, Timestamp: 1_700_000_000_000_000, Version: ext},
+		{SubscribeID: 1, GroupSequence: 5, Timestamp: 1_700_000_001_000_000, Version: ext},
+		{SubscribeID: 1, GroupSequence: 7, Version: ext},
+	}
+	require.Len(t, *streams, len(want))
+	for i, w := range want {
+		r := bytes.NewReader((*streams)[i].Bytes())
+		var st message.StreamType
+		require.NoError(t, st.Decode(r))
+		gm := message.GroupMessage{Version: ext}
+		require.NoError(t, gm.Decode(r))
+		assert.Equal(t, w, gm)
+	}
+}
+
+func TestSession_ProcessUniStream_GroupTimestamp(t *testing.T) {
+	sess, _ := newTestSessionWithConn(t, extendedConn)
+	substr := newSendSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{})
+	tr := newTrackReader("/broadcastpath", "trackname", substr, func() {})
+	sess.addTrackReader(SubscribeID(1), tr)
+
+	for _, ts := range []uint64{1_700_000_000_000_000, 0} {
+		var buf bytes.Buffer
+		require.NoError(t, message.StreamTypeGroup.Encode(&buf))
+		require.NoError(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 7, Timestamp: ts, Version: message.VersionLite04Ext}.Encode(&buf))
+		sess.processUniStream(&FakeQUICReceiveStream{ReadFunc: buf.Read})
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	gr, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	assert.True(t, time.UnixMicro(1_700_000_000_000_000).Equal(gr.Timestamp()))
+
+	gr, err = tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	assert.True(t, gr.Timestamp().IsZero())
+}
+
+func TestTimeWire(t *testing.T) {
+	tests := map[string]struct {
+		time time.Time
+		want uint64
+	}{
+		"zero":         {time: time.Time{}, want: 0},
+		"before epoch": {time: time.Unix(-1, 0), want: 0},
+		"after epoch":  {time: time.UnixMicro(42), want: 42},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, timeToWire(tt.time))
+		})
+	}
+
+	assert.True(t, timeFromWire(0).IsZero())
+	assert.Equal(t, uint64(1500), durationToWire(1500*time.Microsecond))
+	assert.Equal(t, uint64(0), durationToWire(-time.Second))
+	assert.Equal(t, 10*time.Second, durationFromWire(10_000_000))
+}
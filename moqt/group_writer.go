@@ -2,6 +2,8 @@ package moqt
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
@@ -9,11 +11,13 @@ import (
 )
 
 func newGroupWriter(stream transport.SendStream, sequence GroupSequence, groupManager *groupWriterManager) *GroupWriter {
+	ctx, cancel := context.WithCancelCause(context.WithValue(stream.Context(), uniStreamTypeCtxKey, message.StreamTypeGroup))
 	w := &GroupWriter{
 		sequence:     sequence,
 		groupManager: groupManager,
 		stream:       stream,
-		ctx:          context.WithValue(stream.Context(), uniStreamTypeCtxKey, message.StreamTypeGroup),
+		ctx:          ctx,
+		cancelCtx:    cancel,
 	}
 
 	if w.groupManager != nil {
@@ -25,14 +29,99 @@ func newGroupWriter(stream transport.SendStream, sequence GroupSequence, groupMa
 
 // GroupWriter writes frames for a single group.
 // It manages the lifecycle of the group.
+//
+// All methods are safe for concurrent use. Concurrent WriteFrame calls are
+// serialized so that frames are never interleaved on the wire; their relative
+// order is the order in which the calls acquire the writer.
 type GroupWriter struct {
 	sequence GroupSequence
+	subgroup SubgroupID
 
-	ctx    context.Context
-	stream transport.SendStream
+	// timestamp is the time declared for the group, if any.
+	timestamp time.Time
 
+	// ctx is canceled when the group ends, or by stopWrites.
+	ctx       context.Context
+	cancelCtx context.CancelCauseFunc
+	stream    transport.SendStream
+
+	mu         sync.Mutex
 	frameCount uint64 // Number of frames sent on this stream
 
+	// pacers are shared by all groups of the track, and by the groups of
+	// the session for its bandwidth limit, and delay frames so that neither
+	// exceeds its rate.
+	pacers []*pacer
+
+	// scheduler, when set, schedules frames by the send priority returned
+	// by priorityFunc.
+	scheduler    *sendScheduler
+	priorityFunc func() int64
+	priority     int64
+	prioritySet  bool
+
+	// checksum, when set, adds a checksum header to every frame.
+	checksum *groupChecksum
+
+	// schema, when set, adds a schema header to every frame.
+	schema SchemaID
+
+	// extensionHeaders, when set, writes the extension headers of every
+	// frame. They are encoded into prefix with the checksum and schema
+	// headers.
+	extensionHeaders bool
+	prefix           []byte
+
+	// headerAnnounced is set if the group header announced whether frames
+	// carry an extension header block, which then cannot change.
+	headerAnnounced bool
+
+	// interceptor, when set, runs on every frame before it is written.
+	interceptor FrameInterceptor
+
+	// errorCodes maps application error codes onto wire codes.
+	errorCodes *ErrorCodeMap
+
+	// shadows, when set, copy the frames of the group to the shadow
+	// subscriptions of a TrackMux.Mirror.
+	shadows []*shadowGroup
+
+	// coalescer, when set, buffers small frames into fewer stream writes.
+	coalescer *coalescer
+
+	// retained, when set, records the frames of a group of a reliable track
+	// until it is closed. It is cleared once a frame fails to be written or
+	// the group is canceled, as the group is then incomplete.
+	retained *retainedGroup
+
+	// expiry, when set, cancels the group when its delivery timeout
+	// elapses; see TrackWriter.SetDeliveryTimeout.
+	expiry *time.Timer
+
+	// drops, when set, records the group when it is canceled.
+	drops   *dropRecorder
+	dropped atomic.Bool
+
+	// openSubgroupFunc, if set, opens a subgroup of the group.
+	openSubgroupFunc func(seq GroupSequence, id SubgroupID) (*GroupWriter, error)
+
+	// onFrameFunc, if set, is called with the payload size of every frame written.
+	onFrameFunc func(size int)
+
+	// onCancelFunc, if set, is called when the group is canceled.
+	onCancelFunc func(code GroupErrorCode)
+
+	// endSpanFunc, if set, ends the tracing span of the group.
+	endSpanFunc func(err error)
+
+	// onEndFunc, if set, is called when the group is closed or canceled,
+	// after it is removed from groupManager. It may be called twice.
+	onEndFunc func()
+
+	// qlog, when set, records the frames and the end of the group.
+	qlog        *qlogWriter
+	subscribeID SubscribeID
+
 	groupManager *groupWriterManager
 }
 
@@ -42,19 +131,216 @@ func (sgs *GroupWriter) GroupSequence() GroupSequence {
 }
 
 // WriteFrame writes a Frame to the group stream.
+// If the track has a pacing rate, WriteFrame blocks until the frame may be
+// sent without exceeding it, or until the group is canceled.
+// The frame is only read, so the caller may reuse it once WriteFrame returns
+// and may write the same frame to other groups concurrently.
 func (sgs *GroupWriter) WriteFrame(frame *Frame) error {
 	if frame == nil {
 		return nil
 	}
 
-	err := frame.encode(sgs.stream)
+	sgs.mu.Lock()
+	defer sgs.mu.Unlock()
+
+	_, err := sgs.writeFrame(frame, false)
+	return err
+}
+
+// SendFrame writes a Frame to the group stream like WriteFrame, but takes
+// ownership of the frame: the caller must not use it after the call. The
+// frame is released once written, or kept without a copy by groups that
+// retain their frames for retransmission. Use it with frames from
+// AcquireFrame, encoded with AppendBytes or ReadFrom, to send payloads
+// without copying them.
+func (sgs *GroupWriter) SendFrame(frame *Frame) error {
+	if frame == nil {
+		return nil
+	}
+
+	sgs.mu.Lock()
+	defer sgs.mu.Unlock()
+
+	kept, err := sgs.writeFrame(frame, true)
+	if !kept {
+		frame.Release()
+	}
+	return err
+}
+
+// writeFrame writes frame and reports whether it kept frame, which it may
+// only do if the frame is owned. sgs.mu must be held.
+func (sgs *GroupWriter) writeFrame(frame *Frame, owned bool) (kept bool, err error) {
+	if sgs.interceptor != nil {
+		out, err := sgs.interceptor(frame)
+		if err != nil {
+			return false, err
+		}
+		if out == nil {
+			sgs.drops.recordFrame(DropReasonPolicy, sgs.sequence)
+			return false, nil
+		}
+		if out != frame {
+			owned = false
+		}
+		frame = out
+	}
+
+	var prefix []byte
+	if sgs.extensionHeaders || sgs.checksum != nil || sgs.schema != 0 {
+		sgs.prefix = appendExtensionHeaders(sgs.prefix[:0], frame, sgs.extensionHeaders, sgs.schema, sgs.checksum)
+		prefix = sgs.prefix
+	}
+	size := frame.Len() + len(prefix)
+	if err := pace(sgs.ctx, size, sgs.pacers...); err != nil {
+		sgs.retained = nil
+		return false, err
+	}
+
+	release, err := sgs.schedule()
 	if err != nil {
-		return err
+		sgs.retained = nil
+		return false, err
+	}
+	defer release()
+
+	if sgs.coalescer != nil {
+		err = encodeFrameWith(sgs.coalescer, frame, prefix)
+		if err == nil {
+			err = sgs.coalescer.written()
+		}
+	} else {
+		err = encodeFrameWith(sgs.stream, frame, prefix)
+	}
+	if err != nil {
+		// The group lacks the frame, so it cannot be fetched again whole.
+		sgs.retained = nil
+		return false, err
 	}
 
+	// Shadows receive the frames as sent, after the interceptor, and only
+	// those the primary subscription got.
+	for _, shadow := range sgs.shadows {
+		shadow.write(frame)
+	}
+	if sgs.retained != nil {
+		if owned {
+			sgs.retained.frames = append(sgs.retained.frames, frame)
+			kept = true
+		} else {
+			sgs.retained.frames = append(sgs.retained.frames, frame.Clone())
+		}
+	}
 	sgs.frameCount++
+	if sgs.onFrameFunc != nil {
+		sgs.onFrameFunc(frame.Len())
+	}
+	sgs.qlog.object(true, sgs.subscribeID, sgs.sequence, sgs.frameCount-1, frame.Len())
 
-	return nil
+	return kept, nil
+}
+
+// Flush writes the frames buffered by write coalescing to the stream at
+// once; see TrackWriter.SetWriteCoalescing. It does nothing for a group
+// without coalescing.
+func (sgs *GroupWriter) Flush() error {
+	sgs.mu.Lock()
+	defer sgs.mu.Unlock()
+
+	if sgs.coalescer == nil {
+		return nil
+	}
+	return sgs.coalescer.flush()
+}
+
+// flushLater writes the buffered frames when the coalescing delay elapses.
+// An error is returned by the next write.
+func (sgs *GroupWriter) flushLater() {
+	sgs.mu.Lock()
+	defer sgs.mu.Unlock()
+
+	_ = sgs.coalescer.flush()
+}
+
+// schedule waits until a frame may be written with the send priority of the
+// group and returns the function to call once it is written. sgs.mu must be
+// held.
+func (sgs *GroupWriter) schedule() (func(), error) {
+	if sgs.scheduler == nil {
+		return func() {}, nil
+	}
+
+	priority := sgs.priorityFunc()
+	if ps, ok := sgs.stream.(transport.PrioritizedSendStream); ok {
+		if !sgs.prioritySet || priority != sgs.priority {
+			ps.SetPriority(priority)
+			sgs.priority = priority
+			sgs.prioritySet = true
+		}
+		return func() {}, nil
+	}
+	return sgs.scheduler.acquire(sgs.ctx, priority)
+}
+
+// SetChecksum makes frames written after the call carry a checksum of the
+// given mode. It is for fetch handlers, whose groups have no header; call it
+// before writing the first frame. Groups opened by a TrackWriter take the
+// mode of the track, announced in their header, and the call has no effect
+// on them.
+func (sgs *GroupWriter) SetChecksum(mode ChecksumMode) {
+	sgs.mu.Lock()
+	defer sgs.mu.Unlock()
+
+	if sgs.headerAnnounced {
+		return
+	}
+	if mode == ChecksumNone {
+		sgs.checksum = nil
+		return
+	}
+	sgs.checksum = &groupChecksum{mode: mode}
+}
+
+// SetSchema makes frames written after the call carry the schema ID id.
+// Zero removes the schema ID. It is for fetch handlers, whose groups have no
+// header; call it before writing the first frame. Groups opened by a
+// TrackWriter take the schema ID of the track, announced in their header,
+// and the call has no effect on them.
+func (sgs *GroupWriter) SetSchema(id SchemaID) {
+	sgs.mu.Lock()
+	defer sgs.mu.Unlock()
+
+	if sgs.headerAnnounced {
+		return
+	}
+	sgs.schema = id
+}
+
+// SetExtensionHeaders sets whether frames written after the call carry their
+// extension headers; see ExtensionHeaders. It is for fetch handlers, whose
+// groups have no header, and the fetching subscriber must expect the same
+// setting; call it before writing the first frame. Groups opened by a
+// TrackWriter announce the setting of the track in their header, and the
+// call has no effect on them.
+func (sgs *GroupWriter) SetExtensionHeaders(enabled bool) {
+	sgs.mu.Lock()
+	defer sgs.mu.Unlock()
+
+	if sgs.headerAnnounced {
+		return
+	}
+	sgs.extensionHeaders = enabled
+}
+
+// SetFrameInterceptor makes frames written after the call pass intercept;
+// see FrameInterceptor. Nil removes it. Groups opened by a TrackWriter take
+// the interceptors of the track and the session, so this is mainly for
+// fetch handlers.
+func (sgs *GroupWriter) SetFrameInterceptor(intercept FrameInterceptor) {
+	sgs.mu.Lock()
+	defer sgs.mu.Unlock()
+
+	sgs.interceptor = intercept
 }
 
 // SetWriteDeadline sets the write deadline for write operations.
@@ -62,30 +348,145 @@ func (sgs *GroupWriter) SetWriteDeadline(t time.Time) error {
 	return sgs.stream.SetWriteDeadline(t)
 }
 
+// expireAfter cancels the group with ExpiredGroupErrorCode unless it is
+// closed or canceled within d.
+func (sgs *GroupWriter) expireAfter(d time.Duration) {
+	sgs.expiry = time.AfterFunc(d, func() {
+		sgs.CancelWrite(ExpiredGroupErrorCode)
+	})
+}
+
+// stopExpiry stops the delivery timeout of the group. It reports false if
+// the timeout has already elapsed.
+func (sgs *GroupWriter) stopExpiry() bool {
+	if sgs.expiry == nil {
+		return true
+	}
+	return sgs.expiry.Stop()
+}
+
+// CancelWriteWithApplicationError cancels the group with an
+// application-defined error code, mapped onto a wire code by
+// Config.ErrorCodes. It fails only if the code cannot be mapped.
+func (sgs *GroupWriter) CancelWriteWithApplicationError(code uint32) error {
+	wire, err := sgs.errorCodes.WireCode(GroupErrorSpace, code)
+	if err != nil {
+		return err
+	}
+	sgs.CancelWrite(GroupErrorCode(wire))
+	return nil
+}
+
 // CancelWrite cancels the group with the specified GroupErrorCode and triggers callbacks.
 func (sgs *GroupWriter) CancelWrite(code GroupErrorCode) {
+	if code != ExpiredGroupErrorCode {
+		sgs.stopExpiry()
+	}
 	sgs.stream.CancelWrite(transport.StreamErrorCode(code))
+	sgs.cancelCtx(localGroupError(code))
+	sgs.mu.Lock()
+	// A canceled group may lack frames, so it is not kept for
+	// retransmission.
+	sgs.retained = nil
+	if sgs.coalescer != nil {
+		sgs.coalescer.discard()
+	}
+	sgs.mu.Unlock()
+
+	if sgs.onCancelFunc != nil {
+		sgs.onCancelFunc(code)
+	}
+	if sgs.endSpanFunc != nil {
+		sgs.endSpanFunc(localGroupError(code))
+	}
+
+	if sgs.qlog != nil {
+		errorCode := uint64(code)
+		sgs.qlog.groupClosed(true, sgs.subscribeID, sgs.sequence, &errorCode)
+	}
+
+	if sgs.drops != nil && sgs.dropped.CompareAndSwap(false, true) {
+		sgs.drops.recordGroup(groupDropReason(code), sgs.sequence)
+	}
+	sgs.endShadows(true, code)
 
 	if sgs.groupManager != nil {
 		sgs.groupManager.removeGroup(sgs)
 	}
+	if sgs.onEndFunc != nil {
+		sgs.onEndFunc()
+	}
 }
 
-// Close closes the group stream gracefully.
+// Close closes the group stream gracefully, once the frame being written
+// by a concurrent WriteFrame, if any, has been written. It fails if the group
+// has been canceled, also when its delivery timeout elapsed.
 func (sgs *GroupWriter) Close() error {
+	sgs.mu.Lock()
+	retained := sgs.retained
+	sgs.retained = nil
+	var flushErr error
+	if sgs.coalescer != nil {
+		flushErr = sgs.coalescer.flush()
+	}
+	if flushErr != nil {
+		sgs.mu.Unlock()
+		sgs.CancelWrite(InternalGroupErrorCode)
+		return flushErr
+	}
+	if retained != nil {
+		retained.buf.keep(retained)
+	}
+
+	if !sgs.stopExpiry() {
+		sgs.mu.Unlock()
+		return localGroupError(ExpiredGroupErrorCode)
+	}
+	// The stream must not be closed while a frame is being written, so
+	// Close waits for the write in flight, if any.
 	err := sgs.stream.Close()
+	sgs.mu.Unlock()
 	if err != nil {
-		return Cause(sgs.ctx)
+		sgs.endShadows(true, InternalGroupErrorCode)
+		err = Cause(sgs.ctx)
+		if sgs.endSpanFunc != nil {
+			sgs.endSpanFunc(err)
+		}
+		sgs.cancelCtx(err)
+		return err
+	}
+	sgs.qlog.groupClosed(true, sgs.subscribeID, sgs.sequence, nil)
+	if sgs.endSpanFunc != nil {
+		sgs.endSpanFunc(nil)
 	}
+	sgs.endShadows(false, 0)
 
 	if sgs.groupManager != nil {
 		sgs.groupManager.removeGroup(sgs)
 	}
+	if sgs.onEndFunc != nil {
+		sgs.onEndFunc()
+	}
+	sgs.cancelCtx(nil)
 
 	return nil
 }
 
+// stopWrites makes the frame writes waiting for pacing or for their turn to
+// be sent fail with cause, so that ending the group, which waits for the
+// write in flight, does not wait for them.
+func (sgs *GroupWriter) stopWrites(cause error) {
+	sgs.cancelCtx(cause)
+}
+
 // Context returns the context associated with this writer.
 func (s *GroupWriter) Context() context.Context {
 	return s.ctx
 }
+
+// endShadows ends the copies of the group on shadow subscriptions.
+func (sgs *GroupWriter) endShadows(canceled bool, code GroupErrorCode) {
+	for _, shadow := range sgs.shadows {
+		shadow.end(canceled, code)
+	}
+}
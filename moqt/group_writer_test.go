@@ -1,14 +1,19 @@
 package moqt
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 	"github.com/qumo-dev/gomoqt/transport"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // --- construction ----------------------------------------------------------
@@ -233,3 +238,188 @@ func TestGroupWriter_CancelWrite(t *testing.T) {
 	sgs.CancelWrite(1)
 	assert.Equal(t, 0, groupManager.countGroups())
 }
+
+// --- concurrency -----------------------------------------------------------
+
+func TestGroupWriter_WriteFrame_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	mockStream := &FakeQUICSendStream{
+		// Not synchronized: the GroupWriter must serialize writes itself.
+		WriteFunc: buf.Write,
+	}
+	sgs := newGroupWriter(mockStream, GroupSequence(1), newGroupWriterManager())
+
+	const writers = 8
+	const framesPerWriter = 50
+
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Go(func() {
+			frame := NewFrame(0)
+			_, _ = frame.Write(bytes.Repeat([]byte{byte(i)}, 16+i))
+			for range framesPerWriter {
+				assert.NoError(t, sgs.WriteFrame(frame))
+			}
+		})
+	}
+	wg.Wait()
+
+	// Every frame must decode intact; interleaved writes would corrupt them.
+	count := 0
+	frame := NewFrame(0)
+	for {
+		err := frame.decode(&buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		body := frame.Body()
+		require.NotEmpty(t, body)
+		assert.Equal(t, bytes.Repeat(body[:1], len(body)), body)
+		assert.Len(t, body, 16+int(body[0]))
+		count++
+	}
+	assert.Equal(t, writers*framesPerWriter, count)
+}
+
+func TestGroupWriter_CloseWhileWriting(t *testing.T) {
+	var active atomic.Int32
+	var overlapped atomic.Bool
+	// enter records a call to the stream and reports an overlap with
+	// another call in progress.
+	enter := func() func() {
+		if active.Add(1) > 1 {
+			overlapped.Store(true)
+		}
+		return func() { active.Add(-1) }
+	}
+	mockStream := &FakeQUICSendStream{
+		WriteFunc: func(p []byte) (int, error) {
+			defer enter()()
+			time.Sleep(time.Millisecond)
+			return len(p), nil
+		},
+		CloseFunc: func() error {
+			defer enter()()
+			time.Sleep(time.Millisecond)
+			return nil
+		},
+	}
+
+	for range 20 {
+		sgs := newGroupWriter(mockStream, GroupSequence(1), nil)
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte("payload"))
+
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			_ = sgs.WriteFrame(frame)
+		})
+		wg.Go(func() {
+			_ = sgs.Close()
+		})
+		wg.Wait()
+	}
+
+	assert.False(t, overlapped.Load(), "Close ran concurrently with Write")
+}
+
+func TestGroupWriter_WriteFrame_SharedFrameAcrossGroups(t *testing.T) {
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("shared payload"))
+
+	const groups = 8
+	bufs := make([]bytes.Buffer, groups)
+
+	var wg sync.WaitGroup
+	for i := range groups {
+		sgs := newGroupWriter(&FakeQUICSendStream{WriteFunc: bufs[i].Write}, GroupSequence(i), nil)
+		wg.Go(func() {
+			for range 20 {
+				assert.NoError(t, sgs.WriteFrame(frame))
+			}
+		})
+	}
+	wg.Wait()
+
+	for i := range groups {
+		decoded := NewFrame(0)
+		for range 20 {
+			require.NoError(t, decoded.decode(&bufs[i]))
+			assert.Equal(t, []byte("shared payload"), decoded.Body())
+		}
+	}
+}
+
+func TestGroupWriter_SendFrame(t *testing.T) {
+	tests := map[string]struct {
+		retained     bool
+		intercept    FrameInterceptor
+		wantBody     []byte
+		wantRetained bool
+	}{
+		"plain": {
+			wantBody: []byte("owned payload"),
+		},
+		"retained without copy": {
+			retained:     true,
+			wantBody:     []byte("owned payload"),
+			wantRetained: true,
+		},
+		"replaced by interceptor": {
+			retained: true,
+			intercept: func(frame *Frame) (*Frame, error) {
+				out := NewFrame(0)
+				_, _ = out.Write([]byte("replaced"))
+				return out, nil
+			},
+			wantBody: []byte("replaced"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			sgs := newGroupWriter(&FakeQUICSendStream{WriteFunc: buf.Write}, GroupSequence(1), nil)
+			sgs.interceptor = tt.intercept
+			if tt.retained {
+				sgs.retained = &retainedGroup{}
+			}
+
+			frame := AcquireFrame(32)
+			frame.AppendBytes(func(b []byte) []byte {
+				return append(b, "owned payload"...)
+			})
+			require.NoError(t, sgs.SendFrame(frame))
+
+			decoded := NewFrame(0)
+			require.NoError(t, decoded.decode(&buf))
+			assert.Equal(t, tt.wantBody, decoded.Body())
+
+			if tt.retained {
+				require.Len(t, sgs.retained.frames, 1)
+				assert.Equal(t, tt.wantBody, sgs.retained.frames[0].Body())
+				if tt.wantRetained {
+					assert.Same(t, frame, sgs.retained.frames[0], "an owned frame must be retained without a copy")
+				}
+			}
+		})
+	}
+}
+
+func TestGroupWriter_SendFrame_Nil(t *testing.T) {
+	sgs := newGroupWriter(&FakeQUICSendStream{}, GroupSequence(1), nil)
+	assert.NoError(t, sgs.SendFrame(nil))
+}
+
+func TestGroupWriter_CancelWriteWithApplicationError(t *testing.T) {
+	var code transport.StreamErrorCode
+	mockStream := &FakeQUICSendStream{
+		CancelWriteFunc: func(c transport.StreamErrorCode) { code = c },
+	}
+	sgs := newGroupWriter(mockStream, GroupSequence(1), newGroupWriterManager())
+	sgs.errorCodes = &ErrorCodeMap{Base: 0x2000}
+
+	require.NoError(t, sgs.CancelWriteWithApplicationError(5))
+	assert.Equal(t, transport.StreamErrorCode(0x2005), code)
+}
@@ -0,0 +1,507 @@
+package moqt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResumeTokenHeader is the header of WebTransport session requests and
+// responses that carries a resumption token. See Handoff.
+const ResumeTokenHeader = "Moqt-Resume-Token"
+
+// HandoffTrackName is the name of the track a Handoff publishes its state on.
+const HandoffTrackName TrackName = "sessions"
+
+// ResumeToken returns the resumption token issued for the session by a
+// server with a Handoff, or "" if none was issued. Tokens are only issued
+// over WebTransport. Client sends it when reconnecting, so that a standby
+// server that took over resumes the session with its subscriptions and
+// authorization token. A token is used once: the resumed session is issued
+// a new one.
+func (sess *Session) ResumeToken() string {
+	return sess.resumeToken
+}
+
+// SessionState is the metadata of a session that a Handoff replicates to a
+// standby server. It contains no media.
+type SessionState struct {
+	// ResumeToken is the token with which the client resumes the session.
+	ResumeToken string `json:"resume_token"`
+
+	// AuthToken is the token the session was authorized with, or "".
+	AuthToken string `json:"auth_token,omitempty"`
+
+	// RemoteAddr is the address of the client when the session started.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+
+	// Subscriptions are the tracks the client subscribed to during the
+	// session, in the order of their first subscription.
+	Subscriptions []TrackRef `json:"subscriptions,omitempty"`
+}
+
+// TrackRef identifies a track of a broadcast.
+type TrackRef struct {
+	BroadcastPath BroadcastPath `json:"broadcast_path"`
+	TrackName     TrackName     `json:"track_name"`
+}
+
+// Handoff keeps the state of the sessions of a server so that a warm
+// standby server can take over from it. The active server issues every
+// WebTransport session a resumption token and publishes the state of its
+// sessions, but not their media, on a track; the standby follows the track
+// with its own Handoff. When the active server fails, clients reconnect to
+// the standby with their resumption tokens, and the standby resumes them,
+// authorizing them again with the authorization token they started with.
+//
+// A resumption token is accepted once, and only after its session ended or
+// the active server it was replicated from was lost, for ResumeWindow. The
+// resumed session is issued a new token.
+//
+// On both servers, the Handoff is installed as the Authorizer, wrapping the
+// actual one, and as a Middleware:
+//
+//	h := &moqt.Handoff{}
+//	server.Authorizer = h.Authorizer(jwtAuthorizer)
+//	server.Use(h.Middleware)
+//
+// The active server publishes the state, protected by the Authorizer:
+//
+//	mux.Publish(ctx, "/.handoff", h)
+//
+// and the standby follows it over a session to the active server:
+//
+//	go h.Follow(ctx, sess, "/.handoff")
+//
+// Handlers find the SessionState of a session, including the subscriptions
+// of the session it resumes, with SessionStateFromContext. Native QUIC
+// cannot carry resumption tokens, so its sessions are never resumed.
+type Handoff struct {
+	// ResumeWindow is how long the resumption token of a session is
+	// accepted after the session ended or, on a standby, after it stopped
+	// following the active server. If zero, defaults to 30s.
+	ResumeWindow time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*handoffSession
+	watchers map[chan handoffEvent]struct{}
+}
+
+var _ TrackHandler = (*Handoff)(nil)
+
+// handoffSession is the state of a session known to a Handoff.
+type handoffSession struct {
+	state SessionState
+	// expires is when a session that ended, or was replicated from a lost
+	// active server, stops being resumable. It is zero while the session
+	// is open, which cannot be resumed.
+	expires time.Time
+}
+
+// handoffEvent is a change of the state of a Handoff, as published on its
+// track.
+type handoffEvent struct {
+	// Op is "open", "subscribe", "end" or "resume", the last of which
+	// consumes the token of a resumed session.
+	Op      string        `json:"op"`
+	Session *SessionState `json:"session,omitempty"`
+	Token   string        `json:"token,omitempty"`
+	Track   *TrackRef     `json:"track,omitempty"`
+}
+
+type handoffContextKeyType struct{}
+
+// handoffContextKey carries the SessionState of a session accepted through
+// a Handoff.
+var handoffContextKey = handoffContextKeyType{}
+
+// SessionStateFromContext returns the state of the session whose context is
+// ctx, if it was accepted through a Handoff. For a resumed session, it is the
+// state replicated from the server the session started on.
+func SessionStateFromContext(ctx context.Context) (SessionState, bool) {
+	state, ok := ctx.Value(handoffContextKey).(*SessionState)
+	if !ok {
+		return SessionState{}, false
+	}
+	return *state, true
+}
+
+func (h *Handoff) resumeWindow() time.Duration {
+	if h.ResumeWindow > 0 {
+		return h.ResumeWindow
+	}
+	return 30 * time.Second
+}
+
+// Sessions returns the states of the sessions known to h: those of the
+// server it is installed on, open or still resumable, and, on a standby,
+// those replicated from the active server that have not been resumed yet.
+func (h *Handoff) Sessions() []SessionState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	states := make([]SessionState, 0, len(h.sessions))
+	for _, s := range h.sessions {
+		if s.expires.IsZero() || now.Before(s.expires) {
+			states = append(states, s.state)
+		}
+	}
+	slices.SortFunc(states, func(a, b SessionState) int {
+		return strings.Compare(a.ResumeToken, b.ResumeToken)
+	})
+	return states
+}
+
+// Restore makes the sessions of states resumable on the server h is
+// installed on, for the resume window, as if replicated from an active
+// server that was lost. It takes over the sessions of a binary replaced
+// with an Upgrader, from the Sessions of its UpgradeState.
+func (h *Handoff) Restore(states []SessionState) {
+	tokens := make([]string, 0, len(states))
+	for _, state := range states {
+		h.open(&state)
+		tokens = append(tokens, state.ResumeToken)
+	}
+	h.lose(tokens)
+}
+
+// Authorizer returns an Authorizer that authorizes sessions with next,
+// issuing a resumption token to those over WebTransport. A session with the
+// resumption token of a resumable session is authorized with the
+// authorization token stored for that session instead of its own, and
+// continues its state. Subscriptions are authorized by next and recorded in
+// the session state. If next is nil, everything is allowed.
+func (h *Handoff) Authorizer(next Authorizer) Authorizer {
+	if next == nil {
+		next = AllowAuthorizer{}
+	}
+	return &handoffAuthorizer{handoff: h, next: next}
+}
+
+type handoffAuthorizer struct {
+	handoff *Handoff
+	next    Authorizer
+}
+
+func (a *handoffAuthorizer) AuthorizeSession(ctx context.Context, r *SessionAuthRequest) (context.Context, error) {
+	h := a.handoff
+	var resumed *SessionState
+	if r.Request != nil && r.ResponseHeader != nil {
+		if token := r.Request.Header.Get(ResumeTokenHeader); token != "" {
+			resumed, _ = h.resume(token)
+		}
+	}
+
+	req := r
+	if resumed != nil {
+		resumedReq := *r
+		resumedReq.Token = resumed.AuthToken
+		req = &resumedReq
+	}
+	authCtx, err := a.next.AuthorizeSession(ctx, req)
+	if err != nil || r.ResponseHeader == nil {
+		return authCtx, err
+	}
+
+	state := &SessionState{
+		ResumeToken: newResumeToken(),
+		AuthToken:   r.Token,
+	}
+	if r.RemoteAddr != nil {
+		state.RemoteAddr = r.RemoteAddr.String()
+	}
+	if resumed != nil {
+		state.AuthToken = resumed.AuthToken
+		state.RemoteAddr = resumed.RemoteAddr
+		state.Subscriptions = resumed.Subscriptions
+	}
+	h.open(state)
+	r.ResponseHeader.Set(ResumeTokenHeader, state.ResumeToken)
+	return context.WithValue(authCtx, handoffContextKey, state), nil
+}
+
+func (a *handoffAuthorizer) AuthorizeSubscribe(ctx context.Context, r *SubscribeAuthRequest) error {
+	if err := a.next.AuthorizeSubscribe(ctx, r); err != nil {
+		return err
+	}
+	if state, ok := ctx.Value(handoffContextKey).(*SessionState); ok {
+		a.handoff.subscribe(state.ResumeToken, TrackRef{
+			BroadcastPath: r.BroadcastPath,
+			TrackName:     r.TrackName,
+		})
+	}
+	return nil
+}
+
+// Middleware is a Middleware that makes sessions accepted through the
+// Handoff's Authorizer resumable when they end, for ResumeWindow.
+func (h *Handoff) Middleware(next Handler) Handler {
+	return HandleFunc(func(sess *Session) {
+		if state, ok := sess.Context().Value(handoffContextKey).(*SessionState); ok {
+			token := state.ResumeToken
+			context.AfterFunc(sess.Context(), func() {
+				h.end(token)
+			})
+		}
+		next.ServeMOQ(sess)
+	})
+}
+
+// ServeTrack publishes the state of h: the sessions known when the
+// subscription starts, then their changes, as the frames of one group, so
+// that they are received in order. Publish h
+// on a path of the active server that only its standby may subscribe to,
+// since resumption tokens authorize sessions.
+func (h *Handoff) ServeTrack(tw *TrackWriter) {
+	if tw.TrackName != HandoffTrackName {
+		NotFoundTrackHandler.ServeTrack(tw)
+		return
+	}
+
+	ch := make(chan handoffEvent, 64)
+	h.mu.Lock()
+	if h.watchers == nil {
+		h.watchers = make(map[chan handoffEvent]struct{})
+	}
+	h.watchers[ch] = struct{}{}
+	var snapshot []handoffEvent
+	now := time.Now()
+	for token, s := range h.sessions {
+		if !s.expires.IsZero() && !now.Before(s.expires) {
+			continue
+		}
+		state := cloneSessionState(s.state)
+		snapshot = append(snapshot, handoffEvent{Op: "open", Session: &state})
+		if !s.expires.IsZero() {
+			snapshot = append(snapshot, handoffEvent{Op: "end", Token: token})
+		}
+	}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.watchers, ch)
+		h.mu.Unlock()
+	}()
+
+	gw, err := tw.OpenGroup()
+	if err != nil {
+		return
+	}
+	for _, ev := range snapshot {
+		if err := writeHandoffEvent(gw, ev); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				// The standby fell behind; it resynchronizes by
+				// subscribing again.
+				gw.CancelWrite(InternalGroupErrorCode)
+				tw.CloseWithError(SubscribeErrorCodeInternal)
+				return
+			}
+			if err := writeHandoffEvent(gw, ev); err != nil {
+				return
+			}
+		case <-tw.Context().Done():
+			return
+		}
+	}
+}
+
+// Follow replicates the state of the active server by subscribing to its
+// Handoff track at path over sess, until the subscription ends or ctx is
+// canceled. The replicated sessions stay resumable for ResumeWindow after
+// Follow returns, so that their clients can fail over to this server. Follow
+// returns the error that ended the subscription, or nil if ctx is canceled.
+func (h *Handoff) Follow(ctx context.Context, sess *Session, path BroadcastPath) error {
+	tr, err := sess.Subscribe(ctx, path, HandoffTrackName, nil)
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+
+	var followed []string
+	defer func() {
+		h.lose(followed)
+	}()
+
+	gr, err := tr.AcceptGroup(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer gr.CancelRead(InternalGroupErrorCode)
+	stop := context.AfterFunc(ctx, func() {
+		gr.CancelRead(InternalGroupErrorCode)
+	})
+	defer stop()
+
+	frame := NewFrame(0)
+	for {
+		if err := gr.ReadFrame(frame); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		var ev handoffEvent
+		if err := json.Unmarshal(frame.Body(), &ev); err != nil {
+			return err
+		}
+		if ev.Op == "open" && ev.Session != nil {
+			followed = append(followed, ev.Session.ResumeToken)
+		}
+		h.apply(ev)
+	}
+}
+
+// apply applies an event replicated from the active server.
+func (h *Handoff) apply(ev handoffEvent) {
+	switch ev.Op {
+	case "open":
+		if ev.Session != nil {
+			h.open(ev.Session)
+		}
+	case "subscribe":
+		if ev.Track != nil {
+			h.subscribe(ev.Token, *ev.Track)
+		}
+	case "end":
+		h.end(ev.Token)
+	case "resume":
+		h.resume(ev.Token)
+	}
+}
+
+func (h *Handoff) open(state *SessionState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.sessions == nil {
+		h.sessions = make(map[string]*handoffSession)
+	}
+	h.pruneLocked(time.Now())
+	h.sessions[state.ResumeToken] = &handoffSession{state: cloneSessionState(*state)}
+	published := cloneSessionState(*state)
+	h.publishLocked(handoffEvent{Op: "open", Session: &published})
+}
+
+func (h *Handoff) subscribe(token string, track TrackRef) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.sessions[token]
+	if !ok || slices.Contains(s.state.Subscriptions, track) {
+		return
+	}
+	s.state.Subscriptions = append(s.state.Subscriptions, track)
+	h.publishLocked(handoffEvent{Op: "subscribe", Token: token, Track: &track})
+}
+
+// end makes the open session with token resumable for the resume window.
+func (h *Handoff) end(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.sessions[token]
+	if !ok || !s.expires.IsZero() {
+		return
+	}
+	s.expires = time.Now().Add(h.resumeWindow())
+	h.publishLocked(handoffEvent{Op: "end", Token: token})
+}
+
+// resume consumes the token of a session and returns its state, if the
+// session is resumable: it ended, or was replicated from a lost active
+// server, within the resume window.
+func (h *Handoff) resume(token string) (*SessionState, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.sessions[token]
+	if !ok || s.expires.IsZero() {
+		return nil, false
+	}
+	delete(h.sessions, token)
+	h.publishLocked(handoffEvent{Op: "resume", Token: token})
+	if !time.Now().Before(s.expires) {
+		return nil, false
+	}
+	state := cloneSessionState(s.state)
+	return &state, true
+}
+
+// lose makes the open sessions replicated from an active server that is no
+// longer followed resumable for the resume window.
+func (h *Handoff) lose(tokens []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	expires := time.Now().Add(h.resumeWindow())
+	for _, token := range tokens {
+		if s, ok := h.sessions[token]; ok && s.expires.IsZero() {
+			s.expires = expires
+		}
+	}
+}
+
+// pruneLocked forgets the sessions that are no longer resumable at now.
+// h.mu must be held.
+func (h *Handoff) pruneLocked(now time.Time) {
+	for token, s := range h.sessions {
+		if !s.expires.IsZero() && !now.Before(s.expires) {
+			delete(h.sessions, token)
+		}
+	}
+}
+
+// publishLocked sends ev to the subscribers of the state track. A
+// subscriber that does not keep up is dropped. h.mu must be held.
+func (h *Handoff) publishLocked(ev handoffEvent) {
+	for ch := range h.watchers {
+		select {
+		case ch <- ev:
+		default:
+			delete(h.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// writeHandoffEvent writes ev to gw as a frame.
+func writeHandoffEvent(gw *GroupWriter, ev handoffEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	frame := NewFrame(len(b))
+	_, _ = frame.Write(b)
+	return gw.WriteFrame(frame)
+}
+
+func cloneSessionState(state SessionState) SessionState {
+	state.Subscriptions = slices.Clone(state.Subscriptions)
+	return state
+}
+
+// newResumeToken returns a new random resumption token.
+func newResumeToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("moqt: crypto/rand unavailable: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,232 @@
+package moqt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingAuthorizer counts the sessions it authorizes, and records the
+// last token it was given. It rejects the token "revoked".
+type countingAuthorizer struct {
+	AllowAuthorizer
+	sessions int
+	token    string
+}
+
+func (a *countingAuthorizer) AuthorizeSession(ctx context.Context, r *SessionAuthRequest) (context.Context, error) {
+	a.sessions++
+	a.token = r.Token
+	if r.Token == "revoked" {
+		return nil, ErrUnauthorized
+	}
+	return ctx, nil
+}
+
+func newHandoffAuthRequest(token string) *SessionAuthRequest {
+	r := httptest.NewRequest(http.MethodConnect, "https://example.com/moq", nil)
+	if token != "" {
+		r.Header.Set(ResumeTokenHeader, token)
+	}
+	return &SessionAuthRequest{
+		Token:          "jwt",
+		Transport:      "webtransport",
+		Request:        r,
+		ResponseHeader: http.Header{},
+	}
+}
+
+func TestHandoff_Authorizer(t *testing.T) {
+	h := &Handoff{}
+	next := &countingAuthorizer{}
+	auth := h.Authorizer(next)
+
+	// A new session is authorized by next and issued a token.
+	r := newHandoffAuthRequest("")
+	ctx, err := auth.AuthorizeSession(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, 1, next.sessions)
+	token := r.ResponseHeader.Get(ResumeTokenHeader)
+	require.NotEmpty(t, token)
+
+	state, ok := SessionStateFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, token, state.ResumeToken)
+	assert.Equal(t, "jwt", state.AuthToken)
+
+	require.NoError(t, auth.AuthorizeSubscribe(ctx, &SubscribeAuthRequest{BroadcastPath: "/live", TrackName: "video"}))
+	require.NoError(t, auth.AuthorizeSubscribe(ctx, &SubscribeAuthRequest{BroadcastPath: "/live", TrackName: "video"}))
+	sessions := h.Sessions()
+	require.Len(t, sessions, 1)
+	assert.Equal(t, []TrackRef{{BroadcastPath: "/live", TrackName: "video"}}, sessions[0].Subscriptions)
+
+	// The token of an open session does not resume it.
+	r = newHandoffAuthRequest(token)
+	r.Token = "other"
+	ctx, err = auth.AuthorizeSession(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.sessions)
+	assert.Equal(t, "other", next.token)
+	state, ok = SessionStateFromContext(ctx)
+	require.True(t, ok)
+	assert.Empty(t, state.Subscriptions)
+
+	// Once the session ended, its token resumes it, authorized again with
+	// the stored token, and is replaced.
+	h.end(token)
+	r = newHandoffAuthRequest(token)
+	r.Token = ""
+	ctx, err = auth.AuthorizeSession(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, 3, next.sessions)
+	assert.Equal(t, "jwt", next.token)
+	resumed := r.ResponseHeader.Get(ResumeTokenHeader)
+	assert.NotEmpty(t, resumed)
+	assert.NotEqual(t, token, resumed)
+	state, ok = SessionStateFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, resumed, state.ResumeToken)
+	assert.Equal(t, "jwt", state.AuthToken)
+	assert.Len(t, state.Subscriptions, 1)
+
+	// A token is used once.
+	h.end(resumed)
+	r = newHandoffAuthRequest(token)
+	ctx, err = auth.AuthorizeSession(context.Background(), r)
+	require.NoError(t, err)
+	state, ok = SessionStateFromContext(ctx)
+	require.True(t, ok)
+	assert.Empty(t, state.Subscriptions)
+
+	// An unknown token is authorized as a new session.
+	r = newHandoffAuthRequest("unknown")
+	_, err = auth.AuthorizeSession(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, 5, next.sessions)
+	assert.NotEqual(t, "unknown", r.ResponseHeader.Get(ResumeTokenHeader))
+
+	// Native QUIC sessions get no token.
+	ctx, err = auth.AuthorizeSession(context.Background(), &SessionAuthRequest{Transport: "quic"})
+	require.NoError(t, err)
+	assert.Equal(t, 6, next.sessions)
+	_, ok = SessionStateFromContext(ctx)
+	assert.False(t, ok)
+}
+
+func TestHandoff_Replication(t *testing.T) {
+	active := &Handoff{}
+	standby := &Handoff{ResumeWindow: 100 * time.Millisecond}
+
+	// Follow the active Handoff as ServeTrack does.
+	events := make(chan handoffEvent, 16)
+	active.mu.Lock()
+	active.watchers = map[chan handoffEvent]struct{}{events: {}}
+	active.mu.Unlock()
+
+	auth := active.Authorizer(nil)
+	r1 := newHandoffAuthRequest("")
+	ctx1, err := auth.AuthorizeSession(context.Background(), r1)
+	require.NoError(t, err)
+	require.NoError(t, auth.AuthorizeSubscribe(ctx1, &SubscribeAuthRequest{BroadcastPath: "/live", TrackName: "audio"}))
+	r2 := newHandoffAuthRequest("")
+	_, err = auth.AuthorizeSession(context.Background(), r2)
+	require.NoError(t, err)
+	active.end(r2.ResponseHeader.Get(ResumeTokenHeader))
+
+	var followed []string
+	for len(events) > 0 {
+		ev := <-events
+		if ev.Op == "open" {
+			followed = append(followed, ev.Session.ResumeToken)
+		}
+		standby.apply(ev)
+	}
+	assert.Equal(t, active.Sessions(), standby.Sessions(), "the standby must hold the state of the active")
+
+	// After losing the active, its sessions are resumable for the window.
+	standby.lose(followed)
+	r := newHandoffAuthRequest(r1.ResponseHeader.Get(ResumeTokenHeader))
+	next := &countingAuthorizer{}
+	ctx, err := standby.Authorizer(next).AuthorizeSession(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, 1, next.sessions, "a resumed session must be authorized again")
+	assert.Equal(t, "jwt", next.token)
+	state, ok := SessionStateFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, []TrackRef{{BroadcastPath: "/live", TrackName: "audio"}}, state.Subscriptions)
+
+	// A resumed session no longer expires with the window.
+	time.Sleep(150 * time.Millisecond)
+	assert.Len(t, standby.Sessions(), 1)
+}
+
+func TestHandoff_ResumeWindowExpires(t *testing.T) {
+	h := &Handoff{ResumeWindow: time.Millisecond}
+	h.apply(handoffEvent{Op: "open", Session: &SessionState{ResumeToken: "tok"}})
+	h.lose([]string{"tok"})
+	time.Sleep(5 * time.Millisecond)
+
+	next := &countingAuthorizer{}
+	r := newHandoffAuthRequest("tok")
+	_, err := h.Authorizer(next).AuthorizeSession(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, 1, next.sessions, "an expired token must be authorized again")
+	assert.NotEqual(t, "tok", r.ResponseHeader.Get(ResumeTokenHeader))
+}
+
+func TestHandoff_Restore(t *testing.T) {
+	h := &Handoff{}
+	h.Restore([]SessionState{{ResumeToken: "tok", AuthToken: "jwt"}})
+	assert.Equal(t, []SessionState{{ResumeToken: "tok", AuthToken: "jwt"}}, h.Sessions())
+
+	next := &countingAuthorizer{}
+	r := newHandoffAuthRequest("tok")
+	r.Token = ""
+	ctx, err := h.Authorizer(next).AuthorizeSession(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, "jwt", next.token, "a restored session must be authorized with its token")
+	state, ok := SessionStateFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "jwt", state.AuthToken)
+	assert.NotEqual(t, "tok", state.ResumeToken)
+}
+
+func TestHandoff_ResumeRevoked(t *testing.T) {
+	h := &Handoff{}
+	h.Restore([]SessionState{{ResumeToken: "tok", AuthToken: "revoked"}})
+
+	next := &countingAuthorizer{}
+	r := newHandoffAuthRequest("tok")
+	_, err := h.Authorizer(next).AuthorizeSession(context.Background(), r)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, "revoked", next.token)
+	assert.Empty(t, r.ResponseHeader.Get(ResumeTokenHeader))
+	assert.Empty(t, h.Sessions(), "the token must be consumed")
+}
+
+func TestHandoff_Middleware(t *testing.T) {
+	h := &Handoff{}
+	h.open(&SessionState{ResumeToken: "tok"})
+
+	conn := &FakeStreamConn{}
+	sess := newSession(conn, nil, nil, nil, nil, nil, nil, &sessionOptions{
+		authCtx: context.WithValue(context.Background(), handoffContextKey, &SessionState{ResumeToken: "tok"}),
+	})
+
+	served := false
+	h.Middleware(HandleFunc(func(*Session) { served = true })).ServeMOQ(sess)
+	assert.True(t, served)
+	assert.Len(t, h.Sessions(), 1)
+
+	_ = sess.CloseWithError(NoError, "")
+	assert.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return !h.sessions["tok"].expires.IsZero()
+	}, time.Second, time.Millisecond, "an ended session must become resumable")
+}
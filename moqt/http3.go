@@ -0,0 +1,33 @@
+package moqt
+
+import (
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/qumo-dev/gomoqt/moqt/internal/quicgo"
+	"github.com/qumo-dev/gomoqt/moqt/internal/webtransportgo"
+)
+
+// NewHTTP3WebTransportServer returns a WebTransportServer that enables
+// WebTransport on an existing HTTP/3 server, so that a WebTransportHandler
+// mounted on its Handler shares the server, and its UDP socket, with the
+// other HTTP/3 routes. Requests that are not WebTransport upgrades are
+// served by h3.Handler as usual.
+//
+// h3 is modified: its settings announce WebTransport and datagram support,
+// and its ConnContext is wrapped. The connections are passed to
+// Server.ServeQUICConn, such as with WrapQUICConn, instead of h3.Serve.
+func NewHTTP3WebTransportServer(h3 *http3.Server) WebTransportServer {
+	return &webtransportgo.Server{
+		Handler: h3.Handler,
+		H3:      h3,
+	}
+}
+
+// WrapQUICConn returns the StreamConn of a connection accepted from a
+// quic-go listener, to be served with Server.ServeQUICConn.
+//
+// For WebTransport, the listener must negotiate NextProtoH3 and enable
+// datagrams and stream resets with partial delivery in its quic.Config.
+func WrapQUICConn(conn *quic.Conn) StreamConn {
+	return quicgo.WrapConn(conn)
+}
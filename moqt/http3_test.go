@@ -0,0 +1,69 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTP3WebTransportServer(t *testing.T) {
+	// The application owns the listener and the HTTP/3 server.
+	ln, err := quic.ListenAddr("127.0.0.1:0", testutil.TLSConfig(t, NextProtoH3), &quic.Config{EnableDatagrams: true, EnableStreamResetPartialDelivery: true})
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	})
+	mux.Handle("/moq", &WebTransportHandler{
+		Handler: HandleFunc(func(sess *Session) {
+			<-sess.Context().Done()
+		}),
+	})
+	server := &Server{WebTransportServer: NewHTTP3WebTransportServer(&http3.Server{Handler: mux})}
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = ln.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = server.ServeQUICConn(WrapQUICConn(conn))
+			}()
+		}
+	}()
+
+	clientTLS := &tls.Config{ServerName: "localhost", InsecureSkipVerify: true}
+	dialer := &Dialer{TLSConfig: clientTLS}
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	sess, err := dialer.Dial(ctx, "https://"+addr+"/moq", nil)
+	require.NoError(t, err)
+	assert.Equal(t, NextProtoH3, sess.ConnectionState().TLS.NegotiatedProtocol)
+	_ = sess.CloseWithError(NoError, "")
+
+	// Other routes are still served.
+	tr := &http3.Transport{TLSClientConfig: clientTLS}
+	defer tr.Close()
+	resp, err := (&http.Client{Transport: tr}).Get("https://" + addr + "/hello")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+}
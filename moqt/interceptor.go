@@ -0,0 +1,70 @@
+package moqt
+
+// FrameInterceptor inspects or transforms a frame on its way to or from
+// the network, for cross-cutting features such as watermarking, tagging or
+// content filtering. It returns the frame to continue with, or nil to drop
+// the frame. An error fails the WriteFrame or ReadFrame call.
+//
+// On the write path, frame belongs to the caller of WriteFrame and may be
+// written to other groups concurrently, so an interceptor must not modify
+// it: it returns a new frame, such as a modified Clone, instead. On the read
+// path, frame is the buffer of the caller of ReadFrame and may be modified
+// in place.
+//
+// Interceptors are registered per track with TrackWriter.SetFrameInterceptor
+// and TrackReader.SetFrameInterceptor, and per session with the
+// WriteFrameInterceptor and ReadFrameInterceptor of Config. Frames written
+// pass the interceptor of the track first and the one of the session last;
+// frames read pass them in the reverse order.
+type FrameInterceptor func(frame *Frame) (*Frame, error)
+
+// ChainFrameInterceptors returns a FrameInterceptor running interceptors in
+// order, each on the frame returned by the previous one, until one drops
+// the frame or fails. Nil interceptors are skipped.
+func ChainFrameInterceptors(interceptors ...FrameInterceptor) FrameInterceptor {
+	var chain []FrameInterceptor
+	for _, i := range interceptors {
+		if i != nil {
+			chain = append(chain, i)
+		}
+	}
+	switch len(chain) {
+	case 0:
+		return nil
+	case 1:
+		return chain[0]
+	}
+	return func(frame *Frame) (*Frame, error) {
+		for _, i := range chain {
+			var err error
+			frame, err = i(frame)
+			if frame == nil || err != nil {
+				return nil, err
+			}
+		}
+		return frame, nil
+	}
+}
+
+// interceptRead runs intercept on frame, a frame read into the buffer of
+// the caller, and copies the frame it returns, if another, into frame. It
+// reports whether the frame was dropped.
+func interceptRead(intercept FrameInterceptor, frame *Frame) (bool, error) {
+	if intercept == nil {
+		return false, nil
+	}
+	out, err := intercept(frame)
+	if err != nil {
+		return false, err
+	}
+	if out == nil {
+		return true, nil
+	}
+	if out != frame {
+		frame.Reset()
+		frame.append(out.Body())
+		frame.schema = out.schema
+		frame.ext = out.ext
+	}
+	return false, nil
+}
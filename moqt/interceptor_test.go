@@ -0,0 +1,211 @@
+package moqt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendInterceptor returns an interceptor appending s to the payload of a
+// clone of the frame.
+func appendInterceptor(s string) FrameInterceptor {
+	return func(frame *Frame) (*Frame, error) {
+		out := frame.Clone()
+		_, _ = out.Write([]byte(s))
+		return out, nil
+	}
+}
+
+// dropInterceptor drops the frames with the payload s.
+func dropInterceptor(s string) FrameInterceptor {
+	return func(frame *Frame) (*Frame, error) {
+		if string(frame.Body()) == s {
+			return nil, nil
+		}
+		return frame, nil
+	}
+}
+
+func TestChainFrameInterceptors(t *testing.T) {
+	errIntercept := errors.New("intercept")
+	failing := func(*Frame) (*Frame, error) { return nil, errIntercept }
+
+	tests := map[string]struct {
+		interceptors []FrameInterceptor
+		payload      string
+		want         string
+		dropped      bool
+		wantErr      error
+	}{
+		"in order": {
+			interceptors: []FrameInterceptor{appendInterceptor("1"), nil, appendInterceptor("2")},
+			payload:      "a",
+			want:         "a12",
+		},
+		"drop stops the chain": {
+			interceptors: []FrameInterceptor{dropInterceptor("a"), failing},
+			payload:      "a",
+			dropped:      true,
+		},
+		"error stops the chain": {
+			interceptors: []FrameInterceptor{failing, appendInterceptor("1")},
+			payload:      "a",
+			wantErr:      errIntercept,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			frame := NewFrame(0)
+			_, _ = frame.Write([]byte(tt.payload))
+
+			out, err := ChainFrameInterceptors(tt.interceptors...)(frame)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.dropped {
+				assert.Nil(t, out)
+				return
+			}
+			assert.Equal(t, tt.want, string(out.Body()))
+			assert.Equal(t, tt.payload, string(frame.Body()), "the input frame must not be modified")
+		})
+	}
+
+	assert.Nil(t, ChainFrameInterceptors())
+	assert.Nil(t, ChainFrameInterceptors(nil, nil))
+}
+
+func TestGroupWriter_SetFrameInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	var drops dropRecorder
+	group := newGroupWriter(&FakeQUICSendStream{WriteFunc: buf.Write}, GroupSequence(1), nil)
+	group.drops = &drops
+	group.SetFrameInterceptor(ChainFrameInterceptors(dropInterceptor("b"), appendInterceptor("!")))
+
+	for _, payload := range []string{"a", "b", "c"} {
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte(payload))
+		require.NoError(t, group.WriteFrame(frame))
+		assert.Equal(t, payload, string(frame.Body()), "the written frame must not be modified")
+	}
+	assert.Equal(t, uint64(1), drops.stats().Frames[DropReasonPolicy])
+
+	reader := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(buf.Bytes()).Read}, nil)
+	var got []string
+	frame := NewFrame(0)
+	for {
+		err := reader.ReadFrame(frame)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, string(frame.Body()))
+	}
+	assert.Equal(t, []string{"a!", "c!"}, got)
+}
+
+func TestGroupReader_SetFrameInterceptor(t *testing.T) {
+	errIntercept := errors.New("intercept")
+	data := writeSchemaGroup(t, 0, ChecksumNone, "a", "b", "c", "d")
+
+	var drops dropRecorder
+	group := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil)
+	group.drops = &drops
+	group.SetFrameInterceptor(func(frame *Frame) (*Frame, error) {
+		switch string(frame.Body()) {
+		case "b":
+			return nil, nil
+		case "c":
+			// Replacing the frame copies it into the buffer of the caller.
+			out := NewFrame(0)
+			_, _ = out.Write([]byte("C"))
+			return out, nil
+		case "d":
+			return nil, errIntercept
+		}
+		return frame, nil
+	})
+
+	frame := NewFrame(0)
+	require.NoError(t, group.ReadFrame(frame))
+	assert.Equal(t, "a", string(frame.Body()))
+	require.NoError(t, group.ReadFrame(frame))
+	assert.Equal(t, "C", string(frame.Body()))
+	assert.ErrorIs(t, group.ReadFrame(frame), errIntercept)
+	assert.ErrorIs(t, group.ReadFrame(frame), io.EOF)
+	assert.Equal(t, uint64(1), drops.stats().Frames[DropReasonPolicy])
+}
+
+func TestTrackWriter_SetFrameInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+	}, func() {})
+	defer writer.Close()
+	writer.sessionInterceptor = appendInterceptor("session")
+
+	// The track interceptor runs first.
+	writer.SetFrameInterceptor(appendInterceptor("track"))
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+	out, err := group.interceptor(NewFrame(0))
+	require.NoError(t, err)
+	assert.Equal(t, "tracksession", string(out.Body()))
+
+	writer.SetFrameInterceptor(nil)
+	group, err = writer.OpenGroup()
+	require.NoError(t, err)
+	out, err = group.interceptor(NewFrame(0))
+	require.NoError(t, err)
+	assert.Equal(t, "session", string(out.Body()))
+}
+
+func TestTrackReader_SetFrameInterceptor(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+	receiver.sessionInterceptor = appendInterceptor("session")
+	receiver.SetFrameInterceptor(appendInterceptor("track"))
+
+	// The session interceptor runs first.
+	receiver.enqueueGroup(GroupSequence(1), &FakeQUICReceiveStream{})
+	group, err := receiver.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	require.NotNil(t, group.interceptor)
+	out, err := group.interceptor(NewFrame(0))
+	require.NoError(t, err)
+	assert.Equal(t, "sessiontrack", string(out.Body()))
+}
+
+func TestTrackWriter_WriteDatagram_FrameInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+	}, func() {})
+	defer writer.Close()
+
+	var calls int
+	writer.SetFrameInterceptor(func(frame *Frame) (*Frame, error) {
+		calls++
+		return appendInterceptor("!")(frame)
+	})
+
+	// Without datagram support the frame is written on a stream, and
+	// intercepted once.
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("a"))
+	_, err := writer.WriteDatagram(frame)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.True(t, strings.HasSuffix(buf.String(), "a!"))
+}
@@ -0,0 +1,284 @@
+// Package connectudp proxies UDP packets through an HTTP proxy with
+// CONNECT-UDP over HTTP/1.1, as specified by RFC 9298, so that QUIC
+// connections can be dialed from networks that only reach the Internet
+// through a proxy.
+package connectudp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// DefaultTemplate is the URI template of proxies whose URL has no path.
+const DefaultTemplate = "/.well-known/masque/udp/{target_host}/{target_port}/"
+
+// datagramCapsule is the type of the DATAGRAM capsule of RFC 9297.
+const datagramCapsule = 0x00
+
+// maxPayload bounds the UDP payload of a received capsule, and maxCapsule
+// the capsules skipped by a Conn.
+const (
+	maxPayload = 1 << 16
+	maxCapsule = 1 << 24
+)
+
+// DialFunc opens the TCP connection to a proxy.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Dial opens a UDP tunnel to target, a host:port, through proxy, an http or
+// https URL whose path, if any, is the URI template of the proxy. The
+// connection to the proxy is opened with dial, or a net.Dialer if nil.
+// tlsConfig, which may be nil, configures TLS for https proxies.
+func Dial(ctx context.Context, proxy *url.URL, target string, dial DialFunc, tlsConfig *tls.Config) (*Conn, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, fmt.Errorf("connectudp: %w", err)
+	}
+
+	proxyAddr, err := canonicalAddr(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	conn, err := dial(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("connectudp: dial proxy: %w", err)
+	}
+
+	// Unblock the handshake when ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	c, err := handshake(ctx, conn, proxy, host, port, tlsConfig)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		return nil, err
+	}
+	if !stop() {
+		conn.Close()
+		return nil, context.Cause(ctx)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func handshake(ctx context.Context, conn net.Conn, proxy *url.URL, host, port string, tlsConfig *tls.Config) (*Conn, error) {
+	if proxy.Scheme == "https" {
+		config := tlsConfig.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			config.ServerName = proxy.Hostname()
+		}
+		config.NextProtos = []string{"http/1.1"}
+		tc := tls.Client(conn, config)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("connectudp: proxy TLS handshake: %w", err)
+		}
+		conn = tc
+	}
+
+	path := ExpandTemplate(proxy.Path, host, port)
+	var b strings.Builder
+	fmt.Fprintf(&b, "GET %s HTTP/1.1\r\n", path)
+	fmt.Fprintf(&b, "Host: %s\r\n", proxy.Host)
+	b.WriteString("Connection: Upgrade\r\n")
+	b.WriteString("Upgrade: connect-udp\r\n")
+	b.WriteString("Capsule-Protocol: ?1\r\n")
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		fmt.Fprintf(&b, "Proxy-Authorization: Basic %s\r\n", auth)
+	}
+	b.WriteString("\r\n")
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return nil, fmt.Errorf("connectudp: write request: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(br, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		return nil, fmt.Errorf("connectudp: read response: %w", err)
+	}
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		rsp.Body.Close()
+		return nil, &ProxyError{StatusCode: rsp.StatusCode, Status: rsp.Status}
+	}
+	if !strings.EqualFold(rsp.Header.Get("Upgrade"), "connect-udp") {
+		return nil, errors.New("connectudp: proxy did not upgrade to connect-udp")
+	}
+
+	return &Conn{
+		conn:   conn,
+		br:     br,
+		target: Addr(net.JoinHostPort(host, port)),
+		rbuf:   make([]byte, maxPayload+8),
+	}, nil
+}
+
+// ProxyError is returned by Dial when the proxy refuses the tunnel.
+type ProxyError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *ProxyError) Error() string {
+	return "connectudp: proxy refused tunnel: " + e.Status
+}
+
+// ExpandTemplate returns the path of the tunnel to host and port from the
+// URI template of a proxy, or from DefaultTemplate if template is empty or
+// "/". Variables are percent-encoded, so that the colons of IPv6 addresses
+// do not end up in the path.
+func ExpandTemplate(template, host, port string) string {
+	if template == "" || template == "/" {
+		template = DefaultTemplate
+	}
+	return strings.NewReplacer(
+		"{target_host}", url.QueryEscape(host),
+		"{target_port}", url.QueryEscape(port),
+	).Replace(template)
+}
+
+func canonicalAddr(proxy *url.URL) (string, error) {
+	var port string
+	switch proxy.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	default:
+		return "", fmt.Errorf("connectudp: unsupported proxy scheme %q", proxy.Scheme)
+	}
+	if proxy.Port() != "" {
+		port = proxy.Port()
+	}
+	return net.JoinHostPort(proxy.Hostname(), port), nil
+}
+
+// Addr is the address of the target of a tunnel. It is resolved by the
+// proxy.
+type Addr string
+
+// Network implements net.Addr.
+func (a Addr) Network() string { return "udp" }
+
+// String implements net.Addr.
+func (a Addr) String() string { return string(a) }
+
+// Conn is a net.PacketConn whose packets go to, and come from, the target of
+// a tunnel, whatever address they are written to.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	target Addr
+
+	rmu  sync.Mutex
+	rbuf []byte
+
+	wmu  sync.Mutex
+	wbuf []byte
+}
+
+var _ net.PacketConn = (*Conn)(nil)
+
+// ReadFrom reads the payload of the next DATAGRAM capsule of the tunnel.
+// Other capsules and datagrams of other contexts are skipped.
+func (c *Conn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	for {
+		typ, err := quicvarint.Read(c.br)
+		if err != nil {
+			return 0, nil, err
+		}
+		length, err := quicvarint.Read(c.br)
+		if err != nil {
+			return 0, nil, err
+		}
+		if length > maxCapsule {
+			return 0, nil, errors.New("connectudp: capsule too large")
+		}
+		if typ != datagramCapsule || length > uint64(len(c.rbuf)) {
+			if _, err := c.br.Discard(int(length)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		payload := c.rbuf[:length]
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+		contextID, n, err := quicvarint.Parse(payload)
+		if err != nil || contextID != 0 {
+			continue
+		}
+		return copy(p, payload[n:]), c.target, nil
+	}
+}
+
+// WriteTo sends p to the target of the tunnel in a DATAGRAM capsule.
+func (c *Conn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	buf := c.wbuf[:0]
+	buf = quicvarint.Append(buf, datagramCapsule)
+	buf = quicvarint.Append(buf, uint64(len(p))+1)
+	buf = quicvarint.Append(buf, 0) // context ID of UDP payloads
+	buf = append(buf, p...)
+	c.wbuf = buf
+
+	if _, err := c.conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// RemoteAddr returns the address of the target of the tunnel.
+func (c *Conn) RemoteAddr() net.Addr { return c.target }
+
+// Close closes the tunnel.
+func (c *Conn) Close() error { return c.conn.Close() }
+
+// LocalAddr returns the local address of the connection to the proxy.
+func (c *Conn) LocalAddr() net.Addr { return c.conn.LocalAddr() }
+
+// SetDeadline implements net.PacketConn.
+func (c *Conn) SetDeadline(t time.Time) error { return c.conn.SetDeadline(t) }
+
+// SetReadDeadline implements net.PacketConn.
+func (c *Conn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+// SetWriteDeadline implements net.PacketConn.
+func (c *Conn) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
+
+// SetReadBuffer does nothing: packets are buffered by the connection to the
+// proxy. It keeps quic-go from warning about the buffer size.
+func (c *Conn) SetReadBuffer(int) error { return nil }
+
+// SetWriteBuffer does nothing, as SetReadBuffer.
+func (c *Conn) SetWriteBuffer(int) error { return nil }
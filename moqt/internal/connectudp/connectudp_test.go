@@ -0,0 +1,291 @@
+package connectudp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/quicvarint"
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProxy is a CONNECT-UDP proxy relaying the tunnels it accepts to the
+// UDP targets of their requests.
+type testProxy struct {
+	ln       net.Listener
+	status   int // status of refused tunnels, or zero
+	requests chan *http.Request
+}
+
+func startProxy(t *testing.T, status int) *testProxy {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := &testProxy{ln: ln, status: status, requests: make(chan *http.Request, 4)}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *testProxy) url() *url.URL {
+	return &url.URL{Scheme: "http", Host: p.ln.Addr().String()}
+}
+
+func (p *testProxy) serve(conn net.Conn) {
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return
+	}
+	p.requests <- req
+
+	if p.status != 0 {
+		_, _ = fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Length: 0\r\n\r\n", p.status, http.StatusText(p.status))
+		return
+	}
+
+	segments := strings.Split(strings.Trim(req.URL.EscapedPath(), "/"), "/")
+	host, _ := url.QueryUnescape(segments[len(segments)-2])
+	port, _ := url.QueryUnescape(segments[len(segments)-1])
+	udp, err := net.Dial("udp", net.JoinHostPort(host, port))
+	if err != nil {
+		return
+	}
+	defer udp.Close()
+
+	_, _ = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: connect-udp\r\nCapsule-Protocol: ?1\r\n\r\n")
+
+	// A capsule of an unknown type, which clients must skip.
+	_, _ = conn.Write([]byte{0x17, 0x02, 0xaa, 0xbb})
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, err := udp.Read(buf)
+			if err != nil {
+				return
+			}
+			capsule := quicvarint.Append(nil, datagramCapsule)
+			capsule = quicvarint.Append(capsule, uint64(n)+1)
+			capsule = quicvarint.Append(capsule, 0)
+			if _, err := conn.Write(append(capsule, buf[:n]...)); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		typ, err := quicvarint.Read(br)
+		if err != nil {
+			return
+		}
+		length, err := quicvarint.Read(br)
+		if err != nil {
+			return
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return
+		}
+		if typ != datagramCapsule || payload[0] != 0 {
+			continue
+		}
+		_, _ = udp.Write(payload[1:])
+	}
+}
+
+func startEcho(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDial(t *testing.T) {
+	proxy := startProxy(t, 0)
+	target := startEcho(t)
+
+	u := proxy.url()
+	u.User = url.UserPassword("alice", "secret")
+	conn, err := Dial(t.Context(), u, target, nil, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	req := <-proxy.requests
+	assert.Equal(t, http.MethodGet, req.Method)
+	assert.Equal(t, "connect-udp", req.Header.Get("Upgrade"))
+	assert.Equal(t, "?1", req.Header.Get("Capsule-Protocol"))
+	assert.Equal(t, "Basic YWxpY2U6c2VjcmV0", req.Header.Get("Proxy-Authorization"))
+	host, port, _ := net.SplitHostPort(target)
+	assert.Equal(t, "/.well-known/masque/udp/"+host+"/"+port+"/", req.URL.Path)
+
+	assert.Equal(t, target, conn.RemoteAddr().String())
+	assert.Equal(t, "udp", conn.RemoteAddr().Network())
+
+	for _, msg := range []string{"hello", "world"} {
+		n, err := conn.WriteTo([]byte(msg), nil)
+		require.NoError(t, err)
+		assert.Equal(t, len(msg), n)
+
+		buf := make([]byte, 64)
+		n, addr, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(buf[:n]))
+		assert.Equal(t, target, addr.String())
+	}
+}
+
+func TestDial_Refused(t *testing.T) {
+	proxy := startProxy(t, http.StatusProxyAuthRequired)
+
+	_, err := Dial(t.Context(), proxy.url(), "example.com:443", nil, nil)
+	var proxyErr *ProxyError
+	require.ErrorAs(t, err, &proxyErr)
+	assert.Equal(t, http.StatusProxyAuthRequired, proxyErr.StatusCode)
+}
+
+func TestDial_Errors(t *testing.T) {
+	tests := map[string]struct {
+		proxy  string
+		target string
+	}{
+		"missing port":      {proxy: "http://127.0.0.1:1", target: "example.com"},
+		"unsupported proxy": {proxy: "socks5://127.0.0.1:1080", target: "example.com:443"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, err := url.Parse(tt.proxy)
+			require.NoError(t, err)
+			_, err = Dial(t.Context(), u, tt.target, nil, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDial_DialFunc(t *testing.T) {
+	errDial := errors.New("blocked")
+	var dialed string
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = network + " " + addr
+		return nil, errDial
+	}
+
+	u, err := url.Parse("https://proxy.example.com")
+	require.NoError(t, err)
+	_, err = Dial(t.Context(), u, "relay.example.com:443", dial, nil)
+	assert.ErrorIs(t, err, errDial)
+	assert.Equal(t, "tcp proxy.example.com:443", dialed)
+}
+
+func TestDial_ContextCanceled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		// Accept but never answer.
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			_, _ = io.Copy(io.Discard, conn)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err = Dial(ctx, &url.URL{Scheme: "http", Host: ln.Addr().String()}, "example.com:443", nil, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestExpandTemplate(t *testing.T) {
+	tests := map[string]struct {
+		template string
+		host     string
+		want     string
+	}{
+		"default":  {template: "", host: "example.com", want: "/.well-known/masque/udp/example.com/443/"},
+		"root":     {template: "/", host: "192.0.2.6", want: "/.well-known/masque/udp/192.0.2.6/443/"},
+		"ipv6":     {template: "", host: "2001:db8::1", want: "/.well-known/masque/udp/2001%3Adb8%3A%3A1/443/"},
+		"template": {template: "/udp/{target_host}:{target_port}", host: "example.com", want: "/udp/example.com:443"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExpandTemplate(tt.template, tt.host, "443"))
+		})
+	}
+}
+
+func TestConn_QUIC(t *testing.T) {
+	proxy := startProxy(t, 0)
+
+	ln, err := quic.ListenAddr("127.0.0.1:0", testutil.TLSConfig(t, "test"), nil)
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(stream, stream)
+		_ = stream.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	pconn, err := Dial(ctx, proxy.url(), ln.Addr().String(), nil, nil)
+	require.NoError(t, err)
+	defer pconn.Close()
+
+	conn, err := quic.Dial(ctx, pconn, pconn.RemoteAddr(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"test"}}, nil)
+	require.NoError(t, err)
+	defer conn.CloseWithError(0, "")
+
+	stream, err := conn.OpenStreamSync(ctx)
+	require.NoError(t, err)
+	_, err = stream.Write([]byte("through the proxy"))
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	got, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "through the proxy", string(got))
+}
@@ -1,7 +1,10 @@
 package message
 
 import (
+	"errors"
 	"io"
+	"maps"
+	"slices"
 )
 
 const (
@@ -19,8 +22,90 @@ type AnnounceMessage struct {
 	AnnounceStatus      AnnounceStatus
 	BroadcastPathSuffix string
 	HopIDs              []uint64
+	// BroadcastID is the optional 16-byte global identifier of the
+	// broadcast, encoded as a trailing field and omitted when empty. Like
+	// Tracks, it is an extension, encoded only in VersionLite04Ext.
+	BroadcastID []byte
+	// Tracks optionally declares metadata of tracks of the broadcast. It
+	// follows BroadcastID, which is encoded empty if only Tracks is set, and
+	// is omitted when empty.
+	Tracks []TrackDescriptor
+
+	// Version is the encoding of the message. It is not encoded.
+	Version Version
 }
 
+// TrackDescriptor declares metadata of a track as parameters. Receivers
+// ignore parameters with unknown keys.
+type TrackDescriptor struct {
+	TrackName  string
+	Parameters map[uint64]uint64
+}
+
+// Keys of TrackDescriptor parameters.
+const (
+	TrackParameterBitrate          uint64 = 0x01 // bits per second
+	TrackParameterWidth            uint64 = 0x02 // pixels
+	TrackParameterHeight           uint64 = 0x03 // pixels
+	TrackParameterKeyframeInterval uint64 = 0x04 // milliseconds
+)
+
+func (td TrackDescriptor) len() int {
+	l := StringLen(td.TrackName)
+	l += VarintLen(uint64(len(td.Parameters)))
+	for k, v := range td.Parameters {
+		l += VarintLen(k) + VarintLen(v)
+	}
+	return l
+}
+
+func (td TrackDescriptor) append(b []byte) []byte {
+	b, _ = WriteString(b, td.TrackName)
+	b, _ = WriteVarint(b, uint64(len(td.Parameters)))
+	for _, k := range slices.Sorted(maps.Keys(td.Parameters)) {
+		b, _ = WriteVarint(b, k)
+		b, _ = WriteVarint(b, td.Parameters[k])
+	}
+	return b
+}
+
+func (td *TrackDescriptor) read(b []byte) (int, error) {
+	var total int
+	name, n, err := ReadString(b)
+	if err != nil {
+		return 0, err
+	}
+	td.TrackName = name
+	total += n
+
+	count, n, err := ReadVarint(b[total:])
+	if err != nil {
+		return 0, err
+	}
+	total += n
+
+	td.Parameters = make(map[uint64]uint64, min(count, 16))
+	for range count {
+		k, n, err := ReadVarint(b[total:])
+		if err != nil {
+			return 0, err
+		}
+		total += n
+		v, n, err := ReadVarint(b[total:])
+		if err != nil {
+			return 0, err
+		}
+		total += n
+		td.Parameters[k] = v
+	}
+	return total, nil
+}
+
+// BroadcastIDLen is the length of a non-empty BroadcastID.
+const BroadcastIDLen = 16
+
+var ErrInvalidBroadcastID = errors.New("invalid broadcast ID length")
+
 func (am AnnounceMessage) Len() int {
 	var l int
 
@@ -30,6 +115,18 @@ func (am AnnounceMessage) Len() int {
 	for _, id := range am.HopIDs {
 		l += VarintLen(id)
 	}
+	if !am.Version.Extended() {
+		return l
+	}
+	if len(am.BroadcastID) != 0 || len(am.Tracks) != 0 {
+		l += BytesLen(am.BroadcastID)
+	}
+	if len(am.Tracks) != 0 {
+		l += VarintLen(uint64(len(am.Tracks)))
+		for _, td := range am.Tracks {
+			l += td.len()
+		}
+	}
 
 	return l
 }
@@ -46,6 +143,17 @@ func (am AnnounceMessage) Encode(w io.Writer) error {
 	for _, id := range am.HopIDs {
 		b, _ = WriteVarint(b, id)
 	}
+	if am.Version.Extended() {
+		if len(am.BroadcastID) != 0 || len(am.Tracks) != 0 {
+			b, _ = WriteBytes(b, am.BroadcastID)
+		}
+		if len(am.Tracks) != 0 {
+			b, _ = WriteVarint(b, uint64(len(am.Tracks)))
+			for _, td := range am.Tracks {
+				b = td.append(b)
+			}
+		}
+	}
 
 	_, err := w.Write(b)
 
@@ -95,6 +203,42 @@ func (am *AnnounceMessage) Decode(src io.Reader) error {
 		b = b[n:]
 	}
 
+	am.BroadcastID = nil
+	if am.Version.Extended() && len(b) != 0 {
+		id, n, err := ReadBytes(b)
+		if err != nil {
+			return err
+		}
+		switch len(id) {
+		case 0:
+		case BroadcastIDLen:
+			am.BroadcastID = id
+		default:
+			return ErrInvalidBroadcastID
+		}
+		b = b[n:]
+	}
+
+	am.Tracks = nil
+	if am.Version.Extended() && len(b) != 0 {
+		count, n, err := ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+
+		am.Tracks = make([]TrackDescriptor, 0, min(count, 64))
+		for range count {
+			var td TrackDescriptor
+			n, err := td.read(b)
+			if err != nil {
+				return err
+			}
+			am.Tracks = append(am.Tracks, td)
+			b = b[n:]
+		}
+	}
+
 	if len(b) != 0 {
 		return ErrMessageTooShort
 	}
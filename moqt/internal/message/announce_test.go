@@ -42,6 +42,47 @@ func TestAnnounceMessage_EncodeDecode(t *testing.T) {
 				HopIDs:              []uint64{100, 200},
 			},
 		},
+		"with broadcast id": {
+			input: message.AnnounceMessage{
+				AnnounceStatus:      message.ACTIVE,
+				BroadcastPathSuffix: "test",
+				HopIDs:              []uint64{1},
+				BroadcastID:         bytes.Repeat([]byte{0xab}, message.BroadcastIDLen),
+				Version:             message.VersionLite04Ext,
+			},
+		},
+		"with tracks": {
+			input: message.AnnounceMessage{
+				AnnounceStatus:      message.ACTIVE,
+				BroadcastPathSuffix: "test",
+				HopIDs:              []uint64{1},
+				BroadcastID:         bytes.Repeat([]byte{0xab}, message.BroadcastIDLen),
+				Tracks: []message.TrackDescriptor{
+					{
+						TrackName: "video/720p",
+						Parameters: map[uint64]uint64{
+							message.TrackParameterBitrate:          2_500_000,
+							message.TrackParameterWidth:            1280,
+							message.TrackParameterHeight:           720,
+							message.TrackParameterKeyframeInterval: 2000,
+						},
+					},
+					{TrackName: "audio", Parameters: map[uint64]uint64{}},
+				},
+				Version: message.VersionLite04Ext,
+			},
+		},
+		"with tracks without broadcast id": {
+			input: message.AnnounceMessage{
+				AnnounceStatus:      message.ACTIVE,
+				BroadcastPathSuffix: "test",
+				HopIDs:              []uint64{},
+				Tracks: []message.TrackDescriptor{
+					{TrackName: "video", Parameters: map[uint64]uint64{message.TrackParameterBitrate: 1000}},
+				},
+				Version: message.VersionLite04Ext,
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -57,7 +98,7 @@ func TestAnnounceMessage_EncodeDecode(t *testing.T) {
 			require.NoError(t, err)
 
 			// Decode
-			var decoded message.AnnounceMessage
+			decoded := message.AnnounceMessage{Version: tc.input.Version}
 			err = decoded.Decode(&buf)
 			require.NoError(t, err)
 
@@ -113,11 +154,14 @@ func TestAnnounceMessage_DecodeErrors(t *testing.T) {
 		var am message.AnnounceMessage
 		// Manually construct data with extra bytes after valid data
 		var buf bytes.Buffer
-		buf.WriteByte(0x05) // length varint = 5
+		buf.WriteByte(0x17) // length varint = 23
 		buf.WriteByte(0x01) // status
 		buf.WriteByte(0x01) // string length 1
 		buf.WriteByte('a')  // string
 		buf.WriteByte(0x00) // hops
+		buf.WriteByte(0x10) // broadcast id length 16
+		buf.Write(make([]byte, 16))
+		buf.WriteByte(0x00) // track count
 		buf.WriteByte(0x00) // extra byte
 		src := bytes.NewReader(buf.Bytes())
 		err := am.Decode(src)
@@ -125,3 +169,77 @@ func TestAnnounceMessage_DecodeErrors(t *testing.T) {
 		assert.Equal(t, message.ErrMessageTooShort, err)
 	})
 }
+
+func TestAnnounceMessage_DecodeInvalidBroadcastID(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.AnnounceMessage{
+		AnnounceStatus:      message.ACTIVE,
+		BroadcastPathSuffix: "test",
+		HopIDs:              []uint64{},
+		BroadcastID:         []byte{1, 2, 3},
+		Version:             message.VersionLite04Ext,
+	}.Encode(&buf))
+
+	am := message.AnnounceMessage{Version: message.VersionLite04Ext}
+	assert.ErrorIs(t, am.Decode(&buf), message.ErrInvalidBroadcastID)
+}
+
+func TestAnnounceMessage_UnknownTrackParameter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.AnnounceMessage{
+		AnnounceStatus:      message.ACTIVE,
+		BroadcastPathSuffix: "test",
+		HopIDs:              []uint64{},
+		Tracks: []message.TrackDescriptor{
+			{TrackName: "video", Parameters: map[uint64]uint64{0x3f: 7}},
+		},
+		Version: message.VersionLite04Ext,
+	}.Encode(&buf))
+
+	am := message.AnnounceMessage{Version: message.VersionLite04Ext}
+	require.NoError(t, am.Decode(&buf))
+	assert.Nil(t, am.BroadcastID)
+	require.Len(t, am.Tracks, 1)
+	assert.Equal(t, uint64(7), am.Tracks[0].Parameters[0x3f])
+}
+
+func TestAnnounceMessage_DecodeTruncatedTracks(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.AnnounceMessage{
+		AnnounceStatus:      message.ACTIVE,
+		BroadcastPathSuffix: "test",
+		HopIDs:              []uint64{},
+		Tracks: []message.TrackDescriptor{
+			{TrackName: "video", Parameters: map[uint64]uint64{message.TrackParameterWidth: 640}},
+		},
+		Version: message.VersionLite04Ext,
+	}.Encode(&buf))
+
+	// Drop the last parameter value and fix up the message length.
+	b := buf.Bytes()
+	b = b[:len(b)-2]
+	b[0] -= 2
+
+	am := message.AnnounceMessage{Version: message.VersionLite04Ext}
+	assert.Error(t, am.Decode(bytes.NewReader(b)))
+}
+
+func TestAnnounceMessage_Lite04(t *testing.T) {
+	msg := message.AnnounceMessage{
+		AnnounceStatus:      message.ACTIVE,
+		BroadcastPathSuffix: "a",
+		HopIDs:              []uint64{},
+		BroadcastID:         bytes.Repeat([]byte{0xab}, message.BroadcastIDLen),
+		Tracks:              []message.TrackDescriptor{{TrackName: "video", Parameters: map[uint64]uint64{}}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, msg.Encode(&buf))
+	// Length, status, broadcast path suffix and hop count.
+	assert.Equal(t, []byte{0x04, 0x01, 0x01, 'a', 0x00}, buf.Bytes(), "extensions must not be encoded")
+
+	var ext bytes.Buffer
+	msg.Version = message.VersionLite04Ext
+	require.NoError(t, msg.Encode(&ext))
+	var lite04 message.AnnounceMessage
+	assert.ErrorIs(t, lite04.Decode(&ext), message.ErrMessageTooShort)
+}
@@ -0,0 +1,59 @@
+package message
+
+/*
+ * MOQ Lite frames have no header fields besides their length, so the
+ * extension headers of a frame are carried in its payload:
+ *
+ * Frame {
+ *   Length (varint),
+ *   [Extension Headers (..),]
+ *   Payload (..),
+ * }
+ *
+ * Extension Headers are present in the frames of a group whose header has
+ * GroupFlagExtensionHeaders set, and in fetched groups the reader was told
+ * to expect them of.
+ *
+ * A checksum and a schema ID are Extension Headers too. A checksum holds
+ * the 32-bit CRC-32C of the Payload, and a schema ID is a varint.
+ *
+ * Extension Headers {
+ *   Count (varint),
+ *   Header (..) ...,
+ * }
+ *
+ * Header {
+ *   Type (varint),
+ *   Value Length (varint),
+ *   Value (..),
+ * }
+ */
+
+// ExtensionHeaders are the extension headers of an object, keyed by type.
+// They are encoded as parameters, sorted by type.
+type ExtensionHeaders map[uint64][]byte
+
+// Len returns the length of the encoded headers.
+func (h ExtensionHeaders) Len() int {
+	return ParametersLen(h)
+}
+
+// Append appends the encoded headers to b.
+func (h ExtensionHeaders) Append(b []byte) []byte {
+	b, _ = WriteParameters(b, h)
+	return b
+}
+
+// ReadExtensionHeaders decodes the headers at the start of b and returns
+// them with the number of bytes read. A repeated type returns
+// ErrDuplicateParameter. Empty headers are returned as nil.
+func ReadExtensionHeaders(b []byte) (ExtensionHeaders, int, error) {
+	params, n, err := ReadParameters(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(params) == 0 {
+		return nil, n, nil
+	}
+	return ExtensionHeaders(params), n, nil
+}
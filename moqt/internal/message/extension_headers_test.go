@@ -0,0 +1,66 @@
+package message_test
+
+import (
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtensionHeaders_AppendRead(t *testing.T) {
+	tests := map[string]struct {
+		input message.ExtensionHeaders
+		wire  []byte
+	}{
+		"empty": {
+			input: nil,
+			wire:  []byte{0x00},
+		},
+		"sorted by type": {
+			input: message.ExtensionHeaders{0x40: []byte{0x2a}, 0x02: []byte("k1")},
+			wire:  []byte{0x02, 0x02, 0x02, 'k', '1', 0x40, 0x40, 0x01, 0x2a},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := tt.input.Append([]byte{0xff})
+			assert.Equal(t, append([]byte{0xff}, tt.wire...), b)
+			assert.Equal(t, len(tt.wire), tt.input.Len())
+
+			got, n, err := message.ReadExtensionHeaders(append(tt.wire, 0x99))
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.wire), n)
+			assert.Equal(t, tt.input, got)
+		})
+	}
+}
+
+func TestReadExtensionHeaders_Malformed(t *testing.T) {
+	tests := map[string]struct {
+		input   []byte
+		wantErr error
+	}{
+		"duplicate type": {
+			input:   []byte{0x02, 0x01, 0x00, 0x01, 0x00},
+			wantErr: message.ErrDuplicateParameter,
+		},
+		"truncated value": {
+			input: []byte{0x01, 0x01, 0x05, 0x00},
+		},
+		"missing count": {
+			input: []byte{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := message.ReadExtensionHeaders(tt.input)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -8,13 +8,50 @@ import (
 type GroupMessage struct {
 	SubscribeID   uint64
 	GroupSequence uint64
+
+	// SubgroupID identifies the subgroup carried by the stream. It is an
+	// optional trailing field, omitted when zero, so that group streams
+	// without subgroups keep their encoding. Like Timestamp and Flags, it is
+	// an extension, encoded only in VersionLite04Ext.
+	SubgroupID uint64
+
+	// Timestamp is the time of the group declared by the publisher, in
+	// microseconds since the Unix epoch. It is an optional trailing field,
+	// omitted when zero; SubgroupID is written whenever it is present.
+	Timestamp uint64
+
+	// Flags describe the encoding of the frames of the group, as a set of
+	// GroupFlag bits. It is an optional trailing field, omitted when zero;
+	// SubgroupID and Timestamp are written whenever it is present.
+	Flags uint64
+
+	// Version is the encoding of the message. It is not encoded.
+	Version Version
 }
 
+const (
+	// GroupFlagExtensionHeaders marks a group whose frames start with an
+	// extension header block; see ExtensionHeaders.
+	GroupFlagExtensionHeaders uint64 = 1 << 0
+)
+
 func (g GroupMessage) Len() int {
 	var l int
 
 	l += VarintLen(uint64(g.SubscribeID))
 	l += VarintLen(uint64(g.GroupSequence))
+	if !g.Version.Extended() {
+		return l
+	}
+	if g.SubgroupID != 0 || g.Timestamp != 0 || g.Flags != 0 {
+		l += VarintLen(g.SubgroupID)
+	}
+	if g.Timestamp != 0 || g.Flags != 0 {
+		l += VarintLen(g.Timestamp)
+	}
+	if g.Flags != 0 {
+		l += VarintLen(g.Flags)
+	}
 
 	return l
 }
@@ -26,6 +63,17 @@ func (g GroupMessage) Encode(w io.Writer) error {
 	b, _ = WriteMessageLength(b, uint64(msgLen))
 	b, _ = WriteVarint(b, g.SubscribeID)
 	b, _ = WriteVarint(b, g.GroupSequence)
+	if g.Version.Extended() {
+		if g.SubgroupID != 0 || g.Timestamp != 0 || g.Flags != 0 {
+			b, _ = WriteVarint(b, g.SubgroupID)
+		}
+		if g.Timestamp != 0 || g.Flags != 0 {
+			b, _ = WriteVarint(b, g.Timestamp)
+		}
+		if g.Flags != 0 {
+			b, _ = WriteVarint(b, g.Flags)
+		}
+	}
 
 	_, err := w.Write(b)
 
@@ -59,6 +107,36 @@ func (g *GroupMessage) Decode(src io.Reader) error {
 	g.GroupSequence = num
 	b = b[n:]
 
+	g.SubgroupID = 0
+	if g.Version.Extended() && len(b) > 0 {
+		num, n, err = ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		g.SubgroupID = num
+		b = b[n:]
+	}
+
+	g.Timestamp = 0
+	if g.Version.Extended() && len(b) > 0 {
+		num, n, err = ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		g.Timestamp = num
+		b = b[n:]
+	}
+
+	g.Flags = 0
+	if g.Version.Extended() && len(b) > 0 {
+		num, n, err = ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		g.Flags = num
+		b = b[n:]
+	}
+
 	if len(b) != 0 {
 		return ErrMessageTooShort
 	}
@@ -33,6 +33,39 @@ func TestGroupMessage_EncodeDecode(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		"with subgroup": {
+			input: message.GroupMessage{
+				SubscribeID:   1,
+				GroupSequence: 2,
+				SubgroupID:    3,
+				Version:       message.VersionLite04Ext,
+			},
+		},
+		"with timestamp": {
+			input: message.GroupMessage{
+				SubscribeID:   1,
+				GroupSequence: 2,
+				Timestamp:     1_700_000_000_000_000,
+				Version:       message.VersionLite04Ext,
+			},
+		},
+		"with subgroup and timestamp": {
+			input: message.GroupMessage{
+				SubscribeID:   1,
+				GroupSequence: 2,
+				SubgroupID:    3,
+				Timestamp:     1_700_000_000_000_000,
+				Version:       message.VersionLite04Ext,
+			},
+		},
+		"with flags": {
+			input: message.GroupMessage{
+				SubscribeID:   1,
+				GroupSequence: 2,
+				Flags:         message.GroupFlagExtensionHeaders,
+				Version:       message.VersionLite04Ext,
+			},
+		},
 		"zero values": {
 			input: message.GroupMessage{
 				SubscribeID:   0,
@@ -70,7 +103,7 @@ func TestGroupMessage_EncodeDecode(t *testing.T) {
 			}
 
 			// Decode
-			var decoded message.GroupMessage
+			decoded := message.GroupMessage{Version: tc.input.Version}
 			err := decoded.Decode(&buf)
 			require.NoError(t, err)
 
@@ -121,16 +154,49 @@ func TestGroupMessage_DecodeErrors(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("read varint error for subgroup id", func(t *testing.T) {
+		// Only the extended version carries the subgroup id.
+		g := message.GroupMessage{Version: message.VersionLite04Ext}
+		var buf bytes.Buffer
+		buf.WriteByte(0x03) // length varint = 3
+		buf.WriteByte(0x01) // subscribe id
+		buf.WriteByte(0x01) // group sequence
+		buf.WriteByte(0x40) // truncated varint for subgroup id
+		src := bytes.NewReader(buf.Bytes())
+		err := g.Decode(src)
+		assert.Error(t, err)
+	})
+
 	t.Run("extra data", func(t *testing.T) {
 		var g message.GroupMessage
 		var buf bytes.Buffer
-		buf.WriteByte(0x03) // length varint = 3
+		buf.WriteByte(0x06) // length varint = 6
 		buf.WriteByte(0x01) // subscribe id
 		buf.WriteByte(0x01) // group sequence
-		buf.WriteByte(0x00) // extra (fills to 3 bytes)
+		buf.WriteByte(0x02) // subgroup id
+		buf.WriteByte(0x03) // timestamp
+		buf.WriteByte(0x01) // flags
+		buf.WriteByte(0x00) // extra (fills to 6 bytes)
 		src := bytes.NewReader(buf.Bytes())
 		err := g.Decode(src)
 		assert.Error(t, err)
 		assert.Equal(t, message.ErrMessageTooShort, err)
 	})
 }
+
+func TestGroupMessage_SubgroupOmittedWhenZero(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 1, Version: message.VersionLite04Ext}.Encode(&buf))
+	assert.Equal(t, []byte{0x02, 0x01, 0x01}, buf.Bytes())
+}
+
+func TestGroupMessage_Lite04(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 1, SubgroupID: 2, Timestamp: 3, Flags: message.GroupFlagExtensionHeaders}.Encode(&buf))
+	assert.Equal(t, []byte{0x02, 0x01, 0x01}, buf.Bytes(), "extensions must not be encoded")
+
+	var ext bytes.Buffer
+	require.NoError(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 1, SubgroupID: 2, Version: message.VersionLite04Ext}.Encode(&ext))
+	var lite04 message.GroupMessage
+	assert.ErrorIs(t, lite04.Decode(&ext), message.ErrMessageTooShort)
+}
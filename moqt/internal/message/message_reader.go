@@ -1,6 +1,7 @@
 package message
 
 import (
+	"bytes"
 	"io"
 	"math"
 )
@@ -84,6 +85,40 @@ func ReadString(b []byte) (string, int, error) {
 	return string(str), n, nil
 }
 
+// ReadParameters reads parameters written by WriteParameters. Their values
+// are copied out of b.
+func ReadParameters(b []byte) (Parameters, int, error) {
+	count, total, err := ReadVarint(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	b = b[total:]
+
+	params := make(Parameters, min(count, 16))
+	for range count {
+		key, n, err := ReadVarint(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		b = b[n:]
+		total += n
+
+		value, n, err := ReadBytes(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		b = b[n:]
+		total += n
+
+		if _, ok := params[key]; ok {
+			return nil, 0, ErrDuplicateParameter
+		}
+		params[key] = bytes.Clone(value)
+	}
+
+	return params, total, nil
+}
+
 func ReadStringArray(b []byte) ([]string, int, error) {
 	count, total, err := ReadVarint(b)
 	if err != nil {
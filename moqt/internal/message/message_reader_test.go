@@ -2,6 +2,7 @@ package message
 
 import (
 	"bytes"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -287,3 +288,48 @@ func TestReadStringArray(t *testing.T) {
 		})
 	}
 }
+
+func TestReadParameters(t *testing.T) {
+	tests := map[string]struct {
+		input    []byte
+		expected Parameters
+		n        int
+		wantErr  error
+	}{
+		"no parameters": {
+			input:    []byte{0x00},
+			expected: Parameters{},
+			n:        1,
+		},
+		"parameters": {
+			input:    []byte{0x02, 0x01, 0x02, 0x68, 0x64, 0x21, 0x00}, // {1: "hd", 0x21: ""}
+			expected: Parameters{0x01: []byte("hd"), 0x21: {}},
+			n:        7,
+		},
+		"duplicate key": {
+			input:   []byte{0x02, 0x01, 0x00, 0x01, 0x00},
+			wantErr: ErrDuplicateParameter,
+		},
+		"incomplete value": {
+			input:   []byte{0x01, 0x01, 0x02, 0x68},
+			wantErr: io.EOF,
+		},
+		"invalid count": {
+			input:   []byte{},
+			wantErr: io.EOF,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, n, err := ReadParameters(tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.n, n)
+		})
+	}
+}
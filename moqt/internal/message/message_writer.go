@@ -2,6 +2,8 @@ package message
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 )
 
 func WriteVarint(b []byte, i uint64) ([]byte, int) {
@@ -64,10 +66,10 @@ func WriteStringArray(dest []byte, arr []string) ([]byte, int) {
 func WriteParameters(dest []byte, params map[uint64][]byte) ([]byte, int) {
 	dest, n := WriteVarint(dest, uint64(len(params)))
 	var m int
-	for key, value := range params {
-		dest, m = WriteVarint(dest, uint64(key))
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		dest, m = WriteVarint(dest, key)
 		n += m
-		dest, m = WriteBytes(dest, value)
+		dest, m = WriteBytes(dest, params[key])
 		n += m
 	}
 	return dest, n
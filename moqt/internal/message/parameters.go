@@ -1,6 +1,11 @@
 package message
 
+import "errors"
+
 /*
 * Parameters
  */
 type Parameters map[uint64][]byte
+
+// ErrDuplicateParameter is returned when parameters repeat a key.
+var ErrDuplicateParameter = errors.New("duplicate parameter")
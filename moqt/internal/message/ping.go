@@ -0,0 +1,61 @@
+package message
+
+import (
+	"io"
+)
+
+/*
+ *	PING Message {
+ *	  Message Length (i)
+ *	  Sequence (i)
+ *	}
+ */
+
+// PingMessage is sent on the Ping stream (0x8). The opener of the stream
+// sends a PING at each keep-alive interval and the peer echoes it back with
+// the same sequence.
+type PingMessage struct {
+	Sequence uint64
+}
+
+func (pm PingMessage) Len() int {
+	return VarintLen(pm.Sequence)
+}
+
+func (pm PingMessage) Encode(w io.Writer) error {
+	msgLen := pm.Len()
+	b := make([]byte, 0, msgLen+VarintLen(uint64(msgLen)))
+
+	b, _ = WriteMessageLength(b, uint64(msgLen))
+	b, _ = WriteVarint(b, pm.Sequence)
+
+	_, err := w.Write(b)
+	return err
+}
+
+func (pm *PingMessage) Decode(src io.Reader) error {
+	size, err := ReadMessageLength(src)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, size)
+
+	_, err = io.ReadFull(src, b)
+	if err != nil {
+		return err
+	}
+
+	num, n, err := ReadVarint(b)
+	if err != nil {
+		return err
+	}
+	pm.Sequence = num
+	b = b[n:]
+
+	if len(b) != 0 {
+		return ErrMessageTooShort
+	}
+
+	return nil
+}
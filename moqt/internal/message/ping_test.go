@@ -0,0 +1,70 @@
+package message_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPingMessage_EncodeDecode(t *testing.T) {
+	tests := map[string]struct {
+		input message.PingMessage
+	}{
+		"zero_sequence": {
+			input: message.PingMessage{Sequence: 0},
+		},
+		"small_sequence": {
+			input: message.PingMessage{Sequence: 42},
+		},
+		"large_sequence": {
+			input: message.PingMessage{Sequence: 1 << 40},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+
+			err := tc.input.Encode(&buf)
+			require.NoError(t, err)
+
+			var decoded message.PingMessage
+			err = decoded.Decode(&buf)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.input, decoded, "decoded message should match input")
+		})
+	}
+}
+
+func TestPingMessage_DecodeErrors(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+	}{
+		"empty_reader": {
+			data: []byte{},
+		},
+		"truncated_length": {
+			data: []byte{0xff},
+		},
+		"truncated_sequence": {
+			data: []byte{0x02, 0x40}, // length=2 but only one byte follows
+		},
+		"extra_data": {
+			data: []byte{0x02, 0x01, 0xff}, // length=2, sequence=1, extra=0xff
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var pm message.PingMessage
+			err := pm.Decode(bytes.NewReader(tc.data))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -12,11 +12,17 @@ type ProbeMessage struct {
 	// A value of 0 means unknown.
 	Bitrate uint64
 	// RTT is the smoothed round-trip time in milliseconds.
-	// A value of 0 means unknown.
+	// A value of 0 means unknown. It is not encoded in VersionLite03.
 	RTT uint64
+
+	// Version is the encoding of the message. It is not encoded.
+	Version Version
 }
 
 func (pm ProbeMessage) Len() int {
+	if pm.Version == VersionLite03 {
+		return VarintLen(pm.Bitrate)
+	}
 	return VarintLen(pm.Bitrate) + VarintLen(pm.RTT)
 }
 
@@ -26,7 +32,9 @@ func (pm ProbeMessage) Encode(w io.Writer) error {
 
 	b, _ = WriteMessageLength(b, uint64(msgLen))
 	b, _ = WriteVarint(b, pm.Bitrate)
-	b, _ = WriteVarint(b, pm.RTT)
+	if pm.Version != VersionLite03 {
+		b, _ = WriteVarint(b, pm.RTT)
+	}
 
 	_, err := w.Write(b)
 	return err
@@ -52,12 +60,15 @@ func (pm *ProbeMessage) Decode(src io.Reader) error {
 	pm.Bitrate = num
 	b = b[n:]
 
-	num, n, err = ReadVarint(b)
-	if err != nil {
-		return err
+	pm.RTT = 0
+	if pm.Version != VersionLite03 {
+		num, n, err = ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		pm.RTT = num
+		b = b[n:]
 	}
-	pm.RTT = num
-	b = b[n:]
 
 	if len(b) != 0 {
 		return ErrMessageTooShort
@@ -26,6 +26,9 @@ func TestProbeMessage_EncodeDecode(t *testing.T) {
 		"zero_rtt": {
 			input: message.ProbeMessage{Bitrate: 5000, RTT: 0},
 		},
+		"lite03": {
+			input: message.ProbeMessage{Bitrate: 5000, Version: message.VersionLite03},
+		},
 	}
 
 	for name, tc := range tests {
@@ -42,7 +45,7 @@ func TestProbeMessage_EncodeDecode(t *testing.T) {
 			require.NoError(t, err)
 
 			// Decode
-			var decoded message.ProbeMessage
+			decoded := message.ProbeMessage{Version: tc.input.Version}
 			err = decoded.Decode(&buf)
 			require.NoError(t, err)
 
@@ -89,3 +92,18 @@ func TestProbeMessage_DecodeErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestProbeMessage_Lite03(t *testing.T) {
+	var buf bytes.Buffer
+	err := message.ProbeMessage{Bitrate: 1000, RTT: 50, Version: message.VersionLite03}.Encode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x02, 0x43, 0xe8}, buf.Bytes(), "RTT must not be encoded")
+
+	var lite04 message.ProbeMessage
+	assert.Error(t, lite04.Decode(bytes.NewReader(buf.Bytes())))
+
+	lite03 := message.ProbeMessage{RTT: 50, Version: message.VersionLite03}
+	require.NoError(t, lite03.Decode(bytes.NewReader(buf.Bytes())))
+	assert.Equal(t, uint64(1000), lite03.Bitrate)
+	assert.Zero(t, lite03.RTT)
+}
@@ -6,11 +6,14 @@ import (
 
 const (
 	// Bi-directional Stream Types
-	StreamTypeAnnounce  StreamType = 0x1
-	StreamTypeSubscribe StreamType = 0x2
-	StreamTypeFetch     StreamType = 0x3
-	StreamTypeProbe     StreamType = 0x4
-	StreamTypeGoaway    StreamType = 0x5
+	StreamTypeAnnounce    StreamType = 0x1
+	StreamTypeSubscribe   StreamType = 0x2
+	StreamTypeFetch       StreamType = 0x3
+	StreamTypeProbe       StreamType = 0x4
+	StreamTypeGoaway      StreamType = 0x5
+	StreamTypeStats       StreamType = 0x6
+	StreamTypeTrackStatus StreamType = 0x7
+	StreamTypePing        StreamType = 0x8
 
 	// Uni-directional Stream Types
 	StreamTypeGroup StreamType = 0x0
@@ -18,6 +21,17 @@ const (
 
 type StreamType byte
 
+// Extended reports whether the stream type is an extension of
+// VersionLite04Ext, which peers of other versions do not know.
+func (stm StreamType) Extended() bool {
+	switch stm {
+	case StreamTypeStats, StreamTypeTrackStatus, StreamTypePing:
+		return true
+	default:
+		return false
+	}
+}
+
 // Encode writes a one-byte stream type header.
 func (stm StreamType) Encode(w io.Writer) error {
 	_, err := w.Write([]byte{byte(stm)})
@@ -73,6 +73,14 @@ func TestStreamType_Constants(t *testing.T) {
 			streamType: message.StreamTypeProbe,
 			expected:   message.StreamType(0x4),
 		},
+		"stats constant": {
+			streamType: message.StreamTypeStats,
+			expected:   message.StreamType(0x6),
+		},
+		"track status constant": {
+			streamType: message.StreamTypeTrackStatus,
+			expected:   message.StreamType(0x7),
+		},
 	}
 
 	for name, tt := range tests {
@@ -90,3 +98,23 @@ func TestStreamType_DecodeErrors(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestStreamType_Extended(t *testing.T) {
+	tests := map[string]struct {
+		streamType message.StreamType
+		want       bool
+	}{
+		"announce":     {streamType: message.StreamTypeAnnounce},
+		"subscribe":    {streamType: message.StreamTypeSubscribe},
+		"group":        {streamType: message.StreamTypeGroup},
+		"stats":        {streamType: message.StreamTypeStats, want: true},
+		"track status": {streamType: message.StreamTypeTrackStatus, want: true},
+		"ping":         {streamType: message.StreamTypePing, want: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.streamType.Extended())
+		})
+	}
+}
@@ -15,10 +15,23 @@ import (
 *   Subscriber Max Latency (varint)
 *   Start Group (varint)
 *   End Group (varint)
+*   [Authorization Token (string)]
+*   [Start (varint)]
+*   [Number of Parameters (varint),
+*    Parameters (Key (varint), Value (bytes))...]
+*   [Start Time (varint)]
+*   [Start Behind (varint)]
 * }
 *
 * Broadcast Path and Track Name are length-prefixed UTF-8 strings.
 * Start Group and End Group use 0 for the default/latest and unbounded values.
+* Start Time is a time in microseconds since the Unix epoch and Start Behind a
+* duration in microseconds before the newest group.
+* The optional fields are extensions, encoded only in VersionLite04Ext.
+* Authorization Token is omitted when empty, Start, Start Time and Start
+* Behind when zero and Parameters when there are none, so that messages
+* without them keep the base layout.
+* The fields preceding a written optional field are written, empty or zero.
  */
 type SubscribeMessage struct {
 	SubscribeID          uint64
@@ -29,6 +42,14 @@ type SubscribeMessage struct {
 	SubscriberMaxLatency uint64
 	StartGroup           uint64
 	EndGroup             uint64
+	AuthToken            string
+	Start                uint64
+	Parameters           Parameters
+	StartTime            uint64
+	StartBehind          uint64
+
+	// Version is the encoding of the message. It is not encoded.
+	Version Version
 }
 
 func (s SubscribeMessage) Len() int {
@@ -42,6 +63,24 @@ func (s SubscribeMessage) Len() int {
 	l += VarintLen(s.SubscriberMaxLatency)
 	l += VarintLen(s.StartGroup)
 	l += VarintLen(s.EndGroup)
+	if !s.Version.Extended() {
+		return l
+	}
+	if s.AuthToken != "" || s.Start != 0 || s.hasParameters() {
+		l += StringLen(s.AuthToken)
+	}
+	if s.Start != 0 || s.hasParameters() {
+		l += VarintLen(s.Start)
+	}
+	if s.hasParameters() {
+		l += ParametersLen(s.Parameters)
+	}
+	if s.StartTime != 0 || s.StartBehind != 0 {
+		l += VarintLen(s.StartTime)
+	}
+	if s.StartBehind != 0 {
+		l += VarintLen(s.StartBehind)
+	}
 
 	return l
 }
@@ -61,6 +100,24 @@ func (s SubscribeMessage) Encode(w io.Writer) error {
 	b, _ = WriteVarint(b, s.SubscriberMaxLatency)
 	b, _ = WriteVarint(b, s.StartGroup)
 	b, _ = WriteVarint(b, s.EndGroup)
+	if s.Version.Extended() {
+		if s.AuthToken != "" || s.Start != 0 || s.hasParameters() {
+			b, _ = WriteVarint(b, uint64(len(s.AuthToken)))
+			b = append(b, s.AuthToken...)
+		}
+		if s.Start != 0 || s.hasParameters() {
+			b, _ = WriteVarint(b, s.Start)
+		}
+		if s.hasParameters() {
+			b, _ = WriteParameters(b, s.Parameters)
+		}
+		if s.StartTime != 0 || s.StartBehind != 0 {
+			b, _ = WriteVarint(b, s.StartTime)
+		}
+		if s.StartBehind != 0 {
+			b, _ = WriteVarint(b, s.StartBehind)
+		}
+	}
 
 	_, err := w.Write(b)
 	return err
@@ -128,9 +185,67 @@ func (s *SubscribeMessage) Decode(src io.Reader) error {
 	s.EndGroup = num
 	b = b[n:]
 
+	s.AuthToken = ""
+	if s.Version.Extended() && len(b) != 0 {
+		str, n, err = ReadString(b)
+		if err != nil {
+			return err
+		}
+		s.AuthToken = str
+		b = b[n:]
+	}
+
+	s.Start = 0
+	if s.Version.Extended() && len(b) != 0 {
+		num, n, err = ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		s.Start = num
+		b = b[n:]
+	}
+
+	s.Parameters = nil
+	if s.Version.Extended() && len(b) != 0 {
+		params, n, err := ReadParameters(b)
+		if err != nil {
+			return err
+		}
+		if len(params) > 0 {
+			s.Parameters = params
+		}
+		b = b[n:]
+	}
+
+	s.StartTime = 0
+	if s.Version.Extended() && len(b) != 0 {
+		num, n, err = ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		s.StartTime = num
+		b = b[n:]
+	}
+
+	s.StartBehind = 0
+	if s.Version.Extended() && len(b) != 0 {
+		num, n, err = ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		s.StartBehind = num
+		b = b[n:]
+	}
+
 	if len(b) != 0 {
 		return ErrMessageTooShort
 	}
 
 	return nil
 }
+
+// hasParameters reports whether the Parameters field is written: when there
+// are parameters, or to precede Start Time or Start Behind.
+func (s SubscribeMessage) hasParameters() bool {
+	return len(s.Parameters) > 0 || s.StartTime != 0 || s.StartBehind != 0
+}
@@ -28,6 +28,63 @@ func TestSubscribeMessage_EncodeDecode(t *testing.T) {
 				SubscriberPriority: 5,
 			},
 		},
+		"with auth token": {
+			input: message.SubscribeMessage{
+				SubscribeID:   2,
+				BroadcastPath: "path",
+				TrackName:     "video",
+				EndGroup:      4,
+				AuthToken:     "secret-token",
+				Version:       message.VersionLite04Ext,
+			},
+		},
+		"with start": {
+			input: message.SubscribeMessage{
+				SubscribeID:   3,
+				BroadcastPath: "path",
+				TrackName:     "video",
+				Start:         2,
+				Version:       message.VersionLite04Ext,
+			},
+		},
+		"with auth token and start": {
+			input: message.SubscribeMessage{
+				SubscribeID:   4,
+				BroadcastPath: "path",
+				TrackName:     "video",
+				AuthToken:     "secret-token",
+				Start:         3,
+				Version:       message.VersionLite04Ext,
+			},
+		},
+		"with parameters": {
+			input: message.SubscribeMessage{
+				SubscribeID:   5,
+				BroadcastPath: "path",
+				TrackName:     "video",
+				Parameters:    message.Parameters{0x21: []byte("hd"), 0x02: {}},
+				Version:       message.VersionLite04Ext,
+			},
+		},
+		"with start time": {
+			input: message.SubscribeMessage{
+				SubscribeID:   6,
+				BroadcastPath: "path",
+				TrackName:     "video",
+				StartTime:     1_700_000_000_000_000,
+				Version:       message.VersionLite04Ext,
+			},
+		},
+		"with start behind": {
+			input: message.SubscribeMessage{
+				SubscribeID:   7,
+				BroadcastPath: "path",
+				TrackName:     "video",
+				Parameters:    message.Parameters{0x21: []byte("hd")},
+				StartBehind:   10_000_000,
+				Version:       message.VersionLite04Ext,
+			},
+		},
 		"nil parameters": {
 			input: message.SubscribeMessage{
 				SubscribeID:        1,
@@ -50,7 +107,7 @@ func TestSubscribeMessage_EncodeDecode(t *testing.T) {
 			require.NoError(t, err)
 
 			// Decode
-			var decoded message.SubscribeMessage
+			decoded := message.SubscribeMessage{Version: tc.input.Version}
 			err = decoded.Decode(&buf)
 			require.NoError(t, err)
 
@@ -185,4 +242,40 @@ func TestSubscribeMessage_DecodeErrors(t *testing.T) {
 		assert.Error(t, err)
 		assert.Error(t, err)
 	})
+
+	t.Run("duplicate parameter", func(t *testing.T) {
+		s := message.SubscribeMessage{Version: message.VersionLite04Ext}
+		src := bytes.NewReader([]byte{
+			0x11,                 // length
+			0x01,                 // subscribe id
+			0x01, 'a', 0x01, 'b', // broadcast path, track name
+			0x00, 0x00, 0x00, 0x00, // priority, ordered, max latency, start group
+			0x00,       // end group
+			0x00, 0x00, // auth token, start
+			0x02, 0x21, 0x00, 0x21, 0x00, // parameters
+		})
+		assert.ErrorIs(t, s.Decode(src), message.ErrDuplicateParameter)
+	})
+}
+
+func TestSubscribeMessage_Lite04(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.SubscribeMessage{
+		SubscribeID:   1,
+		BroadcastPath: "a",
+		TrackName:     "b",
+		AuthToken:     "secret-token",
+		Start:         2,
+		Parameters:    message.Parameters{0x21: []byte("hd")},
+		StartTime:     3,
+		StartBehind:   4,
+	}.Encode(&buf))
+	// Length, subscribe id, broadcast path, track name, priority, ordered,
+	// max latency, start group and end group.
+	assert.Equal(t, []byte{0x0a, 0x01, 0x01, 'a', 0x01, 'b', 0x00, 0x00, 0x00, 0x00, 0x00}, buf.Bytes(), "extensions must not be encoded")
+
+	var ext bytes.Buffer
+	require.NoError(t, message.SubscribeMessage{SubscribeID: 1, AuthToken: "secret-token", Version: message.VersionLite04Ext}.Encode(&ext))
+	var lite04 message.SubscribeMessage
+	assert.ErrorIs(t, lite04.Decode(&ext), message.ErrMessageTooShort)
 }
@@ -12,6 +12,14 @@ type SubscribeUpdateMessage struct {
 	SubscriberMaxLatency uint64
 	StartGroup           uint64
 	EndGroup             uint64
+
+	// Visibility is a hint of the subscriber on whether the track is being
+	// presented. It is an extension, encoded only in VersionLite04Ext, and
+	// omitted from the message when zero.
+	Visibility uint64
+
+	// Version is the encoding of the message. It is not encoded.
+	Version Version
 }
 
 func (su SubscribeUpdateMessage) Len() int {
@@ -22,6 +30,9 @@ func (su SubscribeUpdateMessage) Len() int {
 	l += VarintLen(su.SubscriberMaxLatency)
 	l += VarintLen(su.StartGroup)
 	l += VarintLen(su.EndGroup)
+	if su.Version.Extended() && su.Visibility != 0 {
+		l += VarintLen(su.Visibility)
+	}
 
 	return l
 }
@@ -36,6 +47,9 @@ func (su SubscribeUpdateMessage) Encode(w io.Writer) error {
 	p, _ = WriteVarint(p, su.SubscriberMaxLatency)
 	p, _ = WriteVarint(p, su.StartGroup)
 	p, _ = WriteVarint(p, su.EndGroup)
+	if su.Version.Extended() && su.Visibility != 0 {
+		p, _ = WriteVarint(p, su.Visibility)
+	}
 
 	_, err := w.Write(p)
 
@@ -83,6 +97,16 @@ func (sum *SubscribeUpdateMessage) Decode(src io.Reader) error {
 	sum.EndGroup = num
 	b = b[n:]
 
+	sum.Visibility = 0
+	if sum.Version.Extended() && len(b) > 0 {
+		num, n, err = ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		sum.Visibility = num
+		b = b[n:]
+	}
+
 	if len(b) != 0 {
 		return ErrMessageTooShort
 	}
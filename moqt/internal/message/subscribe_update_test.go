@@ -29,6 +29,14 @@ func TestSubscribeUpdateMessage_EncodeDecode(t *testing.T) {
 				SubscriberPriority: 255,
 			},
 		},
+		"visibility": {
+			input: message.SubscribeUpdateMessage{
+				SubscriberPriority: 5,
+				EndGroup:           10,
+				Visibility:         2,
+				Version:            message.VersionLite04Ext,
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -44,7 +52,7 @@ func TestSubscribeUpdateMessage_EncodeDecode(t *testing.T) {
 			require.NoError(t, err)
 
 			// Decode
-			var decoded message.SubscribeUpdateMessage
+			decoded := message.SubscribeUpdateMessage{Version: tc.input.Version}
 			err = decoded.Decode(&buf)
 			require.NoError(t, err)
 
@@ -122,3 +130,21 @@ func TestSubscribeUpdateMessage_DecodeErrors(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestSubscribeUpdateMessage_VisibilityOmitted(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.SubscribeUpdateMessage{SubscriberPriority: 5, Version: message.VersionLite04Ext}.Encode(&buf))
+	// Length, priority, ordered, max latency, start and end.
+	assert.Equal(t, []byte{5, 5, 0, 0, 0, 0}, buf.Bytes())
+}
+
+func TestSubscribeUpdateMessage_Lite04(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.SubscribeUpdateMessage{SubscriberPriority: 5, Visibility: 1}.Encode(&buf))
+	assert.Equal(t, []byte{5, 5, 0, 0, 0, 0}, buf.Bytes(), "extensions must not be encoded")
+
+	var ext bytes.Buffer
+	require.NoError(t, message.SubscribeUpdateMessage{Visibility: 1, Version: message.VersionLite04Ext}.Encode(&ext))
+	var lite04 message.SubscribeUpdateMessage
+	assert.ErrorIs(t, lite04.Decode(&ext), message.ErrMessageTooShort)
+}
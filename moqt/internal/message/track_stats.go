@@ -0,0 +1,68 @@
+package message
+
+import (
+	"io"
+)
+
+// TrackStatsMessage is sent on the Stats stream (0x6).
+// The subscriber reports the audience of a subscription it relays so that
+// the publisher can display viewer counts.
+type TrackStatsMessage struct {
+	// SubscribeID identifies the subscription the statistics belong to.
+	SubscribeID uint64
+	// Subscribers is the number of downstream subscribers.
+	Subscribers uint64
+	// DroppedGroups is the number of groups not delivered downstream.
+	DroppedGroups uint64
+	// DroppedFrames is the number of frames not delivered downstream.
+	DroppedFrames uint64
+}
+
+func (tsm TrackStatsMessage) Len() int {
+	return VarintLen(tsm.SubscribeID) + VarintLen(tsm.Subscribers) +
+		VarintLen(tsm.DroppedGroups) + VarintLen(tsm.DroppedFrames)
+}
+
+func (tsm TrackStatsMessage) Encode(w io.Writer) error {
+	msgLen := tsm.Len()
+	b := make([]byte, 0, msgLen+VarintLen(uint64(msgLen)))
+
+	b, _ = WriteMessageLength(b, uint64(msgLen))
+	b, _ = WriteVarint(b, tsm.SubscribeID)
+	b, _ = WriteVarint(b, tsm.Subscribers)
+	b, _ = WriteVarint(b, tsm.DroppedGroups)
+	b, _ = WriteVarint(b, tsm.DroppedFrames)
+
+	_, err := w.Write(b)
+	return err
+}
+
+func (tsm *TrackStatsMessage) Decode(src io.Reader) error {
+	size, err := ReadMessageLength(src)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, size)
+
+	_, err = io.ReadFull(src, b)
+	if err != nil {
+		return err
+	}
+
+	fields := []*uint64{&tsm.SubscribeID, &tsm.Subscribers, &tsm.DroppedGroups, &tsm.DroppedFrames}
+	for _, field := range fields {
+		num, n, err := ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		*field = num
+		b = b[n:]
+	}
+
+	if len(b) != 0 {
+		return ErrMessageTooShort
+	}
+
+	return nil
+}
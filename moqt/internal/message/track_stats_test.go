@@ -0,0 +1,70 @@
+package message_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackStatsMessage_EncodeDecode(t *testing.T) {
+	tests := map[string]struct {
+		input message.TrackStatsMessage
+	}{
+		"valid_message": {
+			input: message.TrackStatsMessage{SubscribeID: 1, Subscribers: 42, DroppedGroups: 3, DroppedFrames: 7},
+		},
+		"zero_values": {
+			input: message.TrackStatsMessage{},
+		},
+		"large_values": {
+			input: message.TrackStatsMessage{SubscribeID: 1 << 30, Subscribers: 1 << 40, DroppedGroups: 1 << 20, DroppedFrames: 1 << 50},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+
+			err := tc.input.Encode(&buf)
+			require.NoError(t, err)
+
+			var decoded message.TrackStatsMessage
+			err = decoded.Decode(&buf)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.input, decoded, "decoded message should match input")
+		})
+	}
+}
+
+func TestTrackStatsMessage_DecodeErrors(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+	}{
+		"empty_reader": {
+			data: []byte{},
+		},
+		"truncated_length": {
+			data: []byte{0xff},
+		},
+		"missing_fields": {
+			data: []byte{0x02, 0x01, 0x02}, // length=2, only two of four fields
+		},
+		"extra_data": {
+			data: []byte{0x05, 0x01, 0x02, 0x03, 0x04, 0xff}, // four fields plus one extra byte
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var tsm message.TrackStatsMessage
+			err := tsm.Decode(bytes.NewReader(tc.data))
+			assert.Error(t, err)
+		})
+	}
+}
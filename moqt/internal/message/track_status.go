@@ -0,0 +1,121 @@
+package message
+
+import (
+	"io"
+)
+
+// TrackStatusRequestMessage is sent on the TrackStatus stream (0x7).
+// The requester asks for the status of a track without subscribing to it.
+type TrackStatusRequestMessage struct {
+	BroadcastPath string
+	TrackName     string
+}
+
+func (tsr TrackStatusRequestMessage) Len() int {
+	return StringLen(tsr.BroadcastPath) + StringLen(tsr.TrackName)
+}
+
+func (tsr TrackStatusRequestMessage) Encode(w io.Writer) error {
+	msgLen := tsr.Len()
+	b := make([]byte, 0, msgLen+VarintLen(uint64(msgLen)))
+
+	b, _ = WriteMessageLength(b, uint64(msgLen))
+	b, _ = WriteVarint(b, uint64(len(tsr.BroadcastPath)))
+	b = append(b, tsr.BroadcastPath...)
+	b, _ = WriteVarint(b, uint64(len(tsr.TrackName)))
+	b = append(b, tsr.TrackName...)
+
+	_, err := w.Write(b)
+	return err
+}
+
+func (tsr *TrackStatusRequestMessage) Decode(src io.Reader) error {
+	size, err := ReadMessageLength(src)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, size)
+
+	_, err = io.ReadFull(src, b)
+	if err != nil {
+		return err
+	}
+
+	str, n, err := ReadString(b)
+	if err != nil {
+		return err
+	}
+	tsr.BroadcastPath = str
+	b = b[n:]
+
+	str, n, err = ReadString(b)
+	if err != nil {
+		return err
+	}
+	tsr.TrackName = str
+	b = b[n:]
+
+	if len(b) != 0 {
+		return ErrMessageTooShort
+	}
+
+	return nil
+}
+
+// TrackStatusMessage is the response to a TrackStatusRequestMessage.
+type TrackStatusMessage struct {
+	// StatusCode tells whether the track exists and whether it is live.
+	StatusCode uint64
+	// LatestGroup and LatestFrame are the sequence of the latest group and
+	// the index of its latest frame, if the publisher reports them.
+	LatestGroup uint64
+	LatestFrame uint64
+}
+
+func (tsm TrackStatusMessage) Len() int {
+	return VarintLen(tsm.StatusCode) + VarintLen(tsm.LatestGroup) + VarintLen(tsm.LatestFrame)
+}
+
+func (tsm TrackStatusMessage) Encode(w io.Writer) error {
+	msgLen := tsm.Len()
+	b := make([]byte, 0, msgLen+VarintLen(uint64(msgLen)))
+
+	b, _ = WriteMessageLength(b, uint64(msgLen))
+	b, _ = WriteVarint(b, tsm.StatusCode)
+	b, _ = WriteVarint(b, tsm.LatestGroup)
+	b, _ = WriteVarint(b, tsm.LatestFrame)
+
+	_, err := w.Write(b)
+	return err
+}
+
+func (tsm *TrackStatusMessage) Decode(src io.Reader) error {
+	size, err := ReadMessageLength(src)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, size)
+
+	_, err = io.ReadFull(src, b)
+	if err != nil {
+		return err
+	}
+
+	fields := []*uint64{&tsm.StatusCode, &tsm.LatestGroup, &tsm.LatestFrame}
+	for _, field := range fields {
+		num, n, err := ReadVarint(b)
+		if err != nil {
+			return err
+		}
+		*field = num
+		b = b[n:]
+	}
+
+	if len(b) != 0 {
+		return ErrMessageTooShort
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package message_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackStatusRequestMessage_EncodeDecode(t *testing.T) {
+	tests := map[string]struct {
+		input message.TrackStatusRequestMessage
+	}{
+		"valid_message": {
+			input: message.TrackStatusRequestMessage{BroadcastPath: "/live", TrackName: "video"},
+		},
+		"empty_strings": {
+			input: message.TrackStatusRequestMessage{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+
+			err := tc.input.Encode(&buf)
+			require.NoError(t, err)
+
+			var decoded message.TrackStatusRequestMessage
+			err = decoded.Decode(&buf)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.input, decoded, "decoded message should match input")
+		})
+	}
+}
+
+func TestTrackStatusRequestMessage_DecodeErrors(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+	}{
+		"empty_reader": {
+			data: []byte{},
+		},
+		"truncated_body": {
+			data: []byte{0x10, 0x01}, // length=16 but only 1 byte
+		},
+		"missing_track_name": {
+			data: []byte{0x02, 0x01, 'a'}, // length=2, only the broadcast path
+		},
+		"extra_data": {
+			data: []byte{0x05, 0x01, 'a', 0x01, 'b', 0xff}, // two strings plus one extra byte
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var tsr message.TrackStatusRequestMessage
+			err := tsr.Decode(bytes.NewReader(tc.data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTrackStatusMessage_EncodeDecode(t *testing.T) {
+	tests := map[string]struct {
+		input message.TrackStatusMessage
+	}{
+		"valid_message": {
+			input: message.TrackStatusMessage{StatusCode: 3, LatestGroup: 42, LatestFrame: 7},
+		},
+		"zero_values": {
+			input: message.TrackStatusMessage{},
+		},
+		"large_values": {
+			input: message.TrackStatusMessage{StatusCode: 1, LatestGroup: 1 << 50, LatestFrame: 1 << 30},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+
+			err := tc.input.Encode(&buf)
+			require.NoError(t, err)
+
+			var decoded message.TrackStatusMessage
+			err = decoded.Decode(&buf)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.input, decoded, "decoded message should match input")
+		})
+	}
+}
+
+func TestTrackStatusMessage_DecodeErrors(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+	}{
+		"empty_reader": {
+			data: []byte{},
+		},
+		"truncated_length": {
+			data: []byte{0xff},
+		},
+		"missing_fields": {
+			data: []byte{0x02, 0x01, 0x02}, // length=2, only two of three fields
+		},
+		"extra_data": {
+			data: []byte{0x04, 0x01, 0x02, 0x03, 0xff}, // three fields plus one extra byte
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var tsm message.TrackStatusMessage
+			err := tsm.Decode(bytes.NewReader(tc.data))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package message
+
+// Version selects the encoding of the messages whose layout differs between
+// the versions of MOQ a session may negotiate.
+type Version uint8
+
+const (
+	// VersionLite04 is the encoding of moq-lite-04, the default.
+	VersionLite04 Version = iota
+
+	// VersionLite03 is the encoding of moq-lite-03, whose PROBE messages
+	// carry no RTT.
+	VersionLite03
+
+	// VersionLite04Ext is the encoding of moq-lite-04 with the extensions of
+	// gomoqt: the optional trailing fields of SUBSCRIBE, SUBSCRIBE_UPDATE,
+	// ANNOUNCE and GROUP messages, which the other versions omit.
+	VersionLite04Ext
+)
+
+// Extended reports whether the messages of v carry the optional trailing
+// fields of the extensions.
+func (v Version) Extended() bool {
+	return v == VersionLite04Ext
+}
@@ -9,6 +9,11 @@ import (
 	"github.com/qumo-dev/gomoqt/transport"
 )
 
+// WrapConn returns the StreamConn of conn.
+func WrapConn(conn *quicgo_quicgo.Conn) transport.StreamConn {
+	return wrapConnection(conn)
+}
+
 func wrapConnection(conn *quicgo_quicgo.Conn) transport.StreamConn {
 	if conn == nil {
 		return nil
@@ -22,6 +27,10 @@ var _ transport.StreamConn = (*connWrapper)(nil)
 
 type connWrapper struct {
 	conn *quicgo_quicgo.Conn
+
+	// ready, if set, is closed once a connection dialed with 0-RTT is
+	// usable after its handshake.
+	ready chan struct{}
 }
 
 func (wrapper *connWrapper) AcceptStream(ctx context.Context) (transport.Stream, error) {
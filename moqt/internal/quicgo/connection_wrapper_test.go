@@ -1,11 +1,36 @@
 package quicgo
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/qumo-dev/gomoqt/transport"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestWrapConnection_Nil(t *testing.T) {
 	assert.Nil(t, wrapConnection(nil))
 }
+
+func TestMaxDatagramSize(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want int64
+	}{
+		"too large":      {err: &transport.DatagramTooLargeError{MaxDatagramPayloadSize: 1180}, want: 1180},
+		"disabled":       {err: errors.New("datagram support disabled"), want: 0},
+		"probe was sent": {err: nil, want: 0},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var probe []byte
+			got := MaxDatagramSize(func(b []byte) error {
+				probe = b
+				return tt.err
+			})
+			assert.Equal(t, tt.want, got)
+			assert.Greater(t, len(probe), 1452, "the probe must exceed any QUIC packet")
+		})
+	}
+}
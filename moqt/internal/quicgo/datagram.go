@@ -0,0 +1,41 @@
+package quicgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+var _ transport.DatagramSizeConn = (*connWrapper)(nil)
+
+// SendDatagram implements transport.DatagramConn.
+func (wrapper *connWrapper) SendDatagram(b []byte) error {
+	return wrapper.conn.SendDatagram(b)
+}
+
+// ReceiveDatagram implements transport.DatagramConn.
+func (wrapper *connWrapper) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	return wrapper.conn.ReceiveDatagram(ctx)
+}
+
+// MaxDatagramSize implements transport.DatagramSizeConn.
+func (wrapper *connWrapper) MaxDatagramSize() int64 {
+	return MaxDatagramSize(wrapper.conn.SendDatagram)
+}
+
+// datagramSizeProbe is larger than any datagram quic-go sends, and is not a
+// valid MOQT datagram should it ever be delivered.
+var datagramSizeProbe = bytes.Repeat([]byte{0xff}, 1<<14)
+
+// MaxDatagramSize returns the largest datagram payload send accepts now, or
+// 0 if it does not tell. quic-go reports its limit, which follows the
+// discovered path MTU, only in the error for a datagram that is too large, so
+// it is offered a probe that is always rejected without being sent.
+func MaxDatagramSize(send func([]byte) error) int64 {
+	if tooLarge, ok := errors.AsType[*transport.DatagramTooLargeError](send(datagramSizeProbe)); ok {
+		return tooLarge.MaxDatagramPayloadSize
+	}
+	return 0
+}
@@ -12,5 +12,5 @@ import (
 func DialAddrEarly(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (transport.StreamConn, error) {
 	conn, err := quicgo_quicgo.DialAddrEarly(ctx, addr, tlsConfig, quicConfig)
 
-	return wrapConnection(conn), err
+	return wrapEarlyConnection(conn), err
 }
@@ -0,0 +1,52 @@
+package quicgo
+
+import (
+	"context"
+
+	quicgo_quicgo "github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+var _ transport.EarlyDataConn = (*connWrapper)(nil)
+
+// HandshakeComplete implements transport.EarlyDataConn.
+func (wrapper *connWrapper) HandshakeComplete() <-chan struct{} {
+	if wrapper.ready != nil {
+		return wrapper.ready
+	}
+	return wrapper.conn.HandshakeComplete()
+}
+
+// Used0RTT implements transport.EarlyDataConn.
+func (wrapper *connWrapper) Used0RTT() bool {
+	return wrapper.conn.ConnectionState().Used0RTT
+}
+
+// wrapEarlyConnection wraps a connection dialed with 0-RTT. Once the
+// handshake completes, a connection whose 0-RTT data the server rejected is
+// made usable for new streams, which quic-go otherwise fails with
+// quic.Err0RTTRejected.
+func wrapEarlyConnection(conn *quicgo_quicgo.Conn) transport.StreamConn {
+	if conn == nil {
+		return nil
+	}
+	select {
+	case <-conn.HandshakeComplete():
+		return wrapConnection(conn)
+	default:
+	}
+
+	ready := make(chan struct{})
+	go func() {
+		defer close(ready)
+		select {
+		case <-conn.HandshakeComplete():
+		case <-conn.Context().Done():
+			return
+		}
+		if !conn.ConnectionState().Used0RTT {
+			_, _ = conn.NextConnection(context.Background())
+		}
+	}()
+	return &connWrapper{conn: conn, ready: ready}
+}
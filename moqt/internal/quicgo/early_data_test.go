@@ -0,0 +1,103 @@
+package quicgo
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialAddrEarly_0RTT(t *testing.T) {
+	tests := map[string]struct {
+		allow0RTT bool
+	}{
+		"accepted": {allow0RTT: true},
+		"rejected": {allow0RTT: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			serverTLS := testutil.TLSConfig(t, "test")
+			clientTLS := &tls.Config{
+				InsecureSkipVerify: true,
+				NextProtos:         []string{"test"},
+				ClientSessionCache: tls.NewLRUClientSessionCache(1),
+			}
+			ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+			defer cancel()
+
+			// The first connection obtains a ticket allowing 0-RTT.
+			addr := listenEcho(t, serverTLS, true)
+			conn, err := DialAddrEarly(ctx, addr, clientTLS, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", echo(t, conn, "hello"))
+			_ = conn.CloseWithError(0, "")
+
+			// Tickets stay valid for listeners sharing the TLS configuration.
+			addr = listenEcho(t, serverTLS, tt.allow0RTT)
+			conn, err = DialAddrEarly(ctx, addr, clientTLS, nil)
+			require.NoError(t, err)
+			defer conn.CloseWithError(0, "")
+			early, ok := conn.(transport.EarlyDataConn)
+			require.True(t, ok)
+
+			select {
+			case <-early.HandshakeComplete():
+			case <-ctx.Done():
+				t.Fatal("handshake did not complete")
+			}
+			assert.Equal(t, tt.allow0RTT, early.Used0RTT())
+			assert.Equal(t, "world", echo(t, conn, "world"))
+		})
+	}
+}
+
+func listenEcho(t *testing.T, tlsConfig *tls.Config, allow0RTT bool) string {
+	t.Helper()
+
+	ln, err := quic.ListenAddrEarly("127.0.0.1:0", tlsConfig, &quic.Config{Allow0RTT: allow0RTT})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					go func() {
+						_, _ = io.Copy(stream, stream)
+						_ = stream.Close()
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func echo(t *testing.T, conn transport.StreamConn, msg string) string {
+	t.Helper()
+
+	stream, err := conn.OpenStream()
+	require.NoError(t, err)
+	_, err = stream.Write([]byte(msg))
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	got, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	return string(got)
+}
@@ -3,6 +3,7 @@ package quicgo
 import (
 	"context"
 	"crypto/tls"
+	"io"
 	"net"
 
 	"github.com/quic-go/quic-go"
@@ -17,6 +18,17 @@ func ListenAddrEarly(addr string, tlsConfig *tls.Config, quicConfig *quic.Config
 	return wrapListener(ln), err
 }
 
+// ListenEarly listens on conn. Closing the listener leaves the connections
+// it accepted open; closing the returned transport closes them, but not conn.
+func ListenEarly(conn net.PacketConn, tlsConfig *tls.Config, quicConfig *quic.Config) (transport.QUICListener, io.Closer, error) {
+	tr := &quicgo_quicgo.Transport{Conn: conn}
+	ln, err := tr.ListenEarly(tlsConfig, quicConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wrapListener(ln), tr, nil
+}
+
 // var _ quic.Listener = (*listenerWrapper)(nil)
 
 func wrapListener(quicListener *quicgo_quicgo.EarlyListener) transport.QUICListener {
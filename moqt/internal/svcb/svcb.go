@@ -0,0 +1,280 @@
+// Package svcb looks up the SVCB and HTTPS records of RFC 9460, which the
+// resolver of the standard library does not query.
+package svcb
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxAliases bounds the chain of AliasMode records followed by Lookup.
+const maxAliases = 8
+
+// defaultTimeout bounds the exchanges of contexts without a deadline.
+const defaultTimeout = 5 * time.Second
+
+// DialFunc opens the connection to a DNS server.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Record is a ServiceMode record.
+type Record struct {
+	Priority uint16
+
+	// Target is the name of the endpoint, without the trailing dot. A
+	// target of "." is replaced by the owner name of the record.
+	Target string
+
+	// Port is the port of the endpoint, or zero if the record has none.
+	Port uint16
+
+	// ALPN lists the application protocols of the endpoint.
+	ALPN []string
+
+	// IPv6Hint and IPv4Hint are addresses of Target.
+	IPv6Hint []netip.Addr
+	IPv4Hint []netip.Addr
+}
+
+// Lookup queries server, a host:port, for the records of type typ, which is
+// dnsmessage.TypeSVCB or dnsmessage.TypeHTTPS, of name. AliasMode records
+// are followed, and the ServiceMode records are returned by increasing
+// priority. A name without records yields none.
+func Lookup(ctx context.Context, dial DialFunc, server, name string, typ dnsmessage.Type) ([]Record, error) {
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	name = strings.TrimSuffix(name, ".")
+	for range maxAliases {
+		resources, err := query(ctx, dial, server, name, typ)
+		if err != nil {
+			return nil, err
+		}
+
+		var records []Record
+		alias := ""
+		for _, r := range resources {
+			if r.Priority == 0 {
+				alias = r.Target.String()
+				continue
+			}
+			records = append(records, newRecord(r, name))
+		}
+		if len(records) > 0 || alias == "" {
+			slices.SortStableFunc(records, func(a, b Record) int {
+				return int(a.Priority) - int(b.Priority)
+			})
+			return records, nil
+		}
+		if alias == "." {
+			// The service is not available.
+			return nil, nil
+		}
+		name = strings.TrimSuffix(alias, ".")
+	}
+	return nil, fmt.Errorf("svcb: too many aliases for %s", name)
+}
+
+func newRecord(r dnsmessage.SVCBResource, owner string) Record {
+	rec := Record{
+		Priority: r.Priority,
+		Target:   strings.TrimSuffix(r.Target.String(), "."),
+	}
+	if rec.Target == "" {
+		rec.Target = owner
+	}
+	if v, ok := r.GetParam(dnsmessage.SVCParamPort); ok && len(v) == 2 {
+		rec.Port = binary.BigEndian.Uint16(v)
+	}
+	if v, ok := r.GetParam(dnsmessage.SVCParamALPN); ok {
+		for len(v) > 0 && int(v[0]) < len(v) {
+			rec.ALPN = append(rec.ALPN, string(v[1:1+v[0]]))
+			v = v[1+v[0]:]
+		}
+	}
+	if v, ok := r.GetParam(dnsmessage.SVCParamIPv6Hint); ok {
+		for ; len(v) >= 16; v = v[16:] {
+			rec.IPv6Hint = append(rec.IPv6Hint, netip.AddrFrom16([16]byte(v[:16])))
+		}
+	}
+	if v, ok := r.GetParam(dnsmessage.SVCParamIPv4Hint); ok {
+		for ; len(v) >= 4; v = v[4:] {
+			rec.IPv4Hint = append(rec.IPv4Hint, netip.AddrFrom4([4]byte(v[:4])))
+		}
+	}
+	return rec
+}
+
+// query exchanges a query for the records of name with server, over UDP and
+// again over TCP if the response is truncated.
+func query(ctx context.Context, dial DialFunc, server, name string, typ dnsmessage.Type) ([]dnsmessage.SVCBResource, error) {
+	qname, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return nil, fmt.Errorf("svcb: %w", err)
+	}
+	var id [2]byte
+	_, _ = rand.Read(id[:])
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: binary.BigEndian.Uint16(id[:]), RecursionDesired: true})
+	_ = b.StartQuestions()
+	_ = b.Question(dnsmessage.Question{Name: qname, Type: typ, Class: dnsmessage.ClassINET})
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("svcb: %w", err)
+	}
+
+	rsp, err := exchange(ctx, dial, "udp", server, msg)
+	if err != nil {
+		return nil, err
+	}
+	var p dnsmessage.Parser
+	h, err := p.Start(rsp)
+	if err == nil && h.Truncated {
+		if rsp, err = exchange(ctx, dial, "tcp", server, msg); err != nil {
+			return nil, err
+		}
+		h, err = p.Start(rsp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("svcb: %w", err)
+	}
+	if h.ID != binary.BigEndian.Uint16(id[:]) || !h.Response {
+		return nil, errors.New("svcb: unexpected response")
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("svcb: lookup %s: %s", name, h.RCode)
+	}
+
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("svcb: %w", err)
+	}
+	var records []dnsmessage.SVCBResource
+	for {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("svcb: %w", err)
+		}
+		switch {
+		case rh.Type == typ && typ == dnsmessage.TypeHTTPS:
+			r, err := p.HTTPSResource()
+			if err != nil {
+				return nil, fmt.Errorf("svcb: %w", err)
+			}
+			records = append(records, r.SVCBResource)
+		case rh.Type == typ && typ == dnsmessage.TypeSVCB:
+			r, err := p.SVCBResource()
+			if err != nil {
+				return nil, fmt.Errorf("svcb: %w", err)
+			}
+			records = append(records, r)
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return nil, fmt.Errorf("svcb: %w", err)
+			}
+		}
+	}
+}
+
+func exchange(ctx context.Context, dial DialFunc, network, server string, msg []byte) ([]byte, error) {
+	conn, err := dial(ctx, network, server)
+	if err != nil {
+		return nil, fmt.Errorf("svcb: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	rsp, err := roundTrip(conn, network, msg)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		return nil, fmt.Errorf("svcb: %w", err)
+	}
+	return rsp, nil
+}
+
+func roundTrip(conn net.Conn, network string, msg []byte) ([]byte, error) {
+	if network == "udp" {
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 65535)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	// Messages over TCP are prefixed with their length.
+	if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(msg)))); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// resolvConf is the configuration file of the system resolver.
+var resolvConf = "/etc/resolv.conf"
+
+// Nameserver returns the address of the first nameserver of the system
+// resolver, as host:port.
+func Nameserver() (string, error) {
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		return "", fmt.Errorf("svcb: %w", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			addr, err := netip.ParseAddr(fields[1])
+			if err != nil {
+				continue
+			}
+			return netip.AddrPortFrom(addr, 53).String(), nil
+		}
+	}
+	return "", errors.New("svcb: no nameserver in " + resolvConf)
+}
@@ -0,0 +1,191 @@
+package svcb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testServer answers queries for the records of its zone over UDP, with
+// truncated responses if truncate is set, and over TCP.
+type testServer struct {
+	zone     map[string][]dnsmessage.SVCBResource
+	truncate bool
+}
+
+func (s *testServer) respond(t *testing.T, query []byte, udp bool) []byte {
+	var q dnsmessage.Message
+	require.NoError(t, q.Unpack(query))
+	rsp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.ID, Response: true},
+		Questions: q.Questions,
+	}
+	records, ok := s.zone[strings.TrimSuffix(q.Questions[0].Name.String(), ".")]
+	switch {
+	case !ok:
+		rsp.RCode = dnsmessage.RCodeNameError
+	case udp && s.truncate:
+		rsp.Truncated = true
+	default:
+		for _, r := range records {
+			rsp.Answers = append(rsp.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: q.Questions[0].Type, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.HTTPSResource{SVCBResource: r},
+			})
+		}
+	}
+	msg, err := rsp.Pack()
+	require.NoError(t, err)
+	return msg
+}
+
+// dial returns a DialFunc connecting to the server over pipes.
+func (s *testServer) dial(t *testing.T) DialFunc {
+	return func(_ context.Context, network, _ string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			if network == "udp" {
+				buf := make([]byte, 512)
+				n, err := server.Read(buf)
+				if err != nil {
+					return
+				}
+				_, _ = server.Write(s.respond(t, buf[:n], true))
+				return
+			}
+			var length [2]byte
+			if _, err := io.ReadFull(server, length[:]); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(server, query); err != nil {
+				return
+			}
+			rsp := s.respond(t, query, false)
+			_, _ = server.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(rsp))), rsp...))
+		}()
+		return client, nil
+	}
+}
+
+func mustName(t *testing.T, name string) dnsmessage.Name {
+	t.Helper()
+	n, err := dnsmessage.NewName(name)
+	require.NoError(t, err)
+	return n
+}
+
+func TestLookup(t *testing.T) {
+	service := dnsmessage.SVCBResource{
+		Priority: 1,
+		Target:   mustName(t, "."),
+		Params: []dnsmessage.SVCParam{
+			{Key: dnsmessage.SVCParamALPN, Value: []byte("\x02h3\x0bmoq-lite-04")},
+			{Key: dnsmessage.SVCParamPort, Value: []byte{0x11, 0x51}},
+			{Key: dnsmessage.SVCParamIPv4Hint, Value: []byte{192, 0, 2, 1, 192, 0, 2, 2}},
+			{Key: dnsmessage.SVCParamIPv6Hint, Value: netip.MustParseAddr("2001:db8::1").AsSlice()},
+		},
+	}
+	zone := map[string][]dnsmessage.SVCBResource{
+		"relay.example.com": {
+			{Priority: 3, Target: mustName(t, "c.example.com.")},
+			service,
+			{Priority: 2, Target: mustName(t, "b.example.com.")},
+		},
+		"alias.example.com":    {{Priority: 0, Target: mustName(t, "relay.example.com.")}},
+		"loop.example.com":     {{Priority: 0, Target: mustName(t, "loop.example.com.")}},
+		"disabled.example.com": {{Priority: 0, Target: mustName(t, ".")}},
+		"empty.example.com":    nil,
+	}
+
+	tests := map[string]struct {
+		name     string
+		truncate bool
+		want     []string
+		wantErr  bool
+	}{
+		"by priority":  {name: "relay.example.com", want: []string{"relay.example.com", "b.example.com", "c.example.com"}},
+		"over tcp":     {name: "relay.example.com", truncate: true, want: []string{"relay.example.com", "b.example.com", "c.example.com"}},
+		"alias":        {name: "alias.example.com.", want: []string{"relay.example.com", "b.example.com", "c.example.com"}},
+		"alias loop":   {name: "loop.example.com", wantErr: true},
+		"disabled":     {name: "disabled.example.com"},
+		"no records":   {name: "empty.example.com"},
+		"no such name": {name: "missing.example.com"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := &testServer{zone: zone, truncate: tt.truncate}
+			records, err := Lookup(t.Context(), server.dial(t), "192.0.2.53:53", tt.name, dnsmessage.TypeHTTPS)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var targets []string
+			for _, r := range records {
+				targets = append(targets, r.Target)
+			}
+			assert.Equal(t, tt.want, targets)
+
+			if len(records) > 0 {
+				r := records[0]
+				assert.Equal(t, uint16(4433), r.Port)
+				assert.Equal(t, []string{"h3", "moq-lite-04"}, r.ALPN)
+				assert.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")}, r.IPv4Hint)
+				assert.Equal(t, []netip.Addr{netip.MustParseAddr("2001:db8::1")}, r.IPv6Hint)
+			}
+		})
+	}
+}
+
+func TestLookup_DialError(t *testing.T) {
+	errRefused := errors.New("refused")
+	_, err := Lookup(t.Context(), func(context.Context, string, string) (net.Conn, error) {
+		return nil, errRefused
+	}, "192.0.2.53:53", "relay.example.com", dnsmessage.TypeHTTPS)
+	assert.ErrorIs(t, err, errRefused)
+}
+
+func TestNameserver(t *testing.T) {
+	tests := map[string]struct {
+		conf    string
+		want    string
+		wantErr bool
+	}{
+		"ipv4":          {conf: "# comment\nsearch example.com\nnameserver 192.0.2.53\nnameserver 192.0.2.54\n", want: "192.0.2.53:53"},
+		"ipv6":          {conf: "nameserver 2001:db8::53\n", want: "[2001:db8::53]:53"},
+		"no nameserver": {conf: "search example.com\n", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resolv.conf")
+			require.NoError(t, os.WriteFile(path, []byte(tt.conf), 0o600))
+			old := resolvConf
+			resolvConf = path
+			t.Cleanup(func() { resolvConf = old })
+
+			got, err := Nameserver()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
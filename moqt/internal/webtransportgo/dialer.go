@@ -3,17 +3,55 @@ package webtransportgo
 import (
 	"context"
 	"crypto/tls"
+	"net"
 	"net/http"
 
 	quicgo_webtransportgo "github.com/okdaichi/webtransport-go"
+	"github.com/quic-go/quic-go"
 	"github.com/qumo-dev/gomoqt/transport"
 )
 
-func Dial(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config, appProtocols []string) (*http.Response, transport.WebTransportSession, error) {
+// DialPacketFunc returns the connection carrying the QUIC packets to addr,
+// and the address to send them to.
+type DialPacketFunc func(ctx context.Context, addr string) (net.PacketConn, net.Addr, error)
+
+// Dial establishes a WebTransport session. If dialPacket is not nil, the
+// QUIC connection runs over the packet connection it returns, which is
+// closed with the QUIC connection.
+func Dial(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config, appProtocols []string, dialPacket DialPacketFunc) (*http.Response, transport.WebTransportSession, error) {
 	dialer := quicgo_webtransportgo.Dialer{
 		TLSClientConfig:      tlsConfig,
 		ApplicationProtocols: appProtocols,
 	}
+	if dialPacket != nil {
+		dialer.DialAddr = func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			pconn, raddr, err := dialPacket(ctx, addr)
+			if err != nil {
+				return nil, err
+			}
+			conn, err := quic.DialEarly(ctx, pconn, raddr, tlsCfg, cfg)
+			if err != nil {
+				pconn.Close()
+				return nil, err
+			}
+			context.AfterFunc(conn.Context(), func() { pconn.Close() })
+			return conn, nil
+		}
+	}
+	rsp, wtsess, err := dialer.Dial(ctx, addr, header)
+
+	return rsp, wrapSession(wtsess), err
+}
+
+// DialConn establishes a WebTransport session on conn, a QUIC connection
+// that negotiated h3. conn is closed if the session cannot be established.
+func DialConn(ctx context.Context, conn *quic.Conn, addr string, header http.Header, appProtocols []string) (*http.Response, transport.WebTransportSession, error) {
+	dialer := quicgo_webtransportgo.Dialer{
+		ApplicationProtocols: appProtocols,
+		DialAddr: func(context.Context, string, *tls.Config, *quic.Config) (*quic.Conn, error) {
+			return conn, nil
+		},
+	}
 	rsp, wtsess, err := dialer.Dial(ctx, addr, header)
 
 	return rsp, wrapSession(wtsess), err
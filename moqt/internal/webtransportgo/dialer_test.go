@@ -2,6 +2,8 @@ package webtransportgo
 
 import (
 	"context"
+	"errors"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,9 +11,23 @@ import (
 )
 
 func TestDial_InvalidAddress(t *testing.T) {
-	rsp, conn, err := Dial(context.Background(), "://bad-url", nil, nil, nil)
+	rsp, conn, err := Dial(context.Background(), "://bad-url", nil, nil, nil, nil)
 
 	require.Error(t, err)
 	assert.Nil(t, rsp)
 	assert.Nil(t, conn)
 }
+
+func TestDial_DialPacket(t *testing.T) {
+	errBlocked := errors.New("blocked")
+	var dialed string
+	dialPacket := func(ctx context.Context, addr string) (net.PacketConn, net.Addr, error) {
+		dialed = addr
+		return nil, nil, errBlocked
+	}
+
+	_, _, err := Dial(context.Background(), "https://example.com:4433/moq", nil, nil, nil, dialPacket)
+
+	assert.ErrorIs(t, err, errBlocked)
+	assert.Equal(t, "example.com:4433", dialed)
+}
@@ -17,27 +17,32 @@ import (
 type Server struct {
 	internalServer *quicgo_webtransportgo.Server
 	Handler        http.Handler
-	connContexts   sync.Map // *quicgo_quicgo.Conn -> context.Context
-	initOnce       sync.Once
+	// H3 is the HTTP/3 server to enable WebTransport on. If nil, one is
+	// created with Handler.
+	H3           *http3.Server
+	connContexts sync.Map // *quicgo_quicgo.Conn -> context.Context
+	initOnce     sync.Once
 }
 
 func (s *Server) init() {
 	s.initOnce.Do(func() {
 		if s.internalServer == nil {
-			s.internalServer = &quicgo_webtransportgo.Server{
-				H3: &http3.Server{
-					Handler: s.Handler,
-				},
-			}
+			s.internalServer = &quicgo_webtransportgo.Server{H3: s.H3}
 		}
 		if s.internalServer.H3 == nil {
 			s.internalServer.H3 = &http3.Server{
 				Handler: s.Handler,
 			}
 		}
+		// The context of the connection, as passed to ServeQUICConn, is the
+		// base of the context of its requests.
+		connContext := s.internalServer.H3.ConnContext
 		s.internalServer.H3.ConnContext = func(ctx context.Context, c *quicgo_quicgo.Conn) context.Context {
 			if stored, ok := s.connContexts.Load(c); ok {
-				return stored.(context.Context)
+				ctx = stored.(context.Context)
+			}
+			if connContext != nil {
+				ctx = connContext(ctx, c)
 			}
 			return ctx
 		}
@@ -7,6 +7,7 @@ import (
 
 	quicgo_webtransportgo "github.com/okdaichi/webtransport-go"
 	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/quicgo"
 	"github.com/qumo-dev/gomoqt/transport"
 )
 
@@ -78,3 +79,27 @@ func (conn *sessionWrapper) Subprotocol() string {
 func (conn *sessionWrapper) ConnectionStats() quic.ConnectionStats {
 	return conn.sess.ConnectionStats()
 }
+
+var _ transport.DatagramSizeConn = (*sessionWrapper)(nil)
+
+// SendDatagram implements transport.DatagramConn.
+func (conn *sessionWrapper) SendDatagram(b []byte) error {
+	return conn.sess.SendDatagram(b)
+}
+
+// ReceiveDatagram implements transport.DatagramConn.
+func (conn *sessionWrapper) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	return conn.sess.ReceiveDatagram(ctx)
+}
+
+// maxQuarterStreamIDLen is the largest number of bytes HTTP datagrams spend
+// on the quarter stream ID of the session ahead of the payload.
+const maxQuarterStreamIDLen = 8
+
+// MaxDatagramSize implements transport.DatagramSizeConn. The limit reported
+// by quic-go includes the quarter stream ID, so the size is conservative by
+// up to a few bytes.
+func (conn *sessionWrapper) MaxDatagramSize() int64 {
+	size := quicgo.MaxDatagramSize(conn.sess.SendDatagram)
+	return max(size-maxQuarterStreamIDLen, 0)
+}
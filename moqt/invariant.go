@@ -0,0 +1,47 @@
+package moqt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Invariant checks catch corruption of internal protocol state, such as a
+// reused subscribe ID or an announcement sent twice, at the point where it
+// happens instead of as a confusing failure later on. They are compiled in
+// only with the moqtdebug build tag:
+//
+//	go test -tags moqtdebug ./...
+//
+// A check is written as
+//
+//	if invariantsEnabled && !cond {
+//		invariantViolated("description", "key", value, ...)
+//	}
+//
+// so that it compiles to nothing in production builds, where
+// invariantsEnabled is a false constant.
+//
+// Invariants only cover state owned by this package. Malformed input from the
+// peer is a protocol error and must be handled, not asserted.
+
+// invariantViolation is the panic value of a violated invariant.
+type invariantViolation struct {
+	msg   string
+	attrs []any
+}
+
+func (v *invariantViolation) Error() string {
+	var sb strings.Builder
+	sb.WriteString("moqt: invariant violated: ")
+	sb.WriteString(v.msg)
+	for i := 0; i+1 < len(v.attrs); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", v.attrs[i], v.attrs[i+1])
+	}
+	return sb.String()
+}
+
+// invariantViolated panics with msg and the key-value pairs in attrs, which
+// describe the state that broke the invariant.
+func invariantViolated(msg string, attrs ...any) {
+	panic(&invariantViolation{msg: msg, attrs: attrs})
+}
@@ -0,0 +1,31 @@
+//go:build moqtdebug
+
+package moqt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_AddTrackReader_ReusedSubscribeID(t *testing.T) {
+	sess := newSession(&FakeStreamConn{}, NewTrackMux(0), nil, nil, nil, nil, nil, nil)
+	defer sess.CloseWithError(NoError, "")
+
+	first, _ := newTestTrackReader(t)
+	second, _ := newTestTrackReader(t)
+	sess.addTrackReader(1, first)
+
+	assert.PanicsWithError(t,
+		"moqt: invariant violated: subscribe ID reused subscribe_id=1 broadcast_path=/test track_name=video existing_broadcast_path=/test existing_track_name=video",
+		func() { sess.addTrackReader(1, second) })
+}
+
+func TestGroupWriterManager_AddGroup_Closed(t *testing.T) {
+	m := newGroupWriterManager()
+	m.close()
+
+	assert.Panics(t, func() {
+		newGroupWriter(&FakeQUICSendStream{}, 1, m)
+	})
+}
@@ -0,0 +1,6 @@
+//go:build !moqtdebug
+
+package moqt
+
+// invariantsEnabled disables the invariant checks in production builds.
+const invariantsEnabled = false
@@ -0,0 +1,7 @@
+//go:build moqtdebug
+
+package moqt
+
+// invariantsEnabled enables the invariant checks in builds with the moqtdebug
+// tag.
+const invariantsEnabled = true
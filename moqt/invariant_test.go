@@ -0,0 +1,49 @@
+package moqt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvariantViolation_Error(t *testing.T) {
+	tests := map[string]struct {
+		msg   string
+		attrs []any
+		want  string
+	}{
+		"no attributes": {
+			msg:  "counter wrapped",
+			want: "moqt: invariant violated: counter wrapped",
+		},
+		"attributes": {
+			msg:   "subscribe ID reused",
+			attrs: []any{"subscribe_id", SubscribeID(3), "broadcast_path", BroadcastPath("/live")},
+			want:  "moqt: invariant violated: subscribe ID reused subscribe_id=3 broadcast_path=/live",
+		},
+		"odd attribute is ignored": {
+			msg:   "state",
+			attrs: []any{"key", 1, "dangling"},
+			want:  "moqt: invariant violated: state key=1",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := &invariantViolation{msg: tt.msg, attrs: tt.attrs}
+			assert.Equal(t, tt.want, v.Error())
+		})
+	}
+}
+
+func TestInvariantViolated(t *testing.T) {
+	defer func() {
+		v, ok := recover().(*invariantViolation)
+		require.True(t, ok, "expected an invariantViolation panic")
+		assert.Equal(t, "subscribe ID reused", v.msg)
+		assert.Equal(t, []any{"subscribe_id", SubscribeID(1)}, v.attrs)
+	}()
+
+	invariantViolated("subscribe ID reused", "subscribe_id", SubscribeID(1))
+}
@@ -0,0 +1,18 @@
+// Package jwtauth provides a moqt.Authorizer that validates JSON Web Tokens,
+// so that WebTransport sessions and subscriptions are admitted by a token
+// issued by an identity provider.
+//
+//	auth := &jwtauth.Authorizer{
+//		JWKSURL:  "https://idp.example.com/.well-known/jwks.json",
+//		Audience: "moq.example.com",
+//	}
+//
+//	server := &moqt.Server{Addr: ":4433", TLSConfig: tlsConfig, Authorizer: auth}
+//
+// Session tokens are taken from the Authorization header or the token query
+// parameter of the WebTransport request. The claims of a valid token are
+// available to handlers through ClaimsFromContext(sess.Context()).
+//
+// To protect only some WebTransport paths, set the Authorizer on the
+// moqt.WebTransportHandler of those paths instead of on the Server.
+package jwtauth
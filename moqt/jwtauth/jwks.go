@@ -0,0 +1,199 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+
+	// minJWKSRefreshInterval bounds the refreshes triggered by unknown key
+	// IDs, so that forged tokens cannot make the Authorizer hammer the
+	// identity provider.
+	minJWKSRefreshInterval = time.Minute
+
+	// maxJWKSSize bounds the size of a fetched key set.
+	maxJWKSSize = 1 << 20
+)
+
+var errUnknownKey = errors.New("jwtauth: unknown key ID")
+
+// keySet is a fetched JSON Web Key Set.
+type keySet struct {
+	keys    map[string]any // by key ID
+	fetched time.Time
+}
+
+// jwk is a JSON Web Key (RFC 7517) holding a public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwksKey returns the public key of the key set with the given ID, fetching
+// the key set if it is missing, stale or lacks the key. A token without a
+// key ID matches the only key of a set with one key.
+func (a *Authorizer) jwksKey(ctx context.Context, kid string) (any, error) {
+	a.jwksMu.Lock()
+	defer a.jwksMu.Unlock()
+
+	refresh := a.JWKSRefreshInterval
+	if refresh <= 0 {
+		refresh = defaultJWKSRefreshInterval
+	}
+	if a.jwks == nil || time.Since(a.jwks.fetched) > refresh {
+		if err := a.fetchJWKS(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	key, ok := a.jwks.lookup(kid)
+	if !ok && time.Since(a.jwks.fetched) > minJWKSRefreshInterval {
+		if err := a.fetchJWKS(ctx); err != nil {
+			return nil, err
+		}
+		key, ok = a.jwks.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnknownKey, kid)
+	}
+	return key, nil
+}
+
+func (s *keySet) lookup(kid string) (any, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// fetchJWKS replaces the key set with the one at JWKSURL while a.jwksMu is
+// held.
+func (a *Authorizer) fetchJWKS(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.JWKSURL, nil)
+	if err != nil {
+		return err
+	}
+	client := a.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip keys of unsupported types rather than rejecting the set.
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	a.jwks = &keySet{keys: keys, fetched: time.Now()}
+	return nil
+}
+
+// publicKey decodes the public key of k.
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("jwtauth: RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwtauth: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("jwtauth: invalid EC coordinates")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwtauth: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwtauth: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("jwtauth: unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
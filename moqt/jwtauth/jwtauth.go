@@ -0,0 +1,157 @@
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Claims are the claims of a validated token.
+type Claims = jwt.MapClaims
+
+// Authorizer is a moqt.Authorizer that validates JSON Web Tokens signed with
+// HMACKey (HS256, HS384, HS512) or with a key of the JSON Web Key Set at
+// JWKSURL (RS*, PS*, ES*, EdDSA).
+//
+// A WebTransport session is allowed if it presents a valid token; its claims
+// are added to the session context, and its "sub" claim is the principal of
+// the session for a moqt.ACL. A subscription is allowed if its own
+// token, or else the token of its session, is still valid, so that expiry is
+// enforced for the lifetime of the session. Native QUIC sessions cannot carry
+// a token and are denied unless AllowNativeQUIC is set.
+//
+// An Authorizer must not be copied or modified after first use.
+type Authorizer struct {
+	// HMACKey is the shared secret of HMAC-signed tokens.
+	HMACKey []byte
+
+	// JWKSURL is the URL of the JSON Web Key Set of asymmetrically signed
+	// tokens. Keys are selected by the "kid" header of the token.
+	JWKSURL string
+
+	// HTTPClient fetches JWKSURL. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// JWKSRefreshInterval is the maximum age of the fetched key set. A token
+	// with an unknown key ID also triggers a refresh, at most once per
+	// minute. Defaults to one hour.
+	JWKSRefreshInterval time.Duration
+
+	// Audience, if set, must be one of the "aud" claims of the token.
+	Audience string
+
+	// Issuer, if set, must be the "iss" claim of the token.
+	Issuer string
+
+	// Leeway is the clock skew allowed when checking "exp" and "nbf".
+	Leeway time.Duration
+
+	// Validate, if set, checks further claims of a token whose signature,
+	// audience, issuer and validity period are correct. A returned error
+	// denies the request.
+	Validate func(claims Claims) error
+
+	// AllowNativeQUIC admits native QUIC sessions, which have no setup
+	// exchange to carry a token. Their subscriptions must then carry one.
+	AllowNativeQUIC bool
+
+	jwksMu sync.Mutex
+	jwks   *keySet
+}
+
+var _ moqt.Authorizer = (*Authorizer)(nil)
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the claims of the token a session was authorized
+// with, given the session context.
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// AuthorizeSession implements moqt.Authorizer.
+func (a *Authorizer) AuthorizeSession(ctx context.Context, r *moqt.SessionAuthRequest) (context.Context, error) {
+	if r.Token == "" {
+		if r.Transport == "quic" && a.AllowNativeQUIC {
+			return ctx, nil
+		}
+		return ctx, fmt.Errorf("%w: missing token", moqt.ErrUnauthorized)
+	}
+	claims, err := a.Verify(ctx, r.Token)
+	if err != nil {
+		return ctx, err
+	}
+	if sub, _ := claims.GetSubject(); sub != "" {
+		ctx = moqt.WithPrincipal(ctx, sub)
+	}
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+// AuthorizeSubscribe implements moqt.Authorizer.
+func (a *Authorizer) AuthorizeSubscribe(ctx context.Context, r *moqt.SubscribeAuthRequest) error {
+	token := r.Token
+	if token == "" {
+		token = r.SessionToken
+	}
+	if token == "" {
+		return fmt.Errorf("%w: missing token", moqt.ErrUnauthorized)
+	}
+	_, err := a.Verify(ctx, token)
+	return err
+}
+
+// Verify parses token and returns its claims if it is valid. Invalid tokens
+// yield an error wrapping moqt.ErrUnauthorized; failures to fetch the key set
+// do not.
+func (a *Authorizer) Verify(ctx context.Context, token string) (Claims, error) {
+	var methods []string
+	if len(a.HMACKey) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if a.JWKSURL != "" {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(a.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if a.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.Audience))
+	}
+	if a.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.Issuer))
+	}
+
+	var keyErr error
+	claims := Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			return a.HMACKey, nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, err := a.jwksKey(ctx, kid)
+		keyErr = err
+		return key, err
+	}, opts...)
+	if err != nil {
+		if keyErr != nil && !errors.Is(keyErr, errUnknownKey) {
+			return nil, fmt.Errorf("jwtauth: %w", keyErr)
+		}
+		return nil, fmt.Errorf("%w: %w", moqt.ErrUnauthorized, err)
+	}
+
+	if a.Validate != nil {
+		if err := a.Validate(claims); err != nil {
+			return nil, fmt.Errorf("%w: %w", moqt.ErrUnauthorized, err)
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,358 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testHMACKey = []byte("secret")
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "alice",
+		"aud": "moq.example.com",
+		"iss": "idp.example.com",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func signHMAC(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testHMACKey)
+	require.NoError(t, err)
+	return token
+}
+
+func signKey(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	point, _ := key.Bytes()
+	size := (len(point) - 1) / 2
+	return map[string]string{
+		"kty": "EC", "kid": kid, "crv": key.Curve.Params().Name,
+		"x": b64(point[1 : 1+size]), "y": b64(point[1+size:]),
+	}
+}
+
+// jwksServer serves the keys returned by keys and counts the fetches.
+func jwksServer(t *testing.T, keys func() []map[string]string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys()})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func TestAuthorizer_Verify_HMAC(t *testing.T) {
+	tests := map[string]struct {
+		token    func(t *testing.T) string
+		validate func(Claims) error
+		wantErr  bool
+	}{
+		"valid": {
+			token: func(t *testing.T) string { return signHMAC(t, validClaims()) },
+		},
+		"expired": {
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				return signHMAC(t, claims)
+			},
+			wantErr: true,
+		},
+		"no expiry": {
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				delete(claims, "exp")
+				return signHMAC(t, claims)
+			},
+			wantErr: true,
+		},
+		"wrong audience": {
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims["aud"] = "other.example.com"
+				return signHMAC(t, claims)
+			},
+			wantErr: true,
+		},
+		"wrong issuer": {
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims["iss"] = "evil.example.com"
+				return signHMAC(t, claims)
+			},
+			wantErr: true,
+		},
+		"bad signature": {
+			token: func(t *testing.T) string {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("other"))
+				require.NoError(t, err)
+				return token
+			},
+			wantErr: true,
+		},
+		"unexpected method": {
+			token: func(t *testing.T) string {
+				key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				require.NoError(t, err)
+				return signKey(t, jwt.SigningMethodES256, "", key, validClaims())
+			},
+			wantErr: true,
+		},
+		"malformed": {
+			token:   func(t *testing.T) string { return "not-a-token" },
+			wantErr: true,
+		},
+		"rejected by Validate": {
+			token: func(t *testing.T) string { return signHMAC(t, validClaims()) },
+			validate: func(claims Claims) error {
+				if claims["sub"] != "bob" {
+					return errors.New("not bob")
+				}
+				return nil
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			auth := &Authorizer{
+				HMACKey:  testHMACKey,
+				Audience: "moq.example.com",
+				Issuer:   "idp.example.com",
+				Validate: tt.validate,
+			}
+			claims, err := auth.Verify(t.Context(), tt.token(t))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, moqt.ErrUnauthorized)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "alice", claims["sub"])
+		})
+	}
+}
+
+func TestAuthorizer_Verify_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	srv, _ := jwksServer(t, func() []map[string]string {
+		return []map[string]string{
+			rsaJWK("rsa", &rsaKey.PublicKey),
+			ecJWK("ec", &ecKey.PublicKey),
+			{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)},
+			{"kty": "oct", "kid": "skipped", "k": b64(testHMACKey)},
+		}
+	})
+	auth := &Authorizer{JWKSURL: srv.URL, Audience: "moq.example.com"}
+
+	tests := map[string]struct {
+		method  jwt.SigningMethod
+		kid     string
+		key     any
+		wantErr bool
+	}{
+		"RS256":              {method: jwt.SigningMethodRS256, kid: "rsa", key: rsaKey},
+		"PS256":              {method: jwt.SigningMethodPS256, kid: "rsa", key: rsaKey},
+		"ES384":              {method: jwt.SigningMethodES384, kid: "ec", key: ecKey},
+		"EdDSA":              {method: jwt.SigningMethodEdDSA, kid: "ed", key: edKey},
+		"key of another kid": {method: jwt.SigningMethodRS256, kid: "ec", key: rsaKey, wantErr: true},
+		"unknown kid":        {method: jwt.SigningMethodRS256, kid: "missing", key: rsaKey, wantErr: true},
+		"HMAC not allowed":   {method: jwt.SigningMethodHS256, kid: "skipped", key: testHMACKey, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := auth.Verify(t.Context(), signKey(t, tt.method, tt.kid, tt.key, validClaims()))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, moqt.ErrUnauthorized)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthorizer_Verify_JWKSRefresh(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var rotated atomic.Bool
+	srv, fetches := jwksServer(t, func() []map[string]string {
+		if rotated.Load() {
+			return []map[string]string{ecJWK("new", &newKey.PublicKey)}
+		}
+		return []map[string]string{ecJWK("old", &oldKey.PublicKey)}
+	})
+	auth := &Authorizer{JWKSURL: srv.URL}
+
+	_, err = auth.Verify(t.Context(), signKey(t, jwt.SigningMethodES256, "old", oldKey, validClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// The key set is cached, and unknown key IDs do not refresh it too often.
+	rotated.Store(true)
+	_, err = auth.Verify(t.Context(), signKey(t, jwt.SigningMethodES256, "new", newKey, validClaims()))
+	assert.ErrorIs(t, err, moqt.ErrUnauthorized)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// Once the rate limit has passed, an unknown key ID refreshes the set.
+	auth.jwksMu.Lock()
+	auth.jwks.fetched = time.Now().Add(-2 * minJWKSRefreshInterval)
+	auth.jwksMu.Unlock()
+	_, err = auth.Verify(t.Context(), signKey(t, jwt.SigningMethodES256, "new", newKey, validClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestAuthorizer_Verify_JWKSUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	auth := &Authorizer{JWKSURL: srv.URL}
+	_, err = auth.Verify(t.Context(), signKey(t, jwt.SigningMethodES256, "k", key, validClaims()))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, moqt.ErrUnauthorized)
+}
+
+func TestAuthorizer_AuthorizeSession(t *testing.T) {
+	tests := map[string]struct {
+		allowQUIC   bool
+		req         *moqt.SessionAuthRequest
+		wantErr     bool
+		wantSubject any
+	}{
+		"valid token": {
+			req:         &moqt.SessionAuthRequest{Transport: "webtransport", Token: signHMAC(t, validClaims())},
+			wantSubject: "alice",
+		},
+		"invalid token": {
+			req:     &moqt.SessionAuthRequest{Transport: "webtransport", Token: "bad"},
+			wantErr: true,
+		},
+		"missing token": {
+			req:     &moqt.SessionAuthRequest{Transport: "webtransport"},
+			wantErr: true,
+		},
+		"native QUIC denied": {
+			req:     &moqt.SessionAuthRequest{Transport: "quic"},
+			wantErr: true,
+		},
+		"native QUIC allowed": {
+			allowQUIC: true,
+			req:       &moqt.SessionAuthRequest{Transport: "quic"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			auth := &Authorizer{HMACKey: testHMACKey, AllowNativeQUIC: tt.allowQUIC}
+			ctx, err := auth.AuthorizeSession(context.Background(), tt.req)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, moqt.ErrUnauthorized)
+				return
+			}
+			require.NoError(t, err)
+			claims, ok := ClaimsFromContext(ctx)
+			assert.Equal(t, tt.wantSubject != nil, ok)
+			if ok {
+				assert.Equal(t, tt.wantSubject, claims["sub"])
+				assert.Equal(t, tt.wantSubject, moqt.PrincipalFromContext(ctx))
+			}
+		})
+	}
+}
+
+func TestAuthorizer_AuthorizeSubscribe(t *testing.T) {
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	tests := map[string]struct {
+		req     *moqt.SubscribeAuthRequest
+		wantErr bool
+	}{
+		"subscribe token": {
+			req: &moqt.SubscribeAuthRequest{Token: signHMAC(t, validClaims())},
+		},
+		"session token": {
+			req: &moqt.SubscribeAuthRequest{SessionToken: signHMAC(t, validClaims())},
+		},
+		"subscribe token preferred": {
+			req: &moqt.SubscribeAuthRequest{Token: signHMAC(t, validClaims()), SessionToken: signHMAC(t, expired)},
+		},
+		"expired session token": {
+			req:     &moqt.SubscribeAuthRequest{SessionToken: signHMAC(t, expired)},
+			wantErr: true,
+		},
+		"no token": {
+			req:     &moqt.SubscribeAuthRequest{},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			auth := &Authorizer{HMACKey: testHMACKey}
+			err := auth.AuthorizeSubscribe(context.Background(), tt.req)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, moqt.ErrUnauthorized)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package moqt
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+// keepAlive tracks when the peer of a session was last heard from on the
+// Ping streams.
+type keepAlive struct {
+	// lastHeard is the time of the last PING received or echoed by the
+	// peer, in Unix nanoseconds.
+	lastHeard atomic.Int64
+}
+
+// heard records that the peer was heard from at now.
+func (k *keepAlive) heard(now time.Time) {
+	k.lastHeard.Store(now.UnixNano())
+}
+
+// idleFor returns the time since the peer was last heard from.
+func (k *keepAlive) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, k.lastHeard.Load()))
+}
+
+// runKeepAlive opens a Ping stream and sends a PING every interval, until
+// the session ends. If idleTimeout is positive, the session is closed with
+// IdleTimeoutErrorCode once the peer has been silent for longer.
+//
+// It only runs on sessions that negotiated VersionLite04Ext. A peer that
+// does not support keep-alive resets the stream; keep-alive is then
+// disabled for the session.
+func (sess *Session) runKeepAlive(interval, idleTimeout time.Duration) {
+	stream, err := sess.openStream()
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	if err := message.StreamTypePing.Encode(stream); err != nil {
+		cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
+		return
+	}
+
+	sess.streamTasks.Go("ping response reader", func() {
+		for {
+			var pm message.PingMessage
+			if err := pm.Decode(stream); err != nil {
+				return
+			}
+			sess.keepAlive.heard(time.Now())
+		}
+	}, func() {
+		cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	streamCtx := stream.Context()
+	for seq := uint64(0); ; seq++ {
+		select {
+		case <-ticker.C:
+		case <-streamCtx.Done():
+			if sess.ctx.Err() == nil {
+				sess.logError("keep-alive stream ended", Cause(streamCtx))
+			}
+			return
+		case <-sess.ctx.Done():
+			return
+		}
+
+		if idleTimeout > 0 && sess.keepAlive.idleFor(time.Now()) > idleTimeout {
+			sess.closeIdle()
+			return
+		}
+
+		if err := (message.PingMessage{Sequence: seq}).Encode(stream); err != nil {
+			if sess.ctx.Err() == nil {
+				sess.logError("failed to send PING message", err)
+			}
+			return
+		}
+	}
+}
+
+// closeIdle closes the session whose peer went silent. Like failSession, it
+// closes the connection without waiting for the loops of the session, as it
+// runs in one of them.
+func (sess *Session) closeIdle() {
+	sess.isTerminating.Store(true)
+	_ = sess.conn.CloseWithError(transport.ConnErrorCode(IdleTimeoutErrorCode), IdleTimeoutErrorCode.String())
+}
+
+// handlePingStream echoes the PINGs of the peer until the stream ends.
+func (sess *Session) handlePingStream(stream transport.Stream) error {
+	for {
+		var pm message.PingMessage
+		if err := pm.Decode(stream); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		sess.keepAlive.heard(time.Now())
+
+		if err := pm.Encode(stream); err != nil {
+			return err
+		}
+	}
+}
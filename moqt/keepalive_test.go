@@ -0,0 +1,223 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPingStream returns the Ping stream opened by a session to a peer that
+// echoes every PING if echo is set, or stays silent. pings counts the PINGs
+// the session sent.
+func newPingStream(echo bool, pings *atomic.Int64) *FakeQUICStream {
+	pr, pw := io.Pipe()
+	stream := &FakeQUICStream{}
+	context.AfterFunc(stream.Context(), func() {
+		_ = pw.Close()
+	})
+
+	header := true
+	stream.WriteFunc = func(p []byte) (int, error) {
+		if header {
+			// The stream type.
+			header = false
+			return len(p), nil
+		}
+		pings.Add(1)
+		if echo {
+			return pw.Write(p)
+		}
+		return len(p), nil
+	}
+	stream.ReadFunc = pr.Read
+	return stream
+}
+
+func TestSession_KeepAlive(t *testing.T) {
+	tests := map[string]struct {
+		echo     bool
+		wantIdle bool
+	}{
+		"peer answers": {
+			echo: true,
+		},
+		"peer silent": {
+			echo:     false,
+			wantIdle: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var pings atomic.Int64
+			conn := &FakeStreamConn{
+				OpenStreamFunc: func() (transport.Stream, error) {
+					return newPingStream(tt.echo, &pings), nil
+				},
+			}
+			extendedConn(conn)
+			sess := newSession(conn, NewTrackMux(0), nil, &Config{
+				KeepAliveInterval: 10 * time.Millisecond,
+				IdleTimeout:       100 * time.Millisecond,
+			}, nil, nil, nil, nil)
+			defer sess.CloseWithError(NoError, "")
+
+			select {
+			case <-sess.Context().Done():
+				require.True(t, tt.wantIdle, "session closed: %v", Cause(sess.Context()))
+				err := Cause(sess.Context())
+				assert.ErrorIs(t, err, ErrSessionIdle)
+				sessErr, ok := errors.AsType[*SessionError](err)
+				require.True(t, ok)
+				assert.Equal(t, IdleTimeoutErrorCode, sessErr.SessionErrorCode())
+				assert.True(t, sess.terminating())
+			case <-time.After(500 * time.Millisecond):
+				require.False(t, tt.wantIdle, "session was not closed")
+			}
+			assert.Greater(t, pings.Load(), int64(3))
+		})
+	}
+}
+
+func TestSession_KeepAlive_Disabled(t *testing.T) {
+	var opened atomic.Bool
+	conn := &FakeStreamConn{
+		OpenStreamFunc: func() (transport.Stream, error) {
+			opened.Store(true)
+			return &FakeQUICStream{}, nil
+		},
+	}
+	sess := newSession(conn, NewTrackMux(0), nil, &Config{}, nil, nil, nil, nil)
+	defer sess.CloseWithError(NoError, "")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, opened.Load(), "no stream should be opened without keep-alive")
+	assert.NoError(t, sess.Context().Err())
+}
+
+func TestSession_HandlePingStream(t *testing.T) {
+	var in bytes.Buffer
+	for seq := range uint64(3) {
+		require.NoError(t, message.PingMessage{Sequence: seq}.Encode(&in))
+	}
+	var out bytes.Buffer
+	stream := &FakeQUICStream{
+		ReadFunc:  in.Read,
+		WriteFunc: out.Write,
+	}
+
+	sess := newTestSession(&FakeStreamConn{})
+	defer sess.CloseWithError(NoError, "")
+
+	require.NoError(t, sess.handlePingStream(stream))
+	for seq := range uint64(3) {
+		var pm message.PingMessage
+		require.NoError(t, pm.Decode(&out))
+		assert.Equal(t, seq, pm.Sequence)
+	}
+	assert.Zero(t, out.Len())
+	assert.Less(t, sess.keepAlive.idleFor(time.Now()), time.Second)
+}
+
+func TestConfig_KeepAliveInterval(t *testing.T) {
+	tests := map[string]struct {
+		config *Config
+		want   time.Duration
+	}{
+		"nil config": {
+			config: nil,
+			want:   0,
+		},
+		"disabled": {
+			config: &Config{},
+			want:   0,
+		},
+		"explicit interval": {
+			config: &Config{KeepAliveInterval: time.Second, IdleTimeout: 30 * time.Second},
+			want:   time.Second,
+		},
+		"derived from idle timeout": {
+			config: &Config{IdleTimeout: 30 * time.Second},
+			want:   10 * time.Second,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.config.keepAliveInterval())
+		})
+	}
+}
+
+func TestSessionError_IsSessionIdle(t *testing.T) {
+	tests := map[string]struct {
+		code SessionErrorCode
+		want bool
+	}{
+		"idle timeout": {
+			code: IdleTimeoutErrorCode,
+			want: true,
+		},
+		"other code": {
+			code: InternalSessionErrorCode,
+			want: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := &SessionError{ApplicationError: &transport.ApplicationError{
+				ErrorCode: transport.ApplicationErrorCode(tt.code),
+				Remote:    true,
+			}}
+			assert.Equal(t, tt.want, errors.Is(err, ErrSessionIdle))
+		})
+	}
+}
+
+func TestSession_KeepAlive_NotExtended(t *testing.T) {
+	var opened atomic.Bool
+	conn := &FakeStreamConn{
+		OpenStreamFunc: func() (transport.Stream, error) {
+			opened.Store(true)
+			return &FakeQUICStream{}, nil
+		},
+	}
+	sess := newSession(conn, NewTrackMux(0), nil, &Config{
+		KeepAliveInterval: 10 * time.Millisecond,
+		IdleTimeout:       50 * time.Millisecond,
+	}, nil, nil, nil, nil)
+	defer sess.CloseWithError(NoError, "")
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, opened.Load(), "no Ping stream should be opened to a moq-lite-04 peer")
+	assert.NoError(t, sess.Context().Err(), "the session is left to the QUIC idle timeout")
+}
+
+func TestSession_ProcessBiStream_Ping_NotExtended(t *testing.T) {
+	sess := newTestSession(&FakeStreamConn{})
+	defer sess.CloseWithError(NoError, "")
+
+	var in, out bytes.Buffer
+	require.NoError(t, message.StreamTypePing.Encode(&in))
+	require.NoError(t, message.PingMessage{Sequence: 1}.Encode(&in))
+	var canceled transport.StreamErrorCode
+	stream := &FakeQUICStream{
+		ReadFunc:        in.Read,
+		WriteFunc:       out.Write,
+		CancelWriteFunc: func(code transport.StreamErrorCode) { canceled = code },
+	}
+
+	sess.processBiStream(stream, func() {})
+	assert.Equal(t, transport.StreamErrorCode(InternalSessionErrorCode), canceled)
+	assert.Zero(t, out.Len(), "the PING should not be echoed")
+}
@@ -0,0 +1,181 @@
+package moqt
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/quicgo"
+)
+
+// ListenConfig is an address a Server listens on, with optional
+// configurations overriding the ones of the Server.
+type ListenConfig struct {
+	// Address to listen on, in the form "host:port".
+	Addr string
+
+	// TLSConfig overrides the TLS configuration of the Server for this
+	// address. It is adjusted like Server.TLSConfig.
+	TLSConfig *tls.Config
+
+	// QUICConfig overrides Server.QUICConfig for this address. Datagrams and
+	// stream resets with partial delivery are enabled either way.
+	QUICConfig *quic.Config
+}
+
+// ListenerErrorPolicy decides how a Server serving several listeners reacts
+// when one of them fails to start or to accept connections.
+type ListenerErrorPolicy int
+
+const (
+	// ListenerErrorStop closes every listener when one fails, and returns its
+	// error. It is the default.
+	ListenerErrorStop ListenerErrorPolicy = iota
+
+	// ListenerErrorContinue logs the failure and keeps serving on the other
+	// listeners. The errors are returned, joined, once every listener has
+	// stopped.
+	ListenerErrorContinue
+)
+
+// String returns the name of the policy.
+func (p ListenerErrorPolicy) String() string {
+	switch p {
+	case ListenerErrorStop:
+		return "stop"
+	case ListenerErrorContinue:
+		return "continue"
+	default:
+		return fmt.Sprintf("ListenerErrorPolicy(%d)", int(p))
+	}
+}
+
+// Serve accepts connections on each of lns concurrently, like
+// ServeQUICListener, until the server shuts down or, following
+// ListenerErrorPolicy, a listener fails. It always returns a non-nil error;
+// after Shutdown or Close, the returned error is ErrServerClosed.
+func (s *Server) Serve(lns ...QUICListener) error {
+	switch len(lns) {
+	case 0:
+		return errors.New("moqt: no listener to serve")
+	case 1:
+		return s.ServeQUICListener(lns[0])
+	}
+
+	s.init()
+
+	errCh := make(chan error, len(lns))
+	for _, ln := range lns {
+		s.tasks.Go("listener", func() {
+			err := s.ServeQUICListener(ln)
+			if err != nil && !errors.Is(err, ErrServerClosed) {
+				err = fmt.Errorf("listener at %s: %w", ln.Addr(), err)
+			}
+			errCh <- err
+		}, func() {
+			errCh <- fmt.Errorf("listener at %s failed", ln.Addr())
+		})
+	}
+
+	var (
+		errs    []error
+		stopped bool
+	)
+	for range lns {
+		err := <-errCh
+		if errors.Is(err, ErrServerClosed) || stopped {
+			// The listener was closed by the server.
+			continue
+		}
+		errs = append(errs, err)
+		if s.ListenerErrorPolicy == ListenerErrorStop {
+			stopped = true
+			for _, ln := range lns {
+				_ = ln.Close()
+			}
+		} else if logger := s.Logger; logger != nil {
+			logger.Error("listener failed", "error", err)
+		}
+	}
+	if len(errs) == 0 {
+		return ErrServerClosed
+	}
+	return errors.Join(errs...)
+}
+
+// listenAndServe listens on the addresses of the server, with tlsConfig for
+// the ones without their own, and serves them.
+func (s *Server) listenAndServe(tlsConfig *tls.Config) error {
+	var (
+		lns  []QUICListener
+		errs []error
+	)
+	for _, lc := range s.listenConfigs() {
+		ln, err := s.listen(lc, tlsConfig)
+		if err != nil {
+			if s.ListenerErrorPolicy == ListenerErrorStop {
+				for _, ln := range lns {
+					_ = ln.Close()
+				}
+				return err
+			}
+			if logger := s.Logger; logger != nil {
+				logger.Error("failed to listen", "address", lc.Addr, "error", err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		lns = append(lns, ln)
+	}
+	if len(lns) == 0 {
+		return errors.Join(errs...)
+	}
+	if len(errs) == 0 {
+		return s.Serve(lns...)
+	}
+	return errors.Join(append(errs, s.Serve(lns...))...)
+}
+
+// listenConfigs returns the addresses to listen on: Addr, unless it is empty
+// and Listeners is not, and Listeners.
+func (s *Server) listenConfigs() []ListenConfig {
+	if s.Addr == "" && len(s.Listeners) > 0 {
+		return s.Listeners
+	}
+	return append([]ListenConfig{{Addr: s.Addr}}, s.Listeners...)
+}
+
+// listen starts a QUIC listener at the address of lc.
+func (s *Server) listen(lc ListenConfig, tlsConfig *tls.Config) (QUICListener, error) {
+	if lc.TLSConfig != nil {
+		tlsConfig = lc.TLSConfig
+	}
+	tlsConfig, err := s.serverTLSConfig(tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure WebTransport required QUIC flags are enabled.
+	quicConf := lc.QUICConfig
+	if quicConf == nil {
+		quicConf = s.QUICConfig
+	}
+	if quicConf == nil {
+		quicConf = &quic.Config{}
+	} else {
+		quicConf = quicConf.Clone()
+	}
+	quicConf.EnableDatagrams = true
+	quicConf.EnableStreamResetPartialDelivery = true
+
+	listenFunc := s.ListenFunc
+	if listenFunc == nil {
+		listenFunc = quicgo.ListenAddrEarly
+	}
+	ln, err := listenFunc(lc.Addr, tlsConfig, quicConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start QUIC listener at %s: %w", lc.Addr, err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,234 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/quicgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ListenAndServe_Listeners(t *testing.T) {
+	ipv6TLS := &tls.Config{ServerName: "ipv6"}
+	altQUIC := &quic.Config{MaxIdleTimeout: time.Minute}
+
+	tests := map[string]struct {
+		addr      string
+		listeners []ListenConfig
+		want      []string
+	}{
+		"addr only": {
+			addr: "127.0.0.1:4433",
+			want: []string{"127.0.0.1:4433"},
+		},
+		"dual stack": {
+			addr:      "127.0.0.1:4433",
+			listeners: []ListenConfig{{Addr: "[::1]:4433", TLSConfig: ipv6TLS}},
+			want:      []string{"127.0.0.1:4433", "[::1]:4433"},
+		},
+		"listeners only": {
+			listeners: []ListenConfig{{Addr: "127.0.0.1:4433"}, {Addr: "127.0.0.1:8443", QUICConfig: altQUIC}},
+			want:      []string{"127.0.0.1:4433", "127.0.0.1:8443"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				addrs []string
+				tlss  = make(map[string]*tls.Config)
+				quics = make(map[string]*quic.Config)
+			)
+			s := &Server{
+				Addr:      tt.addr,
+				Listeners: tt.listeners,
+				TLSConfig: &tls.Config{ServerName: "default"},
+				ListenFunc: func(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					addrs = append(addrs, addr)
+					tlss[addr] = tlsConfig
+					quics[addr] = quicConfig
+					return &FakeEarlyListener{}, nil
+				},
+			}
+
+			s.init()
+			errCh := make(chan error, 1)
+			go func() { errCh <- s.ListenAndServe() }()
+			require.Eventually(t, func() bool {
+				s.listenerMu.RLock()
+				defer s.listenerMu.RUnlock()
+				return len(s.listeners) == len(tt.want)
+			}, time.Second, 5*time.Millisecond)
+			require.NoError(t, s.Close())
+			assert.ErrorIs(t, <-errCh, ErrServerClosed)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tt.want, addrs)
+			for _, lc := range tt.listeners {
+				if lc.TLSConfig != nil {
+					assert.Equal(t, lc.TLSConfig.ServerName, tlss[lc.Addr].ServerName)
+				} else {
+					assert.Equal(t, "default", tlss[lc.Addr].ServerName)
+				}
+				assert.Equal(t, []string{VersionLite04Ext, NextProtoMOQ, NextProtoH3}, tlss[lc.Addr].NextProtos)
+				if lc.QUICConfig != nil {
+					assert.Equal(t, lc.QUICConfig.MaxIdleTimeout, quics[lc.Addr].MaxIdleTimeout)
+				}
+				assert.True(t, quics[lc.Addr].EnableDatagrams)
+				assert.True(t, quics[lc.Addr].EnableStreamResetPartialDelivery)
+			}
+		})
+	}
+}
+
+func TestServer_ListenAndServe_ListenFailure(t *testing.T) {
+	errListen := errors.New("address in use")
+
+	tests := map[string]struct {
+		policy ListenerErrorPolicy
+		// serving reports whether the other listener is served.
+		serving bool
+	}{
+		"stop": {
+			policy: ListenerErrorStop,
+		},
+		"continue": {
+			policy:  ListenerErrorContinue,
+			serving: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ln := &FakeEarlyListener{}
+			s := &Server{
+				Addr:                "127.0.0.1:4433",
+				Listeners:           []ListenConfig{{Addr: "[::1]:4433"}},
+				ListenerErrorPolicy: tt.policy,
+				TLSConfig:           &tls.Config{},
+				ListenFunc: func(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error) {
+					if addr == "[::1]:4433" {
+						return nil, errListen
+					}
+					return ln, nil
+				},
+			}
+
+			s.init()
+			errCh := make(chan error, 1)
+			go func() { errCh <- s.ListenAndServe() }()
+			if tt.serving {
+				require.Eventually(t, func() bool {
+					s.listenerMu.RLock()
+					defer s.listenerMu.RUnlock()
+					_, ok := s.listeners[ln]
+					return ok
+				}, time.Second, 5*time.Millisecond)
+				require.NoError(t, s.Close())
+			}
+
+			err := <-errCh
+			assert.ErrorIs(t, err, errListen)
+			assert.Equal(t, tt.serving, errors.Is(err, ErrServerClosed))
+			// The listener that started is closed either way.
+			_, acceptErr := ln.Accept(t.Context())
+			assert.ErrorIs(t, acceptErr, ErrServerClosed)
+		})
+	}
+}
+
+func TestServer_Serve(t *testing.T) {
+	errAccept := errors.New("accept failed")
+
+	tests := map[string]struct {
+		policy ListenerErrorPolicy
+		// stopped reports whether Serve returns without Close.
+		stopped bool
+	}{
+		"stop": {
+			policy:  ListenerErrorStop,
+			stopped: true,
+		},
+		"continue": {
+			policy: ListenerErrorContinue,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			healthy := &FakeEarlyListener{}
+			failing := &FakeEarlyListener{
+				AcceptFunc: func(ctx context.Context) (StreamConn, error) {
+					return nil, errAccept
+				},
+				AddrFunc: func() net.Addr {
+					return &net.UDPAddr{IP: net.IPv6loopback, Port: 4433}
+				},
+			}
+			s := &Server{ListenerErrorPolicy: tt.policy}
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- s.Serve(healthy, failing) }()
+
+			select {
+			case err := <-errCh:
+				require.True(t, tt.stopped, "Serve returned early: %v", err)
+				assert.ErrorIs(t, err, errAccept)
+				assert.Contains(t, err.Error(), "[::1]:4433")
+			case <-time.After(200 * time.Millisecond):
+				require.False(t, tt.stopped, "Serve did not return")
+				require.NoError(t, s.Close())
+				err := <-errCh
+				assert.ErrorIs(t, err, errAccept)
+			}
+			_, err := healthy.Accept(t.Context())
+			assert.ErrorIs(t, err, ErrServerClosed)
+		})
+	}
+}
+
+func TestServer_Serve_NoListener(t *testing.T) {
+	s := &Server{}
+	assert.Error(t, s.Serve())
+}
+
+func TestListenerErrorPolicy_String(t *testing.T) {
+	assert.Equal(t, "stop", ListenerErrorStop.String())
+	assert.Equal(t, "continue", ListenerErrorContinue.String())
+	assert.Equal(t, "ListenerErrorPolicy(7)", ListenerErrorPolicy(7).String())
+}
+
+// listenLoopback opens a UDP socket on 127.0.0.1 and returns its address
+// and a ListenFunc serving it, so that a server can be dialed on the address
+// without closing and reopening the socket.
+func listenLoopback(t *testing.T) (string, func(string, *tls.Config, *quic.Config) (QUICListener, error)) {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return conn.LocalAddr().String(), func(_ string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error) {
+		ln, tr, err := quicgo.ListenEarly(conn, tlsConfig, quicConfig)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() {
+			_ = tr.Close()
+		})
+		return ln, nil
+	}
+}
@@ -0,0 +1,28 @@
+// Package loadshed sheds load from a moqt.Server under CPU or memory
+// pressure while keeping established sessions and premium tracks alive.
+//
+// A Monitor samples resource pressure periodically. While CPU or memory use
+// is above its threshold, it escalates one Level per sample, shedding load
+// in priority order:
+//
+//  1. LevelRejectSessions: new sessions are rejected.
+//  2. LevelDropEnhancement: subscriptions to enhancement tracks, such as the
+//     upper layers of a layered video track, are closed and refused.
+//  3. LevelReduceCache: relay caches are reduced to their minimum.
+//
+// It recovers one level per sample once pressure is clearly below the
+// thresholds again. Every level change is reported as an Event.
+//
+//	r := relay.New(mux, nil)
+//	monitor := loadshed.New(&loadshed.Config{
+//		Enhancement: func(tw *moqt.TrackWriter) bool {
+//			return strings.HasPrefix(string(tw.TrackName), "video-enh")
+//		},
+//		Caches:  []loadshed.CacheLimiter{r},
+//		OnEvent: func(ev loadshed.Event) { log.Println(ev) },
+//	})
+//	go monitor.Run(ctx)
+//
+//	server.Use(monitor.Middleware())
+//	mux.Route(ctx, "/{path...}", monitor.TrackHandler(r.Handler(origin)))
+package loadshed
@@ -0,0 +1,308 @@
+package loadshed
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Level is a load shedding level. Each level also sheds the load of the
+// levels below it.
+type Level int
+
+const (
+	// LevelNormal sheds no load.
+	LevelNormal Level = iota
+
+	// LevelRejectSessions rejects new sessions.
+	LevelRejectSessions
+
+	// LevelDropEnhancement closes and refuses subscriptions to enhancement
+	// tracks.
+	LevelDropEnhancement
+
+	// LevelReduceCache reduces relay caches to Config.ReducedCacheGroups.
+	LevelReduceCache
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelNormal:
+		return "normal"
+	case LevelRejectSessions:
+		return "reject_sessions"
+	case LevelDropEnhancement:
+		return "drop_enhancement"
+	case LevelReduceCache:
+		return "reduce_cache"
+	default:
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+}
+
+// Sample is a measurement of resource pressure.
+type Sample struct {
+	// CPU is the fraction of the available CPU time in use, from 0 to 1.
+	CPU float64
+
+	// Memory is the fraction of the memory limit in use, from 0 to 1.
+	Memory float64
+}
+
+// Event reports a change of the shedding level of a Monitor.
+type Event struct {
+	Level    Level
+	Previous Level
+	Sample   Sample
+	Time     time.Time
+}
+
+// String returns a text for the event.
+func (ev Event) String() string {
+	return fmt.Sprintf("load shedding level %s -> %s (cpu: %.2f, memory: %.2f)", ev.Previous, ev.Level, ev.Sample.CPU, ev.Sample.Memory)
+}
+
+// CacheLimiter is a cache whose size can be reduced under pressure.
+// *relay.Relay implements it.
+type CacheLimiter interface {
+	// SetCacheGroups sets the number of groups cached per track.
+	// Zero restores the configured size.
+	SetCacheGroups(n int)
+}
+
+// recoveryRatio is the fraction of a threshold that use must fall below
+// before a level is left, so that the level does not flap around it.
+const recoveryRatio = 0.9
+
+// Config contains configuration options for a Monitor.
+type Config struct {
+	// Sampler measures resource pressure.
+	// If nil, a RuntimeSampler of the process is used.
+	Sampler Sampler
+
+	// Interval is the time between samples. If zero, defaults to 1s.
+	Interval time.Duration
+
+	// CPUThreshold is the CPU use above which load is shed.
+	// If zero, defaults to 0.85.
+	CPUThreshold float64
+
+	// MemoryThreshold is the memory use above which load is shed.
+	// If zero, defaults to 0.85.
+	MemoryThreshold float64
+
+	// Enhancement reports whether a served track is an enhancement track,
+	// which is dropped at LevelDropEnhancement. If nil, no track is.
+	Enhancement func(tw *moqt.TrackWriter) bool
+
+	// Caches are reduced at LevelReduceCache.
+	Caches []CacheLimiter
+
+	// ReducedCacheGroups is the number of groups cached per track at
+	// LevelReduceCache. If zero, defaults to 1.
+	ReducedCacheGroups int
+
+	// OnEvent, if set, is called on every level change.
+	OnEvent func(Event)
+
+	// Logger receives level changes. If nil, they are not logged.
+	Logger *slog.Logger
+}
+
+func (c *Config) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return time.Second
+}
+
+func (c *Config) cpuThreshold() float64 {
+	if c.CPUThreshold > 0 {
+		return c.CPUThreshold
+	}
+	return 0.85
+}
+
+func (c *Config) memoryThreshold() float64 {
+	if c.MemoryThreshold > 0 {
+		return c.MemoryThreshold
+	}
+	return 0.85
+}
+
+func (c *Config) reducedCacheGroups() int {
+	if c.ReducedCacheGroups > 0 {
+		return c.ReducedCacheGroups
+	}
+	return 1
+}
+
+// Monitor watches resource pressure and sheds load accordingly.
+//
+// All methods are safe for concurrent use.
+type Monitor struct {
+	config  Config
+	sampler Sampler
+
+	mu    sync.Mutex
+	level Level
+	// enhancements holds the writers of enhancement tracks being served.
+	enhancements map[*moqt.TrackWriter]struct{}
+}
+
+// New returns a Monitor with the given configuration, which may be nil.
+// It sheds no load until Run is called.
+func New(config *Config) *Monitor {
+	m := &Monitor{
+		enhancements: make(map[*moqt.TrackWriter]struct{}),
+	}
+	if config != nil {
+		m.config = *config
+	}
+	m.sampler = m.config.Sampler
+	if m.sampler == nil {
+		m.sampler = NewRuntimeSampler(0)
+	}
+	return m
+}
+
+// Run samples resource pressure every Config.Interval and adjusts the
+// shedding level until ctx is canceled. It then returns to LevelNormal.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.setLevel(LevelNormal, Sample{})
+			return
+		case <-ticker.C:
+			m.update(m.sampler.Sample())
+		}
+	}
+}
+
+// Level returns the current shedding level.
+func (m *Monitor) Level() Level {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.level
+}
+
+// Middleware returns a moqt.Middleware that closes new sessions at
+// LevelRejectSessions and above. Established sessions are not affected.
+func (m *Monitor) Middleware() moqt.Middleware {
+	return func(next moqt.Handler) moqt.Handler {
+		return moqt.HandleFunc(func(sess *moqt.Session) {
+			if m.Level() >= LevelRejectSessions {
+				_ = sess.CloseWithError(moqt.InternalSessionErrorCode, "server overloaded")
+				return
+			}
+			next.ServeMOQ(sess)
+		})
+	}
+}
+
+// TrackHandler returns a moqt.TrackHandler that serves tracks with next,
+// except enhancement tracks at LevelDropEnhancement and above, whose
+// subscriptions are refused. Subscriptions to enhancement tracks being
+// served are closed when that level is reached; other tracks are never
+// dropped.
+func (m *Monitor) TrackHandler(next moqt.TrackHandler) moqt.TrackHandler {
+	return moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		if m.config.Enhancement == nil || !m.config.Enhancement(tw) {
+			next.ServeTrack(tw)
+			return
+		}
+
+		m.mu.Lock()
+		if m.level >= LevelDropEnhancement {
+			m.mu.Unlock()
+			tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
+			return
+		}
+		m.enhancements[tw] = struct{}{}
+		m.mu.Unlock()
+
+		defer func() {
+			m.mu.Lock()
+			delete(m.enhancements, tw)
+			m.mu.Unlock()
+		}()
+		next.ServeTrack(tw)
+	})
+}
+
+// update moves the level one step towards the pressure of s.
+func (m *Monitor) update(s Sample) {
+	cpu, mem := m.config.cpuThreshold(), m.config.memoryThreshold()
+	level := m.Level()
+	switch {
+	case s.CPU >= cpu || s.Memory >= mem:
+		if level < LevelReduceCache {
+			level++
+		}
+	case s.CPU < cpu*recoveryRatio && s.Memory < mem*recoveryRatio:
+		if level > LevelNormal {
+			level--
+		}
+	}
+	m.setLevel(level, s)
+}
+
+// setLevel changes the level, applies the shedding actions of the levels
+// that were entered or left and reports the change.
+func (m *Monitor) setLevel(level Level, s Sample) {
+	m.mu.Lock()
+	prev := m.level
+	if level == prev {
+		m.mu.Unlock()
+		return
+	}
+	m.level = level
+
+	var drop []*moqt.TrackWriter
+	if prev < LevelDropEnhancement && level >= LevelDropEnhancement {
+		for tw := range m.enhancements {
+			drop = append(drop, tw)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, tw := range drop {
+		tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
+	}
+	switch {
+	case prev < LevelReduceCache && level >= LevelReduceCache:
+		for _, c := range m.config.Caches {
+			c.SetCacheGroups(m.config.reducedCacheGroups())
+		}
+	case prev >= LevelReduceCache && level < LevelReduceCache:
+		for _, c := range m.config.Caches {
+			c.SetCacheGroups(0)
+		}
+	}
+
+	ev := Event{Level: level, Previous: prev, Sample: s, Time: time.Now()}
+	if m.config.Logger != nil {
+		logLevel := slog.LevelInfo
+		if level > prev {
+			logLevel = slog.LevelWarn
+		}
+		m.config.Logger.Log(context.Background(), logLevel, "load shedding level changed",
+			"level", level.String(),
+			"previous", prev.String(),
+			"cpu", s.CPU,
+			"memory", s.Memory,
+		)
+	}
+	if m.config.OnEvent != nil {
+		m.config.OnEvent(ev)
+	}
+}
@@ -0,0 +1,234 @@
+package loadshed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevel_String(t *testing.T) {
+	assert.Equal(t, "normal", LevelNormal.String())
+	assert.Equal(t, "reject_sessions", LevelRejectSessions.String())
+	assert.Equal(t, "drop_enhancement", LevelDropEnhancement.String())
+	assert.Equal(t, "reduce_cache", LevelReduceCache.String())
+	assert.Equal(t, "Level(9)", Level(9).String())
+}
+
+func TestConfig_Defaults(t *testing.T) {
+	m := New(nil)
+	assert.Equal(t, time.Second, m.config.interval())
+	assert.Equal(t, 0.85, m.config.cpuThreshold())
+	assert.Equal(t, 0.85, m.config.memoryThreshold())
+	assert.Equal(t, 1, m.config.reducedCacheGroups())
+	assert.IsType(t, &RuntimeSampler{}, m.sampler)
+}
+
+func TestMonitor_Update(t *testing.T) {
+	tests := map[string]struct {
+		samples []Sample
+		want    Level
+	}{
+		"below thresholds": {
+			samples: []Sample{{CPU: 0.5, Memory: 0.5}},
+			want:    LevelNormal,
+		},
+		"cpu pressure escalates one level per sample": {
+			samples: []Sample{{CPU: 0.9}, {CPU: 0.9}},
+			want:    LevelDropEnhancement,
+		},
+		"memory pressure escalates": {
+			samples: []Sample{{Memory: 0.9}},
+			want:    LevelRejectSessions,
+		},
+		"highest level is kept": {
+			samples: []Sample{{CPU: 1}, {CPU: 1}, {CPU: 1}, {CPU: 1}, {CPU: 1}},
+			want:    LevelReduceCache,
+		},
+		"level is held near the threshold": {
+			samples: []Sample{{CPU: 0.9}, {CPU: 0.8}},
+			want:    LevelRejectSessions,
+		},
+		"recovers one level per sample": {
+			samples: []Sample{{CPU: 0.9}, {CPU: 0.9}, {CPU: 0.9}, {CPU: 0.1}},
+			want:    LevelDropEnhancement,
+		},
+		"recovers to normal": {
+			samples: []Sample{{CPU: 0.9}, {CPU: 0.1}},
+			want:    LevelNormal,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := New(&Config{Sampler: SamplerFunc(func() Sample { return Sample{} })})
+			for _, s := range tt.samples {
+				m.update(s)
+			}
+			assert.Equal(t, tt.want, m.Level())
+		})
+	}
+}
+
+type fakeCache struct {
+	sizes []int
+}
+
+func (c *fakeCache) SetCacheGroups(n int) {
+	c.sizes = append(c.sizes, n)
+}
+
+func TestMonitor_ReduceCache(t *testing.T) {
+	cache := &fakeCache{}
+	var events []Event
+	m := New(&Config{
+		Sampler:            SamplerFunc(func() Sample { return Sample{} }),
+		Caches:             []CacheLimiter{cache},
+		ReducedCacheGroups: 2,
+		OnEvent:            func(ev Event) { events = append(events, ev) },
+	})
+
+	for range 3 {
+		m.update(Sample{CPU: 1})
+	}
+	assert.Equal(t, []int{2}, cache.sizes)
+
+	m.update(Sample{})
+	assert.Equal(t, []int{2, 0}, cache.sizes)
+
+	require.Len(t, events, 4)
+	assert.Equal(t, LevelNormal, events[0].Previous)
+	assert.Equal(t, LevelRejectSessions, events[0].Level)
+	assert.Equal(t, Sample{CPU: 1}, events[0].Sample)
+	assert.Equal(t, LevelReduceCache, events[3].Previous)
+	assert.Equal(t, LevelDropEnhancement, events[3].Level)
+}
+
+func TestMonitor_Run(t *testing.T) {
+	levels := make(chan Level, 4)
+	m := New(&Config{
+		Sampler:  SamplerFunc(func() Sample { return Sample{CPU: 1} }),
+		Interval: time.Millisecond,
+		OnEvent:  func(ev Event) { levels <- ev.Level },
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+
+	assert.Equal(t, LevelRejectSessions, <-levels)
+	cancel()
+	<-done
+	assert.Equal(t, LevelNormal, m.Level())
+}
+
+func TestRuntimeSampler(t *testing.T) {
+	s := NewRuntimeSampler(1 << 40)
+	time.Sleep(10 * time.Millisecond)
+
+	sample := s.Sample()
+	assert.GreaterOrEqual(t, sample.CPU, 0.0)
+	assert.LessOrEqual(t, sample.CPU, 1.0)
+	assert.Greater(t, sample.Memory, 0.0)
+	assert.Less(t, sample.Memory, 1.0)
+}
+
+func TestMonitor_Server(t *testing.T) {
+	m := New(&Config{
+		Sampler: SamplerFunc(func() Sample { return Sample{} }),
+		Enhancement: func(tw *moqt.TrackWriter) bool {
+			return tw.TrackName == "enhancement"
+		},
+	})
+
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live", m.TrackHandler(newTestPublisher()))
+	server := &moqt.Server{
+		TrackMux:            mux,
+		DisableWebTransport: true,
+		Handler: moqt.HandleFunc(func(sess *moqt.Session) {
+			<-sess.Context().Done()
+		}),
+	}
+	server.Use(m.Middleware())
+	addr := moqttest.Serve(t, server)
+
+	sess := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	base, err := sess.Subscribe(t.Context(), "/live", "base", nil)
+	require.NoError(t, err)
+	enhancement, err := sess.Subscribe(t.Context(), "/live", "enhancement", nil)
+	require.NoError(t, err)
+	acceptGroup(t, base)
+	acceptGroup(t, enhancement)
+
+	// Pressure drops the enhancement track and rejects new sessions, but
+	// keeps the established session and its base track.
+	m.update(Sample{CPU: 1})
+	m.update(Sample{CPU: 1})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	for {
+		_, err := enhancement.AcceptGroup(ctx)
+		if err != nil {
+			require.NoError(t, ctx.Err())
+			break
+		}
+	}
+	acceptGroup(t, base)
+
+	_, err = sess.Subscribe(t.Context(), "/live", "enhancement", nil)
+	assert.Error(t, err)
+
+	rejected := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	select {
+	case <-rejected.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("new session was not rejected")
+	}
+	assert.NoError(t, sess.Context().Err())
+}
+
+func acceptGroup(t *testing.T, tr *moqt.TrackReader) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	_, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+}
+
+// newTestPublisher returns a handler that writes a group every 10ms until
+// the subscription ends.
+func newTestPublisher() moqt.TrackHandler {
+	return moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			gw, err := tw.OpenGroup()
+			if err != nil {
+				return
+			}
+			frame := moqt.NewFrame(5)
+			_, _ = frame.Write([]byte("hello"))
+			err = gw.WriteFrame(frame)
+			_ = gw.Close()
+			if err != nil {
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-tw.Context().Done():
+				return
+			}
+		}
+	})
+}
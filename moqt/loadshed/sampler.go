@@ -0,0 +1,75 @@
+package loadshed
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// Sampler measures resource pressure.
+type Sampler interface {
+	Sample() Sample
+}
+
+// SamplerFunc is an adapter to allow the use of ordinary functions as
+// Samplers.
+type SamplerFunc func() Sample
+
+// Sample returns f().
+func (f SamplerFunc) Sample() Sample {
+	return f()
+}
+
+// RuntimeSampler measures the pressure of the current process: the CPU time
+// it used since the previous sample relative to the CPUs available to it,
+// and the memory mapped by the Go runtime relative to a limit.
+//
+// CPU is always 0 on platforms without getrusage.
+type RuntimeSampler struct {
+	memoryLimit uint64
+
+	mu       sync.Mutex
+	lastCPU  time.Duration
+	lastTime time.Time
+}
+
+// NewRuntimeSampler returns a RuntimeSampler for the given memory limit in
+// bytes. If memoryLimit is zero, the runtime memory limit (GOMEMLIMIT) is
+// used; Memory is then 0 if no limit is set.
+func NewRuntimeSampler(memoryLimit uint64) *RuntimeSampler {
+	return &RuntimeSampler{
+		memoryLimit: memoryLimit,
+		lastCPU:     processCPUTime(),
+		lastTime:    time.Now(),
+	}
+}
+
+// Sample implements Sampler.
+func (s *RuntimeSampler) Sample() Sample {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/gc/gomemlimit:bytes"},
+	}
+	metrics.Read(samples)
+
+	var sample Sample
+	limit := s.memoryLimit
+	if limit == 0 && samples[1].Value.Kind() == metrics.KindUint64 {
+		limit = samples[1].Value.Uint64()
+	}
+	if limit > 0 && limit < math.MaxInt64 && samples[0].Value.Kind() == metrics.KindUint64 {
+		sample.Memory = float64(samples[0].Value.Uint64()) / float64(limit)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now, cpu := time.Now(), processCPUTime()
+	if elapsed := now.Sub(s.lastTime); elapsed > 0 {
+		available := float64(elapsed) * float64(runtime.GOMAXPROCS(0))
+		sample.CPU = min(float64(cpu-s.lastCPU)/available, 1)
+	}
+	s.lastCPU, s.lastTime = cpu, now
+	return sample
+}
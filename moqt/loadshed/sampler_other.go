@@ -0,0 +1,11 @@
+//go:build !unix
+
+package loadshed
+
+import "time"
+
+// processCPUTime returns 0; the CPU time of the process is not measured on
+// this platform.
+func processCPUTime() time.Duration {
+	return 0
+}
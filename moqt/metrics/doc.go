@@ -0,0 +1,12 @@
+// Package metrics exports the telemetry of a moqt.Server to Prometheus.
+//
+// A Collector implements both moqt.ServerMetrics and prometheus.Collector:
+//
+//	server := &moqt.Server{Addr: ":4433", TLSConfig: tlsConfig}
+//	prometheus.MustRegister(metrics.New(server))
+//
+//	http.Handle("/metrics", promhttp.Handler())
+//	go http.ListenAndServe(":9090", nil)
+//
+//	err := server.ListenAndServe()
+package metrics
@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Collector gathers the metrics of a moqt.Server and exposes them as a
+// prometheus.Collector.
+//
+// Per-track series are kept while the track has subscribers, so their
+// counters restart when a track is subscribed again, which Prometheus
+// handles as a counter reset.
+//
+// All methods are safe for concurrent use.
+type Collector struct {
+	server *moqt.Server
+
+	mu            sync.RWMutex
+	sessions      map[string]*transportStats
+	subscriptions int64
+	tracks        map[trackKey]*trackStats
+	groupResets   map[moqt.GroupErrorCode]uint64
+	controls      map[string]uint64
+}
+
+var (
+	sessionsActiveDesc = prometheus.NewDesc("moqt_sessions_active",
+		"Number of active sessions.", []string{"transport"}, nil)
+	sessionsTotalDesc = prometheus.NewDesc("moqt_sessions_total",
+		"Number of sessions accepted.", []string{"transport"}, nil)
+	setupFailuresDesc = prometheus.NewDesc("moqt_setup_failures_total",
+		"Number of connections rejected before a session was established.", []string{"transport"}, nil)
+	subscriptionsActiveDesc = prometheus.NewDesc("moqt_subscriptions_active",
+		"Number of active subscriptions to served tracks.", nil, nil)
+	announcedBroadcastsDesc = prometheus.NewDesc("moqt_announced_broadcasts",
+		"Number of broadcasts announced on the server.", nil, nil)
+	trackSubscriptionsDesc = prometheus.NewDesc("moqt_track_subscriptions_active",
+		"Number of active subscriptions per served track.", []string{"broadcast_path", "track_name"}, nil)
+	trackFramesDesc = prometheus.NewDesc("moqt_track_frames_sent_total",
+		"Number of frames sent per served track.", []string{"broadcast_path", "track_name"}, nil)
+	trackBytesDesc = prometheus.NewDesc("moqt_track_bytes_sent_total",
+		"Number of frame payload bytes sent per served track.", []string{"broadcast_path", "track_name"}, nil)
+	groupResetsDesc = prometheus.NewDesc("moqt_group_resets_total",
+		"Number of group streams reset by the server, by group error code.", []string{"code"}, nil)
+	controlMessagesDesc = prometheus.NewDesc("moqt_control_messages_total",
+		"Number of control messages received from peers, by kind.", []string{"kind"}, nil)
+	controlRateExceededDesc = prometheus.NewDesc("moqt_control_rate_exceeded_total",
+		"Number of sessions closed for exceeding the control message rate.", []string{"transport"}, nil)
+)
+
+type trackKey struct {
+	path moqt.BroadcastPath
+	name moqt.TrackName
+}
+
+// transportStats holds the session counters of a transport.
+type transportStats struct {
+	active              int64
+	total               uint64
+	setupFailures       uint64
+	controlRateExceeded uint64
+}
+
+// trackStats holds the counters of a served track. subscriptions is guarded
+// by Collector.mu; the other fields are updated atomically under its read
+// lock.
+type trackStats struct {
+	subscriptions int
+	frames        atomic.Uint64
+	bytes         atomic.Uint64
+}
+
+// New returns a Collector for server and sets it as server.Metrics.
+// It must be called before the server starts serving.
+func New(server *moqt.Server) *Collector {
+	c := &Collector{
+		server:      server,
+		sessions:    make(map[string]*transportStats),
+		tracks:      make(map[trackKey]*trackStats),
+		groupResets: make(map[moqt.GroupErrorCode]uint64),
+		controls:    make(map[string]uint64),
+	}
+	server.Metrics = c
+	return c
+}
+
+// transport returns the counters of transport. The caller must hold c.mu.
+func (c *Collector) transport(transport string) *transportStats {
+	stats, ok := c.sessions[transport]
+	if !ok {
+		stats = &transportStats{}
+		c.sessions[transport] = stats
+	}
+	return stats
+}
+
+var (
+	_ moqt.ServerMetrics   = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// SessionStarted implements moqt.ServerMetrics.
+func (c *Collector) SessionStarted(transport string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.transport(transport)
+	stats.active++
+	stats.total++
+}
+
+// SessionEnded implements moqt.ServerMetrics.
+func (c *Collector) SessionEnded(transport string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transport(transport).active--
+}
+
+// SetupFailed implements moqt.ServerMetrics.
+func (c *Collector) SetupFailed(transport string, _ error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transport(transport).setupFailures++
+}
+
+// SubscriptionStarted implements moqt.ServerMetrics.
+func (c *Collector) SubscriptionStarted(path moqt.BroadcastPath, name moqt.TrackName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscriptions++
+
+	key := trackKey{path: path, name: name}
+	stats, ok := c.tracks[key]
+	if !ok {
+		stats = &trackStats{}
+		c.tracks[key] = stats
+	}
+	stats.subscriptions++
+}
+
+// SubscriptionEnded implements moqt.ServerMetrics.
+func (c *Collector) SubscriptionEnded(path moqt.BroadcastPath, name moqt.TrackName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscriptions--
+
+	key := trackKey{path: path, name: name}
+	if stats, ok := c.tracks[key]; ok {
+		stats.subscriptions--
+		if stats.subscriptions <= 0 {
+			delete(c.tracks, key)
+		}
+	}
+}
+
+// FrameSent implements moqt.ServerMetrics.
+func (c *Collector) FrameSent(path moqt.BroadcastPath, name moqt.TrackName, size int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if stats, ok := c.tracks[trackKey{path: path, name: name}]; ok {
+		stats.frames.Add(1)
+		stats.bytes.Add(uint64(size))
+	}
+}
+
+// GroupReset implements moqt.ServerMetrics.
+func (c *Collector) GroupReset(_ moqt.BroadcastPath, _ moqt.TrackName, code moqt.GroupErrorCode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.groupResets[code]++
+}
+
+// ControlMessageReceived implements moqt.ServerMetrics.
+func (c *Collector) ControlMessageReceived(kind string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.controls[kind]++
+}
+
+// ControlRateExceeded implements moqt.ServerMetrics.
+func (c *Collector) ControlRateExceeded(transport string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transport(transport).controlRateExceeded++
+}
+
+// announcedBroadcasts returns the number of broadcasts announced on the
+// TrackMuxes of the server.
+func (c *Collector) announcedBroadcasts() int {
+	muxes := []*moqt.TrackMux{cmp.Or(c.server.TrackMux, moqt.DefaultMux)}
+	for _, vh := range c.server.VirtualHosts {
+		if vh.TrackMux != nil && !slices.Contains(muxes, vh.TrackMux) {
+			muxes = append(muxes, vh.TrackMux)
+		}
+	}
+
+	var n int
+	for _, mux := range muxes {
+		n += mux.NumBroadcasts()
+	}
+	return n
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sessionsActiveDesc
+	ch <- sessionsTotalDesc
+	ch <- setupFailuresDesc
+	ch <- subscriptionsActiveDesc
+	ch <- announcedBroadcastsDesc
+	ch <- trackSubscriptionsDesc
+	ch <- trackFramesDesc
+	ch <- trackBytesDesc
+	ch <- groupResetsDesc
+	ch <- controlMessagesDesc
+	ch <- controlRateExceededDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(announcedBroadcastsDesc, prometheus.GaugeValue, float64(c.announcedBroadcasts()))
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for transport, stats := range c.sessions {
+		ch <- prometheus.MustNewConstMetric(sessionsActiveDesc, prometheus.GaugeValue, float64(stats.active), transport)
+		ch <- prometheus.MustNewConstMetric(sessionsTotalDesc, prometheus.CounterValue, float64(stats.total), transport)
+		ch <- prometheus.MustNewConstMetric(setupFailuresDesc, prometheus.CounterValue, float64(stats.setupFailures), transport)
+		ch <- prometheus.MustNewConstMetric(controlRateExceededDesc, prometheus.CounterValue, float64(stats.controlRateExceeded), transport)
+	}
+
+	ch <- prometheus.MustNewConstMetric(subscriptionsActiveDesc, prometheus.GaugeValue, float64(c.subscriptions))
+
+	for k, stats := range c.tracks {
+		path, name := string(k.path), string(k.name)
+		ch <- prometheus.MustNewConstMetric(trackSubscriptionsDesc, prometheus.GaugeValue, float64(stats.subscriptions), path, name)
+		ch <- prometheus.MustNewConstMetric(trackFramesDesc, prometheus.CounterValue, float64(stats.frames.Load()), path, name)
+		ch <- prometheus.MustNewConstMetric(trackBytesDesc, prometheus.CounterValue, float64(stats.bytes.Load()), path, name)
+	}
+
+	for code, n := range c.groupResets {
+		ch <- prometheus.MustNewConstMetric(groupResetsDesc, prometheus.CounterValue, float64(n), strconv.FormatUint(uint64(code), 10))
+	}
+
+	for kind, n := range c.controls {
+		ch <- prometheus.MustNewConstMetric(controlMessagesDesc, prometheus.CounterValue, float64(n), kind)
+	}
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	server := &moqt.Server{}
+	c := New(server)
+	assert.Same(t, c, server.Metrics)
+}
+
+func TestCollector_Collect(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live/cam", moqt.NotFoundTrackHandler)
+	vhMux := moqt.NewTrackMux(0)
+	vhMux.Publish(t.Context(), "/vh", moqt.NotFoundTrackHandler)
+
+	c := New(&moqt.Server{
+		TrackMux:     mux,
+		VirtualHosts: []*moqt.VirtualHost{{Host: "a.example"}, {Host: "b.example", TrackMux: vhMux}},
+	})
+
+	c.SessionStarted("quic")
+	c.SessionStarted("quic")
+	c.SessionStarted("webtransport")
+	c.SessionEnded("quic")
+	c.SetupFailed("webtransport", errors.New("upgrade failed"))
+
+	c.SubscriptionStarted("/live/cam", "video")
+	c.SubscriptionStarted("/live/cam", "video")
+	c.SubscriptionStarted("/live/cam", `a"b`)
+	c.SubscriptionEnded("/live/cam", `a"b`)
+	c.FrameSent("/live/cam", "video", 100)
+	c.FrameSent("/live/cam", "video", 50)
+	c.FrameSent("/live/cam", "unknown", 50)
+	c.GroupReset("/live/cam", "video", moqt.ExpiredGroupErrorCode)
+	c.GroupReset("/live/cam", "video", moqt.ExpiredGroupErrorCode)
+	c.ControlMessageReceived(moqt.ControlMessageSubscribe)
+	c.ControlMessageReceived(moqt.ControlMessageSubscribe)
+	c.ControlMessageReceived(moqt.ControlMessageAnnounce)
+	c.ControlRateExceeded("quic")
+
+	want := `# HELP moqt_sessions_active Number of active sessions.
+# TYPE moqt_sessions_active gauge
+moqt_sessions_active{transport="quic"} 1
+moqt_sessions_active{transport="webtransport"} 1
+# HELP moqt_sessions_total Number of sessions accepted.
+# TYPE moqt_sessions_total counter
+moqt_sessions_total{transport="quic"} 2
+moqt_sessions_total{transport="webtransport"} 1
+# HELP moqt_setup_failures_total Number of connections rejected before a session was established.
+# TYPE moqt_setup_failures_total counter
+moqt_setup_failures_total{transport="quic"} 0
+moqt_setup_failures_total{transport="webtransport"} 1
+# HELP moqt_control_rate_exceeded_total Number of sessions closed for exceeding the control message rate.
+# TYPE moqt_control_rate_exceeded_total counter
+moqt_control_rate_exceeded_total{transport="quic"} 1
+moqt_control_rate_exceeded_total{transport="webtransport"} 0
+# HELP moqt_subscriptions_active Number of active subscriptions to served tracks.
+# TYPE moqt_subscriptions_active gauge
+moqt_subscriptions_active 2
+# HELP moqt_announced_broadcasts Number of broadcasts announced on the server.
+# TYPE moqt_announced_broadcasts gauge
+moqt_announced_broadcasts 2
+# HELP moqt_track_subscriptions_active Number of active subscriptions per served track.
+# TYPE moqt_track_subscriptions_active gauge
+moqt_track_subscriptions_active{broadcast_path="/live/cam",track_name="video"} 2
+# HELP moqt_track_frames_sent_total Number of frames sent per served track.
+# TYPE moqt_track_frames_sent_total counter
+moqt_track_frames_sent_total{broadcast_path="/live/cam",track_name="video"} 2
+# HELP moqt_track_bytes_sent_total Number of frame payload bytes sent per served track.
+# TYPE moqt_track_bytes_sent_total counter
+moqt_track_bytes_sent_total{broadcast_path="/live/cam",track_name="video"} 150
+# HELP moqt_group_resets_total Number of group streams reset by the server, by group error code.
+# TYPE moqt_group_resets_total counter
+moqt_group_resets_total{code="3"} 2
+# HELP moqt_control_messages_total Number of control messages received from peers, by kind.
+# TYPE moqt_control_messages_total counter
+moqt_control_messages_total{kind="announce"} 1
+moqt_control_messages_total{kind="subscribe"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(want)))
+}
+
+func TestCollector_Register(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	c := New(&moqt.Server{TrackMux: moqt.NewTrackMux(0)})
+	require.NoError(t, reg.Register(c))
+
+	c.SessionStarted("quic")
+	c.SubscriptionStarted("/live/cam", "video")
+	c.FrameSent("/live/cam", "video", 5)
+
+	n, err := testutil.GatherAndCount(reg)
+	require.NoError(t, err)
+	assert.Equal(t, 9, n)
+}
+
+func TestCollector_Server(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live/cam", moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		gw, err := tw.OpenGroup()
+		if err != nil {
+			return
+		}
+		frame := moqt.NewFrame(0)
+		_, _ = frame.Write([]byte("hello"))
+		_ = gw.WriteFrame(frame)
+		_ = gw.Close()
+		<-tw.Context().Done()
+	}))
+
+	server := &moqt.Server{
+		TrackMux:            mux,
+		DisableWebTransport: true,
+		Handler: moqt.HandleFunc(func(sess *moqt.Session) {
+			<-sess.Context().Done()
+		}),
+	}
+	c := New(server)
+	addr := moqttest.Serve(t, server)
+	sess := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+
+	tr, err := sess.Subscribe(t.Context(), "/live/cam", "video", nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	_, err = tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		out := scrape(t, c)
+		return strings.Contains(out, `moqt_sessions_active{transport="quic"} 1`) &&
+			strings.Contains(out, "moqt_subscriptions_active 1") &&
+			strings.Contains(out, "moqt_announced_broadcasts 1") &&
+			strings.Contains(out, `moqt_track_bytes_sent_total{broadcast_path="/live/cam",track_name="video"} 5`)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, sess.CloseWithError(moqt.NoError, ""))
+	require.Eventually(t, func() bool {
+		out := scrape(t, c)
+		return strings.Contains(out, `moqt_sessions_active{transport="quic"} 0`) &&
+			strings.Contains(out, "moqt_subscriptions_active 0")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// scrape returns the metrics of c in the text exposition format.
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(c))
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
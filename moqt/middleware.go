@@ -0,0 +1,44 @@
+package moqt
+
+// Middleware wraps a Handler with behavior that runs around every session it
+// serves, such as logging, authentication or rate limiting. A middleware may
+// reject a session by closing it instead of calling the next Handler.
+type Middleware func(next Handler) Handler
+
+// Chain returns h wrapped by middlewares. The first middleware is the
+// outermost one and sees each session first. Nil middlewares are skipped.
+func Chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			h = middlewares[i](h)
+		}
+	}
+	return h
+}
+
+type serverHandlerContextKeyType struct{}
+
+// serverHandlerContextKey carries the Server whose middlewares apply to
+// WebTransport sessions accepted by a WebTransportHandler.
+var serverHandlerContextKey = serverHandlerContextKeyType{}
+
+// Use appends middlewares to the chain that runs around the Handler of every
+// session the Server serves: native QUIC sessions, sessions of VirtualHosts,
+// and WebTransport sessions accepted by a WebTransportHandler reached through
+// the Server. Middlewares run in the order they were added. Sessions accepted
+// after Use returns are served through the extended chain.
+func (s *Server) Use(middlewares ...Middleware) {
+	s.middlewareMu.Lock()
+	defer s.middlewareMu.Unlock()
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+// handler returns h wrapped by the Server's middlewares, or nil if h is nil.
+func (s *Server) handler(h Handler) Handler {
+	if h == nil {
+		return nil
+	}
+	s.middlewareMu.RLock()
+	defer s.middlewareMu.RUnlock()
+	return Chain(h, s.middlewares...)
+}
@@ -0,0 +1,111 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMiddleware appends name to calls before calling the next handler.
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next Handler) Handler {
+		return HandleFunc(func(sess *Session) {
+			*calls = append(*calls, name)
+			next.ServeMOQ(sess)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	tests := map[string]struct {
+		middlewares func(calls *[]string) []Middleware
+		want        []string
+	}{
+		"no middlewares": {
+			middlewares: func(calls *[]string) []Middleware { return nil },
+			want:        []string{"handler"},
+		},
+		"first is outermost": {
+			middlewares: func(calls *[]string) []Middleware {
+				return []Middleware{recordingMiddleware("a", calls), recordingMiddleware("b", calls)}
+			},
+			want: []string{"a", "b", "handler"},
+		},
+		"nil middleware skipped": {
+			middlewares: func(calls *[]string) []Middleware {
+				return []Middleware{nil, recordingMiddleware("a", calls)}
+			},
+			want: []string{"a", "handler"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls []string
+			h := HandleFunc(func(sess *Session) { calls = append(calls, "handler") })
+
+			Chain(h, tt.middlewares(&calls)...).ServeMOQ(nil)
+
+			assert.Equal(t, tt.want, calls)
+		})
+	}
+}
+
+func TestServer_Use_NativeQUIC(t *testing.T) {
+	var calls []string
+	s := &Server{
+		Handler: HandleFunc(func(sess *Session) { calls = append(calls, "handler") }),
+	}
+	s.Use(recordingMiddleware("log", &calls))
+	s.Use(recordingMiddleware("auth", &calls))
+
+	_ = s.handleNativeQUIC(newTestNativeQUICConn(t))
+
+	assert.Equal(t, []string{"log", "auth", "handler"}, calls)
+}
+
+func TestServer_Use_Rejects(t *testing.T) {
+	called := false
+	s := &Server{
+		Handler: HandleFunc(func(sess *Session) { called = true }),
+	}
+	s.Use(func(next Handler) Handler {
+		return HandleFunc(func(sess *Session) {
+			_ = sess.CloseWithError(UnauthorizedSessionErrorCode, "unauthorized")
+		})
+	})
+
+	conn := newTestNativeQUICConn(t)
+	_ = s.handleNativeQUIC(conn)
+
+	assert.False(t, called)
+	assert.Error(t, conn.Context().Err(), "rejected session should be closed")
+}
+
+func TestServer_Use_WebTransportHandler(t *testing.T) {
+	var calls []string
+	s := &Server{}
+	s.init()
+	s.Use(recordingMiddleware("log", &calls))
+
+	u := &WebTransportHandler{
+		TrackMux: NewTrackMux(0),
+		Handler:  HandleFunc(func(sess *Session) { calls = append(calls, "handler") }),
+		UpgradeFunc: func(w http.ResponseWriter, r *http.Request) (WebTransportSession, error) {
+			return &FakeWebTransportSession{}, nil
+		},
+	}
+
+	ctx := s.connContext(context.Background(), &FakeStreamConn{})
+	r, err := http.NewRequestWithContext(ctx, http.MethodConnect, "https://example.com/moq", nil)
+	require.NoError(t, err)
+	r.TLS = &tls.ConnectionState{}
+
+	u.ServeHTTP(&FakeHTTPResponseWriter{}, r)
+
+	assert.Equal(t, []string{"log", "handler"}, calls)
+}
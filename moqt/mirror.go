@@ -0,0 +1,311 @@
+package moqt
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Mirror re-announces the broadcasts of mux that match filter on target, so
+// that a secondary downstream, such as a new relay or a recording sink
+// attached to target, receives the same broadcasts as the primary one.
+// This enables dark-launch validation of new infrastructure.
+//
+// Broadcasts already announced on mux are mirrored immediately, and later
+// announcements are mirrored as they arrive. A mirrored announcement ends when
+// the source announcement ends or when stop is called. A nil filter mirrors
+// every broadcast.
+//
+// Subscriptions arriving through target are shadows of the primary ones: the
+// source handler is not run for them. Instead, every group a TrackWriter
+// served by mux writes to a mirrored track is copied to the shadow
+// subscriptions of the same track, with the same sequence, so a shadow
+// receives what the primary subscribers receive while a primary subscription
+// to the track is active. Frames are copied as sent, after the frame
+// interceptors of the primary subscription, and with its extension headers. Copies are written in the background; a shadow
+// that falls more than shadowGroupQueue frames behind loses the group, and
+// primary delivery is never delayed.
+//
+// Mirror panics if target is nil or is mux itself.
+func (mux *TrackMux) Mirror(target *TrackMux, filter func(BroadcastPath) bool) (stop func()) {
+	if target == nil {
+		panic("[TrackMux] nil mirror target")
+	}
+	if target == mux {
+		panic("[TrackMux] cannot mirror a mux onto itself")
+	}
+	if filter == nil {
+		filter = func(BroadcastPath) bool { return true }
+	}
+
+	m := &trackMirror{
+		target:   target,
+		filter:   filter,
+		mirrored: make(map[*Announcement]struct{}),
+		shadows:  make(map[trackKey]map[*TrackWriter]struct{}),
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	mux.mu.Lock()
+	if mux.mirrors == nil {
+		mux.mirrors = make(map[*trackMirror]struct{})
+	}
+	mux.mirrors[m] = struct{}{}
+	existing := make([]*Announcement, 0, len(mux.trackHandlerIndex))
+	for _, ath := range mux.trackHandlerIndex {
+		existing = append(existing, ath.Announcement)
+	}
+	mux.mu.Unlock()
+
+	for _, ann := range existing {
+		m.add(ann)
+	}
+
+	return func() {
+		mux.mu.Lock()
+		delete(mux.mirrors, m)
+		mux.mu.Unlock()
+
+		m.cancel()
+	}
+}
+
+// mirror forwards an announcement to every mirror registered on the mux.
+func (mux *TrackMux) mirror(ann *Announcement) {
+	mux.mu.RLock()
+	if len(mux.mirrors) == 0 {
+		mux.mu.RUnlock()
+		return
+	}
+	mirrors := make([]*trackMirror, 0, len(mux.mirrors))
+	for m := range mux.mirrors {
+		mirrors = append(mirrors, m)
+	}
+	mux.mu.RUnlock()
+
+	for _, m := range mirrors {
+		m.add(ann)
+	}
+}
+
+// mirrorsOf returns the mirrors registered on the mux that mirror path.
+func (mux *TrackMux) mirrorsOf(path BroadcastPath) []*trackMirror {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	var mirrors []*trackMirror
+	for m := range mux.mirrors {
+		if m.filter(path) {
+			mirrors = append(mirrors, m)
+		}
+	}
+	return mirrors
+}
+
+// trackMirror holds the state of one TrackMux.Mirror registration.
+type trackMirror struct {
+	target *TrackMux
+	filter func(BroadcastPath) bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	mirrored map[*Announcement]struct{}
+
+	// shadows are the writers of the subscriptions received through
+	// target, by track.
+	shadows map[trackKey]map[*TrackWriter]struct{}
+}
+
+// trackKey identifies a track.
+type trackKey struct {
+	path BroadcastPath
+	name TrackName
+}
+
+// add announces ann on the target unless it is filtered out, already
+// mirrored or the mirror has been stopped.
+func (m *trackMirror) add(ann *Announcement) {
+	if ann == nil || !ann.IsActive() || m.ctx.Err() != nil {
+		return
+	}
+	path := ann.BroadcastPath()
+	if !m.filter(path) {
+		return
+	}
+
+	m.mu.Lock()
+	if _, ok := m.mirrored[ann]; ok {
+		m.mu.Unlock()
+		return
+	}
+	m.mirrored[ann] = struct{}{}
+	m.mu.Unlock()
+
+	// The shadow ends with the source announcement or the mirror, and then
+	// releases what it registered on them.
+	shadow, end := NewAnnouncementWithID(context.Background(), path, ann.id)
+	shadow.hopIDs = slices.Clone(ann.hopIDs)
+	shadow.copyTrackMetadata(ann)
+
+	stopSource := ann.AfterFunc(end)
+	stopMirror := context.AfterFunc(m.ctx, end)
+	shadow.AfterFunc(func() {
+		stopSource()
+		stopMirror()
+
+		m.mu.Lock()
+		delete(m.mirrored, ann)
+		m.mu.Unlock()
+	})
+
+	m.target.Announce(shadow, TrackHandlerFunc(func(tw *TrackWriter) {
+		m.serveShadow(shadow, tw)
+	}))
+}
+
+// serveShadow registers the writer of a shadow subscription until the
+// subscription, the shadow announcement or the mirror ends.
+func (m *trackMirror) serveShadow(shadow *Announcement, tw *TrackWriter) {
+	key := trackKey{path: tw.BroadcastPath, name: tw.TrackName}
+
+	m.mu.Lock()
+	writers := m.shadows[key]
+	if writers == nil {
+		writers = make(map[*TrackWriter]struct{})
+		m.shadows[key] = writers
+	}
+	writers[tw] = struct{}{}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(writers, tw)
+		if len(writers) == 0 {
+			delete(m.shadows, key)
+		}
+		m.mu.Unlock()
+	}()
+
+	select {
+	case <-tw.Context().Done():
+	case <-shadow.Done():
+	case <-m.ctx.Done():
+	}
+}
+
+// shadowsOf returns the writers of the shadow subscriptions to a track.
+func (m *trackMirror) shadowsOf(path BroadcastPath, name TrackName) []*TrackWriter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writers := make([]*TrackWriter, 0, len(m.shadows[trackKey{path: path, name: name}]))
+	for tw := range m.shadows[trackKey{path: path, name: name}] {
+		writers = append(writers, tw)
+	}
+	return writers
+}
+
+// shadowGroupQueue is the number of frames a shadow group may fall behind
+// the primary group before it is canceled.
+const shadowGroupQueue = 64
+
+// shadowGroup copies the frames of a group written by a primary subscription
+// to the same group of a shadow subscription. The copy is opened and written
+// by its own goroutine, which belongs to the group and ends with it.
+type shadowGroup struct {
+	mu       sync.Mutex
+	frames   chan *Frame
+	ended    bool
+	canceled bool
+	code     GroupErrorCode
+}
+
+// openShadowGroups starts copying group seq to the shadow subscriptions of
+// the track of w, with extension headers if ext. A shadow whose session
+// cannot carry them loses the group.
+func (w *TrackWriter) openShadowGroups(seq GroupSequence, subgroup SubgroupID, timestamp time.Time, ext bool) []*shadowGroup {
+	var groups []*shadowGroup
+	for _, m := range w.mirrors {
+		for _, shadow := range m.shadowsOf(w.BroadcastPath, w.TrackName) {
+			g := &shadowGroup{frames: make(chan *Frame, shadowGroupQueue)}
+			goTask(w.tasks, "shadow group writer", func() {
+				g.run(func() (*GroupWriter, error) {
+					if ext {
+						if err := shadow.SetExtensionHeaders(true); err != nil {
+							return nil, err
+						}
+					}
+					shadow.advanceSequence(seq)
+					return shadow.openGroup(seq, subgroup, timestamp)
+				})
+			}, nil)
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// write queues a copy of frame, or cancels the shadow group if it is too
+// far behind.
+func (g *shadowGroup) write(frame *Frame) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.ended {
+		return
+	}
+	select {
+	case g.frames <- frame.Clone():
+	default:
+		g.endLocked(true, InternalGroupErrorCode)
+	}
+}
+
+// end ends the shadow group after the queued frames, by closing it or, if
+// canceled, by canceling it with code.
+func (g *shadowGroup) end(canceled bool, code GroupErrorCode) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.ended {
+		g.endLocked(canceled, code)
+	}
+}
+
+func (g *shadowGroup) endLocked(canceled bool, code GroupErrorCode) {
+	g.ended = true
+	g.canceled = canceled
+	g.code = code
+	close(g.frames)
+}
+
+func (g *shadowGroup) run(open func() (*GroupWriter, error)) {
+	group, err := open()
+	if err != nil {
+		for range g.frames {
+		}
+		return
+	}
+
+	for frame := range g.frames {
+		if err := group.SendFrame(frame); err != nil {
+			group.CancelWrite(InternalGroupErrorCode)
+			for range g.frames {
+			}
+			return
+		}
+	}
+
+	g.mu.Lock()
+	canceled, code := g.canceled, g.code
+	g.mu.Unlock()
+
+	if canceled {
+		group.CancelWrite(code)
+	} else {
+		_ = group.Close()
+	}
+}
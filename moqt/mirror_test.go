@@ -0,0 +1,401 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMux_Mirror(t *testing.T) {
+	tests := map[string]struct {
+		filter   func(BroadcastPath) bool
+		path     BroadcastPath
+		mirrored bool
+	}{
+		"nil filter mirrors all": {
+			path:     "/live/a",
+			mirrored: true,
+		},
+		"matching filter": {
+			filter:   func(p BroadcastPath) bool { return strings.HasPrefix(string(p), "/live/") },
+			path:     "/live/a",
+			mirrored: true,
+		},
+		"non-matching filter": {
+			filter: func(p BroadcastPath) bool { return strings.HasPrefix(string(p), "/live/") },
+			path:   "/vod/a",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			source := NewTrackMux(0)
+			target := NewTrackMux(0)
+			stop := source.Mirror(target, tt.filter)
+			defer stop()
+
+			source.PublishFunc(t.Context(), tt.path, func(tw *TrackWriter) {})
+
+			ann, _ := target.TrackHandler(tt.path)
+			if !tt.mirrored {
+				assert.Nil(t, ann)
+				return
+			}
+			require.NotNil(t, ann)
+			assert.True(t, ann.IsActive())
+		})
+	}
+}
+
+func TestMux_Mirror_ExistingAnnouncements(t *testing.T) {
+	source := NewTrackMux(0)
+	target := NewTrackMux(0)
+	source.PublishFunc(t.Context(), "/live/a", func(tw *TrackWriter) {})
+
+	stop := source.Mirror(target, nil)
+	defer stop()
+
+	ann, _ := target.TrackHandler("/live/a")
+	require.NotNil(t, ann)
+	assert.True(t, ann.IsActive())
+}
+
+func TestMux_Mirror_EndsWithSource(t *testing.T) {
+	source := NewTrackMux(0)
+	target := NewTrackMux(0)
+	stop := source.Mirror(target, nil)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	source.PublishFunc(ctx, "/live/a", func(tw *TrackWriter) {})
+
+	shadow, _ := target.TrackHandler("/live/a")
+	require.NotNil(t, shadow)
+
+	cancel()
+
+	select {
+	case <-shadow.Done():
+	case <-time.After(time.Second):
+		t.Fatal("mirrored announcement should end with the source announcement")
+	}
+	assert.Eventually(t, func() bool {
+		ann, _ := target.TrackHandler("/live/a")
+		return ann == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMux_Mirror_Stop(t *testing.T) {
+	source := NewTrackMux(0)
+	target := NewTrackMux(0)
+	stop := source.Mirror(target, nil)
+
+	source.PublishFunc(t.Context(), "/live/a", func(tw *TrackWriter) {})
+	shadow, _ := target.TrackHandler("/live/a")
+	require.NotNil(t, shadow)
+
+	stop()
+
+	select {
+	case <-shadow.Done():
+	case <-time.After(time.Second):
+		t.Fatal("mirrored announcement should end when the mirror stops")
+	}
+
+	source.PublishFunc(t.Context(), "/live/b", func(tw *TrackWriter) {})
+	ann, _ := target.TrackHandler("/live/b")
+	assert.Nil(t, ann, "announcements after stop should not be mirrored")
+
+	primary, _ := source.TrackHandler("/live/a")
+	assert.NotNil(t, primary, "stopping the mirror should not affect the source")
+}
+
+func TestMux_Mirror_HandlerRemovedFromSource(t *testing.T) {
+	source := NewTrackMux(0)
+	target := NewTrackMux(0)
+	stop := source.Mirror(target, nil)
+	defer stop()
+
+	ann, end := NewAnnouncement(t.Context(), "/live/a")
+	source.Announce(ann, TrackHandlerFunc(func(tw *TrackWriter) {}))
+
+	_, handler := target.TrackHandler("/live/a")
+	require.NotNil(t, handler)
+
+	// The source handler is gone while the shadow handler is still held.
+	end()
+	assert.Eventually(t, func() bool {
+		a, _ := source.TrackHandler("/live/a")
+		return a == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// The shadow has ended with the source, so its handler returns at once.
+	shadow := newMirrorTestWriter(t, "/live/a", 2, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	})
+	assert.NotPanics(t, func() { handler.ServeTrack(shadow) })
+}
+
+// newMirrorTestWriter returns the writer of subscription id to the video
+// track of path, opening group streams with open.
+func newMirrorTestWriter(t *testing.T, path BroadcastPath, id SubscribeID, open func() (transport.SendStream, error)) *TrackWriter {
+	t.Helper()
+	substr := newReceiveSubscribeStream(id, &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	tw := newTrackWriter(path, "video", substr, open, func() {})
+	t.Cleanup(func() { _ = tw.Close() })
+	return tw
+}
+
+// serveShadow serves tw through target in the background and waits until
+// the mirror of source registered it.
+func serveShadow(t *testing.T, source, target *TrackMux, tw *TrackWriter) {
+	t.Helper()
+	go target.serveTrack(tw)
+	require.Eventually(t, func() bool {
+		mirrors := source.mirrorsOf(tw.BroadcastPath)
+		return len(mirrors) == 1 && len(mirrors[0].shadowsOf(tw.BroadcastPath, tw.TrackName)) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestMux_Mirror_FansOutPrimaryGroups(t *testing.T) {
+	source := NewTrackMux(0)
+	target := NewTrackMux(0)
+	stop := source.Mirror(target, nil)
+	defer stop()
+
+	var served atomic.Int32
+	source.PublishFunc(t.Context(), "/live/a", func(tw *TrackWriter) {
+		served.Add(1)
+		gw, err := tw.OpenGroupAt(5)
+		if !assert.NoError(t, err) {
+			return
+		}
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte("hello"))
+		assert.NoError(t, gw.WriteFrame(frame))
+		assert.NoError(t, gw.Close())
+	})
+
+	var buf bytes.Buffer
+	closed := make(chan struct{})
+	shadow := newMirrorTestWriter(t, "/live/a", 2, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{
+			WriteFunc: buf.Write,
+			CloseFunc: func() error { close(closed); return nil },
+		}, nil
+	})
+	serveShadow(t, source, target, shadow)
+
+	primary := newMirrorTestWriter(t, "/live/a", 1, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	})
+	source.serveTrack(primary)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("the group should be copied to the shadow subscription")
+	}
+	assert.Equal(t, int32(1), served.Load(), "the source handler should only serve the primary subscription")
+
+	var st message.StreamType
+	require.NoError(t, st.Decode(&buf))
+	var gm message.GroupMessage
+	require.NoError(t, gm.Decode(&buf))
+	assert.Equal(t, message.GroupMessage{SubscribeID: 2, GroupSequence: 5}, gm)
+	frame := NewFrame(0)
+	require.NoError(t, frame.decode(&buf))
+	assert.Equal(t, []byte("hello"), frame.Body())
+}
+
+func TestMux_Mirror_AfterInterceptor(t *testing.T) {
+	source := NewTrackMux(0)
+	target := NewTrackMux(0)
+	stop := source.Mirror(target, nil)
+	defer stop()
+
+	source.PublishFunc(t.Context(), "/live/a", func(tw *TrackWriter) {
+		if !assert.NoError(t, tw.SetExtensionHeaders(true)) {
+			return
+		}
+		tw.SetFrameInterceptor(func(frame *Frame) (*Frame, error) {
+			if string(frame.Body()) == "drop" {
+				return nil, nil
+			}
+			out := NewFrame(0)
+			_, _ = out.Write([]byte(strings.ToUpper(string(frame.Body()))))
+			out.SetExtensions(ExtensionHeaders{0x0b: []byte("key-1")})
+			return out, nil
+		})
+		gw, err := tw.OpenGroupAt(5)
+		if !assert.NoError(t, err) {
+			return
+		}
+		for _, payload := range []string{"hello", "drop", "world"} {
+			frame := NewFrame(0)
+			_, _ = frame.Write([]byte(payload))
+			assert.NoError(t, gw.WriteFrame(frame))
+		}
+		assert.NoError(t, gw.Close())
+	})
+
+	var buf bytes.Buffer
+	closed := make(chan struct{})
+	shadow := newMirrorTestWriter(t, "/live/a", 2, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{
+			WriteFunc: buf.Write,
+			CloseFunc: func() error { close(closed); return nil },
+		}, nil
+	})
+	serveShadow(t, source, target, shadow)
+
+	primary := newMirrorTestWriter(t, "/live/a", 1, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	})
+	source.serveTrack(primary)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("the group should be copied to the shadow subscription")
+	}
+
+	// The shadow receives the frames as intercepted, with their headers.
+	var st message.StreamType
+	require.NoError(t, st.Decode(&buf))
+	gm := message.GroupMessage{Version: message.VersionLite04Ext}
+	require.NoError(t, gm.Decode(&buf))
+	assert.Equal(t, message.GroupFlagExtensionHeaders, gm.Flags)
+	group := newGroupReader(GroupSequence(5), &FakeQUICReceiveStream{ReadFunc: buf.Read}, nil)
+	group.SetExtensionHeaders(true)
+	frame := NewFrame(0)
+	for _, want := range []string{"HELLO", "WORLD"} {
+		require.NoError(t, group.ReadFrame(frame))
+		assert.Equal(t, want, string(frame.Body()))
+		assert.Equal(t, ExtensionHeaders{0x0b: []byte("key-1")}, frame.Extensions())
+	}
+}
+
+func TestMux_Mirror_SlowShadow(t *testing.T) {
+	source := NewTrackMux(0)
+	target := NewTrackMux(0)
+	stop := source.Mirror(target, nil)
+	defer stop()
+
+	written := make(chan struct{})
+	source.PublishFunc(t.Context(), "/live/a", func(tw *TrackWriter) {
+		defer close(written)
+		gw, err := tw.OpenGroup()
+		if !assert.NoError(t, err) {
+			return
+		}
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte("f"))
+		for range 2 * shadowGroupQueue {
+			assert.NoError(t, gw.WriteFrame(frame))
+		}
+		assert.NoError(t, gw.Close())
+	})
+
+	// The shadow cannot open a stream until the primary is done.
+	canceled := make(chan transport.StreamErrorCode, 1)
+	shadow := newMirrorTestWriter(t, "/live/a", 2, func() (transport.SendStream, error) {
+		<-written
+		return &FakeQUICSendStream{
+			CancelWriteFunc: func(code transport.StreamErrorCode) { canceled <- code },
+		}, nil
+	})
+	serveShadow(t, source, target, shadow)
+
+	primary := newMirrorTestWriter(t, "/live/a", 1, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	})
+	go source.serveTrack(primary)
+
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("a slow shadow should not delay the primary")
+	}
+	select {
+	case code := <-canceled:
+		assert.Equal(t, transport.StreamErrorCode(InternalGroupErrorCode), code)
+	case <-time.After(time.Second):
+		t.Fatal("a shadow too far behind should lose the group")
+	}
+}
+
+func TestMux_Mirror_Stop_ReleasesSource(t *testing.T) {
+	source := NewTrackMux(0)
+	target := NewTrackMux(0)
+
+	ann, end := NewAnnouncement(t.Context(), "/live/a")
+	defer end()
+	source.Announce(ann, TrackHandlerFunc(func(tw *TrackWriter) {}))
+	before := activeAfterHandlers(ann)
+
+	stop := source.Mirror(target, nil)
+	assert.Equal(t, before+1, activeAfterHandlers(ann))
+
+	stop()
+	assert.Eventually(t, func() bool {
+		return activeAfterHandlers(ann) == before
+	}, time.Second, time.Millisecond, "a stopped mirror should not stay registered on the source announcement")
+}
+
+// activeAfterHandlers returns the number of handlers registered on a with
+// AfterFunc and not stopped.
+func activeAfterHandlers(a *Announcement) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for _, f := range a.afterHandlers {
+		if f != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMux_Mirror_Panics(t *testing.T) {
+	mux := NewTrackMux(0)
+	assert.Panics(t, func() { mux.Mirror(nil, nil) })
+	assert.Panics(t, func() { mux.Mirror(mux, nil) })
+}
+
+func TestGroupWriter_ShadowsOnlyWrittenFrames(t *testing.T) {
+	errWrite := errors.New("write failed")
+	var fail atomic.Bool
+	gw := newGroupWriter(&FakeQUICSendStream{WriteFunc: func(p []byte) (int, error) {
+		if fail.Load() {
+			return 0, errWrite
+		}
+		return len(p), nil
+	}}, 1, nil)
+	shadow := &shadowGroup{frames: make(chan *Frame, shadowGroupQueue)}
+	gw.shadows = []*shadowGroup{shadow}
+
+	for _, payload := range []string{"sent", "failed"} {
+		fail.Store(payload == "failed")
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte(payload))
+		_ = gw.WriteFrame(frame)
+	}
+	shadow.end(true, InternalGroupErrorCode)
+
+	var copied []string
+	for frame := range shadow.frames {
+		copied = append(copied, string(frame.Body()))
+	}
+	assert.Equal(t, []string{"sent"}, copied, "a frame the primary subscription did not get must not reach the shadow")
+}
@@ -86,6 +86,12 @@ type TrackMux struct {
 
 	announcementTree announcingNode
 	// treeMu           sync.RWMutex
+
+	// mirrors are the active Mirror registrations, guarded by mu.
+	mirrors map[*trackMirror]struct{}
+
+	// patternHandlers are the handlers registered with Route, guarded by mu.
+	patternHandlers []*patternHandler
 }
 
 // PublishFunc registers a simple function handler for the provided path on
@@ -204,6 +210,8 @@ func (mux *TrackMux) Announce(announcement *Announcement, handler TrackHandler)
 				delete(node.subscriptions, ac.aw)
 				node.mu.Unlock()
 				// Close the AW to signal the writer to cleanup and close its channel.
+				// This ends once the stream is canceled, without waiting for
+				// the peer.
 				go func(a *AnnouncementWriter) {
 					// Use InternalAnnounceErrorCode to indicate an internal error condition
 					_ = a.CloseWithError(AnnounceErrorCodeInternal)
@@ -221,6 +229,16 @@ func (mux *TrackMux) Announce(announcement *Announcement, handler TrackHandler)
 
 		mux.removeHandler(announced)
 	})
+
+	mux.mirror(announcement)
+}
+
+// NumBroadcasts returns the number of broadcasts currently announced on the
+// TrackMux, not counting paths served by Route patterns.
+func (mux *TrackMux) NumBroadcasts() int {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	return len(mux.trackHandlerIndex)
 }
 
 // TrackHandler returns the Announcement and associated TrackHandler for the specified
@@ -266,10 +284,13 @@ func (mux *TrackMux) serveTrack(tw *TrackWriter) {
 
 	path := tw.BroadcastPath
 
+	// Groups of mirrored tracks are copied to their shadow subscriptions.
+	tw.mirrors = mux.mirrorsOf(path)
+
 	// Use findTrackHandler for consistent lookup with optimized locking
 	ath := mux.findTrackHandler(path)
 	if ath == nil {
-		tw.CloseWithError(SubscribeErrorCodeNotFound)
+		mux.servePattern(tw)
 		return
 	}
 
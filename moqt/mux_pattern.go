@@ -0,0 +1,260 @@
+package moqt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RouteFunc registers f for subscriptions to broadcast paths matching
+// pattern in the DefaultMux.
+// This is a convenience wrapper around DefaultMux.RouteFunc.
+func RouteFunc(ctx context.Context, pattern string, f func(tw *TrackWriter)) {
+	DefaultMux.RouteFunc(ctx, pattern, f)
+}
+
+// Route registers the handler for subscriptions to broadcast paths
+// matching pattern in the DefaultMux.
+// This is a convenience wrapper around DefaultMux.Route.
+func Route(ctx context.Context, pattern string, handler TrackHandler) {
+	DefaultMux.Route(ctx, pattern, handler)
+}
+
+// RouteFunc registers a simple function handler for subscriptions to
+// broadcast paths matching pattern. It wraps the function into a
+// TrackHandlerFunc.
+func (mux *TrackMux) RouteFunc(ctx context.Context, pattern string, f func(tw *TrackWriter)) {
+	mux.Route(ctx, pattern, TrackHandlerFunc(f))
+}
+
+// Route registers the handler for subscriptions to broadcast paths matching
+// pattern, so that a single handler can serve many paths. The handler
+// remains active until the provided context is canceled.
+//
+// A pattern is a broadcast path whose segments may be wildcards, in the style
+// of net/http:
+//
+//   - "{name}" matches exactly one non-empty segment.
+//   - "{name...}" must be the last segment and matches the rest of the path.
+//
+// The matched values are available through TrackWriter.PathValue. Paths
+// registered with Publish or Announce take precedence over patterns. When
+// several patterns match, the most specific one is used: at the first
+// differing segment a literal wins over "{name}", which wins over
+// "{name...}". A pattern equivalent to a registered one replaces it.
+//
+// Unlike Publish, Route does not announce anything; subscribers must learn
+// the paths through other means. Route panics if pattern is invalid.
+func (mux *TrackMux) Route(ctx context.Context, pattern string, handler TrackHandler) {
+	if ctx == nil {
+		panic("[TrackMux] nil context")
+	}
+
+	p, err := parsePathPattern(pattern)
+	if err != nil {
+		panic("[TrackMux] " + err.Error())
+	}
+
+	ph := &patternHandler{
+		pattern:      p,
+		TrackHandler: handler,
+		ctx:          ctx,
+	}
+
+	mux.mu.Lock()
+	patterns := make([]*patternHandler, 0, len(mux.patternHandlers)+1)
+	for _, old := range mux.patternHandlers {
+		if !old.pattern.equivalent(p) {
+			patterns = append(patterns, old)
+		}
+	}
+	mux.patternHandlers = append(patterns, ph)
+	mux.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		mux.mu.Lock()
+		defer mux.mu.Unlock()
+		for i, h := range mux.patternHandlers {
+			if h == ph {
+				mux.patternHandlers = append(mux.patternHandlers[:i:i], mux.patternHandlers[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// findPatternHandler returns the most specific pattern handler matching path
+// and the values of its wildcards.
+func (mux *TrackMux) findPatternHandler(path BroadcastPath) (*patternHandler, map[string]string) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	var (
+		best   *patternHandler
+		values map[string]string
+	)
+	for _, ph := range mux.patternHandlers {
+		if ph.ctx.Err() != nil {
+			continue
+		}
+		if best != nil && !ph.pattern.moreSpecific(best.pattern) {
+			continue
+		}
+		if v, ok := ph.pattern.match(path); ok {
+			best, values = ph, v
+		}
+	}
+	return best, values
+}
+
+// servePattern serves tw with the most specific pattern handler matching its
+// broadcast path, or rejects it with SubscribeErrorCodeNotFound.
+func (mux *TrackMux) servePattern(tw *TrackWriter) {
+	ph, values := mux.findPatternHandler(tw.BroadcastPath)
+	if ph == nil {
+		tw.CloseWithError(SubscribeErrorCodeNotFound)
+		return
+	}
+
+	tw.pathValues = values
+
+	// Ensure track is closed when the registration ends
+	stop := context.AfterFunc(ph.ctx, func() {
+		tw.Close()
+	})
+	defer stop()
+
+	ph.ServeTrack(tw)
+}
+
+type patternHandler struct {
+	TrackHandler
+	pattern *pathPattern
+	ctx     context.Context
+}
+
+// pathPattern is a parsed broadcast path pattern.
+type pathPattern struct {
+	segments []patternSegment
+}
+
+type patternSegment struct {
+	// text is the literal segment or the wildcard name.
+	text string
+	kind segmentKind
+}
+
+type segmentKind uint8
+
+// Segment kinds are ordered from the most to the least specific.
+const (
+	segmentLiteral segmentKind = iota
+	segmentWildcard
+	segmentMulti
+)
+
+func parsePathPattern(s string) (*pathPattern, error) {
+	if !isValidPath(BroadcastPath(s)) {
+		return nil, fmt.Errorf("invalid pattern %q: must start with '/'", s)
+	}
+
+	parts := strings.Split(s[1:], "/")
+	p := &pathPattern{
+		segments: make([]patternSegment, 0, len(parts)),
+	}
+	names := make(map[string]struct{})
+	for i, part := range parts {
+		if !strings.ContainsAny(part, "{}") {
+			p.segments = append(p.segments, patternSegment{text: part, kind: segmentLiteral})
+			continue
+		}
+
+		if len(part) < 2 || part[0] != '{' || part[len(part)-1] != '}' {
+			return nil, fmt.Errorf("invalid pattern %q: wildcard must be a whole segment", s)
+		}
+		name := part[1 : len(part)-1]
+		kind := segmentWildcard
+		if rest, ok := strings.CutSuffix(name, "..."); ok {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("invalid pattern %q: %q must be the last segment", s, part)
+			}
+			name, kind = rest, segmentMulti
+		}
+		if name == "" || strings.ContainsAny(name, "{}.") {
+			return nil, fmt.Errorf("invalid pattern %q: bad wildcard name %q", s, name)
+		}
+		if _, dup := names[name]; dup {
+			return nil, fmt.Errorf("invalid pattern %q: duplicate wildcard name %q", s, name)
+		}
+		names[name] = struct{}{}
+		p.segments = append(p.segments, patternSegment{text: name, kind: kind})
+	}
+	return p, nil
+}
+
+// match reports whether path matches p and returns the wildcard values.
+func (p *pathPattern) match(path BroadcastPath) (map[string]string, bool) {
+	if !isValidPath(path) {
+		return nil, false
+	}
+
+	rest := string(path[1:])
+	var values map[string]string
+	for i, seg := range p.segments {
+		if seg.kind == segmentMulti {
+			if values == nil {
+				values = make(map[string]string, 1)
+			}
+			values[seg.text] = rest
+			return values, true
+		}
+
+		part, next, found := strings.Cut(rest, "/")
+		last := i == len(p.segments)-1
+		if found == last {
+			// A pattern segment is missing or the path has more segments.
+			return nil, false
+		}
+
+		switch seg.kind {
+		case segmentLiteral:
+			if part != seg.text {
+				return nil, false
+			}
+		case segmentWildcard:
+			if part == "" {
+				return nil, false
+			}
+			if values == nil {
+				values = make(map[string]string, len(p.segments))
+			}
+			values[seg.text] = part
+		}
+		rest = next
+	}
+	return values, true
+}
+
+// moreSpecific reports whether p takes precedence over q.
+func (p *pathPattern) moreSpecific(q *pathPattern) bool {
+	for i := range min(len(p.segments), len(q.segments)) {
+		if p.segments[i].kind != q.segments[i].kind {
+			return p.segments[i].kind < q.segments[i].kind
+		}
+	}
+	return len(p.segments) > len(q.segments)
+}
+
+// equivalent reports whether p and q match the same paths.
+func (p *pathPattern) equivalent(q *pathPattern) bool {
+	if len(p.segments) != len(q.segments) {
+		return false
+	}
+	for i, seg := range p.segments {
+		other := q.segments[i]
+		if seg.kind != other.kind || (seg.kind == segmentLiteral && seg.text != other.text) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+package moqt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePathPattern(t *testing.T) {
+	tests := map[string]struct {
+		pattern string
+		wantErr bool
+	}{
+		"literal":                {pattern: "/live/room"},
+		"root":                   {pattern: "/"},
+		"wildcard":               {pattern: "/live/{channel}"},
+		"multi wildcard":         {pattern: "/vod/{rest...}"},
+		"mixed":                  {pattern: "/live/{channel}/{quality}"},
+		"missing leading slash":  {pattern: "live/{channel}", wantErr: true},
+		"empty":                  {pattern: "", wantErr: true},
+		"partial wildcard":       {pattern: "/live/room-{id}", wantErr: true},
+		"empty name":             {pattern: "/live/{}", wantErr: true},
+		"multi not last":         {pattern: "/live/{rest...}/video", wantErr: true},
+		"duplicate name":         {pattern: "/{a}/{a}", wantErr: true},
+		"unbalanced brace":       {pattern: "/live/{channel", wantErr: true},
+		"dotted name":            {pattern: "/live/{a.b}", wantErr: true},
+		"multi without name":     {pattern: "/live/{...}", wantErr: true},
+		"literal trailing slash": {pattern: "/live/"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parsePathPattern(tt.pattern)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPathPattern_Match(t *testing.T) {
+	tests := map[string]struct {
+		pattern string
+		path    BroadcastPath
+		match   bool
+		values  map[string]string
+	}{
+		"literal match":        {pattern: "/live/room", path: "/live/room", match: true},
+		"literal mismatch":     {pattern: "/live/room", path: "/live/other"},
+		"wildcard":             {pattern: "/live/{channel}", path: "/live/sports", match: true, values: map[string]string{"channel": "sports"}},
+		"wildcard too deep":    {pattern: "/live/{channel}", path: "/live/sports/hd"},
+		"wildcard too short":   {pattern: "/live/{channel}", path: "/live"},
+		"wildcard empty":       {pattern: "/live/{channel}", path: "/live/"},
+		"two wildcards":        {pattern: "/live/{channel}/{quality}", path: "/live/news/hd", match: true, values: map[string]string{"channel": "news", "quality": "hd"}},
+		"multi rest":           {pattern: "/vod/{rest...}", path: "/vod/2024/movie", match: true, values: map[string]string{"rest": "2024/movie"}},
+		"multi empty rest":     {pattern: "/vod/{rest...}", path: "/vod/", match: true, values: map[string]string{"rest": ""}},
+		"multi missing slash":  {pattern: "/vod/{rest...}", path: "/vod"},
+		"multi at root":        {pattern: "/{rest...}", path: "/a/b", match: true, values: map[string]string{"rest": "a/b"}},
+		"root literal":         {pattern: "/", path: "/", match: true},
+		"invalid path":         {pattern: "/{rest...}", path: "a/b"},
+		"trailing slash match": {pattern: "/live/", path: "/live/", match: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := parsePathPattern(tt.pattern)
+			require.NoError(t, err)
+
+			values, ok := p.match(tt.path)
+			assert.Equal(t, tt.match, ok)
+			if tt.match {
+				assert.Equal(t, tt.values, values)
+			}
+		})
+	}
+}
+
+func TestMux_Route(t *testing.T) {
+	tests := map[string]struct {
+		patterns  []string
+		published BroadcastPath
+		path      BroadcastPath
+		want      string
+		values    map[string]string
+	}{
+		"single pattern": {
+			patterns: []string{"/live/{channel}"},
+			path:     "/live/sports",
+			want:     "/live/{channel}",
+			values:   map[string]string{"channel": "sports"},
+		},
+		"literal beats wildcard": {
+			patterns: []string{"/live/{channel}", "/live/news"},
+			path:     "/live/news",
+			want:     "/live/news",
+		},
+		"wildcard beats multi": {
+			patterns: []string{"/live/{rest...}", "/live/{channel}"},
+			path:     "/live/news",
+			want:     "/live/{channel}",
+			values:   map[string]string{"channel": "news"},
+		},
+		"multi serves deeper paths": {
+			patterns: []string{"/live/{rest...}", "/live/{channel}"},
+			path:     "/live/news/hd",
+			want:     "/live/{rest...}",
+			values:   map[string]string{"rest": "news/hd"},
+		},
+		"earlier literal wins": {
+			patterns: []string{"/{app}/room", "/live/{room}"},
+			path:     "/live/room",
+			want:     "/live/{room}",
+			values:   map[string]string{"room": "room"},
+		},
+		"published path takes precedence": {
+			patterns:  []string{"/live/{channel}"},
+			published: "/live/news",
+			path:      "/live/news",
+			want:      "published",
+		},
+		"no match": {
+			patterns: []string{"/live/{channel}"},
+			path:     "/vod/movie",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mux := NewTrackMux(0)
+
+			served := ""
+			var values map[string]string
+			for _, pattern := range tt.patterns {
+				mux.RouteFunc(t.Context(), pattern, func(tw *TrackWriter) {
+					served = pattern
+					values = tw.pathValues
+				})
+			}
+			if tt.published != "" {
+				mux.PublishFunc(t.Context(), tt.published, func(tw *TrackWriter) { served = "published" })
+			}
+
+			tw := &TrackWriter{BroadcastPath: tt.path}
+			mux.serveTrack(tw)
+
+			assert.Equal(t, tt.want, served)
+			assert.Equal(t, tt.values, values)
+			for k, v := range tt.values {
+				assert.Equal(t, v, tw.PathValue(k))
+			}
+		})
+	}
+}
+
+func TestMux_Route_Replace(t *testing.T) {
+	mux := NewTrackMux(0)
+
+	served := ""
+	mux.RouteFunc(t.Context(), "/live/{channel}", func(tw *TrackWriter) { served = "first" })
+	mux.RouteFunc(t.Context(), "/live/{name}", func(tw *TrackWriter) { served = "second" })
+
+	mux.serveTrack(&TrackWriter{BroadcastPath: "/live/a"})
+	assert.Equal(t, "second", served, "an equivalent pattern should replace the registered one")
+	assert.Len(t, mux.patternHandlers, 1)
+}
+
+func TestMux_Route_ContextCanceled(t *testing.T) {
+	mux := NewTrackMux(0)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	served := false
+	mux.RouteFunc(ctx, "/live/{channel}", func(tw *TrackWriter) { served = true })
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		mux.mu.RLock()
+		defer mux.mu.RUnlock()
+		return len(mux.patternHandlers) == 0
+	}, time.Second, time.Millisecond)
+
+	mux.serveTrack(&TrackWriter{BroadcastPath: "/live/a"})
+	assert.False(t, served, "a canceled route should not serve subscriptions")
+}
+
+func TestMux_Route_ClosesTrackWhenCanceled(t *testing.T) {
+	mux := NewTrackMux(0)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	started := make(chan struct{})
+	mux.RouteFunc(ctx, "/live/{channel}", func(tw *TrackWriter) {
+		close(started)
+		<-tw.Context().Done()
+	})
+
+	stream := &FakeQUICStream{}
+	substr := newReceiveSubscribeStream(SubscribeID(1), stream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	tw := newTrackWriter("/live/a", "video", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	done := make(chan struct{})
+	go func() {
+		mux.serveTrack(tw)
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the track should be closed when the route is canceled")
+	}
+	assert.Error(t, stream.Context().Err())
+}
+
+func TestMux_Route_Panics(t *testing.T) {
+	mux := NewTrackMux(0)
+	assert.Panics(t, func() {
+		var nilCtx context.Context
+		mux.Route(nilCtx, "/live/{channel}", NotFoundTrackHandler)
+	}, "Route should panic when context is nil")
+	assert.Panics(t, func() { mux.Route(t.Context(), "live", NotFoundTrackHandler) })
+}
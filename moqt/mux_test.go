@@ -12,6 +12,7 @@ import (
 
 	"testing/synctest"
 
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 	"github.com/qumo-dev/gomoqt/transport"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -75,6 +76,21 @@ func TestMux_PublishFunc(t *testing.T) {
 	assert.True(t, called, "handler function should be called")
 }
 
+func TestMux_NumBroadcasts(t *testing.T) {
+	mux := NewTrackMux(0)
+	assert.Equal(t, 0, mux.NumBroadcasts())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mux.Publish(ctx, "/a", NotFoundTrackHandler)
+	mux.Publish(context.Background(), "/b", NotFoundTrackHandler)
+	assert.Equal(t, 2, mux.NumBroadcasts())
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		return mux.NumBroadcasts() == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
 // Test path validation
 func TestMux_Publish_InvalidPath(t *testing.T) {
 	mux := NewTrackMux(0)
@@ -139,7 +155,7 @@ func TestMux_Announce_WithNilHandler_ClosesTrack(t *testing.T) {
 	// Now try serveTrack - should call CloseWithError and stream CancelWrite/CancelRead with TrackNotFoundErrorCode
 	mockStream := &FakeQUICStream{}
 
-	tw := newTrackWriter(path, TrackName("test"), newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream { return mockStream }(), &SubscribeConfig{}), func() (transport.SendStream, error) {
+	tw := newTrackWriter(path, TrackName("test"), newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream { return mockStream }(), &SubscribeConfig{}, message.VersionLite04Ext, nil), func() (transport.SendStream, error) {
 		return &FakeQUICSendStream{}, nil
 	}, func() {})
 
@@ -162,7 +178,7 @@ func TestMux_ServeTrack_NotFound_ClosesWithError(t *testing.T) {
 
 	mockStream := &FakeQUICStream{}
 
-	tw := newTrackWriter(path, TrackName("test"), newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream { return mockStream }(), &SubscribeConfig{}), func() (transport.SendStream, error) {
+	tw := newTrackWriter(path, TrackName("test"), newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream { return mockStream }(), &SubscribeConfig{}, message.VersionLite04Ext, nil), func() (transport.SendStream, error) {
 		return &FakeQUICSendStream{}, nil
 	}, func() {})
 
@@ -684,7 +700,7 @@ func TestNotFound(t *testing.T) {
 			trackWriter: newTrackWriter(BroadcastPath("/test"), TrackName("test"),
 				newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream {
 					return &FakeQUICStream{}
-				}(), &SubscribeConfig{}),
+				}(), &SubscribeConfig{}, message.VersionLite04Ext, nil),
 				func() (transport.SendStream, error) {
 					return &FakeQUICSendStream{}, nil
 				}, func() {}),
@@ -717,7 +733,7 @@ func TestNotFoundHandler(t *testing.T) {
 			trackWriter: newTrackWriter(BroadcastPath("/test"), TrackName("test"),
 				newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream {
 					return &FakeQUICStream{}
-				}(), &SubscribeConfig{}),
+				}(), &SubscribeConfig{}, message.VersionLite04Ext, nil),
 				func() (transport.SendStream, error) {
 					return &FakeQUICSendStream{}, nil
 				}, func() {}),
@@ -748,7 +764,7 @@ func TestTrackHandlerFunc(t *testing.T) {
 	testTrackWriter := newTrackWriter(BroadcastPath("/test"), TrackName("test"),
 		newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream {
 			return &FakeQUICStream{}
-		}(), &SubscribeConfig{}),
+		}(), &SubscribeConfig{}, message.VersionLite04Ext, nil),
 		func() (transport.SendStream, error) {
 			return &FakeQUICSendStream{}, nil
 		}, func() {})
@@ -770,7 +786,7 @@ func TestTrackHandlerFuncServeTrack(t *testing.T) {
 	trackWriter := newTrackWriter(BroadcastPath("/test"), TrackName("test"),
 		newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream {
 			return &FakeQUICStream{}
-		}(), &SubscribeConfig{}),
+		}(), &SubscribeConfig{}, message.VersionLite04Ext, nil),
 		func() (transport.SendStream, error) {
 			return &FakeQUICSendStream{}, nil
 		}, func() {})
@@ -1905,7 +1921,7 @@ func TestMux_ServeTrack_ClosesWhenAnnouncementEnds(t *testing.T) {
 	streamCtx := t.Context()
 	mockStream.ParentCtx = streamCtx
 
-	tw := newTrackWriter(path, TrackName("test"), newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream { return mockStream }(), &SubscribeConfig{}), func() (transport.SendStream, error) {
+	tw := newTrackWriter(path, TrackName("test"), newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream { return mockStream }(), &SubscribeConfig{}, message.VersionLite04Ext, nil), func() (transport.SendStream, error) {
 		return &FakeQUICSendStream{}, nil
 	}, func() {})
 
@@ -2223,7 +2239,7 @@ func TestMux_Publish_WithNilHandler_ClosesTrack(t *testing.T) {
 	// Serve should close with TrackNotFound
 	mockStream := &FakeQUICStream{}
 
-	tw := newTrackWriter(path, TrackName("test"), newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream { return mockStream }(), &SubscribeConfig{}), func() (transport.SendStream, error) {
+	tw := newTrackWriter(path, TrackName("test"), newReceiveSubscribeStream(SubscribeID(1), func() transport.Stream { return mockStream }(), &SubscribeConfig{}, message.VersionLite04Ext, nil), func() (transport.SendStream, error) {
 		return &FakeQUICSendStream{}, nil
 	}, func() {})
 
@@ -0,0 +1,116 @@
+package moqt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPacingBurstDuration is the amount of data, expressed as time at
+	// the target bitrate, that may be sent back to back when no burst is set.
+	defaultPacingBurstDuration = 10 * time.Millisecond
+
+	// minPacingBurst is the smallest default burst in bytes, roughly one
+	// QUIC packet, so that low bitrates still send whole packets.
+	minPacingBurst = 1200
+)
+
+// pacer is a token bucket measured in bytes. Callers reserve the size of the
+// data they are about to send and wait for the returned delay, which spreads
+// large groups over time instead of handing them to the transport at once.
+//
+// Reservations may drive the bucket negative so that frames larger than the
+// burst are still admitted, after a proportionally longer wait.
+type pacer struct {
+	mu sync.Mutex
+
+	rate   float64 // bytes per second; zero disables pacing
+	burst  float64 // bucket capacity in bytes
+	tokens float64
+	last   time.Time
+}
+
+// setRate configures the pacer. A zero bitrate disables pacing. A zero burst
+// selects a default derived from the bitrate.
+func (p *pacer) setRate(bitrate uint64, burst int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rate = float64(bitrate) / 8
+	if burst > 0 {
+		p.burst = float64(burst)
+	} else {
+		p.burst = max(p.rate*defaultPacingBurstDuration.Seconds(), minPacingBurst)
+	}
+	p.tokens = p.burst
+	p.last = time.Time{}
+}
+
+// reserve takes n bytes from the bucket and returns how long the caller must
+// wait before sending them.
+func (p *pacer) reserve(n int, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rate <= 0 {
+		return 0
+	}
+
+	if !p.last.IsZero() {
+		elapsed := now.Sub(p.last).Seconds()
+		if elapsed > 0 {
+			p.tokens = min(p.burst, p.tokens+elapsed*p.rate)
+		}
+	}
+	p.last = now
+
+	p.tokens -= float64(n)
+	if p.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-p.tokens / p.rate * float64(time.Second))
+}
+
+// wait blocks until n bytes may be sent or ctx is done.
+func (p *pacer) wait(ctx context.Context, n int) error {
+	return pace(ctx, n, p)
+}
+
+// pace reserves n bytes from every non-nil pacer and blocks until the
+// longest of their delays has passed or ctx is done.
+func pace(ctx context.Context, n int, pacers ...*pacer) error {
+	now := time.Now()
+	var delay time.Duration
+	for _, p := range pacers {
+		if p != nil {
+			delay = max(delay, p.reserve(n, now))
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return Cause(ctx)
+	}
+}
+
+// SetBandwidthLimit caps the rate at which the session sends the frames of
+// all the tracks it publishes, in bits per second, on top of the pacing rate
+// and Config.MaxSubscriptionBitrate of each track. Frames are delayed in the
+// group send loop rather than dropped, so a relay can serve sessions at
+// different service levels. Zero removes the cap, which is the default.
+//
+// The new limit applies to frames written after the call. It is safe to
+// call concurrently.
+func (sess *Session) SetBandwidthLimit(bitrate uint64) {
+	sess.bandwidth.setRate(bitrate, 0)
+}
@@ -0,0 +1,336 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacer_Reserve(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	tests := map[string]struct {
+		bitrate  uint64
+		burst    int
+		sizes    []int
+		expected []time.Duration
+	}{
+		"disabled": {
+			bitrate:  0,
+			sizes:    []int{1 << 20, 1 << 20},
+			expected: []time.Duration{0, 0},
+		},
+		"within burst": {
+			bitrate:  8000, // 1000 bytes/s
+			burst:    500,
+			sizes:    []int{200, 300},
+			expected: []time.Duration{0, 0},
+		},
+		"exceeds burst": {
+			bitrate:  8000, // 1000 bytes/s
+			burst:    500,
+			sizes:    []int{500, 250, 250},
+			expected: []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond},
+		},
+		"frame larger than burst": {
+			bitrate:  8000,
+			burst:    100,
+			sizes:    []int{1100},
+			expected: []time.Duration{time.Second},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var p pacer
+			p.setRate(tt.bitrate, tt.burst)
+			for i, size := range tt.sizes {
+				assert.Equal(t, tt.expected[i], p.reserve(size, start), "reservation %d", i)
+			}
+		})
+	}
+}
+
+func TestPacer_Refill(t *testing.T) {
+	var p pacer
+	p.setRate(8000, 1000) // 1000 bytes/s
+
+	start := time.Unix(0, 0)
+	assert.Equal(t, time.Duration(0), p.reserve(1000, start))
+
+	// Half a second refills half of the bucket.
+	assert.Equal(t, time.Duration(0), p.reserve(500, start.Add(500*time.Millisecond)))
+	assert.Equal(t, 100*time.Millisecond, p.reserve(100, start.Add(500*time.Millisecond)))
+
+	// Refill never exceeds the burst.
+	assert.Equal(t, time.Duration(0), p.reserve(1000, start.Add(time.Hour)))
+	assert.Equal(t, time.Second, p.reserve(1000, start.Add(time.Hour)))
+}
+
+func TestPacer_DefaultBurst(t *testing.T) {
+	tests := map[string]struct {
+		bitrate  uint64
+		expected float64
+	}{
+		"low bitrate uses minimum": {bitrate: 64_000, expected: minPacingBurst},
+		"high bitrate uses 10ms":   {bitrate: 80_000_000, expected: 100_000},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var p pacer
+			p.setRate(tt.bitrate, 0)
+			assert.Equal(t, tt.expected, p.burst)
+		})
+	}
+}
+
+func TestPacer_Wait(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var p pacer
+		p.setRate(8000, 100) // 1000 bytes/s
+
+		start := time.Now()
+		require.NoError(t, p.wait(context.Background(), 100))
+		require.NoError(t, p.wait(context.Background(), 500))
+		assert.Equal(t, 500*time.Millisecond, time.Since(start))
+	})
+}
+
+func TestPacer_Wait_ContextCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var p pacer
+		p.setRate(8, 1) // 1 byte/s
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(time.Second)
+			cancel()
+		}()
+
+		err := p.wait(ctx, 1000)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestPacer_Wait_Nil(t *testing.T) {
+	var p *pacer
+	assert.NoError(t, p.wait(context.Background(), 1<<20))
+}
+
+func TestTrackWriter_SetPacingRate(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+		openUniStreamFunc := func() (transport.SendStream, error) {
+			return &FakeQUICSendStream{}, nil
+		}
+		writer := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, func() {})
+		defer writer.Close()
+
+		writer.SetPacingRate(80_000, 1000) // 10,000 bytes/s
+
+		group, err := writer.OpenGroup()
+		require.NoError(t, err)
+
+		frame := NewFrame(1000)
+		_, _ = frame.Write(make([]byte, 1000))
+
+		start := time.Now()
+		for range 11 {
+			require.NoError(t, group.WriteFrame(frame))
+		}
+		// The first frame fits in the burst; the other ten take 100ms each.
+		assert.Equal(t, time.Second, time.Since(start))
+
+		// Disabling pacing lets frames through immediately.
+		writer.SetPacingRate(0, 0)
+		start = time.Now()
+		for range 10 {
+			require.NoError(t, group.WriteFrame(frame))
+		}
+		assert.Equal(t, time.Duration(0), time.Since(start))
+	})
+}
+
+func TestGroupWriter_WriteFrame_PacingCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		stream := &FakeQUICSendStream{}
+		group := newGroupWriter(stream, GroupSequence(1), nil)
+		p := &pacer{}
+		p.setRate(8, 1) // 1 byte/s
+		group.pacers = []*pacer{p}
+
+		go func() {
+			time.Sleep(time.Second)
+			group.CancelWrite(ExpiredGroupErrorCode)
+		}()
+
+		frame := NewFrame(100)
+		_, _ = frame.Write(make([]byte, 100))
+		err := group.WriteFrame(frame)
+
+		var grpErr *GroupError
+		require.True(t, errors.As(err, &grpErr), "expected GroupError, got %v", err)
+		assert.Equal(t, ExpiredGroupErrorCode, grpErr.GroupErrorCode())
+	})
+}
+
+func TestTrackWriter_Close_PacedWrite(t *testing.T) {
+	tests := map[string]func(w *TrackWriter){
+		"close":            func(w *TrackWriter) { _ = w.Close() },
+		"close with error": func(w *TrackWriter) { w.CloseWithError(SubscribeErrorCodeInternal) },
+	}
+
+	for name, closeTrack := range tests {
+		t.Run(name, func(t *testing.T) {
+			substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+			openUniStreamFunc := func() (transport.SendStream, error) {
+				return &FakeQUICSendStream{}, nil
+			}
+			writer := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, func() {})
+			writer.SetPacingRate(8, 1) // 1 byte/s
+
+			group, err := writer.OpenGroup()
+			require.NoError(t, err)
+
+			// The write waits for 99s of pacing, which closing the track
+			// must not wait for.
+			errCh := make(chan error, 1)
+			go func() {
+				frame := NewFrame(100)
+				_, _ = frame.Write(make([]byte, 100))
+				errCh <- group.WriteFrame(frame)
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			closed := make(chan struct{})
+			go func() {
+				closeTrack(writer)
+				close(closed)
+			}()
+			select {
+			case <-closed:
+			case <-time.After(time.Second):
+				t.Fatal("closing the track waited for the paced write")
+			}
+			assert.ErrorIs(t, <-errCh, ErrClosedTrack)
+		})
+	}
+}
+
+func TestPace_Slowest(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var fast, slow pacer
+		fast.setRate(80_000, 100) // 10,000 bytes/s
+		slow.setRate(8_000, 100)  // 1,000 bytes/s
+
+		start := time.Now()
+		require.NoError(t, pace(context.Background(), 100, &fast, nil, &slow))
+		require.NoError(t, pace(context.Background(), 100, &fast, nil, &slow))
+		assert.Equal(t, 100*time.Millisecond, time.Since(start))
+	})
+}
+
+func TestTrackWriter_BandwidthLimits(t *testing.T) {
+	tests := map[string]struct {
+		bitrate        uint64 // Config.MaxSubscriptionBitrate
+		sessionBitrate uint64 // Session.SetBandwidthLimit
+		expected       time.Duration
+	}{
+		"subscription cap": {
+			bitrate:  80_000, // 10,000 bytes/s
+			expected: time.Second,
+		},
+		"session limit": {
+			sessionBitrate: 40_000, // 5,000 bytes/s
+			expected:       2 * time.Second,
+		},
+		"slowest applies": {
+			bitrate:        80_000,
+			sessionBitrate: 40_000,
+			expected:       2 * time.Second,
+		},
+		"unlimited": {
+			expected: 0,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			synctest.Test(t, func(t *testing.T) {
+				substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+				openUniStreamFunc := func() (transport.SendStream, error) {
+					return &FakeQUICSendStream{}, nil
+				}
+				writer := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, func() {})
+				defer writer.Close()
+
+				var session pacer
+				session.setRate(tt.sessionBitrate, 1000)
+				writer.bitrateLimit.setRate(tt.bitrate, 1000)
+				writer.sessionLimit = &session
+
+				group, err := writer.OpenGroup()
+				require.NoError(t, err)
+
+				frame := NewFrame(1000)
+				_, _ = frame.Write(make([]byte, 1000))
+
+				start := time.Now()
+				for range 11 {
+					require.NoError(t, group.WriteFrame(frame))
+				}
+				assert.Equal(t, tt.expected, time.Since(start))
+			})
+		})
+	}
+}
+
+func TestSession_BandwidthLimits(t *testing.T) {
+	session := newSession(&FakeStreamConn{}, NewTrackMux(0), nil, &Config{MaxSubscriptionBitrate: 1_000_000}, nil, nil, nil, nil)
+	t.Cleanup(func() { _ = session.CloseWithError(NoError, "") })
+
+	session.SetBandwidthLimit(2_000_000)
+	assert.Equal(t, 250_000.0, session.bandwidth.rate)
+
+	rates := make(chan float64, 1)
+	session.mux.PublishFunc(t.Context(), "/test/path", func(tw *TrackWriter) {
+		assert.Same(t, &session.bandwidth, tw.sessionLimit)
+		rates <- tw.bitrateLimit.rate
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, message.StreamTypeSubscribe.Encode(&buf))
+	require.NoError(t, message.SubscribeMessage{
+		SubscribeID:   1,
+		BroadcastPath: "/test/path",
+		TrackName:     "video",
+	}.Encode(&buf))
+	session.processBiStream(&FakeQUICStream{
+		ReadFunc: func(p []byte) (int, error) {
+			if buf.Len() == 0 {
+				return 0, io.EOF
+			}
+			return buf.Read(p)
+		},
+	}, func() {})
+
+	select {
+	case rate := <-rates:
+		assert.Equal(t, 125_000.0, rate)
+	default:
+		t.Fatal("subscription not served")
+	}
+
+	session.SetBandwidthLimit(0)
+	assert.Zero(t, session.bandwidth.rate)
+}
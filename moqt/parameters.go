@@ -0,0 +1,255 @@
+package moqt
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+)
+
+// Parameters are the extension parameters of a subscription, keyed by
+// parameter type and held in their encoded form. Parameters are sent with
+// the SUBSCRIBE message, and those a peer does not know are kept as
+// received, so that extensions reach the publisher across relays
+// unmodified. Values of a known type are accessed with a Parameter.
+type Parameters map[uint64][]byte
+
+// Clone returns a copy of p, or nil if p is empty.
+func (p Parameters) Clone() Parameters {
+	if len(p) == 0 {
+		return nil
+	}
+	c := make(Parameters, len(p))
+	for key, value := range p {
+		c[key] = append([]byte(nil), value...)
+	}
+	return c
+}
+
+// ParameterKind is the encoding of the values of a parameter type.
+type ParameterKind uint8
+
+const (
+	// ParameterVarint values are QUIC variable-length integers.
+	ParameterVarint ParameterKind = iota + 1
+
+	// ParameterBytes values are opaque bytes.
+	ParameterBytes
+
+	// ParameterString values are UTF-8 text.
+	ParameterString
+
+	// ParameterBool values are a single byte, 0 or 1.
+	ParameterBool
+)
+
+func (k ParameterKind) String() string {
+	switch k {
+	case ParameterVarint:
+		return "varint"
+	case ParameterBytes:
+		return "bytes"
+	case ParameterString:
+		return "string"
+	case ParameterBool:
+		return "bool"
+	default:
+		return fmt.Sprintf("ParameterKind(%d)", uint8(k))
+	}
+}
+
+// ParameterType describes a parameter type regardless of the Go type of its
+// values. It is implemented by Parameter.
+type ParameterType interface {
+	// Key is the key of the parameter in Parameters.
+	Key() uint64
+
+	// Name is a text describing the parameter, used in errors.
+	Name() string
+
+	// Kind is the encoding of the values of the parameter.
+	Kind() ParameterKind
+
+	// Check returns an error if value is not a valid encoded value.
+	Check(value []byte) error
+}
+
+// Parameter is a parameter type whose values are of type T. It gets and
+// sets the values of the parameter in Parameters.
+type Parameter[T any] struct {
+	key    uint64
+	name   string
+	kind   ParameterKind
+	encode func(v T) []byte
+	decode func(value []byte) (T, error)
+}
+
+var (
+	errTrailingBytes = errors.New("trailing bytes")
+	errInvalidUTF8   = errors.New("invalid UTF-8")
+	errInvalidBool   = errors.New("not a boolean")
+)
+
+// VarintParameter returns the parameter type key with integer values.
+func VarintParameter(key uint64, name string) Parameter[uint64] {
+	return Parameter[uint64]{
+		key:  key,
+		name: name,
+		kind: ParameterVarint,
+		encode: func(v uint64) []byte {
+			b, _ := message.WriteVarint(nil, v)
+			return b
+		},
+		decode: func(value []byte) (uint64, error) {
+			v, n, err := message.ReadVarint(value)
+			if err != nil {
+				return 0, err
+			}
+			if n != len(value) {
+				return 0, errTrailingBytes
+			}
+			return v, nil
+		},
+	}
+}
+
+// BytesParameter returns the parameter type key with opaque values.
+func BytesParameter(key uint64, name string) Parameter[[]byte] {
+	return Parameter[[]byte]{
+		key:    key,
+		name:   name,
+		kind:   ParameterBytes,
+		encode: func(v []byte) []byte { return append([]byte(nil), v...) },
+		decode: func(value []byte) ([]byte, error) { return append([]byte(nil), value...), nil },
+	}
+}
+
+// StringParameter returns the parameter type key with text values.
+func StringParameter(key uint64, name string) Parameter[string] {
+	return Parameter[string]{
+		key:    key,
+		name:   name,
+		kind:   ParameterString,
+		encode: func(v string) []byte { return []byte(v) },
+		decode: func(value []byte) (string, error) {
+			if !utf8.Valid(value) {
+				return "", errInvalidUTF8
+			}
+			return string(value), nil
+		},
+	}
+}
+
+// BoolParameter returns the parameter type key with boolean values.
+func BoolParameter(key uint64, name string) Parameter[bool] {
+	return Parameter[bool]{
+		key:  key,
+		name: name,
+		kind: ParameterBool,
+		encode: func(v bool) []byte {
+			if v {
+				return []byte{1}
+			}
+			return []byte{0}
+		},
+		decode: func(value []byte) (bool, error) {
+			if len(value) != 1 || value[0] > 1 {
+				return false, errInvalidBool
+			}
+			return value[0] == 1, nil
+		},
+	}
+}
+
+// Key implements ParameterType.
+func (p Parameter[T]) Key() uint64 { return p.key }
+
+// Name implements ParameterType.
+func (p Parameter[T]) Name() string { return p.name }
+
+// Kind implements ParameterType.
+func (p Parameter[T]) Kind() ParameterKind { return p.kind }
+
+// Check implements ParameterType.
+func (p Parameter[T]) Check(value []byte) error {
+	_, err := p.decode(value)
+	return err
+}
+
+// Get returns the value of the parameter in params. It reports false if
+// params does not hold the parameter or its value is malformed.
+func (p Parameter[T]) Get(params Parameters) (T, bool) {
+	value, ok := params[p.key]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	v, err := p.decode(value)
+	return v, err == nil
+}
+
+// Set sets the value of the parameter in *params, allocating the map if
+// it is nil.
+func (p Parameter[T]) Set(params *Parameters, v T) {
+	if *params == nil {
+		*params = make(Parameters)
+	}
+	(*params)[p.key] = p.encode(v)
+}
+
+// ParameterRegistry is a set of parameter types indexed by key. A session
+// whose Config.Parameters registers a type rejects subscriptions carrying a
+// malformed value of it. Parameters of unregistered types are passed
+// through.
+type ParameterRegistry map[uint64]ParameterType
+
+// NewParameterRegistry returns a registry holding the given parameter types.
+func NewParameterRegistry(types ...ParameterType) ParameterRegistry {
+	r := make(ParameterRegistry, len(types))
+	for _, t := range types {
+		r[t.Key()] = t
+	}
+	return r
+}
+
+// Lookup returns the parameter type with the given key.
+func (r ParameterRegistry) Lookup(key uint64) (ParameterType, bool) {
+	t, ok := r[key]
+	return t, ok
+}
+
+// Check returns a *ParameterError for the first parameter of params, by
+// key, whose type is registered and whose value is malformed.
+func (r ParameterRegistry) Check(params Parameters) error {
+	if len(r) == 0 {
+		return nil
+	}
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		t, ok := r[key]
+		if !ok {
+			continue
+		}
+		if err := t.Check(params[key]); err != nil {
+			return &ParameterError{Key: key, Name: t.Name(), Err: err}
+		}
+	}
+	return nil
+}
+
+// ParameterError is returned for a parameter whose value is malformed.
+type ParameterError struct {
+	Key  uint64
+	Name string
+	Err  error
+}
+
+func (e *ParameterError) Error() string {
+	return fmt.Sprintf("moqt: invalid parameter %s (%#x): %v", e.Name, e.Key, e.Err)
+}
+
+func (e *ParameterError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,218 @@
+package moqt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParameter_SetGet(t *testing.T) {
+	var params Parameters
+	VarintParameter(0x10, "max-bitrate").Set(&params, 1_000_000)
+	BytesParameter(0x11, "session-id").Set(&params, []byte{1, 2, 3})
+	StringParameter(0x12, "quality").Set(&params, "hd")
+	BoolParameter(0x13, "low-latency").Set(&params, true)
+
+	assert.Equal(t, Parameters{
+		0x10: {0x80, 0x0f, 0x42, 0x40},
+		0x11: {1, 2, 3},
+		0x12: []byte("hd"),
+		0x13: {1},
+	}, params)
+
+	bitrate, ok := VarintParameter(0x10, "max-bitrate").Get(params)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1_000_000), bitrate)
+	id, ok := BytesParameter(0x11, "session-id").Get(params)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1, 2, 3}, id)
+	quality, ok := StringParameter(0x12, "quality").Get(params)
+	assert.True(t, ok)
+	assert.Equal(t, "hd", quality)
+	lowLatency, ok := BoolParameter(0x13, "low-latency").Get(params)
+	assert.True(t, ok)
+	assert.True(t, lowLatency)
+
+	_, ok = StringParameter(0x14, "missing").Get(params)
+	assert.False(t, ok)
+}
+
+func TestParameter_Check(t *testing.T) {
+	tests := map[string]struct {
+		typ     ParameterType
+		value   []byte
+		wantErr bool
+	}{
+		"varint":              {typ: VarintParameter(1, "v"), value: []byte{0x25}},
+		"varint truncated":    {typ: VarintParameter(1, "v"), value: []byte{0x40}, wantErr: true},
+		"varint trailing":     {typ: VarintParameter(1, "v"), value: []byte{0x25, 0x00}, wantErr: true},
+		"bytes":               {typ: BytesParameter(1, "b"), value: []byte{0xff}},
+		"string":              {typ: StringParameter(1, "s"), value: []byte("text")},
+		"string invalid utf8": {typ: StringParameter(1, "s"), value: []byte{0xff}, wantErr: true},
+		"bool false":          {typ: BoolParameter(1, "b"), value: []byte{0}},
+		"bool invalid":        {typ: BoolParameter(1, "b"), value: []byte{2}, wantErr: true},
+		"bool empty":          {typ: BoolParameter(1, "b"), value: []byte{}, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.typ.Check(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParameterRegistry_Check(t *testing.T) {
+	registry := NewParameterRegistry(
+		StringParameter(0x12, "quality"),
+		BoolParameter(0x13, "low-latency"),
+	)
+	typ, ok := registry.Lookup(0x13)
+	require.True(t, ok)
+	assert.Equal(t, ParameterBool, typ.Kind())
+
+	tests := map[string]struct {
+		params  Parameters
+		wantKey uint64
+	}{
+		"valid":           {params: Parameters{0x12: []byte("hd"), 0x13: {0}}},
+		"unregistered":    {params: Parameters{0x99: {0xff, 0xff}}},
+		"malformed":       {params: Parameters{0x12: []byte("hd"), 0x13: {7}}, wantKey: 0x13},
+		"first malformed": {params: Parameters{0x12: {0xff}, 0x13: {7}}, wantKey: 0x12},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := registry.Check(tt.params)
+			if tt.wantKey == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var paramErr *ParameterError
+			require.ErrorAs(t, err, &paramErr)
+			assert.Equal(t, tt.wantKey, paramErr.Key)
+		})
+	}
+}
+
+func TestParameters_Clone(t *testing.T) {
+	assert.Nil(t, Parameters{}.Clone())
+
+	params := Parameters{1: {1}}
+	clone := params.Clone()
+	clone[1][0] = 2
+	assert.Equal(t, Parameters{1: {1}}, params)
+}
+
+// newParameterSession returns a session registering the boolean parameter
+// 0x13.
+func newParameterSession(t *testing.T, conn *FakeStreamConn) *Session {
+	extendedConn(conn)
+	config := &Config{Parameters: NewParameterRegistry(BoolParameter(0x13, "low-latency"))}
+	session := newSession(conn, NewTrackMux(0), nil, config, nil, nil, nil, nil)
+	t.Cleanup(func() {
+		_ = session.CloseWithError(NoError, "")
+	})
+	return session
+}
+
+func TestSession_Subscribe_Parameters(t *testing.T) {
+	var written bytes.Buffer
+	opened := false
+	session := newParameterSession(t, &FakeStreamConn{
+		OpenStreamFunc: func() (transport.Stream, error) {
+			opened = true
+			return &FakeQUICStream{WriteFunc: written.Write}, nil
+		},
+	})
+
+	// A malformed registered parameter is not sent.
+	_, err := session.Subscribe(t.Context(), "/test/path", "video", &SubscribeConfig{
+		Parameters: Parameters{0x13: {7}},
+	})
+	var paramErr *ParameterError
+	require.ErrorAs(t, err, &paramErr)
+	assert.False(t, opened)
+
+	params := Parameters{0x13: {1}, 0x99: []byte("opaque")}
+	_, _ = session.Subscribe(t.Context(), "/test/path", "video", &SubscribeConfig{Parameters: params})
+
+	var st message.StreamType
+	require.NoError(t, st.Decode(&written))
+	sm := message.SubscribeMessage{Version: message.VersionLite04Ext}
+	require.NoError(t, sm.Decode(&written))
+	assert.Equal(t, message.Parameters(params), sm.Parameters)
+}
+
+func TestSession_ProcessBiStream_SubscribeParameters(t *testing.T) {
+	tests := map[string]struct {
+		params   message.Parameters
+		wantCode *transport.StreamErrorCode
+	}{
+		"passed through": {
+			params: message.Parameters{0x13: {1}, 0x99: []byte("opaque")},
+		},
+		"malformed": {
+			params:   message.Parameters{0x13: {7}},
+			wantCode: new(transport.StreamErrorCode(SubscribeErrorCodeInvalidParameter)),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			session := newParameterSession(t, &FakeStreamConn{})
+
+			got := make(chan Parameters, 1)
+			session.mux.PublishFunc(t.Context(), "/test/path", func(tw *TrackWriter) {
+				got <- tw.TrackConfig().Parameters
+			})
+
+			var buf bytes.Buffer
+			require.NoError(t, message.StreamTypeSubscribe.Encode(&buf))
+			require.NoError(t, message.SubscribeMessage{
+				SubscribeID:   1,
+				BroadcastPath: "/test/path",
+				TrackName:     "video",
+				Parameters:    tt.params,
+				Version:       message.VersionLite04Ext,
+			}.Encode(&buf))
+			var code *transport.StreamErrorCode
+			stream := &FakeQUICStream{
+				ReadFunc: func(p []byte) (int, error) {
+					if buf.Len() == 0 {
+						return 0, io.EOF
+					}
+					return buf.Read(p)
+				},
+				CancelWriteFunc: func(c transport.StreamErrorCode) {
+					code = &c
+				},
+			}
+
+			session.processBiStream(stream, func() {})
+
+			if tt.wantCode != nil {
+				require.NotNil(t, code)
+				assert.Equal(t, *tt.wantCode, *code)
+				assert.Empty(t, got)
+				return
+			}
+			select {
+			case params := <-got:
+				assert.Equal(t, Parameters(tt.params), params)
+			case <-time.After(time.Second):
+				t.Fatal("track handler was not called")
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/connectudp"
+	"github.com/qumo-dev/gomoqt/moqt/internal/webtransportgo"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxyURL returns the proxy of a WebTransport session to target, or nil
+// to dial it directly.
+func (d *Dialer) proxyURL(target string) (*url.URL, error) {
+	proxy := d.Proxy
+	if proxy == nil {
+		// As http.ProxyFromEnvironment, but reading the environment on
+		// each dial rather than once per process.
+		proxy = func(req *http.Request) (*url.URL, error) {
+			return httpproxy.FromEnvironment().ProxyFunc()(req.URL)
+		}
+	}
+	req, err := http.NewRequest(http.MethodConnect, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return proxy(req)
+}
+
+// proxyPacketDialer returns the function dialing the QUIC packets of a
+// WebTransport session to target through the proxy selected by d.Proxy, or
+// nil to dial them directly.
+func (d *Dialer) proxyPacketDialer(target string) (webtransportgo.DialPacketFunc, error) {
+	proxy, err := d.proxyURL(target)
+	if err != nil || proxy == nil {
+		return nil, err
+	}
+	if proxy.Scheme != "http" && proxy.Scheme != "https" {
+		return nil, fmt.Errorf("moqt: unsupported proxy scheme %q", proxy.Scheme)
+	}
+
+	var tlsConfig *tls.Config
+	if d.TLSConfig != nil {
+		tlsConfig = &tls.Config{RootCAs: d.TLSConfig.RootCAs}
+	}
+	return func(ctx context.Context, addr string) (net.PacketConn, net.Addr, error) {
+		conn, err := connectudp.Dial(ctx, proxy, addr, d.DialProxyFunc, tlsConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, conn.RemoteAddr(), nil
+	}, nil
+}
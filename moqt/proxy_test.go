@@ -0,0 +1,125 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialer_DialWebTransport_Proxy(t *testing.T) {
+	errBlocked := errors.New("blocked")
+
+	tests := map[string]struct {
+		proxy      string
+		wantDialed string
+		wantErr    error
+	}{
+		"http proxy": {
+			proxy:      "http://proxy.example.com:3128",
+			wantDialed: "proxy.example.com:3128",
+			wantErr:    errBlocked,
+		},
+		"https proxy default port": {
+			proxy:      "https://proxy.example.com",
+			wantDialed: "proxy.example.com:443",
+			wantErr:    errBlocked,
+		},
+		"unsupported scheme": {
+			proxy: "socks5://proxy.example.com:1080",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxyURL, err := url.Parse(tt.proxy)
+			require.NoError(t, err)
+
+			var requested *http.Request
+			var dialed string
+			dialer := &Dialer{
+				Proxy: func(req *http.Request) (*url.URL, error) {
+					requested = req
+					return proxyURL, nil
+				},
+				DialProxyFunc: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					dialed = addr
+					return nil, errBlocked
+				},
+			}
+
+			_, err = dialer.DialWebTransport(t.Context(), "relay.example.com:4433", "/moq", nil)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			require.NotNil(t, requested)
+			assert.Equal(t, "https://relay.example.com:4433/moq", requested.URL.String())
+			assert.Equal(t, tt.wantDialed, dialed)
+		})
+	}
+}
+
+func TestDialer_DialWebTransport_NoProxy(t *testing.T) {
+	proxyDialed := false
+	dialer := &Dialer{
+		Config: &Config{SetupTimeout: 50 * time.Millisecond},
+		Proxy: func(*http.Request) (*url.URL, error) {
+			return nil, nil // as http.ProxyFromEnvironment for NO_PROXY hosts
+		},
+		DialProxyFunc: func(context.Context, string, string) (net.Conn, error) {
+			proxyDialed = true
+			return nil, errors.New("unexpected")
+		},
+	}
+
+	_, err := dialer.DialWebTransport(t.Context(), "127.0.0.1:1", "/moq", nil)
+	assert.Error(t, err)
+	assert.False(t, proxyDialed)
+}
+
+func TestClient_Dial_ProxySkipsQUIC(t *testing.T) {
+	quicDialed := false
+	client := &Client{
+		Dialer: &Dialer{
+			Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: "proxy.example.com:3128"}),
+			DialQUICFunc: func(context.Context, string, *tls.Config, *quic.Config) (StreamConn, error) {
+				quicDialed = true
+				return nil, errors.New("refused")
+			},
+			DialProxyFunc: func(context.Context, string, string) (net.Conn, error) {
+				return nil, errors.New("refused")
+			},
+		},
+	}
+
+	_, err := client.Dial(t.Context(), "https://example.com:443")
+	require.Error(t, err)
+	assert.False(t, quicDialed)
+}
+
+func TestDialer_DialWebTransport_EnvironmentProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+	t.Setenv("NO_PROXY", "")
+	errBlocked := errors.New("blocked")
+
+	var dialed string
+	dialer := &Dialer{
+		DialProxyFunc: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = addr
+			return nil, errBlocked
+		},
+	}
+
+	_, err := dialer.DialWebTransport(t.Context(), "relay.example.com:4433", "/moq", nil)
+	assert.ErrorIs(t, err, errBlocked)
+	assert.Equal(t, "proxy.example.com:3128", dialed)
+}
@@ -0,0 +1,325 @@
+package moqt
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+)
+
+// qlog vantage points.
+const (
+	qlogClient = "client"
+	qlogServer = "server"
+)
+
+// qlogRecordSeparator starts every record of a JSON-SEQ file (RFC 7464).
+const qlogRecordSeparator = 0x1e
+
+// qlogWriter writes the MOQT events of a session as a qlog trace in the
+// JSON-SEQ format, so that it can be loaded into qvis next to the transport
+// qlog of quic-go.
+//
+// A nil *qlogWriter is valid and discards all events, so call sites only pay
+// for a nil check when qlog is disabled. The file is created by start, once
+// the vantage point is known; events recorded before start are held in
+// memory until then.
+type qlogWriter struct {
+	dir string
+	// ref is the reference time of the trace, the creation of the session.
+	ref time.Time
+
+	mu      sync.Mutex
+	pending []qlogEvent
+	file    *os.File
+	buf     *bufio.Writer
+	closed  bool
+}
+
+// newQLogWriter returns a writer for the directory returned by
+// config.QLogDirFunc, or nil if qlog is disabled.
+func newQLogWriter(config *Config) *qlogWriter {
+	dir := config.qlogDir()
+	if dir == "" {
+		return nil
+	}
+	return &qlogWriter{dir: dir, ref: time.Now()}
+}
+
+// start creates the trace file and records the start of the session.
+// MOQ Lite negotiates the protocol with ALPN instead of SETUP messages, so the
+// session_started event carries the negotiated version in their place.
+func (q *qlogWriter) start(vantagePoint string, state ConnectionState) error {
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file != nil || q.closed {
+		return nil
+	}
+
+	var id [4]byte
+	_, _ = rand.Read(id[:])
+	name := fmt.Sprintf("%s_%s_%s.moqt.sqlog", q.ref.UTC().Format("20060102T150405.000"), vantagePoint, hex.EncodeToString(id[:]))
+
+	file, err := os.Create(filepath.Join(q.dir, name))
+	if err != nil {
+		return err
+	}
+	q.file = file
+	q.buf = bufio.NewWriter(file)
+
+	q.writeRecord(qlogHeader{
+		QLogVersion: "0.3",
+		QLogFormat:  "JSON-SEQ",
+		Title:       "gomoqt " + vantagePoint,
+		Trace: qlogTrace{
+			VantagePoint: qlogVantagePoint{Type: vantagePoint},
+			CommonFields: qlogCommonFields{
+				ProtocolType:  []string{"MOQT"},
+				TimeFormat:    "relative",
+				ReferenceTime: float64(q.ref.UnixNano()) / float64(time.Millisecond),
+			},
+		},
+	})
+
+	data := map[string]any{
+		"version": state.Version,
+	}
+	if state.TLS != nil {
+		data["alpn"] = state.TLS.NegotiatedProtocol
+	}
+	q.writeRecord(qlogEvent{Name: "moqt:session_started", Data: data})
+
+	for _, ev := range q.pending {
+		q.writeRecord(ev)
+	}
+	q.pending = nil
+	return nil
+}
+
+// event records an event with the given name and data.
+func (q *qlogWriter) event(name string, data map[string]any) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+	if q.buf == nil {
+		q.pending = append(q.pending, q.newEvent(name, data))
+		return
+	}
+	q.writeRecord(q.newEvent(name, data))
+}
+
+// close flushes and closes the trace file.
+func (q *qlogWriter) close() error {
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.pending = nil
+
+	if q.file == nil {
+		return nil
+	}
+	q.writeRecord(q.newEvent("moqt:session_closed", nil))
+	if err := q.buf.Flush(); err != nil {
+		_ = q.file.Close()
+		return err
+	}
+	return q.file.Close()
+}
+
+// newEvent returns an event that occurred now.
+func (q *qlogWriter) newEvent(name string, data map[string]any) qlogEvent {
+	return qlogEvent{
+		Time: float64(time.Since(q.ref).Nanoseconds()) / float64(time.Millisecond),
+		Name: name,
+		Data: data,
+	}
+}
+
+// writeRecord writes v as a JSON-SEQ record. The caller must hold q.mu.
+// Write errors are sticky in the bufio.Writer and reported by close.
+func (q *qlogWriter) writeRecord(v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	_ = q.buf.WriteByte(qlogRecordSeparator)
+	_, _ = q.buf.Write(b)
+	_ = q.buf.WriteByte('\n')
+}
+
+// Control message events.
+
+// controlMessage records a control message sent (send is true) or received
+// on a bidirectional stream.
+func (q *qlogWriter) controlMessage(send bool, messageType string, data map[string]any) {
+	if q == nil {
+		return
+	}
+	name := "moqt:control_message_parsed"
+	if send {
+		name = "moqt:control_message_created"
+	}
+	data["message_type"] = messageType
+	q.event(name, data)
+}
+
+// subscribe records a SUBSCRIBE message.
+func (q *qlogWriter) subscribe(send bool, msg message.SubscribeMessage) {
+	if q == nil {
+		return
+	}
+	q.controlMessage(send, "subscribe", map[string]any{
+		"subscribe_id":        msg.SubscribeID,
+		"broadcast_path":      msg.BroadcastPath,
+		"track_name":          msg.TrackName,
+		"subscriber_priority": msg.SubscriberPriority,
+		"subscriber_ordered":  msg.SubscriberOrdered,
+		"start":               msg.Start,
+		"start_time":          msg.StartTime,
+		"start_behind":        msg.StartBehind,
+		"start_group":         msg.StartGroup,
+		"end_group":           msg.EndGroup,
+	})
+}
+
+// announceInterest records an ANNOUNCE_INTEREST message.
+func (q *qlogWriter) announceInterest(send bool, msg message.AnnounceInterestMessage) {
+	if q == nil {
+		return
+	}
+	q.controlMessage(send, "announce_interest", map[string]any{
+		"broadcast_path_prefix": msg.BroadcastPathPrefix,
+		"exclude_hop":           msg.ExcludeHop,
+	})
+}
+
+// announce records an ANNOUNCE message.
+func (q *qlogWriter) announce(send bool, msg message.AnnounceMessage) {
+	if q == nil {
+		return
+	}
+	status := "ended"
+	if msg.AnnounceStatus == message.ACTIVE {
+		status = "active"
+	}
+	q.controlMessage(send, "announce", map[string]any{
+		"announce_status":       status,
+		"broadcast_path_suffix": msg.BroadcastPathSuffix,
+		"hop_count":             len(msg.HopIDs),
+	})
+}
+
+// Data events.
+
+// groupOpened records the header of a group stream that was sent (send is
+// true) or received.
+func (q *qlogWriter) groupOpened(send bool, id SubscribeID, seq GroupSequence) {
+	if q == nil {
+		return
+	}
+	name := "moqt:group_parsed"
+	if send {
+		name = "moqt:group_created"
+	}
+	q.event(name, map[string]any{
+		"subscribe_id":   id,
+		"group_sequence": seq,
+	})
+}
+
+// groupClosed records the end of a sent (send is true) or received group
+// stream. errorCode is set if the stream was reset instead of finished.
+func (q *qlogWriter) groupClosed(send bool, id SubscribeID, seq GroupSequence, errorCode *uint64) {
+	if q == nil {
+		return
+	}
+	data := map[string]any{
+		"subscribe_id":   id,
+		"group_sequence": seq,
+		"direction":      qlogDirection(send),
+	}
+	if errorCode != nil {
+		data["error_code"] = *errorCode
+	}
+	q.event("moqt:group_closed", data)
+}
+
+// object records a frame written (send is true) or read. objectID is the
+// index of the frame in its group.
+func (q *qlogWriter) object(send bool, id SubscribeID, seq GroupSequence, objectID uint64, length int) {
+	if q == nil {
+		return
+	}
+	name := "moqt:object_parsed"
+	if send {
+		name = "moqt:object_created"
+	}
+	q.event(name, map[string]any{
+		"subscribe_id":   id,
+		"group_sequence": seq,
+		"object_id":      objectID,
+		"length":         length,
+	})
+}
+
+func qlogDirection(send bool) string {
+	if send {
+		return "send"
+	}
+	return "receive"
+}
+
+type qlogHeader struct {
+	QLogVersion string    `json:"qlog_version"`
+	QLogFormat  string    `json:"qlog_format"`
+	Title       string    `json:"title"`
+	Trace       qlogTrace `json:"trace"`
+}
+
+type qlogTrace struct {
+	VantagePoint qlogVantagePoint `json:"vantage_point"`
+	CommonFields qlogCommonFields `json:"common_fields"`
+}
+
+type qlogVantagePoint struct {
+	Type string `json:"type"`
+}
+
+type qlogCommonFields struct {
+	ProtocolType  []string `json:"protocol_type"`
+	TimeFormat    string   `json:"time_format"`
+	ReferenceTime float64  `json:"reference_time"`
+}
+
+type qlogEvent struct {
+	Time float64        `json:"time"`
+	Name string         `json:"name"`
+	Data map[string]any `json:"data,omitempty"`
+}
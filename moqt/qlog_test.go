@@ -0,0 +1,189 @@
+package moqt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readQLog returns the records of the single qlog file in dir.
+func readQLog(t *testing.T, dir string) []map[string]any {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.moqt.sqlog"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		require.NotEmpty(t, line)
+		require.Equal(t, byte(qlogRecordSeparator), line[0], "record must start with RS")
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(line[1:], &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func qlogEventNames(records []map[string]any) []string {
+	var names []string
+	for _, record := range records[1:] {
+		names = append(names, record["name"].(string))
+	}
+	return names
+}
+
+func TestNewQLogWriter(t *testing.T) {
+	tests := map[string]struct {
+		config  *Config
+		wantNil bool
+	}{
+		"nil config": {
+			config:  nil,
+			wantNil: true,
+		},
+		"no dir func": {
+			config:  &Config{},
+			wantNil: true,
+		},
+		"empty dir": {
+			config:  &Config{QLogDirFunc: func() string { return "" }},
+			wantNil: true,
+		},
+		"dir": {
+			config:  &Config{QLogDirFunc: func() string { return t.TempDir() }},
+			wantNil: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.wantNil, newQLogWriter(tt.config) == nil)
+		})
+	}
+}
+
+func TestQLogWriter_Nil(t *testing.T) {
+	var q *qlogWriter
+
+	assert.NotPanics(t, func() {
+		assert.NoError(t, q.start(qlogClient, ConnectionState{}))
+		q.subscribe(true, message.SubscribeMessage{})
+		q.groupOpened(true, 1, 1)
+		q.object(true, 1, 1, 0, 10)
+		q.groupClosed(true, 1, 1, nil)
+		assert.NoError(t, q.close())
+	})
+}
+
+func TestQLogWriter_Trace(t *testing.T) {
+	dir := t.TempDir()
+	q := newQLogWriter(&Config{QLogDirFunc: func() string { return dir }})
+	require.NotNil(t, q)
+
+	// Events before start are kept until the file is created.
+	q.announceInterest(false, message.AnnounceInterestMessage{BroadcastPathPrefix: "/live/"})
+
+	require.NoError(t, q.start(qlogServer, ConnectionState{Version: VersionLite04}))
+	q.announce(true, message.AnnounceMessage{AnnounceStatus: message.ACTIVE, BroadcastPathSuffix: "cam"})
+	q.subscribe(false, message.SubscribeMessage{SubscribeID: 7, BroadcastPath: "/live/cam", TrackName: "video"})
+	q.groupOpened(true, 7, 1)
+	q.object(true, 7, 1, 0, 42)
+	code := uint64(ExpiredGroupErrorCode)
+	q.groupClosed(true, 7, 1, &code)
+	require.NoError(t, q.close())
+
+	// Events after close are discarded.
+	q.groupOpened(true, 7, 2)
+	require.NoError(t, q.close())
+
+	records := readQLog(t, dir)
+
+	header := records[0]
+	assert.Equal(t, "0.3", header["qlog_version"])
+	assert.Equal(t, "JSON-SEQ", header["qlog_format"])
+	trace := header["trace"].(map[string]any)
+	assert.Equal(t, qlogServer, trace["vantage_point"].(map[string]any)["type"])
+	assert.Equal(t, []any{"MOQT"}, trace["common_fields"].(map[string]any)["protocol_type"])
+
+	assert.Equal(t, []string{
+		"moqt:session_started",
+		"moqt:control_message_parsed",
+		"moqt:control_message_created",
+		"moqt:control_message_parsed",
+		"moqt:group_created",
+		"moqt:object_created",
+		"moqt:group_closed",
+		"moqt:session_closed",
+	}, qlogEventNames(records))
+
+	assert.Equal(t, VersionLite04, records[1]["data"].(map[string]any)["version"])
+	assert.Equal(t, "announce_interest", records[2]["data"].(map[string]any)["message_type"])
+	assert.Equal(t, "active", records[3]["data"].(map[string]any)["announce_status"])
+	assert.Equal(t, "video", records[4]["data"].(map[string]any)["track_name"])
+	assert.Equal(t, float64(42), records[6]["data"].(map[string]any)["length"])
+	closed := records[7]["data"].(map[string]any)
+	assert.Equal(t, "send", closed["direction"])
+	assert.Equal(t, float64(ExpiredGroupErrorCode), closed["error_code"])
+}
+
+func TestQLogWriter_StartError(t *testing.T) {
+	q := newQLogWriter(&Config{QLogDirFunc: func() string {
+		return filepath.Join(t.TempDir(), "missing")
+	}})
+
+	assert.Error(t, q.start(qlogClient, ConnectionState{}))
+	assert.NoError(t, q.close())
+}
+
+func TestTrackWriter_QLog(t *testing.T) {
+	dir := t.TempDir()
+	q := newQLogWriter(&Config{QLogDirFunc: func() string { return dir }})
+	require.NoError(t, q.start(qlogServer, ConnectionState{}))
+
+	substr := newReceiveSubscribeStream(SubscribeID(3), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	writer.qlog = q
+
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("payload"))
+	require.NoError(t, group.WriteFrame(frame))
+	require.NoError(t, group.WriteFrame(frame))
+	require.NoError(t, group.Close())
+	require.NoError(t, writer.Close())
+	require.NoError(t, q.close())
+
+	records := readQLog(t, dir)
+	assert.Equal(t, []string{
+		"moqt:session_started",
+		"moqt:group_created",
+		"moqt:object_created",
+		"moqt:object_created",
+		"moqt:group_closed",
+		"moqt:session_closed",
+	}, qlogEventNames(records))
+
+	second := records[4]["data"].(map[string]any)
+	assert.Equal(t, float64(3), second["subscribe_id"])
+	assert.Equal(t, float64(1), second["object_id"])
+	assert.Equal(t, float64(len("payload")), second["length"])
+}
@@ -7,17 +7,19 @@ import (
 	"github.com/qumo-dev/gomoqt/transport"
 )
 
-func newReceiveSubscribeStream(id SubscribeID, stream transport.Stream, config *SubscribeConfig) *receiveSubscribeStream {
+// newReceiveSubscribeStream returns the receiving end of the subscription
+// id on stream, whose updates are read by a task of tasks; see goTask.
+func newReceiveSubscribeStream(id SubscribeID, stream transport.Stream, config *SubscribeConfig, version message.Version, tasks *taskGroup) *receiveSubscribeStream {
 	substr := &receiveSubscribeStream{
 		subscribeID: id,
 		config:      config,
 		stream:      stream,
 		updatedCh:   make(chan struct{}, 1),
+		version:     version,
 	}
 
-	// Listen for updates in a separate goroutine
-	go func() {
-		var updateMsg message.SubscribeUpdateMessage
+	goTask(tasks, "subscribe update reader", func() {
+		updateMsg := message.SubscribeUpdateMessage{Version: substr.version}
 		var err error
 
 		for {
@@ -32,18 +34,35 @@ func newReceiveSubscribeStream(id SubscribeID, stream transport.Stream, config *
 				MaxLatency: updateMsg.SubscriberMaxLatency,
 				StartGroup: groupSequenceFromWire(updateMsg.StartGroup),
 				EndGroup:   groupSequenceFromWire(updateMsg.EndGroup),
+				Visibility: Visibility(updateMsg.Visibility),
 			}
 
 			substr.mu.Lock()
 
+			if substr.config != nil {
+				config.Start = substr.config.Start
+				config.StartTime = substr.config.StartTime
+				config.StartBehind = substr.config.StartBehind
+				config.Parameters = substr.config.Parameters
+			}
 			substr.config = config
 			select {
 			case substr.updatedCh <- struct{}{}:
 			default:
 			}
+			funcs := make([]func(*SubscribeConfig), 0, len(substr.updateFuncs))
+			for _, f := range substr.updateFuncs {
+				funcs = append(funcs, f)
+			}
 			substr.mu.Unlock()
+
+			for _, f := range funcs {
+				f(config)
+			}
 		}
-	}()
+	}, func() {
+		cancelStreamWithError(substr.stream, transport.StreamErrorCode(SubscribeErrorCodeInternal))
+	})
 
 	return substr
 }
@@ -53,17 +72,62 @@ type receiveSubscribeStream struct {
 
 	stream transport.Stream
 
+	// version is the encoding of the SUBSCRIBE_UPDATE messages received
+	// and of the GROUP messages of the subscription.
+	version message.Version
+
 	mu sync.Mutex
 
 	config          *SubscribeConfig
 	updatedCh       chan struct{}
 	responseStarted bool
+
+	// updateFuncs are the callbacks registered with onUpdate.
+	updateFuncs    map[uint64]func(*SubscribeConfig)
+	nextUpdateFunc uint64
+
+	// info is the PublishInfo of the last SUBSCRIBE_OK sent.
+	info PublishInfo
+
+	// onResponseFunc, if set, is called when SUBSCRIBE_OK is sent, or when
+	// the stream is closed before, with the error that closed it.
+	// It must tolerate repeated calls.
+	onResponseFunc func(err error)
+}
+
+// endResponse reports the end of the SUBSCRIBE handshake to onResponseFunc.
+func (substr *receiveSubscribeStream) endResponse(err error) {
+	if substr.onResponseFunc != nil {
+		substr.onResponseFunc(err)
+	}
 }
 
 func (substr *receiveSubscribeStream) SubscribeID() SubscribeID {
 	return substr.subscribeID
 }
 
+// priority returns the priority of the subscription: the higher of the
+// subscriber and the publisher priority.
+func (substr *receiveSubscribeStream) priority() TrackPriority {
+	substr.mu.Lock()
+	defer substr.mu.Unlock()
+
+	priority := substr.info.Priority
+	if substr.config != nil {
+		priority = max(priority, substr.config.Priority)
+	}
+	return priority
+}
+
+// ordered reports whether the subscriber or the publisher asked for groups
+// in ascending order.
+func (substr *receiveSubscribeStream) ordered() bool {
+	substr.mu.Lock()
+	defer substr.mu.Unlock()
+
+	return substr.info.Ordered || (substr.config != nil && substr.config.Ordered)
+}
+
 func (substr *receiveSubscribeStream) ensureInfo(info PublishInfo) error {
 	substr.mu.Lock()
 	if substr.responseStarted {
@@ -110,6 +174,8 @@ func (substr *receiveSubscribeStream) writeInfoLocked(info PublishInfo) error {
 	}
 
 	substr.responseStarted = true
+	substr.info = info
+	substr.endResponse(nil)
 
 	return nil
 }
@@ -154,9 +220,40 @@ func (substr *receiveSubscribeStream) TrackConfig() *SubscribeConfig {
 }
 
 func (substr *receiveSubscribeStream) Updated() <-chan struct{} {
+	substr.mu.Lock()
+	defer substr.mu.Unlock()
+
+	if substr.updatedCh == nil {
+		// The stream has been closed; never hand out a nil channel that
+		// would block the caller forever.
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+
 	return substr.updatedCh
 }
 
+// onUpdate registers f to be called with the new configuration of every
+// SUBSCRIBE_UPDATE received, until stop is called.
+func (substr *receiveSubscribeStream) onUpdate(f func(*SubscribeConfig)) (stop func()) {
+	substr.mu.Lock()
+	defer substr.mu.Unlock()
+
+	if substr.updateFuncs == nil {
+		substr.updateFuncs = make(map[uint64]func(*SubscribeConfig))
+	}
+	id := substr.nextUpdateFunc
+	substr.nextUpdateFunc++
+	substr.updateFuncs[id] = f
+
+	return func() {
+		substr.mu.Lock()
+		defer substr.mu.Unlock()
+		delete(substr.updateFuncs, id)
+	}
+}
+
 func (substr *receiveSubscribeStream) close() error {
 	substr.mu.Lock()
 	defer substr.mu.Unlock()
@@ -166,6 +263,8 @@ func (substr *receiveSubscribeStream) close() error {
 		close(updateCh)
 	}
 
+	substr.endResponse(nil)
+
 	return substr.stream.Close()
 }
 
@@ -175,6 +274,7 @@ func (substr *receiveSubscribeStream) closeWithError(code SubscribeErrorCode) er
 
 	strErrCode := transport.StreamErrorCode(code)
 	cancelStreamWithError(substr.stream, strErrCode)
+	substr.endResponse(&SubscribeError{StreamError: &transport.StreamError{ErrorCode: strErrCode}})
 
 	if updateCh := substr.updatedCh; updateCh != nil {
 		substr.updatedCh = nil
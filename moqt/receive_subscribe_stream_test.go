@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"sync"
 	"testing"
 	"time"
@@ -46,7 +47,7 @@ func TestNewReceiveSubscribeStream(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			mockStream := &FakeQUICStream{}
 
-			rss := newReceiveSubscribeStream(tt.subscribeID, mockStream, tt.config)
+			rss := newReceiveSubscribeStream(tt.subscribeID, mockStream, tt.config, message.VersionLite04Ext, nil)
 
 			assert.NotNil(t, rss, "newReceiveSubscribeStream should not return nil")
 			assert.Equal(t, tt.subscribeID, rss.SubscribeID(), "SubscribeID should match")
@@ -85,7 +86,7 @@ func TestReceiveSubscribeStream_SubscribeID(t *testing.T) {
 				Priority: TrackPriority(1),
 			}
 
-			rss := newReceiveSubscribeStream(tt.subscribeID, mockStream, config)
+			rss := newReceiveSubscribeStream(tt.subscribeID, mockStream, config, message.VersionLite04Ext, nil)
 
 			result := rss.SubscribeID()
 			assert.Equal(t, tt.subscribeID, result, "SubscribeID should match expected value")
@@ -119,7 +120,7 @@ func TestReceiveSubscribeStream_TrackConfig(t *testing.T) {
 			subscribeID := SubscribeID(123)
 			mockStream := &FakeQUICStream{}
 
-			rss := newReceiveSubscribeStream(subscribeID, mockStream, tt.config)
+			rss := newReceiveSubscribeStream(subscribeID, mockStream, tt.config, message.VersionLite04Ext, nil)
 
 			resultConfig := rss.TrackConfig()
 
@@ -141,7 +142,7 @@ func TestReceiveSubscribeStream_Updated(t *testing.T) {
 		Priority: TrackPriority(1),
 	}
 
-	rss := newReceiveSubscribeStream(subscribeID, mockStream, config)
+	rss := newReceiveSubscribeStream(subscribeID, mockStream, config, message.VersionLite04Ext, nil)
 
 	updatedCh := rss.Updated()
 	assert.NotNil(t, updatedCh, "Updated channel should not be nil")
@@ -160,6 +161,8 @@ func TestReceiveSubscribeStream_ListenUpdates_WithSubscribeUpdateMessage(t *test
 	// Create a valid SubscribeUpdateMessage
 	updateMsg := message.SubscribeUpdateMessage{
 		SubscriberPriority: 5,
+		Visibility:         uint64(VisibilityHidden),
+		Version:            message.VersionLite04Ext,
 	}
 
 	// Encode the message
@@ -173,9 +176,10 @@ func TestReceiveSubscribeStream_ListenUpdates_WithSubscribeUpdateMessage(t *test
 
 	config := &SubscribeConfig{
 		Priority: TrackPriority(1),
+		Start:    SubscribeStartLatestGroup,
 	}
 
-	rss := newReceiveSubscribeStream(subscribeID, mockStream, config)
+	rss := newReceiveSubscribeStream(subscribeID, mockStream, config, message.VersionLite04Ext, nil)
 
 	// Wait for the update to be processed
 	select {
@@ -188,6 +192,8 @@ func TestReceiveSubscribeStream_ListenUpdates_WithSubscribeUpdateMessage(t *test
 	updatedConfig := rss.TrackConfig()
 	if err == nil {
 		assert.Equal(t, TrackPriority(5), updatedConfig.Priority, "TrackPriority should be updated")
+		assert.Equal(t, VisibilityHidden, updatedConfig.Visibility)
+		assert.Equal(t, SubscribeStartLatestGroup, updatedConfig.Start, "Start should be kept")
 	}
 
 	// Give some time for the goroutine to complete
@@ -227,7 +233,7 @@ func TestReceiveSubscribeStream_CloseWithError(t *testing.T) {
 				Priority: TrackPriority(1),
 			}
 
-			rss := newReceiveSubscribeStream(subscribeID, mockStream, config)
+			rss := newReceiveSubscribeStream(subscribeID, mockStream, config, message.VersionLite04Ext, nil)
 			updatedCh := rss.Updated()
 
 			err := rss.closeWithError(tt.errorCode)
@@ -255,7 +261,7 @@ func TestReceiveSubscribeStream_CloseWithError_MultipleClose(t *testing.T) {
 		Priority: TrackPriority(1),
 	}
 	// Create stream manually
-	rss := newReceiveSubscribeStream(123, mockStream, config)
+	rss := newReceiveSubscribeStream(123, mockStream, config, message.VersionLite04Ext, nil)
 	updatedCh := rss.Updated()
 
 	rss.closeWithError(SubscribeErrorCodeInternal)
@@ -277,7 +283,7 @@ func TestReceiveSubscribeStream_ConcurrentAccess(t *testing.T) {
 		Priority: TrackPriority(1),
 	}
 
-	rss := newReceiveSubscribeStream(subscribeID, mockStream, config)
+	rss := newReceiveSubscribeStream(subscribeID, mockStream, config, message.VersionLite04Ext, nil)
 
 	// Test concurrent access to SubscribeID (should be safe as it's read-only)
 	var wg sync.WaitGroup
@@ -330,7 +336,7 @@ func TestReceiveSubscribeStream_Close_DoesNotCancelReadOnGracefulClose(t *testin
 	// Create a mock stream that returns EOF on Read and a background context.
 	mockStream := &FakeQUICStream{}
 
-	rss := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	rss := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	// Perform a graceful close; it should not call CancelRead
 	err := rss.close()
@@ -349,7 +355,7 @@ func TestReceiveSubscribeStream_UpdateChannelBehavior(t *testing.T) {
 		mockStream := &FakeQUICStream{}
 		config := &SubscribeConfig{Priority: TrackPriority(1)}
 
-		rss := newReceiveSubscribeStream(subscribeID, mockStream, config)
+		rss := newReceiveSubscribeStream(subscribeID, mockStream, config, message.VersionLite04Ext, nil)
 
 		// Wait for the goroutine to handle EOF and close the channel
 		time.Sleep(50 * time.Millisecond)
@@ -395,7 +401,7 @@ func TestReceiveSubscribeStream_UpdateChannelBehavior(t *testing.T) {
 		}
 
 		config := &SubscribeConfig{Priority: TrackPriority(0)}
-		rss := newReceiveSubscribeStream(subscribeID, mockStream, config) // Should receive multiple update notifications
+		rss := newReceiveSubscribeStream(subscribeID, mockStream, config, message.VersionLite04Ext, nil) // Should receive multiple update notifications
 		updateCount := 0
 		expectedUpdates := 1 // We expect at least 1 update, but may get more
 
@@ -422,3 +428,38 @@ func TestReceiveSubscribeStream_UpdateChannelBehavior(t *testing.T) {
 		time.Sleep(10 * time.Millisecond)
 	})
 }
+
+func TestReceiveSubscribeStream_OnUpdate(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	rss := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{ReadFunc: pr.Read}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+
+	updates := make(chan *SubscribeConfig, 2)
+	stop := rss.onUpdate(func(config *SubscribeConfig) {
+		updates <- config
+	})
+
+	require.NoError(t, message.SubscribeUpdateMessage{
+		SubscriberPriority: 4,
+		StartGroup:         groupSequenceToWire(10),
+		EndGroup:           groupSequenceToWire(20),
+	}.Encode(pw))
+
+	select {
+	case config := <-updates:
+		assert.Equal(t, TrackPriority(4), config.Priority)
+		assert.Equal(t, GroupSequence(10), config.StartGroup)
+		assert.Equal(t, GroupSequence(20), config.EndGroup)
+		assert.Equal(t, config, rss.TrackConfig())
+	case <-time.After(time.Second):
+		t.Fatal("update callback was not called")
+	}
+
+	// Stopped callbacks are not called.
+	stop()
+	require.NoError(t, message.SubscribeUpdateMessage{SubscriberPriority: 5}.Encode(pw))
+	require.Eventually(t, func() bool {
+		return rss.TrackConfig().Priority == 5
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, updates)
+}
@@ -0,0 +1,423 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// errGroupAborted is returned by group.frame when the upstream group was
+// canceled before it completed.
+var errGroupAborted = errors.New("relay: upstream group aborted")
+
+// errCacheClosed is returned by trackCache.next when the upstream
+// subscription has ended and every cached group has been returned.
+var errCacheClosed = errors.New("relay: upstream track ended")
+
+// group is a cached group. Frames are appended while the group is received
+// from upstream and can be read concurrently by any number of subscribers.
+// Stored frames are never modified.
+type group struct {
+	seq      moqt.GroupSequence
+	subgroup moqt.SubgroupID
+	index    uint64
+	received time.Time
+	// timestamp is the time declared for the group by the publisher, if any.
+	timestamp time.Time
+	// ext is set if the frames of the group carry extension headers.
+	ext bool
+	// seeded is set for groups taken over from a previous upstream
+	// subscription of the track.
+	seeded bool
+
+	mu      sync.Mutex
+	frames  []*moqt.Frame
+	done    bool
+	aborted bool
+	// notify is closed and replaced whenever frames or done change.
+	notify chan struct{}
+}
+
+func newGroup(seq moqt.GroupSequence, received time.Time) *group {
+	return &group{
+		seq:      seq,
+		received: received,
+		notify:   make(chan struct{}),
+	}
+}
+
+// append adds a copy of frame to the group.
+func (g *group) append(frame *moqt.Frame) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return
+	}
+	g.frames = append(g.frames, frame.Clone())
+	close(g.notify)
+	g.notify = make(chan struct{})
+}
+
+// finish marks the group complete, or aborted if the upstream group failed.
+func (g *group) finish(aborted bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return
+	}
+	g.done = true
+	g.aborted = aborted
+	close(g.notify)
+}
+
+// frame returns the i-th frame of the group, waiting until it is received.
+// It returns io.EOF after the last frame of a complete group and
+// errGroupAborted if the group was aborted upstream.
+func (g *group) frame(ctx context.Context, i int) (*moqt.Frame, error) {
+	for {
+		g.mu.Lock()
+		if i < len(g.frames) {
+			frame := g.frames[i]
+			g.mu.Unlock()
+			return frame, nil
+		}
+		done, aborted, notify := g.done, g.aborted, g.notify
+		g.mu.Unlock()
+
+		if aborted {
+			return nil, errGroupAborted
+		}
+		if done {
+			return nil, io.EOF
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// trackCache holds the most recent groups of one track in arrival order.
+type trackCache struct {
+	size int
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	groups    []*group
+	nextIndex uint64
+	closed    bool
+	// notify is closed and replaced whenever a group is added or the cache
+	// is closed.
+	notify chan struct{}
+}
+
+func newTrackCache(size int, ttl time.Duration) *trackCache {
+	return &trackCache{
+		size:   size,
+		ttl:    ttl,
+		notify: make(chan struct{}),
+	}
+}
+
+// add starts caching a new group and evicts groups beyond the size limit.
+// A group that was already taken over from a previous upstream subscription
+// is not cached again; the returned group is then discarded.
+func (c *trackCache) add(seq moqt.GroupSequence, now time.Time) *group {
+	return c.addSubgroup(seq, 0, now)
+}
+
+// addSubgroup is like add for a subgroup of group seq. Each subgroup is
+// cached as a group of its own.
+func (c *trackCache) addSubgroup(seq moqt.GroupSequence, subgroup moqt.SubgroupID, now time.Time) *group {
+	return c.addTimedSubgroup(seq, subgroup, time.Time{}, now)
+}
+
+// addTimedSubgroup is like addSubgroup for a group declared with timestamp
+// by the publisher.
+func (c *trackCache) addTimedSubgroup(seq moqt.GroupSequence, subgroup moqt.SubgroupID, timestamp, now time.Time) *group {
+	g := newGroup(seq, now)
+	g.subgroup = subgroup
+	g.timestamp = timestamp
+	return c.insert(g)
+}
+
+// addReceived adds the group or subgroup received as gr at now, with the
+// time and extension headers setting of its header.
+func (c *trackCache) addReceived(gr *moqt.GroupReader, now time.Time) *group {
+	g := newGroup(gr.GroupSequence(), now)
+	g.subgroup = gr.SubgroupID()
+	g.timestamp = gr.Timestamp()
+	g.ext = gr.HasExtensionHeaders()
+	return c.insert(g)
+}
+
+// insert adds g as the newest group.
+func (c *trackCache) insert(g *group) *group {
+	seq, subgroup := g.seq, g.subgroup
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextIndex++
+	g.index = c.nextIndex
+	if c.closed {
+		return g
+	}
+	for _, cached := range c.groups {
+		if cached.seeded && cached.seq == seq && cached.subgroup == subgroup {
+			return g
+		}
+	}
+
+	c.groups = append(c.groups, g)
+	if len(c.groups) > c.size {
+		c.groups = append(c.groups[:0:0], c.groups[len(c.groups)-c.size:]...)
+	}
+	close(c.notify)
+	c.notify = make(chan struct{})
+	return g
+}
+
+// seed adds the complete, unexpired groups of the cache of a previous
+// upstream subscription of the same track, which must no longer receive
+// groups.
+func (c *trackCache) seed(prev *trackCache) {
+	prev.mu.Lock()
+	groups := prev.groups
+	prev.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiry := time.Now().Add(-c.ttl)
+	for _, g := range groups {
+		g.mu.Lock()
+		complete := g.done && !g.aborted
+		frames := g.frames
+		g.mu.Unlock()
+		if !complete || !g.received.After(expiry) {
+			continue
+		}
+
+		c.nextIndex++
+		c.groups = append(c.groups, &group{
+			seq:       g.seq,
+			subgroup:  g.subgroup,
+			index:     c.nextIndex,
+			received:  g.received,
+			timestamp: g.timestamp,
+			seeded:    true,
+			frames:    frames,
+			done:      true,
+			notify:    make(chan struct{}),
+		})
+	}
+	if len(c.groups) > c.size {
+		c.groups = append(c.groups[:0:0], c.groups[len(c.groups)-c.size:]...)
+	}
+}
+
+// resize sets the number of groups kept by the cache and evicts the oldest
+// groups beyond it.
+func (c *trackCache) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	if len(c.groups) > c.size {
+		c.groups = append(c.groups[:0:0], c.groups[len(c.groups)-c.size:]...)
+	}
+}
+
+// live reports whether the cache holds a group younger than the TTL.
+func (c *trackCache) live(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiry := now.Add(-c.ttl)
+	for _, g := range c.groups {
+		if g.received.After(expiry) {
+			return true
+		}
+	}
+	return false
+}
+
+// close marks the end of the upstream subscription. Cached groups remain
+// available to subscribers that have not read them yet.
+func (c *trackCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.notify)
+}
+
+// next returns the oldest cached group that arrived after the group with
+// index after and is younger than the TTL, waiting for one if necessary.
+// A new subscriber passes 0 to start with the cached groups.
+func (c *trackCache) next(ctx context.Context, after uint64) (*group, error) {
+	for {
+		c.mu.Lock()
+		expiry := time.Now().Add(-c.ttl)
+		for _, g := range c.groups {
+			if g.index > after && g.received.After(expiry) {
+				c.mu.Unlock()
+				return g, nil
+			}
+		}
+		closed, notify := c.closed, c.notify
+		c.mu.Unlock()
+
+		if closed {
+			return nil, errCacheClosed
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// latest returns the index to pass to next for the newest cached group,
+// skipping older ones.
+func (c *trackCache) latest() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.groups) == 0 {
+		return c.nextIndex
+	}
+	return c.groups[len(c.groups)-1].index - 1
+}
+
+// start returns the index to pass to next for a subscription that starts
+// at s: before the first subgroup of the newest complete group, or of the
+// group of the highest sequence, or after the cached groups. Without a complete group, a
+// subscription for the latest group starts with the newest group.
+func (c *trackCache) start(s moqt.SubscribeStart) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch s {
+	case moqt.SubscribeStartLatestGroup:
+		expiry := time.Now().Add(-c.ttl)
+		for i := len(c.groups) - 1; i >= 0; i-- {
+			g := c.groups[i]
+			g.mu.Lock()
+			complete := g.done && !g.aborted
+			g.mu.Unlock()
+			if complete && g.received.After(expiry) {
+				return c.firstIndexLocked(g.seq)
+			}
+		}
+		return c.newestIndexLocked()
+	case moqt.SubscribeStartCurrentGroup:
+		return c.newestIndexLocked()
+	case moqt.SubscribeStartNextGroup:
+		return c.nextIndex
+	default:
+		return 0
+	}
+}
+
+// startAt returns the index to pass to next for a subscription that starts
+// at the time t, or behind the newest group if t is zero: before the first
+// subgroup of the latest cached group declared at or before the target
+// time, or of the earliest one if all of them are declared after it. It
+// reports false if no unexpired group of the cache has a declared time.
+func (c *trackCache) startAt(t time.Time, behind time.Duration) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiry := time.Now().Add(-c.ttl)
+	var timed []*group
+	for _, g := range c.groups {
+		if g.subgroup == 0 && !g.timestamp.IsZero() && g.received.After(expiry) {
+			timed = append(timed, g)
+		}
+	}
+	if len(timed) == 0 {
+		return 0, false
+	}
+
+	target := t
+	if target.IsZero() {
+		newest := timed[0].timestamp
+		for _, g := range timed[1:] {
+			if g.timestamp.After(newest) {
+				newest = g.timestamp
+			}
+		}
+		target = newest.Add(-behind)
+	}
+
+	var at, earliest *group
+	for _, g := range timed {
+		if earliest == nil || g.timestamp.Before(earliest.timestamp) {
+			earliest = g
+		}
+		if g.timestamp.After(target) {
+			continue
+		}
+		if at == nil || g.timestamp.After(at.timestamp) ||
+			(g.timestamp.Equal(at.timestamp) && g.seq > at.seq) {
+			at = g
+		}
+	}
+	if at == nil {
+		at = earliest
+	}
+	return c.firstIndexLocked(at.seq), true
+}
+
+// newestIndexLocked returns the index to pass to next for the first
+// subgroup of the cached group of the highest sequence. c.mu must be held.
+func (c *trackCache) newestIndexLocked() uint64 {
+	if len(c.groups) == 0 {
+		return c.nextIndex
+	}
+	newest := c.groups[0].seq
+	for _, g := range c.groups[1:] {
+		newest = max(newest, g.seq)
+	}
+	return c.firstIndexLocked(newest)
+}
+
+// firstIndexLocked returns the index to pass to next for the first cached
+// subgroup of group seq. c.mu must be held.
+func (c *trackCache) firstIndexLocked(seq moqt.GroupSequence) uint64 {
+	for _, g := range c.groups {
+		if g.seq == seq {
+			return g.index - 1
+		}
+	}
+	return c.nextIndex
+}
+
+// find returns the unexpired cached group seq, or nil. Subgroups are not
+// returned.
+func (c *trackCache) find(seq moqt.GroupSequence, now time.Time) *group {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiry := now.Add(-c.ttl)
+	for _, g := range c.groups {
+		if g.seq == seq && g.subgroup == 0 && g.received.After(expiry) {
+			return g
+		}
+	}
+	return nil
+}
+
+// len returns the number of cached groups.
+func (c *trackCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.groups)
+}
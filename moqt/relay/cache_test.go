@@ -0,0 +1,478 @@
+package relay
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFrame(t *testing.T, payload string) *moqt.Frame {
+	t.Helper()
+	frame := moqt.NewFrame(len(payload))
+	_, err := frame.Write([]byte(payload))
+	require.NoError(t, err)
+	return frame
+}
+
+func TestGroup_Frame(t *testing.T) {
+	tests := map[string]struct {
+		aborted bool
+		wantErr error
+	}{
+		"complete": {
+			wantErr: io.EOF,
+		},
+		"aborted": {
+			aborted: true,
+			wantErr: errGroupAborted,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := newGroup(1, time.Now())
+			g.append(newTestFrame(t, "a"))
+
+			frame, err := g.frame(context.Background(), 0)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("a"), frame.Body())
+
+			g.finish(tt.aborted)
+
+			_, err = g.frame(context.Background(), 1)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGroup_Frame_CopiesFrame(t *testing.T) {
+	g := newGroup(1, time.Now())
+	src := newTestFrame(t, "a")
+	g.append(src)
+
+	src.Reset()
+	_, _ = src.Write([]byte("b"))
+
+	frame, err := g.frame(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a"), frame.Body())
+}
+
+func TestGroup_Frame_Waits(t *testing.T) {
+	g := newGroup(1, time.Now())
+
+	done := make(chan *moqt.Frame)
+	go func() {
+		frame, _ := g.frame(context.Background(), 0)
+		done <- frame
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("frame returned before the frame was received")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	g.append(newTestFrame(t, "a"))
+
+	select {
+	case frame := <-done:
+		require.NotNil(t, frame)
+		assert.Equal(t, []byte("a"), frame.Body())
+	case <-time.After(time.Second):
+		t.Fatal("frame did not return after the frame was received")
+	}
+}
+
+func TestGroup_Frame_ContextCanceled(t *testing.T) {
+	g := newGroup(1, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := g.frame(ctx, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGroup_AppendAfterFinish(t *testing.T) {
+	g := newGroup(1, time.Now())
+	g.finish(false)
+	g.append(newTestFrame(t, "a"))
+
+	_, err := g.frame(context.Background(), 0)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestTrackCache_Add_Evicts(t *testing.T) {
+	c := newTrackCache(2, time.Minute)
+	now := time.Now()
+	c.add(1, now)
+	c.add(2, now)
+	c.add(3, now)
+
+	assert.Equal(t, 2, c.len())
+
+	g, err := c.next(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, moqt.GroupSequence(2), g.seq)
+}
+
+func TestTrackCache_AddSubgroup(t *testing.T) {
+	prev := newTrackCache(4, time.Minute)
+	now := time.Now()
+	prev.add(1, now).finish(false)
+	prev.addSubgroup(1, 2, now).finish(false)
+
+	c := newTrackCache(4, time.Minute)
+	c.seed(prev)
+	c.add(1, now)
+	c.addSubgroup(1, 2, now)
+	c.addSubgroup(1, 3, now)
+	assert.Equal(t, 3, c.len(), "only the new subgroup should be cached")
+
+	var subgroups []moqt.SubgroupID
+	var after uint64
+	for range 3 {
+		g, err := c.next(context.Background(), after)
+		require.NoError(t, err)
+		assert.Equal(t, moqt.GroupSequence(1), g.seq)
+		subgroups = append(subgroups, g.subgroup)
+		after = g.index
+	}
+	assert.Equal(t, []moqt.SubgroupID{0, 2, 3}, subgroups)
+}
+
+func TestTrackCache_Resize(t *testing.T) {
+	c := newTrackCache(4, time.Minute)
+	now := time.Now()
+	for seq := range moqt.GroupSequence(4) {
+		c.add(seq+1, now)
+	}
+
+	c.resize(1)
+	assert.Equal(t, 1, c.len())
+	g, err := c.next(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, moqt.GroupSequence(4), g.seq)
+
+	c.resize(4)
+	c.add(5, now)
+	assert.Equal(t, 2, c.len())
+}
+
+func TestTrackCache_Next(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		received []time.Time
+		after    uint64
+		wantSeq  moqt.GroupSequence
+	}{
+		"oldest cached group": {
+			received: []time.Time{now, now},
+			after:    0,
+			wantSeq:  1,
+		},
+		"group after index": {
+			received: []time.Time{now, now},
+			after:    1,
+			wantSeq:  2,
+		},
+		"skips expired groups": {
+			received: []time.Time{now.Add(-time.Hour), now},
+			after:    0,
+			wantSeq:  2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTrackCache(8, time.Minute)
+			for i, received := range tt.received {
+				c.add(moqt.GroupSequence(i+1), received)
+			}
+
+			g, err := c.next(context.Background(), tt.after)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSeq, g.seq)
+		})
+	}
+}
+
+func TestTrackCache_Next_Waits(t *testing.T) {
+	c := newTrackCache(8, time.Minute)
+	first := c.add(1, time.Now())
+
+	done := make(chan *group)
+	go func() {
+		g, _ := c.next(context.Background(), first.index)
+		done <- g
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("next returned before a new group was added")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	c.add(2, time.Now())
+
+	select {
+	case g := <-done:
+		require.NotNil(t, g)
+		assert.Equal(t, moqt.GroupSequence(2), g.seq)
+	case <-time.After(time.Second):
+		t.Fatal("next did not return after a group was added")
+	}
+}
+
+func TestTrackCache_Next_Closed(t *testing.T) {
+	c := newTrackCache(8, time.Minute)
+	c.add(1, time.Now())
+	c.close()
+
+	// Cached groups remain readable after close.
+	g, err := c.next(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, moqt.GroupSequence(1), g.seq)
+
+	_, err = c.next(context.Background(), g.index)
+	assert.ErrorIs(t, err, errCacheClosed)
+
+	// Groups added after close are not cached.
+	c.add(2, time.Now())
+	assert.Equal(t, 1, c.len())
+}
+
+func TestTrackCache_Next_ContextCanceled(t *testing.T) {
+	c := newTrackCache(8, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.next(ctx, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTrackCache_Seed(t *testing.T) {
+	now := time.Now()
+	prev := newTrackCache(8, time.Minute)
+	prev.add(1, now.Add(-time.Hour)).finish(false) // expired
+	complete := prev.add(2, now)
+	complete.append(newTestFrame(t, "two"))
+	complete.finish(false)
+	prev.add(3, now).finish(true) // aborted
+	prev.add(4, now)              // incomplete
+	prev.close()
+
+	c := newTrackCache(8, time.Minute)
+	c.seed(prev)
+	require.Equal(t, 1, c.len())
+	assert.True(t, c.live(now))
+
+	g, err := c.next(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, moqt.GroupSequence(2), g.seq)
+	frame, err := g.frame(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("two"), frame.Body())
+	_, err = g.frame(context.Background(), 1)
+	assert.ErrorIs(t, err, io.EOF)
+
+	// A seeded group received again from the new upstream is not duplicated.
+	c.add(2, now)
+	c.add(5, now)
+	assert.Equal(t, 2, c.len())
+}
+
+func TestTrackCache_Live(t *testing.T) {
+	now := time.Now()
+	c := newTrackCache(8, time.Minute)
+	assert.False(t, c.live(now))
+
+	c.add(1, now.Add(-time.Hour))
+	assert.False(t, c.live(now))
+
+	c.add(2, now)
+	assert.True(t, c.live(now))
+}
+
+func TestTrackCache_Find(t *testing.T) {
+	now := time.Now()
+	c := newTrackCache(4, time.Minute)
+	c.add(1, now.Add(-2*time.Minute))
+	c.add(2, now)
+	c.addSubgroup(3, 1, now)
+
+	tests := map[string]struct {
+		seq   moqt.GroupSequence
+		found bool
+	}{
+		"cached":        {seq: 2, found: true},
+		"expired":       {seq: 1},
+		"only subgroup": {seq: 3},
+		"not cached":    {seq: 4},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := c.find(tt.seq, now)
+			if !tt.found {
+				assert.Nil(t, g)
+				return
+			}
+			require.NotNil(t, g)
+			assert.Equal(t, tt.seq, g.seq)
+		})
+	}
+}
+
+func TestTrackCache_Latest(t *testing.T) {
+	c := newTrackCache(8, time.Minute)
+	assert.Zero(t, c.latest())
+
+	for seq := range moqt.GroupSequence(3) {
+		c.add(seq+1, time.Now())
+	}
+	g, err := c.next(context.Background(), c.latest())
+	require.NoError(t, err)
+	assert.Equal(t, moqt.GroupSequence(3), g.seq)
+}
+
+func TestTrackCache_Start(t *testing.T) {
+	tests := map[string]struct {
+		start moqt.SubscribeStart
+		// complete is the number of cached groups that are complete, from
+		// the oldest.
+		complete int
+		wantSeq  moqt.GroupSequence
+	}{
+		"default starts with the oldest group": {
+			start:    moqt.SubscribeStartDefault,
+			complete: 2,
+			wantSeq:  1,
+		},
+		"latest complete group": {
+			start:    moqt.SubscribeStartLatestGroup,
+			complete: 2,
+			wantSeq:  2,
+		},
+		"latest group without complete group": {
+			start:    moqt.SubscribeStartLatestGroup,
+			complete: 0,
+			wantSeq:  3,
+		},
+		"current group": {
+			start:    moqt.SubscribeStartCurrentGroup,
+			complete: 2,
+			wantSeq:  3,
+		},
+		"next group": {
+			start:    moqt.SubscribeStartNextGroup,
+			complete: 2,
+			wantSeq:  4,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTrackCache(8, time.Minute)
+			now := time.Now()
+			for seq := range moqt.GroupSequence(3) {
+				g := c.add(seq+1, now)
+				if int(seq) < tt.complete {
+					g.finish(false)
+				}
+			}
+			// Group 2 has a second subgroup, which starts with it.
+			c.addSubgroup(2, 1, now)
+
+			after := c.start(tt.start)
+			c.add(4, now)
+
+			g, err := c.next(context.Background(), after)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSeq, g.seq)
+			assert.Zero(t, g.subgroup)
+		})
+	}
+}
+
+func TestTrackCache_StartAt(t *testing.T) {
+	base := time.UnixMicro(1_700_000_000_000_000)
+
+	tests := map[string]struct {
+		time    time.Time
+		behind  time.Duration
+		untimed bool
+		wantSeq moqt.GroupSequence
+		wantOK  bool
+	}{
+		"exact time": {
+			time:    base.Add(2 * time.Second),
+			wantSeq: 3,
+			wantOK:  true,
+		},
+		"between groups": {
+			time:    base.Add(1500 * time.Millisecond),
+			wantSeq: 2,
+			wantOK:  true,
+		},
+		"before the cache": {
+			time:    base.Add(-time.Hour),
+			wantSeq: 1,
+			wantOK:  true,
+		},
+		"after the cache": {
+			time:    base.Add(time.Hour),
+			wantSeq: 4,
+			wantOK:  true,
+		},
+		"behind live": {
+			behind:  2 * time.Second,
+			wantSeq: 2,
+			wantOK:  true,
+		},
+		"behind the cache": {
+			behind:  time.Hour,
+			wantSeq: 1,
+			wantOK:  true,
+		},
+		"untimed groups": {
+			time:    base,
+			untimed: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTrackCache(8, time.Minute)
+			now := time.Now()
+			for seq := range moqt.GroupSequence(4) {
+				var ts time.Time
+				if !tt.untimed {
+					ts = base.Add(time.Duration(seq) * time.Second)
+				}
+				c.addTimedSubgroup(seq+1, 0, ts, now)
+			}
+			// The subgroup of group 3 does not start the subscription.
+			c.addTimedSubgroup(3, 1, base, now)
+
+			after, ok := c.startAt(tt.time, tt.behind)
+			require.Equal(t, tt.wantOK, ok)
+			if !ok {
+				return
+			}
+
+			g, err := c.next(context.Background(), after)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSeq, g.seq)
+			assert.Zero(t, g.subgroup)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Cluster configures a static cluster of relays that forward announcements
+// to each other. See Relay.JoinCluster.
+type Cluster struct {
+	// Node is the URL of this relay. It is skipped in Peers, so that every
+	// relay of the cluster can be configured with the same list.
+	Node string
+
+	// Peers are the URLs of the relays of the cluster, e.g.
+	// "moqt://relay-2.example.com:4433".
+	Peers []string
+
+	// Dialer is used to connect to the peers.
+	// If nil, a zero moqt.Dialer is used.
+	Dialer *moqt.Dialer
+
+	// MaxHops is the number of relays a broadcast may have traversed to be
+	// relayed from a peer. The default of 1 only accepts broadcasts from the
+	// relay their publisher is connected to, which suits a full mesh; raise
+	// it when the peers of the relays do not all know each other.
+	MaxHops int
+
+	// RetryInterval is the time between attempts to connect to a peer that
+	// is unreachable or disconnected. If zero, defaults to 1s.
+	RetryInterval time.Duration
+}
+
+// maxHops returns the configured MaxHops or the default (1).
+func (c *Cluster) maxHops() int {
+	if c.MaxHops > 0 {
+		return c.MaxHops
+	}
+	return 1
+}
+
+// retryInterval returns the configured RetryInterval or the default (1s).
+func (c *Cluster) retryInterval() time.Duration {
+	if c.RetryInterval > 0 {
+		return c.RetryInterval
+	}
+	return time.Second
+}
+
+// JoinCluster connects to the peers of cluster until ctx is canceled, and
+// announces their broadcasts on the relay's mux with a handler that relays
+// them from the peer, so that subscribers can connect to any relay of the
+// cluster. Each relay pulls the broadcasts of its peers over the session it
+// dials, so the peers must serve the relay's mux, as a server whose handler
+// calls Serve does.
+//
+// Announcements are not forwarded back to the relay they came from, nor
+// accepted once they have traversed this relay or more than MaxHops relays.
+// When several peers announce the same path, the broadcast is relayed from
+// the one it reached through the fewest relays; broadcasts of local
+// publishers take precedence. The broadcasts of peers are not put in the
+// directories of the fleets joined with Join.
+//
+// The relay's mux must have a hop ID, such as one from moqt.NewHopID.
+// JoinCluster returns nil when ctx is canceled, and an error if cluster is
+// incomplete.
+func (r *Relay) JoinCluster(ctx context.Context, cluster *Cluster) error {
+	if cluster == nil || cluster.Node == "" {
+		return errors.New("relay: cluster needs a node")
+	}
+	if r.mux.HopID() == 0 {
+		return errors.New("relay: cluster needs a mux with a hop ID")
+	}
+
+	filter := func(ann *moqt.Announcement) bool {
+		return moqt.MaxHops(cluster.maxHops())(ann) &&
+			moqt.ExcludeHops(r.mux.HopID())(ann)
+	}
+
+	var wg sync.WaitGroup
+	peers := slices.Compact(slices.Sorted(slices.Values(cluster.Peers)))
+	for _, peer := range peers {
+		if peer == cluster.Node {
+			continue
+		}
+		wg.Go(func() {
+			r.followPeer(ctx, cluster, peer, filter)
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+// followPeer relays the broadcasts of peer accepted by filter, connecting to
+// it again whenever the session ends, until ctx is canceled.
+func (r *Relay) followPeer(ctx context.Context, cluster *Cluster, peer string, filter moqt.AnnouncementFilter) {
+	dialer := cluster.Dialer
+	if dialer == nil {
+		dialer = &moqt.Dialer{}
+	}
+
+	for {
+		// The peer pulls the broadcasts of this relay over its own session,
+		// so it gets an empty mux. The hop ID lets the peer exclude the
+		// broadcasts it received from this relay.
+		sess, err := dialer.Dial(ctx, peer, moqt.NewTrackMux(r.mux.HopID()))
+		if err == nil {
+			err = r.servePeer(ctx, sess, filter)
+			_ = sess.CloseWithError(moqt.NoError, "")
+		}
+		if err != nil && ctx.Err() == nil {
+			r.logError("failed to relay peer", err, "peer", peer)
+		}
+
+		select {
+		case <-time.After(cluster.retryInterval()):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// servePeer announces the broadcasts of a peer session accepted by filter
+// until ctx is canceled or the announce stream ends.
+func (r *Relay) servePeer(ctx context.Context, sess *moqt.Session, filter moqt.AnnouncementFilter) error {
+	anns, err := sess.AcceptAnnounce("/")
+	if err != nil {
+		return err
+	}
+	defer anns.Close()
+
+	for ann := range anns.Announcements(ctx) {
+		if ann.IsActive() && filter(ann) {
+			r.announcePeer(sess, ann)
+		}
+	}
+	return nil
+}
+
+// announcePeer announces ann of a peer session, unless the mux already has
+// an active announcement of its path through as few relays. In that case,
+// ann is announced once the other one ends, if it is still active.
+func (r *Relay) announcePeer(sess *moqt.Session, ann *moqt.Announcement) {
+	current, _ := r.mux.TrackHandler(ann.BroadcastPath())
+	if current != nil && current != ann && current.IsActive() &&
+		len(current.HopIDs()) <= len(ann.HopIDs()) {
+		current.AfterFunc(func() {
+			if ann.IsActive() {
+				r.announcePeer(sess, ann)
+			}
+		})
+		return
+	}
+	r.mux.Announce(ann, r.handler(sess, ann.BroadcastID()))
+}
@@ -0,0 +1,147 @@
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startClusterRelays starts n relays and returns their muxes and URLs.
+func startClusterRelays(t *testing.T, n int) ([]*moqt.TrackMux, []*Relay, []string) {
+	t.Helper()
+
+	muxes := make([]*moqt.TrackMux, n)
+	relays := make([]*Relay, n)
+	nodes := make([]string, n)
+	for i := range n {
+		muxes[i] = moqt.NewTrackMux(moqt.NewHopID())
+		relays[i] = New(muxes[i], nil)
+		nodes[i] = "moqt://" + startRelayServer(t, muxes[i], relays[i])
+	}
+	return muxes, relays, nodes
+}
+
+func TestRelay_JoinCluster(t *testing.T) {
+	dialer := &moqt.Dialer{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	muxes, relays, nodes := startClusterRelays(t, 3)
+	for i, r := range relays {
+		go func() {
+			_ = r.JoinCluster(t.Context(), &Cluster{Node: nodes[i], Peers: nodes, Dialer: dialer, RetryInterval: 50 * time.Millisecond})
+		}()
+	}
+
+	var upstreamSubscriptions atomic.Int32
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.Publish(t.Context(), "/live/cam", newTestPublisher(&upstreamSubscriptions))
+	moqttest.Dial(t, strings.TrimPrefix(nodes[0], "moqt://"), pubMux)
+
+	// Every relay pulls the broadcast from the relay of the publisher.
+	for _, mux := range muxes[1:] {
+		require.Eventually(t, func() bool {
+			ann, _ := mux.TrackHandler("/live/cam")
+			return ann != nil
+		}, 5*time.Second, 10*time.Millisecond)
+		ann, _ := mux.TrackHandler("/live/cam")
+		assert.Equal(t, []uint64{muxes[0].HopID()}, ann.HopIDs())
+	}
+
+	sub := moqttest.Dial(t, strings.TrimPrefix(nodes[2], "moqt://"), moqt.NewTrackMux(0))
+	tr := subscribeRelay(t, sub, "/live/cam", "video")
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	gr, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	frame := moqt.NewFrame(0)
+	require.NoError(t, gr.ReadFrame(frame))
+	assert.Equal(t, []byte("hello"), frame.Body())
+}
+
+func TestRelay_JoinCluster_MaxHops(t *testing.T) {
+	dialer := &moqt.Dialer{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+
+	tests := map[string]struct {
+		maxHops   int
+		wantRelay bool
+	}{
+		"default":  {maxHops: 0, wantRelay: false},
+		"two hops": {maxHops: 2, wantRelay: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The relays form a line: 0 - 1 - 2.
+			muxes, relays, nodes := startClusterRelays(t, 3)
+			peers := [][]string{{nodes[1]}, {nodes[0], nodes[2]}, {nodes[1]}}
+			for i, r := range relays {
+				cluster := &Cluster{Node: nodes[i], Peers: peers[i], Dialer: dialer, RetryInterval: 50 * time.Millisecond}
+				if i == 2 {
+					cluster.MaxHops = tt.maxHops
+				}
+				go func() {
+					_ = r.JoinCluster(t.Context(), cluster)
+				}()
+			}
+
+			var upstreamSubscriptions atomic.Int32
+			pubMux := moqt.NewTrackMux(0)
+			pubMux.Publish(t.Context(), "/live/cam", newTestPublisher(&upstreamSubscriptions))
+			moqttest.Dial(t, strings.TrimPrefix(nodes[0], "moqt://"), pubMux)
+
+			require.Eventually(t, func() bool {
+				ann, _ := muxes[1].TrackHandler("/live/cam")
+				return ann != nil
+			}, 5*time.Second, 10*time.Millisecond)
+
+			if tt.wantRelay {
+				require.Eventually(t, func() bool {
+					ann, _ := muxes[2].TrackHandler("/live/cam")
+					return ann != nil
+				}, 5*time.Second, 10*time.Millisecond)
+				ann, _ := muxes[2].TrackHandler("/live/cam")
+				assert.Equal(t, []uint64{muxes[0].HopID(), muxes[1].HopID()}, ann.HopIDs())
+			} else {
+				assert.Never(t, func() bool {
+					ann, _ := muxes[2].TrackHandler("/live/cam")
+					return ann != nil
+				}, 200*time.Millisecond, 10*time.Millisecond)
+			}
+
+			// The broadcast is never forwarded back to the publisher's relay.
+			ann, _ := muxes[0].TrackHandler("/live/cam")
+			require.NotNil(t, ann)
+			assert.Empty(t, ann.HopIDs())
+		})
+	}
+}
+
+func TestRelay_JoinCluster_Incomplete(t *testing.T) {
+	tests := map[string]struct {
+		mux     *moqt.TrackMux
+		cluster *Cluster
+	}{
+		"nil cluster": {
+			mux: moqt.NewTrackMux(moqt.NewHopID()),
+		},
+		"missing node": {
+			mux:     moqt.NewTrackMux(moqt.NewHopID()),
+			cluster: &Cluster{Peers: []string{"moqt://relay.example.com"}},
+		},
+		"missing hop ID": {
+			mux:     moqt.NewTrackMux(0),
+			cluster: &Cluster{Node: "moqt://relay.example.com"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, New(tt.mux, nil).JoinCluster(t.Context(), tt.cluster))
+		})
+	}
+}
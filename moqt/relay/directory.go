@@ -0,0 +1,195 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// ErrNotInDirectory is returned by Directory.Lookup for broadcasts that no
+// node of the fleet publishes.
+var ErrNotInDirectory = errors.New("relay: broadcast not in directory")
+
+// DirectoryEntry records which relay node of a fleet serves a broadcast.
+type DirectoryEntry struct {
+	// BroadcastPath is the path of the broadcast.
+	BroadcastPath moqt.BroadcastPath
+
+	// Node is the URL at which other relays reach the node, as used for
+	// Origin.URL.
+	Node string
+
+	// BroadcastID is the global ID of the broadcast, or zero.
+	BroadcastID moqt.BroadcastID
+}
+
+// Directory stores the discovery state shared by the relays of a fleet: the
+// broadcasts each node serves. Entries are leases that expire unless they are
+// put again, so entries of a node that stopped without deleting them
+// disappear by themselves.
+//
+// A Directory kept outside the relay processes, such as the Redis directory
+// of package redisdir, lets stateless relays share discovery state and find
+// it again after a restart. Implementations must be safe for concurrent use.
+//
+// The Directory is the only pluggable store of a Relay. The announcements it
+// accepts with Serve are bound to the sessions they are relayed from, so the
+// relay keeps them in memory and puts them in the directories of the fleets
+// it joined while they are active. After a restart, they are put again as
+// their publishers reconnect.
+type Directory interface {
+	// Put records entry for ttl, replacing the entry of the same broadcast
+	// path.
+	Put(ctx context.Context, entry DirectoryEntry, ttl time.Duration) error
+
+	// Delete removes the entry of path if it is held by node.
+	Delete(ctx context.Context, path moqt.BroadcastPath, node string) error
+
+	// Lookup returns the entry of path, or an error wrapping
+	// ErrNotInDirectory if there is none.
+	Lookup(ctx context.Context, path moqt.BroadcastPath) (DirectoryEntry, error)
+
+	// List returns the entries whose broadcast path starts with prefix.
+	List(ctx context.Context, prefix string) ([]DirectoryEntry, error)
+}
+
+// DirectoryWatcher is implemented by Directories that report changes, so
+// that the relays of a fleet see the broadcasts of the others as soon as they
+// are put or deleted rather than at the next synchronization.
+type DirectoryWatcher interface {
+	// Watch returns a channel that receives a value after entries whose
+	// broadcast path starts with prefix are added, changed or deleted.
+	// Renewals of unchanged entries and expirations need not be reported,
+	// and changes may be coalesced. The channel is closed when ctx is
+	// canceled or watching fails.
+	Watch(ctx context.Context, prefix string) (<-chan struct{}, error)
+}
+
+// MemoryDirectory is a Directory held in memory. It shares discovery state
+// between relays in one process, such as in tests; its state is lost when
+// the process exits.
+//
+// The zero value is an empty directory ready to use.
+type MemoryDirectory struct {
+	mu       sync.Mutex
+	entries  map[moqt.BroadcastPath]memoryEntry
+	watchers map[*memoryWatcher]struct{}
+}
+
+var (
+	_ Directory        = (*MemoryDirectory)(nil)
+	_ DirectoryWatcher = (*MemoryDirectory)(nil)
+)
+
+// memoryWatcher is a Watch of a MemoryDirectory.
+type memoryWatcher struct {
+	prefix string
+	ch     chan struct{}
+}
+
+type memoryEntry struct {
+	DirectoryEntry
+	expires time.Time
+}
+
+// Put implements Directory.
+func (d *MemoryDirectory) Put(ctx context.Context, entry DirectoryEntry, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.entries == nil {
+		d.entries = make(map[moqt.BroadcastPath]memoryEntry)
+	}
+	if old, ok := d.entries[entry.BroadcastPath]; !ok || old.DirectoryEntry != entry || !time.Now().Before(old.expires) {
+		d.notifyLocked(entry.BroadcastPath)
+	}
+	d.entries[entry.BroadcastPath] = memoryEntry{
+		DirectoryEntry: entry,
+		expires:        time.Now().Add(ttl),
+	}
+	return nil
+}
+
+// Delete implements Directory.
+func (d *MemoryDirectory) Delete(ctx context.Context, path moqt.BroadcastPath, node string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.entries[path]; ok && e.Node == node {
+		delete(d.entries, path)
+		d.notifyLocked(path)
+	}
+	return nil
+}
+
+// Lookup implements Directory.
+func (d *MemoryDirectory) Lookup(ctx context.Context, path moqt.BroadcastPath) (DirectoryEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.entries[path]
+	if !ok || !time.Now().Before(e.expires) {
+		return DirectoryEntry{}, ErrNotInDirectory
+	}
+	return e.DirectoryEntry, nil
+}
+
+// List implements Directory. The entries are sorted by broadcast path.
+func (d *MemoryDirectory) List(ctx context.Context, prefix string) ([]DirectoryEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	var entries []DirectoryEntry
+	for path, e := range d.entries {
+		if !now.Before(e.expires) {
+			delete(d.entries, path)
+			continue
+		}
+		if strings.HasPrefix(string(path), prefix) {
+			entries = append(entries, e.DirectoryEntry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b DirectoryEntry) int {
+		return strings.Compare(string(a.BroadcastPath), string(b.BroadcastPath))
+	})
+	return entries, nil
+}
+
+// Watch implements DirectoryWatcher. Expirations are not reported.
+func (d *MemoryDirectory) Watch(ctx context.Context, prefix string) (<-chan struct{}, error) {
+	w := &memoryWatcher{prefix: prefix, ch: make(chan struct{}, 1)}
+
+	d.mu.Lock()
+	if d.watchers == nil {
+		d.watchers = make(map[*memoryWatcher]struct{})
+	}
+	d.watchers[w] = struct{}{}
+	d.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.watchers, w)
+		close(w.ch)
+	})
+	return w.ch, nil
+}
+
+// notifyLocked notifies the watchers of path. d.mu must be held.
+func (d *MemoryDirectory) notifyLocked(path moqt.BroadcastPath) {
+	for w := range d.watchers {
+		if !strings.HasPrefix(string(path), w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package relay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryDirectory(t *testing.T) {
+	var d MemoryDirectory
+	ctx := t.Context()
+
+	cam := DirectoryEntry{BroadcastPath: "/live/cam", Node: "moqt://a", BroadcastID: moqt.NewBroadcastID()}
+	mic := DirectoryEntry{BroadcastPath: "/live/mic", Node: "moqt://b"}
+	vod := DirectoryEntry{BroadcastPath: "/vod/movie", Node: "moqt://a"}
+	for _, e := range []DirectoryEntry{mic, cam, vod} {
+		require.NoError(t, d.Put(ctx, e, time.Minute))
+	}
+
+	got, err := d.Lookup(ctx, "/live/cam")
+	require.NoError(t, err)
+	assert.Equal(t, cam, got)
+
+	_, err = d.Lookup(ctx, "/live/none")
+	assert.ErrorIs(t, err, ErrNotInDirectory)
+
+	entries, err := d.List(ctx, "/live/")
+	require.NoError(t, err)
+	assert.Equal(t, []DirectoryEntry{cam, mic}, entries)
+
+	// Only the node holding an entry deletes it.
+	require.NoError(t, d.Delete(ctx, "/live/cam", "moqt://b"))
+	_, err = d.Lookup(ctx, "/live/cam")
+	require.NoError(t, err)
+	require.NoError(t, d.Delete(ctx, "/live/cam", "moqt://a"))
+	_, err = d.Lookup(ctx, "/live/cam")
+	assert.ErrorIs(t, err, ErrNotInDirectory)
+
+	// Entries expire unless they are put again.
+	require.NoError(t, d.Put(ctx, mic, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = d.Lookup(ctx, "/live/mic")
+	assert.ErrorIs(t, err, ErrNotInDirectory)
+	entries, err = d.List(ctx, "/")
+	require.NoError(t, err)
+	assert.Equal(t, []DirectoryEntry{vod}, entries)
+}
+
+func TestMemoryDirectory_Watch(t *testing.T) {
+	var d MemoryDirectory
+	ctx, cancel := context.WithCancel(t.Context())
+	changes, err := d.Watch(ctx, "/live/")
+	require.NoError(t, err)
+
+	changed := func() bool {
+		select {
+		case <-changes:
+			return true
+		default:
+			return false
+		}
+	}
+
+	cam := DirectoryEntry{BroadcastPath: "/live/cam", Node: "moqt://a"}
+	require.NoError(t, d.Put(ctx, cam, time.Minute))
+	assert.True(t, changed(), "a new entry must be reported")
+
+	require.NoError(t, d.Put(ctx, cam, time.Minute))
+	assert.False(t, changed(), "a renewal must not be reported")
+
+	cam.Node = "moqt://b"
+	require.NoError(t, d.Put(ctx, cam, time.Minute))
+	assert.True(t, changed(), "a changed entry must be reported")
+
+	require.NoError(t, d.Put(ctx, DirectoryEntry{BroadcastPath: "/vod/movie", Node: "moqt://a"}, time.Minute))
+	assert.False(t, changed(), "entries outside the prefix must not be reported")
+
+	require.NoError(t, d.Delete(ctx, "/live/cam", "moqt://b"))
+	assert.True(t, changed(), "a deletion must be reported")
+
+	cancel()
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-changes:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond, "the channel must be closed with ctx")
+}
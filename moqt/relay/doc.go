@@ -0,0 +1,28 @@
+// Package relay implements a caching MOQ relay on top of moqt.Session.
+//
+// A Relay re-announces the broadcasts of upstream publisher sessions on a
+// moqt.TrackMux and serves downstream subscriptions from a per-track cache.
+// Each track is subscribed upstream once, no matter how many downstream
+// subscribers it has; received groups are kept in memory and fanned out to
+// every subscriber, and new subscribers start with the recently cached
+// groups instead of waiting for the next one.
+//
+// A Relay can also pull tracks that have no local publisher from an
+// upstream Origin with Relay.Pull, and share discovery state with the other
+// relays of a fleet through a Directory with Relay.Join, or forward
+// announcements between the relays of a static Cluster with
+// Relay.JoinCluster.
+//
+// Example:
+//
+//	mux := moqt.NewTrackMux(moqt.NewHopID())
+//	r := relay.New(mux, &relay.Config{CacheGroups: 4, CacheTTL: 5 * time.Second})
+//
+//	server := &moqt.Server{
+//	    TrackMux: mux,
+//	    Handler: moqt.HandleFunc(func(sess *moqt.Session) {
+//	        // Relay everything the peer publishes.
+//	        _ = r.Serve(sess.Context(), sess, "/")
+//	    }),
+//	}
+package relay
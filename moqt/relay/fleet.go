@@ -0,0 +1,300 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Fleet configures how a Relay shares discovery state with the other relays
+// of a fleet. See Relay.Join.
+type Fleet struct {
+	// Directory stores the discovery state of the fleet.
+	Directory Directory
+
+	// Node is the URL at which the other relays reach this relay, e.g.
+	// "moqt://relay-1.example.com:4433".
+	Node string
+
+	// Dialer is used to connect to the other relays.
+	// If nil, a zero moqt.Dialer is used.
+	Dialer *moqt.Dialer
+
+	// TTL is how long the entries of this relay stay in the directory
+	// without being refreshed, and so how long those of a relay that
+	// stopped without leaving are still used. The directory is synchronized
+	// every third of it. If zero, defaults to 30s.
+	TTL time.Duration
+}
+
+// ttl returns the configured TTL or the default (30s).
+func (f *Fleet) ttl() time.Duration {
+	if f.TTL > 0 {
+		return f.TTL
+	}
+	return 30 * time.Second
+}
+
+// Join shares discovery state with the other relays of fleet until ctx is
+// canceled:
+//
+//   - The broadcasts relayed with Serve are put in the directory with this
+//     relay as their node.
+//   - The broadcasts of the other nodes are announced on the relay's mux,
+//     with a handler that relays them from their node.
+//   - Subscriptions to paths with no local publisher that are not announced
+//     yet are looked up in the directory, through a moqt.TrackMux route.
+//
+// The directory is synchronized every third of the TTL of the fleet, and as
+// soon as it changes if it implements DirectoryWatcher. Since the state is
+// kept in the directory, relays can be restarted or added without losing it. Join returns nil when ctx is canceled, after deleting
+// the entries of this relay, and an error if fleet is incomplete.
+func (r *Relay) Join(ctx context.Context, fleet *Fleet) error {
+	if fleet == nil || fleet.Directory == nil || fleet.Node == "" {
+		return errors.New("relay: fleet needs a directory and a node")
+	}
+
+	m := &fleetMember{
+		relay:   r,
+		fleet:   fleet,
+		ctx:     ctx,
+		ttl:     fleet.ttl(),
+		origins: make(map[string]*Origin),
+		remote:  make(map[moqt.BroadcastPath]*remoteBroadcast),
+	}
+	defer m.leave()
+
+	r.mu.Lock()
+	r.fleets[m] = struct{}{}
+	r.mu.Unlock()
+
+	r.mux.Route(ctx, "/{path...}", moqt.TrackHandlerFunc(m.serveTrack))
+
+	ticker := time.NewTicker(m.ttl / 3)
+	defer ticker.Stop()
+
+	// Watch before the first synchronization so that no change is missed.
+	changes := m.watch()
+	m.refresh()
+	for {
+		if changes == nil {
+			changes = m.watch()
+		}
+
+		select {
+		case <-ticker.C:
+			m.refresh()
+		case _, ok := <-changes:
+			if !ok {
+				// Watch again at the next tick.
+				changes = nil
+				continue
+			}
+			m.update()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// fleetMember is the state of a Relay joined to a fleet.
+type fleetMember struct {
+	relay *Relay
+	fleet *Fleet
+	ctx   context.Context
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	origins map[string]*Origin
+	// remote holds the broadcasts of other nodes announced on the mux.
+	remote map[moqt.BroadcastPath]*remoteBroadcast
+}
+
+// remoteBroadcast is a broadcast of another node announced on the mux.
+type remoteBroadcast struct {
+	entry DirectoryEntry
+	ann   *moqt.Announcement
+	end   moqt.EndAnnouncementFunc
+}
+
+// watch returns the changes of the directory, or nil if it does not
+// implement DirectoryWatcher or watching fails.
+func (m *fleetMember) watch() <-chan struct{} {
+	w, ok := m.fleet.Directory.(DirectoryWatcher)
+	if !ok {
+		return nil
+	}
+	changes, err := w.Watch(m.ctx, "/")
+	if err != nil {
+		if m.ctx.Err() == nil {
+			m.relay.logError("failed to watch directory", err)
+		}
+		return nil
+	}
+	return changes
+}
+
+// refresh renews the entries of the local broadcasts and announces the
+// broadcasts of the other nodes.
+func (m *fleetMember) refresh() {
+	for _, ann := range m.relay.localAnnouncements() {
+		m.put(ann)
+	}
+	m.update()
+}
+
+// update announces the broadcasts of the other nodes listed in the
+// directory.
+func (m *fleetMember) update() {
+	entries, err := m.fleet.Directory.List(m.ctx, "/")
+	if err != nil {
+		if m.ctx.Err() == nil {
+			m.relay.logError("failed to list directory", err)
+		}
+		return
+	}
+	m.sync(entries)
+}
+
+// sync announces the broadcasts of entries served by other nodes and ends
+// those no longer listed.
+func (m *fleetMember) sync(entries []DirectoryEntry) {
+	listed := make(map[moqt.BroadcastPath]DirectoryEntry, len(entries))
+	for _, e := range entries {
+		if e.Node != m.fleet.Node {
+			listed[e.BroadcastPath] = e
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for path, rb := range m.remote {
+		if e, ok := listed[path]; !ok || e != rb.entry || !rb.ann.IsActive() {
+			rb.end()
+			delete(m.remote, path)
+		}
+	}
+
+	for path, e := range listed {
+		if _, ok := m.remote[path]; ok {
+			continue
+		}
+		// Broadcasts published locally take precedence.
+		if ann, _ := m.relay.mux.TrackHandler(path); ann != nil {
+			continue
+		}
+
+		ann, end := moqt.NewAnnouncementWithID(m.ctx, path, e.BroadcastID)
+		m.remote[path] = &remoteBroadcast{entry: e, ann: ann, end: end}
+		m.relay.mux.Announce(ann, m.relay.handler(m.originLocked(e.Node), e.BroadcastID))
+	}
+}
+
+// serveTrack serves a subscription to a path that is not announced on the
+// mux from the node the directory names for it.
+func (m *fleetMember) serveTrack(tw *moqt.TrackWriter) {
+	e, err := m.fleet.Directory.Lookup(tw.Context(), tw.BroadcastPath)
+	if err != nil || e.Node == m.fleet.Node {
+		if err != nil && !errors.Is(err, ErrNotInDirectory) {
+			m.relay.logError("failed to look up broadcast", err, "broadcast_path", tw.BroadcastPath)
+		}
+		moqt.NotFoundTrackHandler.ServeTrack(tw)
+		return
+	}
+
+	m.mu.Lock()
+	origin := m.originLocked(e.Node)
+	m.mu.Unlock()
+
+	m.relay.handler(origin, e.BroadcastID).ServeTrack(tw)
+}
+
+// originLocked returns the pooled Origin of node. m.mu must be held.
+func (m *fleetMember) originLocked(node string) *Origin {
+	o, ok := m.origins[node]
+	if !ok {
+		o = &Origin{URL: node, Dialer: m.fleet.Dialer}
+		m.origins[node] = o
+	}
+	return o
+}
+
+// put records ann in the directory as served by this node.
+func (m *fleetMember) put(ann *moqt.Announcement) {
+	err := m.fleet.Directory.Put(m.ctx, DirectoryEntry{
+		BroadcastPath: ann.BroadcastPath(),
+		Node:          m.fleet.Node,
+		BroadcastID:   ann.BroadcastID(),
+	}, m.ttl)
+	if err != nil && m.ctx.Err() == nil {
+		m.relay.logError("failed to put broadcast in directory", err, "broadcast_path", ann.BroadcastPath())
+	}
+}
+
+// delete removes the entry of path of this node from the directory.
+func (m *fleetMember) delete(ctx context.Context, path moqt.BroadcastPath) {
+	if err := m.fleet.Directory.Delete(ctx, path, m.fleet.Node); err != nil {
+		m.relay.logError("failed to delete broadcast from directory", err, "broadcast_path", path)
+	}
+}
+
+// leave deletes the entries of this node from the directory, ends the
+// announcements of other nodes and closes their sessions.
+func (m *fleetMember) leave() {
+	r := m.relay
+	r.mu.Lock()
+	delete(r.fleets, m)
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(m.ctx), m.ttl/3)
+	defer cancel()
+	for _, ann := range r.localAnnouncements() {
+		m.delete(ctx, ann.BroadcastPath())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rb := range m.remote {
+		rb.end()
+	}
+	for _, o := range m.origins {
+		_ = o.Close()
+	}
+}
+
+// addLocal records ann, announced by Serve, as served by this relay, and
+// puts it in the directories of the joined fleets while it is active.
+func (r *Relay) addLocal(ann *moqt.Announcement) {
+	r.mu.Lock()
+	r.local[ann] = struct{}{}
+	fleets := slices.Collect(maps.Keys(r.fleets))
+	r.mu.Unlock()
+
+	for _, m := range fleets {
+		m.put(ann)
+	}
+
+	ann.AfterFunc(func() {
+		r.mu.Lock()
+		delete(r.local, ann)
+		fleets := slices.Collect(maps.Keys(r.fleets))
+		r.mu.Unlock()
+
+		for _, m := range fleets {
+			m.delete(m.ctx, ann.BroadcastPath())
+		}
+	})
+}
+
+// localAnnouncements returns the active announcements relayed with Serve.
+func (r *Relay) localAnnouncements() []*moqt.Announcement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Collect(maps.Keys(r.local))
+}
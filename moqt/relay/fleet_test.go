@@ -0,0 +1,112 @@
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelay_Join(t *testing.T) {
+	var dir MemoryDirectory
+	dialer := &moqt.Dialer{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+
+	// Relay A serves the publisher.
+	muxA := moqt.NewTrackMux(moqt.NewHopID())
+	relayA := New(muxA, nil)
+	addrA := startRelayServer(t, muxA, relayA)
+	nodeA := "moqt://" + addrA
+	go func() {
+		_ = relayA.Join(t.Context(), &Fleet{Directory: &dir, Node: nodeA, Dialer: dialer, TTL: time.Second})
+	}()
+
+	var upstreamSubscriptions atomic.Int32
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.Publish(t.Context(), "/live/cam", newTestPublisher(&upstreamSubscriptions))
+	moqttest.Dial(t, addrA, pubMux)
+
+	require.Eventually(t, func() bool {
+		e, err := dir.Lookup(t.Context(), "/live/cam")
+		return err == nil && e.Node == nodeA
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Relay B learns about the broadcast from the directory alone.
+	muxB := moqt.NewTrackMux(moqt.NewHopID())
+	relayB := New(muxB, nil)
+	addrB := startRelayServer(t, muxB, relayB)
+	ctxB, leaveB := context.WithCancel(t.Context())
+	joined := make(chan error, 1)
+	go func() {
+		joined <- relayB.Join(ctxB, &Fleet{Directory: &dir, Node: "moqt://" + addrB, Dialer: dialer, TTL: time.Second})
+	}()
+
+	require.Eventually(t, func() bool {
+		ann, _ := muxB.TrackHandler("/live/cam")
+		return ann != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	sub := moqttest.Dial(t, addrB, moqt.NewTrackMux(0))
+	tr := subscribeRelay(t, sub, "/live/cam", "video")
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	gr, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	frame := moqt.NewFrame(0)
+	require.NoError(t, gr.ReadFrame(frame))
+	assert.Equal(t, []byte("hello"), frame.Body())
+
+	// Relay B does not put broadcasts of other nodes in the directory.
+	entries, err := dir.List(t.Context(), "/")
+	require.NoError(t, err)
+	assert.Equal(t, []DirectoryEntry{{BroadcastPath: "/live/cam", Node: nodeA}}, entries)
+
+	// Leaving ends the announcements of other nodes.
+	leaveB()
+	require.NoError(t, <-joined)
+	ann, _ := muxB.TrackHandler("/live/cam")
+	assert.Nil(t, ann)
+}
+
+func TestRelay_Join_Watch(t *testing.T) {
+	var dir MemoryDirectory
+	mux := moqt.NewTrackMux(moqt.NewHopID())
+	r := New(mux, nil)
+
+	// With a TTL of a minute, only watching picks up changes in time.
+	go func() {
+		_ = r.Join(t.Context(), &Fleet{Directory: &dir, Node: "moqt://relay-b", TTL: time.Minute})
+	}()
+	entry := DirectoryEntry{BroadcastPath: "/live/cam", Node: "moqt://relay-a"}
+	require.NoError(t, dir.Put(t.Context(), entry, time.Minute))
+	require.Eventually(t, func() bool {
+		ann, _ := mux.TrackHandler("/live/cam")
+		return ann != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, dir.Delete(t.Context(), "/live/cam", "moqt://relay-a"))
+	require.Eventually(t, func() bool {
+		ann, _ := mux.TrackHandler("/live/cam")
+		return ann == nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestRelay_Join_IncompleteFleet(t *testing.T) {
+	r := New(moqt.NewTrackMux(0), nil)
+
+	tests := map[string]*Fleet{
+		"nil fleet":         nil,
+		"missing directory": {Node: "moqt://relay.example.com"},
+		"missing node":      {Directory: &MemoryDirectory{}},
+	}
+	for name, fleet := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, r.Join(t.Context(), fleet))
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// errOriginClosed is returned by Origin.Subscribe after Close.
+var errOriginClosed = errors.New("relay: origin closed")
+
+// Origin is an upstream origin server that a relay pulls tracks from on
+// demand. It implements Upstream.
+//
+// An Origin pools a single session to the origin: the session is dialed on
+// the first subscription, shared by all later ones, and dialed again after it
+// ends. Concurrent subscriptions wait for the same dial.
+//
+// All methods are safe for concurrent use. Fields must not be modified after
+// the first call to Subscribe.
+type Origin struct {
+	// URL is the address of the origin, e.g. "moqt://origin.example.com:4433"
+	// or "https://origin.example.com/moq".
+	URL string
+
+	// Dialer is used to connect to the origin.
+	// If nil, a zero moqt.Dialer is used.
+	Dialer *moqt.Dialer
+
+	mu      sync.Mutex
+	sess    *moqt.Session
+	dialing *originDial
+	closed  bool
+}
+
+// originDial is an in-flight dial shared by concurrent subscriptions.
+type originDial struct {
+	done chan struct{}
+	sess *moqt.Session
+	err  error
+}
+
+// Subscribe subscribes to the track on the origin, dialing it if there is no
+// open session.
+func (o *Origin) Subscribe(ctx context.Context, path moqt.BroadcastPath, name moqt.TrackName, config *moqt.SubscribeConfig) (*moqt.TrackReader, error) {
+	sess, err := o.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sess.Subscribe(ctx, path, name, config)
+}
+
+// Close closes the pooled session. Later calls to Subscribe fail.
+func (o *Origin) Close() error {
+	o.mu.Lock()
+	sess := o.sess
+	o.sess = nil
+	o.closed = true
+	o.mu.Unlock()
+
+	if sess == nil {
+		return nil
+	}
+	return sess.CloseWithError(moqt.NoError, "")
+}
+
+// session returns the pooled session, dialing the origin if necessary.
+func (o *Origin) session(ctx context.Context) (*moqt.Session, error) {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return nil, errOriginClosed
+	}
+	if o.sess != nil && o.sess.Context().Err() == nil {
+		sess := o.sess
+		o.mu.Unlock()
+		return sess, nil
+	}
+
+	dial := o.dialing
+	if dial == nil {
+		dial = &originDial{done: make(chan struct{})}
+		o.dialing = dial
+		go o.dial(dial)
+	}
+	o.mu.Unlock()
+
+	select {
+	case <-dial.done:
+		return dial.sess, dial.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (o *Origin) dial(dial *originDial) {
+	dialer := o.Dialer
+	if dialer == nil {
+		dialer = &moqt.Dialer{}
+	}
+
+	// The origin does not subscribe to the relay, so it gets an empty mux.
+	sess, err := dialer.Dial(context.Background(), o.URL, moqt.NewTrackMux(0))
+
+	o.mu.Lock()
+	o.dialing = nil
+	if err == nil && o.closed {
+		o.mu.Unlock()
+		_ = sess.CloseWithError(moqt.NoError, "")
+		sess, err = nil, errOriginClosed
+	} else {
+		if err == nil {
+			o.sess = sess
+		}
+		o.mu.Unlock()
+	}
+
+	dial.sess, dial.err = sess, err
+	close(dial.done)
+}
@@ -0,0 +1,138 @@
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startOriginServer(t *testing.T, subscriptions, sessions *atomic.Int32) string {
+	t.Helper()
+
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live/cam", newTestPublisher(subscriptions))
+	return startServer(t, mux, moqt.HandleFunc(func(sess *moqt.Session) {
+		sessions.Add(1)
+		<-sess.Context().Done()
+	}))
+}
+
+func newTestOrigin(t *testing.T, addr string) *Origin {
+	t.Helper()
+
+	origin := &Origin{
+		URL:    "moqt://" + addr,
+		Dialer: &moqt.Dialer{TLSConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	t.Cleanup(func() {
+		_ = origin.Close()
+	})
+	return origin
+}
+
+func TestRelay_Pull(t *testing.T) {
+	var originSubscriptions, originSessions atomic.Int32
+	origin := newTestOrigin(t, startOriginServer(t, &originSubscriptions, &originSessions))
+
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, nil)
+	r.Pull(t.Context(), "/{path...}", origin)
+	addr := startServer(t, mux, moqt.HandleFunc(func(sess *moqt.Session) {
+		<-sess.Context().Done()
+	}))
+
+	const subscribers = 3
+	readers := make([]*moqt.TrackReader, subscribers)
+	for i := range subscribers {
+		sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+		tr, err := sub.Subscribe(t.Context(), "/live/cam", "video", nil)
+		require.NoError(t, err)
+		readers[i] = tr
+	}
+
+	for _, tr := range readers {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		gr, err := tr.AcceptGroup(ctx)
+		cancel()
+		require.NoError(t, err)
+
+		frame := moqt.NewFrame(0)
+		require.NoError(t, gr.ReadFrame(frame))
+		assert.Equal(t, []byte("hello"), frame.Body())
+	}
+
+	assert.Equal(t, int32(1), originSessions.Load(), "origin must be dialed once")
+	assert.Equal(t, int32(1), originSubscriptions.Load(), "track must be subscribed on the origin once")
+}
+
+func TestRelay_Pull_LocalPublisherFirst(t *testing.T) {
+	var originSubscriptions, originSessions atomic.Int32
+	origin := newTestOrigin(t, startOriginServer(t, &originSubscriptions, &originSessions))
+
+	var localSubscriptions atomic.Int32
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live/cam", newTestPublisher(&localSubscriptions))
+	r := New(mux, nil)
+	r.Pull(t.Context(), "/{path...}", origin)
+	addr := startServer(t, mux, moqt.HandleFunc(func(sess *moqt.Session) {
+		<-sess.Context().Done()
+	}))
+
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	tr, err := sub.Subscribe(t.Context(), "/live/cam", "video", nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	_, err = tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), localSubscriptions.Load())
+	assert.Equal(t, int32(0), originSessions.Load(), "origin must not be dialed")
+}
+
+func TestRelay_Pull_NotFoundOnOrigin(t *testing.T) {
+	var originSubscriptions, originSessions atomic.Int32
+	origin := newTestOrigin(t, startOriginServer(t, &originSubscriptions, &originSessions))
+
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, nil)
+	r.Pull(t.Context(), "/{path...}", origin)
+	addr := startServer(t, mux, moqt.HandleFunc(func(sess *moqt.Session) {
+		<-sess.Context().Done()
+	}))
+
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	_, err := sub.Subscribe(t.Context(), "/missing", "video", nil)
+
+	var subErr *moqt.SubscribeError
+	require.True(t, errors.As(err, &subErr), "got %v", err)
+	assert.Equal(t, moqt.SubscribeErrorCodeNotFound, subErr.SubscribeErrorCode())
+}
+
+func TestOrigin_Closed(t *testing.T) {
+	origin := &Origin{URL: "moqt://127.0.0.1:1"}
+	require.NoError(t, origin.Close())
+
+	_, err := origin.Subscribe(t.Context(), "/live/cam", "video", nil)
+	assert.ErrorIs(t, err, errOriginClosed)
+}
+
+func TestOrigin_DialError(t *testing.T) {
+	origin := &Origin{URL: "invalid://origin"}
+
+	_, err := origin.Subscribe(t.Context(), "/live/cam", "video", nil)
+	assert.ErrorIs(t, err, moqt.ErrInvalidScheme)
+
+	// A failed dial is not cached.
+	_, err = origin.Subscribe(t.Context(), "/live/cam", "video", nil)
+	assert.ErrorIs(t, err, moqt.ErrInvalidScheme)
+}
@@ -0,0 +1,90 @@
+package relay
+
+import (
+	"strings"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Policy controls which broadcasts of an upstream session a Relay
+// re-announces downstream and under which paths, so that the relay routes
+// the namespaces of its publishers instead of mirroring them.
+//
+// Prefixes are matched against the upstream path of a broadcast, before any
+// rewrite. They are plain string prefixes, so end them with "/" to match
+// whole path segments.
+//
+// Example:
+//
+//	// Relay the live broadcasts of a tenant under its own namespace.
+//	policy := &relay.Policy{
+//	    Allow:    []string{"/live/"},
+//	    Deny:     []string{"/live/private/"},
+//	    Rewrites: []relay.Rewrite{{From: "/", To: "/tenant-a/"}},
+//	}
+//	_ = r.ServePolicy(ctx, sess, "/", policy)
+type Policy struct {
+	// Allow lists the path prefixes of the broadcasts to relay.
+	// If empty, every broadcast is allowed.
+	Allow []string
+
+	// Deny lists the path prefixes of broadcasts never relayed, even if
+	// allowed.
+	Deny []string
+
+	// Filter, if set, must also accept a broadcast for it to be relayed,
+	// such as moqt.MaxHops(2).
+	Filter moqt.AnnouncementFilter
+
+	// Rewrites rewrite the paths of the relayed broadcasts. The first rule
+	// whose From prefix matches applies; paths matching none are kept.
+	Rewrites []Rewrite
+}
+
+// Rewrite replaces the prefix From of a broadcast path with To.
+type Rewrite struct {
+	From string
+	To   string
+}
+
+// Route returns the path under which the broadcast of ann is announced
+// downstream, and false if the policy does not relay it. A nil Policy relays
+// every broadcast under its own path. Broadcasts rewritten to a path not
+// starting with "/" are not relayed.
+func (p *Policy) Route(ann *moqt.Announcement) (moqt.BroadcastPath, bool) {
+	path := ann.BroadcastPath()
+	if p == nil {
+		return path, true
+	}
+
+	if len(p.Allow) > 0 && !hasAnyPrefix(path, p.Allow) {
+		return "", false
+	}
+	if hasAnyPrefix(path, p.Deny) {
+		return "", false
+	}
+	if p.Filter != nil && !p.Filter(ann) {
+		return "", false
+	}
+
+	for _, rw := range p.Rewrites {
+		if suffix, ok := path.GetSuffix(rw.From); ok {
+			path = moqt.BroadcastPath(rw.To + suffix)
+			break
+		}
+	}
+	if !strings.HasPrefix(string(path), "/") {
+		return "", false
+	}
+	return path, true
+}
+
+// hasAnyPrefix reports whether path starts with one of prefixes.
+func hasAnyPrefix(path moqt.BroadcastPath, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path.HasPrefix(prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package relay
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Route(t *testing.T) {
+	tests := map[string]struct {
+		policy *Policy
+		path   moqt.BroadcastPath
+		want   moqt.BroadcastPath
+		wantOK bool
+	}{
+		"nil policy": {
+			path: "/live/cam", want: "/live/cam", wantOK: true,
+		},
+		"allowed": {
+			policy: &Policy{Allow: []string{"/vod/", "/live/"}},
+			path:   "/live/cam", want: "/live/cam", wantOK: true,
+		},
+		"not allowed": {
+			policy: &Policy{Allow: []string{"/vod/"}},
+			path:   "/live/cam",
+		},
+		"denied": {
+			policy: &Policy{Allow: []string{"/live/"}, Deny: []string{"/live/private/"}},
+			path:   "/live/private/cam",
+		},
+		"filtered": {
+			policy: &Policy{Filter: moqt.MatchSuffix("*/audio")},
+			path:   "/live/cam",
+		},
+		"rewritten": {
+			policy: &Policy{Rewrites: []Rewrite{{From: "/live/", To: "/tenant-a/live/"}, {From: "/", To: "/other/"}}},
+			path:   "/live/cam", want: "/tenant-a/live/cam", wantOK: true,
+		},
+		"no matching rewrite": {
+			policy: &Policy{Rewrites: []Rewrite{{From: "/vod/", To: "/archive/"}}},
+			path:   "/live/cam", want: "/live/cam", wantOK: true,
+		},
+		"invalid rewrite": {
+			policy: &Policy{Rewrites: []Rewrite{{From: "/live/", To: "live/"}}},
+			path:   "/live/cam",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ann, _ := moqt.NewAnnouncement(context.Background(), tt.path)
+			got, ok := tt.policy.Route(ann)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRelay_ServePolicy(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{Policy: &Policy{
+		Deny:     []string{"/private/"},
+		Rewrites: []Rewrite{{From: "/", To: "/tenant-a/"}},
+	}})
+	addr := startRelayServer(t, mux, r)
+
+	var subscriptions atomic.Int32
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.Publish(t.Context(), "/live/cam", newTestPublisher(&subscriptions))
+	pubMux.Publish(t.Context(), "/private/cam", newTestPublisher(&subscriptions))
+	moqttest.Dial(t, addr, pubMux)
+
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	tr := subscribeRelay(t, sub, "/tenant-a/live/cam", "video")
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	gr, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	frame := moqt.NewFrame(0)
+	require.NoError(t, gr.ReadFrame(frame))
+	assert.Equal(t, []byte("hello"), frame.Body())
+
+	// Rewritten and denied broadcasts are not announced under their own path.
+	for _, path := range []moqt.BroadcastPath{"/live/cam", "/tenant-a/private/cam", "/private/cam"} {
+		ann, _ := mux.TrackHandler(path)
+		assert.Nil(t, ann, "%s must not be announced", path)
+	}
+
+	// Fetches use the downstream path.
+	require.Eventually(t, func() bool {
+		return r.cachedGroup("/tenant-a/live/cam", "video", gr.GroupSequence()) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
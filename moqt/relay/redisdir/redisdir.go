@@ -0,0 +1,393 @@
+// Package redisdir implements a relay.Directory stored in Redis, so that the
+// relays of a fleet share discovery state that survives their restarts.
+//
+// Each entry is stored under the key KeyPrefix + broadcast path with the
+// entry TTL as key expiry. Additions, changes and deletions of entries are
+// published on the channel KeyPrefix + "changes", which Watch subscribes to.
+// The package speaks the Redis protocol directly and has no dependencies; it
+// works with Redis and with compatible servers such as Valkey that support
+// SET with PX, SCAN, MGET, EVAL and Pub/Sub.
+//
+// Example:
+//
+//	dir := &redisdir.Directory{Addr: "redis.internal:6379"}
+//	defer dir.Close()
+//
+//	go r.Join(ctx, &relay.Fleet{
+//	    Directory: dir,
+//	    Node:      "moqt://relay-1.example.com:4433",
+//	})
+package redisdir
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/moqt/relay"
+)
+
+// DefaultKeyPrefix is the key prefix used if Directory.KeyPrefix is empty.
+const DefaultKeyPrefix = "moqt:broadcast:"
+
+// putScript sets KEYS[1] to ARGV[1] with the expiry ARGV[2] in milliseconds,
+// and publishes ARGV[4] on the channel ARGV[3] if the value changed.
+const putScript = `local v = redis.call('GET', KEYS[1])
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+if v ~= ARGV[1] then
+	redis.call('PUBLISH', ARGV[3], ARGV[4])
+end
+return 1`
+
+// deleteScript deletes KEYS[1] if its entry is held by the node ARGV[1], and
+// publishes ARGV[3] on the channel ARGV[2] if it did.
+const deleteScript = `local v = redis.call('GET', KEYS[1])
+if v and cjson.decode(v).node == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	redis.call('PUBLISH', ARGV[2], ARGV[3])
+	return 1
+end
+return 0`
+
+// Directory is a relay.Directory stored in Redis. It uses a single
+// connection, dialed on first use and dialed again after an error.
+//
+// All methods are safe for concurrent use. Fields must not be modified after
+// the first call to a method.
+type Directory struct {
+	// Addr is the address of the Redis server.
+	// If empty, defaults to "localhost:6379".
+	Addr string
+
+	// Username and Password authenticate the connection if Password is set.
+	Username string
+	Password string
+
+	// DB is the database to select.
+	DB int
+
+	// KeyPrefix is prepended to broadcast paths to form keys, so that
+	// several fleets can share a database.
+	// If empty, defaults to DefaultKeyPrefix.
+	KeyPrefix string
+
+	mu   sync.Mutex
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+var (
+	_ relay.Directory        = (*Directory)(nil)
+	_ relay.DirectoryWatcher = (*Directory)(nil)
+)
+
+// record is the stored value of an entry.
+type record struct {
+	Node        string `json:"node"`
+	BroadcastID string `json:"broadcast_id,omitempty"`
+}
+
+// Put implements relay.Directory.
+func (d *Directory) Put(ctx context.Context, entry relay.DirectoryEntry, ttl time.Duration) error {
+	rec := record{Node: entry.Node}
+	if !entry.BroadcastID.IsZero() {
+		rec.BroadcastID = hex.EncodeToString(entry.BroadcastID[:])
+	}
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	ms := max(ttl.Milliseconds(), 1)
+	_, err = d.do(ctx, "EVAL", putScript, "1", d.key(entry.BroadcastPath),
+		string(value), strconv.FormatInt(ms, 10), d.channel(), string(entry.BroadcastPath))
+	return err
+}
+
+// Delete implements relay.Directory.
+func (d *Directory) Delete(ctx context.Context, path moqt.BroadcastPath, node string) error {
+	_, err := d.do(ctx, "EVAL", deleteScript, "1", d.key(path), node, d.channel(), string(path))
+	return err
+}
+
+// Watch implements relay.DirectoryWatcher. It subscribes to the changes on
+// a connection of its own, which is closed with ctx. Expirations are not
+// reported.
+func (d *Directory) Watch(ctx context.Context, prefix string) (<-chan struct{}, error) {
+	conn, rw, err := d.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := roundTrip(ctx, conn, rw, []string{"SUBSCRIBE", d.channel()}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	// Messages arrive at any time: only ctx bounds the subscription.
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	go func() {
+		defer close(changes)
+		defer stop()
+		defer conn.Close()
+		for {
+			reply, err := readReply(rw.Reader)
+			if err != nil {
+				return
+			}
+			// A message is the array ["message", channel, path].
+			msg, ok := reply.([]any)
+			if !ok || len(msg) != 3 || msg[0] != "message" {
+				continue
+			}
+			if path, ok := msg[2].(string); !ok || !strings.HasPrefix(path, prefix) {
+				continue
+			}
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// Lookup implements relay.Directory.
+func (d *Directory) Lookup(ctx context.Context, path moqt.BroadcastPath) (relay.DirectoryEntry, error) {
+	reply, err := d.do(ctx, "GET", d.key(path))
+	if errors.Is(err, errNil) {
+		return relay.DirectoryEntry{}, fmt.Errorf("%w: %s", relay.ErrNotInDirectory, path)
+	}
+	if err != nil {
+		return relay.DirectoryEntry{}, err
+	}
+	return parseEntry(path, reply)
+}
+
+// List implements relay.Directory. The entries are in no particular order.
+func (d *Directory) List(ctx context.Context, prefix string) ([]relay.DirectoryEntry, error) {
+	pattern := escapePattern(d.key(moqt.BroadcastPath(prefix))) + "*"
+
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := d.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("redisdir: unexpected SCAN reply %v", reply)
+		}
+		cursor, _ = page[0].(string)
+		found, _ := page[1].([]any)
+		for _, k := range found {
+			if k, ok := k.(string); ok {
+				keys = append(keys, k)
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	reply, err := d.do(ctx, append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != len(keys) {
+		return nil, fmt.Errorf("redisdir: unexpected MGET reply %v", reply)
+	}
+
+	entries := make([]relay.DirectoryEntry, 0, len(keys))
+	for i, v := range values {
+		// Keys that expired since SCAN are nil.
+		if v == nil {
+			continue
+		}
+		path := moqt.BroadcastPath(strings.TrimPrefix(keys[i], d.keyPrefix()))
+		e, err := parseEntry(path, v)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Close closes the connection to the server. The next call dials again.
+func (d *Directory) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closeLocked()
+}
+
+func (d *Directory) closeLocked() error {
+	if d.conn == nil {
+		return nil
+	}
+	err := d.conn.Close()
+	d.conn, d.rw = nil, nil
+	return err
+}
+
+func (d *Directory) keyPrefix() string {
+	if d.KeyPrefix != "" {
+		return d.KeyPrefix
+	}
+	return DefaultKeyPrefix
+}
+
+func (d *Directory) key(path moqt.BroadcastPath) string {
+	return d.keyPrefix() + string(path)
+}
+
+// channel returns the channel the changes are published on. Broadcast paths
+// start with a slash, so it is not the key of an entry.
+func (d *Directory) channel() string {
+	return d.keyPrefix() + "changes"
+}
+
+// do sends a command and returns its reply. The connection is closed after
+// errors other than error and nil replies, as it may be out of sync.
+func (d *Directory) do(ctx context.Context, args ...string) (any, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.connectLocked(ctx); err != nil {
+		return nil, err
+	}
+
+	reply, err := d.roundTripLocked(ctx, args)
+	if err != nil {
+		if _, ok := errors.AsType[redisError](err); !ok && !errors.Is(err, errNil) {
+			_ = d.closeLocked()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return reply, err
+}
+
+func (d *Directory) connectLocked(ctx context.Context) error {
+	if d.conn != nil {
+		return nil
+	}
+
+	conn, rw, err := d.dial(ctx)
+	if err != nil {
+		return err
+	}
+	d.conn, d.rw = conn, rw
+	return nil
+}
+
+// dial connects to the server and sets the connection up.
+func (d *Directory) dial(ctx context.Context) (net.Conn, *bufio.ReadWriter, error) {
+	addr := d.Addr
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	var setup [][]string
+	if d.Password != "" {
+		if d.Username != "" {
+			setup = append(setup, []string{"AUTH", d.Username, d.Password})
+		} else {
+			setup = append(setup, []string{"AUTH", d.Password})
+		}
+	}
+	if d.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(d.DB)})
+	}
+	for _, args := range setup {
+		if _, err := roundTrip(ctx, conn, rw, args); err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+	}
+	return conn, rw, nil
+}
+
+func (d *Directory) roundTripLocked(ctx context.Context, args []string) (any, error) {
+	return roundTrip(ctx, d.conn, d.rw, args)
+}
+
+// roundTrip sends a command on conn and returns its reply.
+func roundTrip(ctx context.Context, conn net.Conn, rw *bufio.ReadWriter, args []string) (any, error) {
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	// Unblock the connection when ctx is canceled.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if err := writeCommand(rw.Writer, args...); err != nil {
+		return nil, err
+	}
+	return readReply(rw.Reader)
+}
+
+// parseEntry decodes the stored value of the entry of path.
+func parseEntry(path moqt.BroadcastPath, value any) (relay.DirectoryEntry, error) {
+	s, ok := value.(string)
+	if !ok {
+		return relay.DirectoryEntry{}, fmt.Errorf("redisdir: unexpected value %v for %s", value, path)
+	}
+	var rec record
+	if err := json.Unmarshal([]byte(s), &rec); err != nil {
+		return relay.DirectoryEntry{}, fmt.Errorf("redisdir: malformed entry for %s: %w", path, err)
+	}
+
+	e := relay.DirectoryEntry{BroadcastPath: path, Node: rec.Node}
+	if rec.BroadcastID != "" {
+		id, err := hex.DecodeString(rec.BroadcastID)
+		if err != nil || len(id) != len(e.BroadcastID) {
+			return relay.DirectoryEntry{}, fmt.Errorf("redisdir: malformed broadcast ID for %s", path)
+		}
+		copy(e.BroadcastID[:], id)
+	}
+	return e, nil
+}
+
+// escapePattern escapes the glob characters of SCAN MATCH patterns in s.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,294 @@
+package redisdir
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/moqt/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a Redis server supporting the commands used by Directory.
+type fakeRedis struct {
+	mu       sync.Mutex
+	values   map[string]string
+	expires  map[string]time.Time
+	commands []string
+	// subscribers are the writers of the connections subscribed to a
+	// channel.
+	subscribers map[*bufio.Writer]string
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	s := &fakeRedis{
+		values:      make(map[string]string),
+		expires:     make(map[string]time.Time),
+		subscribers: make(map[*bufio.Writer]string),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, ln.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		elems := req.([]any)
+		args := make([]string, len(elems))
+		for i, e := range elems {
+			args[i] = e.(string)
+		}
+		s.handle(w, args)
+		// Messages are written to subscribers with s.mu held.
+		s.mu.Lock()
+		err = w.Flush()
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// publishLocked writes message to the subscribers of channel. s.mu must be
+// held.
+func (s *fakeRedis) publishLocked(channel, message string) {
+	for w, ch := range s.subscribers {
+		if ch != channel {
+			continue
+		}
+		w.WriteString("*3\r\n$7\r\nmessage\r\n")
+		w.WriteString("$" + strconv.Itoa(len(channel)) + "\r\n" + channel + "\r\n")
+		w.WriteString("$" + strconv.Itoa(len(message)) + "\r\n" + message + "\r\n")
+		_ = w.Flush()
+	}
+}
+
+func (s *fakeRedis) handle(w *bufio.Writer, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = append(s.commands, args[0])
+	for k, exp := range s.expires {
+		if !time.Now().Before(exp) {
+			delete(s.values, k)
+			delete(s.expires, k)
+		}
+	}
+
+	bulk := func(v string) { w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n") }
+	switch args[0] {
+	case "AUTH", "SELECT":
+		w.WriteString("+OK\r\n")
+	case "SUBSCRIBE":
+		s.subscribers[w] = args[1]
+		w.WriteString("*3\r\n$9\r\nsubscribe\r\n")
+		bulk(args[1])
+		w.WriteString(":1\r\n")
+	case "GET":
+		v, ok := s.values[args[1]]
+		if !ok {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		bulk(v)
+	case "EVAL":
+		if args[1] == putScript {
+			old := s.values[args[3]]
+			s.values[args[3]] = args[4]
+			ms, _ := time.ParseDuration(args[5] + "ms")
+			s.expires[args[3]] = time.Now().Add(ms)
+			if old != args[4] {
+				s.publishLocked(args[6], args[7])
+			}
+			w.WriteString(":1\r\n")
+			return
+		}
+		var rec record
+		_ = json.Unmarshal([]byte(s.values[args[3]]), &rec)
+		if v, ok := s.values[args[3]]; ok && v != "" && rec.Node == args[4] {
+			delete(s.values, args[3])
+			s.publishLocked(args[5], args[6])
+			w.WriteString(":1\r\n")
+			return
+		}
+		w.WriteString(":0\r\n")
+	case "SCAN":
+		prefix := strings.NewReplacer(`\`, "").Replace(strings.TrimSuffix(args[3], "*"))
+		var keys []string
+		for k := range s.values {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		w.WriteString("*2\r\n")
+		bulk("0")
+		w.WriteString("*" + strconv.Itoa(len(keys)) + "\r\n")
+		for _, k := range keys {
+			bulk(k)
+		}
+	case "MGET":
+		w.WriteString("*" + strconv.Itoa(len(args)-1) + "\r\n")
+		for _, k := range args[1:] {
+			if v, ok := s.values[k]; ok {
+				bulk(v)
+			} else {
+				w.WriteString("$-1\r\n")
+			}
+		}
+	default:
+		w.WriteString("-ERR unknown command\r\n")
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	server, addr := startFakeRedis(t)
+	d := &Directory{Addr: addr, Password: "secret", DB: 2}
+	t.Cleanup(func() { _ = d.Close() })
+	ctx := t.Context()
+
+	cam := relay.DirectoryEntry{BroadcastPath: "/live/cam", Node: "moqt://a", BroadcastID: moqt.NewBroadcastID()}
+	mic := relay.DirectoryEntry{BroadcastPath: "/live/mic", Node: "moqt://b"}
+	vod := relay.DirectoryEntry{BroadcastPath: "/vod/movie", Node: "moqt://a"}
+	for _, e := range []relay.DirectoryEntry{cam, mic, vod} {
+		require.NoError(t, d.Put(ctx, e, time.Minute))
+	}
+
+	got, err := d.Lookup(ctx, "/live/cam")
+	require.NoError(t, err)
+	assert.Equal(t, cam, got)
+
+	_, err = d.Lookup(ctx, "/live/none")
+	assert.ErrorIs(t, err, relay.ErrNotInDirectory)
+
+	entries, err := d.List(ctx, "/live/")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []relay.DirectoryEntry{cam, mic}, entries)
+
+	// Only the node holding an entry deletes it.
+	require.NoError(t, d.Delete(ctx, "/live/cam", "moqt://b"))
+	_, err = d.Lookup(ctx, "/live/cam")
+	require.NoError(t, err)
+	require.NoError(t, d.Delete(ctx, "/live/cam", "moqt://a"))
+	_, err = d.Lookup(ctx, "/live/cam")
+	assert.ErrorIs(t, err, relay.ErrNotInDirectory)
+
+	server.mu.Lock()
+	assert.Equal(t, []string{"AUTH", "SELECT"}, server.commands[:2], "connection must be set up once")
+	_, ok := server.values[DefaultKeyPrefix+"/vod/movie"]
+	server.mu.Unlock()
+	assert.True(t, ok, "entries must be stored under the key prefix")
+}
+
+func TestDirectory_Redial(t *testing.T) {
+	_, addr := startFakeRedis(t)
+	d := &Directory{Addr: addr}
+	ctx := t.Context()
+
+	entry := relay.DirectoryEntry{BroadcastPath: "/live/cam", Node: "moqt://a"}
+	require.NoError(t, d.Put(ctx, entry, time.Minute))
+
+	// A broken connection is dialed again on the next call.
+	require.NoError(t, d.Close())
+	got, err := d.Lookup(ctx, "/live/cam")
+	require.NoError(t, err)
+	assert.Equal(t, entry, got)
+	require.NoError(t, d.Close())
+}
+
+func TestDirectory_Watch(t *testing.T) {
+	_, addr := startFakeRedis(t)
+	d := &Directory{Addr: addr}
+	t.Cleanup(func() { _ = d.Close() })
+
+	ctx, cancel := context.WithCancel(t.Context())
+	changes, err := d.Watch(ctx, "/live/")
+	require.NoError(t, err)
+
+	changed := func() bool {
+		select {
+		case <-changes:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}
+
+	cam := relay.DirectoryEntry{BroadcastPath: "/live/cam", Node: "moqt://a"}
+	require.NoError(t, d.Put(ctx, cam, time.Minute))
+	assert.True(t, changed(), "a new entry must be reported")
+
+	require.NoError(t, d.Put(ctx, cam, time.Minute))
+	assert.False(t, changed(), "a renewal must not be reported")
+
+	require.NoError(t, d.Put(ctx, relay.DirectoryEntry{BroadcastPath: "/vod/movie", Node: "moqt://a"}, time.Minute))
+	assert.False(t, changed(), "entries outside the prefix must not be reported")
+
+	require.NoError(t, d.Delete(ctx, "/live/cam", "moqt://a"))
+	assert.True(t, changed(), "a deletion must be reported")
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		assert.False(t, ok, "the channel must be closed with ctx")
+	case <-time.After(time.Second):
+		t.Fatal("the channel was not closed with ctx")
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		want    any
+		wantErr error
+	}{
+		"simple string": {input: "+OK\r\n", want: "OK"},
+		"integer":       {input: ":42\r\n", want: int64(42)},
+		"bulk string":   {input: "$5\r\nhello\r\n", want: "hello"},
+		"nil bulk":      {input: "$-1\r\n", wantErr: errNil},
+		"array with nil": {
+			input: "*2\r\n$1\r\na\r\n$-1\r\n",
+			want:  []any{"a", nil},
+		},
+		"error": {input: "-ERR wrong\r\n", wantErr: redisError("ERR wrong")},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := readReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
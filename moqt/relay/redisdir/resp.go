@@ -0,0 +1,84 @@
+package redisdir
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// errNil is the value of a nil reply.
+var errNil = errors.New("redisdir: nil reply")
+
+// redisError is an error reply.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redisdir: " + string(e)
+}
+
+// writeCommand writes args as a RESP array of bulk strings.
+func writeCommand(w *bufio.Writer, args ...string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return w.Flush()
+}
+
+// readReply reads a RESP reply. Simple and bulk strings are returned as
+// string, integers as int64 and arrays as []any. A nil bulk string or array
+// is returned as errNil, an error reply as redisError.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redisdir: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redisdir: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redisdir: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		elems := make([]any, n)
+		for i := range elems {
+			elem, err := readReply(r)
+			if err != nil && !errors.Is(err, errNil) {
+				return nil, err
+			}
+			// Nil elements, such as missing keys of MGET, are kept as nil.
+			elems[i] = elem
+		}
+		return elems, nil
+	default:
+		return nil, fmt.Errorf("redisdir: unknown reply type %q", kind)
+	}
+}
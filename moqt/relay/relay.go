@@ -0,0 +1,560 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Upstream is the source of relayed tracks. *moqt.Session implements it.
+type Upstream interface {
+	Subscribe(ctx context.Context, path moqt.BroadcastPath, name moqt.TrackName, config *moqt.SubscribeConfig) (*moqt.TrackReader, error)
+}
+
+// Config contains configuration options for a Relay.
+type Config struct {
+	// CacheGroups is the number of recent groups cached per track.
+	// If zero, defaults to 8.
+	CacheGroups int
+
+	// CacheTTL is how long a cached group is served to subscribers after it
+	// was received from upstream. If zero, defaults to 10s.
+	CacheTTL time.Duration
+
+	// Logger receives relay errors. If nil, errors are not logged.
+	Logger *slog.Logger
+
+	// Policy controls which broadcasts accepted with Serve are re-announced
+	// downstream, and under which paths. If nil, every broadcast is relayed
+	// under its own path.
+	Policy *Policy
+}
+
+// cacheGroups returns the configured cache size or the default (8).
+func (c *Config) cacheGroups() int {
+	if c != nil && c.CacheGroups > 0 {
+		return c.CacheGroups
+	}
+	return 8
+}
+
+// cacheTTL returns the configured cache TTL or the default (10s).
+func (c *Config) cacheTTL() time.Duration {
+	if c != nil && c.CacheTTL > 0 {
+		return c.CacheTTL
+	}
+	return 10 * time.Second
+}
+
+// logger returns the configured logger, or nil.
+func (c *Config) logger() *slog.Logger {
+	if c != nil {
+		return c.Logger
+	}
+	return nil
+}
+
+// policy returns the configured policy, or nil.
+func (c *Config) policy() *Policy {
+	if c != nil {
+		return c.Policy
+	}
+	return nil
+}
+
+// Relay fans tracks of upstream sessions out to downstream subscribers.
+//
+// Each track is subscribed upstream once while it has downstream
+// subscribers. Received groups are cached and written to every subscriber;
+// the upstream subscription is closed when the last subscriber leaves.
+//
+// Tracks of broadcasts announced with a moqt.BroadcastID are keyed by their
+// moqt.TrackID instead of by upstream session and path. They share one
+// upstream subscription whichever session announced them, and when it ends,
+// their cached groups are kept for the cache TTL and served to the next
+// subscription of the same track, such as after the publisher reconnects.
+//
+// All methods are safe for concurrent use.
+type Relay struct {
+	mux        *moqt.TrackMux
+	configured int
+	ttl        time.Duration
+	logger     *slog.Logger
+	policy     *Policy
+
+	mu     sync.Mutex
+	size   int
+	tracks map[trackKey]*relayTrack
+	// retired holds the caches of ended tracks with a global ID until their
+	// groups expire.
+	retired map[moqt.TrackID]*trackCache
+
+	// local holds the active announcements relayed with Serve, and fleets
+	// the fleets joined with Join.
+	local  map[*moqt.Announcement]struct{}
+	fleets map[*fleetMember]struct{}
+}
+
+// trackKey identifies a relayed track by its global ID or, if it has none,
+// by its upstream and path.
+type trackKey struct {
+	id       moqt.TrackID
+	upstream Upstream
+	path     moqt.BroadcastPath
+	name     moqt.TrackName
+}
+
+// newTrackKey returns the key of a track served from upstream.
+// broadcast is the global ID of its broadcast, or zero.
+func newTrackKey(upstream Upstream, broadcast moqt.BroadcastID, path moqt.BroadcastPath, name moqt.TrackName) trackKey {
+	if broadcast.IsZero() {
+		return trackKey{upstream: upstream, path: path, name: name}
+	}
+	return trackKey{id: moqt.NewTrackID(broadcast, path, name)}
+}
+
+// relayTrack is an upstream subscription shared by downstream subscribers.
+type relayTrack struct {
+	key      trackKey
+	upstream Upstream
+	path     moqt.BroadcastPath
+	name     moqt.TrackName
+	cache    *trackCache
+
+	// served is the path the track is served under downstream, which
+	// differs from path if a Policy rewrote it.
+	served moqt.BroadcastPath
+
+	// ready is closed once the upstream subscription has been attempted.
+	// reader and err are set before ready is closed.
+	ready  chan struct{}
+	reader *moqt.TrackReader
+	err    error
+
+	// subscribers is guarded by Relay.mu.
+	subscribers int
+}
+
+// New returns a Relay that announces relayed broadcasts on mux.
+// If mux is nil, moqt.DefaultMux is used.
+func New(mux *moqt.TrackMux, config *Config) *Relay {
+	if mux == nil {
+		mux = moqt.DefaultMux
+	}
+	return &Relay{
+		mux:        mux,
+		configured: config.cacheGroups(),
+		size:       config.cacheGroups(),
+		ttl:        config.cacheTTL(),
+		logger:     config.logger(),
+		policy:     config.policy(),
+		tracks:     make(map[trackKey]*relayTrack),
+		retired:    make(map[moqt.TrackID]*trackCache),
+		local:      make(map[*moqt.Announcement]struct{}),
+		fleets:     make(map[*fleetMember]struct{}),
+	}
+}
+
+// Serve accepts the announcements of sess under prefix and announces them on
+// the relay's mux with a handler that relays from sess, as allowed and
+// rewritten by Config.Policy. It returns nil when ctx is canceled or the
+// announce stream ends, and an error if the announce stream cannot be opened.
+func (r *Relay) Serve(ctx context.Context, sess *moqt.Session, prefix string) error {
+	return r.ServePolicy(ctx, sess, prefix, r.policy)
+}
+
+// ServePolicy is like Serve, but applies policy instead of Config.Policy,
+// such as a policy that prefixes the paths of a tenant's session with the
+// tenant's namespace. A nil policy relays every broadcast under its own path.
+func (r *Relay) ServePolicy(ctx context.Context, sess *moqt.Session, prefix string, policy *Policy) error {
+	anns, err := sess.AcceptAnnounce(prefix)
+	if err != nil {
+		return err
+	}
+	defer anns.Close()
+
+	for ann := range anns.Announcements(ctx) {
+		if !ann.IsActive() {
+			continue
+		}
+		path, ok := policy.Route(ann)
+		if !ok {
+			continue
+		}
+		if path == ann.BroadcastPath() {
+			r.mux.Announce(ann, r.handler(sess, ann.BroadcastID()))
+			r.addLocal(ann)
+			continue
+		}
+
+		alias, _ := ann.Alias(path)
+		r.mux.Announce(alias, r.aliasHandler(sess, ann.BroadcastID(), ann.BroadcastPath()))
+		r.addLocal(alias)
+	}
+	return nil
+}
+
+// Handler returns a moqt.TrackHandler that serves subscriptions from the
+// cache of the matching upstream track, subscribing to it on first use.
+func (r *Relay) Handler(upstream Upstream) moqt.TrackHandler {
+	return r.handler(upstream, moqt.BroadcastID{})
+}
+
+func (r *Relay) handler(upstream Upstream, broadcast moqt.BroadcastID) moqt.TrackHandler {
+	return r.aliasHandler(upstream, broadcast, "")
+}
+
+// aliasHandler is like handler, but subscribes upstream to the broadcast at
+// path instead of the path of the subscription, unless path is "".
+func (r *Relay) aliasHandler(upstream Upstream, broadcast moqt.BroadcastID, path moqt.BroadcastPath) moqt.TrackHandler {
+	return moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		from := path
+		if from == "" {
+			from = tw.BroadcastPath
+		}
+		t := r.acquire(newTrackKey(upstream, broadcast, from, tw.TrackName), upstream, from, tw.TrackName, tw.BroadcastPath, tw.TrackConfig().Parameters)
+		defer r.release(t)
+		r.serveTrack(t, tw)
+	})
+}
+
+// Pull serves subscriptions to broadcast paths matching pattern that have no
+// local publisher by subscribing to them on origin, typically an *Origin.
+// As with Serve, each track is subscribed upstream once while it has
+// downstream subscribers. Pull registers a moqt.TrackMux route, so pattern
+// follows the syntax of TrackMux.Route; use "/{path...}" to pull every path.
+// The route is removed when ctx is canceled.
+func (r *Relay) Pull(ctx context.Context, pattern string, origin Upstream) {
+	r.mux.Route(ctx, pattern, r.Handler(origin))
+}
+
+// CachedGroups returns the number of groups currently cached for the track
+// of a broadcast without a global ID, or 0 if the track is not relayed.
+func (r *Relay) CachedGroups(upstream Upstream, path moqt.BroadcastPath, name moqt.TrackName) int {
+	return r.cachedGroups(trackKey{upstream: upstream, path: path, name: name})
+}
+
+// CachedTrackGroups returns the number of groups currently cached for the
+// track with the given global ID, including those kept after its upstream
+// subscription ended, or 0 if there are none.
+func (r *Relay) CachedTrackGroups(id moqt.TrackID) int {
+	if n := r.cachedGroups(trackKey{id: id}); n > 0 {
+		return n
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c := r.retired[id]; c != nil {
+		return c.len()
+	}
+	return 0
+}
+
+// SetCacheGroups changes the number of recent groups cached per track, for
+// relayed tracks and those relayed later, evicting the oldest groups of
+// caches that hold more. If n is zero or negative, Config.CacheGroups is
+// restored. Lowering it frees memory under pressure at the cost of new
+// subscribers starting with fewer cached groups.
+func (r *Relay) SetCacheGroups(n int) {
+	if n <= 0 {
+		n = r.configured
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.size = n
+	for _, t := range r.tracks {
+		t.cache.resize(n)
+	}
+	for _, c := range r.retired {
+		c.resize(n)
+	}
+}
+
+func (r *Relay) cachedGroups(key trackKey) int {
+	r.mu.Lock()
+	t := r.tracks[key]
+	r.mu.Unlock()
+	if t == nil {
+		return 0
+	}
+	return t.cache.len()
+}
+
+func (r *Relay) serveTrack(t *relayTrack, tw *moqt.TrackWriter) {
+	<-t.ready
+	if t.err != nil {
+		code := moqt.SubscribeErrorCodeInternal
+		if subErr, ok := errors.AsType[*moqt.SubscribeError](t.err); ok {
+			code = subErr.SubscribeErrorCode()
+		}
+		tw.CloseWithError(code)
+		return
+	}
+
+	// Groups are written concurrently; wait for them before returning
+	// because the session closes the writer when the handler returns.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Delivery is paused while the subscriber is not visible, and resumes
+	// with the latest group once it is.
+	resume := make(chan struct{}, 1)
+	stop := tw.OnUpdate(func(config *moqt.SubscribeConfig) {
+		if config.Visibility == moqt.VisibilityVisible {
+			select {
+			case resume <- struct{}{}:
+			default:
+			}
+		}
+	})
+	defer stop()
+
+	// A subscription for an absolute range starts with the cached groups
+	// of the range, and ends after its last group. One starting from a time
+	// starts with the cached group declared at that time, if any.
+	config := tw.TrackConfig()
+	after := t.cache.start(config.Start)
+	if !config.StartTime.IsZero() || config.StartBehind > 0 {
+		if i, ok := t.cache.startAt(config.StartTime, config.StartBehind); ok {
+			after = i
+		}
+	}
+	if config.StartGroup != moqt.MinGroupSequence {
+		after = 0
+	}
+	for {
+		if tw.TrackConfig().Visibility != moqt.VisibilityVisible {
+			select {
+			case <-resume:
+				after = max(after, t.cache.latest())
+				continue
+			case <-tw.Context().Done():
+				return
+			}
+		}
+
+		g, err := t.cache.next(tw.Context(), after)
+		if err != nil {
+			if errors.Is(err, errCacheClosed) {
+				wg.Wait()
+				_ = tw.Close()
+			}
+			return
+		}
+		config := tw.TrackConfig()
+		if config.Visibility != moqt.VisibilityVisible {
+			continue
+		}
+		after = g.index
+		if config.StartGroup != moqt.MinGroupSequence && g.seq < config.StartGroup {
+			continue
+		}
+		if config.EndGroup != moqt.MinGroupSequence && g.seq > config.EndGroup {
+			// The range is over, also if its last group was not cached.
+			wg.Wait()
+			_ = tw.Close()
+			return
+		}
+
+		// Groups are opened in order, so that a range does not end before
+		// its earlier groups are opened, and copied concurrently.
+		gw, err := openGroup(tw, g)
+		if err != nil {
+			continue
+		}
+		wg.Go(func() {
+			copyGroup(gw, g)
+		})
+	}
+}
+
+// openGroup opens the group or subgroup of g on tw, with the time declared
+// for the group and its extension headers setting. Groups of tw are opened
+// by a single goroutine, so the setting applies to g only.
+func openGroup(tw *moqt.TrackWriter, g *group) (*moqt.GroupWriter, error) {
+	if err := tw.SetExtensionHeaders(g.ext); err != nil {
+		return nil, err
+	}
+	if g.subgroup != 0 {
+		return tw.OpenSubgroupAt(g.seq, g.subgroup)
+	}
+	return tw.OpenGroupAtTime(g.seq, g.timestamp)
+}
+
+// copyGroup writes the frames of g to gw as they are received and closes
+// gw, or cancels it if g is aborted upstream.
+func copyGroup(gw *moqt.GroupWriter, g *group) {
+	for i := 0; ; i++ {
+		frame, err := g.frame(gw.Context(), i)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				_ = gw.Close()
+			} else {
+				gw.CancelWrite(moqt.PublishAbortedErrorCode)
+			}
+			return
+		}
+
+		if err := gw.WriteFrame(frame); err != nil {
+			return
+		}
+	}
+}
+
+// ServeFetch implements moqt.FetchHandler. It serves FETCH requests for
+// groups of relayed tracks from the cache, so that subscribers can catch up
+// on past groups without reaching the upstream. A group that is still being
+// received is served as it arrives. Groups that are not cached are canceled
+// with moqt.OutOfRangeErrorCode.
+func (r *Relay) ServeFetch(w *moqt.GroupWriter, req *moqt.FetchRequest) {
+	g := r.cachedGroup(req.BroadcastPath, req.TrackName, req.GroupSequence)
+	if g == nil {
+		w.CancelWrite(moqt.OutOfRangeErrorCode)
+		return
+	}
+	w.SetExtensionHeaders(g.ext)
+	copyGroup(w, g)
+}
+
+// cachedGroup returns the cached group seq of a relayed track, or nil.
+func (r *Relay) cachedGroup(path moqt.BroadcastPath, name moqt.TrackName, seq moqt.GroupSequence) *group {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tracks {
+		if t.served != path || t.name != name {
+			continue
+		}
+		if g := t.cache.find(seq, time.Now()); g != nil {
+			return g
+		}
+	}
+	return nil
+}
+
+// acquire returns the relayed track for key, subscribing to path and name
+// on upstream if no subscriber holds it yet. served is the path the track is
+// served under downstream. The extension parameters of the subscriber that
+// caused the upstream subscription are forwarded unmodified.
+func (r *Relay) acquire(key trackKey, upstream Upstream, path moqt.BroadcastPath, name moqt.TrackName, served moqt.BroadcastPath, params moqt.Parameters) *relayTrack {
+	r.mu.Lock()
+	t, ok := r.tracks[key]
+	if ok {
+		t.subscribers++
+		r.mu.Unlock()
+		return t
+	}
+	t = &relayTrack{
+		key:         key,
+		upstream:    upstream,
+		path:        path,
+		name:        name,
+		served:      served,
+		cache:       newTrackCache(r.size, r.ttl),
+		ready:       make(chan struct{}),
+		subscribers: 1,
+	}
+	if retired, ok := r.retired[key.id]; ok && key.id != (moqt.TrackID{}) {
+		delete(r.retired, key.id)
+		t.cache.seed(retired)
+	}
+	r.tracks[key] = t
+	r.mu.Unlock()
+
+	var config *moqt.SubscribeConfig
+	if len(params) > 0 {
+		config = &moqt.SubscribeConfig{Parameters: params.Clone()}
+	}
+	reader, err := upstream.Subscribe(context.Background(), path, name, config)
+	t.reader, t.err = reader, err
+	close(t.ready)
+
+	if err != nil {
+		r.logError("failed to subscribe upstream", err, "broadcast_path", path, "track_name", name)
+		r.remove(t)
+		return t
+	}
+
+	go r.receive(t)
+	return t
+}
+
+// release drops a subscriber of t and closes the upstream subscription
+// when none is left.
+func (r *Relay) release(t *relayTrack) {
+	r.mu.Lock()
+	t.subscribers--
+	last := t.subscribers == 0
+	if last && r.tracks[t.key] == t {
+		delete(r.tracks, t.key)
+		r.retire(t)
+	}
+	r.mu.Unlock()
+
+	if last && t.reader != nil {
+		_ = t.reader.Close()
+	}
+}
+
+// remove forgets t so that later subscribers subscribe upstream again.
+func (r *Relay) remove(t *relayTrack) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tracks[t.key] == t {
+		delete(r.tracks, t.key)
+		r.retire(t)
+	}
+}
+
+// retire keeps the cache of t, which has just been forgotten, for the next
+// subscription of its track if the track has a global ID, and drops retired
+// caches whose groups have expired. r.mu must be held.
+func (r *Relay) retire(t *relayTrack) {
+	now := time.Now()
+	for id, c := range r.retired {
+		if !c.live(now) {
+			delete(r.retired, id)
+		}
+	}
+	if t.key.id != (moqt.TrackID{}) && t.cache.live(now) {
+		r.retired[t.key.id] = t.cache
+	}
+}
+
+// receive caches the groups of the upstream subscription until it ends.
+func (r *Relay) receive(t *relayTrack) {
+	defer t.cache.close()
+	defer r.remove(t)
+
+	for {
+		gr, err := t.reader.AcceptGroup(context.Background())
+		if err != nil {
+			return
+		}
+
+		g := t.cache.addReceived(gr, time.Now())
+		go receiveGroup(gr, g)
+	}
+}
+
+// receiveGroup copies the frames of gr into g.
+func receiveGroup(gr *moqt.GroupReader, g *group) {
+	frame := moqt.NewFrame(0)
+	for {
+		if err := gr.ReadFrame(frame); err != nil {
+			g.finish(!errors.Is(err, io.EOF))
+			return
+		}
+		g.append(frame)
+	}
+}
+
+func (r *Relay) logError(msg string, err error, args ...any) {
+	if r.logger != nil {
+		r.logger.Error(msg, append(args, "error", err)...)
+	}
+}
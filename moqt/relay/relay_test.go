@@ -0,0 +1,551 @@
+package relay
+
+import (
+	"context"
+	"io"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Defaults(t *testing.T) {
+	tests := map[string]struct {
+		config   *Config
+		wantSize int
+		wantTTL  time.Duration
+	}{
+		"nil config": {
+			config:   nil,
+			wantSize: 8,
+			wantTTL:  10 * time.Second,
+		},
+		"zero config": {
+			config:   &Config{},
+			wantSize: 8,
+			wantTTL:  10 * time.Second,
+		},
+		"custom config": {
+			config:   &Config{CacheGroups: 2, CacheTTL: time.Second},
+			wantSize: 2,
+			wantTTL:  time.Second,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.wantSize, tt.config.cacheGroups())
+			assert.Equal(t, tt.wantTTL, tt.config.cacheTTL())
+			assert.Nil(t, tt.config.logger())
+		})
+	}
+}
+
+func TestNew_DefaultMux(t *testing.T) {
+	r := New(nil, nil)
+	assert.Same(t, moqt.DefaultMux, r.mux)
+}
+
+func TestRelay_SetCacheGroups(t *testing.T) {
+	r := New(moqt.NewTrackMux(moqt.NewHopID()), &Config{CacheGroups: 4})
+	key := trackKey{path: "/live", name: "video"}
+	cache := newTrackCache(r.size, r.ttl)
+	now := time.Now()
+	for seq := range moqt.GroupSequence(4) {
+		cache.add(seq+1, now)
+	}
+	r.tracks[key] = &relayTrack{key: key, cache: cache}
+
+	r.SetCacheGroups(2)
+	assert.Equal(t, 2, cache.len())
+	assert.Equal(t, 2, r.size)
+
+	r.SetCacheGroups(0)
+	assert.Equal(t, 4, r.size)
+}
+
+func TestRelay_FanOut(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{CacheGroups: 4, CacheTTL: time.Minute})
+	addr := startRelayServer(t, mux, r)
+
+	var upstreamSubscriptions atomic.Int32
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.Publish(t.Context(), "/live/cam", newTestPublisher(&upstreamSubscriptions))
+	moqttest.Dial(t, addr, pubMux)
+
+	const subscribers = 3
+	sessions := make([]*moqt.Session, 0, subscribers+1)
+	readers := make([]*moqt.TrackReader, 0, subscribers)
+	for range subscribers {
+		sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+		sessions = append(sessions, sub)
+		readers = append(readers, subscribeRelay(t, sub, "/live/cam", "video"))
+	}
+
+	for _, tr := range readers {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		gr, err := tr.AcceptGroup(ctx)
+		cancel()
+		require.NoError(t, err)
+
+		frame := moqt.NewFrame(0)
+		require.NoError(t, gr.ReadFrame(frame))
+		assert.Equal(t, []byte("hello"), frame.Body())
+	}
+
+	assert.Equal(t, int32(1), upstreamSubscriptions.Load(), "track must be subscribed upstream once")
+
+	// A late joiner starts with the cached groups.
+	require.Eventually(t, func() bool {
+		return r.CachedGroups(firstUpstream(r), "/live/cam", "video") >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	late := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	sessions = append(sessions, late)
+	tr := subscribeRelay(t, late, "/live/cam", "video")
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	_, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), upstreamSubscriptions.Load(), "late joiner must not subscribe upstream")
+
+	// The upstream subscription ends with the last downstream subscriber.
+	for _, sess := range sessions {
+		require.NoError(t, sess.CloseWithError(moqt.NoError, ""))
+	}
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.tracks) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelay_ParametersPassThrough(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{CacheGroups: 4, CacheTTL: time.Minute})
+	addr := startRelayServer(t, mux, r)
+
+	got := make(chan moqt.Parameters, 1)
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.PublishFunc(t.Context(), "/live/cam", func(tw *moqt.TrackWriter) {
+		got <- tw.TrackConfig().Parameters
+		newPayloadPublisher("hello").ServeTrack(tw)
+	})
+	moqttest.Dial(t, addr, pubMux)
+
+	// The relay knows no parameter type, so the value reaches the
+	// publisher as sent.
+	quality := moqt.StringParameter(0x21, "quality")
+	config := &moqt.SubscribeConfig{}
+	quality.Set(&config.Parameters, "hd")
+	config.Parameters[0x3f] = []byte{0xde, 0xad}
+
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	require.Eventually(t, func() bool {
+		_, err := sub.Subscribe(t.Context(), "/live/cam", "video", config)
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	select {
+	case params := <-got:
+		assert.Equal(t, config.Parameters, params)
+		v, ok := quality.Get(params)
+		assert.True(t, ok)
+		assert.Equal(t, "hd", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream was not subscribed")
+	}
+}
+
+func TestRelay_ExtensionHeadersPassThrough(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{CacheGroups: 4, CacheTTL: time.Minute})
+	addr := startRelayServer(t, mux, r)
+
+	captureTime := moqt.VarintParameter(0x02, "capture-time")
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.PublishFunc(t.Context(), "/live/cam", func(tw *moqt.TrackWriter) {
+		tw.SetExtensionHeaders(true)
+		for seq := uint64(1); ; seq++ {
+			gw, err := tw.OpenGroup()
+			if err != nil {
+				return
+			}
+			frame := moqt.NewFrame(5)
+			_, _ = frame.Write([]byte("hello"))
+			var ext moqt.ExtensionHeaders
+			captureTime.Set(&ext, 1000+seq)
+			frame.SetExtensions(ext)
+			err = gw.WriteFrame(frame)
+			_ = gw.Close()
+			if err != nil {
+				return
+			}
+
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-tw.Context().Done():
+				return
+			}
+		}
+	})
+	moqttest.Dial(t, addr, pubMux)
+
+	// The relay forwards the headers and the group flag announcing them, so
+	// the subscriber strips them without configuration.
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	tr := subscribeRelay(t, sub, "/live/cam", "video")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	gr, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+
+	frame := moqt.NewFrame(0)
+	require.NoError(t, gr.ReadFrame(frame))
+	assert.Equal(t, "hello", string(frame.Body()))
+	v, ok := captureTime.Get(frame.Extensions())
+	assert.True(t, ok)
+	assert.Greater(t, v, uint64(1000))
+}
+
+func TestRelay_Visibility(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{CacheGroups: 4, CacheTTL: time.Minute})
+	addr := startRelayServer(t, mux, r)
+
+	var upstreamSubscriptions atomic.Int32
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.Publish(t.Context(), "/live/cam", newTestPublisher(&upstreamSubscriptions))
+	moqttest.Dial(t, addr, pubMux)
+
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	tr := subscribeRelay(t, sub, "/live/cam", "video")
+	accept := func(timeout time.Duration) (*moqt.GroupReader, error) {
+		ctx, cancel := context.WithTimeout(t.Context(), timeout)
+		defer cancel()
+		return tr.AcceptGroup(ctx)
+	}
+	gr, err := accept(5 * time.Second)
+	require.NoError(t, err)
+
+	// Groups stop once the groups in flight are received.
+	require.NoError(t, tr.UpdateVisibility(moqt.VisibilityHidden))
+	last := gr.GroupSequence()
+	for {
+		gr, err := accept(200 * time.Millisecond)
+		if err != nil {
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+			break
+		}
+		last = max(last, gr.GroupSequence())
+	}
+
+	// The subscription resumes with the latest group, skipping the groups
+	// published while it was hidden.
+	require.NoError(t, tr.UpdateVisibility(moqt.VisibilityVisible))
+	gr, err = accept(5 * time.Second)
+	require.NoError(t, err)
+	assert.Greater(t, gr.GroupSequence(), last+1)
+	assert.Equal(t, int32(1), upstreamSubscriptions.Load(), "the upstream subscription must be kept")
+}
+
+func TestRelay_Range(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{CacheGroups: 1000, CacheTTL: time.Minute})
+	addr := startRelayServer(t, mux, r)
+
+	var upstreamSubscriptions atomic.Int32
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.Publish(t.Context(), "/live/cam", newTestPublisher(&upstreamSubscriptions))
+	moqttest.Dial(t, addr, pubMux)
+
+	// Cache the groups of the range.
+	subscribeRelay(t, moqttest.Dial(t, addr, moqt.NewTrackMux(0)), "/live/cam", "video")
+	require.Eventually(t, func() bool {
+		return r.CachedGroups(firstUpstream(r), "/live/cam", "video") >= 4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	tr, err := sub.Subscribe(t.Context(), "/live/cam", "video", &moqt.SubscribeConfig{StartGroup: 2, EndGroup: 3})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var got []moqt.GroupSequence
+	for {
+		gr, err := tr.AcceptGroup(ctx)
+		if err != nil {
+			require.ErrorIs(t, err, io.EOF)
+			break
+		}
+		got = append(got, gr.GroupSequence())
+	}
+	assert.ElementsMatch(t, []moqt.GroupSequence{2, 3}, got)
+}
+
+func TestRelay_StartTime(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{CacheGroups: 1000, CacheTTL: time.Minute})
+	addr := startRelayServer(t, mux, r)
+
+	// The publisher declares group seq at base+seq seconds.
+	base := time.UnixMicro(1_700_000_000_000_000)
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.Publish(t.Context(), "/live/cam", moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		for seq := range moqt.GroupSequence(10) {
+			gw, err := tw.OpenGroupAtTime(seq+1, base.Add(time.Duration(seq+1)*time.Second))
+			if err != nil {
+				return
+			}
+			frame := moqt.NewFrame(5)
+			_, _ = frame.Write([]byte("hello"))
+			_ = gw.WriteFrame(frame)
+			_ = gw.Close()
+			time.Sleep(10 * time.Millisecond)
+		}
+		<-tw.Context().Done()
+	}))
+	moqttest.Dial(t, addr, pubMux)
+
+	subscribeRelay(t, moqttest.Dial(t, addr, moqt.NewTrackMux(0)), "/live/cam", "video")
+	require.Eventually(t, func() bool {
+		return r.CachedGroups(firstUpstream(r), "/live/cam", "video") >= 10
+	}, 5*time.Second, 10*time.Millisecond)
+
+	tests := map[string]struct {
+		config  *moqt.SubscribeConfig
+		wantSeq moqt.GroupSequence
+	}{
+		"start time": {
+			config:  &moqt.SubscribeConfig{StartTime: base.Add(5500 * time.Millisecond)},
+			wantSeq: 5,
+		},
+		"start behind": {
+			config:  &moqt.SubscribeConfig{StartBehind: 3 * time.Second},
+			wantSeq: 7,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+			tr, err := sub.Subscribe(t.Context(), "/live/cam", "video", tt.config)
+			require.NoError(t, err)
+			defer tr.Close()
+
+			// The cached groups from wantSeq are sent, newest first.
+			ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+			defer cancel()
+			var got []moqt.GroupSequence
+			for seq := tt.wantSeq; seq <= 10; seq++ {
+				gr, err := tr.AcceptGroup(ctx)
+				require.NoError(t, err)
+				assert.True(t, base.Add(time.Duration(gr.GroupSequence())*time.Second).Equal(gr.Timestamp()))
+				got = append(got, gr.GroupSequence())
+			}
+			assert.Equal(t, tt.wantSeq, slices.Min(got))
+		})
+	}
+}
+
+func TestRelay_BroadcastIDSurvivesReconnect(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{CacheGroups: 4, CacheTTL: time.Minute})
+	addr := startRelayServer(t, mux, r)
+
+	id := moqt.NewBroadcastID()
+	trackID := moqt.NewTrackID(id, "/live/cam", "video")
+	publish := func(payload string) *moqt.Session {
+		pubMux := moqt.NewTrackMux(0)
+		ann, _ := moqt.NewAnnouncementWithID(t.Context(), "/live/cam", id)
+		pubMux.Announce(ann, newPayloadPublisher(payload))
+		return moqttest.Dial(t, addr, pubMux)
+	}
+	firstPayload := func(tr *moqt.TrackReader) string {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		gr, err := tr.AcceptGroup(ctx)
+		require.NoError(t, err)
+		frame := moqt.NewFrame(0)
+		require.NoError(t, gr.ReadFrame(frame))
+		return string(frame.Body())
+	}
+
+	first := publish("first")
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	assert.Equal(t, "first", firstPayload(subscribeRelay(t, sub, "/live/cam", "video")))
+	require.Eventually(t, func() bool {
+		return r.CachedTrackGroups(trackID) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The cache outlives the publisher session.
+	require.NoError(t, first.CloseWithError(moqt.NoError, ""))
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.tracks) == 0 && r.retired[trackID] != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// After the publisher reconnects, a new subscriber starts with the
+	// groups cached from the previous session.
+	publish("second")
+	late := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	assert.Equal(t, "first", firstPayload(subscribeRelay(t, late, "/live/cam", "video")))
+}
+
+func TestRelay_ServeFetch(t *testing.T) {
+	mux := moqt.NewTrackMux(0)
+	r := New(mux, &Config{CacheGroups: 4, CacheTTL: time.Minute})
+	addr := startServerWithFetch(t, mux, moqt.HandleFunc(func(sess *moqt.Session) {
+		_ = r.Serve(sess.Context(), sess, "/")
+		<-sess.Context().Done()
+	}), r)
+
+	var upstreamSubscriptions atomic.Int32
+	pubMux := moqt.NewTrackMux(0)
+	pubMux.Publish(t.Context(), "/live/cam", newTestPublisher(&upstreamSubscriptions))
+	moqttest.Dial(t, addr, pubMux)
+
+	sub := moqttest.Dial(t, addr, moqt.NewTrackMux(0))
+	tr := subscribeRelay(t, sub, "/live/cam", "video")
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	live, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return r.CachedGroups(firstUpstream(r), "/live/cam", "video") >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Catch up on the cached group while the subscription continues.
+	var got []moqt.GroupSequence
+	req := &moqt.FetchRequest{BroadcastPath: "/live/cam", TrackName: "video", GroupSequence: live.GroupSequence()}
+	for gr, err := range sub.FetchGroups(ctx, req, live.GroupSequence()+1) {
+		require.NoError(t, err)
+		frame := moqt.NewFrame(0)
+		require.NoError(t, gr.ReadFrame(frame))
+		assert.Equal(t, []byte("hello"), frame.Body())
+		got = append(got, gr.GroupSequence())
+	}
+	assert.Equal(t, []moqt.GroupSequence{live.GroupSequence(), live.GroupSequence() + 1}, got)
+
+	// Groups that are not cached are out of range.
+	gr, err := sub.Fetch((&moqt.FetchRequest{BroadcastPath: "/live/cam", TrackName: "video", GroupSequence: 1 << 40}).WithContext(ctx))
+	require.NoError(t, err)
+	var grpErr *moqt.GroupError
+	require.ErrorAs(t, gr.ReadFrame(moqt.NewFrame(0)), &grpErr)
+	assert.Equal(t, moqt.OutOfRangeErrorCode, grpErr.GroupErrorCode())
+	assert.Equal(t, int32(1), upstreamSubscriptions.Load())
+}
+
+// newPayloadPublisher returns a handler that writes a group with payload
+// every 10ms until the subscription ends.
+func newPayloadPublisher(payload string) moqt.TrackHandler {
+	return moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		for {
+			gw, err := tw.OpenGroup()
+			if err != nil {
+				return
+			}
+			frame := moqt.NewFrame(len(payload))
+			_, _ = frame.Write([]byte(payload))
+			err = gw.WriteFrame(frame)
+			_ = gw.Close()
+			if err != nil {
+				return
+			}
+
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-tw.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+// newTestPublisher returns a handler that writes a "hello" group every 10ms
+// until the subscription ends and counts how often it is subscribed.
+func newTestPublisher(subscriptions *atomic.Int32) moqt.TrackHandler {
+	return moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		subscriptions.Add(1)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			gw, err := tw.OpenGroup()
+			if err != nil {
+				return
+			}
+			frame := moqt.NewFrame(5)
+			_, _ = frame.Write([]byte("hello"))
+			err = gw.WriteFrame(frame)
+			_ = gw.Close()
+			if err != nil {
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-tw.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+// firstUpstream returns the upstream of any relayed track.
+func firstUpstream(r *Relay) Upstream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.tracks {
+		return key.upstream
+	}
+	return nil
+}
+
+func startRelayServer(t *testing.T, mux *moqt.TrackMux, r *Relay) string {
+	t.Helper()
+
+	return startServer(t, mux, moqt.HandleFunc(func(sess *moqt.Session) {
+		_ = r.Serve(sess.Context(), sess, "/")
+		<-sess.Context().Done()
+	}))
+}
+
+func startServer(t *testing.T, mux *moqt.TrackMux, handler moqt.Handler) string {
+	t.Helper()
+
+	return startServerWithFetch(t, mux, handler, nil)
+}
+
+func startServerWithFetch(t *testing.T, mux *moqt.TrackMux, handler moqt.Handler, fetch moqt.FetchHandler) string {
+	t.Helper()
+
+	return moqttest.Serve(t, &moqt.Server{
+		TrackMux:            mux,
+		DisableWebTransport: true,
+		Handler:             handler,
+		FetchHandler:        fetch,
+	})
+}
+
+// subscribeRelay subscribes to the track once the relay has learned about
+// its broadcast.
+func subscribeRelay(t *testing.T, sess *moqt.Session, path moqt.BroadcastPath, name moqt.TrackName) *moqt.TrackReader {
+	t.Helper()
+
+	var tr *moqt.TrackReader
+	require.Eventually(t, func() bool {
+		var err error
+		tr, err = sess.Subscribe(t.Context(), path, name, nil)
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	return tr
+}
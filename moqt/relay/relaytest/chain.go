@@ -0,0 +1,191 @@
+package relaytest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/moqt/internal/quicgo"
+	"github.com/qumo-dev/gomoqt/moqt/relay"
+)
+
+// setupTimeout bounds the setup of the sessions and subscriptions of a
+// Chain, including the retries while announcements propagate.
+const setupTimeout = 10 * time.Second
+
+// Chain is a publisher, a chain of relays and their subscribers, connected
+// over a simulated Network:
+//
+//	publisher → relay 1 → … → relay N → subscribers
+//
+// Each relay relays every broadcast of the session to the previous node, and
+// subscribers connect to the last relay.
+type Chain struct {
+	// Network carries the sessions of the chain.
+	Network *Network
+
+	// Publisher is the mux of the publisher session: the broadcasts
+	// published on it are relayed along the chain.
+	Publisher *moqt.TrackMux
+
+	// Relays are the relays, from the one next to the publisher.
+	Relays []*relay.Relay
+
+	tb        testing.TB
+	tlsConfig *tls.Config
+	// edge is the address of the last relay.
+	edge string
+}
+
+// NewChain starts a chain of len(hops) relays with config. hops[0] is the
+// link between the publisher and the first relay, and hops[i] the link
+// between relay i and relay i+1. The chain is torn down when tb ends.
+func NewChain(tb testing.TB, config *relay.Config, hops ...Link) *Chain {
+	tb.Helper()
+	if len(hops) == 0 {
+		tb.Fatal("relaytest: a chain needs at least one hop")
+	}
+
+	c := &Chain{
+		Network:   &Network{},
+		Publisher: moqt.NewTrackMux(moqt.NewHopID()),
+		tb:        tb,
+		tlsConfig: testutil.TLSConfig(tb),
+	}
+
+	var upstream string
+	for i, hop := range hops {
+		mux := moqt.NewTrackMux(moqt.NewHopID())
+		r := relay.New(mux, config)
+		c.Relays = append(c.Relays, r)
+
+		addr := c.listen(mux, r, moqt.HandleFunc(func(sess *moqt.Session) {
+			if i == 0 {
+				// The publisher connects to the first relay.
+				_ = r.Serve(sess.Context(), sess, "/")
+			}
+			<-sess.Context().Done()
+		}))
+		if i == 0 {
+			c.dial(hop, addr, c.Publisher)
+		} else {
+			// The relay subscribes to the broadcasts of the previous one.
+			sess := c.dial(hop, upstream, moqt.NewTrackMux(0))
+			go func() {
+				_ = r.Serve(sess.Context(), sess, "/")
+			}()
+		}
+		upstream = addr
+	}
+	c.edge = upstream
+	return c
+}
+
+// Dial connects a subscriber to the last relay through link.
+func (c *Chain) Dial(link Link) *moqt.Session {
+	c.tb.Helper()
+	return c.dial(link, c.edge, moqt.NewTrackMux(0))
+}
+
+// Subscribe connects a subscriber to the last relay through link and
+// subscribes to a track, once its broadcast has propagated along the chain.
+func (c *Chain) Subscribe(link Link, path moqt.BroadcastPath, name moqt.TrackName, config *moqt.SubscribeConfig) *moqt.TrackReader {
+	c.tb.Helper()
+
+	sess := c.Dial(link)
+	deadline := time.Now().Add(setupTimeout)
+	for {
+		tr, err := sess.Subscribe(c.tb.Context(), path, name, config)
+		if err == nil {
+			c.tb.Cleanup(func() {
+				_ = tr.Close()
+			})
+			return tr
+		}
+		if time.Now().After(deadline) {
+			c.tb.Fatalf("relaytest: cannot subscribe to %s %s: %v", path, name, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// listen starts a server for the relay r on a new Conn of the network.
+func (c *Chain) listen(mux *moqt.TrackMux, r *relay.Relay, handler moqt.Handler) string {
+	c.tb.Helper()
+
+	conn := c.Network.Listen(Link{})
+	server := &moqt.Server{
+		Addr:                conn.LocalAddr().String(),
+		TLSConfig:           c.tlsConfig,
+		TrackMux:            mux,
+		DisableWebTransport: true,
+		Handler:             handler,
+		FetchHandler:        r,
+		ListenFunc: func(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (moqt.QUICListener, error) {
+			ln, tr, err := quicgo.ListenEarly(conn, tlsConfig, quicConfig)
+			if err != nil {
+				return nil, err
+			}
+			c.tb.Cleanup(func() {
+				_ = tr.Close()
+			})
+			return ln, nil
+		},
+	}
+	go func() {
+		_ = server.ListenAndServe()
+	}()
+	c.tb.Cleanup(func() {
+		_ = server.Close()
+		_ = conn.Close()
+	})
+	return conn.LocalAddr().String()
+}
+
+// dial connects a session serving mux to addr through link.
+func (c *Chain) dial(link Link, addr string, mux *moqt.TrackMux) *moqt.Session {
+	c.tb.Helper()
+
+	conn := c.Network.Listen(link)
+	tr := &quic.Transport{Conn: conn}
+	c.tb.Cleanup(func() {
+		_ = tr.Close()
+		_ = conn.Close()
+	})
+	dialer := &moqt.Dialer{
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (moqt.StreamConn, error) {
+			udpAddr, err := net.ResolveUDPAddr("udp", addr)
+			if err != nil {
+				return nil, err
+			}
+			qconn, err := tr.DialEarly(ctx, udpAddr, tlsConfig, quicConfig)
+			if err != nil {
+				return nil, err
+			}
+			return moqt.WrapQUICConn(qconn), nil
+		},
+	}
+
+	deadline := time.Now().Add(setupTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(c.tb.Context(), setupTimeout)
+		sess, err := dialer.Dial(ctx, "moqt://"+addr, mux)
+		cancel()
+		if err == nil {
+			c.tb.Cleanup(func() {
+				_ = sess.CloseWithError(moqt.NoError, "")
+			})
+			return sess
+		}
+		if time.Now().After(deadline) {
+			c.tb.Fatalf("relaytest: cannot dial %s: %v", addr, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,71 @@
+package relaytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	hop := 10 * time.Millisecond
+
+	tests := map[string]struct {
+		hops       []Link
+		subscriber Link
+		// minLatency is the sum of the latencies of the hops.
+		minLatency time.Duration
+	}{
+		"one relay": {
+			hops:       []Link{{Latency: hop}},
+			subscriber: Link{Latency: hop},
+			minLatency: 2 * hop,
+		},
+		"three relays": {
+			hops:       []Link{{Latency: hop}, {Latency: hop}, {Latency: hop}},
+			subscriber: Link{Latency: hop},
+			minLatency: 4 * hop,
+		},
+		"lossy middle hop": {
+			hops:       []Link{{Latency: hop}, {Latency: hop, Jitter: 5 * time.Millisecond, Loss: 0.05}, {Latency: hop}},
+			minLatency: 3 * hop,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			chain := NewChain(t, &relay.Config{CacheGroups: 32, CacheTTL: time.Minute}, tt.hops...)
+			require.Len(t, chain.Relays, len(tt.hops))
+			chain.Publisher.Publish(t.Context(), "/live", &Probe{Interval: 10 * time.Millisecond})
+			tr := chain.Subscribe(tt.subscriber, "/live", "probe", nil)
+
+			ctx, cancel := context.WithTimeout(t.Context(), 20*time.Second)
+			defer cancel()
+			stats, err := Measure(ctx, tr, 50)
+			require.NoError(t, err, stats.String())
+
+			// With enough cache, groups are delivered reliably along the chain,
+			// no faster than the network allows, and without queuing up.
+			assert.Equal(t, 50, stats.Received)
+			assert.Zero(t, stats.LossRate(), stats.String())
+			assert.GreaterOrEqual(t, stats.Percentile(0), tt.minLatency, stats.String())
+			assert.Less(t, stats.Percentile(50), tt.minLatency+time.Second, stats.String())
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	stats := Stats{
+		Received:  4,
+		Lost:      1,
+		Latencies: []time.Duration{1, 2, 3, 4},
+	}
+	assert.InDelta(t, 0.2, stats.LossRate(), 1e-9)
+	assert.Equal(t, time.Duration(1), stats.Percentile(0))
+	assert.Equal(t, time.Duration(4), stats.Percentile(100))
+	assert.Zero(t, Stats{}.LossRate())
+	assert.Zero(t, Stats{}.Percentile(50))
+}
@@ -0,0 +1,20 @@
+// Package relaytest runs chains of relays in-process over a simulated
+// network, for regression tests of relay behavior under latency and loss.
+//
+// A Network is an in-memory packet network carrying real QUIC connections,
+// with the Link conditions of each hop. NewChain wires a publisher, a chain
+// of relays and subscribers over it, a Probe publishes timestamped groups,
+// and Measure reports their end-to-end latency and loss:
+//
+//	chain := relaytest.NewChain(t, nil,
+//		relaytest.Link{Latency: 10 * time.Millisecond},
+//		relaytest.Link{Latency: 10 * time.Millisecond, Loss: 0.01},
+//	)
+//	chain.Publisher.Publish(ctx, "/live", &relaytest.Probe{})
+//	tr := chain.Subscribe(relaytest.Link{}, "/live", "probe", nil)
+//
+//	stats, err := relaytest.Measure(ctx, tr, 100)
+//	if stats.Percentile(99) > 200*time.Millisecond {
+//		t.Errorf("p99 latency: %v", stats.Percentile(99))
+//	}
+package relaytest
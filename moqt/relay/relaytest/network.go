@@ -0,0 +1,317 @@
+package relaytest
+
+import (
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// queueSize is the number of packets a Conn buffers, in each direction and
+// per peer, before dropping more like a full socket buffer.
+const queueSize = 4096
+
+// Link describes the conditions of the network on a hop.
+type Link struct {
+	// Latency is the one-way delay of every packet.
+	Latency time.Duration
+
+	// Jitter is the upper bound of a random delay added to Latency. Packets
+	// are not reordered: a packet is delivered after the ones sent before it.
+	Jitter time.Duration
+
+	// Loss is the probability, from 0 to 1, that a packet is dropped.
+	Loss float64
+}
+
+// join returns the conditions of a path through l and other.
+func (l Link) join(other Link) Link {
+	return Link{
+		Latency: l.Latency + other.Latency,
+		Jitter:  l.Jitter + other.Jitter,
+		Loss:    1 - (1-l.Loss)*(1-other.Loss),
+	}
+}
+
+// delay returns the delay of a packet.
+func (l Link) delay() time.Duration {
+	d := l.Latency
+	if l.Jitter > 0 {
+		d += rand.N(l.Jitter)
+	}
+	return d
+}
+
+// Network is an in-memory packet network. Its Conns exchange datagrams by
+// address, through the conditions of the Links of both ends, and can carry
+// QUIC connections like UDP sockets.
+//
+// The zero value is ready to use.
+type Network struct {
+	mu    sync.Mutex
+	conns map[string]*Conn
+	next  uint32
+}
+
+// Listen returns a new Conn with a unique address, whose packets, sent and
+// received, go through link.
+func (n *Network) Listen(link Link) *Conn {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.next++
+	addr := &net.UDPAddr{
+		IP:   net.IPv4(10, byte(n.next>>16), byte(n.next>>8), byte(n.next)),
+		Port: 4433,
+	}
+	c := &Conn{
+		network: n,
+		addr:    addr,
+		link:    link,
+		in:      make(chan packet, queueSize),
+		pipes:   make(map[*Conn]*pipe),
+		closed:  make(chan struct{}),
+	}
+	c.readDeadline.init()
+	if n.conns == nil {
+		n.conns = make(map[string]*Conn)
+	}
+	n.conns[addr.String()] = c
+	return c
+}
+
+func (n *Network) lookup(addr net.Addr) *Conn {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.conns[addr.String()]
+}
+
+func (n *Network) remove(c *Conn) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.conns, c.addr.String())
+}
+
+type packet struct {
+	data []byte
+	from net.Addr
+	due  time.Time
+}
+
+// Conn is an endpoint of a Network. It implements net.PacketConn.
+type Conn struct {
+	network *Network
+	addr    *net.UDPAddr
+	link    Link
+
+	in chan packet
+
+	mu    sync.Mutex
+	pipes map[*Conn]*pipe
+
+	readDeadline deadline
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ net.PacketConn = (*Conn)(nil)
+
+// ReadFrom reads the next packet delivered to c.
+func (c *Conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case p := <-c.in:
+		return copy(b, p.data), p.from, nil
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	case <-c.readDeadline.wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+// WriteTo sends b to addr. Like UDP, it does not report packets that are
+// dropped, or sent to an address without a Conn.
+func (c *Conn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	dst := c.network.lookup(addr)
+	if dst == nil {
+		return len(b), nil
+	}
+	link := c.link.join(dst.link)
+	if link.Loss > 0 && rand.Float64() < link.Loss {
+		return len(b), nil
+	}
+	c.send(dst, packet{
+		data: append([]byte(nil), b...),
+		from: c.addr,
+		due:  time.Now().Add(link.delay()),
+	})
+	return len(b), nil
+}
+
+// send queues pkt on the pipe from c to dst.
+func (c *Conn) send(dst *Conn, pkt packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pipes[dst]
+	if !ok {
+		p = &pipe{queue: make(chan packet, queueSize)}
+		c.pipes[dst] = p
+		go p.run(dst, c.closed)
+	}
+	p.send(pkt)
+}
+
+// Close closes c. Packets in flight from c are dropped.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.network.remove(c)
+	})
+	return nil
+}
+
+// LocalAddr returns the address of c on the Network.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+// SetDeadline sets the read deadline of c. Writes do not block.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the deadline of ReadFrom.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline does nothing: writes do not block.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// SetReadBuffer does nothing: the buffers of a Conn have a fixed size. It
+// lets QUIC stacks tune their sockets without warnings.
+func (c *Conn) SetReadBuffer(bytes int) error {
+	return nil
+}
+
+// SetWriteBuffer does nothing, like SetReadBuffer.
+func (c *Conn) SetWriteBuffer(bytes int) error {
+	return nil
+}
+
+// pipe delivers the packets from a Conn to another in order, each at its
+// due time or after the previous one. Its send method is called with the
+// mutex of the sending Conn held.
+type pipe struct {
+	queue chan packet
+	last  time.Time
+}
+
+func (p *pipe) send(pkt packet) {
+	if pkt.due.Before(p.last) {
+		pkt.due = p.last
+	}
+	p.last = pkt.due
+	select {
+	case p.queue <- pkt:
+	default:
+		// The queue is full.
+	}
+}
+
+func (p *pipe) run(dst *Conn, closed <-chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		var pkt packet
+		select {
+		case pkt = <-p.queue:
+		case <-closed:
+			return
+		}
+		if d := time.Until(pkt.due); d > 0 {
+			timer.Reset(d)
+			select {
+			case <-timer.C:
+			case <-closed:
+				return
+			}
+		}
+		select {
+		case dst.in <- pkt:
+		case <-dst.closed:
+		default:
+			// The receive buffer is full.
+		}
+	}
+}
+
+// deadline is a resettable deadline, like the ones of net.Pipe.
+type deadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{}
+}
+
+func (d *deadline) init() {
+	d.cancel = make(chan struct{})
+}
+
+// set sets the deadline. A zero t clears it.
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// The timer fired: wait for it to close cancel.
+		<-d.cancel
+	}
+	d.timer = nil
+
+	closed := isClosed(d.cancel)
+	if t.IsZero() {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		return
+	}
+	if dur := time.Until(t); dur > 0 {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		cancel := d.cancel
+		d.timer = time.AfterFunc(dur, func() {
+			close(cancel)
+		})
+		return
+	}
+	if !closed {
+		close(d.cancel)
+	}
+}
+
+// wait returns a channel that is closed when the deadline passes.
+func (d *deadline) wait() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
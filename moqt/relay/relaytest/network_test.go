@@ -0,0 +1,103 @@
+package relaytest
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetwork(t *testing.T) {
+	tests := map[string]struct {
+		a, b Link
+		// delivered reports whether the packets arrive.
+		delivered bool
+		minDelay  time.Duration
+	}{
+		"ideal": {
+			delivered: true,
+		},
+		"latency of both ends": {
+			a:         Link{Latency: 20 * time.Millisecond},
+			b:         Link{Latency: 10 * time.Millisecond},
+			delivered: true,
+			minDelay:  30 * time.Millisecond,
+		},
+		"jitter": {
+			a:         Link{Latency: 10 * time.Millisecond, Jitter: 10 * time.Millisecond},
+			delivered: true,
+			minDelay:  10 * time.Millisecond,
+		},
+		"loss": {
+			a: Link{Loss: 1},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var n Network
+			a, b := n.Listen(tt.a), n.Listen(tt.b)
+			defer a.Close()
+			defer b.Close()
+
+			start := time.Now()
+			for i := range byte(5) {
+				_, err := a.WriteTo([]byte{i}, b.LocalAddr())
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, b.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+			buf := make([]byte, 16)
+			for i := range byte(5) {
+				n, from, err := b.ReadFrom(buf)
+				if !tt.delivered {
+					assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+					return
+				}
+				require.NoError(t, err)
+				// Packets are not reordered.
+				assert.Equal(t, []byte{i}, buf[:n])
+				assert.Equal(t, a.LocalAddr(), from)
+			}
+			assert.GreaterOrEqual(t, time.Since(start), tt.minDelay)
+		})
+	}
+}
+
+func TestConn_Close(t *testing.T) {
+	var n Network
+	a, b := n.Listen(Link{}), n.Listen(Link{})
+	require.NoError(t, b.Close())
+
+	// Packets to a closed Conn are dropped.
+	_, err := a.WriteTo([]byte("x"), b.LocalAddr())
+	assert.NoError(t, err)
+
+	_, _, err = b.ReadFrom(make([]byte, 1))
+	assert.ErrorIs(t, err, net.ErrClosed)
+	_, err = b.WriteTo([]byte("x"), a.LocalAddr())
+	assert.ErrorIs(t, err, net.ErrClosed)
+	require.NoError(t, a.Close())
+}
+
+func TestConn_SetReadDeadline(t *testing.T) {
+	var n Network
+	c := n.Listen(Link{})
+	defer c.Close()
+
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(-time.Second)))
+	_, _, err := c.ReadFrom(make([]byte, 1))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// Clearing the deadline lets reads block again.
+	require.NoError(t, c.SetReadDeadline(time.Time{}))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = c.WriteTo([]byte("x"), c.LocalAddr())
+	}()
+	_, _, err = c.ReadFrom(make([]byte, 1))
+	assert.NoError(t, err)
+}
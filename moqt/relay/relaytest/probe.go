@@ -0,0 +1,149 @@
+package relaytest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Probe is a moqt.TrackHandler that writes a group every Interval, with one
+// frame carrying the time it was written, so that a subscriber can Measure
+// the latency of its delivery. The clocks of the processes must agree, as
+// they do in a Chain.
+type Probe struct {
+	// Interval is the time between groups. If zero, 10ms is used.
+	Interval time.Duration
+
+	// Size is the size of the frames, at least 8 bytes. Larger frames are
+	// padded.
+	Size int
+}
+
+// ServeTrack writes groups to tw until the subscription ends.
+func (p *Probe) ServeTrack(tw *moqt.TrackWriter) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		gw, err := tw.OpenGroup()
+		if err != nil {
+			return
+		}
+		frame := moqt.NewFrame(max(p.Size, 8))
+		payload := make([]byte, max(p.Size, 8))
+		binary.BigEndian.PutUint64(payload, uint64(time.Now().UnixNano()))
+		_, _ = frame.Write(payload)
+		err = gw.WriteFrame(frame)
+		_ = gw.Close()
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-tw.Context().Done():
+			return
+		}
+	}
+}
+
+// Stats are the results of Measure.
+type Stats struct {
+	// Received is the number of groups of the measured range received.
+	Received int
+
+	// Lost is the number of groups of the measured range that were not
+	// received, or were reset along the way.
+	Lost int
+
+	// Latencies are the delivery latencies of the received groups, from
+	// the time the Probe wrote them, in ascending order.
+	Latencies []time.Duration
+}
+
+// LossRate returns the share of the groups that were lost.
+func (s Stats) LossRate() float64 {
+	if s.Received+s.Lost == 0 {
+		return 0
+	}
+	return float64(s.Lost) / float64(s.Received+s.Lost)
+}
+
+// Percentile returns the p-th percentile of the latencies, for p from 0 to
+// 100, or zero if no group was received.
+func (s Stats) Percentile(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	i := int(p / 100 * float64(len(s.Latencies)-1))
+	return s.Latencies[min(max(i, 0), len(s.Latencies)-1)]
+}
+
+// String returns a summary of s.
+func (s Stats) String() string {
+	return fmt.Sprintf("received: %d, lost: %d, p50: %v, p99: %v",
+		s.Received, s.Lost, s.Percentile(50), s.Percentile(99))
+}
+
+// Measure reads the groups of a track served by a Probe and returns the
+// statistics of a range of consecutive groups, starting with the first group
+// received. Groups may arrive out of order: a group of the range that has not
+// arrived once a group as many sequences past the range arrives is counted as
+// lost. It returns an error if ctx or the track ends first.
+func Measure(ctx context.Context, tr *moqt.TrackReader, groups int) (Stats, error) {
+	var (
+		stats   Stats
+		started bool
+		first   moqt.GroupSequence
+		seen    = make(map[moqt.GroupSequence]bool)
+	)
+	finish := func() Stats {
+		stats.Lost = groups - stats.Received
+		slices.Sort(stats.Latencies)
+		return stats
+	}
+
+	frame := moqt.NewFrame(0)
+	for stats.Received < groups {
+		gr, err := tr.AcceptGroup(ctx)
+		if err != nil {
+			return finish(), err
+		}
+		seq := gr.GroupSequence()
+		if !started {
+			started = true
+			first = seq
+		}
+		if seq >= first+2*moqt.GroupSequence(groups) {
+			break
+		}
+		if seq < first || seq >= first+moqt.GroupSequence(groups) || seen[seq] {
+			continue
+		}
+		seen[seq] = true
+
+		err = gr.ReadFrame(frame)
+		received := time.Now()
+		if err != nil {
+			// The group was reset along the way: it is lost.
+			continue
+		}
+		body := frame.Body()
+		if len(body) < 8 {
+			return finish(), errors.New("relaytest: frame is not from a Probe")
+		}
+		stats.Received++
+		sent := time.Unix(0, int64(binary.BigEndian.Uint64(body)))
+		stats.Latencies = append(stats.Latencies, received.Sub(sent))
+	}
+	return finish(), nil
+}
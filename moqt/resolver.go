@@ -0,0 +1,231 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/svcb"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// A Resolver discovers the endpoints of the server of a URL, as host:port,
+// in the order in which they are tried. The endpoints returned by
+// Client.Resolver are raced as those of an EndpointPolicy, so that DNS can
+// steer clients to relays without their addresses being hardcoded.
+type Resolver interface {
+	Resolve(ctx context.Context, u *url.URL) ([]string, error)
+}
+
+// ResolverFunc is an adapter to allow the use of ordinary functions as
+// Resolvers.
+type ResolverFunc func(ctx context.Context, u *url.URL) ([]string, error)
+
+// Resolve calls f(ctx, u).
+func (f ResolverFunc) Resolve(ctx context.Context, u *url.URL) ([]string, error) {
+	return f(ctx, u)
+}
+
+// IPResolver resolves the host of a URL to its addresses, alternating
+// between IPv6 and IPv4 as Happy Eyeballs does. It is the default Resolver.
+type IPResolver struct {
+	// Resolver looks up the addresses. If nil, net.DefaultResolver is used.
+	Resolver *net.Resolver
+}
+
+// Resolve implements Resolver.
+func (r IPResolver) Resolve(ctx context.Context, u *url.URL) ([]string, error) {
+	return r.resolve(ctx, u.Hostname(), urlPort(u))
+}
+
+func (r IPResolver) resolve(ctx context.Context, host, port string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{net.JoinHostPort(host, port)}, nil
+	}
+
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]string, 0, len(addrs))
+	for _, addr := range interleaveFamilies(addrs) {
+		endpoints = append(endpoints, net.JoinHostPort(addr.String(), port))
+	}
+	return endpoints, nil
+}
+
+// SRVResolver discovers relays from the SRV records of the host of a URL,
+// _<Service>._udp.<host>. Targets are tried by priority, and by weight
+// within a priority, each at the addresses it resolves to and at the port
+// of its record.
+type SRVResolver struct {
+	// Service is the service of the records. Defaults to "moqt".
+	Service string
+
+	// Resolver looks up the records and the addresses of their targets. If
+	// nil, net.DefaultResolver is used.
+	Resolver *net.Resolver
+
+	// Fallback resolves URLs whose host has no SRV records. If nil, an
+	// IPResolver is used.
+	Fallback Resolver
+}
+
+// Resolve implements Resolver.
+func (r SRVResolver) Resolve(ctx context.Context, u *url.URL) ([]string, error) {
+	service := r.Service
+	if service == "" {
+		service = "moqt"
+	}
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	_, srvs, err := resolver.LookupSRV(ctx, service, "udp", u.Hostname())
+	if dnsErr, ok := errors.AsType[*net.DNSError](err); ok && dnsErr.IsNotFound {
+		srvs, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(srvs) == 0 {
+		return fallback(r.Fallback, r.Resolver).Resolve(ctx, u)
+	}
+
+	ips := IPResolver{Resolver: r.Resolver}
+	var endpoints []string
+	var errs []error
+	for _, srv := range srvs {
+		addrs, err := ips.resolve(ctx, trimDot(srv.Target), strconv.Itoa(int(srv.Port)))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		endpoints = append(endpoints, addrs...)
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return endpoints, nil
+}
+
+// HTTPSResolver discovers the endpoints of a server from the HTTPS records
+// of RFC 9460 of the host of a URL, or of _<port>._https.<host> for ports
+// other than 443. Records are tried by priority, at their target, or
+// alternative endpoint, and at their port, if any. The address hints of a
+// record are used instead of resolving its target. The ALPN of records is
+// not checked.
+type HTTPSResolver struct {
+	// Nameserver is the address of the DNS server queried for the records,
+	// as host:port. If empty, the first nameserver of /etc/resolv.conf is
+	// used.
+	Nameserver string
+
+	// Dial opens the connections to the DNS server. If nil, a net.Dialer is
+	// used.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Resolver looks up the addresses of targets without hints. If nil,
+	// net.DefaultResolver is used.
+	Resolver *net.Resolver
+
+	// Fallback resolves URLs whose host has no HTTPS records. If nil, an
+	// IPResolver is used.
+	Fallback Resolver
+}
+
+// Resolve implements Resolver.
+func (r HTTPSResolver) Resolve(ctx context.Context, u *url.URL) ([]string, error) {
+	server := r.Nameserver
+	if server == "" {
+		var err error
+		if server, err = svcb.Nameserver(); err != nil {
+			return nil, err
+		}
+	}
+	name := u.Hostname()
+	port := urlPort(u)
+	if port != "443" {
+		name = "_" + port + "._https." + name
+	}
+
+	records, err := svcb.Lookup(ctx, r.Dial, server, name, dnsmessage.TypeHTTPS)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return fallback(r.Fallback, r.Resolver).Resolve(ctx, u)
+	}
+
+	ips := IPResolver{Resolver: r.Resolver}
+	var endpoints []string
+	var errs []error
+	for _, rec := range records {
+		recPort := port
+		if rec.Port != 0 {
+			recPort = strconv.Itoa(int(rec.Port))
+		}
+		target := rec.Target
+		if target == name {
+			// The record is for the server itself.
+			target = u.Hostname()
+		}
+
+		if len(rec.IPv6Hint) > 0 || len(rec.IPv4Hint) > 0 {
+			for _, addr := range interleaveFamilies(hintAddrs(rec)) {
+				endpoints = append(endpoints, net.JoinHostPort(addr.String(), recPort))
+			}
+			continue
+		}
+		addrs, err := ips.resolve(ctx, target, recPort)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		endpoints = append(endpoints, addrs...)
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return endpoints, nil
+}
+
+func hintAddrs(rec svcb.Record) []net.IPAddr {
+	addrs := make([]net.IPAddr, 0, len(rec.IPv6Hint)+len(rec.IPv4Hint))
+	for _, hints := range [][]netip.Addr{rec.IPv6Hint, rec.IPv4Hint} {
+		for _, hint := range hints {
+			addrs = append(addrs, net.IPAddr{IP: hint.AsSlice(), Zone: hint.Zone()})
+		}
+	}
+	return addrs
+}
+
+func fallback(r Resolver, resolver *net.Resolver) Resolver {
+	if r != nil {
+		return r
+	}
+	return IPResolver{Resolver: resolver}
+}
+
+// urlPort returns the port of u, or 443.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	return "443"
+}
+
+func trimDot(name string) string {
+	if len(name) > 1 && name[len(name)-1] == '.' {
+		return name[:len(name)-1]
+	}
+	return name
+}
@@ -0,0 +1,261 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testZone is a DNS server answering from its records, keyed by name and
+// type, and with NXDOMAIN for other names.
+type testZone map[string]map[dnsmessage.Type][]dnsmessage.ResourceBody
+
+func (z testZone) serve(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			q := query.Questions[0]
+			rsp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+				Questions: query.Questions,
+			}
+			records, ok := z[strings.TrimSuffix(q.Name.String(), ".")]
+			if !ok {
+				rsp.RCode = dnsmessage.RCodeNameError
+			}
+			for _, body := range records[q.Type] {
+				rsp.Answers = append(rsp.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   body,
+				})
+			}
+			msg, err := rsp.Pack()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteTo(msg, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// zoneResolver returns a resolver querying the zone at addr.
+func zoneResolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", addr)
+		},
+	}
+}
+
+func mustName(t *testing.T, name string) dnsmessage.Name {
+	t.Helper()
+	n, err := dnsmessage.NewName(name)
+	require.NoError(t, err)
+	return n
+}
+
+func httpsRecord(t *testing.T, priority uint16, target string, params ...dnsmessage.SVCParam) *dnsmessage.HTTPSResource {
+	t.Helper()
+	return &dnsmessage.HTTPSResource{SVCBResource: dnsmessage.SVCBResource{
+		Priority: priority,
+		Target:   mustName(t, target),
+		Params:   params,
+	}}
+}
+
+func aRecord(ip string) *dnsmessage.AResource {
+	return &dnsmessage.AResource{A: [4]byte(net.ParseIP(ip).To4())}
+}
+
+func aaaaRecord(ip string) *dnsmessage.AAAAResource {
+	return &dnsmessage.AAAAResource{AAAA: [16]byte(net.ParseIP(ip).To16())}
+}
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	require.NoError(t, err)
+	return u
+}
+
+func TestIPResolver(t *testing.T) {
+	addr := testZone{
+		"relay.example.com": {
+			dnsmessage.TypeA:    {aRecord("192.0.2.1"), aRecord("192.0.2.2")},
+			dnsmessage.TypeAAAA: {aaaaRecord("2001:db8::1")},
+		},
+	}.serve(t)
+
+	tests := map[string]struct {
+		url  string
+		want []string
+	}{
+		"ip literal": {
+			url:  "moqt://192.0.2.1:9000",
+			want: []string{"192.0.2.1:9000"},
+		},
+		"default port": {
+			url:  "https://[2001:db8::1]",
+			want: []string{"[2001:db8::1]:443"},
+		},
+		"both families": {
+			url:  "https://relay.example.com:4433",
+			want: []string{"[2001:db8::1]:4433", "192.0.2.1:4433", "192.0.2.2:4433"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := IPResolver{Resolver: zoneResolver(addr)}.Resolve(t.Context(), mustParseURL(t, tt.url))
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, got)
+			if len(got) == 3 {
+				assert.NotEqual(t, strings.HasPrefix(got[0], "["), strings.HasPrefix(got[1], "["), "families must alternate")
+			}
+		})
+	}
+}
+
+func TestSRVResolver(t *testing.T) {
+	addr := testZone{
+		"_moqt._udp.relay.example.com": {
+			dnsmessage.TypeSRV: {
+				&dnsmessage.SRVResource{Priority: 20, Weight: 1, Port: 4434, Target: mustName(t, "backup.example.com.")},
+				&dnsmessage.SRVResource{Priority: 10, Weight: 1, Port: 4433, Target: mustName(t, "edge.example.com.")},
+			},
+		},
+		"edge.example.com":   {dnsmessage.TypeA: {aRecord("192.0.2.10")}},
+		"backup.example.com": {dnsmessage.TypeA: {aRecord("192.0.2.20")}},
+		"plain.example.com":  {dnsmessage.TypeA: {aRecord("192.0.2.30")}},
+	}.serve(t)
+	resolver := SRVResolver{Resolver: zoneResolver(addr)}
+
+	tests := map[string]struct {
+		url  string
+		want []string
+	}{
+		"by priority": {
+			url:  "https://relay.example.com",
+			want: []string{"192.0.2.10:4433", "192.0.2.20:4434"},
+		},
+		"fallback without records": {
+			url:  "https://plain.example.com:4433",
+			want: []string{"192.0.2.30:4433"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := resolver.Resolve(t.Context(), mustParseURL(t, tt.url))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHTTPSResolver(t *testing.T) {
+	port := func(p uint16) dnsmessage.SVCParam {
+		return dnsmessage.SVCParam{Key: dnsmessage.SVCParamPort, Value: []byte{byte(p >> 8), byte(p)}}
+	}
+	ipv4hint := dnsmessage.SVCParam{Key: dnsmessage.SVCParamIPv4Hint, Value: []byte{192, 0, 2, 40}}
+
+	addr := testZone{
+		"relay.example.com": {
+			dnsmessage.TypeHTTPS: {
+				httpsRecord(t, 2, "."),
+				httpsRecord(t, 1, "alt.example.com.", port(8443)),
+			},
+			dnsmessage.TypeA: {aRecord("192.0.2.1")},
+		},
+		"alt.example.com": {dnsmessage.TypeA: {aRecord("192.0.2.2")}},
+		"_4433._https.hinted.example.com": {
+			dnsmessage.TypeHTTPS: {httpsRecord(t, 1, ".", ipv4hint)},
+		},
+		"alias.example.com": {
+			dnsmessage.TypeHTTPS: {httpsRecord(t, 0, "relay.example.com.")},
+		},
+		"plain.example.com": {dnsmessage.TypeA: {aRecord("192.0.2.30")}},
+	}.serve(t)
+	resolver := HTTPSResolver{Nameserver: addr, Resolver: zoneResolver(addr)}
+
+	tests := map[string]struct {
+		url  string
+		want []string
+	}{
+		"alternative endpoint first": {
+			url:  "https://relay.example.com",
+			want: []string{"192.0.2.2:8443", "192.0.2.1:443"},
+		},
+		"port prefix and hints": {
+			url:  "https://hinted.example.com:4433",
+			want: []string{"192.0.2.40:4433"},
+		},
+		"alias": {
+			url:  "https://alias.example.com",
+			want: []string{"192.0.2.2:8443", "192.0.2.1:443"},
+		},
+		"fallback without records": {
+			url:  "https://plain.example.com",
+			want: []string{"192.0.2.30:443"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := resolver.Resolve(t.Context(), mustParseURL(t, tt.url))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_Dial_Resolver(t *testing.T) {
+	server := newFakeClientServer()
+	var resolved *url.URL
+	client := &Client{
+		Dialer: server.dialer(),
+		Resolver: ResolverFunc(func(_ context.Context, u *url.URL) ([]string, error) {
+			resolved = u
+			return []string{"192.0.2.1:4433"}, nil
+		}),
+	}
+	defer client.Close()
+
+	var serverName string
+	client.Dialer.DialQUICFunc = func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+		serverName = tlsConfig.ServerName
+		return server.dialQUIC(ctx, addr, tlsConfig, quicConfig)
+	}
+
+	_, err := client.Dial(t.Context(), "moqt://relay.example.com:4433")
+	require.NoError(t, err)
+	require.NotNil(t, resolved)
+	assert.Equal(t, "relay.example.com:4433", resolved.Host)
+	assert.Equal(t, []string{"192.0.2.1:4433"}, server.addrs)
+	assert.Equal(t, "relay.example.com", serverName)
+}
@@ -0,0 +1,427 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Reliable tracks, such as catalogs or chat history, must not silently lose
+// groups. The publisher keeps their last groups in a RetransmitBuffer, which
+// serves FETCH requests for them, and the subscriber fetches again the
+// groups it lost with a RetransmitPolicy: groups whose stream was reset, and
+// groups missing from the sequence.
+
+// RetransmitBuffer keeps the last groups written on reliable tracks, so that
+// subscribers that lost one can fetch it again. It is a FetchHandler, to be
+// installed on the Server or Dialer publishing the tracks, and tracks are
+// marked reliable with TrackWriter.SetReliable:
+//
+//	buf := &moqt.RetransmitBuffer{}
+//	server.FetchHandler = buf
+//	// In the track handler:
+//	tw.SetReliable(buf)
+//
+// A group is kept once closed by the publisher, even if its delivery failed,
+// with the checksum mode, schema ID and extension headers setting it was
+// written with. A group that was canceled, or one of whose frames failed to
+// be written, is not kept, so that a fetch never returns an incomplete group
+// as whole. Groups sent as datagrams are kept as soon as they are sent.
+type RetransmitBuffer struct {
+	// MaxGroups is the number of groups kept per track.
+	// If zero, defaults to 64.
+	MaxGroups int
+
+	mu     sync.Mutex
+	tracks map[retainedTrackKey]*retainedTrack
+}
+
+var _ FetchHandler = (*RetransmitBuffer)(nil)
+
+type retainedTrackKey struct {
+	path BroadcastPath
+	name TrackName
+}
+
+// retainedTrack holds the groups kept for a track.
+type retainedTrack struct {
+	groups map[GroupSequence]*retainedGroup
+	// order holds the sequences of groups, oldest first.
+	order []GroupSequence
+}
+
+// retainedGroup is a group of a reliable track, recorded while it is
+// written.
+type retainedGroup struct {
+	buf      *RetransmitBuffer
+	key      retainedTrackKey
+	seq      GroupSequence
+	checksum ChecksumMode
+	schema   SchemaID
+	ext      bool
+	frames   []*Frame
+}
+
+func (b *RetransmitBuffer) maxGroups() int {
+	if b.MaxGroups > 0 {
+		return b.MaxGroups
+	}
+	return 64
+}
+
+// keep stores g, replacing a group of the same sequence, and evicts the
+// oldest groups of its track beyond MaxGroups.
+func (b *RetransmitBuffer) keep(g *retainedGroup) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tracks == nil {
+		b.tracks = make(map[retainedTrackKey]*retainedTrack)
+	}
+	track, ok := b.tracks[g.key]
+	if !ok {
+		track = &retainedTrack{groups: make(map[GroupSequence]*retainedGroup)}
+		b.tracks[g.key] = track
+	}
+	if _, ok := track.groups[g.seq]; !ok {
+		track.order = append(track.order, g.seq)
+	}
+	track.groups[g.seq] = g
+	for len(track.order) > b.maxGroups() {
+		delete(track.groups, track.order[0])
+		track.order = track.order[1:]
+	}
+}
+
+func (b *RetransmitBuffer) lookup(path BroadcastPath, name TrackName, seq GroupSequence) (*retainedGroup, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	track, ok := b.tracks[retainedTrackKey{path: path, name: name}]
+	if !ok {
+		return nil, false
+	}
+	g, ok := track.groups[seq]
+	return g, ok
+}
+
+// Group returns the frames of group seq of a track, so that b is also a
+// GroupStore. The frames must not be modified.
+func (b *RetransmitBuffer) Group(ctx context.Context, path BroadcastPath, name TrackName, seq GroupSequence) ([]*Frame, error) {
+	g, ok := b.lookup(path, name, seq)
+	if !ok {
+		return nil, fmt.Errorf("%w: group %s of track %s%s", ErrGroupNotFound, seq, path, name)
+	}
+	return g.frames, nil
+}
+
+// ServeFetch writes the requested group as it was first written, or cancels
+// it with OutOfRangeErrorCode if b does not hold it.
+func (b *RetransmitBuffer) ServeFetch(w *GroupWriter, r *FetchRequest) {
+	g, ok := b.lookup(r.BroadcastPath, r.TrackName, r.GroupSequence)
+	if !ok {
+		w.CancelWrite(OutOfRangeErrorCode)
+		return
+	}
+
+	w.SetChecksum(g.checksum)
+	w.SetSchema(g.schema)
+	w.SetExtensionHeaders(g.ext)
+	// The frames already passed the interceptors when first written.
+	w.SetFrameInterceptor(nil)
+	for _, frame := range g.frames {
+		if err := w.WriteFrame(frame); err != nil {
+			w.CancelWrite(InternalGroupErrorCode)
+			return
+		}
+	}
+	_ = w.Close()
+}
+
+// RetransmitPolicy configures how a TrackReader of a reliable track fetches
+// again the groups it lost. The publisher must serve them, such as with a
+// RetransmitBuffer.
+type RetransmitPolicy struct {
+	// GapTimeout is how long a group missing from the sequence is waited
+	// for before it is fetched, as groups may arrive out of order, and how
+	// long to wait between two fetches of a group.
+	// If zero, defaults to 200ms.
+	GapTimeout time.Duration
+
+	// MaxGap is the largest number of missing groups fetched for one gap.
+	// Larger gaps, such as a change of the subscribed range, are not
+	// repaired. If zero, defaults to 64.
+	MaxGap int
+
+	// MaxAttempts is the number of fetches of a lost group before it is
+	// given up. If zero, defaults to 3.
+	MaxAttempts int
+
+	// FetchTimeout bounds each fetch. If zero, defaults to 5s.
+	FetchTimeout time.Duration
+}
+
+func (p *RetransmitPolicy) gapTimeout() time.Duration {
+	if p.GapTimeout > 0 {
+		return p.GapTimeout
+	}
+	return 200 * time.Millisecond
+}
+
+func (p *RetransmitPolicy) maxGap() int {
+	if p.MaxGap > 0 {
+		return p.MaxGap
+	}
+	return 64
+}
+
+func (p *RetransmitPolicy) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 3
+}
+
+func (p *RetransmitPolicy) fetchTimeout() time.Duration {
+	if p.FetchTimeout > 0 {
+		return p.FetchTimeout
+	}
+	return 5 * time.Second
+}
+
+// retransmitter detects the lost groups of a subscription and fetches them
+// again.
+type retransmitter struct {
+	policy RetransmitPolicy
+	ctx    context.Context
+
+	// fetch returns the encoded frames of a group.
+	fetch func(ctx context.Context, seq GroupSequence) ([]byte, error)
+	// enqueue queues a repaired group.
+	enqueue func(seq GroupSequence, data []byte)
+	// tasks owns the repairs; see goTask.
+	tasks *taskGroup
+
+	mu      sync.Mutex
+	started bool
+	highest GroupSequence
+	// missing holds the gap timers of groups missing from the sequence.
+	missing map[GroupSequence]*time.Timer
+	// repairing holds the groups being fetched.
+	repairing map[GroupSequence]struct{}
+}
+
+func newRetransmitter(ctx context.Context, policy RetransmitPolicy,
+	fetch func(context.Context, GroupSequence) ([]byte, error),
+	enqueue func(GroupSequence, []byte),
+	tasks *taskGroup,
+) *retransmitter {
+	rt := &retransmitter{
+		policy:    policy,
+		ctx:       ctx,
+		fetch:     fetch,
+		enqueue:   enqueue,
+		tasks:     tasks,
+		missing:   make(map[GroupSequence]*time.Timer),
+		repairing: make(map[GroupSequence]struct{}),
+	}
+	context.AfterFunc(ctx, rt.stop)
+	return rt
+}
+
+// received records the arrival of group seq, and starts the gap timers of
+// the groups it skips.
+func (rt *retransmitter) received(seq GroupSequence) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if timer, ok := rt.missing[seq]; ok {
+		timer.Stop()
+		delete(rt.missing, seq)
+	}
+	if !rt.started {
+		rt.started = true
+		rt.highest = seq
+		return
+	}
+	if seq <= rt.highest {
+		return
+	}
+	if gap := uint64(seq - rt.highest - 1); gap > 0 && gap <= uint64(rt.policy.maxGap()) && rt.ctx.Err() == nil {
+		for s := rt.highest + 1; s < seq; s++ {
+			rt.missing[s] = time.AfterFunc(rt.policy.gapTimeout(), func() {
+				rt.gapExpired(s)
+			})
+		}
+	}
+	rt.highest = seq
+}
+
+// dropped stops waiting for the groups first through last, which the
+// publisher announced it will not deliver.
+func (rt *retransmitter) dropped(first, last GroupSequence) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for seq, timer := range rt.missing {
+		if seq >= first && seq <= last {
+			timer.Stop()
+			delete(rt.missing, seq)
+		}
+	}
+}
+
+func (rt *retransmitter) gapExpired(seq GroupSequence) {
+	rt.mu.Lock()
+	_, ok := rt.missing[seq]
+	delete(rt.missing, seq)
+	rt.mu.Unlock()
+
+	if ok {
+		rt.repair(seq)
+	}
+}
+
+// repair fetches group seq again in the background, unless it is already
+// being fetched.
+func (rt *retransmitter) repair(seq GroupSequence) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if _, ok := rt.repairing[seq]; ok || rt.ctx.Err() != nil {
+		return
+	}
+	rt.repairing[seq] = struct{}{}
+
+	goTask(rt.tasks, "group repair", func() {
+		defer func() {
+			rt.mu.Lock()
+			delete(rt.repairing, seq)
+			rt.mu.Unlock()
+		}()
+
+		for attempt := range rt.policy.maxAttempts() {
+			if attempt > 0 {
+				select {
+				case <-rt.ctx.Done():
+					return
+				case <-time.After(rt.policy.gapTimeout()):
+				}
+			}
+			ctx, cancel := context.WithTimeout(rt.ctx, rt.policy.fetchTimeout())
+			data, err := rt.fetch(ctx, seq)
+			cancel()
+			if err == nil {
+				rt.enqueue(seq, data)
+				return
+			}
+			if rt.ctx.Err() != nil {
+				return
+			}
+		}
+	}, nil)
+}
+
+// stop stops the gap timers.
+func (rt *retransmitter) stop() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for seq, timer := range rt.missing {
+		timer.Stop()
+		delete(rt.missing, seq)
+	}
+}
+
+// retransmitDropped stops waiting for the groups first through last, which
+// the publisher announced it will not deliver.
+func (r *TrackReader) retransmitDropped(first, last GroupSequence) {
+	r.trackMu.Lock()
+	rt := r.retransmit
+	r.trackMu.Unlock()
+
+	if rt != nil {
+		rt.dropped(first, last)
+	}
+}
+
+// SetReliable marks the track reliable: groups lost after the call, either
+// reset or missing from the sequence, are fetched again according to policy
+// and queued once more, to be returned by AcceptGroup after the group that
+// failed, if any. A nil policy stops repairing groups. The publisher must
+// serve the lost groups, such as with a RetransmitBuffer.
+func (r *TrackReader) SetReliable(policy *RetransmitPolicy) {
+	r.trackMu.Lock()
+	defer r.trackMu.Unlock()
+
+	if r.retransmit != nil {
+		r.retransmit.stop()
+		r.retransmitCancel()
+		r.retransmit = nil
+	}
+	if policy == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	r.retransmitCancel = cancel
+	r.retransmit = newRetransmitter(ctx, *policy, r.fetchGroupData, func(seq GroupSequence, data []byte) {
+		r.trackMu.Lock()
+		ext := r.extensionHeaders
+		r.trackMu.Unlock()
+		r.enqueue(queuedGroup{sequence: seq, extensionHeaders: ext, stream: &datagramStream{Reader: bytes.NewReader(data)}})
+	}, r.tasks)
+}
+
+// fetchGroupData fetches group seq of the track and returns its encoded
+// frames.
+func (r *TrackReader) fetchGroupData(ctx context.Context, seq GroupSequence) ([]byte, error) {
+	if r.fetchFunc == nil {
+		return nil, ErrClosedSession
+	}
+	req := (&FetchRequest{
+		BroadcastPath: r.BroadcastPath,
+		TrackName:     r.TrackName,
+		Priority:      r.sendSubscribeStream.TrackConfig().Priority,
+		GroupSequence: seq,
+	}).WithContext(ctx)
+	group, err := r.fetchFunc(req)
+	if err != nil {
+		return nil, err
+	}
+	// Read the group as encoded, so that the repaired group is verified
+	// like the groups of the subscription when it is read.
+	data, err := io.ReadAll(group.stream)
+	if err != nil {
+		group.CancelRead(InternalGroupErrorCode)
+		return nil, err
+	}
+	return data, nil
+}
+
+// SetReliable marks the track reliable: groups opened after the call are
+// kept in buf, so that subscribers that lose them can fetch them again;
+// see RetransmitBuffer. Nil stops keeping groups. It is safe to call
+// concurrently.
+func (w *TrackWriter) SetReliable(buf *RetransmitBuffer) {
+	w.reliable.Store(buf)
+}
+
+// retainGroup returns the record of group seq of a reliable track, or nil.
+func (w *TrackWriter) retainGroup(seq GroupSequence) *retainedGroup {
+	buf := w.reliable.Load()
+	if buf == nil {
+		return nil
+	}
+	return &retainedGroup{
+		buf:      buf,
+		key:      retainedTrackKey{path: w.BroadcastPath, name: w.TrackName},
+		seq:      seq,
+		checksum: ChecksumMode(w.checksumMode.Load()),
+		schema:   SchemaID(w.schemaID.Load()),
+		ext:      w.extensionHeaders.Load(),
+	}
+}
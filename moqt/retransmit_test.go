@@ -0,0 +1,278 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readGroupPayloads reads the frames of group until EOF.
+func readGroupPayloads(t *testing.T, group *GroupReader) []string {
+	t.Helper()
+
+	var payloads []string
+	frame := NewFrame(0)
+	for {
+		err := group.ReadFrame(frame)
+		if errors.Is(err, io.EOF) {
+			return payloads
+		}
+		require.NoError(t, err)
+		payloads = append(payloads, string(frame.Body()))
+	}
+}
+
+func TestRetransmitBuffer(t *testing.T) {
+	buf := &RetransmitBuffer{MaxGroups: 2}
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	defer writer.Close()
+	writer.SetReliable(buf)
+	writer.SetChecksum(ChecksumGroup)
+	writer.SetSchema(7)
+
+	for _, payload := range []string{"a", "b", "c"} {
+		group, err := writer.OpenGroup()
+		require.NoError(t, err)
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte(payload))
+		require.NoError(t, group.WriteFrame(frame))
+		require.NoError(t, group.Close())
+	}
+
+	// The oldest group is evicted.
+	_, err := buf.Group(t.Context(), "/broadcastpath", "trackname", 1)
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+	frames, err := buf.Group(t.Context(), "/broadcastpath", "trackname", 3)
+	require.NoError(t, err)
+	require.Len(t, frames, 1)
+	assert.Equal(t, "c", string(frames[0].Body()))
+
+	// A fetched group is encoded as it was first written.
+	var data bytes.Buffer
+	w := newGroupWriter(&FakeQUICSendStream{WriteFunc: data.Write}, 3, nil)
+	w.SetFrameInterceptor(appendInterceptor("!"))
+	buf.ServeFetch(w, &FetchRequest{BroadcastPath: "/broadcastpath", TrackName: "trackname", GroupSequence: 3})
+	group := newGroupReader(3, &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data.Bytes()).Read}, nil)
+	group.SetChecksum(ChecksumGroup, ChecksumPolicyError)
+	group.SetSchemaLookup(func(id SchemaID) (Schema, bool) { return Schema{ID: id}, id == 7 })
+	assert.Equal(t, []string{"c"}, readGroupPayloads(t, group))
+
+	var canceled GroupErrorCode
+	w = newGroupWriter(&FakeQUICSendStream{CancelWriteFunc: func(code transport.StreamErrorCode) {
+		canceled = GroupErrorCode(code)
+	}}, 1, nil)
+	buf.ServeFetch(w, &FetchRequest{BroadcastPath: "/broadcastpath", TrackName: "trackname", GroupSequence: 1})
+	assert.Equal(t, OutOfRangeErrorCode, canceled)
+}
+
+func TestRetransmitBuffer_IncompleteGroup(t *testing.T) {
+	errWrite := errors.New("write failed")
+
+	tests := map[string]struct {
+		// writeErr, if set, fails the write of the second frame.
+		writeErr error
+		// end ends the group after its frames.
+		end func(group *GroupWriter)
+	}{
+		"failed mid-stream": {end: func(g *GroupWriter) { g.CancelWrite(InternalGroupErrorCode) }},
+		"publish aborted":   {end: func(g *GroupWriter) { g.CancelWrite(PublishAbortedErrorCode) }},
+		"expired":           {end: func(g *GroupWriter) { g.CancelWrite(ExpiredGroupErrorCode) }},
+		"frame write failed": {
+			writeErr: errWrite,
+			end:      func(g *GroupWriter) { _ = g.Close() },
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			buf := &RetransmitBuffer{}
+			substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+			var writeErr error
+			writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+				return &FakeQUICSendStream{WriteFunc: func(p []byte) (int, error) {
+					if writeErr != nil {
+						return 0, writeErr
+					}
+					return len(p), nil
+				}}, nil
+			}, func() {})
+			defer writer.Close()
+			writer.SetReliable(buf)
+
+			group, err := writer.OpenGroup()
+			require.NoError(t, err)
+			for _, payload := range []string{"a", "b"} {
+				frame := NewFrame(0)
+				_, _ = frame.Write([]byte(payload))
+				if payload == "b" {
+					writeErr = tt.writeErr
+				}
+				err := group.WriteFrame(frame)
+				if writeErr != nil {
+					require.ErrorIs(t, err, writeErr)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+			tt.end(group)
+
+			_, err = buf.Group(t.Context(), "/broadcastpath", "trackname", 1)
+			assert.ErrorIs(t, err, ErrGroupNotFound)
+
+			var canceled *GroupErrorCode
+			w := newGroupWriter(&FakeQUICSendStream{
+				CancelWriteFunc: func(code transport.StreamErrorCode) {
+					canceled = new(GroupErrorCode(code))
+				},
+			}, 1, nil)
+			buf.ServeFetch(w, &FetchRequest{BroadcastPath: "/broadcastpath", TrackName: "trackname", GroupSequence: 1})
+			require.NotNil(t, canceled)
+			assert.Equal(t, OutOfRangeErrorCode, *canceled)
+		})
+	}
+}
+
+func TestRetransmitter(t *testing.T) {
+	tests := map[string]struct {
+		// received are the groups received, in order.
+		received []GroupSequence
+		// dropped is a range announced with SUBSCRIBE_DROP.
+		dropped *[2]GroupSequence
+		want    []GroupSequence
+	}{
+		"gap": {
+			received: []GroupSequence{1, 4},
+			want:     []GroupSequence{2, 3},
+		},
+		"out of order": {
+			received: []GroupSequence{1, 3, 2},
+		},
+		"partly filled gap": {
+			received: []GroupSequence{1, 4, 2},
+			want:     []GroupSequence{3},
+		},
+		"dropped range": {
+			received: []GroupSequence{1, 5},
+			dropped:  &[2]GroupSequence{2, 3},
+			want:     []GroupSequence{4},
+		},
+		"gap too large": {
+			received: []GroupSequence{1, 10},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				enqueued []GroupSequence
+			)
+			rt := newRetransmitter(t.Context(), RetransmitPolicy{GapTimeout: 10 * time.Millisecond, MaxGap: 4},
+				func(ctx context.Context, seq GroupSequence) ([]byte, error) {
+					return []byte{byte(seq)}, nil
+				},
+				func(seq GroupSequence, data []byte) {
+					mu.Lock()
+					defer mu.Unlock()
+					assert.Equal(t, []byte{byte(seq)}, data)
+					enqueued = append(enqueued, seq)
+				}, nil)
+			for _, seq := range tt.received {
+				rt.received(seq)
+			}
+			if tt.dropped != nil {
+				rt.dropped(tt.dropped[0], tt.dropped[1])
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			slices.Sort(enqueued)
+			assert.Equal(t, tt.want, enqueued)
+		})
+	}
+}
+
+func TestRetransmitter_Attempts(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	done := make(chan struct{})
+	rt := newRetransmitter(t.Context(), RetransmitPolicy{GapTimeout: time.Millisecond, MaxAttempts: 3},
+		func(ctx context.Context, seq GroupSequence) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			if calls < 3 {
+				return nil, errors.New("not yet")
+			}
+			return []byte("ok"), nil
+		},
+		func(seq GroupSequence, data []byte) {
+			close(done)
+		}, nil)
+
+	rt.repair(1)
+	// A group being repaired is not fetched twice.
+	rt.repair(1)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("group not repaired")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, calls)
+}
+
+func TestTrackReader_SetReliable(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	stream := &FakeQUICStream{ReadFunc: func([]byte) (int, error) {
+		<-ctx.Done()
+		return 0, io.EOF
+	}}
+	reader := newTrackReader("/test", "video", newTestSendSubscribeStreamFromStream(stream, nil), func() {})
+	defer reader.Close()
+
+	data := writeSchemaGroup(t, 0, ChecksumNone, "a", "b")
+	var fetched GroupSequence
+	reader.fetchFunc = func(req *FetchRequest) (*GroupReader, error) {
+		fetched = req.GroupSequence
+		return newGroupReader(req.GroupSequence, &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil), nil
+	}
+	reader.SetReliable(&RetransmitPolicy{})
+
+	// The publisher resets group 1.
+	reader.enqueueGroup(1, &FakeQUICReceiveStream{ReadFunc: func([]byte) (int, error) {
+		return 0, &transport.StreamError{ErrorCode: transport.StreamErrorCode(ExpiredGroupErrorCode), Remote: true}
+	}})
+	group, err := reader.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	var grpErr *GroupError
+	require.ErrorAs(t, group.ReadFrame(NewFrame(0)), &grpErr)
+
+	// The group is fetched again and queued once more.
+	acceptCtx, cancelAccept := context.WithTimeout(t.Context(), time.Second)
+	defer cancelAccept()
+	group, err = reader.AcceptGroup(acceptCtx)
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(1), group.GroupSequence())
+	assert.Equal(t, GroupSequence(1), fetched)
+	assert.Equal(t, []string{"a", "b"}, readGroupPayloads(t, group))
+}
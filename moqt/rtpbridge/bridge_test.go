@@ -0,0 +1,234 @@
+package rtpbridge
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Defaults(t *testing.T) {
+	tests := map[string]struct {
+		config            *Config
+		wantClockRate     uint32
+		wantGroupDuration time.Duration
+		wantFrameRate     float64
+	}{
+		"nil config": {
+			config:            nil,
+			wantGroupDuration: time.Second,
+			wantFrameRate:     30,
+		},
+		"zero config": {
+			config:            &Config{},
+			wantGroupDuration: time.Second,
+			wantFrameRate:     30,
+		},
+		"custom config": {
+			config:            &Config{ClockRate: 48000, GroupDuration: 2 * time.Second, FrameRate: 50},
+			wantClockRate:     48000,
+			wantGroupDuration: 2 * time.Second,
+			wantFrameRate:     50,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.wantClockRate, tt.config.clockRate())
+			assert.Equal(t, tt.wantGroupDuration, tt.config.groupDuration())
+			assert.Equal(t, tt.wantFrameRate, tt.config.frameRate())
+			assert.Nil(t, tt.config.keyFrame())
+		})
+	}
+}
+
+func TestNewIngester_ZeroClockRate(t *testing.T) {
+	assert.Panics(t, func() { NewIngester(nil, &Config{}) })
+}
+
+func TestIngester_MediaTime(t *testing.T) {
+	tests := map[string]struct {
+		timestamps []uint32
+		want       []time.Duration
+	}{
+		"increasing": {
+			timestamps: []uint32{1000, 1000, 46000, 91000},
+			want:       []time.Duration{0, 0, 500 * time.Millisecond, time.Second},
+		},
+		"wrap around": {
+			timestamps: []uint32{0xffffffff - 44999, 0, 45000},
+			want:       []time.Duration{0, 500 * time.Millisecond, time.Second},
+		},
+		"decode order": {
+			timestamps: []uint32{0, 9000, 3000, 6000, 18000},
+			want:       []time.Duration{0, 100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			in := &Ingester{clockRate: 90000}
+			got := make([]time.Duration, 0, len(tt.timestamps))
+			for _, ts := range tt.timestamps {
+				got = append(got, in.mediaTime(ts))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIngest_GroupDuration(t *testing.T) {
+	// 2.5s of 10 packets per second at 90kHz.
+	var packets [][]byte
+	for i := range 25 {
+		packets = append(packets, newPacket(uint16(i), uint32(i*9000), []byte{byte(i)}))
+	}
+
+	tr := ingest(t, packets, &Config{ClockRate: 90000, FrameRate: 10})
+	groups := readGroups(t, tr, 3)
+
+	assert.Len(t, groups[0], 10)
+	assert.Len(t, groups[1], 10)
+	assert.Len(t, groups[2], 5)
+	assert.Equal(t, packets[10], groups[1][0], "packets are carried unchanged")
+}
+
+func TestIngest_KeyFrame(t *testing.T) {
+	// Payloads starting with 'K' start key frames.
+	payloads := []string{"d", "K", "d", "d", "K", "d"}
+	var packets [][]byte
+	for i, p := range payloads {
+		packets = append(packets, newPacket(uint16(i), uint32(i*3000), []byte(p)))
+	}
+	// An invalid packet is skipped.
+	packets = append(packets[:3:3], append([][]byte{{0x00}}, packets[3:]...)...)
+
+	tr := ingest(t, packets, &Config{
+		ClockRate: 90000,
+		KeyFrame:  func(payload []byte) bool { return bytes.HasPrefix(payload, []byte("K")) },
+	})
+	groups := readGroups(t, tr, 2)
+
+	// Packets before the first key frame are discarded.
+	assert.Equal(t, [][]byte{packets[1], packets[2], packets[4]}, groups[0])
+	assert.Equal(t, [][]byte{packets[5], packets[6]}, groups[1])
+}
+
+func TestEgress(t *testing.T) {
+	var packets [][]byte
+	for i := range 25 {
+		packets = append(packets, newPacket(uint16(i), uint32(i*9000), []byte{byte(i)}))
+	}
+	tr := ingest(t, packets, &Config{ClockRate: 90000, FrameRate: 10})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	dst := &packetSink{}
+	dst.onWrite = func(n int) {
+		if n == len(packets) {
+			cancel()
+		}
+	}
+
+	err := Egress(ctx, tr, dst)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, packets, dst.packets)
+}
+
+func TestEgress_WriteError(t *testing.T) {
+	tr := ingest(t, [][]byte{newPacket(0, 0, []byte("p"))}, &Config{ClockRate: 90000})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	err := Egress(ctx, tr, &packetSink{err: io.ErrClosedPipe})
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+// ingest publishes packets with Ingest and returns a subscription to them.
+func ingest(t *testing.T, packets [][]byte, config *Config) *moqt.TrackReader {
+	t.Helper()
+
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/rtp", moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		if err := Ingest(&packetSource{packets: packets, interval: time.Millisecond}, tw, config); err != nil {
+			t.Errorf("Ingest: %v", err)
+		}
+		<-tw.Context().Done()
+	}))
+	sess := moqttest.Dial(t, moqttest.ServeMux(t, mux), moqt.NewTrackMux(0))
+
+	tr, err := sess.Subscribe(t.Context(), "/rtp", "video", nil)
+	require.NoError(t, err)
+	return tr
+}
+
+// readGroups reads n groups of tr and returns the frames of each, in group
+// sequence order.
+func readGroups(t *testing.T, tr *moqt.TrackReader, n int) [][][]byte {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	groups := make(map[moqt.GroupSequence][][]byte, n)
+	for range n {
+		gr, err := tr.AcceptGroup(ctx)
+		require.NoError(t, err)
+
+		var frames [][]byte
+		for frame := range gr.Frames(nil) {
+			frames = append(frames, bytes.Clone(frame.Body()))
+		}
+		groups[gr.GroupSequence()] = frames
+	}
+
+	seqs := slices.Sorted(maps.Keys(groups))
+	sorted := make([][][]byte, 0, n)
+	for _, seq := range seqs {
+		sorted = append(sorted, groups[seq])
+	}
+	return sorted
+}
+
+// packetSource returns one packet per Read call, then io.EOF. Packets are
+// paced by interval so that groups arrive in order.
+type packetSource struct {
+	packets  [][]byte
+	interval time.Duration
+}
+
+func (s *packetSource) Read(b []byte) (int, error) {
+	if len(s.packets) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(s.interval)
+	n := copy(b, s.packets[0])
+	s.packets = s.packets[1:]
+	return n, nil
+}
+
+// packetSink records written packets, or fails with err if set.
+type packetSink struct {
+	packets [][]byte
+	onWrite func(n int)
+	err     error
+}
+
+func (s *packetSink) Write(b []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.packets = append(s.packets, bytes.Clone(b))
+	s.onWrite(len(s.packets))
+	return len(b), nil
+}
@@ -0,0 +1,27 @@
+// Package rtpbridge converts between RTP packet streams and MOQ tracks, for
+// services that ingest media over WebRTC and distribute it over MOQ, or the
+// other way around.
+//
+// Each RTP packet is carried unchanged as one frame, so that the egress side
+// can forward it with its original sequence number, timestamp and marker
+// bit. On ingest, packets are mapped to groups by RTP time: a new group
+// starts every Config.GroupDuration of media time, or at every key frame if
+// Config.KeyFrame is set.
+//
+// The package does not depend on pion/webrtc. A *webrtc.TrackLocalStaticRTP
+// is an io.Writer of RTP packets and can be passed to Egress directly; a
+// *webrtc.TrackRemote is adapted with PacketReaderFunc:
+//
+//	handler := moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+//	    src := rtpbridge.PacketReaderFunc(func(b []byte) (int, error) {
+//	        n, _, err := remote.Read(b)
+//	        return n, err
+//	    })
+//	    _ = rtpbridge.Ingest(src, tw, &rtpbridge.Config{ClockRate: 90000})
+//	})
+//
+//	// and on the distribution side:
+//	tr, err := sess.Subscribe(ctx, "/live/cam", "video", nil)
+//	...
+//	err = rtpbridge.Egress(ctx, tr, local)
+package rtpbridge
@@ -0,0 +1,61 @@
+package rtpbridge
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// Egress reads the groups of tr in order and writes each frame to dst as an
+// RTP packet, until ctx is canceled, the subscription ends or dst fails.
+// dst receives one packet per Write call; a pion *webrtc.TrackLocalStaticRTP
+// can be passed directly and rewrites the SSRC and payload type for each of
+// its peers.
+//
+// Groups are forwarded one at a time. A group older than one already
+// forwarded is skipped, and a group that is reset midway is abandoned, so a
+// packet loss follows and the next group starts afresh. Egress returns the
+// error that ended it.
+func Egress(ctx context.Context, tr *moqt.TrackReader, dst io.Writer) error {
+	frame := moqt.NewFrame(0)
+	var forwarded bool
+	var latest moqt.GroupSequence
+
+	for {
+		gr, err := tr.AcceptGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		seq := gr.GroupSequence()
+		if forwarded && seq <= latest {
+			gr.CancelRead(moqt.ExpiredGroupErrorCode)
+			continue
+		}
+		forwarded, latest = true, seq
+
+		if err := forwardGroup(gr, frame, dst); err != nil {
+			return err
+		}
+	}
+}
+
+// forwardGroup writes the frames of gr to dst. It returns an error only if
+// dst fails.
+func forwardGroup(gr *moqt.GroupReader, frame *moqt.Frame, dst io.Writer) error {
+	for {
+		if err := gr.ReadFrame(frame); err != nil {
+			if !errors.Is(err, io.EOF) {
+				gr.CancelRead(moqt.InternalGroupErrorCode)
+			}
+			return nil
+		}
+
+		if _, err := dst.Write(frame.Body()); err != nil {
+			gr.CancelRead(moqt.InternalGroupErrorCode)
+			return err
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package rtpbridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/rtp"
+)
+
+// maxPacketSize is the size of the buffer Ingest reads packets into.
+const maxPacketSize = 1 << 16
+
+// Config contains configuration options for ingesting RTP into a track.
+type Config struct {
+	// ClockRate is the RTP clock rate of the track in Hz, for example 90000
+	// for video or 48000 for Opus. It must be set.
+	ClockRate uint32
+
+	// GroupDuration is the media time covered by each group.
+	// It is ignored if KeyFrame is set. If zero, defaults to 1s.
+	GroupDuration time.Duration
+
+	// FrameRate is the nominal number of media frames per second. Packets of
+	// a frame share one RTP timestamp; a frame up to half a frame interval
+	// early still starts a new group. If zero, defaults to 30.
+	FrameRate float64
+
+	// KeyFrame, if set, reports whether an RTP payload starts a key frame.
+	// A new group starts at every key frame instead of every GroupDuration,
+	// and packets before the first key frame are discarded.
+	KeyFrame func(payload []byte) bool
+}
+
+// groupDuration returns the configured group duration or the default (1s).
+func (c *Config) groupDuration() time.Duration {
+	if c != nil && c.GroupDuration > 0 {
+		return c.GroupDuration
+	}
+	return time.Second
+}
+
+// frameRate returns the configured frame rate or the default (30).
+func (c *Config) frameRate() float64 {
+	if c != nil && c.FrameRate > 0 {
+		return c.FrameRate
+	}
+	return 30
+}
+
+// clockRate returns the configured clock rate, or 0.
+func (c *Config) clockRate() uint32 {
+	if c != nil {
+		return c.ClockRate
+	}
+	return 0
+}
+
+// keyFrame returns the configured key frame detector, or nil.
+func (c *Config) keyFrame() func([]byte) bool {
+	if c != nil {
+		return c.KeyFrame
+	}
+	return nil
+}
+
+// Ingester writes RTP packets to a track, one frame per packet.
+//
+// All methods are safe for concurrent use.
+type Ingester struct {
+	tw        *moqt.TrackWriter
+	clockRate uint32
+	keyFrame  func([]byte) bool
+	ticker    *moqt.GroupTicker
+
+	mu sync.Mutex
+	// started reports whether a packet has been written.
+	started bool
+	// last is the RTP timestamp of the last packet.
+	last uint32
+	// elapsed is the RTP time of the last packet since the first one, in
+	// clock ticks, unwrapped across timestamp wrap-around.
+	elapsed int64
+	// latest is the highest elapsed time seen.
+	latest int64
+	// group is the current group in key frame mode.
+	group *moqt.GroupWriter
+	frame *moqt.Frame
+}
+
+// NewIngester returns an Ingester that writes to tw.
+// It panics if config.ClockRate is zero.
+func NewIngester(tw *moqt.TrackWriter, config *Config) *Ingester {
+	clockRate := config.clockRate()
+	if clockRate == 0 {
+		panic("[Ingester] zero clock rate")
+	}
+
+	in := &Ingester{
+		tw:        tw,
+		clockRate: clockRate,
+		keyFrame:  config.keyFrame(),
+		frame:     moqt.NewFrame(0),
+	}
+	if in.keyFrame == nil {
+		in.ticker = moqt.NewGroupTicker(tw, config.frameRate(), config.groupDuration())
+	}
+	return in
+}
+
+// WritePacket writes an RTP packet to the track. It returns ErrInvalidPacket
+// if packet cannot be parsed; the track is not affected in that case.
+func (in *Ingester) WritePacket(b []byte) error {
+	var pkt rtp.Packet
+	if err := pkt.Unmarshal(b); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPacket, err)
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	timestamp := in.mediaTime(pkt.Timestamp)
+
+	in.frame.Reset()
+	_, _ = in.frame.Write(b)
+
+	if in.ticker != nil {
+		return in.ticker.WriteFrame(in.frame, timestamp)
+	}
+
+	if in.keyFrame(pkt.Payload) {
+		if in.group != nil {
+			_ = in.group.Close()
+			in.group = nil
+		}
+		gw, err := in.tw.OpenGroup()
+		if err != nil {
+			return err
+		}
+		in.group = gw
+	}
+	if in.group == nil {
+		return nil
+	}
+	if err := in.group.WriteFrame(in.frame); err != nil {
+		// The rest of the group cannot be decoded; wait for the next key frame.
+		in.group.CancelWrite(moqt.InternalGroupErrorCode)
+		in.group = nil
+		return err
+	}
+	return nil
+}
+
+// mediaTime returns the media time reached at a packet with the given RTP
+// timestamp, relative to the first packet. Timestamps go backwards for
+// reordered packets and for frames sent in decode order, such as B-frames, so
+// the media time is the highest timestamp seen. The caller must hold in.mu.
+func (in *Ingester) mediaTime(timestamp uint32) time.Duration {
+	if in.started {
+		// The difference is signed so that earlier timestamps step back
+		// instead of wrapping around.
+		in.elapsed += int64(int32(timestamp - in.last))
+	}
+	in.started = true
+	in.last = timestamp
+	in.latest = max(in.latest, in.elapsed)
+
+	clockRate := int64(in.clockRate)
+	return time.Duration(in.latest/clockRate)*time.Second +
+		time.Duration(in.latest%clockRate)*time.Second/time.Duration(clockRate)
+}
+
+// Close closes the current group.
+func (in *Ingester) Close() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if in.ticker != nil {
+		return in.ticker.Close()
+	}
+	if in.group == nil {
+		return nil
+	}
+	err := in.group.Close()
+	in.group = nil
+	return err
+}
+
+// Ingest reads RTP packets from src and writes them to tw until src returns
+// an error or the track is closed. Each call to src.Read must return exactly
+// one packet, as a pion *webrtc.TrackRemote adapted with PacketReaderFunc
+// does. Invalid packets and errors writing a single group are skipped.
+//
+// Ingest returns nil when src returns io.EOF, and otherwise the error that
+// stopped it. The current group is closed before Ingest returns.
+func Ingest(src io.Reader, tw *moqt.TrackWriter, config *Config) error {
+	in := NewIngester(tw, config)
+	defer in.Close()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if werr := in.WritePacket(buf[:n]); werr != nil && tw.Context().Err() != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
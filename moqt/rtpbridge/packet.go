@@ -0,0 +1,16 @@
+package rtpbridge
+
+import "errors"
+
+// ErrInvalidPacket is returned by Ingester.WritePacket for data that is not
+// an RTP packet. It wraps the rtp.ErrMalformedPacket reported by the parser.
+var ErrInvalidPacket = errors.New("rtpbridge: invalid RTP packet")
+
+// PacketReaderFunc adapts a function that reads one RTP packet per call,
+// such as the Read method of a pion *webrtc.TrackRemote, to an io.Reader.
+type PacketReaderFunc func(b []byte) (int, error)
+
+// Read calls f(b).
+func (f PacketReaderFunc) Read(b []byte) (int, error) {
+	return f(b)
+}
@@ -0,0 +1,56 @@
+package rtpbridge
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/msf/rtp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPacket returns an RTP packet without CSRCs or extensions.
+func newPacket(seq uint16, timestamp uint32, payload []byte) []byte {
+	b := make([]byte, 12, 12+len(payload))
+	b[0] = 0x80
+	b[1] = 96
+	binary.BigEndian.PutUint16(b[2:], seq)
+	binary.BigEndian.PutUint32(b[4:], timestamp)
+	binary.BigEndian.PutUint32(b[8:], 0x1234)
+	return append(b, payload...)
+}
+
+func TestIngester_WritePacket_Invalid(t *testing.T) {
+	badVersion := newPacket(1, 100, []byte("p"))
+	badVersion[0] = 0x40
+
+	badPadding := newPacket(1, 100, []byte{'p', 9})
+	badPadding[0] |= 0x20
+
+	tests := map[string][]byte{
+		"too short":   {0x80, 96, 0, 1},
+		"bad version": badVersion,
+		"bad padding": badPadding,
+	}
+
+	for name, packet := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The track is not touched for packets that cannot be parsed.
+			in := NewIngester(nil, &Config{ClockRate: 90000, KeyFrame: func([]byte) bool { return true }})
+			err := in.WritePacket(packet)
+			assert.ErrorIs(t, err, ErrInvalidPacket)
+			assert.ErrorIs(t, err, rtp.ErrMalformedPacket)
+		})
+	}
+}
+
+func TestPacketReaderFunc(t *testing.T) {
+	r := PacketReaderFunc(func(b []byte) (int, error) {
+		return copy(b, "packet"), nil
+	})
+
+	buf := make([]byte, 16)
+	n, err := r.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "packet", string(buf[:n]))
+}
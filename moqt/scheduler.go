@@ -0,0 +1,159 @@
+package moqt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// GroupSendInfo describes a group being sent, for a PriorityPolicy.
+type GroupSendInfo struct {
+	BroadcastPath BroadcastPath
+	TrackName     TrackName
+
+	// Priority is the priority of the subscription: the higher of the
+	// subscriber priority and the publisher priority sent with WriteInfo.
+	// It changes when the subscriber updates the subscription.
+	Priority TrackPriority
+
+	// Ordered reports whether the subscription asks for groups in
+	// ascending order, oldest first: it is set if the subscriber or the
+	// publisher set Ordered. It changes when the subscriber updates the
+	// subscription.
+	Ordered bool
+
+	GroupSequence GroupSequence
+	SubgroupID    SubgroupID
+}
+
+// PriorityPolicy maps the groups a session sends to send priorities. Under
+// congestion, data of groups with higher send priorities is sent first.
+//
+// The policy is consulted for every frame, so it must be fast and safe for
+// concurrent use.
+type PriorityPolicy interface {
+	SendPriority(g GroupSendInfo) int64
+}
+
+// PriorityPolicyFunc is an adapter to use ordinary functions as a
+// PriorityPolicy.
+type PriorityPolicyFunc func(g GroupSendInfo) int64
+
+// SendPriority calls f(g).
+func (f PriorityPolicyFunc) SendPriority(g GroupSendInfo) int64 {
+	return f(g)
+}
+
+// DefaultPriorityPolicy orders groups by the priority of their subscription
+// first, so that audio published with a higher priority than video goes
+// first, then by group sequence, and then by subgroup, lowest first. Groups
+// go newest first, so that stale groups wait for current ones, unless the
+// subscription is Ordered, in which case they go oldest first. Set it as
+// Config.PriorityPolicy to schedule groups this way.
+var DefaultPriorityPolicy PriorityPolicy = PriorityPolicyFunc(defaultSendPriority)
+
+func defaultSendPriority(g GroupSendInfo) int64 {
+	const sequenceMask = 1<<40 - 1
+	sequence := uint64(g.GroupSequence) & sequenceMask
+	if g.Ordered {
+		sequence = sequenceMask - sequence
+	}
+	return int64(g.Priority)<<48 |
+		int64(sequence)<<8 |
+		int64(255-min(g.SubgroupID, 255))
+}
+
+// maxSchedulingDelay bounds how long a write waits for writes of higher
+// priority, so that a stream stalled by flow control cannot hold back the
+// other streams of a session indefinitely.
+const maxSchedulingDelay = 100 * time.Millisecond
+
+// sendScheduler schedules the frames written to the group streams of a
+// session by send priority. Streams of backends that implement
+// transport.PrioritizedSendStream get the priority and are scheduled by the
+// backend. On other streams a write waits while writes of higher priority are
+// in progress, which they are while the connection is congested, for at most
+// maxSchedulingDelay.
+type sendScheduler struct {
+	policy PriorityPolicy
+
+	mu sync.Mutex
+	// inflight counts the writes in progress by priority.
+	inflight map[int64]int
+	// released is closed and replaced whenever a write ends.
+	released chan struct{}
+}
+
+// newSendScheduler returns a scheduler of the given policy, or nil if policy
+// is nil, in which case frames are not scheduled.
+func newSendScheduler(policy PriorityPolicy) *sendScheduler {
+	if policy == nil {
+		return nil
+	}
+	return &sendScheduler{
+		policy:   policy,
+		inflight: make(map[int64]int),
+		released: make(chan struct{}),
+	}
+}
+
+// priority returns the send priority of g.
+func (s *sendScheduler) priority(g GroupSendInfo) int64 {
+	return s.policy.SendPriority(g)
+}
+
+// acquire waits until a write of the given priority may start and returns
+// the function that ends it. It returns an error if ctx is done first.
+func (s *sendScheduler) acquire(ctx context.Context, priority int64) (func(), error) {
+	deadline := time.Now().Add(maxSchedulingDelay)
+	var timer *time.Timer
+
+	s.mu.Lock()
+	for s.preemptedLocked(priority) {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			break
+		}
+		released := s.released
+		s.mu.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(wait)
+			defer timer.Stop()
+		}
+		select {
+		case <-released:
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, Cause(ctx)
+		}
+
+		s.mu.Lock()
+	}
+	s.inflight[priority]++
+	s.mu.Unlock()
+
+	return func() { s.release(priority) }, nil
+}
+
+// preemptedLocked reports whether a write of higher priority than priority
+// is in progress.
+func (s *sendScheduler) preemptedLocked(priority int64) bool {
+	for p := range s.inflight {
+		if p > priority {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sendScheduler) release(priority int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inflight[priority]--; s.inflight[priority] <= 0 {
+		delete(s.inflight, priority)
+	}
+	close(s.released)
+	s.released = make(chan struct{})
+}
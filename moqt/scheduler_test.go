@@ -0,0 +1,234 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPriorityPolicy(t *testing.T) {
+	tests := map[string]struct {
+		higher GroupSendInfo
+		lower  GroupSendInfo
+	}{
+		"track priority first": {
+			higher: GroupSendInfo{Priority: 2, GroupSequence: 1},
+			lower:  GroupSendInfo{Priority: 1, GroupSequence: 1000},
+		},
+		"newer group first": {
+			higher: GroupSendInfo{Priority: 1, GroupSequence: 11},
+			lower:  GroupSendInfo{Priority: 1, GroupSequence: 10},
+		},
+		"ordered: older group first": {
+			higher: GroupSendInfo{Priority: 1, Ordered: true, GroupSequence: 10},
+			lower:  GroupSendInfo{Priority: 1, Ordered: true, GroupSequence: 11},
+		},
+		"ordered: track priority first": {
+			higher: GroupSendInfo{Priority: 2, Ordered: true, GroupSequence: 1000},
+			lower:  GroupSendInfo{Priority: 1, Ordered: true, GroupSequence: 0},
+		},
+		"lower subgroup first": {
+			higher: GroupSendInfo{Priority: 1, GroupSequence: 10, SubgroupID: 0},
+			lower:  GroupSendInfo{Priority: 1, GroupSequence: 10, SubgroupID: 1},
+		},
+		"maximum values": {
+			higher: GroupSendInfo{Priority: 255, GroupSequence: MaxGroupSequence},
+			lower:  GroupSendInfo{Priority: 254, GroupSequence: MaxGroupSequence, SubgroupID: 1000},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			higher := DefaultPriorityPolicy.SendPriority(tt.higher)
+			lower := DefaultPriorityPolicy.SendPriority(tt.lower)
+			assert.Greater(t, higher, lower)
+			assert.GreaterOrEqual(t, lower, int64(0))
+		})
+	}
+}
+
+func TestSendScheduler_Acquire(t *testing.T) {
+	s := newSendScheduler(DefaultPriorityPolicy)
+
+	releaseHigh, err := s.acquire(context.Background(), 10)
+	require.NoError(t, err)
+
+	// Writes of equal or higher priority are not held back.
+	release, err := s.acquire(context.Background(), 10)
+	require.NoError(t, err)
+	release()
+	release, err = s.acquire(context.Background(), 20)
+	require.NoError(t, err)
+	release()
+
+	// A lower priority write waits for the higher priority write to end.
+	acquired := make(chan struct{})
+	go func() {
+		release, err := s.acquire(context.Background(), 5)
+		if err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lower priority write started while a higher one was in progress")
+	case <-time.After(maxSchedulingDelay / 4):
+	}
+	releaseHigh()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("lower priority write did not start")
+	}
+}
+
+func TestSendScheduler_AcquireMaxDelay(t *testing.T) {
+	s := newSendScheduler(DefaultPriorityPolicy)
+
+	release, err := s.acquire(context.Background(), 10)
+	require.NoError(t, err)
+	defer release()
+
+	start := time.Now()
+	releaseLow, err := s.acquire(context.Background(), 5)
+	require.NoError(t, err, "a stalled write must not hold back others indefinitely")
+	releaseLow()
+	assert.GreaterOrEqual(t, time.Since(start), maxSchedulingDelay)
+}
+
+func TestSendScheduler_AcquireCanceled(t *testing.T) {
+	s := newSendScheduler(DefaultPriorityPolicy)
+
+	release, err := s.acquire(context.Background(), 10)
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.acquire(ctx, 5)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// fakePrioritizedSendStream is a FakeQUICSendStream that records the
+// priorities it is given.
+type fakePrioritizedSendStream struct {
+	*FakeQUICSendStream
+	priorities []int64
+}
+
+func (s *fakePrioritizedSendStream) SetPriority(priority int64) {
+	s.priorities = append(s.priorities, priority)
+}
+
+func TestTrackWriter_SchedulesGroups(t *testing.T) {
+	var stream *fakePrioritizedSendStream
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{Priority: 3}, message.VersionLite04Ext, nil)
+	tw := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		var buf bytes.Buffer
+		stream = &fakePrioritizedSendStream{FakeQUICSendStream: &FakeQUICSendStream{WriteFunc: buf.Write}}
+		return stream, nil
+	}, func() {})
+
+	var seen []GroupSendInfo
+	tw.scheduler = newSendScheduler(PriorityPolicyFunc(func(g GroupSendInfo) int64 {
+		seen = append(seen, g)
+		return int64(g.Priority)
+	}))
+
+	gw, err := tw.OpenGroupAt(7)
+	require.NoError(t, err)
+	require.NoError(t, gw.WriteFrame(NewFrame(0)))
+	require.NoError(t, gw.WriteFrame(NewFrame(0)))
+
+	// The publisher priority takes effect if it is higher.
+	substr.mu.Lock()
+	substr.info.Priority = 9
+	substr.mu.Unlock()
+	require.NoError(t, gw.WriteFrame(NewFrame(0)))
+
+	require.Len(t, seen, 3)
+	assert.Equal(t, GroupSendInfo{
+		BroadcastPath: "/broadcastpath",
+		TrackName:     "trackname",
+		Priority:      3,
+		GroupSequence: 7,
+	}, seen[0])
+	assert.Equal(t, []int64{3, 9}, stream.priorities, "priority is set when it changes")
+}
+
+func TestTrackWriter_SchedulesGroups_Ordered(t *testing.T) {
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{Ordered: true}, message.VersionLite04Ext, nil)
+	tw := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		var buf bytes.Buffer
+		return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+	}, func() {})
+
+	var seen []GroupSendInfo
+	tw.scheduler = newSendScheduler(PriorityPolicyFunc(func(g GroupSendInfo) int64 {
+		seen = append(seen, g)
+		return DefaultPriorityPolicy.SendPriority(g)
+	}))
+
+	older, err := tw.OpenGroupAt(1)
+	require.NoError(t, err)
+	require.NoError(t, older.WriteFrame(NewFrame(0)))
+	newer, err := tw.OpenGroupAt(2)
+	require.NoError(t, err)
+	require.NoError(t, newer.WriteFrame(NewFrame(0)))
+
+	require.Len(t, seen, 2)
+	assert.True(t, seen[0].Ordered)
+	assert.Greater(t, DefaultPriorityPolicy.SendPriority(seen[0]), DefaultPriorityPolicy.SendPriority(seen[1]))
+}
+
+func TestSession_SchedulerOptIn(t *testing.T) {
+	session, _ := newTestSessionWithConn(t)
+	assert.Nil(t, session.scheduler, "groups are not scheduled by default")
+
+	conn := &FakeStreamConn{}
+	session = newSession(conn, NewTrackMux(0), nil, &Config{PriorityPolicy: DefaultPriorityPolicy}, nil, nil, nil, nil)
+	t.Cleanup(func() { _ = session.CloseWithError(NoError, "") })
+	assert.NotNil(t, session.scheduler)
+}
+
+func TestTrackWriter_WriteFrameLatency(t *testing.T) {
+	tests := map[string]struct {
+		policy PriorityPolicy
+	}{
+		"unscheduled": {},
+		"scheduled":   {policy: DefaultPriorityPolicy},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+			tw := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+				var buf bytes.Buffer
+				return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+			}, func() {})
+			tw.scheduler = newSendScheduler(tt.policy)
+
+			// Without contention, no frame waits for the scheduler. The
+			// writes of the group are stopped beforehand, so a write that
+			// waited would fail with errWaited instead.
+			errWaited := errors.New("write waited for the scheduler")
+			for seq := GroupSequence(1); seq <= 10; seq++ {
+				gw, err := tw.OpenGroupAt(seq)
+				require.NoError(t, err)
+				gw.stopWrites(errWaited)
+				for range 10 {
+					require.NoError(t, gw.WriteFrame(NewFrame(0)))
+				}
+				require.NoError(t, gw.Close())
+			}
+		})
+	}
+}
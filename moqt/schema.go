@@ -0,0 +1,123 @@
+package moqt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SchemaID identifies the payload format of frames, so that the format of a
+// data track can evolve while readers detect frames they cannot decode.
+//
+// A schema ID is carried as the SchemaHeader extension header of the frame.
+// Like a checksum, it is written even if the extension headers of frames are
+// not enabled, and announced in the group header, so relays forward it and
+// readers find it without configuration. Readers check it only if they have
+// a SchemaLookupFunc; otherwise it is returned by Frame.Extensions like any
+// other header.
+//
+// Zero means no schema ID.
+type SchemaID uint64
+
+// SchemaHeader is the extension header carrying the schema ID of a frame.
+var SchemaHeader = VarintParameter(0x2e, "schema-id")
+
+// errSchemaUnsupported is returned when a schema ID is set on a track of a
+// session whose version cannot announce it.
+var errSchemaUnsupported = errors.New("moqt: schema IDs require VersionLite04Ext")
+
+// Schema describes a payload format.
+type Schema struct {
+	// ID is the identifier carried by the frames of the schema.
+	ID SchemaID
+
+	// Name is a text describing the schema, used in errors.
+	Name string
+
+	// Validate, if set, checks the payload of every frame of the schema
+	// read. A payload it rejects is returned as a SchemaError.
+	Validate func(payload []byte) error
+}
+
+// SchemaLookupFunc returns the schema with the given ID, reporting false if
+// the reader does not know it.
+type SchemaLookupFunc func(id SchemaID) (Schema, bool)
+
+// SchemaRegistry is a set of schemas indexed by ID. Its Lookup method is a
+// SchemaLookupFunc.
+type SchemaRegistry map[SchemaID]Schema
+
+// NewSchemaRegistry returns a registry holding the given schemas.
+func NewSchemaRegistry(schemas ...Schema) SchemaRegistry {
+	r := make(SchemaRegistry, len(schemas))
+	for _, s := range schemas {
+		r[s.ID] = s
+	}
+	return r
+}
+
+// Lookup returns the schema with the given ID.
+func (r SchemaRegistry) Lookup(id SchemaID) (Schema, bool) {
+	s, ok := r[id]
+	return s, ok
+}
+
+// SchemaError is returned by GroupReader.ReadFrame for a frame whose schema
+// the reader does not accept. It matches ErrSchemaMismatch with errors.Is.
+// The frame holds the received payload and later frames can still be read.
+type SchemaError struct {
+	// ID is the schema ID of the frame. It is zero if the frame carries no
+	// valid schema ID.
+	ID SchemaID
+
+	// Schema is the schema of the frame, if the lookup found it.
+	Schema *Schema
+
+	// Err is the error returned by Schema.Validate, if any.
+	Err error
+}
+
+func (e *SchemaError) Error() string {
+	switch {
+	case e.Schema != nil && e.Err != nil:
+		return fmt.Sprintf("moqt: invalid payload for schema %d (%s): %v", e.ID, e.Schema.Name, e.Err)
+	case e.ID == 0:
+		return "moqt: frame without schema ID"
+	default:
+		return fmt.Sprintf("moqt: unknown schema %d", e.ID)
+	}
+}
+
+// Is reports whether target is ErrSchemaMismatch.
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrSchemaMismatch
+}
+
+// Unwrap returns the error returned by Schema.Validate, if any.
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// readSchema sets the schema ID of a decoded frame, whose extension headers
+// were read, from its schema header. A malformed header is ignored.
+func readSchema(frame *Frame) {
+	if id, ok := SchemaHeader.Get(frame.ext); ok {
+		frame.schema = SchemaID(id)
+	}
+}
+
+// checkSchema checks the schema ID of a decoded frame against lookup.
+func checkSchema(frame *Frame, lookup SchemaLookupFunc) error {
+	if frame.schema == 0 {
+		return &SchemaError{}
+	}
+	schema, ok := lookup(frame.schema)
+	if !ok {
+		return &SchemaError{ID: frame.schema}
+	}
+	if schema.Validate != nil {
+		if err := schema.Validate(frame.body); err != nil {
+			return &SchemaError{ID: frame.schema, Schema: &schema, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package moqt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSchemaGroup writes payloads through a GroupWriter with the given
+// schema ID and checksum mode and returns the encoded group stream.
+func writeSchemaGroup(t *testing.T, id SchemaID, mode ChecksumMode, payloads ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	group := newGroupWriter(&FakeQUICSendStream{WriteFunc: buf.Write}, GroupSequence(1), nil)
+	group.SetSchema(id)
+	group.SetChecksum(mode)
+
+	for _, payload := range payloads {
+		frame := NewFrame(len(payload))
+		_, _ = frame.Write([]byte(payload))
+		require.NoError(t, group.WriteFrame(frame))
+	}
+	return buf.Bytes()
+}
+
+func TestSchema_ReadFrame(t *testing.T) {
+	errInvalid := errors.New("invalid")
+	registry := NewSchemaRegistry(
+		Schema{ID: 1, Name: "v1"},
+		Schema{ID: 2, Name: "v2", Validate: func(payload []byte) error {
+			if len(payload) == 0 {
+				return errInvalid
+			}
+			return nil
+		}},
+	)
+
+	tests := map[string]struct {
+		id       SchemaID
+		mode     ChecksumMode
+		payload  string
+		wantErr  error
+		wantID   SchemaID
+		wantBody string
+	}{
+		"known":               {id: 1, payload: "data", wantID: 1, wantBody: "data"},
+		"known with checksum": {id: 2, mode: ChecksumGroup, payload: "data", wantID: 2, wantBody: "data"},
+		"large id":            {id: 1 << 20, payload: "data", wantErr: ErrSchemaMismatch, wantID: 1 << 20, wantBody: "data"},
+		"unknown":             {id: 3, payload: "data", wantErr: ErrSchemaMismatch, wantID: 3, wantBody: "data"},
+		"invalid payload":     {id: 2, payload: "", wantErr: errInvalid, wantID: 2},
+		"writer without id":   {mode: ChecksumFrame, payload: "x", wantErr: ErrSchemaMismatch, wantBody: "x"},
+		"empty known payload": {id: 1, payload: "", wantID: 1},
+		"with frame checksum": {id: 1, mode: ChecksumFrame, payload: "x", wantID: 1, wantBody: "x"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := writeSchemaGroup(t, tt.id, tt.mode, tt.payload, "next")
+			group := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil)
+			group.SetChecksum(tt.mode, ChecksumPolicyError)
+			group.SetSchemaLookup(registry.Lookup)
+
+			frame := NewFrame(0)
+			err := group.ReadFrame(frame)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorIs(t, err, ErrSchemaMismatch)
+				var schemaErr *SchemaError
+				require.ErrorAs(t, err, &schemaErr)
+				assert.Equal(t, tt.wantID, schemaErr.ID)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantID, frame.SchemaID())
+			}
+			assert.Equal(t, tt.wantBody, string(frame.Body()))
+
+			// A mismatch does not prevent reading the next frame.
+			err = group.ReadFrame(frame)
+			if tt.id == 1 || tt.id == 2 {
+				require.NoError(t, err)
+				assert.Equal(t, "next", string(frame.Body()))
+			}
+		})
+	}
+}
+
+func TestSchema_ReaderWithoutLookup(t *testing.T) {
+	data := writeSchemaGroup(t, 7, ChecksumNone, "abc")
+	group := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil)
+	group.SetExtensionHeaders(true)
+
+	// The schema ID is read, but not checked, like any other header.
+	frame := NewFrame(0)
+	require.NoError(t, group.ReadFrame(frame))
+	assert.Equal(t, "abc", string(frame.Body()))
+	assert.Equal(t, SchemaID(7), frame.SchemaID())
+	id, ok := SchemaHeader.Get(frame.Extensions())
+	assert.True(t, ok)
+	assert.Equal(t, uint64(7), id)
+}
+
+func TestSchema_FrameReencodes(t *testing.T) {
+	data := writeSchemaGroup(t, 1, ChecksumNone, "payload")
+	group := newGroupReader(GroupSequence(1), &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}, nil)
+	group.SetSchemaLookup(NewSchemaRegistry(Schema{ID: 1}).Lookup)
+
+	frame := NewFrame(0)
+	require.NoError(t, group.ReadFrame(frame))
+	clone := frame.Clone()
+	assert.Equal(t, SchemaID(1), clone.SchemaID())
+
+	// The stripped frame is written again without its extension headers.
+	var buf bytes.Buffer
+	require.NoError(t, frame.encode(&buf))
+	assert.Equal(t, "\x07payload", buf.String())
+}
+
+func TestSchemaError_Error(t *testing.T) {
+	assert.Equal(t, "moqt: frame without schema ID", (&SchemaError{}).Error())
+	assert.Equal(t, "moqt: unknown schema 5", (&SchemaError{ID: 5}).Error())
+	assert.Equal(t, "moqt: invalid payload for schema 5 (v5): bad",
+		(&SchemaError{ID: 5, Schema: &Schema{ID: 5, Name: "v5"}, Err: errors.New("bad")}).Error())
+}
+
+func TestTrackWriter_SetSchema(t *testing.T) {
+	var buf bytes.Buffer
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+	}, func() {})
+	defer writer.Close()
+
+	require.NoError(t, writer.SetSchema(300))
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+	assert.Equal(t, SchemaID(300), group.schema)
+
+	// The schema header was announced in the group header and cannot change.
+	group.SetSchema(0)
+	assert.Equal(t, SchemaID(300), group.schema)
+	r := bytes.NewReader(buf.Bytes())
+	var st message.StreamType
+	require.NoError(t, st.Decode(r))
+	gm := message.GroupMessage{Version: message.VersionLite04Ext}
+	require.NoError(t, gm.Decode(r))
+	assert.Equal(t, message.GroupFlagExtensionHeaders, gm.Flags)
+
+	require.NoError(t, writer.SetSchema(0))
+	group, err = writer.OpenGroup()
+	require.NoError(t, err)
+	assert.Zero(t, group.schema)
+}
+
+func TestTrackWriter_SetSchemaUnsupported(t *testing.T) {
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	defer writer.Close()
+
+	// A moq-lite-04 group header cannot announce the schema header.
+	assert.ErrorIs(t, writer.SetSchema(1), errSchemaUnsupported)
+	assert.NoError(t, writer.SetSchema(0))
+
+	writer.schemaID.Store(1)
+	_, err := writer.OpenGroup()
+	assert.ErrorIs(t, err, errSchemaUnsupported)
+}
+
+func TestTrackReader_SetSchemaLookup(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+	receiver.SetSchemaLookup(NewSchemaRegistry(Schema{ID: 1}).Lookup)
+
+	receiver.enqueueGroup(GroupSequence(1), &FakeQUICReceiveStream{})
+	group, err := receiver.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	require.NotNil(t, group.schemaLookup)
+	_, ok := group.schemaLookup(1)
+	assert.True(t, ok)
+}
@@ -1,9 +1,11 @@
 package moqt
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 	"github.com/qumo-dev/gomoqt/transport"
@@ -15,6 +17,7 @@ func newSendSubscribeStream(id SubscribeID, stream transport.Stream, initConfig
 		config:    initConfig,
 		stream:    stream,
 		droppedCh: make(chan struct{}, 1),
+		finished:  make(chan struct{}),
 	}
 
 	return substr
@@ -32,6 +35,17 @@ type sendSubscribeStream struct {
 	droppedCh chan struct{}
 	drops     []SubscribeDrop
 
+	// finished is closed when the publisher ends the subscription
+	// gracefully, by closing its side of the stream, at finishedAt.
+	finished   chan struct{}
+	finishedAt time.Time
+
+	// onDropFunc, if set, is called for every SUBSCRIBE_DROP received.
+	onDropFunc func(SubscribeDrop)
+
+	// version is the encoding of the SUBSCRIBE_UPDATE messages.
+	version message.Version
+
 	id SubscribeID
 }
 
@@ -39,6 +53,10 @@ func (substr *sendSubscribeStream) readSubscribeResponses() {
 	for {
 		ok, drop, err := readSubscribeResponse(substr.stream)
 		if err != nil {
+			if errors.Is(err, io.EOF) {
+				substr.finishedAt = time.Now()
+				close(substr.finished)
+			}
 			return
 		}
 
@@ -54,11 +72,15 @@ func (substr *sendSubscribeStream) readSubscribeResponses() {
 		}
 
 		if drop != nil {
-			substr.appendDrop(SubscribeDrop{
+			d := SubscribeDrop{
 				StartGroup: groupSequenceFromWire(drop.StartGroup),
 				EndGroup:   groupSequenceFromWire(drop.EndGroup),
 				ErrorCode:  SubscribeErrorCode(drop.ErrorCode),
-			})
+			}
+			substr.appendDrop(d)
+			if substr.onDropFunc != nil {
+				substr.onDropFunc(d)
+			}
 			return
 		}
 	}
@@ -119,7 +141,25 @@ func (substr *sendSubscribeStream) updateSubscribe(newConfig *SubscribeConfig) e
 		return nil
 	}
 
-	// Send the message first before updating config
+	return substr.modifySubscribe(func(*SubscribeConfig) *SubscribeConfig {
+		return newConfig
+	})
+}
+
+// modifySubscribe sends the configuration returned by modify for the current
+// one as a SUBSCRIBE_UPDATE. The current configuration cannot change until
+// the update is sent, so that concurrent modifications are not lost.
+func (substr *sendSubscribeStream) modifySubscribe(modify func(current *SubscribeConfig) *SubscribeConfig) error {
+	// Hold the lock while encoding so that concurrent updates are never
+	// interleaved on the stream and the stored config matches the last
+	// update sent.
+	substr.mu.Lock()
+	current := substr.config
+	if current == nil {
+		current = &SubscribeConfig{}
+	}
+	newConfig := modify(current)
+
 	ordered := boolToWireFlag(newConfig.Ordered)
 
 	startGroup := groupSequenceToWire(newConfig.StartGroup)
@@ -132,17 +172,20 @@ func (substr *sendSubscribeStream) updateSubscribe(newConfig *SubscribeConfig) e
 		SubscriberMaxLatency: newConfig.MaxLatency,
 		StartGroup:           startGroup,
 		EndGroup:             endGroup,
+		Visibility:           uint64(newConfig.Visibility),
+		Version:              substr.version,
 	}
 	err := sum.Encode(substr.stream)
+	if err == nil {
+		substr.config = newConfig
+	}
+	substr.mu.Unlock()
+
 	if err != nil {
 		substr.closeWithError(SubscribeErrorCodeInternal)
 		return err
 	}
 
-	substr.mu.Lock()
-	substr.config = newConfig
-	substr.mu.Unlock()
-
 	return nil
 }
 
@@ -170,6 +213,9 @@ func (substr *sendSubscribeStream) pendingDrops() []SubscribeDrop {
 }
 
 func (substr *sendSubscribeStream) ReadInfo() PublishInfo {
+	substr.mu.Lock()
+	defer substr.mu.Unlock()
+
 	return substr.info
 }
 
@@ -13,7 +13,6 @@ import (
 
 	"github.com/quic-go/quic-go"
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
-	"github.com/qumo-dev/gomoqt/moqt/internal/quicgo"
 	"github.com/qumo-dev/gomoqt/moqt/internal/webtransportgo"
 	"github.com/qumo-dev/gomoqt/transport"
 )
@@ -52,7 +51,13 @@ type Server struct {
 	// Address to listen on, in the form "host:port".
 	Addr string
 
-	// TLS configuration
+	// TLS configuration. Its GetConfigForClient, if set, selects the
+	// configuration of each connection from its ClientHello, such as the
+	// certificates, client authentication and ALPN tokens of the domain it
+	// asks for, so that several domains are hosted on one port. The
+	// configurations it returns are adjusted like TLSConfig: NextProtos
+	// defaults to the tokens of the enabled front-ends, tokens of disabled
+	// front-ends are removed, and the certificates of VirtualHosts apply.
 	TLSConfig *tls.Config
 
 	// QUIC configuration
@@ -65,6 +70,17 @@ type Server struct {
 	// If nil, the server will use quic.ListenAddrEarly from the quic-go library.
 	ListenFunc func(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error)
 
+	// Listeners lists further addresses for ListenAndServe and
+	// ListenAndServeTLS to listen on, such as the IPv6 address of a
+	// dual-stack server or other ports, each with optional TLS and QUIC
+	// configurations. Addr is not listened on if it is empty and Listeners
+	// is not.
+	Listeners []ListenConfig
+
+	// ListenerErrorPolicy decides what happens when one of several listeners
+	// fails. The default, ListenerErrorStop, closes all of them.
+	ListenerErrorPolicy ListenerErrorPolicy
+
 	// WebTransport server for handling WebTransport sessions.
 	// If nil, the server will use a default implementation.
 	WebTransportServer WebTransportServer
@@ -73,7 +89,7 @@ type Server struct {
 	// If nil, the server should use a global default mux or initialize a new one.
 	TrackMux *TrackMux
 
-	// Handler serves accepted native QUIC sessions (i.e. connections negotiated with NextProtoMOQ).
+	// Handler serves accepted native QUIC sessions (i.e. connections negotiated with a version of Config.SupportedVersions).
 	// If nil, native QUIC connections are not handled.
 	Handler Handler
 
@@ -84,10 +100,52 @@ type Server struct {
 	// Logger for server events and errors. Optional; if nil, logging is disabled.
 	Logger *slog.Logger
 
+	// Metrics receives server-side telemetry for accepted sessions and the
+	// tracks they serve. Optional; if nil, no telemetry is reported.
+	Metrics ServerMetrics
+
+	// Tracer receives the spans of session setup, subscribe handshakes and
+	// group delivery of accepted sessions. Optional; if nil, nothing is traced.
+	Tracer Tracer
+
+	// Authorizer authorizes accepted sessions before their Handler runs, and
+	// their incoming subscriptions. Optional; if nil, everything is allowed.
+	Authorizer Authorizer
+
+	// ACL checks the broadcasts that accepted sessions announce and the
+	// tracks they subscribe to. Optional; if nil, everything is allowed.
+	ACL ACL
+
+	// SessionContext, if set, is called for each accepted session after its
+	// authorization and before it starts, with the context returned by the
+	// Authorizer, if any, or the setup context. The session context carries
+	// the values of the returned context, such as a tenant ID or quotas, so
+	// that they are available from Session.Value and Session.Context in the
+	// Handler and in the TrackHandlers serving the session. It must not
+	// return nil.
+	SessionContext func(ctx context.Context, r *SessionAuthRequest) context.Context
+
 	// NextSessionURI is the URI sent to clients during Shutdown, allowing them
 	// to reconnect to a different server. If empty, no redirect URI is provided.
 	NextSessionURI string
 
+	// VirtualHosts lists independent logical services hosted on this Server,
+	// selected per connection by TLS server name and, for WebTransport, by
+	// request path. Connections matching no VirtualHost use the Server's own
+	// TrackMux, Handler, FetchHandler and Config. If WebTransportServer is nil,
+	// WebTransport requests matching no VirtualHost go to http.DefaultServeMux.
+	// VirtualHosts must not be modified after the Server starts serving.
+	VirtualHosts []*VirtualHost
+
+	// DisableWebTransport turns off the WebTransport front-end: the "h3" ALPN
+	// token is not advertised and HTTP/3 connections are rejected.
+	DisableWebTransport bool
+
+	// DisableNativeQUIC turns off the native QUIC front-end: the ALPN tokens
+	// of MOQ versions are not advertised and native MOQ connections are
+	// rejected.
+	DisableNativeQUIC bool
+
 	ConnContext func(ctx context.Context, conn StreamConn) context.Context
 
 	listenerMu    sync.RWMutex
@@ -96,17 +154,40 @@ type Server struct {
 
 	connManager *connManager
 
+	// tasks owns the goroutines of the server: the handlers of accepted
+	// connections, the listeners served by Serve, the certificate watcher,
+	// the GOAWAY senders of Shutdown and drainLate, and the closer of the
+	// WebTransport server. The panic of a task is logged, and the
+	// connection it served closed.
+	tasks taskGroup
+
+	middlewareMu sync.RWMutex
+	middlewares  []Middleware
+
 	initOnce sync.Once
 
+	// inShutdown is set by the first call to Close or Shutdown, which then
+	// cancels ctx with ErrServerClosed. ctx bounds the accept loops of the
+	// listeners and the setup of sessions.
 	inShutdown atomic.Bool
+	// drain is set by Drain.
+	drain  atomic.Pointer[drainRequest]
+	ctx    context.Context
+	cancel context.CancelCauseFunc
 }
 
 func (s *Server) init() {
 	s.initOnce.Do(func() {
+		s.ctx, s.cancel = context.WithCancelCause(context.Background())
 		s.listeners = make(map[QUICListener]struct{})
 		s.connManager = newConnManager()
+		s.tasks.fail = s.logTaskFailure
 		if s.WebTransportServer == nil {
-			s.WebTransportServer = NewWebTransportServer(nil)
+			var handler http.Handler
+			if len(s.VirtualHosts) > 0 {
+				handler = &virtualHostHandler{server: s, fallback: http.DefaultServeMux}
+			}
+			s.WebTransportServer = NewWebTransportServer(handler)
 		}
 	})
 }
@@ -117,6 +198,7 @@ var serverContextKey = serverContextKeyType{}
 
 // ServeQUICListener accepts connections on the provided QUIC listener and handles them using the Server's configuration.
 // This runs until the listener is closed or the server shuts down.
+// It fails at once if both WebTransport and native QUIC are disabled.
 func (s *Server) ServeQUICListener(ln QUICListener) error {
 	if s.shuttingDown() {
 		return ErrServerClosed
@@ -124,41 +206,28 @@ func (s *Server) ServeQUICListener(ln QUICListener) error {
 
 	s.init()
 
+	if _, err := s.nextProtos(nil); err != nil {
+		return err
+	}
+
 	s.addListener(ln)
 	defer s.removeListener(ln)
 
-	// Create context for listener's Accept operation
-	// This context will be canceled when the server is shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Watch for shutdown and cancel context when shutting down
-	go func() {
-		for !s.shuttingDown() {
-			time.Sleep(100 * time.Millisecond)
-		}
-		cancel()
-	}()
-
 	for {
-		// Listen for new QUIC connections
-		conn, err := ln.Accept(ctx)
+		// Accept is canceled as soon as the server closes.
+		conn, err := ln.Accept(s.ctx)
 		if err != nil {
-			// Check if this is due to shutdown
-			if s.shuttingDown() {
-				return ErrServerClosed
-			}
-			// Check if context was cancelled
-			if errors.Is(err, context.Canceled) {
+			if s.shuttingDown() || s.ctx.Err() != nil {
 				return ErrServerClosed
 			}
 			return fmt.Errorf("failed to accept QUIC connection: %w", err)
 		}
 
-		// Handle connection in a goroutine
-		go func(conn StreamConn) {
+		s.tasks.Go("connection handler", func() {
 			_ = s.ServeQUICConn(conn)
-		}(conn)
+		}, func() {
+			_ = conn.CloseWithError(transport.ConnErrorCode(InternalSessionErrorCode), "internal error")
+		})
 	}
 }
 
@@ -171,24 +240,185 @@ func (s *Server) ServeQUICConn(conn StreamConn) error {
 
 	s.init()
 
+	if s.draining() {
+		s.setupFailed("quic", ErrServerDraining)
+		conn.CloseWithError(transport.ConnErrorCode(NoError), ErrServerDraining.Error())
+		return ErrServerDraining
+	}
+
 	tlsInfo := conn.TLS()
 	if tlsInfo == nil {
-		return fmt.Errorf("connection does not have TLS information; cannot determine protocol")
+		err := fmt.Errorf("connection does not have TLS information; cannot determine protocol")
+		s.rejectProtocol(conn, "quic", err)
+		return err
+	}
+	protocol := tlsInfo.NegotiatedProtocol
+	if !s.protocolEnabled(protocol) {
+		err := fmt.Errorf("moqt: protocol %q is disabled on this server", protocol)
+		s.rejectProtocol(conn, protocolTransport(protocol), err)
+		return err
 	}
-	switch protocol := tlsInfo.NegotiatedProtocol; protocol {
+	switch protocol {
 	case NextProtoH3:
-		ctx := s.connContext(conn.Context(), conn)
+		ctx := s.connContext(s.serveContext(conn), conn)
 		wrapped := &streamConnContext{StreamConn: conn, ctx: ctx}
 		return s.WebTransportServer.ServeQUICConn(wrapped)
-	case NextProtoMOQ:
-		return s.handleNativeQUIC(conn)
 	default:
-		return fmt.Errorf("unsupported protocol: %s", protocol)
+		if s.Config.supportsVersion(protocol) {
+			return s.handleNativeQUIC(conn)
+		}
+		err := fmt.Errorf("unsupported protocol: %s", protocol)
+		s.rejectProtocol(conn, "quic", err)
+		return err
+	}
+}
+
+// rejectProtocol closes conn, whose ALPN protocol is not served, with
+// UnsupportedVersionErrorCode, and reports err to Metrics.
+func (s *Server) rejectProtocol(conn StreamConn, transportLabel string, err error) {
+	s.setupFailed(transportLabel, err)
+	conn.CloseWithError(transport.ConnErrorCode(UnsupportedVersionErrorCode), UnsupportedVersionErrorCode.String())
+}
+
+// protocolTransport returns the ServerMetrics transport label of an ALPN
+// protocol.
+func protocolTransport(protocol string) string {
+	if protocol == NextProtoH3 {
+		return "webtransport"
+	}
+	return "quic"
+}
+
+// sessionStarted reports sess to Metrics, and reports its end when it ends.
+func (s *Server) sessionStarted(sess *Session, transport string) {
+	if s.Metrics == nil {
+		return
+	}
+	s.Metrics.SessionStarted(transport)
+	context.AfterFunc(sess.Context(), func() {
+		s.Metrics.SessionEnded(transport)
+	})
+}
+
+// setupFailed reports a rejected connection to Metrics.
+func (s *Server) setupFailed(transport string, err error) {
+	if s.Metrics != nil {
+		s.Metrics.SetupFailed(transport, err)
+	}
+}
+
+// protocolEnabled reports whether the front-end for the ALPN protocol is enabled.
+func (s *Server) protocolEnabled(protocol string) bool {
+	switch protocol {
+	case NextProtoH3:
+		return !s.DisableWebTransport
+	default:
+		return !isVersion(protocol) || !s.DisableNativeQUIC
+	}
+}
+
+// serverTLSConfig returns the TLS configuration of the listener: a clone of
+// config adjusted by adjustTLSConfig. The configurations returned by its
+// GetConfigForClient are cloned and adjusted the same way.
+func (s *Server) serverTLSConfig(config *tls.Config) (*tls.Config, error) {
+	tlsConfig := config.Clone()
+	if err := s.adjustTLSConfig(tlsConfig); err != nil {
+		return nil, err
+	}
+
+	if getConfig := tlsConfig.GetConfigForClient; getConfig != nil {
+		tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			config, err := getConfig(hello)
+			if config == nil || err != nil {
+				// A nil configuration keeps the one of the listener.
+				return config, err
+			}
+			config = config.Clone()
+			config.GetConfigForClient = nil
+			if err := s.adjustTLSConfig(config); err != nil {
+				return nil, err
+			}
+			return config, nil
+		}
+	}
+	return tlsConfig, nil
+}
+
+// adjustTLSConfig sets the ALPN tokens of the enabled front-ends and the
+// certificates of the virtual hosts on config.
+func (s *Server) adjustTLSConfig(config *tls.Config) error {
+	protos, err := s.nextProtos(config.NextProtos)
+	if err != nil {
+		return err
+	}
+	config.NextProtos = protos
+	s.virtualHostTLSConfig(config)
+	return nil
+}
+
+// nextProtos returns the ALPN tokens to advertise. Configured tokens are kept
+// in order without those of disabled front-ends; if none are configured, the
+// tokens of the enabled front-ends are used, the MOQ versions before h3 so
+// that clients offering both, such as a Client with TransportAuto, get
+// native QUIC. It fails if the server or its Config is invalid.
+func (s *Server) nextProtos(configured []string) ([]string, error) {
+	if s.DisableWebTransport && s.DisableNativeQUIC {
+		return nil, errors.New("moqt: both WebTransport and native QUIC are disabled")
+	}
+	if err := s.Config.validate(); err != nil {
+		return nil, err
 	}
+	if len(configured) == 0 {
+		configured = append(s.Config.supportedVersions(), NextProtoH3)
+	}
+
+	protos := make([]string, 0, len(configured))
+	for _, proto := range configured {
+		if s.protocolEnabled(proto) {
+			protos = append(protos, proto)
+		}
+	}
+	if len(protos) == 0 {
+		return nil, fmt.Errorf("moqt: no enabled front-end serves the ALPN protocols %q", configured)
+	}
+	return protos, nil
+}
+
+// serveContext returns a context of conn that is also canceled, with
+// ErrServerClosed, when the server closes, so that the setup of its sessions
+// stops with the server.
+func (s *Server) serveContext(conn StreamConn) context.Context {
+	s.init()
+
+	ctx, cancel := context.WithCancelCause(conn.Context())
+	stop := context.AfterFunc(s.ctx, func() {
+		cancel(context.Cause(s.ctx))
+	})
+	context.AfterFunc(ctx, func() {
+		stop()
+	})
+	return ctx
+}
+
+// sessionContext returns the context whose values a session carries: the
+// result of SessionContext, if set, called with authCtx or, if nil, ctx.
+func (s *Server) sessionContext(ctx, authCtx context.Context, r *SessionAuthRequest) context.Context {
+	if s.SessionContext == nil {
+		return authCtx
+	}
+	if authCtx != nil {
+		ctx = authCtx
+	}
+	custom := s.SessionContext(ctx, r)
+	if custom == nil {
+		panic("SessionContext returned nil")
+	}
+	return custom
 }
 
 func (s *Server) connContext(ctx context.Context, conn StreamConn) context.Context {
 	ctx = context.WithValue(ctx, serverContextKey, s.connManager)
+	ctx = context.WithValue(ctx, serverHandlerContextKey, s)
 
 	if s.ConnContext != nil {
 		custom := s.ConnContext(ctx, conn)
@@ -231,7 +461,7 @@ type WebTransportHandler struct {
 	CheckOrigin func(r *http.Request) bool
 
 	// ApplicationProtocols lists ALPN tokens supported for WebTransport upgrades.
-	// If empty, MOQ's default protocol (NextProtoMOQ) is used.
+	// If empty, the versions of Config.SupportedVersions are used.
 	ApplicationProtocols []string
 
 	// ReorderingTimeout sets the maximum wait time for out-of-order packets in WebTransport streams.
@@ -248,6 +478,16 @@ type WebTransportHandler struct {
 	// If nil, the default WebTransport upgrader is used.
 	UpgradeFunc func(w http.ResponseWriter, r *http.Request) (WebTransportSession, error)
 
+	// Authorizer authorizes sessions before the upgrade, and their incoming
+	// subscriptions. If nil, the Authorizer of the Server reaching this
+	// handler is used, if any.
+	Authorizer Authorizer
+
+	// ACL checks the broadcasts that sessions announce and the tracks they
+	// subscribe to. If nil, the ACL of the Server reaching this handler is
+	// used, if any.
+	ACL ACL
+
 	// FallbackHandler handles non-WebTransport requests (e.g., plain HTTP on the same endpoint).
 	// Optional; when nil, behavior is determined by the server’s default request handling.
 	FallbackHandler http.Handler
@@ -262,7 +502,7 @@ func (u *WebTransportHandler) upgradeWebTransport(w http.ResponseWriter, r *http
 	}
 	protocols := u.ApplicationProtocols
 	if len(protocols) == 0 {
-		protocols = []string{NextProtoMOQ}
+		protocols = u.Config.supportedVersions()
 	}
 	// Fallback to default upgrader if custom upgrader is not set
 	defaultUpgrader := webtransportgo.Upgrader{
@@ -277,8 +517,61 @@ func (u *WebTransportHandler) upgradeWebTransport(w http.ResponseWriter, r *http
 // dispatches it to the configured handler. If the upgrade fails, it falls back
 // to FallbackHandler or returns a 400 response.
 func (u *WebTransportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server, _ := r.Context().Value(serverHandlerContextKey).(*Server)
+	var tracer Tracer
+	if server != nil {
+		tracer = server.Tracer
+	}
+	traceCtx, endSetup := startSessionSpan(r.Context(), tracer, "webtransport", r.RemoteAddr, true)
+
+	if server != nil && server.draining() && r.Method == http.MethodConnect {
+		endSetup(ErrServerDraining)
+		server.setupFailed("webtransport", ErrServerDraining)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	authorizer := u.Authorizer
+	if authorizer == nil && server != nil {
+		authorizer = server.Authorizer
+	}
+	acl := u.ACL
+	if acl == nil && server != nil {
+		acl = server.ACL
+	}
+	token := requestAuthToken(r)
+	req := &SessionAuthRequest{
+		Token:          token,
+		Transport:      "webtransport",
+		RemoteAddr:     requestRemoteAddr(r),
+		TLS:            r.TLS,
+		Request:        r,
+		ResponseHeader: w.Header(),
+	}
+	var authCtx context.Context
+	// Only upgrade requests are authorized; others go to FallbackHandler.
+	if authorizer != nil && r.Method == http.MethodConnect {
+		var err error
+		authCtx, err = authorizer.AuthorizeSession(r.Context(), req)
+		if err != nil {
+			endSetup(err)
+			if server != nil {
+				server.setupFailed("webtransport", err)
+			}
+			http.Error(w, http.StatusText(authHTTPStatus(err)), authHTTPStatus(err))
+			return
+		}
+	}
+	if server != nil && r.Method == http.MethodConnect {
+		authCtx = server.sessionContext(r.Context(), authCtx, req)
+	}
+
 	conn, err := u.upgradeWebTransport(w, r)
 	if err != nil {
+		endSetup(err)
+		if server != nil {
+			server.setupFailed("webtransport", err)
+		}
 		u.fallback(w, r)
 		return
 	}
@@ -290,9 +583,25 @@ func (u *WebTransportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		manager = v.(*connManager)
 	}
 
-	sess := newSession(conn, u.TrackMux, manager, u.Config, u.FetchHandler, nil, u.Logger)
+	handler := u.Handler
+	opts := &sessionOptions{tracer: tracer, traceCtx: traceCtx, authorizer: authorizer, authToken: token, authCtx: authCtx, acl: acl}
+	opts.transport = "webtransport"
+	opts.path = r.URL.Path
+	opts.resumeToken = w.Header().Get(ResumeTokenHeader)
+	if server != nil {
+		handler = server.handler(handler)
+		opts.serverMetrics = server.Metrics
+	}
 
-	u.Handler.ServeMOQ(sess)
+	sess := newSession(conn, u.TrackMux, manager, u.Config, u.FetchHandler, nil, u.Logger, opts)
+	endSetup(nil)
+	sess.startQLog(qlogServer)
+	if server != nil {
+		server.sessionStarted(sess, "webtransport")
+		server.drainLate(sess)
+	}
+
+	handler.ServeMOQ(sess)
 }
 
 func (h *WebTransportHandler) fallback(w http.ResponseWriter, r *http.Request) {
@@ -314,14 +623,53 @@ func (f HandleFunc) ServeMOQ(sess *Session) {
 }
 
 func (s *Server) handleNativeQUIC(conn StreamConn) error {
-	if s.Handler != nil {
-		sess := newSession(conn, s.TrackMux, s.connManager, s.Config, s.FetchHandler, nil, s.Logger)
-		s.Handler.ServeMOQ(sess)
+	var serverName string
+	if tlsInfo := conn.TLS(); tlsInfo != nil {
+		serverName = tlsInfo.ServerName
+	}
+	target := s.virtualHostTarget(s.virtualHost(serverName, ""))
+
+	if handler := s.handler(target.Handler); handler != nil {
+		ctx := s.serveContext(conn)
+		traceCtx, endSetup := startSessionSpan(ctx, s.Tracer, "quic", addrString(conn.RemoteAddr()), true)
+		req := &SessionAuthRequest{
+			Transport:  "quic",
+			RemoteAddr: conn.RemoteAddr(),
+			TLS:        conn.TLS(),
+		}
+		var authCtx context.Context
+		if s.Authorizer != nil {
+			var err error
+			authCtx, err = s.Authorizer.AuthorizeSession(ctx, req)
+			if err != nil {
+				endSetup(err)
+				s.setupFailed("quic", err)
+				code := authSessionErrorCode(err)
+				conn.CloseWithError(transport.ConnErrorCode(code), code.String())
+				return err
+			}
+		}
+		authCtx = s.sessionContext(ctx, authCtx, req)
+		sess := newSession(conn, target.TrackMux, s.connManager, target.Config, target.FetchHandler, nil, target.Logger, &sessionOptions{
+			serverMetrics: s.Metrics,
+			tracer:        s.Tracer,
+			traceCtx:      traceCtx,
+			authorizer:    s.Authorizer,
+			acl:           s.ACL,
+			authCtx:       authCtx,
+			transport:     "quic",
+		})
+		endSetup(nil)
+		sess.startQLog(qlogServer)
+		s.sessionStarted(sess, "quic")
+		s.drainLate(sess)
+		handler.ServeMOQ(sess)
 	}
 	return fmt.Errorf("no native QUIC handler configured")
 }
 
-// ListenAndServe starts the server by listening on the server's Address and serving QUIC connections.
+// ListenAndServe starts the server by listening on the server's Address, and
+// on Listeners, and serving QUIC connections.
 // TLS configuration must be provided on the Server for ListenAndServe to function properly.
 func (s *Server) ListenAndServe() error {
 	s.init()
@@ -330,96 +678,59 @@ func (s *Server) ListenAndServe() error {
 	if s.TLSConfig == nil {
 		return fmt.Errorf("configuration for TLS is required for QUIC")
 	}
-
-	// Clone the TLS config to avoid modifying the original
-	tlsConfig := s.TLSConfig.Clone()
-
-	// Make sure we have NextProtos set for ALPN negotiation
-	if len(tlsConfig.NextProtos) == 0 {
-		tlsConfig.NextProtos = []string{NextProtoH3, NextProtoMOQ}
-	}
-
-	// Ensure WebTransport required QUIC flags are enabled.
-	var quicConf *quic.Config
-	if s.QUICConfig == nil {
-		quicConf = &quic.Config{}
-	} else {
-		quicConf = s.QUICConfig.Clone()
-	}
-	quicConf.EnableDatagrams = true
-	quicConf.EnableStreamResetPartialDelivery = true
-
-	listenFunc := s.ListenFunc
-	if listenFunc == nil {
-		listenFunc = quicgo.ListenAddrEarly
-	}
-	ln, err := listenFunc(s.Addr, tlsConfig, quicConf)
-	if err != nil {
-		return fmt.Errorf("failed to start QUIC listener at %s: %w", s.Addr, err)
+	if _, err := s.nextProtos(s.TLSConfig.NextProtos); err != nil {
+		return err
 	}
 
-	return s.ServeQUICListener(ln)
+	return s.listenAndServe(s.TLSConfig)
 }
 
 // ListenAndServeTLS starts the listener over QUIC/TLS using the provided
 // certificate files. It wraps ListenAndServe by creating a TLS config from
-// the provided cert/key files.
+// the provided cert/key files. The files are watched with a CertReloader
+// while the server runs: new connections get the renewed certificate once
+// the files change, and established sessions continue.
 func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	if s.shuttingDown() {
 		return ErrServerClosed
 	}
 	s.init()
 
-	var err error
-	// Generate TLS configuration
-	certs := make([]tls.Certificate, 1)
-	certs[0], err = tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		return fmt.Errorf("failed to load X509 key pair (cert=%s, key=%s): %w", certFile, keyFile, err)
-	}
-
-	// Create TLS config with certificates
-	tlsConfig := &tls.Config{
-		Certificates: certs,
-		NextProtos:   []string{NextProtoH3, NextProtoMOQ},
-	}
-
-	// Ensure WebTransport required QUIC flags are enabled.
-	var quicConf *quic.Config
-	if s.QUICConfig == nil {
-		quicConf = &quic.Config{}
-	} else {
-		quicConf = s.QUICConfig.Clone()
-	}
-	quicConf.EnableDatagrams = true
-	quicConf.EnableStreamResetPartialDelivery = true
-
-	listenFunc := s.ListenFunc
-	if listenFunc == nil {
-		listenFunc = quicgo.ListenAddrEarly
+	if _, err := s.nextProtos(nil); err != nil {
+		return err
 	}
 
-	ln, err := listenFunc(s.Addr, tlsConfig.Clone(), quicConf)
+	// Generate TLS configuration
+	reloader, err := NewCertReloader(certFile, keyFile)
 	if err != nil {
 		return err
 	}
+	reloader.OnReload = func(err error) {
+		if logger := s.Logger; logger != nil {
+			if err != nil {
+				logger.Error("failed to reload TLS certificate", "error", err)
+			} else {
+				logger.Info("reloaded TLS certificate", "cert", certFile)
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	s.tasks.Go("certificate watcher", func() {
+		reloader.Watch(ctx, defaultCertReloadInterval)
+	}, nil)
 
-	return s.ServeQUICListener(ln)
+	// Listeners without their own TLS configuration use the certificate.
+	return s.listenAndServe(&tls.Config{GetCertificate: reloader.GetCertificate})
 }
 
-// Close gracefully shuts down the server by closing all listeners and
-// sessions, waiting until all sessions have been terminated.
+// Close shuts down the server by closing all listeners and sessions, the
+// latter with NoError, waiting until all sessions have been terminated.
 func (s *Server) Close() error {
-	if s.shuttingDown() {
+	if !s.beginShutdown() {
 		return ErrServerClosed
 	}
 
-	// Set the shutdown flag
-	s.inShutdown.Store(true)
-
-	// Ensure that the server is initialized
-	s.init()
-
 	// Close all listeners first to stop accepting new connections
 	s.listenerMu.Lock()
 	for ln := range s.listeners {
@@ -427,28 +738,21 @@ func (s *Server) Close() error {
 	}
 	s.listenerMu.Unlock()
 
-	connectionManager := s.connManager
-	s.connManager = nil
-
 	// Terminate all active sessions
-	for conn := range connectionManager.connections {
-		// Close sessions concurrently; log potential errors.
-		go func(conn StreamConn) {
-
-		}(conn)
+	for _, conn := range s.connManager.conns() {
+		_ = conn.CloseWithError(transport.ConnErrorCode(NoError), "server closed")
 	}
 
 	// Wait for all sessions to close
-	<-connectionManager.Done()
+	<-s.connManager.Done()
 
 	// Close WebTransport server (guard against panics from underlying implementations)
 	if s.WebTransportServer != nil {
 		done := make(chan struct{})
-		go func() {
-			defer func() { recover() }()
+		s.tasks.Go("WebTransport server closer", func() {
 			_ = s.WebTransportServer.Close()
 			close(done)
-		}()
+		}, nil)
 		select {
 		case <-done:
 		case <-time.After(100 * time.Millisecond):
@@ -471,13 +775,10 @@ func (s *Server) Close() error {
 // It stops accepting new connections, asks active connections to go away,
 // and waits for all tracked connections to close.
 func (s *Server) Shutdown(ctx context.Context) error {
-	if s.shuttingDown() {
+	if !s.beginShutdown() {
 		return ErrServerClosed
 	}
 
-	// Set the shutdown flag
-	s.inShutdown.Store(true)
-
 	// Close all listeners first to stop accepting new connections
 	s.listenerMu.Lock()
 	for ln := range s.listeners {
@@ -485,30 +786,26 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	}
 	s.listenerMu.Unlock()
 
-	connManager := s.connManager
-	s.connManager = nil
-
-	for conn := range connManager.connections {
+	for _, conn := range s.connManager.conns() {
 		// Send goaway to sessions concurrently; log potential errors.
-		go func(conn StreamConn) {
+		s.tasks.Go("GOAWAY sender", func() {
 			err := s.goAway(ctx, conn)
 			if logger := s.Logger; logger != nil && err != nil {
 				logger.Error("error sending GOAWAY to connection during shutdown", "error", err)
 			}
-		}(conn)
+		}, nil)
 	}
 
 	// Wait for all sessions to close
-	<-connManager.Done()
+	<-s.connManager.Done()
 
 	// Close WebTransport server (guard against panics from underlying implementations)
 	if s.WebTransportServer != nil {
 		done := make(chan struct{})
-		go func() {
-			defer func() { recover() }()
+		s.tasks.Go("WebTransport server closer", func() {
 			_ = s.WebTransportServer.Close()
 			close(done)
-		}()
+		}, nil)
 		select {
 		case <-done:
 		case <-time.After(100 * time.Millisecond):
@@ -532,18 +829,7 @@ func (s *Server) Shutdown(ctx context.Context) error {
 // closing the connection with a timeout error if needed.
 func (s *Server) goAway(ctx context.Context, conn StreamConn) error {
 	// Best-effort attempt to send a GOAWAY message.
-	stream, err := conn.OpenStream()
-	if err != nil {
-		return err
-	}
-	defer stream.Close()
-
-	err = message.StreamTypeGoaway.Encode(stream)
-	if err != nil {
-		return err
-	}
-	err = message.GoawayMessage{NewSessionURI: s.NextSessionURI}.Encode(stream)
-	if err != nil {
+	if err := sendGoAway(conn, s.NextSessionURI); err != nil {
 		return err
 	}
 
@@ -558,6 +844,22 @@ func (s *Server) goAway(ctx context.Context, conn StreamConn) error {
 	return nil
 }
 
+// sendGoAway sends a GOAWAY message with newURI on a new bidirectional
+// stream of conn.
+func sendGoAway(conn StreamConn, newURI string) error {
+	stream, err := conn.OpenStream()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	err = message.StreamTypeGoaway.Encode(stream)
+	if err != nil {
+		return err
+	}
+	return message.GoawayMessage{NewSessionURI: newURI}.Encode(stream)
+}
+
 func (s *Server) addListener(ln QUICListener) {
 	s.listenerMu.Lock()
 	defer s.listenerMu.Unlock()
@@ -584,6 +886,31 @@ func (s *Server) removeListener(ln QUICListener) {
 	}
 }
 
+// logTaskFailure logs the failure of a task of the server with its stack
+// trace.
+func (s *Server) logTaskFailure(err error) {
+	if s.Logger == nil {
+		return
+	}
+	if panicErr, ok := errors.AsType[*taskPanicError](err); ok {
+		s.Logger.Error("server goroutine failed", "error", err, "stack", string(panicErr.stack))
+		return
+	}
+	s.Logger.Error("server goroutine failed", "error", err)
+}
+
 func (s *Server) shuttingDown() bool {
 	return s.inShutdown.Load()
 }
+
+// beginShutdown marks the server as shutting down and cancels its context,
+// which stops the accept loops of the listeners. It reports false if the
+// server was already shutting down.
+func (s *Server) beginShutdown() bool {
+	if !s.inShutdown.CompareAndSwap(false, true) {
+		return false
+	}
+	s.init()
+	s.cancel(ErrServerClosed)
+	return true
+}
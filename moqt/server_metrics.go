@@ -0,0 +1,61 @@
+package moqt
+
+// ServerMetrics receives server-side telemetry from a Server and the sessions
+// it accepts, so that metrics exporters can observe sessions, subscriptions
+// and delivered data without wrapping handlers.
+//
+// Methods are called synchronously on the session and delivery paths and
+// must return quickly. Implementations must be safe for concurrent use.
+// Embed NopServerMetrics to implement only the methods of interest.
+type ServerMetrics interface {
+	// SessionStarted is called when a session is accepted.
+	// transport is "webtransport" or "quic".
+	SessionStarted(transport string)
+
+	// SessionEnded is called when an accepted session ends.
+	SessionEnded(transport string)
+
+	// SetupFailed is called when an incoming connection or WebTransport
+	// request is rejected before a session is established.
+	SetupFailed(transport string, err error)
+
+	// SubscriptionStarted is called when a peer subscribes to a track served
+	// by the session, before the track handler is called.
+	SubscriptionStarted(path BroadcastPath, name TrackName)
+
+	// SubscriptionEnded is called when the track handler of a subscription
+	// has returned and the subscription is closed.
+	SubscriptionEnded(path BroadcastPath, name TrackName)
+
+	// FrameSent is called for every frame written to a group of a served
+	// track. size is the frame payload length in bytes.
+	FrameSent(path BroadcastPath, name TrackName, size int)
+
+	// GroupReset is called when a group stream of a served track is canceled
+	// with CancelWrite.
+	GroupReset(path BroadcastPath, name TrackName, code GroupErrorCode)
+
+	// ControlMessageReceived is called for every control message received
+	// from the peer, such as a SUBSCRIBE or an ANNOUNCE. kind is one of the
+	// ControlMessage constants.
+	ControlMessageReceived(kind string)
+
+	// ControlRateExceeded is called when a session is closed because its
+	// peer sent more control messages than Config.ControlMessageRate allows.
+	ControlRateExceeded(transport string)
+}
+
+// NopServerMetrics is a ServerMetrics that discards all events.
+type NopServerMetrics struct{}
+
+func (NopServerMetrics) SessionStarted(string)                               {}
+func (NopServerMetrics) SessionEnded(string)                                 {}
+func (NopServerMetrics) SetupFailed(string, error)                           {}
+func (NopServerMetrics) SubscriptionStarted(BroadcastPath, TrackName)        {}
+func (NopServerMetrics) SubscriptionEnded(BroadcastPath, TrackName)          {}
+func (NopServerMetrics) FrameSent(BroadcastPath, TrackName, int)             {}
+func (NopServerMetrics) GroupReset(BroadcastPath, TrackName, GroupErrorCode) {}
+func (NopServerMetrics) ControlMessageReceived(string)                       {}
+func (NopServerMetrics) ControlRateExceeded(string)                          {}
+
+var _ ServerMetrics = NopServerMetrics{}
@@ -0,0 +1,140 @@
+package moqt
+
+import (
+	"bytes"
+	"crypto/tls"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServerMetrics struct {
+	NopServerMetrics
+
+	mu           sync.Mutex
+	started      []string
+	ended        []string
+	setupFailed  []string
+	frames       []int
+	resets       []GroupErrorCode
+	sessionEnded chan struct{}
+}
+
+func (m *fakeServerMetrics) SessionStarted(transport string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = append(m.started, transport)
+}
+
+func (m *fakeServerMetrics) SessionEnded(transport string) {
+	m.mu.Lock()
+	m.ended = append(m.ended, transport)
+	m.mu.Unlock()
+	if m.sessionEnded != nil {
+		close(m.sessionEnded)
+	}
+}
+
+func (m *fakeServerMetrics) SetupFailed(transport string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setupFailed = append(m.setupFailed, transport)
+}
+
+func (m *fakeServerMetrics) FrameSent(path BroadcastPath, name TrackName, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.frames = append(m.frames, size)
+}
+
+func (m *fakeServerMetrics) GroupReset(path BroadcastPath, name TrackName, code GroupErrorCode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resets = append(m.resets, code)
+}
+
+func TestServer_Metrics_SetupFailed(t *testing.T) {
+	tests := map[string]struct {
+		tls           *tls.ConnectionState
+		server        *Server
+		wantTransport string
+	}{
+		"no tls": {
+			server:        &Server{},
+			wantTransport: "quic",
+		},
+		"unsupported protocol": {
+			tls:           &tls.ConnectionState{NegotiatedProtocol: "unknown"},
+			server:        &Server{},
+			wantTransport: "quic",
+		},
+		"webtransport disabled": {
+			tls:           &tls.ConnectionState{NegotiatedProtocol: NextProtoH3},
+			server:        &Server{DisableWebTransport: true},
+			wantTransport: "webtransport",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			metrics := &fakeServerMetrics{}
+			tt.server.Metrics = metrics
+			conn := &FakeStreamConn{}
+			conn.TLSFunc = func() *tls.ConnectionState { return tt.tls }
+
+			err := tt.server.ServeQUICConn(conn)
+			require.Error(t, err)
+			assert.Equal(t, []string{tt.wantTransport}, metrics.setupFailed)
+			assert.Empty(t, metrics.started)
+		})
+	}
+}
+
+func TestServer_Metrics_NativeQUICSession(t *testing.T) {
+	metrics := &fakeServerMetrics{sessionEnded: make(chan struct{})}
+	s := &Server{
+		Metrics: metrics,
+		Handler: HandleFunc(func(sess *Session) {
+			assert.Equal(t, ServerMetrics(metrics), sess.serverMetrics)
+			_ = sess.CloseWithError(NoError, "")
+		}),
+	}
+
+	_ = s.ServeQUICConn(newTestNativeQUICConn(t))
+
+	select {
+	case <-metrics.sessionEnded:
+	case <-time.After(time.Second):
+		t.Fatal("SessionEnded was not reported")
+	}
+	assert.Equal(t, []string{"quic"}, metrics.started)
+	assert.Equal(t, []string{"quic"}, metrics.ended)
+	assert.Empty(t, metrics.setupFailed)
+}
+
+func TestTrackWriter_Metrics(t *testing.T) {
+	var buf bytes.Buffer
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{WriteFunc: buf.Write}, nil
+	}, func() {})
+	defer writer.Close()
+	metrics := &fakeServerMetrics{}
+	writer.metrics = metrics
+
+	group, err := writer.OpenGroup()
+	require.NoError(t, err)
+
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("hello"))
+	require.NoError(t, group.WriteFrame(frame))
+	group.CancelWrite(ExpiredGroupErrorCode)
+
+	assert.Equal(t, []int{5}, metrics.frames)
+	assert.Equal(t, []GroupErrorCode{ExpiredGroupErrorCode}, metrics.resets)
+}
@@ -2,8 +2,14 @@ package moqt
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
+	"math/big"
 	"net"
 	"net/http"
 	"testing"
@@ -84,6 +90,15 @@ func TestServer_ServeQUICConn_UnsupportedProtocol(t *testing.T) {
 	err := s.ServeQUICConn(conn)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unsupported protocol")
+	assertClosedWithCode(t, conn, UnsupportedVersionErrorCode)
+}
+
+// assertClosedWithCode asserts that conn was closed with code.
+func assertClosedWithCode(t *testing.T, conn *FakeStreamConn, code SessionErrorCode) {
+	t.Helper()
+	appErr, ok := errors.AsType[*transport.ApplicationError](context.Cause(conn.Context()))
+	require.True(t, ok, "connection should be closed")
+	assert.Equal(t, transport.ApplicationErrorCode(code), appErr.ErrorCode)
 }
 
 func TestServer_ServeQUICConn_WebTransport(t *testing.T) {
@@ -122,6 +137,275 @@ func TestServer_ServeQUICConn_NativeQUICWithoutHandlerReturnsError(t *testing.T)
 	assert.Contains(t, err.Error(), "no native QUIC handler configured")
 }
 
+func TestServer_ServeQUICConn_DisabledProtocol(t *testing.T) {
+	tests := map[string]struct {
+		server   *Server
+		protocol string
+	}{
+		"webtransport disabled": {
+			server:   &Server{DisableWebTransport: true, WebTransportServer: &FakeWebTransportServer{}},
+			protocol: NextProtoH3,
+		},
+		"native quic disabled": {
+			server:   &Server{DisableNativeQUIC: true},
+			protocol: NextProtoMOQ,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn := &FakeStreamConn{}
+			conn.TLSFunc = func() *tls.ConnectionState {
+				return &tls.ConnectionState{NegotiatedProtocol: tt.protocol}
+			}
+
+			err := tt.server.ServeQUICConn(conn)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "is disabled")
+			assertClosedWithCode(t, conn, UnsupportedVersionErrorCode)
+		})
+	}
+}
+
+func TestServer_nextProtos(t *testing.T) {
+	tests := map[string]struct {
+		server     *Server
+		configured []string
+		want       []string
+		wantErr    bool
+	}{
+		"defaults": {
+			server: &Server{},
+			want:   []string{VersionLite04Ext, NextProtoMOQ, NextProtoH3},
+		},
+		"native quic only": {
+			server: &Server{DisableWebTransport: true},
+			want:   []string{VersionLite04Ext, NextProtoMOQ},
+		},
+		"webtransport only": {
+			server: &Server{DisableNativeQUIC: true},
+			want:   []string{NextProtoH3},
+		},
+		"supported versions": {
+			server: &Server{Config: &Config{SupportedVersions: []string{VersionLite03, VersionLite04}}},
+			want:   []string{VersionLite03, VersionLite04, NextProtoH3},
+		},
+		"configured tokens filtered": {
+			server:     &Server{DisableNativeQUIC: true},
+			configured: []string{"custom", NextProtoMOQ, NextProtoH3},
+			want:       []string{"custom", NextProtoH3},
+		},
+		"both disabled": {
+			server:  &Server{DisableWebTransport: true, DisableNativeQUIC: true},
+			wantErr: true,
+		},
+		"configured tokens all disabled": {
+			server:     &Server{DisableWebTransport: true},
+			configured: []string{NextProtoH3},
+			wantErr:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tt.server.nextProtos(tt.configured)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServer_ListenAndServe_AdvertisesEnabledTransports(t *testing.T) {
+	var gotTLS *tls.Config
+	s := &Server{
+		Addr:              "localhost:0",
+		TLSConfig:         &tls.Config{},
+		DisableNativeQUIC: true,
+		ListenFunc: func(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error) {
+			gotTLS = tlsConfig
+			return nil, errors.New("listen failed")
+		},
+	}
+
+	assert.Error(t, s.ListenAndServe())
+	require.NotNil(t, gotTLS)
+	assert.Equal(t, []string{NextProtoH3}, gotTLS.NextProtos)
+}
+
+func TestServer_ListenAndServe_AllTransportsDisabled(t *testing.T) {
+	called := false
+	s := &Server{
+		Addr:                "localhost:0",
+		TLSConfig:           &tls.Config{},
+		DisableWebTransport: true,
+		DisableNativeQUIC:   true,
+		ListenFunc: func(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error) {
+			called = true
+			return nil, errors.New("listen failed")
+		},
+	}
+
+	err := s.ListenAndServe()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "both WebTransport and native QUIC are disabled")
+	assert.False(t, called)
+}
+
+func TestServer_ListenAndServe_NoEnabledProtocol(t *testing.T) {
+	called := false
+	s := &Server{
+		Addr:                "localhost:0",
+		TLSConfig:           &tls.Config{NextProtos: []string{NextProtoH3}},
+		DisableWebTransport: true,
+		ListenFunc: func(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error) {
+			called = true
+			return nil, errors.New("listen failed")
+		},
+	}
+
+	err := s.ListenAndServe()
+	assert.ErrorContains(t, err, "no enabled front-end")
+	assert.False(t, called)
+}
+
+func TestServer_ServeQUICListener_AllTransportsDisabled(t *testing.T) {
+	accepted := false
+	s := &Server{DisableWebTransport: true, DisableNativeQUIC: true}
+	ln := &FakeEarlyListener{AcceptFunc: func(ctx context.Context) (StreamConn, error) {
+		accepted = true
+		return nil, errors.New("accept failed")
+	}}
+
+	err := s.ServeQUICListener(ln)
+	assert.False(t, accepted)
+	assert.ErrorContains(t, err, "both WebTransport and native QUIC are disabled")
+}
+
+func TestServer_ServeQUICListener_InvalidConfig(t *testing.T) {
+	accepted := false
+	s := &Server{Config: &Config{MaxQueuedGroups: -1}}
+	ln := &FakeEarlyListener{AcceptFunc: func(ctx context.Context) (StreamConn, error) {
+		accepted = true
+		return nil, errors.New("accept failed")
+	}}
+
+	err := s.ServeQUICListener(ln)
+	assert.False(t, accepted)
+	assert.ErrorContains(t, err, "MaxQueuedGroups")
+}
+
+func TestServer_serverTLSConfig(t *testing.T) {
+	errConfig := errors.New("config")
+	hostCert := tls.Certificate{Certificate: [][]byte{[]byte("host")}}
+	perDomain := &tls.Config{
+		NextProtos: []string{"custom", NextProtoH3},
+		ClientAuth: tls.RequireAnyClientCert,
+	}
+	s := &Server{
+		DisableWebTransport: true,
+		VirtualHosts:        []*VirtualHost{{Host: "live.example.com", Certificates: []tls.Certificate{hostCert}}},
+	}
+
+	base := &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			switch hello.ServerName {
+			case "live.example.com":
+				return perDomain, nil
+			case "bad.example.com":
+				return nil, errConfig
+			}
+			return nil, nil
+		},
+	}
+	tlsConfig, err := s.serverTLSConfig(base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{VersionLite04Ext, NextProtoMOQ}, tlsConfig.NextProtos)
+	assert.Nil(t, base.NextProtos, "the configuration must not be modified")
+
+	// The configuration of a connection is adjusted like the one of the
+	// listener.
+	config, err := tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "live.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"custom"}, config.NextProtos)
+	assert.Equal(t, tls.RequireAnyClientCert, config.ClientAuth)
+	assert.Nil(t, config.GetConfigForClient)
+	cert, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: "live.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, hostCert.Certificate, cert.Certificate)
+	assert.Equal(t, []string{"custom", NextProtoH3}, perDomain.NextProtos, "the configuration must not be modified")
+
+	config, err = tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "other.example.com"})
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	_, err = tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "bad.example.com"})
+	assert.ErrorIs(t, err, errConfig)
+}
+
+func TestServer_ListenAndServe_GetConfigForClient(t *testing.T) {
+	newCert := func(name string) tls.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			DNSNames:     []string{name},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	defaultCert, liveCert := newCert("default.example.com"), newCert("live.example.com")
+
+	addr, listen := listenLoopback(t)
+	server := &Server{
+		Addr:       addr,
+		ListenFunc: listen,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{defaultCert},
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				if hello.ServerName == "live.example.com" {
+					return &tls.Config{Certificates: []tls.Certificate{liveCert}}, nil
+				}
+				return nil, nil
+			},
+		},
+		Handler: HandleFunc(func(sess *Session) {
+			<-sess.Context().Done()
+		}),
+	}
+	go func() {
+		_ = server.ListenAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	for _, name := range []string{"default.example.com", "live.example.com"} {
+		dialer := &Dialer{TLSConfig: &tls.Config{ServerName: name, InsecureSkipVerify: true}}
+		var sess *Session
+		require.Eventually(t, func() bool {
+			ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+			defer cancel()
+			var err error
+			sess, err = dialer.Dial(ctx, "moqt://"+addr, nil)
+			return err == nil
+		}, 5*time.Second, 20*time.Millisecond)
+
+		state := sess.ConnectionState().TLS
+		require.NotNil(t, state)
+		assert.Equal(t, name, state.PeerCertificates[0].Subject.CommonName)
+		assert.Equal(t, VersionLite04Ext, state.NegotiatedProtocol)
+		_ = sess.CloseWithError(NoError, "")
+	}
+}
+
 func TestServer_ListenAndServe_RequiresTLSConfig(t *testing.T) {
 	s := &Server{}
 	err := s.ListenAndServe()
@@ -150,7 +434,7 @@ func TestServer_ListenAndServe_ConfiguresDefaultsBeforeListen(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to start QUIC listener")
 	assert.True(t, called)
 	assert.NotNil(t, gotTLS)
-	assert.Equal(t, []string{NextProtoH3, NextProtoMOQ}, gotTLS.NextProtos)
+	assert.Equal(t, []string{VersionLite04Ext, NextProtoMOQ, NextProtoH3}, gotTLS.NextProtos)
 	assert.NotNil(t, gotQUIC)
 	assert.True(t, gotQUIC.EnableDatagrams)
 	assert.True(t, gotQUIC.EnableStreamResetPartialDelivery)
@@ -192,6 +476,95 @@ func TestServer_Close_ClosesListenersAndWTServer(t *testing.T) {
 	assert.True(t, closed)
 }
 
+func TestServer_Close_CancelsAccept(t *testing.T) {
+	s := &Server{}
+	ln := &FakeEarlyListener{
+		AcceptFunc: func(ctx context.Context) (StreamConn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	errCh := make(chan error, 1)
+	s.init()
+	go func() {
+		errCh <- s.ServeQUICListener(ln)
+	}()
+	require.Eventually(t, func() bool {
+		s.listenerMu.RLock()
+		defer s.listenerMu.RUnlock()
+		return len(s.listeners) == 1
+	}, time.Second, time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, <-errCh, ErrServerClosed)
+	assert.Less(t, time.Since(start), 50*time.Millisecond, "Accept should be canceled without polling")
+	assert.ErrorIs(t, context.Cause(s.ctx), ErrServerClosed)
+}
+
+func TestServer_Close_ClosesSessions(t *testing.T) {
+	handlerDone := make(chan struct{})
+	s := &Server{
+		Handler: HandleFunc(func(sess *Session) {
+			<-sess.Context().Done()
+			close(handlerDone)
+		}),
+	}
+	conn := newTestNativeQUICConn(t)
+	go func() {
+		_ = s.ServeQUICConn(conn)
+	}()
+	require.Eventually(t, func() bool {
+		s.init()
+		return s.connManager.countSessions() == 1
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, s.Close())
+	select {
+	case <-handlerDone:
+	case <-time.After(time.Second):
+		t.Fatal("session was not closed")
+	}
+	appErr, ok := errors.AsType[*transport.ApplicationError](context.Cause(conn.Context()))
+	require.True(t, ok)
+	assert.Equal(t, transport.ApplicationErrorCode(NoError), appErr.ErrorCode)
+}
+
+// blockingAuthorizer blocks in AuthorizeSession until its context ends.
+type blockingAuthorizer struct {
+	AllowAuthorizer
+	started chan struct{}
+}
+
+func (a *blockingAuthorizer) AuthorizeSession(ctx context.Context, r *SessionAuthRequest) (context.Context, error) {
+	close(a.started)
+	<-ctx.Done()
+	return nil, context.Cause(ctx)
+}
+
+func TestServer_Close_CancelsSessionSetup(t *testing.T) {
+	auth := &blockingAuthorizer{started: make(chan struct{})}
+	s := &Server{
+		Handler:    HandleFunc(func(sess *Session) {}),
+		Authorizer: auth,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.ServeQUICConn(newTestNativeQUICConn(t))
+	}()
+	<-auth.started
+
+	require.NoError(t, s.Close())
+	select {
+	case err := <-errCh:
+		assert.ErrorIs(t, err, ErrServerClosed)
+	case <-time.After(time.Second):
+		t.Fatal("session setup was not canceled")
+	}
+}
+
 func TestServer_Shutdown_NoSessions(t *testing.T) {
 	s := &Server{}
 	s.init()
@@ -211,7 +584,7 @@ func TestServer_addRemoveSession_ShutdownCompletesWhenLastSessionLeaves(t *testi
 
 	conn := &FakeStreamConn{}
 
-	sess := newSession(conn, nil, nil, nil, nil, nil, nil)
+	sess := newSession(conn, nil, nil, nil, nil, nil, nil, nil)
 	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
 
 	s.connManager.addConn(conn)
@@ -454,9 +827,8 @@ func TestServer_ServeQUICListener_AcceptsAndServesConn(t *testing.T) {
 		t.Fatal("timed out waiting for connection to be served")
 	}
 
-	// Shut down the server to stop the listener loop
-	s.inShutdown.Store(true)
-	ln.Close()
+	// Close the server: the context of Accept is canceled right away.
+	assert.NoError(t, s.Close())
 
 	select {
 	case err := <-errCh:
@@ -486,6 +858,7 @@ func TestServer_ServeQUICConn_NilTLS(t *testing.T) {
 	err := s.ServeQUICConn(conn)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "does not have TLS information")
+	assertClosedWithCode(t, conn, UnsupportedVersionErrorCode)
 }
 
 func TestServer_goAway_SendsGoawayMessage(t *testing.T) {
@@ -617,7 +990,7 @@ func TestServer_ListenAndServeTLS_ConfiguresDefaults(t *testing.T) {
 	assert.Error(t, err) // listen failed
 	assert.True(t, called)
 	assert.NotNil(t, gotTLS)
-	assert.Equal(t, []string{NextProtoH3, NextProtoMOQ}, gotTLS.NextProtos)
+	assert.Equal(t, []string{NextProtoMOQ, NextProtoH3}, gotTLS.NextProtos)
 	assert.NotNil(t, gotQUIC)
 	assert.True(t, gotQUIC.EnableDatagrams)
 	assert.True(t, gotQUIC.EnableStreamResetPartialDelivery)
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,18 +18,21 @@ import (
 	"github.com/qumo-dev/gomoqt/transport"
 )
 
-const (
-	moqtVersion = "moq-lite-04"
-)
-
 // Session represents an active MOQ session over a QUIC connection.
 // It manages bidirectional and unidirectional streams, subscriptions, and
 // announcements for a single peer connection.
+//
+// All exported methods are safe for concurrent use by multiple goroutines.
+// Each subscription, fetch, and announce request uses its own stream, so
+// concurrent calls do not block each other beyond stream creation.
 type Session struct {
 	ctx    context.Context // Context for the session
 	config *Config
 
-	wg sync.WaitGroup // WaitGroup for session cleanup
+	// tasks and streamTasks own the goroutines of the session; see
+	// supervisor.go.
+	tasks       taskGroup
+	streamTasks taskGroup
 
 	conn StreamConn
 
@@ -45,11 +50,53 @@ type Session struct {
 	onGoaway     func(newSessionURI string)
 	logger       *slog.Logger
 
+	// metrics is set by Dialer before the session is returned to the caller.
+	metrics ClientMetrics
+
+	// serverMetrics receives the telemetry of sessions accepted by a Server.
+	serverMetrics ServerMetrics
+
+	// tracer, if set, receives the spans of the session. traceCtx carries
+	// the session setup span and parents the spans of incoming subscriptions.
+	tracer   Tracer
+	traceCtx context.Context
+
+	// authorizer, if set, authorizes incoming subscriptions. authToken is
+	// the token the session was authorized with.
+	authorizer Authorizer
+	authToken  string
+
+	// acl, if set, checks the broadcasts announced by the peer and its
+	// subscriptions.
+	acl ACL
+
+	// controlLimit, if set, limits the rate of the control messages of the
+	// peer.
+	controlLimit *controlLimiter
+
+	// early is the connection of a dialed session that may be in 0-RTT, or
+	// nil; see awaitHandshake.
+	early transport.EarlyDataConn
+
+	// version is the negotiated version of MOQ, and wireVersion the encoding
+	// of its messages.
+	version     string
+	wireVersion message.Version
+
+	// resumeToken is the resumption token issued for the session over
+	// WebTransport, or "".
+	resumeToken string
+
 	isTerminating atomic.Bool
-	// sessErr       error
+	isClosed      atomic.Bool
 
 	connManager *connManager
 
+	// probeChMu guards sends on, and the closing of, probeResponseCh and
+	// probeTargetsCh so that late notifications never hit a closed channel.
+	probeChMu     sync.Mutex
+	probeChClosed bool
+
 	// probe stream state (subscriber side, lazily initialized)
 	outgoingProbeMu     sync.Mutex
 	outgoingProbeStream transport.Stream
@@ -60,7 +107,97 @@ type Session struct {
 	incomingProbeStream transport.Stream
 	probeTargetsCh      chan ProbeResult
 
+	// stats stream state (subscriber side, lazily initialized)
+	outgoingStatsMu     sync.Mutex
+	outgoingStatsStream transport.Stream
+
 	bitrateTracker bitrateTracker
+
+	// uniStreamSlots bounds the number of unidirectional streams whose
+	// header is being decoded, so that a flood of group streams cannot
+	// grow the number of goroutines without limit.
+	uniStreamSlots chan struct{}
+
+	// controlPriority holds back the acceptance of unidirectional streams
+	// while a bidirectional stream waits for its opening message.
+	controlPriority *controlPriority
+
+	// recentErrors keeps the most recent logged errors for DebugDump.
+	recentErrors errorHistory
+
+	// qlog records MOQT events if Config.QLogDirFunc is set, or is nil.
+	qlog *qlogWriter
+
+	// scheduler schedules the frames of the group streams of all
+	// subscriptions served by the session.
+	scheduler *sendScheduler
+
+	// bandwidth paces the frames of all subscriptions served by the
+	// session; see SetBandwidthLimit.
+	bandwidth pacer
+
+	// datagramSize holds the callbacks of OnMaxDatagramSizeChange.
+	datagramSize datagramSizeWatcher
+
+	// keepAlive tracks the liveness of the peer if Config.KeepAliveInterval
+	// or Config.IdleTimeout is set.
+	keepAlive keepAlive
+
+	// startTime is the time the session was set up. transport and path
+	// describe sessions accepted by a Server; see SessionInfo.
+	startTime time.Time
+	transport string
+	path      string
+
+	// isDraining is set when the Server drains the session: new
+	// subscriptions are rejected, and drained is closed once the session
+	// has no subscription left.
+	isDraining atomic.Bool
+	drainMu    sync.Mutex
+	drained    chan struct{}
+
+	// goAwaySent is set by the first call to GoAway.
+	goAwaySent atomic.Bool
+}
+
+const (
+	// maxPendingUniStreams is the capacity of Session.uniStreamSlots.
+	maxPendingUniStreams = 64
+
+	// streamHeaderTimeout bounds the time a peer may take to send the
+	// header of a stream, and the opening message of a bidirectional one.
+	streamHeaderTimeout = 5 * time.Second
+)
+
+// sessionOptions holds the optional telemetry receivers and authorization
+// state of a session. They are passed to newSession so that they are set
+// before streams are accepted.
+type sessionOptions struct {
+	serverMetrics ServerMetrics
+	tracer        Tracer
+	traceCtx      context.Context
+
+	// authorizer authorizes incoming subscriptions; authToken is the token
+	// the session was authorized with and authCtx the context returned by
+	// AuthorizeSession or Server.SessionContext, whose values the session
+	// context carries.
+	authorizer Authorizer
+	authToken  string
+	authCtx    context.Context
+
+	// acl checks announced broadcasts and incoming subscriptions.
+	acl ACL
+
+	// resumeToken is the resumption token issued for the session.
+	resumeToken string
+
+	// transport is "quic" or "webtransport", and path the request path of a
+	// WebTransport session.
+	transport string
+	path      string
+
+	// early is the connection of a session dialed with 0-RTT.
+	early transport.EarlyDataConn
 }
 
 func newSession(
@@ -71,6 +208,7 @@ func newSession(
 	fetchHandler FetchHandler,
 	onGoaway func(newSessionURI string),
 	logger *slog.Logger,
+	opts *sessionOptions,
 ) *Session {
 	if mux == nil {
 		mux = DefaultMux
@@ -90,31 +228,93 @@ func newSession(
 		connManager:     manager,
 		probeResponseCh: make(chan ProbeResult, 1), // latest-value semantics
 		probeTargetsCh:  make(chan ProbeResult, 1), // latest-value semantics
+		uniStreamSlots:  make(chan struct{}, maxPendingUniStreams),
+		controlPriority: newControlPriority(),
 		bitrateTracker: bitrateTracker{
 			maxAge:   config.probeMaxAge(),
 			maxDelta: config.probeMaxDelta(),
 		},
+		qlog:      newQLogWriter(config),
+		scheduler: newSendScheduler(config.priorityPolicy()),
+
+		controlLimit: config.controlLimiter(),
+		startTime:    time.Now(),
+	}
+	sess.version = negotiatedVersion(conn)
+	sess.wireVersion = wireVersion(sess.version)
+
+	if opts != nil {
+		sess.serverMetrics = opts.serverMetrics
+		sess.tracer = opts.tracer
+		sess.traceCtx = opts.traceCtx
+		sess.authorizer = opts.authorizer
+		sess.acl = opts.acl
+		sess.authToken = opts.authToken
+		sess.resumeToken = opts.resumeToken
+		sess.transport = opts.transport
+		sess.path = opts.path
+		sess.early = opts.early
+		if opts.authCtx != nil {
+			sess.ctx = authContext{Context: connCtx, values: opts.authCtx}
+		}
+	}
+	if sess.traceCtx == nil {
+		sess.traceCtx = context.Background()
 	}
+	// The setup span parents spans for the lifetime of the session.
+	sess.traceCtx = context.WithoutCancel(sess.traceCtx)
 
-	if manager != nil {
-		manager.addConn(conn)
+	if sess.qlog != nil {
+		context.AfterFunc(connCtx, func() {
+			if err := sess.qlog.close(); err != nil {
+				sess.logError("failed to write qlog", err)
+			}
+		})
+	}
+
+	sess.tasks.fail = sess.failSession
+	sess.streamTasks.fail = func(err error) {
+		sess.logTaskFailure("stream handler failed", err)
 	}
 
 	if provider, ok := conn.(probeStatsProvider); ok {
-		sess.wg.Go(func() {
+		sess.tasks.Go("bitrate detector", func() {
 			sess.detectBitrateChanges(provider)
-		})
+		}, nil)
+	}
+
+	// Peers of versions without the Ping stream are left to the QUIC idle
+	// timeout and keep-alive.
+	if interval := config.keepAliveInterval(); interval > 0 && sess.wireVersion.Extended() {
+		sess.keepAlive.heard(time.Now())
+		sess.tasks.Go("keep-alive loop", func() {
+			sess.runKeepAlive(interval, config.idleTimeout())
+		}, nil)
 	}
 
 	// Listen bidirectional streams
-	sess.wg.Go(func() {
-		sess.handleBiStreams()
-	})
+	sess.tasks.Go("bidirectional stream accept loop", sess.handleBiStreams, nil)
 
 	// Listen unidirectional streams
-	sess.wg.Go(func() {
-		sess.handleUniStreams()
-	})
+	sess.tasks.Go("unidirectional stream accept loop", sess.handleUniStreams, nil)
+
+	// Listen datagrams
+	if dc, ok := conn.(transport.DatagramConn); ok {
+		sess.tasks.Go("datagram receive loop", func() {
+			sess.handleDatagrams(dc)
+		}, nil)
+	}
+	if dc, ok := conn.(transport.DatagramSizeConn); ok {
+		sess.tasks.Go("datagram size watcher", func() {
+			sess.watchDatagramSize(dc)
+		}, nil)
+	}
+
+	if manager != nil {
+		// The session is registered once set up, as Server.Sessions
+		// exposes it.
+		manager.addSession(conn, sess)
+	}
 
 	return sess
 }
@@ -128,23 +328,78 @@ func (s *Session) logError(msg string, err error, args ...any) {
 		return
 	}
 
+	s.recentErrors.record(msg, err)
+
 	if s.logger != nil {
 		s.logger.Error(msg, append(args, "error", err)...)
 	}
 }
 
+// failSession tears the session down after one of its own goroutines
+// failed. The session cannot continue without its loops, so the connection
+// is closed as CloseWithError would, without waiting for them.
+func (s *Session) failSession(err error) {
+	s.logTaskFailure("session goroutine failed", err)
+	s.isTerminating.Store(true)
+	_ = s.conn.CloseWithError(transport.ConnErrorCode(InternalSessionErrorCode), "internal error")
+}
+
+// logTaskFailure logs the failure of a task with its stack trace.
+func (s *Session) logTaskFailure(msg string, err error) {
+	if panicErr, ok := errors.AsType[*taskPanicError](err); ok {
+		s.logError(msg, err, "stack", string(panicErr.stack))
+		return
+	}
+	s.logError(msg, err)
+}
+
+// startQLog creates the qlog trace of the session, if enabled. The session
+// does not know its perspective, so it is started by the Dialer or Server.
+func (s *Session) startQLog(vantagePoint string) {
+	if s.qlog == nil {
+		return
+	}
+	if err := s.qlog.start(vantagePoint, s.ConnectionState()); err != nil {
+		s.logError("failed to create qlog file", err)
+	}
+}
+
 // Context returns the session's context which is canceled when the session
 // terminates. Use it to observe session lifecycle and cancellation.
 func (s *Session) Context() context.Context {
 	return s.ctx
 }
 
+// Value returns the value associated with key in the session context, or
+// nil, like Context().Value. The values are those attached by the
+// Authorizer or Server.SessionContext when the session was accepted, and
+// those of the connection context.
+func (s *Session) Value(key any) any {
+	return s.ctx.Value(key)
+}
+
 // ConnectionState returns connection metadata for the session.
 func (s *Session) ConnectionState() ConnectionState {
-	return ConnectionState{
-		Version: moqtVersion,
+	state := ConnectionState{
+		Version: s.version,
 		TLS:     s.conn.TLS(),
 	}
+	if conn, ok := s.conn.(transport.EarlyDataConn); ok {
+		state.Used0RTT = conn.Used0RTT()
+	}
+	return state
+}
+
+// Extensions reports whether the session negotiated VersionLite04Ext, whose
+// messages carry the extension fields of this package. Without them, the
+// peer receives no subscription tokens, start positions, parameters or
+// visibility, no broadcast IDs or track metadata, and no group timestamps;
+// subgroups cannot be opened, and groups carry no extension headers or
+// checksums. Nor are the stats, track status and Ping streams opened or
+// accepted: TrackReader.ReportStats and TrackStatus fail, and keep-alive is
+// left to QUIC.
+func (s *Session) Extensions() bool {
+	return s.wireVersion.Extended()
 }
 
 // LocalAddr returns the local network address.
@@ -181,8 +436,10 @@ func (s *Session) Stats() SessionStats {
 }
 
 // CloseWithError closes the session with an error code and message.
+// It is safe to call concurrently and more than once; only the first call
+// closes the connection and later calls return nil.
 func (s *Session) CloseWithError(code SessionErrorCode, msg string) error {
-	if s.terminating() {
+	if !s.isClosed.CompareAndSwap(false, true) {
 		return nil
 	}
 	s.isTerminating.Store(true)
@@ -198,11 +455,14 @@ func (s *Session) CloseWithError(code SessionErrorCode, msg string) error {
 		return fmt.Errorf("session termination failed: %w", err)
 	}
 
-	// Wait for finishing handling streams
-	s.wg.Wait()
+	// Wait for the session's loops. Stream handlers end with their streams.
+	s.tasks.Wait()
 
+	s.probeChMu.Lock()
+	s.probeChClosed = true
 	close(s.probeResponseCh)
 	close(s.probeTargetsCh)
+	s.probeChMu.Unlock()
 
 	if s.connManager != nil {
 		connManager := s.connManager
@@ -214,9 +474,59 @@ func (s *Session) CloseWithError(code SessionErrorCode, msg string) error {
 	return nil
 }
 
+// CloseWithApplicationError closes the session with an application-defined
+// error code, mapped onto a wire code by Config.ErrorCodes.
+func (s *Session) CloseWithApplicationError(code uint32, msg string) error {
+	wire, err := s.config.errorCodes().WireCode(SessionErrorSpace, code)
+	if err != nil {
+		return err
+	}
+	return s.CloseWithError(SessionErrorCode(wire), msg)
+}
+
+// ApplicationError returns the application-defined error code carried by an
+// error returned by the session or its tracks and groups, decoded with
+// Config.ErrorCodes. It returns false if the code is protocol-defined.
+func (s *Session) ApplicationError(err error) (ApplicationErrorCode, bool) {
+	return s.config.errorCodes().ApplicationError(err)
+}
+
+// GoAway sends a GOAWAY message with newSessionURI, the URI at which the
+// peer may reconnect, or "" to let it reconnect where it connected. The
+// session stays open so that its subscriptions can finish, but rejects new
+// incoming subscriptions with SubscribeErrorCodeGoingAway; the peer is
+// expected to migrate and close it. Calls after the first return nil
+// without sending another GOAWAY.
+//
+// Server.Shutdown and Server.Drain send a GOAWAY to all sessions.
+func (s *Session) GoAway(newSessionURI string) error {
+	if s.isClosed.Load() || s.ctx.Err() != nil {
+		return ErrClosedSession
+	}
+	if !s.goAwaySent.CompareAndSwap(false, true) {
+		return nil
+	}
+	s.startDrain()
+
+	if err := sendGoAway(s.conn, newSessionURI); err != nil {
+		if appErr, ok := errors.AsType[*transport.ApplicationError](err); ok {
+			return &SessionError{ApplicationError: appErr}
+		}
+		return fmt.Errorf("failed to send GOAWAY: %w", err)
+	}
+	return nil
+}
+
 // Subscribe sends SUBSCRIBE and waits for SUBSCRIBE_OK.
 // ctx is used while opening the stream, sending SUBSCRIBE, and waiting for the response.
 // If config is nil, a zero-value SubscribeConfig is used.
+// Subscribe is safe for concurrent use; each call opens its own stream.
+// Before the handshake of a session dialed with 0-RTT completes, SUBSCRIBE
+// is sent in early data, and sent again if the server rejects it. A
+// SUBSCRIBE carrying an AuthToken waits for the handshake instead, as early
+// data can be replayed. Subscribe returns an error without subscribing if
+// config sets extension fields the session did not negotiate; see
+// SubscribeConfig.
 func (s *Session) Subscribe(ctx context.Context, path BroadcastPath, name TrackName, config *SubscribeConfig) (*TrackReader, error) {
 	if ctx == nil {
 		return nil, errors.New("nil context")
@@ -233,9 +543,43 @@ func (s *Session) Subscribe(ctx context.Context, path BroadcastPath, name TrackN
 	if config == nil {
 		config = &SubscribeConfig{}
 	}
+	if err := s.config.parameterRegistry().Check(config.Parameters); err != nil {
+		return nil, err
+	}
+	if fields := config.extensionFields(); len(fields) != 0 && !s.wireVersion.Extended() {
+		return nil, fmt.Errorf("%w: %s set", errSubscribeExtensionsUnsupported, strings.Join(fields, ", "))
+	}
+
+	if config.AuthToken != "" {
+		// A replayed token would authorize the attacker's subscription.
+		if err := s.awaitHandshake(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	early := s.inEarlyData()
+	track, err := s.subscribe(ctx, path, name, config)
+	if err != nil && early && s.earlyDataRejected(ctx) {
+		// The server rejected 0-RTT, so SUBSCRIBE never reached it.
+		return s.subscribe(ctx, path, name, config)
+	}
+	return track, err
+}
+
+// errSubscribeExtensionsUnsupported is returned when a subscription sets
+// extension fields on a session whose version cannot carry them.
+var errSubscribeExtensionsUnsupported = errors.New("moqt: subscription extensions require VersionLite04Ext")
 
+// subscribe opens a subscribe stream for Subscribe.
+func (s *Session) subscribe(ctx context.Context, path BroadcastPath, name TrackName, config *SubscribeConfig) (_ *TrackReader, err error) {
 	id := s.nextSubscribeID()
 
+	if s.tracer != nil {
+		var end func(error)
+		ctx, end = s.tracer.StartSubscribe(ctx, path, name, false)
+		defer func() { end(err) }()
+	}
+
 	stream, err := s.conn.OpenStream()
 	if err != nil {
 		if appErr, ok := errors.AsType[*transport.ApplicationError](err); ok {
@@ -258,7 +602,7 @@ func (s *Session) Subscribe(ctx context.Context, path BroadcastPath, name TrackN
 		return nil, fmt.Errorf("failed to encode stream type message: %w", err)
 	}
 
-	err = message.SubscribeMessage{
+	sm := message.SubscribeMessage{
 		SubscribeID:          uint64(id),
 		BroadcastPath:        string(path),
 		TrackName:            string(name),
@@ -267,7 +611,14 @@ func (s *Session) Subscribe(ctx context.Context, path BroadcastPath, name TrackN
 		SubscriberMaxLatency: config.MaxLatency,
 		StartGroup:           groupSequenceToWire(config.StartGroup),
 		EndGroup:             groupSequenceToWire(config.EndGroup),
-	}.Encode(stream)
+		AuthToken:            config.AuthToken,
+		Start:                uint64(config.Start),
+		Parameters:           message.Parameters(config.Parameters),
+		StartTime:            timeToWire(config.StartTime),
+		StartBehind:          durationToWire(config.StartBehind),
+		Version:              s.wireVersion,
+	}
+	err = sm.Encode(stream)
 	if err != nil {
 		if strErr, ok := errors.AsType[*transport.StreamError](err); ok && strErr.Remote {
 			stream.CancelRead(strErr.ErrorCode)
@@ -280,11 +631,41 @@ func (s *Session) Subscribe(ctx context.Context, path BroadcastPath, name TrackN
 
 		return nil, fmt.Errorf("failed to encode SUBSCRIBE message: %w", err)
 	}
+	s.qlog.subscribe(true, sm)
 
 	substr := newSendSubscribeStream(id, stream, config)
+	substr.version = s.wireVersion
 
 	track := newTrackReader(path, name, substr, func() { s.removeTrackReader(id) })
+	track.metrics = s.metrics
+	track.qlog = s.qlog
+	if s.tracer != nil {
+		track.tracer = s.tracer
+		track.traceCtx = context.WithoutCancel(ctx)
+	}
+	track.maxQueued = s.config.maxQueuedGroups()
+	track.errorCodes = s.config.errorCodes()
+	track.sessionInterceptor = s.config.readFrameInterceptor()
+	track.fetchFunc = s.Fetch
+	track.reportStatsFunc = func(stats TrackStats) error { return s.reportTrackStats(id, stats) }
+	track.tasks = &s.streamTasks
+	substr.onDropFunc = func(drop SubscribeDrop) {
+		track.drops.record(DropEvent{
+			Reason:     subscribeDropReason(drop.ErrorCode),
+			StartGroup: drop.StartGroup,
+			EndGroup:   drop.EndGroup,
+		})
+		track.retransmitDropped(drop.StartGroup, drop.EndGroup)
+		if s.metrics != nil {
+			s.metrics.GroupGap(path, name, drop)
+		}
+	}
 	s.addTrackReader(id, track)
+	defer func() {
+		if err != nil {
+			s.removeTrackReader(id)
+		}
+	}()
 	ctx, cancel := context.WithTimeout(ctx, s.timeout())
 	defer cancel()
 	if deadline, ok := ctx.Deadline(); ok {
@@ -317,7 +698,9 @@ func (s *Session) Subscribe(ctx context.Context, path BroadcastPath, name TrackN
 		StartGroup: groupSequenceFromWire(okMsg.StartGroup),
 		EndGroup:   groupSequenceFromWire(okMsg.EndGroup),
 	})
-	go substr.readSubscribeResponses()
+	s.streamTasks.Go("subscribe response reader", substr.readSubscribeResponses, func() {
+		cancelStreamWithError(stream, transport.StreamErrorCode(SubscribeErrorCodeInternal))
+	})
 
 	return track, nil
 }
@@ -325,7 +708,11 @@ func (s *Session) Subscribe(ctx context.Context, path BroadcastPath, name TrackN
 // nextSubscribeID atomically increments and returns the next SubscribeID for new subscriptions.
 func (s *Session) nextSubscribeID() SubscribeID {
 	// Increment and return the previous value atomically
-	return SubscribeID(s.subscribeIDCounter.Add(1))
+	id := SubscribeID(s.subscribeIDCounter.Add(1))
+	if invariantsEnabled && id == 0 {
+		invariantViolated("subscribe ID counter wrapped")
+	}
+	return id
 }
 
 func (s *Session) timeout() time.Duration {
@@ -337,7 +724,7 @@ func (s *Session) Fetch(req *FetchRequest) (*GroupReader, error) {
 		return nil, ErrClosedSession
 	}
 
-	stream, err := s.conn.OpenStream()
+	stream, err := s.openStream()
 	if err != nil {
 		if appErr, ok := errors.AsType[*transport.ApplicationError](err); ok {
 			return nil, &SessionError{
@@ -379,6 +766,8 @@ func (s *Session) Fetch(req *FetchRequest) (*GroupReader, error) {
 	}
 
 	group := newGroupReader(req.GroupSequence, stream, nil)
+	group.interceptor = s.config.readFrameInterceptor()
+	group.errorCodes = s.config.errorCodes()
 
 	context.AfterFunc(req.Context(), func() {
 		// Cancel the stream when the context is done
@@ -396,7 +785,7 @@ func (sess *Session) AcceptAnnounce(prefix string) (*AnnouncementReader, error)
 		return nil, ErrClosedSession
 	}
 
-	stream, err := sess.conn.OpenStream()
+	stream, err := sess.openStream()
 	if err != nil {
 		if appErr, ok := errors.AsType[*transport.ApplicationError](err); ok {
 			return nil, &SessionError{
@@ -421,10 +810,11 @@ func (sess *Session) AcceptAnnounce(prefix string) (*AnnouncementReader, error)
 		return nil, fmt.Errorf("failed to encode stream type message: %w", err)
 	}
 
-	err = message.AnnounceInterestMessage{
+	aim := message.AnnounceInterestMessage{
 		BroadcastPathPrefix: prefix,
 		ExcludeHop:          sess.mux.hopID,
-	}.Encode(stream)
+	}
+	err = aim.Encode(stream)
 	if err != nil {
 		if strErr, ok := errors.AsType[*transport.StreamError](err); ok {
 			cancelStreamWithError(stream, transport.StreamErrorCode(AnnounceErrorCodeInternal))
@@ -438,7 +828,81 @@ func (sess *Session) AcceptAnnounce(prefix string) (*AnnouncementReader, error)
 		return nil, fmt.Errorf("failed to send ANNOUNCE_INTEREST message: %w", err)
 	}
 
-	return newAnnouncementReader(stream, prefix, nil), nil
+	sess.qlog.announceInterest(true, aim)
+
+	var check func(BroadcastPath) error
+	if sess.acl != nil || sess.countsControlMessages() {
+		check = sess.checkAnnounce
+	}
+	return newCheckedAnnouncementReader(stream, prefix, nil, sess.qlog, sess.wireVersion, check, &sess.streamTasks), nil
+}
+
+// Announcements registers interest in the broadcasts under prefix and yields
+// the changes of their announcements as they happen: first the broadcasts
+// already announced, then each broadcast announced later, and each yielded
+// announcement once more when it ends. An announcement is yielded active
+// (IsActive reports true) for ANNOUNCE and ended for UNANNOUNCE, so a
+// consumer can keep a live view of the namespace:
+//
+//	for ann := range sess.Announcements(ctx, "/live/") {
+//	    if ann.IsActive() {
+//	        add(ann.BroadcastPath())
+//	    } else {
+//	        remove(ann.BroadcastPath())
+//	    }
+//	}
+//
+// The iteration ends when ctx is canceled, the loop breaks, or the announce
+// stream ends; use AcceptAnnounce to learn why a request failed.
+func (sess *Session) Announcements(ctx context.Context, prefix string) iter.Seq[*Announcement] {
+	return func(yield func(*Announcement) bool) {
+		reader, err := sess.AcceptAnnounce(prefix)
+		if err != nil {
+			sess.logError("failed to request announcements", err, "prefix", prefix)
+			return
+		}
+		defer reader.Close()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// Announcements and their endings are delivered on a single channel
+		// to keep their order; an ending is only sent after its announcement
+		// was received.
+		changes := make(chan *Announcement)
+		send := func(ann *Announcement) {
+			select {
+			case changes <- ann:
+			case <-ctx.Done():
+			}
+		}
+		sess.streamTasks.Go("announcement iterator", func() {
+			defer cancel()
+			for {
+				ann, err := reader.ReceiveAnnouncement(ctx)
+				if err != nil {
+					return
+				}
+				// Broadcasts that ended before being received are skipped.
+				if !ann.IsActive() {
+					continue
+				}
+				send(ann)
+				ann.AfterFunc(func() { send(ann) })
+			}
+		}, cancel)
+
+		for {
+			select {
+			case ann := <-changes:
+				if !yield(ann) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
 }
 
 // SessionStats is a point-in-time snapshot of a Session's operational metrics.
@@ -476,6 +940,7 @@ type ProbeResult struct {
 // that receives the measured bitrate reported by the publisher.
 // Calling Probe again on the same session updates the target bitrate.
 // The channel is closed when the probe stream ends or the session terminates.
+// Concurrent calls are serialized and share the same probe stream.
 func (sess *Session) Probe(targetBitrate uint64) (<-chan ProbeResult, error) {
 	if sess.terminating() {
 		return nil, ErrClosedSession
@@ -487,7 +952,7 @@ func (sess *Session) Probe(targetBitrate uint64) (<-chan ProbeResult, error) {
 	probeStream := sess.outgoingProbeStream
 	// Lazily open the probe stream.
 	if probeStream == nil || probeStream.Context().Err() != nil {
-		stream, err := sess.conn.OpenStream()
+		stream, err := sess.openStream()
 		if err != nil {
 			if appErr, ok := errors.AsType[*transport.ApplicationError](err); ok {
 				return nil, &SessionError{ApplicationError: appErr}
@@ -504,11 +969,11 @@ func (sess *Session) Probe(targetBitrate uint64) (<-chan ProbeResult, error) {
 			return nil, fmt.Errorf("failed to encode stream type message: %w", err)
 		}
 
-		sess.wg.Go(func() {
+		sess.streamTasks.Go("probe response reader", func() {
 			// Read PROBE responses until the stream is closed or an error occurs.
 			streamCtx := stream.Context()
 			for {
-				var pm message.ProbeMessage
+				pm := message.ProbeMessage{Version: sess.wireVersion}
 				if err := pm.Decode(stream); err != nil {
 					if !errors.Is(err, io.EOF) {
 						sess.logError("failed to decode PROBE message", err)
@@ -518,15 +983,7 @@ func (sess *Session) Probe(targetBitrate uint64) (<-chan ProbeResult, error) {
 				}
 				sess.bitrateTracker.record(pm.Bitrate, time.Now())
 
-				// Update the latest probe result, dropping it if the channel buffer is full (i.e. the previous value has not been consumed).
-				select {
-				case <-sess.probeResponseCh:
-				default:
-				}
-				select {
-				case sess.probeResponseCh <- ProbeResult{Bitrate: pm.Bitrate}:
-				default:
-				}
+				sess.notifyResults(pm.Bitrate)
 
 				select {
 				case <-streamCtx.Done():
@@ -534,6 +991,8 @@ func (sess *Session) Probe(targetBitrate uint64) (<-chan ProbeResult, error) {
 				default:
 				}
 			}
+		}, func() {
+			cancelStreamWithError(stream, transport.StreamErrorCode(ProbeErrorCodeInternal))
 		})
 
 		probeStream = stream
@@ -544,6 +1003,7 @@ func (sess *Session) Probe(targetBitrate uint64) (<-chan ProbeResult, error) {
 	err := message.ProbeMessage{
 		Bitrate: targetBitrate,
 		RTT:     0,
+		Version: sess.wireVersion,
 	}.Encode(probeStream)
 	if err != nil {
 		if strErr, ok := errors.AsType[*transport.StreamError](err); ok {
@@ -570,6 +1030,82 @@ func (sess *Session) ProbeTargets() <-chan ProbeResult {
 	return sess.probeTargetsCh
 }
 
+// reportTrackStats sends stats for the subscription id to the publisher.
+// The stats stream is opened on first use and shared by every subscription
+// of the session.
+func (sess *Session) reportTrackStats(id SubscribeID, stats TrackStats) error {
+	if sess.terminating() {
+		return ErrClosedSession
+	}
+	if !sess.wireVersion.Extended() {
+		return errStatsUnsupported
+	}
+
+	sess.outgoingStatsMu.Lock()
+	defer sess.outgoingStatsMu.Unlock()
+
+	stream := sess.outgoingStatsStream
+	if stream == nil || stream.Context().Err() != nil {
+		var err error
+		stream, err = sess.openStream()
+		if err != nil {
+			if appErr, ok := errors.AsType[*transport.ApplicationError](err); ok {
+				return &SessionError{ApplicationError: appErr}
+			}
+			return fmt.Errorf("failed to open stream for stats: %w", err)
+		}
+
+		if err := message.StreamTypeStats.Encode(stream); err != nil {
+			cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
+			return fmt.Errorf("failed to encode stream type message: %w", err)
+		}
+
+		sess.outgoingStatsStream = stream
+	}
+
+	err := message.TrackStatsMessage{
+		SubscribeID:   uint64(id),
+		Subscribers:   stats.Subscribers,
+		DroppedGroups: stats.DroppedGroups,
+		DroppedFrames: stats.DroppedFrames,
+	}.Encode(stream)
+	if err != nil {
+		cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
+		sess.outgoingStatsStream = nil
+		return fmt.Errorf("failed to send stats message: %w", err)
+	}
+
+	return nil
+}
+
+// handleStatsStream applies the stats reported by the subscriber to the
+// matching track writers until the stream ends.
+func (sess *Session) handleStatsStream(stream transport.Stream) error {
+	for {
+		var tsm message.TrackStatsMessage
+		if err := tsm.Decode(stream); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		sess.trackWriterMapLocker.RLock()
+		track, ok := sess.trackWriters[SubscribeID(tsm.SubscribeID)]
+		sess.trackWriterMapLocker.RUnlock()
+		if !ok {
+			// The subscription may have ended while the report was in flight.
+			continue
+		}
+
+		track.setAudience(TrackStats{
+			Subscribers:   tsm.Subscribers,
+			DroppedGroups: tsm.DroppedGroups,
+			DroppedFrames: tsm.DroppedFrames,
+		})
+	}
+}
+
 // listenBiStreams accepts bidirectional streams and handles them based on their type.
 // It listens for incoming streams and processes them in separate goroutines.
 // The function handles announce, subscribe, and info streams, and terminates the session
@@ -581,76 +1117,176 @@ func (sess *Session) handleBiStreams() {
 			return
 		}
 
-		// Handle the stream
-		go sess.processBiStream(stream)
+		// Handle the stream; unidirectional streams wait until its opening
+		// message is read.
+		opened := sess.controlPriority.hold()
+		sess.streamTasks.Go("bidirectional stream handler", func() {
+			sess.processBiStream(stream, opened)
+		}, func() {
+			opened()
+			cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
+		})
 	}
 }
 
-func (sess *Session) processBiStream(stream transport.Stream) {
+// processBiStream handles a bidirectional stream. It calls opened once the
+// opening message of the stream is read, or the stream failed.
+func (sess *Session) processBiStream(stream transport.Stream, opened func()) {
 	defer stream.Close()
+
+	// The stream holds the control priority until its opening message is
+	// read, so a stalled peer must not hold it for longer than the header
+	// timeout.
+	_ = stream.SetReadDeadline(time.Now().Add(streamHeaderTimeout))
+	release := opened
+	opened = func() {
+		release()
+		_ = stream.SetReadDeadline(time.Time{})
+	}
+	defer opened()
 	var streamType message.StreamType
 	err := streamType.Decode(stream)
 	if err != nil {
 		sess.logError("failed to decode stream type", err)
 		return
 	}
+	if streamType.Extended() && !sess.wireVersion.Extended() {
+		// The peer of another version cannot have opened it.
+		sess.logError("unknown stream type", fmt.Errorf("stream type %d", streamType))
+		cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
+		return
+	}
+	if kind := controlMessageKind(streamType); kind != "" && !sess.controlMessage(kind) {
+		return
+	}
 
 	switch streamType {
 	case message.StreamTypeAnnounce:
 		var aim message.AnnounceInterestMessage
 		err := aim.Decode(stream)
+		opened()
 		if err != nil {
 			sess.logError("failed to decode ANNOUNCE_INTEREST message", err)
 			cancelStreamWithError(stream, transport.StreamErrorCode(AnnounceErrorCodeInternal))
 			return
 		}
+		sess.qlog.announceInterest(false, aim)
 
 		prefix := aim.BroadcastPathPrefix
 
 		annstr := newAnnouncementWriter(stream, prefix, sess.mux.hopID, aim.ExcludeHop, sess.logger)
+		annstr.filter = sess.config.announcementFilter()
+		annstr.qlog = sess.qlog
+		annstr.version = sess.wireVersion
 
 		sess.mux.serveAnnouncements(annstr)
 
 		// Ensure the announcement writer is closed when done
 		annstr.Close()
 	case message.StreamTypeSubscribe:
-		var sm message.SubscribeMessage
+		sm := message.SubscribeMessage{Version: sess.wireVersion}
 		err := sm.Decode(stream)
+		opened()
 		if err != nil {
 			sess.logError("failed to decode SUBSCRIBE message", err)
 			cancelStreamWithError(stream, transport.StreamErrorCode(SubscribeErrorCodeInternal))
 			return
 		}
+		sess.qlog.subscribe(false, sm)
+
+		if sess.isDraining.Load() {
+			cancelStreamWithError(stream, transport.StreamErrorCode(SubscribeErrorCodeGoingAway))
+			return
+		}
+
+		if err := sess.authorizeSubscribe(sm); err != nil {
+			sess.logError("subscription denied", err, "broadcast_path", sm.BroadcastPath, "track_name", sm.TrackName)
+			cancelStreamWithError(stream, transport.StreamErrorCode(authSubscribeErrorCode(err)))
+			return
+		}
+		if err := sess.checkSubscribe(sm); err != nil {
+			sess.logError("subscription denied by ACL", err, "broadcast_path", sm.BroadcastPath, "track_name", sm.TrackName)
+			cancelStreamWithError(stream, transport.StreamErrorCode(aclSubscribeErrorCode(err)))
+			return
+		}
+		if err := sess.config.parameterRegistry().Check(Parameters(sm.Parameters)); err != nil {
+			sess.logError("subscription rejected", err, "broadcast_path", sm.BroadcastPath, "track_name", sm.TrackName)
+			cancelStreamWithError(stream, transport.StreamErrorCode(SubscribeErrorCodeInvalidParameter))
+			return
+		}
 
 		// Create a receiveSubscribeStream with draft3 fields decoded from SUBSCRIBE message
 		config := &SubscribeConfig{
-			Priority:   TrackPriority(sm.SubscriberPriority),
-			Ordered:    boolFromWireFlag(sm.SubscriberOrdered),
-			MaxLatency: sm.SubscriberMaxLatency,
+			Priority:    TrackPriority(sm.SubscriberPriority),
+			Ordered:     boolFromWireFlag(sm.SubscriberOrdered),
+			MaxLatency:  sm.SubscriberMaxLatency,
+			Start:       SubscribeStart(sm.Start),
+			Parameters:  Parameters(sm.Parameters),
+			StartTime:   timeFromWire(sm.StartTime),
+			StartBehind: durationFromWire(sm.StartBehind),
 		}
 
 		// Decode 0-sentinel / +1-encoded fields (matching SUBSCRIBE_UPDATE logic)
 		config.StartGroup = groupSequenceFromWire(sm.StartGroup)
 		config.EndGroup = groupSequenceFromWire(sm.EndGroup)
 
-		substr := newReceiveSubscribeStream(SubscribeID(sm.SubscribeID), stream, config)
+		substr := newReceiveSubscribeStream(SubscribeID(sm.SubscribeID), stream, config, sess.wireVersion, &sess.streamTasks)
+		if sess.countsControlMessages() {
+			substr.onUpdate(func(*SubscribeConfig) {
+				if !sess.controlMessage(ControlMessageSubscribeUpdate) {
+					cancelStreamWithError(stream, transport.StreamErrorCode(SubscribeErrorCodeInternal))
+				}
+			})
+		}
 
 		track := newTrackWriter(
 			BroadcastPath(sm.BroadcastPath),
 			TrackName(sm.TrackName),
 			substr,
-			sess.conn.OpenUniStream,
+			sess.openUniStream,
 			func() { sess.removeTrackWriter(SubscribeID(sm.SubscribeID)) },
 		)
+		track.qlog = sess.qlog
+		track.metrics = sess.serverMetrics
+		track.sendDatagramFunc = datagramSender(sess.conn)
+		track.scheduler = sess.scheduler
+		track.tasks = &sess.streamTasks
+		track.bitrateLimit.setRate(sess.config.maxSubscriptionBitrate(), 0)
+		track.sessionLimit = &sess.bandwidth
+		track.sessionInterceptor = sess.config.writeFrameInterceptor()
+		track.errorCodes = sess.config.errorCodes()
+		if sess.tracer != nil {
+			traceCtx, end := sess.tracer.StartSubscribe(sess.traceCtx, track.BroadcastPath, track.TrackName, true)
+			track.tracer = sess.tracer
+			track.traceCtx = traceCtx
+			substr.onResponseFunc = endSpanOnce(end)
+		}
 		sess.addTrackWriter(SubscribeID(sm.SubscribeID), track)
 
-		sess.mux.serveTrack(track)
+		if sess.serverMetrics != nil {
+			sess.serverMetrics.SubscriptionStarted(track.BroadcastPath, track.TrackName)
+		}
 
-		// Ensure the track writer is closed when done
-		track.Close()
+		// Release the subscription even if the handler panics, in which
+		// case the track is closed with an error.
+		served := false
+		defer func() {
+			if served {
+				track.Close()
+			} else {
+				track.CloseWithError(SubscribeErrorCodeInternal)
+			}
+			if sess.serverMetrics != nil {
+				sess.serverMetrics.SubscriptionEnded(track.BroadcastPath, track.TrackName)
+			}
+		}()
+
+		sess.mux.serveTrack(track)
+		served = true
 	case message.StreamTypeFetch:
 		var fm message.FetchMessage
 		err := fm.Decode(stream)
+		opened()
 		if err != nil {
 			sess.logError("failed to decode FETCH message", err)
 			cancelStreamWithError(stream, transport.StreamErrorCode(FetchErrorCodeInternal))
@@ -668,9 +1304,16 @@ func (sess *Session) processBiStream(stream transport.Stream) {
 		}
 
 		group := newGroupWriter(stream, req.GroupSequence, nil)
+		group.interceptor = sess.config.writeFrameInterceptor()
+		group.errorCodes = sess.config.errorCodes()
 
 		stop := context.AfterFunc(req.Context(), func() {
-			// Cancel the stream when the context is done
+			// The stream context is also done once the handler closes the
+			// group; resetting the stream then would discard the data not
+			// yet delivered.
+			if errors.Is(context.Cause(req.Context()), context.Canceled) {
+				return
+			}
 			group.CancelWrite(ExpiredGroupErrorCode)
 		})
 		defer stop()
@@ -682,6 +1325,8 @@ func (sess *Session) processBiStream(stream transport.Stream) {
 			return
 		}
 	case message.StreamTypeProbe:
+		// Streams read by a loop are opened once their type is known.
+		opened()
 		err := sess.handleProbeStream(stream)
 		if err != nil {
 			sess.logError("probe stream error", err)
@@ -689,11 +1334,33 @@ func (sess *Session) processBiStream(stream transport.Stream) {
 			return
 		}
 	case message.StreamTypeGoaway:
+		opened()
 		if err := sess.handleGoawayStream(stream); err != nil {
 			sess.logError("goaway stream error", err)
 			cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
 			return
 		}
+	case message.StreamTypeStats:
+		opened()
+		if err := sess.handleStatsStream(stream); err != nil {
+			sess.logError("stats stream error", err)
+			cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
+			return
+		}
+	case message.StreamTypeTrackStatus:
+		opened()
+		if err := sess.handleTrackStatusStream(stream); err != nil {
+			sess.logError("track status stream error", err)
+			cancelStreamWithError(stream, transport.StreamErrorCode(SubscribeErrorCodeInternal))
+			return
+		}
+	case message.StreamTypePing:
+		opened()
+		if err := sess.handlePingStream(stream); err != nil {
+			sess.logError("ping stream error", err)
+			cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
+			return
+		}
 	default:
 		sess.logError("unknown stream type", fmt.Errorf("stream type %d", streamType))
 		cancelStreamWithError(stream, transport.StreamErrorCode(InternalSessionErrorCode))
@@ -701,42 +1368,72 @@ func (sess *Session) processBiStream(stream transport.Stream) {
 	}
 }
 
+// handleUniStreams accepts unidirectional streams and decodes their headers
+// in separate goroutines. Group streams are the data plane of the session, so
+// before each accept the loop waits until no bidirectional stream, which
+// carries control messages, waits for its opening message, and takes a slot
+// from uniStreamSlots. A control message thus waits for at most
+// maxPendingUniStreams stream headers, however busy the tracks are.
 func (sess *Session) handleUniStreams() {
 	for {
+		select {
+		case <-sess.controlPriority.idleCh():
+		case <-sess.ctx.Done():
+			return
+		}
+
+		select {
+		case sess.uniStreamSlots <- struct{}{}:
+		case <-sess.ctx.Done():
+			return
+		}
+
 		stream, err := sess.conn.AcceptUniStream(sess.ctx)
 		if err != nil {
+			<-sess.uniStreamSlots
 			return
 		}
 
-		go sess.processUniStream(stream)
+		sess.streamTasks.Go("unidirectional stream handler", func() {
+			defer func() { <-sess.uniStreamSlots }()
+			sess.processUniStream(stream)
+		}, func() {
+			stream.CancelRead(transport.StreamErrorCode(InternalSessionErrorCode))
+		})
 	}
 }
 
 func (sess *Session) processUniStream(stream transport.ReceiveStream) {
+	_ = stream.SetReadDeadline(time.Now().Add(streamHeaderTimeout))
+
 	var streamType message.StreamType
 	err := streamType.Decode(stream)
 	if err != nil {
 		sess.logError("failed to decode uni stream type", err)
+		stream.CancelRead(transport.StreamErrorCode(InternalSessionErrorCode))
 		return
 	}
 
 	switch streamType {
 	case message.StreamTypeGroup:
-		var gm message.GroupMessage
+		gm := message.GroupMessage{Version: sess.wireVersion}
 		err := gm.Decode(stream)
 		if err != nil {
 			sess.logError("failed to decode GROUP message", err)
+			stream.CancelRead(transport.StreamErrorCode(InternalSessionErrorCode))
 			return
 		}
+		_ = stream.SetReadDeadline(time.Time{})
+		sess.qlog.groupOpened(false, SubscribeID(gm.SubscribeID), GroupSequence(gm.GroupSequence))
 
-		track, ok := sess.trackReaders[SubscribeID(gm.SubscribeID)]
+		track, ok := sess.findTrackReader(SubscribeID(gm.SubscribeID))
 		if !ok {
 			stream.CancelRead(transport.StreamErrorCode(InvalidSubscribeIDErrorCode))
 			return
 		}
 
 		// Enqueue the receiver — ownership of the stream transfers to the TrackReader.
-		track.enqueueGroup(GroupSequence(gm.GroupSequence), stream)
+		track.enqueue(receivedGroup(gm, stream))
 	default:
 		// Unknown stream types are stream-local and non-fatal for extension probing.
 		sess.logError("unknown uni stream type", fmt.Errorf("stream type %d", streamType))
@@ -754,23 +1451,123 @@ func (s *Session) addTrackWriter(id SubscribeID, writer *TrackWriter) {
 
 func (s *Session) removeTrackWriter(id SubscribeID) {
 	s.trackWriterMapLocker.Lock()
-	defer s.trackWriterMapLocker.Unlock()
-
 	delete(s.trackWriters, id)
+	s.trackWriterMapLocker.Unlock()
+
+	s.checkDrained()
 }
 
 func (s *Session) addTrackReader(id SubscribeID, reader *TrackReader) {
 	s.trackReaderMapLocker.Lock()
 	defer s.trackReaderMapLocker.Unlock()
 
+	// Subscribe IDs are allocated locally, so a reused ID means that two
+	// subscriptions would receive each other's groups.
+	if invariantsEnabled {
+		if existing, ok := s.trackReaders[id]; ok {
+			invariantViolated("subscribe ID reused",
+				"subscribe_id", id,
+				"broadcast_path", reader.BroadcastPath, "track_name", reader.TrackName,
+				"existing_broadcast_path", existing.BroadcastPath, "existing_track_name", existing.TrackName)
+		}
+	}
+
 	s.trackReaders[id] = reader
 }
 
 func (s *Session) removeTrackReader(id SubscribeID) {
 	s.trackReaderMapLocker.Lock()
-	defer s.trackReaderMapLocker.Unlock()
-
 	delete(s.trackReaders, id)
+	s.trackReaderMapLocker.Unlock()
+
+	s.checkDrained()
+}
+
+func (s *Session) findTrackReader(id SubscribeID) (*TrackReader, bool) {
+	s.trackReaderMapLocker.RLock()
+	defer s.trackReaderMapLocker.RUnlock()
+
+	reader, ok := s.trackReaders[id]
+	return reader, ok
+}
+
+// authorizeSubscribe asks the Authorizer of the session, if any, whether the
+// subscription of sm may be served.
+func (sess *Session) authorizeSubscribe(sm message.SubscribeMessage) error {
+	if sess.authorizer == nil {
+		return nil
+	}
+	return sess.authorizer.AuthorizeSubscribe(sess.ctx, &SubscribeAuthRequest{
+		BroadcastPath: BroadcastPath(sm.BroadcastPath),
+		TrackName:     TrackName(sm.TrackName),
+		Token:         sm.AuthToken,
+		SessionToken:  sess.authToken,
+		RemoteAddr:    sess.conn.RemoteAddr(),
+	})
+}
+
+// checkSubscribe asks the ACL of the session, if any, whether the
+// subscription of sm may be served.
+func (sess *Session) checkSubscribe(sm message.SubscribeMessage) error {
+	if sess.acl == nil {
+		return nil
+	}
+	return sess.acl.CheckSubscribe(sess.ctx, &SubscribeAuthRequest{
+		BroadcastPath: BroadcastPath(sm.BroadcastPath),
+		TrackName:     TrackName(sm.TrackName),
+		Token:         sm.AuthToken,
+		SessionToken:  sess.authToken,
+		RemoteAddr:    sess.conn.RemoteAddr(),
+	})
+}
+
+// checkAnnounce counts the announcement of the broadcast at path by the peer
+// as a control message, and asks the ACL of the session, if any, whether the
+// peer may announce it.
+func (sess *Session) checkAnnounce(path BroadcastPath) error {
+	if !sess.controlMessage(ControlMessageAnnounce) {
+		return errControlRateExceeded
+	}
+	if sess.acl == nil {
+		return nil
+	}
+	err := sess.acl.CheckAnnounce(sess.ctx, &AnnounceAuthRequest{
+		BroadcastPath: path,
+		SessionToken:  sess.authToken,
+		RemoteAddr:    sess.conn.RemoteAddr(),
+	})
+	if err != nil {
+		sess.logError("announcement denied by ACL", err, "broadcast_path", path)
+	}
+	return err
+}
+
+// countsControlMessages reports whether the control messages of the peer are
+// limited or reported to metrics.
+func (sess *Session) countsControlMessages() bool {
+	return sess.controlLimit != nil || sess.serverMetrics != nil
+}
+
+// controlMessage counts a control message of kind received from the peer.
+// If the peer exceeds Config.ControlMessageRate, it closes the session with
+// ProtocolViolationErrorCode and returns false.
+func (sess *Session) controlMessage(kind string) bool {
+	if sess.serverMetrics != nil {
+		sess.serverMetrics.ControlMessageReceived(kind)
+	}
+	if sess.controlLimit == nil || sess.controlLimit.allow(time.Now()) {
+		return true
+	}
+
+	if sess.serverMetrics != nil {
+		sess.serverMetrics.ControlRateExceeded(sess.transport)
+	}
+	sess.logError("closing session", errControlRateExceeded, "kind", kind)
+	// Closing waits for the loops of the session, which may be the caller.
+	sess.streamTasks.Go("control rate close", func() {
+		sess.CloseWithError(ProtocolViolationErrorCode, errControlRateExceeded.Error())
+	}, nil)
+	return false
 }
 
 func cancelStreamWithError(stream transport.Stream, code transport.StreamErrorCode) {
@@ -795,7 +1592,7 @@ func (sess *Session) handleProbeStream(stream transport.Stream) error {
 	}()
 
 	for {
-		var pm message.ProbeMessage
+		pm := message.ProbeMessage{Version: sess.wireVersion}
 		if err := pm.Decode(stream); err != nil {
 			if errors.Is(err, io.EOF) {
 				return nil
@@ -803,36 +1600,37 @@ func (sess *Session) handleProbeStream(stream transport.Stream) error {
 			return err
 		}
 
-		// Update the latest probe target, dropping it if the channel buffer is full (i.e. the previous value has not been consumed).
-		select {
-		case <-sess.probeTargetsCh:
-		default:
-		}
-		select {
-		case sess.probeTargetsCh <- ProbeResult{Bitrate: pm.Bitrate}:
-		default:
-		}
+		sess.notifyTargets(pm.Bitrate)
 	}
 }
 
+// notifyResults publishes the latest measured bitrate on probeResponseCh.
 func (sess *Session) notifyResults(bitrate uint64) {
-	select {
-	case <-sess.probeResponseCh:
-	default:
-	}
-	select {
-	case sess.probeResponseCh <- ProbeResult{Bitrate: bitrate}:
-	default:
-	}
+	sess.notifyLatest(sess.probeResponseCh, ProbeResult{Bitrate: bitrate})
 }
 
+// notifyTargets publishes the latest target bitrate on probeTargetsCh.
 func (sess *Session) notifyTargets(bitrate uint64) {
+	sess.notifyLatest(sess.probeTargetsCh, ProbeResult{Bitrate: bitrate})
+}
+
+// notifyLatest replaces any unconsumed value in ch with result
+// (latest-value semantics). It is a no-op once the session has closed the
+// probe channels.
+func (sess *Session) notifyLatest(ch chan ProbeResult, result ProbeResult) {
+	sess.probeChMu.Lock()
+	defer sess.probeChMu.Unlock()
+
+	if sess.probeChClosed {
+		return
+	}
+
 	select {
-	case <-sess.probeTargetsCh:
+	case <-ch:
 	default:
 	}
 	select {
-	case sess.probeTargetsCh <- ProbeResult{Bitrate: bitrate}:
+	case ch <- result:
 	default:
 	}
 }
@@ -849,6 +1647,7 @@ func (sess *Session) detectBitrateChanges(provider probeStatsProvider) {
 		err := message.ProbeMessage{
 			Bitrate: bitrate,
 			RTT:     rtt,
+			Version: sess.wireVersion,
 		}.Encode(stream)
 		if err != nil {
 			if !errors.Is(err, io.EOF) {
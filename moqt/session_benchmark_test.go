@@ -163,7 +163,7 @@ func BenchmarkSession_TrackWriterOperations(b *testing.B) {
 		// Create mock subscribe stream
 		mockSubStream := &FakeQUICStream{}
 
-		substr := newReceiveSubscribeStream(id, mockSubStream, &SubscribeConfig{})
+		substr := newReceiveSubscribeStream(id, mockSubStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 		trackWriter := newTrackWriter(
 			BroadcastPath("/test"),
 			TrackName("track"),
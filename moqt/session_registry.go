@@ -0,0 +1,99 @@
+package moqt
+
+import (
+	"net"
+	"slices"
+	"time"
+)
+
+// SessionInfo describes a live session accepted by a Server, as returned by
+// Server.Sessions.
+type SessionInfo struct {
+	// Session is the session itself, such as to close it with
+	// CloseWithError.
+	Session *Session
+
+	// RemoteAddr is the address of the peer.
+	RemoteAddr net.Addr
+
+	// Transport is "quic" for native QUIC sessions, or "webtransport".
+	Transport string
+
+	// Path is the request path of a WebTransport session, or "" for a
+	// native QUIC session.
+	Path string
+
+	// Version is the MOQ version negotiated for the session.
+	Version string
+
+	// Subscriptions is the number of tracks the server has subscribed to
+	// from the peer on the session, and Publications the number of
+	// subscriptions of the peer the server is serving.
+	Subscriptions int
+	Publications  int
+
+	// StartTime is the time the session was set up.
+	StartTime time.Time
+
+	// Uptime is the time elapsed since StartTime when the info was taken.
+	Uptime time.Duration
+}
+
+// Sessions returns the live sessions of the server, from the oldest. The
+// info is a snapshot: sessions may end, and their counts change, once it is
+// returned.
+func (s *Server) Sessions() []SessionInfo {
+	s.init()
+
+	now := time.Now()
+	sessions := s.connManager.sessions()
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, sess := range sessions {
+		if sess.Context().Err() != nil {
+			continue
+		}
+		infos = append(infos, sess.info(now))
+	}
+	slices.SortFunc(infos, func(a, b SessionInfo) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+	return infos
+}
+
+// FindSession returns the oldest live session of the server for which match
+// returns true, and whether there is one.
+//
+//	info, ok := server.FindSession(func(info moqt.SessionInfo) bool {
+//	    return info.Path == "/live"
+//	})
+func (s *Server) FindSession(match func(SessionInfo) bool) (SessionInfo, bool) {
+	for _, info := range s.Sessions() {
+		if match(info) {
+			return info, true
+		}
+	}
+	return SessionInfo{}, false
+}
+
+// info returns the SessionInfo of sess at now.
+func (sess *Session) info(now time.Time) SessionInfo {
+	sess.trackReaderMapLocker.RLock()
+	subscriptions := len(sess.trackReaders)
+	sess.trackReaderMapLocker.RUnlock()
+
+	sess.trackWriterMapLocker.RLock()
+	publications := len(sess.trackWriters)
+	sess.trackWriterMapLocker.RUnlock()
+
+	return SessionInfo{
+		Session:       sess,
+		RemoteAddr:    sess.RemoteAddr(),
+		Transport:     sess.transport,
+		Path:          sess.path,
+		Version:       sess.ConnectionState().Version,
+		Subscriptions: subscriptions,
+		Publications:  publications,
+		StartTime:     sess.startTime,
+		Uptime:        now.Sub(sess.startTime),
+	}
+}
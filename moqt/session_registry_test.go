@@ -0,0 +1,108 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Sessions(t *testing.T) {
+	s := &Server{
+		Handler: HandleFunc(func(sess *Session) {
+			<-sess.Context().Done()
+		}),
+	}
+	s.init()
+	t.Cleanup(func() { _ = s.Close() })
+
+	// A native QUIC session, then a WebTransport session.
+	quicAddr := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 4433}
+	quicConn := newTestNativeQUICConn(t, func(conn *FakeStreamConn) {
+		conn.RemoteAddrFunc = func() net.Addr { return quicAddr }
+	})
+	go func() {
+		_ = s.ServeQUICConn(quicConn)
+	}()
+	require.Eventually(t, func() bool {
+		return len(s.Sessions()) == 1
+	}, time.Second, time.Millisecond)
+
+	wtAddr := &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443}
+	u := &WebTransportHandler{
+		TrackMux: NewTrackMux(0),
+		UpgradeFunc: func(w http.ResponseWriter, r *http.Request) (WebTransportSession, error) {
+			sess := &FakeWebTransportSession{}
+			sess.RemoteAddrFunc = func() net.Addr { return wtAddr }
+			return sess, nil
+		},
+		Handler: s.Handler,
+	}
+	r, _ := http.NewRequest(http.MethodConnect, "https://example.com/live", nil)
+	r.TLS = &tls.ConnectionState{}
+	r = r.WithContext(s.connContext(context.Background(), &FakeStreamConn{}))
+	go u.ServeHTTP(&FakeHTTPResponseWriter{}, r)
+	require.Eventually(t, func() bool {
+		return len(s.Sessions()) == 2
+	}, time.Second, time.Millisecond)
+
+	infos := s.Sessions()
+	assert.Equal(t, quicAddr, infos[0].RemoteAddr)
+	assert.Equal(t, "quic", infos[0].Transport)
+	assert.Empty(t, infos[0].Path)
+	assert.Equal(t, wtAddr, infos[1].RemoteAddr)
+	assert.Equal(t, "webtransport", infos[1].Transport)
+	assert.Equal(t, "/live", infos[1].Path)
+	for _, info := range infos {
+		assert.Equal(t, VersionLite04, info.Version)
+		assert.NotNil(t, info.Session)
+		assert.False(t, info.StartTime.IsZero())
+		assert.GreaterOrEqual(t, info.Uptime, time.Duration(0))
+	}
+	assert.False(t, infos[1].StartTime.Before(infos[0].StartTime))
+
+	// A targeted disconnect.
+	info, ok := s.FindSession(func(info SessionInfo) bool {
+		return info.Path == "/live"
+	})
+	require.True(t, ok)
+	assert.Equal(t, wtAddr, info.RemoteAddr)
+	require.NoError(t, info.Session.CloseWithError(NoError, ""))
+	require.Eventually(t, func() bool {
+		return len(s.Sessions()) == 1
+	}, time.Second, time.Millisecond)
+
+	_, ok = s.FindSession(func(info SessionInfo) bool {
+		return info.Path == "/live"
+	})
+	assert.False(t, ok)
+}
+
+func TestSession_info(t *testing.T) {
+	sess := newTestSession(&FakeStreamConn{})
+	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+
+	sess.trackReaders[1] = &TrackReader{}
+	sess.trackReaders[2] = &TrackReader{}
+	sess.trackWriters[3] = &TrackWriter{}
+
+	now := sess.startTime.Add(time.Minute)
+	info := sess.info(now)
+	assert.Equal(t, 2, info.Subscriptions)
+	assert.Equal(t, 1, info.Publications)
+	assert.Equal(t, time.Minute, info.Uptime)
+	assert.Same(t, sess, info.Session)
+}
+
+func TestServer_Sessions_Empty(t *testing.T) {
+	s := &Server{}
+	assert.Empty(t, s.Sessions())
+
+	_, ok := s.FindSession(func(SessionInfo) bool { return true })
+	assert.False(t, ok)
+}
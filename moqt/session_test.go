@@ -8,6 +8,7 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -22,7 +23,7 @@ import (
 )
 
 func newTestSession(conn StreamConn) *Session {
-	return newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil)
+	return newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil, nil)
 }
 
 func newTestSessionWithConn(tb testing.TB, opts ...func(*FakeStreamConn)) (*Session, *FakeStreamConn) {
@@ -38,6 +39,14 @@ func newTestSessionWithConn(tb testing.TB, opts ...func(*FakeStreamConn)) (*Sess
 	return session, conn
 }
 
+// extendedConn makes the connection negotiate VersionLite04Ext, which
+// carries the extension fields of the messages.
+func extendedConn(conn *FakeStreamConn) {
+	conn.TLSFunc = func() *tls.ConnectionState {
+		return &tls.ConnectionState{NegotiatedProtocol: VersionLite04Ext}
+	}
+}
+
 type noStatsConn struct{}
 
 func (noStatsConn) AcceptStream(context.Context) (transport.Stream, error) { return nil, io.EOF }
@@ -69,13 +78,13 @@ func TestNewSession(t *testing.T) {
 			conn.TLSFunc = func() *tls.ConnectionState { return &tls.ConnectionState{NegotiatedProtocol: NextProtoMOQ} }
 			conn.OpenStreamFunc = func() (transport.Stream, error) { return nil, io.EOF }
 
-			session := newSession(conn, tt.mux, nil, nil, nil, nil, nil)
+			session := newSession(conn, tt.mux, nil, nil, nil, nil, nil, nil)
 
 			if tt.expectOK {
 				assert.NotNil(t, session, "newSession should not return nil")
 				assert.Equal(t, tt.mux, session.mux, "mux should be set correctly")
 				assert.NotNil(t, session.trackReaders, "receive group stream queues should not be nil")
-				assert.Equal(t, moqtVersion, session.ConnectionState().Version, "ConnectionState() should expose the MOQ version")
+				assert.Equal(t, VersionLite04, session.ConnectionState().Version, "ConnectionState() should expose the MOQ version")
 				// remote address method should return connection's address
 				assert.Equal(t, "127.0.0.1:8080", session.RemoteAddr().String(), "RemoteAddr() should forward to connection")
 			}
@@ -92,7 +101,7 @@ func TestNewSessionConnectionState(t *testing.T) {
 	})
 
 	state := session.ConnectionState()
-	assert.Equal(t, moqtVersion, state.Version, "ConnectionState() should expose the MOQ version")
+	assert.Equal(t, VersionLite04, state.Version, "ConnectionState() should expose the MOQ version")
 	require.NotNil(t, state.TLS, "ConnectionState().TLS should be populated")
 	assert.Equal(t, NextProtoMOQ, state.TLS.NegotiatedProtocol, "TLS negotiated protocol should reflect quic")
 }
@@ -112,7 +121,7 @@ func TestNewSessionWithNilMux(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			conn := &FakeStreamConn{}
 
-			session := newSession(conn, tt.mux, nil, nil, nil, nil, nil)
+			session := newSession(conn, tt.mux, nil, nil, nil, nil, nil, nil)
 
 			if tt.expectDefault {
 				assert.Equal(t, DefaultMux, session.mux, "should use DefaultMux when nil mux is provided")
@@ -134,7 +143,7 @@ func TestNewSession_ConfigIsCloned(t *testing.T) {
 	conn := &FakeStreamConn{}
 	cfg := &Config{ProbeInterval: 50 * time.Millisecond}
 
-	session := newSession(conn, nil, nil, cfg, nil, nil, nil)
+	session := newSession(conn, nil, nil, cfg, nil, nil, nil, nil)
 	defer session.CloseWithError(InternalSessionErrorCode, "terminate reason")
 
 	// mutate the original after newSession
@@ -827,7 +836,7 @@ func TestSession_Stats_NoTransport(t *testing.T) {
 	// noStatsConn does not implement probeStatsProvider.
 	// Transport-derived fields must be zero values.
 	conn := noStatsConn{}
-	sess := newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil)
+	sess := newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil, nil)
 	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
 
 	stats := sess.Stats()
@@ -878,7 +887,7 @@ func TestSession_Stats_EstimatedBitrateUpdatedByDetectBitrateChanges(t *testing.
 	// Pass config at creation time so the Ticker in detectBitrateChanges
 	// picks up the short interval (it is captured at goroutine start).
 	cfg := &Config{ProbeInterval: 5 * time.Millisecond, ProbeMaxAge: 10 * time.Millisecond}
-	sess := newSession(conn, NewTrackMux(0), nil, cfg, nil, nil, nil)
+	sess := newSession(conn, NewTrackMux(0), nil, cfg, nil, nil, nil, nil)
 	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
 
 	// Allow detectBitrateChanges at least two ticks: first initializes, second measures.
@@ -963,7 +972,7 @@ func TestSession_ProcessBiStream_Announce(t *testing.T) {
 	// This will block, so we run it in a goroutine
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(mockStream)
+		session.processBiStream(mockStream, func() {})
 		close(done)
 	}()
 
@@ -1042,7 +1051,7 @@ func TestSession_ProcessBiStream_Subscribe(t *testing.T) {
 	// This will block in serveTrack, so we run it in a goroutine
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(mockStream)
+		session.processBiStream(mockStream, func() {})
 		close(done)
 	}()
 
@@ -1106,7 +1115,7 @@ func TestSession_ProcessBiStream_InvalidStreamType(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(mockStream)
+		session.processBiStream(mockStream, func() {})
 		close(done)
 	}()
 
@@ -1145,7 +1154,7 @@ func TestSession_ProcessBiStream_DecodeStreamTypeError(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(mockStream)
+		session.processBiStream(mockStream, func() {})
 		close(done)
 	}()
 
@@ -1179,7 +1188,7 @@ func TestSession_ProcessBiStream_DecodeAnnounceMessageError(t *testing.T) {
 		return 0, io.ErrUnexpectedEOF
 	}
 
-	session.processBiStream(mockStream)
+	session.processBiStream(mockStream, func() {})
 }
 
 func TestSession_ProcessBiStream_DecodeSubscribeMessageError(t *testing.T) {
@@ -1203,7 +1212,7 @@ func TestSession_ProcessBiStream_DecodeSubscribeMessageError(t *testing.T) {
 		return 0, io.ErrUnexpectedEOF
 	}
 
-	session.processBiStream(mockStream)
+	session.processBiStream(mockStream, func() {})
 }
 
 func TestSession_ProcessBiStream_Fetch(t *testing.T) {
@@ -1242,7 +1251,7 @@ func TestSession_ProcessBiStream_Fetch(t *testing.T) {
 		return n, nil
 	}
 
-	session.processBiStream(mockStream)
+	session.processBiStream(mockStream, func() {})
 
 	assert.True(t, called, "fetch handler should be called")
 	require.NotNil(t, gotReq)
@@ -1286,7 +1295,7 @@ func TestSession_ProcessBiStream_FetchTypedNilHandler(t *testing.T) {
 		return n, nil
 	}
 
-	session.processBiStream(mockStream)
+	session.processBiStream(mockStream, func() {})
 
 	// CancelWrite check
 	var cancelWriteErr *transport.StreamError
@@ -1335,7 +1344,7 @@ func TestSession_ProcessBiStream_FetchHandlerPanic(t *testing.T) {
 		return n, nil
 	}
 
-	session.processBiStream(mockStream)
+	session.processBiStream(mockStream, func() {})
 
 	assert.True(t, called, "fetch handler should be called")
 
@@ -1398,8 +1407,7 @@ func TestSession_ProcessBiStream_Probe(t *testing.T) {
 		return quic.ConnectionStats{}
 	}
 
-	session := newTestSession(conn)
-	session.config = &Config{ProbeInterval: 5 * time.Millisecond}
+	session := newSession(conn, NewTrackMux(0), nil, &Config{ProbeInterval: 5 * time.Millisecond}, nil, nil, nil, nil)
 
 	probeStream := &FakeQUICStream{}
 
@@ -1432,7 +1440,7 @@ func TestSession_ProcessBiStream_Probe(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(probeStream)
+		session.processBiStream(probeStream, func() {})
 		close(done)
 	}()
 
@@ -1460,7 +1468,7 @@ func TestSession_ProcessBiStream_ProbeMultipleMessages(t *testing.T) {
 
 	session := newSession(conn, NewTrackMux(0), nil,
 		&Config{ProbeInterval: 5 * time.Millisecond, ProbeMaxAge: 15 * time.Millisecond},
-		nil, nil, nil)
+		nil, nil, nil, nil)
 
 	probeStream := &FakeQUICStream{}
 
@@ -1494,7 +1502,7 @@ func TestSession_ProcessBiStream_ProbeMultipleMessages(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(probeStream)
+		session.processBiStream(probeStream, func() {})
 		close(done)
 	}()
 
@@ -1542,7 +1550,7 @@ func TestSession_ProcessBiStream_ProbeTargets(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(probeStream)
+		session.processBiStream(probeStream, func() {})
 		close(done)
 	}()
 
@@ -1684,7 +1692,7 @@ func TestSession_ProcessBiStream_ProbeReplacesExisting(t *testing.T) {
 
 	stream1Done := make(chan struct{})
 	go func() {
-		session.processBiStream(stream1)
+		session.processBiStream(stream1, func() {})
 		close(stream1Done)
 	}()
 
@@ -1714,7 +1722,7 @@ func TestSession_ProcessBiStream_ProbeReplacesExisting(t *testing.T) {
 
 	stream2Done := make(chan struct{})
 	go func() {
-		session.processBiStream(stream2)
+		session.processBiStream(stream2, func() {})
 		close(stream2Done)
 	}()
 
@@ -1776,7 +1784,7 @@ func TestSession_ProcessBiStream_ProbeDecodeError(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(probeStream)
+		session.processBiStream(probeStream, func() {})
 		close(done)
 	}()
 
@@ -1808,7 +1816,7 @@ func TestSession_ProcessBiStream_ProbeUnsupported(t *testing.T) {
 	// never started.  handleProbeStream still registers the stream and reads
 	// PROBE messages; with no PROBE data it hits EOF immediately and returns nil.
 	conn := &noStatsConn{}
-	session := newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil)
+	session := newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil, nil)
 
 	var incoming bytes.Buffer
 	require.NoError(t, message.StreamTypeProbe.Encode(&incoming))
@@ -1827,7 +1835,7 @@ func TestSession_ProcessBiStream_ProbeUnsupported(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(probeStream)
+		session.processBiStream(probeStream, func() {})
 		close(done)
 	}()
 
@@ -1874,7 +1882,7 @@ func TestSession_ProbeTargets_LatestValueSemantics(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		session.processBiStream(probeStream)
+		session.processBiStream(probeStream, func() {})
 		close(done)
 	}()
 
@@ -1942,7 +1950,7 @@ func TestSession_ProbeTargets_InitialMessageDelivered(t *testing.T) {
 
 	streamDone := make(chan struct{})
 	go func() {
-		session.processBiStream(probeStream)
+		session.processBiStream(probeStream, func() {})
 		close(streamDone)
 	}()
 
@@ -2792,7 +2800,7 @@ func TestSession_processBiStream_logError(t *testing.T) {
 	session := newTestSession(conn)
 	session.logger = logger
 
-	session.processBiStream(mockStream)
+	session.processBiStream(mockStream, func() {})
 
 	output := logBuf.String()
 	assert.Contains(t, output, "failed to decode stream type")
@@ -2843,7 +2851,7 @@ func TestSession_Stats_EstimatedBitrateUpdatedEveryInterval(t *testing.T) {
 		ProbeMaxAge:   1 * time.Hour,
 		ProbeMaxDelta: 1000.0, // 100000% change needed for notification
 	}
-	sess := newSession(conn, NewTrackMux(0), nil, cfg, nil, nil, nil)
+	sess := newSession(conn, NewTrackMux(0), nil, cfg, nil, nil, nil, nil)
 	t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
 
 	// Initial tick to initialize tracker baseline
@@ -2906,7 +2914,7 @@ func TestSession_Probe_ConcurrentAccess(t *testing.T) {
 
 	conn.OpenStreamFunc = func() (transport.Stream, error) { return mockStream, nil }
 
-	session := newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil)
+	session := newSession(conn, NewTrackMux(0), nil, nil, nil, nil, nil, nil)
 
 	// Test concurrent access to Probe (receiving peer measurements)
 	results, _ := session.Probe(1000000)
@@ -2964,3 +2972,595 @@ func TestSession_Probe_ConcurrentAccess(t *testing.T) {
 	consumeCancel()
 	_ = session.CloseWithError(NoError, "")
 }
+
+func TestSession_CloseWithError_Concurrent(t *testing.T) {
+	conn := &FakeStreamConn{}
+	var closeCount atomic.Int32
+	conn.CloseWithErrorFunc = func(code transport.ConnErrorCode, reason string) error {
+		closeCount.Add(1)
+		return nil
+	}
+
+	session := newTestSession(conn)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			assert.NoError(t, session.CloseWithError(NoError, ""))
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), closeCount.Load(), "the connection should be closed exactly once")
+}
+
+func TestSession_ProcessUniStream_ConcurrentWithSubscriptions(t *testing.T) {
+	session, _ := newTestSessionWithConn(t)
+
+	groupStream := func(id SubscribeID) *FakeQUICReceiveStream {
+		var buf bytes.Buffer
+		_ = message.StreamTypeGroup.Encode(&buf)
+		_ = message.GroupMessage{SubscribeID: uint64(id)}.Encode(&buf)
+		return &FakeQUICReceiveStream{ReadFunc: buf.Read}
+	}
+
+	var wg sync.WaitGroup
+	for i := range 16 {
+		id := SubscribeID(i + 1)
+		wg.Go(func() {
+			substr := newSendSubscribeStream(id, &FakeQUICStream{}, &SubscribeConfig{})
+			reader := newTrackReader("/test", "video", substr, func() { session.removeTrackReader(id) })
+			session.addTrackReader(id, reader)
+			_ = reader.Close()
+		})
+		wg.Go(func() {
+			session.processUniStream(groupStream(id))
+		})
+	}
+	wg.Wait()
+}
+
+func TestSession_ProbeNotifications_ConcurrentWithClose(t *testing.T) {
+	session := newTestSession(&FakeStreamConn{})
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 50 {
+				session.notifyResults(uint64(i*100 + j))
+				session.notifyTargets(uint64(i*100 + j))
+			}
+		})
+	}
+	wg.Go(func() {
+		_ = session.CloseWithError(NoError, "")
+	})
+
+	// Sending after the channels have been closed must not panic.
+	wg.Wait()
+	session.notifyResults(1)
+	session.notifyTargets(1)
+}
+
+func TestSession_ReportTrackStats(t *testing.T) {
+	conn := &FakeStreamConn{}
+	extendedConn(conn)
+	statsStream := &FakeQUICStream{}
+	var written bytes.Buffer
+	statsStream.WriteFunc = written.Write
+
+	opened := 0
+	conn.OpenStreamFunc = func() (transport.Stream, error) {
+		opened++
+		return statsStream, nil
+	}
+
+	session := newTestSession(conn)
+	defer session.CloseWithError(NoError, "")
+
+	require.NoError(t, session.reportTrackStats(1, TrackStats{Subscribers: 3}))
+	require.NoError(t, session.reportTrackStats(2, TrackStats{Subscribers: 5, DroppedGroups: 1, DroppedFrames: 2}))
+	assert.Equal(t, 1, opened, "reports should share one stats stream")
+
+	r := bytes.NewReader(written.Bytes())
+	var streamType message.StreamType
+	require.NoError(t, streamType.Decode(r))
+	assert.Equal(t, message.StreamTypeStats, streamType)
+
+	var first, second message.TrackStatsMessage
+	require.NoError(t, first.Decode(r))
+	require.NoError(t, second.Decode(r))
+	assert.Equal(t, message.TrackStatsMessage{SubscribeID: 1, Subscribers: 3}, first)
+	assert.Equal(t, message.TrackStatsMessage{SubscribeID: 2, Subscribers: 5, DroppedGroups: 1, DroppedFrames: 2}, second)
+}
+
+func TestSession_ReportTrackStats_ReopensAfterError(t *testing.T) {
+	conn := &FakeStreamConn{}
+	extendedConn(conn)
+	broken := &FakeQUICStream{
+		WriteFunc: func(p []byte) (int, error) {
+			if len(p) == 1 {
+				return 1, nil // stream type
+			}
+			return 0, errors.New("write failed")
+		},
+	}
+	healthy := &FakeQUICStream{}
+	streams := []*FakeQUICStream{broken, healthy}
+	conn.OpenStreamFunc = func() (transport.Stream, error) {
+		s := streams[0]
+		streams = streams[1:]
+		return s, nil
+	}
+
+	session := newTestSession(conn)
+	defer session.CloseWithError(NoError, "")
+
+	assert.Error(t, session.reportTrackStats(1, TrackStats{Subscribers: 1}))
+	assert.NoError(t, session.reportTrackStats(1, TrackStats{Subscribers: 1}))
+	assert.Empty(t, streams, "a failed stats stream should be replaced")
+}
+
+func TestSession_ProcessBiStream_Stats(t *testing.T) {
+	conn := &FakeStreamConn{}
+	extendedConn(conn)
+	session := newTestSession(conn)
+	defer session.CloseWithError(NoError, "")
+
+	substr := newReceiveSubscribeStream(SubscribeID(7), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/test", "video", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	session.addTrackWriter(7, writer)
+
+	var incoming bytes.Buffer
+	require.NoError(t, message.StreamTypeStats.Encode(&incoming))
+	require.NoError(t, message.TrackStatsMessage{SubscribeID: 99, Subscribers: 1}.Encode(&incoming))
+	require.NoError(t, message.TrackStatsMessage{SubscribeID: 7, Subscribers: 12, DroppedFrames: 4}.Encode(&incoming))
+	stream := &FakeQUICStream{ReadFunc: incoming.Read}
+
+	session.processBiStream(stream, func() {})
+
+	stats, ok := writer.Audience()
+	require.True(t, ok)
+	assert.Equal(t, TrackStats{Subscribers: 12, DroppedFrames: 4}, stats)
+}
+
+func TestSession_ReportTrackStats_NotExtended(t *testing.T) {
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) {
+			t.Error("no stats stream should be opened to a moq-lite-04 peer")
+			return &FakeQUICStream{}, nil
+		}
+	})
+
+	assert.ErrorIs(t, session.reportTrackStats(1, TrackStats{Subscribers: 1}), errStatsUnsupported)
+}
+
+func TestSession_ProcessBiStream_Stats_NotExtended(t *testing.T) {
+	session := newTestSession(&FakeStreamConn{})
+	defer session.CloseWithError(NoError, "")
+
+	substr := newReceiveSubscribeStream(SubscribeID(7), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04, nil)
+	writer := newTrackWriter("/test", "video", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	session.addTrackWriter(7, writer)
+
+	var incoming bytes.Buffer
+	require.NoError(t, message.StreamTypeStats.Encode(&incoming))
+	require.NoError(t, message.TrackStatsMessage{SubscribeID: 7, Subscribers: 12}.Encode(&incoming))
+	var canceled transport.StreamErrorCode
+	stream := &FakeQUICStream{
+		ReadFunc:        incoming.Read,
+		CancelWriteFunc: func(code transport.StreamErrorCode) { canceled = code },
+	}
+
+	session.processBiStream(stream, func() {})
+
+	assert.Equal(t, transport.StreamErrorCode(InternalSessionErrorCode), canceled)
+	_, ok := writer.Audience()
+	assert.False(t, ok, "stats of a moq-lite-04 peer should not be applied")
+}
+
+func TestSession_HandleUniStreams_BoundsPending(t *testing.T) {
+	var accepted atomic.Int64
+	conn := &FakeStreamConn{}
+	conn.AcceptUniStreamFunc = func(ctx context.Context) (transport.ReceiveStream, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		accepted.Add(1)
+		// The peer never sends the stream header.
+		return &FakeQUICReceiveStream{
+			ReadFunc: func(p []byte) (int, error) {
+				<-ctx.Done()
+				return 0, io.EOF
+			},
+		}, nil
+	}
+
+	session := newTestSession(conn)
+
+	require.Eventually(t, func() bool {
+		return accepted.Load() == maxPendingUniStreams
+	}, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int64(maxPendingUniStreams), accepted.Load(),
+		"streams should not be accepted while every slot waits for a header")
+
+	_ = session.CloseWithError(NoError, "")
+}
+
+func TestSession_HandleUniStreams_WaitsForControl(t *testing.T) {
+	var accepted atomic.Int64
+	conn := &FakeStreamConn{}
+	conn.AcceptUniStreamFunc = func(ctx context.Context) (transport.ReceiveStream, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		accepted.Add(1)
+		return &FakeQUICReceiveStream{
+			ReadFunc: func(p []byte) (int, error) { return 0, io.EOF },
+		}, nil
+	}
+
+	// The opening message of the control stream arrives late.
+	opening := make(chan struct{})
+	var incoming bytes.Buffer
+	require.NoError(t, message.StreamTypePing.Encode(&incoming))
+	controlStream := &FakeQUICStream{ReadFunc: func(p []byte) (int, error) {
+		<-opening
+		return incoming.Read(p)
+	}}
+	control := make(chan transport.Stream, 1)
+	conn.AcceptStreamFunc = func(ctx context.Context) (transport.Stream, error) {
+		select {
+		case stream := <-control:
+			return stream, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	session := newTestSession(conn)
+	defer session.CloseWithError(NoError, "")
+
+	require.Eventually(t, func() bool { return accepted.Load() > 100 }, time.Second, time.Millisecond,
+		"the data plane should be under load")
+
+	control <- controlStream
+	require.Eventually(t, func() bool {
+		return !isClosed(session.controlPriority.idleCh())
+	}, time.Second, time.Millisecond)
+
+	// At most the stream being accepted when the control stream arrived.
+	time.Sleep(10 * time.Millisecond)
+	held := accepted.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, held, accepted.Load(), "no group stream should be accepted while a control stream is pending")
+
+	close(opening)
+	require.Eventually(t, func() bool { return accepted.Load() > held }, time.Second, time.Millisecond,
+		"group streams should be accepted once the control stream is opened")
+}
+
+func TestSession_HandleBiStreams_StalledOpening(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		done := make(chan struct{})
+		defer close(done)
+
+		// The peer opens a bidirectional stream and never sends on it. Reads
+		// fail once the read deadline passes, as on a QUIC stream.
+		var mu sync.Mutex
+		var deadlines []time.Time
+		stalled := &FakeQUICStream{
+			SetReadDeadlineFunc: func(d time.Time) error {
+				mu.Lock()
+				defer mu.Unlock()
+				deadlines = append(deadlines, d)
+				return nil
+			},
+			ReadFunc: func(p []byte) (int, error) {
+				mu.Lock()
+				var d time.Time
+				if len(deadlines) > 0 {
+					d = deadlines[len(deadlines)-1]
+				}
+				mu.Unlock()
+				if d.IsZero() {
+					<-done
+					return 0, io.EOF
+				}
+				time.Sleep(time.Until(d))
+				return 0, os.ErrDeadlineExceeded
+			},
+		}
+
+		var group bytes.Buffer
+		require.NoError(t, message.StreamTypeGroup.Encode(&group))
+		require.NoError(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 7}.Encode(&group))
+		uni := make(chan transport.ReceiveStream, 2)
+
+		conn := &FakeStreamConn{}
+		bidi := make(chan transport.Stream, 1)
+		bidi <- stalled
+		conn.AcceptStreamFunc = func(ctx context.Context) (transport.Stream, error) {
+			select {
+			case stream := <-bidi:
+				return stream, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		conn.AcceptUniStreamFunc = func(ctx context.Context) (transport.ReceiveStream, error) {
+			select {
+			case stream := <-uni:
+				return stream, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		session := newTestSession(conn)
+		defer session.CloseWithError(NoError, "")
+		substr := newSendSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{})
+		tr := newTrackReader("/broadcastpath", "trackname", substr, func() {})
+		session.addTrackReader(SubscribeID(1), tr)
+
+		synctest.Wait()
+		require.False(t, isClosed(session.controlPriority.idleCh()), "the stalled stream should hold the control priority")
+
+		// The accept loop may already wait for a stream, which then takes
+		// the first one.
+		start := time.Now()
+		uni <- &FakeQUICReceiveStream{ReadFunc: func(p []byte) (int, error) { return 0, io.EOF }}
+		uni <- &FakeQUICReceiveStream{ReadFunc: group.Read}
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+		defer cancel()
+		gr, err := tr.AcceptGroup(ctx)
+		require.NoError(t, err, "group streams should be accepted once the opening message times out")
+		assert.Equal(t, GroupSequence(7), gr.GroupSequence())
+		assert.GreaterOrEqual(t, time.Since(start), streamHeaderTimeout)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.NotEmpty(t, deadlines)
+		assert.True(t, deadlines[len(deadlines)-1].IsZero(), "the read deadline should be cleared")
+	})
+}
+
+func TestSession_ControlLatencyUnderDataLoad(t *testing.T) {
+	const maxQueued = 8
+
+	var header bytes.Buffer
+	require.NoError(t, message.StreamTypeGroup.Encode(&header))
+	require.NoError(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 1}.Encode(&header))
+	headerBytes := header.Bytes()
+
+	var flooded atomic.Int64
+	conn := &FakeStreamConn{}
+	extendedConn(conn)
+	conn.AcceptUniStreamFunc = func(ctx context.Context) (transport.ReceiveStream, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		flooded.Add(1)
+		r := bytes.NewReader(headerBytes)
+		return &FakeQUICReceiveStream{ReadFunc: r.Read}, nil
+	}
+
+	var incoming bytes.Buffer
+	require.NoError(t, message.StreamTypeStats.Encode(&incoming))
+	require.NoError(t, message.TrackStatsMessage{SubscribeID: 7, Subscribers: 1}.Encode(&incoming))
+	controlStream := &FakeQUICStream{ReadFunc: incoming.Read}
+
+	control := make(chan transport.Stream, 1)
+	conn.AcceptStreamFunc = func(ctx context.Context) (transport.Stream, error) {
+		select {
+		case stream := <-control:
+			return stream, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	session := newSession(conn, NewTrackMux(0), nil, &Config{MaxQueuedGroups: maxQueued}, nil, nil, nil, nil)
+	defer session.CloseWithError(NoError, "")
+
+	// A busy track whose groups are never accepted.
+	reader, _ := newTestTrackReader(t)
+	reader.maxQueued = session.config.maxQueuedGroups()
+	session.addTrackReader(1, reader)
+
+	substr := newReceiveSubscribeStream(SubscribeID(7), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/test", "video", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	session.addTrackWriter(7, writer)
+
+	require.Eventually(t, func() bool { return flooded.Load() > 1000 }, 5*time.Second, time.Millisecond,
+		"the data plane should be under load")
+
+	start := time.Now()
+	control <- controlStream
+	select {
+	case <-writer.AudienceUpdated():
+	case <-time.After(time.Second):
+		t.Fatal("control message was not processed under data load")
+	}
+	assert.Less(t, time.Since(start), 250*time.Millisecond, "control-plane latency should stay bounded")
+
+	reader.trackMu.Lock()
+	queued := len(reader.queueing)
+	reader.trackMu.Unlock()
+	assert.LessOrEqual(t, queued, maxQueued, "a busy track should hold a bounded number of groups")
+	assert.NotZero(t, reader.DropStats().Groups[DropReasonStale])
+}
+
+func TestSession_Announcements(t *testing.T) {
+	pr, pw := io.Pipe()
+	stream := &FakeQUICStream{ReadFunc: pr.Read}
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) { return stream, nil }
+	})
+	t.Cleanup(func() { _ = pw.Close() })
+
+	announce := func(status message.AnnounceStatus, suffix string) {
+		go func() {
+			_ = message.AnnounceMessage{AnnounceStatus: status, BroadcastPathSuffix: suffix}.Encode(pw)
+		}()
+	}
+
+	type change struct {
+		path   BroadcastPath
+		active bool
+	}
+	// Each change triggers the next message, so that the order is fixed.
+	next := []func(){
+		func() { announce(message.ACTIVE, "b") },
+		func() { announce(message.ENDED, "a") },
+		func() { announce(message.ACTIVE, "c") },
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	announce(message.ACTIVE, "a")
+	var got []change
+	for ann := range session.Announcements(ctx, "/live/") {
+		got = append(got, change{ann.BroadcastPath(), ann.IsActive()})
+		if len(next) == 0 {
+			break
+		}
+		next[0]()
+		next = next[1:]
+	}
+
+	assert.Equal(t, []change{
+		{"/live/a", true},
+		{"/live/b", true},
+		{"/live/a", false},
+		{"/live/c", true},
+	}, got)
+
+	select {
+	case <-stream.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("the announce stream must be closed after the loop breaks")
+	}
+}
+
+func TestSession_Announcements_StreamEnds(t *testing.T) {
+	pr, pw := io.Pipe()
+	stream := &FakeQUICStream{ReadFunc: pr.Read}
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) { return stream, nil }
+	})
+
+	go func() {
+		_ = message.AnnounceMessage{AnnounceStatus: message.ACTIVE, BroadcastPathSuffix: "a"}.Encode(pw)
+	}()
+
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range session.Announcements(t.Context(), "/live/") {
+			n++
+			stream.CancelWrite(transport.StreamErrorCode(AnnounceErrorCodeInternal))
+		}
+		done <- n
+	}()
+
+	select {
+	case n := <-done:
+		require.GreaterOrEqual(t, n, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("iteration did not end with the stream")
+	}
+}
+
+func TestSession_Announcements_OpenError(t *testing.T) {
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) { return nil, io.EOF }
+	})
+
+	for range session.Announcements(t.Context(), "/live/") {
+		t.Fatal("nothing must be yielded")
+	}
+}
+
+func TestSession_Subscribe_Start(t *testing.T) {
+	var written bytes.Buffer
+	session, _ := newTestSessionWithConn(t, extendedConn, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) {
+			return &FakeQUICStream{WriteFunc: written.Write}, nil
+		}
+	})
+
+	_, _ = session.Subscribe(t.Context(), "/test/path", "video", &SubscribeConfig{Start: SubscribeStartLatestGroup})
+
+	var st message.StreamType
+	require.NoError(t, st.Decode(&written))
+	sm := message.SubscribeMessage{Version: message.VersionLite04Ext}
+	require.NoError(t, sm.Decode(&written))
+	assert.Equal(t, uint64(SubscribeStartLatestGroup), sm.Start)
+}
+
+func TestSession_ProcessBiStream_SubscribeStart(t *testing.T) {
+	session, _ := newTestSessionWithConn(t, extendedConn)
+
+	got := make(chan SubscribeStart, 1)
+	session.mux.PublishFunc(t.Context(), "/test/path", func(tw *TrackWriter) {
+		got <- tw.TrackConfig().Start
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, message.StreamTypeSubscribe.Encode(&buf))
+	require.NoError(t, message.SubscribeMessage{
+		SubscribeID:   1,
+		BroadcastPath: "/test/path",
+		TrackName:     "video",
+		Start:         uint64(SubscribeStartNextGroup),
+		Version:       message.VersionLite04Ext,
+	}.Encode(&buf))
+	stream := &FakeQUICStream{
+		ReadFunc: func(p []byte) (int, error) {
+			if buf.Len() == 0 {
+				return 0, io.EOF
+			}
+			return buf.Read(p)
+		},
+	}
+
+	session.processBiStream(stream, func() {})
+
+	select {
+	case start := <-got:
+		assert.Equal(t, SubscribeStartNextGroup, start)
+	case <-time.After(time.Second):
+		t.Fatal("track handler was not called")
+	}
+}
+
+func TestSession_CloseWithApplicationError(t *testing.T) {
+	var code transport.ConnErrorCode
+	conn := &FakeStreamConn{
+		CloseWithErrorFunc: func(c transport.ConnErrorCode, _ string) error {
+			code = c
+			return nil
+		},
+	}
+	cfg := &Config{ErrorCodes: &ErrorCodeMap{Base: 0x2000}}
+	sess := newSession(conn, NewTrackMux(0), nil, cfg, nil, nil, nil, nil)
+
+	require.NoError(t, sess.CloseWithApplicationError(7, "shutting down"))
+	assert.Equal(t, transport.ConnErrorCode(0x2007), code)
+
+	got, ok := sess.ApplicationError(&SessionError{
+		ApplicationError: &transport.ApplicationError{ErrorCode: 0x2007, Remote: true},
+	})
+	require.True(t, ok)
+	assert.Equal(t, ApplicationErrorCode{Space: SessionErrorSpace, Code: 7}, got)
+}
@@ -0,0 +1,56 @@
+package moqt
+
+import (
+	"errors"
+	"time"
+)
+
+// SubgroupID identifies a subgroup of a group. A group can be split into
+// subgroups, each sent on its own stream, so that parts of a group, such as
+// the layers of a scalable video frame or the slices of a picture, are
+// delivered independently and can be prioritized or canceled on their own.
+// Subgroup 0 is the stream of the group itself.
+type SubgroupID uint64
+
+// Subgroup opens subgroup id of the group on a new stream and returns a
+// GroupWriter for it. Subscribers accept it as a separate GroupReader with
+// the same group sequence and SubgroupID id. Each subgroup should be opened
+// once, and the subgroup is closed or canceled independently of the group.
+//
+// It returns an error if id is 0, if the group does not belong to a
+// TrackWriter, such as the group of a fetch, or if the session did not
+// negotiate the extensions of the group header; see Session.Extensions.
+func (sgs *GroupWriter) Subgroup(id SubgroupID) (*GroupWriter, error) {
+	if id == 0 {
+		return nil, errors.New("moqt: subgroup 0 is the group itself")
+	}
+	if sgs.openSubgroupFunc == nil {
+		return nil, errors.New("moqt: group does not support subgroups")
+	}
+	return sgs.openSubgroupFunc(sgs.sequence, id)
+}
+
+// errSubgroupsUnsupported is returned when a subgroup is opened on a
+// session whose version cannot carry subgroups.
+var errSubgroupsUnsupported = errors.New("moqt: subgroups require VersionLite04Ext")
+
+// SubgroupID returns the subgroup written by the GroupWriter, or 0 for the
+// group itself.
+func (sgs *GroupWriter) SubgroupID() SubgroupID {
+	return sgs.subgroup
+}
+
+// SubgroupID returns the subgroup read by the GroupReader, or 0 for the
+// group itself.
+func (s *GroupReader) SubgroupID() SubgroupID {
+	return s.subgroup
+}
+
+// OpenSubgroupAt opens subgroup id of the group with sequence seq on a new
+// stream without opening the group itself, and advances the sequence counter
+// as OpenGroupAt does. It is meant for relays forwarding the subgroups they
+// receive; publishers open subgroups with GroupWriter.Subgroup.
+func (w *TrackWriter) OpenSubgroupAt(seq GroupSequence, id SubgroupID) (*GroupWriter, error) {
+	w.advanceSequence(seq)
+	return w.openGroup(seq, id, time.Time{})
+}
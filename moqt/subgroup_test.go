@@ -0,0 +1,123 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupWriter_Subgroup(t *testing.T) {
+	tw, streams := newDatagramTrackWriter(nil)
+
+	gw, err := tw.OpenGroup()
+	require.NoError(t, err)
+	assert.Equal(t, SubgroupID(0), gw.SubgroupID())
+
+	sub, err := gw.Subgroup(2)
+	require.NoError(t, err)
+	assert.Equal(t, gw.GroupSequence(), sub.GroupSequence())
+	assert.Equal(t, SubgroupID(2), sub.SubgroupID())
+
+	frame := NewFrame(0)
+	_, _ = frame.Write([]byte("layer"))
+	require.NoError(t, sub.WriteFrame(frame))
+	require.NoError(t, sub.Close())
+
+	// Each subgroup has its own stream with the subgroup in its header.
+	require.Len(t, *streams, 2)
+	r := bytes.NewReader((*streams)[1].Bytes())
+	var st message.StreamType
+	require.NoError(t, st.Decode(r))
+	assert.Equal(t, message.StreamTypeGroup, st)
+	gm := message.GroupMessage{Version: message.VersionLite04Ext}
+	require.NoError(t, gm.Decode(r))
+	assert.Equal(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 1, SubgroupID: 2, Version: message.VersionLite04Ext}, gm)
+	got := NewFrame(0)
+	require.NoError(t, got.decode(r))
+	assert.Equal(t, []byte("layer"), got.Body())
+}
+
+func TestGroupWriter_Subgroup_Errors(t *testing.T) {
+	tests := map[string]struct {
+		group func(t *testing.T) *GroupWriter
+		id    SubgroupID
+	}{
+		"subgroup zero": {
+			group: func(t *testing.T) *GroupWriter {
+				tw, _ := newDatagramTrackWriter(nil)
+				gw, err := tw.OpenGroup()
+				require.NoError(t, err)
+				return gw
+			},
+			id: 0,
+		},
+		"without the extension version": {
+			group: func(t *testing.T) *GroupWriter {
+				substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04, nil)
+				tw := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+					return &FakeQUICSendStream{}, nil
+				}, func() {})
+				gw, err := tw.OpenGroup()
+				require.NoError(t, err)
+				return gw
+			},
+			id: 1,
+		},
+		"group without track": {
+			group: func(t *testing.T) *GroupWriter {
+				return newGroupWriter(&FakeQUICSendStream{}, 1, nil)
+			},
+			id: 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tt.group(t).Subgroup(tt.id)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTrackWriter_OpenSubgroupAt(t *testing.T) {
+	tw, streams := newDatagramTrackWriter(nil)
+
+	sub, err := tw.OpenSubgroupAt(5, 3)
+	require.NoError(t, err)
+	assert.Equal(t, GroupSequence(5), sub.GroupSequence())
+	assert.Equal(t, SubgroupID(3), sub.SubgroupID())
+	require.Len(t, *streams, 1)
+
+	gw, err := tw.OpenGroup()
+	require.NoError(t, err)
+	assert.Greater(t, gw.GroupSequence(), GroupSequence(5), "the sequence counter should advance")
+}
+
+func TestSession_ProcessUniStream_Subgroup(t *testing.T) {
+	sess, _ := newTestSessionWithConn(t, extendedConn)
+	substr := newSendSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{})
+	tr := newTrackReader("/broadcastpath", "trackname", substr, func() {})
+	sess.addTrackReader(SubscribeID(1), tr)
+
+	for _, subgroup := range []uint64{0, 1} {
+		var buf bytes.Buffer
+		require.NoError(t, message.StreamTypeGroup.Encode(&buf))
+		require.NoError(t, message.GroupMessage{SubscribeID: 1, GroupSequence: 7, SubgroupID: subgroup, Version: message.VersionLite04Ext}.Encode(&buf))
+		sess.processUniStream(&FakeQUICReceiveStream{ReadFunc: buf.Read})
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	for _, want := range []SubgroupID{0, 1} {
+		gr, err := tr.AcceptGroup(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, GroupSequence(7), gr.GroupSequence())
+		assert.Equal(t, want, gr.SubgroupID())
+	}
+}
@@ -0,0 +1,40 @@
+package moqt
+
+import (
+	"context"
+	"sync"
+)
+
+// SubscribeRequest describes one subscription of Session.SubscribeAll.
+type SubscribeRequest struct {
+	BroadcastPath BroadcastPath
+	TrackName     TrackName
+
+	// Config is the subscription configuration of the track. If nil, a
+	// zero-value SubscribeConfig is used.
+	Config *SubscribeConfig
+}
+
+// SubscribeAll subscribes to the tracks of requests concurrently, so that a
+// player subscribing to many tracks at once, such as the tracks of a
+// catalog, waits for about one round trip instead of one per track. Each
+// subscription is opened on its own stream, as with Subscribe.
+//
+// It returns the reader and the error of each request, in the order of
+// requests: exactly one of readers[i] and errs[i] is nil. Unlike
+// SubscribeBundle, a failed subscription leaves the others open; the
+// caller closes the readers it does not use.
+func (s *Session) SubscribeAll(ctx context.Context, requests []SubscribeRequest) (readers []*TrackReader, errs []error) {
+	readers = make([]*TrackReader, len(requests))
+	errs = make([]error, len(requests))
+
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		wg.Go(func() {
+			readers[i], errs[i] = s.Subscribe(ctx, req.BroadcastPath, req.TrackName, req.Config)
+		})
+	}
+	wg.Wait()
+
+	return readers, errs
+}
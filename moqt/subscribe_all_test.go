@@ -0,0 +1,55 @@
+package moqt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_SubscribeAll(t *testing.T) {
+	session, streams, mu := newBundleTestSession(t, 2)
+
+	requests := []SubscribeRequest{
+		{BroadcastPath: "/live", TrackName: "video", Config: &SubscribeConfig{Priority: 2}},
+		{BroadcastPath: "/live", TrackName: "audio"},
+		{BroadcastPath: "/other", TrackName: "captions"},
+	}
+	readers, errs := session.SubscribeAll(context.Background(), requests)
+	require.Len(t, readers, len(requests))
+	require.Len(t, errs, len(requests))
+
+	// One subscription fails and the others are kept.
+	var failed int
+	for i, req := range requests {
+		if errs[i] != nil {
+			failed++
+			assert.Nil(t, readers[i])
+			continue
+		}
+		require.NotNil(t, readers[i])
+		t.Cleanup(func() { _ = readers[i].Close() })
+		assert.Equal(t, req.BroadcastPath, readers[i].BroadcastPath)
+		assert.Equal(t, req.TrackName, readers[i].TrackName)
+		if req.Config != nil {
+			assert.Equal(t, req.Config.Priority, readers[i].TrackConfig().Priority)
+		}
+	}
+	assert.Equal(t, 1, failed)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, *streams, 2)
+	for _, stream := range *streams {
+		assert.NoError(t, stream.Context().Err(), "successful subscriptions must stay open")
+	}
+}
+
+func TestSession_SubscribeAll_Empty(t *testing.T) {
+	session, _, _ := newBundleTestSession(t, 0)
+
+	readers, errs := session.SubscribeAll(context.Background(), nil)
+	assert.Empty(t, readers)
+	assert.Empty(t, errs)
+}
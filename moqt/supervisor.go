@@ -0,0 +1,128 @@
+package moqt
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Every goroutine a Session starts is owned by one of its two task groups,
+// which form a small supervision tree rooted at the session:
+//
+//   - Session.tasks owns the session's own loops: the stream and datagram
+//     accept loops, the datagram size watcher, the bitrate detector and the
+//     keep-alive loop. They return once the connection is closed, and
+//     CloseWithError waits for them. If one of them fails, the whole session
+//     is torn down.
+//   - Session.streamTasks owns the handler of each accepted stream and the
+//     response readers of outgoing subscriptions, probes and pings, and the
+//     helpers of the session's iterators and of closing on control abuse.
+//     Each returns when its stream ends, at the latest when the connection
+//     is closed. If one of them fails, only its stream is torn down. They run
+//     application handlers, which may close the session themselves, so
+//     CloseWithError does not wait for them.
+//
+// A TrackBundle and a Client own the goroutines they start in the same way:
+// the track watchers of a bundle, and the supervision loop of a Client and
+// the helpers of its tracks, are tasks that Close waits for.
+//
+// A task fails by panicking. Like net/http does for its handlers, the panic
+// of a task is recovered, logged with its stack and contained to what the
+// task owns, so that a bug in one application handler does not take the
+// process down. A violated invariant is the exception: it means the state of
+// the package is corrupt, so its panic is never recovered and crashes the
+// process with the stack of the violation.
+//
+// The objects of a session start their goroutines as tasks of the session,
+// through goTask: the readers of single streams, such as the update reader
+// of a received subscription and the announcement reader, the closer of a
+// bounded track, the shadow groups of a mirror, the repairs of a
+// retransmitting TrackReader and the filler of a BufferedTrackReader are
+// tasks of Session.streamTasks. A Server owns the handlers of accepted
+// connections and its other helpers in Server.tasks.
+//
+// A few goroutines are not owned by a task group, because they belong to a
+// single call that bounds them: the dials raced by raceDial, the reader of
+// the readiness pipe of Upgrader.Upgrade, the workers ending an Announcement
+// and the closer of an AnnouncementWriter that fell behind the TrackMux.
+// Each documents where it ends.
+
+// taskGroup runs goroutines with a common owner and failure policy.
+type taskGroup struct {
+	wg      sync.WaitGroup
+	running atomic.Int64
+
+	// fail is called with the error of every task that panicked, after the
+	// task was torn down. If nil, the panic is propagated.
+	fail func(err error)
+}
+
+// Go runs f in a new goroutine owned by g. If f panics, the panic is
+// recovered, teardown is called, if not nil, to release what f owned, and
+// the failure is reported to g.fail. The panic of a violated invariant is
+// propagated instead.
+func (g *taskGroup) Go(name string, f func(), teardown func()) {
+	g.running.Add(1)
+	g.wg.Go(func() {
+		defer g.running.Add(-1)
+		defer func() {
+			if v := recover(); v != nil {
+				g.recovered(name, v, teardown)
+			}
+		}()
+		f()
+	})
+}
+
+// goTask runs f as a task of tasks; see taskGroup.Go. Objects created
+// outside a session, such as by tests, have no task group, and run f as a
+// task of its own, whose panic is propagated.
+func goTask(tasks *taskGroup, name string, f func(), teardown func()) {
+	if tasks == nil {
+		tasks = &taskGroup{}
+	}
+	tasks.Go(name, f, teardown)
+}
+
+// recovered handles the panic value v of the task name.
+func (g *taskGroup) recovered(name string, v any, teardown func()) {
+	if _, ok := v.(*invariantViolation); ok {
+		panic(v)
+	}
+	err := &taskPanicError{task: name, value: v, stack: debug.Stack()}
+	if teardown != nil {
+		teardown()
+	}
+	if g.fail == nil {
+		panic(err)
+	}
+	g.fail(err)
+}
+
+// Wait waits for all goroutines of g to return.
+func (g *taskGroup) Wait() {
+	g.wg.Wait()
+}
+
+// Running returns the number of goroutines of g that have not returned.
+func (g *taskGroup) Running() int64 {
+	return g.running.Load()
+}
+
+// taskPanicError is the failure of a task that panicked.
+type taskPanicError struct {
+	task  string
+	value any
+	stack []byte
+}
+
+func (e *taskPanicError) Error() string {
+	return fmt.Sprintf("moqt: %s panicked: %v", e.task, e.value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *taskPanicError) Unwrap() error {
+	err, _ := e.value.(error)
+	return err
+}
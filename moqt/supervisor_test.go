@@ -0,0 +1,331 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskGroup_Go(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tests := map[string]struct {
+		f           func()
+		wantFailure bool
+		wantIs      error
+	}{
+		"returns": {
+			f: func() {},
+		},
+		"panics with value": {
+			f:           func() { panic("boom") },
+			wantFailure: true,
+		},
+		"panics with error": {
+			f:           func() { panic(errBoom) },
+			wantFailure: true,
+			wantIs:      errBoom,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var failures []error
+			var tornDown atomic.Bool
+			g := &taskGroup{fail: func(err error) { failures = append(failures, err) }}
+
+			g.Go("test task", tt.f, func() { tornDown.Store(true) })
+			g.Wait()
+
+			assert.Zero(t, g.Running())
+			assert.Equal(t, tt.wantFailure, tornDown.Load())
+			if !tt.wantFailure {
+				assert.Empty(t, failures)
+				return
+			}
+			require.Len(t, failures, 1)
+			panicErr, ok := errors.AsType[*taskPanicError](failures[0])
+			require.True(t, ok)
+			assert.Contains(t, panicErr.Error(), "test task panicked")
+			assert.NotEmpty(t, panicErr.stack)
+			if tt.wantIs != nil {
+				assert.ErrorIs(t, failures[0], tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestTaskGroup_InvariantViolationPropagates(t *testing.T) {
+	var failed, tornDown bool
+	g := &taskGroup{fail: func(error) { failed = true }}
+	violation := &invariantViolation{msg: "test"}
+
+	defer func() {
+		assert.Same(t, violation, recover())
+		assert.False(t, failed)
+		assert.False(t, tornDown)
+	}()
+	g.recovered("test task", violation, func() { tornDown = true })
+}
+
+func TestTaskGroup_Running(t *testing.T) {
+	var g taskGroup
+	release := make(chan struct{})
+	for range 3 {
+		g.Go("blocked task", func() { <-release }, nil)
+	}
+	assert.Equal(t, int64(3), g.Running())
+
+	close(release)
+	g.Wait()
+	assert.Zero(t, g.Running())
+}
+
+func TestSession_LoopPanicClosesSession(t *testing.T) {
+	closed := make(chan SessionErrorCode, 1)
+	conn := &FakeStreamConn{}
+	conn.AcceptStreamFunc = func(ctx context.Context) (transport.Stream, error) {
+		panic("accept failed")
+	}
+	conn.CloseWithErrorFunc = func(code transport.ConnErrorCode, reason string) error {
+		closed <- SessionErrorCode(code)
+		return nil
+	}
+	newTestSession(conn)
+
+	select {
+	case code := <-closed:
+		assert.Equal(t, InternalSessionErrorCode, code)
+	case <-time.After(time.Second):
+		t.Fatal("session was not closed after its accept loop panicked")
+	}
+}
+
+func TestSession_HandlerPanicClosesStreamOnly(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, message.StreamTypeSubscribe.Encode(&buf))
+	require.NoError(t, message.SubscribeMessage{
+		SubscribeID:   1,
+		BroadcastPath: "/test/path",
+		TrackName:     "video",
+	}.Encode(&buf))
+
+	canceled := make(chan transport.StreamErrorCode, 1)
+	stream := &FakeQUICStream{
+		ReadFunc: buf.Read,
+		CancelWriteFunc: func(code transport.StreamErrorCode) {
+			select {
+			case canceled <- code:
+			default:
+			}
+		},
+	}
+
+	accepted := false
+	sess, conn := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.AcceptStreamFunc = func(ctx context.Context) (transport.Stream, error) {
+			if !accepted {
+				accepted = true
+				return stream, nil
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		conn.OpenUniStreamFunc = func() (transport.SendStream, error) { return &FakeQUICSendStream{}, nil }
+	})
+	sess.mux.PublishFunc(t.Context(), "/test/path", func(tw *TrackWriter) {
+		panic("handler failed")
+	})
+
+	select {
+	case code := <-canceled:
+		assert.Equal(t, transport.StreamErrorCode(SubscribeErrorCodeInternal), code)
+	case <-time.After(time.Second):
+		t.Fatal("stream was not canceled after its handler panicked")
+	}
+
+	assert.Eventually(t, func() bool {
+		return sess.streamTasks.Running() == 0
+	}, time.Second, 10*time.Millisecond)
+	sess.trackWriterMapLocker.RLock()
+	assert.Empty(t, sess.trackWriters)
+	sess.trackWriterMapLocker.RUnlock()
+	assert.NoError(t, conn.Context().Err(), "session should stay open")
+	assert.NotEmpty(t, sess.recentErrors.recent())
+}
+
+// packageGoroutines returns the stacks of the goroutines running code of the
+// package, other than tests and their assertions, by goroutine header.
+func packageGoroutines() map[string]string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	goroutines := make(map[string]string)
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if !strings.Contains(g, "gomoqt/moqt.") || strings.Contains(g, "testing.tRunner") || strings.Contains(g, "stretchr/testify") {
+			continue
+		}
+		header, _, _ := strings.Cut(g, " [")
+		goroutines[header] = g
+	}
+	return goroutines
+}
+
+// blockingStream returns a stream that reads replies to the first stream
+// type written to it, and then blocks until ctx is done.
+func blockingStream(ctx context.Context, replies map[message.StreamType][]byte) *FakeQUICStream {
+	var streamType atomic.Int32
+	streamType.Store(-1)
+	var r *bytes.Reader
+	return &FakeQUICStream{
+		WriteFunc: func(p []byte) (int, error) {
+			if len(p) > 0 {
+				streamType.CompareAndSwap(-1, int32(p[0]))
+			}
+			return len(p), nil
+		},
+		ReadFunc: func(p []byte) (int, error) {
+			if r == nil {
+				r = bytes.NewReader(replies[message.StreamType(streamType.Load())])
+			}
+			if r.Len() > 0 {
+				return r.Read(p)
+			}
+			<-ctx.Done()
+			return 0, &transport.StreamError{ErrorCode: transport.StreamErrorCode(InternalSessionErrorCode), Remote: true}
+		},
+		ParentCtx: ctx,
+	}
+}
+
+func TestSession_NoGoroutinesLeftAfterClose(t *testing.T) {
+	before := packageGoroutines()
+
+	connCtx, closeConn := context.WithCancel(context.Background())
+	defer closeConn()
+
+	// The peer subscribes to a track of the session.
+	var inbound bytes.Buffer
+	require.NoError(t, message.StreamTypeSubscribe.Encode(&inbound))
+	require.NoError(t, message.SubscribeMessage{
+		SubscribeID:   1,
+		BroadcastPath: "/test/path",
+		TrackName:     "video",
+		EndGroup:      2,
+		Version:       message.VersionLite04Ext,
+	}.Encode(&inbound))
+	subscribeStream := blockingStream(connCtx, nil)
+	subscribeStream.ReadFunc = func(p []byte) (int, error) {
+		if inbound.Len() > 0 {
+			return inbound.Read(p)
+		}
+		<-connCtx.Done()
+		return 0, io.EOF
+	}
+	// The peer accepts the subscription of the session.
+	var subscribeOk bytes.Buffer
+	_, _ = subscribeOk.Write([]byte{byte(message.MessageTypeSubscribeOk)})
+	require.NoError(t, message.SubscribeOkMessage{}.Encode(&subscribeOk))
+	replies := map[message.StreamType][]byte{
+		message.StreamTypeSubscribe: subscribeOk.Bytes(),
+	}
+
+	conn := &FakeStreamConn{}
+	extendedConn(conn)
+	var accepted atomic.Bool
+	conn.AcceptStreamFunc = func(ctx context.Context) (transport.Stream, error) {
+		if !accepted.Swap(true) {
+			return subscribeStream, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-connCtx.Done():
+			return nil, io.EOF
+		}
+	}
+	conn.AcceptUniStreamFunc = func(ctx context.Context) (transport.ReceiveStream, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-connCtx.Done():
+			return nil, io.EOF
+		}
+	}
+	conn.OpenStreamFunc = func() (transport.Stream, error) {
+		return blockingStream(connCtx, replies), nil
+	}
+	conn.OpenUniStreamFunc = func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}
+	conn.CloseWithErrorFunc = func(transport.ConnErrorCode, string) error {
+		closeConn()
+		return nil
+	}
+
+	sess := newSession(conn, NewTrackMux(0), nil, &Config{KeepAliveInterval: time.Millisecond}, nil, nil, nil, nil)
+	served := make(chan struct{})
+	sess.mux.PublishFunc(t.Context(), "/test/path", func(tw *TrackWriter) {
+		defer close(served)
+		for seq := GroupSequence(1); seq <= 2; seq++ {
+			group, err := tw.OpenGroupAt(seq)
+			if err != nil {
+				return
+			}
+			_ = group.WriteFrame(NewFrame(0))
+			_ = group.Close()
+		}
+	})
+
+	reader, err := sess.Subscribe(t.Context(), "/remote", "video", &SubscribeConfig{})
+	require.NoError(t, err)
+	buffered := NewBufferedTrackReader(reader, nil)
+	_, err = sess.AcceptAnnounce("/")
+	require.NoError(t, err)
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("the subscription of the peer was not served")
+	}
+
+	left := func() bool {
+		for header := range packageGoroutines() {
+			if _, ok := before[header]; !ok {
+				return true
+			}
+		}
+		return false
+	}
+	require.True(t, left(), "the session should run goroutines")
+
+	require.NoError(t, sess.CloseWithError(NoError, ""))
+	_ = buffered.Close()
+
+	assert.Eventually(t, func() bool { return !left() }, 2*time.Second, 10*time.Millisecond, "goroutines of the session are left")
+	if t.Failed() {
+		for header, stack := range packageGoroutines() {
+			if _, ok := before[header]; !ok {
+				t.Log(stack)
+			}
+		}
+	}
+}
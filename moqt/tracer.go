@@ -0,0 +1,92 @@
+package moqt
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+// Tracer receives span-like callbacks for session setup, subscribe handshakes
+// and group delivery, so that MOQ activity can be correlated with the rest of
+// a distributed trace. The moqt/tracing package adapts OpenTelemetry.
+//
+// Each Start method receives the context of the enclosing operation and
+// returns a function that ends the span; it is called exactly once, with the
+// error that ended the operation or nil. StartSession and StartSubscribe also
+// return a context carrying the span, which is passed to the Start calls of
+// nested operations. incoming reports whether the operation was initiated by
+// the peer.
+//
+// Methods are called synchronously and must return quickly. Implementations
+// must be safe for concurrent use. Embed NopTracer to implement only the
+// methods of interest.
+type Tracer interface {
+	// StartSession is called when the setup of a session begins: by a Dialer
+	// with the context passed to Dial, or by a Server with the connection
+	// context. The span ends when the session is established or setup fails.
+	// transport is "webtransport" or "quic" and addr is the peer address.
+	StartSession(ctx context.Context, transport, addr string, incoming bool) (context.Context, func(err error))
+
+	// StartSubscribe is called when a subscription begins: by Session.Subscribe
+	// with the context passed to it, or on receipt of a SUBSCRIBE with the
+	// context returned by StartSession. The span ends when SUBSCRIBE_OK is
+	// received or sent, or the subscription fails first.
+	StartSubscribe(ctx context.Context, path BroadcastPath, name TrackName, incoming bool) (context.Context, func(err error))
+
+	// StartGroup is called when a group of a subscription is opened for
+	// writing, or accepted for reading, with the context returned by
+	// StartSubscribe. The span ends when the group is closed or reset.
+	StartGroup(ctx context.Context, path BroadcastPath, name TrackName, seq GroupSequence, incoming bool) func(err error)
+}
+
+// NopTracer is a Tracer that records nothing.
+type NopTracer struct{}
+
+func (NopTracer) StartSession(ctx context.Context, _, _ string, _ bool) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+
+func (NopTracer) StartSubscribe(ctx context.Context, _ BroadcastPath, _ TrackName, _ bool) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+
+func (NopTracer) StartGroup(context.Context, BroadcastPath, TrackName, GroupSequence, bool) func(error) {
+	return func(error) {}
+}
+
+var _ Tracer = NopTracer{}
+
+// startSessionSpan starts the session setup span of tracer, if set.
+func startSessionSpan(ctx context.Context, tracer Tracer, transport, addr string, incoming bool) (context.Context, func(error)) {
+	if tracer == nil {
+		return ctx, func(error) {}
+	}
+	return tracer.StartSession(ctx, transport, addr, incoming)
+}
+
+// addrString returns the string form of addr, or "" if it is nil.
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// endSpanOnce wraps end so that only its first call ends the span. It returns
+// nil if end is nil.
+func endSpanOnce(end func(error)) func(error) {
+	if end == nil {
+		return nil
+	}
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { end(err) })
+	}
+}
+
+// localGroupError returns the GroupError of a group reset locally with code.
+func localGroupError(code GroupErrorCode) error {
+	return &GroupError{StreamError: &transport.StreamError{ErrorCode: transport.StreamErrorCode(code)}}
+}
@@ -0,0 +1,262 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type traceKey struct{}
+
+// fakeSpan is a span recorded by fakeTracer.
+type fakeSpan struct {
+	name     string
+	parent   string
+	incoming bool
+	ended    int
+	err      error
+}
+
+// fakeTracer records spans. The context of a span carries its name, so that
+// the parent of nested spans can be checked.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (f *fakeTracer) start(ctx context.Context, name string, incoming bool) (context.Context, func(error)) {
+	parent, _ := ctx.Value(traceKey{}).(string)
+	span := &fakeSpan{name: name, parent: parent, incoming: incoming}
+
+	f.mu.Lock()
+	f.spans = append(f.spans, span)
+	f.mu.Unlock()
+
+	return context.WithValue(ctx, traceKey{}, name), func(err error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		span.ended++
+		span.err = err
+	}
+}
+
+func (f *fakeTracer) StartSession(ctx context.Context, transport, addr string, incoming bool) (context.Context, func(error)) {
+	return f.start(ctx, "session "+transport+" "+addr, incoming)
+}
+
+func (f *fakeTracer) StartSubscribe(ctx context.Context, path BroadcastPath, name TrackName, incoming bool) (context.Context, func(error)) {
+	return f.start(ctx, "subscribe "+string(path)+" "+string(name), incoming)
+}
+
+func (f *fakeTracer) StartGroup(ctx context.Context, path BroadcastPath, name TrackName, seq GroupSequence, incoming bool) func(error) {
+	_, end := f.start(ctx, "group "+seq.String(), incoming)
+	return end
+}
+
+func (f *fakeTracer) recorded() []fakeSpan {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	spans := make([]fakeSpan, 0, len(f.spans))
+	for _, span := range f.spans {
+		spans = append(spans, *span)
+	}
+	return spans
+}
+
+func TestDialer_Tracer_DialQUIC(t *testing.T) {
+	dialErr := errors.New("dial failed")
+
+	tests := map[string]struct {
+		err  error
+		want []fakeSpan
+	}{
+		"success": {
+			want: []fakeSpan{{name: "session quic example.com:9000", parent: "caller", ended: 1}},
+		},
+		"failure": {
+			err:  dialErr,
+			want: []fakeSpan{{name: "session quic example.com:9000", parent: "caller", ended: 1, err: dialErr}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tracer := &fakeTracer{}
+			dialer := &Dialer{
+				Tracer: tracer,
+				DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+					assert.Equal(t, "session quic example.com:9000", ctx.Value(traceKey{}))
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &FakeStreamConn{}, nil
+				},
+			}
+
+			ctx := context.WithValue(t.Context(), traceKey{}, "caller")
+			sess, err := dialer.DialQUIC(ctx, "example.com:9000", nil)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+				t.Cleanup(func() { _ = sess.CloseWithError(NoError, "") })
+				assert.Equal(t, "session quic example.com:9000", sess.traceCtx.Value(traceKey{}))
+			}
+
+			assert.Equal(t, tt.want, tracer.recorded())
+		})
+	}
+}
+
+func TestServer_Tracer_NativeQUICSession(t *testing.T) {
+	tracer := &fakeTracer{}
+	var traceCtx context.Context
+	s := &Server{
+		Tracer: tracer,
+		Handler: HandleFunc(func(sess *Session) {
+			traceCtx = sess.traceCtx
+		}),
+	}
+
+	_ = s.ServeQUICConn(newTestNativeQUICConn(t))
+
+	assert.Equal(t, []fakeSpan{{name: "session quic 127.0.0.1:8080", incoming: true, ended: 1}}, tracer.recorded())
+	require.NotNil(t, traceCtx)
+	assert.Equal(t, "session quic 127.0.0.1:8080", traceCtx.Value(traceKey{}))
+}
+
+func TestSession_Tracer_SubscribeError(t *testing.T) {
+	openErr := errors.New("open failed")
+	tracer := &fakeTracer{}
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) { return nil, openErr }
+	})
+	session.tracer = tracer
+
+	_, err := session.Subscribe(t.Context(), "/test", "video", nil)
+	require.ErrorIs(t, err, openErr)
+
+	spans := tracer.recorded()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "subscribe /test video", spans[0].name)
+	assert.False(t, spans[0].incoming)
+	assert.Equal(t, 1, spans[0].ended)
+	assert.ErrorIs(t, spans[0].err, openErr)
+}
+
+func TestReceiveSubscribeStream_OnResponse(t *testing.T) {
+	tests := map[string]struct {
+		respond  func(substr *receiveSubscribeStream)
+		wantCode SubscribeErrorCode
+		wantErr  bool
+	}{
+		"subscribe ok": {
+			respond: func(substr *receiveSubscribeStream) {
+				require.NoError(t, substr.writeInfo(PublishInfo{}))
+				_ = substr.close()
+			},
+		},
+		"closed without response": {
+			respond: func(substr *receiveSubscribeStream) {
+				_ = substr.close()
+			},
+		},
+		"closed with error": {
+			respond: func(substr *receiveSubscribeStream) {
+				_ = substr.closeWithError(SubscribeErrorCodeNotFound)
+			},
+			wantCode: SubscribeErrorCodeNotFound,
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			stream := &FakeQUICStream{
+				ReadFunc:  func([]byte) (int, error) { return 0, io.EOF },
+				WriteFunc: buf.Write,
+			}
+			substr := newReceiveSubscribeStream(SubscribeID(1), stream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+
+			var errs []error
+			substr.onResponseFunc = endSpanOnce(func(err error) { errs = append(errs, err) })
+			tt.respond(substr)
+
+			require.Len(t, errs, 1)
+			if !tt.wantErr {
+				assert.NoError(t, errs[0])
+				return
+			}
+			var subErr *SubscribeError
+			require.ErrorAs(t, errs[0], &subErr)
+			assert.Equal(t, transport.StreamErrorCode(tt.wantCode), subErr.ErrorCode)
+		})
+	}
+}
+
+func TestTrackWriter_Tracer_Groups(t *testing.T) {
+	tracer := &fakeTracer{}
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	defer writer.Close()
+	writer.tracer = tracer
+	writer.traceCtx = context.WithValue(t.Context(), traceKey{}, "subscribe")
+
+	closed, err := writer.OpenGroup()
+	require.NoError(t, err)
+	require.NoError(t, closed.Close())
+
+	canceled, err := writer.OpenGroup()
+	require.NoError(t, err)
+	canceled.CancelWrite(ExpiredGroupErrorCode)
+	canceled.CancelWrite(ExpiredGroupErrorCode)
+
+	spans := tracer.recorded()
+	require.Len(t, spans, 2)
+	assert.Equal(t, fakeSpan{name: "group 1", parent: "subscribe", ended: 1}, spans[0])
+	assert.Equal(t, "subscribe", spans[1].parent)
+	assert.Equal(t, 1, spans[1].ended)
+	var grpErr *GroupError
+	require.ErrorAs(t, spans[1].err, &grpErr)
+	assert.Equal(t, transport.StreamErrorCode(ExpiredGroupErrorCode), grpErr.ErrorCode)
+}
+
+func TestTrackReader_Tracer_Groups(t *testing.T) {
+	tracer := &fakeTracer{}
+	receiver, _ := newTestTrackReader(t)
+	receiver.tracer = tracer
+	receiver.traceCtx = context.WithValue(t.Context(), traceKey{}, "subscribe")
+
+	receiver.enqueueGroup(GroupSequence(1), &FakeQUICReceiveStream{
+		ReadFunc: func([]byte) (int, error) { return 0, io.EOF },
+	})
+	receiver.enqueueGroup(GroupSequence(2), &FakeQUICReceiveStream{})
+
+	finished, err := receiver.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	require.ErrorIs(t, finished.ReadFrame(NewFrame(0)), io.EOF)
+
+	canceled, err := receiver.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	canceled.CancelRead(InternalGroupErrorCode)
+
+	spans := tracer.recorded()
+	require.Len(t, spans, 2)
+	assert.Equal(t, fakeSpan{name: "group 1", parent: "subscribe", incoming: true, ended: 1}, spans[0])
+	assert.True(t, spans[1].incoming)
+	assert.Equal(t, 1, spans[1].ended)
+	assert.Error(t, spans[1].err)
+}
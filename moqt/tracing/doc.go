@@ -0,0 +1,14 @@
+// Package tracing adapts OpenTelemetry tracing to moqt.Tracer, so that the
+// session setups, subscribe handshakes and group deliveries of a Server or
+// Dialer are recorded as spans of the surrounding distributed traces.
+//
+//	tracer := tracing.New(otel.GetTracerProvider())
+//
+//	server := &moqt.Server{Addr: ":4433", TLSConfig: tlsConfig, Tracer: tracer}
+//	dialer := &moqt.Dialer{Tracer: tracer}
+//
+// Subscriptions started with Session.Subscribe are children of the span in
+// the context passed to it, and subscriptions received from the peer are
+// children of the session setup span. Group spans are children of the span
+// of their subscription.
+package tracing
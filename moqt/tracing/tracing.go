@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the spans.
+const ScopeName = "github.com/qumo-dev/gomoqt/moqt/tracing"
+
+// Span names.
+const (
+	SessionSpanName   = "moqt.session.setup"
+	SubscribeSpanName = "moqt.subscribe"
+	GroupSpanName     = "moqt.group"
+)
+
+// Span attribute keys.
+const (
+	TransportKey     = attribute.Key("moqt.transport")
+	PeerAddressKey   = attribute.Key("moqt.peer.address")
+	BroadcastPathKey = attribute.Key("moqt.broadcast_path")
+	TrackNameKey     = attribute.Key("moqt.track_name")
+	GroupSequenceKey = attribute.Key("moqt.group_sequence")
+)
+
+// Tracer is a moqt.Tracer that records OpenTelemetry spans.
+//
+// Session and subscribe spans are of kind server when initiated by the peer
+// and client otherwise. Group spans are of kind producer when written and
+// consumer when read. A span that ends with an error records it and has an
+// error status.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ moqt.Tracer = (*Tracer)(nil)
+
+// New returns a Tracer that creates spans with provider.
+// If provider is nil, the global TracerProvider is used.
+func New(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(ScopeName)}
+}
+
+// StartSession implements moqt.Tracer.
+func (t *Tracer) StartSession(ctx context.Context, transport, addr string, incoming bool) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, SessionSpanName,
+		trace.WithSpanKind(requestKind(incoming)),
+		trace.WithAttributes(
+			TransportKey.String(transport),
+			PeerAddressKey.String(addr),
+		),
+	)
+	return ctx, endFunc(span)
+}
+
+// StartSubscribe implements moqt.Tracer.
+func (t *Tracer) StartSubscribe(ctx context.Context, path moqt.BroadcastPath, name moqt.TrackName, incoming bool) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, SubscribeSpanName,
+		trace.WithSpanKind(requestKind(incoming)),
+		trace.WithAttributes(
+			BroadcastPathKey.String(string(path)),
+			TrackNameKey.String(string(name)),
+		),
+	)
+	return ctx, endFunc(span)
+}
+
+// StartGroup implements moqt.Tracer.
+func (t *Tracer) StartGroup(ctx context.Context, path moqt.BroadcastPath, name moqt.TrackName, seq moqt.GroupSequence, incoming bool) func(err error) {
+	kind := trace.SpanKindProducer
+	if incoming {
+		kind = trace.SpanKindConsumer
+	}
+	_, span := t.tracer.Start(ctx, GroupSpanName,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			BroadcastPathKey.String(string(path)),
+			TrackNameKey.String(string(name)),
+			GroupSequenceKey.Int64(int64(seq)),
+		),
+	)
+	return endFunc(span)
+}
+
+// requestKind returns the span kind of a session or subscription.
+func requestKind(incoming bool) trace.SpanKind {
+	if incoming {
+		return trace.SpanKindServer
+	}
+	return trace.SpanKindClient
+}
+
+// endFunc returns a function that ends span with the status of err.
+func endFunc(span trace.Span) func(err error) {
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
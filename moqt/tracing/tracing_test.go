@@ -0,0 +1,200 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecorder(t *testing.T) (*Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return New(provider), recorder
+}
+
+func TestNew_NilProvider(t *testing.T) {
+	assert.NotNil(t, New(nil).tracer)
+}
+
+func TestTracer_Spans(t *testing.T) {
+	failure := errors.New("failure")
+
+	tests := map[string]struct {
+		start     func(tr *Tracer, ctx context.Context) func(error)
+		err       error
+		wantName  string
+		wantKind  trace.SpanKind
+		wantAttrs []attribute.KeyValue
+	}{
+		"outgoing session": {
+			start: func(tr *Tracer, ctx context.Context) func(error) {
+				_, end := tr.StartSession(ctx, "quic", "example.com:4433", false)
+				return end
+			},
+			wantName:  SessionSpanName,
+			wantKind:  trace.SpanKindClient,
+			wantAttrs: []attribute.KeyValue{TransportKey.String("quic"), PeerAddressKey.String("example.com:4433")},
+		},
+		"failed incoming session": {
+			start: func(tr *Tracer, ctx context.Context) func(error) {
+				_, end := tr.StartSession(ctx, "webtransport", "192.0.2.1:5000", true)
+				return end
+			},
+			err:       failure,
+			wantName:  SessionSpanName,
+			wantKind:  trace.SpanKindServer,
+			wantAttrs: []attribute.KeyValue{TransportKey.String("webtransport"), PeerAddressKey.String("192.0.2.1:5000")},
+		},
+		"incoming subscribe": {
+			start: func(tr *Tracer, ctx context.Context) func(error) {
+				_, end := tr.StartSubscribe(ctx, "/live", "video", true)
+				return end
+			},
+			wantName:  SubscribeSpanName,
+			wantKind:  trace.SpanKindServer,
+			wantAttrs: []attribute.KeyValue{BroadcastPathKey.String("/live"), TrackNameKey.String("video")},
+		},
+		"sent group": {
+			start: func(tr *Tracer, ctx context.Context) func(error) {
+				return tr.StartGroup(ctx, "/live", "video", 7, false)
+			},
+			wantName: GroupSpanName,
+			wantKind: trace.SpanKindProducer,
+			wantAttrs: []attribute.KeyValue{
+				BroadcastPathKey.String("/live"), TrackNameKey.String("video"), GroupSequenceKey.Int64(7),
+			},
+		},
+		"reset received group": {
+			start: func(tr *Tracer, ctx context.Context) func(error) {
+				return tr.StartGroup(ctx, "/live", "video", 8, true)
+			},
+			err:      failure,
+			wantName: GroupSpanName,
+			wantKind: trace.SpanKindConsumer,
+			wantAttrs: []attribute.KeyValue{
+				BroadcastPathKey.String("/live"), TrackNameKey.String("video"), GroupSequenceKey.Int64(8),
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tracer, recorder := newRecorder(t)
+			ctx, parent := tracer.tracer.Start(t.Context(), "parent")
+
+			tt.start(tracer, ctx)(tt.err)
+			parent.End()
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 2)
+			span := spans[0]
+			assert.Equal(t, tt.wantName, span.Name())
+			assert.Equal(t, tt.wantKind, span.SpanKind())
+			assert.Equal(t, tt.wantAttrs, span.Attributes())
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+
+			if tt.err == nil {
+				assert.Equal(t, codes.Unset, span.Status().Code)
+				assert.Empty(t, span.Events())
+				return
+			}
+			assert.Equal(t, codes.Error, span.Status().Code)
+			assert.Equal(t, tt.err.Error(), span.Status().Description)
+			require.Len(t, span.Events(), 1)
+			assert.Equal(t, "exception", span.Events()[0].Name)
+		})
+	}
+}
+
+func TestTracer_Server(t *testing.T) {
+	tracer, recorder := newRecorder(t)
+
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live", moqt.TrackHandlerFunc(func(tw *moqt.TrackWriter) {
+		gw, err := tw.OpenGroup()
+		if err != nil {
+			return
+		}
+		frame := moqt.NewFrame(0)
+		_, _ = frame.Write([]byte("hello"))
+		_ = gw.WriteFrame(frame)
+		_ = gw.Close()
+		<-tw.Context().Done()
+	}))
+
+	addr := moqttest.Serve(t, &moqt.Server{
+		TrackMux:            mux,
+		DisableWebTransport: true,
+		Tracer:              tracer,
+		Handler: moqt.HandleFunc(func(sess *moqt.Session) {
+			<-sess.Context().Done()
+		}),
+	})
+	sess := moqttest.DialWith(t, &moqt.Dialer{Tracer: tracer}, addr, moqt.NewTrackMux(0))
+
+	ctx, caller := tracer.tracer.Start(t.Context(), "caller")
+	tr, err := sess.Subscribe(ctx, "/live", "video", nil)
+	require.NoError(t, err)
+	caller.End()
+
+	acceptCtx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	gr, err := tr.AcceptGroup(acceptCtx)
+	require.NoError(t, err)
+	for range gr.Frames(nil) {
+	}
+
+	// Spans by name and kind. The server also records the session setup of
+	// the probing dials made before the traced one.
+	var spans map[string][]sdktrace.ReadOnlySpan
+	require.Eventually(t, func() bool {
+		spans = make(map[string][]sdktrace.ReadOnlySpan)
+		for _, span := range recorder.Ended() {
+			key := span.Name() + " " + span.SpanKind().String()
+			spans[key] = append(spans[key], span)
+		}
+		// The caller span and the session setup, subscribe and group spans
+		// of both sides.
+		return len(spans) == 7
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Len(t, spans["moqt.session.setup client"], 1)
+	clientSession := spans["moqt.session.setup client"][0]
+	clientSubscribe := spans["moqt.subscribe client"][0]
+	serverSubscribe := spans["moqt.subscribe server"][0]
+	producer := spans["moqt.group producer"][0]
+	consumer := spans["moqt.group consumer"][0]
+
+	assert.False(t, clientSession.Parent().IsValid())
+	assert.Equal(t, caller.SpanContext().SpanID(), clientSubscribe.Parent().SpanID())
+	assert.Contains(t, spanIDs(spans["moqt.session.setup server"]), serverSubscribe.Parent().SpanID())
+	assert.Equal(t, serverSubscribe.SpanContext().SpanID(), producer.Parent().SpanID())
+	assert.Equal(t, clientSubscribe.SpanContext().SpanID(), consumer.Parent().SpanID())
+
+	for _, list := range spans {
+		for _, span := range list {
+			assert.Equal(t, codes.Unset, span.Status().Code, span.Name())
+		}
+	}
+}
+
+func spanIDs(spans []sdktrace.ReadOnlySpan) []trace.SpanID {
+	ids := make([]trace.SpanID, 0, len(spans))
+	for _, span := range spans {
+		ids = append(ids, span.SpanContext().SpanID())
+	}
+	return ids
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 	"github.com/qumo-dev/gomoqt/transport"
 )
 
@@ -123,7 +124,7 @@ func BenchmarkTrackWriter_OpenGroup(b *testing.B) {
 		b.Run(fmt.Sprintf("groups-%d", size), func(b *testing.B) {
 			mockStream := &FakeQUICStream{}
 
-			substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+			substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 			var streamMu sync.Mutex
 			openUniStreamFunc := func() (transport.SendStream, error) {
@@ -163,7 +164,7 @@ func BenchmarkTrackWriter_ConcurrentOpenGroup(b *testing.B) {
 		b.Run(fmt.Sprintf("goroutines-%d", conc), func(b *testing.B) {
 			mockStream := &FakeQUICStream{}
 
-			substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+			substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 			var streamMu sync.Mutex
 			openUniStreamFunc := func() (transport.SendStream, error) {
@@ -205,7 +206,7 @@ func BenchmarkTrackWriter_ActiveGroupManagement(b *testing.B) {
 		b.Run(fmt.Sprintf("size-%d", size), func(b *testing.B) {
 			mockStream := &FakeQUICStream{}
 
-			substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+			substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 			openUniStreamFunc := func() (transport.SendStream, error) {
 				mockSendStream := &FakeQUICSendStream{}
@@ -254,7 +255,7 @@ func BenchmarkTrackWriter_MemoryAllocation(b *testing.B) {
 	for i := 0; b.Loop(); i++ {
 		mockStream := &FakeQUICStream{}
 
-		substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+		substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 		openUniStreamFunc := func() (transport.SendStream, error) {
 			mockSendStream := &FakeQUICSendStream{}
@@ -306,7 +307,7 @@ func BenchmarkTrackWriter_CloseWithActiveGroups(b *testing.B) {
 			for b.Loop() {
 				mockStream := &FakeQUICStream{}
 
-				substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+				substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 				openUniStreamFunc := func() (transport.SendStream, error) {
 					mockSendStream := &FakeQUICSendStream{}
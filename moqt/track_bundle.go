@@ -0,0 +1,218 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// BundleTrack describes one track of a TrackBundle.
+type BundleTrack struct {
+	// Name is the name of the track within the bundle's broadcast.
+	Name TrackName
+
+	// Config is the subscription configuration of the track. Its Priority is
+	// relative to the bundle priority passed to Session.SubscribeBundle.
+	// If nil, a zero-value SubscribeConfig is used.
+	Config *SubscribeConfig
+}
+
+// TrackBundle is a set of tracks of one broadcast, such as audio, video and
+// captions, that are subscribed, prioritized and torn down as a unit.
+//
+// The bundle ends as soon as any of its tracks ends: the remaining tracks are
+// closed and Context is canceled with the cause of the first track to end.
+// All methods are safe for concurrent use.
+type TrackBundle struct {
+	// BroadcastPath is the path of the broadcast the tracks belong to.
+	BroadcastPath BroadcastPath
+
+	readers  []*TrackReader
+	relative []TrackPriority
+
+	mu       sync.Mutex
+	priority TrackPriority
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	// tasks owns the watchers that end the bundle when a track ends.
+	tasks taskGroup
+}
+
+// SubscribeBundle subscribes to tracks of the broadcast at path as a unit.
+// The subscriptions are opened concurrently; if any of them fails, the others
+// are closed and the first error is returned. Each track is subscribed with
+// priority plus its relative BundleTrack.Config.Priority, saturating at the
+// maximum TrackPriority.
+func (s *Session) SubscribeBundle(ctx context.Context, path BroadcastPath, priority TrackPriority, tracks ...BundleTrack) (*TrackBundle, error) {
+	if len(tracks) == 0 {
+		return nil, errors.New("moqt: empty track bundle")
+	}
+
+	seen := make(map[TrackName]struct{}, len(tracks))
+	relative := make([]TrackPriority, len(tracks))
+	configs := make([]*SubscribeConfig, len(tracks))
+	for i, track := range tracks {
+		if _, ok := seen[track.Name]; ok {
+			return nil, fmt.Errorf("moqt: duplicate track %q in bundle", track.Name)
+		}
+		seen[track.Name] = struct{}{}
+
+		config := SubscribeConfig{}
+		if track.Config != nil {
+			config = *track.Config
+		}
+		relative[i] = config.Priority
+		config.Priority = bundlePriority(priority, relative[i])
+		configs[i] = &config
+	}
+
+	readers := make([]*TrackReader, len(tracks))
+	errs := make([]error, len(tracks))
+	var wg sync.WaitGroup
+	for i, track := range tracks {
+		wg.Go(func() {
+			readers[i], errs[i] = s.Subscribe(ctx, path, track.Name, configs[i])
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		for _, reader := range readers {
+			if reader != nil {
+				_ = reader.Close()
+			}
+		}
+		return nil, fmt.Errorf("moqt: failed to subscribe to bundle track %q: %w", tracks[i].Name, err)
+	}
+
+	b := &TrackBundle{
+		BroadcastPath: path,
+		readers:       readers,
+		relative:      relative,
+		priority:      priority,
+	}
+	b.ctx, b.cancel = context.WithCancelCause(context.Background())
+
+	for _, reader := range readers {
+		b.tasks.Go("bundle track watcher", func() {
+			select {
+			case <-reader.Context().Done():
+				_ = b.end(Cause(reader.Context()))
+			case <-b.ctx.Done():
+			}
+		}, nil)
+	}
+
+	return b, nil
+}
+
+// bundlePriority returns base plus relative, saturating at the maximum.
+func bundlePriority(base, relative TrackPriority) TrackPriority {
+	if sum := int(base) + int(relative); sum < 256 {
+		return TrackPriority(sum)
+	}
+	return TrackPriority(255)
+}
+
+// Readers returns the track readers of the bundle in the order they were requested.
+func (b *TrackBundle) Readers() []*TrackReader {
+	return append([]*TrackReader(nil), b.readers...)
+}
+
+// Reader returns the reader of the named track, or nil if the track is not
+// part of the bundle.
+func (b *TrackBundle) Reader(name TrackName) *TrackReader {
+	for _, reader := range b.readers {
+		if reader.TrackName == name {
+			return reader
+		}
+	}
+	return nil
+}
+
+// Context returns a context that is canceled when the bundle ends.
+// Use context.Cause to obtain the error of the track that ended it.
+func (b *TrackBundle) Context() context.Context {
+	return b.ctx
+}
+
+// Priority returns the current bundle priority.
+func (b *TrackBundle) Priority() TrackPriority {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.priority
+}
+
+// SetPriority changes the bundle priority, updating every track while
+// preserving their relative priorities.
+func (b *TrackBundle) SetPriority(priority TrackPriority) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ctx.Err() != nil {
+		return context.Cause(b.ctx)
+	}
+
+	var errs []error
+	for i, reader := range b.readers {
+		config := *reader.TrackConfig()
+		config.Priority = bundlePriority(priority, b.relative[i])
+		if err := reader.Update(&config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	b.priority = priority
+	return errors.Join(errs...)
+}
+
+// Close closes every track of the bundle.
+// It is safe to call more than once.
+func (b *TrackBundle) Close() error {
+	err := b.end(ErrClosedTrack)
+	b.tasks.Wait()
+	return err
+}
+
+// CloseWithError closes every track of the bundle with the provided code.
+func (b *TrackBundle) CloseWithError(code SubscribeErrorCode) {
+	if !b.stop(ErrClosedTrack) {
+		return
+	}
+	for _, reader := range b.readers {
+		reader.CloseWithError(code)
+	}
+	b.tasks.Wait()
+}
+
+// end ends the bundle with cause and closes all tracks once.
+func (b *TrackBundle) end(cause error) error {
+	if !b.stop(cause) {
+		return nil
+	}
+
+	var errs []error
+	for _, reader := range b.readers {
+		if err := reader.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stop cancels the bundle context and reports whether this call ended it.
+func (b *TrackBundle) stop(cause error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ctx.Err() != nil {
+		return false
+	}
+	b.cancel(cause)
+	return true
+}
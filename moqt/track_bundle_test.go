@@ -0,0 +1,157 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBundleTestSession returns a session whose subscribe streams are
+// accepted with SUBSCRIBE_OK. failAt makes the n-th opened stream fail (1-based).
+func newBundleTestSession(t *testing.T, failAt int32) (*Session, *[]*FakeQUICStream, *sync.Mutex) {
+	t.Helper()
+
+	var (
+		mu      sync.Mutex
+		streams []*FakeQUICStream
+		opened  atomic.Int32
+	)
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) {
+			if opened.Add(1) == failAt {
+				return nil, errors.New("open failed")
+			}
+
+			var resp bytes.Buffer
+			_, _ = resp.Write([]byte{byte(message.MessageTypeSubscribeOk)})
+			_ = message.SubscribeOkMessage{}.Encode(&resp)
+			stream := &FakeQUICStream{ReadFunc: resp.Read}
+
+			mu.Lock()
+			streams = append(streams, stream)
+			mu.Unlock()
+			return stream, nil
+		}
+	})
+	return session, &streams, &mu
+}
+
+func TestSession_SubscribeBundle(t *testing.T) {
+	session, _, _ := newBundleTestSession(t, 0)
+
+	bundle, err := session.SubscribeBundle(context.Background(), "/live", 10,
+		BundleTrack{Name: "video", Config: &SubscribeConfig{Priority: 2, Ordered: true}},
+		BundleTrack{Name: "audio", Config: &SubscribeConfig{Priority: 5}},
+		BundleTrack{Name: "captions"},
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bundle.Close() })
+
+	require.Len(t, bundle.Readers(), 3)
+	assert.Equal(t, TrackPriority(12), bundle.Reader("video").TrackConfig().Priority)
+	assert.True(t, bundle.Reader("video").TrackConfig().Ordered)
+	assert.Equal(t, TrackPriority(15), bundle.Reader("audio").TrackConfig().Priority)
+	assert.Equal(t, TrackPriority(10), bundle.Reader("captions").TrackConfig().Priority)
+	assert.Nil(t, bundle.Reader("missing"))
+
+	require.NoError(t, bundle.SetPriority(254))
+	assert.Equal(t, TrackPriority(254), bundle.Priority())
+	assert.Equal(t, TrackPriority(255), bundle.Reader("video").TrackConfig().Priority)
+	assert.Equal(t, TrackPriority(254), bundle.Reader("captions").TrackConfig().Priority)
+}
+
+func TestSession_SubscribeBundle_InvalidTracks(t *testing.T) {
+	session, _, _ := newBundleTestSession(t, 0)
+
+	_, err := session.SubscribeBundle(context.Background(), "/live", 0)
+	assert.Error(t, err)
+
+	_, err = session.SubscribeBundle(context.Background(), "/live", 0, BundleTrack{Name: "video"}, BundleTrack{Name: "video"})
+	assert.Error(t, err)
+}
+
+func TestSession_SubscribeBundle_FailureClosesOthers(t *testing.T) {
+	session, streams, mu := newBundleTestSession(t, 2)
+
+	bundle, err := session.SubscribeBundle(context.Background(), "/live", 0,
+		BundleTrack{Name: "video"}, BundleTrack{Name: "audio"}, BundleTrack{Name: "captions"},
+	)
+	require.Error(t, err)
+	assert.Nil(t, bundle)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, *streams, 2)
+	for _, stream := range *streams {
+		assert.Error(t, stream.Context().Err(), "successful subscriptions must be closed")
+	}
+
+	session.trackReaderMapLocker.RLock()
+	defer session.trackReaderMapLocker.RUnlock()
+	assert.Empty(t, session.trackReaders)
+}
+
+func TestTrackBundle_TrackEndEndsBundle(t *testing.T) {
+	session, _, _ := newBundleTestSession(t, 0)
+
+	bundle, err := session.SubscribeBundle(context.Background(), "/live", 0,
+		BundleTrack{Name: "video"}, BundleTrack{Name: "audio"},
+	)
+	require.NoError(t, err)
+
+	bundle.Reader("audio").CloseWithError(SubscribeErrorCodeInternal)
+
+	select {
+	case <-bundle.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("bundle did not end")
+	}
+	assert.Error(t, context.Cause(bundle.Context()))
+	assert.Error(t, bundle.Reader("video").Context().Err(), "remaining tracks must be closed")
+	assert.Error(t, bundle.SetPriority(1))
+	assert.NoError(t, bundle.Close())
+}
+
+func TestTrackBundle_Close(t *testing.T) {
+	session, _, _ := newBundleTestSession(t, 0)
+
+	bundle, err := session.SubscribeBundle(context.Background(), "/live", 0,
+		BundleTrack{Name: "video"}, BundleTrack{Name: "audio"},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, bundle.Close())
+	assert.ErrorIs(t, context.Cause(bundle.Context()), ErrClosedTrack)
+	for _, reader := range bundle.Readers() {
+		assert.Error(t, reader.Context().Err())
+	}
+
+	// Closing again is a no-op.
+	require.NoError(t, bundle.Close())
+	bundle.CloseWithError(SubscribeErrorCodeInternal)
+}
+
+func TestTrackBundle_CloseWithError(t *testing.T) {
+	session, streams, mu := newBundleTestSession(t, 0)
+
+	bundle, err := session.SubscribeBundle(context.Background(), "/live", 0, BundleTrack{Name: "video"})
+	require.NoError(t, err)
+
+	bundle.CloseWithError(SubscribeErrorCodeInternal)
+	assert.ErrorIs(t, context.Cause(bundle.Context()), ErrClosedTrack)
+
+	mu.Lock()
+	defer mu.Unlock()
+	strErr, ok := errors.AsType[*transport.StreamError](context.Cause((*streams)[0].Context()))
+	require.True(t, ok)
+	assert.Equal(t, transport.StreamErrorCode(SubscribeErrorCodeInternal), strErr.ErrorCode)
+}
@@ -2,19 +2,159 @@ package moqt
 
 import (
 	"fmt"
+	"time"
 )
 
 // SubscribeConfig holds subscription parameters for a track.
 // It describes the subscriber's requested delivery priority, ordering, latency,
 // and group range.
+//
+// Start, StartTime, StartBehind, Visibility, AuthToken and Parameters are
+// extensions: Session.Subscribe fails if any but Visibility is set on a
+// session that did not negotiate them, and Visibility is not sent; see
+// Session.Extensions.
 type SubscribeConfig struct {
-	Priority   TrackPriority
-	Ordered    bool
+	// Priority is the priority of the track relative to the other tracks of
+	// the session: under congestion, the publisher sends tracks of higher
+	// priority first.
+	Priority TrackPriority
+
+	// Ordered asks the publisher to send groups in ascending order, oldest
+	// first, such as for audio. Otherwise newer groups go first, so that
+	// stale groups wait for current ones.
+	Ordered bool
+
 	MaxLatency uint64
 	StartGroup GroupSequence
 	EndGroup   GroupSequence
+
+	// Start is where the subscriber asks the subscription to start when
+	// StartGroup is not set. It is sent with the SUBSCRIBE message only, and
+	// kept by the publisher across updates.
+	Start SubscribeStart
+
+	// StartTime asks the subscription to start from the latest group whose
+	// declared time is at or before it, and StartBehind from the latest group
+	// at least that far behind the newest one; see
+	// TrackWriter.OpenGroupWithTime. They apply when StartGroup is not set,
+	// with StartTime taking precedence, and are resolved by publishers that
+	// keep the times of their groups, such as relays serving from their
+	// cache; msf.TimeFilter sets them from a media time as well. Like Start,
+	// they are sent with the SUBSCRIBE message only and kept by the
+	// publisher across updates.
+	StartTime   time.Time
+	StartBehind time.Duration
+
+	// Visibility is the hint of the subscriber on whether the track is being
+	// presented. It is sent with updates only, so that a subscription always
+	// starts visible; see TrackReader.UpdateVisibility.
+	Visibility Visibility
+
+	// AuthToken is sent with the SUBSCRIBE message for the publisher's
+	// Authorizer. It is not sent with updates and is not part of the
+	// configuration seen by the publisher.
+	AuthToken string
+
+	// Parameters are extension parameters sent with the SUBSCRIBE message,
+	// and kept by the publisher across updates. See Config.Parameters.
+	Parameters Parameters
+}
+
+// extensionFields returns the names of the extension fields set in sc that
+// are sent with the SUBSCRIBE message.
+func (sc *SubscribeConfig) extensionFields() []string {
+	var fields []string
+	if sc.Start != SubscribeStartDefault {
+		fields = append(fields, "Start")
+	}
+	if !sc.StartTime.IsZero() {
+		fields = append(fields, "StartTime")
+	}
+	if sc.StartBehind != 0 {
+		fields = append(fields, "StartBehind")
+	}
+	if sc.AuthToken != "" {
+		fields = append(fields, "AuthToken")
+	}
+	if len(sc.Parameters) != 0 {
+		fields = append(fields, "Parameters")
+	}
+	return fields
 }
 
 func (sc SubscribeConfig) String() string {
-	return fmt.Sprintf("{ subscriber_priority: %d, ordered: %t, max_latency_ms: %d, start_group: %d, end_group: %d }", sc.Priority, sc.Ordered, sc.MaxLatency, sc.StartGroup, sc.EndGroup)
+	return fmt.Sprintf("{ subscriber_priority: %d, ordered: %t, max_latency_ms: %d, start: %s, start_group: %d, end_group: %d, start_time: %s, start_behind: %s, visibility: %s }", sc.Priority, sc.Ordered, sc.MaxLatency, sc.Start, sc.StartGroup, sc.EndGroup, sc.StartTime.Format(time.RFC3339Nano), sc.StartBehind, sc.Visibility)
+}
+
+// SubscribeStart is where a subscription starts, trading the time to the
+// first group against its latency. Publishers that keep no past groups can
+// only start at the current or the next group; relays serve past groups from
+// their cache.
+type SubscribeStart uint8
+
+const (
+	// SubscribeStartDefault lets the publisher choose. Relays start with the
+	// groups they have cached.
+	SubscribeStartDefault SubscribeStart = iota
+
+	// SubscribeStartLatestGroup starts with the most recent complete group,
+	// so that a player can start at once, behind the live edge.
+	SubscribeStartLatestGroup
+
+	// SubscribeStartCurrentGroup starts with the group in progress, from its
+	// first frame.
+	SubscribeStartCurrentGroup
+
+	// SubscribeStartNextGroup starts with the next group to begin, for the
+	// lowest latency at the cost of waiting for a group boundary.
+	SubscribeStartNextGroup
+)
+
+func (s SubscribeStart) String() string {
+	switch s {
+	case SubscribeStartDefault:
+		return "default"
+	case SubscribeStartLatestGroup:
+		return "latest_group"
+	case SubscribeStartCurrentGroup:
+		return "current_group"
+	case SubscribeStartNextGroup:
+		return "next_group"
+	default:
+		return fmt.Sprintf("subscribe_start(%d)", uint8(s))
+	}
+}
+
+// Visibility is a hint of a subscriber on whether it presents a track, such
+// as when the window of a player is hidden or the application goes to the
+// background. Publishers and relays may downgrade or pause the delivery of a
+// subscription that is not visible, without ending it, and resume on the
+// opposite hint.
+type Visibility uint8
+
+const (
+	// VisibilityVisible is the default: the track is presented.
+	VisibilityVisible Visibility = iota
+
+	// VisibilityHidden means that the track is not presented, such as in a
+	// hidden window or a collapsed view, and its groups would be discarded.
+	VisibilityHidden
+
+	// VisibilityBackground means that the application is in the background.
+	// The subscriber may still use the track, such as to keep playing audio,
+	// and publishers may downgrade rather than pause it.
+	VisibilityBackground
+)
+
+func (v Visibility) String() string {
+	switch v {
+	case VisibilityVisible:
+		return "visible"
+	case VisibilityHidden:
+		return "hidden"
+	case VisibilityBackground:
+		return "background"
+	default:
+		return fmt.Sprintf("visibility(%d)", uint8(v))
+	}
 }
@@ -62,12 +62,35 @@ func TestSubscribeConfig_String(t *testing.T) {
 		MaxLatency: 250,
 		StartGroup: 5,
 		EndGroup:   10,
+		Start:      SubscribeStartNextGroup,
+		Visibility: VisibilityBackground,
 	}
 
 	result := config.String()
 	assert.Contains(t, result, "subscriber_priority: 128")
 	assert.Contains(t, result, "ordered: true")
 	assert.Contains(t, result, "max_latency_ms: 250")
+	assert.Contains(t, result, "start: next_group")
 	assert.Contains(t, result, "start_group: 5")
 	assert.Contains(t, result, "end_group: 10")
+	assert.Contains(t, result, "visibility: background")
+}
+
+func TestSubscribeStart_String(t *testing.T) {
+	tests := map[string]struct {
+		start SubscribeStart
+		want  string
+	}{
+		"default":       {start: SubscribeStartDefault, want: "default"},
+		"latest group":  {start: SubscribeStartLatestGroup, want: "latest_group"},
+		"current group": {start: SubscribeStartCurrentGroup, want: "current_group"},
+		"next group":    {start: SubscribeStartNextGroup, want: "next_group"},
+		"unknown":       {start: SubscribeStart(9), want: "subscribe_start(9)"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.start.String())
+		})
+	}
 }
@@ -0,0 +1,73 @@
+package moqt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// BroadcastID is an optional globally unique identifier of a broadcast.
+// The origin publisher assigns it with NewAnnouncementWithID and it is
+// carried unchanged in announcements across relays, so that every hop can
+// identify the broadcast independently of the session it arrives on and of
+// the path prefix it is announced under. The zero BroadcastID means none.
+type BroadcastID [16]byte
+
+// NewBroadcastID returns a random (version 4) UUID.
+func NewBroadcastID() BroadcastID {
+	var id BroadcastID
+	_, _ = rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+// IsZero reports whether id is the zero BroadcastID.
+func (id BroadcastID) IsZero() bool {
+	return id == BroadcastID{}
+}
+
+// String returns id in the canonical UUID format.
+func (id BroadcastID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], id[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], id[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], id[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], id[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], id[10:])
+	return string(b[:])
+}
+
+// TrackID is a stable global identifier of a track, suitable as a cache key
+// that survives reconnections, relay restarts and path renaming.
+type TrackID [16]byte
+
+// NewTrackID returns the ID of the track name of a broadcast. If the
+// broadcast has a BroadcastID, the ID is derived from it and name only;
+// otherwise it is derived from path and name, which is stable as long as the
+// broadcast path is.
+func NewTrackID(broadcast BroadcastID, path BroadcastPath, name TrackName) TrackID {
+	h := sha256.New()
+	if broadcast.IsZero() {
+		h.Write([]byte{0})
+		h.Write([]byte(path))
+	} else {
+		h.Write([]byte{1})
+		h.Write(broadcast[:])
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+
+	var id TrackID
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+// String returns the hexadecimal form of id.
+func (id TrackID) String() string {
+	return hex.EncodeToString(id[:])
+}
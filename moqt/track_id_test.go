@@ -0,0 +1,77 @@
+package moqt
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBroadcastID(t *testing.T) {
+	id := NewBroadcastID()
+	assert.False(t, id.IsZero())
+	assert.NotEqual(t, id, NewBroadcastID())
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id.String())
+	assert.True(t, BroadcastID{}.IsZero())
+}
+
+func TestNewTrackID(t *testing.T) {
+	id := NewBroadcastID()
+
+	tests := map[string]struct {
+		a, b  TrackID
+		equal bool
+	}{
+		"same path and name": {
+			a:     NewTrackID(BroadcastID{}, "/live/a", "video"),
+			b:     NewTrackID(BroadcastID{}, "/live/a", "video"),
+			equal: true,
+		},
+		"different path": {
+			a: NewTrackID(BroadcastID{}, "/live/a", "video"),
+			b: NewTrackID(BroadcastID{}, "/live/b", "video"),
+		},
+		"different name": {
+			a: NewTrackID(BroadcastID{}, "/live/a", "video"),
+			b: NewTrackID(BroadcastID{}, "/live/a", "audio"),
+		},
+		"broadcast ID ignores path": {
+			a:     NewTrackID(id, "/live/a", "video"),
+			b:     NewTrackID(id, "/edge/live/a", "video"),
+			equal: true,
+		},
+		"broadcast ID differs from path": {
+			a: NewTrackID(id, "/live/a", "video"),
+			b: NewTrackID(BroadcastID{}, "/live/a", "video"),
+		},
+		"different broadcast ID": {
+			a: NewTrackID(id, "/live/a", "video"),
+			b: NewTrackID(NewBroadcastID(), "/live/a", "video"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.equal {
+				assert.Equal(t, tt.a, tt.b)
+			} else {
+				assert.NotEqual(t, tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestAnnouncement_TrackID(t *testing.T) {
+	id := NewBroadcastID()
+	ann, end := NewAnnouncementWithID(t.Context(), "/live/a", id)
+	defer end()
+
+	assert.Equal(t, id, ann.BroadcastID())
+	assert.Equal(t, NewTrackID(id, "/live/a", "video"), ann.TrackID("video"))
+	assert.Len(t, ann.TrackID("video").String(), 32)
+
+	plain, end := NewAnnouncement(t.Context(), "/live/a")
+	defer end()
+	assert.True(t, plain.BroadcastID().IsZero())
+	assert.Equal(t, NewTrackID(BroadcastID{}, "/live/a", "video"), plain.TrackID("video"))
+}
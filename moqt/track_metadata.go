@@ -0,0 +1,124 @@
+package moqt
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+)
+
+// TrackMetadata is information a publisher declares about a track of an
+// announced broadcast. It is carried in the announcement, so a subscriber
+// such as an ABR controller can choose between the renditions of a
+// broadcast before subscribing to any of them. Zero fields are unknown.
+//
+// The values are declarations of the publisher and are not verified against
+// the data of the track.
+type TrackMetadata struct {
+	// Bitrate is the declared bitrate in bits per second.
+	Bitrate uint64
+
+	// Width and Height are the declared resolution in pixels.
+	Width  uint64
+	Height uint64
+
+	// KeyframeInterval is the declared interval between keyframes. It is
+	// carried with millisecond precision.
+	KeyframeInterval time.Duration
+}
+
+// IsZero reports whether md declares nothing.
+func (md TrackMetadata) IsZero() bool {
+	return md == TrackMetadata{}
+}
+
+// SetTrackMetadata declares metadata of the track name of the announced
+// broadcast. The metadata is sent with the announcement, so it must be set
+// before the announcement is passed to TrackMux.Announce; peers the broadcast
+// was already announced to do not see later changes. Nor do peers of
+// sessions without extensions see it at all; see Session.Extensions.
+func (a *Announcement) SetTrackMetadata(name TrackName, md TrackMetadata) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.tracks == nil {
+		a.tracks = make(map[TrackName]TrackMetadata)
+	}
+	a.tracks[name] = md
+}
+
+// TrackMetadata returns the metadata declared for the track name, and
+// whether the publisher declared any.
+func (a *Announcement) TrackMetadata(name TrackName) (TrackMetadata, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	md, ok := a.tracks[name]
+	return md, ok
+}
+
+// TrackNames returns the sorted names of the tracks with declared metadata.
+func (a *Announcement) TrackNames() []TrackName {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(a.tracks))
+}
+
+// trackDescriptors returns the wire form of the track metadata of a.
+func (a *Announcement) trackDescriptors() []message.TrackDescriptor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.tracks) == 0 {
+		return nil
+	}
+	descs := make([]message.TrackDescriptor, 0, len(a.tracks))
+	for _, name := range slices.Sorted(maps.Keys(a.tracks)) {
+		md := a.tracks[name]
+		params := make(map[uint64]uint64, 4)
+		if md.Bitrate != 0 {
+			params[message.TrackParameterBitrate] = md.Bitrate
+		}
+		if md.Width != 0 {
+			params[message.TrackParameterWidth] = md.Width
+		}
+		if md.Height != 0 {
+			params[message.TrackParameterHeight] = md.Height
+		}
+		if ms := md.KeyframeInterval.Milliseconds(); ms > 0 {
+			params[message.TrackParameterKeyframeInterval] = uint64(ms)
+		}
+		descs = append(descs, message.TrackDescriptor{
+			TrackName:  string(name),
+			Parameters: params,
+		})
+	}
+	return descs
+}
+
+// setTrackDescriptors sets the track metadata of a from its wire form.
+// Unknown parameters are ignored.
+func (a *Announcement) setTrackDescriptors(descs []message.TrackDescriptor) {
+	for _, desc := range descs {
+		params := desc.Parameters
+		a.SetTrackMetadata(TrackName(desc.TrackName), TrackMetadata{
+			Bitrate:          params[message.TrackParameterBitrate],
+			Width:            params[message.TrackParameterWidth],
+			Height:           params[message.TrackParameterHeight],
+			KeyframeInterval: time.Duration(params[message.TrackParameterKeyframeInterval]) * time.Millisecond,
+		})
+	}
+}
+
+// copyTrackMetadata copies the track metadata of src to a.
+func (a *Announcement) copyTrackMetadata(src *Announcement) {
+	src.mu.Lock()
+	tracks := maps.Clone(src.tracks)
+	src.mu.Unlock()
+
+	a.mu.Lock()
+	a.tracks = tracks
+	a.mu.Unlock()
+}
@@ -0,0 +1,74 @@
+package moqt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnouncement_TrackDescriptors(t *testing.T) {
+	tests := map[string]struct {
+		metadata TrackMetadata
+		want     map[uint64]uint64
+	}{
+		"zero": {
+			metadata: TrackMetadata{},
+			want:     map[uint64]uint64{},
+		},
+		"all fields": {
+			metadata: TrackMetadata{Bitrate: 800_000, Width: 640, Height: 360, KeyframeInterval: 1500 * time.Millisecond},
+			want: map[uint64]uint64{
+				message.TrackParameterBitrate:          800_000,
+				message.TrackParameterWidth:            640,
+				message.TrackParameterHeight:           360,
+				message.TrackParameterKeyframeInterval: 1500,
+			},
+		},
+		"sub-millisecond keyframe interval": {
+			metadata: TrackMetadata{KeyframeInterval: time.Microsecond},
+			want:     map[uint64]uint64{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ann, _ := NewAnnouncement(context.Background(), "/test")
+			ann.SetTrackMetadata("video", tt.metadata)
+
+			descs := ann.trackDescriptors()
+			require.Len(t, descs, 1)
+			assert.Equal(t, "video", descs[0].TrackName)
+			assert.Equal(t, tt.want, descs[0].Parameters)
+
+			received, _ := NewAnnouncement(context.Background(), "/test")
+			received.setTrackDescriptors(descs)
+			md, ok := received.TrackMetadata("video")
+			require.True(t, ok)
+			if tt.metadata.KeyframeInterval < time.Millisecond {
+				tt.metadata.KeyframeInterval = 0
+			}
+			assert.Equal(t, tt.metadata, md)
+		})
+	}
+}
+
+func TestAnnouncement_TrackDescriptors_None(t *testing.T) {
+	ann, _ := NewAnnouncement(context.Background(), "/test")
+	assert.Nil(t, ann.trackDescriptors())
+	assert.Empty(t, ann.TrackNames())
+}
+
+func TestAnnouncement_CopyTrackMetadata(t *testing.T) {
+	src, _ := NewAnnouncement(context.Background(), "/test")
+	src.SetTrackMetadata("video", TrackMetadata{Bitrate: 1000})
+
+	dst, _ := NewAnnouncement(context.Background(), "/test")
+	dst.copyTrackMetadata(src)
+	src.SetTrackMetadata("audio", TrackMetadata{Bitrate: 64})
+
+	assert.Equal(t, []TrackName{"video"}, dst.TrackNames())
+}
@@ -3,8 +3,11 @@ package moqt
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"iter"
 	"sync"
+	"time"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 	"github.com/qumo-dev/gomoqt/transport"
@@ -37,28 +40,69 @@ func (m *groupReaderManager) removeGroup(group *GroupReader) {
 	delete(m.activeGroups, group)
 }
 
+// close marks the manager closed and returns the groups that were active.
+func (m *groupReaderManager) close() []*GroupReader {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	groups := make([]*GroupReader, 0, len(m.activeGroups))
+	for g := range m.activeGroups {
+		groups = append(groups, g)
+	}
+	m.activeGroups = nil
+	return groups
+}
+
+// rangeEndLinger is how long AcceptGroup waits, after the publisher ended a
+// subscription with an EndGroup, for that group to arrive, as group streams
+// sent before the end may be received after it.
+const rangeEndLinger = time.Second
+
 func newTrackReader(path BroadcastPath, name TrackName, subscribeStream *sendSubscribeStream, onCloseFunc func()) *TrackReader {
 	track := &TrackReader{
 		BroadcastPath:       path,
 		TrackName:           name,
 		sendSubscribeStream: subscribeStream,
 		queuedCh:            make(chan struct{}, 1),
-		queueing: make([]struct {
-			sequence GroupSequence
-			stream   transport.ReceiveStream
-		}, 0, 1<<3),
-		dequeued:     make(map[*GroupReader]struct{}),
-		groupManager: newGroupReaderManager(),
-		onCloseFunc:  onCloseFunc,
-		ctx:          context.WithValue(subscribeStream.stream.Context(), biStreamTypeCtxKey, message.StreamTypeSubscribe),
+		queueing:            make([]queuedGroup, 0, 1<<3),
+		dequeued:            make(map[*GroupReader]struct{}),
+		groupManager:        newGroupReaderManager(),
+		onCloseFunc:         onCloseFunc,
+		ctx:                 context.WithValue(subscribeStream.stream.Context(), biStreamTypeCtxKey, message.StreamTypeSubscribe),
 	}
 
 	return track
 }
 
+// queuedGroup is a received group or subgroup stream waiting to be accepted.
+type queuedGroup struct {
+	sequence  GroupSequence
+	subgroup  SubgroupID
+	timestamp time.Time
+	// extensionHeaders is set if the frames of the group start with
+	// extension headers, as announced by its header.
+	extensionHeaders bool
+	stream           transport.ReceiveStream
+}
+
+// receivedGroup returns the queued group of a stream or datagram starting
+// with the header gm.
+func receivedGroup(gm message.GroupMessage, stream transport.ReceiveStream) queuedGroup {
+	return queuedGroup{
+		sequence:         GroupSequence(gm.GroupSequence),
+		subgroup:         SubgroupID(gm.SubgroupID),
+		timestamp:        timeFromWire(gm.Timestamp),
+		extensionHeaders: gm.Flags&message.GroupFlagExtensionHeaders != 0,
+		stream:           stream,
+	}
+}
+
 // TrackReader receives groups for a subscribed track.
 // It queues incoming group streams and allows the application to accept them via AcceptGroup.
 // TrackReader provides lifecycle and update APIs for managing subscriptions.
+//
+// All methods are safe for concurrent use. Concurrent AcceptGroup calls each
+// receive a distinct group; Close and CloseWithError unblock them.
 type TrackReader struct {
 	// BroadcastPath is the path of the broadcast this subscription targets.
 	// The value is set at subscription time and does not change.
@@ -70,25 +114,105 @@ type TrackReader struct {
 
 	sendSubscribeStream *sendSubscribeStream
 
-	queueing []struct {
-		sequence GroupSequence
-		stream   transport.ReceiveStream
-	}
+	queueing []queuedGroup
 	queuedCh chan struct{}
 	trackMu  sync.Mutex
 
+	// latestGroup is the highest group sequence received, guarded by trackMu.
+	latestGroup GroupSequence
+
+	// rangeGroups is the number of groups received within the StartGroup and
+	// EndGroup of the subscription, guarded by trackMu.
+	rangeGroups uint64
+
+	// maxQueued bounds len(queueing); zero means no limit.
+	// It is set by Session.Subscribe from Config.MaxQueuedGroups.
+	maxQueued int
+
+	// errorCodes maps application error codes onto wire codes. It is set
+	// by Session.Subscribe from Config.ErrorCodes.
+	errorCodes *ErrorCodeMap
+
 	dequeued map[*GroupReader]struct{}
 
 	groupManager *groupReaderManager
 	onCloseFunc  func()
 
+	// checksum is the checksum configuration of groups accepted from now on.
+	checksumMode   ChecksumMode
+	checksumPolicy ChecksumPolicy
+
+	// schemaLookup checks the schema IDs of groups accepted from now on.
+	schemaLookup SchemaLookupFunc
+
+	// extensionHeaders is the extension headers setting announced by the
+	// header of the latest group received, which repaired groups, having
+	// no header, take.
+	extensionHeaders bool
+
+	// interceptor runs on the frames of groups accepted from now on.
+	interceptor FrameInterceptor
+
+	// sessionInterceptor is set by Session.Subscribe from
+	// Config.ReadFrameInterceptor.
+	sessionInterceptor FrameInterceptor
+
+	// retransmit, when set, fetches again the lost groups of a reliable
+	// track. retransmitCancel stops it.
+	retransmit       *retransmitter
+	retransmitCancel context.CancelFunc
+
+	// fetchFunc is set by Session.Subscribe to fetch groups of the track.
+	fetchFunc func(*FetchRequest) (*GroupReader, error)
+
+	// metrics is set by Session.Subscribe before the reader is returned.
+	metrics ClientMetrics
+
+	// drops counts groups and frames of this subscription that were not delivered.
+	drops dropRecorder
+
+	// delivered counts the groups, frames and bytes of this subscription
+	// that were read.
+	delivered deliveryCounter
+
+	// reportStatsFunc is set by Session.Subscribe to send stats upstream.
+	reportStatsFunc func(TrackStats) error
+
+	// tasks is set by Session.Subscribe and owns the goroutines of the
+	// reader and of its BufferedTrackReader; see goTask.
+	tasks *taskGroup
+
+	// qlog is set by Session.Subscribe before the reader is returned.
+	qlog *qlogWriter
+
+	// tracer and traceCtx, the context of the subscribe span, are set by
+	// Session.Subscribe before the reader is returned.
+	tracer   Tracer
+	traceCtx context.Context
+
 	ctx context.Context
 }
 
+// SubscribeID returns the identifier of the subscription.
 func (r *TrackReader) SubscribeID() SubscribeID {
 	return r.sendSubscribeStream.SubscribeID()
 }
 
+// ReportStats sends the audience of this subscription to the publisher,
+// which exposes it through TrackWriter.Audience. Relays call it periodically,
+// usually through TrackAudience.Report. Reporting is optional; publishers
+// that do not support it ignore the report. It returns an error on sessions
+// without extensions, whose peers do not know the report; see
+// Session.Extensions.
+func (r *TrackReader) ReportStats(stats TrackStats) error {
+	if r.reportStatsFunc == nil {
+		return errors.New("moqt: stats reporting is not available for this track")
+	}
+	return r.reportStatsFunc(stats)
+}
+
+// TrackConfig returns the current subscription configuration.
+// The returned value must not be modified; use Update to change it.
 func (r *TrackReader) TrackConfig() *SubscribeConfig {
 	return r.sendSubscribeStream.TrackConfig()
 }
@@ -139,7 +263,24 @@ func (r *TrackReader) Drops(ctx context.Context) iter.Seq[SubscribeDrop] {
 
 // AcceptGroup blocks until the next group is available or context is
 // canceled. It returns a GroupReader tied to the accepted group stream.
+// Each subgroup of a group is accepted as a GroupReader of its own, with the
+// group's sequence and its SubgroupID.
+//
+// Once the publisher has ended a subscription with an EndGroup, after
+// sending that group, AcceptGroup returns io.EOF when no group is queued and
+// every group from StartGroup to EndGroup has been received. Otherwise, as
+// groups may arrive after the end, it waits for them up to a second.
 func (r *TrackReader) AcceptGroup(ctx context.Context) (*GroupReader, error) {
+	return r.acceptGroup(ctx, nil)
+}
+
+// errAcceptInterrupted is returned by acceptGroup when its interrupt channel
+// is closed.
+var errAcceptInterrupted = errors.New("moqt: group accept interrupted")
+
+// acceptGroup is AcceptGroup that also stops waiting, with
+// errAcceptInterrupted, when interrupt is closed.
+func (r *TrackReader) acceptGroup(ctx context.Context, interrupt <-chan struct{}) (*GroupReader, error) {
 	trackCtx := r.Context()
 
 	for {
@@ -150,32 +291,168 @@ func (r *TrackReader) AcceptGroup(ctx context.Context) (*GroupReader, error) {
 			r.queueing = r.queueing[1:]
 
 			group := newGroupReader(next.sequence, next.stream, r.groupManager)
+			group.subgroup = next.subgroup
+			group.timestamp = next.timestamp
+			group.drops = &r.drops
+			group.qlog = r.qlog
+			group.subscribeID = r.sendSubscribeStream.id
+			group.errorCodes = r.errorCodes
+			if r.checksumMode != ChecksumNone {
+				group.checksum = &groupChecksum{mode: r.checksumMode, policy: r.checksumPolicy}
+			}
+			group.schemaLookup = r.schemaLookup
+			group.extensionHeaders = next.extensionHeaders
+			group.headerAnnounced = true
+			group.interceptor = ChainFrameInterceptors(r.sessionInterceptor, r.interceptor)
+			if rt := r.retransmit; rt != nil && next.subgroup == 0 {
+				seq := next.sequence
+				group.onResetFunc = func() { rt.repair(seq) }
+			}
+			r.delivered.groups.Add(1)
+			group.onFrameFunc = func(size int) {
+				r.delivered.frames.Add(1)
+				r.delivered.bytes.Add(uint64(size))
+				if r.metrics != nil {
+					r.metrics.FrameReceived(r.BroadcastPath, r.TrackName, size)
+				}
+			}
+			if r.tracer != nil {
+				group.endSpanFunc = endSpanOnce(r.tracer.StartGroup(r.traceCtx, r.BroadcastPath, r.TrackName, next.sequence, true))
+			}
 
 			r.trackMu.Unlock()
 			return group, nil
 		}
+		// Capture the channel under the lock; Close clears it concurrently.
+		queuedCh := r.queuedCh
+		rangeGroups := r.rangeGroups
 		r.trackMu.Unlock()
 
 		if trackCtx.Err() != nil {
 			return nil, Cause(trackCtx)
 		}
 
+		if queuedCh == nil {
+			return nil, ErrClosedTrack
+		}
+
+		// Only bounded subscriptions end with the publisher closing its side.
+		var finished <-chan struct{}
+		var linger <-chan time.Time
+		if config := r.TrackConfig(); config.EndGroup != MinGroupSequence {
+			select {
+			case <-r.sendSubscribeStream.finished:
+				// Groups sent before the end may still be on their way,
+				// unless every group of an absolute range was received.
+				wait := rangeEndLinger - time.Since(r.sendSubscribeStream.finishedAt)
+				complete := config.StartGroup != MinGroupSequence &&
+					rangeGroups >= uint64(config.EndGroup-config.StartGroup)+1
+				if complete || wait <= 0 {
+					return nil, io.EOF
+				}
+				linger = time.After(wait)
+			default:
+				finished = r.sendSubscribeStream.finished
+			}
+		}
+
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		case <-trackCtx.Done():
 			return nil, Cause(trackCtx)
-		case <-r.queuedCh:
+		case <-interrupt:
+			return nil, errAcceptInterrupted
+		case <-queuedCh:
+		case <-finished:
+		case <-linger:
 		}
 	}
 }
 
+// SetChecksum makes groups accepted after the call verify a checksum of the
+// given mode on every frame, handling mismatches according to policy.
+// Frames without a checksum of the mode fail verification, so the publisher
+// must write the track with the same mode; see ChecksumMode.
+func (r *TrackReader) SetChecksum(mode ChecksumMode, policy ChecksumPolicy) {
+	r.trackMu.Lock()
+	defer r.trackMu.Unlock()
+
+	r.checksumMode = mode
+	r.checksumPolicy = policy
+}
+
+// SetSchemaLookup makes groups accepted after the call read a schema ID with
+// every frame and check it with lookup, so that frames of a payload format
+// the subscriber cannot decode return a SchemaError instead of being
+// misread. The publisher must write the track with TrackWriter.SetSchema. A
+// nil lookup reads frames without schema IDs, which is the default.
+func (r *TrackReader) SetSchemaLookup(lookup SchemaLookupFunc) {
+	r.trackMu.Lock()
+	defer r.trackMu.Unlock()
+
+	r.schemaLookup = lookup
+}
+
+// SetFrameInterceptor makes the frames of groups accepted after the call
+// pass intercept after the interceptor of the session, if any; see
+// FrameInterceptor. Nil removes it.
+func (r *TrackReader) SetFrameInterceptor(intercept FrameInterceptor) {
+	r.trackMu.Lock()
+	defer r.trackMu.Unlock()
+
+	r.interceptor = intercept
+}
+
+// DropStats returns the number of groups and frames of this subscription
+// that were not delivered, by reason. It counts groups reset by the
+// publisher, ranges announced with SUBSCRIBE_DROP and frames or groups
+// discarded by the ChecksumPolicy.
+func (r *TrackReader) DropStats() DropStats {
+	return r.drops.stats()
+}
+
+// DeliveryStats returns the counters of the data delivered on this
+// subscription so far. Sampled periodically, they give the throughput of the
+// track and the occupancy of its queue, as adaptive bitrate controllers use.
+func (r *TrackReader) DeliveryStats() DeliveryStats {
+	r.trackMu.Lock()
+	queued := len(r.queueing)
+	latest := r.latestGroup
+	r.trackMu.Unlock()
+
+	return DeliveryStats{
+		Groups:       r.delivered.groups.Load(),
+		Frames:       r.delivered.frames.Load(),
+		Bytes:        r.delivered.bytes.Load(),
+		QueuedGroups: queued,
+		LatestGroup:  latest,
+	}
+}
+
+// SetDropTrace installs f to be called synchronously for every drop recorded
+// on this subscription; nil removes it. f must return quickly.
+func (r *TrackReader) SetDropTrace(f func(DropEvent)) {
+	r.drops.setTrace(f)
+}
+
+// ReportRebuffer reports a playback stall of duration on this track to the
+// Dialer's ClientMetrics. It does nothing if no metrics sink is configured.
+func (r *TrackReader) ReportRebuffer(duration time.Duration) {
+	if r.metrics != nil {
+		r.metrics.Rebuffer(r.BroadcastPath, r.TrackName, duration)
+	}
+}
+
+// Context returns the context of the subscription. It is canceled when the
+// subscription ends.
 func (r *TrackReader) Context() context.Context {
 	return r.ctx
 }
 
 // Close cancels queued groups, closes the queued channel, and terminates
 // the subscription stream gracefully.
+// It is safe to call concurrently with other methods and more than once.
 func (r *TrackReader) Close() error {
 	r.trackMu.Lock()
 	defer r.trackMu.Unlock()
@@ -193,6 +470,11 @@ func (r *TrackReader) Close() error {
 	}
 	r.dequeued = nil
 
+	// Cancel groups handed out by AcceptGroup that are still being read
+	for _, group := range r.groupManager.close() {
+		group.CancelRead(SubscribeCanceledErrorCode)
+	}
+
 	if r.queuedCh != nil {
 		close(r.queuedCh)
 		r.queuedCh = nil
@@ -203,7 +485,20 @@ func (r *TrackReader) Close() error {
 	return r.sendSubscribeStream.close()
 }
 
+// CloseWithApplicationError cancels the subscription with an
+// application-defined error code, mapped onto a wire code by
+// Config.ErrorCodes. It fails only if the code cannot be mapped.
+func (r *TrackReader) CloseWithApplicationError(code uint32) error {
+	wire, err := r.errorCodes.WireCode(SubscribeErrorSpace, code)
+	if err != nil {
+		return err
+	}
+	r.CloseWithError(SubscribeErrorCode(wire))
+	return nil
+}
+
 // CloseWithError cancels the subscription with the provided SubscribeErrorCode and terminates the subscription.
+// It is safe to call concurrently with other methods and more than once.
 func (r *TrackReader) CloseWithError(code SubscribeErrorCode) {
 	r.trackMu.Lock()
 	defer r.trackMu.Unlock()
@@ -221,6 +516,11 @@ func (r *TrackReader) CloseWithError(code SubscribeErrorCode) {
 	}
 	r.dequeued = nil
 
+	// Cancel groups handed out by AcceptGroup that are still being read
+	for _, group := range r.groupManager.close() {
+		group.CancelRead(SubscribeCanceledErrorCode)
+	}
+
 	if r.queuedCh != nil {
 		close(r.queuedCh)
 		r.queuedCh = nil
@@ -232,6 +532,7 @@ func (r *TrackReader) CloseWithError(code SubscribeErrorCode) {
 }
 
 // Update updates the subscription configuration with a new TrackConfig.
+// Concurrent updates are serialized; the last one to be sent wins.
 func (r *TrackReader) Update(config *SubscribeConfig) error {
 	if config == nil {
 		return errors.New("subscribe config cannot be nil")
@@ -240,10 +541,58 @@ func (r *TrackReader) Update(config *SubscribeConfig) error {
 	return r.sendSubscribeStream.updateSubscribe(config)
 }
 
+// UpdatePriority changes the priority of the subscription, keeping the rest
+// of its configuration. The publisher applies it to the groups it sends
+// afterwards.
+func (r *TrackReader) UpdatePriority(priority TrackPriority) error {
+	return r.sendSubscribeStream.modifySubscribe(func(current *SubscribeConfig) *SubscribeConfig {
+		config := *current
+		config.Priority = priority
+		return &config
+	})
+}
+
+// UpdateRange changes the group range of the subscription, keeping the rest
+// of its configuration. As in SubscribeConfig, a zero start is the latest
+// group and a zero end is unbounded. Whether groups outside the new range
+// stop being sent is up to the publisher; see TrackWriter.OnUpdate.
+func (r *TrackReader) UpdateRange(start, end GroupSequence) error {
+	if end != MinGroupSequence && start > end {
+		return fmt.Errorf("moqt: invalid subscribe range %s-%s", start, end)
+	}
+	return r.sendSubscribeStream.modifySubscribe(func(current *SubscribeConfig) *SubscribeConfig {
+		config := *current
+		config.StartGroup = start
+		config.EndGroup = end
+		return &config
+	})
+}
+
+// UpdateVisibility tells the publisher whether the track is presented,
+// keeping the rest of the configuration of the subscription. A publisher or
+// relay may pause or downgrade the delivery of a subscription that is not
+// visible, and resumes it, from its latest group, once it is visible again.
+// The hint is not sent on sessions without extensions; see
+// Session.Extensions.
+func (r *TrackReader) UpdateVisibility(visibility Visibility) error {
+	return r.sendSubscribeStream.modifySubscribe(func(current *SubscribeConfig) *SubscribeConfig {
+		config := *current
+		config.Visibility = visibility
+		return &config
+	})
+}
+
 func (r *TrackReader) enqueueGroup(sequence GroupSequence, stream transport.ReceiveStream) {
+	r.enqueue(queuedGroup{sequence: sequence, stream: stream})
+}
+
+// enqueue queues the stream of a group or subgroup.
+func (r *TrackReader) enqueue(g queuedGroup) {
+	sequence, subgroup, stream := g.sequence, g.subgroup, g.stream
 	if stream == nil {
 		return
 	}
+	config := r.TrackConfig()
 
 	r.trackMu.Lock()
 	defer r.trackMu.Unlock()
@@ -253,14 +602,25 @@ func (r *TrackReader) enqueueGroup(sequence GroupSequence, stream transport.Rece
 		return
 	}
 
-	entry := struct {
-		sequence GroupSequence
-		stream   transport.ReceiveStream
-	}{
-		sequence: sequence,
-		stream:   stream,
+	r.queueing = append(r.queueing, g)
+	r.extensionHeaders = g.extensionHeaders
+	r.latestGroup = max(r.latestGroup, sequence)
+	if subgroup == 0 && config.EndGroup != MinGroupSequence &&
+		sequence >= config.StartGroup && sequence <= config.EndGroup {
+		r.rangeGroups++
+	}
+	if r.retransmit != nil && subgroup == 0 {
+		r.retransmit.received(sequence)
+	}
+
+	// Drop the oldest groups of a track that is not keeping up, so that a
+	// busy track holds a bounded number of streams.
+	if r.maxQueued > 0 && len(r.queueing) > r.maxQueued {
+		oldest := r.queueing[0]
+		r.queueing = r.queueing[1:]
+		oldest.stream.CancelRead(transport.StreamErrorCode(ExpiredGroupErrorCode))
+		r.drops.recordGroup(DropReasonStale, oldest.sequence)
 	}
-	r.queueing = append(r.queueing, entry)
 
 	select {
 	case r.queuedCh <- struct{}{}:
@@ -3,10 +3,14 @@ package moqt
 import (
 	"bytes"
 	"context"
+	"io"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -268,3 +272,257 @@ func TestTrackReader_SubscribeID(t *testing.T) {
 
 	assert.Equal(t, SubscribeID(42), receiver.SubscribeID())
 }
+
+func TestTrackReader_Close_CancelsAcceptedGroups(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+
+	var canceled atomic.Bool
+	recvStream := &FakeQUICReceiveStream{
+		CancelReadFunc: func(transport.StreamErrorCode) { canceled.Store(true) },
+	}
+	receiver.enqueueGroup(GroupSequence(1), recvStream)
+
+	group, err := receiver.AcceptGroup(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, group)
+
+	require.NoError(t, receiver.Close())
+	assert.True(t, canceled.Load(), "Close should cancel groups returned by AcceptGroup")
+}
+
+func TestTrackReader_ConcurrentAcceptGroupAndClose(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+
+	const groups = 32
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+
+	for range 4 {
+		wg.Go(func() {
+			for {
+				_, err := receiver.AcceptGroup(context.Background())
+				if err != nil {
+					return
+				}
+				accepted.Add(1)
+			}
+		})
+	}
+
+	for i := range groups {
+		wg.Go(func() {
+			receiver.enqueueGroup(GroupSequence(i), &FakeQUICReceiveStream{})
+		})
+	}
+
+	assert.Eventually(t, func() bool {
+		return accepted.Load() == groups
+	}, time.Second, time.Millisecond)
+
+	// Close unblocks all waiting AcceptGroup calls.
+	closed := make(chan struct{})
+	go func() {
+		_ = receiver.Close()
+		close(closed)
+	}()
+	go receiver.CloseWithError(SubscribeErrorCodeInternal)
+
+	<-closed
+	wg.Wait()
+}
+
+func TestTrackReader_EnqueueGroup_MaxQueued(t *testing.T) {
+	tests := map[string]struct {
+		maxQueued    int
+		enqueued     int
+		wantQueued   []GroupSequence
+		wantCanceled int
+	}{
+		"no limit": {
+			enqueued:   4,
+			wantQueued: []GroupSequence{1, 2, 3, 4},
+		},
+		"under limit": {
+			maxQueued:  4,
+			enqueued:   3,
+			wantQueued: []GroupSequence{1, 2, 3},
+		},
+		"over limit drops oldest": {
+			maxQueued:    2,
+			enqueued:     5,
+			wantQueued:   []GroupSequence{4, 5},
+			wantCanceled: 3,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			receiver, _ := newTestTrackReader(t)
+			receiver.maxQueued = tt.maxQueued
+
+			canceled := 0
+			for i := 1; i <= tt.enqueued; i++ {
+				stream := &FakeQUICReceiveStream{
+					CancelReadFunc: func(code transport.StreamErrorCode) {
+						assert.Equal(t, transport.StreamErrorCode(ExpiredGroupErrorCode), code)
+						canceled++
+					},
+				}
+				receiver.enqueueGroup(GroupSequence(i), stream)
+			}
+
+			var queued []GroupSequence
+			for _, entry := range receiver.queueing {
+				queued = append(queued, entry.sequence)
+			}
+			assert.Equal(t, tt.wantQueued, queued)
+			assert.Equal(t, tt.wantCanceled, canceled)
+
+			if tt.wantCanceled > 0 {
+				assert.Equal(t, uint64(tt.wantCanceled), receiver.DropStats().Groups[DropReasonStale])
+			} else {
+				assert.Empty(t, receiver.DropStats().Groups)
+			}
+		})
+	}
+}
+
+func TestTrackReader_AcceptGroup_RangeEnd(t *testing.T) {
+	tests := map[string]struct {
+		config   SubscribeConfig
+		enqueued []GroupSequence
+		wantErr  error
+	}{
+		"complete range": {
+			config:   SubscribeConfig{StartGroup: 2, EndGroup: 3},
+			enqueued: []GroupSequence{3, 2},
+			wantErr:  io.EOF,
+		},
+		"group missing": {
+			config:   SubscribeConfig{StartGroup: 2, EndGroup: 3},
+			enqueued: []GroupSequence{3},
+			wantErr:  context.DeadlineExceeded,
+		},
+		"relative start": {
+			config:   SubscribeConfig{EndGroup: 3},
+			enqueued: []GroupSequence{3},
+			wantErr:  context.DeadlineExceeded,
+		},
+		"unbounded": {
+			config:   SubscribeConfig{},
+			enqueued: []GroupSequence{1},
+			wantErr:  context.DeadlineExceeded,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The stream of the fake reads io.EOF: the publisher ends the
+			// subscription.
+			substr := newTestSendSubscribeStreamFromStream(&FakeQUICStream{}, &tt.config)
+			receiver := newTrackReader("/test", "video", substr, func() {})
+			for _, seq := range tt.enqueued {
+				receiver.enqueueGroup(seq, &FakeQUICReceiveStream{})
+			}
+
+			ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+			defer cancel()
+			for range tt.enqueued {
+				_, err := receiver.AcceptGroup(ctx)
+				require.NoError(t, err)
+			}
+			_, err := receiver.AcceptGroup(ctx)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestTrackReader_UpdatePartial(t *testing.T) {
+	initial := SubscribeConfig{Priority: 1, Ordered: true, MaxLatency: 500, StartGroup: 3, EndGroup: 9}
+
+	tests := map[string]struct {
+		update  func(r *TrackReader) error
+		want    SubscribeConfig
+		wantErr bool
+	}{
+		"priority": {
+			update: func(r *TrackReader) error { return r.UpdatePriority(7) },
+			want:   SubscribeConfig{Priority: 7, Ordered: true, MaxLatency: 500, StartGroup: 3, EndGroup: 9},
+		},
+		"range": {
+			update: func(r *TrackReader) error { return r.UpdateRange(10, 20) },
+			want:   SubscribeConfig{Priority: 1, Ordered: true, MaxLatency: 500, StartGroup: 10, EndGroup: 20},
+		},
+		"unbounded range": {
+			update: func(r *TrackReader) error { return r.UpdateRange(10, MinGroupSequence) },
+			want:   SubscribeConfig{Priority: 1, Ordered: true, MaxLatency: 500, StartGroup: 10},
+		},
+		"inverted range": {
+			update:  func(r *TrackReader) error { return r.UpdateRange(20, 10) },
+			want:    initial,
+			wantErr: true,
+		},
+		"visibility": {
+			update: func(r *TrackReader) error { return r.UpdateVisibility(VisibilityHidden) },
+			want:   SubscribeConfig{Priority: 1, Ordered: true, MaxLatency: 500, StartGroup: 3, EndGroup: 9, Visibility: VisibilityHidden},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var written bytes.Buffer
+			mockStream := &FakeQUICStream{WriteFunc: written.Write}
+			config := initial
+			substr := newSendSubscribeStream(SubscribeID(1), mockStream, &config)
+			substr.version = message.VersionLite04Ext
+			receiver := newTrackReader("/test", "video", substr, func() {})
+
+			err := tt.update(receiver)
+			assert.Equal(t, tt.want, *receiver.TrackConfig())
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Zero(t, written.Len(), "an invalid update must not be sent")
+				return
+			}
+			require.NoError(t, err)
+
+			msg := message.SubscribeUpdateMessage{Version: message.VersionLite04Ext}
+			require.NoError(t, msg.Decode(&written))
+			assert.Equal(t, uint8(tt.want.Priority), msg.SubscriberPriority)
+			assert.Equal(t, groupSequenceToWire(tt.want.StartGroup), msg.StartGroup)
+			assert.Equal(t, groupSequenceToWire(tt.want.EndGroup), msg.EndGroup)
+			assert.Equal(t, tt.want.MaxLatency, msg.SubscriberMaxLatency)
+			assert.Equal(t, uint64(tt.want.Visibility), msg.Visibility)
+		})
+	}
+}
+
+func TestTrackReader_DeliveryStats(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+
+	var group bytes.Buffer
+	gw := newGroupWriter(&FakeQUICSendStream{WriteFunc: group.Write}, 0, nil)
+	for _, payload := range []string{"abc", "defgh"} {
+		frame := NewFrame(0)
+		_, _ = frame.Write([]byte(payload))
+		require.NoError(t, gw.WriteFrame(frame))
+	}
+
+	receiver.enqueueGroup(3, &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(group.Bytes()).Read})
+	receiver.enqueueGroup(5, &FakeQUICReceiveStream{})
+	assert.Equal(t, DeliveryStats{QueuedGroups: 2, LatestGroup: 5}, receiver.DeliveryStats())
+
+	gr, err := receiver.AcceptGroup(t.Context())
+	require.NoError(t, err)
+	frame := NewFrame(0)
+	for gr.ReadFrame(frame) == nil {
+	}
+
+	assert.Equal(t, DeliveryStats{
+		Groups:       1,
+		Frames:       2,
+		Bytes:        8,
+		QueuedGroups: 1,
+		LatestGroup:  5,
+	}, receiver.DeliveryStats())
+}
@@ -0,0 +1,109 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TrackStats is the audience of a track as seen by one subscriber.
+// A relay reports the aggregate of its own downstream subscriptions, so the
+// publisher learns the total number of viewers behind each subscription.
+type TrackStats struct {
+	// Subscribers is the number of end subscribers receiving the track.
+	Subscribers uint64
+
+	// DroppedGroups and DroppedFrames count the groups and frames that were
+	// not delivered to those subscribers.
+	DroppedGroups uint64
+	DroppedFrames uint64
+}
+
+// errStatsUnsupported is returned when stats are reported on a session whose
+// version has no stats stream.
+var errStatsUnsupported = errors.New("moqt: stats reporting requires VersionLite04Ext")
+
+// TrackAudience aggregates the stats of the TrackWriters serving one track.
+// A writer counts as one subscriber unless its subscriber reports stats, in
+// which case the reported values are used, so counts add up across relays.
+//
+// Publishers use Stats to display viewer counts. Relays use Report to pass
+// the aggregate to their upstream subscription.
+//
+// The zero value is ready to use. All methods are safe for concurrent use.
+type TrackAudience struct {
+	mu      sync.Mutex
+	writers map[*TrackWriter]struct{}
+}
+
+// Add includes w in the aggregate and returns a function that removes it.
+// Call the function when the writer is closed.
+func (a *TrackAudience) Add(w *TrackWriter) (remove func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.writers == nil {
+		a.writers = make(map[*TrackWriter]struct{})
+	}
+	a.writers[w] = struct{}{}
+
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.writers, w)
+	}
+}
+
+// Stats returns the aggregate stats of the added writers.
+func (a *TrackAudience) Stats() TrackStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var total TrackStats
+	for w := range a.writers {
+		if reported, ok := w.Audience(); ok {
+			total.Subscribers += reported.Subscribers
+			total.DroppedGroups += reported.DroppedGroups
+			total.DroppedFrames += reported.DroppedFrames
+		} else {
+			total.Subscribers++
+		}
+
+		drops := w.DropStats()
+		for _, n := range drops.Groups {
+			total.DroppedGroups += n
+		}
+		for _, n := range drops.Frames {
+			total.DroppedFrames += n
+		}
+	}
+	return total
+}
+
+// Report sends Stats to upstream every interval until ctx is canceled or
+// the subscription ends. A report is only sent when the stats changed since
+// the previous one. It returns the first error from TrackReader.ReportStats.
+func (a *TrackAudience) Report(ctx context.Context, upstream *TrackReader, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last TrackStats
+	sent := false
+	for {
+		if stats := a.Stats(); !sent || stats != last {
+			if err := upstream.ReportStats(stats); err != nil {
+				return err
+			}
+			last, sent = stats, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-upstream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package moqt
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStatsTrackWriter(tb testing.TB) *TrackWriter {
+	tb.Helper()
+	substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}, func() {})
+	tb.Cleanup(func() { _ = writer.Close() })
+	return writer
+}
+
+func TestTrackAudience_Stats(t *testing.T) {
+	tests := map[string]struct {
+		reported []*TrackStats
+		drops    []DropEvent
+		want     TrackStats
+	}{
+		"no writers": {},
+		"unreported writers count once": {
+			reported: []*TrackStats{nil, nil, nil},
+			want:     TrackStats{Subscribers: 3},
+		},
+		"reported stats are summed": {
+			reported: []*TrackStats{
+				{Subscribers: 10, DroppedGroups: 1},
+				{Subscribers: 5, DroppedFrames: 2},
+				nil,
+			},
+			want: TrackStats{Subscribers: 16, DroppedGroups: 1, DroppedFrames: 2},
+		},
+		"reported zero subscribers": {
+			reported: []*TrackStats{{}},
+			want:     TrackStats{},
+		},
+		"local drops are included": {
+			reported: []*TrackStats{nil},
+			drops: []DropEvent{
+				{Reason: DropReasonStale, StartGroup: 1, EndGroup: 3},
+				{Reason: DropReasonOverBudget, StartGroup: 4, EndGroup: 4, Frame: true},
+			},
+			want: TrackStats{Subscribers: 1, DroppedGroups: 3, DroppedFrames: 1},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var audience TrackAudience
+			for i, stats := range tt.reported {
+				w := newTestStatsTrackWriter(t)
+				if stats != nil {
+					w.setAudience(*stats)
+				}
+				if i == 0 {
+					for _, ev := range tt.drops {
+						w.RecordDrop(ev)
+					}
+				}
+				audience.Add(w)
+			}
+
+			assert.Equal(t, tt.want, audience.Stats())
+		})
+	}
+}
+
+func TestTrackAudience_Remove(t *testing.T) {
+	var audience TrackAudience
+	remove := audience.Add(newTestStatsTrackWriter(t))
+	audience.Add(newTestStatsTrackWriter(t))
+	assert.Equal(t, uint64(2), audience.Stats().Subscribers)
+
+	remove()
+	assert.Equal(t, uint64(1), audience.Stats().Subscribers)
+
+	remove()
+	assert.Equal(t, uint64(1), audience.Stats().Subscribers, "removing twice should be a no-op")
+}
+
+func TestTrackAudience_Report(t *testing.T) {
+	reader, _ := newTestTrackReader(t)
+
+	var mu sync.Mutex
+	var reports []TrackStats
+	reader.reportStatsFunc = func(stats TrackStats) error {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, stats)
+		return nil
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reports)
+	}
+
+	var audience TrackAudience
+	audience.Add(newTestStatsTrackWriter(t))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- audience.Report(ctx, reader, 5*time.Millisecond) }()
+
+	require.Eventually(t, func() bool { return count() == 1 }, time.Second, time.Millisecond)
+
+	// Unchanged stats are not sent again.
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 1, count())
+
+	audience.Add(newTestStatsTrackWriter(t))
+	require.Eventually(t, func() bool { return count() == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Report should return when ctx is canceled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []TrackStats{{Subscribers: 1}, {Subscribers: 2}}, reports)
+}
+
+func TestTrackAudience_Report_Error(t *testing.T) {
+	reader, _ := newTestTrackReader(t)
+
+	var audience TrackAudience
+	err := audience.Report(t.Context(), reader, time.Millisecond)
+	assert.Error(t, err, "Report should fail when the reader cannot report stats")
+}
+
+func TestTrackWriter_Audience(t *testing.T) {
+	w := newTestStatsTrackWriter(t)
+
+	_, ok := w.Audience()
+	assert.False(t, ok)
+
+	w.setAudience(TrackStats{Subscribers: 1})
+	w.setAudience(TrackStats{Subscribers: 7})
+
+	select {
+	case <-w.AudienceUpdated():
+	default:
+		t.Fatal("AudienceUpdated should be signaled")
+	}
+
+	stats, ok := w.Audience()
+	assert.True(t, ok)
+	assert.Equal(t, TrackStats{Subscribers: 7}, stats, "Audience should return the latest report")
+}
@@ -0,0 +1,229 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+)
+
+// TrackStatusCode tells whether a track exists and whether it is live.
+type TrackStatusCode uint8
+
+const (
+	// TrackStatusUnknown means the track's broadcast is served, but its
+	// handler does not report the status of its tracks.
+	TrackStatusUnknown TrackStatusCode = iota
+	// TrackStatusNotFound means no handler serves the track.
+	TrackStatusNotFound
+	// TrackStatusNotStarted means the track exists but no group has been
+	// published yet.
+	TrackStatusNotStarted
+	// TrackStatusLive means groups of the track are being published.
+	TrackStatusLive
+	// TrackStatusEnded means the track has ended; no more groups will be
+	// published.
+	TrackStatusEnded
+)
+
+func (code TrackStatusCode) String() string {
+	switch code {
+	case TrackStatusUnknown:
+		return "unknown"
+	case TrackStatusNotFound:
+		return "not found"
+	case TrackStatusNotStarted:
+		return "not started"
+	case TrackStatusLive:
+		return "live"
+	case TrackStatusEnded:
+		return "ended"
+	default:
+		return fmt.Sprintf("TrackStatusCode(%d)", uint8(code))
+	}
+}
+
+// TrackStatus is the status of a track, as returned by Session.TrackStatus.
+type TrackStatus struct {
+	Code TrackStatusCode
+
+	// LatestGroup and LatestFrame are the sequence of the latest group of
+	// the track and the index of the latest frame of that group. They are
+	// only meaningful when Code is TrackStatusLive or TrackStatusEnded.
+	LatestGroup GroupSequence
+	LatestFrame uint64
+}
+
+func (ts TrackStatus) String() string {
+	return fmt.Sprintf("{ code: %s, latest_group: %d, latest_frame: %d }", ts.Code, ts.LatestGroup, ts.LatestFrame)
+}
+
+// TrackStatusRequest is a request for the status of a track, received from
+// the peer of a session.
+type TrackStatusRequest struct {
+	BroadcastPath BroadcastPath
+	TrackName     TrackName
+
+	pathValues map[string]string
+	ctx        context.Context
+}
+
+// Context returns the request's context, which is done when the requesting
+// stream ends. It is always non-nil.
+func (r *TrackStatusRequest) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// PathValue returns the value of the named wildcard in the pattern that
+// routed this request to its handler (see TrackMux.Route), or "".
+func (r *TrackStatusRequest) PathValue(name string) string {
+	return r.pathValues[name]
+}
+
+// TrackStatusHandler answers track status requests from application state.
+//
+// A TrackHandler published on a TrackMux, or routed by a pattern, may also
+// implement TrackStatusHandler; track status requests for its broadcast are
+// then answered by ServeTrackStatus. Requests for broadcasts whose handler
+// does not implement it are answered with TrackStatusUnknown, and requests
+// for broadcasts that are not served with TrackStatusNotFound.
+type TrackStatusHandler interface {
+	ServeTrackStatus(r *TrackStatusRequest) TrackStatus
+}
+
+// TrackStatusHandlerFunc is an adapter to allow ordinary functions to act as
+// a TrackStatusHandler.
+type TrackStatusHandlerFunc func(r *TrackStatusRequest) TrackStatus
+
+func (f TrackStatusHandlerFunc) ServeTrackStatus(r *TrackStatusRequest) TrackStatus {
+	return f(r)
+}
+
+// errTrackStatusUnsupported is returned when the status of a track is
+// requested on a session whose version has no TRACK_STATUS.
+var errTrackStatusUnsupported = errors.New("moqt: track status requires VersionLite04Ext")
+
+// TrackStatus asks the peer for the status of a track without subscribing to
+// it: whether it exists, has not started, is live or has ended, and which
+// are its latest group and frame. The request is authorized like a
+// subscription by the peer's Authorizer.
+//
+// A denied request returns a *SubscribeError with
+// SubscribeErrorCodeUnauthorized. It returns an error without asking if the
+// session did not negotiate the extensions; see Session.Extensions.
+func (s *Session) TrackStatus(ctx context.Context, path BroadcastPath, name TrackName) (TrackStatus, error) {
+	if s.terminating() {
+		return TrackStatus{}, ErrClosedSession
+	}
+	if !s.wireVersion.Extended() {
+		return TrackStatus{}, errTrackStatusUnsupported
+	}
+
+	stream, err := s.openStream()
+	if err != nil {
+		if appErr, ok := errors.AsType[*transport.ApplicationError](err); ok {
+			return TrackStatus{}, &SessionError{ApplicationError: appErr}
+		}
+		return TrackStatus{}, fmt.Errorf("failed to open stream for track status: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		cancelStreamWithError(stream, transport.StreamErrorCode(SubscribeErrorCodeInternal))
+	})
+	defer stop()
+
+	err = message.StreamTypeTrackStatus.Encode(stream)
+	if err == nil {
+		err = message.TrackStatusRequestMessage{
+			BroadcastPath: string(path),
+			TrackName:     string(name),
+		}.Encode(stream)
+	}
+	if err == nil {
+		err = stream.Close()
+	}
+	var tsm message.TrackStatusMessage
+	if err == nil {
+		err = tsm.Decode(stream)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return TrackStatus{}, ctx.Err()
+		}
+		cancelStreamWithError(stream, transport.StreamErrorCode(SubscribeErrorCodeInternal))
+		if strErr, ok := errors.AsType[*transport.StreamError](err); ok && strErr.Remote {
+			return TrackStatus{}, &SubscribeError{StreamError: strErr}
+		}
+		return TrackStatus{}, fmt.Errorf("failed to request track status: %w", err)
+	}
+
+	return TrackStatus{
+		Code:        TrackStatusCode(tsm.StatusCode),
+		LatestGroup: GroupSequence(tsm.LatestGroup),
+		LatestFrame: tsm.LatestFrame,
+	}, nil
+}
+
+// handleTrackStatusStream answers a track status request from the TrackMux
+// of the session.
+func (sess *Session) handleTrackStatusStream(stream transport.Stream) error {
+	var req message.TrackStatusRequestMessage
+	if err := req.Decode(stream); err != nil {
+		return fmt.Errorf("failed to decode TRACK_STATUS_REQUEST message: %w", err)
+	}
+
+	err := sess.authorizeSubscribe(message.SubscribeMessage{
+		BroadcastPath: req.BroadcastPath,
+		TrackName:     req.TrackName,
+	})
+	if err != nil {
+		sess.logError("track status request denied", err, "broadcast_path", req.BroadcastPath, "track_name", req.TrackName)
+		cancelStreamWithError(stream, transport.StreamErrorCode(authSubscribeErrorCode(err)))
+		return nil
+	}
+
+	status, err := sess.mux.trackStatus(&TrackStatusRequest{
+		BroadcastPath: BroadcastPath(req.BroadcastPath),
+		TrackName:     TrackName(req.TrackName),
+		ctx:           stream.Context(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return message.TrackStatusMessage{
+		StatusCode:  uint64(status.Code),
+		LatestGroup: uint64(status.LatestGroup),
+		LatestFrame: status.LatestFrame,
+	}.Encode(stream)
+}
+
+// trackStatus answers r with the handler serving its broadcast path.
+func (mux *TrackMux) trackStatus(r *TrackStatusRequest) (status TrackStatus, err error) {
+	var handler TrackHandler
+	if ath := mux.findTrackHandler(r.BroadcastPath); ath != nil {
+		handler = ath.TrackHandler
+	} else if ph, values := mux.findPatternHandler(r.BroadcastPath); ph != nil {
+		handler = ph.TrackHandler
+		r.pathValues = values
+	} else {
+		return TrackStatus{Code: TrackStatusNotFound}, nil
+	}
+
+	sh, ok := handler.(TrackStatusHandler)
+	if !ok {
+		return TrackStatus{Code: TrackStatusUnknown}, nil
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic during track status handling: %v", p)
+		}
+	}()
+	return sh.ServeTrackStatus(r), nil
+}
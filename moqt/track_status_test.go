@@ -0,0 +1,251 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusTrackHandler is a TrackHandler that reports a fixed track status.
+type statusTrackHandler struct {
+	TrackHandler
+	status TrackStatus
+}
+
+func (h statusTrackHandler) ServeTrackStatus(r *TrackStatusRequest) TrackStatus {
+	if r.TrackName != "video" {
+		return TrackStatus{Code: TrackStatusNotFound}
+	}
+	return h.status
+}
+
+func TestTrackStatusCode_String(t *testing.T) {
+	tests := map[string]struct {
+		code TrackStatusCode
+		want string
+	}{
+		"unknown":     {code: TrackStatusUnknown, want: "unknown"},
+		"not found":   {code: TrackStatusNotFound, want: "not found"},
+		"not started": {code: TrackStatusNotStarted, want: "not started"},
+		"live":        {code: TrackStatusLive, want: "live"},
+		"ended":       {code: TrackStatusEnded, want: "ended"},
+		"undefined":   {code: TrackStatusCode(42), want: "TrackStatusCode(42)"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.code.String())
+		})
+	}
+}
+
+func TestTrackMux_TrackStatus(t *testing.T) {
+	mux := NewTrackMux(0)
+	ctx := t.Context()
+	live := TrackStatus{Code: TrackStatusLive, LatestGroup: 12, LatestFrame: 3}
+	mux.Publish(ctx, "/live", statusTrackHandler{TrackHandler: NotFoundTrackHandler, status: live})
+	mux.Publish(ctx, "/plain", NotFoundTrackHandler)
+	mux.Route(ctx, "/vod/{name}", statusTrackHandler{
+		TrackHandler: NotFoundTrackHandler,
+		status:       TrackStatus{Code: TrackStatusEnded, LatestGroup: 99},
+	})
+	mux.Route(ctx, "/panic", statusTrackHandler{TrackHandler: NotFoundTrackHandler, status: live})
+
+	tests := map[string]struct {
+		path    BroadcastPath
+		name    TrackName
+		want    TrackStatus
+		wantErr bool
+	}{
+		"published handler": {path: "/live", name: "video", want: live},
+		"unknown track":     {path: "/live", name: "audio", want: TrackStatus{Code: TrackStatusNotFound}},
+		"no status handler": {path: "/plain", name: "video", want: TrackStatus{Code: TrackStatusUnknown}},
+		"routed handler":    {path: "/vod/movie", name: "video", want: TrackStatus{Code: TrackStatusEnded, LatestGroup: 99}},
+		"not served":        {path: "/none", name: "video", want: TrackStatus{Code: TrackStatusNotFound}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := mux.trackStatus(&TrackStatusRequest{BroadcastPath: tt.path, TrackName: tt.name})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("path values", func(t *testing.T) {
+		var got string
+		mux.Route(ctx, "/clip/{id}", struct {
+			TrackHandler
+			TrackStatusHandlerFunc
+		}{NotFoundTrackHandler, func(r *TrackStatusRequest) TrackStatus {
+			got = r.PathValue("id")
+			return TrackStatus{}
+		}})
+		_, err := mux.trackStatus(&TrackStatusRequest{BroadcastPath: "/clip/7", TrackName: "video"})
+		require.NoError(t, err)
+		assert.Equal(t, "7", got)
+	})
+
+	t.Run("panicking handler", func(t *testing.T) {
+		mux.Route(ctx, "/broken", struct {
+			TrackHandler
+			TrackStatusHandlerFunc
+		}{NotFoundTrackHandler, func(*TrackStatusRequest) TrackStatus { panic("boom") }})
+		_, err := mux.trackStatus(&TrackStatusRequest{BroadcastPath: "/broken", TrackName: "video"})
+		assert.Error(t, err)
+	})
+}
+
+func TestSession_TrackStatus(t *testing.T) {
+	var response bytes.Buffer
+	require.NoError(t, message.TrackStatusMessage{StatusCode: uint64(TrackStatusLive), LatestGroup: 5, LatestFrame: 2}.Encode(&response))
+
+	var written bytes.Buffer
+	stream := &FakeQUICStream{ReadFunc: response.Read, WriteFunc: written.Write}
+	session, _ := newTestSessionWithConn(t, extendedConn, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) { return stream, nil }
+	})
+
+	status, err := session.TrackStatus(t.Context(), "/live", "video")
+	require.NoError(t, err)
+	assert.Equal(t, TrackStatus{Code: TrackStatusLive, LatestGroup: 5, LatestFrame: 2}, status)
+
+	r := bytes.NewReader(written.Bytes())
+	var streamType message.StreamType
+	require.NoError(t, streamType.Decode(r))
+	assert.Equal(t, message.StreamTypeTrackStatus, streamType)
+	var req message.TrackStatusRequestMessage
+	require.NoError(t, req.Decode(r))
+	assert.Equal(t, message.TrackStatusRequestMessage{BroadcastPath: "/live", TrackName: "video"}, req)
+}
+
+func TestSession_TrackStatus_Denied(t *testing.T) {
+	stream := &FakeQUICStream{ReadFunc: func([]byte) (int, error) {
+		return 0, &transport.StreamError{ErrorCode: transport.StreamErrorCode(SubscribeErrorCodeUnauthorized), Remote: true}
+	}}
+	session, _ := newTestSessionWithConn(t, extendedConn, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) { return stream, nil }
+	})
+
+	_, err := session.TrackStatus(t.Context(), "/live", "video")
+	subErr, ok := errors.AsType[*SubscribeError](err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, SubscribeErrorCodeUnauthorized, subErr.SubscribeErrorCode())
+}
+
+func TestSession_TrackStatus_Canceled(t *testing.T) {
+	unblock := make(chan struct{})
+	stream := &FakeQUICStream{ReadFunc: func([]byte) (int, error) {
+		<-unblock
+		return 0, &transport.StreamError{ErrorCode: transport.StreamErrorCode(SubscribeErrorCodeInternal)}
+	}}
+	stream.CancelReadFunc = func(transport.StreamErrorCode) {
+		select {
+		case <-unblock:
+		default:
+			close(unblock)
+		}
+	}
+	session, _ := newTestSessionWithConn(t, extendedConn, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) { return stream, nil }
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := session.TrackStatus(ctx, "/live", "video")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSession_TrackStatus_NotExtended(t *testing.T) {
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) {
+			t.Error("no track status stream should be opened to a moq-lite-04 peer")
+			return &FakeQUICStream{}, nil
+		}
+	})
+
+	_, err := session.TrackStatus(t.Context(), "/live", "video")
+	assert.ErrorIs(t, err, errTrackStatusUnsupported)
+}
+
+func TestSession_ProcessBiStream_TrackStatus_NotExtended(t *testing.T) {
+	mux := NewTrackMux(0)
+	mux.Publish(t.Context(), "/live", statusTrackHandler{
+		TrackHandler: NotFoundTrackHandler,
+		status:       TrackStatus{Code: TrackStatusLive},
+	})
+	session := newSession(&FakeStreamConn{}, mux, nil, nil, nil, nil, nil, nil)
+	defer session.CloseWithError(NoError, "")
+
+	var incoming, written bytes.Buffer
+	require.NoError(t, message.StreamTypeTrackStatus.Encode(&incoming))
+	require.NoError(t, message.TrackStatusRequestMessage{BroadcastPath: "/live", TrackName: "video"}.Encode(&incoming))
+	var canceled transport.StreamErrorCode
+	stream := &FakeQUICStream{
+		ReadFunc:        incoming.Read,
+		WriteFunc:       written.Write,
+		CancelWriteFunc: func(code transport.StreamErrorCode) { canceled = code },
+	}
+
+	session.processBiStream(stream, func() {})
+
+	assert.Equal(t, transport.StreamErrorCode(InternalSessionErrorCode), canceled)
+	assert.Zero(t, written.Len())
+}
+
+func TestSession_ProcessBiStream_TrackStatus(t *testing.T) {
+	tests := map[string]struct {
+		authorizer Authorizer
+		want       *message.TrackStatusMessage
+		wantCode   transport.StreamErrorCode
+	}{
+		"answered": {
+			want: &message.TrackStatusMessage{StatusCode: uint64(TrackStatusLive), LatestGroup: 8, LatestFrame: 1},
+		},
+		"denied": {
+			authorizer: DenyAuthorizer{},
+			wantCode:   transport.StreamErrorCode(SubscribeErrorCodeUnauthorized),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mux := NewTrackMux(0)
+			mux.Publish(t.Context(), "/live", statusTrackHandler{
+				TrackHandler: NotFoundTrackHandler,
+				status:       TrackStatus{Code: TrackStatusLive, LatestGroup: 8, LatestFrame: 1},
+			})
+			conn := &FakeStreamConn{}
+			extendedConn(conn)
+			session := newSession(conn, mux, nil, nil, nil, nil, nil, &sessionOptions{authorizer: tt.authorizer})
+			defer session.CloseWithError(NoError, "")
+
+			var incoming, written bytes.Buffer
+			require.NoError(t, message.StreamTypeTrackStatus.Encode(&incoming))
+			require.NoError(t, message.TrackStatusRequestMessage{BroadcastPath: "/live", TrackName: "video"}.Encode(&incoming))
+			var canceled transport.StreamErrorCode
+			stream := &FakeQUICStream{
+				ReadFunc:        incoming.Read,
+				WriteFunc:       written.Write,
+				CancelWriteFunc: func(code transport.StreamErrorCode) { canceled = code },
+			}
+
+			session.processBiStream(stream, func() {})
+
+			if tt.want == nil {
+				assert.Equal(t, tt.wantCode, canceled)
+				assert.Zero(t, written.Len())
+				return
+			}
+			var got message.TrackStatusMessage
+			require.NoError(t, got.Decode(&written))
+			assert.Equal(t, *tt.want, got)
+		})
+	}
+}
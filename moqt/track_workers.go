@@ -0,0 +1,92 @@
+package moqt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// FrameFunc processes a frame of the group seq. It must not retain frame
+// after returning; use Frame.Clone to keep it.
+type FrameFunc func(seq GroupSequence, frame *Frame) error
+
+// ProcessFrames accepts the groups of the track and calls fn with their
+// frames on up to workers goroutines, so that CPU-heavy processing such as
+// decryption or parsing runs off the goroutine reading the track. If workers
+// is zero or negative, it defaults to runtime.GOMAXPROCS(0).
+//
+// Each group stream is read and processed by a single worker, so the frames
+// of a group are passed to fn in order, while different groups are processed
+// concurrently. When all workers are busy, no more groups are accepted:
+// groups wait in the reader, subject to Config.MaxQueuedGroups, and their
+// streams are not read, so QUIC flow control slows the publisher down.
+//
+// If fn returns an error, the rest of the group is canceled with
+// InternalGroupErrorCode. Frames failing their checksum or schema checks are
+// skipped, and groups that fail to be read are canceled.
+//
+// ProcessFrames returns the error of AcceptGroup, such as ctx.Err() or the
+// end of the subscription, once the groups being processed are done. When
+// ctx is done, the groups being processed are canceled with
+// SubscribeCanceledErrorCode.
+func (r *TrackReader) ProcessFrames(ctx context.Context, workers int, fn FrameFunc) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Each worker slot carries its frame buffer.
+	slots := make(chan *Frame, workers)
+	for range workers {
+		slots <- NewFrame(0)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		var frame *Frame
+		select {
+		case frame = <-slots:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		group, err := r.AcceptGroup(ctx)
+		if err != nil {
+			return err
+		}
+
+		wg.Go(func() {
+			defer func() { slots <- frame }()
+			stop := context.AfterFunc(ctx, func() {
+				group.CancelRead(SubscribeCanceledErrorCode)
+			})
+			defer stop()
+			processGroup(group, frame, fn)
+		})
+	}
+}
+
+// processGroup passes the frames of group to fn in order.
+func processGroup(group *GroupReader, frame *Frame, fn FrameFunc) {
+	seq := group.GroupSequence()
+	for {
+		err := group.ReadFrame(frame)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrSchemaMismatch) {
+			continue
+		}
+		if err != nil {
+			group.CancelRead(InternalGroupErrorCode)
+			return
+		}
+		if err := fn(seq, frame); err != nil {
+			group.CancelRead(InternalGroupErrorCode)
+			return
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFramesStream returns a receive stream holding a group of payloads.
+func newFramesStream(t *testing.T, payloads ...string) *FakeQUICReceiveStream {
+	t.Helper()
+	data := writeSchemaGroup(t, 0, ChecksumNone, payloads...)
+	return &FakeQUICReceiveStream{ReadFunc: bytes.NewReader(data).Read}
+}
+
+func TestTrackReader_ProcessFrames(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+	for seq := range GroupSequence(6) {
+		receiver.enqueueGroup(seq, newFramesStream(t, fmt.Sprint(seq, "-0"), fmt.Sprint(seq, "-1"), fmt.Sprint(seq, "-2")))
+	}
+
+	var (
+		mu          sync.Mutex
+		got         = make(map[GroupSequence][]string)
+		active, top atomic.Int32
+	)
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- receiver.ProcessFrames(ctx, 2, func(seq GroupSequence, frame *Frame) error {
+			n := active.Add(1)
+			defer active.Add(-1)
+			for m := top.Load(); n > m && !top.CompareAndSwap(m, n); m = top.Load() {
+			}
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			got[seq] = append(got[seq], string(frame.Body()))
+			mu.Unlock()
+			return nil
+		})
+	}()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		total := 0
+		for _, frames := range got {
+			total += len(frames)
+		}
+		return total == 18
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-errc, context.Canceled)
+	assert.LessOrEqual(t, top.Load(), int32(2))
+	for seq, frames := range got {
+		assert.Equal(t, []string{fmt.Sprint(seq, "-0"), fmt.Sprint(seq, "-1"), fmt.Sprint(seq, "-2")}, frames)
+	}
+}
+
+func TestTrackReader_ProcessFrames_Backpressure(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+	receiver.enqueueGroup(0, newFramesStream(t, "first"))
+	var read atomic.Bool
+	receiver.enqueueGroup(1, &FakeQUICReceiveStream{ReadFunc: func(p []byte) (int, error) {
+		read.Store(true)
+		return 0, errors.New("not expected")
+	}})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- receiver.ProcessFrames(ctx, 1, func(seq GroupSequence, frame *Frame) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+
+	<-started
+	// The only worker is busy, so the next group is neither accepted nor read.
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, read.Load())
+	receiver.trackMu.Lock()
+	assert.Len(t, receiver.queueing, 1)
+	receiver.trackMu.Unlock()
+
+	cancel()
+	close(release)
+	assert.ErrorIs(t, <-errc, context.Canceled)
+}
+
+func TestTrackReader_ProcessFrames_Error(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+	stream := newFramesStream(t, "one", "two", "three")
+	var code atomic.Int64
+	code.Store(-1)
+	stream.CancelReadFunc = func(c transport.StreamErrorCode) {
+		code.Store(int64(c))
+	}
+	receiver.enqueueGroup(0, stream)
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- receiver.ProcessFrames(ctx, 1, func(seq GroupSequence, frame *Frame) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, string(frame.Body()))
+			if len(got) == 2 {
+				return errors.New("decrypt failed")
+			}
+			return nil
+		})
+	}()
+
+	require.Eventually(t, func() bool {
+		return code.Load() == int64(InternalGroupErrorCode)
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errc, context.Canceled)
+	mu.Lock()
+	assert.Equal(t, []string{"one", "two"}, got)
+	mu.Unlock()
+}
+
+func TestTrackReader_ProcessFrames_Closed(t *testing.T) {
+	receiver, _ := newTestTrackReader(t)
+	require.NoError(t, receiver.Close())
+
+	// The error of AcceptGroup is returned.
+	_, want := receiver.AcceptGroup(t.Context())
+	require.Error(t, want)
+	err := receiver.ProcessFrames(t.Context(), 0, func(GroupSequence, *Frame) error { return nil })
+	assert.Equal(t, want, err)
+}
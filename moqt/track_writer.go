@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 	"github.com/qumo-dev/gomoqt/transport"
@@ -15,6 +16,9 @@ type groupWriterManager struct {
 	mu           sync.Mutex
 	activeGroups map[*GroupWriter]struct{}
 
+	// idleChs are closed once no group is active.
+	idleChs []chan struct{}
+
 	closed bool
 }
 
@@ -28,6 +32,11 @@ func (m *groupWriterManager) addGroup(group *GroupWriter) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if m.closed {
+		// TrackWriter.Close detaches the manager before closing it, so no
+		// group can be opened on a closed manager.
+		if invariantsEnabled {
+			invariantViolated("group opened on a closed track", "group_sequence", group.sequence)
+		}
 		return
 	}
 	m.activeGroups[group] = struct{}{}
@@ -38,6 +47,30 @@ func (m *groupWriterManager) removeGroup(group *GroupWriter) {
 	defer m.mu.Unlock()
 
 	delete(m.activeGroups, group)
+	if len(m.activeGroups) == 0 {
+		m.notifyIdleLocked()
+	}
+}
+
+// idle returns a channel that is closed once no group is active.
+func (m *groupWriterManager) idle() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan struct{})
+	if len(m.activeGroups) == 0 {
+		close(ch)
+		return ch
+	}
+	m.idleChs = append(m.idleChs, ch)
+	return ch
+}
+
+func (m *groupWriterManager) notifyIdleLocked() {
+	for _, ch := range m.idleChs {
+		close(ch)
+	}
+	m.idleChs = nil
 }
 
 func (m *groupWriterManager) countGroups() int {
@@ -46,11 +79,20 @@ func (m *groupWriterManager) countGroups() int {
 	return len(m.activeGroups)
 }
 
-func (m *groupWriterManager) close() {
+// close marks the manager closed and returns the groups that were active.
+// The snapshot is taken under the lock so that it does not race with
+// GroupWriter.Close or CancelWrite removing groups concurrently.
+func (m *groupWriterManager) close() []*GroupWriter {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.closed = true
+	groups := make([]*GroupWriter, 0, len(m.activeGroups))
+	for g := range m.activeGroups {
+		groups = append(groups, g)
+	}
 	m.activeGroups = nil
+	m.notifyIdleLocked()
+	return groups
 }
 
 func newTrackWriter(
@@ -69,6 +111,7 @@ func newTrackWriter(
 		groupManager:      newGroupWriterManager(),
 		openUniStreamFunc: openUniStreamFunc,
 		onCloseTrackFunc:  onCloseTrackFunc,
+		audienceCh:        make(chan struct{}, 1),
 		ctx:               context.WithValue(streamCtx, biStreamTypeCtxKey, message.StreamTypeSubscribe),
 	}
 
@@ -78,6 +121,10 @@ func newTrackWriter(
 // TrackWriter writes groups for a published track.
 // It manages the lifecycle of active groups for that track.
 // The TrackWriter provides output-side methods to send track data.
+//
+// All methods are safe for concurrent use. OpenGroup, OpenGroupAt and the
+// drop methods may run in parallel; Close and CloseWithError wait for
+// in-flight calls to finish and make any later call fail.
 type TrackWriter struct {
 	// BroadcastPath is the path of the broadcast this track belongs to.
 	// The value is set when the subscription is accepted and does not change.
@@ -96,14 +143,106 @@ type TrackWriter struct {
 	// groupSequence is atomically incremented for each OpenGroup call
 	groupSequence atomic.Uint64
 
+	// pacer is shared by every group of this track.
+	pacer pacer
+
+	// bitrateLimit caps the rate of this track at
+	// Config.MaxSubscriptionBitrate and sessionLimit, if set, is the
+	// bandwidth limit of the session. Both are set before the handler is
+	// called.
+	bitrateLimit pacer
+	sessionLimit *pacer
+
+	// scheduler is set by the session and schedules the frames of all
+	// groups by send priority.
+	scheduler *sendScheduler
+
+	// tasks is set by the session and owns the goroutines of the writer and
+	// of its shadow groups; see goTask.
+	tasks *taskGroup
+
+	// deliveryTimeout is the delivery timeout in nanoseconds of groups
+	// opened from now on, or zero for none.
+	deliveryTimeout atomic.Int64
+
+	// coalesceDelay and coalesceSize configure the write coalescing of
+	// groups opened from now on; a zero delay disables it.
+	coalesceDelay atomic.Int64
+	coalesceSize  atomic.Int64
+
+	// checksumMode is the ChecksumMode of groups opened from now on.
+	checksumMode atomic.Uint32
+
+	// schemaID is the SchemaID of groups opened from now on.
+	schemaID atomic.Uint64
+
+	// extensionHeaders writes the extension headers of the frames of groups
+	// opened from now on, if set.
+	extensionHeaders atomic.Bool
+
+	// reliable keeps the groups opened from now on, if set.
+	reliable atomic.Pointer[RetransmitBuffer]
+
+	// interceptor runs on the frames of groups opened from now on.
+	interceptor atomic.Pointer[FrameInterceptor]
+
+	// sessionInterceptor is set by the session before the handler is
+	// called, from Config.WriteFrameInterceptor.
+	sessionInterceptor FrameInterceptor
+
+	// errorCodes maps application error codes onto wire codes. It is set
+	// by the session from Config.ErrorCodes and passed on to the groups.
+	errorCodes *ErrorCodeMap
+
+	// mirrors are the TrackMux.Mirror registrations the groups of the
+	// track are copied through. They are set by the TrackMux before the
+	// handler is called.
+	mirrors []*trackMirror
+
+	// drops counts groups and frames of this subscription that were not delivered.
+	drops dropRecorder
+
+	// rangeEnded is set once the last group of a bounded subscription has
+	// ended.
+	rangeEnded atomic.Bool
+
+	// pathValues holds the wildcard values of the pattern that matched
+	// BroadcastPath. It is set before the handler is called.
+	pathValues map[string]string
+
+	// audience is the latest TrackStats reported by the subscriber.
+	audience   atomic.Pointer[TrackStats]
+	audienceCh chan struct{}
+
 	openUniStreamFunc func() (transport.SendStream, error)
 
+	// sendDatagramFunc is set by the session if the connection supports
+	// datagrams.
+	sendDatagramFunc func([]byte) error
+
+	// maxDatagramSize is the largest datagram the connection accepted, as
+	// learned from the last oversized datagram, or zero if unknown.
+	maxDatagramSize atomic.Int64
+
 	onCloseTrackFunc func()
 
+	// qlog is set by the session before the handler is called.
+	qlog *qlogWriter
+
+	// metrics is set by the session of a Server before the handler is called.
+	metrics ServerMetrics
+
+	// tracer and traceCtx, the context of the subscribe span, are set by the
+	// session before the handler is called.
+	tracer   Tracer
+	traceCtx context.Context
+
 	ctx context.Context
 }
 
-// Close stops publishing and cancels active groups.
+// Close stops publishing and cancels active groups. Frame writes of the
+// groups that wait for pacing or scheduling fail with ErrClosedTrack.
+// It is safe to call concurrently with other methods and more than once.
 func (w *TrackWriter) Close() error {
 	// Take the write lock to ensure Close is exclusive with OpenGroup calls.
 	// This prevents OpenGroup from running concurrently with Close and
@@ -116,11 +255,13 @@ func (w *TrackWriter) Close() error {
 		groupManager := w.groupManager
 		w.groupManager = nil
 
-		activeGroups := groupManager.activeGroups
-
-		groupManager.close()
-
-		for g := range activeGroups {
+		groups := groupManager.close()
+		// Ending a group waits for the frame being written, so the writes
+		// waiting for pacing or scheduling are stopped first.
+		for _, g := range groups {
+			g.stopWrites(ErrClosedTrack)
+		}
+		for _, g := range groups {
 			_ = g.Close()
 		}
 	}
@@ -138,6 +279,9 @@ func (w *TrackWriter) Close() error {
 }
 
 // CloseWithError stops publishing due to an error and cancels active groups.
+// Frame writes of the groups that wait for pacing or scheduling fail with
+// ErrClosedTrack.
+// It is safe to call concurrently with other methods and more than once.
 func (w *TrackWriter) CloseWithError(code SubscribeErrorCode) {
 	// Ensure CloseWithError is exclusive with OpenGroup.
 	w.mu.Lock()
@@ -147,11 +291,13 @@ func (w *TrackWriter) CloseWithError(code SubscribeErrorCode) {
 		groupManager := w.groupManager
 		w.groupManager = nil
 
-		activeGroups := groupManager.activeGroups
-
-		groupManager.close()
-
-		for g := range activeGroups {
+		groups := groupManager.close()
+		// Ending a group waits for the frame being written, so the writes
+		// waiting for pacing or scheduling are stopped first.
+		for _, g := range groups {
+			g.stopWrites(ErrClosedTrack)
+		}
+		for _, g := range groups {
 			g.CancelWrite(PublishAbortedErrorCode)
 		}
 	}
@@ -166,21 +312,54 @@ func (w *TrackWriter) CloseWithError(code SubscribeErrorCode) {
 	}
 }
 
+// CloseWithApplicationError stops publishing with an application-defined
+// error code, mapped onto a wire code by Config.ErrorCodes, and cancels
+// active groups. It fails only if the code cannot be mapped.
+func (w *TrackWriter) CloseWithApplicationError(code uint32) error {
+	wire, err := w.errorCodes.WireCode(SubscribeErrorSpace, code)
+	if err != nil {
+		return err
+	}
+	w.CloseWithError(SubscribeErrorCode(wire))
+	return nil
+}
+
 // OpenGroup opens a new group with an automatically incremented sequence number
 // and returns a GroupWriter to write frames into it.
 // The sequence starts at 1 and increments by 1 for each call.
+// Concurrent calls receive distinct sequence numbers.
 func (w *TrackWriter) OpenGroup() (*GroupWriter, error) {
-	// Atomically increment and get the next sequence
+	return w.openGroup(w.nextSequence(), 0, time.Time{})
+}
+
+// nextSequence atomically increments and returns the next group sequence.
+func (w *TrackWriter) nextSequence() GroupSequence {
 	seq := GroupSequence(w.groupSequence.Add(1))
-	return w.openGroupWithSequence(seq)
+	if invariantsEnabled && seq == 0 {
+		invariantViolated("group sequence counter wrapped",
+			"broadcast_path", w.BroadcastPath, "track_name", w.TrackName)
+	}
+	return seq
 }
 
 // OpenGroupAt opens a new group with the specified sequence number.
 // It advances the internal next-sequence counter to at least seq+1 so that
 // subsequent OpenGroup calls will not produce a duplicate sequence.
+//
+// If the subscriber set an EndGroup, groups past it cannot be opened and
+// fail with ErrGroupOutOfRange. Once the EndGroup has been closed or
+// canceled, the TrackWriter closes itself as soon as the other groups in
+// flight have ended, which ends the subscription for the subscriber. Groups
+// of the range should therefore be opened before the EndGroup is closed.
 func (w *TrackWriter) OpenGroupAt(seq GroupSequence) (*GroupWriter, error) {
-	// Advance the internal counter to avoid collisions with subsequent
-	// OpenGroup calls. CAS loop ensures correctness under concurrency.
+	w.advanceSequence(seq)
+	return w.openGroup(seq, 0, time.Time{})
+}
+
+// advanceSequence advances the internal counter to at least seq+1 to avoid
+// collisions with subsequent OpenGroup calls.
+func (w *TrackWriter) advanceSequence(seq GroupSequence) {
+	// CAS loop ensures correctness under concurrency.
 	for {
 		cur := w.groupSequence.Load()
 		next := max(cur, uint64(seq)+1)
@@ -191,20 +370,137 @@ func (w *TrackWriter) OpenGroupAt(seq GroupSequence) (*GroupWriter, error) {
 			break
 		}
 	}
-	return w.openGroupWithSequence(seq)
 }
 
 // SkipGroups skips the next n group sequences without opening them.
 // This is useful when you need to intentionally create gaps in the sequence,
 // for example, when dropping groups due to packet loss or priority decisions.
+// It is safe to call concurrently with OpenGroup.
 func (w *TrackWriter) SkipGroups(n uint64) {
 	w.groupSequence.Add(n)
 }
 
+// Context returns the context of the subscription. It is canceled when the
+// subscription ends.
 func (w *TrackWriter) Context() context.Context {
 	return w.ctx
 }
 
+// SetPacingRate limits the rate at which frames of this track are handed to
+// the transport, smoothing the burst of a large group over time.
+// bitrate is in bits per second and applies to all groups of the track
+// together; zero disables pacing, which is the default. burst is the number
+// of bytes that may be written back to back; if zero, the amount sent in
+// 10ms at bitrate (at least 1200 bytes) is used.
+//
+// The new rate applies to frames written after the call, including frames of
+// groups that are already open. It is safe to call concurrently.
+func (w *TrackWriter) SetPacingRate(bitrate uint64, burst int) {
+	w.pacer.setRate(bitrate, burst)
+}
+
+// pacers returns the pacers that every frame of the track goes through.
+func (w *TrackWriter) pacers() []*pacer {
+	return []*pacer{&w.pacer, &w.bitrateLimit, w.sessionLimit}
+}
+
+// SetDeliveryTimeout bounds the time a group may take to be delivered: a
+// group opened after the call that has not been closed within d of being
+// opened is canceled with ExpiredGroupErrorCode, so its stream is reset and
+// pending writes to it fail. The drop is recorded as DropReasonStale and the
+// writer can move on to the next group. This suits low-latency live tracks,
+// where late data is worthless. Zero disables the timeout, which is the
+// default. It is safe to call concurrently.
+func (w *TrackWriter) SetDeliveryTimeout(d time.Duration) {
+	w.deliveryTimeout.Store(int64(max(d, 0)))
+}
+
+// SetWriteCoalescing makes groups opened after the call buffer their frames
+// so that small frames written in quick succession, such as audio frames,
+// are sent with fewer stream writes. Buffered frames are written once they
+// reach size bytes, delay after the first of them was written, or when the
+// group is flushed or closed. This saves system calls and packetization
+// overhead at the cost of up to delay of latency. A zero size selects 8 KiB,
+// and a zero delay disables coalescing, which is the default. It is safe to
+// call concurrently.
+func (w *TrackWriter) SetWriteCoalescing(delay time.Duration, size int) {
+	w.coalesceSize.Store(int64(max(size, 0)))
+	w.coalesceDelay.Store(int64(max(delay, 0)))
+}
+
+// SetChecksum makes groups opened after the call carry a checksum of the
+// given mode with every frame, as an extension header announced in the
+// group header. Subscribers verify it if they read the track with the same
+// mode; see ChecksumMode. It returns an error if the session did not
+// negotiate the extensions, whose group headers cannot announce it; see
+// Session.Extensions. It is safe to call concurrently.
+func (w *TrackWriter) SetChecksum(mode ChecksumMode) error {
+	if mode != ChecksumNone && !w.extended() {
+		return errChecksumUnsupported
+	}
+	w.checksumMode.Store(uint32(mode))
+	return nil
+}
+
+// SetSchema makes groups opened after the call carry the schema ID id with
+// every frame, as an extension header announced in the group header, so
+// that the payload format can change between groups. Zero removes the
+// schema ID, which is the default. Subscribers check it if they read the
+// track with a SchemaLookupFunc; see SchemaID. It returns an error if the
+// session did not negotiate the extensions, whose group headers cannot
+// announce it; see Session.Extensions. It is safe to call concurrently.
+func (w *TrackWriter) SetSchema(id SchemaID) error {
+	if id != 0 && !w.extended() {
+		return errSchemaUnsupported
+	}
+	w.schemaID.Store(uint64(id))
+	return nil
+}
+
+// SetExtensionHeaders sets whether groups opened after the call carry the
+// extension headers of every frame, as set with Frame.SetExtensions. It is
+// disabled by default. The setting is announced in the header of every
+// group, so subscribers read the headers without configuration; see
+// ExtensionHeaders. Enabling them returns an error if the session did not
+// negotiate the extensions, whose group headers cannot announce them; see
+// Session.Extensions. It is safe to call concurrently.
+func (w *TrackWriter) SetExtensionHeaders(enabled bool) error {
+	if enabled && !w.extended() {
+		return errExtensionHeadersUnsupported
+	}
+	w.extensionHeaders.Store(enabled)
+	return nil
+}
+
+// extended reports whether the session of the subscription negotiated the
+// extensions. A TrackWriter without a subscription stream has them.
+func (w *TrackWriter) extended() bool {
+	return w.subscribeStream == nil || w.subscribeStream.version.Extended()
+}
+
+// SetFrameInterceptor makes the frames of groups opened after the call pass
+// intercept before the interceptor of the session, if any; see
+// FrameInterceptor. Nil removes it. It is safe to call concurrently.
+func (w *TrackWriter) SetFrameInterceptor(intercept FrameInterceptor) {
+	if intercept == nil {
+		w.interceptor.Store(nil)
+		return
+	}
+	w.interceptor.Store(&intercept)
+}
+
+// frameInterceptor returns the interceptors of the track and the session,
+// chained.
+func (w *TrackWriter) frameInterceptor() FrameInterceptor {
+	var intercept FrameInterceptor
+	if p := w.interceptor.Load(); p != nil {
+		intercept = *p
+	}
+	return ChainFrameInterceptors(intercept, w.sessionInterceptor)
+}
+
+// WriteInfo sends a SUBSCRIBE_OK carrying the publisher's delivery
+// preferences. It is serialized with other writes on the subscribe stream.
 func (w *TrackWriter) WriteInfo(info PublishInfo) error {
 	return w.subscribeStream.writeInfo(info)
 }
@@ -230,7 +526,37 @@ func (w *TrackWriter) DropGroups(drop SubscribeDrop) error {
 		return fmt.Errorf("track writer is closed")
 	}
 
-	return w.subscribeStream.writeDrop(drop)
+	if err := w.subscribeStream.writeDrop(drop); err != nil {
+		return err
+	}
+
+	w.drops.record(DropEvent{
+		Reason:     subscribeDropReason(drop.ErrorCode),
+		StartGroup: drop.StartGroup,
+		EndGroup:   drop.EndGroup,
+	})
+	return nil
+}
+
+// RecordDrop records frames or groups that the application chose not to
+// send, such as frames skipped to stay within a bitrate budget, so that they
+// are counted in DropStats and passed to the drop trace hook. Groups
+// canceled through GroupWriter.CancelWrite and ranges sent with DropGroups
+// are recorded automatically.
+func (w *TrackWriter) RecordDrop(ev DropEvent) {
+	w.drops.record(ev)
+}
+
+// DropStats returns the number of groups and frames of this subscription
+// that were not delivered, by reason.
+func (w *TrackWriter) DropStats() DropStats {
+	return w.drops.stats()
+}
+
+// SetDropTrace installs f to be called synchronously for every drop recorded
+// on this subscription; nil removes it. f must return quickly.
+func (w *TrackWriter) SetDropTrace(f func(DropEvent)) {
+	w.drops.setTrace(f)
 }
 
 // DropNextGroups skips the next n groups and emits a SUBSCRIBE_DROP for the
@@ -259,6 +585,8 @@ func (w *TrackWriter) DropNextGroups(n uint64, code SubscribeErrorCode) error {
 	}
 }
 
+// TrackConfig returns the latest subscription configuration received from
+// the subscriber. The returned value must not be modified.
 func (w *TrackWriter) TrackConfig() *SubscribeConfig {
 	if w.subscribeStream == nil {
 		return &SubscribeConfig{}
@@ -267,12 +595,67 @@ func (w *TrackWriter) TrackConfig() *SubscribeConfig {
 	return w.subscribeStream.TrackConfig()
 }
 
+// Updated returns a channel that receives a value whenever the subscriber
+// updates the subscription. The channel is closed when the subscription ends.
 func (w *TrackWriter) Updated() <-chan struct{} {
 	return w.subscribeStream.Updated()
 }
 
-// openGroupWithSequence is the internal implementation for opening a group with a specific sequence.
-func (w *TrackWriter) openGroupWithSequence(seq GroupSequence) (*GroupWriter, error) {
+// OnUpdate registers f to be called with the new configuration whenever the
+// subscriber updates the subscription, such as its priority or group range,
+// until the returned stop function is called. f is called from the goroutine
+// reading the subscribe stream, so it must not block; TrackConfig already
+// returns the new configuration when f is called.
+//
+// Changes of priority are applied to the groups sent afterwards. The group
+// range and visibility are not enforced by the TrackWriter: publishers that
+// serve ranges, such as of recorded tracks, adjust the groups they write,
+// and publishers may pause or downgrade a subscription that is not visible.
+func (w *TrackWriter) OnUpdate(f func(config *SubscribeConfig)) (stop func()) {
+	if w.subscribeStream == nil {
+		return func() {}
+	}
+	return w.subscribeStream.onUpdate(f)
+}
+
+// PathValue returns the value of the named wildcard in the pattern that
+// routed this subscription to its handler (see TrackMux.Route).
+// It returns "" if the subscription was not routed by a pattern or the
+// pattern has no wildcard with that name.
+func (w *TrackWriter) PathValue(name string) string {
+	return w.pathValues[name]
+}
+
+// Audience returns the latest stats reported by the subscriber through
+// TrackReader.ReportStats. The boolean is false if nothing has been reported,
+// in which case the subscriber counts as a single viewer.
+func (w *TrackWriter) Audience() (TrackStats, bool) {
+	stats := w.audience.Load()
+	if stats == nil {
+		return TrackStats{}, false
+	}
+	return *stats, true
+}
+
+// AudienceUpdated returns a channel that receives a value whenever the
+// subscriber reports new stats.
+func (w *TrackWriter) AudienceUpdated() <-chan struct{} {
+	return w.audienceCh
+}
+
+// setAudience stores stats reported by the subscriber and signals
+// AudienceUpdated without blocking.
+func (w *TrackWriter) setAudience(stats TrackStats) {
+	w.audience.Store(&stats)
+	select {
+	case w.audienceCh <- struct{}{}:
+	default:
+	}
+}
+
+// openGroup is the internal implementation for opening a group, or one of
+// its subgroups, with a specific sequence and the time declared for it.
+func (w *TrackWriter) openGroup(seq GroupSequence, subgroup SubgroupID, timestamp time.Time) (*GroupWriter, error) {
 	// Avoid accessing s.ctx directly; it can be nil if the receiveSubscribeStream
 	// has been cleared during Close(). Instead, capture the receiveSubscribeStream
 	// under lock and validate its context below.
@@ -290,6 +673,25 @@ func (w *TrackWriter) openGroupWithSequence(seq GroupSequence) (*GroupWriter, er
 		return nil, Cause(w.Context())
 	}
 
+	if end := w.TrackConfig().EndGroup; end != MinGroupSequence && seq > end {
+		return nil, ErrGroupOutOfRange
+	}
+	if subgroup != 0 && !w.subscribeStream.version.Extended() {
+		return nil, errSubgroupsUnsupported
+	}
+	if !w.extended() {
+		// The group header cannot announce the extension headers.
+		if w.extensionHeaders.Load() {
+			return nil, errExtensionHeadersUnsupported
+		}
+		if ChecksumMode(w.checksumMode.Load()) != ChecksumNone {
+			return nil, errChecksumUnsupported
+		}
+		if w.schemaID.Load() != 0 {
+			return nil, errSchemaUnsupported
+		}
+	}
+
 	// Ensure the first SUBSCRIBE_OK has been sent before opening a group.
 	err := w.subscribeStream.ensureInfo(PublishInfo{
 		StartGroup: seq,
@@ -322,10 +724,20 @@ func (w *TrackWriter) openGroupWithSequence(seq GroupSequence) (*GroupWriter, er
 		return nil, err
 	}
 
-	err = message.GroupMessage{
+	ext := w.extensionHeaders.Load()
+	mode := ChecksumMode(w.checksumMode.Load())
+	schema := SchemaID(w.schemaID.Load())
+	gm := message.GroupMessage{
 		SubscribeID:   uint64(w.subscribeStream.subscribeID),
 		GroupSequence: uint64(seq),
-	}.Encode(stream)
+		SubgroupID:    uint64(subgroup),
+		Timestamp:     timeToWire(timestamp),
+		Version:       w.subscribeStream.version,
+	}
+	if ext || mode != ChecksumNone || schema != 0 {
+		gm.Flags |= message.GroupFlagExtensionHeaders
+	}
+	err = gm.Encode(stream)
 	if err != nil {
 		var strErr *transport.StreamError
 		if errors.As(err, &strErr) {
@@ -338,5 +750,81 @@ func (w *TrackWriter) openGroupWithSequence(seq GroupSequence) (*GroupWriter, er
 		return nil, err
 	}
 
-	return newGroupWriter(stream, seq, w.groupManager), nil
+	group := newGroupWriter(stream, seq, w.groupManager)
+	group.subgroup = subgroup
+	group.timestamp = timestamp
+	group.errorCodes = w.errorCodes
+	group.openSubgroupFunc = func(seq GroupSequence, id SubgroupID) (*GroupWriter, error) {
+		return w.openGroup(seq, id, timestamp)
+	}
+	group.pacers = w.pacers()
+	if w.scheduler != nil {
+		group.scheduler = w.scheduler
+		group.priorityFunc = func() int64 {
+			return w.scheduler.priority(GroupSendInfo{
+				BroadcastPath: w.BroadcastPath,
+				TrackName:     w.TrackName,
+				Priority:      w.subscribeStream.priority(),
+				Ordered:       w.subscribeStream.ordered(),
+				GroupSequence: seq,
+				SubgroupID:    subgroup,
+			})
+		}
+	}
+	group.drops = &w.drops
+	if mode != ChecksumNone {
+		group.checksum = &groupChecksum{mode: mode}
+	}
+	group.schema = schema
+	group.extensionHeaders = ext
+	group.headerAnnounced = true
+	group.interceptor = w.frameInterceptor()
+	if delay := time.Duration(w.coalesceDelay.Load()); delay > 0 {
+		group.coalescer = newCoalescer(stream, delay, int(w.coalesceSize.Load()), group.flushLater)
+	}
+	if subgroup == 0 {
+		group.retained = w.retainGroup(seq)
+		groupManager := w.groupManager
+		group.onEndFunc = func() { w.groupEnded(seq, groupManager) }
+	}
+	group.qlog = w.qlog
+	group.subscribeID = w.subscribeStream.subscribeID
+	if w.metrics != nil {
+		group.onFrameFunc = func(size int) { w.metrics.FrameSent(w.BroadcastPath, w.TrackName, size) }
+		group.onCancelFunc = func(code GroupErrorCode) { w.metrics.GroupReset(w.BroadcastPath, w.TrackName, code) }
+	}
+	if w.tracer != nil {
+		group.endSpanFunc = endSpanOnce(w.tracer.StartGroup(w.traceCtx, w.BroadcastPath, w.TrackName, seq, false))
+	}
+	w.qlog.groupOpened(true, group.subscribeID, seq)
+	if d := time.Duration(w.deliveryTimeout.Load()); d > 0 {
+		group.expireAfter(d)
+	}
+	if len(w.mirrors) > 0 {
+		group.shadows = w.openShadowGroups(seq, subgroup, timestamp, ext)
+	}
+
+	return group, nil
+}
+
+// groupEnded closes the track, once the groups in flight on groupManager
+// have ended, if seq is the last group of a bounded subscription. It must
+// not take w.mu, as Close ends the groups with it held.
+func (w *TrackWriter) groupEnded(seq GroupSequence, groupManager *groupWriterManager) {
+	end := w.TrackConfig().EndGroup
+	if end == MinGroupSequence || seq < end || groupManager == nil {
+		return
+	}
+	if !w.rangeEnded.CompareAndSwap(false, true) {
+		return
+	}
+
+	idle := groupManager.idle()
+	goTask(w.tasks, "bounded track closer", func() {
+		select {
+		case <-idle:
+			_ = w.Close()
+		case <-w.Context().Done():
+		}
+	}, nil)
 }
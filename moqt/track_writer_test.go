@@ -6,6 +6,7 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/qumo-dev/gomoqt/moqt/internal/message"
 	"github.com/qumo-dev/gomoqt/transport"
@@ -23,7 +24,7 @@ func newTrackWriterDropTestSender(t *testing.T) (*TrackWriter, *bytes.Buffer) {
 		return buf.Write(p)
 	}
 
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		mockSendStream := &FakeQUICSendStream{}
@@ -39,7 +40,7 @@ func TestNewTrackWriter(t *testing.T) {
 		return mockSendStream, nil
 	}
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	t.Logf("mockStream addr: %p", mockStream)
 	t.Logf("substr.stream addr: %p", substr.stream)
 	onCloseTrack := func() {
@@ -64,7 +65,7 @@ func TestTrackWriter_OpenGroup(t *testing.T) {
 			return len(b), nil
 		},
 	}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		mockSendStream := &FakeQUICSendStream{}
@@ -92,7 +93,7 @@ func TestTrackWriter_OpenGroup_ContextCanceled(t *testing.T) {
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		return nil, nil
 	}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	onCloseTrack := func() {}
 
 	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, onCloseTrack)
@@ -112,7 +113,7 @@ func TestTrackWriter_OpenGroup_OpenGroupError(t *testing.T) {
 	}
 
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	onCloseTrack := func() {}
 
@@ -133,7 +134,7 @@ func TestTrackWriter_OpenGroup_Success(t *testing.T) {
 			return len(b), nil
 		},
 	}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		mockSendStream := &FakeQUICSendStream{}
@@ -166,7 +167,7 @@ func TestTrackWriter_ContextCancellation(t *testing.T) {
 	}
 	mockStream := &FakeQUICStream{}
 	mockStream.ParentCtx = ctx
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	onCloseTrack := func() {}
 
 	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, onCloseTrack)
@@ -193,7 +194,7 @@ func TestTrackWriter_Close(t *testing.T) {
 	}
 
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	var onCloseTrackCalled bool
 	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, func() {
 		onCloseTrackCalled = true
@@ -220,7 +221,7 @@ func TestTrackWriter_OpenAfterClose(t *testing.T) {
 	}
 
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	onCloseTrack := func() {}
 
 	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, onCloseTrack)
@@ -276,7 +277,7 @@ func TestTrackWriter_OpenWhileClose(t *testing.T) {
 	}
 
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	onCloseTrack := func() {}
 
 	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, onCloseTrack)
@@ -313,7 +314,7 @@ func TestTrackWriter_Context(t *testing.T) {
 		return mockSendStream, nil
 	}
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	onCloseTrack := func() {}
 
 	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, onCloseTrack)
@@ -328,7 +329,7 @@ func TestTrackWriter_TrackConfig(t *testing.T) {
 		return mockSendStream, nil
 	}
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	onCloseTrack := func() {}
 
 	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, onCloseTrack)
@@ -349,7 +350,7 @@ func TestTrackWriter_RemoveGroup(t *testing.T) {
 		return mockSendStream, nil
 	}
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 	onCloseTrack := func() {}
 
 	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, onCloseTrack)
@@ -366,7 +367,7 @@ func TestTrackWriter_RemoveGroup(t *testing.T) {
 
 func TestTrackWriter_OpenGroup_AutoIncrement(t *testing.T) {
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		mockSendStream := &FakeQUICSendStream{}
@@ -392,7 +393,7 @@ func TestTrackWriter_OpenGroup_AutoIncrement(t *testing.T) {
 
 func TestTrackWriter_SkipGroups(t *testing.T) {
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		mockSendStream := &FakeQUICSendStream{}
@@ -491,7 +492,7 @@ func TestTrackWriter_DropNextGroups(t *testing.T) {
 
 func TestTrackWriter_OpenGroupAt(t *testing.T) {
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		return &FakeQUICSendStream{}, nil
@@ -513,7 +514,7 @@ func TestTrackWriter_OpenGroupAt(t *testing.T) {
 
 func TestTrackWriter_OpenGroupAt_AdvancesCounter(t *testing.T) {
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		return &FakeQUICSendStream{}, nil
@@ -548,6 +549,43 @@ func TestTrackWriter_OpenGroupAt_AdvancesCounter(t *testing.T) {
 	assert.Equal(t, GroupSequence(102), g5.GroupSequence())
 }
 
+func TestTrackWriter_EndGroup(t *testing.T) {
+	mockStream := &FakeQUICStream{}
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{EndGroup: 3}, message.VersionLite04Ext, nil)
+
+	openUniStreamFunc := func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}
+
+	closed := make(chan struct{})
+	sender := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, func() {
+		close(closed)
+	})
+
+	g2, err := sender.OpenGroupAt(2)
+	require.NoError(t, err)
+	g3, err := sender.OpenGroupAt(3)
+	require.NoError(t, err)
+
+	_, err = sender.OpenGroupAt(4)
+	assert.ErrorIs(t, err, ErrGroupOutOfRange)
+
+	// The track stays open until every group of the range is written.
+	require.NoError(t, g3.Close())
+	select {
+	case <-closed:
+		t.Fatal("track closed while a group of the range is open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, g2.Close())
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("track not closed after its last group")
+	}
+}
+
 func TestTrackWriter_WriteInfo(t *testing.T) {
 	sender, buf := newTrackWriterDropTestSender(t)
 
@@ -576,7 +614,7 @@ func TestTrackWriter_WriteInfo(t *testing.T) {
 
 func TestTrackWriter_Updated(t *testing.T) {
 	mockStream := &FakeQUICStream{}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		return &FakeQUICSendStream{}, nil
@@ -633,7 +671,7 @@ func TestTrackWriter_DropGroups_ContextCanceled(t *testing.T) {
 	cancel()
 
 	mockStream := &FakeQUICStream{ParentCtx: ctx}
-	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{})
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
 
 	openUniStreamFunc := func() (transport.SendStream, error) {
 		return &FakeQUICSendStream{}, nil
@@ -648,3 +686,113 @@ func TestTrackWriter_DropGroups_ContextCanceled(t *testing.T) {
 	})
 	assert.Error(t, err)
 }
+
+func TestTrackWriter_ConcurrentOpenGroupAndClose(t *testing.T) {
+	mockStream := &FakeQUICStream{}
+	substr := newReceiveSubscribeStream(SubscribeID(1), mockStream, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+	openUniStreamFunc := func() (transport.SendStream, error) {
+		return &FakeQUICSendStream{}, nil
+	}
+	writer := newTrackWriter("/broadcastpath", "trackname", substr, openUniStreamFunc, func() {})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[GroupSequence]struct{})
+
+	for range 8 {
+		wg.Go(func() {
+			for range 20 {
+				group, err := writer.OpenGroup()
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				_, dup := seen[group.GroupSequence()]
+				seen[group.GroupSequence()] = struct{}{}
+				mu.Unlock()
+				assert.False(t, dup, "OpenGroup must not hand out duplicate sequences")
+
+				frame := NewFrame(0)
+				_, _ = frame.Write([]byte("payload"))
+				_ = group.WriteFrame(frame)
+				_ = group.Close()
+			}
+		})
+	}
+	wg.Go(func() {
+		_ = writer.TrackConfig()
+		_ = writer.Updated()
+		writer.SkipGroups(1)
+	})
+	wg.Go(func() {
+		_ = writer.Close()
+	})
+	wg.Go(func() {
+		writer.CloseWithError(SubscribeErrorCodeInternal)
+	})
+	wg.Wait()
+
+	_, err := writer.OpenGroup()
+	assert.Error(t, err, "OpenGroup after Close should fail")
+}
+
+func TestTrackWriter_SetDeliveryTimeout(t *testing.T) {
+	tests := map[string]struct {
+		timeout     time.Duration
+		closeAfter  time.Duration
+		wantExpired bool
+	}{
+		"late group is reset": {
+			timeout:     20 * time.Millisecond,
+			closeAfter:  100 * time.Millisecond,
+			wantExpired: true,
+		},
+		"group closed in time": {
+			timeout:    time.Second,
+			closeAfter: 0,
+		},
+		"disabled": {
+			timeout:    0,
+			closeAfter: 50 * time.Millisecond,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			var codes []transport.StreamErrorCode
+			substr := newReceiveSubscribeStream(SubscribeID(1), &FakeQUICStream{}, &SubscribeConfig{}, message.VersionLite04Ext, nil)
+			tw := newTrackWriter("/broadcastpath", "trackname", substr, func() (transport.SendStream, error) {
+				stream := &FakeQUICSendStream{}
+				stream.CancelWriteFunc = func(code transport.StreamErrorCode) {
+					mu.Lock()
+					codes = append(codes, code)
+					mu.Unlock()
+				}
+				return stream, nil
+			}, func() {})
+			tw.SetDeliveryTimeout(tt.timeout)
+
+			gw, err := tw.OpenGroup()
+			require.NoError(t, err)
+			require.NoError(t, gw.WriteFrame(NewFrame(0)))
+
+			time.Sleep(tt.closeAfter)
+			err = gw.Close()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.wantExpired {
+				var grpErr *GroupError
+				require.ErrorAs(t, err, &grpErr)
+				assert.Equal(t, ExpiredGroupErrorCode, grpErr.GroupErrorCode())
+				assert.Equal(t, []transport.StreamErrorCode{transport.StreamErrorCode(ExpiredGroupErrorCode)}, codes)
+				assert.Equal(t, uint64(1), tw.DropStats().Groups[DropReasonStale])
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, codes)
+			assert.Zero(t, tw.DropStats().Groups[DropReasonStale])
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/quicgo"
+)
+
+// UpgradeEnv is the environment variable through which a server handing
+// over its listeners describes them to the binary that replaces it. Its
+// value is an UpgradeState encoded as JSON, without the sessions, which
+// carry credentials and are read from the inherited state pipe instead.
+const UpgradeEnv = "GOMOQT_UPGRADE"
+
+// UpgradeState is the metadata a server hands to the binary that replaces
+// it during an Upgrade.
+type UpgradeState struct {
+	// Listeners are the UDP sockets handed over.
+	Listeners []UpgradeListener `json:"listeners"`
+
+	// ReadyFD is the descriptor of the pipe on which the new binary writes
+	// one byte once it serves every listener.
+	ReadyFD int `json:"ready_fd"`
+
+	// StateFD is the descriptor of the pipe from which the new binary reads
+	// Sessions, encoded as JSON, until the old binary closes it.
+	StateFD int `json:"state_fd"`
+
+	// Sessions are the sessions of the old binary, for the new binary to
+	// resume, such as with Handoff.Restore. Their resume and auth tokens
+	// are credentials, so they are sent on the state pipe rather than in
+	// the environment, where other processes of the user could read them
+	// and whose size is limited.
+	Sessions []SessionState `json:"-"`
+}
+
+// UpgradeListener is a UDP socket handed over during an Upgrade.
+type UpgradeListener struct {
+	// Addr is the address the socket was opened for, as passed to the
+	// ListenFunc of the Upgrader.
+	Addr string `json:"addr"`
+
+	// FD is the descriptor of the socket in the new binary.
+	FD int `json:"fd"`
+}
+
+// Upgrader replaces the running binary of a server with a new one without
+// closing its UDP sockets, so that the server keeps its ports and clients
+// are never refused during the upgrade.
+//
+// The handoff protocol is as follows:
+//
+//  1. The old binary starts the new one, passing each socket, the write end
+//     of a ready pipe and the read end of a state pipe as inherited
+//     descriptors, and an UpgradeState describing them in the UpgradeEnv
+//     environment variable. It then writes the sessions on the state pipe.
+//  2. The new binary listens on the inherited sockets instead of opening
+//     new ones, and writes one byte on the pipe once it serves all of them.
+//  3. Having read the byte, the old binary stops accepting connections and
+//     sends GOAWAY to its sessions with Server.Shutdown. Clients supporting
+//     session migration reconnect, and land on the new binary.
+//
+// QUIC connection state lives in the memory of a process, so connections
+// themselves are not handed over: the old binary drains them. Until it
+// exits, both binaries read the sockets and some packets reach the wrong
+// one, which drops them and lets QUIC retransmit them. Keep the drain short,
+// and do not set a StatelessResetKey in the QUICConfig, or a binary would
+// reset the connections of the other.
+//
+// The same Upgrader is used by both binaries. Its ListenFunc is installed on
+// the Server:
+//
+//	up, err := moqt.NewUpgrader()
+//	server.ListenFunc = up.ListenFunc
+//	go server.ListenAndServe()
+//
+// and, on SIGUSR2 for instance, the old binary upgrades and shuts down:
+//
+//	if _, err := up.Upgrade(ctx); err == nil {
+//		server.Shutdown(ctx)
+//		up.Close()
+//	}
+//
+// Inherited descriptors are not supported on Windows.
+type Upgrader struct {
+	// Command returns the command that runs the new binary. Upgrade sets its
+	// ExtraFiles and adds UpgradeEnv to its Env. If nil, the executable of
+	// the running process is started with the same arguments, environment,
+	// standard output and standard error.
+	Command func() (*exec.Cmd, error)
+
+	// Sessions returns the sessions handed to the new binary, such as the
+	// Sessions of a Handoff. Optional.
+	Sessions func() []SessionState
+
+	mu        sync.Mutex
+	listeners map[string]*upgradeListener
+
+	// inherited is the state handed over by the old binary, or nil.
+	inherited *UpgradeState
+	// ready is the pipe to the old binary, until it is signaled.
+	ready *os.File
+}
+
+// upgradeListener is a socket an Upgrader listens on.
+type upgradeListener struct {
+	conn      *net.UDPConn
+	transport io.Closer
+}
+
+// NewUpgrader returns an Upgrader. If the process was started by Upgrade,
+// the Upgrader takes over the state of the old binary from UpgradeEnv and
+// the state pipe, and removes the variable from the environment.
+func NewUpgrader() (*Upgrader, error) {
+	u := &Upgrader{}
+	value, ok := os.LookupEnv(UpgradeEnv)
+	if !ok {
+		return u, nil
+	}
+	var state UpgradeState
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return nil, fmt.Errorf("moqt: invalid %s: %w", UpgradeEnv, err)
+	}
+	_ = os.Unsetenv(UpgradeEnv)
+	if state.StateFD != 0 {
+		f := os.NewFile(uintptr(state.StateFD), "upgrade-state")
+		err := json.NewDecoder(f).Decode(&state.Sessions)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("moqt: failed to read the upgrade state: %w", err)
+		}
+	}
+	u.inherited = &state
+	u.ready = os.NewFile(uintptr(state.ReadyFD), "upgrade-ready")
+	return u, nil
+}
+
+// State returns the state handed over by the old binary, if the process was
+// started by Upgrade.
+func (u *Upgrader) State() (UpgradeState, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.inherited == nil {
+		return UpgradeState{}, false
+	}
+	return *u.inherited, true
+}
+
+// ListenFunc listens on addr, on the socket inherited for addr if any and
+// on a new UDP socket otherwise. It has the signature of Server.ListenFunc.
+// Closing the returned listener leaves the connections it accepted open
+// until Close.
+func (u *Upgrader) ListenFunc(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.listeners[addr]; ok {
+		return nil, fmt.Errorf("moqt: already listening on %s", addr)
+	}
+
+	conn, err := u.listenLocked(addr)
+	if err != nil {
+		return nil, err
+	}
+	ln, transport, err := quicgo.ListenEarly(conn, tlsConfig, quicConfig)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if u.listeners == nil {
+		u.listeners = make(map[string]*upgradeListener)
+	}
+	u.listeners[addr] = &upgradeListener{conn: conn, transport: transport}
+
+	u.signalReadyLocked()
+	return ln, nil
+}
+
+// listenLocked returns the socket inherited for addr, or a new one. u.mu
+// must be held.
+func (u *Upgrader) listenLocked(addr string) (*net.UDPConn, error) {
+	if u.inherited != nil {
+		i := slices.IndexFunc(u.inherited.Listeners, func(l UpgradeListener) bool {
+			return l.Addr == addr
+		})
+		if i >= 0 {
+			f := os.NewFile(uintptr(u.inherited.Listeners[i].FD), "udp:"+addr)
+			pc, err := net.FilePacketConn(f)
+			_ = f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("moqt: failed to inherit the socket of %s: %w", addr, err)
+			}
+			conn, ok := pc.(*net.UDPConn)
+			if !ok {
+				_ = pc.Close()
+				return nil, fmt.Errorf("moqt: inherited socket of %s is not a UDP socket", addr)
+			}
+			return conn, nil
+		}
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", udpAddr)
+}
+
+// signalReadyLocked tells the old binary that the new one is ready once it
+// listens on every inherited socket. u.mu must be held.
+func (u *Upgrader) signalReadyLocked() {
+	if u.ready == nil {
+		return
+	}
+	for _, l := range u.inherited.Listeners {
+		if _, ok := u.listeners[l.Addr]; !ok {
+			return
+		}
+	}
+	_, _ = u.ready.Write([]byte{1})
+	_ = u.ready.Close()
+	u.ready = nil
+}
+
+// Upgrade starts the new binary, handing it the sockets of the listeners
+// opened by ListenFunc, and waits until it serves them. The server then
+// shuts down with Server.Shutdown, and calls Close once it returns. If ctx
+// ends or the new binary exits before it is ready, the new binary is killed
+// and an error is returned.
+func (u *Upgrader) Upgrade(ctx context.Context) (*os.Process, error) {
+	u.mu.Lock()
+	addrs := make([]string, 0, len(u.listeners))
+	for addr := range u.listeners {
+		addrs = append(addrs, addr)
+	}
+	slices.Sort(addrs)
+	files := make([]*os.File, 0, len(addrs))
+	for _, addr := range addrs {
+		f, err := u.listeners[addr].conn.File()
+		if err != nil {
+			u.mu.Unlock()
+			closeFiles(files)
+			return nil, fmt.Errorf("moqt: failed to hand over the socket of %s: %w", addr, err)
+		}
+		files = append(files, f)
+	}
+	u.mu.Unlock()
+	defer closeFiles(files)
+
+	if len(files) == 0 {
+		return nil, errors.New("moqt: no listener to hand over")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	stateR, stateW, err := os.Pipe()
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	defer stateW.Close()
+
+	// Inherited descriptors are numbered from 3, in the order of ExtraFiles.
+	state := UpgradeState{ReadyFD: 3, StateFD: 4}
+	for i, addr := range addrs {
+		state.Listeners = append(state.Listeners, UpgradeListener{Addr: addr, FD: 5 + i})
+	}
+	var list []SessionState
+	if u.Sessions != nil {
+		list = u.Sessions()
+	}
+	sessions, err := json.Marshal(list)
+	var value []byte
+	if err == nil {
+		value, err = json.Marshal(state)
+	}
+	var cmd *exec.Cmd
+	if err == nil {
+		cmd, err = u.command()
+	}
+	if err != nil {
+		_ = w.Close()
+		_ = stateR.Close()
+		return nil, err
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(slices.DeleteFunc(cmd.Env, func(kv string) bool {
+		return strings.HasPrefix(kv, UpgradeEnv+"=")
+	}), UpgradeEnv+"="+string(value))
+	cmd.ExtraFiles = append([]*os.File{w, stateR}, files...)
+
+	err = cmd.Start()
+	_ = w.Close()
+	_ = stateR.Close()
+	u.restoreNonblock()
+	if err != nil {
+		return nil, fmt.Errorf("moqt: failed to start the new binary: %w", err)
+	}
+
+	// The writer ends when the new binary has read the sessions or, as the
+	// pipe is closed below, when the upgrade ends without it. The reader
+	// ends when the new binary writes to the ready pipe or, as it is killed
+	// below if it is not ready in time, exits and closes it.
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		_, _ = stateW.Write(sessions)
+		_ = stateW.Close()
+	}()
+	ready := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := io.ReadFull(r, b[:])
+		if errors.Is(err, io.EOF) {
+			err = errors.New("moqt: new binary exited before it was ready")
+		}
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+	_ = stateW.Close()
+	<-written
+	if err != nil {
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+// restoreNonblock puts the sockets back in non-blocking mode after they
+// were handed to the new binary, so that the deadlines of their readers
+// keep working.
+func (u *Upgrader) restoreNonblock() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, l := range u.listeners {
+		_ = setNonblock(l.conn)
+	}
+}
+
+func (u *Upgrader) command() (*exec.Cmd, error) {
+	if u.Command != nil {
+		return u.Command()
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd, nil
+}
+
+// Close closes the connections accepted on the listeners opened by
+// ListenFunc, and their sockets.
+func (u *Upgrader) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var errs []error
+	for addr, l := range u.listeners {
+		errs = append(errs, l.transport.Close(), l.conn.Close())
+		delete(u.listeners, addr)
+	}
+	return errors.Join(errs...)
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
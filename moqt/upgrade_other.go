@@ -0,0 +1,11 @@
+//go:build !unix
+
+package moqt
+
+import "net"
+
+// setNonblock does nothing, as sockets are only handed to child processes
+// on Unix.
+func setNonblock(conn *net.UDPConn) error {
+	return nil
+}
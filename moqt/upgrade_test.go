@@ -0,0 +1,207 @@
+package moqt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upgradeChildEnv makes TestUpgrader_Child run as the new binary of an
+// upgrade.
+const upgradeChildEnv = "GOMOQT_TEST_UPGRADE_CHILD"
+
+// upgradeTestSessions returns sessions larger than an environment variable
+// can hold, to check that they are handed over on the state pipe.
+func upgradeTestSessions() []SessionState {
+	sessions := make([]SessionState, 2000)
+	for i := range sessions {
+		sessions[i] = SessionState{ResumeToken: fmt.Sprintf("tok-%d-%s", i, strings.Repeat("x", 100))}
+	}
+	return sessions
+}
+
+func newUpgradeTestServer(t *testing.T, addr string, up *Upgrader) *Server {
+	t.Helper()
+
+	return &Server{
+		Addr:                addr,
+		TLSConfig:           testutil.TLSConfig(t),
+		ListenFunc:          up.ListenFunc,
+		DisableWebTransport: true,
+		Handler: HandleFunc(func(sess *Session) {
+			<-sess.Context().Done()
+		}),
+	}
+}
+
+// TestUpgrader_Child is the new binary started by TestUpgrader.
+func TestUpgrader_Child(t *testing.T) {
+	if os.Getenv(upgradeChildEnv) == "" {
+		t.Skip("run by TestUpgrader")
+	}
+
+	require.NotContains(t, os.Getenv(UpgradeEnv), "tok-", "credentials must not be in the environment")
+	up, err := NewUpgrader()
+	require.NoError(t, err)
+	state, ok := up.State()
+	require.True(t, ok)
+	require.Equal(t, upgradeTestSessions(), state.Sessions)
+	require.Len(t, state.Listeners, 1)
+
+	server := newUpgradeTestServer(t, state.Listeners[0].Addr, up)
+	_ = server.ListenAndServe()
+}
+
+func TestUpgrader(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inherited descriptors are not supported on Windows")
+	}
+
+	up := &Upgrader{
+		Command: func() (*exec.Cmd, error) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestUpgrader_Child$")
+			cmd.Env = append(os.Environ(), upgradeChildEnv+"=1")
+			return cmd, nil
+		},
+		Sessions: upgradeTestSessions,
+	}
+	// The socket is handed over under the address it was opened for, so the
+	// new binary listens on the same port.
+	server := newUpgradeTestServer(t, "127.0.0.1:0", up)
+	bound := make(chan string, 1)
+	server.ListenFunc = func(addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (QUICListener, error) {
+		ln, err := up.ListenFunc(addr, tlsConfig, quicConfig)
+		if err == nil {
+			bound <- ln.Addr().String()
+		}
+		return ln, err
+	}
+	go func() {
+		_ = server.ListenAndServe()
+	}()
+	addr := <-bound
+
+	goaway := make(chan string, 1)
+	dialer := &Dialer{
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		OnGoaway: func(uri string) {
+			goaway <- uri
+		},
+	}
+	dial := func() *Session {
+		var sess *Session
+		require.Eventually(t, func() bool {
+			ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+			defer cancel()
+			var err error
+			sess, err = dialer.Dial(ctx, "moqt://"+addr, nil)
+			return err == nil
+		}, 10*time.Second, 20*time.Millisecond)
+		t.Cleanup(func() {
+			_ = sess.CloseWithError(NoError, "")
+		})
+		return sess
+	}
+	dial()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	p, err := up.Upgrade(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = p.Kill()
+		_, _ = p.Wait()
+	})
+
+	server.NextSessionURI = "moqt://" + addr
+	shutdownCtx, cancelShutdown := context.WithTimeout(t.Context(), time.Second)
+	defer cancelShutdown()
+	_ = server.Shutdown(shutdownCtx)
+	select {
+	case uri := <-goaway:
+		assert.Equal(t, "moqt://"+addr, uri)
+	case <-time.After(time.Second):
+		t.Fatal("no GOAWAY received")
+	}
+	require.NoError(t, up.Close())
+
+	// The old binary no longer reads the socket: the new one accepts.
+	dial()
+}
+
+func TestUpgrader_Upgrade_NotReady(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inherited descriptors are not supported on Windows")
+	}
+
+	up := &Upgrader{
+		Command: func() (*exec.Cmd, error) {
+			// The test binary runs no test and exits without listening.
+			return exec.Command(os.Args[0], "-test.run=^$"), nil
+		},
+	}
+	ln, err := up.ListenFunc("127.0.0.1:0", &tls.Config{}, nil)
+	require.NoError(t, err)
+	defer up.Close()
+	defer ln.Close()
+
+	_, err = up.Upgrade(t.Context())
+	assert.ErrorContains(t, err, "exited before it was ready")
+}
+
+func TestUpgrader_Upgrade_NoListener(t *testing.T) {
+	_, err := (&Upgrader{}).Upgrade(t.Context())
+	assert.Error(t, err)
+}
+
+func TestUpgrader_ListenFunc_Twice(t *testing.T) {
+	up := &Upgrader{}
+	ln, err := up.ListenFunc("127.0.0.1:0", &tls.Config{}, nil)
+	require.NoError(t, err)
+	defer up.Close()
+	defer ln.Close()
+
+	_, err = up.ListenFunc("127.0.0.1:0", &tls.Config{}, nil)
+	assert.Error(t, err)
+}
+
+func TestNewUpgrader(t *testing.T) {
+	tests := map[string]struct {
+		value     string
+		inherited bool
+		wantErr   bool
+	}{
+		"fresh start": {},
+		"upgrade":     {value: `{"listeners":[],"ready_fd":-1}`, inherited: true},
+		"invalid":     {value: "{", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.value != "" {
+				t.Setenv(UpgradeEnv, tt.value)
+			}
+			up, err := NewUpgrader()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			_, ok := up.State()
+			assert.Equal(t, tt.inherited, ok)
+			_, set := os.LookupEnv(UpgradeEnv)
+			assert.False(t, set)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package moqt
+
+import (
+	"net"
+	"syscall"
+)
+
+// setNonblock puts the socket of conn back in non-blocking mode. Handing
+// its descriptor to a child process puts it in blocking mode, and the socket
+// shares the mode with its duplicates.
+func setNonblock(conn *net.UDPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var nbErr error
+	err = raw.Control(func(fd uintptr) {
+		nbErr = syscall.SetNonblock(int(fd), true)
+	})
+	if err != nil {
+		return err
+	}
+	return nbErr
+}
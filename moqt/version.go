@@ -0,0 +1,82 @@
+package moqt
+
+import (
+	"slices"
+
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+)
+
+// Versions of MOQ a session can negotiate. The version is the ALPN token of
+// a native QUIC connection and the subprotocol of a WebTransport session.
+const (
+	// VersionLite04 is moq-lite-04, the default version.
+	VersionLite04 = NextProtoMOQ
+
+	// VersionLite03 is moq-lite-03, whose PROBE messages carry no RTT.
+	VersionLite03 = "moq-lite-03"
+
+	// VersionLite04Ext is moq-lite-04 with the extensions of this package:
+	// the authorization token, start and parameters of SUBSCRIBE, the
+	// visibility of SUBSCRIBE_UPDATE, the broadcast ID and tracks of
+	// ANNOUNCE, and the subgroup, timestamp and flags of GROUP. The other
+	// versions do not carry them, so features that depend on them, such as
+	// extension headers and checksums, are unavailable on their sessions.
+	VersionLite04Ext = "moq-lite-04+gomoqt"
+)
+
+// isVersion reports whether v is a version this package implements.
+func isVersion(v string) bool {
+	return v == VersionLite04Ext || v == VersionLite04 || v == VersionLite03
+}
+
+// wireVersion returns the encoding of the messages of version v.
+func wireVersion(v string) message.Version {
+	switch v {
+	case VersionLite04Ext:
+		return message.VersionLite04Ext
+	case VersionLite03:
+		return message.VersionLite03
+	default:
+		return message.VersionLite04
+	}
+}
+
+// supportedVersions returns the configured versions this package
+// implements, in order, or VersionLite04Ext and VersionLite04 if there are
+// none.
+func (c *Config) supportedVersions() []string {
+	var versions []string
+	if c != nil {
+		for _, v := range c.SupportedVersions {
+			if isVersion(v) && !slices.Contains(versions, v) {
+				versions = append(versions, v)
+			}
+		}
+	}
+	if len(versions) == 0 {
+		return []string{VersionLite04Ext, VersionLite04}
+	}
+	return versions
+}
+
+// supportsVersion reports whether v is one of the supported versions.
+func (c *Config) supportsVersion(v string) bool {
+	return slices.Contains(c.supportedVersions(), v)
+}
+
+// negotiatedVersion returns the version negotiated on conn: the subprotocol
+// of a WebTransport session, or the ALPN protocol of a native QUIC
+// connection. Peers that negotiated none speak VersionLite04, which predates
+// negotiation.
+func negotiatedVersion(conn StreamConn) string {
+	if wt, ok := conn.(interface{ Subprotocol() string }); ok {
+		if v := wt.Subprotocol(); v != "" {
+			return v
+		}
+		return VersionLite04
+	}
+	if state := conn.TLS(); state != nil && isVersion(state.NegotiatedProtocol) {
+		return state.NegotiatedProtocol
+	}
+	return VersionLite04
+}
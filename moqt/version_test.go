@@ -0,0 +1,272 @@
+package moqt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/qumo-dev/gomoqt/moqt/internal/message"
+	"github.com/qumo-dev/gomoqt/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_SupportedVersions(t *testing.T) {
+	tests := map[string]struct {
+		config *Config
+		want   []string
+	}{
+		"nil config": {
+			want: []string{VersionLite04Ext, VersionLite04},
+		},
+		"empty": {
+			config: &Config{},
+			want:   []string{VersionLite04Ext, VersionLite04},
+		},
+		"in order": {
+			config: &Config{SupportedVersions: []string{VersionLite03, VersionLite04}},
+			want:   []string{VersionLite03, VersionLite04},
+		},
+		"unknown and duplicates ignored": {
+			config: &Config{SupportedVersions: []string{"moq-lite-99", VersionLite03, VersionLite03}},
+			want:   []string{VersionLite03},
+		},
+		"only unknown": {
+			config: &Config{SupportedVersions: []string{"moq-lite-99"}},
+			want:   []string{VersionLite04Ext, VersionLite04},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.config.supportedVersions())
+			assert.Equal(t, tt.want, tt.config.Clone().supportedVersions())
+		})
+	}
+}
+
+func TestNegotiatedVersion(t *testing.T) {
+	withALPN := func(proto string) *FakeStreamConn {
+		return &FakeStreamConn{TLSFunc: func() *tls.ConnectionState {
+			return &tls.ConnectionState{NegotiatedProtocol: proto}
+		}}
+	}
+	withSubprotocol := func(proto string) *FakeWebTransportSession {
+		return &FakeWebTransportSession{
+			FakeStreamConn:  *withALPN(NextProtoH3),
+			SubprotocolFunc: func() string { return proto },
+		}
+	}
+
+	tests := map[string]struct {
+		conn StreamConn
+		want string
+	}{
+		"alpn":                    {conn: withALPN(VersionLite03), want: VersionLite03},
+		"extension alpn":          {conn: withALPN(VersionLite04Ext), want: VersionLite04Ext},
+		"unknown alpn":            {conn: withALPN("custom"), want: VersionLite04},
+		"no tls":                  {conn: &FakeStreamConn{}, want: VersionLite04},
+		"subprotocol":             {conn: withSubprotocol(VersionLite03), want: VersionLite03},
+		"no subprotocol selected": {conn: withSubprotocol(""), want: VersionLite04},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiatedVersion(tt.conn))
+		})
+	}
+}
+
+func TestDialer_DialQUIC_SupportedVersions(t *testing.T) {
+	var offered []string
+	dialer := &Dialer{
+		Config: &Config{SupportedVersions: []string{VersionLite04, VersionLite03}},
+		DialQUICFunc: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (StreamConn, error) {
+			offered = tlsConfig.NextProtos
+			return &FakeStreamConn{TLSFunc: func() *tls.ConnectionState {
+				return &tls.ConnectionState{NegotiatedProtocol: VersionLite03}
+			}}, nil
+		},
+	}
+
+	sess, err := dialer.DialQUIC(t.Context(), "example.com:9000", nil)
+	require.NoError(t, err)
+	defer sess.CloseWithError(NoError, "")
+
+	assert.Equal(t, []string{VersionLite04, VersionLite03}, offered)
+	assert.Equal(t, VersionLite03, sess.ConnectionState().Version)
+}
+
+func TestDialer_DialWebTransport_UnsupportedVersion(t *testing.T) {
+	// A server predating negotiation selects no subprotocol and speaks
+	// VersionLite04, which the dialer does not support.
+	conn := &FakeWebTransportSession{}
+	dialer := &Dialer{
+		Config: &Config{SupportedVersions: []string{VersionLite03}},
+		DialWebTransportFunc: func(ctx context.Context, addr string, header http.Header, tlsConfig *tls.Config) (*http.Response, WebTransportSession, error) {
+			return nil, conn, nil
+		},
+	}
+
+	sess, err := dialer.DialWebTransport(t.Context(), "example.com:8443", "/", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+	assert.Nil(t, sess)
+
+	appErr, ok := context.Cause(conn.Context()).(*transport.ApplicationError)
+	require.True(t, ok)
+	assert.Equal(t, transport.ApplicationErrorCode(UnsupportedVersionErrorCode), appErr.ErrorCode)
+}
+
+func TestServer_ServeQUICConn_SupportedVersions(t *testing.T) {
+	tests := map[string]struct {
+		protocol string
+		wantErr  string
+	}{
+		"supported":   {protocol: VersionLite03},
+		"unsupported": {protocol: VersionLite04, wantErr: "unsupported protocol"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var version string
+			s := &Server{
+				Config: &Config{SupportedVersions: []string{VersionLite03}},
+				Handler: HandleFunc(func(sess *Session) {
+					version = sess.ConnectionState().Version
+				}),
+			}
+			conn := newTestNativeQUICConn(t, func(conn *FakeStreamConn) {
+				conn.TLSFunc = func() *tls.ConnectionState {
+					return &tls.ConnectionState{NegotiatedProtocol: tt.protocol}
+				}
+			})
+
+			err := s.ServeQUICConn(conn)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, version)
+				return
+			}
+			assert.Equal(t, VersionLite03, version)
+		})
+	}
+}
+
+func TestSession_Probe_Lite03(t *testing.T) {
+	conn := &FakeStreamConn{TLSFunc: func() *tls.ConnectionState {
+		return &tls.ConnectionState{NegotiatedProtocol: VersionLite03}
+	}}
+
+	probeStream := &FakeQUICStream{}
+	var written bytes.Buffer
+	probeStream.WriteFunc = written.Write
+
+	var response bytes.Buffer
+	require.NoError(t, message.ProbeMessage{Bitrate: 250000, Version: message.VersionLite03}.Encode(&response))
+	probeStream.ReadFunc = response.Read
+	conn.OpenStreamFunc = func() (transport.Stream, error) { return probeStream, nil }
+
+	session := newTestSession(conn)
+	defer session.CloseWithError(NoError, "")
+
+	ch, err := session.Probe(1000000)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(250000), (<-ch).Bitrate)
+
+	r := bytes.NewReader(written.Bytes())
+	var streamType message.StreamType
+	require.NoError(t, streamType.Decode(r))
+	sent := message.ProbeMessage{Version: message.VersionLite03}
+	require.NoError(t, sent.Decode(r))
+	assert.Equal(t, uint64(1000000), sent.Bitrate)
+	assert.Zero(t, r.Len())
+}
+
+func TestSession_Extensions(t *testing.T) {
+	tests := map[string]struct {
+		protocol string
+		want     bool
+	}{
+		"extension version": {protocol: VersionLite04Ext, want: true},
+		"moq-lite-04":       {protocol: VersionLite04},
+		"moq-lite-03":       {protocol: VersionLite03},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+				conn.TLSFunc = func() *tls.ConnectionState {
+					return &tls.ConnectionState{NegotiatedProtocol: tt.protocol}
+				}
+			})
+			assert.Equal(t, tt.want, session.Extensions())
+		})
+	}
+}
+
+func TestSession_NoExtensionStreams(t *testing.T) {
+	for _, protocol := range []string{VersionLite04, VersionLite03} {
+		t.Run(protocol, func(t *testing.T) {
+			var mu sync.Mutex
+			var streamTypes []message.StreamType
+			conn := &FakeStreamConn{
+				TLSFunc: func() *tls.ConnectionState {
+					return &tls.ConnectionState{NegotiatedProtocol: protocol}
+				},
+				OpenStreamFunc: func() (transport.Stream, error) {
+					first := true
+					return &FakeQUICStream{WriteFunc: func(p []byte) (int, error) {
+						mu.Lock()
+						defer mu.Unlock()
+						if first && len(p) > 0 {
+							streamTypes = append(streamTypes, message.StreamType(p[0]))
+							first = false
+						}
+						return len(p), nil
+					}}, nil
+				},
+			}
+			session := newSession(conn, NewTrackMux(0), nil, &Config{
+				KeepAliveInterval: 5 * time.Millisecond,
+				IdleTimeout:       time.Minute,
+			}, nil, nil, nil, nil)
+			defer session.CloseWithError(NoError, "")
+
+			assert.Error(t, session.reportTrackStats(1, TrackStats{Subscribers: 1}))
+			_, err := session.TrackStatus(t.Context(), "/live", "video")
+			assert.Error(t, err)
+			time.Sleep(50 * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, st := range streamTypes {
+				assert.False(t, st.Extended(), "stream type %d opened to a %s peer", st, protocol)
+			}
+		})
+	}
+}
+
+func TestSession_Subscribe_Lite04(t *testing.T) {
+	opened := false
+	session, _ := newTestSessionWithConn(t, func(conn *FakeStreamConn) {
+		conn.OpenStreamFunc = func() (transport.Stream, error) {
+			opened = true
+			return &FakeQUICStream{}, nil
+		}
+	})
+
+	// A moq-lite-04 peer does not understand the extension fields.
+	reader, err := session.Subscribe(t.Context(), "/test/path", "video", &SubscribeConfig{
+		AuthToken: "abc",
+		Start:     SubscribeStartLatestGroup,
+	})
+	assert.ErrorIs(t, err, errSubscribeExtensionsUnsupported)
+	assert.ErrorContains(t, err, "Start, AuthToken")
+	assert.Nil(t, reader)
+	assert.False(t, opened, "no stream should be opened")
+}
@@ -0,0 +1,226 @@
+package moqt
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// VirtualHost is an independent logical MOQ service hosted on a Server.
+// Connections are routed to a VirtualHost by the TLS server name (SNI) and,
+// for WebTransport, by the request path, analogous to virtual hosts in HTTP
+// servers. Each VirtualHost has its own handlers, mux, configuration and
+// certificates; fields left nil fall back to the corresponding Server field.
+type VirtualHost struct {
+	// Name identifies the virtual host in logs.
+	// If empty, Host is used.
+	Name string
+
+	// Host is the server name matched against the TLS SNI of native QUIC
+	// connections and the host of WebTransport requests, case-insensitively.
+	// A leading "*." matches any subdomain. If empty, any host matches.
+	Host string
+
+	// Path is the request path prefix matched for WebTransport sessions.
+	// If empty, any path matches. Native QUIC connections carry no path, so
+	// a VirtualHost with a non-empty Path only serves WebTransport sessions.
+	Path string
+
+	// Certificates are presented to clients whose SNI matches Host.
+	// If empty, the Server's TLS configuration is used.
+	Certificates []tls.Certificate
+
+	// Config is the MOQ configuration for sessions of this virtual host.
+	Config *Config
+
+	// TrackMux routes announcements and subscriptions of this virtual host.
+	TrackMux *TrackMux
+
+	// Handler serves sessions of this virtual host.
+	Handler Handler
+
+	// FetchHandler serves FETCH requests of this virtual host.
+	FetchHandler FetchHandler
+
+	// Logger for events of this virtual host.
+	// If nil, the Server's Logger is used with a "virtual_host" attribute.
+	Logger *slog.Logger
+}
+
+// name returns the label used for the virtual host in logs.
+func (vh *VirtualHost) name() string {
+	if vh.Name != "" {
+		return vh.Name
+	}
+	return vh.Host
+}
+
+// matchHost reports how specifically vh.Host matches host: 3 for an exact
+// match, 2 for a wildcard match, 1 for an empty Host and 0 for no match.
+func (vh *VirtualHost) matchHost(host string) int {
+	switch {
+	case vh.Host == "":
+		return 1
+	case strings.EqualFold(vh.Host, host):
+		return 3
+	case strings.HasPrefix(vh.Host, "*."):
+		suffix := vh.Host[1:]
+		if len(host) > len(suffix) && strings.EqualFold(host[len(host)-len(suffix):], suffix) {
+			return 2
+		}
+	}
+	return 0
+}
+
+// matchPath reports whether vh.Path is a prefix of path on a segment boundary.
+// Native QUIC connections pass an empty path, which only matches an empty Path.
+func (vh *VirtualHost) matchPath(path string) bool {
+	if vh.Path == "" {
+		return true
+	}
+	if !strings.HasPrefix(path, vh.Path) {
+		return false
+	}
+	return len(path) == len(vh.Path) || strings.HasSuffix(vh.Path, "/") || path[len(vh.Path)] == '/'
+}
+
+// virtualHost returns the VirtualHost that best matches host and path: the
+// most specific host match first, then the longest path prefix. It returns
+// nil if no VirtualHost matches.
+func (s *Server) virtualHost(host, path string) *VirtualHost {
+	var (
+		best      *VirtualHost
+		bestScore int
+	)
+	for _, vh := range s.VirtualHosts {
+		if vh == nil || !vh.matchPath(path) {
+			continue
+		}
+		score := vh.matchHost(host)
+		if score == 0 {
+			continue
+		}
+		if best == nil || score > bestScore || (score == bestScore && len(vh.Path) > len(best.Path)) {
+			best = vh
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// virtualHostTarget resolves the session settings for a virtual host,
+// falling back to the Server's fields. A nil vh yields the Server's fields.
+func (s *Server) virtualHostTarget(vh *VirtualHost) *VirtualHost {
+	target := &VirtualHost{
+		Config:       s.Config,
+		TrackMux:     s.TrackMux,
+		Handler:      s.Handler,
+		FetchHandler: s.FetchHandler,
+		Logger:       s.Logger,
+	}
+	if vh == nil {
+		return target
+	}
+
+	target.Name = vh.name()
+	if vh.Config != nil {
+		target.Config = vh.Config
+	}
+	if vh.TrackMux != nil {
+		target.TrackMux = vh.TrackMux
+	}
+	if vh.Handler != nil {
+		target.Handler = vh.Handler
+	}
+	if vh.FetchHandler != nil {
+		target.FetchHandler = vh.FetchHandler
+	}
+	if vh.Logger != nil {
+		target.Logger = vh.Logger
+	} else if s.Logger != nil {
+		target.Logger = s.Logger.With("virtual_host", target.Name)
+	}
+	return target
+}
+
+// virtualHostTLSConfig installs a GetCertificate callback that presents the
+// certificates of the VirtualHost matching the client's SNI. Clients whose
+// SNI matches no VirtualHost with certificates get the original config's
+// certificates.
+func (s *Server) virtualHostTLSConfig(tlsConfig *tls.Config) {
+	hasCerts := false
+	for _, vh := range s.VirtualHosts {
+		if vh != nil && len(vh.Certificates) > 0 {
+			hasCerts = true
+			break
+		}
+	}
+	if !hasCerts {
+		return
+	}
+
+	next := tlsConfig.GetCertificate
+	tlsConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if vh := s.virtualHostForCertificate(hello.ServerName); vh != nil {
+			return &vh.Certificates[0], nil
+		}
+		if next != nil {
+			return next(hello)
+		}
+		return nil, nil
+	}
+}
+
+// virtualHostForCertificate returns the best host match that has certificates.
+func (s *Server) virtualHostForCertificate(serverName string) *VirtualHost {
+	var (
+		best      *VirtualHost
+		bestScore int
+	)
+	for _, vh := range s.VirtualHosts {
+		if vh == nil || len(vh.Certificates) == 0 {
+			continue
+		}
+		if score := vh.matchHost(serverName); score > bestScore {
+			best = vh
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// virtualHostHandler routes WebTransport requests to virtual hosts by host
+// and path. Requests matching no VirtualHost are passed to fallback.
+type virtualHostHandler struct {
+	server   *Server
+	fallback http.Handler
+}
+
+func (h *virtualHostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	vh := h.server.virtualHost(host, r.URL.Path)
+	if vh == nil {
+		h.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	target := h.server.virtualHostTarget(vh)
+	if target.Handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	(&WebTransportHandler{
+		Config:       target.Config,
+		TrackMux:     target.TrackMux,
+		Handler:      target.Handler,
+		FetchHandler: target.FetchHandler,
+		Logger:       target.Logger,
+	}).ServeHTTP(w, r)
+}
@@ -0,0 +1,163 @@
+package moqt
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_virtualHost(t *testing.T) {
+	exact := &VirtualHost{Name: "exact", Host: "live.example.com"}
+	wildcard := &VirtualHost{Name: "wildcard", Host: "*.example.com"}
+	catchAll := &VirtualHost{Name: "catch-all"}
+	tenant := &VirtualHost{Name: "tenant", Host: "live.example.com", Path: "/tenant"}
+	s := &Server{VirtualHosts: []*VirtualHost{catchAll, wildcard, exact, tenant, nil}}
+
+	tests := map[string]struct {
+		host     string
+		path     string
+		expected *VirtualHost
+	}{
+		"exact host":             {host: "live.example.com", expected: exact},
+		"exact host any case":    {host: "LIVE.example.com", expected: exact},
+		"wildcard host":          {host: "vod.example.com", expected: wildcard},
+		"wildcard needs label":   {host: "example.com", expected: catchAll},
+		"unknown host":           {host: "other.org", expected: catchAll},
+		"path prefix":            {host: "live.example.com", path: "/tenant/moq", expected: tenant},
+		"path exact":             {host: "live.example.com", path: "/tenant", expected: tenant},
+		"path segment boundary":  {host: "live.example.com", path: "/tenants", expected: exact},
+		"native quic skips path": {host: "live.example.com", path: "", expected: exact},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, s.virtualHost(tt.host, tt.path))
+		})
+	}
+}
+
+func TestServer_virtualHost_NoMatch(t *testing.T) {
+	s := &Server{VirtualHosts: []*VirtualHost{{Host: "live.example.com"}}}
+
+	assert.Nil(t, s.virtualHost("other.org", ""))
+}
+
+func TestServer_virtualHostTarget_FallsBackToServer(t *testing.T) {
+	serverMux := NewTrackMux(0)
+	hostMux := NewTrackMux(0)
+	s := &Server{
+		TrackMux: serverMux,
+		Config:   &Config{SetupTimeout: 1},
+		Handler:  HandleFunc(func(*Session) {}),
+	}
+
+	target := s.virtualHostTarget(&VirtualHost{Host: "live.example.com", TrackMux: hostMux})
+	assert.Equal(t, "live.example.com", target.Name)
+	assert.Same(t, hostMux, target.TrackMux)
+	assert.Same(t, s.Config, target.Config)
+	assert.NotNil(t, target.Handler)
+
+	target = s.virtualHostTarget(nil)
+	assert.Same(t, serverMux, target.TrackMux)
+}
+
+func TestServer_handleNativeQUIC_RoutesBySNI(t *testing.T) {
+	hostMux := NewTrackMux(0)
+	var (
+		serverCalled bool
+		hostSession  *Session
+	)
+	s := &Server{
+		Handler: HandleFunc(func(*Session) { serverCalled = true }),
+		VirtualHosts: []*VirtualHost{{
+			Host:     "live.example.com",
+			TrackMux: hostMux,
+			Handler:  HandleFunc(func(sess *Session) { hostSession = sess }),
+		}},
+	}
+
+	conn := newTestNativeQUICConn(t, func(conn *FakeStreamConn) {
+		conn.TLSFunc = func() *tls.ConnectionState {
+			return &tls.ConnectionState{NegotiatedProtocol: NextProtoMOQ, ServerName: "live.example.com"}
+		}
+	})
+	_ = s.handleNativeQUIC(conn)
+
+	require.NotNil(t, hostSession)
+	assert.Same(t, hostMux, hostSession.mux)
+	assert.False(t, serverCalled)
+
+	_ = s.handleNativeQUIC(newTestNativeQUICConn(t))
+	assert.True(t, serverCalled)
+}
+
+func TestServer_virtualHostTLSConfig(t *testing.T) {
+	hostCert := tls.Certificate{Certificate: [][]byte{[]byte("host")}}
+	fallbackCert := tls.Certificate{Certificate: [][]byte{[]byte("fallback")}}
+	s := &Server{VirtualHosts: []*VirtualHost{
+		{Host: "live.example.com", Certificates: []tls.Certificate{hostCert}},
+		{Host: "other.example.com"},
+	}}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &fallbackCert, nil },
+	}
+	s.virtualHostTLSConfig(tlsConfig)
+
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "live.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, hostCert.Certificate, cert.Certificate)
+
+	cert, err = tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, fallbackCert.Certificate, cert.Certificate)
+}
+
+func TestServer_virtualHostTLSConfig_NoCertificates(t *testing.T) {
+	s := &Server{VirtualHosts: []*VirtualHost{{Host: "live.example.com"}}}
+
+	tlsConfig := &tls.Config{}
+	s.virtualHostTLSConfig(tlsConfig)
+	assert.Nil(t, tlsConfig.GetCertificate)
+}
+
+func TestVirtualHostHandler_ServeHTTP(t *testing.T) {
+	fallbackCalled := false
+	s := &Server{VirtualHosts: []*VirtualHost{
+		{Host: "live.example.com", Path: "/moq", Handler: HandleFunc(func(*Session) {})},
+		{Host: "idle.example.com"},
+	}}
+	h := &virtualHostHandler{
+		server: s,
+		fallback: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fallbackCalled = true
+		}),
+	}
+
+	// Unmatched requests go to the fallback handler.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "https://other.org/moq", nil))
+	assert.True(t, fallbackCalled)
+
+	// Virtual hosts without a handler respond with 404.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://idle.example.com:443/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Matched requests are upgraded by the virtual host; plain HTTP is rejected.
+	fallbackCalled = false
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://live.example.com:443/moq", nil))
+	assert.False(t, fallbackCalled)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_Init_VirtualHosts(t *testing.T) {
+	s := &Server{VirtualHosts: []*VirtualHost{{Host: "live.example.com"}}}
+	s.init()
+
+	assert.NotNil(t, s.WebTransportServer)
+}
@@ -0,0 +1,572 @@
+package msf
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+const (
+	// defaultABRInterval is how often an ABRController samples delivery.
+	defaultABRInterval = time.Second
+
+	// abrSmoothing is the weight of the latest sample in the throughput
+	// estimate of an ABRController.
+	abrSmoothing = 0.3
+)
+
+// ErrABRClosed is returned by ABRController.AcceptGroup once the controller
+// is closed.
+var ErrABRClosed = errors.New("msf: ABR controller closed")
+
+// ABRState is what an ABRAlgorithm decides on.
+type ABRState struct {
+	// Renditions are the renditions to choose from, by ascending bitrate.
+	Renditions []Track
+
+	// Current is the index in Renditions of the rendition subscribed to.
+	Current int
+
+	// Throughput is the estimated delivery rate of the subscription, in
+	// bits per second. It is zero until the first sample, unless
+	// ABRConfig.InitialThroughput is set.
+	Throughput int64
+
+	// Bandwidth is the bandwidth available, in bits per second, as
+	// reported by ABRConfig.Bandwidth, or zero if it is not set.
+	Bandwidth int64
+
+	// QueuedGroups is the number of groups received and not yet accepted.
+	QueuedGroups int
+
+	// Buffer is the media buffered ahead of playback, as reported by
+	// ABRConfig.Buffer, or zero if it is not set.
+	Buffer time.Duration
+}
+
+// ABRAlgorithm decides which rendition an ABRController subscribes to.
+type ABRAlgorithm interface {
+	// Select returns the index in state.Renditions of the rendition to
+	// subscribe to. Returning state.Current keeps the subscription.
+	Select(state ABRState) int
+}
+
+// ABRAlgorithmFunc is an adapter to allow the use of ordinary functions as
+// ABRAlgorithms.
+type ABRAlgorithmFunc func(state ABRState) int
+
+// Select calls f(state).
+func (f ABRAlgorithmFunc) Select(state ABRState) int {
+	return f(state)
+}
+
+// ThroughputRule is the default ABRAlgorithm. It steps down when the
+// delivery rate falls below the bitrate of the current rendition, or when
+// groups pile up in the queue, and steps up to the highest rendition whose
+// bitrate fits the throughput or the bandwidth, while enough media is
+// buffered.
+//
+// A live track is delivered at the bitrate of its rendition, so that the
+// delivery rate tells when to step down but rarely when to step up; that
+// takes an estimate of the bandwidth, as ABRConfig.Bandwidth provides.
+type ThroughputRule struct {
+	// Safety is the share of the throughput or bandwidth renditions may
+	// use. If zero, defaults to 0.8.
+	Safety float64
+
+	// MinBuffer is the buffer below which the rule does not step up. It
+	// only applies if ABRConfig.Buffer is set.
+	MinBuffer time.Duration
+
+	// MaxQueuedGroups is the number of queued groups above which the rule
+	// steps down. If zero, defaults to 2.
+	MaxQueuedGroups int
+}
+
+// Select implements ABRAlgorithm.
+func (r ThroughputRule) Select(state ABRState) int {
+	safety := r.Safety
+	if safety <= 0 {
+		safety = 0.8
+	}
+	maxQueued := r.MaxQueuedGroups
+	if maxQueued <= 0 {
+		maxQueued = 2
+	}
+
+	if len(state.Renditions) == 0 {
+		return 0
+	}
+
+	// fit returns the highest rendition whose bitrate fits rate.
+	fit := func(rate int64) int {
+		budget := int64(float64(rate) * safety)
+		target := 0
+		for i, track := range state.Renditions {
+			if valueOrZero(track.Bitrate) <= budget {
+				target = i
+			}
+		}
+		return target
+	}
+
+	if state.QueuedGroups > maxQueued {
+		return max(state.Current-1, 0)
+	}
+	bitrate := valueOrZero(state.Renditions[state.Current].Bitrate)
+	if state.Throughput > 0 && float64(state.Throughput) < float64(bitrate)*safety {
+		return min(fit(state.Throughput), max(state.Current-1, 0))
+	}
+	if target := fit(max(state.Throughput, state.Bandwidth)); target > state.Current && state.Buffer >= r.MinBuffer {
+		return target
+	}
+	return state.Current
+}
+
+// ABRConfig configures an ABRController.
+type ABRConfig struct {
+	// Algorithm decides which rendition to subscribe to. If nil, a
+	// ThroughputRule with its defaults is used.
+	Algorithm ABRAlgorithm
+
+	// Interval is how often delivery is sampled and Algorithm consulted.
+	// If zero, defaults to one second.
+	Interval time.Duration
+
+	// InitialThroughput is the throughput, in bits per second, the first
+	// rendition is selected for, such as measured by
+	// moqt.Session.ProbeBandwidth. If zero, the lowest rendition is first.
+	InitialThroughput int64
+
+	// Buffer, if set, returns the media buffered ahead of playback by the
+	// player, which is passed to Algorithm as ABRState.Buffer.
+	Buffer func() time.Duration
+
+	// Bandwidth, if set, returns an estimate of the bandwidth available,
+	// in bits per second, which is passed to Algorithm as
+	// ABRState.Bandwidth, such as the SessionStats.EstimatedBitrate of the
+	// session.
+	Bandwidth func() int64
+}
+
+func (c *ABRConfig) algorithm() ABRAlgorithm {
+	if c.Algorithm != nil {
+		return c.Algorithm
+	}
+	return ThroughputRule{}
+}
+
+func (c *ABRConfig) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultABRInterval
+}
+
+// ABRController adapts the rendition of a subscription to the throughput
+// of its delivery. It subscribes to one of a set of renditions of the same
+// content, such as the tracks of an alternate group published by a
+// Simulcast, samples the moqt.DeliveryStats of the subscription every
+// interval, and switches to the rendition its ABRAlgorithm selects.
+//
+// Switches happen at group boundaries: the next rendition is subscribed to
+// from the group after the last one accepted, the range of the previous
+// subscription is narrowed to end before the first group of the next one,
+// and AcceptGroup returns the groups of the previous rendition until then.
+// The groups returned by AcceptGroup therefore follow each other across
+// switches, as long as the renditions share their group sequences.
+//
+// AcceptGroup must not be called concurrently; the other methods are safe
+// for concurrent use.
+type ABRController struct {
+	subscribe  SubscribeFunc
+	config     ABRConfig
+	renditions []Track
+
+	mu      sync.Mutex
+	current int
+	reader  *moqt.TrackReader
+	pending *abrSwitch
+
+	// throughput is the estimate of the delivery rate, in bits per
+	// second, and lastBytes and lastSample the previous sample of reader.
+	// A zero lastSample marks a new subscription, whose first sample only
+	// sets the baseline.
+	throughput float64
+	lastBytes  uint64
+	lastSample time.Time
+
+	// delivered is the highest group sequence returned by AcceptGroup, and
+	// floor the highest one of the previous rendition: groups of the
+	// current rendition up to it are duplicates.
+	delivered moqt.GroupSequence
+	floor     moqt.GroupSequence
+
+	// first is the first group of the rendition switched to, returned by
+	// the next AcceptGroup.
+	first *moqt.GroupReader
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// abrSwitch is a subscription to the next rendition, whose first group is
+// known once ready is closed.
+type abrSwitch struct {
+	index  int
+	reader *moqt.TrackReader
+	ready  chan struct{}
+
+	// first and err are set before ready is closed.
+	first *moqt.GroupReader
+	err   error
+}
+
+// NewABRController subscribes with subscribe to the rendition of renditions
+// selected for config.InitialThroughput and starts adapting it. Every
+// rendition must have a bitrate. A nil config uses the defaults.
+func NewABRController(ctx context.Context, subscribe SubscribeFunc, renditions []Track, config *ABRConfig) (*ABRController, error) {
+	if subscribe == nil {
+		return nil, errors.New("msf: ABR controller has no subscribe function")
+	}
+	if len(renditions) == 0 {
+		return nil, errors.New("msf: ABR controller has no renditions")
+	}
+	for _, track := range renditions {
+		if track.Bitrate == nil {
+			return nil, fmt.Errorf("msf: rendition %q has no bitrate", track.Name)
+		}
+	}
+	if config == nil {
+		config = &ABRConfig{}
+	}
+
+	sorted := cloneTracks(renditions)
+	slices.SortStableFunc(sorted, func(a, b Track) int {
+		return cmp.Compare(*a.Bitrate, *b.Bitrate)
+	})
+
+	c := &ABRController{
+		subscribe:  subscribe,
+		config:     *config,
+		renditions: sorted,
+		throughput: float64(config.InitialThroughput),
+		done:       make(chan struct{}),
+	}
+	c.current = c.clamp(c.config.algorithm().Select(c.state(moqt.DeliveryStats{}, c.config.inputs())))
+
+	reader, err := subscribe(ctx, sorted[c.current])
+	if err != nil {
+		return nil, err
+	}
+	c.reader = reader
+
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	go c.run()
+	return c, nil
+}
+
+// Current returns the rendition whose groups AcceptGroup returns.
+func (c *ABRController) Current() Track {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.renditions[c.current].Clone()
+}
+
+// Throughput returns the estimated delivery rate, in bits per second.
+func (c *ABRController) Throughput() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int64(c.throughput)
+}
+
+// AcceptGroup returns the next group of the rendition subscribed to, and
+// that rendition. When it returns the first group of a new rendition, the
+// subscription to the previous one is closed, which cancels its groups:
+// a group must be read before the next one is accepted.
+func (c *ABRController) AcceptGroup(ctx context.Context) (*moqt.GroupReader, Track, error) {
+	for {
+		c.mu.Lock()
+		if c.ctx.Err() != nil {
+			c.mu.Unlock()
+			return nil, Track{}, ErrABRClosed
+		}
+		reader, pending, floor := c.reader, c.pending, c.floor
+		first := c.first
+		c.first = nil
+		c.mu.Unlock()
+
+		if first != nil {
+			if first.GroupSequence() <= floor {
+				first.CancelRead(moqt.SubscribeCanceledErrorCode)
+				continue
+			}
+			return c.deliver(first)
+		}
+
+		// Switch once the groups before the next rendition are delivered.
+		if pending != nil && isReady(pending) {
+			if pending.err != nil {
+				c.abandon(pending)
+				continue
+			}
+			c.mu.Lock()
+			delivered := c.delivered
+			c.mu.Unlock()
+			if delivered+1 >= pending.first.GroupSequence() {
+				c.switchTo(pending)
+				continue
+			}
+		}
+
+		acceptCtx, cancel := context.WithCancel(ctx)
+		if pending != nil {
+			go func() {
+				select {
+				case <-pending.ready:
+					if pending.err != nil {
+						cancel()
+						return
+					}
+					// Wait for the rest of the previous rendition, but
+					// not past an interval.
+					select {
+					case <-time.After(c.config.interval()):
+						cancel()
+					case <-acceptCtx.Done():
+					}
+				case <-acceptCtx.Done():
+				}
+			}()
+		}
+		group, err := reader.AcceptGroup(acceptCtx)
+		cancel()
+
+		if err != nil {
+			switch {
+			case ctx.Err() != nil:
+				return nil, Track{}, ctx.Err()
+			case c.ctx.Err() != nil:
+				return nil, Track{}, ErrABRClosed
+			case pending == nil:
+				return nil, Track{}, err
+			}
+			select {
+			case <-pending.ready:
+			case <-ctx.Done():
+				return nil, Track{}, ctx.Err()
+			}
+			if pending.err != nil {
+				c.abandon(pending)
+				continue
+			}
+			// The previous rendition ended or took too long.
+			c.switchTo(pending)
+			continue
+		}
+
+		seq := group.GroupSequence()
+		if seq <= floor {
+			group.CancelRead(moqt.SubscribeCanceledErrorCode)
+			continue
+		}
+		if pending != nil && isReady(pending) && pending.err == nil && seq >= pending.first.GroupSequence() {
+			group.CancelRead(moqt.SubscribeCanceledErrorCode)
+			c.switchTo(pending)
+			continue
+		}
+		return c.deliver(group)
+	}
+}
+
+// deliver returns group, of the current rendition.
+func (c *ABRController) deliver(group *moqt.GroupReader) (*moqt.GroupReader, Track, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delivered = max(c.delivered, group.GroupSequence())
+	return group, c.renditions[c.current].Clone(), nil
+}
+
+// Close stops adapting and closes the subscriptions.
+func (c *ABRController) Close() error {
+	c.cancel()
+	<-c.done
+
+	c.mu.Lock()
+	reader, pending := c.reader, c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if pending != nil {
+		<-pending.ready
+		if pending.first != nil {
+			pending.first.CancelRead(moqt.SubscribeCanceledErrorCode)
+		}
+		if pending.reader != nil {
+			_ = pending.reader.Close()
+		}
+	}
+	return reader.Close()
+}
+
+// run samples delivery every interval until the controller is closed.
+func (c *ABRController) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.config.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.sample(now)
+		}
+	}
+}
+
+// sample updates the throughput estimate and starts a switch if the
+// algorithm selects another rendition.
+func (c *ABRController) sample(now time.Time) {
+	inputs := c.config.inputs()
+
+	c.mu.Lock()
+	stats := c.reader.DeliveryStats()
+	last := c.lastSample
+	if elapsed := now.Sub(last).Seconds(); !last.IsZero() && elapsed > 0 {
+		rate := float64(stats.Bytes-c.lastBytes) * 8 / elapsed
+		if c.throughput == 0 {
+			c.throughput = rate
+		} else {
+			c.throughput += abrSmoothing * (rate - c.throughput)
+		}
+	}
+	c.lastBytes, c.lastSample = stats.Bytes, now
+
+	if last.IsZero() || c.pending != nil {
+		c.mu.Unlock()
+		return
+	}
+	target := c.clamp(c.config.algorithm().Select(c.state(stats, inputs)))
+	if target == c.current {
+		c.mu.Unlock()
+		return
+	}
+	pending := &abrSwitch{index: target, ready: make(chan struct{})}
+	c.pending = pending
+	start := c.delivered + 1
+	c.mu.Unlock()
+
+	go c.prepare(pending, start)
+}
+
+// prepare subscribes to the rendition of p from start and waits for its
+// first group. The range of the current subscription is then narrowed to
+// end before it.
+func (c *ABRController) prepare(p *abrSwitch, start moqt.GroupSequence) {
+	defer close(p.ready)
+
+	reader, err := c.subscribe(c.ctx, c.renditions[p.index])
+	if err == nil {
+		p.reader = reader
+		// Subscribing from the next group is a hint; later groups are
+		// accepted too.
+		_ = reader.UpdateRange(start, moqt.MinGroupSequence)
+		p.first, err = reader.AcceptGroup(c.ctx)
+	}
+	if err != nil {
+		p.err = err
+		return
+	}
+
+	c.mu.Lock()
+	current := c.reader
+	c.mu.Unlock()
+	if end := p.first.GroupSequence() - 1; end > moqt.MinGroupSequence {
+		config := current.TrackConfig()
+		if config.StartGroup <= end {
+			_ = current.UpdateRange(config.StartGroup, end)
+		}
+	}
+}
+
+// switchTo makes the rendition of p current, closing the previous
+// subscription, and queues its first group to be returned by AcceptGroup.
+func (c *ABRController) switchTo(p *abrSwitch) {
+	c.mu.Lock()
+	previous := c.reader
+	c.reader = p.reader
+	c.current = p.index
+	c.pending = nil
+	c.floor = c.delivered
+	c.lastBytes, c.lastSample = 0, time.Time{}
+	c.first = p.first
+	c.mu.Unlock()
+
+	_ = previous.Close()
+}
+
+// abandon gives up the switch of p, which failed.
+func (c *ABRController) abandon(p *abrSwitch) {
+	c.mu.Lock()
+	if c.pending == p {
+		c.pending = nil
+	}
+	c.mu.Unlock()
+	if p.reader != nil {
+		_ = p.reader.Close()
+	}
+}
+
+// abrInputs are the measures of the player and the session passed to the
+// algorithm, taken without holding the lock of the controller.
+type abrInputs struct {
+	buffer    time.Duration
+	bandwidth int64
+}
+
+func (c *ABRConfig) inputs() abrInputs {
+	var in abrInputs
+	if c.Buffer != nil {
+		in.buffer = c.Buffer()
+	}
+	if c.Bandwidth != nil {
+		in.bandwidth = c.Bandwidth()
+	}
+	return in
+}
+
+// state returns the state passed to the algorithm. c.mu must be held once
+// the controller is running.
+func (c *ABRController) state(stats moqt.DeliveryStats, in abrInputs) ABRState {
+	return ABRState{
+		Renditions:   c.renditions,
+		Current:      c.current,
+		Throughput:   int64(c.throughput),
+		Bandwidth:    in.bandwidth,
+		QueuedGroups: stats.QueuedGroups,
+		Buffer:       in.buffer,
+	}
+}
+
+// clamp returns i bounded to the indexes of the renditions.
+func (c *ABRController) clamp(i int) int {
+	return min(max(i, 0), len(c.renditions)-1)
+}
+
+// isReady reports whether the first group of p is known.
+func isReady(p *abrSwitch) bool {
+	select {
+	case <-p.ready:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,201 @@
+package msf
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func abrRenditions() []Track {
+	return []Track{
+		{Name: "video-hd", Packaging: PackagingLOC, IsLive: new(true), Bitrate: new(int64(4_000_000))},
+		{Name: "video-sd", Packaging: PackagingLOC, IsLive: new(true), Bitrate: new(int64(1_000_000))},
+		{Name: "video-ld", Packaging: PackagingLOC, IsLive: new(true), Bitrate: new(int64(300_000))},
+	}
+}
+
+func TestThroughputRule(t *testing.T) {
+	ladder := []Track{
+		{Bitrate: new(int64(300_000))},
+		{Bitrate: new(int64(1_000_000))},
+		{Bitrate: new(int64(4_000_000))},
+	}
+
+	tests := map[string]struct {
+		rule  ThroughputRule
+		state ABRState
+		want  int
+	}{
+		"no measure": {
+			state: ABRState{Current: 1},
+			want:  1,
+		},
+		"keeps while delivered": {
+			state: ABRState{Current: 1, Throughput: 1_000_000},
+			want:  1,
+		},
+		"steps down below bitrate": {
+			state: ABRState{Current: 2, Throughput: 1_500_000},
+			want:  1,
+		},
+		"steps down to fit": {
+			state: ABRState{Current: 2, Throughput: 400_000},
+			want:  0,
+		},
+		"steps down when queued": {
+			state: ABRState{Current: 2, Throughput: 4_000_000, QueuedGroups: 3},
+			want:  1,
+		},
+		"steps up to bandwidth": {
+			state: ABRState{Current: 0, Throughput: 300_000, Bandwidth: 6_000_000},
+			want:  2,
+		},
+		"safety margin": {
+			state: ABRState{Current: 0, Throughput: 300_000, Bandwidth: 4_000_000},
+			want:  1,
+		},
+		"buffer too low to step up": {
+			rule:  ThroughputRule{MinBuffer: 2 * time.Second},
+			state: ABRState{Current: 0, Throughput: 300_000, Bandwidth: 6_000_000, Buffer: time.Second},
+			want:  0,
+		},
+		"buffer high enough": {
+			rule:  ThroughputRule{MinBuffer: 2 * time.Second},
+			state: ABRState{Current: 0, Throughput: 300_000, Bandwidth: 6_000_000, Buffer: 3 * time.Second},
+			want:  2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.state.Renditions = ladder
+			assert.Equal(t, tt.want, tt.rule.Select(tt.state))
+		})
+	}
+}
+
+func TestNewABRController_RejectsInvalidInput(t *testing.T) {
+	subscribe := func(context.Context, Track) (*moqt.TrackReader, error) { return nil, nil }
+
+	tests := map[string]struct {
+		subscribe  SubscribeFunc
+		renditions []Track
+	}{
+		"no subscribe function": {renditions: abrRenditions()},
+		"no renditions":         {subscribe: subscribe},
+		"no bitrate":            {subscribe: subscribe, renditions: []Track{{Name: "video"}}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewABRController(t.Context(), tt.subscribe, tt.renditions, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestABRController_SwitchesAtGroupBoundaries(t *testing.T) {
+	b, err := NewBroadcast(Catalog{Version: 1})
+	require.NoError(t, err)
+	s, err := NewSimulcast(b, nil, abrRenditions()...)
+	require.NoError(t, err)
+	defer s.Close()
+
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live", b)
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+
+	// Every frame starts a group on every rendition.
+	go func() {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for ts := time.Duration(0); ; ts += 5 * time.Millisecond {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for _, track := range abrRenditions() {
+				frame := moqt.NewFrame(0)
+				_, _ = frame.Write([]byte(track.Name))
+				if s.WriteFrame(moqt.TrackName(track.Name), ts, true, frame) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var target atomic.Int64
+	c, err := NewABRController(ctx, func(ctx context.Context, track Track) (*moqt.TrackReader, error) {
+		return sess.Subscribe(ctx, "/live", moqt.TrackName(track.Name), nil)
+	}, abrRenditions(), &ABRConfig{
+		Interval: 10 * time.Millisecond,
+		Algorithm: ABRAlgorithmFunc(func(ABRState) int {
+			return int(target.Load())
+		}),
+	})
+	require.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, "video-ld", c.Current().Name, "renditions are sorted by bitrate")
+
+	seen := make(map[moqt.GroupSequence]bool)
+	var switches []string
+	for _, step := range []int64{0, 2, 1} {
+		target.Store(step)
+		want := abrRenditions()[2-step].Name
+		for count := 0; count < 3; {
+			gr, track, err := c.AcceptGroup(ctx)
+			require.NoError(t, err)
+			frame := moqt.NewFrame(0)
+			require.NoError(t, gr.ReadFrame(frame))
+			assert.Equal(t, track.Name, string(frame.Body()), "the group must be of the returned rendition")
+
+			seq := gr.GroupSequence()
+			assert.False(t, seen[seq], "group %d delivered twice", seq)
+			seen[seq] = true
+			if len(switches) == 0 || switches[len(switches)-1] != track.Name {
+				switches = append(switches, track.Name)
+			}
+			if track.Name == want {
+				count++
+			}
+		}
+	}
+	assert.Equal(t, "video-sd", c.Current().Name)
+	assert.Equal(t, "video-ld,video-hd,video-sd", strings.Join(switches, ","))
+}
+
+func TestABRController_Close(t *testing.T) {
+	b, err := NewBroadcast(Catalog{Version: 1})
+	require.NoError(t, err)
+	s, err := NewSimulcast(b, nil, abrRenditions()...)
+	require.NoError(t, err)
+	defer s.Close()
+
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live", b)
+	sess := moqttest.DialMux(t, mux)
+
+	c, err := NewABRController(t.Context(), func(ctx context.Context, track Track) (*moqt.TrackReader, error) {
+		return sess.Subscribe(ctx, "/live", moqt.TrackName(track.Name), nil)
+	}, abrRenditions(), &ABRConfig{InitialThroughput: 10_000_000})
+	require.NoError(t, err)
+	assert.Equal(t, "video-hd", c.Current().Name, "the initial throughput selects the rendition")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = c.Close()
+	}()
+	_, _, err = c.AcceptGroup(t.Context())
+	assert.ErrorIs(t, err, ErrABRClosed)
+}
@@ -28,6 +28,9 @@ type Broadcast struct {
 	catalogTrackName moqt.TrackName
 	catalog          Catalog
 	tracks           moqt.Broadcast
+
+	// changed is closed and replaced when the catalog changes.
+	changed chan struct{}
 }
 
 // initLocked initializes lazily allocated broadcast defaults while b.mu is held.
@@ -35,6 +38,17 @@ func (b *Broadcast) initLocked() {
 	if b.catalogTrackName == "" {
 		b.catalogTrackName = DefaultCatalogTrackName
 	}
+	if b.changed == nil {
+		b.changed = make(chan struct{})
+	}
+}
+
+// setCatalogLocked replaces the catalog snapshot and wakes the catalog track
+// subscribers while b.mu is held.
+func (b *Broadcast) setCatalogLocked(catalog Catalog) {
+	b.catalog = catalog
+	close(b.changed)
+	b.changed = make(chan struct{})
 }
 
 // NewBroadcast constructs a Broadcast using an initial independent catalog.
@@ -107,7 +121,7 @@ func (b *Broadcast) SetCatalog(catalog Catalog) error {
 
 	b.initLocked()
 	staleTrackNames := b.staleTrackNamesLocked(clone)
-	b.catalog = clone
+	b.setCatalogLocked(clone)
 	for _, name := range staleTrackNames {
 		b.tracks.Remove(name)
 	}
@@ -163,7 +177,7 @@ func (b *Broadcast) RegisterTrack(track Track, handler moqt.TrackHandler) error
 		return err
 	}
 
-	b.catalog = updated
+	b.setCatalogLocked(updated)
 	return b.tracks.Register(moqt.TrackName(trackClone.Name), handler)
 }
 
@@ -189,7 +203,9 @@ func (b *Broadcast) RemoveTrack(name moqt.TrackName) bool {
 		}
 	}
 
-	b.catalog = updated
+	if removed {
+		b.setCatalogLocked(updated)
+	}
 	removedFromTracks := b.tracks.Remove(name)
 
 	return removed || removedFromTracks
@@ -210,6 +226,37 @@ func (b *Broadcast) Handler(name moqt.TrackName) moqt.TrackHandler {
 	return b.tracks.Handler(name)
 }
 
+// DeclareTracks declares the metadata of every track of the current catalog
+// snapshot on ann, so that subscribers can choose a rendition before
+// subscribing to the catalog. It must be called before ann is announced.
+func (b *Broadcast) DeclareTracks(ann *moqt.Announcement) {
+	if b == nil || ann == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, track := range b.catalog.Tracks {
+		ann.SetTrackMetadata(moqt.TrackName(track.Name), track.Metadata())
+	}
+}
+
+// Metadata returns the announcement metadata of the track: its bitrate and
+// resolution. MSF does not describe keyframe intervals, so the
+// KeyframeInterval of the result is zero.
+func (t Track) Metadata() moqt.TrackMetadata {
+	var md moqt.TrackMetadata
+	if t.Bitrate != nil && *t.Bitrate > 0 {
+		md.Bitrate = uint64(*t.Bitrate)
+	}
+	if t.Width != nil && *t.Width > 0 {
+		md.Width = uint64(*t.Width)
+	}
+	if t.Height != nil && *t.Height > 0 {
+		md.Height = uint64(*t.Height)
+	}
+	return md
+}
+
 // ServeTrack implements the moqt.TrackHandler interface by dispatching the
 // incoming TrackWriter to the handler determined by Handler().  This allows a
 // Broadcast value to be published directly without additional wrappers.
@@ -220,32 +267,62 @@ func (b *Broadcast) ServeTrack(tw *moqt.TrackWriter) {
 	b.Handler(tw.TrackName).ServeTrack(tw)
 }
 
-// serveCatalogTrack serializes the current catalog snapshot onto the reserved catalog track.
+// serveCatalogTrack serializes the current catalog snapshot onto the reserved
+// catalog track and writes a new group each time the catalog changes until
+// the subscription ends. Changes are written as catalog deltas when a delta
+// can express them, and as independent catalogs otherwise.
 func (b *Broadcast) serveCatalogTrack(tw *moqt.TrackWriter) {
-	payload, err := b.CatalogBytes()
-	if err != nil {
-		tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
-		return
+	var (
+		sent    Catalog
+		started bool
+	)
+	for {
+		b.mu.Lock()
+		b.initLocked()
+		catalog, changed := b.catalog.Clone(), b.changed
+		b.mu.Unlock()
+
+		var (
+			payload []byte
+			err     error
+		)
+		if delta, ok := sent.Diff(catalog); started && ok {
+			payload, err = delta.MarshalJSON()
+		} else {
+			payload, err = catalog.MarshalJSON()
+		}
+		if err != nil {
+			tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
+			return
+		}
+		if err := writeCatalogGroup(tw, payload); err != nil {
+			tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
+			return
+		}
+		sent, started = catalog, true
+
+		select {
+		case <-changed:
+		case <-tw.Context().Done():
+			return
+		}
 	}
+}
 
+// writeCatalogGroup writes payload as the single frame of a new group.
+func writeCatalogGroup(tw *moqt.TrackWriter, payload []byte) error {
 	group, err := tw.OpenGroup()
 	if err != nil {
-		tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
-		return
+		return err
 	}
 
 	frame := moqt.NewFrame(len(payload))
 	_, _ = frame.Write(payload)
 	if err := group.WriteFrame(frame); err != nil {
 		group.CancelWrite(moqt.InternalGroupErrorCode)
-		tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
-		return
-	}
-	if err := group.Close(); err != nil {
-		tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
-		return
+		return err
 	}
-	_ = tw.Close()
+	return group.Close()
 }
 
 // validateTrackHandler performs a small sanity check on a TrackHandler
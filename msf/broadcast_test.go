@@ -1,6 +1,7 @@
 package msf
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"testing"
@@ -397,3 +398,25 @@ func TestBroadcastRegisterTrack_RejectsDuplicateTrackNamesAcrossNamespaces(t *te
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "unique track names across namespaces")
 }
+
+func TestBroadcastDeclareTracks_SetsAnnouncementMetadata(t *testing.T) {
+	broadcast, err := NewBroadcast(Catalog{
+		Version: 1,
+		Tracks: []Track{
+			{Name: "hd", Packaging: PackagingLOC, IsLive: new(true), Bitrate: new(int64(3_000_000)), Width: new(int64(1280)), Height: new(int64(720))},
+			{Name: "audio", Packaging: PackagingLOC, IsLive: new(true)},
+		},
+	})
+	require.NoError(t, err)
+
+	ann, _ := moqt.NewAnnouncement(context.Background(), "/live")
+	broadcast.DeclareTracks(ann)
+
+	assert.Equal(t, []moqt.TrackName{"audio", "hd"}, ann.TrackNames())
+	md, ok := ann.TrackMetadata("hd")
+	require.True(t, ok)
+	assert.Equal(t, moqt.TrackMetadata{Bitrate: 3_000_000, Width: 1280, Height: 720}, md)
+	md, ok = ann.TrackMetadata("audio")
+	require.True(t, ok)
+	assert.True(t, md.IsZero())
+}
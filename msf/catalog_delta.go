@@ -1,6 +1,7 @@
 package msf
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"slices"
@@ -346,6 +347,82 @@ func (c *TrackClone) UnmarshalJSON(data []byte) error {
 	return c.Track.unmarshalObject(trackRaw)
 }
 
+// Diff returns the delta that updates the receiver to updated, adding the
+// tracks of updated that the receiver lacks and removing the tracks that
+// updated lacks. Applying the delta with ApplyDelta yields updated, except
+// that added tracks are placed after the existing ones.
+//
+// It returns false if no valid delta expresses the update, so that updated
+// must be sent as an independent catalog: when the catalogs differ in
+// version or default namespace, when a track present in both changed, when
+// generatedAt, isComplete or an extension field was cleared, or when no
+// track was added or removed.
+func (c Catalog) Diff(updated Catalog) (CatalogDelta, bool) {
+	if c.Version != updated.Version || c.DefaultNamespace != updated.DefaultNamespace {
+		return CatalogDelta{}, false
+	}
+
+	var delta CatalogDelta
+	switch {
+	case updated.GeneratedAt == nil && c.GeneratedAt != nil:
+		return CatalogDelta{}, false
+	case updated.GeneratedAt != nil && (c.GeneratedAt == nil || *c.GeneratedAt != *updated.GeneratedAt):
+		delta.GeneratedAt = cloneInt64Ptr(updated.GeneratedAt)
+	}
+	if c.IsComplete && !updated.IsComplete {
+		return CatalogDelta{}, false
+	}
+	delta.IsComplete = updated.IsComplete && !c.IsComplete
+	for key := range c.ExtraFields {
+		if _, ok := updated.ExtraFields[key]; !ok {
+			return CatalogDelta{}, false
+		}
+	}
+	for key, raw := range updated.ExtraFields {
+		if !bytes.Equal(c.ExtraFields[key], raw) {
+			if delta.ExtraFields == nil {
+				delta.ExtraFields = make(map[string]json.RawMessage)
+			}
+			delta.ExtraFields[key] = cloneRawMessage(raw)
+		}
+	}
+
+	for _, track := range c.Tracks {
+		id := track.ID(c.DefaultNamespace)
+		next, _, ok := updated.findTrack(id)
+		if !ok {
+			delta.RemoveTracks = append(delta.RemoveTracks, TrackRef{Namespace: track.Namespace, Name: track.Name})
+			continue
+		}
+		if !sameTrack(track, next) {
+			return CatalogDelta{}, false
+		}
+	}
+	for _, track := range updated.Tracks {
+		if _, _, ok := c.findTrack(track.ID(updated.DefaultNamespace)); !ok {
+			delta.AddTracks = append(delta.AddTracks, track.Clone())
+		}
+	}
+
+	if len(delta.AddTracks) == 0 && len(delta.RemoveTracks) == 0 {
+		return CatalogDelta{}, false
+	}
+	return delta, true
+}
+
+// sameTrack reports whether a and b encode to the same JSON object.
+func sameTrack(a, b Track) bool {
+	aj, err := a.MarshalJSON()
+	if err != nil {
+		return false
+	}
+	bj, err := b.MarshalJSON()
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aj, bj)
+}
+
 // cloneTrackRefs returns a deep copy of a TrackRef slice.
 func cloneTrackRefs(in []TrackRef) []TrackRef {
 	if in == nil {
@@ -1415,3 +1415,82 @@ func TestBroadcast_SetCatalog_KeepsActiveRemovesStale(t *testing.T) {
 	assert.Len(t, catalog.Tracks, 1)
 	assert.Equal(t, "video", catalog.Tracks[0].Name)
 }
+
+func TestCatalogDiff(t *testing.T) {
+	video := Track{Name: "video", Packaging: PackagingLOC, IsLive: new(true), Bitrate: new(int64(3_000_000))}
+	audio := Track{Name: "audio", Packaging: PackagingLOC, IsLive: new(true)}
+	subtitle := Track{Name: "subtitle", Packaging: PackagingLOC, IsLive: new(true)}
+	base := Catalog{Version: 1, Tracks: []Track{video, audio}}
+
+	tests := map[string]struct {
+		updated Catalog
+		wantOK  bool
+		want    CatalogDelta
+	}{
+		"add track": {
+			updated: Catalog{Version: 1, Tracks: []Track{video, audio, subtitle}},
+			wantOK:  true,
+			want:    CatalogDelta{AddTracks: []Track{subtitle}},
+		},
+		"remove track": {
+			updated: Catalog{Version: 1, Tracks: []Track{audio}},
+			wantOK:  true,
+			want:    CatalogDelta{RemoveTracks: []TrackRef{{Name: "video"}}},
+		},
+		"add and remove with metadata": {
+			updated: Catalog{Version: 1, GeneratedAt: new(int64(42)), IsComplete: true, Tracks: []Track{video, subtitle}},
+			wantOK:  true,
+			want: CatalogDelta{
+				GeneratedAt:  new(int64(42)),
+				IsComplete:   true,
+				AddTracks:    []Track{subtitle},
+				RemoveTracks: []TrackRef{{Name: "audio"}},
+			},
+		},
+		"changed track": {
+			updated: Catalog{Version: 1, Tracks: []Track{
+				{Name: "video", Packaging: PackagingLOC, IsLive: new(true), Bitrate: new(int64(1_000_000))},
+				audio,
+			}},
+		},
+		"changed version": {
+			updated: Catalog{Version: 2, Tracks: []Track{video, audio, subtitle}},
+		},
+		"changed default namespace": {
+			updated: Catalog{Version: 1, DefaultNamespace: "ns", Tracks: []Track{video, audio, subtitle}},
+		},
+		"no track change": {
+			updated: Catalog{Version: 1, GeneratedAt: new(int64(42)), Tracks: []Track{video, audio}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			delta, ok := base.Diff(tt.updated)
+			require.Equal(t, tt.wantOK, ok)
+			if !ok {
+				return
+			}
+			raw, err := delta.MarshalJSON()
+			require.NoError(t, err)
+			want, err := tt.want.MarshalJSON()
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(raw))
+
+			// The delta survives encoding and updates the base catalog.
+			decoded, err := ParseCatalogDelta(raw)
+			require.NoError(t, err)
+			applied, err := base.ApplyDelta(decoded)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, trackNames(tt.updated.Tracks), trackNames(applied.Tracks))
+			assert.Equal(t, tt.updated.IsComplete, applied.IsComplete)
+			assert.Equal(t, tt.updated.GeneratedAt, applied.GeneratedAt)
+		})
+	}
+
+	// A delta cannot clear an extension field.
+	withExt := base.Clone()
+	withExt.ExtraFields = map[string]json.RawMessage{"x-ext": json.RawMessage(`1`)}
+	_, ok := withExt.Diff(Catalog{Version: 1, Tracks: []Track{video, audio, subtitle}})
+	assert.False(t, ok)
+}
@@ -0,0 +1,157 @@
+package msf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// ErrNoBaseCatalog is returned by CatalogWatcher.Next when a catalog delta
+// arrives before any independent catalog it could apply to.
+var ErrNoBaseCatalog = errors.New("msf: catalog delta received before an independent catalog")
+
+// ErrCatalogConflict is returned by CatalogWatcher.Next when a catalog delta
+// does not follow the current catalog: an earlier group was lost or arrived
+// out of order, or the delta does not apply to the catalog.
+var ErrCatalogConflict = errors.New("msf: catalog delta conflicts with the current catalog")
+
+// CatalogUpdate is a catalog received on the catalog track.
+type CatalogUpdate struct {
+	// Catalog is the catalog after the update.
+	Catalog Catalog
+
+	// Delta is the delta the update applied to the previous catalog, or nil
+	// if the publisher sent an independent catalog.
+	Delta *CatalogDelta
+
+	// GroupSequence is the group of the catalog track that carried the
+	// update.
+	GroupSequence moqt.GroupSequence
+}
+
+// CatalogWatcher reads the catalogs and catalog deltas published on a
+// catalog track, such as one served by Broadcast, and keeps the current
+// catalog.
+//
+// A CatalogWatcher is not safe for concurrent use.
+type CatalogWatcher struct {
+	tr    *moqt.TrackReader
+	frame *moqt.Frame
+
+	// catalog is the last catalog received. Deltas are applied to it only
+	// if hasBase is set.
+	catalog Catalog
+	hasBase bool
+
+	// last is the sequence of the last group read, if read is set.
+	last moqt.GroupSequence
+	read bool
+}
+
+// NewCatalogWatcher returns a watcher reading from tr, a subscription to a
+// catalog track.
+func NewCatalogWatcher(tr *moqt.TrackReader) *CatalogWatcher {
+	return &CatalogWatcher{tr: tr, frame: moqt.NewFrame(0)}
+}
+
+// WatchCatalog subscribes to the DefaultCatalogTrackName track of the
+// broadcast at path and returns a watcher of its updates.
+func WatchCatalog(ctx context.Context, sess *moqt.Session, path moqt.BroadcastPath) (*CatalogWatcher, error) {
+	tr, err := sess.Subscribe(ctx, path, DefaultCatalogTrackName, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewCatalogWatcher(tr), nil
+}
+
+// Next waits for the next catalog or catalog delta and returns the updated
+// catalog. Independent catalogs replace the current catalog and deltas are
+// applied to it.
+//
+// A delta applies only to the catalog of the group right before its own.
+// If groups are missing in between, or the delta does not apply, Next
+// returns an error wrapping ErrCatalogConflict; a delta that cannot be
+// decoded returns an error too. In both cases the current catalog is kept
+// but the following deltas return ErrNoBaseCatalog until an independent
+// catalog arrives, such as after subscribing to the catalog track again.
+//
+// Groups older than the last one read, which arrived out of order, are
+// skipped, as the groups after them supersede them. Errors of the
+// subscription, such as the end of the track, are returned as is.
+func (w *CatalogWatcher) Next(ctx context.Context) (CatalogUpdate, error) {
+	var gr *moqt.GroupReader
+	for {
+		var err error
+		gr, err = w.tr.AcceptGroup(ctx)
+		if err != nil {
+			return CatalogUpdate{}, err
+		}
+		if !w.read || gr.GroupSequence() > w.last {
+			break
+		}
+		gr.CancelRead(moqt.InternalGroupErrorCode)
+	}
+	seq := gr.GroupSequence()
+	prev, follows := w.last, w.read && seq == w.last+1
+	w.last, w.read = seq, true
+
+	err := gr.ReadFrame(w.frame)
+	gr.CancelRead(moqt.InternalGroupErrorCode)
+	if err != nil {
+		w.hasBase = false
+		return CatalogUpdate{}, fmt.Errorf("msf: failed to read catalog group %s: %w", seq, err)
+	}
+	data := w.frame.Body()
+
+	var header struct {
+		DeltaUpdate bool `json:"deltaUpdate"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		w.hasBase = false
+		return CatalogUpdate{}, fmt.Errorf("msf: malformed catalog in group %s: %w", seq, err)
+	}
+
+	if !header.DeltaUpdate {
+		catalog, err := ParseCatalog(data)
+		if err == nil {
+			err = catalog.Validate()
+		}
+		if err != nil {
+			return CatalogUpdate{}, fmt.Errorf("msf: invalid catalog in group %s: %w", seq, err)
+		}
+		w.catalog, w.hasBase = catalog, true
+		return CatalogUpdate{Catalog: catalog.Clone(), GroupSequence: seq}, nil
+	}
+
+	if !w.hasBase {
+		return CatalogUpdate{}, ErrNoBaseCatalog
+	}
+	w.hasBase = false
+	if !follows {
+		return CatalogUpdate{}, fmt.Errorf("%w: delta in group %s does not follow group %s", ErrCatalogConflict, seq, prev)
+	}
+	delta, err := ParseCatalogDelta(data)
+	if err != nil {
+		return CatalogUpdate{}, fmt.Errorf("msf: invalid catalog delta in group %s: %w", seq, err)
+	}
+	catalog, err := w.catalog.ApplyDelta(delta)
+	if err != nil {
+		return CatalogUpdate{}, fmt.Errorf("%w: delta in group %s: %w", ErrCatalogConflict, seq, err)
+	}
+	w.catalog, w.hasBase = catalog, true
+	return CatalogUpdate{Catalog: catalog.Clone(), Delta: &delta, GroupSequence: seq}, nil
+}
+
+// Catalog returns a deep copy of the last catalog received, which is empty
+// until the first independent catalog is received.
+func (w *CatalogWatcher) Catalog() Catalog {
+	return w.catalog.Clone()
+}
+
+// Close ends the subscription to the catalog track.
+func (w *CatalogWatcher) Close() error {
+	return w.tr.Close()
+}
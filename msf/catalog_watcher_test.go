@@ -0,0 +1,200 @@
+package msf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchCatalog_Broadcast(t *testing.T) {
+	broadcast, err := NewBroadcast(Catalog{Version: 1})
+	require.NoError(t, err)
+	require.NoError(t, broadcast.RegisterTrack(Track{Name: "video", Packaging: PackagingLOC, IsLive: new(true)}, &FakeTrackHandler{}))
+
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live", broadcast)
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	w, err := WatchCatalog(ctx, sess, "/live")
+	require.NoError(t, err)
+	defer w.Close()
+
+	update, err := w.Next(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, update.Delta)
+	require.Len(t, update.Catalog.Tracks, 1)
+	assert.Equal(t, "video", update.Catalog.Tracks[0].Name)
+
+	// Added and removed tracks are published as deltas.
+	require.NoError(t, broadcast.RegisterTrack(Track{Name: "audio", Packaging: PackagingLOC, IsLive: new(true)}, &FakeTrackHandler{}))
+	next, err := w.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, update.GroupSequence+1, next.GroupSequence)
+	require.NotNil(t, next.Delta)
+	assert.Len(t, next.Delta.AddTracks, 1)
+	assert.Len(t, next.Catalog.Tracks, 2)
+
+	require.True(t, broadcast.RemoveTrack("video"))
+	next, err = w.Next(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, next.Delta)
+	require.Len(t, next.Catalog.Tracks, 1)
+	assert.Equal(t, "audio", next.Catalog.Tracks[0].Name)
+
+	// Changes a delta cannot express are published as catalogs.
+	require.NoError(t, broadcast.RegisterTrack(Track{Name: "audio", Packaging: PackagingLOC, IsLive: new(false)}, &FakeTrackHandler{}))
+	next, err = w.Next(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, next.Delta)
+	require.Len(t, next.Catalog.Tracks, 1)
+	assert.False(t, *next.Catalog.Tracks[0].IsLive)
+}
+
+func TestCatalogWatcher_Next(t *testing.T) {
+	const (
+		video     = `{"version":1,"tracks":[{"name":"video","packaging":"loc","isLive":true}]}`
+		addAudio  = `{"deltaUpdate":true,"addTracks":[{"name":"audio","packaging":"loc","isLive":true}]}`
+		addText   = `{"deltaUpdate":true,"addTracks":[{"name":"text","packaging":"loc","isLive":true}]}`
+		removeBad = `{"deltaUpdate":true,"removeTracks":[{"name":"missing"}]}`
+	)
+	type group struct {
+		seq     moqt.GroupSequence
+		payload string
+	}
+	tests := map[string]struct {
+		groups []group
+		// wantErr lists the error expected from each call to Next, and
+		// wantTracks the number of tracks of the returned catalog otherwise.
+		wantErr    []error
+		wantTracks []int
+	}{
+		"catalog then deltas": {
+			groups:     []group{{0, video}, {1, addAudio}, {2, addText}},
+			wantErr:    []error{nil, nil, nil},
+			wantTracks: []int{1, 2, 3},
+		},
+		"delta before catalog": {
+			groups:     []group{{0, addAudio}, {1, video}, {2, addAudio}},
+			wantErr:    []error{ErrNoBaseCatalog, nil, nil},
+			wantTracks: []int{0, 1, 2},
+		},
+		"missing group": {
+			groups:     []group{{0, video}, {2, addAudio}, {3, addText}, {4, video}, {5, addText}},
+			wantErr:    []error{nil, ErrCatalogConflict, ErrNoBaseCatalog, nil, nil},
+			wantTracks: []int{1, 0, 0, 1, 2},
+		},
+		"delta not applying": {
+			groups:     []group{{0, video}, {1, removeBad}, {2, addAudio}},
+			wantErr:    []error{nil, ErrCatalogConflict, ErrNoBaseCatalog},
+			wantTracks: []int{1, 0, 0},
+		},
+		"malformed group": {
+			groups:     []group{{0, video}, {1, `not json`}, {2, addAudio}},
+			wantErr:    []error{nil, errMalformed, ErrNoBaseCatalog},
+			wantTracks: []int{1, 0, 0},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Groups after the first are written one at a time so that they
+			// arrive in order.
+			write := make(chan struct{})
+			mux := moqt.NewTrackMux(0)
+			mux.PublishFunc(t.Context(), "/live", func(tw *moqt.TrackWriter) {
+				for i, g := range tt.groups {
+					if i > 0 {
+						select {
+						case <-write:
+						case <-tw.Context().Done():
+							return
+						}
+					}
+					if err := writeTestGroup(tw, g.seq, g.payload); err != nil {
+						return
+					}
+				}
+				<-tw.Context().Done()
+			})
+			sess := moqttest.DialMux(t, mux)
+
+			ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+			defer cancel()
+			w, err := WatchCatalog(ctx, sess, "/live")
+			require.NoError(t, err)
+			defer w.Close()
+
+			for i, wantErr := range tt.wantErr {
+				if i > 0 {
+					write <- struct{}{}
+				}
+				update, err := w.Next(ctx)
+				switch wantErr {
+				case nil:
+					require.NoError(t, err, "group %d", i)
+					assert.Equal(t, tt.groups[i].seq, update.GroupSequence)
+					assert.Len(t, update.Catalog.Tracks, tt.wantTracks[i], "group %d", i)
+					assert.Equal(t, update.Catalog.Tracks, w.Catalog().Tracks)
+				case errMalformed:
+					assert.Error(t, err, "group %d", i)
+				default:
+					assert.ErrorIs(t, err, wantErr, "group %d", i)
+				}
+			}
+		})
+	}
+}
+
+// errMalformed marks an expected decoding error in TestCatalogWatcher_Next.
+var errMalformed = errors.New("malformed")
+
+func TestCatalogWatcher_SkipsStaleGroups(t *testing.T) {
+	write := make(chan struct{})
+	mux := moqt.NewTrackMux(0)
+	mux.PublishFunc(t.Context(), "/live", func(tw *moqt.TrackWriter) {
+		_ = writeTestGroup(tw, 5, `{"version":1,"tracks":[{"name":"video","packaging":"loc","isLive":true}]}`)
+		<-write
+		// A delta older than the catalog arrives late.
+		_ = writeTestGroup(tw, 4, `{"deltaUpdate":true,"addTracks":[{"name":"audio","packaging":"loc","isLive":true}]}`)
+		_ = writeTestGroup(tw, 6, `{"deltaUpdate":true,"addTracks":[{"name":"text","packaging":"loc","isLive":true}]}`)
+		<-tw.Context().Done()
+	})
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	w, err := WatchCatalog(ctx, sess, "/live")
+	require.NoError(t, err)
+	defer w.Close()
+
+	update, err := w.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, moqt.GroupSequence(5), update.GroupSequence)
+
+	close(write)
+	update, err = w.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, moqt.GroupSequence(6), update.GroupSequence)
+	assert.ElementsMatch(t, []string{"video", "text"}, trackNames(update.Catalog.Tracks))
+}
+
+func writeTestGroup(tw *moqt.TrackWriter, seq moqt.GroupSequence, payload string) error {
+	group, err := tw.OpenGroupAt(seq)
+	if err != nil {
+		return err
+	}
+	frame := moqt.NewFrame(len(payload))
+	_, _ = frame.Write([]byte(payload))
+	if err := group.WriteFrame(frame); err != nil {
+		return err
+	}
+	return group.Close()
+}
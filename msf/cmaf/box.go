@@ -0,0 +1,107 @@
+package cmaf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrMalformedBox is returned for ISO BMFF data whose boxes cannot be
+// decoded.
+var ErrMalformedBox = errors.New("cmaf: malformed box")
+
+// box is an ISO BMFF box.
+type box struct {
+	typ  string
+	data []byte // the whole box, header included
+	body []byte // the box after its header
+}
+
+// readBox reads the next box from r. It returns io.EOF if r is at the end.
+func readBox(r io.Reader) (box, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:8]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return box{}, fmt.Errorf("%w: truncated header", ErrMalformedBox)
+		}
+		return box{}, err
+	}
+	size := uint64(binary.BigEndian.Uint32(header[:4]))
+	headerLen := 8
+	switch size {
+	case 0:
+		return box{}, fmt.Errorf("%w: boxes extending to the end of the stream are not supported", ErrMalformedBox)
+	case 1:
+		if _, err := io.ReadFull(r, header[8:16]); err != nil {
+			return box{}, fmt.Errorf("%w: truncated header", ErrMalformedBox)
+		}
+		size = binary.BigEndian.Uint64(header[8:16])
+		headerLen = 16
+	}
+	if size < uint64(headerLen) || size > maxBoxSize {
+		return box{}, fmt.Errorf("%w: invalid size %d", ErrMalformedBox, size)
+	}
+
+	data := make([]byte, size)
+	copy(data, header[:headerLen])
+	if _, err := io.ReadFull(r, data[headerLen:]); err != nil {
+		return box{}, fmt.Errorf("%w: truncated %q box", ErrMalformedBox, header[4:8])
+	}
+	return box{typ: string(header[4:8]), data: data, body: data[headerLen:]}, nil
+}
+
+// maxBoxSize bounds the size of the boxes read, so that a corrupted size
+// does not allocate without bound.
+const maxBoxSize = 1 << 30
+
+// parseBoxes splits b into the boxes it holds.
+func parseBoxes(b []byte) ([]box, error) {
+	var boxes []box
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, fmt.Errorf("%w: truncated header", ErrMalformedBox)
+		}
+		size := uint64(binary.BigEndian.Uint32(b[:4]))
+		headerLen := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return nil, fmt.Errorf("%w: truncated header", ErrMalformedBox)
+			}
+			size = binary.BigEndian.Uint64(b[8:16])
+			headerLen = 16
+		}
+		if size < headerLen || size > uint64(len(b)) {
+			return nil, fmt.Errorf("%w: invalid size %d", ErrMalformedBox, size)
+		}
+		boxes = append(boxes, box{typ: string(b[4:8]), data: b[:size], body: b[headerLen:size]})
+		b = b[size:]
+	}
+	return boxes, nil
+}
+
+// child returns the first child box of the given type in body.
+func child(body []byte, typ string) (box, bool) {
+	boxes, err := parseBoxes(body)
+	if err != nil {
+		return box{}, false
+	}
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// firstBoxType returns the type of the first box of b, or "" if b does not
+// start with a box header.
+func firstBoxType(b []byte) string {
+	if len(b) < 8 {
+		return ""
+	}
+	return string(b[4:8])
+}
@@ -0,0 +1,31 @@
+// Package cmaf carries CMAF (fragmented MP4) media on MOQ tracks, so that
+// existing CMAF encoders can publish over MOQ and players can reconstruct
+// the segments they play.
+//
+// A Parser splits the output of an encoder into its init segment and its
+// chunks, each a moof box and its mdat box. A Writer publishes the chunks
+// on a track: each chunk starting with a sync sample opens a new group, so
+// that a group holds one CMAF segment, and each chunk is one frame. The init
+// segment is either stored as the initData of the catalog track with
+// SetInitData, or written as group 0 with InitModeGroup.
+//
+//	p := cmaf.NewParser(encoderOutput)
+//	init, err := p.ReadInit()
+//	w := cmaf.NewWriter(tw, init, cmaf.InitModeGroup)
+//	for {
+//		chunk, err := p.ReadChunk()
+//		if err != nil {
+//			break
+//		}
+//		if err := w.WriteChunk(chunk); err != nil {
+//			break
+//		}
+//	}
+//
+// A Reader reads the groups back as segments, which appended to the init
+// segment form a playable fragmented MP4 file:
+//
+//	r := cmaf.NewReader(tr, nil)
+//	seg, err := r.ReadSegment(ctx)
+//	play(r.Init(), seg.Data)
+package cmaf
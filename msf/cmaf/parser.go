@@ -0,0 +1,208 @@
+package cmaf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Chunk is a CMAF chunk: a moof box and the mdat box holding its samples,
+// preceded by the boxes the encoder wrote before them, such as styp, prft or
+// emsg.
+type Chunk struct {
+	// Data holds the boxes of the chunk.
+	Data []byte
+
+	// Sync reports whether the first sample of the chunk is a sync sample,
+	// so that decoding can start at the chunk.
+	Sync bool
+}
+
+// Parser splits a fragmented MP4 stream, such as the output of a CMAF
+// encoder, into its init segment and chunks.
+//
+// A Parser is not safe for concurrent use.
+type Parser struct {
+	r io.Reader
+
+	// trexFlags holds the default sample flags of the tracks of the init
+	// segment, by track ID.
+	trexFlags map[uint32]uint32
+}
+
+// NewParser returns a Parser reading from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: r}
+}
+
+// ReadInit reads the init segment, the boxes up to and including the moov
+// box. It must be called before ReadChunk.
+func (p *Parser) ReadInit() ([]byte, error) {
+	var init []byte
+	for {
+		b, err := readBox(p.r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("%w: missing moov box", ErrMalformedBox)
+			}
+			return nil, err
+		}
+		switch b.typ {
+		case "moof", "mdat":
+			return nil, fmt.Errorf("%w: %q box before the init segment", ErrMalformedBox, b.typ)
+		}
+		init = append(init, b.data...)
+		if b.typ == "moov" {
+			p.trexFlags = trexFlags(b.body)
+			return init, nil
+		}
+	}
+}
+
+// ReadChunk reads the next chunk. It returns io.EOF at the end of the
+// stream, and io.ErrUnexpectedEOF if the stream ends within a chunk.
+func (p *Parser) ReadChunk() (Chunk, error) {
+	var (
+		data []byte
+		moof *box
+	)
+	for {
+		b, err := readBox(p.r)
+		if errors.Is(err, io.EOF) && len(data) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return Chunk{}, err
+		}
+
+		switch b.typ {
+		case "ftyp", "moov":
+			return Chunk{}, fmt.Errorf("%w: %q box in a media segment", ErrMalformedBox, b.typ)
+		case "moof":
+			if moof != nil {
+				return Chunk{}, fmt.Errorf("%w: moof box without mdat", ErrMalformedBox)
+			}
+			moof = &b
+		case "mdat":
+			if moof == nil {
+				return Chunk{}, fmt.Errorf("%w: mdat box without moof", ErrMalformedBox)
+			}
+			data = append(data, b.data...)
+			return Chunk{Data: data, Sync: p.syncFragment(moof.body)}, nil
+		}
+		data = append(data, b.data...)
+	}
+}
+
+// Sample flags of ISO/IEC 14496-12.
+const sampleIsNonSync = 0x10000
+
+// syncFragment reports whether the first sample of the fragment with the
+// given moof body is a sync sample. Flags are taken from the trun box, the
+// tfhd box or the trex box of the init segment, in that order, and a sample
+// without flags is a sync sample.
+func (p *Parser) syncFragment(moof []byte) bool {
+	traf, ok := child(moof, "traf")
+	if !ok {
+		return false
+	}
+	tfhd, ok := child(traf.body, "tfhd")
+	if !ok {
+		return false
+	}
+	trackID, defaultFlags, hasDefault := parseTfhd(tfhd.body)
+
+	if trun, ok := child(traf.body, "trun"); ok {
+		if flags, ok := firstSampleFlags(trun.body); ok {
+			return flags&sampleIsNonSync == 0
+		}
+	}
+	if hasDefault {
+		return defaultFlags&sampleIsNonSync == 0
+	}
+	return p.trexFlags[trackID]&sampleIsNonSync == 0
+}
+
+// parseTfhd returns the track ID and the default sample flags, if present,
+// of a tfhd box body.
+func parseTfhd(b []byte) (trackID, flags uint32, ok bool) {
+	boxFlags := fullBoxFlags(b)
+	trackID, _ = u32(b, 4)
+	off := 8
+	for _, f := range []struct {
+		bit  uint32
+		size int
+	}{{0x01, 8}, {0x02, 4}, {0x08, 4}, {0x10, 4}} {
+		if boxFlags&f.bit != 0 {
+			off += f.size
+		}
+	}
+	if boxFlags&0x20 == 0 {
+		return trackID, 0, false
+	}
+	flags, ok = u32(b, off)
+	return trackID, flags, ok
+}
+
+// firstSampleFlags returns the flags of the first sample of a trun box body,
+// if present.
+func firstSampleFlags(b []byte) (uint32, bool) {
+	boxFlags := fullBoxFlags(b)
+	off := 8 // version, flags and sample_count
+	if boxFlags&0x01 != 0 {
+		off += 4 // data_offset
+	}
+	if boxFlags&0x04 != 0 {
+		return u32(b, off)
+	}
+	if boxFlags&0x100 != 0 {
+		off += 4 // sample_duration
+	}
+	if boxFlags&0x200 != 0 {
+		off += 4 // sample_size
+	}
+	if boxFlags&0x400 != 0 {
+		return u32(b, off)
+	}
+	return 0, false
+}
+
+// trexFlags returns the default sample flags of the trex boxes of a moov
+// box body, by track ID.
+func trexFlags(moov []byte) map[uint32]uint32 {
+	mvex, ok := child(moov, "mvex")
+	if !ok {
+		return nil
+	}
+	boxes, err := parseBoxes(mvex.body)
+	if err != nil {
+		return nil
+	}
+	flags := make(map[uint32]uint32)
+	for _, b := range boxes {
+		if b.typ != "trex" {
+			continue
+		}
+		trackID, ok1 := u32(b.body, 4)
+		f, ok2 := u32(b.body, 20)
+		if ok1 && ok2 {
+			flags[trackID] = f
+		}
+	}
+	return flags
+}
+
+// fullBoxFlags returns the flags of a full box body.
+func fullBoxFlags(b []byte) uint32 {
+	v, _ := u32(b, 0)
+	return v & 0xffffff
+}
+
+// u32 returns the big-endian uint32 at off in b.
+func u32(b []byte, off int) (uint32, bool) {
+	if len(b) < off+4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(b[off:]), true
+}
@@ -0,0 +1,137 @@
+package cmaf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const nonSync = sampleIsNonSync
+
+// testInit returns an init segment whose trex gives track 1 the default
+// sample flags.
+func testInit(defaultFlags uint32) []byte {
+	trex := testutil.Box("trex", testutil.FullBox(0, 1, 1, 0, 0, defaultFlags))
+	return append(testutil.Box("ftyp", []byte("iso6"), testutil.FullBox(0)), testutil.Box("moov", testutil.Box("mvhd", testutil.FullBox(0)), testutil.Box("mvex", trex))...)
+}
+
+// testChunk returns a chunk with a tfhd and trun box of the given flags and
+// fields, and an mdat holding payload.
+func testChunk(tfhd, trun []byte, payload string) []byte {
+	moof := testutil.Box("moof", testutil.Box("mfhd", testutil.FullBox(0, 1)), testutil.Box("traf", testutil.Box("tfhd", tfhd), testutil.Box("trun", trun)))
+	return append(moof, testutil.Box("mdat", []byte(payload))...)
+}
+
+func TestParser(t *testing.T) {
+	tests := map[string]struct {
+		trexFlags uint32
+		chunk     []byte
+		sync      bool
+	}{
+		"first sample flags sync": {
+			chunk: testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x04, 1, 0), "a"),
+			sync:  true,
+		},
+		"first sample flags non-sync": {
+			chunk: testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x04, 1, nonSync), "a"),
+		},
+		"first sample flags after data offset": {
+			chunk: testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x05, 1, 100, nonSync), "a"),
+		},
+		"per-sample flags": {
+			chunk: testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x700, 2, 10, 20, 0, 10, 20, nonSync), "a"),
+			sync:  true,
+		},
+		"tfhd default flags": {
+			chunk: testChunk(testutil.FullBox(0x28, 1, 1000, nonSync), testutil.FullBox(0, 1), "a"),
+		},
+		"trex default flags": {
+			trexFlags: nonSync,
+			chunk:     testChunk(testutil.FullBox(0, 1), testutil.FullBox(0, 1), "a"),
+		},
+		"no flags": {
+			chunk: testChunk(testutil.FullBox(0, 1), testutil.FullBox(0, 1), "a"),
+			sync:  true,
+		},
+		"styp before moof": {
+			chunk: append(testutil.Box("styp", []byte("cmfs")), testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x04, 1, 0), "a")...),
+			sync:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			init := testInit(tt.trexFlags)
+			p := NewParser(bytes.NewReader(slices.Concat(init, tt.chunk, tt.chunk)))
+
+			gotInit, err := p.ReadInit()
+			require.NoError(t, err)
+			assert.Equal(t, init, gotInit)
+
+			for range 2 {
+				chunk, err := p.ReadChunk()
+				require.NoError(t, err)
+				assert.Equal(t, tt.chunk, chunk.Data)
+				assert.Equal(t, tt.sync, chunk.Sync)
+			}
+			_, err = p.ReadChunk()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestParser_Malformed(t *testing.T) {
+	init := testInit(0)
+	chunk := testChunk(testutil.FullBox(0, 1), testutil.FullBox(0, 1), "a")
+
+	tests := map[string]struct {
+		data      []byte
+		initError bool
+		wantErr   error
+	}{
+		"chunk before init": {data: chunk, initError: true, wantErr: ErrMalformedBox},
+		"no moov":           {data: testutil.Box("ftyp"), initError: true, wantErr: ErrMalformedBox},
+		"mdat without moof": {data: slices.Concat(init, testutil.Box("mdat")), wantErr: ErrMalformedBox},
+		"moof without mdat": {data: slices.Concat(init, testutil.Box("moof"), chunk), wantErr: ErrMalformedBox},
+		"truncated chunk":   {data: slices.Concat(init, testutil.Box("moof")), wantErr: io.ErrUnexpectedEOF},
+		"truncated box":     {data: slices.Concat(init, chunk[:len(chunk)-1]), wantErr: ErrMalformedBox},
+		"invalid size":      {data: slices.Concat(init, []byte{0, 0, 0, 4, 'm', 'o', 'o', 'f'}), wantErr: ErrMalformedBox},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := NewParser(bytes.NewReader(tt.data))
+			_, err := p.ReadInit()
+			if tt.initError {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			_, err = p.ReadChunk()
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParser_LargeSize(t *testing.T) {
+	// An mdat with a 64-bit size.
+	mdat := binary.BigEndian.AppendUint32(nil, 1)
+	mdat = append(mdat, "mdat"...)
+	mdat = binary.BigEndian.AppendUint64(mdat, 16+3)
+	mdat = append(mdat, "abc"...)
+	moof := testutil.Box("moof", testutil.Box("traf", testutil.Box("tfhd", testutil.FullBox(0, 1))))
+
+	p := NewParser(bytes.NewReader(slices.Concat(testInit(0), moof, mdat)))
+	_, err := p.ReadInit()
+	require.NoError(t, err)
+	chunk, err := p.ReadChunk()
+	require.NoError(t, err)
+	assert.Equal(t, slices.Concat(moof, mdat), chunk.Data)
+	assert.True(t, chunk.Sync)
+}
@@ -0,0 +1,215 @@
+package cmaf
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timing computes the duration of chunks from the timescales and default
+// sample durations of the tracks of an init segment.
+type Timing struct {
+	// timescales holds the timescale of each track, by track ID.
+	timescales map[uint32]uint32
+	// durations holds the default sample duration of the trex box of each
+	// track, by track ID.
+	durations map[uint32]uint32
+}
+
+// NewTiming returns the Timing of the tracks of the init segment init.
+func NewTiming(init []byte) (*Timing, error) {
+	boxes, err := parseBoxes(init)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range boxes {
+		if b.typ == "moov" {
+			return newTiming(b.body)
+		}
+	}
+	return nil, fmt.Errorf("%w: missing moov box", ErrMalformedBox)
+}
+
+func newTiming(moov []byte) (*Timing, error) {
+	boxes, err := parseBoxes(moov)
+	if err != nil {
+		return nil, err
+	}
+	t := &Timing{timescales: make(map[uint32]uint32), durations: make(map[uint32]uint32)}
+	for _, b := range boxes {
+		switch b.typ {
+		case "trak":
+			trackID, timescale, ok := parseTrak(b.body)
+			if !ok {
+				return nil, fmt.Errorf("%w: trak box without track ID or timescale", ErrMalformedBox)
+			}
+			t.timescales[trackID] = timescale
+		case "mvex":
+			mvex, err := parseBoxes(b.body)
+			if err != nil {
+				return nil, err
+			}
+			for _, trex := range mvex {
+				trackID, ok1 := u32(trex.body, 4)
+				d, ok2 := u32(trex.body, 12)
+				if trex.typ == "trex" && ok1 && ok2 {
+					t.durations[trackID] = d
+				}
+			}
+		}
+	}
+	if len(t.timescales) == 0 {
+		return nil, fmt.Errorf("%w: moov box without trak", ErrMalformedBox)
+	}
+	return t, nil
+}
+
+// parseTrak returns the track ID of the tkhd box and the timescale of the
+// mdhd box of a trak box body.
+func parseTrak(trak []byte) (trackID, timescale uint32, ok bool) {
+	tkhd, ok := child(trak, "tkhd")
+	if !ok {
+		return 0, 0, false
+	}
+	mdia, ok := child(trak, "mdia")
+	if !ok {
+		return 0, 0, false
+	}
+	mdhd, ok := child(mdia.body, "mdhd")
+	if !ok {
+		return 0, 0, false
+	}
+	// Version 1 boxes have 64-bit creation and modification times.
+	off := 12
+	if len(tkhd.body) > 0 && tkhd.body[0] == 1 {
+		off = 20
+	}
+	trackID, ok1 := u32(tkhd.body, off)
+	off = 12
+	if len(mdhd.body) > 0 && mdhd.body[0] == 1 {
+		off = 20
+	}
+	timescale, ok2 := u32(mdhd.body, off)
+	return trackID, timescale, ok1 && ok2 && timescale > 0
+}
+
+// Duration returns the duration of the samples of data, a chunk or a
+// segment. For a fragment holding several tracks, the duration of the
+// longest one is counted.
+func (t *Timing) Duration(data []byte) (time.Duration, error) {
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		return 0, err
+	}
+	var total time.Duration
+	for _, b := range boxes {
+		if b.typ != "moof" {
+			continue
+		}
+		d, err := t.fragmentDuration(b.body)
+		if err != nil {
+			return 0, err
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// fragmentDuration returns the duration of the fragment with the given moof
+// body.
+func (t *Timing) fragmentDuration(moof []byte) (time.Duration, error) {
+	boxes, err := parseBoxes(moof)
+	if err != nil {
+		return 0, err
+	}
+	var longest time.Duration
+	for _, traf := range boxes {
+		if traf.typ != "traf" {
+			continue
+		}
+		tfhd, ok := child(traf.body, "tfhd")
+		if !ok {
+			return 0, fmt.Errorf("%w: traf box without tfhd", ErrMalformedBox)
+		}
+		trackID, defaultDuration, hasDefault := parseTfhdDuration(tfhd.body)
+		timescale, ok := t.timescales[trackID]
+		if !ok {
+			return 0, fmt.Errorf("%w: fragment of unknown track %d", ErrMalformedBox, trackID)
+		}
+		if !hasDefault {
+			defaultDuration = t.durations[trackID]
+		}
+
+		traf, err := parseBoxes(traf.body)
+		if err != nil {
+			return 0, err
+		}
+		var ticks uint64
+		for _, trun := range traf {
+			if trun.typ != "trun" {
+				continue
+			}
+			n, ok := trunDuration(trun.body, defaultDuration)
+			if !ok {
+				return 0, fmt.Errorf("%w: truncated trun box", ErrMalformedBox)
+			}
+			ticks += n
+		}
+		longest = max(longest, time.Duration(ticks*uint64(time.Second)/uint64(timescale)))
+	}
+	return longest, nil
+}
+
+// parseTfhdDuration returns the track ID and the default sample duration,
+// if present, of a tfhd box body.
+func parseTfhdDuration(b []byte) (trackID, duration uint32, ok bool) {
+	boxFlags := fullBoxFlags(b)
+	trackID, _ = u32(b, 4)
+	off := 8
+	if boxFlags&0x01 != 0 {
+		off += 8 // base_data_offset
+	}
+	if boxFlags&0x02 != 0 {
+		off += 4 // sample_description_index
+	}
+	if boxFlags&0x08 == 0 {
+		return trackID, 0, false
+	}
+	duration, ok = u32(b, off)
+	return trackID, duration, ok
+}
+
+// trunDuration returns the sum of the sample durations of a trun box body,
+// where samples without a duration last defaultDuration.
+func trunDuration(b []byte, defaultDuration uint32) (uint64, bool) {
+	boxFlags := fullBoxFlags(b)
+	count, ok := u32(b, 4)
+	if !ok {
+		return 0, false
+	}
+	if boxFlags&0x100 == 0 {
+		return uint64(count) * uint64(defaultDuration), true
+	}
+
+	off := 8
+	if boxFlags&0x01 != 0 {
+		off += 4 // data_offset
+	}
+	if boxFlags&0x04 != 0 {
+		off += 4 // first_sample_flags
+	}
+	size := 0
+	for _, bit := range []uint32{0x100, 0x200, 0x400, 0x800} {
+		if boxFlags&bit != 0 {
+			size += 4
+		}
+	}
+	if uint64(len(b)) < uint64(off)+uint64(count)*uint64(size) {
+		return 0, false
+	}
+	var total uint64
+	for i := range int(count) {
+		d, _ := u32(b, off+i*size)
+		total += uint64(d)
+	}
+	return total, true
+}
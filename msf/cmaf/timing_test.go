@@ -0,0 +1,82 @@
+package cmaf
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTimingInit returns an init segment with track 1 of the given timescale
+// and trex default sample duration.
+func testTimingInit(timescale, defaultDuration uint32) []byte {
+	// tkhd: creation and modification times, then the track ID.
+	tkhd := testutil.Box("tkhd", testutil.FullBox(0, 0, 0, 1))
+	// mdhd: creation and modification times, then the timescale.
+	mdhd := testutil.Box("mdhd", testutil.FullBox(0, 0, 0, timescale))
+	trak := testutil.Box("trak", tkhd, testutil.Box("mdia", mdhd))
+	trex := testutil.Box("trex", testutil.FullBox(0, 1, 1, defaultDuration, 0, 0))
+	return append(testutil.Box("ftyp", []byte("iso6"), testutil.FullBox(0)), testutil.Box("moov", testutil.Box("mvhd", testutil.FullBox(0)), trak, testutil.Box("mvex", trex))...)
+}
+
+func TestTiming_Duration(t *testing.T) {
+	tests := map[string]struct {
+		trexDuration uint32
+		data         []byte
+		want         time.Duration
+		wantErr      bool
+	}{
+		"per-sample durations": {
+			data: testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x305, 2, 100, 0, 300, 10, 600, 10), "a"),
+			want: 100 * time.Millisecond,
+		},
+		"tfhd default duration": {
+			data: testChunk(testutil.FullBox(0x0A, 1, 1, 450), testutil.FullBox(0, 4), "a"),
+			want: 200 * time.Millisecond,
+		},
+		"trex default duration": {
+			trexDuration: 900,
+			data:         testChunk(testutil.FullBox(0, 1), testutil.FullBox(0, 3), "a"),
+			want:         300 * time.Millisecond,
+		},
+		"several fragments": {
+			trexDuration: 900,
+			data:         slices.Concat(testChunk(testutil.FullBox(0, 1), testutil.FullBox(0, 1), "a"), testChunk(testutil.FullBox(0, 1), testutil.FullBox(0, 2), "b")),
+			want:         300 * time.Millisecond,
+		},
+		"unknown track": {
+			data:    testChunk(testutil.FullBox(0, 2), testutil.FullBox(0, 1), "a"),
+			wantErr: true,
+		},
+		"truncated trun": {
+			data:    testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x100, 2, 300), "a"),
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			timing, err := NewTiming(testTimingInit(9000, tt.trexDuration))
+			require.NoError(t, err)
+
+			d, err := timing.Duration(tt.data)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrMalformedBox)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, d)
+		})
+	}
+}
+
+func TestNewTiming(t *testing.T) {
+	_, err := NewTiming(testutil.Box("ftyp", []byte("iso6")))
+	assert.ErrorIs(t, err, ErrMalformedBox)
+	// The init segments of the other tests have no trak box.
+	_, err = NewTiming(testInit(0))
+	assert.ErrorIs(t, err, ErrMalformedBox)
+}
@@ -0,0 +1,223 @@
+package cmaf
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf"
+)
+
+// InitGroup is the group that carries the init segment of a track written
+// with InitModeGroup. A Writer numbers the media groups from 1.
+const InitGroup moqt.GroupSequence = 0
+
+// InitMode selects how a Writer delivers the init segment of a track.
+type InitMode uint8
+
+const (
+	// InitModeCatalog leaves the init segment to the catalog, where
+	// SetInitData stores it as the initData of the track. This is the
+	// default.
+	InitModeCatalog InitMode = iota
+	// InitModeGroup writes the init segment as the only frame of InitGroup
+	// before the first media group, so that subscribers without the catalog
+	// can decode the track.
+	InitModeGroup
+)
+
+// Writer publishes CMAF chunks on a track. Each chunk starting with a sync
+// sample opens a new group, which thus holds one CMAF segment, and each
+// chunk is written as one frame.
+//
+// A Writer is not safe for concurrent use.
+type Writer struct {
+	tw    *moqt.TrackWriter
+	init  []byte
+	mode  InitMode
+	group *moqt.GroupWriter
+	frame *moqt.Frame
+
+	initSent bool
+	next     moqt.GroupSequence
+}
+
+// NewWriter returns a Writer writing to tw. init is the init segment of the
+// track, written to InitGroup if mode is InitModeGroup.
+func NewWriter(tw *moqt.TrackWriter, init []byte, mode InitMode) *Writer {
+	return &Writer{tw: tw, init: init, mode: mode, frame: moqt.NewFrame(0), next: InitGroup + 1}
+}
+
+// WriteChunk writes chunk to the track. Chunks before the first sync chunk
+// are dropped, as subscribers could not decode them.
+func (w *Writer) WriteChunk(chunk Chunk) error {
+	if w.mode == InitModeGroup && !w.initSent {
+		if err := w.writeInit(); err != nil {
+			return err
+		}
+		w.initSent = true
+	}
+
+	if chunk.Sync {
+		if err := w.Close(); err != nil {
+			return err
+		}
+		group, err := w.tw.OpenGroupAt(w.next)
+		if err != nil {
+			return err
+		}
+		w.group = group
+		w.next++
+	}
+	if w.group == nil {
+		return nil
+	}
+	return w.writeFrame(w.group, chunk.Data)
+}
+
+func (w *Writer) writeInit() error {
+	group, err := w.tw.OpenGroupAt(InitGroup)
+	if err != nil {
+		return err
+	}
+	if err := w.writeFrame(group, w.init); err != nil {
+		group.CancelWrite(moqt.InternalGroupErrorCode)
+		return err
+	}
+	return group.Close()
+}
+
+func (w *Writer) writeFrame(group *moqt.GroupWriter, data []byte) error {
+	w.frame.Reset()
+	_, _ = w.frame.Write(data)
+	return group.WriteFrame(w.frame)
+}
+
+// Close closes the current group. It does not close the track.
+func (w *Writer) Close() error {
+	if w.group == nil {
+		return nil
+	}
+	err := w.group.Close()
+	w.group = nil
+	return err
+}
+
+// Segment is a CMAF segment reconstructed from a group.
+type Segment struct {
+	// GroupSequence is the group that carried the segment.
+	GroupSequence moqt.GroupSequence
+
+	// Data holds the chunks of the segment, in order. Appended to the init
+	// segment, it forms a playable fragmented MP4 file.
+	Data []byte
+}
+
+// Reader reconstructs the CMAF segments of a track written by a Writer.
+//
+// A Reader is not safe for concurrent use.
+type Reader struct {
+	tr    *moqt.TrackReader
+	init  []byte
+	frame *moqt.Frame
+
+	// pending holds the segments received before the init segment.
+	pending []Segment
+}
+
+// NewReader returns a Reader reading from tr. init is the init segment of
+// the track, such as the one returned by InitData. If it is nil, the track
+// must be written with InitModeGroup and the init segment is read from
+// InitGroup.
+func NewReader(tr *moqt.TrackReader, init []byte) *Reader {
+	return &Reader{tr: tr, init: init, frame: moqt.NewFrame(0)}
+}
+
+// Init returns the init segment, or nil until it has been received.
+func (r *Reader) Init() []byte {
+	return r.init
+}
+
+// ReadSegment waits for the next group and returns its segment. Segments
+// received before the init segment are returned once it is received, so that
+// the init segment is always known when ReadSegment returns. A group that
+// ends with an error is returned as an error, after which later groups can
+// still be read.
+func (r *Reader) ReadSegment(ctx context.Context) (Segment, error) {
+	for {
+		if r.init != nil && len(r.pending) > 0 {
+			seg := r.pending[0]
+			r.pending = r.pending[1:]
+			return seg, nil
+		}
+
+		group, err := r.tr.AcceptGroup(ctx)
+		if err != nil {
+			return Segment{}, err
+		}
+		seq := group.GroupSequence()
+		data, err := r.readGroup(group)
+		if err != nil {
+			return Segment{}, fmt.Errorf("cmaf: failed to read group %s: %w", seq, err)
+		}
+
+		if seq == InitGroup && firstBoxType(data) == "ftyp" {
+			if r.init == nil {
+				r.init = data
+			}
+			continue
+		}
+		if r.init == nil {
+			r.pending = append(r.pending, Segment{GroupSequence: seq, Data: data})
+			continue
+		}
+		return Segment{GroupSequence: seq, Data: data}, nil
+	}
+}
+
+// readGroup returns the frames of group, concatenated.
+func (r *Reader) readGroup(group *moqt.GroupReader) ([]byte, error) {
+	var data []byte
+	for {
+		err := group.ReadFrame(r.frame)
+		if errors.Is(err, io.EOF) {
+			return data, nil
+		}
+		if err != nil {
+			group.CancelRead(moqt.InternalGroupErrorCode)
+			return nil, err
+		}
+		data = append(data, r.frame.Body()...)
+	}
+}
+
+// Close ends the subscription to the track.
+func (r *Reader) Close() error {
+	return r.tr.Close()
+}
+
+// SetInitData stores init as the initData of a catalog track and sets its
+// packaging to cmaf.
+func SetInitData(track *msf.Track, init []byte) {
+	track.Packaging = msf.PackagingCMAF
+	track.InitData = base64.StdEncoding.EncodeToString(init)
+}
+
+// InitData returns the init segment stored in the initData of a catalog
+// track.
+func InitData(track msf.Track) ([]byte, error) {
+	if track.InitData == "" {
+		return nil, fmt.Errorf("cmaf: track %s has no initData", track.Name)
+	}
+	init, err := base64.StdEncoding.DecodeString(track.InitData)
+	if err != nil {
+		return nil, fmt.Errorf("cmaf: invalid initData of track %s: %w", track.Name, err)
+	}
+	if firstBoxType(init) != "ftyp" {
+		return nil, fmt.Errorf("%w: initData of track %s does not start with ftyp", ErrMalformedBox, track.Name)
+	}
+	return init, nil
+}
@@ -0,0 +1,95 @@
+package cmaf
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter_Reader(t *testing.T) {
+	init := testInit(0)
+	key := func(payload string) Chunk {
+		return Chunk{Data: testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x04, 1, 0), payload), Sync: true}
+	}
+	delta := func(payload string) Chunk {
+		return Chunk{Data: testChunk(testutil.FullBox(0, 1), testutil.FullBox(0x04, 1, nonSync), payload)}
+	}
+
+	tests := map[string]struct {
+		mode InitMode
+		// readerInit is passed to NewReader.
+		readerInit []byte
+	}{
+		"init in catalog": {mode: InitModeCatalog, readerInit: init},
+		"init in group":   {mode: InitModeGroup},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mux := moqt.NewTrackMux(0)
+			mux.PublishFunc(t.Context(), "/live", func(tw *moqt.TrackWriter) {
+				w := NewWriter(tw, init, tt.mode)
+				// The delta before the first keyframe is dropped.
+				for _, chunk := range []Chunk{delta("x"), key("a"), delta("b"), key("c")} {
+					if err := w.WriteChunk(chunk); err != nil {
+						return
+					}
+				}
+				_ = w.Close()
+				<-tw.Context().Done()
+			})
+			sess := moqttest.DialMux(t, mux)
+
+			ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+			defer cancel()
+			tr, err := sess.Subscribe(ctx, "/live", "video", nil)
+			require.NoError(t, err)
+			r := NewReader(tr, tt.readerInit)
+			defer r.Close()
+
+			// Groups may arrive in any order.
+			segments := make(map[moqt.GroupSequence][]byte)
+			for range 2 {
+				seg, err := r.ReadSegment(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, init, r.Init())
+				segments[seg.GroupSequence] = seg.Data
+			}
+			assert.Equal(t, map[moqt.GroupSequence][]byte{
+				1: slices.Concat(key("a").Data, delta("b").Data),
+				2: key("c").Data,
+			}, segments)
+		})
+	}
+}
+
+func TestInitData(t *testing.T) {
+	init := testInit(0)
+	track := msf.Track{Name: "video", IsLive: new(true)}
+	SetInitData(&track, init)
+	assert.Equal(t, msf.PackagingCMAF, track.Packaging)
+
+	// The init segment survives the catalog.
+	data, err := msf.Catalog{Version: 1, Tracks: []msf.Track{track}}.MarshalJSON()
+	require.NoError(t, err)
+	catalog, err := msf.ParseCatalog(data)
+	require.NoError(t, err)
+	got, err := InitData(catalog.Tracks[0])
+	require.NoError(t, err)
+	assert.Equal(t, init, got)
+
+	_, err = InitData(msf.Track{Name: "video"})
+	assert.Error(t, err)
+	_, err = InitData(msf.Track{Name: "video", InitData: "!"})
+	assert.Error(t, err)
+	_, err = InitData(msf.Track{Name: "video", InitData: "AAAA"})
+	assert.ErrorIs(t, err, ErrMalformedBox)
+}
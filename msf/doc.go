@@ -10,7 +10,11 @@
 // Most of the package is transport-agnostic and can be used in pure
 // data-processing tools or tests. The optional Broadcast helper integrates an
 // MSF catalog snapshot with moqt.TrackHandler routing for publishers that want
-// a small in-memory track registry.
+// a small in-memory track registry, Simulcast publishes renditions of the same
+// content on a Broadcast with groups aligned at keyframes, CatalogWatcher
+// follows the catalog track of a broadcast as a subscriber, and
+// ABRController switches a subscriber between renditions as its delivery
+// allows.
 //
 // Most optional catalog fields use pointer types so that the distinction
 // between "field absent" and "field present with zero value" is preserved
@@ -0,0 +1,20 @@
+// Package hls serves CMAF-packaged MOQ tracks as HLS and Low-Latency HLS
+// (RFC 8216 and its second edition draft), so that players without MOQ
+// support can play content ingested over MOQ, such as during a migration.
+//
+// An Egress subscribes to a track written by cmaf.Writer and exposes it as
+// an http.Handler serving a media playlist, the init segment, segments and
+// parts. Each group of the track is one segment and each CMAF chunk one
+// part; segment and part durations are read from the fragments:
+//
+//	tr, err := sess.Subscribe(ctx, "/live", "video", nil)
+//	e := hls.NewEgress(tr, nil, nil) // or the init segment of the catalog
+//	defer e.Close()
+//	go e.Run(ctx)
+//	http.Handle("/live/video/", e) // playlist at /live/video/index.m3u8
+//
+// The playlist lists the last segments of the track, a sliding window of
+// Config.WindowSize segments, with the parts of the newest ones, and
+// supports the blocking playlist reload and preload hints of Low-Latency
+// HLS. Players unaware of parts play the complete segments.
+package hls
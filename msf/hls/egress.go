@@ -0,0 +1,352 @@
+package hls
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/cmaf"
+)
+
+// DefaultWindowSize is the number of complete segments listed in the
+// playlist when Config.WindowSize is zero.
+const DefaultWindowSize = 6
+
+// partSegments is the number of complete segments whose parts are listed in
+// the playlist, besides the segment in progress.
+const partSegments = 2
+
+// Config configures an Egress.
+type Config struct {
+	// WindowSize is the number of complete segments listed in the playlist.
+	// If zero, DefaultWindowSize is used.
+	WindowSize int
+}
+
+func (c *Config) windowSize() int {
+	if c != nil && c.WindowSize > 0 {
+		return c.WindowSize
+	}
+	return DefaultWindowSize
+}
+
+// Egress serves a CMAF-packaged track, as written by cmaf.Writer, as an
+// HLS media playlist with Low-Latency HLS parts.
+//
+// Each group of the track is one segment, and each frame, a CMAF chunk, is
+// one part. Run reads the track, and ServeHTTP serves the playlist, the init
+// segment, the segments and the parts.
+//
+// An Egress is safe for concurrent use.
+type Egress struct {
+	tr     *moqt.TrackReader
+	window int
+
+	mu sync.Mutex
+	// changed is closed, and replaced, when the playlist changes.
+	changed chan struct{}
+
+	init   []byte
+	timing *cmaf.Timing
+	// waiting holds the groups received before the init segment.
+	waiting []*incoming
+
+	// segments holds the segments of the window, oldest first. Only the
+	// last one may be in progress.
+	segments []*segment
+	nextMSN  uint64
+
+	targetDuration time.Duration
+	partTarget     time.Duration
+	ended          bool
+}
+
+// segment is a segment of the playlist.
+type segment struct {
+	msn      uint64
+	group    moqt.GroupSequence
+	parts    []part
+	duration time.Duration
+	complete bool
+}
+
+// part is a part of a segment: one CMAF chunk.
+type part struct {
+	data     []byte
+	duration time.Duration
+}
+
+// incoming is a group being read.
+type incoming struct {
+	seq moqt.GroupSequence
+	// frames holds the frames received before the segment was opened.
+	frames [][]byte
+	seg    *segment
+	done   bool
+	// skipped reports whether the group came after a newer one.
+	skipped bool
+}
+
+// NewEgress returns an Egress serving tr. init is the init segment of the
+// track, such as the one returned by cmaf.InitData. If it is nil, the track
+// must be written with cmaf.InitModeGroup and the init segment is read from
+// cmaf.InitGroup.
+func NewEgress(tr *moqt.TrackReader, init []byte, config *Config) *Egress {
+	e := &Egress{
+		tr:      tr,
+		window:  config.windowSize(),
+		changed: make(chan struct{}),
+	}
+	if init != nil {
+		_ = e.setInit(init)
+	}
+	return e
+}
+
+// Run reads the groups of the track until it ends or ctx is canceled, and
+// returns the error that ended it. The playlist then ends with
+// EXT-X-ENDLIST.
+//
+// Groups are read one at a time, so that a segment is complete before the
+// next one starts. Until the init segment is known, groups are read
+// concurrently, as the group carrying it may come after media groups.
+func (e *Egress) Run(ctx context.Context) error {
+	defer e.end()
+
+	frame := moqt.NewFrame(0)
+	for {
+		group, err := e.tr.AcceptGroup(ctx)
+		if err != nil {
+			return err
+		}
+		if group.GroupSequence() == cmaf.InitGroup {
+			go e.readInit(group)
+			continue
+		}
+
+		e.mu.Lock()
+		ready := e.init != nil
+		e.mu.Unlock()
+		if ready {
+			e.readGroup(group, frame)
+		} else {
+			go e.readGroup(group, moqt.NewFrame(0))
+		}
+	}
+}
+
+// readInit reads the init segment from group.
+func (e *Egress) readInit(group *moqt.GroupReader) {
+	var (
+		data  []byte
+		frame = moqt.NewFrame(0)
+	)
+	for {
+		err := group.ReadFrame(frame)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			group.CancelRead(moqt.InternalGroupErrorCode)
+			return
+		}
+		data = append(data, frame.Body()...)
+	}
+	_ = e.setInit(data)
+}
+
+// readGroup reads group as a segment, adding each frame as a part once it
+// is received.
+func (e *Egress) readGroup(group *moqt.GroupReader, frame *moqt.Frame) {
+	g := &incoming{seq: group.GroupSequence()}
+	e.mu.Lock()
+	if !e.startLocked(g) {
+		e.waiting = append(e.waiting, g)
+	}
+	e.mu.Unlock()
+
+	for {
+		err := group.ReadFrame(frame)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				group.CancelRead(moqt.InternalGroupErrorCode)
+			}
+			break
+		}
+
+		e.mu.Lock()
+		if g.skipped || (g.seg != nil && g.seg.complete) {
+			e.mu.Unlock()
+			group.CancelRead(moqt.InternalGroupErrorCode)
+			return
+		}
+		data := append([]byte(nil), frame.Body()...)
+		if g.seg != nil {
+			e.addPartLocked(g.seg, data)
+		} else {
+			g.frames = append(g.frames, data)
+		}
+		e.mu.Unlock()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	g.done = true
+	if g.seg != nil {
+		e.closeSegmentLocked(g.seg)
+	}
+}
+
+// startLocked opens the segment of g once the init segment is known, and
+// reports whether g no longer waits for it. A group older than the newest
+// segment is skipped. e.mu must be held.
+func (e *Egress) startLocked(g *incoming) bool {
+	if e.init == nil {
+		return false
+	}
+	if n := len(e.segments); n > 0 && e.segments[n-1].group >= g.seq {
+		g.skipped = true
+		return true
+	}
+	g.seg = e.openSegmentLocked(g.seq)
+	for _, data := range g.frames {
+		e.addPartLocked(g.seg, data)
+	}
+	g.frames = nil
+	if g.done {
+		e.closeSegmentLocked(g.seg)
+	}
+	return true
+}
+
+// setInit sets the init segment and starts the groups waiting for it.
+func (e *Egress) setInit(init []byte) error {
+	timing, err := cmaf.NewTiming(init)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.init != nil {
+		return nil
+	}
+	e.init = init
+	e.timing = timing
+	slices.SortFunc(e.waiting, func(a, b *incoming) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	for _, g := range e.waiting {
+		e.startLocked(g)
+	}
+	e.waiting = nil
+	e.notifyLocked()
+	return nil
+}
+
+// openSegmentLocked opens the segment of the group seq. A segment still in
+// progress is completed first, and the parts it receives later are dropped.
+// e.mu must be held.
+func (e *Egress) openSegmentLocked(seq moqt.GroupSequence) *segment {
+	if n := len(e.segments); n > 0 && !e.segments[n-1].complete {
+		e.closeSegmentLocked(e.segments[n-1])
+	}
+	seg := &segment{msn: e.nextMSN, group: seq}
+	e.nextMSN++
+	e.segments = append(e.segments, seg)
+	e.notifyLocked()
+	return seg
+}
+
+func (e *Egress) addPartLocked(seg *segment, data []byte) {
+	d, _ := e.timing.Duration(data)
+	seg.parts = append(seg.parts, part{data: data, duration: d})
+	seg.duration += d
+	e.partTarget = max(e.partTarget, d)
+	e.notifyLocked()
+}
+
+// closeSegmentLocked completes seg, and slides the window. A segment without
+// part is dropped. e.mu must be held.
+func (e *Egress) closeSegmentLocked(seg *segment) {
+	if seg.complete {
+		return
+	}
+	seg.complete = true
+	if n := len(e.segments); len(seg.parts) == 0 && n > 0 && e.segments[n-1] == seg {
+		e.segments = e.segments[:n-1]
+		e.nextMSN--
+	}
+	e.targetDuration = max(e.targetDuration, seg.duration)
+
+	complete := 0
+	for _, s := range e.segments {
+		if s.complete {
+			complete++
+		}
+	}
+	for complete > e.window && e.segments[0].complete {
+		e.segments = e.segments[1:]
+		complete--
+	}
+	e.notifyLocked()
+}
+
+// end marks the end of the track.
+func (e *Egress) end() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.ended = true
+	e.notifyLocked()
+}
+
+func (e *Egress) notifyLocked() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// wait waits until ready reports true or ctx ends, and reports whether
+// ready is true. e.mu must be held; it is released while waiting.
+func (e *Egress) wait(ctx context.Context, ready func() bool) bool {
+	for !ready() {
+		if e.ended {
+			return false
+		}
+		changed := e.changed
+		e.mu.Unlock()
+		select {
+		case <-changed:
+			e.mu.Lock()
+		case <-ctx.Done():
+			e.mu.Lock()
+			return ready()
+		}
+	}
+	return true
+}
+
+// Close ends the subscription to the track.
+func (e *Egress) Close() error {
+	return e.tr.Close()
+}
+
+// segmentLocked returns the segment msn of the window, if any. e.mu must be
+// held.
+func (e *Egress) segmentLocked(msn uint64) *segment {
+	if len(e.segments) == 0 || msn < e.segments[0].msn {
+		return nil
+	}
+	i := msn - e.segments[0].msn
+	if i >= uint64(len(e.segments)) {
+		return nil
+	}
+	return e.segments[i]
+}
@@ -0,0 +1,109 @@
+package hls
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/testutil"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testInit is an init segment with track 1 of timescale 1000.
+var testInit = append(testutil.Box("ftyp", []byte("iso6"), testutil.FullBox(0)), testutil.Box("moov",
+	testutil.Box("trak", testutil.Box("tkhd", testutil.FullBox(0, 0, 0, 1)), testutil.Box("mdia", testutil.Box("mdhd", testutil.FullBox(0, 0, 0, 1000)))),
+	testutil.Box("mvex", testutil.Box("trex", testutil.FullBox(0, 1, 1, 0, 0, 0))),
+)...)
+
+// testChunk returns a chunk of one sample lasting ms milliseconds, sync or
+// not, holding payload.
+func testChunk(ms uint32, sync bool, payload string) []byte {
+	var flags uint32 = 0x10000
+	if sync {
+		flags = 0
+	}
+	trun := testutil.Box("trun", testutil.FullBox(0x104, 1, flags, ms))
+	moof := testutil.Box("moof", testutil.Box("mfhd", testutil.FullBox(0, 1)), testutil.Box("traf", testutil.Box("tfhd", testutil.FullBox(0, 1)), trun))
+	return append(moof, testutil.Box("mdat", []byte(payload))...)
+}
+
+// playlist returns the playlist of e.
+func playlist(e *Egress) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return string(e.playlistLocked())
+}
+
+// addGroup starts a group of e holding chunks, and completes it if done.
+func addGroup(e *Egress, seq moqt.GroupSequence, done bool, chunks ...[]byte) *incoming {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	g := &incoming{seq: seq, frames: chunks, done: done}
+	if !e.startLocked(g) {
+		e.waiting = append(e.waiting, g)
+	}
+	return g
+}
+
+func TestEgress_Playlist(t *testing.T) {
+	e := NewEgress(nil, testInit, &Config{WindowSize: 2})
+
+	// Three complete segments of two parts and one in progress.
+	for seq := range moqt.GroupSequence(3) {
+		addGroup(e, seq+1, true, testChunk(500, true, "a"), testChunk(1000, false, "b"))
+	}
+	addGroup(e, 4, false, testChunk(500, true, "a"))
+	// A late group is skipped.
+	assert.True(t, addGroup(e, 2, true, testChunk(500, true, "a")).skipped)
+
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:2
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=3.000
+#EXT-X-PART-INF:PART-TARGET=1.000
+#EXT-X-MEDIA-SEQUENCE:1
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-PART:DURATION=0.500,URI="part1.0.m4s",INDEPENDENT=YES
+#EXT-X-PART:DURATION=1.000,URI="part1.1.m4s"
+#EXTINF:1.500,
+seg1.m4s
+#EXT-X-PART:DURATION=0.500,URI="part2.0.m4s",INDEPENDENT=YES
+#EXT-X-PART:DURATION=1.000,URI="part2.1.m4s"
+#EXTINF:1.500,
+seg2.m4s
+#EXT-X-PART:DURATION=0.500,URI="part3.0.m4s",INDEPENDENT=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="part3.1.m4s"
+`, playlist(e))
+
+	// A new group completes the segment in progress, and an empty segment
+	// is dropped.
+	addGroup(e, 5, true)
+	e.end()
+	got := playlist(e)
+	assert.Contains(t, got, "#EXT-X-MEDIA-SEQUENCE:2\n")
+	assert.Contains(t, got, "#EXTINF:0.500,\nseg3.m4s\n#EXT-X-ENDLIST\n")
+	assert.NotContains(t, got, "seg1.m4s")
+	assert.NotContains(t, got, "seg4.m4s")
+}
+
+func TestEgress_WaitingGroups(t *testing.T) {
+	e := NewEgress(nil, nil, nil)
+
+	// Groups received before the init segment start once it arrives, in
+	// order.
+	g2 := addGroup(e, 2, false, testChunk(1000, true, "b"))
+	g1 := addGroup(e, 1, true, testChunk(2000, true, "a"))
+	assert.Nil(t, g1.seg)
+	require.NoError(t, e.setInit(testInit))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	require.Len(t, e.segments, 2)
+	assert.Same(t, g1.seg, e.segments[0])
+	assert.True(t, g1.seg.complete)
+	assert.Same(t, g2.seg, e.segments[1])
+	assert.False(t, g2.seg.complete)
+	assert.Equal(t, 2*time.Second, e.targetDuration)
+}
@@ -0,0 +1,249 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the resources served by an Egress. The segments and parts are
+// named "seg<msn>.m4s" and "part<msn>.<index>.m4s" after the media sequence
+// number of their segment. Only the last element of the request path is
+// looked at, so that an Egress can be mounted under any prefix.
+const (
+	PlaylistName = "index.m3u8"
+	InitName     = "init.mp4"
+)
+
+// minBlockTimeout is the shortest time a blocking playlist or part request
+// is held. Requests are held for three target durations otherwise.
+const minBlockTimeout = 3 * time.Second
+
+// ServeHTTP serves the playlist, the init segment, the segments and the
+// parts. Playlist requests with the _HLS_msn and _HLS_part query parameters
+// of Low-Latency HLS are held until the requested segment or part is
+// listed, and part requests until the part is received, so that players
+// can request the part of the preload hint ahead of time.
+func (e *Egress) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := path.Base(r.URL.Path)
+	switch name {
+	case PlaylistName:
+		e.servePlaylist(w, r)
+		return
+	case InitName:
+		e.serveInit(w)
+		return
+	}
+	if s, ok := strings.CutPrefix(name, "seg"); ok {
+		if s, ok := strings.CutSuffix(s, ".m4s"); ok {
+			if msn, err := strconv.ParseUint(s, 10, 64); err == nil {
+				e.serveSegment(w, msn)
+				return
+			}
+		}
+	}
+	if s, ok := strings.CutPrefix(name, "part"); ok {
+		if s, ok := strings.CutSuffix(s, ".m4s"); ok {
+			msnStr, indexStr, _ := strings.Cut(s, ".")
+			msn, err1 := strconv.ParseUint(msnStr, 10, 64)
+			index, err2 := strconv.Atoi(indexStr)
+			if err1 == nil && err2 == nil && index >= 0 {
+				e.servePart(w, r, msn, index)
+				return
+			}
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (e *Egress) servePlaylist(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	msnStr, partStr := query.Get("_HLS_msn"), query.Get("_HLS_part")
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if msnStr != "" || partStr != "" {
+		msn, err := strconv.ParseUint(msnStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid _HLS_msn", http.StatusBadRequest)
+			return
+		}
+		index := -1
+		if partStr != "" {
+			index, err = strconv.Atoi(partStr)
+			if err != nil || index < 0 {
+				http.Error(w, "invalid _HLS_part", http.StatusBadRequest)
+				return
+			}
+		}
+		// A request more than two segments ahead is rejected.
+		if msn > e.nextMSN+1 {
+			http.Error(w, "_HLS_msn too far ahead", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), e.blockTimeoutLocked())
+		defer cancel()
+		ready := func() bool {
+			if e.init == nil || len(e.segments) == 0 {
+				return false
+			}
+			last := e.segments[len(e.segments)-1]
+			switch {
+			case last.msn > msn:
+				return true
+			case last.msn < msn:
+				return false
+			case index < 0:
+				return last.complete
+			default:
+				return len(last.parts) > index || last.complete
+			}
+		}
+		if !e.wait(ctx, ready) && !e.ended {
+			http.Error(w, "playlist not updated in time", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	if e.init == nil {
+		http.Error(w, "playlist not ready", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(e.playlistLocked())
+}
+
+// playlistLocked returns the media playlist. e.mu must be held.
+func (e *Egress) playlistLocked() []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:6\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", max(1, int(math.Ceil(e.targetDuration.Seconds()))))
+	if e.partTarget > 0 {
+		fmt.Fprintf(&b, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=%.3f\n", 3*e.partTarget.Seconds())
+		fmt.Fprintf(&b, "#EXT-X-PART-INF:PART-TARGET=%.3f\n", e.partTarget.Seconds())
+	} else {
+		b.WriteString("#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES\n")
+	}
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", e.firstMSNLocked())
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=%q\n", InitName)
+
+	// Parts are listed for the last complete segments and the segment in
+	// progress.
+	withParts := len(e.segments) - partSegments
+	if n := len(e.segments); n > 0 && !e.segments[n-1].complete {
+		withParts--
+	}
+	for i, seg := range e.segments {
+		if i >= withParts {
+			for j, p := range seg.parts {
+				fmt.Fprintf(&b, "#EXT-X-PART:DURATION=%.3f,URI=\"part%d.%d.m4s\"", p.duration.Seconds(), seg.msn, j)
+				if j == 0 {
+					b.WriteString(",INDEPENDENT=YES")
+				}
+				b.WriteByte('\n')
+			}
+		}
+		if seg.complete {
+			fmt.Fprintf(&b, "#EXTINF:%.3f,\nseg%d.m4s\n", seg.duration.Seconds(), seg.msn)
+		}
+	}
+
+	switch {
+	case e.ended:
+		b.WriteString("#EXT-X-ENDLIST\n")
+	case e.partTarget > 0:
+		msn, index := e.nextMSN, 0
+		if n := len(e.segments); n > 0 && !e.segments[n-1].complete {
+			msn, index = e.segments[n-1].msn, len(e.segments[n-1].parts)
+		}
+		fmt.Fprintf(&b, "#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"part%d.%d.m4s\"\n", msn, index)
+	}
+	return b.Bytes()
+}
+
+// firstMSNLocked returns the media sequence number of the first segment of
+// the playlist. e.mu must be held.
+func (e *Egress) firstMSNLocked() uint64 {
+	if len(e.segments) == 0 {
+		return e.nextMSN
+	}
+	return e.segments[0].msn
+}
+
+// blockTimeoutLocked returns how long a blocking request is held. e.mu must
+// be held.
+func (e *Egress) blockTimeoutLocked() time.Duration {
+	return max(3*e.targetDuration, minBlockTimeout)
+}
+
+func (e *Egress) serveInit(w http.ResponseWriter) {
+	e.mu.Lock()
+	init := e.init
+	e.mu.Unlock()
+
+	if init == nil {
+		http.Error(w, "init segment not received", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "video/mp4")
+	_, _ = w.Write(init)
+}
+
+func (e *Egress) serveSegment(w http.ResponseWriter, msn uint64) {
+	e.mu.Lock()
+	seg := e.segmentLocked(msn)
+	if seg == nil || !seg.complete {
+		e.mu.Unlock()
+		http.Error(w, "segment not found", http.StatusNotFound)
+		return
+	}
+	parts := seg.parts
+	e.mu.Unlock()
+
+	w.Header().Set("Content-Type", "video/mp4")
+	for _, p := range parts {
+		_, _ = w.Write(p.data)
+	}
+}
+
+func (e *Egress) servePart(w http.ResponseWriter, r *http.Request, msn uint64, index int) {
+	e.mu.Lock()
+	ctx, cancel := context.WithTimeout(r.Context(), e.blockTimeoutLocked())
+	defer cancel()
+	// A part of the segment in progress or of the next one is waited for.
+	e.wait(ctx, func() bool {
+		if msn > e.nextMSN || msn < e.firstMSNLocked() {
+			return true
+		}
+		seg := e.segmentLocked(msn)
+		return seg != nil && (len(seg.parts) > index || seg.complete)
+	})
+	var data []byte
+	if seg := e.segmentLocked(msn); seg != nil && index < len(seg.parts) {
+		data = seg.parts[index].data
+	}
+	e.mu.Unlock()
+
+	if data == nil {
+		http.Error(w, "part not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "video/mp4")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,114 @@
+package hls
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/cmaf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// get requests path from server and returns the status and body.
+func get(t *testing.T, server *httptest.Server, path string) (int, string) {
+	t.Helper()
+
+	resp, err := server.Client().Get(server.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestEgress_ServeHTTP(t *testing.T) {
+	chunks := [][]byte{testChunk(500, true, "a"), testChunk(500, false, "b"), testChunk(500, true, "c")}
+	// steps lets the test write the chunks one at a time.
+	steps := make(chan struct{})
+
+	mux := moqt.NewTrackMux(0)
+	mux.PublishFunc(t.Context(), "/live", func(tw *moqt.TrackWriter) {
+		w := cmaf.NewWriter(tw, testInit, cmaf.InitModeGroup)
+		for _, chunk := range chunks {
+			if err := w.WriteChunk(cmaf.Chunk{Data: chunk, Sync: chunk[len(chunk)-1] != 'b'}); err != nil {
+				return
+			}
+			<-steps
+		}
+		_ = w.Close()
+		_ = tw.Close()
+	})
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	tr, err := sess.Subscribe(ctx, "/live", "video", nil)
+	require.NoError(t, err)
+	e := NewEgress(tr, nil, nil)
+	defer e.Close()
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go func() {
+		_ = e.Run(runCtx)
+	}()
+	server := httptest.NewServer(http.StripPrefix("/live/video", e))
+	defer server.Close()
+
+	// The playlist request is held until the first part is received.
+	status, playlist := get(t, server, "/live/video/index.m3u8?_HLS_msn=0&_HLS_part=0")
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, playlist, `#EXT-X-PART:DURATION=0.500,URI="part0.0.m4s",INDEPENDENT=YES`)
+	assert.Contains(t, playlist, `#EXT-X-PRELOAD-HINT:TYPE=PART,URI="part0.1.m4s"`)
+
+	status, body := get(t, server, "/live/video/init.mp4")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, string(testInit), body)
+
+	// The part of the preload hint is held until it is received.
+	type response struct {
+		status int
+		body   string
+	}
+	hinted := make(chan response, 1)
+	go func() {
+		status, body := get(t, server, "/live/video/part0.1.m4s")
+		hinted <- response{status, body}
+	}()
+	steps <- struct{}{}
+	assert.Equal(t, response{http.StatusOK, string(chunks[1])}, <-hinted)
+
+	// The segment is listed once the next group starts.
+	steps <- struct{}{}
+	status, playlist = get(t, server, "/live/video/index.m3u8?_HLS_msn=1&_HLS_part=0")
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, playlist, "#EXTINF:1.000,\nseg0.m4s\n")
+	status, body = get(t, server, "/live/video/seg0.m4s")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, string(slices.Concat(chunks[0], chunks[1])), body)
+
+	status, _ = get(t, server, "/live/video/seg1.m4s")
+	assert.Equal(t, http.StatusNotFound, status, "a segment in progress is not served")
+	status, _ = get(t, server, "/live/video/index.m3u8?_HLS_msn=5")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = get(t, server, "/live/video/other")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// The playlist ends once Run returns.
+	steps <- struct{}{}
+	status, playlist = get(t, server, "/live/video/index.m3u8?_HLS_msn=1")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, strings.HasSuffix(playlist, "#EXTINF:0.500,\nseg1.m4s\n#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"part2.0.m4s\"\n"), playlist)
+	stop()
+	require.Eventually(t, func() bool {
+		_, playlist := get(t, server, "/live/video/index.m3u8")
+		return strings.HasSuffix(playlist, "seg1.m4s\n#EXT-X-ENDLIST\n")
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,28 @@
+// Package loc implements the Low Overhead Media Container (LOC) packaging
+// of draft-ietf-moq-loc for MSF tracks with packaging "loc".
+//
+// LOC carries each encoded audio or video frame as one object, with its
+// metadata, such as the capture timestamp or the decoder configuration, in
+// header properties. MOQ Lite frames have no extension headers, so the
+// properties are carried in front of the payload: a varint length followed
+// by the properties encoded as MOQT key-value pairs, where even IDs hold a
+// varint and odd IDs hold length-prefixed bytes. The object ID of a frame is
+// its position in the group, which is reported as Header.Sequence.
+//
+// A FrameWriter writes frames to a group and a FrameReader reads them back:
+//
+//	w := loc.NewFrameWriter(group)
+//	err := w.WriteFrame(loc.Header{Timestamp: captured, VideoConfig: avcC}, frame)
+//
+//	r := loc.NewFrameReader(group)
+//	for {
+//		h, payload, err := r.ReadFrame()
+//		if err != nil {
+//			break // io.EOF at the end of the group
+//		}
+//		decode(h.Timestamp, payload)
+//	}
+//
+// AppendFrame and ParseFrame encode and decode single payloads without a
+// group, such as for caches or tests.
+package loc
@@ -0,0 +1,72 @@
+package loc
+
+import (
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// FrameWriter writes LOC frames to a group.
+//
+// A FrameWriter is not safe for concurrent use.
+type FrameWriter struct {
+	group *moqt.GroupWriter
+	frame *moqt.Frame
+	buf   []byte
+	seq   uint64
+}
+
+// NewFrameWriter returns a FrameWriter writing to group.
+func NewFrameWriter(group *moqt.GroupWriter) *FrameWriter {
+	return &FrameWriter{group: group, frame: moqt.NewFrame(0)}
+}
+
+// WriteFrame writes payload, an encoded audio or video frame, with header h
+// as the next frame of the group. h.Sequence is ignored: the sequence of the
+// frame is its position in the group.
+func (w *FrameWriter) WriteFrame(h Header, payload []byte) error {
+	w.buf = AppendFrame(w.buf[:0], h, payload)
+	w.frame.Reset()
+	_, _ = w.frame.Write(w.buf)
+	if err := w.group.WriteFrame(w.frame); err != nil {
+		return err
+	}
+	w.seq++
+	return nil
+}
+
+// Sequence returns the sequence of the next frame to be written.
+func (w *FrameWriter) Sequence() uint64 {
+	return w.seq
+}
+
+// FrameReader reads LOC frames from a group.
+//
+// A FrameReader is not safe for concurrent use.
+type FrameReader struct {
+	group *moqt.GroupReader
+	frame *moqt.Frame
+	seq   uint64
+}
+
+// NewFrameReader returns a FrameReader reading from group.
+func NewFrameReader(group *moqt.GroupReader) *FrameReader {
+	return &FrameReader{group: group, frame: moqt.NewFrame(0)}
+}
+
+// ReadFrame reads the next frame of the group and returns its header and
+// payload, which are valid until the next call. It returns io.EOF at the end
+// of the group, and an error wrapping ErrMalformedHeader for a frame that is
+// not LOC-packaged; later frames can still be read.
+func (r *FrameReader) ReadFrame() (Header, []byte, error) {
+	if err := r.group.ReadFrame(r.frame); err != nil {
+		return Header{}, nil, err
+	}
+	seq := r.seq
+	r.seq++
+
+	h, payload, err := ParseFrame(r.frame.Body())
+	if err != nil {
+		return Header{}, nil, err
+	}
+	h.Sequence = seq
+	return h, payload, nil
+}
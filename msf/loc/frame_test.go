@@ -0,0 +1,69 @@
+package loc
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameWriter_FrameReader(t *testing.T) {
+	ts := time.UnixMicro(1_700_000_000_000_000)
+
+	mux := moqt.NewTrackMux(0)
+	mux.PublishFunc(t.Context(), "/live", func(tw *moqt.TrackWriter) {
+		group, err := tw.OpenGroup()
+		if err != nil {
+			return
+		}
+		w := NewFrameWriter(group)
+		_ = w.WriteFrame(Header{Timestamp: ts, VideoConfig: []byte("config")}, []byte("key"))
+		_ = w.WriteFrame(Header{Timestamp: ts.Add(33 * time.Millisecond)}, []byte("delta"))
+		// A frame that is not LOC-packaged.
+		_ = group.WriteFrame(moqt.NewFrame(0))
+		_ = w.WriteFrame(Header{}, []byte("last"))
+		_ = group.Close()
+		<-tw.Context().Done()
+	})
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	tr, err := sess.Subscribe(ctx, "/live", "video", nil)
+	require.NoError(t, err)
+	defer tr.Close()
+	group, err := tr.AcceptGroup(ctx)
+	require.NoError(t, err)
+
+	r := NewFrameReader(group)
+	h, payload, err := r.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), h.Sequence)
+	assert.True(t, ts.Equal(h.Timestamp))
+	assert.Equal(t, "config", string(h.VideoConfig))
+	assert.Equal(t, "key", string(payload))
+
+	h, payload, err = r.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), h.Sequence)
+	assert.Equal(t, 33*time.Millisecond, h.Timestamp.Sub(ts))
+	assert.Empty(t, h.VideoConfig)
+	assert.Equal(t, "delta", string(payload))
+
+	_, _, err = r.ReadFrame()
+	assert.ErrorIs(t, err, ErrMalformedHeader)
+
+	h, payload, err = r.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), h.Sequence)
+	assert.True(t, h.Timestamp.IsZero())
+	assert.Equal(t, "last", string(payload))
+
+	_, _, err = r.ReadFrame()
+	assert.ErrorIs(t, err, io.EOF)
+}
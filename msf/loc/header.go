@@ -0,0 +1,160 @@
+package loc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// Property IDs of the LOC header properties defined by draft-ietf-moq-loc.
+const (
+	// PropertyTimestamp is the capture time of the frame in microseconds
+	// since the Unix epoch.
+	PropertyTimestamp uint64 = 0x02
+	// PropertyVideoFrameMarking is the video frame marking of RFC 9626.
+	PropertyVideoFrameMarking uint64 = 0x04
+	// PropertyAudioLevel is the audio level of RFC 6464.
+	PropertyAudioLevel uint64 = 0x06
+	// PropertyVideoConfig is the codec-specific decoder configuration, such
+	// as an AVCDecoderConfigurationRecord.
+	PropertyVideoConfig uint64 = 0x0D
+)
+
+// ErrMalformedHeader is returned by ParseFrame and FrameReader.ReadFrame for
+// a payload whose LOC header cannot be decoded.
+var ErrMalformedHeader = errors.New("loc: malformed header")
+
+// Property is a LOC header property that Header has no field for. It is
+// preserved so that frames can be forwarded unchanged.
+type Property struct {
+	// ID is the property ID. Even IDs hold Value and odd IDs hold Bytes.
+	ID uint64
+
+	Value uint64
+	Bytes []byte
+}
+
+// Header is the metadata carried with a LOC frame.
+type Header struct {
+	// Sequence is the position of the frame in its group, starting at 0. It
+	// is set by FrameReader and ignored when writing.
+	Sequence uint64
+
+	// Timestamp is the capture time of the frame. Zero omits it.
+	Timestamp time.Time
+
+	// VideoConfig is the decoder configuration, usually sent with the first
+	// frame of a group. Empty omits it.
+	VideoConfig []byte
+
+	// VideoFrameMarking and AudioLevel hold the properties of the same name,
+	// if present.
+	VideoFrameMarking *uint64
+	AudioLevel        *uint64
+
+	// Properties holds the other properties, in order.
+	Properties []Property
+}
+
+// AppendFrame appends the LOC encoding of a frame with header h and payload
+// to dst and returns the extended buffer.
+func AppendFrame(dst []byte, h Header, payload []byte) []byte {
+	props := appendProperties(nil, h)
+	dst = quicvarint.Append(dst, uint64(len(props)))
+	dst = append(dst, props...)
+	return append(dst, payload...)
+}
+
+func appendProperties(b []byte, h Header) []byte {
+	if !h.Timestamp.IsZero() {
+		b = appendVarintProperty(b, PropertyTimestamp, uint64(h.Timestamp.UnixMicro()))
+	}
+	if h.VideoFrameMarking != nil {
+		b = appendVarintProperty(b, PropertyVideoFrameMarking, *h.VideoFrameMarking)
+	}
+	if h.AudioLevel != nil {
+		b = appendVarintProperty(b, PropertyAudioLevel, *h.AudioLevel)
+	}
+	if len(h.VideoConfig) > 0 {
+		b = appendBytesProperty(b, PropertyVideoConfig, h.VideoConfig)
+	}
+	for _, p := range h.Properties {
+		if p.ID%2 == 0 {
+			b = appendVarintProperty(b, p.ID, p.Value)
+		} else {
+			b = appendBytesProperty(b, p.ID, p.Bytes)
+		}
+	}
+	return b
+}
+
+func appendVarintProperty(b []byte, id, v uint64) []byte {
+	b = quicvarint.Append(b, id)
+	return quicvarint.Append(b, v)
+}
+
+func appendBytesProperty(b []byte, id uint64, v []byte) []byte {
+	b = quicvarint.Append(b, id)
+	b = quicvarint.Append(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// ParseFrame decodes a LOC frame and returns its header and payload. The
+// payload and the byte slices of the header alias data. Header.Sequence is
+// left zero.
+func ParseFrame(data []byte) (Header, []byte, error) {
+	n, l, err := quicvarint.Parse(data)
+	if err != nil || uint64(len(data)-l) < n {
+		return Header{}, nil, fmt.Errorf("%w: truncated header", ErrMalformedHeader)
+	}
+	props, payload := data[l:l+int(n)], data[l+int(n):]
+
+	var h Header
+	for len(props) > 0 {
+		id, l, err := quicvarint.Parse(props)
+		if err != nil {
+			return Header{}, nil, fmt.Errorf("%w: truncated property", ErrMalformedHeader)
+		}
+		props = props[l:]
+
+		v, l, err := quicvarint.Parse(props)
+		if err != nil {
+			return Header{}, nil, fmt.Errorf("%w: truncated property %#x", ErrMalformedHeader, id)
+		}
+		props = props[l:]
+
+		if id%2 == 0 {
+			h.setVarint(id, v)
+			continue
+		}
+		if uint64(len(props)) < v {
+			return Header{}, nil, fmt.Errorf("%w: truncated property %#x", ErrMalformedHeader, id)
+		}
+		h.setBytes(id, props[:v:v])
+		props = props[v:]
+	}
+	return h, payload, nil
+}
+
+func (h *Header) setVarint(id, v uint64) {
+	switch id {
+	case PropertyTimestamp:
+		h.Timestamp = time.UnixMicro(int64(v))
+	case PropertyVideoFrameMarking:
+		h.VideoFrameMarking = &v
+	case PropertyAudioLevel:
+		h.AudioLevel = &v
+	default:
+		h.Properties = append(h.Properties, Property{ID: id, Value: v})
+	}
+}
+
+func (h *Header) setBytes(id uint64, v []byte) {
+	if id == PropertyVideoConfig {
+		h.VideoConfig = v
+		return
+	}
+	h.Properties = append(h.Properties, Property{ID: id, Bytes: v})
+}
@@ -0,0 +1,86 @@
+package loc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendFrame_ParseFrame(t *testing.T) {
+	ts := time.UnixMicro(1_700_000_000_123_456)
+	tests := map[string]struct {
+		header  Header
+		payload string
+	}{
+		"empty header": {
+			payload: "frame",
+		},
+		"timestamp": {
+			header:  Header{Timestamp: ts},
+			payload: "frame",
+		},
+		"video": {
+			header: Header{
+				Timestamp:         ts,
+				VideoConfig:       []byte{0x01, 0x64, 0x00, 0x1f},
+				VideoFrameMarking: new(uint64(0x80)),
+			},
+			payload: "keyframe",
+		},
+		"audio": {
+			header:  Header{Timestamp: ts, AudioLevel: new(uint64(0))},
+			payload: "opus",
+		},
+		"other properties": {
+			header: Header{Properties: []Property{
+				{ID: 0x20, Value: 300},
+				{ID: 0x21, Bytes: []byte("meta")},
+			}},
+			payload: "",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := AppendFrame([]byte("prefix"), tt.header, []byte(tt.payload))
+			require.Equal(t, "prefix", string(data[:6]))
+
+			h, payload, err := ParseFrame(data[6:])
+			require.NoError(t, err)
+			assert.Equal(t, tt.payload, string(payload))
+			assert.True(t, tt.header.Timestamp.Equal(h.Timestamp))
+			h.Timestamp = tt.header.Timestamp
+			assert.Equal(t, tt.header, h)
+		})
+	}
+}
+
+func TestParseFrame_Malformed(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":                  {},
+		"header longer than all": {0x05, 0x02},
+		"missing value":          {0x01, 0x02},
+		"truncated bytes":        {0x03, 0x0d, 0x05, 0x01},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ParseFrame(data)
+			assert.ErrorIs(t, err, ErrMalformedHeader)
+		})
+	}
+}
+
+func TestParseFrame_Wire(t *testing.T) {
+	// Capture timestamp 1 and a 2-byte decoder configuration.
+	data := []byte{0x06, 0x02, 0x01, 0x0d, 0x02, 0xaa, 0xbb, 'x'}
+
+	h, payload, err := ParseFrame(data)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), h.Timestamp.UnixMicro())
+	assert.Equal(t, []byte{0xaa, 0xbb}, h.VideoConfig)
+	assert.Equal(t, "x", string(payload))
+	assert.Equal(t, data, AppendFrame(nil, h, payload))
+}
@@ -0,0 +1,31 @@
+// Package rtp ingests RTP streams into MOQ, so that existing encoders,
+// such as SRT or RTP hardware encoders and WebRTC tracks, can feed a gomoqt
+// relay.
+//
+// A Publisher depacketizes the H.264 (RFC 6184) or Opus (RFC 7587) payload
+// of an RTP stream and publishes the frames on a track with LOC packaging,
+// see the msf/loc package. H.264 groups start at IDR access units, which
+// carry the decoder configuration, and every Opus frame is a group of its
+// own:
+//
+//	mux.PublishFunc(ctx, "/live", func(tw *moqt.TrackWriter) {
+//		conn, err := net.ListenPacket("udp", ":5004")
+//		if err != nil {
+//			tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
+//			return
+//		}
+//		defer conn.Close()
+//		_ = rtp.NewPublisher(tw, rtp.CodecH264).Serve(conn)
+//	})
+//
+// Packets read elsewhere, such as from a WebRTC track, are published with
+// WriteRTP. A Publisher writes to one track, so the gateway usually
+// publishes to a relay, which serves the subscribers.
+//
+// A Subscriber does the opposite, for gateways to RTP receivers and WebRTC
+// peers: it plays a track of LOC frames as an RTP stream, packetized by a
+// Packetizer.
+//
+// Packet and H264Depacketizer decode RTP packets and H.264 payloads on their
+// own. Packets are expected in order: there is no jitter buffer.
+package rtp
@@ -0,0 +1,227 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// H.264 NAL unit types handled by the depacketizer and the Publisher.
+const (
+	nalIDR   = 5
+	nalSPS   = 7
+	nalPPS   = 8
+	nalAUD   = 9
+	nalSTAPA = 24
+	nalFUA   = 28
+)
+
+// AccessUnit is an H.264 access unit: the NAL units of one picture.
+type AccessUnit struct {
+	// Timestamp is the RTP timestamp of the picture.
+	Timestamp uint32
+
+	// NALUs are the NAL units of the picture, without start codes.
+	NALUs [][]byte
+
+	// Keyframe reports whether the access unit holds an IDR picture.
+	Keyframe bool
+
+	// Discontinuity reports whether packets were lost before the access
+	// unit, so that it may refer to pictures the decoder never got.
+	Discontinuity bool
+}
+
+// H264Depacketizer reassembles H.264 access units from RTP packets with the
+// payload format of RFC 6184, in non-interleaved mode: single NAL units,
+// STAP-A and FU-A. An access unit ends at a packet with the marker bit set,
+// or at the first packet of the next picture.
+//
+// Packets must be pushed in order. An access unit that lost a packet is
+// discarded, and the next one is marked as a Discontinuity. Packets arriving
+// late are ignored.
+//
+// The zero value is ready to use. An H264Depacketizer is not safe for
+// concurrent use.
+type H264Depacketizer struct {
+	started bool
+	lastSeq uint16
+
+	// open reports whether an access unit is in progress.
+	open       bool
+	au         AccessUnit
+	fragment   []byte
+	inFragment bool
+	// broken reports whether the access unit in progress lost a packet.
+	broken bool
+	// lost reports whether packets were lost since the last access unit.
+	lost bool
+}
+
+// Push adds the packet p, appends the access units it completes to dst, and
+// returns the extended slice. The access units do not alias p.
+func (d *H264Depacketizer) Push(dst []AccessUnit, p *Packet) ([]AccessUnit, error) {
+	var gap bool
+	if d.started {
+		diff := int16(p.SequenceNumber - d.lastSeq)
+		if diff <= 0 {
+			return dst, nil
+		}
+		gap = diff > 1
+	}
+	d.started = true
+	d.lastSeq = p.SequenceNumber
+
+	// The lost packets may belong to the access unit in progress as well
+	// as to the one of p.
+	if d.open && p.Timestamp != d.au.Timestamp {
+		d.broken = d.broken || gap
+		dst = d.flush(dst)
+	}
+	if gap {
+		d.fragment, d.inFragment = nil, false
+		d.broken = true
+	}
+	d.open = true
+	d.au.Timestamp = p.Timestamp
+
+	if err := d.depacketize(p.Payload); err != nil {
+		d.broken = true
+		if p.Marker {
+			dst = d.flush(dst)
+		}
+		return dst, err
+	}
+	if p.Marker {
+		dst = d.flush(dst)
+	}
+	return dst, nil
+}
+
+func (d *H264Depacketizer) depacketize(payload []byte) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("%w: empty H.264 payload", ErrMalformedPacket)
+	}
+	switch typ := payload[0] & 0x1F; {
+	case typ >= 1 && typ <= 23:
+		d.add(payload)
+	case typ == nalSTAPA:
+		for b := payload[1:]; len(b) > 0; {
+			if len(b) < 2 {
+				return fmt.Errorf("%w: truncated STAP-A", ErrMalformedPacket)
+			}
+			n := int(binary.BigEndian.Uint16(b))
+			if n == 0 || len(b) < 2+n {
+				return fmt.Errorf("%w: truncated STAP-A", ErrMalformedPacket)
+			}
+			d.add(b[2 : 2+n])
+			b = b[2+n:]
+		}
+	case typ == nalFUA:
+		if len(payload) < 2 {
+			return fmt.Errorf("%w: truncated FU-A", ErrMalformedPacket)
+		}
+		header := payload[1]
+		if header&0x80 != 0 {
+			d.fragment = append(d.fragment[:0], payload[0]&0xE0|header&0x1F)
+			d.inFragment = true
+		} else if !d.inFragment {
+			// The first fragment was lost.
+			d.broken = true
+			return nil
+		}
+		d.fragment = append(d.fragment, payload[2:]...)
+		if header&0x40 != 0 {
+			d.add(d.fragment)
+			d.fragment, d.inFragment = nil, false
+		}
+	default:
+		return fmt.Errorf("%w: unsupported NAL unit type %d", ErrMalformedPacket, typ)
+	}
+	return nil
+}
+
+// add appends a copy of nalu to the access unit in progress.
+func (d *H264Depacketizer) add(nalu []byte) {
+	if nalu[0]&0x1F == nalIDR {
+		d.au.Keyframe = true
+	}
+	d.au.NALUs = append(d.au.NALUs, append([]byte(nil), nalu...))
+}
+
+// flush ends the access unit in progress, appending it to dst unless it is
+// broken or empty.
+func (d *H264Depacketizer) flush(dst []AccessUnit) []AccessUnit {
+	au := d.au
+	d.au = AccessUnit{}
+	d.open = false
+	d.fragment, d.inFragment = nil, false
+
+	if d.broken {
+		d.broken = false
+		d.lost = true
+		return dst
+	}
+	if len(au.NALUs) == 0 {
+		return dst
+	}
+	au.Discontinuity = d.lost
+	d.lost = false
+	return append(dst, au)
+}
+
+// AVCDecoderConfig returns the AVCDecoderConfigurationRecord of ISO/IEC
+// 14496-15 for the given SPS and PPS NAL units, with 4-byte NAL unit
+// lengths. It is the decoder configuration of LOC and of the avcC box. It
+// returns nil if sps is too short to hold a profile and level.
+func AVCDecoderConfig(sps, pps []byte) []byte {
+	if len(sps) < 4 {
+		return nil
+	}
+	b := []byte{1, sps[1], sps[2], sps[3], 0xFC | 3, 0xE0 | 1}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(sps)))
+	b = append(b, sps...)
+	b = append(b, 1)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(pps)))
+	return append(b, pps...)
+}
+
+// ParseAVCDecoderConfig returns the SPS and PPS NAL units of an
+// AVCDecoderConfigurationRecord, such as the VideoConfig of the frames
+// written by a Publisher. The returned slices alias config.
+func ParseAVCDecoderConfig(config []byte) (sps, pps [][]byte, err error) {
+	if len(config) < 6 || config[0] != 1 {
+		return nil, nil, fmt.Errorf("%w: invalid AVCDecoderConfigurationRecord", ErrMalformedPacket)
+	}
+	b := config[5:]
+	// read reads a count, of which mask holds the bits, and as many NAL
+	// units with 2-byte lengths.
+	read := func(mask byte) ([][]byte, bool) {
+		if len(b) < 1 {
+			return nil, false
+		}
+		n := int(b[0] & mask)
+		b = b[1:]
+		nalus := make([][]byte, 0, n)
+		for range n {
+			if len(b) < 2 {
+				return nil, false
+			}
+			size := int(binary.BigEndian.Uint16(b))
+			if len(b) < 2+size {
+				return nil, false
+			}
+			nalus = append(nalus, b[2:2+size])
+			b = b[2+size:]
+		}
+		return nalus, true
+	}
+	sps, ok := read(0x1F)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: truncated AVCDecoderConfigurationRecord", ErrMalformedPacket)
+	}
+	pps, ok = read(0xFF)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: truncated AVCDecoderConfigurationRecord", ErrMalformedPacket)
+	}
+	return sps, pps, nil
+}
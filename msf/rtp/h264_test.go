@@ -0,0 +1,179 @@
+package rtp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test NAL units, each starting with its NAL unit header.
+var (
+	testSPS   = []byte{0x67, 0x42, 0xC0, 0x1F, 0xAA}
+	testPPS   = []byte{0x68, 0xCE, 0x3C, 0x80}
+	testIDR   = []byte{0x65, 0x88, 0x84, 0x00, 0x33, 0x44}
+	testSlice = []byte{0x41, 0x9A, 0x02, 0x03}
+)
+
+// stapA returns a STAP-A payload aggregating nalus.
+func stapA(nalus ...[]byte) []byte {
+	b := []byte{0x78}
+	for _, n := range nalus {
+		b = append(b, byte(len(n)>>8), byte(len(n)))
+		b = append(b, n...)
+	}
+	return b
+}
+
+// fuA returns the FU-A payloads fragmenting nalu in fragments of size
+// bytes.
+func fuA(nalu []byte, size int) [][]byte {
+	var payloads [][]byte
+	data := nalu[1:]
+	for i := 0; i < len(data); i += size {
+		header := nalu[0] & 0x1F
+		if i == 0 {
+			header |= 0x80
+		}
+		end := min(i+size, len(data))
+		if end == len(data) {
+			header |= 0x40
+		}
+		payloads = append(payloads, append([]byte{nalu[0]&0xE0 | nalFUA, header}, data[i:end]...))
+	}
+	return payloads
+}
+
+func TestH264Depacketizer(t *testing.T) {
+	fragments := fuA(testIDR, 2)
+	require.Len(t, fragments, 3)
+
+	tests := map[string]struct {
+		packets []Packet
+		want    []AccessUnit
+		wantErr bool
+	}{
+		"single NAL units": {
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 10, Payload: testSPS},
+				{SequenceNumber: 2, Timestamp: 10, Payload: testIDR, Marker: true},
+				{SequenceNumber: 3, Timestamp: 20, Payload: testSlice, Marker: true},
+			},
+			want: []AccessUnit{
+				{Timestamp: 10, NALUs: [][]byte{testSPS, testIDR}, Keyframe: true},
+				{Timestamp: 20, NALUs: [][]byte{testSlice}},
+			},
+		},
+		"STAP-A": {
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 10, Payload: stapA(testSPS, testPPS, testIDR), Marker: true},
+			},
+			want: []AccessUnit{
+				{Timestamp: 10, NALUs: [][]byte{testSPS, testPPS, testIDR}, Keyframe: true},
+			},
+		},
+		"FU-A": {
+			packets: []Packet{
+				{SequenceNumber: 65535, Timestamp: 10, Payload: fragments[0]},
+				{SequenceNumber: 0, Timestamp: 10, Payload: fragments[1]},
+				{SequenceNumber: 1, Timestamp: 10, Payload: fragments[2], Marker: true},
+			},
+			want: []AccessUnit{
+				{Timestamp: 10, NALUs: [][]byte{testIDR}, Keyframe: true},
+			},
+		},
+		"timestamp change ends an access unit": {
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 10, Payload: testSlice},
+				{SequenceNumber: 2, Timestamp: 20, Payload: testSlice, Marker: true},
+			},
+			want: []AccessUnit{
+				{Timestamp: 10, NALUs: [][]byte{testSlice}},
+				{Timestamp: 20, NALUs: [][]byte{testSlice}},
+			},
+		},
+		"lost fragment": {
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 10, Payload: fragments[0]},
+				{SequenceNumber: 3, Timestamp: 10, Payload: fragments[2], Marker: true},
+				{SequenceNumber: 4, Timestamp: 20, Payload: testSlice, Marker: true},
+			},
+			want: []AccessUnit{
+				{Timestamp: 20, NALUs: [][]byte{testSlice}, Discontinuity: true},
+			},
+		},
+		"lost marker": {
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 10, Payload: testSlice},
+				{SequenceNumber: 3, Timestamp: 20, Payload: testSlice, Marker: true},
+				{SequenceNumber: 4, Timestamp: 30, Payload: testSlice, Marker: true},
+			},
+			want: []AccessUnit{
+				{Timestamp: 30, NALUs: [][]byte{testSlice}, Discontinuity: true},
+			},
+		},
+		"late packet": {
+			packets: []Packet{
+				{SequenceNumber: 2, Timestamp: 10, Payload: testSlice, Marker: true},
+				{SequenceNumber: 1, Timestamp: 0, Payload: testIDR, Marker: true},
+			},
+			want: []AccessUnit{
+				{Timestamp: 10, NALUs: [][]byte{testSlice}},
+			},
+		},
+		"unsupported": {
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 10, Payload: []byte{0x79, 0, 0}, Marker: true},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				d   H264Depacketizer
+				got []AccessUnit
+			)
+			for _, p := range tt.packets {
+				var err error
+				got, err = d.Push(got, &p)
+				if tt.wantErr {
+					assert.ErrorIs(t, err, ErrMalformedPacket)
+					return
+				}
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAVCDecoderConfig(t *testing.T) {
+	want := []byte{1, 0x42, 0xC0, 0x1F, 0xFF, 0xE1, 0, 5}
+	want = append(want, testSPS...)
+	want = append(want, 1, 0, 4)
+	want = append(want, testPPS...)
+	assert.Equal(t, want, AVCDecoderConfig(testSPS, testPPS))
+	assert.Nil(t, AVCDecoderConfig(testSPS[:3], testPPS))
+}
+
+func TestParseAVCDecoderConfig(t *testing.T) {
+	config := AVCDecoderConfig(testSPS, testPPS)
+	sps, pps, err := ParseAVCDecoderConfig(config)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testSPS}, sps)
+	assert.Equal(t, [][]byte{testPPS}, pps)
+
+	for name, data := range map[string][]byte{
+		"empty":       nil,
+		"version 0":   append([]byte{0}, config[1:]...),
+		"short SPS":   config[:10],
+		"missing PPS": config[:8+len(testSPS)],
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ParseAVCDecoderConfig(data)
+			assert.ErrorIs(t, err, ErrMalformedPacket)
+		})
+	}
+}
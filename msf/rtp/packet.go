@@ -0,0 +1,97 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrMalformedPacket is returned for an RTP packet, or an RTP payload, that
+// cannot be decoded.
+var ErrMalformedPacket = errors.New("rtp: malformed packet")
+
+// Packet is an RTP packet of RFC 3550.
+type Packet struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	CSRC           []uint32
+
+	// Payload is the payload of the packet, without padding. It aliases the
+	// buffer passed to Unmarshal.
+	Payload []byte
+}
+
+// Unmarshal decodes the RTP packet in data into p. The header extension,
+// if any, is skipped.
+func (p *Packet) Unmarshal(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("%w: %d bytes", ErrMalformedPacket, len(data))
+	}
+	if v := data[0] >> 6; v != 2 {
+		return fmt.Errorf("%w: version %d", ErrMalformedPacket, v)
+	}
+	padding := data[0]&0x20 != 0
+	extension := data[0]&0x10 != 0
+	csrcCount := int(data[0] & 0x0F)
+
+	p.Marker = data[1]&0x80 != 0
+	p.PayloadType = data[1] & 0x7F
+	p.SequenceNumber = binary.BigEndian.Uint16(data[2:])
+	p.Timestamp = binary.BigEndian.Uint32(data[4:])
+	p.SSRC = binary.BigEndian.Uint32(data[8:])
+
+	n := 12
+	if len(data) < n+4*csrcCount {
+		return fmt.Errorf("%w: truncated CSRC list", ErrMalformedPacket)
+	}
+	p.CSRC = p.CSRC[:0]
+	for range csrcCount {
+		p.CSRC = append(p.CSRC, binary.BigEndian.Uint32(data[n:]))
+		n += 4
+	}
+
+	if extension {
+		if len(data) < n+4 {
+			return fmt.Errorf("%w: truncated header extension", ErrMalformedPacket)
+		}
+		n += 4 + 4*int(binary.BigEndian.Uint16(data[n+2:]))
+		if len(data) < n {
+			return fmt.Errorf("%w: truncated header extension", ErrMalformedPacket)
+		}
+	}
+
+	end := len(data)
+	if padding {
+		if end == n {
+			return fmt.Errorf("%w: missing padding", ErrMalformedPacket)
+		}
+		pad := int(data[end-1])
+		if pad == 0 || end-n < pad {
+			return fmt.Errorf("%w: invalid padding", ErrMalformedPacket)
+		}
+		end -= pad
+	}
+	p.Payload = data[n:end]
+	return nil
+}
+
+// Append appends the encoding of p to dst and returns the extended buffer.
+// It writes no header extension and no padding.
+func (p *Packet) Append(dst []byte) []byte {
+	first := byte(0x80) | byte(len(p.CSRC)&0x0F)
+	second := p.PayloadType & 0x7F
+	if p.Marker {
+		second |= 0x80
+	}
+	dst = append(dst, first, second)
+	dst = binary.BigEndian.AppendUint16(dst, p.SequenceNumber)
+	dst = binary.BigEndian.AppendUint32(dst, p.Timestamp)
+	dst = binary.BigEndian.AppendUint32(dst, p.SSRC)
+	for _, csrc := range p.CSRC[:min(len(p.CSRC), 15)] {
+		dst = binary.BigEndian.AppendUint32(dst, csrc)
+	}
+	return append(dst, p.Payload...)
+}
@@ -0,0 +1,76 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// marshalPacket encodes an RTP packet with the given header fields and
+// payload, and no CSRC, extension or padding.
+func marshalPacket(marker bool, seq uint16, ts, ssrc uint32, payload []byte) []byte {
+	b := []byte{0x80, 96}
+	if marker {
+		b[1] |= 0x80
+	}
+	b = binary.BigEndian.AppendUint16(b, seq)
+	b = binary.BigEndian.AppendUint32(b, ts)
+	b = binary.BigEndian.AppendUint32(b, ssrc)
+	return append(b, payload...)
+}
+
+func TestPacket_Unmarshal(t *testing.T) {
+	header := func(first byte) []byte {
+		return []byte{first, 0x80 | 111, 0x01, 0x02, 0, 0, 0x03, 0xE8, 0xDE, 0xAD, 0xBE, 0xEF}
+	}
+
+	tests := map[string]struct {
+		data    []byte
+		want    Packet
+		wantErr bool
+	}{
+		"plain": {
+			data: append(header(0x80), "abc"...),
+			want: Packet{Marker: true, PayloadType: 111, SequenceNumber: 0x0102, Timestamp: 1000, SSRC: 0xDEADBEEF, CSRC: []uint32{}, Payload: []byte("abc")},
+		},
+		"csrc, extension and padding": {
+			data: append(append(header(0xB1), 0, 0, 0, 7, 0xBE, 0xDE, 0, 1, 1, 2, 3, 4), "abc\x00\x00\x03"...),
+			want: Packet{Marker: true, PayloadType: 111, SequenceNumber: 0x0102, Timestamp: 1000, SSRC: 0xDEADBEEF, CSRC: []uint32{7}, Payload: []byte("abc")},
+		},
+		"short":                {data: header(0x80)[:11], wantErr: true},
+		"version 1":            {data: header(0x40), wantErr: true},
+		"truncated csrc":       {data: header(0x82), wantErr: true},
+		"truncated extension":  {data: append(header(0x90), 0xBE, 0xDE, 0, 1), wantErr: true},
+		"padding beyond data":  {data: append(header(0xA0), 'a', 5), wantErr: true},
+		"padding without data": {data: header(0xA0), wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var p Packet
+			err := p.Unmarshal(tt.data)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrMalformedPacket)
+				return
+			}
+			require.NoError(t, err)
+			if len(tt.want.CSRC) == 0 {
+				assert.Empty(t, p.CSRC)
+				p.CSRC, tt.want.CSRC = nil, nil
+			}
+			assert.Equal(t, tt.want, p)
+		})
+	}
+}
+
+func TestPacket_Append(t *testing.T) {
+	p := Packet{Marker: true, PayloadType: 96, SequenceNumber: 7, Timestamp: 90000, SSRC: 1, CSRC: []uint32{2}, Payload: []byte("abc")}
+	var got Packet
+	require.NoError(t, got.Unmarshal(p.Append(nil)))
+	assert.Equal(t, p, got)
+
+	assert.Equal(t, marshalPacket(true, 1, 2, 3, []byte("x")),
+		(&Packet{Marker: true, PayloadType: 96, SequenceNumber: 1, Timestamp: 2, SSRC: 3, Payload: []byte("x")}).Append(nil))
+}
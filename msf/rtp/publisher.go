@@ -0,0 +1,252 @@
+package rtp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/loc"
+)
+
+// Codec is the codec of the RTP stream ingested by a Publisher.
+type Codec uint8
+
+const (
+	// CodecH264 is H.264 video with the payload format of RFC 6184.
+	CodecH264 Codec = iota + 1
+	// CodecOpus is Opus audio with the payload format of RFC 7587.
+	CodecOpus
+)
+
+// ClockRate returns the RTP clock rate of the codec in Hz.
+func (c Codec) ClockRate() uint32 {
+	switch c {
+	case CodecH264:
+		return 90000
+	case CodecOpus:
+		return 48000
+	default:
+		return 0
+	}
+}
+
+func (c Codec) String() string {
+	switch c {
+	case CodecH264:
+		return "H.264"
+	case CodecOpus:
+		return "Opus"
+	default:
+		return fmt.Sprintf("Codec(%d)", uint8(c))
+	}
+}
+
+// maxPacketSize is the size of the buffer Serve reads packets into.
+const maxPacketSize = 1 << 16
+
+// Publisher publishes an RTP stream on a track as LOC frames.
+//
+// For H.264, each access unit is one frame, in the length-prefixed format
+// of ISO/IEC 14496-15 with 4-byte lengths. Each IDR access unit opens a new
+// group, and carries the AVCDecoderConfig of the last SPS and PPS received
+// as its VideoConfig; the SPS, PPS and access unit delimiters are left out
+// of the frames. Access units before the first IDR are dropped, as are the
+// ones after a packet loss, until the next IDR, so that every group decodes.
+//
+// For Opus, each packet is one frame, in its own group, as every Opus frame
+// decodes on its own.
+//
+// The Timestamp of each frame is the time the first packet of the stream
+// was received, advanced by the RTP timestamps. A change of SSRC, such as an
+// encoder restart, starts over.
+//
+// A Publisher is not safe for concurrent use.
+type Publisher struct {
+	tw    *moqt.TrackWriter
+	codec Codec
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+
+	packet Packet
+	h264   H264Depacketizer
+	aus    []AccessUnit
+	sps    []byte
+	pps    []byte
+	buf    []byte
+
+	started bool
+	ssrc    uint32
+	// base is the capture time of the RTP timestamp baseTS.
+	base   time.Time
+	baseTS uint32
+	// elapsed is the extended RTP timestamp of the last packet, relative to
+	// baseTS, so that the timestamps keep growing when they wrap.
+	elapsed int64
+	lastTS  uint32
+
+	group  *moqt.GroupWriter
+	frames *loc.FrameWriter
+}
+
+// NewPublisher returns a Publisher publishing an RTP stream of codec on tw.
+func NewPublisher(tw *moqt.TrackWriter, codec Codec) *Publisher {
+	return &Publisher{tw: tw, codec: codec, now: time.Now}
+}
+
+// WriteRTP publishes the RTP packet in data, such as a packet read from a
+// UDP socket or from a WebRTC track. Packets must be written in order. It
+// returns an error wrapping ErrMalformedPacket for a packet that cannot be
+// decoded, after which the stream can go on, and the error of the track
+// otherwise.
+func (p *Publisher) WriteRTP(data []byte) error {
+	if err := p.packet.Unmarshal(data); err != nil {
+		return err
+	}
+	pkt := &p.packet
+
+	if !p.started || pkt.SSRC != p.ssrc {
+		if err := p.Close(); err != nil {
+			return err
+		}
+		p.h264 = H264Depacketizer{}
+		p.sps, p.pps = nil, nil
+		p.started = true
+		p.ssrc = pkt.SSRC
+		p.base = p.now()
+		p.baseTS = pkt.Timestamp
+		p.lastTS = pkt.Timestamp
+		p.elapsed = 0
+	}
+
+	switch p.codec {
+	case CodecH264:
+		var err error
+		p.aus, err = p.h264.Push(p.aus[:0], pkt)
+		for _, au := range p.aus {
+			if werr := p.writeAccessUnit(au); werr != nil {
+				return werr
+			}
+		}
+		clear(p.aus)
+		return err
+	case CodecOpus:
+		if len(pkt.Payload) == 0 {
+			return nil
+		}
+		return p.writeGroup(p.timestamp(pkt.Timestamp), pkt.Payload)
+	default:
+		return fmt.Errorf("rtp: unsupported codec %v", p.codec)
+	}
+}
+
+func (p *Publisher) writeAccessUnit(au AccessUnit) error {
+	p.buf = p.buf[:0]
+	for _, nalu := range au.NALUs {
+		switch nalu[0] & 0x1F {
+		case nalSPS:
+			p.sps = nalu
+			continue
+		case nalPPS:
+			p.pps = nalu
+			continue
+		case nalAUD:
+			continue
+		}
+		p.buf = binary.BigEndian.AppendUint32(p.buf, uint32(len(nalu)))
+		p.buf = append(p.buf, nalu...)
+	}
+	ts := p.timestamp(au.Timestamp)
+	if len(p.buf) == 0 {
+		return nil
+	}
+
+	if au.Keyframe {
+		return p.openGroup(ts, AVCDecoderConfig(p.sps, p.pps), p.buf)
+	}
+	if au.Discontinuity {
+		if err := p.Close(); err != nil {
+			return err
+		}
+	}
+	if p.group == nil {
+		return nil
+	}
+	return p.frames.WriteFrame(loc.Header{Timestamp: ts}, p.buf)
+}
+
+// openGroup closes the current group and opens a new one, starting with
+// payload.
+func (p *Publisher) openGroup(ts time.Time, config, payload []byte) error {
+	if err := p.Close(); err != nil {
+		return err
+	}
+	group, err := p.tw.OpenGroup()
+	if err != nil {
+		return err
+	}
+	p.group = group
+	p.frames = loc.NewFrameWriter(group)
+	if err := p.frames.WriteFrame(loc.Header{Timestamp: ts, VideoConfig: config}, payload); err != nil {
+		group.CancelWrite(moqt.InternalGroupErrorCode)
+		p.group, p.frames = nil, nil
+		return err
+	}
+	return nil
+}
+
+// writeGroup writes payload as the only frame of a new group.
+func (p *Publisher) writeGroup(ts time.Time, payload []byte) error {
+	if err := p.openGroup(ts, nil, payload); err != nil {
+		return err
+	}
+	return p.Close()
+}
+
+// timestamp returns the capture time of the RTP timestamp ts.
+func (p *Publisher) timestamp(ts uint32) time.Time {
+	p.elapsed += int64(int32(ts - p.lastTS))
+	p.lastTS = ts
+	return p.base.Add(time.Duration(p.elapsed) * time.Second / time.Duration(p.codec.ClockRate()))
+}
+
+// Serve reads RTP packets from conn and publishes them until the track or
+// conn is closed. Packets that cannot be decoded are skipped. The packets
+// of a single stream are expected on conn, such as on a port an encoder
+// sends to.
+func (p *Publisher) Serve(conn net.PacketConn) error {
+	defer p.Close()
+
+	stop := context.AfterFunc(p.tw.Context(), func() {
+		_ = conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if p.tw.Context().Err() != nil {
+				return context.Cause(p.tw.Context())
+			}
+			return err
+		}
+		if err := p.WriteRTP(buf[:n]); err != nil && !errors.Is(err, ErrMalformedPacket) {
+			return err
+		}
+	}
+}
+
+// Close closes the current group. It does not close the track.
+func (p *Publisher) Close() error {
+	if p.group == nil {
+		return nil
+	}
+	err := p.group.Close()
+	p.group, p.frames = nil, nil
+	return err
+}
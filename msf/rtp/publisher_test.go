@@ -0,0 +1,174 @@
+package rtp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/loc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFrame is a LOC frame read back by readGroups.
+type testFrame struct {
+	elapsed time.Duration
+	config  []byte
+	payload []byte
+}
+
+// readGroups reads n groups of tr and returns their frames by sequence.
+func readGroups(t *testing.T, ctx context.Context, tr *moqt.TrackReader, base time.Time, n int) map[moqt.GroupSequence][]testFrame {
+	t.Helper()
+
+	// Groups may arrive in any order.
+	groups := make(map[moqt.GroupSequence][]testFrame)
+	for range n {
+		group, err := tr.AcceptGroup(ctx)
+		require.NoError(t, err)
+		r := loc.NewFrameReader(group)
+		var frames []testFrame
+		for {
+			h, payload, err := r.ReadFrame()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			frames = append(frames, testFrame{
+				elapsed: h.Timestamp.Sub(base),
+				config:  slices.Clone(h.VideoConfig),
+				payload: slices.Clone(payload),
+			})
+		}
+		groups[group.GroupSequence()] = frames
+	}
+	return groups
+}
+
+// avc returns the length-prefixed encoding of nalus.
+func avc(nalus ...[]byte) []byte {
+	var b []byte
+	for _, n := range nalus {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(n)))
+		b = append(b, n...)
+	}
+	return b
+}
+
+func TestPublisher_H264(t *testing.T) {
+	base := time.UnixMicro(1_700_000_000_000_000)
+	fragments := fuA(testIDR, 4)
+	packets := [][]byte{
+		// The slice before the first IDR is dropped.
+		marshalPacket(true, 1, 0, 1, testSlice),
+		marshalPacket(false, 2, 3000, 1, stapA(testSPS, testPPS)),
+		marshalPacket(false, 3, 3000, 1, fragments[0]),
+		marshalPacket(true, 4, 3000, 1, fragments[1]),
+		marshalPacket(true, 5, 6000, 1, testSlice),
+		// After a loss, slices are dropped until the next IDR.
+		marshalPacket(true, 7, 12000, 1, testSlice),
+		marshalPacket(true, 8, 15000, 1, testIDR),
+		// A malformed packet is reported, and the stream goes on.
+		{0x80},
+	}
+
+	mux := moqt.NewTrackMux(0)
+	mux.PublishFunc(t.Context(), "/live", func(tw *moqt.TrackWriter) {
+		p := NewPublisher(tw, CodecH264)
+		p.now = func() time.Time { return base }
+		for _, data := range packets {
+			err := p.WriteRTP(data)
+			if err != nil && !errors.Is(err, ErrMalformedPacket) {
+				return
+			}
+		}
+		_ = p.Close()
+		<-tw.Context().Done()
+	})
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	tr, err := sess.Subscribe(ctx, "/live", "video", nil)
+	require.NoError(t, err)
+	defer tr.Close()
+
+	// LOC timestamps have a precision of a microsecond.
+	config := AVCDecoderConfig(testSPS, testPPS)
+	groups := readGroups(t, ctx, tr, base, 2)
+	require.Len(t, groups, 2)
+	var got [][]testFrame
+	for _, seq := range slices.Sorted(maps.Keys(groups)) {
+		got = append(got, groups[seq])
+	}
+	assert.Equal(t, [][]testFrame{
+		{
+			{elapsed: 33333 * time.Microsecond, config: config, payload: avc(testIDR)},
+			{elapsed: 66666 * time.Microsecond, payload: avc(testSlice)},
+		},
+		{
+			{elapsed: 166666 * time.Microsecond, config: config, payload: avc(testIDR)},
+		},
+	}, got)
+}
+
+func TestPublisher_Serve(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	served := make(chan error, 1)
+	mux := moqt.NewTrackMux(0)
+	mux.PublishFunc(t.Context(), "/live", func(tw *moqt.TrackWriter) {
+		if err := tw.WriteInfo(moqt.PublishInfo{}); err != nil {
+			served <- err
+			return
+		}
+		served <- NewPublisher(tw, CodecOpus).Serve(conn)
+	})
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	tr, err := sess.Subscribe(ctx, "/live", "audio", nil)
+	require.NoError(t, err)
+	defer tr.Close()
+
+	base := time.Now()
+	sender, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer sender.Close()
+	for i, payload := range []string{"a", "b"} {
+		_, err := sender.Write(marshalPacket(true, uint16(i), uint32(i)*960, 1, []byte(payload)))
+		require.NoError(t, err)
+	}
+
+	// Every Opus frame is a group, 20 ms apart.
+	groups := readGroups(t, ctx, tr, base, 2)
+	var payloads []string
+	var elapsed []time.Duration
+	for _, seq := range slices.Sorted(maps.Keys(groups)) {
+		require.Len(t, groups[seq], 1)
+		payloads = append(payloads, string(groups[seq][0].payload))
+		elapsed = append(elapsed, groups[seq][0].elapsed)
+	}
+	assert.Equal(t, []string{"a", "b"}, payloads)
+	assert.Equal(t, 20*time.Millisecond, elapsed[1]-elapsed[0])
+
+	// Serve returns once conn is closed.
+	require.NoError(t, conn.Close())
+	select {
+	case err := <-served:
+		assert.ErrorIs(t, err, net.ErrClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+	}
+}
@@ -0,0 +1,268 @@
+package rtp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/loc"
+)
+
+// DefaultMTU is the default maximum size of the packets of a Packetizer. It
+// leaves room for the headers of SRTP, UDP and IPv6 in common paths.
+const DefaultMTU = 1200
+
+// Default payload types of a Packetizer, as commonly negotiated in WebRTC.
+const (
+	DefaultH264PayloadType uint8 = 96
+	DefaultOpusPayloadType uint8 = 111
+)
+
+// Packetizer packetizes LOC frames, such as the ones written by a Publisher,
+// into an RTP stream.
+//
+// For H.264, the payload is expected in the length-prefixed format of
+// ISO/IEC 14496-15 with 4-byte lengths. The SPS and PPS of the last
+// VideoConfig are sent before each IDR picture, NAL units that do not fit in
+// a packet are fragmented with FU-A, and the marker bit is set on the last
+// packet of each frame. For Opus, each frame is one packet.
+//
+// RTP timestamps follow the Timestamp of the frames; a frame without one
+// keeps the timestamp of the previous frame.
+//
+// A Packetizer is not safe for concurrent use.
+type Packetizer struct {
+	// SSRC, PayloadType and MTU are set by NewPacketizer and may be changed
+	// before the first frame.
+	SSRC        uint32
+	PayloadType uint8
+	MTU         int
+
+	codec Codec
+
+	started bool
+	seq     uint16
+	// base is the capture time of the RTP timestamp baseTS.
+	base   time.Time
+	baseTS uint32
+	lastTS uint32
+
+	sps, pps [][]byte
+}
+
+// NewPacketizer returns a Packetizer for codec, with a random SSRC, initial
+// sequence number and initial timestamp as recommended by RFC 3550.
+func NewPacketizer(codec Codec) *Packetizer {
+	p := &Packetizer{
+		SSRC:   rand.Uint32(),
+		MTU:    DefaultMTU,
+		codec:  codec,
+		seq:    uint16(rand.Uint32()),
+		baseTS: rand.Uint32(),
+	}
+	switch codec {
+	case CodecH264:
+		p.PayloadType = DefaultH264PayloadType
+	case CodecOpus:
+		p.PayloadType = DefaultOpusPayloadType
+	}
+	return p
+}
+
+// Packetize appends the RTP packets of the LOC frame with header h and
+// payload to dst and returns the extended slice. It returns an error
+// wrapping ErrMalformedPacket for an H.264 payload or VideoConfig that
+// cannot be decoded.
+func (p *Packetizer) Packetize(dst [][]byte, h loc.Header, payload []byte) ([][]byte, error) {
+	ts := p.timestamp(h.Timestamp)
+
+	switch p.codec {
+	case CodecH264:
+		if len(h.VideoConfig) > 0 {
+			sps, pps, err := ParseAVCDecoderConfig(h.VideoConfig)
+			if err != nil {
+				return dst, err
+			}
+			p.sps, p.pps = cloneNALUs(sps), cloneNALUs(pps)
+		}
+		nalus, err := splitAVC(payload)
+		if err != nil {
+			return dst, err
+		}
+		if len(nalus) == 0 {
+			return dst, nil
+		}
+		for _, nalu := range nalus {
+			if nalu[0]&0x1F == nalIDR {
+				nalus = slices.Concat(p.sps, p.pps, nalus)
+				break
+			}
+		}
+		for i, nalu := range nalus {
+			dst = p.appendNALU(dst, ts, nalu, i == len(nalus)-1)
+		}
+		return dst, nil
+	case CodecOpus:
+		if len(payload) == 0 {
+			return dst, nil
+		}
+		return append(dst, p.packet(ts, false, payload)), nil
+	default:
+		return dst, fmt.Errorf("rtp: unsupported codec %v", p.codec)
+	}
+}
+
+// appendNALU appends the packets of an H.264 NAL unit: a single NAL unit
+// packet if it fits, and FU-A fragments otherwise.
+func (p *Packetizer) appendNALU(dst [][]byte, ts uint32, nalu []byte, last bool) [][]byte {
+	size := p.MTU - 12
+	if len(nalu) <= size {
+		return append(dst, p.packet(ts, last, nalu))
+	}
+
+	indicator := nalu[0]&0xE0 | nalFUA
+	typ := nalu[0] & 0x1F
+	data := nalu[1:]
+	chunk := size - 2
+	for start := true; len(data) > 0; start = false {
+		n := min(chunk, len(data))
+		header := typ
+		if start {
+			header |= 0x80
+		}
+		end := n == len(data)
+		if end {
+			header |= 0x40
+		}
+		payload := append([]byte{indicator, header}, data[:n]...)
+		dst = append(dst, p.packet(ts, last && end, payload))
+		data = data[n:]
+	}
+	return dst
+}
+
+// packet encodes the next packet of the stream.
+func (p *Packetizer) packet(ts uint32, marker bool, payload []byte) []byte {
+	pkt := Packet{
+		Marker:         marker,
+		PayloadType:    p.PayloadType,
+		SequenceNumber: p.seq,
+		Timestamp:      ts,
+		SSRC:           p.SSRC,
+		Payload:        payload,
+	}
+	p.seq++
+	return pkt.Append(make([]byte, 0, 12+len(payload)))
+}
+
+// timestamp returns the RTP timestamp of the capture time t.
+func (p *Packetizer) timestamp(t time.Time) uint32 {
+	if t.IsZero() {
+		return p.lastTS
+	}
+	if !p.started {
+		p.started = true
+		p.base = t
+	}
+	elapsed := t.Sub(p.base)
+	p.lastTS = p.baseTS + uint32(int64(elapsed)*int64(p.codec.ClockRate())/int64(time.Second))
+	return p.lastTS
+}
+
+// splitAVC returns the NAL units of an access unit with 4-byte lengths. The
+// returned slices alias b.
+func splitAVC(b []byte) ([][]byte, error) {
+	var nalus [][]byte
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("%w: truncated NAL unit length", ErrMalformedPacket)
+		}
+		n := binary.BigEndian.Uint32(b)
+		if n == 0 || uint64(len(b)-4) < uint64(n) {
+			return nil, fmt.Errorf("%w: invalid NAL unit length %d", ErrMalformedPacket, n)
+		}
+		nalus = append(nalus, b[4:4+n])
+		b = b[4+n:]
+	}
+	return nalus, nil
+}
+
+func cloneNALUs(nalus [][]byte) [][]byte {
+	clones := make([][]byte, len(nalus))
+	for i, nalu := range nalus {
+		clones[i] = append([]byte(nil), nalu...)
+	}
+	return clones
+}
+
+// Subscriber plays a track published with LOC packaging, such as by a
+// Publisher, as an RTP stream. It is the counterpart of Publisher for
+// gateways to RTP receivers and WebRTC peers.
+//
+// Groups are played in order, one at a time: a group older than the last
+// one played is skipped.
+type Subscriber struct {
+	*Packetizer
+
+	tr *moqt.TrackReader
+}
+
+// NewSubscriber returns a Subscriber playing tr as an RTP stream of codec.
+func NewSubscriber(tr *moqt.TrackReader, codec Codec) *Subscriber {
+	return &Subscriber{Packetizer: NewPacketizer(codec), tr: tr}
+}
+
+// Serve writes the RTP packets of the track to w, one packet per Write call
+// as on a connected UDP socket, until ctx ends, the track ends or a write
+// fails. Frames that cannot be packetized are skipped. It does not close
+// the track.
+func (s *Subscriber) Serve(ctx context.Context, w io.Writer) error {
+	var (
+		started bool
+		last    moqt.GroupSequence
+		packets [][]byte
+	)
+	for {
+		group, err := s.tr.AcceptGroup(ctx)
+		if err != nil {
+			return err
+		}
+		if started && group.GroupSequence() <= last {
+			group.CancelRead(moqt.ExpiredGroupErrorCode)
+			continue
+		}
+		started = true
+		last = group.GroupSequence()
+
+		frames := loc.NewFrameReader(group)
+		for {
+			h, payload, err := frames.ReadFrame()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				if errors.Is(err, loc.ErrMalformedHeader) {
+					continue
+				}
+				// The rest of the group is lost; go on with the next one.
+				break
+			}
+			packets, err = s.Packetize(packets[:0], h, payload)
+			if err != nil {
+				continue
+			}
+			for _, packet := range packets {
+				if _, err := w.Write(packet); err != nil {
+					group.CancelRead(moqt.InternalGroupErrorCode)
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package rtp
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/loc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketizer_H264(t *testing.T) {
+	p := NewPacketizer(CodecH264)
+	p.MTU = 12 + 4
+	base := time.Now()
+	config := AVCDecoderConfig(testSPS, testPPS)
+
+	var (
+		d       H264Depacketizer
+		aus     []AccessUnit
+		markers int
+		packets [][]byte
+		err     error
+	)
+	frames := []struct {
+		h       loc.Header
+		payload []byte
+	}{
+		{loc.Header{Timestamp: base, VideoConfig: config}, avc(testIDR)},
+		{loc.Header{Timestamp: base.Add(40 * time.Millisecond)}, avc(testSlice)},
+	}
+	for _, f := range frames {
+		packets, err = p.Packetize(packets[:0], f.h, f.payload)
+		require.NoError(t, err)
+		for _, data := range packets {
+			require.LessOrEqual(t, len(data), p.MTU)
+			var pkt Packet
+			require.NoError(t, pkt.Unmarshal(data))
+			assert.Equal(t, p.SSRC, pkt.SSRC)
+			assert.Equal(t, DefaultH264PayloadType, pkt.PayloadType)
+			if pkt.Marker {
+				markers++
+			}
+			aus, err = d.Push(aus, &pkt)
+			require.NoError(t, err)
+		}
+	}
+
+	// The decoder configuration is sent in band before the IDR picture,
+	// which is fragmented to fit the MTU.
+	require.Len(t, aus, 2)
+	assert.Equal(t, 2, markers)
+	assert.Equal(t, [][]byte{testSPS, testPPS, testIDR}, aus[0].NALUs)
+	assert.True(t, aus[0].Keyframe)
+	assert.Equal(t, [][]byte{testSlice}, aus[1].NALUs)
+	assert.Equal(t, uint32(3600), aus[1].Timestamp-aus[0].Timestamp)
+
+	_, err = p.Packetize(nil, loc.Header{}, []byte{0, 0, 0, 9, 1})
+	assert.ErrorIs(t, err, ErrMalformedPacket)
+}
+
+func TestPacketizer_Opus(t *testing.T) {
+	p := NewPacketizer(CodecOpus)
+	base := time.Now()
+
+	var got []Packet
+	for i, payload := range []string{"a", "b"} {
+		packets, err := p.Packetize(nil, loc.Header{Timestamp: base.Add(time.Duration(i) * 20 * time.Millisecond)}, []byte(payload))
+		require.NoError(t, err)
+		require.Len(t, packets, 1)
+		var pkt Packet
+		require.NoError(t, pkt.Unmarshal(packets[0]))
+		got = append(got, pkt)
+	}
+	assert.Equal(t, "b", string(got[1].Payload))
+	assert.Equal(t, got[0].SequenceNumber+1, got[1].SequenceNumber)
+	assert.Equal(t, uint32(960), got[1].Timestamp-got[0].Timestamp)
+}
+
+// packetWriter records the packets written to it.
+type packetWriter struct {
+	mu      sync.Mutex
+	packets []Packet
+	written chan struct{}
+}
+
+func (w *packetWriter) Write(b []byte) (int, error) {
+	var pkt Packet
+	if err := pkt.Unmarshal(bytes.Clone(b)); err != nil {
+		return 0, err
+	}
+	w.mu.Lock()
+	w.packets = append(w.packets, pkt)
+	w.mu.Unlock()
+	w.written <- struct{}{}
+	return len(b), nil
+}
+
+func TestSubscriber_Serve(t *testing.T) {
+	base := time.Now()
+	mux := moqt.NewTrackMux(0)
+	mux.PublishFunc(t.Context(), "/live", func(tw *moqt.TrackWriter) {
+		for i, payload := range []string{"a", "b"} {
+			group, err := tw.OpenGroup()
+			if err != nil {
+				return
+			}
+			_ = loc.NewFrameWriter(group).WriteFrame(loc.Header{Timestamp: base.Add(time.Duration(i) * 20 * time.Millisecond)}, []byte(payload))
+			_ = group.Close()
+		}
+		<-tw.Context().Done()
+	})
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	tr, err := sess.Subscribe(ctx, "/live", "audio", nil)
+	require.NoError(t, err)
+	defer tr.Close()
+
+	w := &packetWriter{written: make(chan struct{}, 2)}
+	served := make(chan error, 1)
+	go func() {
+		served <- NewSubscriber(tr, CodecOpus).Serve(ctx, w)
+	}()
+	// A group arriving after a newer one is skipped, so the last group is
+	// the last one played.
+	for last := ""; last != "b"; {
+		select {
+		case <-w.written:
+		case <-ctx.Done():
+			t.Fatal("packets not written")
+		}
+		w.mu.Lock()
+		last = string(w.packets[len(w.packets)-1].Payload)
+		w.mu.Unlock()
+	}
+	cancel()
+	assert.ErrorIs(t, <-served, context.Canceled)
+}
@@ -0,0 +1,314 @@
+package msf
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// TrackQuery describes the kind of track a subscriber wants, such as
+// "video up to 720p" or "English audio". Zero-valued fields do not constrain
+// the selection.
+type TrackQuery struct {
+	// Role restricts the selection to tracks with this role. Tracks without an
+	// explicit role are matched by their media fields: width or height for
+	// video, sample rate or channel configuration for audio.
+	Role Role
+	// Language restricts the selection to tracks whose language tag equals
+	// Language or starts with Language followed by a hyphen, compared
+	// case-insensitively, so that "en" matches "en-US".
+	Language string
+	// Codec restricts the selection to tracks whose codec string starts with
+	// Codec, so that "avc1" matches "avc1.64001f".
+	Codec string
+	// MaxHeight excludes tracks taller than MaxHeight pixels.
+	MaxHeight int64
+	// MaxBitrate excludes tracks whose bitrate exceeds MaxBitrate bits per second.
+	MaxBitrate int64
+}
+
+// matches reports whether track satisfies every constraint of q.
+func (q TrackQuery) matches(track Track) bool {
+	if track.Packaging == PackagingMediaTimeline || track.Packaging == PackagingEventTimeline {
+		return false
+	}
+	if q.Role != "" && trackRole(track) != q.Role {
+		return false
+	}
+	if q.Language != "" && !matchLanguage(track.Language, q.Language) {
+		return false
+	}
+	if q.Codec != "" && !strings.HasPrefix(track.Codec, q.Codec) {
+		return false
+	}
+	if q.MaxHeight > 0 && track.Height != nil && *track.Height > q.MaxHeight {
+		return false
+	}
+	if q.MaxBitrate > 0 && track.Bitrate != nil && *track.Bitrate > q.MaxBitrate {
+		return false
+	}
+	return true
+}
+
+// better reports whether a is preferred over b. Larger pictures win, then
+// higher bitrates; otherwise the earlier catalog entry is kept.
+func better(a, b Track) bool {
+	if ah, bh := valueOrZero(a.Height), valueOrZero(b.Height); ah != bh {
+		return ah > bh
+	}
+	return valueOrZero(a.Bitrate) > valueOrZero(b.Bitrate)
+}
+
+// trackRole returns the explicit role of track, or infers one from its media fields.
+func trackRole(track Track) Role {
+	switch {
+	case track.Role != "":
+		return track.Role
+	case track.Width != nil || track.Height != nil:
+		return RoleVideo
+	case track.SampleRate != nil || track.ChannelConfig != "":
+		return RoleAudio
+	default:
+		return ""
+	}
+}
+
+// matchLanguage reports whether tag equals want or is a subtag of want.
+func matchLanguage(tag, want string) bool {
+	if len(tag) < len(want) || !strings.EqualFold(tag[:len(want)], want) {
+		return false
+	}
+	return len(tag) == len(want) || tag[len(want)] == '-'
+}
+
+func valueOrZero(v *int64) int64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// SelectTrack returns the catalog track that best satisfies q.
+// Among matching tracks the one with the largest height is chosen, then the
+// one with the highest bitrate. Timeline tracks are never selected.
+func (c Catalog) SelectTrack(q TrackQuery) (Track, bool) {
+	var (
+		best  Track
+		found bool
+	)
+	for _, track := range c.Tracks {
+		if !q.matches(track) {
+			continue
+		}
+		if !found || better(track, best) {
+			best = track
+			found = true
+		}
+	}
+	if !found {
+		return Track{}, false
+	}
+	return best.Clone(), true
+}
+
+// SelectionChange reports how the tracks chosen by a TrackSelection changed
+// after a catalog update.
+type SelectionChange struct {
+	// Added lists tracks that became selected.
+	Added []Track
+	// Removed lists tracks that are no longer selected.
+	Removed []Track
+}
+
+// IsEmpty reports whether the change neither adds nor removes tracks.
+func (c SelectionChange) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// TrackSelection resolves a fixed set of queries against a catalog and keeps
+// the result current as catalog snapshots and deltas arrive. Each query
+// selects at most one track; queries resolving to the same track share it.
+//
+// A TrackSelection is safe for concurrent use.
+type TrackSelection struct {
+	mu sync.Mutex
+
+	queries  []TrackQuery
+	catalog  Catalog
+	selected []Track
+}
+
+// NewTrackSelection returns a selection for the given queries with an empty catalog.
+func NewTrackSelection(queries ...TrackQuery) *TrackSelection {
+	return &TrackSelection{queries: slices.Clone(queries)}
+}
+
+// Catalog returns a deep copy of the catalog the selection was last resolved against.
+func (s *TrackSelection) Catalog() Catalog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.catalog.Clone()
+}
+
+// Selected returns the currently selected tracks in query order, without duplicates.
+func (s *TrackSelection) Selected() []Track {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneTracks(s.selected)
+}
+
+// Update resolves the queries against an independent catalog snapshot and
+// reports which tracks were added to or removed from the selection.
+func (s *TrackSelection) Update(catalog Catalog) (SelectionChange, error) {
+	if err := catalog.Validate(); err != nil {
+		return SelectionChange{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resolveLocked(catalog.Clone()), nil
+}
+
+// ApplyDelta applies a catalog delta to the current catalog and re-resolves
+// the queries. Update must have been called with an initial snapshot first.
+// The selection is unchanged if the delta cannot be applied.
+func (s *TrackSelection) ApplyDelta(delta CatalogDelta) (SelectionChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	catalog, err := s.catalog.ApplyDelta(delta)
+	if err != nil {
+		return SelectionChange{}, err
+	}
+	return s.resolveLocked(catalog), nil
+}
+
+// resolveLocked replaces the catalog and selected tracks while s.mu is held.
+func (s *TrackSelection) resolveLocked(catalog Catalog) SelectionChange {
+	selected := make([]Track, 0, len(s.queries))
+	seen := make(map[TrackID]struct{}, len(s.queries))
+	for _, q := range s.queries {
+		track, ok := catalog.SelectTrack(q)
+		if !ok {
+			continue
+		}
+		id := track.ID(catalog.DefaultNamespace)
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		selected = append(selected, track)
+	}
+
+	previous := make(map[TrackID]Track, len(s.selected))
+	for _, track := range s.selected {
+		previous[track.ID(s.catalog.DefaultNamespace)] = track
+	}
+
+	var change SelectionChange
+	for _, track := range selected {
+		id := track.ID(catalog.DefaultNamespace)
+		if _, ok := previous[id]; ok {
+			delete(previous, id)
+			continue
+		}
+		change.Added = append(change.Added, track.Clone())
+	}
+	for _, track := range s.selected {
+		if _, ok := previous[track.ID(s.catalog.DefaultNamespace)]; ok {
+			change.Removed = append(change.Removed, track.Clone())
+		}
+	}
+
+	s.catalog = catalog
+	s.selected = selected
+	return change
+}
+
+// SubscribeFunc opens a subscription for a selected catalog track, typically
+// by calling moqt.Session.Subscribe with the track's broadcast path and name.
+type SubscribeFunc func(ctx context.Context, track Track) (*moqt.TrackReader, error)
+
+// SelectionSubscriber keeps one subscription open for every track of a
+// TrackSelection, subscribing to added tracks and closing removed ones.
+//
+// A SelectionSubscriber is safe for concurrent use.
+type SelectionSubscriber struct {
+	// Subscribe opens a subscription for a newly selected track.
+	Subscribe SubscribeFunc
+
+	mu      sync.Mutex
+	readers map[TrackID]*moqt.TrackReader
+}
+
+// Apply closes the readers of removed tracks and subscribes to added tracks.
+// Tracks that fail to subscribe are skipped and reported in the returned
+// error; the other changes are still applied.
+func (s *SelectionSubscriber) Apply(ctx context.Context, defaultNamespace string, change SelectionChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, track := range change.Removed {
+		id := track.ID(defaultNamespace)
+		if reader, ok := s.readers[id]; ok {
+			if reader != nil {
+				_ = reader.Close()
+			}
+			delete(s.readers, id)
+		}
+	}
+
+	var errs []error
+	for _, track := range change.Added {
+		id := track.ID(defaultNamespace)
+		if _, ok := s.readers[id]; ok {
+			continue
+		}
+		if s.Subscribe == nil {
+			errs = append(errs, errors.New("msf: selection subscriber has no subscribe function"))
+			break
+		}
+		reader, err := s.Subscribe(ctx, track)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if s.readers == nil {
+			s.readers = make(map[TrackID]*moqt.TrackReader)
+		}
+		s.readers[id] = reader
+	}
+
+	return errors.Join(errs...)
+}
+
+// Reader returns the open reader for the track identified by id.
+func (s *SelectionSubscriber) Reader(id TrackID) (*moqt.TrackReader, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reader, ok := s.readers[id]
+	return reader, ok
+}
+
+// Close closes every open reader.
+func (s *SelectionSubscriber) Close() error {
+	s.mu.Lock()
+	readers := s.readers
+	s.readers = nil
+	s.mu.Unlock()
+
+	var errs []error
+	for _, reader := range readers {
+		if reader == nil {
+			continue
+		}
+		if err := reader.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,159 @@
+package msf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selectionTestCatalog() Catalog {
+	return Catalog{
+		Version: 1,
+		Tracks: []Track{
+			{Name: "video-1080", Packaging: PackagingLOC, IsLive: new(true), Role: RoleVideo, Codec: "avc1.640028", Height: new(int64(1080)), Bitrate: new(int64(6_000_000))},
+			{Name: "video-720", Packaging: PackagingLOC, IsLive: new(true), Codec: "avc1.64001f", Width: new(int64(1280)), Height: new(int64(720)), Bitrate: new(int64(3_000_000))},
+			{Name: "video-480", Packaging: PackagingLOC, IsLive: new(true), Role: RoleVideo, Codec: "av01.0.04M.08", Height: new(int64(480)), Bitrate: new(int64(1_000_000))},
+			{Name: "audio-en", Packaging: PackagingLOC, IsLive: new(true), Role: RoleAudio, Language: "en-US", Codec: "opus", Bitrate: new(int64(64_000))},
+			{Name: "audio-ja", Packaging: PackagingLOC, IsLive: new(true), SampleRate: new(int64(48_000)), Language: "ja", Codec: "opus"},
+			{Name: "timeline", Packaging: PackagingMediaTimeline, IsLive: new(true), MimeType: "application/json", Depends: []string{"video-720"}},
+		},
+	}
+}
+
+func TestCatalogSelectTrack(t *testing.T) {
+	tests := map[string]struct {
+		query    TrackQuery
+		expected string
+		found    bool
+	}{
+		"highest video":       {query: TrackQuery{Role: RoleVideo}, expected: "video-1080", found: true},
+		"720p video":          {query: TrackQuery{Role: RoleVideo, MaxHeight: 720}, expected: "video-720", found: true},
+		"bitrate cap":         {query: TrackQuery{Role: RoleVideo, MaxBitrate: 2_000_000}, expected: "video-480", found: true},
+		"codec prefix":        {query: TrackQuery{Role: RoleVideo, Codec: "av01"}, expected: "video-480", found: true},
+		"english audio":       {query: TrackQuery{Role: RoleAudio, Language: "en"}, expected: "audio-en", found: true},
+		"inferred audio role": {query: TrackQuery{Role: RoleAudio, Language: "JA"}, expected: "audio-ja", found: true},
+		"language subtag":     {query: TrackQuery{Role: RoleAudio, Language: "e"}, found: false},
+		"no match":            {query: TrackQuery{Role: RoleVideo, MaxHeight: 240}, found: false},
+	}
+
+	catalog := selectionTestCatalog()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			track, ok := catalog.SelectTrack(tt.query)
+			assert.Equal(t, tt.found, ok)
+			assert.Equal(t, tt.expected, track.Name)
+		})
+	}
+}
+
+func TestTrackSelection_Update(t *testing.T) {
+	selection := NewTrackSelection(
+		TrackQuery{Role: RoleVideo, MaxHeight: 720},
+		TrackQuery{Role: RoleAudio, Language: "en"},
+	)
+
+	change, err := selection.Update(selectionTestCatalog())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"video-720", "audio-en"}, trackNames(change.Added))
+	assert.Empty(t, change.Removed)
+	assert.Equal(t, []string{"video-720", "audio-en"}, trackNames(selection.Selected()))
+
+	// Re-resolving the same catalog changes nothing.
+	change, err = selection.Update(selectionTestCatalog())
+	require.NoError(t, err)
+	assert.True(t, change.IsEmpty())
+
+	_, err = selection.Update(Catalog{})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"video-720", "audio-en"}, trackNames(selection.Selected()))
+}
+
+func TestTrackSelection_ApplyDelta(t *testing.T) {
+	selection := NewTrackSelection(
+		TrackQuery{Role: RoleVideo, MaxHeight: 720},
+		TrackQuery{Role: RoleAudio, Language: "en"},
+	)
+	_, err := selection.Update(selectionTestCatalog())
+	require.NoError(t, err)
+
+	delta := CatalogDelta{
+		RemoveTracks: []TrackRef{{Name: "timeline"}, {Name: "video-720"}},
+		AddTracks: []Track{
+			{Name: "video-540", Packaging: PackagingLOC, IsLive: new(true), Role: RoleVideo, Height: new(int64(540))},
+		},
+	}
+	change, err := selection.ApplyDelta(delta)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"video-540"}, trackNames(change.Added))
+	assert.Equal(t, []string{"video-720"}, trackNames(change.Removed))
+	assert.Equal(t, []string{"video-540", "audio-en"}, trackNames(selection.Selected()))
+
+	_, err = selection.ApplyDelta(CatalogDelta{RemoveTracks: []TrackRef{{Name: "missing"}}})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"video-540", "audio-en"}, trackNames(selection.Selected()))
+}
+
+func TestTrackSelection_SharedTrack(t *testing.T) {
+	selection := NewTrackSelection(TrackQuery{Role: RoleVideo}, TrackQuery{Codec: "avc1"})
+
+	change, err := selection.Update(selectionTestCatalog())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"video-1080"}, trackNames(change.Added))
+}
+
+func TestSelectionSubscriber_Apply(t *testing.T) {
+	var subscribed []string
+	subscriber := &SelectionSubscriber{
+		Subscribe: func(ctx context.Context, track Track) (*moqt.TrackReader, error) {
+			subscribed = append(subscribed, track.Name)
+			if track.Name == "broken" {
+				return nil, errors.New("subscribe failed")
+			}
+			return nil, nil
+		},
+	}
+
+	err := subscriber.Apply(context.Background(), "", SelectionChange{
+		Added: []Track{{Name: "video"}, {Name: "audio"}, {Name: "broken"}},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"video", "audio", "broken"}, subscribed)
+
+	_, ok := subscriber.Reader(TrackID{Namespace: inheritedNamespaceSentinel, Name: "video"})
+	assert.True(t, ok)
+	_, ok = subscriber.Reader(TrackID{Namespace: inheritedNamespaceSentinel, Name: "broken"})
+	assert.False(t, ok)
+
+	// Already subscribed tracks are not subscribed again.
+	subscribed = nil
+	err = subscriber.Apply(context.Background(), "", SelectionChange{
+		Added:   []Track{{Name: "video"}},
+		Removed: []Track{{Name: "audio"}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, subscribed)
+	_, ok = subscriber.Reader(TrackID{Namespace: inheritedNamespaceSentinel, Name: "audio"})
+	assert.False(t, ok)
+
+	require.NoError(t, subscriber.Close())
+	_, ok = subscriber.Reader(TrackID{Namespace: inheritedNamespaceSentinel, Name: "video"})
+	assert.False(t, ok)
+}
+
+func TestSelectionSubscriber_NoSubscribeFunc(t *testing.T) {
+	var subscriber SelectionSubscriber
+	err := subscriber.Apply(context.Background(), "", SelectionChange{Added: []Track{{Name: "video"}}})
+	assert.Error(t, err)
+}
+
+func trackNames(tracks []Track) []string {
+	names := make([]string, 0, len(tracks))
+	for _, track := range tracks {
+		names = append(names, track.Name)
+	}
+	return names
+}
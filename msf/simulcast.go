@@ -0,0 +1,270 @@
+package msf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// maxSimulcastKeyframes is the number of recent keyframes whose group
+// sequence a Simulcast remembers for renditions that reach them later.
+const maxSimulcastKeyframes = 16
+
+// ErrSimulcastClosed is returned by Simulcast.WriteFrame once the simulcast
+// is closed.
+var ErrSimulcastClosed = errors.New("msf: simulcast closed")
+
+// SimulcastConfig configures a Simulcast.
+type SimulcastConfig struct {
+	// AltGroup is the alternate group of the renditions in the catalog,
+	// which tells subscribers they may switch between them. It is set on
+	// the renditions without one. If zero, defaults to 1.
+	AltGroup int64
+
+	// Tolerance is how far apart the timestamps of keyframes of different
+	// renditions may be and still start groups of the same sequence. If
+	// zero, keyframes are aligned only at equal timestamps.
+	Tolerance time.Duration
+}
+
+// altGroup returns the configured alternate group or the default (1).
+func (c *SimulcastConfig) altGroup() int64 {
+	if c != nil && c.AltGroup != 0 {
+		return c.AltGroup
+	}
+	return 1
+}
+
+// tolerance returns the configured tolerance, or 0.
+func (c *SimulcastConfig) tolerance() time.Duration {
+	if c != nil {
+		return c.Tolerance
+	}
+	return 0
+}
+
+// Simulcast publishes renditions of the same content, such as the layers
+// of an ABR ladder, as separate tracks of a Broadcast. Every keyframe starts
+// a new group, and keyframes of the same timestamp start groups of the same
+// sequence on every rendition, so that subscribers can switch renditions at
+// group boundaries without losing their place. The renditions are entries
+// of the catalog of the broadcast, in one alternate group.
+//
+// Each subscription to a rendition starts at its next keyframe, and all of
+// them are fed from WriteFrame; a subscriber that cannot keep up delays the
+// others of its rendition.
+//
+// All methods are safe for concurrent use.
+type Simulcast struct {
+	broadcast *Broadcast
+	tolerance time.Duration
+
+	mu         sync.Mutex
+	renditions map[moqt.TrackName]*rendition
+	closed     bool
+	done       chan struct{}
+
+	// keyframes holds the timestamps and sequences of the latest keyframes,
+	// oldest first, and next is the sequence of the next new keyframe.
+	keyframes []simulcastKeyframe
+	next      moqt.GroupSequence
+}
+
+type simulcastKeyframe struct {
+	timestamp time.Duration
+	sequence  moqt.GroupSequence
+}
+
+// rendition is a track of a Simulcast with its subscriptions.
+type rendition struct {
+	// sequence is the sequence of the current group, or zero before the
+	// first keyframe.
+	sequence moqt.GroupSequence
+	groups   map[*moqt.TrackWriter]*moqt.GroupWriter
+}
+
+// NewSimulcast registers tracks as the renditions of a Simulcast on b. The
+// tracks are added to the catalog with the alternate group of config, and
+// must have distinct names.
+func NewSimulcast(b *Broadcast, config *SimulcastConfig, tracks ...Track) (*Simulcast, error) {
+	if b == nil {
+		return nil, fmt.Errorf("msf: nil broadcast")
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("msf: simulcast without renditions")
+	}
+
+	s := &Simulcast{
+		broadcast:  b,
+		tolerance:  config.tolerance(),
+		renditions: make(map[moqt.TrackName]*rendition, len(tracks)),
+		done:       make(chan struct{}),
+		next:       1,
+	}
+	for _, track := range tracks {
+		name := moqt.TrackName(track.Name)
+		if _, ok := s.renditions[name]; ok {
+			return nil, fmt.Errorf("msf: duplicate simulcast rendition %q", track.Name)
+		}
+		s.renditions[name] = &rendition{groups: make(map[*moqt.TrackWriter]*moqt.GroupWriter)}
+	}
+
+	altGroup := config.altGroup()
+	for i, track := range tracks {
+		track = track.Clone()
+		if track.AltGroup == nil {
+			track.AltGroup = &altGroup
+		}
+		if err := b.RegisterTrack(track, moqt.TrackHandlerFunc(s.serveRendition)); err != nil {
+			for _, registered := range tracks[:i] {
+				b.RemoveTrack(moqt.TrackName(registered.Name))
+			}
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// WriteFrame writes frame, of media time timestamp, to every subscription
+// to the rendition name. A keyframe starts a new group, of the sequence of
+// the keyframes of other renditions at the same timestamp, if any. Frames
+// before the first keyframe of a rendition, or of a subscription, are
+// discarded. A subscription failing the write is closed with an error.
+//
+// frame is only read, and may be released or reused once WriteFrame
+// returns.
+func (s *Simulcast) WriteFrame(name moqt.TrackName, timestamp time.Duration, keyframe bool, frame *moqt.Frame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSimulcastClosed
+	}
+	r, ok := s.renditions[name]
+	if !ok {
+		return fmt.Errorf("msf: unknown simulcast rendition %q", name)
+	}
+
+	if keyframe {
+		r.sequence = s.sequenceLocked(timestamp, r.sequence)
+		for tw, group := range r.groups {
+			if group != nil {
+				_ = group.Close()
+			}
+			group, err := tw.OpenGroupAt(r.sequence)
+			if err != nil {
+				// A subscriber that narrowed its range, such as to switch
+				// renditions, ends once past it.
+				if errors.Is(err, moqt.ErrGroupOutOfRange) {
+					_ = tw.Close()
+				} else {
+					tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
+				}
+				delete(r.groups, tw)
+				continue
+			}
+			r.groups[tw] = group
+		}
+	}
+	if r.sequence == 0 {
+		return nil
+	}
+
+	for tw, group := range r.groups {
+		if group == nil {
+			continue
+		}
+		if err := group.WriteFrame(frame); err != nil {
+			group.CancelWrite(moqt.InternalGroupErrorCode)
+			tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
+			delete(r.groups, tw)
+		}
+	}
+	return nil
+}
+
+// sequenceLocked returns the group sequence of a keyframe at timestamp of
+// a rendition whose current group is current, while s.mu is held. It is the
+// sequence of a later keyframe of another rendition at the same timestamp,
+// or else a new one.
+func (s *Simulcast) sequenceLocked(timestamp time.Duration, current moqt.GroupSequence) moqt.GroupSequence {
+	for _, k := range s.keyframes {
+		if k.sequence > current && (timestamp-k.timestamp).Abs() <= s.tolerance {
+			return k.sequence
+		}
+	}
+
+	seq := s.next
+	s.next++
+	s.keyframes = append(s.keyframes, simulcastKeyframe{timestamp: timestamp, sequence: seq})
+	if len(s.keyframes) > maxSimulcastKeyframes {
+		s.keyframes = s.keyframes[1:]
+	}
+	return seq
+}
+
+// Close ends every subscription to the renditions and removes them from
+// the catalog.
+func (s *Simulcast) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	names := make([]moqt.TrackName, 0, len(s.renditions))
+	for name, r := range s.renditions {
+		names = append(names, name)
+		for tw, group := range r.groups {
+			if group != nil {
+				_ = group.Close()
+			}
+			_ = tw.Close()
+		}
+		clear(r.groups)
+	}
+	s.mu.Unlock()
+
+	for _, name := range names {
+		s.broadcast.RemoveTrack(name)
+	}
+	return nil
+}
+
+// serveRendition serves a subscription to a rendition until it ends or the
+// simulcast is closed.
+func (s *Simulcast) serveRendition(tw *moqt.TrackWriter) {
+	// Accept the subscription before the next keyframe arrives.
+	if err := tw.WriteInfo(moqt.PublishInfo{}); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	r, ok := s.renditions[tw.TrackName]
+	if !ok || s.closed {
+		s.mu.Unlock()
+		tw.CloseWithError(moqt.SubscribeErrorCodeNotFound)
+		return
+	}
+	r.groups[tw] = nil
+	s.mu.Unlock()
+
+	select {
+	case <-tw.Context().Done():
+	case <-s.done:
+	}
+
+	s.mu.Lock()
+	if group, ok := r.groups[tw]; ok {
+		if group != nil {
+			_ = group.Close()
+		}
+		delete(r.groups, tw)
+	}
+	s.mu.Unlock()
+	_ = tw.Close()
+}
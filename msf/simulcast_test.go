@@ -0,0 +1,192 @@
+package msf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func simulcastTracks() []Track {
+	return []Track{
+		{Name: "video-hd", Packaging: PackagingLOC, IsLive: new(true), Height: new(int64(1080))},
+		{Name: "video-sd", Packaging: PackagingLOC, IsLive: new(true), Height: new(int64(480))},
+	}
+}
+
+func TestSimulcast_AlignsGroupsAtKeyframes(t *testing.T) {
+	type keyframe struct {
+		name      moqt.TrackName
+		timestamp time.Duration
+		want      moqt.GroupSequence
+	}
+
+	tests := map[string]struct {
+		tolerance time.Duration
+		keyframes []keyframe
+	}{
+		"same timestamps": {
+			keyframes: []keyframe{
+				{"video-hd", 0, 1}, {"video-sd", 0, 1},
+				{"video-hd", 2 * time.Second, 2}, {"video-sd", 2 * time.Second, 2},
+			},
+		},
+		"lagging rendition": {
+			keyframes: []keyframe{
+				{"video-hd", 0, 1}, {"video-hd", 2 * time.Second, 2},
+				{"video-sd", 0, 1}, {"video-sd", 2 * time.Second, 2},
+			},
+		},
+		"missed keyframe": {
+			keyframes: []keyframe{
+				{"video-hd", 0, 1}, {"video-sd", 0, 1},
+				{"video-hd", 2 * time.Second, 2},
+				{"video-hd", 4 * time.Second, 3}, {"video-sd", 4 * time.Second, 3},
+			},
+		},
+		"extra keyframe": {
+			keyframes: []keyframe{
+				{"video-hd", 0, 1}, {"video-sd", 0, 1},
+				{"video-sd", time.Second, 2},
+				{"video-hd", 2 * time.Second, 3}, {"video-sd", 2 * time.Second, 3},
+			},
+		},
+		"within tolerance": {
+			tolerance: 5 * time.Millisecond,
+			keyframes: []keyframe{
+				{"video-hd", 0, 1}, {"video-sd", 3 * time.Millisecond, 1},
+				{"video-sd", 2 * time.Second, 2}, {"video-hd", 2*time.Second - 2*time.Millisecond, 2},
+			},
+		},
+		"beyond tolerance": {
+			keyframes: []keyframe{
+				{"video-hd", 0, 1}, {"video-sd", time.Millisecond, 2},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := NewBroadcast(Catalog{Version: 1})
+			require.NoError(t, err)
+			s, err := NewSimulcast(b, &SimulcastConfig{Tolerance: tt.tolerance}, simulcastTracks()...)
+			require.NoError(t, err)
+			defer s.Close()
+
+			frame := moqt.NewFrame(0)
+			for i, k := range tt.keyframes {
+				require.NoError(t, s.WriteFrame(k.name, k.timestamp, true, frame))
+				assert.Equal(t, k.want, s.renditions[k.name].sequence, "keyframe %d", i)
+			}
+		})
+	}
+}
+
+func TestNewSimulcast_RegistersCatalogEntries(t *testing.T) {
+	b, err := NewBroadcast(Catalog{Version: 1})
+	require.NoError(t, err)
+	tracks := simulcastTracks()
+	tracks[1].AltGroup = new(int64(9))
+
+	s, err := NewSimulcast(b, &SimulcastConfig{AltGroup: 2}, tracks...)
+	require.NoError(t, err)
+
+	catalog := b.Catalog()
+	require.Len(t, catalog.Tracks, 2)
+	assert.Equal(t, int64(2), *catalog.Tracks[0].AltGroup)
+	assert.Equal(t, int64(9), *catalog.Tracks[1].AltGroup, "an explicit alternate group is kept")
+	assert.Nil(t, tracks[0].AltGroup, "the tracks of the caller are not modified")
+
+	require.NoError(t, s.Close())
+	assert.Empty(t, b.Catalog().Tracks)
+	assert.ErrorIs(t, s.WriteFrame("video-hd", 0, true, moqt.NewFrame(0)), ErrSimulcastClosed)
+}
+
+func TestNewSimulcast_RejectsInvalidInput(t *testing.T) {
+	b, err := NewBroadcast(Catalog{Version: 1})
+	require.NoError(t, err)
+	tracks := simulcastTracks()
+
+	tests := map[string]struct {
+		broadcast *Broadcast
+		tracks    []Track
+	}{
+		"nil broadcast":   {tracks: tracks},
+		"no renditions":   {broadcast: b},
+		"duplicate names": {broadcast: b, tracks: []Track{tracks[0], tracks[0]}},
+		"invalid track":   {broadcast: b, tracks: []Track{tracks[0], {Name: "video-bad"}}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewSimulcast(tt.broadcast, nil, tt.tracks...)
+			assert.Error(t, err)
+			assert.Empty(t, b.Catalog().Tracks, "no rendition is left registered")
+		})
+	}
+
+	s, err := NewSimulcast(b, nil, tracks...)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Error(t, s.WriteFrame("video-4k", 0, true, moqt.NewFrame(0)))
+}
+
+func TestSimulcast_Subscriptions(t *testing.T) {
+	b, err := NewBroadcast(Catalog{Version: 1})
+	require.NoError(t, err)
+	s, err := NewSimulcast(b, nil, simulcastTracks()...)
+	require.NoError(t, err)
+	defer s.Close()
+
+	mux := moqt.NewTrackMux(0)
+	mux.Publish(t.Context(), "/live", b)
+	sess := moqttest.DialMux(t, mux)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	readers := make(map[moqt.TrackName]*moqt.TrackReader)
+	for _, name := range []moqt.TrackName{"video-hd", "video-sd"} {
+		tr, err := sess.Subscribe(ctx, "/live", name, nil)
+		require.NoError(t, err)
+		defer tr.Close()
+		readers[name] = tr
+	}
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.renditions["video-hd"].groups) == 1 && len(s.renditions["video-sd"].groups) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	write := func(name moqt.TrackName, ts time.Duration, keyframe bool, payload string) {
+		frame := moqt.NewFrame(len(payload))
+		_, _ = frame.Write([]byte(payload))
+		require.NoError(t, s.WriteFrame(name, ts, keyframe, frame))
+	}
+	// The delta frame before the first keyframe is discarded.
+	write("video-hd", 0, false, "hd-skip")
+	for i, ts := range []time.Duration{0, 2 * time.Second} {
+		key := []string{"a", "b"}[i]
+		write("video-hd", ts, true, "hd-"+key)
+		write("video-hd", ts+time.Second, false, "hd-"+key+"'")
+		write("video-sd", ts, true, "sd-"+key)
+	}
+
+	// Groups may arrive in any order, but share their sequences across
+	// renditions.
+	for name, tr := range readers {
+		prefix := string(name[len("video-"):])
+		got := make(map[moqt.GroupSequence]string)
+		for range 2 {
+			gr, err := tr.AcceptGroup(ctx)
+			require.NoError(t, err)
+			frame := moqt.NewFrame(0)
+			require.NoError(t, gr.ReadFrame(frame))
+			got[gr.GroupSequence()] = string(frame.Body())
+		}
+		assert.Equal(t, map[moqt.GroupSequence]string{1: prefix + "-a", 2: prefix + "-b"}, got, name)
+	}
+}
@@ -0,0 +1,211 @@
+package msf
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+)
+
+// MediaTimeline is an in-memory index of media timeline entries, typically
+// filled from the records of a mediatimeline track. It translates media and
+// wall-clock times into object locations.
+//
+// Entries are kept ordered by media time. Wall-clock lookups assume that
+// wall-clock time increases with media time, as it does for live content.
+// A MediaTimeline is safe for concurrent use; the zero value is empty.
+type MediaTimeline struct {
+	mu      sync.RWMutex
+	entries []MediaTimelineEntry
+}
+
+// Add records entries in the timeline. An entry with the same media time as
+// an existing one replaces it.
+func (t *MediaTimeline) Add(entries ...MediaTimelineEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, entry := range entries {
+		i, found := slices.BinarySearchFunc(t.entries, entry.MediaTime, func(e MediaTimelineEntry, mediaTime int64) int {
+			return cmp.Compare(e.MediaTime, mediaTime)
+		})
+		if found {
+			t.entries[i] = entry
+			continue
+		}
+		t.entries = slices.Insert(t.entries, i, entry)
+	}
+}
+
+// Len returns the number of entries in the timeline.
+func (t *MediaTimeline) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.entries)
+}
+
+// Entries returns a copy of the timeline entries ordered by media time.
+func (t *MediaTimeline) Entries() []MediaTimelineEntry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return slices.Clone(t.entries)
+}
+
+// AtMediaTime returns the last entry whose media time is at or before
+// mediaTime. It returns false if the timeline is empty or mediaTime precedes
+// every entry.
+func (t *MediaTimeline) AtMediaTime(mediaTime int64) (MediaTimelineEntry, bool) {
+	return t.search(func(e MediaTimelineEntry) bool { return e.MediaTime > mediaTime })
+}
+
+// AtWallclock returns the last entry whose wall-clock time, in milliseconds
+// since the Unix epoch, is at or before wallclock. It returns false if the
+// timeline is empty or wallclock precedes every entry.
+func (t *MediaTimeline) AtWallclock(wallclock int64) (MediaTimelineEntry, bool) {
+	return t.search(func(e MediaTimelineEntry) bool { return e.Wallclock > wallclock })
+}
+
+// search returns the entry preceding the first entry for which after is true.
+func (t *MediaTimeline) search(after func(MediaTimelineEntry) bool) (MediaTimelineEntry, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	i := sort.Search(len(t.entries), func(i int) bool { return after(t.entries[i]) })
+	if i == 0 {
+		return MediaTimelineEntry{}, false
+	}
+	return t.entries[i-1], true
+}
+
+// first returns the earliest entry of the timeline.
+func (t *MediaTimeline) first() (MediaTimelineEntry, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.entries) == 0 {
+		return MediaTimelineEntry{}, false
+	}
+	return t.entries[0], true
+}
+
+// last returns the latest entry of the timeline.
+func (t *MediaTimeline) last() (MediaTimelineEntry, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.entries) == 0 {
+		return MediaTimelineEntry{}, false
+	}
+	return t.entries[len(t.entries)-1], true
+}
+
+// StartGroup translates the start of a subscription by time, as set by a
+// TimeFilter, into the group it starts from, for publishers that record the
+// timeline of a track instead of declaring the times of its groups: the
+// group of the last entry at or before config.StartTime, or of the last
+// entry at least config.StartBehind behind the newest one. Times before the
+// first entry start at the first entry. It returns false if the
+// subscription sets StartGroup, starts by neither time, or the timeline is
+// empty.
+func (t *MediaTimeline) StartGroup(config moqt.SubscribeConfig) (moqt.GroupSequence, bool) {
+	if config.StartGroup != moqt.MinGroupSequence {
+		return 0, false
+	}
+
+	var wallclock int64
+	switch {
+	case !config.StartTime.IsZero():
+		wallclock = config.StartTime.UnixMilli()
+	case config.StartBehind > 0:
+		newest, ok := t.last()
+		if !ok {
+			return 0, false
+		}
+		wallclock = newest.Wallclock - config.StartBehind.Milliseconds()
+	default:
+		return 0, false
+	}
+
+	entry, ok := t.AtWallclock(wallclock)
+	if !ok {
+		if entry, ok = t.first(); !ok {
+			return 0, false
+		}
+	}
+	return moqt.GroupSequence(entry.Location.GroupID), true
+}
+
+// ErrEmptyTimeline is returned when a TimeFilter by media time is applied
+// with a timeline without entries.
+var ErrEmptyTimeline = errors.New("msf: empty media timeline")
+
+// TimeFilter selects where a subscription starts by time instead of by group
+// sequence, such as "from 30 seconds ago" or "from 14:00 UTC". It is created
+// with StartAt, StartAgo or StartAtMediaTime; the zero TimeFilter starts at
+// the newest group, as a subscription without a filter does.
+//
+// A TimeFilter is sent with the subscription as SubscribeConfig.StartTime
+// or StartBehind, and translated into a group by the party that knows the
+// times of the groups: relays from the group times declared with
+// TrackWriter.OpenGroupWithTime, and publishers from the same times or with
+// MediaTimeline.StartGroup. Only a media time is translated by the
+// subscriber, into the wall-clock time of its entry in the broadcast's
+// mediatimeline track.
+type TimeFilter struct {
+	wallclock    time.Time
+	behind       time.Duration
+	mediaTime    int64
+	hasMediaTime bool
+}
+
+// StartAt returns a TimeFilter starting at the content produced at t.
+func StartAt(t time.Time) TimeFilter {
+	return TimeFilter{wallclock: t}
+}
+
+// StartAgo returns a TimeFilter starting at the content produced d before
+// the newest. Zero starts at the newest group.
+func StartAgo(d time.Duration) TimeFilter {
+	return TimeFilter{behind: d}
+}
+
+// StartAtMediaTime returns a TimeFilter starting at mediaTime, in the units
+// of the media timestamps of the timeline it is applied with.
+func StartAtMediaTime(mediaTime int64) TimeFilter {
+	return TimeFilter{mediaTime: mediaTime, hasMediaTime: true}
+}
+
+// Apply sets the start of config to the filter: StartTime or StartBehind,
+// clearing StartGroup, which would take precedence. A filter by media time
+// is converted with timeline, in which times before the first entry resolve
+// to the first entry; other filters ignore it.
+func (f TimeFilter) Apply(config *moqt.SubscribeConfig, timeline *MediaTimeline) error {
+	if config == nil {
+		return fmt.Errorf("msf: nil subscribe config")
+	}
+	if f.behind < 0 {
+		return fmt.Errorf("msf: time filter must not start in the future")
+	}
+
+	start := f.wallclock
+	if f.hasMediaTime {
+		if timeline == nil {
+			return ErrEmptyTimeline
+		}
+		entry, ok := timeline.AtMediaTime(f.mediaTime)
+		if !ok {
+			if entry, ok = timeline.first(); !ok {
+				return ErrEmptyTimeline
+			}
+		}
+		start = time.UnixMilli(entry.Wallclock)
+	}
+
+	config.StartGroup = moqt.MinGroupSequence
+	config.StartTime = start
+	config.StartBehind = f.behind
+	return nil
+}
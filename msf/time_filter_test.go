@@ -0,0 +1,148 @@
+package msf
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMediaTimeline returns a timeline with one entry every two seconds of
+// media time, starting at 14:00:00 UTC, with one group per entry.
+func newTestMediaTimeline(t *testing.T) (*MediaTimeline, time.Time) {
+	t.Helper()
+
+	start := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	var records []MediaTimelineEntry
+	require.NoError(t, json.Unmarshal([]byte(`[
+		[4000, [3, 0], 1767362404000],
+		[0, [1, 0], 1767362400000],
+		[2000, [2, 0], 1767362402000]
+	]`), &records))
+
+	timeline := &MediaTimeline{}
+	timeline.Add(records...)
+	return timeline, start
+}
+
+func TestMediaTimeline_Add(t *testing.T) {
+	timeline, _ := newTestMediaTimeline(t)
+
+	entries := timeline.Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, []int64{0, 2000, 4000}, []int64{entries[0].MediaTime, entries[1].MediaTime, entries[2].MediaTime})
+
+	// An entry with an existing media time replaces it.
+	timeline.Add(MediaTimelineEntry{MediaTime: 2000, Location: Location{GroupID: 9}})
+	assert.Equal(t, 3, timeline.Len())
+	entry, ok := timeline.AtMediaTime(2000)
+	require.True(t, ok)
+	assert.Equal(t, uint64(9), entry.Location.GroupID)
+}
+
+func TestMediaTimeline_Lookup(t *testing.T) {
+	timeline, start := newTestMediaTimeline(t)
+
+	tests := map[string]struct {
+		lookup func() (MediaTimelineEntry, bool)
+		group  uint64
+		found  bool
+	}{
+		"media time exact":   {lookup: func() (MediaTimelineEntry, bool) { return timeline.AtMediaTime(2000) }, group: 2, found: true},
+		"media time between": {lookup: func() (MediaTimelineEntry, bool) { return timeline.AtMediaTime(3999) }, group: 2, found: true},
+		"media time after":   {lookup: func() (MediaTimelineEntry, bool) { return timeline.AtMediaTime(10_000) }, group: 3, found: true},
+		"media time before":  {lookup: func() (MediaTimelineEntry, bool) { return timeline.AtMediaTime(-1) }, found: false},
+		"wallclock between": {lookup: func() (MediaTimelineEntry, bool) {
+			return timeline.AtWallclock(start.Add(3 * time.Second).UnixMilli())
+		}, group: 2, found: true},
+		"wallclock before": {lookup: func() (MediaTimelineEntry, bool) {
+			return timeline.AtWallclock(start.Add(-time.Second).UnixMilli())
+		}, found: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			entry, ok := tt.lookup()
+			assert.Equal(t, tt.found, ok)
+			assert.Equal(t, tt.group, entry.Location.GroupID)
+		})
+	}
+}
+
+func TestTimeFilter_Apply(t *testing.T) {
+	timeline, start := newTestMediaTimeline(t)
+
+	tests := map[string]struct {
+		filter    TimeFilter
+		timeline  *MediaTimeline
+		startTime time.Time
+		behind    time.Duration
+		expectErr error
+	}{
+		"wallclock":        {filter: StartAt(start.Add(4 * time.Second)), startTime: start.Add(4 * time.Second)},
+		"ago":              {filter: StartAgo(2 * time.Second), behind: 2 * time.Second},
+		"now":              {filter: StartAgo(0)},
+		"zero":             {filter: TimeFilter{}},
+		"media time":       {filter: StartAtMediaTime(2500), timeline: timeline, startTime: start.Add(2 * time.Second)},
+		"media time early": {filter: StartAtMediaTime(-10), timeline: timeline, startTime: start},
+		"media time empty": {filter: StartAtMediaTime(0), timeline: &MediaTimeline{}, expectErr: ErrEmptyTimeline},
+		"media time nil":   {filter: StartAtMediaTime(0), expectErr: ErrEmptyTimeline},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := &moqt.SubscribeConfig{Priority: 3, StartGroup: 7, StartBehind: time.Hour}
+			err := tt.filter.Apply(config, tt.timeline)
+			if tt.expectErr != nil {
+				assert.ErrorIs(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, moqt.MinGroupSequence, config.StartGroup)
+			assert.True(t, tt.startTime.Equal(config.StartTime), "start time %v", config.StartTime)
+			assert.Equal(t, tt.behind, config.StartBehind)
+			assert.Equal(t, moqt.TrackPriority(3), config.Priority)
+		})
+	}
+
+	assert.Error(t, StartAgo(time.Second).Apply(nil, timeline))
+	assert.Error(t, StartAgo(-time.Second).Apply(&moqt.SubscribeConfig{}, timeline))
+}
+
+func TestMediaTimeline_StartGroup(t *testing.T) {
+	timeline, start := newTestMediaTimeline(t)
+
+	tests := map[string]struct {
+		config moqt.SubscribeConfig
+		group  moqt.GroupSequence
+		found  bool
+	}{
+		"start time":         {config: moqt.SubscribeConfig{StartTime: start.Add(3 * time.Second)}, group: 2, found: true},
+		"start time early":   {config: moqt.SubscribeConfig{StartTime: start.Add(-time.Minute)}, group: 1, found: true},
+		"start behind":       {config: moqt.SubscribeConfig{StartBehind: 2 * time.Second}, group: 2, found: true},
+		"start behind first": {config: moqt.SubscribeConfig{StartBehind: time.Minute}, group: 1, found: true},
+		"start group set":    {config: moqt.SubscribeConfig{StartGroup: 5, StartBehind: time.Second}},
+		"no time":            {config: moqt.SubscribeConfig{}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			group, ok := timeline.StartGroup(tt.config)
+			assert.Equal(t, tt.found, ok)
+			assert.Equal(t, tt.group, group)
+		})
+	}
+
+	// A filter applied by the subscriber is translated by the publisher.
+	config := &moqt.SubscribeConfig{}
+	require.NoError(t, StartAtMediaTime(4000).Apply(config, timeline))
+	group, ok := timeline.StartGroup(*config)
+	assert.True(t, ok)
+	assert.Equal(t, moqt.GroupSequence(3), group)
+
+	_, ok = (&MediaTimeline{}).StartGroup(moqt.SubscribeConfig{StartBehind: time.Second})
+	assert.False(t, ok)
+}
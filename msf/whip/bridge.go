@@ -0,0 +1,321 @@
+package whip
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/rtp"
+)
+
+// Track names of the broadcasts published and played by a Bridge.
+const (
+	VideoTrackName moqt.TrackName = "video"
+	AudioTrackName moqt.TrackName = "audio"
+)
+
+// sdpContentType is the media type of the offers and answers.
+const sdpContentType = "application/sdp"
+
+// maxOfferSize is the largest SDP offer read from a request.
+const maxOfferSize = 64 << 10
+
+// Subscriber subscribes to the tracks played with WHEP. It is implemented
+// by *moqt.Session.
+type Subscriber interface {
+	Subscribe(ctx context.Context, path moqt.BroadcastPath, name moqt.TrackName, config *moqt.SubscribeConfig) (*moqt.TrackReader, error)
+}
+
+// Bridge terminates WHIP ingest and WHEP playback, converting between
+// WebRTC media and MOQ tracks with the msf/rtp package:
+//
+//   - POST /whip/<path> with an SDP offer publishes the media of the client
+//     as the broadcast <path> on TrackMux, with H.264 video on the "video"
+//     track and Opus audio on the "audio" track, in LOC packaging.
+//   - POST /whep/<path> with an SDP offer subscribes to the tracks of the
+//     broadcast <path> with Subscriber, for the negotiated codecs, and sends
+//     them to the client.
+//   - DELETE on the resource URL returned in the Location header of either
+//     ends the session.
+//
+// The paths are relative to the prefix the Bridge is mounted at, such as
+// with http.StripPrefix. Trickle ICE and ICE restarts with PATCH are not
+// supported; the Engine gathers its candidates before answering.
+//
+// The bridge only converts the transport: the media are neither decoded
+// nor transcoded, so WHEP clients receive the codecs WHIP clients sent.
+type Bridge struct {
+	// Engine negotiates the WebRTC connections. It is required.
+	Engine Engine
+
+	// TrackMux is the mux the WHIP broadcasts are published on. If nil,
+	// WHIP requests are refused.
+	TrackMux *moqt.TrackMux
+
+	// Subscriber subscribes to the WHEP broadcasts, such as a session to a
+	// relay. If nil, WHEP requests are refused.
+	Subscriber Subscriber
+
+	mu       sync.Mutex
+	sessions map[string]func()
+}
+
+// ServeHTTP serves the WHIP and WHEP endpoints and session resources.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kind, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch kind {
+	case "whip", "whep":
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Accept-Post", sdpContentType)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "OPTIONS, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if rest == "" {
+			http.Error(w, "missing broadcast path", http.StatusNotFound)
+			return
+		}
+		path := moqt.BroadcastPath("/" + rest)
+		if kind == "whip" {
+			b.serveWHIP(w, r, path)
+		} else {
+			b.serveWHEP(w, r, path)
+		}
+	case "sessions":
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", "DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !b.closeSession(rest) {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Close ends every session.
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	sessions := b.sessions
+	b.sessions = nil
+	b.mu.Unlock()
+
+	for _, end := range sessions {
+		end()
+	}
+	return nil
+}
+
+func (b *Bridge) serveWHIP(w http.ResponseWriter, r *http.Request, path moqt.BroadcastPath) {
+	if b.TrackMux == nil {
+		http.Error(w, "WHIP is not served", http.StatusForbidden)
+		return
+	}
+	offer, ok := readOffer(w, r)
+	if !ok {
+		return
+	}
+	conn, err := b.Engine.Ingest(r.Context(), offer)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot answer the offer: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := newIngest(conn)
+	b.TrackMux.PublishFunc(ctx, path, in.serveTrack)
+
+	id := b.addSession(func() {
+		cancel()
+		_ = conn.Close()
+	})
+	go func() {
+		in.run()
+		cancel()
+		_ = conn.Close()
+		b.removeSession(id)
+	}()
+
+	writeAnswer(w, r, id, conn.Answer())
+}
+
+func (b *Bridge) serveWHEP(w http.ResponseWriter, r *http.Request, path moqt.BroadcastPath) {
+	if b.Subscriber == nil {
+		http.Error(w, "WHEP is not served", http.StatusForbidden)
+		return
+	}
+	offer, ok := readOffer(w, r)
+	if !ok {
+		return
+	}
+	conn, err := b.Engine.Egress(r.Context(), offer)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot answer the offer: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		subs    []*rtp.Subscriber
+		codecs  []rtp.Codec
+		readers []*moqt.TrackReader
+	)
+	closeReaders := func() {
+		for _, tr := range readers {
+			_ = tr.Close()
+		}
+	}
+	for _, codec := range conn.Codecs() {
+		name, ok := trackName(codec)
+		if !ok {
+			continue
+		}
+		tr, err := b.Subscriber.Subscribe(r.Context(), path, name, nil)
+		if err != nil {
+			closeReaders()
+			_ = conn.Close()
+			status := http.StatusBadGateway
+			if subErr, ok := errors.AsType[*moqt.SubscribeError](err); ok && subErr.SubscribeErrorCode() == moqt.SubscribeErrorCodeNotFound {
+				status = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf("cannot subscribe to %s: %v", name, err), status)
+			return
+		}
+		readers = append(readers, tr)
+		subs = append(subs, rtp.NewSubscriber(tr, codec))
+		codecs = append(codecs, codec)
+	}
+	if len(subs) == 0 {
+		_ = conn.Close()
+		http.Error(w, "no supported codec negotiated", http.StatusNotAcceptable)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	id := b.addSession(cancel)
+	var wg sync.WaitGroup
+	for i, sub := range subs {
+		codec := codecs[i]
+		wg.Go(func() {
+			_ = sub.Serve(ctx, rtpWriter{conn: conn, codec: codec})
+			cancel()
+		})
+	}
+	go func() {
+		select {
+		case <-conn.Done():
+		case <-ctx.Done():
+		}
+		cancel()
+		wg.Wait()
+		closeReaders()
+		_ = conn.Close()
+		b.removeSession(id)
+	}()
+
+	writeAnswer(w, r, id, conn.Answer())
+}
+
+// addSession registers a session ended by end and returns its ID.
+func (b *Bridge) addSession(end func()) string {
+	id := rand.Text()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sessions == nil {
+		b.sessions = make(map[string]func())
+	}
+	b.sessions[id] = end
+	return id
+}
+
+func (b *Bridge) removeSession(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, id)
+}
+
+// closeSession ends the session id and reports whether it existed.
+func (b *Bridge) closeSession(id string) bool {
+	b.mu.Lock()
+	end, ok := b.sessions[id]
+	delete(b.sessions, id)
+	b.mu.Unlock()
+
+	if ok {
+		end()
+	}
+	return ok
+}
+
+// readOffer reads the SDP offer of r, or writes an error response.
+func readOffer(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != sdpContentType {
+		http.Error(w, "content type must be "+sdpContentType, http.StatusUnsupportedMediaType)
+		return "", false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOfferSize))
+	if err != nil {
+		http.Error(w, "cannot read the offer", http.StatusBadRequest)
+		return "", false
+	}
+	return string(body), true
+}
+
+// writeAnswer writes the 201 Created response of the session id.
+func writeAnswer(w http.ResponseWriter, r *http.Request, id, answer string) {
+	w.Header().Set("Content-Type", sdpContentType)
+	w.Header().Set("Location", sessionPath(r, id))
+	w.WriteHeader(http.StatusCreated)
+	_, _ = io.WriteString(w, answer)
+}
+
+// sessionPath returns the path of the resource of the session id, under the
+// prefix the Bridge is mounted at.
+func sessionPath(r *http.Request, id string) string {
+	requestPath, _, _ := strings.Cut(r.RequestURI, "?")
+	prefix, ok := strings.CutSuffix(requestPath, r.URL.Path)
+	if !ok {
+		prefix = ""
+	}
+	return prefix + "/sessions/" + id
+}
+
+// trackName returns the name of the track of codec.
+func trackName(codec rtp.Codec) (moqt.TrackName, bool) {
+	switch codec {
+	case rtp.CodecH264:
+		return VideoTrackName, true
+	case rtp.CodecOpus:
+		return AudioTrackName, true
+	default:
+		return "", false
+	}
+}
+
+// rtpWriter writes the packets of a Subscriber to the media of codec.
+type rtpWriter struct {
+	conn  EgressConn
+	codec rtp.Codec
+}
+
+func (w rtpWriter) Write(packet []byte) (int, error) {
+	if err := w.conn.WriteRTP(w.codec, packet); err != nil {
+		return 0, err
+	}
+	return len(packet), nil
+}
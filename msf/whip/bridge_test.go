@@ -0,0 +1,188 @@
+package whip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qumo-dev/gomoqt/internal/moqttest"
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/rtp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConn is a Conn answering "answer".
+type fakeConn struct {
+	codecs []rtp.Codec
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newFakeConn(codecs ...rtp.Codec) *fakeConn {
+	return &fakeConn{codecs: codecs, done: make(chan struct{})}
+}
+
+func (c *fakeConn) Answer() string        { return "answer" }
+func (c *fakeConn) Codecs() []rtp.Codec   { return c.codecs }
+func (c *fakeConn) Done() <-chan struct{} { return c.done }
+
+func (c *fakeConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+// fakeIngestConn returns the packets sent on its channel.
+type fakeIngestConn struct {
+	*fakeConn
+	packets chan []byte
+}
+
+func (c *fakeIngestConn) ReadRTP() (rtp.Codec, []byte, error) {
+	select {
+	case packet := <-c.packets:
+		return rtp.CodecOpus, packet, nil
+	case <-c.done:
+		return 0, nil, errors.New("closed")
+	}
+}
+
+// fakeEgressConn sends the packets written to its channel.
+type fakeEgressConn struct {
+	*fakeConn
+	packets chan rtp.Packet
+}
+
+func (c *fakeEgressConn) WriteRTP(codec rtp.Codec, packet []byte) error {
+	var p rtp.Packet
+	if err := p.Unmarshal(append([]byte(nil), packet...)); err != nil {
+		return err
+	}
+	select {
+	case c.packets <- p:
+	default:
+	}
+	return nil
+}
+
+type fakeEngine struct {
+	ingest *fakeIngestConn
+	egress *fakeEgressConn
+}
+
+func (e *fakeEngine) Ingest(ctx context.Context, offer string) (IngestConn, error) {
+	if offer != "offer" {
+		return nil, errors.New("invalid offer")
+	}
+	return e.ingest, nil
+}
+
+func (e *fakeEngine) Egress(ctx context.Context, offer string) (EgressConn, error) {
+	return e.egress, nil
+}
+
+// post posts an SDP offer to h and returns the response.
+func post(h http.Handler, target, contentType, offer string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(offer))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBridge_ServeHTTP(t *testing.T) {
+	b := &Bridge{Engine: &fakeEngine{}}
+
+	tests := map[string]struct {
+		method      string
+		target      string
+		contentType string
+		want        int
+	}{
+		"unknown endpoint":   {method: http.MethodPost, target: "/other/live", want: http.StatusNotFound},
+		"missing path":       {method: http.MethodPost, target: "/whip/", contentType: sdpContentType, want: http.StatusNotFound},
+		"GET":                {method: http.MethodGet, target: "/whip/live", want: http.StatusMethodNotAllowed},
+		"OPTIONS":            {method: http.MethodOptions, target: "/whep/live", want: http.StatusNoContent},
+		"WHIP not served":    {method: http.MethodPost, target: "/whip/live", contentType: sdpContentType, want: http.StatusForbidden},
+		"WHEP not served":    {method: http.MethodPost, target: "/whep/live", contentType: sdpContentType, want: http.StatusForbidden},
+		"unknown session":    {method: http.MethodDelete, target: "/sessions/abc", want: http.StatusNotFound},
+		"PATCH of a session": {method: http.MethodPatch, target: "/sessions/abc", want: http.StatusMethodNotAllowed},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader("offer"))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			b.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestBridge_WHIPToWHEP(t *testing.T) {
+	engine := &fakeEngine{
+		ingest: &fakeIngestConn{fakeConn: newFakeConn(rtp.CodecOpus), packets: make(chan []byte)},
+		egress: &fakeEgressConn{fakeConn: newFakeConn(rtp.CodecOpus), packets: make(chan rtp.Packet, 16)},
+	}
+	mux := moqt.NewTrackMux(0)
+	sess := moqttest.DialMux(t, mux)
+	b := &Bridge{Engine: engine, TrackMux: mux, Subscriber: sess}
+	defer b.Close()
+	h := http.StripPrefix("/bridge", b)
+
+	rec := post(h, "/bridge/whip/live", "text/plain", "offer")
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	rec = post(h, "/bridge/whip/live", sdpContentType, "bad")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = post(h, "/bridge/whip/live", sdpContentType, "offer")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "answer", rec.Body.String())
+	assert.Equal(t, sdpContentType, rec.Header().Get("Content-Type"))
+	whipSession := rec.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(whipSession, "/bridge/sessions/"), whipSession)
+
+	rec = post(h, "/bridge/whep/live", sdpContentType, "offer")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	whepSession := rec.Header().Get("Location")
+
+	// Packets sent by the WHIP client reach the WHEP client.
+	var seq uint16
+	var got rtp.Packet
+	for received := false; !received; {
+		packet := rtp.Packet{PayloadType: 111, SequenceNumber: seq, Timestamp: uint32(seq) * 960, SSRC: 1, Payload: []byte("opus")}
+		seq++
+		select {
+		case engine.ingest.packets <- packet.Append(nil):
+		case <-time.After(5 * time.Second):
+			t.Fatal("packet not read")
+		}
+		select {
+		case got = <-engine.egress.packets:
+			received = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.Equal(t, "opus", string(got.Payload))
+
+	// DELETE ends the sessions.
+	for _, session := range []string{whepSession, whipSession} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, session, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	for _, done := range []<-chan struct{}{engine.egress.Done(), engine.ingest.Done()} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection not closed")
+		}
+	}
+}
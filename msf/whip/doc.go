@@ -0,0 +1,23 @@
+// Package whip bridges WebRTC and MOQ: it terminates WHIP ingest (RFC 9725)
+// and republishes the media as MOQ tracks, and serves WHEP playback from
+// MOQ subscriptions, so that WebRTC and MOQ clients can share a deployment
+// without a media server converting the transport.
+//
+// A Bridge is an http.Handler serving the WHIP and WHEP endpoints. It leaves
+// ICE, DTLS and SRTP to an Engine, a wrapper of a WebRTC stack such as Pion,
+// and converts the RTP packets of the negotiated media with the msf/rtp
+// package: H.264 video on the "video" track and Opus audio on the "audio"
+// track, with LOC packaging.
+//
+//	b := &whip.Bridge{
+//		Engine:     engine,
+//		TrackMux:   mux,  // WHIP broadcasts are published here
+//		Subscriber: sess, // WHEP broadcasts are subscribed from here
+//	}
+//	defer b.Close()
+//	http.Handle("/webrtc/", http.StripPrefix("/webrtc", b))
+//
+// A WHIP client then publishes the broadcast "/live" by posting its offer
+// to /webrtc/whip/live, and a WHEP client plays it by posting its offer to
+// /webrtc/whep/live.
+package whip
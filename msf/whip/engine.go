@@ -0,0 +1,58 @@
+package whip
+
+import (
+	"context"
+
+	"github.com/qumo-dev/gomoqt/msf/rtp"
+)
+
+// Engine is the WebRTC stack of a Bridge, such as a wrapper of Pion. It
+// answers the SDP offers of WHIP and WHEP clients, runs ICE, DTLS and SRTP,
+// and exchanges the RTP packets of the negotiated media with the bridge.
+//
+// An Engine should only accept the codecs of rtp.Codec, with at most one
+// audio and one video media per connection.
+type Engine interface {
+	// Ingest answers the offer of a WHIP client, which sends media. ctx is
+	// the context of the HTTP request; the connection outlives it.
+	Ingest(ctx context.Context, offer string) (IngestConn, error)
+
+	// Egress answers the offer of a WHEP client, which receives media. ctx
+	// is the context of the HTTP request; the connection outlives it.
+	Egress(ctx context.Context, offer string) (EgressConn, error)
+}
+
+// Conn is a WebRTC connection negotiated by an Engine.
+type Conn interface {
+	// Answer returns the SDP answer to the offer of the client.
+	Answer() string
+
+	// Codecs returns the codecs of the negotiated media.
+	Codecs() []rtp.Codec
+
+	// Done returns a channel that is closed when the connection ends, such
+	// as when ICE fails or the client closes it.
+	Done() <-chan struct{}
+
+	// Close closes the connection.
+	Close() error
+}
+
+// IngestConn is the connection of a WHIP client.
+type IngestConn interface {
+	Conn
+
+	// ReadRTP returns the next RTP packet received and the codec of its
+	// media. The packet is valid until the next call. It returns an error
+	// once the connection ends.
+	ReadRTP() (rtp.Codec, []byte, error)
+}
+
+// EgressConn is the connection of a WHEP client.
+type EgressConn interface {
+	Conn
+
+	// WriteRTP sends an RTP packet on the media of codec. The Engine
+	// rewrites its payload type to the negotiated one.
+	WriteRTP(codec rtp.Codec, packet []byte) error
+}
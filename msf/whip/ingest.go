@@ -0,0 +1,92 @@
+package whip
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/qumo-dev/gomoqt/moqt"
+	"github.com/qumo-dev/gomoqt/msf/rtp"
+)
+
+// ingest publishes the media of a WHIP connection. Each subscription to a
+// track gets an rtp.Publisher of its own, fed with every packet of the
+// media, so that it starts at the next keyframe.
+type ingest struct {
+	conn   IngestConn
+	codecs []rtp.Codec
+	done   chan struct{}
+
+	mu         sync.Mutex
+	publishers map[rtp.Codec]map[*moqt.TrackWriter]*rtp.Publisher
+}
+
+func newIngest(conn IngestConn) *ingest {
+	return &ingest{
+		conn:       conn,
+		codecs:     conn.Codecs(),
+		done:       make(chan struct{}),
+		publishers: make(map[rtp.Codec]map[*moqt.TrackWriter]*rtp.Publisher),
+	}
+}
+
+// run publishes the packets of the connection until it ends.
+func (in *ingest) run() {
+	defer close(in.done)
+	for {
+		codec, packet, err := in.conn.ReadRTP()
+		if err != nil {
+			return
+		}
+
+		in.mu.Lock()
+		for tw, p := range in.publishers[codec] {
+			if err := p.WriteRTP(packet); err != nil && !errors.Is(err, rtp.ErrMalformedPacket) {
+				tw.CloseWithError(moqt.SubscribeErrorCodeInternal)
+				delete(in.publishers[codec], tw)
+			}
+		}
+		in.mu.Unlock()
+	}
+}
+
+// serveTrack serves a subscription to a track of the broadcast.
+func (in *ingest) serveTrack(tw *moqt.TrackWriter) {
+	codec, ok := in.codec(tw.TrackName)
+	if !ok {
+		tw.CloseWithError(moqt.SubscribeErrorCodeNotFound)
+		return
+	}
+	// Accept the subscription before the first keyframe arrives.
+	if err := tw.WriteInfo(moqt.PublishInfo{}); err != nil {
+		return
+	}
+
+	p := rtp.NewPublisher(tw, codec)
+	in.mu.Lock()
+	if in.publishers[codec] == nil {
+		in.publishers[codec] = make(map[*moqt.TrackWriter]*rtp.Publisher)
+	}
+	in.publishers[codec][tw] = p
+	in.mu.Unlock()
+
+	select {
+	case <-tw.Context().Done():
+	case <-in.done:
+	}
+
+	in.mu.Lock()
+	delete(in.publishers[codec], tw)
+	_ = p.Close()
+	in.mu.Unlock()
+	_ = tw.Close()
+}
+
+// codec returns the negotiated codec of the track name.
+func (in *ingest) codec(name moqt.TrackName) (rtp.Codec, bool) {
+	for _, codec := range in.codecs {
+		if n, ok := trackName(codec); ok && n == name {
+			return codec, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,26 @@
+package transport
+
+import "context"
+
+// DatagramConn is implemented by connections that can send and receive
+// unreliable datagrams: QUIC datagrams (RFC 9221) for native QUIC and HTTP
+// datagrams (RFC 9297) for WebTransport.
+type DatagramConn interface {
+	// SendDatagram sends b as a single datagram. It returns a
+	// *DatagramTooLargeError if b does not fit into a datagram.
+	SendDatagram(b []byte) error
+
+	// ReceiveDatagram waits for the next datagram.
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
+
+// DatagramSizeConn is implemented by datagram connections that report the
+// largest datagram they can currently send.
+type DatagramSizeConn interface {
+	DatagramConn
+
+	// MaxDatagramSize returns the largest payload SendDatagram accepts now,
+	// or 0 if it is unknown. It follows the path MTU discovered by QUIC, so
+	// it may change during the lifetime of the connection.
+	MaxDatagramSize() int64
+}
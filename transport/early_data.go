@@ -0,0 +1,17 @@
+package transport
+
+// EarlyDataConn is implemented by client connections of QUIC backends that
+// send data in 0-RTT, before the handshake completes, when they resume a
+// TLS session. Data sent in 0-RTT may be replayed by an attacker, and is
+// lost if the server rejects it, so it must only carry idempotent requests.
+type EarlyDataConn interface {
+	StreamConn
+
+	// HandshakeComplete is closed once the handshake is complete and, if
+	// the server rejected 0-RTT, new streams can be opened again.
+	HandshakeComplete() <-chan struct{}
+
+	// Used0RTT reports whether the server accepted the data sent in 0-RTT.
+	// It is only meaningful once HandshakeComplete is closed.
+	Used0RTT() bool
+}
@@ -0,0 +1,13 @@
+package transport
+
+// PrioritizedSendStream is implemented by send streams of QUIC backends that
+// schedule the data of the streams of a connection by priority. Data of
+// streams with higher priorities is sent first; streams of equal priority
+// share the connection.
+type PrioritizedSendStream interface {
+	SendStream
+
+	// SetPriority sets the send priority of the stream. It may be called
+	// again at any time to change it.
+	SetPriority(priority int64)
+}